@@ -0,0 +1,319 @@
+// Package breaking compares two versions of a schema, given as the files of
+// their descriptor sets, and reports the changes that break peers still
+// using the old version: on the wire, for the binary encoding, or in JSON,
+// for the encoding of protojson and the plugins in this repo.
+package breaking
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+// Change is one breaking change.
+type Change struct {
+	// File is the name of the old file declaring Subject.
+	File string
+	// Subject is the full name of the element changed, e.g.
+	// "example.v1.User.email".
+	Subject string
+	// Message describes the change.
+	Message string
+	// Wire reports whether the change breaks the binary encoding.
+	Wire bool
+	// JSON reports whether the change breaks the JSON encoding.
+	JSON bool
+}
+
+func (c *Change) String() string {
+	var breaks []string
+	if c.Wire {
+		breaks = append(breaks, "wire")
+	}
+	if c.JSON {
+		breaks = append(breaks, "JSON")
+	}
+	return fmt.Sprintf("%s: %s: %s (breaks %s)", c.File, c.Subject, c.Message, strings.Join(breaks, ", "))
+}
+
+// Compare returns the changes from old to new breaking the elements of old,
+// in the order they are declared. A type, service or method missing from
+// new is reported as removed, and its elements are not compared.
+func Compare(old, new []*descriptor.FileDescriptorProto) []*Change {
+	c := &comparer{new: newIndex(new)}
+	for _, f := range old {
+		c.file = f.GetName()
+		scope := strings.TrimSuffix("."+f.GetPackage(), ".")
+		c.messages(scope, f.GetMessageType())
+		c.enums(scope, f.GetEnumType())
+		for _, svc := range f.GetService() {
+			c.service(scope, svc)
+		}
+	}
+	return c.changes
+}
+
+// index holds the types and services of a schema by full proto name, with
+// a leading dot.
+type index struct {
+	messages map[string]*descriptor.DescriptorProto
+	enums    map[string]*descriptor.EnumDescriptorProto
+	services map[string]*descriptor.ServiceDescriptorProto
+}
+
+func newIndex(files []*descriptor.FileDescriptorProto) *index {
+	idx := &index{
+		messages: make(map[string]*descriptor.DescriptorProto),
+		enums:    make(map[string]*descriptor.EnumDescriptorProto),
+		services: make(map[string]*descriptor.ServiceDescriptorProto),
+	}
+	var walk func(scope string, msgs []*descriptor.DescriptorProto, enums []*descriptor.EnumDescriptorProto)
+	walk = func(scope string, msgs []*descriptor.DescriptorProto, enums []*descriptor.EnumDescriptorProto) {
+		for _, msg := range msgs {
+			name := scope + "." + msg.GetName()
+			idx.messages[name] = msg
+			walk(name, msg.GetNestedType(), msg.GetEnumType())
+		}
+		for _, enum := range enums {
+			idx.enums[scope+"."+enum.GetName()] = enum
+		}
+	}
+	for _, f := range files {
+		scope := strings.TrimSuffix("."+f.GetPackage(), ".")
+		walk(scope, f.GetMessageType(), f.GetEnumType())
+		for _, svc := range f.GetService() {
+			idx.services[scope+"."+svc.GetName()] = svc
+		}
+	}
+	return idx
+}
+
+type comparer struct {
+	new     *index
+	file    string
+	changes []*Change
+}
+
+func (c *comparer) report(subject string, wire, json bool, format string, args ...interface{}) {
+	c.changes = append(c.changes, &Change{
+		File:    c.file,
+		Subject: strings.TrimPrefix(subject, "."),
+		Message: fmt.Sprintf(format, args...),
+		Wire:    wire,
+		JSON:    json,
+	})
+}
+
+func (c *comparer) messages(scope string, msgs []*descriptor.DescriptorProto) {
+	for _, msg := range msgs {
+		name := scope + "." + msg.GetName()
+		next, ok := c.new.messages[name]
+		if !ok {
+			c.report(name, true, true, "message removed")
+			continue
+		}
+		c.fields(name, msg, next)
+		c.messages(name, msg.GetNestedType())
+		c.enums(name, msg.GetEnumType())
+	}
+}
+
+// fields compares the fields of the message name, matched by number.
+func (c *comparer) fields(name string, msg, next *descriptor.DescriptorProto) {
+	byNumber := make(map[int32]*descriptor.FieldDescriptorProto)
+	byName := make(map[string]*descriptor.FieldDescriptorProto)
+	for _, field := range next.GetField() {
+		byNumber[field.GetNumber()] = field
+		byName[field.GetName()] = field
+	}
+	for _, field := range msg.GetField() {
+		subject := name + "." + field.GetName()
+		nf, ok := byNumber[field.GetNumber()]
+		if !ok {
+			if moved, ok := byName[field.GetName()]; ok {
+				c.report(subject, true, false, "field number changed from %d to %d", field.GetNumber(), moved.GetNumber())
+				continue
+			}
+			wire := !reservedNumber(next.GetReservedRange(), field.GetNumber())
+			json := !contains(next.GetReservedName(), field.GetName())
+			if wire || json {
+				c.report(subject, wire, json, "field %d removed", field.GetNumber())
+			}
+			continue
+		}
+		if oldType, newType := fieldType(field), fieldType(nf); oldType != newType {
+			c.report(subject, wireClass(field) != wireClass(nf), true, "field %d type changed from %s to %s", field.GetNumber(), oldType, newType)
+		}
+		if field.GetLabel() != nf.GetLabel() {
+			c.report(subject, true, true, "field %d label changed from %s to %s", field.GetNumber(), label(field), label(nf))
+		}
+		if oldJSON, newJSON := jsonName(field), jsonName(nf); oldJSON != newJSON {
+			c.report(subject, false, true, "field %d JSON name changed from %q to %q", field.GetNumber(), oldJSON, newJSON)
+		} else if field.GetName() != nf.GetName() {
+			// JSON accepts the proto name of a field as well as its JSON
+			// name.
+			c.report(subject, false, true, "field %d renamed to %q", field.GetNumber(), nf.GetName())
+		}
+	}
+}
+
+func (c *comparer) enums(scope string, enums []*descriptor.EnumDescriptorProto) {
+	for _, enum := range enums {
+		name := scope + "." + enum.GetName()
+		next, ok := c.new.enums[name]
+		if !ok {
+			c.report(name, true, true, "enum removed")
+			continue
+		}
+		byNumber := make(map[int32][]string)
+		for _, v := range next.GetValue() {
+			byNumber[v.GetNumber()] = append(byNumber[v.GetNumber()], v.GetName())
+		}
+		for _, v := range enum.GetValue() {
+			// Enum values are scoped like their enum.
+			subject := scope + "." + v.GetName()
+			names, ok := byNumber[v.GetNumber()]
+			if !ok {
+				wire := !reservedEnumNumber(next.GetReservedRange(), v.GetNumber())
+				json := !contains(next.GetReservedName(), v.GetName())
+				if wire || json {
+					c.report(subject, wire, json, "enum value %d removed", v.GetNumber())
+				}
+				continue
+			}
+			if !contains(names, v.GetName()) {
+				c.report(subject, false, true, "enum value %d renamed to %q", v.GetNumber(), names[0])
+			}
+		}
+	}
+}
+
+func (c *comparer) service(scope string, svc *descriptor.ServiceDescriptorProto) {
+	name := scope + "." + svc.GetName()
+	next, ok := c.new.services[name]
+	if !ok {
+		c.report(name, true, true, "service removed")
+		return
+	}
+	methods := make(map[string]*descriptor.MethodDescriptorProto)
+	for _, m := range next.GetMethod() {
+		methods[m.GetName()] = m
+	}
+	for _, m := range svc.GetMethod() {
+		subject := name + "." + m.GetName()
+		nm, ok := methods[m.GetName()]
+		if !ok {
+			c.report(subject, true, true, "method removed")
+			continue
+		}
+		if m.GetInputType() != nm.GetInputType() {
+			c.report(subject, true, true, "request type changed from %s to %s", strings.TrimPrefix(m.GetInputType(), "."), strings.TrimPrefix(nm.GetInputType(), "."))
+		}
+		if m.GetOutputType() != nm.GetOutputType() {
+			c.report(subject, true, true, "response type changed from %s to %s", strings.TrimPrefix(m.GetOutputType(), "."), strings.TrimPrefix(nm.GetOutputType(), "."))
+		}
+		if m.GetClientStreaming() != nm.GetClientStreaming() || m.GetServerStreaming() != nm.GetServerStreaming() {
+			c.report(subject, true, true, "streaming changed from %s to %s", streaming(m), streaming(nm))
+		}
+	}
+}
+
+// fieldType returns the proto type of field: the full name of its message
+// or enum, or the name of its scalar type.
+func fieldType(field *descriptor.FieldDescriptorProto) string {
+	if field.GetTypeName() != "" {
+		return strings.TrimPrefix(field.GetTypeName(), ".")
+	}
+	return strings.ToLower(strings.TrimPrefix(field.GetType().String(), "TYPE_"))
+}
+
+// wireClass returns the class of the encoding of field: fields of the same
+// class read each other's values. Messages only read messages of the same
+// type.
+func wireClass(field *descriptor.FieldDescriptorProto) string {
+	switch field.GetType() {
+	case descriptor.FieldDescriptorProto_TYPE_INT32, descriptor.FieldDescriptorProto_TYPE_INT64,
+		descriptor.FieldDescriptorProto_TYPE_UINT32, descriptor.FieldDescriptorProto_TYPE_UINT64,
+		descriptor.FieldDescriptorProto_TYPE_BOOL, descriptor.FieldDescriptorProto_TYPE_ENUM:
+		return "varint"
+	case descriptor.FieldDescriptorProto_TYPE_SINT32, descriptor.FieldDescriptorProto_TYPE_SINT64:
+		return "zigzag"
+	case descriptor.FieldDescriptorProto_TYPE_FIXED32, descriptor.FieldDescriptorProto_TYPE_SFIXED32:
+		return "fixed32"
+	case descriptor.FieldDescriptorProto_TYPE_FIXED64, descriptor.FieldDescriptorProto_TYPE_SFIXED64:
+		return "fixed64"
+	case descriptor.FieldDescriptorProto_TYPE_STRING, descriptor.FieldDescriptorProto_TYPE_BYTES:
+		return "bytes"
+	}
+	return fieldType(field)
+}
+
+func label(field *descriptor.FieldDescriptorProto) string {
+	return strings.ToLower(strings.TrimPrefix(field.GetLabel().String(), "LABEL_"))
+}
+
+// jsonName returns the JSON name of field, which protoc sets in descriptor
+// sets, or else the lower camel case of its name.
+func jsonName(field *descriptor.FieldDescriptorProto) string {
+	if field.GetJsonName() != "" {
+		return field.GetJsonName()
+	}
+	var b strings.Builder
+	upper := false
+	for _, r := range field.GetName() {
+		switch {
+		case r == '_':
+			upper = true
+		case upper && r >= 'a' && r <= 'z':
+			b.WriteRune(r - 'a' + 'A')
+			upper = false
+		default:
+			b.WriteRune(r)
+			upper = false
+		}
+	}
+	return b.String()
+}
+
+func streaming(m *descriptor.MethodDescriptorProto) string {
+	switch {
+	case m.GetClientStreaming() && m.GetServerStreaming():
+		return "bidirectional"
+	case m.GetClientStreaming():
+		return "client"
+	case m.GetServerStreaming():
+		return "server"
+	}
+	return "unary"
+}
+
+// reservedNumber reports whether n is in ranges, whose ends are exclusive.
+func reservedNumber(ranges []*descriptor.DescriptorProto_ReservedRange, n int32) bool {
+	for _, r := range ranges {
+		if n >= r.GetStart() && n < r.GetEnd() {
+			return true
+		}
+	}
+	return false
+}
+
+// reservedEnumNumber reports whether n is in ranges, whose ends, unlike
+// those of messages, are inclusive.
+func reservedEnumNumber(ranges []*descriptor.EnumDescriptorProto_EnumReservedRange, n int32) bool {
+	for _, r := range ranges {
+		if n >= r.GetStart() && n <= r.GetEnd() {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(list []string, s string) bool {
+	for _, e := range list {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
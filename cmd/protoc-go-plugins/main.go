@@ -0,0 +1,148 @@
+// Command protoc-go-plugins runs the checks of this repo that work on whole
+// schemas rather than on the files of a protoc run.
+//
+// Usage:
+//
+//	protoc-go-plugins breaking --against=old.binpb [--json=false] new.binpb
+//
+// breaking compares two descriptor sets, as written by
+// "protoc --include_imports --descriptor_set_out" or "buf build -o", and
+// lists the changes of new.binpb breaking the wire or JSON compatibility
+// of old.binpb. It exits with status 1 if there are any, so that CI can
+// gate on it.
+//
+//	protoc-go-plugins compat --plugins=clone+validate dir...
+//
+// compat inspects the .pb.go files of the Go package directories, and
+// lists the messages the code of the plugins, named without their
+// protoc-gen-go- prefix, would not compile against, with how to regenerate
+// the packages for them. It exits with status 1 if there are any, so that
+// builds fail before running protoc.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+
+	"github.com/f4tq/protoc-go-plugins/breaking"
+	"github.com/f4tq/protoc-go-plugins/compat"
+)
+
+const usage = `usage: protoc-go-plugins <command> [flags] [args]
+
+commands:
+  breaking --against=old.binpb new.binpb
+      report the changes of new.binpb breaking old.binpb
+  compat --plugins=clone+validate dir...
+      report the messages of the packages the plugins' code would not compile against
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	switch os.Args[1] {
+	case "breaking":
+		os.Exit(runBreaking(os.Args[2:]))
+	case "compat":
+		os.Exit(runCompat(os.Args[2:]))
+	case "help", "-h", "--help":
+		fmt.Print(usage)
+	default:
+		fmt.Fprintf(os.Stderr, "protoc-go-plugins: unknown command %q\n%s", os.Args[1], usage)
+		os.Exit(2)
+	}
+}
+
+// runBreaking runs the breaking command and returns its exit status: 0 if
+// there are no breaking changes, 1 if there are, 2 on error.
+func runBreaking(args []string) int {
+	fs := flag.NewFlagSet("breaking", flag.ContinueOnError)
+	against := fs.String("against", "", "descriptor set of the `baseline` schema")
+	json := fs.Bool("json", true, "also report the changes breaking only the JSON encoding")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: protoc-go-plugins breaking --against=old.binpb [flags] new.binpb")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *against == "" || fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+	old, err := readDescriptorSet(*against)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "protoc-go-plugins: %v\n", err)
+		return 2
+	}
+	next, err := readDescriptorSet(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "protoc-go-plugins: %v\n", err)
+		return 2
+	}
+	status := 0
+	for _, c := range breaking.Compare(old.GetFile(), next.GetFile()) {
+		if !c.Wire && !*json {
+			continue
+		}
+		fmt.Println(c)
+		status = 1
+	}
+	return status
+}
+
+// runCompat runs the compat command and returns its exit status: 0 if the
+// code of the plugins compiles against the packages, 1 if it does not, 2 on
+// error.
+func runCompat(args []string) int {
+	fs := flag.NewFlagSet("compat", flag.ContinueOnError)
+	plugins := fs.String("plugins", "", "the `plugins` generating for the packages, separated by +")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: protoc-go-plugins compat --plugins=clone+validate dir...")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *plugins == "" || fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+	status := 0
+	for _, dir := range fs.Args() {
+		files, err := compat.Inspect(dir)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "protoc-go-plugins: %v\n", err)
+			return 2
+		}
+		if len(files) == 0 {
+			fmt.Fprintf(os.Stderr, "protoc-go-plugins: %s has no .pb.go files\n", dir)
+			return 2
+		}
+		for _, p := range compat.Check(files, strings.Split(*plugins, "+")) {
+			fmt.Println(p)
+			status = 1
+		}
+	}
+	return status
+}
+
+func readDescriptorSet(name string) (*descriptor.FileDescriptorSet, error) {
+	b, err := ioutil.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	set := new(descriptor.FileDescriptorSet)
+	if err := proto.Unmarshal(b, set); err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	return set, nil
+}
@@ -0,0 +1,259 @@
+// Package compat inspects the Go packages protoc-gen-go or gogo generated
+// for a schema, and reports the plugins of this repo whose code would not
+// compile against them: the code of some plugins reads the XXX_unrecognized
+// field that only the messages of the APIv1 and gogo keep, and that of
+// others reads fields through the getters gogo can leave out.
+package compat
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// API is the protobuf API a package was generated for.
+type API int
+
+const (
+	// Unknown is the API of files importing none of the protobuf runtimes.
+	Unknown API = iota
+	// APIv1 is github.com/golang/protobuf, as generated by its protoc-gen-go
+	// before v1.4: the messages keep their unknown fields in
+	// XXX_unrecognized.
+	APIv1
+	// APIv2 is google.golang.org/protobuf, as generated by protoc-gen-go
+	// from v1.4 on: the messages keep their unknown fields unexported.
+	APIv2
+	// Gogo is github.com/gogo/protobuf, whose gogoproto options drop the
+	// getters and XXX_ fields of the messages.
+	Gogo
+)
+
+func (a API) String() string {
+	switch a {
+	case APIv1:
+		return "apiv1"
+	case APIv2:
+		return "apiv2"
+	case Gogo:
+		return "gogo"
+	}
+	return "unknown"
+}
+
+// File is a generated .pb.go file.
+type File struct {
+	Name     string
+	API      API
+	Messages []*Message
+}
+
+// Message is the struct of a message.
+type Message struct {
+	// Name is the Go name of the message.
+	Name string
+	// Fields reports whether the message has fields, besides the XXX_ ones.
+	Fields bool
+	// Unrecognized reports whether the message has an XXX_unrecognized
+	// field.
+	Unrecognized bool
+	// Getters reports whether the message has Get methods.
+	Getters bool
+}
+
+// Requirement is what the code generated by a plugin needs of the
+// messages it is compiled with.
+type Requirement struct {
+	// Unrecognized requires the XXX_unrecognized field.
+	Unrecognized bool
+	// Getters requires the Get methods of the fields.
+	Getters bool
+}
+
+// Requirements are the requirements of the plugins of this repo, by the
+// name of their generator without the protoc-gen-go- prefix. The plugins
+// missing have none. The code of sign needs XXX_unrecognized as it signs
+// the encoding of protoc-gen-go-canonical.
+var Requirements = map[string]*Requirement{
+	"canonical":  {Unrecognized: true},
+	"clone":      {Unrecognized: true},
+	"mask":       {Unrecognized: true},
+	"merge":      {Unrecognized: true},
+	"redact":     {Unrecognized: true},
+	"size":       {Unrecognized: true},
+	"sign":       {Unrecognized: true, Getters: true},
+	"collection": {Getters: true},
+	"diff":       {Getters: true},
+	"equal":      {Getters: true},
+	"gcppubsub":  {Getters: true},
+	"i18n":       {Getters: true},
+	"k8s":        {Getters: true},
+	"kafka":      {Getters: true},
+	"otel":       {Getters: true},
+	"sqlc":       {Getters: true},
+	"sqs":        {Getters: true},
+	"terraform":  {Getters: true},
+	"validate":   {Getters: true},
+}
+
+// Problem is a plugin whose code would not compile against a message.
+type Problem struct {
+	// File is the name of the generated file declaring Message.
+	File    string
+	Message string
+	Plugin  string
+	// Reason says what the code of the plugin needs, and Guidance how to
+	// regenerate the package for it.
+	Reason   string
+	Guidance string
+}
+
+func (p *Problem) String() string {
+	return fmt.Sprintf("%s: %s: protoc-gen-go-%s: %s; %s", p.File, p.Message, p.Plugin, p.Reason, p.Guidance)
+}
+
+// Inspect parses the .pb.go files of dir.
+func Inspect(dir string) ([]*File, error) {
+	names, err := filepath.Glob(filepath.Join(dir, "*.pb.go"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+	var files []*File
+	fset := token.NewFileSet()
+	for _, name := range names {
+		f, err := parser.ParseFile(fset, name, nil, 0)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, inspect(name, f))
+	}
+	return files, nil
+}
+
+func inspect(name string, f *ast.File) *File {
+	file := &File{Name: name}
+	for _, imp := range f.Imports {
+		path, _ := strconv.Unquote(imp.Path.Value)
+		switch {
+		case strings.HasPrefix(path, "google.golang.org/protobuf/"):
+			// protoc-gen-go v1.4 imports github.com/golang/protobuf too.
+			file.API = APIv2
+		case strings.HasPrefix(path, "github.com/gogo/protobuf/"):
+			file.API = Gogo
+		case strings.HasPrefix(path, "github.com/golang/protobuf/") && file.API == Unknown:
+			file.API = APIv1
+		}
+	}
+
+	// The methods of the types of the file, by receiver.
+	methods := make(map[string][]string)
+	for _, decl := range f.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || fn.Recv == nil || len(fn.Recv.List) != 1 {
+			continue
+		}
+		recv := fn.Recv.List[0].Type
+		if star, ok := recv.(*ast.StarExpr); ok {
+			recv = star.X
+		}
+		if id, ok := recv.(*ast.Ident); ok {
+			methods[id.Name] = append(methods[id.Name], fn.Name.Name)
+		}
+	}
+
+	for _, decl := range f.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range gen.Specs {
+			ts := spec.(*ast.TypeSpec)
+			st, ok := ts.Type.(*ast.StructType)
+			if !ok || !contains(methods[ts.Name.Name], "ProtoMessage") {
+				continue
+			}
+			m := &Message{Name: ts.Name.Name}
+			for _, field := range st.Fields.List {
+				for _, id := range field.Names {
+					switch {
+					case id.Name == "XXX_unrecognized":
+						m.Unrecognized = true
+					case id.IsExported() && !strings.HasPrefix(id.Name, "XXX_"):
+						m.Fields = true
+					}
+				}
+			}
+			for _, method := range methods[ts.Name.Name] {
+				if strings.HasPrefix(method, "Get") {
+					m.Getters = true
+				}
+			}
+			file.Messages = append(file.Messages, m)
+		}
+	}
+	return file
+}
+
+// Check returns the problems of compiling the code of plugins against the
+// messages of files, in the order of the files, their messages and
+// plugins.
+func Check(files []*File, plugins []string) []*Problem {
+	var problems []*Problem
+	for _, f := range files {
+		for _, m := range f.Messages {
+			for _, p := range plugins {
+				req := Requirements[p]
+				if req == nil {
+					continue
+				}
+				add := func(reason, guidance string) {
+					problems = append(problems, &Problem{
+						File:     f.Name,
+						Message:  m.Name,
+						Plugin:   p,
+						Reason:   reason,
+						Guidance: guidance,
+					})
+				}
+				if req.Unrecognized && !m.Unrecognized {
+					switch f.API {
+					case APIv2:
+						add("the code reads XXX_unrecognized, which APIv2 messages do not have",
+							"generate the package with github.com/golang/protobuf's protoc-gen-go before v1.4, or leave the plugin out")
+					case Gogo:
+						add("the code reads XXX_unrecognized, which (gogoproto.goproto_unrecognized) = false leaves out",
+							"remove the option from the message or file")
+					default:
+						add("the code reads XXX_unrecognized, which the message does not have",
+							"generate the package with github.com/golang/protobuf's protoc-gen-go before v1.4")
+					}
+				}
+				if req.Getters && m.Fields && !m.Getters {
+					if f.API == Gogo {
+						add("the code reads fields through their getters, which (gogoproto.goproto_getters) = false leaves out",
+							"remove the option from the message or file")
+					} else {
+						add("the code reads fields through their getters, which the message does not have",
+							"generate the package with protoc-gen-go")
+					}
+				}
+			}
+		}
+	}
+	return problems
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
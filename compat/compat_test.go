@@ -0,0 +1,41 @@
+package compat
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestCheck checks the problems of plugins against the messages of each
+// API.
+func TestCheck(t *testing.T) {
+	v1 := &Message{Name: "Doc", Fields: true, Unrecognized: true, Getters: true}
+	v2 := &Message{Name: "Doc", Fields: true, Getters: true}
+	tests := []struct {
+		name    string
+		api     API
+		msg     *Message
+		plugin  string
+		problem string
+	}{
+		{"canonical on APIv1", APIv1, v1, "canonical", ""},
+		{"canonical on APIv2", APIv2, v2, "canonical", "XXX_unrecognized"},
+		// The code of sign encodes messages with the EncodeCanonical
+		// methods of protoc-gen-go-canonical.
+		{"sign on APIv1", APIv1, v1, "sign", ""},
+		{"sign on APIv2", APIv2, v2, "sign", "XXX_unrecognized"},
+		{"sign without getters", Gogo, &Message{Name: "Doc", Fields: true, Unrecognized: true}, "sign", "getters"},
+		{"builder on APIv2", APIv2, v2, "builder", ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			files := []*File{{Name: "doc.pb.go", API: test.api, Messages: []*Message{test.msg}}}
+			problems := Check(files, []string{test.plugin})
+			switch {
+			case test.problem == "" && len(problems) > 0:
+				t.Errorf("Check = %v, want no problem", problems)
+			case test.problem != "" && (len(problems) != 1 || !strings.Contains(problems[0].Reason, test.problem)):
+				t.Errorf("Check = %v, want a problem with %q", problems, test.problem)
+			}
+		})
+	}
+}
@@ -0,0 +1,123 @@
+// Package goname gives the Go names protoc-gen-go declares the fields of
+// messages under, for the plugins in this repo that generate code reading
+// or writing them.
+package goname
+
+import "github.com/golang/protobuf/protoc-gen-go/descriptor"
+
+// methods are the methods of the generated messages that fields may not be
+// named after, nor their getters.
+var methods = []string{
+	"Reset",
+	"String",
+	"ProtoMessage",
+	"Marshal",
+	"Unmarshal",
+	"ExtensionRangeArray",
+	"ExtensionMap",
+	"Descriptor",
+}
+
+// Fields returns the Go names of the fields and oneofs of msg, by their
+// proto names. A name is the CamelCase of the proto name, with underscores
+// appended while it conflicts with a method of the message, with the
+// getter of a field, or with an earlier name, as protoc-gen-go does: a
+// field named "string" is String_, its getter GetString_.
+func Fields(msg *descriptor.DescriptorProto) map[string]string {
+	used := make(map[string]bool)
+	for _, m := range methods {
+		used[m] = true
+	}
+	unique := func(name string, getter bool) string {
+		for used[name] || getter && used["Get"+name] {
+			name += "_"
+		}
+		used[name] = true
+		used["Get"+name] = getter
+		return name
+	}
+	names := make(map[string]string)
+	oneofs := msg.GetOneofDecl()
+	done := make(map[int32]bool)
+	for _, f := range msg.GetField() {
+		names[f.GetName()] = unique(CamelCase(f.GetName()), true)
+		// A oneof is named after its first field, and has no getter.
+		if f.OneofIndex != nil && !done[f.GetOneofIndex()] && int(f.GetOneofIndex()) < len(oneofs) {
+			done[f.GetOneofIndex()] = true
+			o := oneofs[f.GetOneofIndex()].GetName()
+			names[o] = unique(CamelCase(o), false)
+		}
+	}
+	return names
+}
+
+// Wrappers returns the names of the wrapper types of the oneof fields of
+// msg, by their proto names, without the Go name of msg and the underscore
+// that prefix them. A name is the Go name of the field, with underscores
+// appended while it conflicts with a message or enum nested in msg, as
+// protoc-gen-go does: the wrapper of a group Text in a oneof of M is
+// M_Text_, as M_Text is the group.
+func Wrappers(msg *descriptor.DescriptorProto) map[string]string {
+	nested := make(map[string]bool)
+	for _, m := range msg.GetNestedType() {
+		nested[CamelCase(m.GetName())] = true
+	}
+	for _, e := range msg.GetEnumType() {
+		nested[CamelCase(e.GetName())] = true
+	}
+	names := Fields(msg)
+	wrappers := make(map[string]string)
+	for _, f := range msg.GetField() {
+		if f.OneofIndex == nil || f.GetProto3Optional() {
+			continue
+		}
+		name := names[f.GetName()]
+		for nested[name] {
+			name += "_"
+		}
+		wrappers[f.GetName()] = name
+	}
+	return wrappers
+}
+
+// CamelCase returns the CamelCase of the proto name s, e.g. "UserId" for
+// "user_id". A leading underscore becomes an X.
+func CamelCase(s string) string {
+	if s == "" {
+		return ""
+	}
+	t := make([]byte, 0, 32)
+	i := 0
+	if s[0] == '_' {
+		// Need a capital letter; drop the '_'.
+		t = append(t, 'X')
+		i++
+	}
+	for ; i < len(s); i++ {
+		c := s[i]
+		if c == '_' && i+1 < len(s) && isASCIILower(s[i+1]) {
+			continue
+		}
+		if isASCIIDigit(c) {
+			t = append(t, c)
+			continue
+		}
+		if isASCIILower(c) {
+			c ^= ' '
+		}
+		t = append(t, c)
+		for i+1 < len(s) && isASCIILower(s[i+1]) {
+			i++
+			t = append(t, s[i])
+		}
+	}
+	return string(t)
+}
+
+func isASCIILower(c byte) bool {
+	return 'a' <= c && c <= 'z'
+}
+
+func isASCIIDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
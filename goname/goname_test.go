@@ -0,0 +1,81 @@
+package goname
+
+import (
+	"testing"
+
+	"google.golang.org/protobuf/compiler/protogen"
+
+	"github.com/f4tq/protoc-go-plugins/internal/plugintest"
+)
+
+// TestFields checks the names against those protoc-gen-go gives.
+func TestFields(t *testing.T) {
+	tests := []struct {
+		name   string
+		fields string
+	}{
+		{"plain", `string name = 1; int32 user_id = 2;`},
+		{"methods", `string string = 1; int32 reset = 2; bytes descriptor = 3; string proto_message = 4;`},
+		{"getters", `string name = 1; string get_name = 2;`},
+		{"getter first", `string get_string = 1; string string = 2;`},
+		{"oneof", `oneof marshal { string unmarshal = 1; int32 extension_map = 2; }`},
+		{"optional", `optional string string = 1; optional bytes reset = 2;`},
+		{"leading underscore", `string _x = 1; string x = 2;`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := plugintest.Request(t, "", map[string]string{
+				"test.proto": `syntax = "proto3"; package test; option go_package = "example.com/test"; message M { ` + test.fields + ` }`,
+			})
+			p, err := protogen.Options{}.New(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			msg := p.Files[len(p.Files)-1].Messages[0]
+			got := Fields(req.GetProtoFile()[len(req.GetProtoFile())-1].GetMessageType()[0])
+			for _, f := range msg.Fields {
+				if want := f.GoName; got[string(f.Desc.Name())] != want {
+					t.Errorf("field %s: got %q, want %q", f.Desc.Name(), got[string(f.Desc.Name())], want)
+				}
+			}
+			for _, o := range msg.Oneofs {
+				if want := o.GoName; got[string(o.Desc.Name())] != want {
+					t.Errorf("oneof %s: got %q, want %q", o.Desc.Name(), got[string(o.Desc.Name())], want)
+				}
+			}
+		})
+	}
+}
+
+// TestWrappers checks the names of the wrapper types of oneof fields
+// against those protoc-gen-go gives.
+func TestWrappers(t *testing.T) {
+	tests := []struct {
+		name   string
+		fields string
+	}{
+		{"plain", `oneof value { string name = 1; int32 size = 2; }`},
+		{"methods", `oneof value { string string = 1; }`},
+		{"group", `oneof value { group Text = 1 { optional string value = 2; } }`},
+		{"nested message", `message Name {} message Name_ {} oneof value { string name = 1; }`},
+		{"nested enum", `enum Kind { KIND_UNSPECIFIED = 0; } oneof value { int32 kind = 1; }`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := plugintest.Request(t, "", map[string]string{
+				"test.proto": `syntax = "proto2"; package test; option go_package = "example.com/test"; message M { ` + test.fields + ` }`,
+			})
+			p, err := protogen.Options{}.New(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			msg := p.Files[len(p.Files)-1].Messages[0]
+			got := Wrappers(req.GetProtoFile()[len(req.GetProtoFile())-1].GetMessageType()[0])
+			for _, f := range msg.Fields {
+				if want := f.GoIdent.GoName; "M_"+got[string(f.Desc.Name())] != want {
+					t.Errorf("field %s: got M_%s, want %s", f.Desc.Name(), got[string(f.Desc.Name())], want)
+				}
+			}
+		})
+	}
+}
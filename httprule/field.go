@@ -0,0 +1,109 @@
+package httprule
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+
+	"github.com/f4tq/protoc-go-plugins/goname"
+)
+
+// Types gives the messages the fields of a binding are looked up in.
+type Types interface {
+	// Message returns the message typeName, e.g. ".pkg.Msg", and the file
+	// declaring it.
+	Message(typeName string) (*descriptor.DescriptorProto, *descriptor.FileDescriptorProto)
+	// GoType returns the Go name of the message typeName in the generated
+	// file, qualified with its package if it is declared outside of it.
+	GoType(typeName string) string
+}
+
+// Field is the scalar or enum field a path variable or query parameter
+// sets.
+type Field struct {
+	// Desc is the descriptor of the field.
+	Desc *descriptor.FieldDescriptorProto
+	// Alloc holds the statements allocating the messages on the path to
+	// the field, each ending in a newline.
+	Alloc string
+
+	// target is the expression of the message holding the field.
+	target string
+	goName string
+	// oneof and wrapper are the Go names of the oneof holding the field,
+	// if any, and of its wrapper type.
+	oneof   string
+	wrapper string
+	// pointer reports whether the field is a pointer to its value.
+	pointer bool
+}
+
+// ResolveField returns the field at path, relative to the message
+// expression target of type typeName. The path goes through singular
+// message fields to a singular scalar or enum field.
+func ResolveField(types Types, target, typeName string, path []string) (*Field, error) {
+	var alloc strings.Builder
+	for i, name := range path {
+		msg, file := types.Message(typeName)
+		field := findField(msg, name)
+		if field == nil {
+			return nil, fmt.Errorf("%s has no field %q", typeName, name)
+		}
+		goNames := goname.Fields(msg)
+		goName := goNames[name]
+		if field.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REPEATED {
+			return nil, fmt.Errorf("field %q cannot be bound: repeated", strings.Join(path[:i+1], "."))
+		}
+		oneof := field.OneofIndex != nil && !field.GetProto3Optional()
+		if i < len(path)-1 {
+			if field.GetType() != descriptor.FieldDescriptorProto_TYPE_MESSAGE || oneof {
+				return nil, fmt.Errorf("field %q cannot be traversed", strings.Join(path[:i+1], "."))
+			}
+			fmt.Fprintf(&alloc, "if %s.%s == nil {\n%s.%s = new(%s)\n}\n", target, goName, target, goName, types.GoType(field.GetTypeName()))
+			target += "." + goName
+			typeName = field.GetTypeName()
+			continue
+		}
+		if field.GetType() == descriptor.FieldDescriptorProto_TYPE_MESSAGE ||
+			field.GetType() == descriptor.FieldDescriptorProto_TYPE_GROUP {
+			return nil, fmt.Errorf("field %q cannot be bound: message", strings.Join(path, "."))
+		}
+		f := &Field{Desc: field, Alloc: alloc.String(), target: target, goName: goName}
+		switch {
+		case oneof:
+			f.oneof = goNames[msg.GetOneofDecl()[field.GetOneofIndex()].GetName()]
+			f.wrapper = types.GoType(typeName) + "_" + goName
+		case field.GetType() == descriptor.FieldDescriptorProto_TYPE_BYTES:
+			// Bytes are nil when unset, never pointers.
+		default:
+			// Scalars with presence are pointers: those of proto3
+			// optional fields and of all proto2 fields.
+			f.pointer = field.GetProto3Optional() || file.GetSyntax() != "proto3"
+		}
+		return f, nil
+	}
+	return nil, fmt.Errorf("empty field path")
+}
+
+// Set returns the statements setting the field to v, an expression of the
+// type of its values.
+func (f *Field) Set(v string) string {
+	switch {
+	case f.oneof != "":
+		return fmt.Sprintf("%s.%s = &%s{%s: %s}", f.target, f.oneof, f.wrapper, f.goName, v)
+	case f.pointer:
+		return fmt.Sprintf("x := %s\n%s.%s = &x", v, f.target, f.goName)
+	}
+	return fmt.Sprintf("%s.%s = %s", f.target, f.goName, v)
+}
+
+// findField returns the field of msg called name, or nil.
+func findField(msg *descriptor.DescriptorProto, name string) *descriptor.FieldDescriptorProto {
+	for _, f := range msg.GetField() {
+		if f.GetName() == name {
+			return f
+		}
+	}
+	return nil
+}
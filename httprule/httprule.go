@@ -0,0 +1,80 @@
+// Package httprule extracts the google.api.http bindings of methods for the
+// plugins in this repo that generate HTTP handlers.
+package httprule
+
+import (
+	"fmt"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"google.golang.org/genproto/googleapis/api/annotations"
+
+	"github.com/f4tq/protoc-go-plugins/runtime/httpgw"
+)
+
+// Binding is one HTTP mapping of a method.
+type Binding struct {
+	// Method is the HTTP method, e.g. "GET".
+	Method string
+	// Template is the path template, e.g. "/v1/{name=users/*}".
+	Template string
+	// Pattern is the compiled Template.
+	Pattern *httpgw.Pattern
+	// Body is "" for no body, "*" for the whole request message, or the
+	// name of the request field the body is decoded into.
+	Body string
+	// ResponseBody is "" for the whole response message, or the name of
+	// the response field written as the body.
+	ResponseBody string
+}
+
+// Bindings returns the bindings of m, the primary one first, or nil if m has
+// no google.api.http option.
+func Bindings(m *descriptor.MethodDescriptorProto) ([]*Binding, error) {
+	if m.GetOptions() == nil || !proto.HasExtension(m.GetOptions(), annotations.E_Http) {
+		return nil, nil
+	}
+	ext, err := proto.GetExtension(m.GetOptions(), annotations.E_Http)
+	if err != nil {
+		return nil, err
+	}
+	rule, ok := ext.(*annotations.HttpRule)
+	if !ok {
+		return nil, fmt.Errorf("%s: unexpected google.api.http option %T", m.GetName(), ext)
+	}
+	var bindings []*Binding
+	for _, r := range append([]*annotations.HttpRule{rule}, rule.GetAdditionalBindings()...) {
+		b, err := binding(r)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", m.GetName(), err)
+		}
+		bindings = append(bindings, b)
+	}
+	return bindings, nil
+}
+
+func binding(r *annotations.HttpRule) (*Binding, error) {
+	b := &Binding{Body: r.GetBody(), ResponseBody: r.GetResponseBody()}
+	switch {
+	case r.GetGet() != "":
+		b.Method, b.Template = "GET", r.GetGet()
+	case r.GetPut() != "":
+		b.Method, b.Template = "PUT", r.GetPut()
+	case r.GetPost() != "":
+		b.Method, b.Template = "POST", r.GetPost()
+	case r.GetDelete() != "":
+		b.Method, b.Template = "DELETE", r.GetDelete()
+	case r.GetPatch() != "":
+		b.Method, b.Template = "PATCH", r.GetPatch()
+	case r.GetCustom() != nil:
+		b.Method, b.Template = r.GetCustom().GetKind(), r.GetCustom().GetPath()
+	default:
+		return nil, fmt.Errorf("google.api.http rule without a pattern")
+	}
+	p, err := httpgw.ParsePattern(b.Template)
+	if err != nil {
+		return nil, err
+	}
+	b.Pattern = p
+	return b, nil
+}
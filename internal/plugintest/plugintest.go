@@ -0,0 +1,310 @@
+// Package plugintest builds the code the plugins in this repo generate,
+// along with the protoc-gen-go output of the messages it is for, so that
+// their tests check it compiles and behaves as it should.
+package plugintest
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"testing"
+
+	"github.com/bufbuild/protocompile"
+	"github.com/golang/protobuf/proto"
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+	gengo "google.golang.org/protobuf/cmd/protoc-gen-go/internal_gengo"
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+)
+
+// Generator is the generate function of a plugin.
+type Generator func(req *plugin.CodeGeneratorRequest) ([]*plugin.CodeGeneratorResponse_File, error)
+
+// Request returns the request of a protoc run generating sources, the
+// content of proto files by name. They may import the well-known types,
+// "options/options.proto" and the files of the Go packages linked into the
+// test, such as "google/api/annotations.proto". param is the parameter of
+// the plugin.
+func Request(t testing.TB, param string, sources map[string]string) *plugin.CodeGeneratorRequest {
+	t.Helper()
+	var names []string
+	for name := range sources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	_, file, _, _ := runtime.Caller(0)
+	root := filepath.Join(filepath.Dir(file), "..", "..")
+	c := protocompile.Compiler{
+		Resolver: protocompile.WithStandardImports(protocompile.CompositeResolver{
+			&protocompile.SourceResolver{Accessor: protocompile.SourceAccessorFromMap(sources)},
+			&protocompile.SourceResolver{ImportPaths: []string{root}},
+			protocompile.ResolverFunc(func(path string) (protocompile.SearchResult, error) {
+				d, err := protoregistry.GlobalFiles.FindFileByPath(path)
+				return protocompile.SearchResult{Desc: d}, err
+			}),
+		}),
+		SourceInfoMode: protocompile.SourceInfoStandard,
+	}
+	res, err := c.Compile(context.Background(), names...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The request lists the files imported before those importing them.
+	req := &plugin.CodeGeneratorRequest{FileToGenerate: names}
+	if param != "" {
+		req.Parameter = proto.String(param)
+	}
+	seen := make(map[string]bool)
+	var add func(f protoreflect.FileDescriptor)
+	add = func(f protoreflect.FileDescriptor) {
+		if seen[f.Path()] {
+			return
+		}
+		seen[f.Path()] = true
+		for i := 0; i < f.Imports().Len(); i++ {
+			add(f.Imports().Get(i).FileDescriptor)
+		}
+		req.ProtoFile = append(req.ProtoFile, protodesc.ToFileDescriptorProto(f))
+	}
+	for _, f := range res {
+		add(f)
+	}
+
+	// Go through the wire, as protoc does, so that the custom options are
+	// read by the extensions the plugins register.
+	b, err := proto.Marshal(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req = new(plugin.CodeGeneratorRequest)
+	if err := proto.Unmarshal(b, req); err != nil {
+		t.Fatal(err)
+	}
+	return req
+}
+
+// Generate returns the content of the files gen generates for req, by
+// name.
+func Generate(t testing.TB, req *plugin.CodeGeneratorRequest, gen Generator) map[string]string {
+	t.Helper()
+	files, err := gen(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := make(map[string]string)
+	for _, f := range files {
+		out[f.GetName()] = f.GetContent()
+	}
+	return out
+}
+
+// Package writes the protoc-gen-go output for req and that of gen into a
+// new directory of the current module, removed at the end of the test, and
+// returns the path of its package, e.g. "./_gen123". The files to generate
+// must all be in the same Go package.
+func Package(t testing.TB, req *plugin.CodeGeneratorRequest, gen Generator) string {
+	t.Helper()
+	return pkg(t, req, gen, false)
+}
+
+// PackageV1 is Package for plugins reading XXX_unrecognized: the messages
+// keep their unknown fields in it, as those of the APIv1 do.
+func PackageV1(t testing.TB, req *plugin.CodeGeneratorRequest, gen Generator) string {
+	t.Helper()
+	return pkg(t, req, gen, true)
+}
+
+// unknownFields is the declaration of the unknown fields of the messages
+// protoc-gen-go generates.
+var unknownFields = regexp.MustCompile(`(?m)^(\s+)unknownFields(\s+)protoimpl\.UnknownFields`)
+
+func pkg(t testing.TB, req *plugin.CodeGeneratorRequest, gen Generator, unrecognized bool) string {
+	t.Helper()
+	// The package is written inside the module, so that it builds against
+	// the same versions of the runtimes.
+	dir, err := os.MkdirTemp(".", "_gen")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	write := func(name, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, filepath.Base(name)), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for name, content := range protocGenGo(t, req) {
+		if unrecognized {
+			content = unknownFields.ReplaceAllString(content, "${1}XXX_unrecognized${2}[]byte")
+		}
+		write(name, content)
+	}
+	for name, content := range Generate(t, req, gen) {
+		write(name, content)
+	}
+	return "./" + filepath.Base(dir)
+}
+
+// protocGenGo returns the content of the files protoc-gen-go generates for
+// req, by name.
+func protocGenGo(t testing.TB, req *plugin.CodeGeneratorRequest) map[string]string {
+	t.Helper()
+	p, err := protogen.Options{}.New(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range p.Files {
+		if f.Generate {
+			gengo.GenerateFile(p, f)
+		}
+	}
+	resp := p.Response()
+	if resp.Error != nil {
+		t.Fatal(resp.GetError())
+	}
+	out := make(map[string]string)
+	for _, f := range resp.GetFile() {
+		out[f.GetName()] = f.GetContent()
+	}
+	return out
+}
+
+// WriteFile writes a file of the package at path, such as a test of the
+// generated code.
+func WriteFile(t testing.TB, path, name, content string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(path, name), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Go runs the go command with args, e.g. "vet" and the path of a package,
+// and fails the test with its output if it fails. The test is skipped
+// without a go command.
+func Go(t testing.TB, args ...string) {
+	t.Helper()
+	goTool, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go command not found")
+	}
+	if out, err := exec.Command(goTool, args...).CombinedOutput(); err != nil {
+		t.Fatalf("go %v: %v\n%s", args, err, out)
+	}
+}
+
+// Conflicts is the content of "conflicts/v1/conflicts.proto", whose fields
+// are named after the methods of the generated messages, so that
+// protoc-gen-go appends underscores to their Go names.
+const Conflicts = `
+syntax = "proto3";
+
+package conflicts.v1;
+
+option go_package = "example.com/conflicts/v1;conflictsv1";
+
+enum Kind {
+    KIND_UNSPECIFIED = 0;
+    KIND_TEXT = 1;
+}
+
+message Conflicts {
+    string string = 1;
+    int64 reset = 2;
+    Conflicts descriptor = 3;
+    optional bytes marshal = 4;
+    repeated string unmarshal = 5;
+    Kind proto_message = 6;
+    map<string, int32> extension_map = 7;
+    oneof value {
+        string extension_range_array = 8;
+        int32 get_string = 9;
+    }
+}
+`
+
+// ConflictsRequest returns the request of a protoc run generating
+// Conflicts, with the parameter param.
+func ConflictsRequest(t testing.TB, param string) *plugin.CodeGeneratorRequest {
+	t.Helper()
+	return Request(t, param, map[string]string{"conflicts/v1/conflicts.proto": Conflicts})
+}
+
+// Shelf is the content of "shelf/v1/shelf.proto", whose methods bind HTTP
+// requests to fields of the kinds set apart: optional bytes, proto3
+// optional and proto2 scalars, oneof members, fields protoc-gen-go renames
+// and fields of nested messages.
+const Shelf = `
+syntax = "proto3";
+
+package shelf.v1;
+
+option go_package = "example.com/shelf/v1;shelfv1";
+
+import "google/api/annotations.proto";
+import "shelf/v1/legacy.proto";
+
+message GetBookRequest {
+    string name = 1;
+    optional bytes etag = 2;
+    optional int32 version = 3;
+    string string = 4;
+    oneof view {
+        string fields = 5;
+        int32 level = 6;
+    }
+    Legacy legacy = 7;
+}
+
+message Book {
+    string name = 1;
+}
+
+service Shelf {
+    rpc GetBook(GetBookRequest) returns (Book) {
+        option (google.api.http) = {
+            get: "/v1/{name=shelves/*/books/*}/{legacy.count}"
+            additional_bindings { get: "/v1/{string}/{etag}" }
+        };
+    }
+    rpc GetLegacyBook(Legacy) returns (Book) {
+        option (google.api.http) = {
+            get: "/v1/legacy/{label}/{count}"
+        };
+    }
+}
+`
+
+// Legacy is the content of "shelf/v1/legacy.proto", which Shelf imports.
+const Legacy = `
+syntax = "proto2";
+
+package shelf.v1;
+
+option go_package = "example.com/shelf/v1;shelfv1";
+
+message Legacy {
+    optional int32 count = 1;
+    optional string label = 2;
+    optional bytes data = 3;
+    required bool flag = 4;
+}
+`
+
+// ShelfRequest returns the request of a protoc run generating Shelf and
+// Legacy, with the parameter param.
+func ShelfRequest(t testing.TB, param string) *plugin.CodeGeneratorRequest {
+	t.Helper()
+	return Request(t, param, map[string]string{
+		"shelf/v1/shelf.proto":  Shelf,
+		"shelf/v1/legacy.proto": Legacy,
+	})
+}
@@ -0,0 +1,68 @@
+package options
+
+import (
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+// Batch is the (f4tq.plugins.Batch) message.
+type Batch struct {
+	Method               *string  `protobuf:"bytes,1,opt,name=method" json:"method,omitempty"`
+	MaxSize              *uint32  `protobuf:"varint,2,opt,name=max_size,json=maxSize" json:"max_size,omitempty"`
+	MaxDelay             *string  `protobuf:"bytes,3,opt,name=max_delay,json=maxDelay" json:"max_delay,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Batch) Reset()         { *m = Batch{} }
+func (m *Batch) String() string { return proto.CompactTextString(m) }
+func (*Batch) ProtoMessage()    {}
+
+func (m *Batch) GetMethod() string {
+	if m != nil && m.Method != nil {
+		return *m.Method
+	}
+	return ""
+}
+
+func (m *Batch) GetMaxSize() uint32 {
+	if m != nil && m.MaxSize != nil {
+		return *m.MaxSize
+	}
+	return 0
+}
+
+func (m *Batch) GetMaxDelay() string {
+	if m != nil && m.MaxDelay != nil {
+		return *m.MaxDelay
+	}
+	return ""
+}
+
+var E_Batch = &proto.ExtensionDesc{
+	ExtendedType:  (*descriptor.MethodOptions)(nil),
+	ExtensionType: (*Batch)(nil),
+	Field:         50230,
+	Name:          "f4tq.plugins.batch",
+	Tag:           "bytes,50230,opt,name=batch",
+	Filename:      "options/options.proto",
+}
+
+func init() {
+	proto.RegisterType((*Batch)(nil), "f4tq.plugins.Batch")
+	proto.RegisterExtension(E_Batch)
+}
+
+// MethodBatch returns the (f4tq.plugins.batch) of method, or nil.
+func MethodBatch(method *descriptor.MethodDescriptorProto) *Batch {
+	if method.GetOptions() == nil {
+		return nil
+	}
+	v, err := proto.GetExtension(method.GetOptions(), E_Batch)
+	if err != nil {
+		return nil
+	}
+	b, _ := v.(*Batch)
+	return b
+}
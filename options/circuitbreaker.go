@@ -0,0 +1,61 @@
+package options
+
+import (
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+// CircuitBreaker is the (f4tq.plugins.CircuitBreaker) message.
+type CircuitBreaker struct {
+	FailureThreshold     *uint32  `protobuf:"varint,1,opt,name=failure_threshold,json=failureThreshold" json:"failure_threshold,omitempty"`
+	OpenTimeout          *string  `protobuf:"bytes,2,opt,name=open_timeout,json=openTimeout" json:"open_timeout,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *CircuitBreaker) Reset()         { *m = CircuitBreaker{} }
+func (m *CircuitBreaker) String() string { return proto.CompactTextString(m) }
+func (*CircuitBreaker) ProtoMessage()    {}
+
+func (m *CircuitBreaker) GetFailureThreshold() uint32 {
+	if m != nil && m.FailureThreshold != nil {
+		return *m.FailureThreshold
+	}
+	return 0
+}
+
+func (m *CircuitBreaker) GetOpenTimeout() string {
+	if m != nil && m.OpenTimeout != nil {
+		return *m.OpenTimeout
+	}
+	return ""
+}
+
+var E_CircuitBreaker = &proto.ExtensionDesc{
+	ExtendedType:  (*descriptor.MethodOptions)(nil),
+	ExtensionType: (*CircuitBreaker)(nil),
+	Field:         50170,
+	Name:          "f4tq.plugins.circuit_breaker",
+	Tag:           "bytes,50170,opt,name=circuit_breaker",
+	Filename:      "options/options.proto",
+}
+
+func init() {
+	proto.RegisterType((*CircuitBreaker)(nil), "f4tq.plugins.CircuitBreaker")
+	proto.RegisterExtension(E_CircuitBreaker)
+}
+
+// MethodCircuitBreaker returns the (f4tq.plugins.circuit_breaker) of
+// method, or nil.
+func MethodCircuitBreaker(method *descriptor.MethodDescriptorProto) *CircuitBreaker {
+	if method.GetOptions() == nil {
+		return nil
+	}
+	v, err := proto.GetExtension(method.GetOptions(), E_CircuitBreaker)
+	if err != nil {
+		return nil
+	}
+	cb, _ := v.(*CircuitBreaker)
+	return cb
+}
@@ -0,0 +1,51 @@
+package options
+
+import (
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+var E_CacheTtl = &proto.ExtensionDesc{
+	ExtendedType:  (*descriptor.MethodOptions)(nil),
+	ExtensionType: (*string)(nil),
+	Field:         50240,
+	Name:          "f4tq.plugins.cache_ttl",
+	Tag:           "bytes,50240,opt,name=cache_ttl,json=cacheTtl",
+	Filename:      "options/options.proto",
+}
+
+var E_CacheInvalidates = &proto.ExtensionDesc{
+	ExtendedType:  (*descriptor.MethodOptions)(nil),
+	ExtensionType: ([]string)(nil),
+	Field:         50241,
+	Name:          "f4tq.plugins.cache_invalidates",
+	Tag:           "bytes,50241,rep,name=cache_invalidates,json=cacheInvalidates",
+	Filename:      "options/options.proto",
+}
+
+func init() {
+	proto.RegisterExtension(E_CacheTtl)
+	proto.RegisterExtension(E_CacheInvalidates)
+}
+
+// CacheTTL returns the (f4tq.plugins.cache_ttl) of method, or "".
+func CacheTTL(method *descriptor.MethodDescriptorProto) string {
+	if method.GetOptions() == nil {
+		return ""
+	}
+	return getString(method.GetOptions(), E_CacheTtl)
+}
+
+// CacheInvalidates returns the (f4tq.plugins.cache_invalidates) of method,
+// or nil.
+func CacheInvalidates(method *descriptor.MethodDescriptorProto) []string {
+	if method.GetOptions() == nil {
+		return nil
+	}
+	v, err := proto.GetExtension(method.GetOptions(), E_CacheInvalidates)
+	if err != nil {
+		return nil
+	}
+	names, _ := v.([]string)
+	return names
+}
@@ -0,0 +1,27 @@
+package options
+
+import (
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+var E_Key = &proto.ExtensionDesc{
+	ExtendedType:  (*descriptor.FieldOptions)(nil),
+	ExtensionType: (*string)(nil),
+	Field:         50290,
+	Name:          "f4tq.plugins.key",
+	Tag:           "bytes,50290,opt,name=key",
+	Filename:      "options/options.proto",
+}
+
+func init() {
+	proto.RegisterExtension(E_Key)
+}
+
+// Key returns the (f4tq.plugins.key) of field, or "".
+func Key(field *descriptor.FieldDescriptorProto) string {
+	if field.GetOptions() == nil {
+		return ""
+	}
+	return getString(field.GetOptions(), E_Key)
+}
@@ -0,0 +1,53 @@
+package options
+
+import (
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+var E_SortKey = &proto.ExtensionDesc{
+	ExtendedType:  (*descriptor.FieldOptions)(nil),
+	ExtensionType: (*uint32)(nil),
+	Field:         50340,
+	Name:          "f4tq.plugins.sort_key",
+	Tag:           "varint,50340,opt,name=sort_key,json=sortKey",
+	Filename:      "options/options.proto",
+}
+
+var E_SortDescending = &proto.ExtensionDesc{
+	ExtendedType:  (*descriptor.FieldOptions)(nil),
+	ExtensionType: (*bool)(nil),
+	Field:         50341,
+	Name:          "f4tq.plugins.sort_descending",
+	Tag:           "varint,50341,opt,name=sort_descending,json=sortDescending",
+	Filename:      "options/options.proto",
+}
+
+func init() {
+	proto.RegisterExtension(E_SortKey)
+	proto.RegisterExtension(E_SortDescending)
+}
+
+// SortKey returns the (f4tq.plugins.sort_key) priority of field, or 0 if
+// it is not a sort key.
+func SortKey(field *descriptor.FieldDescriptorProto) uint32 {
+	if field.GetOptions() == nil {
+		return 0
+	}
+	v, err := proto.GetExtension(field.GetOptions(), E_SortKey)
+	if err != nil {
+		return 0
+	}
+	if n, ok := v.(*uint32); ok && n != nil {
+		return *n
+	}
+	return 0
+}
+
+// SortDescending reports whether field sets (f4tq.plugins.sort_descending).
+func SortDescending(field *descriptor.FieldDescriptorProto) bool {
+	if field.GetOptions() == nil {
+		return false
+	}
+	return getBool(field.GetOptions(), E_SortDescending)
+}
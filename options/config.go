@@ -0,0 +1,27 @@
+package options
+
+import (
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+var E_Config = &proto.ExtensionDesc{
+	ExtendedType:  (*descriptor.MessageOptions)(nil),
+	ExtensionType: (*bool)(nil),
+	Field:         50390,
+	Name:          "f4tq.plugins.config",
+	Tag:           "varint,50390,opt,name=config",
+	Filename:      "options/options.proto",
+}
+
+func init() {
+	proto.RegisterExtension(E_Config)
+}
+
+// Config reports whether msg sets (f4tq.plugins.config).
+func Config(msg *descriptor.DescriptorProto) bool {
+	if msg.GetOptions() == nil {
+		return false
+	}
+	return getBool(msg.GetOptions(), E_Config)
+}
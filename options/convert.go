@@ -0,0 +1,46 @@
+package options
+
+import (
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+var E_PreviousVersion = &proto.ExtensionDesc{
+	ExtendedType:  (*descriptor.FileOptions)(nil),
+	ExtensionType: (*string)(nil),
+	Field:         50300,
+	Name:          "f4tq.plugins.previous_version",
+	Tag:           "bytes,50300,opt,name=previous_version,json=previousVersion",
+	Filename:      "options/options.proto",
+}
+
+var E_RenamedFrom = &proto.ExtensionDesc{
+	ExtendedType:  (*descriptor.FieldOptions)(nil),
+	ExtensionType: (*string)(nil),
+	Field:         50301,
+	Name:          "f4tq.plugins.renamed_from",
+	Tag:           "bytes,50301,opt,name=renamed_from,json=renamedFrom",
+	Filename:      "options/options.proto",
+}
+
+func init() {
+	proto.RegisterExtension(E_PreviousVersion)
+	proto.RegisterExtension(E_RenamedFrom)
+}
+
+// PreviousVersion returns the (f4tq.plugins.previous_version) of file, or
+// "".
+func PreviousVersion(file *descriptor.FileDescriptorProto) string {
+	if file.GetOptions() == nil {
+		return ""
+	}
+	return getString(file.GetOptions(), E_PreviousVersion)
+}
+
+// RenamedFrom returns the (f4tq.plugins.renamed_from) of field, or "".
+func RenamedFrom(field *descriptor.FieldDescriptorProto) string {
+	if field.GetOptions() == nil {
+		return ""
+	}
+	return getString(field.GetOptions(), E_RenamedFrom)
+}
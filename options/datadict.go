@@ -0,0 +1,47 @@
+package options
+
+import (
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+var E_DefaultDataClass = &proto.ExtensionDesc{
+	ExtendedType:  (*descriptor.MessageOptions)(nil),
+	ExtensionType: (*string)(nil),
+	Field:         50430,
+	Name:          "f4tq.plugins.default_data_class",
+	Tag:           "bytes,50430,opt,name=default_data_class,json=defaultDataClass",
+	Filename:      "options/options.proto",
+}
+
+var E_DataClass = &proto.ExtensionDesc{
+	ExtendedType:  (*descriptor.FieldOptions)(nil),
+	ExtensionType: (*string)(nil),
+	Field:         50430,
+	Name:          "f4tq.plugins.data_class",
+	Tag:           "bytes,50430,opt,name=data_class,json=dataClass",
+	Filename:      "options/options.proto",
+}
+
+func init() {
+	proto.RegisterExtension(E_DefaultDataClass)
+	proto.RegisterExtension(E_DataClass)
+}
+
+// DefaultDataClass returns the data classification
+// (f4tq.plugins.default_data_class) of the fields of msg, or "".
+func DefaultDataClass(msg *descriptor.DescriptorProto) string {
+	if msg.GetOptions() == nil {
+		return ""
+	}
+	return getString(msg.GetOptions(), E_DefaultDataClass)
+}
+
+// DataClass returns the data classification (f4tq.plugins.data_class) of
+// field, or "".
+func DataClass(field *descriptor.FieldDescriptorProto) string {
+	if field.GetOptions() == nil {
+		return ""
+	}
+	return getString(field.GetOptions(), E_DataClass)
+}
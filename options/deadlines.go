@@ -0,0 +1,44 @@
+package options
+
+import (
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+var E_DefaultTimeout = &proto.ExtensionDesc{
+	ExtendedType:  (*descriptor.ServiceOptions)(nil),
+	ExtensionType: (*string)(nil),
+	Field:         50220,
+	Name:          "f4tq.plugins.default_timeout",
+	Tag:           "bytes,50220,opt,name=default_timeout,json=defaultTimeout",
+	Filename:      "options/options.proto",
+}
+
+var E_Timeout = &proto.ExtensionDesc{
+	ExtendedType:  (*descriptor.MethodOptions)(nil),
+	ExtensionType: (*string)(nil),
+	Field:         50220,
+	Name:          "f4tq.plugins.timeout",
+	Tag:           "bytes,50220,opt,name=timeout",
+	Filename:      "options/options.proto",
+}
+
+func init() {
+	proto.RegisterExtension(E_DefaultTimeout)
+	proto.RegisterExtension(E_Timeout)
+}
+
+// Timeout returns the default deadline of method: its
+// (f4tq.plugins.timeout), else the (f4tq.plugins.default_timeout) of svc,
+// else "".
+func Timeout(svc *descriptor.ServiceDescriptorProto, method *descriptor.MethodDescriptorProto) string {
+	if method.GetOptions() != nil {
+		if t := getString(method.GetOptions(), E_Timeout); t != "" {
+			return t
+		}
+	}
+	if svc.GetOptions() == nil {
+		return ""
+	}
+	return getString(svc.GetOptions(), E_DefaultTimeout)
+}
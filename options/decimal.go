@@ -0,0 +1,27 @@
+package options
+
+import (
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+var E_Decimal = &proto.ExtensionDesc{
+	ExtendedType:  (*descriptor.FieldOptions)(nil),
+	ExtensionType: (*bool)(nil),
+	Field:         50130,
+	Name:          "f4tq.plugins.decimal",
+	Tag:           "varint,50130,opt,name=decimal",
+	Filename:      "options/options.proto",
+}
+
+func init() {
+	proto.RegisterExtension(E_Decimal)
+}
+
+// Decimal reports whether field is marked (f4tq.plugins.decimal).
+func Decimal(field *descriptor.FieldDescriptorProto) bool {
+	if field.GetOptions() == nil {
+		return false
+	}
+	return getBool(field.GetOptions(), E_Decimal)
+}
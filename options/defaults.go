@@ -0,0 +1,27 @@
+package options
+
+import (
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+var E_DefaultValue = &proto.ExtensionDesc{
+	ExtendedType:  (*descriptor.FieldOptions)(nil),
+	ExtensionType: (*string)(nil),
+	Field:         50270,
+	Name:          "f4tq.plugins.default_value",
+	Tag:           "bytes,50270,opt,name=default_value,json=defaultValue",
+	Filename:      "options/options.proto",
+}
+
+func init() {
+	proto.RegisterExtension(E_DefaultValue)
+}
+
+// DefaultValue returns the (f4tq.plugins.default_value) of field, or "".
+func DefaultValue(field *descriptor.FieldDescriptorProto) string {
+	if field.GetOptions() == nil {
+		return ""
+	}
+	return getString(field.GetOptions(), E_DefaultValue)
+}
@@ -0,0 +1,45 @@
+package options
+
+import (
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+var E_DomainType = &proto.ExtensionDesc{
+	ExtendedType:  (*descriptor.MessageOptions)(nil),
+	ExtensionType: (*string)(nil),
+	Field:         50310,
+	Name:          "f4tq.plugins.domain_type",
+	Tag:           "bytes,50310,opt,name=domain_type,json=domainType",
+	Filename:      "options/options.proto",
+}
+
+var E_DomainField = &proto.ExtensionDesc{
+	ExtendedType:  (*descriptor.FieldOptions)(nil),
+	ExtensionType: (*string)(nil),
+	Field:         50311,
+	Name:          "f4tq.plugins.domain_field",
+	Tag:           "bytes,50311,opt,name=domain_field,json=domainField",
+	Filename:      "options/options.proto",
+}
+
+func init() {
+	proto.RegisterExtension(E_DomainType)
+	proto.RegisterExtension(E_DomainField)
+}
+
+// DomainType returns the (f4tq.plugins.domain_type) of msg, or "".
+func DomainType(msg *descriptor.DescriptorProto) string {
+	if msg.GetOptions() == nil {
+		return ""
+	}
+	return getString(msg.GetOptions(), E_DomainType)
+}
+
+// DomainField returns the (f4tq.plugins.domain_field) of field, or "".
+func DomainField(field *descriptor.FieldDescriptorProto) string {
+	if field.GetOptions() == nil {
+		return ""
+	}
+	return getString(field.GetOptions(), E_DomainField)
+}
@@ -0,0 +1,27 @@
+package options
+
+import (
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+var E_Encrypted = &proto.ExtensionDesc{
+	ExtendedType:  (*descriptor.FieldOptions)(nil),
+	ExtensionType: (*bool)(nil),
+	Field:         50360,
+	Name:          "f4tq.plugins.encrypted",
+	Tag:           "varint,50360,opt,name=encrypted",
+	Filename:      "options/options.proto",
+}
+
+func init() {
+	proto.RegisterExtension(E_Encrypted)
+}
+
+// Encrypted reports whether field sets (f4tq.plugins.encrypted).
+func Encrypted(field *descriptor.FieldDescriptorProto) bool {
+	if field.GetOptions() == nil {
+		return false
+	}
+	return getBool(field.GetOptions(), E_Encrypted)
+}
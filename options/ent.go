@@ -0,0 +1,27 @@
+package options
+
+import (
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+var E_Ent = &proto.ExtensionDesc{
+	ExtendedType:  (*descriptor.MessageOptions)(nil),
+	ExtensionType: (*bool)(nil),
+	Field:         50110,
+	Name:          "f4tq.plugins.ent",
+	Tag:           "varint,50110,opt,name=ent",
+	Filename:      "options/options.proto",
+}
+
+func init() {
+	proto.RegisterExtension(E_Ent)
+}
+
+// Ent reports whether msg is marked (f4tq.plugins.ent).
+func Ent(msg *descriptor.DescriptorProto) bool {
+	if msg.GetOptions() == nil {
+		return false
+	}
+	return getBool(msg.GetOptions(), E_Ent)
+}
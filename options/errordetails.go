@@ -0,0 +1,27 @@
+package options
+
+import (
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+var E_ErrorDetail = &proto.ExtensionDesc{
+	ExtendedType:  (*descriptor.MessageOptions)(nil),
+	ExtensionType: (*bool)(nil),
+	Field:         50210,
+	Name:          "f4tq.plugins.error_detail",
+	Tag:           "varint,50210,opt,name=error_detail,json=errorDetail",
+	Filename:      "options/options.proto",
+}
+
+func init() {
+	proto.RegisterExtension(E_ErrorDetail)
+}
+
+// ErrorDetail reports whether msg is marked (f4tq.plugins.error_detail).
+func ErrorDetail(msg *descriptor.DescriptorProto) bool {
+	if msg.GetOptions() == nil {
+		return false
+	}
+	return getBool(msg.GetOptions(), E_ErrorDetail)
+}
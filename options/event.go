@@ -0,0 +1,27 @@
+package options
+
+import (
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+var E_SchemaVersion = &proto.ExtensionDesc{
+	ExtendedType:  (*descriptor.FileOptions)(nil),
+	ExtensionType: (*string)(nil),
+	Field:         50330,
+	Name:          "f4tq.plugins.schema_version",
+	Tag:           "bytes,50330,opt,name=schema_version,json=schemaVersion",
+	Filename:      "options/options.proto",
+}
+
+func init() {
+	proto.RegisterExtension(E_SchemaVersion)
+}
+
+// SchemaVersion returns the (f4tq.plugins.schema_version) of file, or "".
+func SchemaVersion(file *descriptor.FileDescriptorProto) string {
+	if file.GetOptions() == nil {
+		return ""
+	}
+	return getString(file.GetOptions(), E_SchemaVersion)
+}
@@ -0,0 +1,28 @@
+package options
+
+import (
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+var E_GraphqlOperation = &proto.ExtensionDesc{
+	ExtendedType:  (*descriptor.MethodOptions)(nil),
+	ExtensionType: (*string)(nil),
+	Field:         50190,
+	Name:          "f4tq.plugins.graphql_operation",
+	Tag:           "bytes,50190,opt,name=graphql_operation,json=graphqlOperation",
+	Filename:      "options/options.proto",
+}
+
+func init() {
+	proto.RegisterExtension(E_GraphqlOperation)
+}
+
+// GraphQLOperation returns the (f4tq.plugins.graphql_operation) option of
+// method, or "" if unset.
+func GraphQLOperation(method *descriptor.MethodDescriptorProto) string {
+	if method.GetOptions() == nil {
+		return ""
+	}
+	return getString(method.GetOptions(), E_GraphqlOperation)
+}
@@ -0,0 +1,33 @@
+package options
+
+import (
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+var E_HealthDependencies = &proto.ExtensionDesc{
+	ExtendedType:  (*descriptor.ServiceOptions)(nil),
+	ExtensionType: ([]string)(nil),
+	Field:         50200,
+	Name:          "f4tq.plugins.health_dependencies",
+	Tag:           "bytes,50200,rep,name=health_dependencies,json=healthDependencies",
+	Filename:      "options/options.proto",
+}
+
+func init() {
+	proto.RegisterExtension(E_HealthDependencies)
+}
+
+// HealthDependencies returns the (f4tq.plugins.health_dependencies) of svc,
+// or nil.
+func HealthDependencies(svc *descriptor.ServiceDescriptorProto) []string {
+	if svc.GetOptions() == nil {
+		return nil
+	}
+	v, err := proto.GetExtension(svc.GetOptions(), E_HealthDependencies)
+	if err != nil {
+		return nil
+	}
+	deps, _ := v.([]string)
+	return deps
+}
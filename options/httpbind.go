@@ -0,0 +1,28 @@
+package options
+
+import (
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+var E_Header = &proto.ExtensionDesc{
+	ExtendedType:  (*descriptor.FieldOptions)(nil),
+	ExtensionType: (*string)(nil),
+	Field:         50400,
+	Name:          "f4tq.plugins.header",
+	Tag:           "bytes,50400,opt,name=header",
+	Filename:      "options/options.proto",
+}
+
+func init() {
+	proto.RegisterExtension(E_Header)
+}
+
+// Header returns the HTTP header (f4tq.plugins.header) bound to field, or
+// "".
+func Header(field *descriptor.FieldDescriptorProto) string {
+	if field.GetOptions() == nil {
+		return ""
+	}
+	return getString(field.GetOptions(), E_Header)
+}
@@ -0,0 +1,28 @@
+package options
+
+import (
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+var E_I18nText = &proto.ExtensionDesc{
+	ExtendedType:  (*descriptor.FieldOptions)(nil),
+	ExtensionType: (*string)(nil),
+	Field:         50420,
+	Name:          "f4tq.plugins.i18n_text",
+	Tag:           "bytes,50420,opt,name=i18n_text,json=i18nText",
+	Filename:      "options/options.proto",
+}
+
+func init() {
+	proto.RegisterExtension(E_I18nText)
+}
+
+// I18nText returns the source text (f4tq.plugins.i18n_text) of field, or
+// "" if field is not user-facing text.
+func I18nText(field *descriptor.FieldDescriptorProto) string {
+	if field.GetOptions() == nil {
+		return ""
+	}
+	return getString(field.GetOptions(), E_I18nText)
+}
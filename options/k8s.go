@@ -0,0 +1,75 @@
+package options
+
+import (
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+// CustomResource is the (f4tq.plugins.CustomResource) message.
+type CustomResource struct {
+	Group                *string  `protobuf:"bytes,1,opt,name=group" json:"group,omitempty"`
+	Version              *string  `protobuf:"bytes,2,opt,name=version" json:"version,omitempty"`
+	Kind                 *string  `protobuf:"bytes,3,opt,name=kind" json:"kind,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *CustomResource) Reset()         { *m = CustomResource{} }
+func (m *CustomResource) String() string { return proto.CompactTextString(m) }
+func (*CustomResource) ProtoMessage()    {}
+
+func (m *CustomResource) GetGroup() string {
+	if m != nil && m.Group != nil {
+		return *m.Group
+	}
+	return ""
+}
+
+func (m *CustomResource) GetVersion() string {
+	if m != nil && m.Version != nil {
+		return *m.Version
+	}
+	return ""
+}
+
+func (m *CustomResource) GetKind() string {
+	if m != nil && m.Kind != nil {
+		return *m.Kind
+	}
+	return ""
+}
+
+var E_Crd = &proto.ExtensionDesc{
+	ExtendedType:  (*descriptor.MessageOptions)(nil),
+	ExtensionType: (*CustomResource)(nil),
+	Field:         50380,
+	Name:          "f4tq.plugins.crd",
+	Tag:           "bytes,50380,opt,name=crd",
+	Filename:      "options/options.proto",
+}
+
+func init() {
+	proto.RegisterType((*CustomResource)(nil), "f4tq.plugins.CustomResource")
+	proto.RegisterExtension(E_Crd)
+}
+
+// CRD returns the (f4tq.plugins.crd) of msg, or nil. Its kind defaults to
+// the message name.
+func CRD(msg *descriptor.DescriptorProto) *CustomResource {
+	if msg.GetOptions() == nil {
+		return nil
+	}
+	v, err := proto.GetExtension(msg.GetOptions(), E_Crd)
+	if err != nil {
+		return nil
+	}
+	c, _ := v.(*CustomResource)
+	if c == nil {
+		return nil
+	}
+	if c.Kind == nil {
+		c = &CustomResource{Group: c.Group, Version: c.Version, Kind: proto.String(msg.GetName())}
+	}
+	return c
+}
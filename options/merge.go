@@ -0,0 +1,27 @@
+package options
+
+import (
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+var E_MergeReplace = &proto.ExtensionDesc{
+	ExtendedType:  (*descriptor.FieldOptions)(nil),
+	ExtensionType: (*bool)(nil),
+	Field:         50260,
+	Name:          "f4tq.plugins.merge_replace",
+	Tag:           "varint,50260,opt,name=merge_replace,json=mergeReplace",
+	Filename:      "options/options.proto",
+}
+
+func init() {
+	proto.RegisterExtension(E_MergeReplace)
+}
+
+// MergeReplace reports whether field is marked (f4tq.plugins.merge_replace).
+func MergeReplace(field *descriptor.FieldDescriptorProto) bool {
+	if field.GetOptions() == nil {
+		return false
+	}
+	return getBool(field.GetOptions(), E_MergeReplace)
+}
@@ -0,0 +1,191 @@
+package options
+
+import (
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+var E_Topic = &proto.ExtensionDesc{
+	ExtendedType:  (*descriptor.MessageOptions)(nil),
+	ExtensionType: (*string)(nil),
+	Field:         50120,
+	Name:          "f4tq.plugins.topic",
+	Tag:           "bytes,50120,opt,name=topic",
+	Filename:      "options/options.proto",
+}
+
+var E_SubjectNameStrategy = &proto.ExtensionDesc{
+	ExtendedType:  (*descriptor.MessageOptions)(nil),
+	ExtensionType: (*string)(nil),
+	Field:         50121,
+	Name:          "f4tq.plugins.subject_name_strategy",
+	Tag:           "bytes,50121,opt,name=subject_name_strategy,json=subjectNameStrategy",
+	Filename:      "options/options.proto",
+}
+
+var E_SubjectPrefix = &proto.ExtensionDesc{
+	ExtendedType:  (*descriptor.ServiceOptions)(nil),
+	ExtensionType: (*string)(nil),
+	Field:         50122,
+	Name:          "f4tq.plugins.subject_prefix",
+	Tag:           "bytes,50122,opt,name=subject_prefix,json=subjectPrefix",
+	Filename:      "options/options.proto",
+}
+
+var E_Subject = &proto.ExtensionDesc{
+	ExtendedType:  (*descriptor.MethodOptions)(nil),
+	ExtensionType: (*string)(nil),
+	Field:         50123,
+	Name:          "f4tq.plugins.subject",
+	Tag:           "bytes,50123,opt,name=subject",
+	Filename:      "options/options.proto",
+}
+
+var E_MessageKey = &proto.ExtensionDesc{
+	ExtendedType:  (*descriptor.FieldOptions)(nil),
+	ExtensionType: (*bool)(nil),
+	Field:         50124,
+	Name:          "f4tq.plugins.message_key",
+	Tag:           "varint,50124,opt,name=message_key,json=messageKey",
+	Filename:      "options/options.proto",
+}
+
+var E_Exchange = &proto.ExtensionDesc{
+	ExtendedType:  (*descriptor.MessageOptions)(nil),
+	ExtensionType: (*string)(nil),
+	Field:         50125,
+	Name:          "f4tq.plugins.exchange",
+	Tag:           "bytes,50125,opt,name=exchange",
+	Filename:      "options/options.proto",
+}
+
+var E_ExchangeType = &proto.ExtensionDesc{
+	ExtendedType:  (*descriptor.MessageOptions)(nil),
+	ExtensionType: (*string)(nil),
+	Field:         50126,
+	Name:          "f4tq.plugins.exchange_type",
+	Tag:           "bytes,50126,opt,name=exchange_type,json=exchangeType",
+	Filename:      "options/options.proto",
+}
+
+var E_RoutingKey = &proto.ExtensionDesc{
+	ExtendedType:  (*descriptor.MessageOptions)(nil),
+	ExtensionType: (*string)(nil),
+	Field:         50127,
+	Name:          "f4tq.plugins.routing_key",
+	Tag:           "bytes,50127,opt,name=routing_key,json=routingKey",
+	Filename:      "options/options.proto",
+}
+
+var E_MessageAttribute = &proto.ExtensionDesc{
+	ExtendedType:  (*descriptor.FieldOptions)(nil),
+	ExtensionType: (*string)(nil),
+	Field:         50128,
+	Name:          "f4tq.plugins.message_attribute",
+	Tag:           "bytes,50128,opt,name=message_attribute,json=messageAttribute",
+	Filename:      "options/options.proto",
+}
+
+func init() {
+	proto.RegisterExtension(E_Topic)
+	proto.RegisterExtension(E_SubjectNameStrategy)
+	proto.RegisterExtension(E_SubjectPrefix)
+	proto.RegisterExtension(E_Subject)
+	proto.RegisterExtension(E_MessageKey)
+	proto.RegisterExtension(E_Exchange)
+	proto.RegisterExtension(E_ExchangeType)
+	proto.RegisterExtension(E_RoutingKey)
+	proto.RegisterExtension(E_MessageAttribute)
+}
+
+// Topic returns the (f4tq.plugins.topic) option of msg, or "" if unset.
+func Topic(msg *descriptor.DescriptorProto) string {
+	if msg.GetOptions() == nil {
+		return ""
+	}
+	return getString(msg.GetOptions(), E_Topic)
+}
+
+// SubjectNameStrategy returns the (f4tq.plugins.subject_name_strategy)
+// option of msg, or "" if unset.
+func SubjectNameStrategy(msg *descriptor.DescriptorProto) string {
+	if msg.GetOptions() == nil {
+		return ""
+	}
+	return getString(msg.GetOptions(), E_SubjectNameStrategy)
+}
+
+// SubjectPrefix returns the (f4tq.plugins.subject_prefix) option of svc, or
+// "" if unset.
+func SubjectPrefix(svc *descriptor.ServiceDescriptorProto) string {
+	if svc.GetOptions() == nil {
+		return ""
+	}
+	return getString(svc.GetOptions(), E_SubjectPrefix)
+}
+
+// Subject returns the subject method of the service fullName is served on:
+// its (f4tq.plugins.subject) option, or the service's subject_prefix (the
+// full service name if unset) followed by "." and the method name.
+func Subject(fullName string, svc *descriptor.ServiceDescriptorProto, method *descriptor.MethodDescriptorProto) string {
+	if method.GetOptions() != nil {
+		if s := getString(method.GetOptions(), E_Subject); s != "" {
+			return s
+		}
+	}
+	prefix := SubjectPrefix(svc)
+	if prefix == "" {
+		prefix = fullName
+	}
+	return prefix + "." + method.GetName()
+}
+
+// MessageKey reports whether field has the (f4tq.plugins.message_key)
+// option set.
+func MessageKey(field *descriptor.FieldDescriptorProto) bool {
+	if field.GetOptions() == nil {
+		return false
+	}
+	return getBool(field.GetOptions(), E_MessageKey)
+}
+
+// Exchange returns the (f4tq.plugins.exchange) option of msg, or "" if
+// unset.
+func Exchange(msg *descriptor.DescriptorProto) string {
+	if msg.GetOptions() == nil {
+		return ""
+	}
+	return getString(msg.GetOptions(), E_Exchange)
+}
+
+// ExchangeType returns the (f4tq.plugins.exchange_type) option of msg, or
+// "topic" if unset.
+func ExchangeType(msg *descriptor.DescriptorProto) string {
+	if msg.GetOptions() != nil {
+		if s := getString(msg.GetOptions(), E_ExchangeType); s != "" {
+			return s
+		}
+	}
+	return "topic"
+}
+
+// RoutingKey returns the (f4tq.plugins.routing_key) option of msg, or its
+// topic if unset.
+func RoutingKey(msg *descriptor.DescriptorProto) string {
+	if msg.GetOptions() == nil {
+		return ""
+	}
+	if s := getString(msg.GetOptions(), E_RoutingKey); s != "" {
+		return s
+	}
+	return Topic(msg)
+}
+
+// MessageAttribute returns the (f4tq.plugins.message_attribute) option of
+// field, or "" if unset.
+func MessageAttribute(field *descriptor.FieldDescriptorProto) string {
+	if field.GetOptions() == nil {
+		return ""
+	}
+	return getString(field.GetOptions(), E_MessageAttribute)
+}
@@ -0,0 +1,32 @@
+package options
+
+import (
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+var E_Normalize = &proto.ExtensionDesc{
+	ExtendedType:  (*descriptor.FieldOptions)(nil),
+	ExtensionType: ([]string)(nil),
+	Field:         50320,
+	Name:          "f4tq.plugins.normalize",
+	Tag:           "bytes,50320,rep,name=normalize",
+	Filename:      "options/options.proto",
+}
+
+func init() {
+	proto.RegisterExtension(E_Normalize)
+}
+
+// Normalize returns the (f4tq.plugins.normalize) steps of field, or nil.
+func Normalize(field *descriptor.FieldDescriptorProto) []string {
+	if field.GetOptions() == nil {
+		return nil
+	}
+	v, err := proto.GetExtension(field.GetOptions(), E_Normalize)
+	if err != nil {
+		return nil
+	}
+	steps, _ := v.([]string)
+	return steps
+}
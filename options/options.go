@@ -0,0 +1,31 @@
+// Package options declares the custom descriptor options defined in
+// options.proto and provides accessors used by the plugins in this repo.
+package options
+
+import (
+	"github.com/golang/protobuf/proto"
+)
+
+// getString returns the string value of ext set on opts, or "".
+func getString(opts proto.Message, ext *proto.ExtensionDesc) string {
+	v, err := proto.GetExtension(opts, ext)
+	if err != nil {
+		return ""
+	}
+	if s, ok := v.(*string); ok && s != nil {
+		return *s
+	}
+	return ""
+}
+
+// getBool returns the bool value of ext set on opts, or false.
+func getBool(opts proto.Message, ext *proto.ExtensionDesc) bool {
+	v, err := proto.GetExtension(opts, ext)
+	if err != nil {
+		return false
+	}
+	if b, ok := v.(*bool); ok && b != nil {
+		return *b
+	}
+	return false
+}
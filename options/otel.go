@@ -0,0 +1,28 @@
+package options
+
+import (
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+var E_TraceAttribute = &proto.ExtensionDesc{
+	ExtendedType:  (*descriptor.FieldOptions)(nil),
+	ExtensionType: (*bool)(nil),
+	Field:         50140,
+	Name:          "f4tq.plugins.trace_attribute",
+	Tag:           "varint,50140,opt,name=trace_attribute",
+	Filename:      "options/options.proto",
+}
+
+func init() {
+	proto.RegisterExtension(E_TraceAttribute)
+}
+
+// TraceAttribute reports whether field is marked
+// (f4tq.plugins.trace_attribute).
+func TraceAttribute(field *descriptor.FieldDescriptorProto) bool {
+	if field.GetOptions() == nil {
+		return false
+	}
+	return getBool(field.GetOptions(), E_TraceAttribute)
+}
@@ -0,0 +1,32 @@
+package options
+
+import (
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+var E_LatencyBuckets = &proto.ExtensionDesc{
+	ExtendedType:  (*descriptor.ServiceOptions)(nil),
+	ExtensionType: ([]float64)(nil),
+	Field:         50150,
+	Name:          "f4tq.plugins.latency_buckets",
+	Tag:           "fixed64,50150,rep,name=latency_buckets",
+	Filename:      "options/options.proto",
+}
+
+func init() {
+	proto.RegisterExtension(E_LatencyBuckets)
+}
+
+// LatencyBuckets returns the (f4tq.plugins.latency_buckets) of svc, or nil.
+func LatencyBuckets(svc *descriptor.ServiceDescriptorProto) []float64 {
+	if svc.GetOptions() == nil {
+		return nil
+	}
+	v, err := proto.GetExtension(svc.GetOptions(), E_LatencyBuckets)
+	if err != nil {
+		return nil
+	}
+	buckets, _ := v.([]float64)
+	return buckets
+}
@@ -0,0 +1,60 @@
+package options
+
+import (
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+// RateLimit is the (f4tq.plugins.RateLimit) message.
+type RateLimit struct {
+	RequestsPerSecond    *float64 `protobuf:"fixed64,1,opt,name=requests_per_second,json=requestsPerSecond" json:"requests_per_second,omitempty"`
+	Burst                *uint32  `protobuf:"varint,2,opt,name=burst" json:"burst,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *RateLimit) Reset()         { *m = RateLimit{} }
+func (m *RateLimit) String() string { return proto.CompactTextString(m) }
+func (*RateLimit) ProtoMessage()    {}
+
+func (m *RateLimit) GetRequestsPerSecond() float64 {
+	if m != nil && m.RequestsPerSecond != nil {
+		return *m.RequestsPerSecond
+	}
+	return 0
+}
+
+func (m *RateLimit) GetBurst() uint32 {
+	if m != nil && m.Burst != nil {
+		return *m.Burst
+	}
+	return 0
+}
+
+var E_RateLimit = &proto.ExtensionDesc{
+	ExtendedType:  (*descriptor.MethodOptions)(nil),
+	ExtensionType: (*RateLimit)(nil),
+	Field:         50180,
+	Name:          "f4tq.plugins.rate_limit",
+	Tag:           "bytes,50180,opt,name=rate_limit",
+	Filename:      "options/options.proto",
+}
+
+func init() {
+	proto.RegisterType((*RateLimit)(nil), "f4tq.plugins.RateLimit")
+	proto.RegisterExtension(E_RateLimit)
+}
+
+// MethodRateLimit returns the (f4tq.plugins.rate_limit) of method, or nil.
+func MethodRateLimit(method *descriptor.MethodDescriptorProto) *RateLimit {
+	if method.GetOptions() == nil {
+		return nil
+	}
+	v, err := proto.GetExtension(method.GetOptions(), E_RateLimit)
+	if err != nil {
+		return nil
+	}
+	rl, _ := v.(*RateLimit)
+	return rl
+}
@@ -0,0 +1,128 @@
+package options
+
+import (
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+// RetryPolicy is the (f4tq.plugins.RetryPolicy) message.
+type RetryPolicy struct {
+	MaxAttempts          *uint32  `protobuf:"varint,1,opt,name=max_attempts,json=maxAttempts" json:"max_attempts,omitempty"`
+	Codes                []string `protobuf:"bytes,2,rep,name=codes" json:"codes,omitempty"`
+	InitialBackoff       *string  `protobuf:"bytes,3,opt,name=initial_backoff,json=initialBackoff" json:"initial_backoff,omitempty"`
+	MaxBackoff           *string  `protobuf:"bytes,4,opt,name=max_backoff,json=maxBackoff" json:"max_backoff,omitempty"`
+	BackoffMultiplier    *float64 `protobuf:"fixed64,5,opt,name=backoff_multiplier,json=backoffMultiplier" json:"backoff_multiplier,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *RetryPolicy) Reset()         { *m = RetryPolicy{} }
+func (m *RetryPolicy) String() string { return proto.CompactTextString(m) }
+func (*RetryPolicy) ProtoMessage()    {}
+
+func (m *RetryPolicy) GetMaxAttempts() uint32 {
+	if m != nil && m.MaxAttempts != nil {
+		return *m.MaxAttempts
+	}
+	return 0
+}
+
+func (m *RetryPolicy) GetCodes() []string {
+	if m != nil {
+		return m.Codes
+	}
+	return nil
+}
+
+func (m *RetryPolicy) GetInitialBackoff() string {
+	if m != nil && m.InitialBackoff != nil {
+		return *m.InitialBackoff
+	}
+	return ""
+}
+
+func (m *RetryPolicy) GetMaxBackoff() string {
+	if m != nil && m.MaxBackoff != nil {
+		return *m.MaxBackoff
+	}
+	return ""
+}
+
+func (m *RetryPolicy) GetBackoffMultiplier() float64 {
+	if m != nil && m.BackoffMultiplier != nil {
+		return *m.BackoffMultiplier
+	}
+	return 0
+}
+
+var E_DefaultRetry = &proto.ExtensionDesc{
+	ExtendedType:  (*descriptor.ServiceOptions)(nil),
+	ExtensionType: (*RetryPolicy)(nil),
+	Field:         50160,
+	Name:          "f4tq.plugins.default_retry",
+	Tag:           "bytes,50160,opt,name=default_retry",
+	Filename:      "options/options.proto",
+}
+
+var E_Retry = &proto.ExtensionDesc{
+	ExtendedType:  (*descriptor.MethodOptions)(nil),
+	ExtensionType: (*RetryPolicy)(nil),
+	Field:         50160,
+	Name:          "f4tq.plugins.retry",
+	Tag:           "bytes,50160,opt,name=retry",
+	Filename:      "options/options.proto",
+}
+
+func init() {
+	proto.RegisterType((*RetryPolicy)(nil), "f4tq.plugins.RetryPolicy")
+	proto.RegisterExtension(E_DefaultRetry)
+	proto.RegisterExtension(E_Retry)
+}
+
+// Retry returns the retry policy of method: its (f4tq.plugins.retry)
+// merged over the (f4tq.plugins.default_retry) of svc. It returns nil if
+// neither is set.
+func Retry(svc *descriptor.ServiceDescriptorProto, method *descriptor.MethodDescriptorProto) *RetryPolicy {
+	var def, override *RetryPolicy
+	if svc.GetOptions() != nil {
+		def = getRetryPolicy(svc.GetOptions(), E_DefaultRetry)
+	}
+	if method.GetOptions() != nil {
+		override = getRetryPolicy(method.GetOptions(), E_Retry)
+	}
+	if def == nil && override == nil {
+		return nil
+	}
+	p := &RetryPolicy{}
+	for _, src := range []*RetryPolicy{def, override} {
+		if src == nil {
+			continue
+		}
+		if src.MaxAttempts != nil {
+			p.MaxAttempts = src.MaxAttempts
+		}
+		if len(src.Codes) > 0 {
+			p.Codes = src.Codes
+		}
+		if src.InitialBackoff != nil {
+			p.InitialBackoff = src.InitialBackoff
+		}
+		if src.MaxBackoff != nil {
+			p.MaxBackoff = src.MaxBackoff
+		}
+		if src.BackoffMultiplier != nil {
+			p.BackoffMultiplier = src.BackoffMultiplier
+		}
+	}
+	return p
+}
+
+func getRetryPolicy(opts proto.Message, ext *proto.ExtensionDesc) *RetryPolicy {
+	v, err := proto.GetExtension(opts, ext)
+	if err != nil {
+		return nil
+	}
+	p, _ := v.(*RetryPolicy)
+	return p
+}
@@ -0,0 +1,102 @@
+package options
+
+import (
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+var E_Table = &proto.ExtensionDesc{
+	ExtendedType:  (*descriptor.MessageOptions)(nil),
+	ExtensionType: (*string)(nil),
+	Field:         50100,
+	Name:          "f4tq.plugins.table",
+	Tag:           "bytes,50100,opt,name=table",
+	Filename:      "options/options.proto",
+}
+
+var E_Column = &proto.ExtensionDesc{
+	ExtendedType:  (*descriptor.FieldOptions)(nil),
+	ExtensionType: (*string)(nil),
+	Field:         50100,
+	Name:          "f4tq.plugins.column",
+	Tag:           "bytes,50100,opt,name=column",
+	Filename:      "options/options.proto",
+}
+
+var E_PrimaryKey = &proto.ExtensionDesc{
+	ExtendedType:  (*descriptor.FieldOptions)(nil),
+	ExtensionType: (*bool)(nil),
+	Field:         50101,
+	Name:          "f4tq.plugins.primary_key",
+	Tag:           "varint,50101,opt,name=primary_key,json=primaryKey",
+	Filename:      "options/options.proto",
+}
+
+var E_Index = &proto.ExtensionDesc{
+	ExtendedType:  (*descriptor.FieldOptions)(nil),
+	ExtensionType: (*bool)(nil),
+	Field:         50102,
+	Name:          "f4tq.plugins.index",
+	Tag:           "varint,50102,opt,name=index",
+	Filename:      "options/options.proto",
+}
+
+var E_Unique = &proto.ExtensionDesc{
+	ExtendedType:  (*descriptor.FieldOptions)(nil),
+	ExtensionType: (*bool)(nil),
+	Field:         50103,
+	Name:          "f4tq.plugins.unique",
+	Tag:           "varint,50103,opt,name=unique",
+	Filename:      "options/options.proto",
+}
+
+func init() {
+	proto.RegisterExtension(E_Table)
+	proto.RegisterExtension(E_Column)
+	proto.RegisterExtension(E_PrimaryKey)
+	proto.RegisterExtension(E_Index)
+	proto.RegisterExtension(E_Unique)
+}
+
+// Table returns the (f4tq.plugins.table) option of msg, or "" if unset.
+func Table(msg *descriptor.DescriptorProto) string {
+	if msg.GetOptions() == nil {
+		return ""
+	}
+	return getString(msg.GetOptions(), E_Table)
+}
+
+// Column returns the SQL column name of field: the (f4tq.plugins.column)
+// option if set, otherwise the proto field name.
+func Column(field *descriptor.FieldDescriptorProto) string {
+	if field.GetOptions() != nil {
+		if c := getString(field.GetOptions(), E_Column); c != "" {
+			return c
+		}
+	}
+	return field.GetName()
+}
+
+// PrimaryKey reports whether field is marked (f4tq.plugins.primary_key).
+func PrimaryKey(field *descriptor.FieldDescriptorProto) bool {
+	if field.GetOptions() == nil {
+		return false
+	}
+	return getBool(field.GetOptions(), E_PrimaryKey)
+}
+
+// Index reports whether field is marked (f4tq.plugins.index).
+func Index(field *descriptor.FieldDescriptorProto) bool {
+	if field.GetOptions() == nil {
+		return false
+	}
+	return getBool(field.GetOptions(), E_Index)
+}
+
+// Unique reports whether field is marked (f4tq.plugins.unique).
+func Unique(field *descriptor.FieldDescriptorProto) bool {
+	if field.GetOptions() == nil {
+		return false
+	}
+	return getBool(field.GetOptions(), E_Unique)
+}
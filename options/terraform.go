@@ -0,0 +1,47 @@
+package options
+
+import (
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+var E_TerraformResource = &proto.ExtensionDesc{
+	ExtendedType:  (*descriptor.MessageOptions)(nil),
+	ExtensionType: (*string)(nil),
+	Field:         50410,
+	Name:          "f4tq.plugins.terraform_resource",
+	Tag:           "bytes,50410,opt,name=terraform_resource,json=terraformResource",
+	Filename:      "options/options.proto",
+}
+
+var E_TerraformComputed = &proto.ExtensionDesc{
+	ExtendedType:  (*descriptor.FieldOptions)(nil),
+	ExtensionType: (*bool)(nil),
+	Field:         50411,
+	Name:          "f4tq.plugins.terraform_computed",
+	Tag:           "varint,50411,opt,name=terraform_computed,json=terraformComputed",
+	Filename:      "options/options.proto",
+}
+
+func init() {
+	proto.RegisterExtension(E_TerraformResource)
+	proto.RegisterExtension(E_TerraformComputed)
+}
+
+// TerraformResource returns the Terraform resource type name
+// (f4tq.plugins.terraform_resource) of msg, or "".
+func TerraformResource(msg *descriptor.DescriptorProto) string {
+	if msg.GetOptions() == nil {
+		return ""
+	}
+	return getString(msg.GetOptions(), E_TerraformResource)
+}
+
+// TerraformComputed reports whether field is marked
+// (f4tq.plugins.terraform_computed).
+func TerraformComputed(field *descriptor.FieldDescriptorProto) bool {
+	if field.GetOptions() == nil {
+		return false
+	}
+	return getBool(field.GetOptions(), E_TerraformComputed)
+}
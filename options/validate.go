@@ -0,0 +1,77 @@
+package options
+
+import (
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+// FieldRules is the (f4tq.plugins.FieldRules) message.
+type FieldRules struct {
+	Required             *bool    `protobuf:"varint,1,opt,name=required" json:"required,omitempty"`
+	MinLen               *uint32  `protobuf:"varint,2,opt,name=min_len,json=minLen" json:"min_len,omitempty"`
+	MaxLen               *uint32  `protobuf:"varint,3,opt,name=max_len,json=maxLen" json:"max_len,omitempty"`
+	Pattern              *string  `protobuf:"bytes,4,opt,name=pattern" json:"pattern,omitempty"`
+	Gte                  *float64 `protobuf:"fixed64,5,opt,name=gte" json:"gte,omitempty"`
+	Lte                  *float64 `protobuf:"fixed64,6,opt,name=lte" json:"lte,omitempty"`
+	Gt                   *float64 `protobuf:"fixed64,7,opt,name=gt" json:"gt,omitempty"`
+	Lt                   *float64 `protobuf:"fixed64,8,opt,name=lt" json:"lt,omitempty"`
+	MinItems             *uint32  `protobuf:"varint,9,opt,name=min_items,json=minItems" json:"min_items,omitempty"`
+	MaxItems             *uint32  `protobuf:"varint,10,opt,name=max_items,json=maxItems" json:"max_items,omitempty"`
+	Skip                 *bool    `protobuf:"varint,11,opt,name=skip" json:"skip,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *FieldRules) Reset()         { *m = FieldRules{} }
+func (m *FieldRules) String() string { return proto.CompactTextString(m) }
+func (*FieldRules) ProtoMessage()    {}
+
+func (m *FieldRules) GetRequired() bool {
+	if m != nil && m.Required != nil {
+		return *m.Required
+	}
+	return false
+}
+
+func (m *FieldRules) GetPattern() string {
+	if m != nil && m.Pattern != nil {
+		return *m.Pattern
+	}
+	return ""
+}
+
+func (m *FieldRules) GetSkip() bool {
+	if m != nil && m.Skip != nil {
+		return *m.Skip
+	}
+	return false
+}
+
+var E_Validate = &proto.ExtensionDesc{
+	ExtendedType:  (*descriptor.FieldOptions)(nil),
+	ExtensionType: (*FieldRules)(nil),
+	Field:         50250,
+	Name:          "f4tq.plugins.validate",
+	Tag:           "bytes,50250,opt,name=validate",
+	Filename:      "options/options.proto",
+}
+
+func init() {
+	proto.RegisterType((*FieldRules)(nil), "f4tq.plugins.FieldRules")
+	proto.RegisterExtension(E_Validate)
+}
+
+// Rules returns the (f4tq.plugins.validate) of field, or nil. The bounds
+// of the rules are only meaningful when their pointer fields are set.
+func Rules(field *descriptor.FieldDescriptorProto) *FieldRules {
+	if field.GetOptions() == nil {
+		return nil
+	}
+	v, err := proto.GetExtension(field.GetOptions(), E_Validate)
+	if err != nil {
+		return nil
+	}
+	r, _ := v.(*FieldRules)
+	return r
+}
@@ -0,0 +1,311 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+
+	"github.com/f4tq/protoc-go-plugins/options"
+	"github.com/f4tq/protoc-go-plugins/protodoc"
+)
+
+var (
+	hdrTmpl = template.Must(template.New("header").Parse(`
+// Code generated by protoc-gen-go-amqp. DO NOT EDIT.
+// source: {{.Source}}
+
+package {{.GoPkg}}
+
+import (
+    "context"
+
+    "github.com/f4tq/protoc-go-plugins/runtime/amqppb"
+    "github.com/golang/protobuf/proto"
+    "github.com/streadway/amqp"
+)
+`))
+
+	messageTmpl = template.Must(template.New("message").Parse(`
+// Routing of {{.Name}} messages over AMQP.{{.Doc}}
+const (
+    {{.Name}}AMQPExchange     = {{printf "%q" .Exchange}}
+    {{.Name}}AMQPExchangeType = {{printf "%q" .ExchangeType}}
+    {{.Name}}AMQPRoutingKey   = {{printf "%q" .RoutingKey}}
+)
+
+// Declare{{.Name}}AMQP declares the exchange {{.Name}} messages are
+// published to and a durable queue bound to it.{{.Doc}}
+func Declare{{.Name}}AMQP(ch *amqp.Channel, queue string) error {
+    return amqppb.Declare(ch, {{.Name}}AMQPExchange, {{.Name}}AMQPExchangeType, queue, {{.Name}}AMQPRoutingKey)
+}
+
+// {{.Name}}AMQPPublisher publishes {{.Name}} messages to
+// {{.Name}}AMQPExchange with {{.Name}}AMQPRoutingKey.{{.Doc}}
+type {{.Name}}AMQPPublisher struct {
+    publisher *amqppb.Publisher
+}
+
+// New{{.Name}}AMQPPublisher returns a {{.Name}}AMQPPublisher publishing
+// through publisher.{{.Doc}}
+func New{{.Name}}AMQPPublisher(publisher *amqppb.Publisher) *{{.Name}}AMQPPublisher {
+    return &{{.Name}}AMQPPublisher{publisher: publisher}
+}
+
+// Publish publishes msg.
+func (p *{{.Name}}AMQPPublisher) Publish(msg *{{.Name}}) error {
+    return p.publisher.Publish({{.Name}}AMQPExchange, {{.Name}}AMQPRoutingKey, msg)
+}
+
+// {{.Name}}AMQPHandler handles consumed {{.Name}} messages.
+// amqppb.Delivery(ctx) returns the delivery a message was decoded from.
+// Returning an error requeues the message unless it is amqppb.Permanent.{{.Doc}}
+type {{.Name}}AMQPHandler interface {
+    Handle{{.Name}}(ctx context.Context, msg *{{.Name}}) error
+}
+
+// {{.Name}}AMQPHandlerFunc adapts a function to a {{.Name}}AMQPHandler.{{.Doc}}
+type {{.Name}}AMQPHandlerFunc func(ctx context.Context, msg *{{.Name}}) error
+
+// Handle{{.Name}} calls f(ctx, msg).
+func (f {{.Name}}AMQPHandlerFunc) Handle{{.Name}}(ctx context.Context, msg *{{.Name}}) error {
+    return f(ctx, msg)
+}
+
+// Consume{{.Name}}AMQP passes the {{.Name}} messages of queue to h until
+// ctx is done. consumer is a consumer tag unique on ch.{{.Doc}}
+func Consume{{.Name}}AMQP(ctx context.Context, ch *amqp.Channel, queue, consumer string, h {{.Name}}AMQPHandler) error {
+    return amqppb.Consume(ctx, ch, queue, consumer, func() proto.Message { return new({{.Name}}) }, func(ctx context.Context, msg proto.Message) error {
+        return h.Handle{{.Name}}(ctx, msg.(*{{.Name}}))
+    })
+}
+`))
+)
+
+// exchangeTypes are the exchange kinds accepted by the exchange_type
+// option.
+var exchangeTypes = map[string]bool{
+	"direct":  true,
+	"fanout":  true,
+	"topic":   true,
+	"headers": true,
+}
+
+func main() {
+	input, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	req := new(plugin.CodeGeneratorRequest)
+	if err = proto.Unmarshal(input, req); err != nil {
+		log.Fatal(err)
+	}
+
+	out, err := generate(req)
+
+	if err != nil {
+		emitError(err)
+		return
+	}
+
+	emitFiles(out)
+}
+
+func generate(req *plugin.CodeGeneratorRequest) ([]*plugin.CodeGeneratorResponse_File, error) {
+	var files []*plugin.CodeGeneratorResponse_File
+	docs := protodoc.New(req.GetProtoFile())
+	genFileNames := make(map[string]bool)
+	for _, n := range req.FileToGenerate {
+		genFileNames[n] = true
+	}
+	for _, desc := range req.GetProtoFile() {
+		name := desc.GetName()
+		if _, ok := genFileNames[name]; !ok {
+			// Only emit output for files present in req.FileToGenerate.
+			continue
+		}
+		code, err := genCode(desc, docs)
+		if err != nil {
+			return nil, err
+		}
+		if code == "" {
+			// No message declares an exchange or topic.
+			continue
+		}
+		formatted, err := format.Source([]byte(code))
+		if err != nil {
+			log.Printf("%v: %s", err, code)
+			return nil, err
+		}
+
+		ext := filepath.Ext(name)
+		base := strings.TrimSuffix(name, ext)
+		output := fmt.Sprintf("%s.pb.amqp.go", base)
+		files = append(files, &plugin.CodeGeneratorResponse_File{
+			Name:    proto.String(output),
+			Content: proto.String(string(formatted)),
+		})
+	}
+
+	return files, nil
+}
+
+func genCode(desc *descriptor.FileDescriptorProto, docs *protodoc.Index) (string, error) {
+	w := bytes.NewBuffer(nil)
+	prefix := ""
+	if desc.GetPackage() != "" {
+		prefix = "." + desc.GetPackage()
+	}
+	body := bytes.NewBuffer(nil)
+	if err := genMessages(body, docs, prefix, desc.GetMessageType()); err != nil {
+		return "", err
+	}
+	if body.Len() == 0 {
+		return "", nil
+	}
+
+	hdr := &header{
+		Source: desc.GetName(),
+		GoPkg:  defaultGoPackageName(desc),
+	}
+	if err := hdrTmpl.Execute(w, hdr); err != nil {
+		log.Fatal(err)
+	}
+	body.WriteTo(w)
+
+	return w.String(), nil
+}
+
+// genMessages writes the publishers and consumers of the messages in msgs,
+// and their nested messages, that have an (f4tq.plugins.exchange) or
+// (f4tq.plugins.topic) option.
+func genMessages(w *bytes.Buffer, docs *protodoc.Index, prefix string, msgs []*descriptor.DescriptorProto) error {
+	for _, m := range msgs {
+		name := prefix + "." + m.GetName()
+		if m.GetOptions().GetMapEntry() {
+			continue
+		}
+		if options.Exchange(m) != "" || options.Topic(m) != "" {
+			t := &amqpMessage{
+				Name:         localTypeName(name),
+				Exchange:     options.Exchange(m),
+				ExchangeType: options.ExchangeType(m),
+				RoutingKey:   options.RoutingKey(m),
+				Doc:          docs.Godoc(name),
+			}
+			if !exchangeTypes[t.ExchangeType] {
+				return fmt.Errorf("%s: unknown exchange_type %q", strings.TrimPrefix(name, "."), t.ExchangeType)
+			}
+			if t.Exchange == "" && t.RoutingKey == "" {
+				return fmt.Errorf("%s: the default exchange requires a routing_key or topic", strings.TrimPrefix(name, "."))
+			}
+			if err := messageTmpl.Execute(w, t); err != nil {
+				return err
+			}
+		}
+		if err := genMessages(w, docs, name, m.GetNestedType()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type header struct {
+	Source string
+	GoPkg  string
+}
+
+type amqpMessage struct {
+	Name         string
+	Exchange     string
+	ExchangeType string
+	RoutingKey   string
+	// Doc is the comment of the message, see protodoc.Index.Godoc.
+	Doc string
+}
+
+// localTypeName returns the Go name of a type declared in the file being
+// generated, e.g. ".pkg.Outer.Inner" becomes "Outer_Inner".
+func localTypeName(typeName string) string {
+	parts := strings.Split(strings.TrimPrefix(typeName, "."), ".")
+	var names []string
+	for _, p := range parts {
+		if len(p) > 0 && p[0] >= 'A' && p[0] <= 'Z' {
+			names = append(names, p)
+		}
+	}
+	return strings.Join(names, "_")
+}
+
+// sanitizePackageName replaces unallowed character in package name
+// with allowed character.
+func sanitizePackageName(pkgName string) string {
+	pkgName = strings.Replace(pkgName, ".", "_", -1)
+	pkgName = strings.Replace(pkgName, "-", "_", -1)
+	return pkgName
+}
+
+// defaultGoPackageName returns the default go package name to be used for go files generated from "f".
+// You might need to use an unique alias for the package when you import it.  Use ReserveGoPackageAlias to get a unique alias.
+func defaultGoPackageName(f *descriptor.FileDescriptorProto) string {
+	name := packageIdentityName(f)
+	return sanitizePackageName(name)
+}
+
+// packageIdentityName returns the identity of packages.
+// protoc-gen-grpc-gateway rejects CodeGenerationRequests which contains more than one packages
+// as protoc-gen-go does.
+func packageIdentityName(f *descriptor.FileDescriptorProto) string {
+	if f.Options != nil && f.Options.GoPackage != nil {
+		gopkg := f.Options.GetGoPackage()
+		idx := strings.LastIndex(gopkg, "/")
+		if idx < 0 {
+			gopkg = gopkg[idx+1:]
+		}
+
+		gopkg = gopkg[idx+1:]
+		// package name is overrided with the string after the
+		// ';' character
+		sc := strings.IndexByte(gopkg, ';')
+		if sc < 0 {
+			return sanitizePackageName(gopkg)
+
+		}
+		return sanitizePackageName(gopkg[sc+1:])
+	}
+
+	if f.Package == nil {
+		base := filepath.Base(f.GetName())
+		ext := filepath.Ext(base)
+		return strings.TrimSuffix(base, ext)
+	}
+	return f.GetPackage()
+}
+
+func emitFiles(out []*plugin.CodeGeneratorResponse_File) {
+	emitResp(&plugin.CodeGeneratorResponse{File: out})
+}
+
+func emitError(err error) {
+	emitResp(&plugin.CodeGeneratorResponse{Error: proto.String(err.Error())})
+}
+
+func emitResp(resp *plugin.CodeGeneratorResponse) {
+	buf, err := proto.Marshal(resp)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if _, err := os.Stdout.Write(buf); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,813 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+
+	"github.com/f4tq/protoc-go-plugins/options"
+	"github.com/f4tq/protoc-go-plugins/protodoc"
+)
+
+// plainKey matches the YAML keys and scalars written without quotes.
+var plainKey = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.]*$`)
+
+func main() {
+	input, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	req := new(plugin.CodeGeneratorRequest)
+	if err = proto.Unmarshal(input, req); err != nil {
+		log.Fatal(err)
+	}
+
+	out, err := generate(req)
+
+	if err != nil {
+		emitError(err)
+		return
+	}
+
+	emitFiles(out)
+}
+
+// generate writes one AsyncAPI 2.6 document, <name>.yaml, of the message
+// bus bindings of all the files to generate: a channel per
+// (f4tq.plugins.topic) the messages are published to, and, for the "nats"
+// protocol, per subject the unary methods of the services are served on.
+// The parameters are name ("asyncapi" by default), the title and version
+// of the document ("Events" and "1.0.0"), the protocol whose bindings are
+// described, "kafka" (default), "nats" or "amqp", and the content_type of
+// the messages, "application/x-protobuf" by default. The payload schemas
+// describe the JSON encoding of jsonpb.
+func generate(req *plugin.CodeGeneratorRequest) ([]*plugin.CodeGeneratorResponse_File, error) {
+	params := parseParams(req.GetParameter())
+	doc := &document{
+		Title:       params["title"],
+		Version:     params["version"],
+		Protocol:    params["protocol"],
+		ContentType: params["content_type"],
+	}
+	if doc.Title == "" {
+		doc.Title = "Events"
+	}
+	if doc.Version == "" {
+		doc.Version = "1.0.0"
+	}
+	if doc.ContentType == "" {
+		doc.ContentType = "application/x-protobuf"
+	}
+	switch doc.Protocol {
+	case "":
+		doc.Protocol = "kafka"
+	case "kafka", "nats", "amqp":
+	default:
+		return nil, fmt.Errorf("unknown protocol %q, want kafka, nats or amqp", doc.Protocol)
+	}
+	name := params["name"]
+	if name == "" {
+		name = "asyncapi"
+	}
+
+	idx := newTypeIndex(req.GetProtoFile())
+	g := &schemaGen{idx: idx, docs: protodoc.New(req.GetProtoFile()), added: make(map[string]bool)}
+	a := &asyncGen{doc: doc, schemas: g, channels: make(map[string]*channel), messages: make(map[string]bool)}
+	genFileNames := make(map[string]bool)
+	for _, n := range req.FileToGenerate {
+		genFileNames[n] = true
+	}
+	for _, desc := range req.GetProtoFile() {
+		if _, ok := genFileNames[desc.GetName()]; !ok {
+			// Only describe the files present in req.FileToGenerate.
+			continue
+		}
+		scope := strings.TrimSuffix("."+desc.GetPackage(), ".")
+		if err := a.topics(scope, desc.GetMessageType()); err != nil {
+			return nil, fmt.Errorf("%s: %v", desc.GetName(), err)
+		}
+		if doc.Protocol == "nats" {
+			for _, svc := range desc.GetService() {
+				if err := a.service(scope, svc); err != nil {
+					return nil, fmt.Errorf("%s: %v", desc.GetName(), err)
+				}
+			}
+		}
+	}
+	if len(doc.Channels) == 0 {
+		return nil, nil
+	}
+	sort.Slice(doc.Channels, func(i, j int) bool { return doc.Channels[i].Name < doc.Channels[j].Name })
+	sort.Slice(doc.Messages, func(i, j int) bool { return doc.Messages[i].Name < doc.Messages[j].Name })
+	sort.Slice(g.schemas, func(i, j int) bool { return g.schemas[i].Name < g.schemas[j].Name })
+
+	return []*plugin.CodeGeneratorResponse_File{{
+		Name:    proto.String(name + ".yaml"),
+		Content: proto.String(doc.yaml(g.schemas)),
+	}}, nil
+}
+
+type asyncGen struct {
+	doc     *document
+	schemas *schemaGen
+	// channels maps the channel names to their channels.
+	channels map[string]*channel
+	// messages holds the messages of components.messages.
+	messages map[string]bool
+}
+
+// topics adds the channels of the messages of msgs, and of the messages
+// nested in them, that have a (f4tq.plugins.topic) option. scope is the
+// full proto name of their parent.
+func (a *asyncGen) topics(scope string, msgs []*descriptor.DescriptorProto) error {
+	for _, msg := range msgs {
+		if msg.GetOptions().GetMapEntry() {
+			continue
+		}
+		typeName := scope + "." + msg.GetName()
+		if topic := options.Topic(msg); topic != "" {
+			name := topic
+			if a.doc.Protocol == "amqp" {
+				name = options.RoutingKey(msg)
+			}
+			c, err := a.channel(name, "subscribe", typeName)
+			if err != nil {
+				return err
+			}
+			c.Description = a.schemas.docs.Comment(typeName)
+			switch a.doc.Protocol {
+			case "kafka":
+				c.Bindings = append(c.Bindings, &binding{Key: "topic", Value: yamlString(topic)})
+			case "amqp":
+				c.Bindings = append(c.Bindings, &binding{Key: "is", Value: "routingKey"})
+				if ex := options.Exchange(msg); ex != "" {
+					c.Bindings = append(c.Bindings,
+						&binding{Key: "exchange", Nested: []*binding{
+							{Key: "name", Value: yamlString(ex)},
+							{Key: "type", Value: yamlString(options.ExchangeType(msg))},
+						}})
+				}
+			}
+			if err := a.message(typeName, msg); err != nil {
+				return err
+			}
+		}
+		if err := a.topics(typeName, msg.GetNestedType()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// service adds the channels of the subjects the unary methods of svc are
+// served on over NATS. The requests are published to them; the replies
+// are described by the x-reply extension of the operation.
+func (a *asyncGen) service(scope string, svc *descriptor.ServiceDescriptorProto) error {
+	fullName := strings.TrimPrefix(scope+"."+svc.GetName(), ".")
+	for _, m := range svc.GetMethod() {
+		if m.GetClientStreaming() || m.GetServerStreaming() {
+			// NATS request/reply carries a single reply.
+			continue
+		}
+		c, err := a.channel(options.Subject(fullName, svc, m), "publish", m.GetInputType())
+		if err != nil {
+			return err
+		}
+		c.Description = a.schemas.docs.Comment("." + fullName + "." + m.GetName())
+		c.OperationID = operationID(fullName + "." + m.GetName())
+		c.Reply = strings.TrimPrefix(m.GetOutputType(), ".")
+		for _, t := range []string{m.GetInputType(), m.GetOutputType()} {
+			if err := a.message(t, a.schemas.idx.messages[t]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// channel adds the channel name, on which op publishes or subscribes to
+// the message typeName.
+func (a *asyncGen) channel(name, op, typeName string) (*channel, error) {
+	if other, ok := a.channels[name]; ok {
+		return nil, fmt.Errorf("%s and %s share channel %q", other.Message, strings.TrimPrefix(typeName, "."), name)
+	}
+	c := &channel{
+		Name:        name,
+		Operation:   op,
+		OperationID: operationID(typeName),
+		Message:     strings.TrimPrefix(typeName, "."),
+	}
+	a.channels[name] = c
+	a.doc.Channels = append(a.doc.Channels, c)
+	return c, nil
+}
+
+// message adds msg, the message typeName, to components.messages and its
+// schema, and those of the messages and enums it is composed of, to
+// components.schemas.
+func (a *asyncGen) message(typeName string, msg *descriptor.DescriptorProto) error {
+	if msg == nil {
+		return fmt.Errorf("unknown message %s", typeName)
+	}
+	if a.messages[typeName] {
+		return nil
+	}
+	a.messages[typeName] = true
+	m := &message{
+		Name:        strings.TrimPrefix(typeName, "."),
+		Title:       msg.GetName(),
+		Description: a.schemas.docs.Comment(typeName),
+	}
+	if err := a.schemas.add(typeName); err != nil {
+		return err
+	}
+	if a.doc.Protocol == "kafka" {
+		for _, f := range msg.GetField() {
+			if !options.MessageKey(f) {
+				continue
+			}
+			if m.Key != nil {
+				return fmt.Errorf("%s: more than one message_key field", m.Name)
+			}
+			// The producers write the keys as text.
+			m.Key = &schema{Type: "string", Description: "The " + f.GetName() + " field."}
+		}
+	}
+	a.doc.Messages = append(a.doc.Messages, m)
+	return nil
+}
+
+// operationID returns the operationId of the operation on typeName, or on
+// the method of that full name.
+func operationID(typeName string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+			return r
+		}
+		return '_'
+	}, strings.TrimPrefix(typeName, "."))
+}
+
+type document struct {
+	Title, Version string
+	Protocol       string
+	ContentType    string
+	Channels       []*channel
+	Messages       []*message
+}
+
+type channel struct {
+	Name        string
+	Description string
+	// Operation is "subscribe" for the channels the messages are published
+	// to, and "publish" for those the requests are sent to.
+	Operation   string
+	OperationID string
+	// Message and Reply are the full names of the message carried and of
+	// the reply to it, if any.
+	Message, Reply string
+	Bindings       []*binding
+}
+
+// binding is an entry of the bindings of a channel, with a YAML scalar
+// Value or the Nested entries of a mapping.
+type binding struct {
+	Key    string
+	Value  string
+	Nested []*binding
+}
+
+type message struct {
+	Name        string
+	Title       string
+	Description string
+	// Key is the schema of the Kafka record key.
+	Key *schema
+}
+
+// yaml returns the document, with schemas in components.schemas.
+func (d *document) yaml(schemas []*property) string {
+	w := bytes.NewBuffer(nil)
+	ref := func(kind, name string) string {
+		return strconv.Quote("#/components/" + kind + "/" + name)
+	}
+	fmt.Fprintf(w, "# Code generated by protoc-gen-go-asyncapi. DO NOT EDIT.\nasyncapi: 2.6.0\n")
+	fmt.Fprintf(w, "info:\n  title: %s\n  version: %s\n", yamlString(d.Title), strconv.Quote(d.Version))
+	fmt.Fprintf(w, "defaultContentType: %s\n", d.ContentType)
+	fmt.Fprintf(w, "channels:\n")
+	for _, c := range d.Channels {
+		fmt.Fprintf(w, "  %s:\n", yamlString(c.Name))
+		if c.Description != "" {
+			fmt.Fprintf(w, "    description: %s\n", strconv.Quote(c.Description))
+		}
+		fmt.Fprintf(w, "    %s:\n      operationId: %s\n", c.Operation, c.OperationID)
+		fmt.Fprintf(w, "      message:\n        $ref: %s\n", ref("messages", c.Message))
+		if c.Reply != "" {
+			fmt.Fprintf(w, "      x-reply:\n        $ref: %s\n", ref("messages", c.Reply))
+		}
+		if len(c.Bindings) > 0 {
+			fmt.Fprintf(w, "    bindings:\n      %s:\n", d.Protocol)
+			writeBindings(w, "        ", c.Bindings)
+		}
+	}
+	fmt.Fprintf(w, "components:\n  messages:\n")
+	for _, m := range d.Messages {
+		fmt.Fprintf(w, "    %s:\n      name: %s\n      title: %s\n", yamlString(m.Name), yamlString(m.Name), yamlString(m.Title))
+		if m.Description != "" {
+			fmt.Fprintf(w, "      description: %s\n", strconv.Quote(m.Description))
+		}
+		fmt.Fprintf(w, "      payload:\n        $ref: %s\n", ref("schemas", m.Name))
+		if m.Key != nil {
+			fmt.Fprintf(w, "      bindings:\n        kafka:\n")
+			writeYAMLEntry(w, "          ", "key", m.Key)
+		}
+	}
+	fmt.Fprintf(w, "  schemas:\n")
+	for _, p := range schemas {
+		writeYAMLEntry(w, "    ", p.Name, p.Schema)
+	}
+	return w.String()
+}
+
+func writeBindings(w *bytes.Buffer, indent string, bindings []*binding) {
+	for _, b := range bindings {
+		if b.Nested != nil {
+			fmt.Fprintf(w, "%s%s:\n", indent, b.Key)
+			writeBindings(w, indent+"  ", b.Nested)
+			continue
+		}
+		fmt.Fprintf(w, "%s%s: %s\n", indent, b.Key, b.Value)
+	}
+}
+
+// wellKnownSchemas are the schemas of the well-known types, which jsonpb
+// encodes specially.
+var wellKnownSchemas = map[string]func() *schema{
+	".google.protobuf.Timestamp": func() *schema { return &schema{Type: "string", Format: "date-time"} },
+	".google.protobuf.Duration": func() *schema {
+		return &schema{Type: "string", Pattern: `^-?[0-9]+(\.[0-9]+)?s$`}
+	},
+	".google.protobuf.FieldMask": func() *schema { return &schema{Type: "string"} },
+	".google.protobuf.Empty":     func() *schema { return &schema{Type: "object"} },
+	".google.protobuf.Struct":    func() *schema { return &schema{Type: "object", AnyProperties: true} },
+	".google.protobuf.Value":     func() *schema { return &schema{} },
+	".google.protobuf.ListValue": func() *schema { return &schema{Type: "array", Items: &schema{}} },
+	".google.protobuf.Any": func() *schema {
+		return &schema{
+			Type:          "object",
+			Properties:    []*property{{Name: "@type", Schema: &schema{Type: "string"}}},
+			Required:      []string{"@type"},
+			AnyProperties: true,
+		}
+	},
+	".google.protobuf.DoubleValue": func() *schema { return &schema{Type: "number", Nullable: true} },
+	".google.protobuf.FloatValue":  func() *schema { return &schema{Type: "number", Nullable: true} },
+	".google.protobuf.Int64Value":  func() *schema { return &schema{Type: "string", Format: "int64", Nullable: true} },
+	".google.protobuf.UInt64Value": func() *schema { return &schema{Type: "string", Format: "uint64", Nullable: true} },
+	".google.protobuf.Int32Value":  func() *schema { return &schema{Type: "integer", Nullable: true} },
+	".google.protobuf.UInt32Value": func() *schema { return &schema{Type: "integer", Nullable: true} },
+	".google.protobuf.BoolValue":   func() *schema { return &schema{Type: "boolean", Nullable: true} },
+	".google.protobuf.StringValue": func() *schema { return &schema{Type: "string", Nullable: true} },
+	".google.protobuf.BytesValue":  func() *schema { return &schema{Type: "string", ContentEncoding: "base64", Nullable: true} },
+}
+
+// schemaGen builds the JSON schemas of messages and enums, and of the
+// messages and enums they are composed of, as jsonpb encodes them: fields
+// under their JSON names, 64-bit integers as strings, enums by name and
+// bytes in base64.
+type schemaGen struct {
+	idx *typeIndex
+	// docs holds the comments of the declarations.
+	docs *protodoc.Index
+	// added holds the types of schemas.
+	added   map[string]bool
+	schemas []*property
+}
+
+// add adds the schema of the message or enum typeName, and those of the
+// types it refers to.
+func (g *schemaGen) add(typeName string) error {
+	if g.added[typeName] {
+		return nil
+	}
+	g.added[typeName] = true
+	name := strings.TrimPrefix(typeName, ".")
+	if enum, ok := g.idx.enums[typeName]; ok {
+		s := &schema{
+			Type:        "string",
+			Description: g.docs.Comment(typeName),
+			Deprecated:  enum.GetOptions().GetDeprecated(),
+		}
+		for _, v := range enum.GetValue() {
+			s.Enum = append(s.Enum, v.GetName())
+		}
+		g.schemas = append(g.schemas, &property{Name: name, Schema: s})
+		return nil
+	}
+	msg, ok := g.idx.messages[typeName]
+	if !ok {
+		return fmt.Errorf("unknown type %s", typeName)
+	}
+	s := &schema{
+		Type:        "object",
+		Description: g.docs.Comment(typeName),
+		Deprecated:  msg.GetOptions().GetDeprecated(),
+	}
+	g.schemas = append(g.schemas, &property{Name: name, Schema: s})
+	for _, field := range msg.GetField() {
+		fs, err := g.field(field)
+		if err != nil {
+			return fmt.Errorf("%s.%s: %v", name, field.GetName(), err)
+		}
+		fs.Description = g.docs.Comment(typeName + "." + field.GetName())
+		fs.Deprecated = field.GetOptions().GetDeprecated()
+		s.Properties = append(s.Properties, &property{Name: jsonName(field), Schema: fs})
+		if options.Rules(field).GetRequired() {
+			s.Required = append(s.Required, jsonName(field))
+		}
+	}
+	return nil
+}
+
+// field returns the schema of field, with its (f4tq.plugins.validate)
+// rules.
+func (g *schemaGen) field(field *descriptor.FieldDescriptorProto) (*schema, error) {
+	rules := options.Rules(field)
+	if rules == nil {
+		rules = &options.FieldRules{}
+	}
+	if g.idx.isMap(field) {
+		// Map values only take the item bounds, as in protoc-gen-go-validate.
+		v, err := g.value(g.idx.messages[field.GetTypeName()].GetField()[1], nil)
+		if err != nil {
+			return nil, err
+		}
+		return &schema{
+			Type:                 "object",
+			AdditionalProperties: v,
+			MinProperties:        uint32Ptr(rules.MinItems),
+			MaxProperties:        uint32Ptr(rules.MaxItems),
+		}, nil
+	}
+	if field.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REPEATED {
+		v, err := g.value(field, rules)
+		if err != nil {
+			return nil, err
+		}
+		return &schema{
+			Type:     "array",
+			Items:    v,
+			MinItems: uint32Ptr(rules.MinItems),
+			MaxItems: uint32Ptr(rules.MaxItems),
+		}, nil
+	}
+	return g.value(field, rules)
+}
+
+// value returns the schema of a value of field, bounded by rules.
+func (g *schemaGen) value(field *descriptor.FieldDescriptorProto, rules *options.FieldRules) (*schema, error) {
+	var s *schema
+	switch field.GetType() {
+	case descriptor.FieldDescriptorProto_TYPE_DOUBLE,
+		descriptor.FieldDescriptorProto_TYPE_FLOAT:
+		s = &schema{Type: "number"}
+	case descriptor.FieldDescriptorProto_TYPE_INT32,
+		descriptor.FieldDescriptorProto_TYPE_SINT32,
+		descriptor.FieldDescriptorProto_TYPE_SFIXED32,
+		descriptor.FieldDescriptorProto_TYPE_UINT32,
+		descriptor.FieldDescriptorProto_TYPE_FIXED32:
+		s = &schema{Type: "integer"}
+	case descriptor.FieldDescriptorProto_TYPE_INT64,
+		descriptor.FieldDescriptorProto_TYPE_SINT64,
+		descriptor.FieldDescriptorProto_TYPE_SFIXED64:
+		// jsonpb writes 64-bit integers as strings, which the numeric
+		// bounds do not apply to.
+		return &schema{Type: "string", Format: "int64"}, nil
+	case descriptor.FieldDescriptorProto_TYPE_UINT64,
+		descriptor.FieldDescriptorProto_TYPE_FIXED64:
+		return &schema{Type: "string", Format: "uint64"}, nil
+	case descriptor.FieldDescriptorProto_TYPE_BOOL:
+		s = &schema{Type: "boolean"}
+	case descriptor.FieldDescriptorProto_TYPE_STRING:
+		s = &schema{Type: "string"}
+	case descriptor.FieldDescriptorProto_TYPE_BYTES:
+		s = &schema{Type: "string", ContentEncoding: "base64"}
+	case descriptor.FieldDescriptorProto_TYPE_ENUM,
+		descriptor.FieldDescriptorProto_TYPE_MESSAGE:
+		if wkt, ok := wellKnownSchemas[field.GetTypeName()]; ok {
+			return wkt(), nil
+		}
+		if err := g.add(field.GetTypeName()); err != nil {
+			return nil, err
+		}
+		return &schema{Ref: strings.TrimPrefix(field.GetTypeName(), ".")}, nil
+	default:
+		return nil, fmt.Errorf("groups are not supported")
+	}
+	if rules == nil {
+		return s, nil
+	}
+	// Lengths count the characters of strings; those of bytes, encoded in
+	// base64, are left to the generated Validate methods.
+	if s.Type == "string" && s.ContentEncoding == "" {
+		s.MinLength = uint32Ptr(rules.MinLen)
+		s.MaxLength = uint32Ptr(rules.MaxLen)
+		s.Pattern = rules.GetPattern()
+	}
+	if s.Type == "number" || s.Type == "integer" {
+		s.ExclusiveMinimum, s.Minimum = rules.Gt, rules.Gte
+		s.ExclusiveMaximum, s.Maximum = rules.Lt, rules.Lte
+	}
+	return s, nil
+}
+
+// jsonName returns the JSON name of field, which protoc sets, or else the
+// lower camel case of its name.
+func jsonName(field *descriptor.FieldDescriptorProto) string {
+	if field.GetJsonName() != "" {
+		return field.GetJsonName()
+	}
+	name := camelCase(field.GetName())
+	return strings.ToLower(name[:1]) + name[1:]
+}
+
+func uint32Ptr(v *uint32) *int64 {
+	if v == nil {
+		return nil
+	}
+	n := int64(*v)
+	return &n
+}
+
+// schema is an AsyncAPI schema, a superset of JSON Schema draft 07.
+type schema struct {
+	// Ref is the name of the schema of a message or enum in
+	// components.schemas.
+	Ref                                string
+	Type, Format, ContentEncoding      string
+	Description                        string
+	Properties                         []*property
+	Required                           []string
+	Items, AdditionalProperties        *schema
+	AnyProperties                      bool // additionalProperties: true
+	Enum                               []string
+	Pattern                            string
+	MinLength, MaxLength               *int64
+	MinItems, MaxItems                 *int64
+	MinProperties, MaxProperties       *int64
+	Minimum, Maximum                   *float64
+	ExclusiveMinimum, ExclusiveMaximum *float64
+	Nullable, Deprecated               bool
+}
+
+type property struct {
+	Name   string
+	Schema *schema
+}
+
+// writeYAMLEntry writes the entry of key and s in a YAML mapping indented
+// by indent.
+func writeYAMLEntry(w *bytes.Buffer, indent, key string, s *schema) {
+	var value bytes.Buffer
+	s.writeYAML(&value, indent+"  ")
+	if value.Len() == 0 {
+		fmt.Fprintf(w, "%s%s: {}\n", indent, yamlString(key))
+		return
+	}
+	fmt.Fprintf(w, "%s%s:\n", indent, yamlString(key))
+	w.Write(value.Bytes())
+}
+
+// writeYAML writes s as a YAML mapping indented by indent.
+func (s *schema) writeYAML(w *bytes.Buffer, indent string) {
+	line := func(key, v string) {
+		fmt.Fprintf(w, "%s%s: %s\n", indent, key, v)
+	}
+	flag := func(key string, v bool) {
+		if v {
+			line(key, "true")
+		}
+	}
+	integer := func(key string, v *int64) {
+		if v != nil {
+			line(key, strconv.FormatInt(*v, 10))
+		}
+	}
+	number := func(key string, v *float64) {
+		if v != nil {
+			line(key, strconv.FormatFloat(*v, 'g', -1, 64))
+		}
+	}
+	list := func(key string, items []string) {
+		if len(items) > 0 {
+			fmt.Fprintf(w, "%s%s:\n", indent, key)
+			for _, v := range items {
+				fmt.Fprintf(w, "%s- %s\n", indent, yamlString(v))
+			}
+		}
+	}
+	if s.Ref != "" {
+		ref := strconv.Quote("#/components/schemas/" + s.Ref)
+		if s.Description == "" && !s.Deprecated {
+			line("$ref", ref)
+			return
+		}
+		// The siblings of $ref are ignored.
+		fmt.Fprintf(w, "%sallOf:\n%s- $ref: %s\n", indent, indent, ref)
+	}
+	if s.Description != "" {
+		line("description", strconv.Quote(s.Description))
+	}
+	switch {
+	case s.Type != "" && s.Nullable:
+		line("type", fmt.Sprintf("[%s, \"null\"]", s.Type))
+	case s.Type != "":
+		line("type", s.Type)
+	}
+	if s.Format != "" {
+		line("format", s.Format)
+	}
+	if s.ContentEncoding != "" {
+		line("contentEncoding", s.ContentEncoding)
+	}
+	flag("deprecated", s.Deprecated)
+	number("minimum", s.Minimum)
+	number("exclusiveMinimum", s.ExclusiveMinimum)
+	number("maximum", s.Maximum)
+	number("exclusiveMaximum", s.ExclusiveMaximum)
+	integer("minLength", s.MinLength)
+	integer("maxLength", s.MaxLength)
+	if s.Pattern != "" {
+		// Go quoting is valid in YAML double-quoted scalars.
+		line("pattern", strconv.Quote(s.Pattern))
+	}
+	integer("minItems", s.MinItems)
+	integer("maxItems", s.MaxItems)
+	integer("minProperties", s.MinProperties)
+	integer("maxProperties", s.MaxProperties)
+	list("enum", s.Enum)
+	list("required", s.Required)
+	if len(s.Properties) > 0 {
+		fmt.Fprintf(w, "%sproperties:\n", indent)
+		for _, p := range s.Properties {
+			writeYAMLEntry(w, indent+"  ", p.Name, p.Schema)
+		}
+	}
+	if s.Items != nil {
+		writeYAMLEntry(w, indent, "items", s.Items)
+	}
+	if s.AdditionalProperties != nil {
+		writeYAMLEntry(w, indent, "additionalProperties", s.AdditionalProperties)
+	}
+	flag("additionalProperties", s.AnyProperties)
+}
+
+// yamlString returns s as a YAML scalar: plain if it cannot be read as
+// anything but that string, and double-quoted otherwise.
+func yamlString(s string) string {
+	switch strings.ToLower(s) {
+	case "y", "n", "yes", "no", "on", "off", "true", "false", "null":
+		return strconv.Quote(s)
+	}
+	if plainKey.MatchString(s) {
+		return s
+	}
+	return strconv.Quote(s)
+}
+
+// typeIndex maps fully-qualified proto type names (".pkg.Msg") to their
+// descriptors and declaring files for every file in the request.
+type typeIndex struct {
+	messages map[string]*descriptor.DescriptorProto
+	enums    map[string]*descriptor.EnumDescriptorProto
+	files    map[string]*descriptor.FileDescriptorProto
+}
+
+func newTypeIndex(files []*descriptor.FileDescriptorProto) *typeIndex {
+	idx := &typeIndex{
+		messages: make(map[string]*descriptor.DescriptorProto),
+		enums:    make(map[string]*descriptor.EnumDescriptorProto),
+		files:    make(map[string]*descriptor.FileDescriptorProto),
+	}
+	for _, f := range files {
+		prefix := ""
+		if f.GetPackage() != "" {
+			prefix = "." + f.GetPackage()
+		}
+		idx.addMessages(f, prefix, f.GetMessageType())
+		idx.addEnums(f, prefix, f.GetEnumType())
+	}
+	return idx
+}
+
+func (idx *typeIndex) addMessages(f *descriptor.FileDescriptorProto, prefix string, msgs []*descriptor.DescriptorProto) {
+	for _, m := range msgs {
+		name := prefix + "." + m.GetName()
+		idx.messages[name] = m
+		idx.files[name] = f
+		idx.addMessages(f, name, m.GetNestedType())
+		idx.addEnums(f, name, m.GetEnumType())
+	}
+}
+
+func (idx *typeIndex) addEnums(f *descriptor.FileDescriptorProto, prefix string, enums []*descriptor.EnumDescriptorProto) {
+	for _, e := range enums {
+		name := prefix + "." + e.GetName()
+		idx.enums[name] = e
+		idx.files[name] = f
+	}
+}
+
+// isMap reports whether field is a map field.
+func (idx *typeIndex) isMap(field *descriptor.FieldDescriptorProto) bool {
+	if field.GetType() != descriptor.FieldDescriptorProto_TYPE_MESSAGE {
+		return false
+	}
+	return idx.messages[field.GetTypeName()].GetOptions().GetMapEntry()
+}
+
+// parseParams splits the comma separated key=value plugin parameter.
+func parseParams(param string) map[string]string {
+	params := make(map[string]string)
+	for _, p := range strings.Split(param, ",") {
+		if p == "" {
+			continue
+		}
+		if i := strings.IndexByte(p, '='); i >= 0 {
+			params[p[:i]] = p[i+1:]
+		} else {
+			params[p] = ""
+		}
+	}
+	return params
+}
+
+// camelCase returns the Go field name protoc-gen-go derives from s.
+func camelCase(s string) string {
+	if s == "" {
+		return ""
+	}
+	t := make([]byte, 0, 32)
+	i := 0
+	if s[0] == '_' {
+		// Need a capital letter; drop the '_'.
+		t = append(t, 'X')
+		i++
+	}
+	for ; i < len(s); i++ {
+		c := s[i]
+		if c == '_' && i+1 < len(s) && isASCIILower(s[i+1]) {
+			continue
+		}
+		if isASCIIDigit(c) {
+			t = append(t, c)
+			continue
+		}
+		if isASCIILower(c) {
+			c ^= ' '
+		}
+		t = append(t, c)
+		for i+1 < len(s) && isASCIILower(s[i+1]) {
+			i++
+			t = append(t, s[i])
+		}
+	}
+	return string(t)
+}
+
+func isASCIILower(c byte) bool {
+	return 'a' <= c && c <= 'z'
+}
+
+func isASCIIDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+func emitFiles(out []*plugin.CodeGeneratorResponse_File) {
+	emitResp(&plugin.CodeGeneratorResponse{File: out})
+}
+
+func emitError(err error) {
+	emitResp(&plugin.CodeGeneratorResponse{Error: proto.String(err.Error())})
+}
+
+func emitResp(resp *plugin.CodeGeneratorResponse) {
+	buf, err := proto.Marshal(resp)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if _, err := os.Stdout.Write(buf); err != nil {
+		log.Fatal(err)
+	}
+}
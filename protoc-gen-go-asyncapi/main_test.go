@@ -0,0 +1,41 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/f4tq/protoc-go-plugins/internal/plugintest"
+)
+
+const commentedProto = `
+syntax = "proto3";
+
+package doc.v1;
+
+option go_package = "example.com/doc/v1;docv1";
+
+import "options/options.proto";
+
+// Doc is a document.
+message Doc {
+    option (f4tq.plugins.topic) = "docs";
+
+    // title names the document.
+    string title = 1; // Shown in lists.
+}
+`
+
+// TestComments checks the channels and schemas carry the leading and
+// trailing comments of the declarations.
+func TestComments(t *testing.T) {
+	req := plugintest.Request(t, "", map[string]string{"doc/v1/doc.proto": commentedProto})
+	got := plugintest.Generate(t, req, generate)["asyncapi.yaml"]
+	for _, want := range []string{
+		`description: "Doc is a document."`,
+		`description: "title names the document.\n\nShown in lists."`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("the document does not contain %q:\n%s", want, got)
+		}
+	}
+}
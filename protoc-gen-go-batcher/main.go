@@ -0,0 +1,565 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+
+	"github.com/f4tq/protoc-go-plugins/goname"
+	"github.com/f4tq/protoc-go-plugins/options"
+	"github.com/f4tq/protoc-go-plugins/protodoc"
+)
+
+const (
+	defaultMaxSize  = 100
+	defaultMaxDelay = 10 * time.Millisecond
+	statusType      = ".google.rpc.Status"
+)
+
+var (
+	hdrTmpl = template.Must(template.New("header").Parse(`
+// Code generated by protoc-gen-go-batcher. DO NOT EDIT.
+// source: {{.Source}}
+
+package {{.GoPkg}}
+
+import (
+    "context"
+    "time"
+
+    "github.com/f4tq/protoc-go-plugins/runtime/batch"
+    "google.golang.org/grpc"
+{{- if .Status}}
+    "google.golang.org/grpc/status"
+{{- end}}
+{{- range $path, $name := .Imports}}
+    {{$name}} "{{$path}}"
+{{- end}}
+)
+`))
+
+	batcherTmpl = template.Must(template.New("batcher").Parse(`
+// {{.Name}}BatchClient is a {{.Name}}Client whose calls to
+{{- range $i, $m := .Methods}}{{if $i}},{{end}} {{$m.Name}}{{end}} are
+// coalesced into batch calls. Calls with call options are not batched.{{.Doc}}
+type {{.Name}}BatchClient struct {
+    {{.Name}}Client
+{{range .Methods}}
+    // {{.Name}}Batcher batches {{.Name}} into {{.Batch}}.
+    {{.Name}}Batcher *batch.Batcher[*{{.Input}}, *{{.Output}}]
+{{- end}}
+}
+
+// New{{.Name}}BatchClient returns a {{.Name}}BatchClient sending its calls
+// through next. The batch sizes and delays of the proto can be changed on
+// its Batchers before the first call.{{.Doc}}
+func New{{.Name}}BatchClient(next {{.Name}}Client) *{{.Name}}BatchClient {
+    c := &{{.Name}}BatchClient{ {{.Name}}Client: next}
+{{- range .Methods}}
+    c.{{.Name}}Batcher = &batch.Batcher[*{{.Input}}, *{{.Output}}]{
+        MaxSize:  {{.MaxSize}},
+        MaxDelay: {{.MaxDelay}},
+        Do:       c.batch{{.Name}},
+    }
+{{- end}}
+    return c
+}
+{{range .Methods}}
+func (c *{{$.Name}}BatchClient) {{.Name}}(ctx context.Context, in *{{.Input}}, opts ...grpc.CallOption) (*{{.Output}}, error) {
+    if len(opts) > 0 {
+        return c.{{$.Name}}Client.{{.Name}}(ctx, in, opts...)
+    }
+    return c.{{.Name}}Batcher.Call(ctx, in)
+}
+
+func (c *{{$.Name}}BatchClient) batch{{.Name}}(ctx context.Context, reqs []*{{.Input}}) ([]*{{.Output}}, []error, error) {
+{{- if .Key}}
+    req := &{{.BatchInput}}{ {{.Requests}}: make([]{{.KeyType}}, len(reqs))}
+    for i, r := range reqs {
+        req.{{.Requests}}[i] = r.{{.Key}}
+    }
+{{- else}}
+    req := &{{.BatchInput}}{ {{.Requests}}: reqs}
+{{- end}}
+    resp, err := c.{{$.Name}}Client.{{.Batch}}(ctx, req)
+    if err != nil {
+        return nil, nil, err
+    }
+{{- if .Errors}}
+    errs := make([]error, len(reqs))
+    for i, st := range resp.{{.Errors}} {
+        if i < len(errs) {
+            errs[i] = status.FromProto(st).Err()
+        }
+    }
+    return resp.{{.Responses}}, errs, nil
+{{- else}}
+    return resp.{{.Responses}}, nil, nil
+{{- end}}
+}
+{{end}}
+`))
+)
+
+func main() {
+	input, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	req := new(plugin.CodeGeneratorRequest)
+	if err = proto.Unmarshal(input, req); err != nil {
+		log.Fatal(err)
+	}
+
+	out, err := generate(req)
+
+	if err != nil {
+		emitError(err)
+		return
+	}
+
+	emitFiles(out)
+}
+
+func generate(req *plugin.CodeGeneratorRequest) ([]*plugin.CodeGeneratorResponse_File, error) {
+	var files []*plugin.CodeGeneratorResponse_File
+	idx := newTypeIndex(req.GetProtoFile())
+	docs := protodoc.New(req.GetProtoFile())
+	genFileNames := make(map[string]bool)
+	for _, n := range req.FileToGenerate {
+		genFileNames[n] = true
+	}
+	for _, desc := range req.GetProtoFile() {
+		name := desc.GetName()
+		if _, ok := genFileNames[name]; !ok {
+			// Only emit output for files present in req.FileToGenerate.
+			continue
+		}
+		code, err := genCode(desc, idx, docs)
+		if err != nil {
+			return nil, err
+		}
+		if code == "" {
+			// No method is batchable.
+			continue
+		}
+		formatted, err := format.Source([]byte(code))
+		if err != nil {
+			log.Printf("%v: %s", err, code)
+			return nil, err
+		}
+
+		ext := filepath.Ext(name)
+		base := strings.TrimSuffix(name, ext)
+		output := fmt.Sprintf("%s.pb.batcher.go", base)
+		files = append(files, &plugin.CodeGeneratorResponse_File{
+			Name:    proto.String(output),
+			Content: proto.String(string(formatted)),
+		})
+	}
+
+	return files, nil
+}
+
+func genCode(desc *descriptor.FileDescriptorProto, idx *typeIndex, docs *protodoc.Index) (string, error) {
+	w := bytes.NewBuffer(nil)
+	imports := newImportSet(desc)
+	body := bytes.NewBuffer(nil)
+	hdr := &header{
+		Source: desc.GetName(),
+		GoPkg:  defaultGoPackageName(desc),
+	}
+	for _, svc := range desc.GetService() {
+		s := &batchService{Name: svc.GetName(), Doc: docs.Godoc(qualify(desc.GetPackage(), svc.GetName()))}
+		for _, m := range svc.GetMethod() {
+			opt := options.MethodBatch(m)
+			if opt == nil {
+				continue
+			}
+			where := svc.GetName() + "." + m.GetName()
+			bm, err := batchMethod(svc, m, opt)
+			if err != nil {
+				return "", fmt.Errorf("%s: %v", where, err)
+			}
+			b, err := batchFields(idx, m, bm)
+			if err != nil {
+				return "", fmt.Errorf("%s: %v", where, err)
+			}
+			b.Name = m.GetName()
+			b.Batch = bm.GetName()
+			b.Input = imports.goTypeName(idx, m.GetInputType())
+			b.Output = imports.goTypeName(idx, m.GetOutputType())
+			b.BatchInput = imports.goTypeName(idx, bm.GetInputType())
+
+			b.MaxSize = defaultMaxSize
+			if opt.GetMaxSize() > 0 {
+				b.MaxSize = int(opt.GetMaxSize())
+			}
+			delay := defaultMaxDelay
+			if opt.GetMaxDelay() != "" {
+				if delay, err = time.ParseDuration(opt.GetMaxDelay()); err != nil {
+					return "", fmt.Errorf("%s: max_delay: %v", where, err)
+				}
+				if delay <= 0 {
+					return "", fmt.Errorf("%s: max_delay must be positive", where)
+				}
+			}
+			b.MaxDelay = goDuration(delay)
+			hdr.Status = hdr.Status || b.Errors != ""
+			s.Methods = append(s.Methods, b)
+		}
+		if len(s.Methods) == 0 {
+			continue
+		}
+		if err := batcherTmpl.Execute(body, s); err != nil {
+			return "", err
+		}
+	}
+	if body.Len() == 0 {
+		return "", nil
+	}
+
+	hdr.Imports = imports.names
+	if err := hdrTmpl.Execute(w, hdr); err != nil {
+		log.Fatal(err)
+	}
+	body.WriteTo(w)
+
+	return w.String(), nil
+}
+
+// batchMethod returns the method named by opt, which must be a unary
+// method of svc like m.
+func batchMethod(svc *descriptor.ServiceDescriptorProto, m *descriptor.MethodDescriptorProto, opt *options.Batch) (*descriptor.MethodDescriptorProto, error) {
+	if m.GetClientStreaming() || m.GetServerStreaming() {
+		return nil, fmt.Errorf("only unary methods can be batched")
+	}
+	for _, bm := range svc.GetMethod() {
+		if bm.GetName() != opt.GetMethod() {
+			continue
+		}
+		if bm.GetClientStreaming() || bm.GetServerStreaming() {
+			return nil, fmt.Errorf("batch method %s is streaming", bm.GetName())
+		}
+		return bm, nil
+	}
+	return nil, fmt.Errorf("batch method %q not found", opt.GetMethod())
+}
+
+// batchFields maps the calls of m onto those of its batch method bm. The
+// batch request takes either the requests of m in a repeated field, or the
+// one key of each, such as the names of a BatchGet method for the name of
+// a Get method. The batch response returns the responses of m in order in
+// a repeated field, optionally along with a google.rpc.Status per request.
+func batchFields(idx *typeIndex, m, bm *descriptor.MethodDescriptorProto) (*batchMethodData, error) {
+	in := idx.messages[m.GetInputType()]
+	b := &batchMethodData{}
+	batchIn, batchOut := idx.messages[bm.GetInputType()], idx.messages[bm.GetOutputType()]
+	inNames, batchInNames, batchOutNames := goname.Fields(in), goname.Fields(batchIn), goname.Fields(batchOut)
+	for _, f := range batchIn.GetField() {
+		if f.GetLabel() != descriptor.FieldDescriptorProto_LABEL_REPEATED {
+			continue
+		}
+		if f.GetType() == descriptor.FieldDescriptorProto_TYPE_MESSAGE && f.GetTypeName() == m.GetInputType() {
+			b.Requests = batchInNames[f.GetName()]
+			break
+		}
+		if key := keyField(in, f); key != nil {
+			b.Requests = batchInNames[f.GetName()]
+			b.Key = inNames[key.GetName()]
+			b.KeyType = scalarGoType(key.GetType())
+			break
+		}
+	}
+	if b.Requests == "" {
+		return nil, fmt.Errorf("%s has no repeated field of %s or of one of its fields", bm.GetInputType(), m.GetInputType())
+	}
+	for _, f := range batchOut.GetField() {
+		if f.GetLabel() != descriptor.FieldDescriptorProto_LABEL_REPEATED {
+			continue
+		}
+		switch f.GetTypeName() {
+		case m.GetOutputType():
+			if b.Responses == "" {
+				b.Responses = batchOutNames[f.GetName()]
+			}
+		case statusType:
+			if b.Errors == "" {
+				b.Errors = batchOutNames[f.GetName()]
+			}
+		}
+	}
+	if b.Responses == "" {
+		return nil, fmt.Errorf("%s has no repeated field of %s", bm.GetOutputType(), m.GetOutputType())
+	}
+	return b, nil
+}
+
+// keyField returns the singular scalar field of in that the repeated field
+// batch holds, matched by name ("name" for "names") and type.
+func keyField(in *descriptor.DescriptorProto, batch *descriptor.FieldDescriptorProto) *descriptor.FieldDescriptorProto {
+	if scalarGoType(batch.GetType()) == "" {
+		return nil
+	}
+	for _, f := range in.GetField() {
+		if f.GetName()+"s" == batch.GetName() && f.GetType() == batch.GetType() &&
+			f.GetLabel() != descriptor.FieldDescriptorProto_LABEL_REPEATED && f.OneofIndex == nil {
+			return f
+		}
+	}
+	return nil
+}
+
+// scalarGoType returns the Go type of the batch keys of type t, or "" if
+// keys cannot have that type.
+func scalarGoType(t descriptor.FieldDescriptorProto_Type) string {
+	switch t {
+	case descriptor.FieldDescriptorProto_TYPE_STRING:
+		return "string"
+	case descriptor.FieldDescriptorProto_TYPE_INT64, descriptor.FieldDescriptorProto_TYPE_SINT64,
+		descriptor.FieldDescriptorProto_TYPE_SFIXED64:
+		return "int64"
+	case descriptor.FieldDescriptorProto_TYPE_UINT64, descriptor.FieldDescriptorProto_TYPE_FIXED64:
+		return "uint64"
+	case descriptor.FieldDescriptorProto_TYPE_INT32, descriptor.FieldDescriptorProto_TYPE_SINT32,
+		descriptor.FieldDescriptorProto_TYPE_SFIXED32:
+		return "int32"
+	case descriptor.FieldDescriptorProto_TYPE_UINT32, descriptor.FieldDescriptorProto_TYPE_FIXED32:
+		return "uint32"
+	}
+	return ""
+}
+
+type header struct {
+	Source  string
+	GoPkg   string
+	Status  bool
+	Imports map[string]string
+}
+
+type batchService struct {
+	Name string
+	// Doc is the comment of the service, see protodoc.Index.Godoc.
+	Doc     string
+	Methods []*batchMethodData
+}
+
+type batchMethodData struct {
+	Name       string
+	Batch      string
+	Input      string
+	Output     string
+	BatchInput string
+	Requests   string
+	Key        string
+	KeyType    string
+	Responses  string
+	Errors     string
+	MaxSize    int
+	MaxDelay   string
+}
+
+// qualify returns the full proto name of name, declared in scope.
+func qualify(scope, name string) string {
+	if scope == "" {
+		return name
+	}
+	return scope + "." + name
+}
+
+// typeIndex maps fully-qualified proto type names (".pkg.Msg") to their
+// descriptors and declaring files for every file in the request.
+type typeIndex struct {
+	messages map[string]*descriptor.DescriptorProto
+	enums    map[string]*descriptor.EnumDescriptorProto
+	files    map[string]*descriptor.FileDescriptorProto
+}
+
+func newTypeIndex(files []*descriptor.FileDescriptorProto) *typeIndex {
+	idx := &typeIndex{
+		messages: make(map[string]*descriptor.DescriptorProto),
+		enums:    make(map[string]*descriptor.EnumDescriptorProto),
+		files:    make(map[string]*descriptor.FileDescriptorProto),
+	}
+	for _, f := range files {
+		prefix := ""
+		if f.GetPackage() != "" {
+			prefix = "." + f.GetPackage()
+		}
+		idx.addMessages(f, prefix, f.GetMessageType())
+		idx.addEnums(f, prefix, f.GetEnumType())
+	}
+	return idx
+}
+
+func (idx *typeIndex) addMessages(f *descriptor.FileDescriptorProto, prefix string, msgs []*descriptor.DescriptorProto) {
+	for _, m := range msgs {
+		name := prefix + "." + m.GetName()
+		idx.messages[name] = m
+		idx.files[name] = f
+		idx.addMessages(f, name, m.GetNestedType())
+		idx.addEnums(f, name, m.GetEnumType())
+	}
+}
+
+func (idx *typeIndex) addEnums(f *descriptor.FileDescriptorProto, prefix string, enums []*descriptor.EnumDescriptorProto) {
+	for _, e := range enums {
+		name := prefix + "." + e.GetName()
+		idx.enums[name] = e
+		idx.files[name] = f
+	}
+}
+
+// isMap reports whether field is a map field.
+func (idx *typeIndex) isMap(field *descriptor.FieldDescriptorProto) bool {
+	if field.GetType() != descriptor.FieldDescriptorProto_TYPE_MESSAGE {
+		return false
+	}
+	return idx.messages[field.GetTypeName()].GetOptions().GetMapEntry()
+}
+
+// goImportPath returns the import path of the Go package generated from f,
+// or "" if go_package does not name one.
+func goImportPath(f *descriptor.FileDescriptorProto) string {
+	gopkg := f.GetOptions().GetGoPackage()
+	if sc := strings.IndexByte(gopkg, ';'); sc >= 0 {
+		gopkg = gopkg[:sc]
+	}
+	if !strings.Contains(gopkg, "/") {
+		return ""
+	}
+	return gopkg
+}
+
+// importSet records the Go packages referenced by the code generated for
+// one file, keyed by import path.
+type importSet struct {
+	self  string
+	names map[string]string
+}
+
+func newImportSet(f *descriptor.FileDescriptorProto) *importSet {
+	return &importSet{self: goImportPath(f), names: make(map[string]string)}
+}
+
+// goTypeName returns the Go name of the message or enum typeName, qualified
+// with its package if it is declared outside the generated package.
+func (s *importSet) goTypeName(idx *typeIndex, typeName string) string {
+	name := localTypeName(typeName)
+	f := idx.files[typeName]
+	path := goImportPath(f)
+	if f == nil || path == "" || path == s.self {
+		return name
+	}
+	pkg := defaultGoPackageName(f)
+	s.names[path] = pkg
+	return pkg + "." + name
+}
+
+// localTypeName returns the Go name of a type declared in the file being
+// generated, e.g. ".pkg.Outer.Inner" becomes "Outer_Inner".
+func localTypeName(typeName string) string {
+	parts := strings.Split(strings.TrimPrefix(typeName, "."), ".")
+	var names []string
+	for _, p := range parts {
+		if len(p) > 0 && p[0] >= 'A' && p[0] <= 'Z' {
+			names = append(names, p)
+		}
+	}
+	return strings.Join(names, "_")
+}
+
+// goDuration returns a Go expression for d in the largest unit that
+// represents it exactly, e.g. "250 * time.Millisecond".
+func goDuration(d time.Duration) string {
+	for _, u := range []struct {
+		unit time.Duration
+		name string
+	}{
+		{time.Hour, "time.Hour"},
+		{time.Minute, "time.Minute"},
+		{time.Second, "time.Second"},
+		{time.Millisecond, "time.Millisecond"},
+		{time.Microsecond, "time.Microsecond"},
+	} {
+		if d%u.unit == 0 {
+			return fmt.Sprintf("%d * %s", d/u.unit, u.name)
+		}
+	}
+	return fmt.Sprintf("%d * time.Nanosecond", d)
+}
+
+// sanitizePackageName replaces unallowed character in package name
+// with allowed character.
+func sanitizePackageName(pkgName string) string {
+	pkgName = strings.Replace(pkgName, ".", "_", -1)
+	pkgName = strings.Replace(pkgName, "-", "_", -1)
+	return pkgName
+}
+
+// defaultGoPackageName returns the default go package name to be used for go files generated from "f".
+// You might need to use an unique alias for the package when you import it.  Use ReserveGoPackageAlias to get a unique alias.
+func defaultGoPackageName(f *descriptor.FileDescriptorProto) string {
+	name := packageIdentityName(f)
+	return sanitizePackageName(name)
+}
+
+// packageIdentityName returns the identity of packages.
+// protoc-gen-grpc-gateway rejects CodeGenerationRequests which contains more than one packages
+// as protoc-gen-go does.
+func packageIdentityName(f *descriptor.FileDescriptorProto) string {
+	if f.Options != nil && f.Options.GoPackage != nil {
+		gopkg := f.Options.GetGoPackage()
+		idx := strings.LastIndex(gopkg, "/")
+		if idx < 0 {
+			gopkg = gopkg[idx+1:]
+		}
+
+		gopkg = gopkg[idx+1:]
+		// package name is overrided with the string after the
+		// ';' character
+		sc := strings.IndexByte(gopkg, ';')
+		if sc < 0 {
+			return sanitizePackageName(gopkg)
+
+		}
+		return sanitizePackageName(gopkg[sc+1:])
+	}
+
+	if f.Package == nil {
+		base := filepath.Base(f.GetName())
+		ext := filepath.Ext(base)
+		return strings.TrimSuffix(base, ext)
+	}
+	return f.GetPackage()
+}
+
+func emitFiles(out []*plugin.CodeGeneratorResponse_File) {
+	emitResp(&plugin.CodeGeneratorResponse{File: out})
+}
+
+func emitError(err error) {
+	emitResp(&plugin.CodeGeneratorResponse{Error: proto.String(err.Error())})
+}
+
+func emitResp(resp *plugin.CodeGeneratorResponse) {
+	buf, err := proto.Marshal(resp)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if _, err := os.Stdout.Write(buf); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,406 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+
+	"github.com/f4tq/protoc-go-plugins/protodoc"
+)
+
+var (
+	hdrTmpl = template.Must(template.New("header").Parse(`
+// Code generated by protoc-gen-go-bufconntest. DO NOT EDIT.
+// source: {{.Source}}
+
+package {{.GoPkg}}
+
+import (
+    "context"
+    "net"
+    "testing"
+
+    "google.golang.org/grpc"
+    "google.golang.org/grpc/test/bufconn"
+{{- range $path, $name := .Imports}}
+    {{$name}} "{{$path}}"
+{{- end}}
+)
+`))
+
+	harnessTmpl = template.Must(template.New("harness").Parse(`
+// {{.Name}}Harness serves a {{.Name}}Server over an in-memory bufconn
+// listener and holds a client connected to it.{{.Doc}}
+type {{.Name}}Harness struct {
+    Server *grpc.Server
+    Conn   *grpc.ClientConn
+    Client {{.Name}}Client
+
+    t   testing.TB
+    lis *bufconn.Listener
+}
+
+// New{{.Name}}Harness registers srv on a new grpc.Server built from opts,
+// serves it over bufconn and dials it. Call Close when done.{{.Doc}}
+func New{{.Name}}Harness(srv {{.Name}}Server, opts ...grpc.ServerOption) (*{{.Name}}Harness, error) {
+    lis := bufconn.Listen(1 << 20)
+    s := grpc.NewServer(opts...)
+    Register{{.Name}}Server(s, srv)
+    go s.Serve(lis)
+
+    dialer := func(context.Context, string) (net.Conn, error) { return lis.Dial() }
+    conn, err := grpc.Dial("bufnet", grpc.WithContextDialer(dialer), grpc.WithInsecure())
+    if err != nil {
+        s.Stop()
+        lis.Close()
+        return nil, err
+    }
+    return &{{.Name}}Harness{
+        Server: s,
+        Conn:   conn,
+        Client: New{{.Name}}Client(conn),
+        lis:    lis,
+    }, nil
+}
+
+// Start{{.Name}}Harness is New{{.Name}}Harness for tests: it fails t on
+// error and closes the harness when the test finishes. The returned
+// harness's Must<Method> wrappers report errors through t.{{.Doc}}
+func Start{{.Name}}Harness(t testing.TB, srv {{.Name}}Server, opts ...grpc.ServerOption) *{{.Name}}Harness {
+    t.Helper()
+    h, err := New{{.Name}}Harness(srv, opts...)
+    if err != nil {
+        t.Fatalf("start {{.Name}} harness: %v", err)
+    }
+    h.t = t
+    t.Cleanup(func() { h.Close() })
+    return h
+}
+
+// Close closes the client connection and stops the server.
+func (h *{{.Name}}Harness) Close() error {
+    err := h.Conn.Close()
+    h.Server.Stop()
+    h.lis.Close()
+    return err
+}
+{{range .Methods}}
+// {{.Name}} calls {{$.Name}}.{{.Name}} through the harness client.{{.Doc}}
+func (h *{{$.Name}}Harness) {{.Name}}(ctx context.Context, in *{{.Input}}, opts ...grpc.CallOption) (*{{.Output}}, error) {
+    return h.Client.{{.Name}}(ctx, in, opts...)
+}
+
+// Must{{.Name}} calls {{$.Name}}.{{.Name}} with a background context and
+// fails the test on error. It requires a harness from Start{{$.Name}}Harness.{{.Doc}}
+func (h *{{$.Name}}Harness) Must{{.Name}}(in *{{.Input}}, opts ...grpc.CallOption) *{{.Output}} {
+    h.t.Helper()
+    resp, err := h.Client.{{.Name}}(context.Background(), in, opts...)
+    if err != nil {
+        h.t.Fatalf("{{$.Name}}.{{.Name}}: %v", err)
+    }
+    return resp
+}
+{{end}}
+`))
+)
+
+func main() {
+	input, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	req := new(plugin.CodeGeneratorRequest)
+	if err = proto.Unmarshal(input, req); err != nil {
+		log.Fatal(err)
+	}
+
+	out, err := generate(req)
+
+	if err != nil {
+		emitError(err)
+		return
+	}
+
+	emitFiles(out)
+}
+
+func generate(req *plugin.CodeGeneratorRequest) ([]*plugin.CodeGeneratorResponse_File, error) {
+	var files []*plugin.CodeGeneratorResponse_File
+	idx := newTypeIndex(req.GetProtoFile())
+	docs := protodoc.New(req.GetProtoFile())
+	genFileNames := make(map[string]bool)
+	for _, n := range req.FileToGenerate {
+		genFileNames[n] = true
+	}
+	for _, desc := range req.GetProtoFile() {
+		name := desc.GetName()
+		if _, ok := genFileNames[name]; !ok {
+			// Only emit output for files present in req.FileToGenerate.
+			continue
+		}
+		if len(desc.GetService()) == 0 {
+			continue
+		}
+		code, err := genCode(desc, idx, docs)
+		if err != nil {
+			return nil, err
+		}
+		formatted, err := format.Source([]byte(code))
+		if err != nil {
+			log.Printf("%v: %s", err, code)
+			return nil, err
+		}
+
+		ext := filepath.Ext(name)
+		base := strings.TrimSuffix(name, ext)
+		output := fmt.Sprintf("%s.pb.bufconntest.go", base)
+		files = append(files, &plugin.CodeGeneratorResponse_File{
+			Name:    proto.String(output),
+			Content: proto.String(string(formatted)),
+		})
+	}
+
+	return files, nil
+}
+
+func genCode(desc *descriptor.FileDescriptorProto, idx *typeIndex, docs *protodoc.Index) (string, error) {
+	w := bytes.NewBuffer(nil)
+	imports := newImportSet(desc)
+	body := bytes.NewBuffer(nil)
+	for _, svc := range desc.GetService() {
+		svcName := qualify(desc.GetPackage(), svc.GetName())
+		s := &harnessService{Name: svc.GetName(), Doc: docs.Godoc(svcName)}
+		for _, m := range svc.GetMethod() {
+			if m.GetClientStreaming() || m.GetServerStreaming() {
+				// Streaming methods are reached through the harness Client.
+				continue
+			}
+			s.Methods = append(s.Methods, &harnessMethod{
+				Name:   m.GetName(),
+				Input:  imports.goTypeName(idx, m.GetInputType()),
+				Output: imports.goTypeName(idx, m.GetOutputType()),
+				Doc:    docs.Godoc(svcName + "." + m.GetName()),
+			})
+		}
+		if err := harnessTmpl.Execute(body, s); err != nil {
+			return "", err
+		}
+	}
+
+	hdr := &header{
+		Source:  desc.GetName(),
+		GoPkg:   defaultGoPackageName(desc),
+		Imports: imports.names,
+	}
+	if err := hdrTmpl.Execute(w, hdr); err != nil {
+		log.Fatal(err)
+	}
+	body.WriteTo(w)
+
+	return w.String(), nil
+}
+
+type header struct {
+	Source  string
+	GoPkg   string
+	Imports map[string]string
+}
+
+type harnessService struct {
+	Name string
+	// Doc is the comment of the service, see protodoc.Index.Godoc.
+	Doc     string
+	Methods []*harnessMethod
+}
+
+type harnessMethod struct {
+	Name   string
+	Input  string
+	Output string
+	// Doc is the comment of the method.
+	Doc string
+}
+
+// qualify returns the full proto name of name, declared in scope.
+func qualify(scope, name string) string {
+	if scope == "" {
+		return name
+	}
+	return scope + "." + name
+}
+
+// typeIndex maps fully-qualified proto type names (".pkg.Msg") to their
+// descriptors and declaring files for every file in the request.
+type typeIndex struct {
+	messages map[string]*descriptor.DescriptorProto
+	enums    map[string]*descriptor.EnumDescriptorProto
+	files    map[string]*descriptor.FileDescriptorProto
+}
+
+func newTypeIndex(files []*descriptor.FileDescriptorProto) *typeIndex {
+	idx := &typeIndex{
+		messages: make(map[string]*descriptor.DescriptorProto),
+		enums:    make(map[string]*descriptor.EnumDescriptorProto),
+		files:    make(map[string]*descriptor.FileDescriptorProto),
+	}
+	for _, f := range files {
+		prefix := ""
+		if f.GetPackage() != "" {
+			prefix = "." + f.GetPackage()
+		}
+		idx.addMessages(f, prefix, f.GetMessageType())
+		idx.addEnums(f, prefix, f.GetEnumType())
+	}
+	return idx
+}
+
+func (idx *typeIndex) addMessages(f *descriptor.FileDescriptorProto, prefix string, msgs []*descriptor.DescriptorProto) {
+	for _, m := range msgs {
+		name := prefix + "." + m.GetName()
+		idx.messages[name] = m
+		idx.files[name] = f
+		idx.addMessages(f, name, m.GetNestedType())
+		idx.addEnums(f, name, m.GetEnumType())
+	}
+}
+
+func (idx *typeIndex) addEnums(f *descriptor.FileDescriptorProto, prefix string, enums []*descriptor.EnumDescriptorProto) {
+	for _, e := range enums {
+		name := prefix + "." + e.GetName()
+		idx.enums[name] = e
+		idx.files[name] = f
+	}
+}
+
+// isMap reports whether field is a map field.
+func (idx *typeIndex) isMap(field *descriptor.FieldDescriptorProto) bool {
+	if field.GetType() != descriptor.FieldDescriptorProto_TYPE_MESSAGE {
+		return false
+	}
+	return idx.messages[field.GetTypeName()].GetOptions().GetMapEntry()
+}
+
+// goImportPath returns the import path of the Go package generated from f,
+// or "" if go_package does not name one.
+func goImportPath(f *descriptor.FileDescriptorProto) string {
+	gopkg := f.GetOptions().GetGoPackage()
+	if sc := strings.IndexByte(gopkg, ';'); sc >= 0 {
+		gopkg = gopkg[:sc]
+	}
+	if !strings.Contains(gopkg, "/") {
+		return ""
+	}
+	return gopkg
+}
+
+// importSet records the Go packages referenced by the code generated for
+// one file, keyed by import path.
+type importSet struct {
+	self  string
+	names map[string]string
+}
+
+func newImportSet(f *descriptor.FileDescriptorProto) *importSet {
+	return &importSet{self: goImportPath(f), names: make(map[string]string)}
+}
+
+// goTypeName returns the Go name of the message or enum typeName, qualified
+// with its package if it is declared outside the generated package.
+func (s *importSet) goTypeName(idx *typeIndex, typeName string) string {
+	name := localTypeName(typeName)
+	f := idx.files[typeName]
+	path := goImportPath(f)
+	if f == nil || path == "" || path == s.self {
+		return name
+	}
+	pkg := defaultGoPackageName(f)
+	s.names[path] = pkg
+	return pkg + "." + name
+}
+
+// localTypeName returns the Go name of a type declared in the file being
+// generated, e.g. ".pkg.Outer.Inner" becomes "Outer_Inner".
+func localTypeName(typeName string) string {
+	parts := strings.Split(strings.TrimPrefix(typeName, "."), ".")
+	var names []string
+	for _, p := range parts {
+		if len(p) > 0 && p[0] >= 'A' && p[0] <= 'Z' {
+			names = append(names, p)
+		}
+	}
+	return strings.Join(names, "_")
+}
+
+// sanitizePackageName replaces unallowed character in package name
+// with allowed character.
+func sanitizePackageName(pkgName string) string {
+	pkgName = strings.Replace(pkgName, ".", "_", -1)
+	pkgName = strings.Replace(pkgName, "-", "_", -1)
+	return pkgName
+}
+
+// defaultGoPackageName returns the default go package name to be used for go files generated from "f".
+// You might need to use an unique alias for the package when you import it.  Use ReserveGoPackageAlias to get a unique alias.
+func defaultGoPackageName(f *descriptor.FileDescriptorProto) string {
+	name := packageIdentityName(f)
+	return sanitizePackageName(name)
+}
+
+// packageIdentityName returns the identity of packages.
+// protoc-gen-grpc-gateway rejects CodeGenerationRequests which contains more than one packages
+// as protoc-gen-go does.
+func packageIdentityName(f *descriptor.FileDescriptorProto) string {
+	if f.Options != nil && f.Options.GoPackage != nil {
+		gopkg := f.Options.GetGoPackage()
+		idx := strings.LastIndex(gopkg, "/")
+		if idx < 0 {
+			gopkg = gopkg[idx+1:]
+		}
+
+		gopkg = gopkg[idx+1:]
+		// package name is overrided with the string after the
+		// ';' character
+		sc := strings.IndexByte(gopkg, ';')
+		if sc < 0 {
+			return sanitizePackageName(gopkg)
+
+		}
+		return sanitizePackageName(gopkg[sc+1:])
+	}
+
+	if f.Package == nil {
+		base := filepath.Base(f.GetName())
+		ext := filepath.Ext(base)
+		return strings.TrimSuffix(base, ext)
+	}
+	return f.GetPackage()
+}
+
+func emitFiles(out []*plugin.CodeGeneratorResponse_File) {
+	emitResp(&plugin.CodeGeneratorResponse{File: out})
+}
+
+func emitError(err error) {
+	emitResp(&plugin.CodeGeneratorResponse{Error: proto.String(err.Error())})
+}
+
+func emitResp(resp *plugin.CodeGeneratorResponse) {
+	buf, err := proto.Marshal(resp)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if _, err := os.Stdout.Write(buf); err != nil {
+		log.Fatal(err)
+	}
+}
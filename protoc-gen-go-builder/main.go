@@ -0,0 +1,460 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+
+	"github.com/f4tq/protoc-go-plugins/goname"
+	"github.com/f4tq/protoc-go-plugins/protodoc"
+)
+
+var (
+	hdrTmpl = template.Must(template.New("header").Parse(`
+// Code generated by protoc-gen-go-builder. DO NOT EDIT.
+// source: {{.Source}}
+
+package {{.GoPkg}}
+{{- if .Imports}}
+
+import (
+{{- range $path, $name := .Imports}}
+    {{$name}} "{{$path}}"
+{{- end}}
+)
+{{- end}}
+`))
+
+	builderTmpl = template.Must(template.New("builder").Parse(`
+// {{.Name}}Builder builds a {{.Name}} field by field. Create one with
+// New{{.Name}}Builder.{{.Doc}}
+type {{.Name}}Builder struct {
+    m *{{.Name}}
+}
+
+// New{{.Name}}Builder returns a builder of an empty {{.Name}}.{{.Doc}}
+func New{{.Name}}Builder() *{{.Name}}Builder {
+    return &{{.Name}}Builder{m: new({{.Name}})}
+}
+{{range .Fields}}
+// Set{{.Go}} sets {{.Proto}}{{if .Oneof}}, replacing any other member of the oneof {{.Oneof}}{{end}}.{{.Doc}}
+func (b *{{$.Name}}Builder) Set{{.Go}}(v {{.Param}}) *{{$.Name}}Builder {
+    {{.Assign}}
+    return b
+}
+{{end}}
+// Build returns the {{.Name}}. The builder must not be used afterwards.
+func (b *{{.Name}}Builder) Build() *{{.Name}} {
+    m := b.m
+    b.m = nil
+    return m
+}
+
+// BuildValidated returns the {{.Name}} like Build, or the error of its
+// Validate method if it has one and it fails.
+func (b *{{.Name}}Builder) BuildValidated() (*{{.Name}}, error) {
+    m := b.Build()
+    if v, ok := interface{}(m).(interface{ Validate() error }); ok {
+        if err := v.Validate(); err != nil {
+            return nil, err
+        }
+    }
+    return m, nil
+}
+`))
+)
+
+func main() {
+	input, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	req := new(plugin.CodeGeneratorRequest)
+	if err = proto.Unmarshal(input, req); err != nil {
+		log.Fatal(err)
+	}
+
+	out, err := generate(req)
+
+	if err != nil {
+		emitError(err)
+		return
+	}
+
+	emitFiles(out)
+}
+
+func generate(req *plugin.CodeGeneratorRequest) ([]*plugin.CodeGeneratorResponse_File, error) {
+	var files []*plugin.CodeGeneratorResponse_File
+	idx := newTypeIndex(req.GetProtoFile())
+	docs := protodoc.New(req.GetProtoFile())
+	genFileNames := make(map[string]bool)
+	for _, n := range req.FileToGenerate {
+		genFileNames[n] = true
+	}
+	for _, desc := range req.GetProtoFile() {
+		name := desc.GetName()
+		if _, ok := genFileNames[name]; !ok {
+			// Only emit output for files present in req.FileToGenerate.
+			continue
+		}
+		code, err := genCode(desc, idx, docs)
+		if err != nil {
+			return nil, err
+		}
+		if code == "" {
+			// The file declares no messages.
+			continue
+		}
+		formatted, err := format.Source([]byte(code))
+		if err != nil {
+			log.Printf("%v: %s", err, code)
+			return nil, err
+		}
+
+		ext := filepath.Ext(name)
+		base := strings.TrimSuffix(name, ext)
+		output := fmt.Sprintf("%s.pb.builder.go", base)
+		files = append(files, &plugin.CodeGeneratorResponse_File{
+			Name:    proto.String(output),
+			Content: proto.String(string(formatted)),
+		})
+	}
+
+	return files, nil
+}
+
+func genCode(desc *descriptor.FileDescriptorProto, idx *typeIndex, docs *protodoc.Index) (string, error) {
+	w := bytes.NewBuffer(nil)
+	g := &builderGen{
+		idx:     idx,
+		docs:    docs,
+		imports: newImportSet(desc),
+		proto3:  desc.GetSyntax() == "proto3",
+	}
+	body := bytes.NewBuffer(nil)
+	if err := g.messages(body, "", desc.GetPackage(), desc.GetMessageType()); err != nil {
+		return "", err
+	}
+	if body.Len() == 0 {
+		return "", nil
+	}
+
+	hdr := &header{
+		Source:  desc.GetName(),
+		GoPkg:   defaultGoPackageName(desc),
+		Imports: g.imports.names,
+	}
+	if err := hdrTmpl.Execute(w, hdr); err != nil {
+		log.Fatal(err)
+	}
+	body.WriteTo(w)
+
+	return w.String(), nil
+}
+
+type builderGen struct {
+	idx     *typeIndex
+	docs    *protodoc.Index
+	imports *importSet
+	proto3  bool
+}
+
+// messages writes the builders of msgs and of the messages nested in them.
+// prefix is the Go name of the enclosing message plus "_", and scope its
+// full proto name.
+func (g *builderGen) messages(w *bytes.Buffer, prefix, scope string, msgs []*descriptor.DescriptorProto) error {
+	for _, msg := range msgs {
+		if msg.GetOptions().GetMapEntry() {
+			continue
+		}
+		name := prefix + msg.GetName()
+		fullName := qualify(scope, msg.GetName())
+		m := &builderMessage{Name: name, Doc: g.docs.Godoc(fullName)}
+		goNames := goname.Fields(msg)
+		for _, field := range msg.GetField() {
+			f := g.field(name, msg, goNames, field)
+			f.Doc = g.docs.Godoc(fullName + "." + field.GetName())
+			m.Fields = append(m.Fields, f)
+		}
+		if err := builderTmpl.Execute(w, m); err != nil {
+			return err
+		}
+		if err := g.messages(w, name+"_", fullName, msg.GetNestedType()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// field returns the template data of the setter of field of msg, whose Go
+// name is msgName and whose fields and oneofs have the Go names goNames.
+func (g *builderGen) field(msgName string, msg *descriptor.DescriptorProto, goNames map[string]string, field *descriptor.FieldDescriptorProto) *builderField {
+	goName := goNames[field.GetName()]
+	f := &builderField{Proto: field.GetName(), Go: goName, Param: g.goType(field)}
+	switch {
+	case g.idx.isMap(field):
+		entry := g.idx.messages[field.GetTypeName()]
+		f.Param = fmt.Sprintf("map[%s]%s", g.goType(entry.GetField()[0]), g.goType(entry.GetField()[1]))
+		f.Assign = fmt.Sprintf("b.m.%s = v", goName)
+	case field.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REPEATED:
+		f.Param = "..." + f.Param
+		f.Assign = fmt.Sprintf("b.m.%s = v", goName)
+	case field.OneofIndex != nil && !field.GetProto3Optional():
+		f.Oneof = msg.GetOneofDecl()[field.GetOneofIndex()].GetName()
+		f.Assign = fmt.Sprintf("b.m.%s = &%s_%s{%s: v}", goNames[f.Oneof], msgName, goName, goName)
+	case field.GetType() == descriptor.FieldDescriptorProto_TYPE_MESSAGE,
+		field.GetType() == descriptor.FieldDescriptorProto_TYPE_GROUP,
+		field.GetType() == descriptor.FieldDescriptorProto_TYPE_BYTES:
+		f.Assign = fmt.Sprintf("b.m.%s = v", goName)
+	case field.GetProto3Optional() || !g.proto3:
+		// Scalars are pointers.
+		f.Assign = fmt.Sprintf("b.m.%s = &v", goName)
+	default:
+		f.Assign = fmt.Sprintf("b.m.%s = v", goName)
+	}
+	return f
+}
+
+// goType returns the Go type of a value of field.
+func (g *builderGen) goType(field *descriptor.FieldDescriptorProto) string {
+	switch field.GetType() {
+	case descriptor.FieldDescriptorProto_TYPE_MESSAGE, descriptor.FieldDescriptorProto_TYPE_GROUP:
+		return "*" + g.imports.goTypeName(g.idx, field.GetTypeName())
+	case descriptor.FieldDescriptorProto_TYPE_ENUM:
+		return g.imports.goTypeName(g.idx, field.GetTypeName())
+	case descriptor.FieldDescriptorProto_TYPE_BYTES:
+		return "[]byte"
+	}
+	return scalarGoType(field.GetType())
+}
+
+// scalarGoType returns the Go type of the scalar type t.
+func scalarGoType(t descriptor.FieldDescriptorProto_Type) string {
+	switch t {
+	case descriptor.FieldDescriptorProto_TYPE_DOUBLE:
+		return "float64"
+	case descriptor.FieldDescriptorProto_TYPE_FLOAT:
+		return "float32"
+	case descriptor.FieldDescriptorProto_TYPE_INT64, descriptor.FieldDescriptorProto_TYPE_SINT64,
+		descriptor.FieldDescriptorProto_TYPE_SFIXED64:
+		return "int64"
+	case descriptor.FieldDescriptorProto_TYPE_UINT64, descriptor.FieldDescriptorProto_TYPE_FIXED64:
+		return "uint64"
+	case descriptor.FieldDescriptorProto_TYPE_INT32, descriptor.FieldDescriptorProto_TYPE_SINT32,
+		descriptor.FieldDescriptorProto_TYPE_SFIXED32:
+		return "int32"
+	case descriptor.FieldDescriptorProto_TYPE_UINT32, descriptor.FieldDescriptorProto_TYPE_FIXED32:
+		return "uint32"
+	case descriptor.FieldDescriptorProto_TYPE_BOOL:
+		return "bool"
+	}
+	return "string"
+}
+
+type header struct {
+	Source  string
+	GoPkg   string
+	Imports map[string]string
+}
+
+type builderMessage struct {
+	Name string
+	// Doc is the comment of the message, see protodoc.Index.Godoc.
+	Doc    string
+	Fields []*builderField
+}
+
+type builderField struct {
+	Proto  string
+	Go     string
+	Oneof  string
+	Param  string
+	Assign string
+	// Doc is the comment of the field.
+	Doc string
+}
+
+// qualify returns the full proto name of name, declared in scope.
+func qualify(scope, name string) string {
+	if scope == "" {
+		return name
+	}
+	return scope + "." + name
+}
+
+// typeIndex maps fully-qualified proto type names (".pkg.Msg") to their
+// descriptors and declaring files for every file in the request.
+type typeIndex struct {
+	messages map[string]*descriptor.DescriptorProto
+	enums    map[string]*descriptor.EnumDescriptorProto
+	files    map[string]*descriptor.FileDescriptorProto
+}
+
+func newTypeIndex(files []*descriptor.FileDescriptorProto) *typeIndex {
+	idx := &typeIndex{
+		messages: make(map[string]*descriptor.DescriptorProto),
+		enums:    make(map[string]*descriptor.EnumDescriptorProto),
+		files:    make(map[string]*descriptor.FileDescriptorProto),
+	}
+	for _, f := range files {
+		prefix := ""
+		if f.GetPackage() != "" {
+			prefix = "." + f.GetPackage()
+		}
+		idx.addMessages(f, prefix, f.GetMessageType())
+		idx.addEnums(f, prefix, f.GetEnumType())
+	}
+	return idx
+}
+
+func (idx *typeIndex) addMessages(f *descriptor.FileDescriptorProto, prefix string, msgs []*descriptor.DescriptorProto) {
+	for _, m := range msgs {
+		name := prefix + "." + m.GetName()
+		idx.messages[name] = m
+		idx.files[name] = f
+		idx.addMessages(f, name, m.GetNestedType())
+		idx.addEnums(f, name, m.GetEnumType())
+	}
+}
+
+func (idx *typeIndex) addEnums(f *descriptor.FileDescriptorProto, prefix string, enums []*descriptor.EnumDescriptorProto) {
+	for _, e := range enums {
+		name := prefix + "." + e.GetName()
+		idx.enums[name] = e
+		idx.files[name] = f
+	}
+}
+
+// isMap reports whether field is a map field.
+func (idx *typeIndex) isMap(field *descriptor.FieldDescriptorProto) bool {
+	if field.GetType() != descriptor.FieldDescriptorProto_TYPE_MESSAGE {
+		return false
+	}
+	return idx.messages[field.GetTypeName()].GetOptions().GetMapEntry()
+}
+
+// goImportPath returns the import path of the Go package generated from f,
+// or "" if go_package does not name one.
+func goImportPath(f *descriptor.FileDescriptorProto) string {
+	gopkg := f.GetOptions().GetGoPackage()
+	if sc := strings.IndexByte(gopkg, ';'); sc >= 0 {
+		gopkg = gopkg[:sc]
+	}
+	if !strings.Contains(gopkg, "/") {
+		return ""
+	}
+	return gopkg
+}
+
+// importSet records the Go packages referenced by the code generated for
+// one file, keyed by import path.
+type importSet struct {
+	self  string
+	names map[string]string
+}
+
+func newImportSet(f *descriptor.FileDescriptorProto) *importSet {
+	return &importSet{self: goImportPath(f), names: make(map[string]string)}
+}
+
+// goTypeName returns the Go name of the message or enum typeName, qualified
+// with its package if it is declared outside the generated package.
+func (s *importSet) goTypeName(idx *typeIndex, typeName string) string {
+	name := localTypeName(typeName)
+	f := idx.files[typeName]
+	path := goImportPath(f)
+	if f == nil || path == "" || path == s.self {
+		return name
+	}
+	pkg := defaultGoPackageName(f)
+	s.names[path] = pkg
+	return pkg + "." + name
+}
+
+// localTypeName returns the Go name of a type declared in the file being
+// generated, e.g. ".pkg.Outer.Inner" becomes "Outer_Inner".
+func localTypeName(typeName string) string {
+	parts := strings.Split(strings.TrimPrefix(typeName, "."), ".")
+	var names []string
+	for _, p := range parts {
+		if len(p) > 0 && p[0] >= 'A' && p[0] <= 'Z' {
+			names = append(names, p)
+		}
+	}
+	return strings.Join(names, "_")
+}
+
+// sanitizePackageName replaces unallowed character in package name
+// with allowed character.
+func sanitizePackageName(pkgName string) string {
+	pkgName = strings.Replace(pkgName, ".", "_", -1)
+	pkgName = strings.Replace(pkgName, "-", "_", -1)
+	return pkgName
+}
+
+// defaultGoPackageName returns the default go package name to be used for go files generated from "f".
+// You might need to use an unique alias for the package when you import it.  Use ReserveGoPackageAlias to get a unique alias.
+func defaultGoPackageName(f *descriptor.FileDescriptorProto) string {
+	name := packageIdentityName(f)
+	return sanitizePackageName(name)
+}
+
+// packageIdentityName returns the identity of packages.
+// protoc-gen-grpc-gateway rejects CodeGenerationRequests which contains more than one packages
+// as protoc-gen-go does.
+func packageIdentityName(f *descriptor.FileDescriptorProto) string {
+	if f.Options != nil && f.Options.GoPackage != nil {
+		gopkg := f.Options.GetGoPackage()
+		idx := strings.LastIndex(gopkg, "/")
+		if idx < 0 {
+			gopkg = gopkg[idx+1:]
+		}
+
+		gopkg = gopkg[idx+1:]
+		// package name is overrided with the string after the
+		// ';' character
+		sc := strings.IndexByte(gopkg, ';')
+		if sc < 0 {
+			return sanitizePackageName(gopkg)
+
+		}
+		return sanitizePackageName(gopkg[sc+1:])
+	}
+
+	if f.Package == nil {
+		base := filepath.Base(f.GetName())
+		ext := filepath.Ext(base)
+		return strings.TrimSuffix(base, ext)
+	}
+	return f.GetPackage()
+}
+
+func emitFiles(out []*plugin.CodeGeneratorResponse_File) {
+	emitResp(&plugin.CodeGeneratorResponse{File: out})
+}
+
+func emitError(err error) {
+	emitResp(&plugin.CodeGeneratorResponse{Error: proto.String(err.Error())})
+}
+
+func emitResp(resp *plugin.CodeGeneratorResponse) {
+	buf, err := proto.Marshal(resp)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if _, err := os.Stdout.Write(buf); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,513 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+
+	"github.com/f4tq/protoc-go-plugins/goname"
+)
+
+// secondsNanos are the well-known types encoded by their seconds and nanos
+// rather than by package proto.
+var secondsNanos = map[string]bool{
+	".google.protobuf.Timestamp": true,
+	".google.protobuf.Duration":  true,
+}
+
+var (
+	hdrTmpl = template.Must(template.New("header").Parse(`
+// Code generated by protoc-gen-go-canonical. DO NOT EDIT.
+// source: {{.Source}}
+
+package {{.GoPkg}}
+
+import (
+    "github.com/f4tq/protoc-go-plugins/runtime/canonical"
+{{- range $path, $name := .Imports}}
+    {{$name}} "{{$path}}"
+{{- end}}
+)
+`))
+
+	canonicalTmpl = template.Must(template.New("canonical").Parse(`
+// CanonicalBytes returns the canonical encoding of m: valid protobuf wire
+// format, identical for equal messages and stable across releases, to sign
+// or content-address m by. See package canonical for the rules.
+func (m *{{.Name}}) CanonicalBytes() []byte {
+    var e canonical.Encoder
+    m.EncodeCanonical(&e)
+    return e.Result()
+}
+
+// EncodeCanonical writes the fields of m to e, see CanonicalBytes.
+func (m *{{.Name}}) EncodeCanonical(e *canonical.Encoder) {
+    if m == nil {
+        return
+    }
+    e.Unknown(m.XXX_unrecognized)
+{{- range .Fields}}
+{{.}}
+{{- end}}
+    e.End()
+}
+`))
+)
+
+func main() {
+	input, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	req := new(plugin.CodeGeneratorRequest)
+	if err = proto.Unmarshal(input, req); err != nil {
+		log.Fatal(err)
+	}
+
+	out, err := generate(req)
+
+	if err != nil {
+		emitError(err)
+		return
+	}
+
+	emitFiles(out)
+}
+
+func generate(req *plugin.CodeGeneratorRequest) ([]*plugin.CodeGeneratorResponse_File, error) {
+	var files []*plugin.CodeGeneratorResponse_File
+	idx := newTypeIndex(req.GetProtoFile())
+	genFileNames := make(map[string]bool)
+	for _, n := range req.FileToGenerate {
+		genFileNames[n] = true
+	}
+	for _, desc := range req.GetProtoFile() {
+		name := desc.GetName()
+		if _, ok := genFileNames[name]; !ok {
+			// Only emit output for files present in req.FileToGenerate.
+			continue
+		}
+		code, err := genCode(desc, idx, genFileNames)
+		if err != nil {
+			return nil, err
+		}
+		if code == "" {
+			// The file declares no messages.
+			continue
+		}
+		formatted, err := format.Source([]byte(code))
+		if err != nil {
+			log.Printf("%v: %s", err, code)
+			return nil, err
+		}
+
+		ext := filepath.Ext(name)
+		base := strings.TrimSuffix(name, ext)
+		output := fmt.Sprintf("%s.pb.canonical.go", base)
+		files = append(files, &plugin.CodeGeneratorResponse_File{
+			Name:    proto.String(output),
+			Content: proto.String(string(formatted)),
+		})
+	}
+
+	return files, nil
+}
+
+func genCode(desc *descriptor.FileDescriptorProto, idx *typeIndex, genFileNames map[string]bool) (string, error) {
+	w := bytes.NewBuffer(nil)
+	g := &canonicalGen{
+		idx:     idx,
+		imports: newImportSet(desc),
+		gen:     genFileNames,
+		proto3:  desc.GetSyntax() == "proto3",
+	}
+	body := bytes.NewBuffer(nil)
+	if err := g.messages(body, "", desc.GetMessageType()); err != nil {
+		return "", err
+	}
+	if body.Len() == 0 {
+		return "", nil
+	}
+
+	hdr := &header{
+		Source:  desc.GetName(),
+		GoPkg:   defaultGoPackageName(desc),
+		Imports: g.imports.names,
+	}
+	if err := hdrTmpl.Execute(w, hdr); err != nil {
+		log.Fatal(err)
+	}
+	body.WriteTo(w)
+
+	return w.String(), nil
+}
+
+type canonicalGen struct {
+	idx     *typeIndex
+	imports *importSet
+	gen     map[string]bool
+	proto3  bool
+}
+
+// messages writes the canonical encoding methods of msgs and of the
+// messages nested in them. prefix is the Go name of the enclosing message
+// plus "_".
+func (g *canonicalGen) messages(w *bytes.Buffer, prefix string, msgs []*descriptor.DescriptorProto) error {
+	for _, msg := range msgs {
+		if msg.GetOptions().GetMapEntry() {
+			continue
+		}
+		name := prefix + msg.GetName()
+		fields := append([]*descriptor.FieldDescriptorProto(nil), msg.GetField()...)
+		sort.Slice(fields, func(i, j int) bool {
+			return fields[i].GetNumber() < fields[j].GetNumber()
+		})
+		m := &canonicalMessage{Name: name}
+		for _, field := range fields {
+			m.Fields = append(m.Fields, g.field(name, msg, field))
+		}
+		if err := canonicalTmpl.Execute(w, m); err != nil {
+			return err
+		}
+		if err := g.messages(w, name+"_", msg.GetNestedType()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// field returns the statements writing field of msg, whose Go name is
+// msgName, to e if it is set.
+func (g *canonicalGen) field(msgName string, msg *descriptor.DescriptorProto, field *descriptor.FieldDescriptorProto) string {
+	num := field.GetNumber()
+	goNames := goname.Fields(msg)
+	goName := goNames[field.GetName()]
+	v := "m." + goName
+	switch {
+	case g.idx.isMap(field):
+		entry := g.idx.messages[field.GetTypeName()].GetField()
+		return fmt.Sprintf("if len(%s) > 0 {\ncanonical.Map(e, %d, %s, func(e *canonical.Encoder, k %s, v %s) {\n%s\n%s\n})\n}",
+			v, num, v, g.goType(entry[0]), g.goType(entry[1]), g.write(entry[0], 1, "k"), g.write(entry[1], 2, "v"))
+	case field.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REPEATED:
+		if fn := packedFunc(field.GetType()); fn != "" {
+			return fmt.Sprintf("if len(%s) > 0 {\ncanonical.%s(e, %d, %s)\n}", v, fn, num, v)
+		}
+		return fmt.Sprintf("for _, v := range %s {\n%s\n}", v, g.write(field, num, "v"))
+	case field.OneofIndex != nil && !field.GetProto3Optional():
+		oneof := goNames[msg.GetOneofDecl()[field.GetOneofIndex()].GetName()]
+		return fmt.Sprintf("if v, ok := m.%s.(*%s_%s); ok {\n%s\n}", oneof, msgName, goName, g.write(field, num, "v."+goName))
+	case field.GetType() == descriptor.FieldDescriptorProto_TYPE_MESSAGE,
+		field.GetType() == descriptor.FieldDescriptorProto_TYPE_GROUP:
+		return fmt.Sprintf("if %s != nil {\n%s\n}", v, g.write(field, num, v))
+	case field.GetType() == descriptor.FieldDescriptorProto_TYPE_BYTES && (field.GetProto3Optional() || !g.proto3):
+		// Bytes are nil when unset.
+		return fmt.Sprintf("if %s != nil {\n%s\n}", v, g.write(field, num, v))
+	case field.GetProto3Optional() || !g.proto3:
+		// Other scalars are pointers.
+		return fmt.Sprintf("if %s != nil {\n%s\n}", v, g.write(field, num, "*"+v))
+	}
+	return fmt.Sprintf("if %s {\n%s\n}", g.isSet(field, v), g.write(field, num, v))
+}
+
+// isSet returns the condition under which the proto3 scalar v differs from
+// its default value.
+func (g *canonicalGen) isSet(field *descriptor.FieldDescriptorProto, v string) string {
+	switch field.GetType() {
+	case descriptor.FieldDescriptorProto_TYPE_BOOL:
+		return v
+	case descriptor.FieldDescriptorProto_TYPE_STRING:
+		return v + ` != ""`
+	case descriptor.FieldDescriptorProto_TYPE_BYTES:
+		return "len(" + v + ") > 0"
+	}
+	return v + " != 0"
+}
+
+// write returns the statement writing the value v of field to e as field
+// number num.
+func (g *canonicalGen) write(field *descriptor.FieldDescriptorProto, num int32, v string) string {
+	switch field.GetType() {
+	case descriptor.FieldDescriptorProto_TYPE_MESSAGE, descriptor.FieldDescriptorProto_TYPE_GROUP:
+		method := "Message"
+		if field.GetType() == descriptor.FieldDescriptorProto_TYPE_GROUP {
+			method = "Group"
+		}
+		if g.gen[g.idx.files[field.GetTypeName()].GetName()] {
+			return fmt.Sprintf("e.%s(%d, %s.EncodeCanonical)", method, num, v)
+		}
+		if secondsNanos[field.GetTypeName()] {
+			return fmt.Sprintf("e.SecondsNanos(%d, %s.GetSeconds(), %s.GetNanos())", num, v, v)
+		}
+		// Messages of other packages have no EncodeCanonical method.
+		return fmt.Sprintf("e.Proto(%d, %s)", num, v)
+	case descriptor.FieldDescriptorProto_TYPE_STRING:
+		return fmt.Sprintf("e.String(%d, %s)", num, v)
+	case descriptor.FieldDescriptorProto_TYPE_BYTES:
+		return fmt.Sprintf("e.Bytes(%d, %s)", num, v)
+	case descriptor.FieldDescriptorProto_TYPE_BOOL:
+		return fmt.Sprintf("e.Bool(%d, %s)", num, v)
+	case descriptor.FieldDescriptorProto_TYPE_DOUBLE:
+		return fmt.Sprintf("e.Double(%d, %s)", num, v)
+	case descriptor.FieldDescriptorProto_TYPE_FLOAT:
+		return fmt.Sprintf("e.Float(%d, %s)", num, v)
+	case descriptor.FieldDescriptorProto_TYPE_UINT64, descriptor.FieldDescriptorProto_TYPE_UINT32:
+		return fmt.Sprintf("e.Uint(%d, uint64(%s))", num, v)
+	case descriptor.FieldDescriptorProto_TYPE_SINT64, descriptor.FieldDescriptorProto_TYPE_SINT32:
+		return fmt.Sprintf("e.Sint(%d, int64(%s))", num, v)
+	case descriptor.FieldDescriptorProto_TYPE_FIXED64, descriptor.FieldDescriptorProto_TYPE_SFIXED64:
+		return fmt.Sprintf("e.Fixed64(%d, uint64(%s))", num, v)
+	case descriptor.FieldDescriptorProto_TYPE_FIXED32, descriptor.FieldDescriptorProto_TYPE_SFIXED32:
+		return fmt.Sprintf("e.Fixed32(%d, uint32(%s))", num, v)
+	}
+	// int32, int64 and enums.
+	return fmt.Sprintf("e.Int(%d, int64(%s))", num, v)
+}
+
+// packedFunc returns the function of package canonical writing a repeated
+// field of type t packed, or "" if t cannot be packed.
+func packedFunc(t descriptor.FieldDescriptorProto_Type) string {
+	switch t {
+	case descriptor.FieldDescriptorProto_TYPE_MESSAGE, descriptor.FieldDescriptorProto_TYPE_GROUP,
+		descriptor.FieldDescriptorProto_TYPE_STRING, descriptor.FieldDescriptorProto_TYPE_BYTES:
+		return ""
+	case descriptor.FieldDescriptorProto_TYPE_BOOL:
+		return "PackedBool"
+	case descriptor.FieldDescriptorProto_TYPE_DOUBLE:
+		return "PackedDouble"
+	case descriptor.FieldDescriptorProto_TYPE_FLOAT:
+		return "PackedFloat"
+	case descriptor.FieldDescriptorProto_TYPE_UINT64, descriptor.FieldDescriptorProto_TYPE_UINT32:
+		return "PackedUint"
+	case descriptor.FieldDescriptorProto_TYPE_SINT64, descriptor.FieldDescriptorProto_TYPE_SINT32:
+		return "PackedSint"
+	case descriptor.FieldDescriptorProto_TYPE_FIXED64, descriptor.FieldDescriptorProto_TYPE_SFIXED64:
+		return "PackedFixed64"
+	case descriptor.FieldDescriptorProto_TYPE_FIXED32, descriptor.FieldDescriptorProto_TYPE_SFIXED32:
+		return "PackedFixed32"
+	}
+	return "PackedInt"
+}
+
+// goType returns the Go type of a value of field.
+func (g *canonicalGen) goType(field *descriptor.FieldDescriptorProto) string {
+	switch field.GetType() {
+	case descriptor.FieldDescriptorProto_TYPE_MESSAGE, descriptor.FieldDescriptorProto_TYPE_GROUP:
+		return "*" + g.imports.goTypeName(g.idx, field.GetTypeName())
+	case descriptor.FieldDescriptorProto_TYPE_ENUM:
+		return g.imports.goTypeName(g.idx, field.GetTypeName())
+	case descriptor.FieldDescriptorProto_TYPE_BYTES:
+		return "[]byte"
+	}
+	return scalarGoType(field.GetType())
+}
+
+// scalarGoType returns the Go type of the scalar type t.
+func scalarGoType(t descriptor.FieldDescriptorProto_Type) string {
+	switch t {
+	case descriptor.FieldDescriptorProto_TYPE_DOUBLE:
+		return "float64"
+	case descriptor.FieldDescriptorProto_TYPE_FLOAT:
+		return "float32"
+	case descriptor.FieldDescriptorProto_TYPE_INT64, descriptor.FieldDescriptorProto_TYPE_SINT64,
+		descriptor.FieldDescriptorProto_TYPE_SFIXED64:
+		return "int64"
+	case descriptor.FieldDescriptorProto_TYPE_UINT64, descriptor.FieldDescriptorProto_TYPE_FIXED64:
+		return "uint64"
+	case descriptor.FieldDescriptorProto_TYPE_INT32, descriptor.FieldDescriptorProto_TYPE_SINT32,
+		descriptor.FieldDescriptorProto_TYPE_SFIXED32:
+		return "int32"
+	case descriptor.FieldDescriptorProto_TYPE_UINT32, descriptor.FieldDescriptorProto_TYPE_FIXED32:
+		return "uint32"
+	case descriptor.FieldDescriptorProto_TYPE_BOOL:
+		return "bool"
+	}
+	return "string"
+}
+
+type header struct {
+	Source  string
+	GoPkg   string
+	Imports map[string]string
+}
+
+type canonicalMessage struct {
+	Name   string
+	Fields []string
+}
+
+// typeIndex maps fully-qualified proto type names (".pkg.Msg") to their
+// descriptors and declaring files for every file in the request.
+type typeIndex struct {
+	messages map[string]*descriptor.DescriptorProto
+	enums    map[string]*descriptor.EnumDescriptorProto
+	files    map[string]*descriptor.FileDescriptorProto
+}
+
+func newTypeIndex(files []*descriptor.FileDescriptorProto) *typeIndex {
+	idx := &typeIndex{
+		messages: make(map[string]*descriptor.DescriptorProto),
+		enums:    make(map[string]*descriptor.EnumDescriptorProto),
+		files:    make(map[string]*descriptor.FileDescriptorProto),
+	}
+	for _, f := range files {
+		prefix := ""
+		if f.GetPackage() != "" {
+			prefix = "." + f.GetPackage()
+		}
+		idx.addMessages(f, prefix, f.GetMessageType())
+		idx.addEnums(f, prefix, f.GetEnumType())
+	}
+	return idx
+}
+
+func (idx *typeIndex) addMessages(f *descriptor.FileDescriptorProto, prefix string, msgs []*descriptor.DescriptorProto) {
+	for _, m := range msgs {
+		name := prefix + "." + m.GetName()
+		idx.messages[name] = m
+		idx.files[name] = f
+		idx.addMessages(f, name, m.GetNestedType())
+		idx.addEnums(f, name, m.GetEnumType())
+	}
+}
+
+func (idx *typeIndex) addEnums(f *descriptor.FileDescriptorProto, prefix string, enums []*descriptor.EnumDescriptorProto) {
+	for _, e := range enums {
+		name := prefix + "." + e.GetName()
+		idx.enums[name] = e
+		idx.files[name] = f
+	}
+}
+
+// isMap reports whether field is a map field.
+func (idx *typeIndex) isMap(field *descriptor.FieldDescriptorProto) bool {
+	if field.GetType() != descriptor.FieldDescriptorProto_TYPE_MESSAGE {
+		return false
+	}
+	return idx.messages[field.GetTypeName()].GetOptions().GetMapEntry()
+}
+
+// goImportPath returns the import path of the Go package generated from f,
+// or "" if go_package does not name one.
+func goImportPath(f *descriptor.FileDescriptorProto) string {
+	gopkg := f.GetOptions().GetGoPackage()
+	if sc := strings.IndexByte(gopkg, ';'); sc >= 0 {
+		gopkg = gopkg[:sc]
+	}
+	if !strings.Contains(gopkg, "/") {
+		return ""
+	}
+	return gopkg
+}
+
+// importSet records the Go packages referenced by the code generated for
+// one file, keyed by import path.
+type importSet struct {
+	self  string
+	names map[string]string
+}
+
+func newImportSet(f *descriptor.FileDescriptorProto) *importSet {
+	return &importSet{self: goImportPath(f), names: make(map[string]string)}
+}
+
+// goTypeName returns the Go name of the message or enum typeName, qualified
+// with its package if it is declared outside the generated package.
+func (s *importSet) goTypeName(idx *typeIndex, typeName string) string {
+	name := localTypeName(typeName)
+	f := idx.files[typeName]
+	path := goImportPath(f)
+	if f == nil || path == "" || path == s.self {
+		return name
+	}
+	pkg := defaultGoPackageName(f)
+	s.names[path] = pkg
+	return pkg + "." + name
+}
+
+// localTypeName returns the Go name of a type declared in the file being
+// generated, e.g. ".pkg.Outer.Inner" becomes "Outer_Inner".
+func localTypeName(typeName string) string {
+	parts := strings.Split(strings.TrimPrefix(typeName, "."), ".")
+	var names []string
+	for _, p := range parts {
+		if len(p) > 0 && p[0] >= 'A' && p[0] <= 'Z' {
+			names = append(names, p)
+		}
+	}
+	return strings.Join(names, "_")
+}
+
+// sanitizePackageName replaces unallowed character in package name
+// with allowed character.
+func sanitizePackageName(pkgName string) string {
+	pkgName = strings.Replace(pkgName, ".", "_", -1)
+	pkgName = strings.Replace(pkgName, "-", "_", -1)
+	return pkgName
+}
+
+// defaultGoPackageName returns the default go package name to be used for go files generated from "f".
+// You might need to use an unique alias for the package when you import it.  Use ReserveGoPackageAlias to get a unique alias.
+func defaultGoPackageName(f *descriptor.FileDescriptorProto) string {
+	name := packageIdentityName(f)
+	return sanitizePackageName(name)
+}
+
+// packageIdentityName returns the identity of packages.
+// protoc-gen-grpc-gateway rejects CodeGenerationRequests which contains more than one packages
+// as protoc-gen-go does.
+func packageIdentityName(f *descriptor.FileDescriptorProto) string {
+	if f.Options != nil && f.Options.GoPackage != nil {
+		gopkg := f.Options.GetGoPackage()
+		idx := strings.LastIndex(gopkg, "/")
+		if idx < 0 {
+			gopkg = gopkg[idx+1:]
+		}
+
+		gopkg = gopkg[idx+1:]
+		// package name is overrided with the string after the
+		// ';' character
+		sc := strings.IndexByte(gopkg, ';')
+		if sc < 0 {
+			return sanitizePackageName(gopkg)
+
+		}
+		return sanitizePackageName(gopkg[sc+1:])
+	}
+
+	if f.Package == nil {
+		base := filepath.Base(f.GetName())
+		ext := filepath.Ext(base)
+		return strings.TrimSuffix(base, ext)
+	}
+	return f.GetPackage()
+}
+
+func emitFiles(out []*plugin.CodeGeneratorResponse_File) {
+	emitResp(&plugin.CodeGeneratorResponse{File: out})
+}
+
+func emitError(err error) {
+	emitResp(&plugin.CodeGeneratorResponse{Error: proto.String(err.Error())})
+}
+
+func emitResp(resp *plugin.CodeGeneratorResponse) {
+	buf, err := proto.Marshal(resp)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if _, err := os.Stdout.Write(buf); err != nil {
+		log.Fatal(err)
+	}
+}
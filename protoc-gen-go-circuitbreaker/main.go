@@ -0,0 +1,446 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+
+	"github.com/f4tq/protoc-go-plugins/options"
+	"github.com/f4tq/protoc-go-plugins/protodoc"
+)
+
+var (
+	hdrTmpl = template.Must(template.New("header").Parse(`
+// Code generated by protoc-gen-go-circuitbreaker. DO NOT EDIT.
+// source: {{.Source}}
+
+package {{.GoPkg}}
+
+import (
+    "context"
+{{- if .Time}}
+    "time"
+{{- end}}
+
+    "github.com/f4tq/protoc-go-plugins/runtime/breaker"
+    "google.golang.org/grpc"
+{{- range $path, $name := .Imports}}
+    {{$name}} "{{$path}}"
+{{- end}}
+)
+`))
+
+	breakerTmpl = template.Must(template.New("breaker").Parse(`
+// {{.Name}}BreakerClient is a {{.Name}}Client whose unary methods are each
+// guarded by their own circuit breaker. While a method's breaker is open its
+// calls fail fast with breaker.ErrOpen.{{.Doc}}
+type {{.Name}}BreakerClient interface {
+    {{.Name}}Client
+
+    // BreakerState returns the state of the breaker of method, named as
+    // in the proto. Unguarded methods report breaker.Closed.
+    BreakerState(method string) breaker.State
+}
+
+// New{{.Name}}BreakerClient wraps next with per-method circuit breakers
+// configured from the (f4tq.plugins.circuit_breaker) method options.
+// Streaming calls are passed through.{{.Doc}}
+func New{{.Name}}BreakerClient(next {{.Name}}Client) {{.Name}}BreakerClient {
+    return &{{.Lower}}BreakerClient{
+        {{.Name}}Client: next,
+        breakers: map[string]*breaker.Breaker{
+{{- range .Methods}}
+            {{printf "%q" .Name}}: breaker.New(breaker.Settings{ {{.Settings}} }),
+{{- end}}
+        },
+    }
+}
+
+type {{.Lower}}BreakerClient struct {
+    {{.Name}}Client
+    breakers map[string]*breaker.Breaker
+}
+
+func (c *{{.Lower}}BreakerClient) BreakerState(method string) breaker.State {
+    if b, ok := c.breakers[method]; ok {
+        return b.State()
+    }
+    return breaker.Closed
+}
+{{range .Methods}}
+func (c *{{$.Lower}}BreakerClient) {{.Name}}(ctx context.Context, in *{{.Input}}, opts ...grpc.CallOption) (*{{.Output}}, error) {
+    var resp *{{.Output}}
+    err := c.breakers[{{printf "%q" .Name}}].Do(ctx, func(ctx context.Context) error {
+        var err error
+        resp, err = c.{{$.Name}}Client.{{.Name}}(ctx, in, opts...)
+        return err
+    })
+    return resp, err
+}
+{{end}}
+`))
+)
+
+func main() {
+	input, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	req := new(plugin.CodeGeneratorRequest)
+	if err = proto.Unmarshal(input, req); err != nil {
+		log.Fatal(err)
+	}
+
+	out, err := generate(req)
+
+	if err != nil {
+		emitError(err)
+		return
+	}
+
+	emitFiles(out)
+}
+
+func generate(req *plugin.CodeGeneratorRequest) ([]*plugin.CodeGeneratorResponse_File, error) {
+	var files []*plugin.CodeGeneratorResponse_File
+	idx := newTypeIndex(req.GetProtoFile())
+	docs := protodoc.New(req.GetProtoFile())
+	genFileNames := make(map[string]bool)
+	for _, n := range req.FileToGenerate {
+		genFileNames[n] = true
+	}
+	for _, desc := range req.GetProtoFile() {
+		name := desc.GetName()
+		if _, ok := genFileNames[name]; !ok {
+			// Only emit output for files present in req.FileToGenerate.
+			continue
+		}
+		code, err := genCode(desc, idx, docs)
+		if err != nil {
+			return nil, err
+		}
+		if code == "" {
+			// No service with unary methods to guard.
+			continue
+		}
+		formatted, err := format.Source([]byte(code))
+		if err != nil {
+			log.Printf("%v: %s", err, code)
+			return nil, err
+		}
+
+		ext := filepath.Ext(name)
+		base := strings.TrimSuffix(name, ext)
+		output := fmt.Sprintf("%s.pb.circuitbreaker.go", base)
+		files = append(files, &plugin.CodeGeneratorResponse_File{
+			Name:    proto.String(output),
+			Content: proto.String(string(formatted)),
+		})
+	}
+
+	return files, nil
+}
+
+func genCode(desc *descriptor.FileDescriptorProto, idx *typeIndex, docs *protodoc.Index) (string, error) {
+	w := bytes.NewBuffer(nil)
+	imports := newImportSet(desc)
+	hdr := &header{
+		Source:  desc.GetName(),
+		GoPkg:   defaultGoPackageName(desc),
+		Imports: imports.names,
+	}
+	body := bytes.NewBuffer(nil)
+	for _, svc := range desc.GetService() {
+		s := &breakerService{
+			Name:  svc.GetName(),
+			Lower: strings.ToLower(svc.GetName()[:1]) + svc.GetName()[1:],
+			Doc:   docs.Godoc(qualify(desc.GetPackage(), svc.GetName())),
+		}
+		for _, m := range svc.GetMethod() {
+			if m.GetClientStreaming() || m.GetServerStreaming() {
+				continue
+			}
+			settings, err := breakerSettings(options.MethodCircuitBreaker(m), hdr)
+			if err != nil {
+				return "", fmt.Errorf("%s.%s: %v", svc.GetName(), m.GetName(), err)
+			}
+			s.Methods = append(s.Methods, &breakerMethod{
+				Name:     m.GetName(),
+				Input:    imports.goTypeName(idx, m.GetInputType()),
+				Output:   imports.goTypeName(idx, m.GetOutputType()),
+				Settings: settings,
+			})
+		}
+		if len(s.Methods) == 0 {
+			continue
+		}
+		if err := breakerTmpl.Execute(body, s); err != nil {
+			return "", err
+		}
+	}
+	if body.Len() == 0 {
+		return "", nil
+	}
+
+	if err := hdrTmpl.Execute(w, hdr); err != nil {
+		log.Fatal(err)
+	}
+	body.WriteTo(w)
+
+	return w.String(), nil
+}
+
+// breakerSettings returns the breaker.Settings composite literal fields for
+// cb, recording the packages they need in hdr. A nil cb or unset fields
+// leave the runtime defaults.
+func breakerSettings(cb *options.CircuitBreaker, hdr *header) (string, error) {
+	if cb == nil {
+		return "", nil
+	}
+	var fields []string
+	if cb.FailureThreshold != nil {
+		if cb.GetFailureThreshold() == 0 {
+			return "", fmt.Errorf("failure_threshold must be positive")
+		}
+		fields = append(fields, fmt.Sprintf("FailureThreshold: %d", cb.GetFailureThreshold()))
+	}
+	if cb.OpenTimeout != nil {
+		d, err := time.ParseDuration(cb.GetOpenTimeout())
+		if err != nil {
+			return "", err
+		}
+		if d <= 0 {
+			return "", fmt.Errorf("open_timeout must be positive")
+		}
+		fields = append(fields, "OpenTimeout: "+goDuration(d))
+		hdr.Time = true
+	}
+	return strings.Join(fields, ", "), nil
+}
+
+type header struct {
+	Source  string
+	GoPkg   string
+	Imports map[string]string
+	Time    bool
+}
+
+type breakerService struct {
+	Name  string
+	Lower string
+	// Doc is the comment of the service, see protodoc.Index.Godoc.
+	Doc     string
+	Methods []*breakerMethod
+}
+
+type breakerMethod struct {
+	Name     string
+	Input    string
+	Output   string
+	Settings string
+}
+
+// qualify returns the full proto name of name, declared in scope.
+func qualify(scope, name string) string {
+	if scope == "" {
+		return name
+	}
+	return scope + "." + name
+}
+
+// typeIndex maps fully-qualified proto type names (".pkg.Msg") to their
+// descriptors and declaring files for every file in the request.
+type typeIndex struct {
+	messages map[string]*descriptor.DescriptorProto
+	enums    map[string]*descriptor.EnumDescriptorProto
+	files    map[string]*descriptor.FileDescriptorProto
+}
+
+func newTypeIndex(files []*descriptor.FileDescriptorProto) *typeIndex {
+	idx := &typeIndex{
+		messages: make(map[string]*descriptor.DescriptorProto),
+		enums:    make(map[string]*descriptor.EnumDescriptorProto),
+		files:    make(map[string]*descriptor.FileDescriptorProto),
+	}
+	for _, f := range files {
+		prefix := ""
+		if f.GetPackage() != "" {
+			prefix = "." + f.GetPackage()
+		}
+		idx.addMessages(f, prefix, f.GetMessageType())
+		idx.addEnums(f, prefix, f.GetEnumType())
+	}
+	return idx
+}
+
+func (idx *typeIndex) addMessages(f *descriptor.FileDescriptorProto, prefix string, msgs []*descriptor.DescriptorProto) {
+	for _, m := range msgs {
+		name := prefix + "." + m.GetName()
+		idx.messages[name] = m
+		idx.files[name] = f
+		idx.addMessages(f, name, m.GetNestedType())
+		idx.addEnums(f, name, m.GetEnumType())
+	}
+}
+
+func (idx *typeIndex) addEnums(f *descriptor.FileDescriptorProto, prefix string, enums []*descriptor.EnumDescriptorProto) {
+	for _, e := range enums {
+		name := prefix + "." + e.GetName()
+		idx.enums[name] = e
+		idx.files[name] = f
+	}
+}
+
+// isMap reports whether field is a map field.
+func (idx *typeIndex) isMap(field *descriptor.FieldDescriptorProto) bool {
+	if field.GetType() != descriptor.FieldDescriptorProto_TYPE_MESSAGE {
+		return false
+	}
+	return idx.messages[field.GetTypeName()].GetOptions().GetMapEntry()
+}
+
+// goImportPath returns the import path of the Go package generated from f,
+// or "" if go_package does not name one.
+func goImportPath(f *descriptor.FileDescriptorProto) string {
+	gopkg := f.GetOptions().GetGoPackage()
+	if sc := strings.IndexByte(gopkg, ';'); sc >= 0 {
+		gopkg = gopkg[:sc]
+	}
+	if !strings.Contains(gopkg, "/") {
+		return ""
+	}
+	return gopkg
+}
+
+// importSet records the Go packages referenced by the code generated for
+// one file, keyed by import path.
+type importSet struct {
+	self  string
+	names map[string]string
+}
+
+func newImportSet(f *descriptor.FileDescriptorProto) *importSet {
+	return &importSet{self: goImportPath(f), names: make(map[string]string)}
+}
+
+// goTypeName returns the Go name of the message or enum typeName, qualified
+// with its package if it is declared outside the generated package.
+func (s *importSet) goTypeName(idx *typeIndex, typeName string) string {
+	name := localTypeName(typeName)
+	f := idx.files[typeName]
+	path := goImportPath(f)
+	if f == nil || path == "" || path == s.self {
+		return name
+	}
+	pkg := defaultGoPackageName(f)
+	s.names[path] = pkg
+	return pkg + "." + name
+}
+
+// localTypeName returns the Go name of a type declared in the file being
+// generated, e.g. ".pkg.Outer.Inner" becomes "Outer_Inner".
+func localTypeName(typeName string) string {
+	parts := strings.Split(strings.TrimPrefix(typeName, "."), ".")
+	var names []string
+	for _, p := range parts {
+		if len(p) > 0 && p[0] >= 'A' && p[0] <= 'Z' {
+			names = append(names, p)
+		}
+	}
+	return strings.Join(names, "_")
+}
+
+// goDuration returns a Go expression for d in the largest unit that
+// represents it exactly, e.g. "250 * time.Millisecond".
+func goDuration(d time.Duration) string {
+	for _, u := range []struct {
+		unit time.Duration
+		name string
+	}{
+		{time.Hour, "time.Hour"},
+		{time.Minute, "time.Minute"},
+		{time.Second, "time.Second"},
+		{time.Millisecond, "time.Millisecond"},
+		{time.Microsecond, "time.Microsecond"},
+	} {
+		if d%u.unit == 0 {
+			return fmt.Sprintf("%d * %s", d/u.unit, u.name)
+		}
+	}
+	return fmt.Sprintf("%d * time.Nanosecond", d)
+}
+
+// sanitizePackageName replaces unallowed character in package name
+// with allowed character.
+func sanitizePackageName(pkgName string) string {
+	pkgName = strings.Replace(pkgName, ".", "_", -1)
+	pkgName = strings.Replace(pkgName, "-", "_", -1)
+	return pkgName
+}
+
+// defaultGoPackageName returns the default go package name to be used for go files generated from "f".
+// You might need to use an unique alias for the package when you import it.  Use ReserveGoPackageAlias to get a unique alias.
+func defaultGoPackageName(f *descriptor.FileDescriptorProto) string {
+	name := packageIdentityName(f)
+	return sanitizePackageName(name)
+}
+
+// packageIdentityName returns the identity of packages.
+// protoc-gen-grpc-gateway rejects CodeGenerationRequests which contains more than one packages
+// as protoc-gen-go does.
+func packageIdentityName(f *descriptor.FileDescriptorProto) string {
+	if f.Options != nil && f.Options.GoPackage != nil {
+		gopkg := f.Options.GetGoPackage()
+		idx := strings.LastIndex(gopkg, "/")
+		if idx < 0 {
+			gopkg = gopkg[idx+1:]
+		}
+
+		gopkg = gopkg[idx+1:]
+		// package name is overrided with the string after the
+		// ';' character
+		sc := strings.IndexByte(gopkg, ';')
+		if sc < 0 {
+			return sanitizePackageName(gopkg)
+
+		}
+		return sanitizePackageName(gopkg[sc+1:])
+	}
+
+	if f.Package == nil {
+		base := filepath.Base(f.GetName())
+		ext := filepath.Ext(base)
+		return strings.TrimSuffix(base, ext)
+	}
+	return f.GetPackage()
+}
+
+func emitFiles(out []*plugin.CodeGeneratorResponse_File) {
+	emitResp(&plugin.CodeGeneratorResponse{File: out})
+}
+
+func emitError(err error) {
+	emitResp(&plugin.CodeGeneratorResponse{Error: proto.String(err.Error())})
+}
+
+func emitResp(resp *plugin.CodeGeneratorResponse) {
+	buf, err := proto.Marshal(resp)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if _, err := os.Stdout.Write(buf); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,452 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+
+	"github.com/f4tq/protoc-go-plugins/options"
+	"github.com/f4tq/protoc-go-plugins/protodoc"
+)
+
+var (
+	hdrTmpl = template.Must(template.New("header").Parse(`
+// Code generated by protoc-gen-go-clientcache. DO NOT EDIT.
+// source: {{.Source}}
+
+package {{.GoPkg}}
+
+import (
+    "context"
+    "time"
+
+    "github.com/f4tq/protoc-go-plugins/runtime/clientcache"
+    "google.golang.org/grpc"
+{{- range $path, $name := .Imports}}
+    {{$name}} "{{$path}}"
+{{- end}}
+)
+`))
+
+	cacheTmpl = template.Must(template.New("cache").Parse(`
+// {{.Name}}CacheClient is a {{.Name}}Client caching the responses of the
+// methods with a cache_ttl in Cache, and invalidating them after calls of
+// the methods listing them in cache_invalidates. Calls with call options
+// bypass the cache. Cache errors fail open.{{.Doc}}
+type {{.Name}}CacheClient struct {
+    {{.Name}}Client
+    Cache clientcache.Cache
+}
+
+// New{{.Name}}CacheClient returns a {{.Name}}CacheClient sending its calls
+// through next.{{.Doc}}
+func New{{.Name}}CacheClient(next {{.Name}}Client, cache clientcache.Cache) *{{.Name}}CacheClient {
+    return &{{.Name}}CacheClient{ {{.Name}}Client: next, Cache: cache}
+}
+{{range .Cached}}
+func (c *{{$.Name}}CacheClient) {{.Name}}(ctx context.Context, in *{{.Input}}, opts ...grpc.CallOption) (*{{.Output}}, error) {
+    if len(opts) > 0 {
+        return c.{{$.Name}}Client.{{.Name}}(ctx, in, opts...)
+    }
+    key, err := clientcache.Key({{printf "%q" .Path}}, in)
+    if err != nil {
+        return c.{{$.Name}}Client.{{.Name}}(ctx, in)
+    }
+    out := new({{.Output}})
+    if clientcache.Lookup(ctx, c.Cache, key, out) {
+        return out, nil
+    }
+    out, err = c.{{$.Name}}Client.{{.Name}}(ctx, in)
+    if err != nil {
+        return nil, err
+    }
+    clientcache.Store(ctx, c.Cache, key, out, {{.TTL}})
+    return out, nil
+}
+
+// Invalidate{{.Name}} drops the cached responses of {{.Name}}.{{.Doc}}
+func (c *{{$.Name}}CacheClient) Invalidate{{.Name}}(ctx context.Context) error {
+    return c.Cache.Invalidate(ctx, {{printf "%q" .Path}})
+}
+{{end}}
+{{- range .Mutating}}
+func (c *{{$.Name}}CacheClient) {{.Name}}(ctx context.Context, in *{{.Input}}, opts ...grpc.CallOption) (*{{.Output}}, error) {
+    out, err := c.{{$.Name}}Client.{{.Name}}(ctx, in, opts...)
+    if err != nil {
+        return nil, err
+    }
+{{- range .Invalidates}}
+    c.Invalidate{{.}}(ctx)
+{{- end}}
+    return out, nil
+}
+{{end}}
+`))
+)
+
+func main() {
+	input, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	req := new(plugin.CodeGeneratorRequest)
+	if err = proto.Unmarshal(input, req); err != nil {
+		log.Fatal(err)
+	}
+
+	out, err := generate(req)
+
+	if err != nil {
+		emitError(err)
+		return
+	}
+
+	emitFiles(out)
+}
+
+func generate(req *plugin.CodeGeneratorRequest) ([]*plugin.CodeGeneratorResponse_File, error) {
+	var files []*plugin.CodeGeneratorResponse_File
+	idx := newTypeIndex(req.GetProtoFile())
+	docs := protodoc.New(req.GetProtoFile())
+	genFileNames := make(map[string]bool)
+	for _, n := range req.FileToGenerate {
+		genFileNames[n] = true
+	}
+	for _, desc := range req.GetProtoFile() {
+		name := desc.GetName()
+		if _, ok := genFileNames[name]; !ok {
+			// Only emit output for files present in req.FileToGenerate.
+			continue
+		}
+		code, err := genCode(desc, idx, docs)
+		if err != nil {
+			return nil, err
+		}
+		if code == "" {
+			// No method is cached.
+			continue
+		}
+		formatted, err := format.Source([]byte(code))
+		if err != nil {
+			log.Printf("%v: %s", err, code)
+			return nil, err
+		}
+
+		ext := filepath.Ext(name)
+		base := strings.TrimSuffix(name, ext)
+		output := fmt.Sprintf("%s.pb.clientcache.go", base)
+		files = append(files, &plugin.CodeGeneratorResponse_File{
+			Name:    proto.String(output),
+			Content: proto.String(string(formatted)),
+		})
+	}
+
+	return files, nil
+}
+
+func genCode(desc *descriptor.FileDescriptorProto, idx *typeIndex, docs *protodoc.Index) (string, error) {
+	w := bytes.NewBuffer(nil)
+	imports := newImportSet(desc)
+	body := bytes.NewBuffer(nil)
+	for _, svc := range desc.GetService() {
+		fullName := svc.GetName()
+		if desc.GetPackage() != "" {
+			fullName = desc.GetPackage() + "." + svc.GetName()
+		}
+		s := &cacheService{Name: svc.GetName(), Doc: docs.Godoc(fullName)}
+		cached := make(map[string]bool)
+		for _, m := range svc.GetMethod() {
+			ttl := options.CacheTTL(m)
+			if ttl == "" {
+				continue
+			}
+			where := fullName + "." + m.GetName()
+			if m.GetClientStreaming() || m.GetServerStreaming() {
+				return "", fmt.Errorf("%s: only unary methods can be cached", where)
+			}
+			d, err := time.ParseDuration(ttl)
+			if err != nil {
+				return "", fmt.Errorf("%s: cache_ttl: %v", where, err)
+			}
+			if d <= 0 {
+				return "", fmt.Errorf("%s: cache_ttl must be positive", where)
+			}
+			cached[m.GetName()] = true
+			s.Cached = append(s.Cached, &cacheMethod{
+				Name:   m.GetName(),
+				Path:   "/" + fullName + "/" + m.GetName(),
+				Input:  imports.goTypeName(idx, m.GetInputType()),
+				Output: imports.goTypeName(idx, m.GetOutputType()),
+				TTL:    goDuration(d),
+				Doc:    docs.Godoc(where),
+			})
+		}
+		for _, m := range svc.GetMethod() {
+			names := options.CacheInvalidates(m)
+			if len(names) == 0 {
+				continue
+			}
+			where := fullName + "." + m.GetName()
+			if m.GetClientStreaming() || m.GetServerStreaming() {
+				return "", fmt.Errorf("%s: cache_invalidates is only supported on unary methods", where)
+			}
+			if cached[m.GetName()] {
+				return "", fmt.Errorf("%s: a cached method cannot invalidate", where)
+			}
+			for _, name := range names {
+				if !cached[name] {
+					return "", fmt.Errorf("%s: cache_invalidates: %s has no cache_ttl", where, name)
+				}
+			}
+			s.Mutating = append(s.Mutating, &cacheMethod{
+				Name:        m.GetName(),
+				Input:       imports.goTypeName(idx, m.GetInputType()),
+				Output:      imports.goTypeName(idx, m.GetOutputType()),
+				Invalidates: names,
+			})
+		}
+		if len(s.Cached) == 0 {
+			continue
+		}
+		if err := cacheTmpl.Execute(body, s); err != nil {
+			return "", err
+		}
+	}
+	if body.Len() == 0 {
+		return "", nil
+	}
+
+	hdr := &header{
+		Source:  desc.GetName(),
+		GoPkg:   defaultGoPackageName(desc),
+		Imports: imports.names,
+	}
+	if err := hdrTmpl.Execute(w, hdr); err != nil {
+		log.Fatal(err)
+	}
+	body.WriteTo(w)
+
+	return w.String(), nil
+}
+
+type header struct {
+	Source  string
+	GoPkg   string
+	Imports map[string]string
+}
+
+type cacheService struct {
+	Name string
+	// Doc is the comment of the service, see protodoc.Index.Godoc.
+	Doc      string
+	Cached   []*cacheMethod
+	Mutating []*cacheMethod
+}
+
+type cacheMethod struct {
+	Name        string
+	Path        string
+	Input       string
+	Output      string
+	TTL         string
+	Invalidates []string
+	// Doc is the comment of the method.
+	Doc string
+}
+
+// typeIndex maps fully-qualified proto type names (".pkg.Msg") to their
+// descriptors and declaring files for every file in the request.
+type typeIndex struct {
+	messages map[string]*descriptor.DescriptorProto
+	enums    map[string]*descriptor.EnumDescriptorProto
+	files    map[string]*descriptor.FileDescriptorProto
+}
+
+func newTypeIndex(files []*descriptor.FileDescriptorProto) *typeIndex {
+	idx := &typeIndex{
+		messages: make(map[string]*descriptor.DescriptorProto),
+		enums:    make(map[string]*descriptor.EnumDescriptorProto),
+		files:    make(map[string]*descriptor.FileDescriptorProto),
+	}
+	for _, f := range files {
+		prefix := ""
+		if f.GetPackage() != "" {
+			prefix = "." + f.GetPackage()
+		}
+		idx.addMessages(f, prefix, f.GetMessageType())
+		idx.addEnums(f, prefix, f.GetEnumType())
+	}
+	return idx
+}
+
+func (idx *typeIndex) addMessages(f *descriptor.FileDescriptorProto, prefix string, msgs []*descriptor.DescriptorProto) {
+	for _, m := range msgs {
+		name := prefix + "." + m.GetName()
+		idx.messages[name] = m
+		idx.files[name] = f
+		idx.addMessages(f, name, m.GetNestedType())
+		idx.addEnums(f, name, m.GetEnumType())
+	}
+}
+
+func (idx *typeIndex) addEnums(f *descriptor.FileDescriptorProto, prefix string, enums []*descriptor.EnumDescriptorProto) {
+	for _, e := range enums {
+		name := prefix + "." + e.GetName()
+		idx.enums[name] = e
+		idx.files[name] = f
+	}
+}
+
+// isMap reports whether field is a map field.
+func (idx *typeIndex) isMap(field *descriptor.FieldDescriptorProto) bool {
+	if field.GetType() != descriptor.FieldDescriptorProto_TYPE_MESSAGE {
+		return false
+	}
+	return idx.messages[field.GetTypeName()].GetOptions().GetMapEntry()
+}
+
+// goImportPath returns the import path of the Go package generated from f,
+// or "" if go_package does not name one.
+func goImportPath(f *descriptor.FileDescriptorProto) string {
+	gopkg := f.GetOptions().GetGoPackage()
+	if sc := strings.IndexByte(gopkg, ';'); sc >= 0 {
+		gopkg = gopkg[:sc]
+	}
+	if !strings.Contains(gopkg, "/") {
+		return ""
+	}
+	return gopkg
+}
+
+// importSet records the Go packages referenced by the code generated for
+// one file, keyed by import path.
+type importSet struct {
+	self  string
+	names map[string]string
+}
+
+func newImportSet(f *descriptor.FileDescriptorProto) *importSet {
+	return &importSet{self: goImportPath(f), names: make(map[string]string)}
+}
+
+// goTypeName returns the Go name of the message or enum typeName, qualified
+// with its package if it is declared outside the generated package.
+func (s *importSet) goTypeName(idx *typeIndex, typeName string) string {
+	name := localTypeName(typeName)
+	f := idx.files[typeName]
+	path := goImportPath(f)
+	if f == nil || path == "" || path == s.self {
+		return name
+	}
+	pkg := defaultGoPackageName(f)
+	s.names[path] = pkg
+	return pkg + "." + name
+}
+
+// localTypeName returns the Go name of a type declared in the file being
+// generated, e.g. ".pkg.Outer.Inner" becomes "Outer_Inner".
+func localTypeName(typeName string) string {
+	parts := strings.Split(strings.TrimPrefix(typeName, "."), ".")
+	var names []string
+	for _, p := range parts {
+		if len(p) > 0 && p[0] >= 'A' && p[0] <= 'Z' {
+			names = append(names, p)
+		}
+	}
+	return strings.Join(names, "_")
+}
+
+// goDuration returns a Go expression for d in the largest unit that
+// represents it exactly, e.g. "250 * time.Millisecond".
+func goDuration(d time.Duration) string {
+	for _, u := range []struct {
+		unit time.Duration
+		name string
+	}{
+		{time.Hour, "time.Hour"},
+		{time.Minute, "time.Minute"},
+		{time.Second, "time.Second"},
+		{time.Millisecond, "time.Millisecond"},
+		{time.Microsecond, "time.Microsecond"},
+	} {
+		if d%u.unit == 0 {
+			return fmt.Sprintf("%d * %s", d/u.unit, u.name)
+		}
+	}
+	return fmt.Sprintf("%d * time.Nanosecond", d)
+}
+
+// sanitizePackageName replaces unallowed character in package name
+// with allowed character.
+func sanitizePackageName(pkgName string) string {
+	pkgName = strings.Replace(pkgName, ".", "_", -1)
+	pkgName = strings.Replace(pkgName, "-", "_", -1)
+	return pkgName
+}
+
+// defaultGoPackageName returns the default go package name to be used for go files generated from "f".
+// You might need to use an unique alias for the package when you import it.  Use ReserveGoPackageAlias to get a unique alias.
+func defaultGoPackageName(f *descriptor.FileDescriptorProto) string {
+	name := packageIdentityName(f)
+	return sanitizePackageName(name)
+}
+
+// packageIdentityName returns the identity of packages.
+// protoc-gen-grpc-gateway rejects CodeGenerationRequests which contains more than one packages
+// as protoc-gen-go does.
+func packageIdentityName(f *descriptor.FileDescriptorProto) string {
+	if f.Options != nil && f.Options.GoPackage != nil {
+		gopkg := f.Options.GetGoPackage()
+		idx := strings.LastIndex(gopkg, "/")
+		if idx < 0 {
+			gopkg = gopkg[idx+1:]
+		}
+
+		gopkg = gopkg[idx+1:]
+		// package name is overrided with the string after the
+		// ';' character
+		sc := strings.IndexByte(gopkg, ';')
+		if sc < 0 {
+			return sanitizePackageName(gopkg)
+
+		}
+		return sanitizePackageName(gopkg[sc+1:])
+	}
+
+	if f.Package == nil {
+		base := filepath.Base(f.GetName())
+		ext := filepath.Ext(base)
+		return strings.TrimSuffix(base, ext)
+	}
+	return f.GetPackage()
+}
+
+func emitFiles(out []*plugin.CodeGeneratorResponse_File) {
+	emitResp(&plugin.CodeGeneratorResponse{File: out})
+}
+
+func emitError(err error) {
+	emitResp(&plugin.CodeGeneratorResponse{Error: proto.String(err.Error())})
+}
+
+func emitResp(resp *plugin.CodeGeneratorResponse) {
+	buf, err := proto.Marshal(resp)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if _, err := os.Stdout.Write(buf); err != nil {
+		log.Fatal(err)
+	}
+}
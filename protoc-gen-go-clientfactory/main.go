@@ -0,0 +1,256 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+
+	"github.com/f4tq/protoc-go-plugins/protodoc"
+)
+
+var (
+	hdrTmpl = template.Must(template.New("header").Parse(`
+// Code generated by protoc-gen-go-clientfactory. DO NOT EDIT.
+// source: {{.Source}}
+
+package {{.GoPkg}}
+
+import (
+    "github.com/f4tq/protoc-go-plugins/runtime/clientpool"
+    "google.golang.org/grpc"
+)
+`))
+
+	factoryTmpl = template.Must(template.New("factory").Parse(`
+// Full method names of {{.FullName}}, the keys of
+// clientpool.Pool.CallOptions.{{.Doc}}
+const (
+{{- range .Methods}}
+    {{$.Name}}{{.Name}}Method = {{printf "%q" .Path}}
+{{- end}}
+)
+
+// {{.Name}}ClientFactory creates {{.Name}}Clients sharing a pool of lazily
+// dialed connections.{{.Doc}}
+type {{.Name}}ClientFactory struct {
+    Pool *clientpool.Pool
+}
+
+// New{{.Name}}ClientFactory returns a {{.Name}}ClientFactory spreading calls
+// over size connections to target, dialed with opts.{{.Doc}}
+func New{{.Name}}ClientFactory(target string, size int, opts ...grpc.DialOption) *{{.Name}}ClientFactory {
+    p := clientpool.New(target, opts...)
+    p.Size = size
+    return &{{.Name}}ClientFactory{Pool: p}
+}
+{{range .Methods}}
+// With{{.Name}}CallOptions sets the default call options of {{.Name}} and
+// returns f. Options passed to a call are applied after them.{{.Doc}}
+func (f *{{$.Name}}ClientFactory) With{{.Name}}CallOptions(opts ...grpc.CallOption) *{{$.Name}}ClientFactory {
+    f.Pool.SetCallOptions({{$.Name}}{{.Name}}Method, opts...)
+    return f
+}
+{{end}}
+// Client returns a {{.Name}}Client sending its calls through the pool.
+func (f *{{.Name}}ClientFactory) Client() {{.Name}}Client {
+    return New{{.Name}}Client(f.Pool)
+}
+
+// Close closes the pooled connections.
+func (f *{{.Name}}ClientFactory) Close() error {
+    return f.Pool.Close()
+}
+`))
+)
+
+func main() {
+	input, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	req := new(plugin.CodeGeneratorRequest)
+	if err = proto.Unmarshal(input, req); err != nil {
+		log.Fatal(err)
+	}
+
+	out, err := generate(req)
+
+	if err != nil {
+		emitError(err)
+		return
+	}
+
+	emitFiles(out)
+}
+
+func generate(req *plugin.CodeGeneratorRequest) ([]*plugin.CodeGeneratorResponse_File, error) {
+	var files []*plugin.CodeGeneratorResponse_File
+	docs := protodoc.New(req.GetProtoFile())
+	genFileNames := make(map[string]bool)
+	for _, n := range req.FileToGenerate {
+		genFileNames[n] = true
+	}
+	for _, desc := range req.GetProtoFile() {
+		name := desc.GetName()
+		if _, ok := genFileNames[name]; !ok {
+			// Only emit output for files present in req.FileToGenerate.
+			continue
+		}
+		code, err := genCode(desc, docs)
+		if err != nil {
+			return nil, err
+		}
+		if code == "" {
+			// No services.
+			continue
+		}
+		formatted, err := format.Source([]byte(code))
+		if err != nil {
+			log.Printf("%v: %s", err, code)
+			return nil, err
+		}
+
+		ext := filepath.Ext(name)
+		base := strings.TrimSuffix(name, ext)
+		output := fmt.Sprintf("%s.pb.clientfactory.go", base)
+		files = append(files, &plugin.CodeGeneratorResponse_File{
+			Name:    proto.String(output),
+			Content: proto.String(string(formatted)),
+		})
+	}
+
+	return files, nil
+}
+
+func genCode(desc *descriptor.FileDescriptorProto, docs *protodoc.Index) (string, error) {
+	w := bytes.NewBuffer(nil)
+	body := bytes.NewBuffer(nil)
+	for _, svc := range desc.GetService() {
+		if len(svc.GetMethod()) == 0 {
+			continue
+		}
+		fullName := svc.GetName()
+		if desc.GetPackage() != "" {
+			fullName = desc.GetPackage() + "." + svc.GetName()
+		}
+		s := &factoryService{Name: svc.GetName(), FullName: fullName, Doc: docs.Godoc(fullName)}
+		for _, m := range svc.GetMethod() {
+			s.Methods = append(s.Methods, &factoryMethod{
+				Name: m.GetName(),
+				Path: "/" + fullName + "/" + m.GetName(),
+				Doc:  docs.Godoc(fullName + "." + m.GetName()),
+			})
+		}
+		if err := factoryTmpl.Execute(body, s); err != nil {
+			return "", err
+		}
+	}
+	if body.Len() == 0 {
+		return "", nil
+	}
+
+	hdr := &header{
+		Source: desc.GetName(),
+		GoPkg:  defaultGoPackageName(desc),
+	}
+	if err := hdrTmpl.Execute(w, hdr); err != nil {
+		log.Fatal(err)
+	}
+	body.WriteTo(w)
+
+	return w.String(), nil
+}
+
+type header struct {
+	Source string
+	GoPkg  string
+}
+
+type factoryService struct {
+	Name     string
+	FullName string
+	// Doc is the comment of the service, see protodoc.Index.Godoc.
+	Doc     string
+	Methods []*factoryMethod
+}
+
+type factoryMethod struct {
+	Name string
+	Path string
+	// Doc is the comment of the method.
+	Doc string
+}
+
+// sanitizePackageName replaces unallowed character in package name
+// with allowed character.
+func sanitizePackageName(pkgName string) string {
+	pkgName = strings.Replace(pkgName, ".", "_", -1)
+	pkgName = strings.Replace(pkgName, "-", "_", -1)
+	return pkgName
+}
+
+// defaultGoPackageName returns the default go package name to be used for go files generated from "f".
+// You might need to use an unique alias for the package when you import it.  Use ReserveGoPackageAlias to get a unique alias.
+func defaultGoPackageName(f *descriptor.FileDescriptorProto) string {
+	name := packageIdentityName(f)
+	return sanitizePackageName(name)
+}
+
+// packageIdentityName returns the identity of packages.
+// protoc-gen-grpc-gateway rejects CodeGenerationRequests which contains more than one packages
+// as protoc-gen-go does.
+func packageIdentityName(f *descriptor.FileDescriptorProto) string {
+	if f.Options != nil && f.Options.GoPackage != nil {
+		gopkg := f.Options.GetGoPackage()
+		idx := strings.LastIndex(gopkg, "/")
+		if idx < 0 {
+			gopkg = gopkg[idx+1:]
+		}
+
+		gopkg = gopkg[idx+1:]
+		// package name is overrided with the string after the
+		// ';' character
+		sc := strings.IndexByte(gopkg, ';')
+		if sc < 0 {
+			return sanitizePackageName(gopkg)
+
+		}
+		return sanitizePackageName(gopkg[sc+1:])
+	}
+
+	if f.Package == nil {
+		base := filepath.Base(f.GetName())
+		ext := filepath.Ext(base)
+		return strings.TrimSuffix(base, ext)
+	}
+	return f.GetPackage()
+}
+
+func emitFiles(out []*plugin.CodeGeneratorResponse_File) {
+	emitResp(&plugin.CodeGeneratorResponse{File: out})
+}
+
+func emitError(err error) {
+	emitResp(&plugin.CodeGeneratorResponse{Error: proto.String(err.Error())})
+}
+
+func emitResp(resp *plugin.CodeGeneratorResponse) {
+	buf, err := proto.Marshal(resp)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if _, err := os.Stdout.Write(buf); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,501 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+
+	"github.com/f4tq/protoc-go-plugins/goname"
+)
+
+var (
+	hdrTmpl = template.Must(template.New("header").Parse(`
+// Code generated by protoc-gen-go-clone. DO NOT EDIT.
+// source: {{.Source}}
+
+package {{.GoPkg}}
+
+import (
+{{- range .Std}}
+    "{{.}}"
+{{- end}}
+{{if .Imports}}
+{{- end}}
+{{- range $path, $name := .Imports}}
+    {{$name}} "{{$path}}"
+{{- end}}
+)
+`))
+
+	cloneTmpl = template.Must(template.New("clone").Parse(`
+// Clone returns a deep copy of m, including its unknown fields.
+func (m *{{.Name}}) Clone() *{{.Name}} {
+    if m == nil {
+        return nil
+    }
+    c := &{{.Name}}{
+{{- range .Plain}}
+        {{.}}: m.{{.}},
+{{- end}}
+        XXX_unrecognized: bytes.Clone(m.XXX_unrecognized),
+    }
+{{- range .Deep}}
+{{.}}
+{{- end}}
+    return c
+}
+
+// DeepCopy is Clone under the name Kubernetes deepcopy-gen uses.
+func (m *{{.Name}}) DeepCopy() *{{.Name}} {
+    return m.Clone()
+}
+
+// DeepCopyInto copies m into out. m must not be nil.
+func (m *{{.Name}}) DeepCopyInto(out *{{.Name}}) {
+    *out = *m.Clone()
+}
+`))
+)
+
+func main() {
+	input, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	req := new(plugin.CodeGeneratorRequest)
+	if err = proto.Unmarshal(input, req); err != nil {
+		log.Fatal(err)
+	}
+
+	out, err := generate(req)
+
+	if err != nil {
+		emitError(err)
+		return
+	}
+
+	emitFiles(out)
+}
+
+func generate(req *plugin.CodeGeneratorRequest) ([]*plugin.CodeGeneratorResponse_File, error) {
+	var files []*plugin.CodeGeneratorResponse_File
+	idx := newTypeIndex(req.GetProtoFile())
+	genFileNames := make(map[string]bool)
+	for _, n := range req.FileToGenerate {
+		genFileNames[n] = true
+	}
+	for _, desc := range req.GetProtoFile() {
+		name := desc.GetName()
+		if _, ok := genFileNames[name]; !ok {
+			// Only emit output for files present in req.FileToGenerate.
+			continue
+		}
+		code, err := genCode(desc, idx, genFileNames)
+		if err != nil {
+			return nil, err
+		}
+		if code == "" {
+			// The file declares no messages.
+			continue
+		}
+		formatted, err := format.Source([]byte(code))
+		if err != nil {
+			log.Printf("%v: %s", err, code)
+			return nil, err
+		}
+
+		ext := filepath.Ext(name)
+		base := strings.TrimSuffix(name, ext)
+		output := fmt.Sprintf("%s.pb.clone.go", base)
+		files = append(files, &plugin.CodeGeneratorResponse_File{
+			Name:    proto.String(output),
+			Content: proto.String(string(formatted)),
+		})
+	}
+
+	return files, nil
+}
+
+func genCode(desc *descriptor.FileDescriptorProto, idx *typeIndex, genFileNames map[string]bool) (string, error) {
+	w := bytes.NewBuffer(nil)
+	g := &cloneGen{
+		idx:     idx,
+		imports: newImportSet(desc),
+		gen:     genFileNames,
+		proto3:  desc.GetSyntax() == "proto3",
+		// bytes clones the unknown fields of every message.
+		std: map[string]bool{"bytes": true},
+	}
+	body := bytes.NewBuffer(nil)
+	if err := g.messages(body, "", desc.GetMessageType()); err != nil {
+		return "", err
+	}
+	if body.Len() == 0 {
+		return "", nil
+	}
+
+	hdr := &header{
+		Source:  desc.GetName(),
+		GoPkg:   defaultGoPackageName(desc),
+		Imports: g.imports.names,
+	}
+	for path := range g.std {
+		hdr.Std = append(hdr.Std, path)
+	}
+	sort.Strings(hdr.Std)
+	if err := hdrTmpl.Execute(w, hdr); err != nil {
+		log.Fatal(err)
+	}
+	body.WriteTo(w)
+
+	return w.String(), nil
+}
+
+type cloneGen struct {
+	idx     *typeIndex
+	imports *importSet
+	gen     map[string]bool
+	proto3  bool
+	// std records the standard packages the copies use.
+	std map[string]bool
+}
+
+// messages writes the Clone methods of msgs and of the messages nested in
+// them. prefix is the Go name of the enclosing message plus "_".
+func (g *cloneGen) messages(w *bytes.Buffer, prefix string, msgs []*descriptor.DescriptorProto) error {
+	for _, msg := range msgs {
+		if msg.GetOptions().GetMapEntry() {
+			continue
+		}
+		name := prefix + msg.GetName()
+		m := &cloneMessage{Name: name}
+		goNames := goname.Fields(msg)
+		done := make(map[int32]bool)
+		for _, field := range msg.GetField() {
+			if field.OneofIndex != nil && !field.GetProto3Optional() {
+				i := field.GetOneofIndex()
+				if !done[i] {
+					done[i] = true
+					m.Deep = append(m.Deep, g.oneof(name, msg, i))
+				}
+				continue
+			}
+			goName := goNames[field.GetName()]
+			if deep := g.field(field, "c."+goName, "m."+goName); deep != "" {
+				m.Deep = append(m.Deep, deep)
+			} else {
+				m.Plain = append(m.Plain, goName)
+			}
+		}
+		if err := cloneTmpl.Execute(w, m); err != nil {
+			return err
+		}
+		if err := g.messages(w, name+"_", msg.GetNestedType()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// field returns the statements copying field from src to dst, or "" if an
+// assignment copies it.
+func (g *cloneGen) field(field *descriptor.FieldDescriptorProto, dst, src string) string {
+	switch {
+	case g.idx.isMap(field):
+		value := g.idx.messages[field.GetTypeName()].GetField()[1]
+		if clone := g.cloneValue(value, "v"); clone != "" {
+			return fmt.Sprintf("if %s != nil {\n%s = make(%s, len(%s))\nfor k, v := range %s {\n%s[k] = %s\n}\n}",
+				src, dst, g.mapType(field), src, src, dst, clone)
+		}
+		g.std["maps"] = true
+		return fmt.Sprintf("%s = maps.Clone(%s)", dst, src)
+	case field.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REPEATED:
+		if clone := g.cloneValue(field, "v"); clone != "" {
+			return fmt.Sprintf("if %s != nil {\n%s = make([]%s, len(%s))\nfor i, v := range %s {\n%s[i] = %s\n}\n}",
+				src, dst, g.goType(field), src, src, dst, clone)
+		}
+		g.std["slices"] = true
+		return fmt.Sprintf("%s = slices.Clone(%s)", dst, src)
+	case field.GetType() == descriptor.FieldDescriptorProto_TYPE_MESSAGE,
+		field.GetType() == descriptor.FieldDescriptorProto_TYPE_GROUP,
+		field.GetType() == descriptor.FieldDescriptorProto_TYPE_BYTES:
+		return fmt.Sprintf("%s = %s", dst, g.cloneValue(field, src))
+	case field.GetProto3Optional() || !g.proto3:
+		// Scalars are pointers.
+		return fmt.Sprintf("if %s != nil {\nv := *%s\n%s = &v\n}", src, src, dst)
+	}
+	return ""
+}
+
+// cloneValue returns an expression copying the value v of field, or "" if
+// v can be assigned.
+func (g *cloneGen) cloneValue(field *descriptor.FieldDescriptorProto, v string) string {
+	switch field.GetType() {
+	case descriptor.FieldDescriptorProto_TYPE_BYTES:
+		g.std["bytes"] = true
+		return fmt.Sprintf("bytes.Clone(%s)", v)
+	case descriptor.FieldDescriptorProto_TYPE_MESSAGE, descriptor.FieldDescriptorProto_TYPE_GROUP:
+		if g.gen[g.idx.files[field.GetTypeName()].GetName()] {
+			return v + ".Clone()"
+		}
+		// Messages of other packages have no Clone method.
+		g.imports.names["github.com/golang/protobuf/proto"] = "proto"
+		return fmt.Sprintf("proto.Clone(%s).(%s)", v, g.goType(field))
+	}
+	return ""
+}
+
+// goType returns the Go type of a value of field.
+func (g *cloneGen) goType(field *descriptor.FieldDescriptorProto) string {
+	switch field.GetType() {
+	case descriptor.FieldDescriptorProto_TYPE_MESSAGE, descriptor.FieldDescriptorProto_TYPE_GROUP:
+		return "*" + g.imports.goTypeName(g.idx, field.GetTypeName())
+	case descriptor.FieldDescriptorProto_TYPE_ENUM:
+		return g.imports.goTypeName(g.idx, field.GetTypeName())
+	case descriptor.FieldDescriptorProto_TYPE_BYTES:
+		return "[]byte"
+	}
+	return scalarGoType(field.GetType())
+}
+
+// mapType returns the Go type of the map field.
+func (g *cloneGen) mapType(field *descriptor.FieldDescriptorProto) string {
+	entry := g.idx.messages[field.GetTypeName()]
+	return fmt.Sprintf("map[%s]%s", g.goType(entry.GetField()[0]), g.goType(entry.GetField()[1]))
+}
+
+// oneof returns the statements copying the oneof i of msg.
+func (g *cloneGen) oneof(msgName string, msg *descriptor.DescriptorProto, i int32) string {
+	goNames, wrappers := goname.Fields(msg), goname.Wrappers(msg)
+	oneof := goNames[msg.GetOneofDecl()[i].GetName()]
+	var b strings.Builder
+	fmt.Fprintf(&b, "switch v := m.%s.(type) {\n", oneof)
+	for _, field := range msg.GetField() {
+		if field.OneofIndex == nil || field.GetOneofIndex() != i || field.GetProto3Optional() {
+			continue
+		}
+		goName := goNames[field.GetName()]
+		value := "v." + goName
+		if clone := g.cloneValue(field, value); clone != "" {
+			value = clone
+		}
+		wrapper := msgName + "_" + wrappers[field.GetName()]
+		fmt.Fprintf(&b, "case *%s:\n", wrapper)
+		fmt.Fprintf(&b, "c.%s = &%s{%s: %s}\n", oneof, wrapper, goName, value)
+	}
+	b.WriteString("}")
+	return b.String()
+}
+
+// scalarGoType returns the Go type of the scalar type t.
+func scalarGoType(t descriptor.FieldDescriptorProto_Type) string {
+	switch t {
+	case descriptor.FieldDescriptorProto_TYPE_DOUBLE:
+		return "float64"
+	case descriptor.FieldDescriptorProto_TYPE_FLOAT:
+		return "float32"
+	case descriptor.FieldDescriptorProto_TYPE_INT64, descriptor.FieldDescriptorProto_TYPE_SINT64,
+		descriptor.FieldDescriptorProto_TYPE_SFIXED64:
+		return "int64"
+	case descriptor.FieldDescriptorProto_TYPE_UINT64, descriptor.FieldDescriptorProto_TYPE_FIXED64:
+		return "uint64"
+	case descriptor.FieldDescriptorProto_TYPE_INT32, descriptor.FieldDescriptorProto_TYPE_SINT32,
+		descriptor.FieldDescriptorProto_TYPE_SFIXED32:
+		return "int32"
+	case descriptor.FieldDescriptorProto_TYPE_UINT32, descriptor.FieldDescriptorProto_TYPE_FIXED32:
+		return "uint32"
+	case descriptor.FieldDescriptorProto_TYPE_BOOL:
+		return "bool"
+	}
+	return "string"
+}
+
+type header struct {
+	Source  string
+	GoPkg   string
+	Std     []string
+	Imports map[string]string
+}
+
+type cloneMessage struct {
+	Name  string
+	Plain []string
+	Deep  []string
+}
+
+// typeIndex maps fully-qualified proto type names (".pkg.Msg") to their
+// descriptors and declaring files for every file in the request.
+type typeIndex struct {
+	messages map[string]*descriptor.DescriptorProto
+	enums    map[string]*descriptor.EnumDescriptorProto
+	files    map[string]*descriptor.FileDescriptorProto
+}
+
+func newTypeIndex(files []*descriptor.FileDescriptorProto) *typeIndex {
+	idx := &typeIndex{
+		messages: make(map[string]*descriptor.DescriptorProto),
+		enums:    make(map[string]*descriptor.EnumDescriptorProto),
+		files:    make(map[string]*descriptor.FileDescriptorProto),
+	}
+	for _, f := range files {
+		prefix := ""
+		if f.GetPackage() != "" {
+			prefix = "." + f.GetPackage()
+		}
+		idx.addMessages(f, prefix, f.GetMessageType())
+		idx.addEnums(f, prefix, f.GetEnumType())
+	}
+	return idx
+}
+
+func (idx *typeIndex) addMessages(f *descriptor.FileDescriptorProto, prefix string, msgs []*descriptor.DescriptorProto) {
+	for _, m := range msgs {
+		name := prefix + "." + m.GetName()
+		idx.messages[name] = m
+		idx.files[name] = f
+		idx.addMessages(f, name, m.GetNestedType())
+		idx.addEnums(f, name, m.GetEnumType())
+	}
+}
+
+func (idx *typeIndex) addEnums(f *descriptor.FileDescriptorProto, prefix string, enums []*descriptor.EnumDescriptorProto) {
+	for _, e := range enums {
+		name := prefix + "." + e.GetName()
+		idx.enums[name] = e
+		idx.files[name] = f
+	}
+}
+
+// isMap reports whether field is a map field.
+func (idx *typeIndex) isMap(field *descriptor.FieldDescriptorProto) bool {
+	if field.GetType() != descriptor.FieldDescriptorProto_TYPE_MESSAGE {
+		return false
+	}
+	return idx.messages[field.GetTypeName()].GetOptions().GetMapEntry()
+}
+
+// goImportPath returns the import path of the Go package generated from f,
+// or "" if go_package does not name one.
+func goImportPath(f *descriptor.FileDescriptorProto) string {
+	gopkg := f.GetOptions().GetGoPackage()
+	if sc := strings.IndexByte(gopkg, ';'); sc >= 0 {
+		gopkg = gopkg[:sc]
+	}
+	if !strings.Contains(gopkg, "/") {
+		return ""
+	}
+	return gopkg
+}
+
+// importSet records the Go packages referenced by the code generated for
+// one file, keyed by import path.
+type importSet struct {
+	self  string
+	names map[string]string
+}
+
+func newImportSet(f *descriptor.FileDescriptorProto) *importSet {
+	return &importSet{self: goImportPath(f), names: make(map[string]string)}
+}
+
+// goTypeName returns the Go name of the message or enum typeName, qualified
+// with its package if it is declared outside the generated package.
+func (s *importSet) goTypeName(idx *typeIndex, typeName string) string {
+	name := localTypeName(typeName)
+	f := idx.files[typeName]
+	path := goImportPath(f)
+	if f == nil || path == "" || path == s.self {
+		return name
+	}
+	pkg := defaultGoPackageName(f)
+	s.names[path] = pkg
+	return pkg + "." + name
+}
+
+// localTypeName returns the Go name of a type declared in the file being
+// generated, e.g. ".pkg.Outer.Inner" becomes "Outer_Inner".
+func localTypeName(typeName string) string {
+	parts := strings.Split(strings.TrimPrefix(typeName, "."), ".")
+	var names []string
+	for _, p := range parts {
+		if len(p) > 0 && p[0] >= 'A' && p[0] <= 'Z' {
+			names = append(names, p)
+		}
+	}
+	return strings.Join(names, "_")
+}
+
+// sanitizePackageName replaces unallowed character in package name
+// with allowed character.
+func sanitizePackageName(pkgName string) string {
+	pkgName = strings.Replace(pkgName, ".", "_", -1)
+	pkgName = strings.Replace(pkgName, "-", "_", -1)
+	return pkgName
+}
+
+// defaultGoPackageName returns the default go package name to be used for go files generated from "f".
+// You might need to use an unique alias for the package when you import it.  Use ReserveGoPackageAlias to get a unique alias.
+func defaultGoPackageName(f *descriptor.FileDescriptorProto) string {
+	name := packageIdentityName(f)
+	return sanitizePackageName(name)
+}
+
+// packageIdentityName returns the identity of packages.
+// protoc-gen-grpc-gateway rejects CodeGenerationRequests which contains more than one packages
+// as protoc-gen-go does.
+func packageIdentityName(f *descriptor.FileDescriptorProto) string {
+	if f.Options != nil && f.Options.GoPackage != nil {
+		gopkg := f.Options.GetGoPackage()
+		idx := strings.LastIndex(gopkg, "/")
+		if idx < 0 {
+			gopkg = gopkg[idx+1:]
+		}
+
+		gopkg = gopkg[idx+1:]
+		// package name is overrided with the string after the
+		// ';' character
+		sc := strings.IndexByte(gopkg, ';')
+		if sc < 0 {
+			return sanitizePackageName(gopkg)
+
+		}
+		return sanitizePackageName(gopkg[sc+1:])
+	}
+
+	if f.Package == nil {
+		base := filepath.Base(f.GetName())
+		ext := filepath.Ext(base)
+		return strings.TrimSuffix(base, ext)
+	}
+	return f.GetPackage()
+}
+
+func emitFiles(out []*plugin.CodeGeneratorResponse_File) {
+	emitResp(&plugin.CodeGeneratorResponse{File: out})
+}
+
+func emitError(err error) {
+	emitResp(&plugin.CodeGeneratorResponse{Error: proto.String(err.Error())})
+}
+
+func emitResp(resp *plugin.CodeGeneratorResponse) {
+	buf, err := proto.Marshal(resp)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if _, err := os.Stdout.Write(buf); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/f4tq/protoc-go-plugins/internal/plugintest"
+)
+
+const groupProto = `
+syntax = "proto2";
+
+package doc.v1;
+
+option go_package = "example.com/doc/v1;docv1";
+
+message Doc {
+    optional group Meta = 1 {
+        optional string author = 2;
+    }
+    repeated group Part = 3 {
+        optional bytes data = 4;
+    }
+    oneof body {
+        group Text = 5 {
+            optional string value = 6;
+        }
+    }
+}
+`
+
+// groupTest runs in the package generated for groupProto.
+const groupTest = `package docv1
+
+import (
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+)
+
+func TestClone(t *testing.T) {
+	m := &Doc{
+		Meta: &Doc_Meta{Author: proto.String("ada")},
+		Part: []*Doc_Part{{Data: []byte("data")}},
+		Body: &Doc_Text_{Text: &Doc_Text{Value: proto.String("text")}},
+	}
+	c := m.Clone()
+	if !proto.Equal(c, m) {
+		t.Fatalf("Clone() = %v, want %v", c, m)
+	}
+	*c.Meta.Author = "grace"
+	c.Part[0].Data[0] = 'D'
+	*c.GetText().Value = "TEXT"
+	if m.GetMeta().GetAuthor() != "ada" || string(m.GetPart()[0].GetData()) != "data" || m.GetText().GetValue() != "text" {
+		t.Errorf("changing the clone changed the original to %v", m)
+	}
+}
+`
+
+// TestGroups runs and vets the Clone methods of messages with group
+// fields, which are deep-copied as the messages they are rather than
+// dereferenced.
+func TestGroups(t *testing.T) {
+	req := plugintest.Request(t, "", map[string]string{"doc/v1/doc.proto": groupProto})
+	pkg := plugintest.PackageV1(t, req, generate)
+	plugintest.WriteFile(t, pkg, "clone_test.go", groupTest)
+	plugintest.Go(t, "vet", pkg)
+	plugintest.Go(t, "test", pkg)
+}
@@ -0,0 +1,533 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+
+	"github.com/f4tq/protoc-go-plugins/goname"
+	"github.com/f4tq/protoc-go-plugins/options"
+	"github.com/f4tq/protoc-go-plugins/protodoc"
+)
+
+var (
+	hdrTmpl = template.Must(template.New("header").Parse(`
+// Code generated by protoc-gen-go-collection. DO NOT EDIT.
+// source: {{.Source}}
+
+package {{.GoPkg}}
+
+import (
+{{- if .Slices}}
+    "slices"
+{{end}}
+{{- if .Collection}}
+    "github.com/f4tq/protoc-go-plugins/runtime/collection"
+{{end}}
+{{- range $path, $name := .Imports}}
+    {{$name}} "{{$path}}"
+{{- end}}
+)
+`))
+
+	addTmpl = template.Must(template.New("add").Parse(`
+// Add{{.GoName}} appends v to the {{.Field}} field of m.{{.Doc}}
+func (m *{{.Msg}}) Add{{.GoName}}(v ...{{.Elem}}) {
+    m.{{.GoName}} = append(m.{{.GoName}}, v...)
+}
+`))
+
+	findTmpl = template.Must(template.New("find").Parse(`
+// Find{{.GoName}}By{{.KeyName}} returns the first element of the {{.Field}}
+// field of m whose {{.Key}} is k, or nil.{{.Doc}}
+func (m *{{.Msg}}) Find{{.GoName}}By{{.KeyName}}(k {{.KeyType}}) {{.Elem}} {
+    for _, v := range m.Get{{.GoName}}() {
+        if v.Get{{.KeyName}}() == k {
+            return v
+        }
+    }
+    return nil
+}
+`))
+
+	indexTmpl = template.Must(template.New("index").Parse(`
+// Index{{.Plural}}By{{.KeyName}} returns the elements of s by their
+// {{.Key}}. If several share a {{.Key}}, the first one is kept and a
+// *collection.DuplicateKeyError reports the first duplicate.
+func Index{{.Plural}}By{{.KeyName}}(s []{{.Elem}}) (map[{{.KeyType}}]{{.Elem}}, error) {
+    return collection.Index(s, ({{.Elem}}).Get{{.KeyName}}, {{printf "%q" .Key}})
+}
+`))
+
+	keysTmpl = template.Must(template.New("keys").Parse(`
+// Sorted{{.GoName}}Keys returns the keys of the {{.Field}} field of m in
+// increasing order.{{.Doc}}
+func (m *{{.Msg}}) Sorted{{.GoName}}Keys() []{{.KeyType}} {
+    keys := make([]{{.KeyType}}, 0, len(m.Get{{.GoName}}()))
+    for k := range m.Get{{.GoName}}() {
+        keys = append(keys, k)
+    }
+    slices.Sort(keys)
+    return keys
+}
+`))
+)
+
+func main() {
+	input, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	req := new(plugin.CodeGeneratorRequest)
+	if err = proto.Unmarshal(input, req); err != nil {
+		log.Fatal(err)
+	}
+
+	out, err := generate(req)
+
+	if err != nil {
+		emitError(err)
+		return
+	}
+
+	emitFiles(out)
+}
+
+func generate(req *plugin.CodeGeneratorRequest) ([]*plugin.CodeGeneratorResponse_File, error) {
+	var files []*plugin.CodeGeneratorResponse_File
+	idx := newTypeIndex(req.GetProtoFile())
+	docs := protodoc.New(req.GetProtoFile())
+	genFileNames := make(map[string]bool)
+	for _, n := range req.FileToGenerate {
+		genFileNames[n] = true
+	}
+	// indexes records the Index functions emitted, by Go import path and
+	// name, as the fields of several files may share an element type.
+	indexes := make(map[string]bool)
+	for _, desc := range req.GetProtoFile() {
+		name := desc.GetName()
+		if _, ok := genFileNames[name]; !ok {
+			// Only emit output for files present in req.FileToGenerate.
+			continue
+		}
+		code, err := genCode(desc, idx, docs, indexes)
+		if err != nil {
+			return nil, err
+		}
+		if code == "" {
+			// The file declares no repeated or map fields.
+			continue
+		}
+		formatted, err := format.Source([]byte(code))
+		if err != nil {
+			log.Printf("%v: %s", err, code)
+			return nil, err
+		}
+
+		ext := filepath.Ext(name)
+		base := strings.TrimSuffix(name, ext)
+		output := fmt.Sprintf("%s.pb.collection.go", base)
+		files = append(files, &plugin.CodeGeneratorResponse_File{
+			Name:    proto.String(output),
+			Content: proto.String(string(formatted)),
+		})
+	}
+
+	return files, nil
+}
+
+func genCode(desc *descriptor.FileDescriptorProto, idx *typeIndex, docs *protodoc.Index, indexes map[string]bool) (string, error) {
+	w := bytes.NewBuffer(nil)
+	g := &collectionGen{
+		idx:     idx,
+		docs:    docs,
+		imports: newImportSet(desc),
+		pkg:     goImportPath(desc),
+		indexes: indexes,
+	}
+	body := bytes.NewBuffer(nil)
+	if err := g.messages(body, "", desc.GetPackage(), desc.GetMessageType()); err != nil {
+		return "", err
+	}
+	if body.Len() == 0 {
+		return "", nil
+	}
+
+	hdr := &header{
+		Source:     desc.GetName(),
+		GoPkg:      defaultGoPackageName(desc),
+		Imports:    g.imports.names,
+		Slices:     g.slices,
+		Collection: g.collection,
+	}
+	if err := hdrTmpl.Execute(w, hdr); err != nil {
+		log.Fatal(err)
+	}
+	body.WriteTo(w)
+
+	return w.String(), nil
+}
+
+type collectionGen struct {
+	idx     *typeIndex
+	docs    *protodoc.Index
+	imports *importSet
+	// pkg is the Go import path of the file and indexes the Index
+	// functions emitted so far, see generate.
+	pkg     string
+	indexes map[string]bool
+	// slices and collection record whether the code uses these packages.
+	slices     bool
+	collection bool
+}
+
+// messages writes the helpers of the repeated and map fields of msgs and of
+// the messages nested in them. prefix is the Go name of the enclosing
+// message plus "_", and scope its full proto name.
+func (g *collectionGen) messages(w *bytes.Buffer, prefix, scope string, msgs []*descriptor.DescriptorProto) error {
+	for _, msg := range msgs {
+		if msg.GetOptions().GetMapEntry() {
+			continue
+		}
+		name := prefix + msg.GetName()
+		fullName := qualify(scope, msg.GetName())
+		goNames := goname.Fields(msg)
+		for _, field := range msg.GetField() {
+			if err := g.field(w, name, fullName, field, goNames[field.GetName()]); err != nil {
+				return fmt.Errorf("%s.%s: %v", name, field.GetName(), err)
+			}
+		}
+		if err := g.messages(w, name+"_", fullName, msg.GetNestedType()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// field writes the helpers of field, whose Go name is goName, of the
+// message whose Go name is msgName and full proto name fullName, if it is
+// repeated or a map.
+func (g *collectionGen) field(w *bytes.Buffer, msgName, fullName string, field *descriptor.FieldDescriptorProto, goName string) error {
+	if field.GetLabel() != descriptor.FieldDescriptorProto_LABEL_REPEATED {
+		if options.Key(field) != "" {
+			return fmt.Errorf("key only applies to repeated message fields")
+		}
+		return nil
+	}
+	f := &collectionField{
+		Msg:    msgName,
+		Field:  field.GetName(),
+		GoName: goName,
+		Doc:    g.docs.Godoc(fullName + "." + field.GetName()),
+	}
+	if g.idx.isMap(field) {
+		if options.Key(field) != "" {
+			return fmt.Errorf("key only applies to repeated message fields")
+		}
+		key := g.idx.messages[field.GetTypeName()].GetField()[0]
+		if key.GetType() == descriptor.FieldDescriptorProto_TYPE_BOOL {
+			// bool keys are not ordered.
+			return nil
+		}
+		g.slices = true
+		f.KeyType = scalarGoType(key.GetType())
+		return keysTmpl.Execute(w, f)
+	}
+	f.Elem = g.goType(field)
+	if err := addTmpl.Execute(w, f); err != nil {
+		return err
+	}
+	if options.Key(field) == "" {
+		return nil
+	}
+	if !g.isMessage(field) {
+		return fmt.Errorf("key only applies to repeated message fields")
+	}
+	elem := g.idx.messages[field.GetTypeName()]
+	var key *descriptor.FieldDescriptorProto
+	for _, kf := range elem.GetField() {
+		if kf.GetName() == options.Key(field) {
+			key = kf
+		}
+	}
+	switch {
+	case key == nil:
+		return fmt.Errorf("%s has no field %s", field.GetTypeName(), options.Key(field))
+	case key.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REPEATED, g.isMessage(key),
+		key.GetType() == descriptor.FieldDescriptorProto_TYPE_BYTES:
+		return fmt.Errorf("key %s is not a singular scalar or enum field", key.GetName())
+	}
+	f.Key = key.GetName()
+	f.KeyName = goname.Fields(elem)[key.GetName()]
+	f.KeyType = g.goType(key)
+	if err := findTmpl.Execute(w, f); err != nil {
+		return err
+	}
+	f.Plural = plural(localTypeName(field.GetTypeName()))
+	name := g.pkg + ".Index" + f.Plural + "By" + f.KeyName
+	if g.indexes[name] {
+		return nil
+	}
+	g.indexes[name] = true
+	g.collection = true
+	return indexTmpl.Execute(w, f)
+}
+
+// plural returns the plural of the Go type name, as in IndexUsers or
+// IndexAddresses.
+func plural(name string) string {
+	switch {
+	case strings.HasSuffix(name, "s"), strings.HasSuffix(name, "x"), strings.HasSuffix(name, "z"),
+		strings.HasSuffix(name, "ch"), strings.HasSuffix(name, "sh"):
+		return name + "es"
+	case strings.HasSuffix(name, "y") && len(name) > 1 && !strings.ContainsRune("aeiouAEIOU", rune(name[len(name)-2])):
+		return name[:len(name)-1] + "ies"
+	}
+	return name + "s"
+}
+
+func (g *collectionGen) isMessage(field *descriptor.FieldDescriptorProto) bool {
+	return field.GetType() == descriptor.FieldDescriptorProto_TYPE_MESSAGE ||
+		field.GetType() == descriptor.FieldDescriptorProto_TYPE_GROUP
+}
+
+// goType returns the Go type of a value of field.
+func (g *collectionGen) goType(field *descriptor.FieldDescriptorProto) string {
+	switch field.GetType() {
+	case descriptor.FieldDescriptorProto_TYPE_MESSAGE, descriptor.FieldDescriptorProto_TYPE_GROUP:
+		return "*" + g.imports.goTypeName(g.idx, field.GetTypeName())
+	case descriptor.FieldDescriptorProto_TYPE_ENUM:
+		return g.imports.goTypeName(g.idx, field.GetTypeName())
+	case descriptor.FieldDescriptorProto_TYPE_BYTES:
+		return "[]byte"
+	}
+	return scalarGoType(field.GetType())
+}
+
+// scalarGoType returns the Go type of the scalar type t.
+func scalarGoType(t descriptor.FieldDescriptorProto_Type) string {
+	switch t {
+	case descriptor.FieldDescriptorProto_TYPE_DOUBLE:
+		return "float64"
+	case descriptor.FieldDescriptorProto_TYPE_FLOAT:
+		return "float32"
+	case descriptor.FieldDescriptorProto_TYPE_INT64, descriptor.FieldDescriptorProto_TYPE_SINT64,
+		descriptor.FieldDescriptorProto_TYPE_SFIXED64:
+		return "int64"
+	case descriptor.FieldDescriptorProto_TYPE_UINT64, descriptor.FieldDescriptorProto_TYPE_FIXED64:
+		return "uint64"
+	case descriptor.FieldDescriptorProto_TYPE_INT32, descriptor.FieldDescriptorProto_TYPE_SINT32,
+		descriptor.FieldDescriptorProto_TYPE_SFIXED32:
+		return "int32"
+	case descriptor.FieldDescriptorProto_TYPE_UINT32, descriptor.FieldDescriptorProto_TYPE_FIXED32:
+		return "uint32"
+	case descriptor.FieldDescriptorProto_TYPE_BOOL:
+		return "bool"
+	}
+	return "string"
+}
+
+type header struct {
+	Source     string
+	GoPkg      string
+	Imports    map[string]string
+	Slices     bool
+	Collection bool
+}
+
+type collectionField struct {
+	Msg     string
+	Field   string
+	GoName  string
+	Elem    string
+	Key     string
+	KeyName string
+	Plural  string
+	KeyType string
+	// Doc is the comment of the field, see protodoc.Index.Godoc.
+	Doc string
+}
+
+// qualify returns the full proto name of name, declared in scope.
+func qualify(scope, name string) string {
+	if scope == "" {
+		return name
+	}
+	return scope + "." + name
+}
+
+// typeIndex maps fully-qualified proto type names (".pkg.Msg") to their
+// descriptors and declaring files for every file in the request.
+type typeIndex struct {
+	messages map[string]*descriptor.DescriptorProto
+	enums    map[string]*descriptor.EnumDescriptorProto
+	files    map[string]*descriptor.FileDescriptorProto
+}
+
+func newTypeIndex(files []*descriptor.FileDescriptorProto) *typeIndex {
+	idx := &typeIndex{
+		messages: make(map[string]*descriptor.DescriptorProto),
+		enums:    make(map[string]*descriptor.EnumDescriptorProto),
+		files:    make(map[string]*descriptor.FileDescriptorProto),
+	}
+	for _, f := range files {
+		prefix := ""
+		if f.GetPackage() != "" {
+			prefix = "." + f.GetPackage()
+		}
+		idx.addMessages(f, prefix, f.GetMessageType())
+		idx.addEnums(f, prefix, f.GetEnumType())
+	}
+	return idx
+}
+
+func (idx *typeIndex) addMessages(f *descriptor.FileDescriptorProto, prefix string, msgs []*descriptor.DescriptorProto) {
+	for _, m := range msgs {
+		name := prefix + "." + m.GetName()
+		idx.messages[name] = m
+		idx.files[name] = f
+		idx.addMessages(f, name, m.GetNestedType())
+		idx.addEnums(f, name, m.GetEnumType())
+	}
+}
+
+func (idx *typeIndex) addEnums(f *descriptor.FileDescriptorProto, prefix string, enums []*descriptor.EnumDescriptorProto) {
+	for _, e := range enums {
+		name := prefix + "." + e.GetName()
+		idx.enums[name] = e
+		idx.files[name] = f
+	}
+}
+
+// isMap reports whether field is a map field.
+func (idx *typeIndex) isMap(field *descriptor.FieldDescriptorProto) bool {
+	if field.GetType() != descriptor.FieldDescriptorProto_TYPE_MESSAGE {
+		return false
+	}
+	return idx.messages[field.GetTypeName()].GetOptions().GetMapEntry()
+}
+
+// goImportPath returns the import path of the Go package generated from f,
+// or "" if go_package does not name one.
+func goImportPath(f *descriptor.FileDescriptorProto) string {
+	gopkg := f.GetOptions().GetGoPackage()
+	if sc := strings.IndexByte(gopkg, ';'); sc >= 0 {
+		gopkg = gopkg[:sc]
+	}
+	if !strings.Contains(gopkg, "/") {
+		return ""
+	}
+	return gopkg
+}
+
+// importSet records the Go packages referenced by the code generated for
+// one file, keyed by import path.
+type importSet struct {
+	self  string
+	names map[string]string
+}
+
+func newImportSet(f *descriptor.FileDescriptorProto) *importSet {
+	return &importSet{self: goImportPath(f), names: make(map[string]string)}
+}
+
+// goTypeName returns the Go name of the message or enum typeName, qualified
+// with its package if it is declared outside the generated package.
+func (s *importSet) goTypeName(idx *typeIndex, typeName string) string {
+	name := localTypeName(typeName)
+	f := idx.files[typeName]
+	path := goImportPath(f)
+	if f == nil || path == "" || path == s.self {
+		return name
+	}
+	pkg := defaultGoPackageName(f)
+	s.names[path] = pkg
+	return pkg + "." + name
+}
+
+// localTypeName returns the Go name of a type declared in the file being
+// generated, e.g. ".pkg.Outer.Inner" becomes "Outer_Inner".
+func localTypeName(typeName string) string {
+	parts := strings.Split(strings.TrimPrefix(typeName, "."), ".")
+	var names []string
+	for _, p := range parts {
+		if len(p) > 0 && p[0] >= 'A' && p[0] <= 'Z' {
+			names = append(names, p)
+		}
+	}
+	return strings.Join(names, "_")
+}
+
+// sanitizePackageName replaces unallowed character in package name
+// with allowed character.
+func sanitizePackageName(pkgName string) string {
+	pkgName = strings.Replace(pkgName, ".", "_", -1)
+	pkgName = strings.Replace(pkgName, "-", "_", -1)
+	return pkgName
+}
+
+// defaultGoPackageName returns the default go package name to be used for go files generated from "f".
+// You might need to use an unique alias for the package when you import it.  Use ReserveGoPackageAlias to get a unique alias.
+func defaultGoPackageName(f *descriptor.FileDescriptorProto) string {
+	name := packageIdentityName(f)
+	return sanitizePackageName(name)
+}
+
+// packageIdentityName returns the identity of packages.
+// protoc-gen-grpc-gateway rejects CodeGenerationRequests which contains more than one packages
+// as protoc-gen-go does.
+func packageIdentityName(f *descriptor.FileDescriptorProto) string {
+	if f.Options != nil && f.Options.GoPackage != nil {
+		gopkg := f.Options.GetGoPackage()
+		idx := strings.LastIndex(gopkg, "/")
+		if idx < 0 {
+			gopkg = gopkg[idx+1:]
+		}
+
+		gopkg = gopkg[idx+1:]
+		// package name is overrided with the string after the
+		// ';' character
+		sc := strings.IndexByte(gopkg, ';')
+		if sc < 0 {
+			return sanitizePackageName(gopkg)
+
+		}
+		return sanitizePackageName(gopkg[sc+1:])
+	}
+
+	if f.Package == nil {
+		base := filepath.Base(f.GetName())
+		ext := filepath.Ext(base)
+		return strings.TrimSuffix(base, ext)
+	}
+	return f.GetPackage()
+}
+
+func emitFiles(out []*plugin.CodeGeneratorResponse_File) {
+	emitResp(&plugin.CodeGeneratorResponse{File: out})
+}
+
+func emitError(err error) {
+	emitResp(&plugin.CodeGeneratorResponse{Error: proto.String(err.Error())})
+}
+
+func emitResp(resp *plugin.CodeGeneratorResponse) {
+	buf, err := proto.Marshal(resp)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if _, err := os.Stdout.Write(buf); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,335 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+
+	"github.com/f4tq/protoc-go-plugins/goname"
+	"github.com/f4tq/protoc-go-plugins/options"
+	"github.com/f4tq/protoc-go-plugins/protodoc"
+)
+
+// timeTypes are the message types that may be sort keys, compared by
+// their seconds and then their nanos.
+var timeTypes = map[string]bool{
+	".google.protobuf.Timestamp": true,
+	".google.protobuf.Duration":  true,
+}
+
+var (
+	hdrTmpl = template.Must(template.New("header").Parse(`
+// Code generated by protoc-gen-go-compare. DO NOT EDIT.
+// source: {{.Source}}
+
+package {{.GoPkg}}
+
+import (
+{{- if .Bytes}}
+    "bytes"
+{{- end}}
+{{- if .Cmp}}
+    "cmp"
+{{- end}}
+    "slices"
+)
+`))
+
+	compareTmpl = template.Must(template.New("compare").Parse(`
+// Compare{{.Name}} orders a and b by their sort keys, by priority:
+//
+{{- range .Doc}}
+//   - {{.}}
+{{- end}}
+//
+// It returns a negative number if a sorts first, a positive number if b
+// does, and zero if they tie. Unset fields compare as their zero values.{{.MessageDoc}}
+func Compare{{.Name}}(a, b *{{.Name}}) int {
+{{- range .Keys}}
+{{.}}
+{{- end}}
+    return 0
+}
+
+// Sort{{.Plural}} sorts s by Compare{{.Name}}, keeping the order of ties.{{.MessageDoc}}
+func Sort{{.Plural}}(s []*{{.Name}}) {
+    slices.SortStableFunc(s, Compare{{.Name}})
+}
+`))
+)
+
+func main() {
+	input, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	req := new(plugin.CodeGeneratorRequest)
+	if err = proto.Unmarshal(input, req); err != nil {
+		log.Fatal(err)
+	}
+
+	out, err := generate(req)
+
+	if err != nil {
+		emitError(err)
+		return
+	}
+
+	emitFiles(out)
+}
+
+func generate(req *plugin.CodeGeneratorRequest) ([]*plugin.CodeGeneratorResponse_File, error) {
+	var files []*plugin.CodeGeneratorResponse_File
+	docs := protodoc.New(req.GetProtoFile())
+	genFileNames := make(map[string]bool)
+	for _, n := range req.FileToGenerate {
+		genFileNames[n] = true
+	}
+	for _, desc := range req.GetProtoFile() {
+		name := desc.GetName()
+		if _, ok := genFileNames[name]; !ok {
+			// Only emit output for files present in req.FileToGenerate.
+			continue
+		}
+		code, err := genCode(desc, docs)
+		if err != nil {
+			return nil, err
+		}
+		if code == "" {
+			// The file declares no sort keys.
+			continue
+		}
+		formatted, err := format.Source([]byte(code))
+		if err != nil {
+			log.Printf("%v: %s", err, code)
+			return nil, err
+		}
+
+		ext := filepath.Ext(name)
+		base := strings.TrimSuffix(name, ext)
+		output := fmt.Sprintf("%s.pb.compare.go", base)
+		files = append(files, &plugin.CodeGeneratorResponse_File{
+			Name:    proto.String(output),
+			Content: proto.String(string(formatted)),
+		})
+	}
+
+	return files, nil
+}
+
+func genCode(desc *descriptor.FileDescriptorProto, docs *protodoc.Index) (string, error) {
+	hdr := &header{
+		Source: desc.GetName(),
+		GoPkg:  defaultGoPackageName(desc),
+	}
+	body := bytes.NewBuffer(nil)
+	if err := messages(body, hdr, docs, "", desc.GetPackage(), desc.GetMessageType()); err != nil {
+		return "", err
+	}
+	if body.Len() == 0 {
+		return "", nil
+	}
+	w := bytes.NewBuffer(nil)
+	if err := hdrTmpl.Execute(w, hdr); err != nil {
+		log.Fatal(err)
+	}
+	body.WriteTo(w)
+
+	return w.String(), nil
+}
+
+// messages writes the comparators of the messages of msgs, and of the
+// messages nested in them, that have sort keys, recording the imports they
+// need in hdr. prefix is the Go name of the enclosing message plus "_",
+// and scope its full proto name.
+func messages(w *bytes.Buffer, hdr *header, docs *protodoc.Index, prefix, scope string, msgs []*descriptor.DescriptorProto) error {
+	for _, msg := range msgs {
+		if msg.GetOptions().GetMapEntry() {
+			continue
+		}
+		name := prefix + msg.GetName()
+		fullName := qualify(scope, msg.GetName())
+		var keys []*descriptor.FieldDescriptorProto
+		for _, field := range msg.GetField() {
+			if options.SortKey(field) == 0 {
+				if options.SortDescending(field) {
+					return fmt.Errorf("%s.%s: (f4tq.plugins.sort_descending) without (f4tq.plugins.sort_key)", name, field.GetName())
+				}
+				continue
+			}
+			keys = append(keys, field)
+		}
+		if len(keys) > 0 {
+			sort.SliceStable(keys, func(i, j int) bool { return options.SortKey(keys[i]) < options.SortKey(keys[j]) })
+			m := &compareMessage{Name: name, Plural: plural(name), MessageDoc: docs.Godoc(fullName)}
+			for i, field := range keys {
+				if i > 0 && options.SortKey(field) == options.SortKey(keys[i-1]) {
+					return fmt.Errorf("%s: fields %s and %s have the same (f4tq.plugins.sort_key) %d",
+						name, keys[i-1].GetName(), field.GetName(), options.SortKey(field))
+				}
+				stmt, err := compareField(hdr, field, goname.Fields(msg)[field.GetName()])
+				if err != nil {
+					return fmt.Errorf("%s.%s: %v", name, field.GetName(), err)
+				}
+				m.Keys = append(m.Keys, stmt)
+				d := field.GetName()
+				if options.SortDescending(field) {
+					d += ", descending"
+				}
+				m.Doc = append(m.Doc, d)
+			}
+			if err := compareTmpl.Execute(w, m); err != nil {
+				return err
+			}
+		}
+		if err := messages(w, hdr, docs, name+"_", fullName, msg.GetNestedType()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// compareField returns the statements returning the order of a and b by
+// field, whose Go name is goName, if they differ in it.
+func compareField(hdr *header, field *descriptor.FieldDescriptorProto, goName string) (string, error) {
+	if field.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REPEATED {
+		return "", fmt.Errorf("repeated fields cannot be sort keys")
+	}
+	x, y := "a", "b"
+	if options.SortDescending(field) {
+		x, y = y, x
+	}
+	get := "Get" + goName + "()"
+	switch field.GetType() {
+	case descriptor.FieldDescriptorProto_TYPE_MESSAGE, descriptor.FieldDescriptorProto_TYPE_GROUP:
+		if !timeTypes[field.GetTypeName()] {
+			return "", fmt.Errorf("messages of type %s cannot be sort keys", field.GetTypeName())
+		}
+		hdr.Cmp = true
+		var stmts []string
+		for _, part := range []string{"GetSeconds()", "GetNanos()"} {
+			stmts = append(stmts, fmt.Sprintf("if c := cmp.Compare(%s.%s.%s, %s.%s.%s); c != 0 {\nreturn c\n}", x, get, part, y, get, part))
+		}
+		return strings.Join(stmts, "\n"), nil
+	case descriptor.FieldDescriptorProto_TYPE_BYTES:
+		hdr.Bytes = true
+		return fmt.Sprintf("if c := bytes.Compare(%s.%s, %s.%s); c != 0 {\nreturn c\n}", x, get, y, get), nil
+	case descriptor.FieldDescriptorProto_TYPE_BOOL:
+		// false sorts before true.
+		return fmt.Sprintf("if x, y := %s.%s, %s.%s; x != y {\nif !x {\nreturn -1\n}\nreturn 1\n}", x, get, y, get), nil
+	}
+	hdr.Cmp = true
+	return fmt.Sprintf("if c := cmp.Compare(%s.%s, %s.%s); c != 0 {\nreturn c\n}", x, get, y, get), nil
+}
+
+// plural returns the plural of the Go type name, as in SortUsers or
+// SortAddresses.
+func plural(name string) string {
+	switch {
+	case strings.HasSuffix(name, "s"), strings.HasSuffix(name, "x"), strings.HasSuffix(name, "z"),
+		strings.HasSuffix(name, "ch"), strings.HasSuffix(name, "sh"):
+		return name + "es"
+	case strings.HasSuffix(name, "y") && len(name) > 1 && !strings.ContainsRune("aeiouAEIOU", rune(name[len(name)-2])):
+		return name[:len(name)-1] + "ies"
+	}
+	return name + "s"
+}
+
+type header struct {
+	Source string
+	GoPkg  string
+	Bytes  bool
+	Cmp    bool
+}
+
+type compareMessage struct {
+	Name   string
+	Plural string
+	Doc    []string
+	Keys   []string
+	// MessageDoc is the comment of the message, see protodoc.Index.Godoc.
+	MessageDoc string
+}
+
+// qualify returns the full proto name of name, declared in scope.
+func qualify(scope, name string) string {
+	if scope == "" {
+		return name
+	}
+	return scope + "." + name
+}
+
+// sanitizePackageName replaces unallowed character in package name
+// with allowed character.
+func sanitizePackageName(pkgName string) string {
+	pkgName = strings.Replace(pkgName, ".", "_", -1)
+	pkgName = strings.Replace(pkgName, "-", "_", -1)
+	return pkgName
+}
+
+// defaultGoPackageName returns the default go package name to be used for go files generated from "f".
+// You might need to use an unique alias for the package when you import it.  Use ReserveGoPackageAlias to get a unique alias.
+func defaultGoPackageName(f *descriptor.FileDescriptorProto) string {
+	name := packageIdentityName(f)
+	return sanitizePackageName(name)
+}
+
+// packageIdentityName returns the identity of packages.
+// protoc-gen-grpc-gateway rejects CodeGenerationRequests which contains more than one packages
+// as protoc-gen-go does.
+func packageIdentityName(f *descriptor.FileDescriptorProto) string {
+	if f.Options != nil && f.Options.GoPackage != nil {
+		gopkg := f.Options.GetGoPackage()
+		idx := strings.LastIndex(gopkg, "/")
+		if idx < 0 {
+			gopkg = gopkg[idx+1:]
+		}
+
+		gopkg = gopkg[idx+1:]
+		// package name is overrided with the string after the
+		// ';' character
+		sc := strings.IndexByte(gopkg, ';')
+		if sc < 0 {
+			return sanitizePackageName(gopkg)
+
+		}
+		return sanitizePackageName(gopkg[sc+1:])
+	}
+
+	if f.Package == nil {
+		base := filepath.Base(f.GetName())
+		ext := filepath.Ext(base)
+		return strings.TrimSuffix(base, ext)
+	}
+	return f.GetPackage()
+}
+
+func emitFiles(out []*plugin.CodeGeneratorResponse_File) {
+	emitResp(&plugin.CodeGeneratorResponse{File: out})
+}
+
+func emitError(err error) {
+	emitResp(&plugin.CodeGeneratorResponse{Error: proto.String(err.Error())})
+}
+
+func emitResp(resp *plugin.CodeGeneratorResponse) {
+	buf, err := proto.Marshal(resp)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if _, err := os.Stdout.Write(buf); err != nil {
+		log.Fatal(err)
+	}
+}
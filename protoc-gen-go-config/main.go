@@ -0,0 +1,436 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+
+	"github.com/f4tq/protoc-go-plugins/options"
+	"github.com/f4tq/protoc-go-plugins/protodoc"
+)
+
+var (
+	hdrTmpl = template.Must(template.New("header").Parse(`
+// Code generated by protoc-gen-go-config. DO NOT EDIT.
+// source: {{.Source}}
+
+package {{.GoPkg}}
+
+import (
+    "github.com/f4tq/protoc-go-plugins/runtime/configtree"
+)
+`))
+
+	configTmpl = template.Must(template.New("config").Parse(`
+{{- if .Config}}
+
+// Decode{{.Name}} returns the {{.Name}} decoded from settings, the
+// configuration tree of viper (AllSettings) or koanf (Raw), keyed by the
+// proto or JSON names of its fields. It fails with a
+// *configtree.UnknownKeysError for the keys that name no field, then
+// applies the defaults of the message and validates it, as
+// configtree.Decode does.{{.Doc}}
+func Decode{{.Name}}(settings map[string]interface{}) (*{{.Name}}, error) {
+    m := new({{.Name}})
+    if err := configtree.Decode(settings, {{.Schema}}, m); err != nil {
+        return nil, err
+    }
+    return m, nil
+}
+{{- end}}
+
+var {{.Schema}} = configtree.Schema{
+{{- range .Keys}}
+    {{printf "%q" .Name}}: {{.Schema}},
+{{- end}}
+}
+`))
+)
+
+func main() {
+	input, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	req := new(plugin.CodeGeneratorRequest)
+	if err = proto.Unmarshal(input, req); err != nil {
+		log.Fatal(err)
+	}
+
+	out, err := generate(req)
+
+	if err != nil {
+		emitError(err)
+		return
+	}
+
+	emitFiles(out)
+}
+
+func generate(req *plugin.CodeGeneratorRequest) ([]*plugin.CodeGeneratorResponse_File, error) {
+	var files []*plugin.CodeGeneratorResponse_File
+	idx := newTypeIndex(req.GetProtoFile())
+	docs := protodoc.New(req.GetProtoFile())
+	genFileNames := make(map[string]bool)
+	for _, n := range req.FileToGenerate {
+		genFileNames[n] = true
+	}
+	has := configMessages(idx, genFileNames)
+	for _, desc := range req.GetProtoFile() {
+		name := desc.GetName()
+		if _, ok := genFileNames[name]; !ok {
+			// Only emit output for files present in req.FileToGenerate.
+			continue
+		}
+		code, err := genCode(desc, idx, docs, has)
+		if err != nil {
+			return nil, err
+		}
+		if code == "" {
+			// The file declares no configuration messages, nor messages
+			// they hold.
+			continue
+		}
+		formatted, err := format.Source([]byte(code))
+		if err != nil {
+			log.Printf("%v: %s", err, code)
+			return nil, err
+		}
+
+		ext := filepath.Ext(name)
+		base := strings.TrimSuffix(name, ext)
+		output := fmt.Sprintf("%s.pb.config.go", base)
+		files = append(files, &plugin.CodeGeneratorResponse_File{
+			Name:    proto.String(output),
+			Content: proto.String(string(formatted)),
+		})
+	}
+
+	return files, nil
+}
+
+func genCode(desc *descriptor.FileDescriptorProto, idx *typeIndex, docs *protodoc.Index, has map[string]bool) (string, error) {
+	body := bytes.NewBuffer(nil)
+	err := walkMessages(strings.TrimSuffix("."+desc.GetPackage(), "."), desc.GetMessageType(), func(typeName string, msg *descriptor.DescriptorProto) error {
+		if !has[typeName] {
+			return nil
+		}
+		if schemaCycle(idx, has, typeName, typeName, make(map[string]bool)) {
+			return fmt.Errorf("%s: configuration messages cannot be recursive", strings.TrimPrefix(typeName, "."))
+		}
+		m := &configMessage{
+			Name:   localTypeName(typeName),
+			Config: options.Config(msg),
+			Schema: schemaVar(typeName),
+			Doc:    docs.Godoc(typeName),
+		}
+		for _, field := range msg.GetField() {
+			schema := "nil"
+			if t := field.GetTypeName(); has[t] && !idx.isMap(field) && sameGoPackage(idx, typeName, t) {
+				schema = schemaVar(t)
+			}
+			m.Keys = append(m.Keys, &configKey{Name: field.GetName(), Schema: schema})
+			if j := field.GetJsonName(); j != "" && j != field.GetName() {
+				m.Keys = append(m.Keys, &configKey{Name: j, Schema: schema})
+			}
+		}
+		return configTmpl.Execute(body, m)
+	})
+	if err != nil {
+		return "", fmt.Errorf("%s: %v", desc.GetName(), err)
+	}
+	if body.Len() == 0 {
+		return "", nil
+	}
+
+	hdr := &header{
+		Source: desc.GetName(),
+		GoPkg:  defaultGoPackageName(desc),
+	}
+	w := bytes.NewBuffer(nil)
+	if err := hdrTmpl.Execute(w, hdr); err != nil {
+		log.Fatal(err)
+	}
+	body.WriteTo(w)
+
+	return w.String(), nil
+}
+
+// walkMessages calls f with msgs and the messages nested in them, map
+// entries aside, and their full proto names. scope is the full proto name
+// of their parent.
+func walkMessages(scope string, msgs []*descriptor.DescriptorProto, f func(string, *descriptor.DescriptorProto) error) error {
+	for _, msg := range msgs {
+		if msg.GetOptions().GetMapEntry() {
+			continue
+		}
+		typeName := scope + "." + msg.GetName()
+		if err := f(typeName, msg); err != nil {
+			return err
+		}
+		if err := walkMessages(typeName, msg.GetNestedType(), f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// configMessages returns the generated configuration messages and the
+// generated messages of their message fields, maps aside, that belong to
+// the same Go package.
+func configMessages(idx *typeIndex, genFileNames map[string]bool) map[string]bool {
+	has := make(map[string]bool)
+	for typeName, msg := range idx.messages {
+		if genFileNames[idx.files[typeName].GetName()] && options.Config(msg) {
+			has[typeName] = true
+		}
+	}
+	// Repeat until no message is added, following the fields of the
+	// messages added last.
+	for changed := true; changed; {
+		changed = false
+		for typeName := range has {
+			for _, field := range idx.messages[typeName].GetField() {
+				t := field.GetTypeName()
+				if field.GetType() != descriptor.FieldDescriptorProto_TYPE_MESSAGE || has[t] || idx.isMap(field) ||
+					!genFileNames[idx.files[t].GetName()] || !sameGoPackage(idx, typeName, t) {
+					continue
+				}
+				has[t] = true
+				changed = true
+			}
+		}
+	}
+	return has
+}
+
+// schemaCycle reports whether the Schema of the message typeName refers,
+// directly or not, to that of target. seen holds the messages visited.
+func schemaCycle(idx *typeIndex, has map[string]bool, typeName, target string, seen map[string]bool) bool {
+	seen[typeName] = true
+	for _, field := range idx.messages[typeName].GetField() {
+		t := field.GetTypeName()
+		if !has[t] || idx.isMap(field) || !sameGoPackage(idx, typeName, t) {
+			continue
+		}
+		if t == target || (!seen[t] && schemaCycle(idx, has, t, target, seen)) {
+			return true
+		}
+	}
+	return false
+}
+
+// sameGoPackage reports whether the types a and b are generated in the
+// same Go package.
+func sameGoPackage(idx *typeIndex, a, b string) bool {
+	fa, fb := idx.files[a], idx.files[b]
+	if fa == fb {
+		return true
+	}
+	pa, pb := goImportPath(fa), goImportPath(fb)
+	if pa == "" || pb == "" {
+		return filepath.Dir(fa.GetName()) == filepath.Dir(fb.GetName())
+	}
+	return pa == pb
+}
+
+// schemaVar returns the name of the variable holding the Schema of the
+// message typeName.
+func schemaVar(typeName string) string {
+	return "configSchema" + localTypeName(typeName)
+}
+
+type header struct {
+	Source string
+	GoPkg  string
+}
+
+type configMessage struct {
+	Name   string
+	Config bool
+	Schema string
+	Keys   []*configKey
+	// Doc is the comment of the message, see protodoc.Index.Godoc.
+	Doc string
+}
+
+type configKey struct {
+	Name   string
+	Schema string
+}
+
+// typeIndex maps fully-qualified proto type names (".pkg.Msg") to their
+// descriptors and declaring files for every file in the request.
+type typeIndex struct {
+	messages map[string]*descriptor.DescriptorProto
+	enums    map[string]*descriptor.EnumDescriptorProto
+	files    map[string]*descriptor.FileDescriptorProto
+}
+
+func newTypeIndex(files []*descriptor.FileDescriptorProto) *typeIndex {
+	idx := &typeIndex{
+		messages: make(map[string]*descriptor.DescriptorProto),
+		enums:    make(map[string]*descriptor.EnumDescriptorProto),
+		files:    make(map[string]*descriptor.FileDescriptorProto),
+	}
+	for _, f := range files {
+		prefix := ""
+		if f.GetPackage() != "" {
+			prefix = "." + f.GetPackage()
+		}
+		idx.addMessages(f, prefix, f.GetMessageType())
+		idx.addEnums(f, prefix, f.GetEnumType())
+	}
+	return idx
+}
+
+func (idx *typeIndex) addMessages(f *descriptor.FileDescriptorProto, prefix string, msgs []*descriptor.DescriptorProto) {
+	for _, m := range msgs {
+		name := prefix + "." + m.GetName()
+		idx.messages[name] = m
+		idx.files[name] = f
+		idx.addMessages(f, name, m.GetNestedType())
+		idx.addEnums(f, name, m.GetEnumType())
+	}
+}
+
+func (idx *typeIndex) addEnums(f *descriptor.FileDescriptorProto, prefix string, enums []*descriptor.EnumDescriptorProto) {
+	for _, e := range enums {
+		name := prefix + "." + e.GetName()
+		idx.enums[name] = e
+		idx.files[name] = f
+	}
+}
+
+// isMap reports whether field is a map field.
+func (idx *typeIndex) isMap(field *descriptor.FieldDescriptorProto) bool {
+	if field.GetType() != descriptor.FieldDescriptorProto_TYPE_MESSAGE {
+		return false
+	}
+	return idx.messages[field.GetTypeName()].GetOptions().GetMapEntry()
+}
+
+// goImportPath returns the import path of the Go package generated from f,
+// or "" if go_package does not name one.
+func goImportPath(f *descriptor.FileDescriptorProto) string {
+	gopkg := f.GetOptions().GetGoPackage()
+	if sc := strings.IndexByte(gopkg, ';'); sc >= 0 {
+		gopkg = gopkg[:sc]
+	}
+	if !strings.Contains(gopkg, "/") {
+		return ""
+	}
+	return gopkg
+}
+
+// importSet records the Go packages referenced by the code generated for
+// one file, keyed by import path.
+type importSet struct {
+	self  string
+	names map[string]string
+}
+
+func newImportSet(f *descriptor.FileDescriptorProto) *importSet {
+	return &importSet{self: goImportPath(f), names: make(map[string]string)}
+}
+
+// goTypeName returns the Go name of the message or enum typeName, qualified
+// with its package if it is declared outside the generated package.
+func (s *importSet) goTypeName(idx *typeIndex, typeName string) string {
+	name := localTypeName(typeName)
+	f := idx.files[typeName]
+	path := goImportPath(f)
+	if f == nil || path == "" || path == s.self {
+		return name
+	}
+	pkg := defaultGoPackageName(f)
+	s.names[path] = pkg
+	return pkg + "." + name
+}
+
+// localTypeName returns the Go name of a type declared in the file being
+// generated, e.g. ".pkg.Outer.Inner" becomes "Outer_Inner".
+func localTypeName(typeName string) string {
+	parts := strings.Split(strings.TrimPrefix(typeName, "."), ".")
+	var names []string
+	for _, p := range parts {
+		if len(p) > 0 && p[0] >= 'A' && p[0] <= 'Z' {
+			names = append(names, p)
+		}
+	}
+	return strings.Join(names, "_")
+}
+
+// sanitizePackageName replaces unallowed character in package name
+// with allowed character.
+func sanitizePackageName(pkgName string) string {
+	pkgName = strings.Replace(pkgName, ".", "_", -1)
+	pkgName = strings.Replace(pkgName, "-", "_", -1)
+	return pkgName
+}
+
+// defaultGoPackageName returns the default go package name to be used for go files generated from "f".
+// You might need to use an unique alias for the package when you import it.  Use ReserveGoPackageAlias to get a unique alias.
+func defaultGoPackageName(f *descriptor.FileDescriptorProto) string {
+	name := packageIdentityName(f)
+	return sanitizePackageName(name)
+}
+
+// packageIdentityName returns the identity of packages.
+// protoc-gen-grpc-gateway rejects CodeGenerationRequests which contains more than one packages
+// as protoc-gen-go does.
+func packageIdentityName(f *descriptor.FileDescriptorProto) string {
+	if f.Options != nil && f.Options.GoPackage != nil {
+		gopkg := f.Options.GetGoPackage()
+		idx := strings.LastIndex(gopkg, "/")
+		if idx < 0 {
+			gopkg = gopkg[idx+1:]
+		}
+
+		gopkg = gopkg[idx+1:]
+		// package name is overrided with the string after the
+		// ';' character
+		sc := strings.IndexByte(gopkg, ';')
+		if sc < 0 {
+			return sanitizePackageName(gopkg)
+
+		}
+		return sanitizePackageName(gopkg[sc+1:])
+	}
+
+	if f.Package == nil {
+		base := filepath.Base(f.GetName())
+		ext := filepath.Ext(base)
+		return strings.TrimSuffix(base, ext)
+	}
+	return f.GetPackage()
+}
+
+func emitFiles(out []*plugin.CodeGeneratorResponse_File) {
+	emitResp(&plugin.CodeGeneratorResponse{File: out})
+}
+
+func emitError(err error) {
+	emitResp(&plugin.CodeGeneratorResponse{Error: proto.String(err.Error())})
+}
+
+func emitResp(resp *plugin.CodeGeneratorResponse) {
+	buf, err := proto.Marshal(resp)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if _, err := os.Stdout.Write(buf); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,431 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+
+	"github.com/f4tq/protoc-go-plugins/protodoc"
+)
+
+var (
+	hdrTmpl = template.Must(template.New("header").Parse(`
+// Code generated by protoc-gen-go-connect. DO NOT EDIT.
+// source: {{.Source}}
+
+package {{.GoPkg}}
+
+import (
+    "context"
+    "net/http"
+
+    "github.com/f4tq/protoc-go-plugins/runtime/connectrpc"
+    "github.com/golang/protobuf/proto"
+{{- range $path, $name := .Imports}}
+    {{$name}} "{{$path}}"
+{{- end}}
+)
+`))
+
+	connectTmpl = template.Must(template.New("connect").Parse(`
+// Register{{.Name}}ConnectHandlers registers the unary and server-streaming
+// methods of srv as Connect procedures on h. Methods with
+// idempotency_level = NO_SIDE_EFFECTS also accept GET requests. Client and
+// bidirectional streaming methods are served over gRPC only; see
+// connectrpc.WithGRPC.{{.Doc}}
+func Register{{.Name}}ConnectHandlers(h *connectrpc.Handler, srv {{.Name}}Server) {
+{{- range .Methods}}
+{{- if .ServerStreaming}}
+    h.HandleServerStream({{printf "%q" .Path}}, func() proto.Message { return new({{.Input}}) }, func(req proto.Message, stream *connectrpc.ServerStream) error {
+        return srv.{{.Name}}(req.(*{{.Input}}), &{{$.Lower}}{{.Name}}ConnectServer{stream})
+    })
+{{- else}}
+    h.HandleUnary({{printf "%q" .Path}}, func() proto.Message { return new({{.Input}}) }, func(ctx context.Context, req proto.Message) (proto.Message, error) {
+        return srv.{{.Name}}(ctx, req.(*{{.Input}}))
+    }, {{.Get}})
+{{- end}}
+{{- end}}
+}
+
+// New{{.Name}}ConnectHandler returns an http.Handler serving srv over the
+// Connect protocol.{{.Doc}}
+func New{{.Name}}ConnectHandler(srv {{.Name}}Server) http.Handler {
+    h := connectrpc.NewHandler()
+    Register{{.Name}}ConnectHandlers(h, srv)
+    return h
+}
+{{range .Methods}}{{if .ServerStreaming}}
+type {{$.Lower}}{{.Name}}ConnectServer struct {
+    *connectrpc.ServerStream
+}
+
+func (s *{{$.Lower}}{{.Name}}ConnectServer) Send(m *{{.Output}}) error {
+    return s.SendMsg(m)
+}
+{{end}}{{end}}
+// {{.Name}}ConnectClient calls {{.FullName}} over the Connect protocol.{{.Doc}}
+type {{.Name}}ConnectClient struct {
+    client *connectrpc.Client
+}
+
+// New{{.Name}}ConnectClient returns a {{.Name}}ConnectClient sending its
+// calls through client.{{.Doc}}
+func New{{.Name}}ConnectClient(client *connectrpc.Client) *{{.Name}}ConnectClient {
+    return &{{.Name}}ConnectClient{client: client}
+}
+{{range .Methods}}
+{{- if .ServerStreaming}}
+// {{.Name}} calls {{$.FullName}}.{{.Name}} and returns the stream of its
+// responses.{{.Doc}}
+func (c *{{$.Name}}ConnectClient) {{.Name}}(ctx context.Context, in *{{.Input}}) (*{{$.Name}}{{.Name}}ConnectStream, error) {
+    stream, err := c.client.CallServerStream(ctx, {{printf "%q" .Path}}, in)
+    if err != nil {
+        return nil, err
+    }
+    return &{{$.Name}}{{.Name}}ConnectStream{stream}, nil
+}
+
+// {{$.Name}}{{.Name}}ConnectStream receives the responses of a {{.Name}}
+// call.
+type {{$.Name}}{{.Name}}ConnectStream struct {
+    *connectrpc.ClientStream
+}
+
+// Recv returns the next response, or io.EOF once the stream ended
+// successfully.
+func (s *{{$.Name}}{{.Name}}ConnectStream) Recv() (*{{.Output}}, error) {
+    m := new({{.Output}})
+    if err := s.Receive(m); err != nil {
+        return nil, err
+    }
+    return m, nil
+}
+{{- else}}
+// {{.Name}} calls {{$.FullName}}.{{.Name}}.{{.Doc}}
+func (c *{{$.Name}}ConnectClient) {{.Name}}(ctx context.Context, in *{{.Input}}) (*{{.Output}}, error) {
+    out := new({{.Output}})
+    if err := c.client.CallUnary(ctx, {{printf "%q" .Path}}, in, out); err != nil {
+        return nil, err
+    }
+    return out, nil
+}
+{{- end}}
+{{end}}
+`))
+)
+
+func main() {
+	input, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	req := new(plugin.CodeGeneratorRequest)
+	if err = proto.Unmarshal(input, req); err != nil {
+		log.Fatal(err)
+	}
+
+	out, err := generate(req)
+
+	if err != nil {
+		emitError(err)
+		return
+	}
+
+	emitFiles(out)
+}
+
+func generate(req *plugin.CodeGeneratorRequest) ([]*plugin.CodeGeneratorResponse_File, error) {
+	var files []*plugin.CodeGeneratorResponse_File
+	idx := newTypeIndex(req.GetProtoFile())
+	docs := protodoc.New(req.GetProtoFile())
+	genFileNames := make(map[string]bool)
+	for _, n := range req.FileToGenerate {
+		genFileNames[n] = true
+	}
+	for _, desc := range req.GetProtoFile() {
+		name := desc.GetName()
+		if _, ok := genFileNames[name]; !ok {
+			// Only emit output for files present in req.FileToGenerate.
+			continue
+		}
+		code, err := genCode(desc, idx, docs)
+		if err != nil {
+			return nil, err
+		}
+		if code == "" {
+			// No method the Connect protocol can serve.
+			continue
+		}
+		formatted, err := format.Source([]byte(code))
+		if err != nil {
+			log.Printf("%v: %s", err, code)
+			return nil, err
+		}
+
+		ext := filepath.Ext(name)
+		base := strings.TrimSuffix(name, ext)
+		output := fmt.Sprintf("%s.pb.connect.go", base)
+		files = append(files, &plugin.CodeGeneratorResponse_File{
+			Name:    proto.String(output),
+			Content: proto.String(string(formatted)),
+		})
+	}
+
+	return files, nil
+}
+
+func genCode(desc *descriptor.FileDescriptorProto, idx *typeIndex, docs *protodoc.Index) (string, error) {
+	w := bytes.NewBuffer(nil)
+	imports := newImportSet(desc)
+	body := bytes.NewBuffer(nil)
+	for _, svc := range desc.GetService() {
+		fullName := svc.GetName()
+		if desc.GetPackage() != "" {
+			fullName = desc.GetPackage() + "." + svc.GetName()
+		}
+		s := &connectService{
+			Name:     svc.GetName(),
+			Lower:    strings.ToLower(svc.GetName()[:1]) + svc.GetName()[1:],
+			FullName: fullName,
+			Doc:      docs.Godoc(fullName),
+		}
+		for _, m := range svc.GetMethod() {
+			if m.GetClientStreaming() {
+				// Needs full-duplex HTTP; served over gRPC only.
+				continue
+			}
+			s.Methods = append(s.Methods, &connectMethod{
+				Name:            m.GetName(),
+				Path:            "/" + fullName + "/" + m.GetName(),
+				Input:           imports.goTypeName(idx, m.GetInputType()),
+				Output:          imports.goTypeName(idx, m.GetOutputType()),
+				ServerStreaming: m.GetServerStreaming(),
+				Get:             m.GetOptions().GetIdempotencyLevel() == descriptor.MethodOptions_NO_SIDE_EFFECTS,
+				Doc:             docs.Godoc(fullName + "." + m.GetName()),
+			})
+		}
+		if len(s.Methods) == 0 {
+			continue
+		}
+		if err := connectTmpl.Execute(body, s); err != nil {
+			return "", err
+		}
+	}
+	if body.Len() == 0 {
+		return "", nil
+	}
+
+	hdr := &header{
+		Source:  desc.GetName(),
+		GoPkg:   defaultGoPackageName(desc),
+		Imports: imports.names,
+	}
+	if err := hdrTmpl.Execute(w, hdr); err != nil {
+		log.Fatal(err)
+	}
+	body.WriteTo(w)
+
+	return w.String(), nil
+}
+
+type header struct {
+	Source  string
+	GoPkg   string
+	Imports map[string]string
+}
+
+type connectService struct {
+	Name     string
+	Lower    string
+	FullName string
+	Methods  []*connectMethod
+	// Doc is the comment of the service, see protodoc.Index.Godoc.
+	Doc string
+}
+
+type connectMethod struct {
+	Name            string
+	Path            string
+	Input           string
+	Output          string
+	ServerStreaming bool
+	Get             bool
+	// Doc is the comment of the method.
+	Doc string
+}
+
+// typeIndex maps fully-qualified proto type names (".pkg.Msg") to their
+// descriptors and declaring files for every file in the request.
+type typeIndex struct {
+	messages map[string]*descriptor.DescriptorProto
+	enums    map[string]*descriptor.EnumDescriptorProto
+	files    map[string]*descriptor.FileDescriptorProto
+}
+
+func newTypeIndex(files []*descriptor.FileDescriptorProto) *typeIndex {
+	idx := &typeIndex{
+		messages: make(map[string]*descriptor.DescriptorProto),
+		enums:    make(map[string]*descriptor.EnumDescriptorProto),
+		files:    make(map[string]*descriptor.FileDescriptorProto),
+	}
+	for _, f := range files {
+		prefix := ""
+		if f.GetPackage() != "" {
+			prefix = "." + f.GetPackage()
+		}
+		idx.addMessages(f, prefix, f.GetMessageType())
+		idx.addEnums(f, prefix, f.GetEnumType())
+	}
+	return idx
+}
+
+func (idx *typeIndex) addMessages(f *descriptor.FileDescriptorProto, prefix string, msgs []*descriptor.DescriptorProto) {
+	for _, m := range msgs {
+		name := prefix + "." + m.GetName()
+		idx.messages[name] = m
+		idx.files[name] = f
+		idx.addMessages(f, name, m.GetNestedType())
+		idx.addEnums(f, name, m.GetEnumType())
+	}
+}
+
+func (idx *typeIndex) addEnums(f *descriptor.FileDescriptorProto, prefix string, enums []*descriptor.EnumDescriptorProto) {
+	for _, e := range enums {
+		name := prefix + "." + e.GetName()
+		idx.enums[name] = e
+		idx.files[name] = f
+	}
+}
+
+// isMap reports whether field is a map field.
+func (idx *typeIndex) isMap(field *descriptor.FieldDescriptorProto) bool {
+	if field.GetType() != descriptor.FieldDescriptorProto_TYPE_MESSAGE {
+		return false
+	}
+	return idx.messages[field.GetTypeName()].GetOptions().GetMapEntry()
+}
+
+// goImportPath returns the import path of the Go package generated from f,
+// or "" if go_package does not name one.
+func goImportPath(f *descriptor.FileDescriptorProto) string {
+	gopkg := f.GetOptions().GetGoPackage()
+	if sc := strings.IndexByte(gopkg, ';'); sc >= 0 {
+		gopkg = gopkg[:sc]
+	}
+	if !strings.Contains(gopkg, "/") {
+		return ""
+	}
+	return gopkg
+}
+
+// importSet records the Go packages referenced by the code generated for
+// one file, keyed by import path.
+type importSet struct {
+	self  string
+	names map[string]string
+}
+
+func newImportSet(f *descriptor.FileDescriptorProto) *importSet {
+	return &importSet{self: goImportPath(f), names: make(map[string]string)}
+}
+
+// goTypeName returns the Go name of the message or enum typeName, qualified
+// with its package if it is declared outside the generated package.
+func (s *importSet) goTypeName(idx *typeIndex, typeName string) string {
+	name := localTypeName(typeName)
+	f := idx.files[typeName]
+	path := goImportPath(f)
+	if f == nil || path == "" || path == s.self {
+		return name
+	}
+	pkg := defaultGoPackageName(f)
+	s.names[path] = pkg
+	return pkg + "." + name
+}
+
+// localTypeName returns the Go name of a type declared in the file being
+// generated, e.g. ".pkg.Outer.Inner" becomes "Outer_Inner".
+func localTypeName(typeName string) string {
+	parts := strings.Split(strings.TrimPrefix(typeName, "."), ".")
+	var names []string
+	for _, p := range parts {
+		if len(p) > 0 && p[0] >= 'A' && p[0] <= 'Z' {
+			names = append(names, p)
+		}
+	}
+	return strings.Join(names, "_")
+}
+
+// sanitizePackageName replaces unallowed character in package name
+// with allowed character.
+func sanitizePackageName(pkgName string) string {
+	pkgName = strings.Replace(pkgName, ".", "_", -1)
+	pkgName = strings.Replace(pkgName, "-", "_", -1)
+	return pkgName
+}
+
+// defaultGoPackageName returns the default go package name to be used for go files generated from "f".
+// You might need to use an unique alias for the package when you import it.  Use ReserveGoPackageAlias to get a unique alias.
+func defaultGoPackageName(f *descriptor.FileDescriptorProto) string {
+	name := packageIdentityName(f)
+	return sanitizePackageName(name)
+}
+
+// packageIdentityName returns the identity of packages.
+// protoc-gen-grpc-gateway rejects CodeGenerationRequests which contains more than one packages
+// as protoc-gen-go does.
+func packageIdentityName(f *descriptor.FileDescriptorProto) string {
+	if f.Options != nil && f.Options.GoPackage != nil {
+		gopkg := f.Options.GetGoPackage()
+		idx := strings.LastIndex(gopkg, "/")
+		if idx < 0 {
+			gopkg = gopkg[idx+1:]
+		}
+
+		gopkg = gopkg[idx+1:]
+		// package name is overrided with the string after the
+		// ';' character
+		sc := strings.IndexByte(gopkg, ';')
+		if sc < 0 {
+			return sanitizePackageName(gopkg)
+
+		}
+		return sanitizePackageName(gopkg[sc+1:])
+	}
+
+	if f.Package == nil {
+		base := filepath.Base(f.GetName())
+		ext := filepath.Ext(base)
+		return strings.TrimSuffix(base, ext)
+	}
+	return f.GetPackage()
+}
+
+func emitFiles(out []*plugin.CodeGeneratorResponse_File) {
+	emitResp(&plugin.CodeGeneratorResponse{File: out})
+}
+
+func emitError(err error) {
+	emitResp(&plugin.CodeGeneratorResponse{Error: proto.String(err.Error())})
+}
+
+func emitResp(resp *plugin.CodeGeneratorResponse) {
+	buf, err := proto.Marshal(resp)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if _, err := os.Stdout.Write(buf); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,711 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+
+	"github.com/f4tq/protoc-go-plugins/goname"
+	"github.com/f4tq/protoc-go-plugins/options"
+	"github.com/f4tq/protoc-go-plugins/protodoc"
+)
+
+var (
+	hdrTmpl = template.Must(template.New("header").Parse(`
+// Code generated by protoc-gen-go-convert. DO NOT EDIT.
+// source: {{.Source}}
+
+package {{.GoPkg}}
+
+import (
+{{- if .Bytes}}
+    "bytes"
+{{- end}}
+{{- if .Maps}}
+    "maps"
+{{- end}}
+{{- if .Slices}}
+    "slices"
+{{- end}}
+{{if or .Bytes .Maps .Slices}}
+{{end}}
+{{- if .Proto}}
+    "github.com/golang/protobuf/proto"
+{{- end}}
+{{- range $path, $name := .Imports}}
+    {{$name}} "{{$path}}"
+{{- end}}
+)
+`))
+
+	convertTmpl = template.Must(template.New("convert").Parse(`
+// {{.Func}} converts in, of type {{.InName}}, to {{.OutName}},
+// matching fields by name, (f4tq.plugins.renamed_from) or number.
+{{- if .Skipped}}
+// Left to the hook: {{.Skipped}}.
+{{- end}}
+//
+// If {{.Self}} has a method {{.Hook}}({{.HookType}}) error, it is
+// called last, on {{.HookRecv}} with {{.HookArg}}, to complete the conversion.{{.Doc}}
+func {{.Func}}(in *{{.In}}) (*{{.Out}}, error) {
+    if in == nil {
+        return nil, nil
+    }
+    out := &{{.Out}}{}
+{{- range .Stmts}}
+{{.}}
+{{- end}}
+    if h, ok := interface{}({{.HookRecv}}).(interface{ {{.Hook}}({{.HookType}}) error }); ok {
+        if err := h.{{.Hook}}({{.HookArg}}); err != nil {
+            return nil, err
+        }
+    }
+    return out, nil
+}
+`))
+)
+
+func main() {
+	input, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	req := new(plugin.CodeGeneratorRequest)
+	if err = proto.Unmarshal(input, req); err != nil {
+		log.Fatal(err)
+	}
+
+	out, err := generate(req)
+
+	if err != nil {
+		emitError(err)
+		return
+	}
+
+	emitFiles(out)
+}
+
+func generate(req *plugin.CodeGeneratorRequest) ([]*plugin.CodeGeneratorResponse_File, error) {
+	var files []*plugin.CodeGeneratorResponse_File
+	idx := newTypeIndex(req.GetProtoFile())
+	docs := protodoc.New(req.GetProtoFile())
+	genFileNames := make(map[string]bool)
+	for _, n := range req.FileToGenerate {
+		genFileNames[n] = true
+	}
+	for _, desc := range req.GetProtoFile() {
+		name := desc.GetName()
+		if _, ok := genFileNames[name]; !ok {
+			// Only emit output for files present in req.FileToGenerate.
+			continue
+		}
+		code, err := genCode(desc, idx, docs, req.GetProtoFile())
+		if err != nil {
+			return nil, err
+		}
+		if code == "" {
+			// The file has no previous version, or no message carried
+			// over from it.
+			continue
+		}
+		formatted, err := format.Source([]byte(code))
+		if err != nil {
+			log.Printf("%v: %s", err, code)
+			return nil, err
+		}
+
+		ext := filepath.Ext(name)
+		base := strings.TrimSuffix(name, ext)
+		output := fmt.Sprintf("%s.pb.convert.go", base)
+		files = append(files, &plugin.CodeGeneratorResponse_File{
+			Name:    proto.String(output),
+			Content: proto.String(string(formatted)),
+		})
+	}
+
+	return files, nil
+}
+
+func genCode(desc *descriptor.FileDescriptorProto, idx *typeIndex, docs *protodoc.Index, all []*descriptor.FileDescriptorProto) (string, error) {
+	prev := options.PreviousVersion(desc)
+	if prev == "" {
+		return "", nil
+	}
+	found := false
+	for _, f := range all {
+		if f.GetPackage() == prev {
+			found = true
+		}
+	}
+	if !found {
+		return "", fmt.Errorf("%s: previous_version %s is not in the request; import it or generate it in the same run", desc.GetName(), prev)
+	}
+	w := bytes.NewBuffer(nil)
+	g := &convertGen{
+		idx:     idx,
+		docs:    docs,
+		imports: newImportSet(desc),
+		file:    desc,
+		newPkg:  "." + desc.GetPackage(),
+		oldPkg:  "." + prev,
+		suffix:  camelCase(prev[strings.LastIndexByte(prev, '.')+1:]),
+	}
+	body := bytes.NewBuffer(nil)
+	if err := g.messages(body, g.newPkg, desc.GetMessageType()); err != nil {
+		return "", err
+	}
+	if body.Len() == 0 {
+		return "", nil
+	}
+
+	hdr := &header{
+		Source:  desc.GetName(),
+		GoPkg:   defaultGoPackageName(desc),
+		Imports: g.imports.names,
+		Bytes:   g.bytes,
+		Maps:    g.maps,
+		Slices:  g.slices,
+		Proto:   g.proto,
+	}
+	if err := hdrTmpl.Execute(w, hdr); err != nil {
+		log.Fatal(err)
+	}
+	body.WriteTo(w)
+
+	return w.String(), nil
+}
+
+type convertGen struct {
+	idx     *typeIndex
+	docs    *protodoc.Index
+	imports *importSet
+	file    *descriptor.FileDescriptorProto
+	// newPkg and oldPkg are the proto packages of file and of its previous
+	// version, with a leading dot; suffix names the previous version in
+	// the converters, e.g. "V1alpha1".
+	newPkg, oldPkg string
+	suffix         string
+	// bytes, maps, slices and proto record the packages the code uses.
+	bytes, maps, slices, proto bool
+}
+
+// direction is one of the two conversions between a message and its
+// previous version.
+type direction struct {
+	// verb is "From" for the conversion from the previous version, "To"
+	// for the one to it.
+	verb           string
+	dstPkg, srcPkg string
+}
+
+// messages writes the converters of msgs, declared in scope, and of the
+// messages nested in them that exist in the previous version.
+func (g *convertGen) messages(w *bytes.Buffer, scope string, msgs []*descriptor.DescriptorProto) error {
+	for _, msg := range msgs {
+		if msg.GetOptions().GetMapEntry() {
+			continue
+		}
+		typeName := scope + "." + msg.GetName()
+		oldName := g.oldPkg + strings.TrimPrefix(typeName, g.newPkg)
+		if old, ok := g.idx.messages[oldName]; ok && !old.GetOptions().GetMapEntry() {
+			pairs := fieldPairs(msg, old)
+			from := direction{verb: "From", dstPkg: g.newPkg, srcPkg: g.oldPkg}
+			if err := g.convert(w, from, typeName, oldName, msg, old, pairs); err != nil {
+				return err
+			}
+			back := make(map[*descriptor.FieldDescriptorProto]*descriptor.FieldDescriptorProto, len(pairs))
+			for n, o := range pairs {
+				back[o] = n
+			}
+			to := direction{verb: "To", dstPkg: g.oldPkg, srcPkg: g.newPkg}
+			if err := g.convert(w, to, typeName, oldName, old, msg, back); err != nil {
+				return err
+			}
+		}
+		if err := g.messages(w, typeName, msg.GetNestedType()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fieldPairs maps the fields of msg to the fields of old they convert to and
+// from: the field of the same name or (f4tq.plugins.renamed_from), else
+// the field of the same number that no field matches by name.
+func fieldPairs(msg, old *descriptor.DescriptorProto) map[*descriptor.FieldDescriptorProto]*descriptor.FieldDescriptorProto {
+	byName := make(map[string]*descriptor.FieldDescriptorProto)
+	byNumber := make(map[int32]*descriptor.FieldDescriptorProto)
+	for _, f := range old.GetField() {
+		byName[f.GetName()] = f
+		byNumber[f.GetNumber()] = f
+	}
+	oldName := func(f *descriptor.FieldDescriptorProto) string {
+		if r := options.RenamedFrom(f); r != "" {
+			return r
+		}
+		return f.GetName()
+	}
+	claimed := make(map[string]bool)
+	for _, f := range msg.GetField() {
+		claimed[oldName(f)] = true
+	}
+	pairs := make(map[*descriptor.FieldDescriptorProto]*descriptor.FieldDescriptorProto)
+	for _, f := range msg.GetField() {
+		if o, ok := byName[oldName(f)]; ok {
+			pairs[f] = o
+		} else if o, ok := byNumber[f.GetNumber()]; ok && !claimed[o.GetName()] {
+			pairs[f] = o
+		}
+	}
+	return pairs
+}
+
+// convert writes the converter of dir from src, the message srcName, to
+// dst, the message dstName. pairs maps the fields of dst to those of src.
+func (g *convertGen) convert(w *bytes.Buffer, dir direction, newName, oldName string, dst, src *descriptor.DescriptorProto, pairs map[*descriptor.FieldDescriptorProto]*descriptor.FieldDescriptorProto) error {
+	dstName, srcName := newName, oldName
+	if dir.verb == "To" {
+		dstName, srcName = oldName, newName
+	}
+	c := &converter{
+		Func:    g.funcName(dir, newName),
+		In:      g.imports.goTypeName(g.idx, srcName),
+		Out:     g.imports.goTypeName(g.idx, dstName),
+		InName:  strings.TrimPrefix(srcName, "."),
+		OutName: strings.TrimPrefix(dstName, "."),
+		Self:    localTypeName(newName),
+		Hook:    "convert" + dir.verb + g.suffix,
+		Doc:     g.docs.Godoc(newName),
+	}
+	c.HookType = "*" + g.imports.goTypeName(g.idx, oldName)
+	c.HookRecv, c.HookArg = "out", "in"
+	if dir.verb == "To" {
+		c.HookRecv, c.HookArg = "in", "out"
+	}
+	dstProto3 := g.idx.files[dstName].GetSyntax() == "proto3"
+	srcProto3 := g.idx.files[srcName].GetSyntax() == "proto3"
+	dstNames, srcNames := goname.Fields(dst), goname.Fields(src)
+	// oneofs holds the cases of the type switch on each oneof of src.
+	oneofs := make(map[int32][]string)
+	var skipped []string
+	for _, d := range dst.GetField() {
+		s, ok := pairs[d]
+		if !ok || !g.compatible(dir, d, s, dstProto3, srcProto3) {
+			skipped = append(skipped, d.GetName())
+			continue
+		}
+		if isOneof(d) {
+			stmts, expr := g.elem(dir, d, s, "v."+srcNames[s.GetName()])
+			stmts = append(stmts, fmt.Sprintf("out.%s = &%s_%s{%s: %s}",
+				dstNames[dst.GetOneofDecl()[d.GetOneofIndex()].GetName()], c.Out, dstNames[d.GetName()], dstNames[d.GetName()], expr))
+			oneofs[s.GetOneofIndex()] = append(oneofs[s.GetOneofIndex()],
+				fmt.Sprintf("case *%s_%s:\n%s", c.In, srcNames[s.GetName()], strings.Join(stmts, "\n")))
+			continue
+		}
+		c.Stmts = append(c.Stmts, g.field(dir, d, s, "out."+dstNames[d.GetName()], "in."+srcNames[s.GetName()], isPointer(d, dstProto3)))
+	}
+	for i, decl := range src.GetOneofDecl() {
+		if cases := oneofs[int32(i)]; len(cases) > 0 {
+			c.Stmts = append(c.Stmts, fmt.Sprintf("switch v := in.%s.(type) {\n%s\n}", srcNames[decl.GetName()], strings.Join(cases, "\n")))
+		}
+	}
+	c.Skipped = strings.Join(skipped, ", ")
+	return convertTmpl.Execute(w, c)
+}
+
+// funcName returns the name of the converter of dir of the message newName.
+func (g *convertGen) funcName(dir direction, newName string) string {
+	return localTypeName(newName) + dir.verb + g.suffix
+}
+
+// field returns the statements setting the field D of dst to the converted
+// value of the field S of src.
+func (g *convertGen) field(dir direction, d, s *descriptor.FieldDescriptorProto, D, S string, pointer bool) string {
+	switch {
+	case g.idx.isMap(d):
+		dk := g.idx.messages[d.GetTypeName()].GetField()[0]
+		dv := g.idx.messages[d.GetTypeName()].GetField()[1]
+		sv := g.idx.messages[s.GetTypeName()].GetField()[1]
+		stmts, expr := g.elem(dir, dv, sv, "e")
+		if len(stmts) == 0 && expr == "e" {
+			g.maps = true
+			return fmt.Sprintf("%s = maps.Clone(%s)", D, S)
+		}
+		stmts = append(stmts, fmt.Sprintf("%s[k] = %s", D, expr))
+		return fmt.Sprintf("if %s != nil {\n%s = make(map[%s]%s, len(%s))\nfor k, e := range %s {\n%s\n}\n}",
+			S, D, scalarGoType(dk.GetType()), g.goType(dv), S, S, strings.Join(stmts, "\n"))
+	case d.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REPEATED:
+		stmts, expr := g.elem(dir, d, s, "e")
+		if len(stmts) == 0 && expr == "e" {
+			g.slices = true
+			return fmt.Sprintf("%s = slices.Clone(%s)", D, S)
+		}
+		stmts = append(stmts, fmt.Sprintf("%s = append(%s, %s)", D, D, expr))
+		return fmt.Sprintf("for _, e := range %s {\n%s\n}", S, strings.Join(stmts, "\n"))
+	case pointer:
+		_, expr := g.elem(dir, d, s, "*"+S)
+		return fmt.Sprintf("if %s != nil {\nv := %s\n%s = &v\n}", S, expr, D)
+	case isMessage(d):
+		stmts, expr := g.elem(dir, d, s, S)
+		stmts = append(stmts, fmt.Sprintf("%s = %s", D, expr))
+		return fmt.Sprintf("if %s != nil {\n%s\n}", S, strings.Join(stmts, "\n"))
+	}
+	_, expr := g.elem(dir, d, s, S)
+	return fmt.Sprintf("%s = %s", D, expr)
+}
+
+// elem returns the statements and the expression converting src, a value
+// of the field s, to a value of the field d.
+func (g *convertGen) elem(dir direction, d, s *descriptor.FieldDescriptorProto, src string) ([]string, string) {
+	switch {
+	case isMessage(d) && d.GetTypeName() == s.GetTypeName():
+		g.proto = true
+		return nil, fmt.Sprintf("proto.Clone(%s).(%s)", src, g.goType(d))
+	case isMessage(d):
+		newName := d.GetTypeName()
+		if dir.verb == "To" {
+			newName = s.GetTypeName()
+		}
+		return []string{
+			fmt.Sprintf("c, err := %s(%s)", g.funcName(dir, newName), src),
+			"if err != nil {\nreturn nil, err\n}",
+		}, "c"
+	case d.GetType() == descriptor.FieldDescriptorProto_TYPE_ENUM && d.GetTypeName() != s.GetTypeName():
+		return nil, fmt.Sprintf("%s(%s)", g.goType(d), src)
+	case d.GetType() == descriptor.FieldDescriptorProto_TYPE_BYTES:
+		g.bytes = true
+		return nil, fmt.Sprintf("bytes.Clone(%s)", src)
+	}
+	return nil, src
+}
+
+// compatible reports whether the field s converts to the field d: both
+// have the same shape and their values have the same type, or types
+// carried over from the previous version.
+func (g *convertGen) compatible(dir direction, d, s *descriptor.FieldDescriptorProto, dstProto3, srcProto3 bool) bool {
+	switch {
+	case d.GetLabel() != s.GetLabel(), g.idx.isMap(d) != g.idx.isMap(s), isOneof(d) != isOneof(s),
+		isPointer(d, dstProto3) != isPointer(s, srcProto3):
+		return false
+	case g.idx.isMap(d):
+		dEntry := g.idx.messages[d.GetTypeName()].GetField()
+		sEntry := g.idx.messages[s.GetTypeName()].GetField()
+		return dEntry[0].GetType() == sEntry[0].GetType() && g.compatibleElem(dir, dEntry[1], sEntry[1])
+	}
+	return g.compatibleElem(dir, d, s)
+}
+
+// compatibleElem reports whether a value of the field s converts to a value
+// of the field d.
+func (g *convertGen) compatibleElem(dir direction, d, s *descriptor.FieldDescriptorProto) bool {
+	switch {
+	case d.GetType() != s.GetType():
+		return false
+	case d.GetTypeName() == s.GetTypeName():
+		return true
+	}
+	rel := strings.TrimPrefix(d.GetTypeName(), dir.dstPkg+".")
+	if rel == d.GetTypeName() || s.GetTypeName() != dir.srcPkg+"."+rel {
+		return false
+	}
+	if d.GetType() == descriptor.FieldDescriptorProto_TYPE_ENUM {
+		return true
+	}
+	// The converters of messages exist for the messages of this file only.
+	return g.idx.files[g.newPkg+"."+rel] == g.file && g.idx.messages[g.oldPkg+"."+rel] != nil
+}
+
+func isMessage(field *descriptor.FieldDescriptorProto) bool {
+	return field.GetType() == descriptor.FieldDescriptorProto_TYPE_MESSAGE ||
+		field.GetType() == descriptor.FieldDescriptorProto_TYPE_GROUP
+}
+
+// isOneof reports whether field is a member of a oneof other than the
+// synthetic oneof of a proto3 optional field.
+func isOneof(field *descriptor.FieldDescriptorProto) bool {
+	return field.OneofIndex != nil && !field.GetProto3Optional()
+}
+
+// isPointer reports whether the Go field of field is a pointer to a scalar.
+func isPointer(field *descriptor.FieldDescriptorProto, proto3 bool) bool {
+	return field.GetLabel() != descriptor.FieldDescriptorProto_LABEL_REPEATED && !isMessage(field) && !isOneof(field) &&
+		field.GetType() != descriptor.FieldDescriptorProto_TYPE_BYTES && (field.GetProto3Optional() || !proto3)
+}
+
+// goType returns the Go type of a value of field.
+func (g *convertGen) goType(field *descriptor.FieldDescriptorProto) string {
+	switch field.GetType() {
+	case descriptor.FieldDescriptorProto_TYPE_MESSAGE, descriptor.FieldDescriptorProto_TYPE_GROUP:
+		return "*" + g.imports.goTypeName(g.idx, field.GetTypeName())
+	case descriptor.FieldDescriptorProto_TYPE_ENUM:
+		return g.imports.goTypeName(g.idx, field.GetTypeName())
+	case descriptor.FieldDescriptorProto_TYPE_BYTES:
+		return "[]byte"
+	}
+	return scalarGoType(field.GetType())
+}
+
+// scalarGoType returns the Go type of the scalar type t.
+func scalarGoType(t descriptor.FieldDescriptorProto_Type) string {
+	switch t {
+	case descriptor.FieldDescriptorProto_TYPE_DOUBLE:
+		return "float64"
+	case descriptor.FieldDescriptorProto_TYPE_FLOAT:
+		return "float32"
+	case descriptor.FieldDescriptorProto_TYPE_INT64, descriptor.FieldDescriptorProto_TYPE_SINT64,
+		descriptor.FieldDescriptorProto_TYPE_SFIXED64:
+		return "int64"
+	case descriptor.FieldDescriptorProto_TYPE_UINT64, descriptor.FieldDescriptorProto_TYPE_FIXED64:
+		return "uint64"
+	case descriptor.FieldDescriptorProto_TYPE_INT32, descriptor.FieldDescriptorProto_TYPE_SINT32,
+		descriptor.FieldDescriptorProto_TYPE_SFIXED32:
+		return "int32"
+	case descriptor.FieldDescriptorProto_TYPE_UINT32, descriptor.FieldDescriptorProto_TYPE_FIXED32:
+		return "uint32"
+	case descriptor.FieldDescriptorProto_TYPE_BOOL:
+		return "bool"
+	}
+	return "string"
+}
+
+type header struct {
+	Source  string
+	GoPkg   string
+	Imports map[string]string
+	Bytes   bool
+	Maps    bool
+	Slices  bool
+	Proto   bool
+}
+
+type converter struct {
+	Func, In, Out   string
+	InName, OutName string
+	// Self is the Go name of the message of the file, which declares the
+	// hook.
+	Self              string
+	Hook, HookType    string
+	HookRecv, HookArg string
+	Stmts             []string
+	Skipped           string
+	// Doc is the comment of the message of the file, see
+	// protodoc.Index.Godoc.
+	Doc string
+}
+
+// typeIndex maps fully-qualified proto type names (".pkg.Msg") to their
+// descriptors and declaring files for every file in the request.
+type typeIndex struct {
+	messages map[string]*descriptor.DescriptorProto
+	enums    map[string]*descriptor.EnumDescriptorProto
+	files    map[string]*descriptor.FileDescriptorProto
+}
+
+func newTypeIndex(files []*descriptor.FileDescriptorProto) *typeIndex {
+	idx := &typeIndex{
+		messages: make(map[string]*descriptor.DescriptorProto),
+		enums:    make(map[string]*descriptor.EnumDescriptorProto),
+		files:    make(map[string]*descriptor.FileDescriptorProto),
+	}
+	for _, f := range files {
+		prefix := ""
+		if f.GetPackage() != "" {
+			prefix = "." + f.GetPackage()
+		}
+		idx.addMessages(f, prefix, f.GetMessageType())
+		idx.addEnums(f, prefix, f.GetEnumType())
+	}
+	return idx
+}
+
+func (idx *typeIndex) addMessages(f *descriptor.FileDescriptorProto, prefix string, msgs []*descriptor.DescriptorProto) {
+	for _, m := range msgs {
+		name := prefix + "." + m.GetName()
+		idx.messages[name] = m
+		idx.files[name] = f
+		idx.addMessages(f, name, m.GetNestedType())
+		idx.addEnums(f, name, m.GetEnumType())
+	}
+}
+
+func (idx *typeIndex) addEnums(f *descriptor.FileDescriptorProto, prefix string, enums []*descriptor.EnumDescriptorProto) {
+	for _, e := range enums {
+		name := prefix + "." + e.GetName()
+		idx.enums[name] = e
+		idx.files[name] = f
+	}
+}
+
+// isMap reports whether field is a map field.
+func (idx *typeIndex) isMap(field *descriptor.FieldDescriptorProto) bool {
+	if field.GetType() != descriptor.FieldDescriptorProto_TYPE_MESSAGE {
+		return false
+	}
+	return idx.messages[field.GetTypeName()].GetOptions().GetMapEntry()
+}
+
+// goImportPath returns the import path of the Go package generated from f,
+// or "" if go_package does not name one.
+func goImportPath(f *descriptor.FileDescriptorProto) string {
+	gopkg := f.GetOptions().GetGoPackage()
+	if sc := strings.IndexByte(gopkg, ';'); sc >= 0 {
+		gopkg = gopkg[:sc]
+	}
+	if !strings.Contains(gopkg, "/") {
+		return ""
+	}
+	return gopkg
+}
+
+// importSet records the Go packages referenced by the code generated for
+// one file, keyed by import path.
+type importSet struct {
+	self  string
+	names map[string]string
+}
+
+func newImportSet(f *descriptor.FileDescriptorProto) *importSet {
+	return &importSet{self: goImportPath(f), names: make(map[string]string)}
+}
+
+// goTypeName returns the Go name of the message or enum typeName, qualified
+// with its package if it is declared outside the generated package.
+func (s *importSet) goTypeName(idx *typeIndex, typeName string) string {
+	name := localTypeName(typeName)
+	f := idx.files[typeName]
+	path := goImportPath(f)
+	if f == nil || path == "" || path == s.self {
+		return name
+	}
+	pkg := defaultGoPackageName(f)
+	s.names[path] = pkg
+	return pkg + "." + name
+}
+
+// localTypeName returns the Go name of a type declared in the file being
+// generated, e.g. ".pkg.Outer.Inner" becomes "Outer_Inner".
+func localTypeName(typeName string) string {
+	parts := strings.Split(strings.TrimPrefix(typeName, "."), ".")
+	var names []string
+	for _, p := range parts {
+		if len(p) > 0 && p[0] >= 'A' && p[0] <= 'Z' {
+			names = append(names, p)
+		}
+	}
+	return strings.Join(names, "_")
+}
+
+// camelCase returns the Go field name protoc-gen-go derives from s.
+func camelCase(s string) string {
+	if s == "" {
+		return ""
+	}
+	t := make([]byte, 0, 32)
+	i := 0
+	if s[0] == '_' {
+		// Need a capital letter; drop the '_'.
+		t = append(t, 'X')
+		i++
+	}
+	for ; i < len(s); i++ {
+		c := s[i]
+		if c == '_' && i+1 < len(s) && isASCIILower(s[i+1]) {
+			continue
+		}
+		if isASCIIDigit(c) {
+			t = append(t, c)
+			continue
+		}
+		if isASCIILower(c) {
+			c ^= ' '
+		}
+		t = append(t, c)
+		for i+1 < len(s) && isASCIILower(s[i+1]) {
+			i++
+			t = append(t, s[i])
+		}
+	}
+	return string(t)
+}
+
+func isASCIILower(c byte) bool {
+	return 'a' <= c && c <= 'z'
+}
+
+func isASCIIDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+// sanitizePackageName replaces unallowed character in package name
+// with allowed character.
+func sanitizePackageName(pkgName string) string {
+	pkgName = strings.Replace(pkgName, ".", "_", -1)
+	pkgName = strings.Replace(pkgName, "-", "_", -1)
+	return pkgName
+}
+
+// defaultGoPackageName returns the default go package name to be used for go files generated from "f".
+// You might need to use an unique alias for the package when you import it.  Use ReserveGoPackageAlias to get a unique alias.
+func defaultGoPackageName(f *descriptor.FileDescriptorProto) string {
+	name := packageIdentityName(f)
+	return sanitizePackageName(name)
+}
+
+// packageIdentityName returns the identity of packages.
+// protoc-gen-grpc-gateway rejects CodeGenerationRequests which contains more than one packages
+// as protoc-gen-go does.
+func packageIdentityName(f *descriptor.FileDescriptorProto) string {
+	if f.Options != nil && f.Options.GoPackage != nil {
+		gopkg := f.Options.GetGoPackage()
+		idx := strings.LastIndex(gopkg, "/")
+		if idx < 0 {
+			gopkg = gopkg[idx+1:]
+		}
+
+		gopkg = gopkg[idx+1:]
+		// package name is overrided with the string after the
+		// ';' character
+		sc := strings.IndexByte(gopkg, ';')
+		if sc < 0 {
+			return sanitizePackageName(gopkg)
+
+		}
+		return sanitizePackageName(gopkg[sc+1:])
+	}
+
+	if f.Package == nil {
+		base := filepath.Base(f.GetName())
+		ext := filepath.Ext(base)
+		return strings.TrimSuffix(base, ext)
+	}
+	return f.GetPackage()
+}
+
+func emitFiles(out []*plugin.CodeGeneratorResponse_File) {
+	emitResp(&plugin.CodeGeneratorResponse{File: out})
+}
+
+func emitError(err error) {
+	emitResp(&plugin.CodeGeneratorResponse{Error: proto.String(err.Error())})
+}
+
+func emitResp(resp *plugin.CodeGeneratorResponse) {
+	buf, err := proto.Marshal(resp)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if _, err := os.Stdout.Write(buf); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,356 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+
+	"github.com/f4tq/protoc-go-plugins/options"
+)
+
+func main() {
+	input, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	req := new(plugin.CodeGeneratorRequest)
+	if err = proto.Unmarshal(input, req); err != nil {
+		log.Fatal(err)
+	}
+
+	out, err := generate(req)
+
+	if err != nil {
+		emitError(err)
+		return
+	}
+
+	emitFiles(out)
+}
+
+// generate writes <name>.json, the report of what the plugins of the
+// plugins parameter, separated by "+", generate for the messages and
+// services of the files to generate, and why they skip the others. name is
+// the parameter of that name, "coverage" by default; the plugins are all
+// those of coverers by default.
+//
+// The report follows the rules each plugin selects its messages and
+// services by; it does not run them, so the errors they would fail on are
+// not reported.
+func generate(req *plugin.CodeGeneratorRequest) ([]*plugin.CodeGeneratorResponse_File, error) {
+	params := parseParams(req.GetParameter())
+	name := params["name"]
+	if name == "" {
+		name = "coverage"
+	}
+	r := &report{Elements: []*element{}, Summary: make(map[string]*tally)}
+	if p := params["plugins"]; p != "" {
+		r.Plugins = strings.Split(p, "+")
+	} else {
+		for p := range coverers {
+			r.Plugins = append(r.Plugins, p)
+		}
+	}
+	sort.Strings(r.Plugins)
+	for _, p := range r.Plugins {
+		if coverers[p] == nil {
+			return nil, fmt.Errorf("unknown plugin %q", p)
+		}
+		r.Summary[p] = new(tally)
+	}
+
+	genFileNames := make(map[string]bool)
+	for _, n := range req.FileToGenerate {
+		genFileNames[n] = true
+	}
+	checked := false
+	for _, desc := range req.GetProtoFile() {
+		if _, ok := genFileNames[desc.GetName()]; !ok {
+			// Only report the files present in req.FileToGenerate.
+			continue
+		}
+		checked = true
+		r.file(desc)
+	}
+	if !checked {
+		return nil, nil
+	}
+
+	b, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return []*plugin.CodeGeneratorResponse_File{{
+		Name:    proto.String(name + ".json"),
+		Content: proto.String(string(b) + "\n"),
+	}}, nil
+}
+
+type report struct {
+	// Plugins are the names of the plugins reported.
+	Plugins  []string   `json:"plugins"`
+	Elements []*element `json:"elements"`
+	// Summary counts the elements each plugin generates for and skips.
+	Summary map[string]*tally `json:"summary"`
+}
+
+// element is a message or service, with what the plugins generate for it.
+type element struct {
+	File string `json:"file"`
+	// Kind is "message" or "service".
+	Kind      string      `json:"kind"`
+	Name      string      `json:"name"`
+	Artifacts []*artifact `json:"artifacts"`
+	Skipped   []*skip     `json:"skipped"`
+}
+
+type artifact struct {
+	Plugin string `json:"plugin"`
+	// Outputs are the files holding the symbols.
+	Outputs []string `json:"outputs"`
+	// Symbols are the Go declarations generated for the element.
+	Symbols []string `json:"symbols"`
+}
+
+// skip is why a plugin generates nothing for an element, or for one of its
+// methods.
+type skip struct {
+	Plugin string `json:"plugin"`
+	Method string `json:"method,omitempty"`
+	Reason string `json:"reason"`
+}
+
+type tally struct {
+	Generated int `json:"generated"`
+	Skipped   int `json:"skipped"`
+}
+
+// coverer holds the rules a plugin selects the messages and services it
+// generates for by. message and service return the symbols generated for
+// the message or service, named goName in Go, and the reasons for what
+// they skip; either is nil for the plugins generating nothing for that kind
+// of element.
+type coverer struct {
+	// outputs are the suffixes of the files the plugin writes for a file.
+	outputs []string
+	message func(msg *descriptor.DescriptorProto, goName string, nested bool) ([]string, []*skip)
+	service func(svc *descriptor.ServiceDescriptorProto, goName string) ([]string, []*skip)
+}
+
+// coverers are the plugins of the report, by the name of their generator
+// without the protoc-gen-go- prefix.
+var coverers = map[string]*coverer{
+	"jsonpb": {
+		outputs: []string{".pb.jsonpb.go"},
+		message: func(msg *descriptor.DescriptorProto, goName string, nested bool) ([]string, []*skip) {
+			if nested {
+				return nil, []*skip{{Reason: "nested messages are not generated"}}
+			}
+			return []string{goName + ".MarshalJSON", goName + ".UnmarshalJSON"}, nil
+		},
+	},
+	"validate": {
+		outputs: []string{".pb.validate.go"},
+		message: func(msg *descriptor.DescriptorProto, goName string, nested bool) ([]string, []*skip) {
+			return []string{goName + ".Validate", goName + ".ValidateAll"}, nil
+		},
+	},
+	"redact": {
+		outputs: []string{".pb.redact.go"},
+		message: func(msg *descriptor.DescriptorProto, goName string, nested bool) ([]string, []*skip) {
+			return []string{goName + ".Redact", goName + ".RedactInPlace"}, nil
+		},
+	},
+	"testifymock": {
+		outputs: []string{".pb.testifymock.go"},
+		service: func(svc *descriptor.ServiceDescriptorProto, goName string) ([]string, []*skip) {
+			symbols := []string{goName + "ClientMock"}
+			for _, m := range svc.GetMethod() {
+				symbols = append(symbols, goName+"ClientMock.On"+m.GetName())
+			}
+			return symbols, nil
+		},
+	},
+	"kafka": {
+		outputs: []string{".pb.kafka.go"},
+		message: func(msg *descriptor.DescriptorProto, goName string, nested bool) ([]string, []*skip) {
+			if options.Topic(msg) == "" {
+				return nil, []*skip{{Reason: "no (f4tq.plugins.topic) option"}}
+			}
+			return []string{
+				goName + "KafkaTopic",
+				"New" + goName + "KafkaProducer",
+				"New" + goName + "ConsumerGroupHandler",
+			}, nil
+		},
+	},
+	"nats": {
+		outputs: []string{".pb.nats.go"},
+		message: func(msg *descriptor.DescriptorProto, goName string, nested bool) ([]string, []*skip) {
+			if options.Topic(msg) == "" {
+				return nil, []*skip{{Reason: "no (f4tq.plugins.topic) option"}}
+			}
+			return []string{"Publish" + goName + "NATS", "Subscribe" + goName + "NATS"}, nil
+		},
+		service: func(svc *descriptor.ServiceDescriptorProto, goName string) ([]string, []*skip) {
+			var methods []string
+			var skipped []*skip
+			for _, m := range svc.GetMethod() {
+				if m.GetClientStreaming() || m.GetServerStreaming() {
+					skipped = append(skipped, &skip{Method: m.GetName(), Reason: "streaming methods are served over gRPC only"})
+					continue
+				}
+				methods = append(methods, goName+"NATSClient."+m.GetName())
+			}
+			if len(methods) == 0 {
+				return nil, skipped
+			}
+			return append([]string{"Serve" + goName + "NATS", "New" + goName + "NATSClient"}, methods...), skipped
+		},
+	},
+	"crdschema": {
+		outputs: []string{".pb.crdschema.go", ".crdschema.yaml"},
+		message: func(msg *descriptor.DescriptorProto, goName string, nested bool) ([]string, []*skip) {
+			if options.CRD(msg) == nil {
+				return nil, []*skip{{Reason: "no (f4tq.plugins.crd) option"}}
+			}
+			return []string{goName + "CRDValidation"}, nil
+		},
+	},
+	"terraform": {
+		outputs: []string{".pb.terraform.go"},
+		message: func(msg *descriptor.DescriptorProto, goName string, nested bool) ([]string, []*skip) {
+			if options.TerraformResource(msg) == "" {
+				return nil, []*skip{{Reason: "no (f4tq.plugins.terraform_resource) option; other messages only get models as the fields of resources"}}
+			}
+			return []string{
+				goName + "Model",
+				goName + "ToTerraform",
+				goName + "FromTerraform",
+				goName + "TerraformTypeName",
+				goName + "TerraformSchema",
+			}, nil
+		},
+	},
+}
+
+func (r *report) file(desc *descriptor.FileDescriptorProto) {
+	base := strings.TrimSuffix(desc.GetName(), filepath.Ext(desc.GetName()))
+	r.messages(desc, base, "", desc.GetPackage(), desc.GetMessageType())
+	for _, svc := range desc.GetService() {
+		e := r.element(desc, "service", qualify(desc.GetPackage(), svc.GetName()))
+		for _, p := range r.Plugins {
+			c := coverers[p]
+			if c.service == nil {
+				continue
+			}
+			symbols, skipped := c.service(svc, svc.GetName())
+			r.add(e, p, base, symbols, skipped)
+		}
+	}
+}
+
+// messages reports msgs and the messages nested in them. prefix is the Go
+// name of the enclosing message plus "_", and scope its full proto name.
+func (r *report) messages(desc *descriptor.FileDescriptorProto, base, prefix, scope string, msgs []*descriptor.DescriptorProto) {
+	for _, msg := range msgs {
+		if msg.GetOptions().GetMapEntry() {
+			continue
+		}
+		goName := prefix + msg.GetName()
+		name := qualify(scope, msg.GetName())
+		e := r.element(desc, "message", name)
+		for _, p := range r.Plugins {
+			c := coverers[p]
+			if c.message == nil {
+				continue
+			}
+			symbols, skipped := c.message(msg, goName, prefix != "")
+			r.add(e, p, base, symbols, skipped)
+		}
+		r.messages(desc, base, goName+"_", name, msg.GetNestedType())
+	}
+}
+
+func (r *report) element(desc *descriptor.FileDescriptorProto, kind, name string) *element {
+	e := &element{
+		File:      desc.GetName(),
+		Kind:      kind,
+		Name:      name,
+		Artifacts: []*artifact{},
+		Skipped:   []*skip{},
+	}
+	r.Elements = append(r.Elements, e)
+	return e
+}
+
+// add records what the plugin p generates for e, in the files of base, or
+// skips.
+func (r *report) add(e *element, p, base string, symbols []string, skipped []*skip) {
+	for _, s := range skipped {
+		s.Plugin = p
+	}
+	e.Skipped = append(e.Skipped, skipped...)
+	if len(symbols) == 0 {
+		r.Summary[p].Skipped++
+		return
+	}
+	a := &artifact{Plugin: p, Symbols: symbols}
+	for _, suffix := range coverers[p].outputs {
+		a.Outputs = append(a.Outputs, base+suffix)
+	}
+	e.Artifacts = append(e.Artifacts, a)
+	r.Summary[p].Generated++
+}
+
+// qualify returns the full proto name of name, declared in scope.
+func qualify(scope, name string) string {
+	if scope == "" {
+		return name
+	}
+	return scope + "." + name
+}
+
+// parseParams splits the comma separated key=value plugin parameter.
+func parseParams(param string) map[string]string {
+	params := make(map[string]string)
+	for _, p := range strings.Split(param, ",") {
+		if p == "" {
+			continue
+		}
+		if i := strings.IndexByte(p, '='); i >= 0 {
+			params[p[:i]] = p[i+1:]
+		} else {
+			params[p] = ""
+		}
+	}
+	return params
+}
+
+func emitFiles(out []*plugin.CodeGeneratorResponse_File) {
+	emitResp(&plugin.CodeGeneratorResponse{File: out})
+}
+
+func emitError(err error) {
+	emitResp(&plugin.CodeGeneratorResponse{Error: proto.String(err.Error())})
+}
+
+func emitResp(resp *plugin.CodeGeneratorResponse) {
+	buf, err := proto.Marshal(resp)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if _, err := os.Stdout.Write(buf); err != nil {
+		log.Fatal(err)
+	}
+}
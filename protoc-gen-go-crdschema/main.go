@@ -0,0 +1,674 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+
+	"github.com/f4tq/protoc-go-plugins/goname"
+	"github.com/f4tq/protoc-go-plugins/options"
+	"github.com/f4tq/protoc-go-plugins/protodoc"
+)
+
+// objectMetaType is the proto type of the Kubernetes object metadata.
+const objectMetaType = ".k8s.io.apimachinery.pkg.apis.meta.v1.ObjectMeta"
+
+var (
+	hdrTmpl = template.Must(template.New("header").Parse(`
+// Code generated by protoc-gen-go-crdschema. DO NOT EDIT.
+// source: {{.Source}}
+
+package {{.GoPkg}}
+
+import (
+    apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+{{- if .Ptr}}
+
+    "github.com/f4tq/protoc-go-plugins/runtime/crdschema"
+{{- end}}
+)
+`))
+
+	validationTmpl = template.Must(template.New("validation").Parse(`
+// {{.Name}}CRDValidation returns the structural schema of the
+// {{.Kind}} custom resource, for its version in the
+// CustomResourceDefinition. It returns a new value on each call.{{.Doc}}
+func {{.Name}}CRDValidation() *apiextensionsv1.CustomResourceValidation {
+    return &apiextensionsv1.CustomResourceValidation{
+        OpenAPIV3Schema: &{{.Schema}},
+    }
+}
+`))
+
+	yamlTmpl = template.Must(template.New("yaml").Parse(`# Code generated by protoc-gen-go-crdschema. DO NOT EDIT.
+# source: {{.Source}}
+#
+# The structural schema of each custom resource, for its version in the
+# CustomResourceDefinition.
+{{range .Resources -}}
+---
+# {{.Kind}}, {{.APIVersion}}.
+openAPIV3Schema:
+{{.YAML}}
+{{- end}}`))
+)
+
+func main() {
+	input, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	req := new(plugin.CodeGeneratorRequest)
+	if err = proto.Unmarshal(input, req); err != nil {
+		log.Fatal(err)
+	}
+
+	out, err := generate(req)
+
+	if err != nil {
+		emitError(err)
+		return
+	}
+
+	emitFiles(out)
+}
+
+func generate(req *plugin.CodeGeneratorRequest) ([]*plugin.CodeGeneratorResponse_File, error) {
+	var files []*plugin.CodeGeneratorResponse_File
+	idx := newTypeIndex(req.GetProtoFile())
+	docs := protodoc.New(req.GetProtoFile())
+	genFileNames := make(map[string]bool)
+	for _, n := range req.FileToGenerate {
+		genFileNames[n] = true
+	}
+	for _, desc := range req.GetProtoFile() {
+		name := desc.GetName()
+		if _, ok := genFileNames[name]; !ok {
+			// Only emit output for files present in req.FileToGenerate.
+			continue
+		}
+		code, yaml, err := genCode(desc, idx, docs)
+		if err != nil {
+			return nil, err
+		}
+		if code == "" {
+			// The file declares no custom resources.
+			continue
+		}
+		formatted, err := format.Source([]byte(code))
+		if err != nil {
+			log.Printf("%v: %s", err, code)
+			return nil, err
+		}
+
+		ext := filepath.Ext(name)
+		base := strings.TrimSuffix(name, ext)
+		files = append(files,
+			&plugin.CodeGeneratorResponse_File{
+				Name:    proto.String(fmt.Sprintf("%s.pb.crdschema.go", base)),
+				Content: proto.String(string(formatted)),
+			},
+			&plugin.CodeGeneratorResponse_File{
+				Name:    proto.String(fmt.Sprintf("%s.crdschema.yaml", base)),
+				Content: proto.String(yaml),
+			},
+		)
+	}
+
+	return files, nil
+}
+
+func genCode(desc *descriptor.FileDescriptorProto, idx *typeIndex, docs *protodoc.Index) (string, string, error) {
+	hdr := &header{
+		Source: desc.GetName(),
+		GoPkg:  defaultGoPackageName(desc),
+	}
+	g := &schemaGen{idx: idx, visiting: make(map[string]bool)}
+	var resources []*resource
+	var walk func(scope, prefix string, msgs []*descriptor.DescriptorProto) error
+	walk = func(scope, prefix string, msgs []*descriptor.DescriptorProto) error {
+		for _, msg := range msgs {
+			if msg.GetOptions().GetMapEntry() {
+				continue
+			}
+			typeName := scope + "." + msg.GetName()
+			name := prefix + msg.GetName()
+			if crd := options.CRD(msg); crd != nil {
+				s, err := g.message(typeName)
+				if err != nil {
+					return fmt.Errorf("%s: %v", name, err)
+				}
+				// The API server sets the type of the object, which the
+				// message does not hold.
+				s.Properties = append([]*property{
+					{Name: "apiVersion", Schema: &schema{Type: "string"}},
+					{Name: "kind", Schema: &schema{Type: "string"}},
+				}, s.Properties...)
+				r := &resource{Name: name, Kind: crd.GetKind(), Doc: docs.Godoc(typeName)}
+				var goValue, yaml bytes.Buffer
+				s.writeGo(&goValue, &hdr.Ptr)
+				s.writeYAML(&yaml, "  ")
+				r.Schema, r.YAML = goValue.String(), yaml.String()
+				if r.APIVersion = crd.GetVersion(); crd.GetGroup() != "" {
+					r.APIVersion = crd.GetGroup() + "/" + crd.GetVersion()
+				}
+				resources = append(resources, r)
+			}
+			if err := walk(typeName, name+"_", msg.GetNestedType()); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := walk(strings.TrimSuffix("."+desc.GetPackage(), "."), "", desc.GetMessageType()); err != nil {
+		return "", "", fmt.Errorf("%s: %v", desc.GetName(), err)
+	}
+	if len(resources) == 0 {
+		return "", "", nil
+	}
+	hdr.Resources = resources
+
+	w := bytes.NewBuffer(nil)
+	if err := hdrTmpl.Execute(w, hdr); err != nil {
+		log.Fatal(err)
+	}
+	for _, r := range resources {
+		if err := validationTmpl.Execute(w, r); err != nil {
+			return "", "", err
+		}
+	}
+	y := bytes.NewBuffer(nil)
+	if err := yamlTmpl.Execute(y, hdr); err != nil {
+		return "", "", err
+	}
+
+	return w.String(), y.String(), nil
+}
+
+// schemaGen builds the schemas of messages as the Kubernetes JSON
+// serializer encodes their protoc-gen-go structs: fields under their proto
+// names, 64-bit integers and enums as numbers, bytes in base64.
+type schemaGen struct {
+	idx *typeIndex
+	// visiting holds the messages being built, to detect recursion.
+	visiting map[string]bool
+}
+
+// message returns the schema of the message typeName.
+func (g *schemaGen) message(typeName string) (*schema, error) {
+	msg, ok := g.idx.messages[typeName]
+	if !ok {
+		return nil, fmt.Errorf("unknown message %s", typeName)
+	}
+	if g.visiting[typeName] {
+		return nil, fmt.Errorf("%s is recursive, which structural schemas cannot describe", typeName)
+	}
+	g.visiting[typeName] = true
+	defer delete(g.visiting, typeName)
+
+	s := &schema{Type: "object"}
+	oneofs := make(map[int32]bool)
+	for _, field := range msg.GetField() {
+		if field.OneofIndex != nil && !field.GetProto3Optional() {
+			// The oneof interface field holds a wrapper struct the
+			// serializer encodes under Go names; leave it unchecked.
+			if i := field.GetOneofIndex(); !oneofs[i] {
+				oneofs[i] = true
+				s.Properties = append(s.Properties, &property{
+					Name:   goname.Fields(msg)[msg.GetOneofDecl()[i].GetName()],
+					Schema: &schema{Type: "object", PreserveUnknownFields: true},
+				})
+			}
+			continue
+		}
+		fs, err := g.field(field)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", field.GetName(), err)
+		}
+		s.Properties = append(s.Properties, &property{Name: field.GetName(), Schema: fs})
+		if options.Rules(field).GetRequired() {
+			s.Required = append(s.Required, field.GetName())
+		}
+	}
+	return s, nil
+}
+
+// field returns the schema of field, with its (f4tq.plugins.validate)
+// rules.
+func (g *schemaGen) field(field *descriptor.FieldDescriptorProto) (*schema, error) {
+	rules := options.Rules(field)
+	if rules == nil {
+		rules = &options.FieldRules{}
+	}
+	if g.idx.isMap(field) {
+		// Map values only take the item bounds, as in protoc-gen-go-validate.
+		v, err := g.value(g.idx.messages[field.GetTypeName()].GetField()[1], nil)
+		if err != nil {
+			return nil, err
+		}
+		return &schema{
+			Type:                 "object",
+			AdditionalProperties: v,
+			MinProperties:        uint32Ptr(rules.MinItems),
+			MaxProperties:        uint32Ptr(rules.MaxItems),
+		}, nil
+	}
+	if field.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REPEATED {
+		v, err := g.value(field, rules)
+		if err != nil {
+			return nil, err
+		}
+		return &schema{
+			Type:     "array",
+			Items:    v,
+			MinItems: uint32Ptr(rules.MinItems),
+			MaxItems: uint32Ptr(rules.MaxItems),
+		}, nil
+	}
+	return g.value(field, rules)
+}
+
+// value returns the schema of a value of field, bounded by rules.
+func (g *schemaGen) value(field *descriptor.FieldDescriptorProto, rules *options.FieldRules) (*schema, error) {
+	var s *schema
+	switch field.GetType() {
+	case descriptor.FieldDescriptorProto_TYPE_DOUBLE:
+		s = &schema{Type: "number", Format: "double"}
+	case descriptor.FieldDescriptorProto_TYPE_FLOAT:
+		s = &schema{Type: "number", Format: "float"}
+	case descriptor.FieldDescriptorProto_TYPE_INT32,
+		descriptor.FieldDescriptorProto_TYPE_SINT32,
+		descriptor.FieldDescriptorProto_TYPE_SFIXED32:
+		s = &schema{Type: "integer", Format: "int32"}
+	case descriptor.FieldDescriptorProto_TYPE_INT64,
+		descriptor.FieldDescriptorProto_TYPE_SINT64,
+		descriptor.FieldDescriptorProto_TYPE_SFIXED64:
+		s = &schema{Type: "integer", Format: "int64"}
+	case descriptor.FieldDescriptorProto_TYPE_UINT32,
+		descriptor.FieldDescriptorProto_TYPE_FIXED32,
+		descriptor.FieldDescriptorProto_TYPE_UINT64,
+		descriptor.FieldDescriptorProto_TYPE_FIXED64:
+		zero := 0.0
+		s = &schema{Type: "integer", Format: "int64", Minimum: &zero}
+	case descriptor.FieldDescriptorProto_TYPE_BOOL:
+		s = &schema{Type: "boolean"}
+	case descriptor.FieldDescriptorProto_TYPE_STRING:
+		s = &schema{Type: "string"}
+	case descriptor.FieldDescriptorProto_TYPE_BYTES:
+		s = &schema{Type: "string", Format: "byte"}
+	case descriptor.FieldDescriptorProto_TYPE_ENUM:
+		e, ok := g.idx.enums[field.GetTypeName()]
+		if !ok {
+			return nil, fmt.Errorf("unknown enum %s", field.GetTypeName())
+		}
+		s = &schema{Type: "integer", Format: "int32"}
+		seen := make(map[int32]bool)
+		for _, v := range e.GetValue() {
+			if !seen[v.GetNumber()] {
+				seen[v.GetNumber()] = true
+				s.Enum = append(s.Enum, strconv.Itoa(int(v.GetNumber())))
+			}
+		}
+	case descriptor.FieldDescriptorProto_TYPE_MESSAGE:
+		if field.GetTypeName() == objectMetaType {
+			// The API server checks the metadata itself.
+			return &schema{Type: "object"}, nil
+		}
+		return g.message(field.GetTypeName())
+	default:
+		return nil, fmt.Errorf("groups are not supported")
+	}
+	if rules == nil {
+		return s, nil
+	}
+	// Lengths count the characters of strings; those of bytes, encoded in
+	// base64, are left to the generated Validate methods.
+	if s.Type == "string" && s.Format == "" {
+		s.MinLength = uint32Ptr(rules.MinLen)
+		s.MaxLength = uint32Ptr(rules.MaxLen)
+		s.Pattern = rules.GetPattern()
+	}
+	if s.Type == "number" || s.Type == "integer" {
+		switch {
+		case rules.Gt != nil:
+			s.Minimum, s.ExclusiveMinimum = rules.Gt, true
+		case rules.Gte != nil:
+			s.Minimum = rules.Gte
+		}
+		switch {
+		case rules.Lt != nil:
+			s.Maximum, s.ExclusiveMaximum = rules.Lt, true
+		case rules.Lte != nil:
+			s.Maximum = rules.Lte
+		}
+	}
+	return s, nil
+}
+
+func uint32Ptr(v *uint32) *int64 {
+	if v == nil {
+		return nil
+	}
+	n := int64(*v)
+	return &n
+}
+
+// schema is an OpenAPI v3 schema, restricted to what structural schemas
+// allow.
+type schema struct {
+	Type, Format                       string
+	Properties                         []*property
+	Required                           []string
+	Items, AdditionalProperties        *schema
+	Enum                               []string // JSON values
+	Pattern                            string
+	MinLength, MaxLength               *int64
+	MinItems, MaxItems                 *int64
+	MinProperties, MaxProperties       *int64
+	Minimum, Maximum                   *float64
+	ExclusiveMinimum, ExclusiveMaximum bool
+	PreserveUnknownFields              bool
+}
+
+type property struct {
+	Name   string
+	Schema *schema
+}
+
+// writeGo writes s as an apiextensionsv1.JSONSchemaProps composite
+// literal. It sets *ptr if it uses package crdschema.
+func (s *schema) writeGo(w *bytes.Buffer, ptr *bool) {
+	w.WriteString("apiextensionsv1.JSONSchemaProps{\n")
+	s.writeGoFields(w, ptr)
+	w.WriteString("}")
+}
+
+func (s *schema) writeGoFields(w *bytes.Buffer, ptr *bool) {
+	str := func(name, v string) {
+		if v != "" {
+			fmt.Fprintf(w, "%s: %q,\n", name, v)
+		}
+	}
+	integer := func(name string, v *int64) {
+		if v != nil {
+			*ptr = true
+			fmt.Fprintf(w, "%s: crdschema.Int64(%d),\n", name, *v)
+		}
+	}
+	number := func(name string, v *float64) {
+		if v != nil {
+			*ptr = true
+			fmt.Fprintf(w, "%s: crdschema.Float64(%s),\n", name, strconv.FormatFloat(*v, 'g', -1, 64))
+		}
+	}
+	str("Type", s.Type)
+	str("Format", s.Format)
+	number("Minimum", s.Minimum)
+	if s.ExclusiveMinimum {
+		w.WriteString("ExclusiveMinimum: true,\n")
+	}
+	number("Maximum", s.Maximum)
+	if s.ExclusiveMaximum {
+		w.WriteString("ExclusiveMaximum: true,\n")
+	}
+	integer("MinLength", s.MinLength)
+	integer("MaxLength", s.MaxLength)
+	str("Pattern", s.Pattern)
+	integer("MinItems", s.MinItems)
+	integer("MaxItems", s.MaxItems)
+	integer("MinProperties", s.MinProperties)
+	integer("MaxProperties", s.MaxProperties)
+	if len(s.Enum) > 0 {
+		w.WriteString("Enum: []apiextensionsv1.JSON{\n")
+		for _, v := range s.Enum {
+			fmt.Fprintf(w, "{Raw: []byte(%q)},\n", v)
+		}
+		w.WriteString("},\n")
+	}
+	if len(s.Required) > 0 {
+		w.WriteString("Required: []string{")
+		for i, name := range s.Required {
+			if i > 0 {
+				w.WriteString(", ")
+			}
+			fmt.Fprintf(w, "%q", name)
+		}
+		w.WriteString("},\n")
+	}
+	if len(s.Properties) > 0 {
+		w.WriteString("Properties: map[string]apiextensionsv1.JSONSchemaProps{\n")
+		for _, p := range s.Properties {
+			fmt.Fprintf(w, "%q: {\n", p.Name)
+			p.Schema.writeGoFields(w, ptr)
+			w.WriteString("},\n")
+		}
+		w.WriteString("},\n")
+	}
+	if s.Items != nil {
+		w.WriteString("Items: &apiextensionsv1.JSONSchemaPropsOrArray{\nSchema: &")
+		s.Items.writeGo(w, ptr)
+		w.WriteString(",\n},\n")
+	}
+	if s.AdditionalProperties != nil {
+		w.WriteString("AdditionalProperties: &apiextensionsv1.JSONSchemaPropsOrBool{\nAllows: true,\nSchema: &")
+		s.AdditionalProperties.writeGo(w, ptr)
+		w.WriteString(",\n},\n")
+	}
+	if s.PreserveUnknownFields {
+		*ptr = true
+		w.WriteString("XPreserveUnknownFields: crdschema.Bool(true),\n")
+	}
+}
+
+// writeYAML writes s as a YAML mapping indented by indent.
+func (s *schema) writeYAML(w *bytes.Buffer, indent string) {
+	line := func(key, v string) {
+		fmt.Fprintf(w, "%s%s: %s\n", indent, key, v)
+	}
+	integer := func(key string, v *int64) {
+		if v != nil {
+			line(key, strconv.FormatInt(*v, 10))
+		}
+	}
+	number := func(key string, v *float64) {
+		if v != nil {
+			line(key, strconv.FormatFloat(*v, 'g', -1, 64))
+		}
+	}
+	list := func(key string, items []string) {
+		if len(items) > 0 {
+			fmt.Fprintf(w, "%s%s:\n", indent, key)
+			for _, v := range items {
+				fmt.Fprintf(w, "%s- %s\n", indent, v)
+			}
+		}
+	}
+	if s.Type != "" {
+		line("type", s.Type)
+	}
+	if s.Format != "" {
+		line("format", s.Format)
+	}
+	number("minimum", s.Minimum)
+	if s.ExclusiveMinimum {
+		line("exclusiveMinimum", "true")
+	}
+	number("maximum", s.Maximum)
+	if s.ExclusiveMaximum {
+		line("exclusiveMaximum", "true")
+	}
+	integer("minLength", s.MinLength)
+	integer("maxLength", s.MaxLength)
+	if s.Pattern != "" {
+		// Go quoting is valid in YAML double-quoted scalars.
+		line("pattern", strconv.Quote(s.Pattern))
+	}
+	integer("minItems", s.MinItems)
+	integer("maxItems", s.MaxItems)
+	integer("minProperties", s.MinProperties)
+	integer("maxProperties", s.MaxProperties)
+	list("enum", s.Enum)
+	list("required", s.Required)
+	if len(s.Properties) > 0 {
+		fmt.Fprintf(w, "%sproperties:\n", indent)
+		for _, p := range s.Properties {
+			fmt.Fprintf(w, "%s  %s:\n", indent, p.Name)
+			p.Schema.writeYAML(w, indent+"    ")
+		}
+	}
+	if s.Items != nil {
+		fmt.Fprintf(w, "%sitems:\n", indent)
+		s.Items.writeYAML(w, indent+"  ")
+	}
+	if s.AdditionalProperties != nil {
+		fmt.Fprintf(w, "%sadditionalProperties:\n", indent)
+		s.AdditionalProperties.writeYAML(w, indent+"  ")
+	}
+	if s.PreserveUnknownFields {
+		line("x-kubernetes-preserve-unknown-fields", "true")
+	}
+}
+
+type header struct {
+	Source string
+	GoPkg  string
+	// Ptr records that the code uses package crdschema.
+	Ptr       bool
+	Resources []*resource
+}
+
+type resource struct {
+	Name       string
+	Kind       string
+	APIVersion string
+	Schema     string
+	YAML       string
+	// Doc is the comment of the message, see protodoc.Index.Godoc.
+	Doc string
+}
+
+// typeIndex maps fully-qualified proto type names (".pkg.Msg") to their
+// descriptors and declaring files for every file in the request.
+type typeIndex struct {
+	messages map[string]*descriptor.DescriptorProto
+	enums    map[string]*descriptor.EnumDescriptorProto
+	files    map[string]*descriptor.FileDescriptorProto
+}
+
+func newTypeIndex(files []*descriptor.FileDescriptorProto) *typeIndex {
+	idx := &typeIndex{
+		messages: make(map[string]*descriptor.DescriptorProto),
+		enums:    make(map[string]*descriptor.EnumDescriptorProto),
+		files:    make(map[string]*descriptor.FileDescriptorProto),
+	}
+	for _, f := range files {
+		prefix := ""
+		if f.GetPackage() != "" {
+			prefix = "." + f.GetPackage()
+		}
+		idx.addMessages(f, prefix, f.GetMessageType())
+		idx.addEnums(f, prefix, f.GetEnumType())
+	}
+	return idx
+}
+
+func (idx *typeIndex) addMessages(f *descriptor.FileDescriptorProto, prefix string, msgs []*descriptor.DescriptorProto) {
+	for _, m := range msgs {
+		name := prefix + "." + m.GetName()
+		idx.messages[name] = m
+		idx.files[name] = f
+		idx.addMessages(f, name, m.GetNestedType())
+		idx.addEnums(f, name, m.GetEnumType())
+	}
+}
+
+func (idx *typeIndex) addEnums(f *descriptor.FileDescriptorProto, prefix string, enums []*descriptor.EnumDescriptorProto) {
+	for _, e := range enums {
+		name := prefix + "." + e.GetName()
+		idx.enums[name] = e
+		idx.files[name] = f
+	}
+}
+
+// isMap reports whether field is a map field.
+func (idx *typeIndex) isMap(field *descriptor.FieldDescriptorProto) bool {
+	if field.GetType() != descriptor.FieldDescriptorProto_TYPE_MESSAGE {
+		return false
+	}
+	return idx.messages[field.GetTypeName()].GetOptions().GetMapEntry()
+}
+
+// sanitizePackageName replaces unallowed character in package name
+// with allowed character.
+func sanitizePackageName(pkgName string) string {
+	pkgName = strings.Replace(pkgName, ".", "_", -1)
+	pkgName = strings.Replace(pkgName, "-", "_", -1)
+	return pkgName
+}
+
+// defaultGoPackageName returns the default go package name to be used for go files generated from "f".
+// You might need to use an unique alias for the package when you import it.  Use ReserveGoPackageAlias to get a unique alias.
+func defaultGoPackageName(f *descriptor.FileDescriptorProto) string {
+	name := packageIdentityName(f)
+	return sanitizePackageName(name)
+}
+
+// packageIdentityName returns the identity of packages.
+// protoc-gen-grpc-gateway rejects CodeGenerationRequests which contains more than one packages
+// as protoc-gen-go does.
+func packageIdentityName(f *descriptor.FileDescriptorProto) string {
+	if f.Options != nil && f.Options.GoPackage != nil {
+		gopkg := f.Options.GetGoPackage()
+		idx := strings.LastIndex(gopkg, "/")
+		if idx < 0 {
+			gopkg = gopkg[idx+1:]
+		}
+
+		gopkg = gopkg[idx+1:]
+		// package name is overrided with the string after the
+		// ';' character
+		sc := strings.IndexByte(gopkg, ';')
+		if sc < 0 {
+			return sanitizePackageName(gopkg)
+
+		}
+		return sanitizePackageName(gopkg[sc+1:])
+	}
+
+	if f.Package == nil {
+		base := filepath.Base(f.GetName())
+		ext := filepath.Ext(base)
+		return strings.TrimSuffix(base, ext)
+	}
+	return f.GetPackage()
+}
+
+func emitFiles(out []*plugin.CodeGeneratorResponse_File) {
+	emitResp(&plugin.CodeGeneratorResponse{File: out})
+}
+
+func emitError(err error) {
+	emitResp(&plugin.CodeGeneratorResponse{Error: proto.String(err.Error())})
+}
+
+func emitResp(resp *plugin.CodeGeneratorResponse) {
+	buf, err := proto.Marshal(resp)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if _, err := os.Stdout.Write(buf); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,825 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+
+	"github.com/f4tq/protoc-go-plugins/options"
+	"github.com/f4tq/protoc-go-plugins/protodoc"
+)
+
+func main() {
+	input, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	req := new(plugin.CodeGeneratorRequest)
+	if err = proto.Unmarshal(input, req); err != nil {
+		log.Fatal(err)
+	}
+
+	out, err := generate(req)
+
+	if err != nil {
+		emitError(err)
+		return
+	}
+
+	emitFiles(out)
+}
+
+// generate writes <package>.cue next to the first file of each proto
+// package with files to generate: the CUE definitions of the messages and
+// enums of the files of the package in the request, as the JSON jsonpb
+// unmarshals. The definitions are closed, as jsonpb rejects unknown fields,
+// and constrain the fields with their (f4tq.plugins.validate) rules and
+// (f4tq.plugins.default_value) defaults.
+//
+// The orig_name parameter names the fields by their proto names rather
+// than their JSON names. The module parameter is the path of the CUE
+// module the files are generated in, which the definitions referring to
+// the types of other packages import them from.
+func generate(req *plugin.CodeGeneratorRequest) ([]*plugin.CodeGeneratorResponse_File, error) {
+	params := parseParams(req.GetParameter())
+	opts := &cueOptions{}
+	for k, v := range params {
+		switch k {
+		case "orig_name":
+			opts.OrigName = v == "" || v == "true"
+		case "module":
+			opts.Module = strings.TrimSuffix(v, "/")
+		default:
+			return nil, fmt.Errorf("unknown parameter %q", k)
+		}
+	}
+	var files []*plugin.CodeGeneratorResponse_File
+	idx := newTypeIndex(req.GetProtoFile())
+	docs := protodoc.New(req.GetProtoFile())
+	genFileNames := make(map[string]bool)
+	for _, n := range req.FileToGenerate {
+		genFileNames[n] = true
+	}
+	// The files of each package, in the order of the request; the first
+	// places the CUE file of the package.
+	var pkgs []string
+	byPkg := make(map[string][]*descriptor.FileDescriptorProto)
+	for _, desc := range req.GetProtoFile() {
+		pkg := desc.GetPackage()
+		if _, ok := byPkg[pkg]; !ok {
+			pkgs = append(pkgs, pkg)
+		}
+		byPkg[pkg] = append(byPkg[pkg], desc)
+	}
+	for _, pkg := range pkgs {
+		gen := false
+		for _, desc := range byPkg[pkg] {
+			gen = gen || genFileNames[desc.GetName()]
+		}
+		if !gen {
+			// Only emit output for the packages of files present in
+			// req.FileToGenerate.
+			continue
+		}
+		g := &cueGen{
+			pkg:     pkg,
+			idx:     idx,
+			byPkg:   byPkg,
+			docs:    docs,
+			opts:    opts,
+			std:     make(map[string]bool),
+			imports: make(map[string]string),
+			body:    bytes.NewBuffer(nil),
+		}
+		code, err := g.genCode()
+		if err != nil {
+			return nil, err
+		}
+		if code == "" {
+			// The package declares no messages or enums.
+			continue
+		}
+		files = append(files, &plugin.CodeGeneratorResponse_File{
+			Name:    proto.String(cueFile(byPkg[pkg][0])),
+			Content: proto.String(code),
+		})
+	}
+
+	return files, nil
+}
+
+// cueOptions are the parameters of the plugin.
+type cueOptions struct {
+	// OrigName names the fields by their proto names rather than their
+	// JSON names.
+	OrigName bool
+	// Module is the path of the CUE module of the generated files.
+	Module string
+}
+
+// cueFile returns the name of the CUE file of the package of f, its first
+// file in the request.
+func cueFile(f *descriptor.FileDescriptorProto) string {
+	return path.Join(path.Dir(f.GetName()), defaultGoPackageName(f)+".cue")
+}
+
+type cueGen struct {
+	// pkg is the proto package of the definitions.
+	pkg   string
+	idx   *typeIndex
+	byPkg map[string][]*descriptor.FileDescriptorProto
+	// docs holds the comments of the declarations.
+	docs *protodoc.Index
+	opts *cueOptions
+	// std holds the packages of the standard library the definitions use.
+	std map[string]bool
+	// imports maps the import paths of the packages of other proto
+	// packages referred to to their aliases.
+	imports map[string]string
+	body    *bytes.Buffer
+}
+
+func (g *cueGen) genCode() (string, error) {
+	files := g.byPkg[g.pkg]
+	scope := strings.TrimSuffix("."+g.pkg, ".")
+	for _, desc := range files {
+		if err := g.messages(scope, desc.GetMessageType()); err != nil {
+			return "", fmt.Errorf("%s: %v", desc.GetName(), err)
+		}
+		g.enums(scope, desc.GetEnumType())
+	}
+	if g.body.Len() == 0 {
+		return "", nil
+	}
+
+	w := bytes.NewBuffer(nil)
+	w.WriteString("// Code generated by protoc-gen-go-cue. DO NOT EDIT.\n")
+	for _, desc := range files {
+		fmt.Fprintf(w, "// source: %s\n", desc.GetName())
+	}
+	fmt.Fprintf(w, "\npackage %s\n", defaultGoPackageName(files[0]))
+	if len(g.std)+len(g.imports) > 0 {
+		var std, paths []string
+		for p := range g.std {
+			std = append(std, p)
+		}
+		for p := range g.imports {
+			paths = append(paths, p)
+		}
+		sort.Strings(std)
+		sort.Strings(paths)
+		w.WriteString("\nimport (\n")
+		for _, p := range std {
+			fmt.Fprintf(w, "\t%s\n", strconv.Quote(p))
+		}
+		if len(std) > 0 && len(paths) > 0 {
+			w.WriteString("\n")
+		}
+		for _, p := range paths {
+			fmt.Fprintf(w, "\t%s %s\n", g.imports[p], strconv.Quote(p))
+		}
+		w.WriteString(")\n")
+	}
+	g.body.WriteTo(w)
+	return w.String(), nil
+}
+
+// messages writes the definitions of msgs, and those of the messages and
+// enums nested in them. scope is the full proto name of their parent.
+func (g *cueGen) messages(scope string, msgs []*descriptor.DescriptorProto) error {
+	for _, msg := range msgs {
+		if msg.GetOptions().GetMapEntry() {
+			continue
+		}
+		typeName := scope + "." + msg.GetName()
+		g.doc("", typeName)
+		fmt.Fprintf(g.body, "%s: {\n", g.defName(typeName))
+		for _, field := range msg.GetField() {
+			if field.OneofIndex != nil && !field.GetProto3Optional() {
+				continue
+			}
+			if err := g.field(typeName, field, "\t", false); err != nil {
+				return err
+			}
+		}
+		// A oneof embeds the disjunction of the structs holding one of its
+		// members, or none by default.
+		for i, oneof := range msg.GetOneofDecl() {
+			var members []*descriptor.FieldDescriptorProto
+			for _, field := range msg.GetField() {
+				if field.OneofIndex != nil && field.GetOneofIndex() == int32(i) && !field.GetProto3Optional() {
+					members = append(members, field)
+				}
+			}
+			if len(members) == 0 {
+				// The oneof of a proto3 optional field.
+				continue
+			}
+			fmt.Fprintf(g.body, "\t// At most one field of the oneof %s is set.\n\t*{}", oneof.GetName())
+			for _, field := range members {
+				g.body.WriteString(" | {\n")
+				if err := g.field(typeName, field, "\t\t", true); err != nil {
+					return err
+				}
+				g.body.WriteString("\t}")
+			}
+			g.body.WriteString("\n")
+		}
+		g.body.WriteString("}\n")
+		if err := g.messages(typeName, msg.GetNestedType()); err != nil {
+			return err
+		}
+		g.enums(typeName, msg.GetEnumType())
+	}
+	return nil
+}
+
+// enums writes the definitions of enums, the disjunctions of the names of
+// their values, as messages does.
+func (g *cueGen) enums(scope string, enums []*descriptor.EnumDescriptorProto) {
+	for _, enum := range enums {
+		typeName := scope + "." + enum.GetName()
+		g.doc("", typeName)
+		var names []string
+		for _, v := range enum.GetValue() {
+			names = append(names, cueString(v.GetName()))
+		}
+		if len(names) == 0 {
+			names = []string{"_|_"}
+		}
+		fmt.Fprintf(g.body, "%s: %s\n", g.defName(typeName), strings.Join(names, " | "))
+	}
+}
+
+// doc writes the comment of the declaration name indented by indent,
+// after a blank line for definitions.
+func (g *cueGen) doc(indent, name string) {
+	if indent == "" {
+		g.body.WriteString("\n")
+	}
+	var lines []string
+	if comment := g.docs.Comment(name); comment != "" {
+		lines = strings.Split(comment, "\n")
+	}
+	if g.docs.Deprecated(name) {
+		if len(lines) > 0 {
+			lines = append(lines, "")
+		}
+		lines = append(lines, "Deprecated: do not use.")
+	}
+	for _, l := range lines {
+		if l == "" {
+			fmt.Fprintf(g.body, "%s//\n", indent)
+			continue
+		}
+		fmt.Fprintf(g.body, "%s// %s\n", indent, l)
+	}
+}
+
+// field writes the field of the message typeName. The members of oneofs
+// are set in the struct of their disjunct.
+func (g *cueGen) field(typeName string, field *descriptor.FieldDescriptorProto, indent string, member bool) error {
+	expr, err := g.fieldExpr(field)
+	if err != nil {
+		return fmt.Errorf("%s.%s: %v", strings.TrimPrefix(typeName, "."), field.GetName(), err)
+	}
+	// Required fields must be set, and fields with defaults take them when
+	// left out.
+	marker := "?"
+	switch {
+	case member:
+		marker = ""
+	case options.Rules(field).GetRequired():
+		marker = "!"
+	case strings.HasPrefix(expr, "*"):
+		marker = ""
+	}
+	g.doc(indent, typeName+"."+field.GetName())
+	fmt.Fprintf(g.body, "%s%s%s: %s\n", indent, cueLabel(g.fieldName(field)), marker, expr)
+	return nil
+}
+
+// fieldName returns the key of field in the JSON.
+func (g *cueGen) fieldName(field *descriptor.FieldDescriptorProto) string {
+	if g.opts.OrigName {
+		return field.GetName()
+	}
+	return jsonName(field)
+}
+
+// fieldExpr returns the expression constraining the JSON value of field.
+func (g *cueGen) fieldExpr(field *descriptor.FieldDescriptorProto) (string, error) {
+	rules := options.Rules(field)
+	if rules == nil {
+		rules = &options.FieldRules{}
+	}
+	if g.idx.isMap(field) {
+		// Map values only take the item bounds, as in protoc-gen-go-validate;
+		// jsonpb writes the keys of all types as strings.
+		v, err := g.valueExpr(g.idx.messages[field.GetTypeName()].GetField()[1], nil)
+		if err != nil {
+			return "", err
+		}
+		parts := []string{fmt.Sprintf("{[string]: %s}", v)}
+		if rules.MinItems != nil {
+			g.std["struct"] = true
+			parts = append(parts, fmt.Sprintf("struct.MinFields(%d)", *rules.MinItems))
+		}
+		if rules.MaxItems != nil {
+			g.std["struct"] = true
+			parts = append(parts, fmt.Sprintf("struct.MaxFields(%d)", *rules.MaxItems))
+		}
+		return strings.Join(parts, " & "), nil
+	}
+	if field.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REPEATED {
+		v, err := g.valueExpr(field, rules)
+		if err != nil {
+			return "", err
+		}
+		parts := []string{fmt.Sprintf("[...%s]", v)}
+		if rules.MinItems != nil {
+			g.std["list"] = true
+			parts = append(parts, fmt.Sprintf("list.MinItems(%d)", *rules.MinItems))
+		}
+		if rules.MaxItems != nil {
+			g.std["list"] = true
+			parts = append(parts, fmt.Sprintf("list.MaxItems(%d)", *rules.MaxItems))
+		}
+		return strings.Join(parts, " & "), nil
+	}
+	v, err := g.valueExpr(field, rules)
+	if err != nil {
+		return "", err
+	}
+	if def := options.DefaultValue(field); def != "" {
+		lit, err := g.literal(field, def)
+		if err != nil {
+			return "", fmt.Errorf("default_value: %v", err)
+		}
+		v = "*" + lit + " | " + v
+	}
+	return v, nil
+}
+
+// wellKnownTypes are the expressions of the JSON of the well-known types,
+// which jsonpb encodes specially.
+var wellKnownTypes = map[string]string{
+	".google.protobuf.Timestamp":   "time.Time",
+	".google.protobuf.Duration":    `=~"^-?[0-9]+([.][0-9]{1,9})?s$"`,
+	".google.protobuf.FieldMask":   "string",
+	".google.protobuf.Empty":       "{}",
+	".google.protobuf.Struct":      "{[string]: _}",
+	".google.protobuf.Value":       "_",
+	".google.protobuf.ListValue":   "[..._]",
+	".google.protobuf.Any":         `{"@type": string, ...}`,
+	".google.protobuf.DoubleValue": "null | float64",
+	".google.protobuf.FloatValue":  "null | float32",
+	".google.protobuf.Int64Value":  "null | int64",
+	".google.protobuf.UInt64Value": "null | uint64",
+	".google.protobuf.Int32Value":  "null | int32",
+	".google.protobuf.UInt32Value": "null | uint32",
+	".google.protobuf.BoolValue":   "null | bool",
+	".google.protobuf.StringValue": "null | string",
+	".google.protobuf.BytesValue":  "null | string",
+}
+
+// valueExpr returns the expression constraining the JSON of a value of
+// field by rules. 64-bit integers are numbers, which jsonpb unmarshals as
+// it does the strings it writes.
+func (g *cueGen) valueExpr(field *descriptor.FieldDescriptorProto, rules *options.FieldRules) (string, error) {
+	var t string
+	switch field.GetType() {
+	case descriptor.FieldDescriptorProto_TYPE_DOUBLE:
+		t = "float64"
+	case descriptor.FieldDescriptorProto_TYPE_FLOAT:
+		t = "float32"
+	case descriptor.FieldDescriptorProto_TYPE_INT32,
+		descriptor.FieldDescriptorProto_TYPE_SINT32,
+		descriptor.FieldDescriptorProto_TYPE_SFIXED32:
+		t = "int32"
+	case descriptor.FieldDescriptorProto_TYPE_UINT32,
+		descriptor.FieldDescriptorProto_TYPE_FIXED32:
+		t = "uint32"
+	case descriptor.FieldDescriptorProto_TYPE_INT64,
+		descriptor.FieldDescriptorProto_TYPE_SINT64,
+		descriptor.FieldDescriptorProto_TYPE_SFIXED64:
+		t = "int64"
+	case descriptor.FieldDescriptorProto_TYPE_UINT64,
+		descriptor.FieldDescriptorProto_TYPE_FIXED64:
+		t = "uint64"
+	case descriptor.FieldDescriptorProto_TYPE_BOOL:
+		t = "bool"
+	case descriptor.FieldDescriptorProto_TYPE_STRING:
+		t = "string"
+	case descriptor.FieldDescriptorProto_TYPE_BYTES:
+		// In base64; the lengths of the rules count the decoded bytes,
+		// which are left to the generated Validate methods.
+		return "string", nil
+	case descriptor.FieldDescriptorProto_TYPE_ENUM:
+		if t, ok := wellKnownTypes[field.GetTypeName()]; ok {
+			return t, nil
+		}
+		return g.typeRef(field.GetTypeName())
+	case descriptor.FieldDescriptorProto_TYPE_MESSAGE,
+		descriptor.FieldDescriptorProto_TYPE_GROUP:
+		// jsonpb writes groups as the messages they are.
+		if t, ok := wellKnownTypes[field.GetTypeName()]; ok {
+			if strings.HasPrefix(t, "time.") {
+				g.std["time"] = true
+			}
+			return t, nil
+		}
+		if rules.GetSkip() {
+			// The message is not validated.
+			return "{...}", nil
+		}
+		return g.typeRef(field.GetTypeName())
+	default:
+		return "", fmt.Errorf("unsupported field type %v", field.GetType())
+	}
+	if rules == nil {
+		return t, nil
+	}
+	parts := []string{t}
+	if t == "string" {
+		if rules.MinLen != nil {
+			g.std["strings"] = true
+			parts = append(parts, fmt.Sprintf("strings.MinRunes(%d)", *rules.MinLen))
+		}
+		if rules.MaxLen != nil {
+			g.std["strings"] = true
+			parts = append(parts, fmt.Sprintf("strings.MaxRunes(%d)", *rules.MaxLen))
+		}
+		if rules.GetPattern() != "" {
+			parts = append(parts, "=~"+cueString(rules.GetPattern()))
+		}
+		return strings.Join(parts, " & "), nil
+	}
+	if t != "bool" {
+		switch {
+		case rules.Gt != nil:
+			parts = append(parts, ">"+cueNumber(*rules.Gt))
+		case rules.Gte != nil:
+			parts = append(parts, ">="+cueNumber(*rules.Gte))
+		}
+		switch {
+		case rules.Lt != nil:
+			parts = append(parts, "<"+cueNumber(*rules.Lt))
+		case rules.Lte != nil:
+			parts = append(parts, "<="+cueNumber(*rules.Lte))
+		}
+	}
+	return strings.Join(parts, " & "), nil
+}
+
+// literal returns the CUE literal of the default value def of field.
+func (g *cueGen) literal(field *descriptor.FieldDescriptorProto, def string) (string, error) {
+	if field.OneofIndex != nil && !field.GetProto3Optional() {
+		return "", fmt.Errorf("defaults are not supported in oneofs")
+	}
+	switch field.GetType() {
+	case descriptor.FieldDescriptorProto_TYPE_STRING:
+		return cueString(def), nil
+	case descriptor.FieldDescriptorProto_TYPE_BYTES:
+		return cueString(base64.StdEncoding.EncodeToString([]byte(def))), nil
+	case descriptor.FieldDescriptorProto_TYPE_BOOL:
+		b, err := strconv.ParseBool(def)
+		if err != nil {
+			return "", err
+		}
+		return strconv.FormatBool(b), nil
+	case descriptor.FieldDescriptorProto_TYPE_ENUM:
+		for _, v := range g.idx.enums[field.GetTypeName()].GetValue() {
+			if v.GetName() == def {
+				return cueString(def), nil
+			}
+		}
+		return "", fmt.Errorf("%s has no value %s", field.GetTypeName(), def)
+	case descriptor.FieldDescriptorProto_TYPE_DOUBLE,
+		descriptor.FieldDescriptorProto_TYPE_FLOAT:
+		f, err := strconv.ParseFloat(def, 64)
+		if err != nil {
+			return "", err
+		}
+		if math.IsInf(f, 0) || math.IsNaN(f) {
+			return "", fmt.Errorf("%s is not finite", def)
+		}
+		return cueNumber(f), nil
+	case descriptor.FieldDescriptorProto_TYPE_UINT32,
+		descriptor.FieldDescriptorProto_TYPE_FIXED32,
+		descriptor.FieldDescriptorProto_TYPE_UINT64,
+		descriptor.FieldDescriptorProto_TYPE_FIXED64:
+		u, err := strconv.ParseUint(def, 0, 64)
+		if err != nil {
+			return "", err
+		}
+		return strconv.FormatUint(u, 10), nil
+	case descriptor.FieldDescriptorProto_TYPE_MESSAGE,
+		descriptor.FieldDescriptorProto_TYPE_GROUP:
+		return "", fmt.Errorf("defaults are only supported on scalar and enum fields")
+	}
+	i, err := strconv.ParseInt(def, 0, 64)
+	if err != nil {
+		return "", err
+	}
+	return strconv.FormatInt(i, 10), nil
+}
+
+// typeRef returns the reference to the definition of the message or enum
+// typeName, importing the package of another proto package if needed.
+func (g *cueGen) typeRef(typeName string) (string, error) {
+	f := g.idx.files[typeName]
+	if f == nil {
+		return "", fmt.Errorf("unknown type %s", typeName)
+	}
+	def := "#" + localTypeName(f, typeName)
+	if f.GetPackage() == g.pkg {
+		return def, nil
+	}
+	if g.opts.Module == "" {
+		return "", fmt.Errorf("%s is declared in the package %s; set the module parameter to import it",
+			strings.TrimPrefix(typeName, "."), f.GetPackage())
+	}
+	first := g.byPkg[f.GetPackage()][0]
+	name := defaultGoPackageName(first)
+	importPath := g.opts.Module
+	if dir := path.Dir(first.GetName()); dir != "." {
+		importPath += "/" + dir
+	}
+	if path.Base(importPath) != name {
+		importPath += ":" + name
+	}
+	alias, ok := g.imports[importPath]
+	if !ok {
+		alias = name
+		for n := 2; g.aliasTaken(alias); n++ {
+			alias = fmt.Sprintf("%s%d", name, n)
+		}
+		g.imports[importPath] = alias
+	}
+	return alias + "." + def, nil
+}
+
+func (g *cueGen) aliasTaken(alias string) bool {
+	if g.std[alias] {
+		return true
+	}
+	for _, a := range g.imports {
+		if a == alias {
+			return true
+		}
+	}
+	return false
+}
+
+func (g *cueGen) defName(typeName string) string {
+	return "#" + localTypeName(g.idx.files[typeName], typeName)
+}
+
+// localTypeName returns the name of the type of typeName, declared in f:
+// its name relative to the package, with "_" separating nested names as in
+// the Go types.
+func localTypeName(f *descriptor.FileDescriptorProto, typeName string) string {
+	name := strings.TrimPrefix(typeName, ".")
+	if f.GetPackage() != "" {
+		name = strings.TrimPrefix(name, f.GetPackage()+".")
+	}
+	return strings.Replace(name, ".", "_", -1)
+}
+
+// cueKeywords are the keywords of CUE, which labels quote.
+var cueKeywords = map[string]bool{
+	"package": true, "import": true, "for": true, "in": true, "if": true,
+	"let": true, "true": true, "false": true, "null": true,
+}
+
+// cueLabel returns name as a label, quoted unless it is an identifier.
+// Identifiers starting with "_" or "#" are hidden fields and definitions.
+func cueLabel(name string) string {
+	for i, r := range name {
+		if !(r == '$' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || i > 0 && (r == '_' || r >= '0' && r <= '9')) {
+			return cueString(name)
+		}
+	}
+	if name == "" || cueKeywords[name] {
+		return cueString(name)
+	}
+	return name
+}
+
+// cueString returns the CUE string literal of s; the escapes of JSON
+// strings are those of CUE.
+func cueString(s string) string {
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(s); err != nil {
+		log.Fatal(err)
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// cueNumber returns the CUE literal of the bound v, an integer literal if
+// it is integral.
+func cueNumber(v float64) string {
+	if v == math.Trunc(v) && math.Abs(v) < 1e15 {
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// jsonName returns the JSON name of field, which protoc sets, or else the
+// lower camel case of its name.
+func jsonName(field *descriptor.FieldDescriptorProto) string {
+	if field.GetJsonName() != "" {
+		return field.GetJsonName()
+	}
+	name := camelCase(field.GetName())
+	return strings.ToLower(name[:1]) + name[1:]
+}
+
+// typeIndex maps fully-qualified proto type names (".pkg.Msg") to their
+// descriptors and declaring files for every file in the request.
+type typeIndex struct {
+	messages map[string]*descriptor.DescriptorProto
+	enums    map[string]*descriptor.EnumDescriptorProto
+	files    map[string]*descriptor.FileDescriptorProto
+}
+
+func newTypeIndex(files []*descriptor.FileDescriptorProto) *typeIndex {
+	idx := &typeIndex{
+		messages: make(map[string]*descriptor.DescriptorProto),
+		enums:    make(map[string]*descriptor.EnumDescriptorProto),
+		files:    make(map[string]*descriptor.FileDescriptorProto),
+	}
+	for _, f := range files {
+		prefix := ""
+		if f.GetPackage() != "" {
+			prefix = "." + f.GetPackage()
+		}
+		idx.addMessages(f, prefix, f.GetMessageType())
+		idx.addEnums(f, prefix, f.GetEnumType())
+	}
+	return idx
+}
+
+func (idx *typeIndex) addMessages(f *descriptor.FileDescriptorProto, prefix string, msgs []*descriptor.DescriptorProto) {
+	for _, m := range msgs {
+		name := prefix + "." + m.GetName()
+		idx.messages[name] = m
+		idx.files[name] = f
+		idx.addMessages(f, name, m.GetNestedType())
+		idx.addEnums(f, name, m.GetEnumType())
+	}
+}
+
+func (idx *typeIndex) addEnums(f *descriptor.FileDescriptorProto, prefix string, enums []*descriptor.EnumDescriptorProto) {
+	for _, e := range enums {
+		name := prefix + "." + e.GetName()
+		idx.enums[name] = e
+		idx.files[name] = f
+	}
+}
+
+// isMap reports whether field is a map field.
+func (idx *typeIndex) isMap(field *descriptor.FieldDescriptorProto) bool {
+	if field.GetType() != descriptor.FieldDescriptorProto_TYPE_MESSAGE {
+		return false
+	}
+	return idx.messages[field.GetTypeName()].GetOptions().GetMapEntry()
+}
+
+// parseParams splits the comma separated key=value plugin parameter.
+func parseParams(param string) map[string]string {
+	params := make(map[string]string)
+	for _, p := range strings.Split(param, ",") {
+		if p == "" {
+			continue
+		}
+		if i := strings.IndexByte(p, '='); i >= 0 {
+			params[p[:i]] = p[i+1:]
+		} else {
+			params[p] = ""
+		}
+	}
+	return params
+}
+
+// camelCase returns the Go field name protoc-gen-go derives from s.
+func camelCase(s string) string {
+	if s == "" {
+		return ""
+	}
+	t := make([]byte, 0, 32)
+	i := 0
+	if s[0] == '_' {
+		// Need a capital letter; drop the '_'.
+		t = append(t, 'X')
+		i++
+	}
+	for ; i < len(s); i++ {
+		c := s[i]
+		if c == '_' && i+1 < len(s) && isASCIILower(s[i+1]) {
+			continue
+		}
+		if isASCIIDigit(c) {
+			t = append(t, c)
+			continue
+		}
+		if isASCIILower(c) {
+			c ^= ' '
+		}
+		t = append(t, c)
+		for i+1 < len(s) && isASCIILower(s[i+1]) {
+			i++
+			t = append(t, s[i])
+		}
+	}
+	return string(t)
+}
+
+func isASCIILower(c byte) bool {
+	return 'a' <= c && c <= 'z'
+}
+
+func isASCIIDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+// sanitizePackageName replaces unallowed character in package name
+// with allowed character.
+func sanitizePackageName(pkgName string) string {
+	pkgName = strings.Replace(pkgName, ".", "_", -1)
+	pkgName = strings.Replace(pkgName, "-", "_", -1)
+	return pkgName
+}
+
+// defaultGoPackageName returns the default go package name to be used for go files generated from "f".
+// You might need to use an unique alias for the package when you import it.  Use ReserveGoPackageAlias to get a unique alias.
+func defaultGoPackageName(f *descriptor.FileDescriptorProto) string {
+	name := packageIdentityName(f)
+	return sanitizePackageName(name)
+}
+
+// packageIdentityName returns the identity of packages.
+// protoc-gen-grpc-gateway rejects CodeGenerationRequests which contains more than one packages
+// as protoc-gen-go does.
+func packageIdentityName(f *descriptor.FileDescriptorProto) string {
+	if f.Options != nil && f.Options.GoPackage != nil {
+		gopkg := f.Options.GetGoPackage()
+		idx := strings.LastIndex(gopkg, "/")
+		if idx < 0 {
+			gopkg = gopkg[idx+1:]
+		}
+
+		gopkg = gopkg[idx+1:]
+		// package name is overrided with the string after the
+		// ';' character
+		sc := strings.IndexByte(gopkg, ';')
+		if sc < 0 {
+			return sanitizePackageName(gopkg)
+
+		}
+		return sanitizePackageName(gopkg[sc+1:])
+	}
+
+	if f.Package == nil {
+		base := filepath.Base(f.GetName())
+		ext := filepath.Ext(base)
+		return strings.TrimSuffix(base, ext)
+	}
+	return f.GetPackage()
+}
+
+func emitFiles(out []*plugin.CodeGeneratorResponse_File) {
+	emitResp(&plugin.CodeGeneratorResponse{File: out})
+}
+
+func emitError(err error) {
+	emitResp(&plugin.CodeGeneratorResponse{Error: proto.String(err.Error())})
+}
+
+func emitResp(resp *plugin.CodeGeneratorResponse) {
+	buf, err := proto.Marshal(resp)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if _, err := os.Stdout.Write(buf); err != nil {
+		log.Fatal(err)
+	}
+}
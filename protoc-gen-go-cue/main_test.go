@@ -0,0 +1,65 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/f4tq/protoc-go-plugins/internal/plugintest"
+)
+
+const groupProto = `
+syntax = "proto2";
+
+package doc.v1;
+
+option go_package = "example.com/doc/v1;docv1";
+
+message Doc {
+    optional group Meta = 1 {
+        optional string author = 2;
+    }
+    repeated group Part = 3 {
+        optional bytes data = 4;
+    }
+}
+`
+
+// TestGroups checks group fields are defined by their messages.
+func TestGroups(t *testing.T) {
+	req := plugintest.Request(t, "", map[string]string{"doc/v1/doc.proto": groupProto})
+	got := plugintest.Generate(t, req, generate)["doc/v1/docv1.cue"]
+	for _, want := range []string{"meta?: #Doc_Meta", "part?: [...#Doc_Part]", "#Doc_Meta: {", "#Doc_Part: {"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("definitions do not contain %q:\n%s", want, got)
+		}
+	}
+}
+
+const commentedProto = `
+syntax = "proto3";
+
+package doc.v1;
+
+option go_package = "example.com/doc/v1;docv1";
+
+// Doc is a document.
+message Doc {
+    // title names the document.
+    string title = 1; // Shown in lists.
+}
+`
+
+// TestComments checks the definitions carry the leading and trailing
+// comments of the declarations.
+func TestComments(t *testing.T) {
+	req := plugintest.Request(t, "", map[string]string{"doc/v1/doc.proto": commentedProto})
+	got := plugintest.Generate(t, req, generate)["doc/v1/docv1.cue"]
+	for _, want := range []string{
+		"// Doc is a document.\n#Doc: {",
+		"\t// title names the document.\n\t//\n\t// Shown in lists.\n\ttitle?: string",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("the definitions does not contain %q:\n%s", want, got)
+		}
+	}
+}
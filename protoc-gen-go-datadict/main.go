@@ -0,0 +1,508 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+
+	"github.com/f4tq/protoc-go-plugins/options"
+	"github.com/f4tq/protoc-go-plugins/protodoc"
+)
+
+// classes are the data classifications, from the least to the most
+// sensitive.
+var classes = []string{"public", "internal", "confidential", "restricted"}
+
+// unclassified is the classification of the fields nothing classifies.
+const unclassified = "unclassified"
+
+// columns are the columns of the dictionary.
+var columns = []string{"File", "Package", "Message", "Field", "Number", "Type", "Label", "JSON Name", "Sensitivity", "Comment"}
+
+func main() {
+	input, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	req := new(plugin.CodeGeneratorRequest)
+	if err = proto.Unmarshal(input, req); err != nil {
+		log.Fatal(err)
+	}
+
+	out, err := generate(req)
+
+	if err != nil {
+		emitError(err)
+		return
+	}
+
+	emitFiles(out)
+}
+
+// generate writes the data dictionary of the files to generate: a row for
+// each of their messages, nested ones included, followed by a row for each
+// of its fields. name is the parameter of that name, "datadict" by
+// default, and the format parameter selects "csv", written to <name>.csv,
+// "xlsx", written to <name>.xlsx, or both, separated by "+". The default
+// is "csv".
+//
+// The sensitivity of a field is its (f4tq.plugins.data_class). Without
+// one, it is the most sensitive of the (f4tq.plugins.default_data_class) of
+// its message, "confidential" for (f4tq.plugins.sensitive) fields and
+// "restricted" for (f4tq.plugins.encrypted) ones, or "unclassified".
+func generate(req *plugin.CodeGeneratorRequest) ([]*plugin.CodeGeneratorResponse_File, error) {
+	params := parseParams(req.GetParameter())
+	name := params["name"]
+	if name == "" {
+		name = "datadict"
+	}
+	format := params["format"]
+	if format == "" {
+		format = "csv"
+	}
+
+	idx := newTypeIndex(req.GetProtoFile())
+	docs := protodoc.New(req.GetProtoFile())
+	genFileNames := make(map[string]bool)
+	for _, n := range req.FileToGenerate {
+		genFileNames[n] = true
+	}
+	rows := [][]string{columns}
+	checked := false
+	for _, desc := range req.GetProtoFile() {
+		if _, ok := genFileNames[desc.GetName()]; !ok {
+			// Only list the files present in req.FileToGenerate.
+			continue
+		}
+		checked = true
+		d := &dictGen{desc: desc, idx: idx, docs: docs}
+		if err := d.messages(desc.GetPackage(), desc.GetMessageType()); err != nil {
+			return nil, fmt.Errorf("%s: %v", desc.GetName(), err)
+		}
+		rows = append(rows, d.rows...)
+	}
+	if !checked {
+		return nil, nil
+	}
+
+	var files []*plugin.CodeGeneratorResponse_File
+	for _, f := range strings.Split(format, "+") {
+		var content []byte
+		var err error
+		switch f {
+		case "csv":
+			content, err = writeCSV(rows)
+		case "xlsx":
+			content, err = writeXLSX(rows)
+		default:
+			return nil, fmt.Errorf("invalid format %q", f)
+		}
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, &plugin.CodeGeneratorResponse_File{
+			Name:    proto.String(name + "." + f),
+			Content: proto.String(string(content)),
+		})
+	}
+	return files, nil
+}
+
+// dictGen lists the messages and fields of a file.
+type dictGen struct {
+	desc *descriptor.FileDescriptorProto
+	idx  *typeIndex
+	docs *protodoc.Index
+	rows [][]string
+}
+
+// messages adds the rows of msgs and the messages nested in them. scope is
+// the full proto name of their parent.
+func (d *dictGen) messages(scope string, msgs []*descriptor.DescriptorProto) error {
+	for _, msg := range msgs {
+		if msg.GetOptions().GetMapEntry() {
+			continue
+		}
+		fullName := qualify(scope, msg.GetName())
+		display := strings.TrimPrefix(fullName, d.desc.GetPackage()+".")
+		defaultClass := options.DefaultDataClass(msg)
+		if defaultClass != "" && rank(defaultClass) < 0 {
+			return fmt.Errorf("%s: invalid (f4tq.plugins.default_data_class) %q, want one of %s", fullName, defaultClass, strings.Join(classes, ", "))
+		}
+		label := ""
+		if msg.GetOptions().GetDeprecated() {
+			label = "deprecated"
+		}
+		d.rows = append(d.rows, []string{
+			d.desc.GetName(), d.desc.GetPackage(), display, "", "", "message", label, "", defaultClass, d.comment(fullName),
+		})
+		for _, field := range msg.GetField() {
+			class, err := classify(field, defaultClass)
+			if err != nil {
+				return fmt.Errorf("%s.%s: %v", fullName, field.GetName(), err)
+			}
+			d.rows = append(d.rows, []string{
+				d.desc.GetName(),
+				d.desc.GetPackage(),
+				display,
+				field.GetName(),
+				strconv.Itoa(int(field.GetNumber())),
+				d.fieldType(field),
+				d.label(msg, field),
+				jsonName(field),
+				class,
+				d.comment(fullName + "." + field.GetName()),
+			})
+		}
+		if err := d.messages(fullName, msg.GetNestedType()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// classify returns the sensitivity of field, of a message whose
+// (f4tq.plugins.default_data_class) is defaultClass.
+func classify(field *descriptor.FieldDescriptorProto, defaultClass string) (string, error) {
+	if class := options.DataClass(field); class != "" {
+		if rank(class) < 0 {
+			return "", fmt.Errorf("invalid (f4tq.plugins.data_class) %q, want one of %s", class, strings.Join(classes, ", "))
+		}
+		return class, nil
+	}
+	class := defaultClass
+	if options.Sensitive(field) && rank(class) < rank("confidential") {
+		class = "confidential"
+	}
+	if options.Encrypted(field) {
+		class = "restricted"
+	}
+	if class == "" {
+		return unclassified, nil
+	}
+	return class, nil
+}
+
+// rank returns the index of class in classes, or -1.
+func rank(class string) int {
+	for i, c := range classes {
+		if c == class {
+			return i
+		}
+	}
+	return -1
+}
+
+// fieldType returns the proto type of field, e.g. "string",
+// "shop.v1.Item" or "map<string, int64>".
+func (d *dictGen) fieldType(field *descriptor.FieldDescriptorProto) string {
+	if d.idx.isMap(field) {
+		entry := d.idx.messages[field.GetTypeName()]
+		return fmt.Sprintf("map<%s, %s>", d.fieldType(entry.GetField()[0]), d.fieldType(entry.GetField()[1]))
+	}
+	switch field.GetType() {
+	case descriptor.FieldDescriptorProto_TYPE_MESSAGE, descriptor.FieldDescriptorProto_TYPE_GROUP,
+		descriptor.FieldDescriptorProto_TYPE_ENUM:
+		return strings.TrimPrefix(field.GetTypeName(), ".")
+	}
+	return strings.ToLower(strings.TrimPrefix(field.GetType().String(), "TYPE_"))
+}
+
+// label returns the label of field: its cardinality, oneof and
+// deprecation.
+func (d *dictGen) label(msg *descriptor.DescriptorProto, field *descriptor.FieldDescriptorProto) string {
+	var labels []string
+	switch {
+	case d.idx.isMap(field):
+	case field.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REPEATED:
+		labels = append(labels, "repeated")
+	case field.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REQUIRED:
+		labels = append(labels, "required")
+	case field.GetProto3Optional() || (field.OneofIndex == nil && d.desc.GetSyntax() != "proto3"):
+		labels = append(labels, "optional")
+	case field.OneofIndex != nil:
+		labels = append(labels, "oneof "+msg.GetOneofDecl()[field.GetOneofIndex()].GetName())
+	}
+	if field.GetOptions().GetDeprecated() {
+		labels = append(labels, "deprecated")
+	}
+	return strings.Join(labels, ", ")
+}
+
+// comment returns the comment of the declaration name on one line.
+func (d *dictGen) comment(name string) string {
+	return strings.Join(strings.Fields(d.docs.Comment(name)), " ")
+}
+
+// jsonName returns the JSON name of field, which protoc sets, or else the
+// lower camel case of its name.
+func jsonName(field *descriptor.FieldDescriptorProto) string {
+	if field.GetJsonName() != "" {
+		return field.GetJsonName()
+	}
+	name := camelCase(field.GetName())
+	return strings.ToLower(name[:1]) + name[1:]
+}
+
+// writeCSV returns rows as CSV.
+func writeCSV(rows [][]string) ([]byte, error) {
+	var b bytes.Buffer
+	w := csv.NewWriter(&b)
+	if err := w.WriteAll(rows); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// xlsxParts are the parts of the workbook besides its sheet, by name.
+var xlsxParts = []struct{ name, content string }{
+	{"[Content_Types].xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">
+<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>
+<Default Extension="xml" ContentType="application/xml"/>
+<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>
+<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>
+<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>
+</Types>
+`},
+	{"_rels/.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>
+</Relationships>
+`},
+	{"xl/workbook.xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">
+<sheets><sheet name="Data Dictionary" sheetId="1" r:id="rId1"/></sheets>
+<definedNames><definedName name="_xlnm._FilterDatabase" localSheetId="0" hidden="1">'Data Dictionary'!$A$1:$%[1]s$%[2]d</definedName></definedNames>
+</workbook>
+`},
+	{"xl/_rels/workbook.xml.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>
+<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>
+</Relationships>
+`},
+	// Style 1, that of the header row, is bold.
+	{"xl/styles.xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">
+<fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts>
+<fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills>
+<borders count="1"><border/></borders>
+<cellStyleXfs count="1"><xf/></cellStyleXfs>
+<cellXfs count="2"><xf/><xf fontId="1" applyFont="1"/></cellXfs>
+</styleSheet>
+`},
+}
+
+// writeXLSX returns rows as an Excel workbook of one sheet, whose first
+// row, the header, is bold, frozen and filters the others.
+func writeXLSX(rows [][]string) ([]byte, error) {
+	last := column(len(columns) - 1)
+	var sheet bytes.Buffer
+	sheet.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">
+<sheetViews><sheetView workbookViewId="0"><pane ySplit="1" topLeftCell="A2" activePane="bottomLeft" state="frozen"/></sheetView></sheetViews>
+<sheetData>
+`)
+	for i, row := range rows {
+		fmt.Fprintf(&sheet, `<row r="%d">`, i+1)
+		for j, v := range row {
+			if v == "" {
+				continue
+			}
+			style := ""
+			if i == 0 {
+				style = ` s="1"`
+			}
+			fmt.Fprintf(&sheet, `<c r="%s%d"%s t="inlineStr"><is><t xml:space="preserve">`, column(j), i+1, style)
+			if err := xml.EscapeText(&sheet, []byte(v)); err != nil {
+				return nil, err
+			}
+			sheet.WriteString(`</t></is></c>`)
+		}
+		sheet.WriteString("</row>\n")
+	}
+	fmt.Fprintf(&sheet, "</sheetData>\n<autoFilter ref=\"A1:%s%d\"/>\n</worksheet>\n", last, len(rows))
+
+	var b bytes.Buffer
+	z := zip.NewWriter(&b)
+	for _, p := range xlsxParts {
+		content := p.content
+		if p.name == "xl/workbook.xml" {
+			content = fmt.Sprintf(content, last, len(rows))
+		}
+		w, err := z.Create(p.name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := w.Write([]byte(content)); err != nil {
+			return nil, err
+		}
+	}
+	w, err := z.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := sheet.WriteTo(w); err != nil {
+		return nil, err
+	}
+	if err := z.Close(); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// column returns the name of the column of index i, from 0: "A" to "Z",
+// then "AA".
+func column(i int) string {
+	name := ""
+	for i++; i > 0; i = (i - 1) / 26 {
+		name = string(rune('A'+(i-1)%26)) + name
+	}
+	return name
+}
+
+// qualify returns the full proto name of name, declared in scope.
+func qualify(scope, name string) string {
+	if scope == "" {
+		return name
+	}
+	return scope + "." + name
+}
+
+// typeIndex maps fully-qualified proto type names (".pkg.Msg") to their
+// descriptors and declaring files for every file in the request.
+type typeIndex struct {
+	messages map[string]*descriptor.DescriptorProto
+	enums    map[string]*descriptor.EnumDescriptorProto
+	files    map[string]*descriptor.FileDescriptorProto
+}
+
+func newTypeIndex(files []*descriptor.FileDescriptorProto) *typeIndex {
+	idx := &typeIndex{
+		messages: make(map[string]*descriptor.DescriptorProto),
+		enums:    make(map[string]*descriptor.EnumDescriptorProto),
+		files:    make(map[string]*descriptor.FileDescriptorProto),
+	}
+	for _, f := range files {
+		prefix := ""
+		if f.GetPackage() != "" {
+			prefix = "." + f.GetPackage()
+		}
+		idx.addMessages(f, prefix, f.GetMessageType())
+		idx.addEnums(f, prefix, f.GetEnumType())
+	}
+	return idx
+}
+
+func (idx *typeIndex) addMessages(f *descriptor.FileDescriptorProto, prefix string, msgs []*descriptor.DescriptorProto) {
+	for _, m := range msgs {
+		name := prefix + "." + m.GetName()
+		idx.messages[name] = m
+		idx.files[name] = f
+		idx.addMessages(f, name, m.GetNestedType())
+		idx.addEnums(f, name, m.GetEnumType())
+	}
+}
+
+func (idx *typeIndex) addEnums(f *descriptor.FileDescriptorProto, prefix string, enums []*descriptor.EnumDescriptorProto) {
+	for _, e := range enums {
+		name := prefix + "." + e.GetName()
+		idx.enums[name] = e
+		idx.files[name] = f
+	}
+}
+
+// isMap reports whether field is a map field.
+func (idx *typeIndex) isMap(field *descriptor.FieldDescriptorProto) bool {
+	if field.GetType() != descriptor.FieldDescriptorProto_TYPE_MESSAGE {
+		return false
+	}
+	return idx.messages[field.GetTypeName()].GetOptions().GetMapEntry()
+}
+
+// camelCase returns the Go field name protoc-gen-go derives from s.
+func camelCase(s string) string {
+	if s == "" {
+		return ""
+	}
+	t := make([]byte, 0, 32)
+	i := 0
+	if s[0] == '_' {
+		// Need a capital letter; drop the '_'.
+		t = append(t, 'X')
+		i++
+	}
+	for ; i < len(s); i++ {
+		c := s[i]
+		if c == '_' && i+1 < len(s) && isASCIILower(s[i+1]) {
+			continue
+		}
+		if isASCIIDigit(c) {
+			t = append(t, c)
+			continue
+		}
+		if isASCIILower(c) {
+			c ^= ' '
+		}
+		t = append(t, c)
+		for i+1 < len(s) && isASCIILower(s[i+1]) {
+			i++
+			t = append(t, s[i])
+		}
+	}
+	return string(t)
+}
+
+func isASCIILower(c byte) bool {
+	return 'a' <= c && c <= 'z'
+}
+
+func isASCIIDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+func parseParams(param string) map[string]string {
+	params := make(map[string]string)
+	for _, p := range strings.Split(param, ",") {
+		if p == "" {
+			continue
+		}
+		if i := strings.IndexByte(p, '='); i >= 0 {
+			params[p[:i]] = p[i+1:]
+		} else {
+			params[p] = ""
+		}
+	}
+	return params
+}
+
+func emitFiles(out []*plugin.CodeGeneratorResponse_File) {
+	emitResp(&plugin.CodeGeneratorResponse{File: out})
+}
+
+func emitError(err error) {
+	emitResp(&plugin.CodeGeneratorResponse{Error: proto.String(err.Error())})
+}
+
+func emitResp(resp *plugin.CodeGeneratorResponse) {
+	buf, err := proto.Marshal(resp)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if _, err := os.Stdout.Write(buf); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,463 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+
+	"github.com/f4tq/protoc-go-plugins/options"
+)
+
+var (
+	upTmpl = template.Must(template.New("up").Parse(`-- Code generated by protoc-gen-go-ddl. DO NOT EDIT.
+-- source: {{.Sources}}
+{{- range .Tables}}
+
+CREATE TABLE {{.Name}} (
+{{- range $i, $c := .Columns}}{{if $i}},{{end}}
+    {{$c.Name}} {{$c.SQLType}}{{if not $c.Nullable}} NOT NULL{{end}}
+{{- end}}
+{{- if .Keys}},
+    PRIMARY KEY ({{range $i, $c := .Keys}}{{if $i}}, {{end}}{{$c}}{{end}})
+{{- end}}
+);
+{{- range .Indexes}}
+CREATE {{if .Unique}}UNIQUE {{end}}INDEX {{.Name}} ON {{.Table}} ({{.Column}});
+{{- end}}
+{{- end}}
+{{- range .ForeignKeys}}
+
+ALTER TABLE {{.Table}} ADD CONSTRAINT {{.Name}}
+    FOREIGN KEY ({{range $i, $c := .Columns}}{{if $i}}, {{end}}{{$c}}{{end}})
+    REFERENCES {{.RefTable}} ({{range $i, $c := .RefColumns}}{{if $i}}, {{end}}{{$c}}{{end}});
+{{- end}}
+`))
+
+	downTmpl = template.Must(template.New("down").Parse(`-- Code generated by protoc-gen-go-ddl. DO NOT EDIT.
+-- source: {{.Sources}}
+{{- range .ForeignKeys}}
+ALTER TABLE {{.Table}} DROP {{$.DropConstraint}} {{.Name}};
+{{- end}}
+{{- range .Reversed}}
+DROP TABLE IF EXISTS {{.Name}};
+{{- end}}
+`))
+)
+
+func main() {
+	input, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	req := new(plugin.CodeGeneratorRequest)
+	if err = proto.Unmarshal(input, req); err != nil {
+		log.Fatal(err)
+	}
+
+	out, err := generate(req)
+
+	if err != nil {
+		emitError(err)
+		return
+	}
+
+	emitFiles(out)
+}
+
+// generate writes one golang-migrate migration creating the tables of the
+// messages with a (f4tq.plugins.table) option in all the files to
+// generate: <version>_<name>.up.sql and .down.sql, with version 1 and name
+// "schema" unless set by the parameters of those names. The dialect
+// parameter selects "postgres", the default, "mysql" or both, separated by
+// "+", each written to the directory of its name when both are.
+//
+// A singular field of a message stored in a table becomes a foreign key
+// to that table: a column per primary key column of the referenced table,
+// named by the field's (f4tq.plugins.column) followed by "_" and the
+// referenced column.
+func generate(req *plugin.CodeGeneratorRequest) ([]*plugin.CodeGeneratorResponse_File, error) {
+	params := parseParams(req.GetParameter())
+	version := params["version"]
+	if version == "" {
+		version = "1"
+	}
+	if _, err := strconv.ParseUint(version, 10, 64); err != nil {
+		return nil, fmt.Errorf("version %q is not a migration version: %v", version, err)
+	}
+	name := params["name"]
+	if name == "" {
+		name = "schema"
+	}
+	dialects := strings.Split(params["dialect"], "+")
+	if params["dialect"] == "" {
+		dialects = []string{"postgres"}
+	}
+
+	idx := newTypeIndex(req.GetProtoFile())
+	var descs []*descriptor.FileDescriptorProto
+	var sources []string
+	genFileNames := make(map[string]bool)
+	for _, n := range req.FileToGenerate {
+		genFileNames[n] = true
+	}
+	for _, desc := range req.GetProtoFile() {
+		if _, ok := genFileNames[desc.GetName()]; !ok {
+			// Only create the tables of the files in req.FileToGenerate.
+			continue
+		}
+		descs = append(descs, desc)
+		sources = append(sources, desc.GetName())
+	}
+
+	var files []*plugin.CodeGeneratorResponse_File
+	for _, d := range dialects {
+		var dia *dialect
+		switch d {
+		case "postgres":
+			dia = postgres
+		case "mysql":
+			dia = mysql
+		default:
+			return nil, fmt.Errorf("unknown dialect %q, want postgres or mysql", d)
+		}
+		s := &schema{Sources: strings.Join(sources, ", "), DropConstraint: dia.dropConstraint}
+		g := &ddlGen{idx: idx, dialect: dia, schema: s}
+		for _, desc := range descs {
+			if err := g.file(desc); err != nil {
+				return nil, err
+			}
+		}
+		if len(s.Tables) == 0 {
+			// Nothing annotated with (f4tq.plugins.table).
+			return nil, nil
+		}
+		for i := len(s.Tables) - 1; i >= 0; i-- {
+			s.Reversed = append(s.Reversed, s.Tables[i])
+		}
+		prefix := ""
+		if len(dialects) > 1 {
+			prefix = d + "/"
+		}
+		for _, m := range []struct {
+			tmpl *template.Template
+			dir  string
+		}{{upTmpl, "up"}, {downTmpl, "down"}} {
+			w := bytes.NewBuffer(nil)
+			if err := m.tmpl.Execute(w, s); err != nil {
+				return nil, err
+			}
+			files = append(files, &plugin.CodeGeneratorResponse_File{
+				Name:    proto.String(fmt.Sprintf("%s%s_%s.%s.sql", prefix, version, name, m.dir)),
+				Content: proto.String(w.String()),
+			})
+		}
+	}
+	return files, nil
+}
+
+// dialect holds the differences between the SQL of the databases.
+type dialect struct {
+	// types maps the column types to those of the dialect.
+	types map[string]string
+	// keyString is the type of the string columns that are keyed,
+	// indexed or referenced.
+	keyString string
+	// indexForeignKeys creates indexes on the foreign key columns, which
+	// the database does not.
+	indexForeignKeys bool
+	dropConstraint   string
+}
+
+var (
+	postgres = &dialect{
+		types: map[string]string{
+			"string":    "text",
+			"bool":      "boolean",
+			"bytes":     "bytea",
+			"int32":     "integer",
+			"int64":     "bigint",
+			"uint32":    "bigint",
+			"uint64":    "numeric(20)",
+			"float":     "real",
+			"double":    "double precision",
+			"enum":      "integer",
+			"timestamp": "timestamptz",
+		},
+		keyString:        "text",
+		indexForeignKeys: true,
+		dropConstraint:   "CONSTRAINT",
+	}
+	mysql = &dialect{
+		types: map[string]string{
+			"string":    "TEXT",
+			"bool":      "BOOLEAN",
+			"bytes":     "BLOB",
+			"int32":     "INT",
+			"int64":     "BIGINT",
+			"uint32":    "INT UNSIGNED",
+			"uint64":    "BIGINT UNSIGNED",
+			"float":     "FLOAT",
+			"double":    "DOUBLE",
+			"enum":      "INT",
+			"timestamp": "DATETIME(6)",
+		},
+		// TEXT columns cannot be keyed without a prefix length.
+		keyString:      "VARCHAR(255)",
+		dropConstraint: "FOREIGN KEY",
+	}
+)
+
+type ddlGen struct {
+	idx     *typeIndex
+	dialect *dialect
+	schema  *schema
+}
+
+func (g *ddlGen) file(desc *descriptor.FileDescriptorProto) error {
+	for _, msg := range desc.GetMessageType() {
+		name := options.Table(msg)
+		if name == "" {
+			continue
+		}
+		t := &table{Name: name}
+		for _, field := range msg.GetField() {
+			if field.OneofIndex != nil || field.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REPEATED {
+				// Oneof members (including proto3 optional) and repeated
+				// fields have no single column representation.
+				continue
+			}
+			if err := g.field(t, field); err != nil {
+				return fmt.Errorf("%s: %s.%s: %v", desc.GetName(), msg.GetName(), field.GetName(), err)
+			}
+		}
+		if len(t.Columns) == 0 {
+			return fmt.Errorf("%s: %s: table %q has no columns", desc.GetName(), msg.GetName(), name)
+		}
+		g.schema.Tables = append(g.schema.Tables, t)
+	}
+	return nil
+}
+
+// field adds the columns of field to t, with its key, indexes and foreign
+// key.
+func (g *ddlGen) field(t *table, field *descriptor.FieldDescriptorProto) error {
+	name := options.Column(field)
+	keyed := options.PrimaryKey(field) || options.Index(field) || options.Unique(field)
+	if field.GetType() == descriptor.FieldDescriptorProto_TYPE_MESSAGE {
+		ref := g.idx.messages[field.GetTypeName()]
+		if field.GetTypeName() == ".google.protobuf.Timestamp" {
+			t.Columns = append(t.Columns, &column{Name: name, SQLType: g.dialect.types["timestamp"], Nullable: true})
+			g.indexes(t, field, name)
+			return nil
+		}
+		refTable := options.Table(ref)
+		if refTable == "" {
+			// Messages not stored in tables have no column.
+			return nil
+		}
+		fk := &foreignKey{Table: t.Name, Name: t.Name + "_" + name + "_fkey", RefTable: refTable}
+		for _, rf := range ref.GetField() {
+			if !options.PrimaryKey(rf) {
+				continue
+			}
+			typ, err := g.columnType(rf, true)
+			if err != nil {
+				return err
+			}
+			col := name + "_" + options.Column(rf)
+			// A reference may be unset.
+			t.Columns = append(t.Columns, &column{Name: col, SQLType: typ, Nullable: !options.PrimaryKey(field)})
+			fk.Columns = append(fk.Columns, col)
+			fk.RefColumns = append(fk.RefColumns, options.Column(rf))
+		}
+		if len(fk.Columns) == 0 {
+			return fmt.Errorf("table %q of %s has no primary key to refer to", refTable, strings.TrimPrefix(field.GetTypeName(), "."))
+		}
+		if options.PrimaryKey(field) {
+			t.Keys = append(t.Keys, fk.Columns...)
+		}
+		g.schema.ForeignKeys = append(g.schema.ForeignKeys, fk)
+		if g.dialect.indexForeignKeys && !options.PrimaryKey(field) {
+			t.Indexes = append(t.Indexes, &index{
+				Name:   t.Name + "_" + name + "_idx",
+				Table:  t.Name,
+				Column: strings.Join(fk.Columns, ", "),
+			})
+		}
+		return nil
+	}
+	typ, err := g.columnType(field, keyed)
+	if err != nil {
+		return err
+	}
+	t.Columns = append(t.Columns, &column{Name: name, SQLType: typ})
+	if options.PrimaryKey(field) {
+		t.Keys = append(t.Keys, name)
+	}
+	g.indexes(t, field, name)
+	return nil
+}
+
+// indexes adds the indexes field requests on the column name of t.
+func (g *ddlGen) indexes(t *table, field *descriptor.FieldDescriptorProto, name string) {
+	if options.Unique(field) {
+		t.Indexes = append(t.Indexes, &index{Name: t.Name + "_" + name + "_key", Table: t.Name, Column: name, Unique: true})
+	} else if options.Index(field) {
+		t.Indexes = append(t.Indexes, &index{Name: t.Name + "_" + name + "_idx", Table: t.Name, Column: name})
+	}
+}
+
+// columnType returns the SQL type of the column of the scalar or enum
+// field, keyed if it is part of a key, an index or a foreign key.
+func (g *ddlGen) columnType(field *descriptor.FieldDescriptorProto, keyed bool) (string, error) {
+	var typ string
+	switch field.GetType() {
+	case descriptor.FieldDescriptorProto_TYPE_STRING:
+		if keyed {
+			return g.dialect.keyString, nil
+		}
+		typ = "string"
+	case descriptor.FieldDescriptorProto_TYPE_BOOL:
+		typ = "bool"
+	case descriptor.FieldDescriptorProto_TYPE_BYTES:
+		typ = "bytes"
+	case descriptor.FieldDescriptorProto_TYPE_INT32,
+		descriptor.FieldDescriptorProto_TYPE_SINT32,
+		descriptor.FieldDescriptorProto_TYPE_SFIXED32:
+		typ = "int32"
+	case descriptor.FieldDescriptorProto_TYPE_INT64,
+		descriptor.FieldDescriptorProto_TYPE_SINT64,
+		descriptor.FieldDescriptorProto_TYPE_SFIXED64:
+		typ = "int64"
+	case descriptor.FieldDescriptorProto_TYPE_UINT32,
+		descriptor.FieldDescriptorProto_TYPE_FIXED32:
+		typ = "uint32"
+	case descriptor.FieldDescriptorProto_TYPE_UINT64,
+		descriptor.FieldDescriptorProto_TYPE_FIXED64:
+		typ = "uint64"
+	case descriptor.FieldDescriptorProto_TYPE_FLOAT:
+		typ = "float"
+	case descriptor.FieldDescriptorProto_TYPE_DOUBLE:
+		typ = "double"
+	case descriptor.FieldDescriptorProto_TYPE_ENUM:
+		typ = "enum"
+	default:
+		return "", fmt.Errorf("unsupported column type %v", field.GetType())
+	}
+	return g.dialect.types[typ], nil
+}
+
+type schema struct {
+	Sources        string
+	Tables         []*table
+	Reversed       []*table
+	ForeignKeys    []*foreignKey
+	DropConstraint string
+}
+
+type table struct {
+	Name    string
+	Columns []*column
+	// Keys are the columns of the primary key.
+	Keys    []string
+	Indexes []*index
+}
+
+type column struct {
+	Name     string
+	SQLType  string
+	Nullable bool
+}
+
+type index struct {
+	Name   string
+	Table  string
+	Column string
+	Unique bool
+}
+
+// foreignKey is the constraint of the columns of a table referring to a
+// message of another; the constraints are added once all the tables are
+// created, so that the tables may refer to each other.
+type foreignKey struct {
+	Table      string
+	Name       string
+	Columns    []string
+	RefTable   string
+	RefColumns []string
+}
+
+// typeIndex maps fully-qualified proto type names (".pkg.Msg") to their
+// descriptors and declaring files for every file in the request.
+type typeIndex struct {
+	messages map[string]*descriptor.DescriptorProto
+	files    map[string]*descriptor.FileDescriptorProto
+}
+
+func newTypeIndex(files []*descriptor.FileDescriptorProto) *typeIndex {
+	idx := &typeIndex{
+		messages: make(map[string]*descriptor.DescriptorProto),
+		files:    make(map[string]*descriptor.FileDescriptorProto),
+	}
+	for _, f := range files {
+		prefix := ""
+		if f.GetPackage() != "" {
+			prefix = "." + f.GetPackage()
+		}
+		idx.addMessages(f, prefix, f.GetMessageType())
+	}
+	return idx
+}
+
+func (idx *typeIndex) addMessages(f *descriptor.FileDescriptorProto, prefix string, msgs []*descriptor.DescriptorProto) {
+	for _, m := range msgs {
+		name := prefix + "." + m.GetName()
+		idx.messages[name] = m
+		idx.files[name] = f
+		idx.addMessages(f, name, m.GetNestedType())
+	}
+}
+
+// parseParams splits the comma separated key=value plugin parameter.
+func parseParams(param string) map[string]string {
+	params := make(map[string]string)
+	for _, p := range strings.Split(param, ",") {
+		if p == "" {
+			continue
+		}
+		if i := strings.IndexByte(p, '='); i >= 0 {
+			params[p[:i]] = p[i+1:]
+		} else {
+			params[p] = ""
+		}
+	}
+	return params
+}
+
+func emitFiles(out []*plugin.CodeGeneratorResponse_File) {
+	emitResp(&plugin.CodeGeneratorResponse{File: out})
+}
+
+func emitError(err error) {
+	emitResp(&plugin.CodeGeneratorResponse{Error: proto.String(err.Error())})
+}
+
+func emitResp(resp *plugin.CodeGeneratorResponse) {
+	buf, err := proto.Marshal(resp)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if _, err := os.Stdout.Write(buf); err != nil {
+		log.Fatal(err)
+	}
+}
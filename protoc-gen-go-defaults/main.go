@@ -0,0 +1,550 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+
+	"github.com/f4tq/protoc-go-plugins/goname"
+	"github.com/f4tq/protoc-go-plugins/options"
+	"github.com/f4tq/protoc-go-plugins/protodoc"
+)
+
+var (
+	hdrTmpl = template.Must(template.New("header").Parse(`
+// Code generated by protoc-gen-go-defaults. DO NOT EDIT.
+// source: {{.Source}}
+
+package {{.GoPkg}}
+{{- if .Imports}}
+
+import (
+{{- range $path, $name := .Imports}}
+    {{$name}} "{{$path}}"
+{{- end}}
+)
+{{- end}}
+`))
+
+	defaultsTmpl = template.Must(template.New("defaults").Parse(`
+// New{{.Name}}WithDefaults returns an empty {{.Name}} with its defaults
+// applied.{{.Doc}}
+func New{{.Name}}WithDefaults() *{{.Name}} {
+    m := new({{.Name}})
+    m.ApplyDefaults()
+    return m
+}
+
+// ApplyDefaults sets the unset fields of m with a
+// (f4tq.plugins.default_value) to that value, and applies the defaults of
+// the messages m holds. Fields without presence are unset when zero.
+func (m *{{.Name}}) ApplyDefaults() {
+    if m == nil {
+        return
+    }
+{{- range .Fields}}
+{{.}}
+{{- end}}
+}
+`))
+)
+
+func main() {
+	input, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	req := new(plugin.CodeGeneratorRequest)
+	if err = proto.Unmarshal(input, req); err != nil {
+		log.Fatal(err)
+	}
+
+	out, err := generate(req)
+
+	if err != nil {
+		emitError(err)
+		return
+	}
+
+	emitFiles(out)
+}
+
+func generate(req *plugin.CodeGeneratorRequest) ([]*plugin.CodeGeneratorResponse_File, error) {
+	var files []*plugin.CodeGeneratorResponse_File
+	idx := newTypeIndex(req.GetProtoFile())
+	docs := protodoc.New(req.GetProtoFile())
+	genFileNames := make(map[string]bool)
+	for _, n := range req.FileToGenerate {
+		genFileNames[n] = true
+	}
+	has := defaultMessages(idx, genFileNames)
+	for _, desc := range req.GetProtoFile() {
+		name := desc.GetName()
+		if _, ok := genFileNames[name]; !ok {
+			// Only emit output for files present in req.FileToGenerate.
+			continue
+		}
+		code, err := genCode(desc, idx, docs, has)
+		if err != nil {
+			return nil, err
+		}
+		if code == "" {
+			// No message of the file has defaults.
+			continue
+		}
+		formatted, err := format.Source([]byte(code))
+		if err != nil {
+			log.Printf("%v: %s", err, code)
+			return nil, err
+		}
+
+		ext := filepath.Ext(name)
+		base := strings.TrimSuffix(name, ext)
+		output := fmt.Sprintf("%s.pb.defaults.go", base)
+		files = append(files, &plugin.CodeGeneratorResponse_File{
+			Name:    proto.String(output),
+			Content: proto.String(string(formatted)),
+		})
+	}
+
+	return files, nil
+}
+
+func genCode(desc *descriptor.FileDescriptorProto, idx *typeIndex, docs *protodoc.Index, has map[string]bool) (string, error) {
+	w := bytes.NewBuffer(nil)
+	g := &defaultsGen{
+		idx:     idx,
+		docs:    docs,
+		imports: newImportSet(desc),
+		proto3:  desc.GetSyntax() == "proto3",
+		has:     has,
+	}
+	body := bytes.NewBuffer(nil)
+	if err := g.messages(body, strings.TrimSuffix("."+desc.GetPackage(), "."), "", desc.GetMessageType()); err != nil {
+		return "", err
+	}
+	if body.Len() == 0 {
+		return "", nil
+	}
+
+	hdr := &header{
+		Source:  desc.GetName(),
+		GoPkg:   defaultGoPackageName(desc),
+		Imports: g.imports.names,
+	}
+	if err := hdrTmpl.Execute(w, hdr); err != nil {
+		log.Fatal(err)
+	}
+	body.WriteTo(w)
+
+	return w.String(), nil
+}
+
+type defaultsGen struct {
+	idx     *typeIndex
+	docs    *protodoc.Index
+	imports *importSet
+	proto3  bool
+	// has holds the messages with an ApplyDefaults method.
+	has map[string]bool
+}
+
+// messages writes the defaults of msgs and of the messages nested in them.
+// scope is the full proto name of their parent, prefix the Go name of the
+// enclosing message plus "_".
+func (g *defaultsGen) messages(w *bytes.Buffer, scope, prefix string, msgs []*descriptor.DescriptorProto) error {
+	for _, msg := range msgs {
+		if msg.GetOptions().GetMapEntry() {
+			continue
+		}
+		typeName := scope + "." + msg.GetName()
+		name := prefix + msg.GetName()
+		if g.hasDefaults(typeName) {
+			m := &defaultsMessage{Name: name, Doc: g.docs.Godoc(typeName)}
+			for _, field := range msg.GetField() {
+				stmt, err := g.field(name, msg, field)
+				if err != nil {
+					return fmt.Errorf("%s.%s: %v", name, field.GetName(), err)
+				}
+				if stmt != "" {
+					m.Fields = append(m.Fields, stmt)
+				}
+			}
+			if err := defaultsTmpl.Execute(w, m); err != nil {
+				return err
+			}
+		}
+		if err := g.messages(w, typeName, name+"_", msg.GetNestedType()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// hasDefaults reports whether the message typeName gets an ApplyDefaults
+// method.
+func (g *defaultsGen) hasDefaults(typeName string) bool {
+	return g.has[typeName]
+}
+
+// defaultMessages returns the generated messages that have a field with a
+// default, or a message field of such a type.
+func defaultMessages(idx *typeIndex, genFileNames map[string]bool) map[string]bool {
+	has := make(map[string]bool)
+	// Repeat until no message is added, as messages may refer to each
+	// other in cycles.
+	for changed := true; changed; {
+		changed = false
+		for typeName, msg := range idx.messages {
+			if has[typeName] || !genFileNames[idx.files[typeName].GetName()] {
+				continue
+			}
+			for _, field := range msg.GetField() {
+				if idx.isMap(field) {
+					field = idx.messages[field.GetTypeName()].GetField()[1]
+				}
+				if options.DefaultValue(field) != "" || has[field.GetTypeName()] {
+					has[typeName] = true
+					changed = true
+					break
+				}
+			}
+		}
+	}
+	return has
+}
+
+// holdsDefaults reports whether field holds messages with defaults.
+func (g *defaultsGen) holdsDefaults(field *descriptor.FieldDescriptorProto) bool {
+	if g.idx.isMap(field) {
+		field = g.idx.messages[field.GetTypeName()].GetField()[1]
+	}
+	return field.GetType() == descriptor.FieldDescriptorProto_TYPE_MESSAGE && g.hasDefaults(field.GetTypeName())
+}
+
+// field returns the statements applying the defaults of field of msg, whose
+// Go name is msgName, or "" if it has none.
+func (g *defaultsGen) field(msgName string, msg *descriptor.DescriptorProto, field *descriptor.FieldDescriptorProto) (string, error) {
+	goNames := goname.Fields(msg)
+	goName := goNames[field.GetName()]
+	oneof := field.OneofIndex != nil && !field.GetProto3Optional()
+	if g.holdsDefaults(field) {
+		switch {
+		case field.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REPEATED:
+			return fmt.Sprintf("for _, v := range m.%s {\nv.ApplyDefaults()\n}", goName), nil
+		case oneof:
+			return fmt.Sprintf("if v, ok := m.%s.(*%s_%s); ok {\nv.%s.ApplyDefaults()\n}",
+				goNames[msg.GetOneofDecl()[field.GetOneofIndex()].GetName()], msgName, goName, goName), nil
+		}
+		return fmt.Sprintf("m.%s.ApplyDefaults()", goName), nil
+	}
+	def := options.DefaultValue(field)
+	if def == "" {
+		return "", nil
+	}
+	if oneof || field.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REPEATED ||
+		field.GetType() == descriptor.FieldDescriptorProto_TYPE_MESSAGE ||
+		field.GetType() == descriptor.FieldDescriptorProto_TYPE_GROUP {
+		return "", fmt.Errorf("default_value is only supported on singular scalar and enum fields outside oneofs")
+	}
+	lit, err := g.literal(field, def)
+	if err != nil {
+		return "", err
+	}
+	switch {
+	case field.GetType() == descriptor.FieldDescriptorProto_TYPE_BYTES && (field.GetProto3Optional() || !g.proto3):
+		return fmt.Sprintf("if m.%s == nil {\nm.%s = %s\n}", goName, goName, lit), nil
+	case field.GetProto3Optional() || !g.proto3:
+		// Scalars are pointers.
+		return fmt.Sprintf("if m.%s == nil {\nm.%s = new(%s)\n*m.%s = %s\n}", goName, goName, g.goType(field), goName, lit), nil
+	}
+	var unset string
+	switch field.GetType() {
+	case descriptor.FieldDescriptorProto_TYPE_BOOL:
+		unset = "!m." + goName
+	case descriptor.FieldDescriptorProto_TYPE_STRING:
+		unset = fmt.Sprintf("m.%s == \"\"", goName)
+	case descriptor.FieldDescriptorProto_TYPE_BYTES:
+		unset = fmt.Sprintf("len(m.%s) == 0", goName)
+	default:
+		unset = fmt.Sprintf("m.%s == 0", goName)
+	}
+	return fmt.Sprintf("if %s {\nm.%s = %s\n}", unset, goName, lit), nil
+}
+
+// literal returns the Go expression of the default def of field.
+func (g *defaultsGen) literal(field *descriptor.FieldDescriptorProto, def string) (string, error) {
+	switch field.GetType() {
+	case descriptor.FieldDescriptorProto_TYPE_STRING:
+		return strconv.Quote(def), nil
+	case descriptor.FieldDescriptorProto_TYPE_BYTES:
+		return "[]byte(" + strconv.Quote(def) + ")", nil
+	case descriptor.FieldDescriptorProto_TYPE_BOOL:
+		b, err := strconv.ParseBool(def)
+		if err != nil {
+			return "", err
+		}
+		return strconv.FormatBool(b), nil
+	case descriptor.FieldDescriptorProto_TYPE_ENUM:
+		for _, v := range g.idx.enums[field.GetTypeName()].GetValue() {
+			if v.GetName() == def {
+				return fmt.Sprintf("%s(%d)", g.goType(field), v.GetNumber()), nil
+			}
+		}
+		return "", fmt.Errorf("%s has no value %s", field.GetTypeName(), def)
+	case descriptor.FieldDescriptorProto_TYPE_DOUBLE, descriptor.FieldDescriptorProto_TYPE_FLOAT:
+		bits := 64
+		if field.GetType() == descriptor.FieldDescriptorProto_TYPE_FLOAT {
+			bits = 32
+		}
+		f, err := strconv.ParseFloat(def, bits)
+		if err != nil {
+			return "", err
+		}
+		if f != f || f > math.MaxFloat64 || f < -math.MaxFloat64 {
+			return "", fmt.Errorf("default %s is not finite", def)
+		}
+		return strconv.FormatFloat(f, 'g', -1, bits), nil
+	}
+	typ := scalarGoType(field.GetType())
+	bits, _ := strconv.Atoi(strings.TrimLeft(typ, "uint"))
+	if strings.HasPrefix(typ, "uint") {
+		u, err := strconv.ParseUint(def, 0, bits)
+		if err != nil {
+			return "", err
+		}
+		return strconv.FormatUint(u, 10), nil
+	}
+	i, err := strconv.ParseInt(def, 0, bits)
+	if err != nil {
+		return "", err
+	}
+	return strconv.FormatInt(i, 10), nil
+}
+
+// goType returns the Go type of a value of field.
+func (g *defaultsGen) goType(field *descriptor.FieldDescriptorProto) string {
+	switch field.GetType() {
+	case descriptor.FieldDescriptorProto_TYPE_MESSAGE, descriptor.FieldDescriptorProto_TYPE_GROUP:
+		return "*" + g.imports.goTypeName(g.idx, field.GetTypeName())
+	case descriptor.FieldDescriptorProto_TYPE_ENUM:
+		return g.imports.goTypeName(g.idx, field.GetTypeName())
+	case descriptor.FieldDescriptorProto_TYPE_BYTES:
+		return "[]byte"
+	}
+	return scalarGoType(field.GetType())
+}
+
+// scalarGoType returns the Go type of the scalar type t.
+func scalarGoType(t descriptor.FieldDescriptorProto_Type) string {
+	switch t {
+	case descriptor.FieldDescriptorProto_TYPE_DOUBLE:
+		return "float64"
+	case descriptor.FieldDescriptorProto_TYPE_FLOAT:
+		return "float32"
+	case descriptor.FieldDescriptorProto_TYPE_INT64, descriptor.FieldDescriptorProto_TYPE_SINT64,
+		descriptor.FieldDescriptorProto_TYPE_SFIXED64:
+		return "int64"
+	case descriptor.FieldDescriptorProto_TYPE_UINT64, descriptor.FieldDescriptorProto_TYPE_FIXED64:
+		return "uint64"
+	case descriptor.FieldDescriptorProto_TYPE_INT32, descriptor.FieldDescriptorProto_TYPE_SINT32,
+		descriptor.FieldDescriptorProto_TYPE_SFIXED32:
+		return "int32"
+	case descriptor.FieldDescriptorProto_TYPE_UINT32, descriptor.FieldDescriptorProto_TYPE_FIXED32:
+		return "uint32"
+	case descriptor.FieldDescriptorProto_TYPE_BOOL:
+		return "bool"
+	}
+	return "string"
+}
+
+type header struct {
+	Source  string
+	GoPkg   string
+	Imports map[string]string
+}
+
+type defaultsMessage struct {
+	Name   string
+	Fields []string
+	// Doc is the comment of the message, see protodoc.Index.Godoc.
+	Doc string
+}
+
+// typeIndex maps fully-qualified proto type names (".pkg.Msg") to their
+// descriptors and declaring files for every file in the request.
+type typeIndex struct {
+	messages map[string]*descriptor.DescriptorProto
+	enums    map[string]*descriptor.EnumDescriptorProto
+	files    map[string]*descriptor.FileDescriptorProto
+}
+
+func newTypeIndex(files []*descriptor.FileDescriptorProto) *typeIndex {
+	idx := &typeIndex{
+		messages: make(map[string]*descriptor.DescriptorProto),
+		enums:    make(map[string]*descriptor.EnumDescriptorProto),
+		files:    make(map[string]*descriptor.FileDescriptorProto),
+	}
+	for _, f := range files {
+		prefix := ""
+		if f.GetPackage() != "" {
+			prefix = "." + f.GetPackage()
+		}
+		idx.addMessages(f, prefix, f.GetMessageType())
+		idx.addEnums(f, prefix, f.GetEnumType())
+	}
+	return idx
+}
+
+func (idx *typeIndex) addMessages(f *descriptor.FileDescriptorProto, prefix string, msgs []*descriptor.DescriptorProto) {
+	for _, m := range msgs {
+		name := prefix + "." + m.GetName()
+		idx.messages[name] = m
+		idx.files[name] = f
+		idx.addMessages(f, name, m.GetNestedType())
+		idx.addEnums(f, name, m.GetEnumType())
+	}
+}
+
+func (idx *typeIndex) addEnums(f *descriptor.FileDescriptorProto, prefix string, enums []*descriptor.EnumDescriptorProto) {
+	for _, e := range enums {
+		name := prefix + "." + e.GetName()
+		idx.enums[name] = e
+		idx.files[name] = f
+	}
+}
+
+// isMap reports whether field is a map field.
+func (idx *typeIndex) isMap(field *descriptor.FieldDescriptorProto) bool {
+	if field.GetType() != descriptor.FieldDescriptorProto_TYPE_MESSAGE {
+		return false
+	}
+	return idx.messages[field.GetTypeName()].GetOptions().GetMapEntry()
+}
+
+// goImportPath returns the import path of the Go package generated from f,
+// or "" if go_package does not name one.
+func goImportPath(f *descriptor.FileDescriptorProto) string {
+	gopkg := f.GetOptions().GetGoPackage()
+	if sc := strings.IndexByte(gopkg, ';'); sc >= 0 {
+		gopkg = gopkg[:sc]
+	}
+	if !strings.Contains(gopkg, "/") {
+		return ""
+	}
+	return gopkg
+}
+
+// importSet records the Go packages referenced by the code generated for
+// one file, keyed by import path.
+type importSet struct {
+	self  string
+	names map[string]string
+}
+
+func newImportSet(f *descriptor.FileDescriptorProto) *importSet {
+	return &importSet{self: goImportPath(f), names: make(map[string]string)}
+}
+
+// goTypeName returns the Go name of the message or enum typeName, qualified
+// with its package if it is declared outside the generated package.
+func (s *importSet) goTypeName(idx *typeIndex, typeName string) string {
+	name := localTypeName(typeName)
+	f := idx.files[typeName]
+	path := goImportPath(f)
+	if f == nil || path == "" || path == s.self {
+		return name
+	}
+	pkg := defaultGoPackageName(f)
+	s.names[path] = pkg
+	return pkg + "." + name
+}
+
+// localTypeName returns the Go name of a type declared in the file being
+// generated, e.g. ".pkg.Outer.Inner" becomes "Outer_Inner".
+func localTypeName(typeName string) string {
+	parts := strings.Split(strings.TrimPrefix(typeName, "."), ".")
+	var names []string
+	for _, p := range parts {
+		if len(p) > 0 && p[0] >= 'A' && p[0] <= 'Z' {
+			names = append(names, p)
+		}
+	}
+	return strings.Join(names, "_")
+}
+
+// sanitizePackageName replaces unallowed character in package name
+// with allowed character.
+func sanitizePackageName(pkgName string) string {
+	pkgName = strings.Replace(pkgName, ".", "_", -1)
+	pkgName = strings.Replace(pkgName, "-", "_", -1)
+	return pkgName
+}
+
+// defaultGoPackageName returns the default go package name to be used for go files generated from "f".
+// You might need to use an unique alias for the package when you import it.  Use ReserveGoPackageAlias to get a unique alias.
+func defaultGoPackageName(f *descriptor.FileDescriptorProto) string {
+	name := packageIdentityName(f)
+	return sanitizePackageName(name)
+}
+
+// packageIdentityName returns the identity of packages.
+// protoc-gen-grpc-gateway rejects CodeGenerationRequests which contains more than one packages
+// as protoc-gen-go does.
+func packageIdentityName(f *descriptor.FileDescriptorProto) string {
+	if f.Options != nil && f.Options.GoPackage != nil {
+		gopkg := f.Options.GetGoPackage()
+		idx := strings.LastIndex(gopkg, "/")
+		if idx < 0 {
+			gopkg = gopkg[idx+1:]
+		}
+
+		gopkg = gopkg[idx+1:]
+		// package name is overrided with the string after the
+		// ';' character
+		sc := strings.IndexByte(gopkg, ';')
+		if sc < 0 {
+			return sanitizePackageName(gopkg)
+
+		}
+		return sanitizePackageName(gopkg[sc+1:])
+	}
+
+	if f.Package == nil {
+		base := filepath.Base(f.GetName())
+		ext := filepath.Ext(base)
+		return strings.TrimSuffix(base, ext)
+	}
+	return f.GetPackage()
+}
+
+func emitFiles(out []*plugin.CodeGeneratorResponse_File) {
+	emitResp(&plugin.CodeGeneratorResponse{File: out})
+}
+
+func emitError(err error) {
+	emitResp(&plugin.CodeGeneratorResponse{Error: proto.String(err.Error())})
+}
+
+func emitResp(resp *plugin.CodeGeneratorResponse) {
+	buf, err := proto.Marshal(resp)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if _, err := os.Stdout.Write(buf); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,391 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+)
+
+var (
+	funcs = template.FuncMap{"quote": strconv.Quote}
+
+	filesTmpl = template.Must(template.New("files").Funcs(funcs).Parse(`digraph files {
+    rankdir=LR;
+    node [shape=box, fontname="Helvetica"];
+{{- range $i, $p := .Packages}}
+    subgraph cluster_{{$i}} {
+        label={{quote $p.Label}};
+{{- if $p.Cycle}}
+        color=red;
+{{- end}}
+{{- range $p.Nodes}}
+        {{quote .Name}} [{{.Attrs}}];
+{{- end}}
+    }
+{{- end}}
+{{- range .FileEdges}}
+    {{quote .From}} -> {{quote .To}}{{if .Cycle}} [color=red]{{end}};
+{{- end}}
+}
+`))
+
+	packagesTmpl = template.Must(template.New("packages").Funcs(funcs).Parse(`digraph packages {
+    rankdir=LR;
+    node [shape=box, fontname="Helvetica"];
+{{- range .Packages}}
+    {{quote .Label}}{{if .Cycle}} [color=red, fontcolor=red]{{end}};
+{{- end}}
+{{- range .PackageEdges}}
+    {{quote .From}} -> {{quote .To}}{{if .Cycle}} [color=red]{{end}};
+{{- end}}
+}
+`))
+)
+
+func main() {
+	input, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	req := new(plugin.CodeGeneratorRequest)
+	if err = proto.Unmarshal(input, req); err != nil {
+		log.Fatal(err)
+	}
+
+	out, err := generate(req)
+
+	if err != nil {
+		emitError(err)
+		return
+	}
+
+	emitFiles(out)
+}
+
+// generate writes the dependency graph of the files to generate and the
+// files they import, directly or not: that of the files, clustered by
+// package, to <name>.dot, that of their packages to <name>.packages.dot,
+// and both to <name>.json. name is the parameter of that name, "deps" by
+// default, and the format parameter selects "dot", "json" or both, the
+// default, separated by "+".
+//
+// Packages importing each other, directly or not, and the imports between
+// them are drawn in red. Files importing more than the heavy parameter of
+// files, 20 by default, directly or not, are filled in orange.
+func generate(req *plugin.CodeGeneratorRequest) ([]*plugin.CodeGeneratorResponse_File, error) {
+	params := parseParams(req.GetParameter())
+	name := params["name"]
+	if name == "" {
+		name = "deps"
+	}
+	format := params["format"]
+	if format == "" {
+		format = "dot+json"
+	}
+	heavy := 20
+	if h, ok := params["heavy"]; ok {
+		n, err := strconv.Atoi(h)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid heavy parameter %q", h)
+		}
+		heavy = n
+	}
+	if len(req.FileToGenerate) == 0 {
+		return nil, nil
+	}
+	g := newGraph(req, heavy)
+
+	var files []*plugin.CodeGeneratorResponse_File
+	add := func(name string, content []byte) {
+		files = append(files, &plugin.CodeGeneratorResponse_File{
+			Name:    proto.String(name),
+			Content: proto.String(string(content)),
+		})
+	}
+	for _, f := range strings.Split(format, "+") {
+		switch f {
+		case "dot":
+			for _, t := range []struct {
+				tmpl *template.Template
+				ext  string
+			}{{filesTmpl, ".dot"}, {packagesTmpl, ".packages.dot"}} {
+				w := bytes.NewBuffer(nil)
+				if err := t.tmpl.Execute(w, g); err != nil {
+					return nil, err
+				}
+				add(name+t.ext, w.Bytes())
+			}
+		case "json":
+			b, err := json.MarshalIndent(g, "", "  ")
+			if err != nil {
+				return nil, err
+			}
+			add(name+".json", append(b, '\n'))
+		default:
+			return nil, fmt.Errorf("unknown format %q, want dot or json", f)
+		}
+	}
+	return files, nil
+}
+
+type graph struct {
+	Files    []*fileNode    `json:"files"`
+	Packages []*packageNode `json:"packages"`
+	// Cycles are the sets of packages importing each other, directly or
+	// not.
+	Cycles [][]string `json:"cycles"`
+
+	FileEdges    []*edge `json:"-"`
+	PackageEdges []*edge `json:"-"`
+}
+
+type fileNode struct {
+	Name    string `json:"name"`
+	Package string `json:"package"`
+	// Generated reports whether the file is one to generate rather than an
+	// import.
+	Generated bool     `json:"generated"`
+	Imports   []string `json:"imports"`
+	// Transitive is the number of files imported, directly or not.
+	Transitive int  `json:"transitive"`
+	Heavy      bool `json:"heavy"`
+	// Attrs are the DOT attributes of the node.
+	Attrs string `json:"-"`
+}
+
+type packageNode struct {
+	Name    string   `json:"name"`
+	Files   []string `json:"files"`
+	Imports []string `json:"imports"`
+	// Cycle reports whether the package is in one of the cycles.
+	Cycle bool `json:"cycle"`
+
+	// Label names the package in the graphs.
+	Label string      `json:"-"`
+	Nodes []*fileNode `json:"-"`
+}
+
+type edge struct {
+	From, To string
+	// Cycle reports whether the edge is part of a cycle of packages.
+	Cycle bool
+}
+
+// newGraph returns the graph of the files of req, in its order: that of
+// the imports before the files importing them.
+func newGraph(req *plugin.CodeGeneratorRequest, heavy int) *graph {
+	g := &graph{Cycles: [][]string{}}
+	genFileNames := make(map[string]bool)
+	for _, n := range req.FileToGenerate {
+		genFileNames[n] = true
+	}
+	files := make(map[string]*descriptor.FileDescriptorProto)
+	packages := make(map[string]*packageNode)
+	for _, desc := range req.GetProtoFile() {
+		files[desc.GetName()] = desc
+		p := packages[desc.GetPackage()]
+		if p == nil {
+			p = &packageNode{Name: desc.GetPackage(), Label: desc.GetPackage(), Imports: []string{}}
+			if p.Label == "" {
+				p.Label = "(no package)"
+			}
+			packages[desc.GetPackage()] = p
+			g.Packages = append(g.Packages, p)
+		}
+		p.Files = append(p.Files, desc.GetName())
+	}
+
+	// The package imports, and the packages in cycles.
+	pkgImports := make(map[string]map[string]bool)
+	for _, desc := range req.GetProtoFile() {
+		for _, dep := range desc.GetDependency() {
+			to, ok := files[dep]
+			if !ok || to.GetPackage() == desc.GetPackage() {
+				continue
+			}
+			if pkgImports[desc.GetPackage()] == nil {
+				pkgImports[desc.GetPackage()] = make(map[string]bool)
+			}
+			pkgImports[desc.GetPackage()][to.GetPackage()] = true
+		}
+	}
+	cycle := make(map[string]int)
+	for i, scc := range stronglyConnected(g.Packages, pkgImports) {
+		if len(scc) < 2 {
+			continue
+		}
+		sort.Strings(scc)
+		g.Cycles = append(g.Cycles, scc)
+		for _, p := range scc {
+			cycle[p] = i + 1
+			packages[p].Cycle = true
+		}
+	}
+	inCycle := func(from, to string) bool {
+		return cycle[from] != 0 && cycle[from] == cycle[to]
+	}
+	for _, p := range g.Packages {
+		for _, q := range g.Packages {
+			if pkgImports[p.Name][q.Name] {
+				p.Imports = append(p.Imports, q.Name)
+				g.PackageEdges = append(g.PackageEdges, &edge{From: p.Label, To: q.Label, Cycle: inCycle(p.Name, q.Name)})
+			}
+		}
+	}
+
+	transitive := make(map[string]map[string]bool)
+	var imported func(name string) map[string]bool
+	imported = func(name string) map[string]bool {
+		if deps, ok := transitive[name]; ok {
+			return deps
+		}
+		deps := make(map[string]bool)
+		// protoc rejects import cycles between files.
+		transitive[name] = deps
+		for _, dep := range files[name].GetDependency() {
+			if _, ok := files[dep]; !ok {
+				continue
+			}
+			deps[dep] = true
+			for d := range imported(dep) {
+				deps[d] = true
+			}
+		}
+		return deps
+	}
+	for _, desc := range req.GetProtoFile() {
+		n := &fileNode{
+			Name:       desc.GetName(),
+			Package:    desc.GetPackage(),
+			Generated:  genFileNames[desc.GetName()],
+			Imports:    []string{},
+			Transitive: len(imported(desc.GetName())),
+		}
+		n.Heavy = n.Transitive > heavy
+		for _, dep := range desc.GetDependency() {
+			to, ok := files[dep]
+			if !ok {
+				continue
+			}
+			n.Imports = append(n.Imports, dep)
+			g.FileEdges = append(g.FileEdges, &edge{From: n.Name, To: dep, Cycle: inCycle(desc.GetPackage(), to.GetPackage())})
+		}
+		label := fmt.Sprintf("%s\n%d imports", n.Name, n.Transitive)
+		if n.Transitive == 1 {
+			label = n.Name + "\n1 import"
+		}
+		n.Attrs = "label=" + strconv.Quote(label)
+		if n.Generated {
+			n.Attrs += ", penwidth=2"
+		}
+		if n.Heavy {
+			n.Attrs += ", style=filled, fillcolor=orange"
+		}
+		g.Files = append(g.Files, n)
+		p := packages[desc.GetPackage()]
+		p.Nodes = append(p.Nodes, n)
+	}
+	return g
+}
+
+// stronglyConnected returns the strongly connected components of the
+// packages, as Tarjan's algorithm finds them, given the packages each
+// imports.
+func stronglyConnected(pkgs []*packageNode, imports map[string]map[string]bool) [][]string {
+	var (
+		sccs    [][]string
+		stack   []string
+		index   = make(map[string]int)
+		low     = make(map[string]int)
+		onStack = make(map[string]bool)
+		next    = 1
+	)
+	var visit func(p string)
+	visit = func(p string) {
+		index[p], low[p] = next, next
+		next++
+		stack = append(stack, p)
+		onStack[p] = true
+		// In the order of the packages, for stable output.
+		for _, q := range pkgs {
+			if !imports[p][q.Name] {
+				continue
+			}
+			switch {
+			case index[q.Name] == 0:
+				visit(q.Name)
+				if low[q.Name] < low[p] {
+					low[p] = low[q.Name]
+				}
+			case onStack[q.Name] && index[q.Name] < low[p]:
+				low[p] = index[q.Name]
+			}
+		}
+		if low[p] != index[p] {
+			return
+		}
+		var scc []string
+		for {
+			q := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			onStack[q] = false
+			scc = append(scc, q)
+			if q == p {
+				break
+			}
+		}
+		sccs = append(sccs, scc)
+	}
+	for _, p := range pkgs {
+		if index[p.Name] == 0 {
+			visit(p.Name)
+		}
+	}
+	return sccs
+}
+
+// parseParams splits the comma separated key=value plugin parameter.
+func parseParams(param string) map[string]string {
+	params := make(map[string]string)
+	for _, p := range strings.Split(param, ",") {
+		if p == "" {
+			continue
+		}
+		if i := strings.IndexByte(p, '='); i >= 0 {
+			params[p[:i]] = p[i+1:]
+		} else {
+			params[p] = ""
+		}
+	}
+	return params
+}
+
+func emitFiles(out []*plugin.CodeGeneratorResponse_File) {
+	emitResp(&plugin.CodeGeneratorResponse{File: out})
+}
+
+func emitError(err error) {
+	emitResp(&plugin.CodeGeneratorResponse{Error: proto.String(err.Error())})
+}
+
+func emitResp(resp *plugin.CodeGeneratorResponse) {
+	buf, err := proto.Marshal(resp)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if _, err := os.Stdout.Write(buf); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,492 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+
+	"github.com/f4tq/protoc-go-plugins/goname"
+)
+
+var (
+	hdrTmpl = template.Must(template.New("header").Parse(`
+// Code generated by protoc-gen-go-diff. DO NOT EDIT.
+// source: {{.Source}}
+
+package {{.GoPkg}}
+
+import (
+{{- range .Std}}
+    "{{.}}"
+{{- end}}
+{{if .Runtime}}
+    "github.com/f4tq/protoc-go-plugins/runtime/equal"
+{{- end}}
+{{- range $path, $name := .Imports}}
+    {{$name}} "{{$path}}"
+{{- end}}
+)
+`))
+
+	diffTmpl = template.Must(template.New("diff").Parse(`
+// Diff returns the paths of the fields whose values differ between m and
+// other, descending into message fields set on both sides. A nil message
+// counts as empty; unknown fields are ignored.
+func (m *{{.Name}}) Diff(other *{{.Name}}) *field_mask.FieldMask {
+    return &field_mask.FieldMask{Paths: m.DiffPaths("", other)}
+}
+
+// DiffPaths returns the paths of Diff, each prefixed with prefix.
+func (m *{{.Name}}) DiffPaths(prefix string, other *{{.Name}}) []string {
+    if m == nil {
+        m = new({{.Name}})
+    }
+    if other == nil {
+        other = new({{.Name}})
+    }
+    var paths []string
+{{- range .Checks}}
+{{.}}
+{{- end}}
+    return paths
+}
+`))
+)
+
+// fieldMaskPath is the import path of the Go FieldMask type.
+const fieldMaskPath = "google.golang.org/genproto/protobuf/field_mask"
+
+func main() {
+	input, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	req := new(plugin.CodeGeneratorRequest)
+	if err = proto.Unmarshal(input, req); err != nil {
+		log.Fatal(err)
+	}
+
+	out, err := generate(req)
+
+	if err != nil {
+		emitError(err)
+		return
+	}
+
+	emitFiles(out)
+}
+
+func generate(req *plugin.CodeGeneratorRequest) ([]*plugin.CodeGeneratorResponse_File, error) {
+	var files []*plugin.CodeGeneratorResponse_File
+	idx := newTypeIndex(req.GetProtoFile())
+	genFileNames := make(map[string]bool)
+	for _, n := range req.FileToGenerate {
+		genFileNames[n] = true
+	}
+	for _, desc := range req.GetProtoFile() {
+		name := desc.GetName()
+		if _, ok := genFileNames[name]; !ok {
+			// Only emit output for files present in req.FileToGenerate.
+			continue
+		}
+		code, err := genCode(desc, idx, genFileNames)
+		if err != nil {
+			return nil, err
+		}
+		if code == "" {
+			// The file declares no messages.
+			continue
+		}
+		formatted, err := format.Source([]byte(code))
+		if err != nil {
+			log.Printf("%v: %s", err, code)
+			return nil, err
+		}
+
+		ext := filepath.Ext(name)
+		base := strings.TrimSuffix(name, ext)
+		output := fmt.Sprintf("%s.pb.diff.go", base)
+		files = append(files, &plugin.CodeGeneratorResponse_File{
+			Name:    proto.String(output),
+			Content: proto.String(string(formatted)),
+		})
+	}
+
+	return files, nil
+}
+
+func genCode(desc *descriptor.FileDescriptorProto, idx *typeIndex, genFileNames map[string]bool) (string, error) {
+	w := bytes.NewBuffer(nil)
+	g := &diffGen{
+		idx:     idx,
+		imports: newImportSet(desc),
+		gen:     genFileNames,
+		proto3:  desc.GetSyntax() == "proto3",
+		std:     make(map[string]bool),
+	}
+	g.imports.names[fieldMaskPath] = "field_mask"
+	body := bytes.NewBuffer(nil)
+	if err := g.messages(body, "", desc.GetMessageType()); err != nil {
+		return "", err
+	}
+	if body.Len() == 0 {
+		return "", nil
+	}
+
+	hdr := &header{
+		Source:  desc.GetName(),
+		GoPkg:   defaultGoPackageName(desc),
+		Runtime: g.runtime,
+		Imports: g.imports.names,
+	}
+	for path := range g.std {
+		hdr.Std = append(hdr.Std, path)
+	}
+	sort.Strings(hdr.Std)
+	if err := hdrTmpl.Execute(w, hdr); err != nil {
+		log.Fatal(err)
+	}
+	body.WriteTo(w)
+
+	return w.String(), nil
+}
+
+type diffGen struct {
+	idx     *typeIndex
+	imports *importSet
+	gen     map[string]bool
+	proto3  bool
+	// std and runtime record the packages the comparisons use.
+	std     map[string]bool
+	runtime bool
+}
+
+// messages writes the Diff methods of msgs and of the messages nested in
+// them. prefix is the Go name of the enclosing message plus "_".
+func (g *diffGen) messages(w *bytes.Buffer, prefix string, msgs []*descriptor.DescriptorProto) error {
+	for _, msg := range msgs {
+		if msg.GetOptions().GetMapEntry() {
+			continue
+		}
+		name := prefix + msg.GetName()
+		m := &diffMessage{Name: name}
+		goNames := goname.Fields(msg)
+		done := make(map[int32]bool)
+		for _, field := range msg.GetField() {
+			if field.OneofIndex != nil && !field.GetProto3Optional() {
+				i := field.GetOneofIndex()
+				if !done[i] {
+					done[i] = true
+					m.Checks = append(m.Checks, g.oneof(name, msg, i))
+				}
+				continue
+			}
+			goName := goNames[field.GetName()]
+			if g.descends(field) {
+				m.Checks = append(m.Checks, g.nested(field, "m."+goName, "other."+goName))
+				continue
+			}
+			m.Checks = append(m.Checks, fmt.Sprintf("if %s {\n%s\n}", g.differs(field, goName), appendPath(field)))
+		}
+		if err := diffTmpl.Execute(w, m); err != nil {
+			return err
+		}
+		if err := g.messages(w, name+"_", msg.GetNestedType()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// appendPath returns the statement adding the path of field to paths.
+func appendPath(field *descriptor.FieldDescriptorProto) string {
+	return fmt.Sprintf("paths = append(paths, prefix+%q)", field.GetName())
+}
+
+// descends reports whether Diff descends into the singular message field,
+// which it does for the messages that get a DiffPaths method.
+func (g *diffGen) descends(field *descriptor.FieldDescriptorProto) bool {
+	return field.GetLabel() != descriptor.FieldDescriptorProto_LABEL_REPEATED &&
+		field.GetType() == descriptor.FieldDescriptorProto_TYPE_MESSAGE &&
+		g.gen[g.idx.files[field.GetTypeName()].GetName()]
+}
+
+// nested returns the statements diffing the message values a and b of
+// field: the field itself differs when only one is set.
+func (g *diffGen) nested(field *descriptor.FieldDescriptorProto, a, b string) string {
+	return fmt.Sprintf("if %s == nil || %s == nil {\nif %s != %s {\n%s\n}\n} else {\npaths = append(paths, %s.DiffPaths(prefix+%q, %s)...)\n}",
+		a, b, a, b, appendPath(field), a, field.GetName()+".", b)
+}
+
+// differs returns a condition holding when field, whose Go name is goName,
+// differs between m and other.
+func (g *diffGen) differs(field *descriptor.FieldDescriptorProto, goName string) string {
+	a, b := "m."+goName, "other."+goName
+	switch {
+	case g.idx.isMap(field):
+		value := g.idx.messages[field.GetTypeName()].GetField()[1]
+		g.std["maps"] = true
+		if eq := g.equalFunc(value); eq != "" {
+			return fmt.Sprintf("!maps.EqualFunc(%s, %s, %s)", a, b, eq)
+		}
+		return fmt.Sprintf("!maps.Equal(%s, %s)", a, b)
+	case field.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REPEATED:
+		g.std["slices"] = true
+		if eq := g.equalFunc(field); eq != "" {
+			return fmt.Sprintf("!slices.EqualFunc(%s, %s, %s)", a, b, eq)
+		}
+		return fmt.Sprintf("!slices.Equal(%s, %s)", a, b)
+	case field.GetType() == descriptor.FieldDescriptorProto_TYPE_MESSAGE,
+		field.GetType() == descriptor.FieldDescriptorProto_TYPE_BYTES:
+		return g.notEqual(field, a, b)
+	case field.GetProto3Optional() || !g.proto3:
+		// Scalars are pointers; compare presence, then values.
+		getA, getB := "m.Get"+goName+"()", "other.Get"+goName+"()"
+		return fmt.Sprintf("(%s == nil) != (%s == nil) || %s", a, b, g.notEqual(field, getA, getB))
+	}
+	return g.notEqual(field, a, b)
+}
+
+// notEqual returns an expression reporting whether the values a and b of
+// field differ.
+func (g *diffGen) notEqual(field *descriptor.FieldDescriptorProto, a, b string) string {
+	if eq := g.equalFunc(field); eq != "" {
+		return fmt.Sprintf("!%s(%s, %s)", eq, a, b)
+	}
+	return fmt.Sprintf("%s != %s", a, b)
+}
+
+// equalFunc returns the function comparing two values of field, or "" if
+// they compare with ==.
+func (g *diffGen) equalFunc(field *descriptor.FieldDescriptorProto) string {
+	switch field.GetType() {
+	case descriptor.FieldDescriptorProto_TYPE_DOUBLE:
+		g.runtime = true
+		return "equal.Float64"
+	case descriptor.FieldDescriptorProto_TYPE_FLOAT:
+		g.runtime = true
+		return "equal.Float32"
+	case descriptor.FieldDescriptorProto_TYPE_BYTES:
+		g.std["bytes"] = true
+		return "bytes.Equal"
+	case descriptor.FieldDescriptorProto_TYPE_MESSAGE, descriptor.FieldDescriptorProto_TYPE_GROUP:
+		g.runtime = true
+		return fmt.Sprintf("equal.Proto[*%s]", g.imports.goTypeName(g.idx, field.GetTypeName()))
+	}
+	return ""
+}
+
+// oneof returns the statements diffing the oneof i of msg. A member
+// differs when it is set on one side only or set to different values.
+func (g *diffGen) oneof(msgName string, msg *descriptor.DescriptorProto, i int32) string {
+	goNames := goname.Fields(msg)
+	oneof := goNames[msg.GetOneofDecl()[i].GetName()]
+	var b strings.Builder
+	for _, field := range msg.GetField() {
+		if field.OneofIndex == nil || field.GetOneofIndex() != i || field.GetProto3Optional() {
+			continue
+		}
+		goName := goNames[field.GetName()]
+		wrapper := msgName + "_" + goName
+		fmt.Fprintf(&b, "if a, ok := m.%s.(*%s); ok {\n", oneof, wrapper)
+		fmt.Fprintf(&b, "if b, ok := other.%s.(*%s); ok {\n", oneof, wrapper)
+		if g.descends(field) {
+			fmt.Fprintf(&b, "%s\n", g.nested(field, "a."+goName, "b."+goName))
+		} else {
+			fmt.Fprintf(&b, "if %s {\n%s\n}\n", g.notEqual(field, "a."+goName, "b."+goName), appendPath(field))
+		}
+		fmt.Fprintf(&b, "} else {\n%s\n}\n", appendPath(field))
+		fmt.Fprintf(&b, "} else if _, ok := other.%s.(*%s); ok {\n%s\n}\n", oneof, wrapper, appendPath(field))
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+type header struct {
+	Source  string
+	GoPkg   string
+	Std     []string
+	Runtime bool
+	Imports map[string]string
+}
+
+type diffMessage struct {
+	Name   string
+	Checks []string
+}
+
+// typeIndex maps fully-qualified proto type names (".pkg.Msg") to their
+// descriptors and declaring files for every file in the request.
+type typeIndex struct {
+	messages map[string]*descriptor.DescriptorProto
+	enums    map[string]*descriptor.EnumDescriptorProto
+	files    map[string]*descriptor.FileDescriptorProto
+}
+
+func newTypeIndex(files []*descriptor.FileDescriptorProto) *typeIndex {
+	idx := &typeIndex{
+		messages: make(map[string]*descriptor.DescriptorProto),
+		enums:    make(map[string]*descriptor.EnumDescriptorProto),
+		files:    make(map[string]*descriptor.FileDescriptorProto),
+	}
+	for _, f := range files {
+		prefix := ""
+		if f.GetPackage() != "" {
+			prefix = "." + f.GetPackage()
+		}
+		idx.addMessages(f, prefix, f.GetMessageType())
+		idx.addEnums(f, prefix, f.GetEnumType())
+	}
+	return idx
+}
+
+func (idx *typeIndex) addMessages(f *descriptor.FileDescriptorProto, prefix string, msgs []*descriptor.DescriptorProto) {
+	for _, m := range msgs {
+		name := prefix + "." + m.GetName()
+		idx.messages[name] = m
+		idx.files[name] = f
+		idx.addMessages(f, name, m.GetNestedType())
+		idx.addEnums(f, name, m.GetEnumType())
+	}
+}
+
+func (idx *typeIndex) addEnums(f *descriptor.FileDescriptorProto, prefix string, enums []*descriptor.EnumDescriptorProto) {
+	for _, e := range enums {
+		name := prefix + "." + e.GetName()
+		idx.enums[name] = e
+		idx.files[name] = f
+	}
+}
+
+// isMap reports whether field is a map field.
+func (idx *typeIndex) isMap(field *descriptor.FieldDescriptorProto) bool {
+	if field.GetType() != descriptor.FieldDescriptorProto_TYPE_MESSAGE {
+		return false
+	}
+	return idx.messages[field.GetTypeName()].GetOptions().GetMapEntry()
+}
+
+// goImportPath returns the import path of the Go package generated from f,
+// or "" if go_package does not name one.
+func goImportPath(f *descriptor.FileDescriptorProto) string {
+	gopkg := f.GetOptions().GetGoPackage()
+	if sc := strings.IndexByte(gopkg, ';'); sc >= 0 {
+		gopkg = gopkg[:sc]
+	}
+	if !strings.Contains(gopkg, "/") {
+		return ""
+	}
+	return gopkg
+}
+
+// importSet records the Go packages referenced by the code generated for
+// one file, keyed by import path.
+type importSet struct {
+	self  string
+	names map[string]string
+}
+
+func newImportSet(f *descriptor.FileDescriptorProto) *importSet {
+	return &importSet{self: goImportPath(f), names: make(map[string]string)}
+}
+
+// goTypeName returns the Go name of the message or enum typeName, qualified
+// with its package if it is declared outside the generated package.
+func (s *importSet) goTypeName(idx *typeIndex, typeName string) string {
+	name := localTypeName(typeName)
+	f := idx.files[typeName]
+	path := goImportPath(f)
+	if f == nil || path == "" || path == s.self {
+		return name
+	}
+	pkg := defaultGoPackageName(f)
+	s.names[path] = pkg
+	return pkg + "." + name
+}
+
+// localTypeName returns the Go name of a type declared in the file being
+// generated, e.g. ".pkg.Outer.Inner" becomes "Outer_Inner".
+func localTypeName(typeName string) string {
+	parts := strings.Split(strings.TrimPrefix(typeName, "."), ".")
+	var names []string
+	for _, p := range parts {
+		if len(p) > 0 && p[0] >= 'A' && p[0] <= 'Z' {
+			names = append(names, p)
+		}
+	}
+	return strings.Join(names, "_")
+}
+
+// sanitizePackageName replaces unallowed character in package name
+// with allowed character.
+func sanitizePackageName(pkgName string) string {
+	pkgName = strings.Replace(pkgName, ".", "_", -1)
+	pkgName = strings.Replace(pkgName, "-", "_", -1)
+	return pkgName
+}
+
+// defaultGoPackageName returns the default go package name to be used for go files generated from "f".
+// You might need to use an unique alias for the package when you import it.  Use ReserveGoPackageAlias to get a unique alias.
+func defaultGoPackageName(f *descriptor.FileDescriptorProto) string {
+	name := packageIdentityName(f)
+	return sanitizePackageName(name)
+}
+
+// packageIdentityName returns the identity of packages.
+// protoc-gen-grpc-gateway rejects CodeGenerationRequests which contains more than one packages
+// as protoc-gen-go does.
+func packageIdentityName(f *descriptor.FileDescriptorProto) string {
+	if f.Options != nil && f.Options.GoPackage != nil {
+		gopkg := f.Options.GetGoPackage()
+		idx := strings.LastIndex(gopkg, "/")
+		if idx < 0 {
+			gopkg = gopkg[idx+1:]
+		}
+
+		gopkg = gopkg[idx+1:]
+		// package name is overrided with the string after the
+		// ';' character
+		sc := strings.IndexByte(gopkg, ';')
+		if sc < 0 {
+			return sanitizePackageName(gopkg)
+
+		}
+		return sanitizePackageName(gopkg[sc+1:])
+	}
+
+	if f.Package == nil {
+		base := filepath.Base(f.GetName())
+		ext := filepath.Ext(base)
+		return strings.TrimSuffix(base, ext)
+	}
+	return f.GetPackage()
+}
+
+func emitFiles(out []*plugin.CodeGeneratorResponse_File) {
+	emitResp(&plugin.CodeGeneratorResponse{File: out})
+}
+
+func emitError(err error) {
+	emitResp(&plugin.CodeGeneratorResponse{Error: proto.String(err.Error())})
+}
+
+func emitResp(resp *plugin.CodeGeneratorResponse) {
+	buf, err := proto.Marshal(resp)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if _, err := os.Stdout.Write(buf); err != nil {
+		log.Fatal(err)
+	}
+}
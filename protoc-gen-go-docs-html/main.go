@@ -0,0 +1,649 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+
+	"github.com/f4tq/protoc-go-plugins/httprule"
+)
+
+// wktURL is the reference of the well-known types, to which their anchors,
+// the lower-case type names, are appended.
+const wktURL = "https://protobuf.dev/reference/protobuf/google.protobuf/#"
+
+var pageTmpl = template.Must(template.New("page").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+<style>
+body { margin: 0; font: 14px/1.5 -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; color: #1f2328; display: flex; }
+nav { position: sticky; top: 0; height: 100vh; overflow-y: auto; width: 18rem; flex: none; padding: 1rem; box-sizing: border-box; border-right: 1px solid #d0d7de; background: #f6f8fa; }
+nav input { width: 100%; box-sizing: border-box; padding: .4rem; margin-bottom: .75rem; }
+nav ul { list-style: none; margin: 0; padding-left: .75rem; }
+nav > ul { padding-left: 0; }
+nav a { color: inherit; text-decoration: none; }
+nav a:hover { text-decoration: underline; }
+main { padding: 1rem 2rem 4rem; min-width: 0; flex: 1; }
+section.type { border-top: 1px solid #d0d7de; margin-top: 1.5rem; }
+table { border-collapse: collapse; margin: .5rem 0; }
+th, td { border: 1px solid #d0d7de; padding: .25rem .6rem; text-align: left; vertical-align: top; }
+th { background: #f6f8fa; }
+code { font: 12px SFMono-Regular, Consolas, monospace; }
+.comment { white-space: pre-line; }
+.kind { color: #57606a; font-weight: normal; font-size: .8em; }
+.badge { display: inline-block; padding: 0 .4rem; border-radius: 1em; font-size: .75em; font-weight: normal; background: #ffebe9; color: #cf222e; border: 1px solid #ff8182; }
+.deprecated > code, .deprecated > a { text-decoration: line-through; }
+.usedby { color: #57606a; }
+</style>
+</head>
+<body>
+<nav>
+<input id="search" type="search" placeholder="Search" aria-label="Search">
+<ul>
+{{- range .Files}}
+<li data-search="{{.Name}}"><a href="#{{.Anchor}}"><strong>{{.Name}}</strong></a>
+<ul>
+{{- range .Types}}
+<li data-search="{{.FullName}}"{{if .Deprecated}} class="deprecated"{{end}}><a href="#{{.FullName}}"><code>{{.Name}}</code></a></li>
+{{- end}}
+{{- range .Services}}
+<li data-search="{{.FullName}}"{{if .Deprecated}} class="deprecated"{{end}}><a href="#{{.FullName}}"><code>{{.Name}}</code></a></li>
+{{- end}}
+</ul>
+</li>
+{{- end}}
+</ul>
+</nav>
+<main>
+<h1>{{.Title}}</h1>
+{{- range .Files}}
+<section class="file" id="{{.Anchor}}">
+<h2>{{.Name}}{{if .Deprecated}} <span class="badge">deprecated</span>{{end}}</h2>
+{{- if .Package}}
+<p>Package <code>{{.Package}}</code></p>
+{{- end}}
+{{- if .Comment}}
+<p class="comment">{{.Comment}}</p>
+{{- end}}
+{{- range .Types}}
+<section class="type" id="{{.FullName}}" data-search="{{.FullName}}">
+<h3><code>{{.Name}}</code> <span class="kind">{{.Kind}}</span>{{if .Deprecated}} <span class="badge">deprecated</span>{{end}}</h3>
+{{- if .Comment}}
+<p class="comment">{{.Comment}}</p>
+{{- end}}
+{{- if .Fields}}
+<table>
+<tr><th>Field</th><th>Type</th><th>Label</th><th>Description</th></tr>
+{{- range .Fields}}
+<tr{{if .Deprecated}} class="deprecated"{{end}}><td><code>{{.Name}}</code>{{if .Deprecated}} <span class="badge">deprecated</span>{{end}}</td><td>{{template "ref" .Type}}</td><td>{{.Label}}</td><td class="comment">{{.Comment}}</td></tr>
+{{- end}}
+</table>
+{{- end}}
+{{- if .Values}}
+<table>
+<tr><th>Name</th><th>Number</th><th>Description</th></tr>
+{{- range .Values}}
+<tr><td><code>{{.Name}}</code>{{if .Deprecated}} <span class="badge">deprecated</span>{{end}}</td><td>{{.Number}}</td><td class="comment">{{.Comment}}</td></tr>
+{{- end}}
+</table>
+{{- end}}
+{{- if .UsedBy}}
+<p class="usedby">Used by {{range $i, $r := .UsedBy}}{{if $i}}, {{end}}{{template "ref" $r}}{{end}}</p>
+{{- end}}
+</section>
+{{- end}}
+{{- range .Services}}
+<section class="type" id="{{.FullName}}" data-search="{{.FullName}}">
+<h3><code>{{.Name}}</code> <span class="kind">service</span>{{if .Deprecated}} <span class="badge">deprecated</span>{{end}}</h3>
+{{- if .Comment}}
+<p class="comment">{{.Comment}}</p>
+{{- end}}
+<table>
+<tr><th>Method</th><th>Request</th><th>Response</th><th>HTTP</th><th>Description</th></tr>
+{{- range .Methods}}
+<tr id="{{.FullName}}"><td><code>{{.Name}}</code>{{if .Deprecated}} <span class="badge">deprecated</span>{{end}}</td><td>{{template "ref" .Request}}</td><td>{{template "ref" .Response}}</td><td>{{range $i, $h := .HTTP}}{{if $i}}<br>{{end}}<code>{{$h}}</code>{{end}}</td><td class="comment">{{.Comment}}</td></tr>
+{{- end}}
+</table>
+</section>
+{{- end}}
+</section>
+{{- end}}
+</main>
+<script>
+(function () {
+  var input = document.getElementById("search");
+  input.addEventListener("input", function () {
+    var q = input.value.trim().toLowerCase();
+    document.querySelectorAll("nav li li, main section.type").forEach(function (el) {
+      el.hidden = q !== "" && el.dataset.search.toLowerCase().indexOf(q) < 0;
+    });
+  });
+})();
+</script>
+</body>
+</html>
+{{define "ref"}}{{range .}}{{if .Href}}<a href="{{.Href}}"><code>{{.Text}}</code></a>{{else if .Code}}<code>{{.Text}}</code>{{else}}{{.Text}}{{end}}{{end}}{{end}}
+`))
+
+func main() {
+	input, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	req := new(plugin.CodeGeneratorRequest)
+	if err = proto.Unmarshal(input, req); err != nil {
+		log.Fatal(err)
+	}
+
+	out, err := generate(req)
+
+	if err != nil {
+		emitError(err)
+		return
+	}
+
+	emitFiles(out)
+}
+
+// generate writes a single self-contained HTML page documenting every file
+// to generate, named by the name parameter, "index.html" by default, and
+// titled by the title parameter. Types link to their declarations on the
+// page and to the reference of the well-known types, and list the fields
+// and methods using them.
+func generate(req *plugin.CodeGeneratorRequest) ([]*plugin.CodeGeneratorResponse_File, error) {
+	params := parseParams(req.GetParameter())
+	p := &page{Title: params["title"]}
+	if p.Title == "" {
+		p.Title = "API Reference"
+	}
+	output := params["name"]
+	if output == "" {
+		output = "index.html"
+	}
+	g := &pageGen{
+		idx:    newTypeIndex(req.GetProtoFile()),
+		pageOf: make(map[string]bool),
+		usedBy: make(map[string][][]*ref),
+	}
+	var descs []*descriptor.FileDescriptorProto
+	genFileNames := make(map[string]bool)
+	for _, n := range req.FileToGenerate {
+		genFileNames[n] = true
+	}
+	for _, desc := range req.GetProtoFile() {
+		if _, ok := genFileNames[desc.GetName()]; !ok {
+			// Only document the files present in req.FileToGenerate.
+			continue
+		}
+		descs = append(descs, desc)
+		for typeName, f := range g.idx.files {
+			if f == desc {
+				g.pageOf[typeName] = true
+			}
+		}
+	}
+	if len(descs) == 0 {
+		return nil, nil
+	}
+	for _, desc := range descs {
+		f, err := g.file(desc)
+		if err != nil {
+			return nil, err
+		}
+		p.Files = append(p.Files, f)
+	}
+	for _, f := range p.Files {
+		for _, t := range f.Types {
+			t.UsedBy = g.usedBy["."+t.FullName]
+		}
+	}
+
+	w := bytes.NewBuffer(nil)
+	if err := pageTmpl.Execute(w, p); err != nil {
+		return nil, err
+	}
+	return []*plugin.CodeGeneratorResponse_File{{
+		Name:    proto.String(output),
+		Content: proto.String(w.String()),
+	}}, nil
+}
+
+type pageGen struct {
+	idx *typeIndex
+	// pageOf holds the types documented on the page.
+	pageOf map[string]bool
+	// usedBy maps the types to the references of the fields and methods
+	// using them.
+	usedBy map[string][][]*ref
+	// comments maps the source paths of the declarations of the current
+	// file to their comments.
+	comments map[string]string
+}
+
+func (g *pageGen) file(desc *descriptor.FileDescriptorProto) (*pageFile, error) {
+	g.comments = make(map[string]string)
+	for _, loc := range desc.GetSourceCodeInfo().GetLocation() {
+		c := loc.GetLeadingComments()
+		if c == "" {
+			c = loc.GetTrailingComments()
+		}
+		if c != "" {
+			g.comments[fmt.Sprint(loc.GetPath())] = c
+		}
+	}
+	f := &pageFile{
+		Name:       desc.GetName(),
+		Anchor:     "file-" + desc.GetName(),
+		Package:    desc.GetPackage(),
+		Comment:    g.comment([]int32{2}),
+		Deprecated: desc.GetOptions().GetDeprecated(),
+	}
+	scope := strings.TrimSuffix("."+desc.GetPackage(), ".")
+	g.messages(f, desc, scope, []int32{4}, desc.GetMessageType())
+	g.enums(f, scope, []int32{5}, desc.GetEnumType())
+	for i, svc := range desc.GetService() {
+		s, err := g.service(scope, []int32{6, int32(i)}, svc)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", desc.GetName(), err)
+		}
+		f.Services = append(f.Services, s)
+	}
+	return f, nil
+}
+
+// messages adds msgs, and the messages and enums nested in them, to f.
+// scope is the full proto name of their parent and path the source path
+// of msgs.
+func (g *pageGen) messages(f *pageFile, desc *descriptor.FileDescriptorProto, scope string, path []int32, msgs []*descriptor.DescriptorProto) {
+	for i, msg := range msgs {
+		if msg.GetOptions().GetMapEntry() {
+			continue
+		}
+		typeName := scope + "." + msg.GetName()
+		msgPath := append(append([]int32(nil), path...), int32(i))
+		t := &pageType{
+			Name:       g.displayName(typeName),
+			FullName:   strings.TrimPrefix(typeName, "."),
+			Kind:       "message",
+			Comment:    g.comment(msgPath),
+			Deprecated: msg.GetOptions().GetDeprecated(),
+		}
+		for j, field := range msg.GetField() {
+			t.Fields = append(t.Fields, &pageField{
+				Name:       field.GetName(),
+				Type:       g.fieldType(field),
+				Label:      label(g.idx, desc, msg, field),
+				Comment:    g.comment(append(append([]int32(nil), msgPath...), 2, int32(j))),
+				Deprecated: field.GetOptions().GetDeprecated(),
+			})
+			g.use(g.fieldTypeName(field), []*ref{{Text: t.Name + "." + field.GetName(), Href: "#" + t.FullName, Code: true}})
+		}
+		f.Types = append(f.Types, t)
+		g.messages(f, desc, typeName, append(msgPath, 3), msg.GetNestedType())
+		g.enums(f, typeName, append(msgPath, 4), msg.GetEnumType())
+	}
+}
+
+// enums adds enums to f, as messages does.
+func (g *pageGen) enums(f *pageFile, scope string, path []int32, enums []*descriptor.EnumDescriptorProto) {
+	for i, enum := range enums {
+		typeName := scope + "." + enum.GetName()
+		enumPath := append(append([]int32(nil), path...), int32(i))
+		t := &pageType{
+			Name:       g.displayName(typeName),
+			FullName:   strings.TrimPrefix(typeName, "."),
+			Kind:       "enum",
+			Comment:    g.comment(enumPath),
+			Deprecated: enum.GetOptions().GetDeprecated(),
+		}
+		for j, v := range enum.GetValue() {
+			t.Values = append(t.Values, &pageValue{
+				Name:       v.GetName(),
+				Number:     v.GetNumber(),
+				Comment:    g.comment(append(append([]int32(nil), enumPath...), 2, int32(j))),
+				Deprecated: v.GetOptions().GetDeprecated(),
+			})
+		}
+		f.Types = append(f.Types, t)
+	}
+}
+
+func (g *pageGen) service(scope string, path []int32, svc *descriptor.ServiceDescriptorProto) (*pageService, error) {
+	s := &pageService{
+		Name:       svc.GetName(),
+		FullName:   strings.TrimPrefix(scope+"."+svc.GetName(), "."),
+		Comment:    g.comment(path),
+		Deprecated: svc.GetOptions().GetDeprecated(),
+	}
+	for j, m := range svc.GetMethod() {
+		pm := &pageMethod{
+			Name:       m.GetName(),
+			FullName:   s.FullName + "." + m.GetName(),
+			Request:    g.typeRef(m.GetInputType()),
+			Response:   g.typeRef(m.GetOutputType()),
+			Comment:    g.comment(append(append([]int32(nil), path...), 2, int32(j))),
+			Deprecated: m.GetOptions().GetDeprecated(),
+		}
+		if m.GetClientStreaming() {
+			pm.Request = append([]*ref{{Text: "stream "}}, pm.Request...)
+		}
+		if m.GetServerStreaming() {
+			pm.Response = append([]*ref{{Text: "stream "}}, pm.Response...)
+		}
+		bindings, err := httprule.Bindings(m)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", svc.GetName(), err)
+		}
+		for _, b := range bindings {
+			pm.HTTP = append(pm.HTTP, b.Method+" "+b.Template)
+		}
+		method := []*ref{{Text: s.Name + "." + m.GetName(), Href: "#" + pm.FullName, Code: true}}
+		g.use(m.GetInputType(), method)
+		if m.GetOutputType() != m.GetInputType() {
+			g.use(m.GetOutputType(), method)
+		}
+		s.Methods = append(s.Methods, pm)
+	}
+	return s, nil
+}
+
+// use records that the field or method r uses typeName, unless it is
+// already recorded.
+func (g *pageGen) use(typeName string, r []*ref) {
+	if typeName == "" {
+		return
+	}
+	for _, u := range g.usedBy[typeName] {
+		if u[0].Href == r[0].Href && u[0].Text == r[0].Text {
+			return
+		}
+	}
+	g.usedBy[typeName] = append(g.usedBy[typeName], r)
+}
+
+// fieldTypeName returns the message or enum field refers to, through map
+// values, or "".
+func (g *pageGen) fieldTypeName(field *descriptor.FieldDescriptorProto) string {
+	if g.idx.isMap(field) {
+		return g.fieldTypeName(g.idx.messages[field.GetTypeName()].GetField()[1])
+	}
+	return field.GetTypeName()
+}
+
+// displayName returns the name of typeName relative to its package, such
+// as User.Address.
+func (g *pageGen) displayName(typeName string) string {
+	pkg := g.idx.files[typeName].GetPackage()
+	if pkg == "" {
+		return strings.TrimPrefix(typeName, ".")
+	}
+	return strings.TrimPrefix(typeName, "."+pkg+".")
+}
+
+// fieldType returns the type of field.
+func (g *pageGen) fieldType(field *descriptor.FieldDescriptorProto) []*ref {
+	if g.idx.isMap(field) {
+		entry := g.idx.messages[field.GetTypeName()]
+		refs := []*ref{{Text: "map<"}}
+		refs = append(refs, g.fieldType(entry.GetField()[0])...)
+		refs = append(refs, &ref{Text: ", "})
+		refs = append(refs, g.fieldType(entry.GetField()[1])...)
+		return append(refs, &ref{Text: ">"})
+	}
+	switch field.GetType() {
+	case descriptor.FieldDescriptorProto_TYPE_MESSAGE, descriptor.FieldDescriptorProto_TYPE_GROUP,
+		descriptor.FieldDescriptorProto_TYPE_ENUM:
+		return g.typeRef(field.GetTypeName())
+	}
+	return []*ref{{Text: strings.ToLower(strings.TrimPrefix(field.GetType().String(), "TYPE_")), Code: true}}
+}
+
+// typeRef returns the reference of the message or enum typeName: to its
+// declaration on the page, or to the reference of the well-known types.
+// Other types are not linked.
+func (g *pageGen) typeRef(typeName string) []*ref {
+	name := strings.TrimPrefix(typeName, ".")
+	switch {
+	case g.pageOf[typeName]:
+		return []*ref{{Text: name, Href: "#" + name, Code: true}}
+	case strings.HasPrefix(name, "google.protobuf."):
+		return []*ref{{Text: name, Href: wktURL + strings.ToLower(strings.TrimPrefix(name, "google.protobuf.")), Code: true}}
+	}
+	return []*ref{{Text: name, Code: true}}
+}
+
+// label returns the label of field: its cardinality or oneof.
+func label(idx *typeIndex, desc *descriptor.FileDescriptorProto, msg *descriptor.DescriptorProto, field *descriptor.FieldDescriptorProto) string {
+	switch {
+	case idx.isMap(field):
+	case field.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REPEATED:
+		return "repeated"
+	case field.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REQUIRED:
+		return "required"
+	case field.GetProto3Optional() || (field.OneofIndex == nil && desc.GetSyntax() != "proto3"):
+		return "optional"
+	case field.OneofIndex != nil:
+		return "oneof " + msg.GetOneofDecl()[field.GetOneofIndex()].GetName()
+	}
+	return ""
+}
+
+// comment returns the comment of the declaration at path, or "".
+func (g *pageGen) comment(path []int32) string {
+	c := g.comments[fmt.Sprint(path)]
+	lines := strings.Split(strings.TrimRight(c, "\n"), "\n")
+	for i, l := range lines {
+		lines[i] = strings.TrimPrefix(strings.TrimRight(l, " \t"), " ")
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+type page struct {
+	Title string
+	Files []*pageFile
+}
+
+type pageFile struct {
+	Name       string
+	Anchor     string
+	Package    string
+	Comment    string
+	Deprecated bool
+	Types      []*pageType
+	Services   []*pageService
+}
+
+// pageType is a message, with its fields, or an enum, with its values.
+type pageType struct {
+	Name       string
+	FullName   string
+	Kind       string
+	Comment    string
+	Deprecated bool
+	Fields     []*pageField
+	Values     []*pageValue
+	UsedBy     [][]*ref
+}
+
+type pageField struct {
+	Name       string
+	Type       []*ref
+	Label      string
+	Comment    string
+	Deprecated bool
+}
+
+type pageValue struct {
+	Name       string
+	Number     int32
+	Comment    string
+	Deprecated bool
+}
+
+type pageService struct {
+	Name       string
+	FullName   string
+	Comment    string
+	Deprecated bool
+	Methods    []*pageMethod
+}
+
+type pageMethod struct {
+	Name       string
+	FullName   string
+	Request    []*ref
+	Response   []*ref
+	HTTP       []string
+	Comment    string
+	Deprecated bool
+}
+
+// ref is a piece of text, linked to Href if set and set as code if Code.
+type ref struct {
+	Text string
+	Href string
+	Code bool
+}
+
+// typeIndex maps fully-qualified proto type names (".pkg.Msg") to their
+// descriptors and declaring files for every file in the request.
+type typeIndex struct {
+	messages map[string]*descriptor.DescriptorProto
+	enums    map[string]*descriptor.EnumDescriptorProto
+	files    map[string]*descriptor.FileDescriptorProto
+}
+
+func newTypeIndex(files []*descriptor.FileDescriptorProto) *typeIndex {
+	idx := &typeIndex{
+		messages: make(map[string]*descriptor.DescriptorProto),
+		enums:    make(map[string]*descriptor.EnumDescriptorProto),
+		files:    make(map[string]*descriptor.FileDescriptorProto),
+	}
+	for _, f := range files {
+		prefix := ""
+		if f.GetPackage() != "" {
+			prefix = "." + f.GetPackage()
+		}
+		idx.addMessages(f, prefix, f.GetMessageType())
+		idx.addEnums(f, prefix, f.GetEnumType())
+	}
+	return idx
+}
+
+func (idx *typeIndex) addMessages(f *descriptor.FileDescriptorProto, prefix string, msgs []*descriptor.DescriptorProto) {
+	for _, m := range msgs {
+		name := prefix + "." + m.GetName()
+		idx.messages[name] = m
+		idx.files[name] = f
+		idx.addMessages(f, name, m.GetNestedType())
+		idx.addEnums(f, name, m.GetEnumType())
+	}
+}
+
+func (idx *typeIndex) addEnums(f *descriptor.FileDescriptorProto, prefix string, enums []*descriptor.EnumDescriptorProto) {
+	for _, e := range enums {
+		name := prefix + "." + e.GetName()
+		idx.enums[name] = e
+		idx.files[name] = f
+	}
+}
+
+// isMap reports whether field is a map field.
+func (idx *typeIndex) isMap(field *descriptor.FieldDescriptorProto) bool {
+	if field.GetType() != descriptor.FieldDescriptorProto_TYPE_MESSAGE {
+		return false
+	}
+	return idx.messages[field.GetTypeName()].GetOptions().GetMapEntry()
+}
+
+// parseParams splits the comma separated key=value plugin parameter.
+func parseParams(param string) map[string]string {
+	params := make(map[string]string)
+	for _, p := range strings.Split(param, ",") {
+		if p == "" {
+			continue
+		}
+		if i := strings.IndexByte(p, '='); i >= 0 {
+			params[p[:i]] = p[i+1:]
+		} else {
+			params[p] = ""
+		}
+	}
+	return params
+}
+
+// sanitizePackageName replaces unallowed character in package name
+// with allowed character.
+func sanitizePackageName(pkgName string) string {
+	pkgName = strings.Replace(pkgName, ".", "_", -1)
+	pkgName = strings.Replace(pkgName, "-", "_", -1)
+	return pkgName
+}
+
+// defaultGoPackageName returns the default go package name to be used for go files generated from "f".
+// You might need to use an unique alias for the package when you import it.  Use ReserveGoPackageAlias to get a unique alias.
+func defaultGoPackageName(f *descriptor.FileDescriptorProto) string {
+	name := packageIdentityName(f)
+	return sanitizePackageName(name)
+}
+
+// packageIdentityName returns the identity of packages.
+// protoc-gen-grpc-gateway rejects CodeGenerationRequests which contains more than one packages
+// as protoc-gen-go does.
+func packageIdentityName(f *descriptor.FileDescriptorProto) string {
+	if f.Options != nil && f.Options.GoPackage != nil {
+		gopkg := f.Options.GetGoPackage()
+		idx := strings.LastIndex(gopkg, "/")
+		if idx < 0 {
+			gopkg = gopkg[idx+1:]
+		}
+
+		gopkg = gopkg[idx+1:]
+		// package name is overrided with the string after the
+		// ';' character
+		sc := strings.IndexByte(gopkg, ';')
+		if sc < 0 {
+			return sanitizePackageName(gopkg)
+
+		}
+		return sanitizePackageName(gopkg[sc+1:])
+	}
+
+	if f.Package == nil {
+		base := filepath.Base(f.GetName())
+		ext := filepath.Ext(base)
+		return strings.TrimSuffix(base, ext)
+	}
+	return f.GetPackage()
+}
+
+func emitFiles(out []*plugin.CodeGeneratorResponse_File) {
+	emitResp(&plugin.CodeGeneratorResponse{File: out})
+}
+
+func emitError(err error) {
+	emitResp(&plugin.CodeGeneratorResponse{Error: proto.String(err.Error())})
+}
+
+func emitResp(resp *plugin.CodeGeneratorResponse) {
+	buf, err := proto.Marshal(resp)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if _, err := os.Stdout.Write(buf); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,553 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+
+	"github.com/f4tq/protoc-go-plugins/httprule"
+)
+
+// wktURL is the reference of the well-known types, to which their anchors,
+// the lower-case type names, are appended.
+const wktURL = "https://protobuf.dev/reference/protobuf/google.protobuf/#"
+
+var docTmpl = template.Must(template.New("doc").Parse(`# {{.Source}}
+{{- if .Comment}}
+
+{{.Comment}}
+{{- end}}
+{{- if .Package}}
+
+Package ` + "`{{.Package}}`" + `
+{{- end}}
+
+## Contents
+{{- if .Messages}}
+
+- [Messages](#messages)
+{{- range .Messages}}
+  - [{{.Name}}](#{{.Anchor}})
+{{- end}}
+{{- end}}
+{{- if .Enums}}
+- [Enums](#enums)
+{{- range .Enums}}
+  - [{{.Name}}](#{{.Anchor}})
+{{- end}}
+{{- end}}
+{{- if .Services}}
+- [Services](#services)
+{{- range .Services}}
+  - [{{.Name}}](#{{.Anchor}})
+{{- end}}
+{{- end}}
+{{- if .Messages}}
+
+## Messages
+{{- range .Messages}}
+
+<a id="{{.Anchor}}"></a>
+### {{.Name}}
+{{- if .Comment}}
+
+{{.Comment}}
+{{- end}}
+{{- if .Fields}}
+
+| Field | Type | Label | Description |
+| ----- | ---- | ----- | ----------- |
+{{- range .Fields}}
+| ` + "`{{.Name}}`" + ` | {{.Type}} | {{.Label}} | {{.Comment}} |
+{{- end}}
+{{- end}}
+{{- end}}
+{{- end}}
+{{- if .Enums}}
+
+## Enums
+{{- range .Enums}}
+
+<a id="{{.Anchor}}"></a>
+### {{.Name}}
+{{- if .Comment}}
+
+{{.Comment}}
+{{- end}}
+
+| Name | Number | Description |
+| ---- | ------ | ----------- |
+{{- range .Values}}
+| ` + "`{{.Name}}`" + ` | {{.Number}} | {{.Comment}} |
+{{- end}}
+{{- end}}
+{{- end}}
+{{- if .Services}}
+
+## Services
+{{- range .Services}}
+
+<a id="{{.Anchor}}"></a>
+### {{.Name}}
+{{- if .Comment}}
+
+{{.Comment}}
+{{- end}}
+{{- $http := .HTTP}}
+
+| Method | Request | Response |{{if $http}} HTTP |{{end}} Description |
+| ------ | ------- | -------- |{{if $http}} ---- |{{end}} ----------- |
+{{- range .Methods}}
+| ` + "`{{.Name}}`" + ` | {{.Request}} | {{.Response}} |{{if $http}} {{.HTTP}} |{{end}} {{.Comment}} |
+{{- end}}
+{{- end}}
+{{- end}}
+`))
+
+func main() {
+	input, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	req := new(plugin.CodeGeneratorRequest)
+	if err = proto.Unmarshal(input, req); err != nil {
+		log.Fatal(err)
+	}
+
+	out, err := generate(req)
+
+	if err != nil {
+		emitError(err)
+		return
+	}
+
+	emitFiles(out)
+}
+
+// generate writes the Markdown documentation of each proto file to
+// <base>.md, linking the types of the other files generated by the same
+// run and the well-known types.
+func generate(req *plugin.CodeGeneratorRequest) ([]*plugin.CodeGeneratorResponse_File, error) {
+	var files []*plugin.CodeGeneratorResponse_File
+	idx := newTypeIndex(req.GetProtoFile())
+	genFileNames := make(map[string]bool)
+	for _, n := range req.FileToGenerate {
+		genFileNames[n] = true
+	}
+	for _, desc := range req.GetProtoFile() {
+		name := desc.GetName()
+		if _, ok := genFileNames[name]; !ok {
+			// Only emit output for files present in req.FileToGenerate.
+			continue
+		}
+		doc, err := genDoc(desc, idx, genFileNames)
+		if err != nil {
+			return nil, err
+		}
+
+		ext := filepath.Ext(name)
+		base := strings.TrimSuffix(name, ext)
+		output := fmt.Sprintf("%s.md", base)
+		files = append(files, &plugin.CodeGeneratorResponse_File{
+			Name:    proto.String(output),
+			Content: proto.String(doc),
+		})
+	}
+
+	return files, nil
+}
+
+func genDoc(desc *descriptor.FileDescriptorProto, idx *typeIndex, genFileNames map[string]bool) (string, error) {
+	g := &docGen{
+		desc:         desc,
+		idx:          idx,
+		genFileNames: genFileNames,
+		comments:     make(map[string]string),
+	}
+	for _, loc := range desc.GetSourceCodeInfo().GetLocation() {
+		c := loc.GetLeadingComments()
+		if c == "" {
+			c = loc.GetTrailingComments()
+		}
+		if c != "" {
+			g.comments[fmt.Sprint(loc.GetPath())] = c
+		}
+	}
+	d := &doc{
+		Source:  desc.GetName(),
+		Package: desc.GetPackage(),
+		Comment: g.block([]int32{2}),
+	}
+	scope := strings.TrimSuffix("."+desc.GetPackage(), ".")
+	g.messages(d, scope, []int32{4}, desc.GetMessageType())
+	g.enums(d, scope, []int32{5}, desc.GetEnumType())
+	for i, svc := range desc.GetService() {
+		s, err := g.service(scope, []int32{6, int32(i)}, svc)
+		if err != nil {
+			return "", fmt.Errorf("%s: %v", desc.GetName(), err)
+		}
+		d.Services = append(d.Services, s)
+	}
+
+	w := bytes.NewBuffer(nil)
+	if err := docTmpl.Execute(w, d); err != nil {
+		return "", err
+	}
+	return w.String(), nil
+}
+
+type docGen struct {
+	desc         *descriptor.FileDescriptorProto
+	idx          *typeIndex
+	genFileNames map[string]bool
+	// comments maps the source paths of the declarations of the file to
+	// their comments.
+	comments map[string]string
+}
+
+// messages adds msgs, and the messages and enums nested in them, to d.
+// scope is the full proto name of their parent and path the source path
+// of msgs.
+func (g *docGen) messages(d *doc, scope string, path []int32, msgs []*descriptor.DescriptorProto) {
+	for i, msg := range msgs {
+		if msg.GetOptions().GetMapEntry() {
+			continue
+		}
+		typeName := scope + "." + msg.GetName()
+		msgPath := append(append([]int32(nil), path...), int32(i))
+		m := &docType{
+			Name:    g.displayName(typeName),
+			Anchor:  strings.TrimPrefix(typeName, "."),
+			Comment: g.block(msgPath),
+		}
+		for j, field := range msg.GetField() {
+			fieldPath := append(append([]int32(nil), msgPath...), 2, int32(j))
+			m.Fields = append(m.Fields, &docField{
+				Name:    field.GetName(),
+				Type:    g.fieldType(field),
+				Label:   g.label(msg, field),
+				Comment: g.cell(fieldPath),
+			})
+		}
+		d.Messages = append(d.Messages, m)
+		g.messages(d, typeName, append(msgPath, 3), msg.GetNestedType())
+		g.enums(d, typeName, append(msgPath, 4), msg.GetEnumType())
+	}
+}
+
+// enums adds enums to d, as messages does.
+func (g *docGen) enums(d *doc, scope string, path []int32, enums []*descriptor.EnumDescriptorProto) {
+	for i, enum := range enums {
+		typeName := scope + "." + enum.GetName()
+		enumPath := append(append([]int32(nil), path...), int32(i))
+		e := &docType{
+			Name:    g.displayName(typeName),
+			Anchor:  strings.TrimPrefix(typeName, "."),
+			Comment: g.block(enumPath),
+		}
+		for j, v := range enum.GetValue() {
+			comment := g.cell(append(append([]int32(nil), enumPath...), 2, int32(j)))
+			if v.GetOptions().GetDeprecated() {
+				comment = strings.TrimSpace("Deprecated. " + comment)
+			}
+			e.Values = append(e.Values, &docValue{Name: v.GetName(), Number: v.GetNumber(), Comment: comment})
+		}
+		d.Enums = append(d.Enums, e)
+	}
+}
+
+func (g *docGen) service(scope string, path []int32, svc *descriptor.ServiceDescriptorProto) (*docService, error) {
+	s := &docService{
+		Name:    svc.GetName(),
+		Anchor:  strings.TrimPrefix(scope+"."+svc.GetName(), "."),
+		Comment: g.block(path),
+	}
+	for j, m := range svc.GetMethod() {
+		dm := &docMethod{
+			Name:     m.GetName(),
+			Request:  g.typeLink(m.GetInputType()),
+			Response: g.typeLink(m.GetOutputType()),
+			Comment:  g.cell(append(append([]int32(nil), path...), 2, int32(j))),
+		}
+		if m.GetClientStreaming() {
+			dm.Request = "stream " + dm.Request
+		}
+		if m.GetServerStreaming() {
+			dm.Response = "stream " + dm.Response
+		}
+		if m.GetOptions().GetDeprecated() {
+			dm.Comment = strings.TrimSpace("Deprecated. " + dm.Comment)
+		}
+		bindings, err := httprule.Bindings(m)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", svc.GetName(), err)
+		}
+		var http []string
+		for _, b := range bindings {
+			http = append(http, fmt.Sprintf("`%s %s`", b.Method, b.Template))
+		}
+		if len(http) > 0 {
+			dm.HTTP = strings.Join(http, "<br>")
+			s.HTTP = true
+		}
+		s.Methods = append(s.Methods, dm)
+	}
+	return s, nil
+}
+
+// displayName returns the name of typeName relative to its package, such
+// as User.Address.
+func (g *docGen) displayName(typeName string) string {
+	pkg := g.idx.files[typeName].GetPackage()
+	if pkg == "" {
+		return strings.TrimPrefix(typeName, ".")
+	}
+	return strings.TrimPrefix(typeName, "."+pkg+".")
+}
+
+// fieldType returns the Markdown of the type of field.
+func (g *docGen) fieldType(field *descriptor.FieldDescriptorProto) string {
+	if g.idx.isMap(field) {
+		entry := g.idx.messages[field.GetTypeName()]
+		return fmt.Sprintf("map&lt;%s, %s&gt;", g.fieldType(entry.GetField()[0]), g.fieldType(entry.GetField()[1]))
+	}
+	switch field.GetType() {
+	case descriptor.FieldDescriptorProto_TYPE_MESSAGE, descriptor.FieldDescriptorProto_TYPE_GROUP,
+		descriptor.FieldDescriptorProto_TYPE_ENUM:
+		return g.typeLink(field.GetTypeName())
+	}
+	return "`" + strings.ToLower(strings.TrimPrefix(field.GetType().String(), "TYPE_")) + "`"
+}
+
+// typeLink returns the link to the documentation of the message or enum
+// typeName: an anchor of this file, of the Markdown of another generated
+// file, or of the reference of the well-known types. Other types are not
+// linked.
+func (g *docGen) typeLink(typeName string) string {
+	name := strings.TrimPrefix(typeName, ".")
+	f := g.idx.files[typeName]
+	switch {
+	case f == g.desc:
+		return fmt.Sprintf("[`%s`](#%s)", g.displayName(typeName), name)
+	case f != nil && g.genFileNames[f.GetName()]:
+		rel, err := filepath.Rel(filepath.Dir(g.desc.GetName()), strings.TrimSuffix(f.GetName(), filepath.Ext(f.GetName()))+".md")
+		if err == nil {
+			return fmt.Sprintf("[`%s`](%s#%s)", name, filepath.ToSlash(rel), name)
+		}
+	case strings.HasPrefix(name, "google.protobuf."):
+		short := strings.TrimPrefix(name, "google.protobuf.")
+		return fmt.Sprintf("[`%s`](%s%s)", name, wktURL, strings.ToLower(short))
+	}
+	return "`" + name + "`"
+}
+
+// label returns the label of field: its cardinality, oneof and
+// deprecation.
+func (g *docGen) label(msg *descriptor.DescriptorProto, field *descriptor.FieldDescriptorProto) string {
+	var labels []string
+	switch {
+	case g.idx.isMap(field):
+	case field.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REPEATED:
+		labels = append(labels, "repeated")
+	case field.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REQUIRED:
+		labels = append(labels, "required")
+	case field.GetProto3Optional() || (field.OneofIndex == nil && g.desc.GetSyntax() != "proto3"):
+		labels = append(labels, "optional")
+	case field.OneofIndex != nil:
+		labels = append(labels, fmt.Sprintf("oneof `%s`", msg.GetOneofDecl()[field.GetOneofIndex()].GetName()))
+	}
+	if field.GetOptions().GetDeprecated() {
+		labels = append(labels, "deprecated")
+	}
+	return strings.Join(labels, ", ")
+}
+
+// block returns the comment of the declaration at path as Markdown
+// paragraphs, or "".
+func (g *docGen) block(path []int32) string {
+	c := g.comments[fmt.Sprint(path)]
+	lines := strings.Split(strings.TrimRight(c, "\n"), "\n")
+	for i, l := range lines {
+		lines[i] = strings.TrimPrefix(strings.TrimRight(l, " \t"), " ")
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+// cell returns the comment of the declaration at path on one line, for a
+// table cell.
+func (g *docGen) cell(path []int32) string {
+	c := strings.Join(strings.Fields(g.comments[fmt.Sprint(path)]), " ")
+	return strings.Replace(c, "|", `\|`, -1)
+}
+
+type doc struct {
+	Source   string
+	Package  string
+	Comment  string
+	Messages []*docType
+	Enums    []*docType
+	Services []*docService
+}
+
+// docType is a message, with its fields, or an enum, with its values.
+type docType struct {
+	Name    string
+	Anchor  string
+	Comment string
+	Fields  []*docField
+	Values  []*docValue
+}
+
+type docField struct {
+	Name    string
+	Type    string
+	Label   string
+	Comment string
+}
+
+type docValue struct {
+	Name    string
+	Number  int32
+	Comment string
+}
+
+type docService struct {
+	Name    string
+	Anchor  string
+	Comment string
+	// HTTP reports whether a method has google.api.http bindings.
+	HTTP    bool
+	Methods []*docMethod
+}
+
+type docMethod struct {
+	Name     string
+	Request  string
+	Response string
+	HTTP     string
+	Comment  string
+}
+
+// typeIndex maps fully-qualified proto type names (".pkg.Msg") to their
+// descriptors and declaring files for every file in the request.
+type typeIndex struct {
+	messages map[string]*descriptor.DescriptorProto
+	enums    map[string]*descriptor.EnumDescriptorProto
+	files    map[string]*descriptor.FileDescriptorProto
+}
+
+func newTypeIndex(files []*descriptor.FileDescriptorProto) *typeIndex {
+	idx := &typeIndex{
+		messages: make(map[string]*descriptor.DescriptorProto),
+		enums:    make(map[string]*descriptor.EnumDescriptorProto),
+		files:    make(map[string]*descriptor.FileDescriptorProto),
+	}
+	for _, f := range files {
+		prefix := ""
+		if f.GetPackage() != "" {
+			prefix = "." + f.GetPackage()
+		}
+		idx.addMessages(f, prefix, f.GetMessageType())
+		idx.addEnums(f, prefix, f.GetEnumType())
+	}
+	return idx
+}
+
+func (idx *typeIndex) addMessages(f *descriptor.FileDescriptorProto, prefix string, msgs []*descriptor.DescriptorProto) {
+	for _, m := range msgs {
+		name := prefix + "." + m.GetName()
+		idx.messages[name] = m
+		idx.files[name] = f
+		idx.addMessages(f, name, m.GetNestedType())
+		idx.addEnums(f, name, m.GetEnumType())
+	}
+}
+
+func (idx *typeIndex) addEnums(f *descriptor.FileDescriptorProto, prefix string, enums []*descriptor.EnumDescriptorProto) {
+	for _, e := range enums {
+		name := prefix + "." + e.GetName()
+		idx.enums[name] = e
+		idx.files[name] = f
+	}
+}
+
+// isMap reports whether field is a map field.
+func (idx *typeIndex) isMap(field *descriptor.FieldDescriptorProto) bool {
+	if field.GetType() != descriptor.FieldDescriptorProto_TYPE_MESSAGE {
+		return false
+	}
+	return idx.messages[field.GetTypeName()].GetOptions().GetMapEntry()
+}
+
+// sanitizePackageName replaces unallowed character in package name
+// with allowed character.
+func sanitizePackageName(pkgName string) string {
+	pkgName = strings.Replace(pkgName, ".", "_", -1)
+	pkgName = strings.Replace(pkgName, "-", "_", -1)
+	return pkgName
+}
+
+// defaultGoPackageName returns the default go package name to be used for go files generated from "f".
+// You might need to use an unique alias for the package when you import it.  Use ReserveGoPackageAlias to get a unique alias.
+func defaultGoPackageName(f *descriptor.FileDescriptorProto) string {
+	name := packageIdentityName(f)
+	return sanitizePackageName(name)
+}
+
+// packageIdentityName returns the identity of packages.
+// protoc-gen-grpc-gateway rejects CodeGenerationRequests which contains more than one packages
+// as protoc-gen-go does.
+func packageIdentityName(f *descriptor.FileDescriptorProto) string {
+	if f.Options != nil && f.Options.GoPackage != nil {
+		gopkg := f.Options.GetGoPackage()
+		idx := strings.LastIndex(gopkg, "/")
+		if idx < 0 {
+			gopkg = gopkg[idx+1:]
+		}
+
+		gopkg = gopkg[idx+1:]
+		// package name is overrided with the string after the
+		// ';' character
+		sc := strings.IndexByte(gopkg, ';')
+		if sc < 0 {
+			return sanitizePackageName(gopkg)
+
+		}
+		return sanitizePackageName(gopkg[sc+1:])
+	}
+
+	if f.Package == nil {
+		base := filepath.Base(f.GetName())
+		ext := filepath.Ext(base)
+		return strings.TrimSuffix(base, ext)
+	}
+	return f.GetPackage()
+}
+
+func emitFiles(out []*plugin.CodeGeneratorResponse_File) {
+	emitResp(&plugin.CodeGeneratorResponse{File: out})
+}
+
+func emitError(err error) {
+	emitResp(&plugin.CodeGeneratorResponse{Error: proto.String(err.Error())})
+}
+
+func emitResp(resp *plugin.CodeGeneratorResponse) {
+	buf, err := proto.Marshal(resp)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if _, err := os.Stdout.Write(buf); err != nil {
+		log.Fatal(err)
+	}
+}
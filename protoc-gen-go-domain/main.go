@@ -0,0 +1,599 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+
+	"github.com/f4tq/protoc-go-plugins/goname"
+	"github.com/f4tq/protoc-go-plugins/options"
+)
+
+var (
+	hdrTmpl = template.Must(template.New("header").Parse(`
+// Code generated by protoc-gen-go-domain. DO NOT EDIT.
+// source: {{.Source}}
+
+package {{.GoPkg}}
+
+import (
+{{- if .Bytes}}
+    "bytes"
+{{- end}}
+{{- if .Maps}}
+    "maps"
+{{- end}}
+{{- if .Slices}}
+    "slices"
+{{- end}}
+{{if or .Bytes .Maps .Slices}}
+{{end}}
+{{- if .Proto}}
+    "github.com/golang/protobuf/proto"
+{{- end}}
+{{- range $path, $name := .Imports}}
+    {{$name}} "{{$path}}"
+{{- end}}
+)
+`))
+
+	domainTmpl = template.Must(template.New("domain").Parse(`
+// ToDomain returns m mapped to a {{.Domain}}, each field to the field of
+// the same Go name or (f4tq.plugins.domain_field).
+{{- if .Skipped}}
+// Left to the hook: {{.Skipped}}.
+{{- end}}
+//
+// If {{.Name}} has a method toDomain(*{{.Domain}}) error, it is called
+// last to complete the result.
+func (m *{{.Name}}) ToDomain() (*{{.Domain}}, error) {
+    if m == nil {
+        return nil, nil
+    }
+    out := &{{.Domain}}{}
+{{- range .To}}
+{{.}}
+{{- end}}
+    if h, ok := interface{}(m).(interface{ toDomain(*{{.Domain}}) error }); ok {
+        if err := h.toDomain(out); err != nil {
+            return nil, err
+        }
+    }
+    return out, nil
+}
+
+// FromDomain sets m to d mapped back, see ToDomain. If {{.Name}} has a
+// method fromDomain(*{{.Domain}}) error, it is called last to complete m.
+func (m *{{.Name}}) FromDomain(d *{{.Domain}}) error {
+    m.Reset()
+    if d == nil {
+        return nil
+    }
+{{- range .From}}
+{{.}}
+{{- end}}
+    if h, ok := interface{}(m).(interface{ fromDomain(*{{.Domain}}) error }); ok {
+        if err := h.fromDomain(d); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+`))
+)
+
+func main() {
+	input, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	req := new(plugin.CodeGeneratorRequest)
+	if err = proto.Unmarshal(input, req); err != nil {
+		log.Fatal(err)
+	}
+
+	out, err := generate(req)
+
+	if err != nil {
+		emitError(err)
+		return
+	}
+
+	emitFiles(out)
+}
+
+func generate(req *plugin.CodeGeneratorRequest) ([]*plugin.CodeGeneratorResponse_File, error) {
+	var files []*plugin.CodeGeneratorResponse_File
+	idx := newTypeIndex(req.GetProtoFile())
+	genFileNames := make(map[string]bool)
+	for _, n := range req.FileToGenerate {
+		genFileNames[n] = true
+	}
+	for _, desc := range req.GetProtoFile() {
+		name := desc.GetName()
+		if _, ok := genFileNames[name]; !ok {
+			// Only emit output for files present in req.FileToGenerate.
+			continue
+		}
+		code, err := genCode(desc, idx, genFileNames)
+		if err != nil {
+			return nil, err
+		}
+		if code == "" {
+			// No message of the file sets domain_type.
+			continue
+		}
+		formatted, err := format.Source([]byte(code))
+		if err != nil {
+			log.Printf("%v: %s", err, code)
+			return nil, err
+		}
+
+		ext := filepath.Ext(name)
+		base := strings.TrimSuffix(name, ext)
+		output := fmt.Sprintf("%s.pb.domain.go", base)
+		files = append(files, &plugin.CodeGeneratorResponse_File{
+			Name:    proto.String(output),
+			Content: proto.String(string(formatted)),
+		})
+	}
+
+	return files, nil
+}
+
+func genCode(desc *descriptor.FileDescriptorProto, idx *typeIndex, genFileNames map[string]bool) (string, error) {
+	w := bytes.NewBuffer(nil)
+	g := &domainGen{
+		idx:     idx,
+		imports: newImportSet(desc),
+		gen:     genFileNames,
+		proto3:  desc.GetSyntax() == "proto3",
+	}
+	body := bytes.NewBuffer(nil)
+	if err := g.messages(body, "", desc.GetMessageType()); err != nil {
+		return "", err
+	}
+	if body.Len() == 0 {
+		return "", nil
+	}
+
+	hdr := &header{
+		Source:  desc.GetName(),
+		GoPkg:   defaultGoPackageName(desc),
+		Imports: g.imports.names,
+		Bytes:   g.bytes,
+		Maps:    g.maps,
+		Slices:  g.slices,
+		Proto:   g.proto,
+	}
+	if err := hdrTmpl.Execute(w, hdr); err != nil {
+		log.Fatal(err)
+	}
+	body.WriteTo(w)
+
+	return w.String(), nil
+}
+
+type domainGen struct {
+	idx     *typeIndex
+	imports *importSet
+	gen     map[string]bool
+	proto3  bool
+	// bytes, maps, slices and proto record the packages the code uses.
+	bytes, maps, slices, proto bool
+}
+
+// direction is one of ToDomain and FromDomain.
+type direction struct {
+	to bool
+	// ret is what the method returns on error.
+	ret string
+}
+
+// messages writes the mappers of the messages of msgs and of the messages
+// nested in them that set domain_type. prefix is the Go name of the
+// enclosing message plus "_".
+func (g *domainGen) messages(w *bytes.Buffer, prefix string, msgs []*descriptor.DescriptorProto) error {
+	for _, msg := range msgs {
+		if msg.GetOptions().GetMapEntry() {
+			continue
+		}
+		name := prefix + msg.GetName()
+		if dt := options.DomainType(msg); dt != "" {
+			domain, err := g.domainType(dt)
+			if err != nil {
+				return fmt.Errorf("%s: %v", name, err)
+			}
+			m := &domainMessage{Name: name, Domain: domain}
+			var skipped []string
+			goNames := goname.Fields(msg)
+			for _, field := range msg.GetField() {
+				df := options.DomainField(field)
+				if df == "-" || field.OneofIndex != nil && !field.GetProto3Optional() {
+					skipped = append(skipped, field.GetName())
+					continue
+				}
+				if df == "" {
+					df = camelCase(field.GetName())
+				}
+				S := "m." + goNames[field.GetName()]
+				m.To = append(m.To, g.field(direction{to: true, ret: "nil, err"}, field, "out."+df, S))
+				m.From = append(m.From, g.field(direction{ret: "err"}, field, S, "d."+df))
+			}
+			m.Skipped = strings.Join(skipped, ", ")
+			if err := domainTmpl.Execute(w, m); err != nil {
+				return err
+			}
+		}
+		if err := g.messages(w, name+"_", msg.GetNestedType()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// domainType returns the Go name of the domain_type dt, "import/path.Type",
+// qualified with the package it imports.
+func (g *domainGen) domainType(dt string) (string, error) {
+	i := strings.LastIndexByte(dt, '.')
+	if i <= 0 || strings.LastIndexByte(dt, '/') > i {
+		return "", fmt.Errorf("domain_type %q is not of the form import/path.Type", dt)
+	}
+	importPath := dt[:i]
+	if importPath == g.imports.self {
+		return dt[i+1:], nil
+	}
+	pkg := sanitizePackageName(path.Base(importPath))
+	g.imports.names[importPath] = pkg
+	return pkg + "." + dt[i+1:], nil
+}
+
+// field returns the statements setting D to the mapped value of S, where
+// one is the Go field of field and the other its domain field.
+func (g *domainGen) field(dir direction, field *descriptor.FieldDescriptorProto, D, S string) string {
+	switch {
+	case g.idx.isMap(field):
+		value := g.idx.messages[field.GetTypeName()].GetField()[1]
+		stmts, expr := g.elem(dir, value, "e")
+		if len(stmts) == 0 && expr == "e" {
+			g.maps = true
+			return fmt.Sprintf("%s = maps.Clone(%s)", D, S)
+		}
+		stmts = append(stmts, fmt.Sprintf("%s[k] = %s", D, expr))
+		return fmt.Sprintf("if %s != nil {\n%s = make(map[%s]%s, len(%s))\nfor k, e := range %s {\n%s\n}\n}",
+			S, D, g.goType(g.idx.messages[field.GetTypeName()].GetField()[0]), g.elemType(dir, value), S, S, strings.Join(stmts, "\n"))
+	case field.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REPEATED:
+		stmts, expr := g.elem(dir, field, "e")
+		if len(stmts) == 0 && expr == "e" {
+			g.slices = true
+			return fmt.Sprintf("%s = slices.Clone(%s)", D, S)
+		}
+		stmts = append(stmts, fmt.Sprintf("%s = append(%s, %s)", D, D, expr))
+		return fmt.Sprintf("for _, e := range %s {\n%s\n}", S, strings.Join(stmts, "\n"))
+	case g.isMessage(field):
+		stmts, expr := g.elem(dir, field, S)
+		stmts = append(stmts, fmt.Sprintf("%s = %s", D, expr))
+		return fmt.Sprintf("if %s != nil {\n%s\n}", S, strings.Join(stmts, "\n"))
+	case field.GetType() != descriptor.FieldDescriptorProto_TYPE_BYTES && (field.GetProto3Optional() || !g.proto3):
+		return fmt.Sprintf("if %s != nil {\nv := *%s\n%s = &v\n}", S, S, D)
+	}
+	_, expr := g.elem(dir, field, S)
+	return fmt.Sprintf("%s = %s", D, expr)
+}
+
+// elem returns the statements and the expression mapping src, a value of
+// field or of its domain field.
+func (g *domainGen) elem(dir direction, field *descriptor.FieldDescriptorProto, src string) ([]string, string) {
+	switch {
+	case g.hasDomain(field) && dir.to:
+		return []string{
+			fmt.Sprintf("c, err := %s.ToDomain()", src),
+			fmt.Sprintf("if err != nil {\nreturn %s\n}", dir.ret),
+		}, "c"
+	case g.hasDomain(field):
+		return []string{
+			fmt.Sprintf("c := new(%s)", g.imports.goTypeName(g.idx, field.GetTypeName())),
+			fmt.Sprintf("if err := c.FromDomain(%s); err != nil {\nreturn %s\n}", src, dir.ret),
+		}, "c"
+	case g.isMessage(field):
+		g.proto = true
+		return nil, fmt.Sprintf("proto.Clone(%s).(%s)", src, g.goType(field))
+	case field.GetType() == descriptor.FieldDescriptorProto_TYPE_BYTES:
+		g.bytes = true
+		return nil, fmt.Sprintf("bytes.Clone(%s)", src)
+	}
+	return nil, src
+}
+
+// elemType returns the Go type of a value of field or of its domain field.
+func (g *domainGen) elemType(dir direction, field *descriptor.FieldDescriptorProto) string {
+	if g.hasDomain(field) && dir.to {
+		dt, _ := g.domainType(options.DomainType(g.idx.messages[field.GetTypeName()]))
+		return "*" + dt
+	}
+	return g.goType(field)
+}
+
+// hasDomain reports whether field holds a message with generated ToDomain
+// and FromDomain methods.
+func (g *domainGen) hasDomain(field *descriptor.FieldDescriptorProto) bool {
+	return g.isMessage(field) && options.DomainType(g.idx.messages[field.GetTypeName()]) != "" &&
+		g.gen[g.idx.files[field.GetTypeName()].GetName()]
+}
+
+func (g *domainGen) isMessage(field *descriptor.FieldDescriptorProto) bool {
+	return field.GetType() == descriptor.FieldDescriptorProto_TYPE_MESSAGE ||
+		field.GetType() == descriptor.FieldDescriptorProto_TYPE_GROUP
+}
+
+// goType returns the Go type of a value of field.
+func (g *domainGen) goType(field *descriptor.FieldDescriptorProto) string {
+	switch field.GetType() {
+	case descriptor.FieldDescriptorProto_TYPE_MESSAGE, descriptor.FieldDescriptorProto_TYPE_GROUP:
+		return "*" + g.imports.goTypeName(g.idx, field.GetTypeName())
+	case descriptor.FieldDescriptorProto_TYPE_ENUM:
+		return g.imports.goTypeName(g.idx, field.GetTypeName())
+	case descriptor.FieldDescriptorProto_TYPE_BYTES:
+		return "[]byte"
+	}
+	return scalarGoType(field.GetType())
+}
+
+// scalarGoType returns the Go type of the scalar type t.
+func scalarGoType(t descriptor.FieldDescriptorProto_Type) string {
+	switch t {
+	case descriptor.FieldDescriptorProto_TYPE_DOUBLE:
+		return "float64"
+	case descriptor.FieldDescriptorProto_TYPE_FLOAT:
+		return "float32"
+	case descriptor.FieldDescriptorProto_TYPE_INT64, descriptor.FieldDescriptorProto_TYPE_SINT64,
+		descriptor.FieldDescriptorProto_TYPE_SFIXED64:
+		return "int64"
+	case descriptor.FieldDescriptorProto_TYPE_UINT64, descriptor.FieldDescriptorProto_TYPE_FIXED64:
+		return "uint64"
+	case descriptor.FieldDescriptorProto_TYPE_INT32, descriptor.FieldDescriptorProto_TYPE_SINT32,
+		descriptor.FieldDescriptorProto_TYPE_SFIXED32:
+		return "int32"
+	case descriptor.FieldDescriptorProto_TYPE_UINT32, descriptor.FieldDescriptorProto_TYPE_FIXED32:
+		return "uint32"
+	case descriptor.FieldDescriptorProto_TYPE_BOOL:
+		return "bool"
+	}
+	return "string"
+}
+
+type header struct {
+	Source  string
+	GoPkg   string
+	Imports map[string]string
+	Bytes   bool
+	Maps    bool
+	Slices  bool
+	Proto   bool
+}
+
+type domainMessage struct {
+	Name    string
+	Domain  string
+	To      []string
+	From    []string
+	Skipped string
+}
+
+// typeIndex maps fully-qualified proto type names (".pkg.Msg") to their
+// descriptors and declaring files for every file in the request.
+type typeIndex struct {
+	messages map[string]*descriptor.DescriptorProto
+	enums    map[string]*descriptor.EnumDescriptorProto
+	files    map[string]*descriptor.FileDescriptorProto
+}
+
+func newTypeIndex(files []*descriptor.FileDescriptorProto) *typeIndex {
+	idx := &typeIndex{
+		messages: make(map[string]*descriptor.DescriptorProto),
+		enums:    make(map[string]*descriptor.EnumDescriptorProto),
+		files:    make(map[string]*descriptor.FileDescriptorProto),
+	}
+	for _, f := range files {
+		prefix := ""
+		if f.GetPackage() != "" {
+			prefix = "." + f.GetPackage()
+		}
+		idx.addMessages(f, prefix, f.GetMessageType())
+		idx.addEnums(f, prefix, f.GetEnumType())
+	}
+	return idx
+}
+
+func (idx *typeIndex) addMessages(f *descriptor.FileDescriptorProto, prefix string, msgs []*descriptor.DescriptorProto) {
+	for _, m := range msgs {
+		name := prefix + "." + m.GetName()
+		idx.messages[name] = m
+		idx.files[name] = f
+		idx.addMessages(f, name, m.GetNestedType())
+		idx.addEnums(f, name, m.GetEnumType())
+	}
+}
+
+func (idx *typeIndex) addEnums(f *descriptor.FileDescriptorProto, prefix string, enums []*descriptor.EnumDescriptorProto) {
+	for _, e := range enums {
+		name := prefix + "." + e.GetName()
+		idx.enums[name] = e
+		idx.files[name] = f
+	}
+}
+
+// isMap reports whether field is a map field.
+func (idx *typeIndex) isMap(field *descriptor.FieldDescriptorProto) bool {
+	if field.GetType() != descriptor.FieldDescriptorProto_TYPE_MESSAGE {
+		return false
+	}
+	return idx.messages[field.GetTypeName()].GetOptions().GetMapEntry()
+}
+
+// goImportPath returns the import path of the Go package generated from f,
+// or "" if go_package does not name one.
+func goImportPath(f *descriptor.FileDescriptorProto) string {
+	gopkg := f.GetOptions().GetGoPackage()
+	if sc := strings.IndexByte(gopkg, ';'); sc >= 0 {
+		gopkg = gopkg[:sc]
+	}
+	if !strings.Contains(gopkg, "/") {
+		return ""
+	}
+	return gopkg
+}
+
+// importSet records the Go packages referenced by the code generated for
+// one file, keyed by import path.
+type importSet struct {
+	self  string
+	names map[string]string
+}
+
+func newImportSet(f *descriptor.FileDescriptorProto) *importSet {
+	return &importSet{self: goImportPath(f), names: make(map[string]string)}
+}
+
+// goTypeName returns the Go name of the message or enum typeName, qualified
+// with its package if it is declared outside the generated package.
+func (s *importSet) goTypeName(idx *typeIndex, typeName string) string {
+	name := localTypeName(typeName)
+	f := idx.files[typeName]
+	path := goImportPath(f)
+	if f == nil || path == "" || path == s.self {
+		return name
+	}
+	pkg := defaultGoPackageName(f)
+	s.names[path] = pkg
+	return pkg + "." + name
+}
+
+// localTypeName returns the Go name of a type declared in the file being
+// generated, e.g. ".pkg.Outer.Inner" becomes "Outer_Inner".
+func localTypeName(typeName string) string {
+	parts := strings.Split(strings.TrimPrefix(typeName, "."), ".")
+	var names []string
+	for _, p := range parts {
+		if len(p) > 0 && p[0] >= 'A' && p[0] <= 'Z' {
+			names = append(names, p)
+		}
+	}
+	return strings.Join(names, "_")
+}
+
+// camelCase returns the Go field name protoc-gen-go derives from s.
+func camelCase(s string) string {
+	if s == "" {
+		return ""
+	}
+	t := make([]byte, 0, 32)
+	i := 0
+	if s[0] == '_' {
+		// Need a capital letter; drop the '_'.
+		t = append(t, 'X')
+		i++
+	}
+	for ; i < len(s); i++ {
+		c := s[i]
+		if c == '_' && i+1 < len(s) && isASCIILower(s[i+1]) {
+			continue
+		}
+		if isASCIIDigit(c) {
+			t = append(t, c)
+			continue
+		}
+		if isASCIILower(c) {
+			c ^= ' '
+		}
+		t = append(t, c)
+		for i+1 < len(s) && isASCIILower(s[i+1]) {
+			i++
+			t = append(t, s[i])
+		}
+	}
+	return string(t)
+}
+
+func isASCIILower(c byte) bool {
+	return 'a' <= c && c <= 'z'
+}
+
+func isASCIIDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+// sanitizePackageName replaces unallowed character in package name
+// with allowed character.
+func sanitizePackageName(pkgName string) string {
+	pkgName = strings.Replace(pkgName, ".", "_", -1)
+	pkgName = strings.Replace(pkgName, "-", "_", -1)
+	return pkgName
+}
+
+// defaultGoPackageName returns the default go package name to be used for go files generated from "f".
+// You might need to use an unique alias for the package when you import it.  Use ReserveGoPackageAlias to get a unique alias.
+func defaultGoPackageName(f *descriptor.FileDescriptorProto) string {
+	name := packageIdentityName(f)
+	return sanitizePackageName(name)
+}
+
+// packageIdentityName returns the identity of packages.
+// protoc-gen-grpc-gateway rejects CodeGenerationRequests which contains more than one packages
+// as protoc-gen-go does.
+func packageIdentityName(f *descriptor.FileDescriptorProto) string {
+	if f.Options != nil && f.Options.GoPackage != nil {
+		gopkg := f.Options.GetGoPackage()
+		idx := strings.LastIndex(gopkg, "/")
+		if idx < 0 {
+			gopkg = gopkg[idx+1:]
+		}
+
+		gopkg = gopkg[idx+1:]
+		// package name is overrided with the string after the
+		// ';' character
+		sc := strings.IndexByte(gopkg, ';')
+		if sc < 0 {
+			return sanitizePackageName(gopkg)
+
+		}
+		return sanitizePackageName(gopkg[sc+1:])
+	}
+
+	if f.Package == nil {
+		base := filepath.Base(f.GetName())
+		ext := filepath.Ext(base)
+		return strings.TrimSuffix(base, ext)
+	}
+	return f.GetPackage()
+}
+
+func emitFiles(out []*plugin.CodeGeneratorResponse_File) {
+	emitResp(&plugin.CodeGeneratorResponse{File: out})
+}
+
+func emitError(err error) {
+	emitResp(&plugin.CodeGeneratorResponse{Error: proto.String(err.Error())})
+}
+
+func emitResp(resp *plugin.CodeGeneratorResponse) {
+	buf, err := proto.Marshal(resp)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if _, err := os.Stdout.Write(buf); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,400 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+
+	"github.com/f4tq/protoc-go-plugins/goname"
+	"github.com/f4tq/protoc-go-plugins/options"
+)
+
+var (
+	hdrTmpl = template.Must(template.New("header").Parse(`
+// Code generated by protoc-gen-go-encrypt. DO NOT EDIT.
+// source: {{.Source}}
+
+package {{.GoPkg}}
+
+import (
+    "context"
+
+    "github.com/f4tq/protoc-go-plugins/runtime/fieldcrypt"
+)
+`))
+
+	encryptTmpl = template.Must(template.New("encrypt").Parse(`
+// EncryptFields envelope-encrypts in place the (f4tq.plugins.encrypted)
+// fields of m and of the messages it holds, under a data key wrapped by
+// kms. On error m may be left partly encrypted.
+func (m *{{.Name}}) EncryptFields(ctx context.Context, kms fieldcrypt.KMS) error {
+    s := fieldcrypt.NewSealer(ctx, kms)
+    m.CryptFields(s)
+    return s.Err()
+}
+
+// DecryptFields decrypts in place the fields that EncryptFields encrypted,
+// unwrapping their data keys with kms. On error m may be left partly
+// decrypted.
+func (m *{{.Name}}) DecryptFields(ctx context.Context, kms fieldcrypt.KMS) error {
+    o := fieldcrypt.NewOpener(ctx, kms)
+    m.CryptFields(o)
+    return o.Err()
+}
+
+// CryptFields replaces the values of the encrypted fields of m, and of the
+// messages it holds, by their transformation by c.
+func (m *{{.Name}}) CryptFields(c fieldcrypt.Crypter) {
+    if m == nil {
+        return
+    }
+{{- range .Fields}}
+{{.}}
+{{- end}}
+}
+`))
+)
+
+func main() {
+	input, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	req := new(plugin.CodeGeneratorRequest)
+	if err = proto.Unmarshal(input, req); err != nil {
+		log.Fatal(err)
+	}
+
+	out, err := generate(req)
+
+	if err != nil {
+		emitError(err)
+		return
+	}
+
+	emitFiles(out)
+}
+
+func generate(req *plugin.CodeGeneratorRequest) ([]*plugin.CodeGeneratorResponse_File, error) {
+	var files []*plugin.CodeGeneratorResponse_File
+	idx := newTypeIndex(req.GetProtoFile())
+	genFileNames := make(map[string]bool)
+	for _, n := range req.FileToGenerate {
+		genFileNames[n] = true
+	}
+	has := encryptMessages(idx, genFileNames)
+	for _, desc := range req.GetProtoFile() {
+		name := desc.GetName()
+		if _, ok := genFileNames[name]; !ok {
+			// Only emit output for files present in req.FileToGenerate.
+			continue
+		}
+		code, err := genCode(desc, idx, has)
+		if err != nil {
+			return nil, err
+		}
+		if code == "" {
+			// No message of the file has fields to encrypt.
+			continue
+		}
+		formatted, err := format.Source([]byte(code))
+		if err != nil {
+			log.Printf("%v: %s", err, code)
+			return nil, err
+		}
+
+		ext := filepath.Ext(name)
+		base := strings.TrimSuffix(name, ext)
+		output := fmt.Sprintf("%s.pb.encrypt.go", base)
+		files = append(files, &plugin.CodeGeneratorResponse_File{
+			Name:    proto.String(output),
+			Content: proto.String(string(formatted)),
+		})
+	}
+
+	return files, nil
+}
+
+func genCode(desc *descriptor.FileDescriptorProto, idx *typeIndex, has map[string]bool) (string, error) {
+	w := bytes.NewBuffer(nil)
+	g := &encryptGen{
+		idx:    idx,
+		proto3: desc.GetSyntax() == "proto3",
+		has:    has,
+	}
+	body := bytes.NewBuffer(nil)
+	if err := g.messages(body, strings.TrimSuffix("."+desc.GetPackage(), "."), "", desc.GetMessageType()); err != nil {
+		return "", err
+	}
+	if body.Len() == 0 {
+		return "", nil
+	}
+
+	hdr := &header{
+		Source: desc.GetName(),
+		GoPkg:  defaultGoPackageName(desc),
+	}
+	if err := hdrTmpl.Execute(w, hdr); err != nil {
+		log.Fatal(err)
+	}
+	body.WriteTo(w)
+
+	return w.String(), nil
+}
+
+type encryptGen struct {
+	idx    *typeIndex
+	proto3 bool
+	// has holds the messages with encryption methods.
+	has map[string]bool
+}
+
+// messages writes the encryption methods of msgs and of the messages nested
+// in them. scope is the full proto name of their parent, prefix the Go name
+// of the enclosing message plus "_".
+func (g *encryptGen) messages(w *bytes.Buffer, scope, prefix string, msgs []*descriptor.DescriptorProto) error {
+	for _, msg := range msgs {
+		if msg.GetOptions().GetMapEntry() {
+			continue
+		}
+		typeName := scope + "." + msg.GetName()
+		name := prefix + msg.GetName()
+		if g.has[typeName] {
+			m := &encryptMessage{Name: name}
+			for _, field := range msg.GetField() {
+				stmt, err := g.field(typeName, name, msg, field)
+				if err != nil {
+					return fmt.Errorf("%s.%s: %v", name, field.GetName(), err)
+				}
+				if stmt != "" {
+					m.Fields = append(m.Fields, stmt)
+				}
+			}
+			if err := encryptTmpl.Execute(w, m); err != nil {
+				return err
+			}
+		}
+		if err := g.messages(w, typeName, name+"_", msg.GetNestedType()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// encryptMessages returns the generated messages that have a field to
+// encrypt, or a message field of such a type.
+func encryptMessages(idx *typeIndex, genFileNames map[string]bool) map[string]bool {
+	has := make(map[string]bool)
+	// Repeat until no message is added, as messages may refer to each
+	// other in cycles.
+	for changed := true; changed; {
+		changed = false
+		for typeName, msg := range idx.messages {
+			if has[typeName] || !genFileNames[idx.files[typeName].GetName()] {
+				continue
+			}
+			for _, field := range msg.GetField() {
+				if options.Encrypted(field) {
+					has[typeName] = true
+					changed = true
+					break
+				}
+				if idx.isMap(field) {
+					field = idx.messages[field.GetTypeName()].GetField()[1]
+				}
+				if has[field.GetTypeName()] {
+					has[typeName] = true
+					changed = true
+					break
+				}
+			}
+		}
+	}
+	return has
+}
+
+// field returns the statements transforming field of msg, whose full proto
+// name is typeName and Go name msgName, or "" if it has nothing to encrypt.
+// The full name of the field binds its ciphertexts to it.
+func (g *encryptGen) field(typeName, msgName string, msg *descriptor.DescriptorProto, field *descriptor.FieldDescriptorProto) (string, error) {
+	goNames := goname.Fields(msg)
+	goName := goNames[field.GetName()]
+	oneof := field.OneofIndex != nil && !field.GetProto3Optional()
+	value := field
+	if g.idx.isMap(field) {
+		value = g.idx.messages[field.GetTypeName()].GetField()[1]
+	}
+	if !options.Encrypted(field) {
+		if value.GetType() != descriptor.FieldDescriptorProto_TYPE_MESSAGE || !g.has[value.GetTypeName()] {
+			return "", nil
+		}
+		switch {
+		case field.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REPEATED:
+			return fmt.Sprintf("for _, v := range m.%s {\nv.CryptFields(c)\n}", goName), nil
+		case oneof:
+			return fmt.Sprintf("if v, ok := m.%s.(*%s_%s); ok {\nv.%s.CryptFields(c)\n}",
+				goNames[msg.GetOneofDecl()[field.GetOneofIndex()].GetName()], msgName, goName, goName), nil
+		}
+		return fmt.Sprintf("m.%s.CryptFields(c)", goName), nil
+	}
+	var method string
+	switch value.GetType() {
+	case descriptor.FieldDescriptorProto_TYPE_STRING:
+		method = "String"
+	case descriptor.FieldDescriptorProto_TYPE_BYTES:
+		method = "Bytes"
+	default:
+		return "", fmt.Errorf("encrypted only applies to string and bytes fields and maps with such values")
+	}
+	apply := func(v string) string {
+		return fmt.Sprintf("c.%s(%s, %q)", method, v, strings.TrimPrefix(typeName, ".")+"."+field.GetName())
+	}
+	switch {
+	case g.idx.isMap(field):
+		return fmt.Sprintf("for k, v := range m.%s {\nm.%s[k] = %s\n}", goName, goName, apply("v")), nil
+	case field.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REPEATED:
+		return fmt.Sprintf("for i, v := range m.%s {\nm.%s[i] = %s\n}", goName, goName, apply("v")), nil
+	case oneof:
+		return fmt.Sprintf("if v, ok := m.%s.(*%s_%s); ok {\nv.%s = %s\n}",
+			goNames[msg.GetOneofDecl()[field.GetOneofIndex()].GetName()], msgName, goName, goName, apply("v."+goName)), nil
+	case value.GetType() == descriptor.FieldDescriptorProto_TYPE_STRING && (field.GetProto3Optional() || !g.proto3):
+		// The field is a pointer.
+		return fmt.Sprintf("if m.%s != nil {\n*m.%s = %s\n}", goName, goName, apply("*m."+goName)), nil
+	}
+	return fmt.Sprintf("m.%s = %s", goName, apply("m."+goName)), nil
+}
+
+type header struct {
+	Source string
+	GoPkg  string
+}
+
+type encryptMessage struct {
+	Name   string
+	Fields []string
+}
+
+// typeIndex maps fully-qualified proto type names (".pkg.Msg") to their
+// descriptors and declaring files for every file in the request.
+type typeIndex struct {
+	messages map[string]*descriptor.DescriptorProto
+	enums    map[string]*descriptor.EnumDescriptorProto
+	files    map[string]*descriptor.FileDescriptorProto
+}
+
+func newTypeIndex(files []*descriptor.FileDescriptorProto) *typeIndex {
+	idx := &typeIndex{
+		messages: make(map[string]*descriptor.DescriptorProto),
+		enums:    make(map[string]*descriptor.EnumDescriptorProto),
+		files:    make(map[string]*descriptor.FileDescriptorProto),
+	}
+	for _, f := range files {
+		prefix := ""
+		if f.GetPackage() != "" {
+			prefix = "." + f.GetPackage()
+		}
+		idx.addMessages(f, prefix, f.GetMessageType())
+		idx.addEnums(f, prefix, f.GetEnumType())
+	}
+	return idx
+}
+
+func (idx *typeIndex) addMessages(f *descriptor.FileDescriptorProto, prefix string, msgs []*descriptor.DescriptorProto) {
+	for _, m := range msgs {
+		name := prefix + "." + m.GetName()
+		idx.messages[name] = m
+		idx.files[name] = f
+		idx.addMessages(f, name, m.GetNestedType())
+		idx.addEnums(f, name, m.GetEnumType())
+	}
+}
+
+func (idx *typeIndex) addEnums(f *descriptor.FileDescriptorProto, prefix string, enums []*descriptor.EnumDescriptorProto) {
+	for _, e := range enums {
+		name := prefix + "." + e.GetName()
+		idx.enums[name] = e
+		idx.files[name] = f
+	}
+}
+
+// isMap reports whether field is a map field.
+func (idx *typeIndex) isMap(field *descriptor.FieldDescriptorProto) bool {
+	if field.GetType() != descriptor.FieldDescriptorProto_TYPE_MESSAGE {
+		return false
+	}
+	return idx.messages[field.GetTypeName()].GetOptions().GetMapEntry()
+}
+
+// sanitizePackageName replaces unallowed character in package name
+// with allowed character.
+func sanitizePackageName(pkgName string) string {
+	pkgName = strings.Replace(pkgName, ".", "_", -1)
+	pkgName = strings.Replace(pkgName, "-", "_", -1)
+	return pkgName
+}
+
+// defaultGoPackageName returns the default go package name to be used for go files generated from "f".
+// You might need to use an unique alias for the package when you import it.  Use ReserveGoPackageAlias to get a unique alias.
+func defaultGoPackageName(f *descriptor.FileDescriptorProto) string {
+	name := packageIdentityName(f)
+	return sanitizePackageName(name)
+}
+
+// packageIdentityName returns the identity of packages.
+// protoc-gen-grpc-gateway rejects CodeGenerationRequests which contains more than one packages
+// as protoc-gen-go does.
+func packageIdentityName(f *descriptor.FileDescriptorProto) string {
+	if f.Options != nil && f.Options.GoPackage != nil {
+		gopkg := f.Options.GetGoPackage()
+		idx := strings.LastIndex(gopkg, "/")
+		if idx < 0 {
+			gopkg = gopkg[idx+1:]
+		}
+
+		gopkg = gopkg[idx+1:]
+		// package name is overrided with the string after the
+		// ';' character
+		sc := strings.IndexByte(gopkg, ';')
+		if sc < 0 {
+			return sanitizePackageName(gopkg)
+
+		}
+		return sanitizePackageName(gopkg[sc+1:])
+	}
+
+	if f.Package == nil {
+		base := filepath.Base(f.GetName())
+		ext := filepath.Ext(base)
+		return strings.TrimSuffix(base, ext)
+	}
+	return f.GetPackage()
+}
+
+func emitFiles(out []*plugin.CodeGeneratorResponse_File) {
+	emitResp(&plugin.CodeGeneratorResponse{File: out})
+}
+
+func emitError(err error) {
+	emitResp(&plugin.CodeGeneratorResponse{Error: proto.String(err.Error())})
+}
+
+func emitResp(resp *plugin.CodeGeneratorResponse) {
+	buf, err := proto.Marshal(resp)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if _, err := os.Stdout.Write(buf); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,518 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+
+	"github.com/f4tq/protoc-go-plugins/options"
+	"github.com/f4tq/protoc-go-plugins/protodoc"
+)
+
+var (
+	schemaTmpl = template.Must(template.New("schema").Parse(`
+// Code generated by protoc-gen-go-ent. DO NOT EDIT.
+// source: {{.Source}}
+
+package {{.Pkg}}
+
+import (
+    "entgo.io/ent"
+{{- if .Table}}
+    "entgo.io/ent/dialect/entsql"
+    "entgo.io/ent/schema"
+{{- end}}
+{{- if .Edges}}
+    "entgo.io/ent/schema/edge"
+{{- end}}
+    "entgo.io/ent/schema/field"
+{{- if .Indexes}}
+    "entgo.io/ent/schema/index"
+{{- end}}
+{{- if .Imports}}
+{{range $path, $name := .Imports}}
+    {{$name}} "{{$path}}"
+{{- end}}
+{{- end}}
+)
+
+// {{.Name}} holds the schema definition for the {{.Name}} entity.{{.Doc}}
+type {{.Name}} struct {
+    ent.Schema
+}
+{{- if .Table}}
+
+// Annotations of the {{.Name}}.
+func ({{.Name}}) Annotations() []schema.Annotation {
+    return []schema.Annotation{
+        entsql.Annotation{Table: {{printf "%q" .Table}}},
+    }
+}
+{{- end}}
+
+// Fields of the {{.Name}}.
+func ({{.Name}}) Fields() []ent.Field {
+    return []ent.Field{
+{{- range .Fields}}
+        {{.}},
+{{- end}}
+    }
+}
+
+// Edges of the {{.Name}}.
+func ({{.Name}}) Edges() []ent.Edge {
+{{- if .Edges}}
+    return []ent.Edge{
+{{- range .Edges}}
+        {{.}},
+{{- end}}
+    }
+{{- else}}
+    return nil
+{{- end}}
+}
+
+// Indexes of the {{.Name}}.
+func ({{.Name}}) Indexes() []ent.Index {
+{{- if .Indexes}}
+    return []ent.Index{
+{{- range .Indexes}}
+        {{.}},
+{{- end}}
+    }
+{{- else}}
+    return nil
+{{- end}}
+}
+`))
+)
+
+func main() {
+	input, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	req := new(plugin.CodeGeneratorRequest)
+	if err = proto.Unmarshal(input, req); err != nil {
+		log.Fatal(err)
+	}
+
+	out, err := generate(req)
+
+	if err != nil {
+		emitError(err)
+		return
+	}
+
+	emitFiles(out)
+}
+
+func generate(req *plugin.CodeGeneratorRequest) ([]*plugin.CodeGeneratorResponse_File, error) {
+	var files []*plugin.CodeGeneratorResponse_File
+	params := parseParams(req.GetParameter())
+	schemaDir := "ent/schema"
+	if d, ok := params["schema_dir"]; ok {
+		schemaDir = d
+	}
+	idx := newTypeIndex(req.GetProtoFile())
+	docs := protodoc.New(req.GetProtoFile())
+	genFileNames := make(map[string]bool)
+	for _, n := range req.FileToGenerate {
+		genFileNames[n] = true
+	}
+	for _, desc := range req.GetProtoFile() {
+		name := desc.GetName()
+		if _, ok := genFileNames[name]; !ok {
+			// Only emit output for files present in req.FileToGenerate.
+			continue
+		}
+		for _, msg := range desc.GetMessageType() {
+			if !options.Ent(msg) {
+				continue
+			}
+			code, err := genCode(desc, msg, idx, docs, path.Base(schemaDir))
+			if err != nil {
+				return nil, err
+			}
+			formatted, err := format.Source([]byte(code))
+			if err != nil {
+				log.Printf("%v: %s", err, code)
+				return nil, err
+			}
+
+			output := path.Join(filepath.Dir(name), schemaDir, strings.ToLower(msg.GetName())+".go")
+			files = append(files, &plugin.CodeGeneratorResponse_File{
+				Name:    proto.String(output),
+				Content: proto.String(string(formatted)),
+			})
+		}
+	}
+
+	return files, nil
+}
+
+func genCode(desc *descriptor.FileDescriptorProto, msg *descriptor.DescriptorProto, idx *typeIndex, docs *protodoc.Index, pkg string) (string, error) {
+	w := bytes.NewBuffer(nil)
+	s := &entSchema{
+		Source: desc.GetName(),
+		Pkg:    sanitizePackageName(pkg),
+		Name:   msg.GetName(),
+		Table:  options.Table(msg),
+		Doc:    docs.Godoc(qualify(desc.GetPackage(), msg.GetName())),
+	}
+	for _, field := range msg.GetField() {
+		if err := s.addField(desc, msg, field, idx); err != nil {
+			return "", err
+		}
+	}
+
+	if err := schemaTmpl.Execute(w, s); err != nil {
+		return "", err
+	}
+
+	return w.String(), nil
+}
+
+type entSchema struct {
+	Source  string
+	Pkg     string
+	Name    string
+	Table   string
+	Imports map[string]string
+	Fields  []string
+	Edges   []string
+	Indexes []string
+	// Doc is the comment of the message, see protodoc.Index.Godoc.
+	Doc string
+}
+
+// addField maps field onto an ent field or edge of s.
+func (s *entSchema) addField(desc *descriptor.FileDescriptorProto, msg *descriptor.DescriptorProto, field *descriptor.FieldDescriptorProto, idx *typeIndex) error {
+	name := field.GetName()
+	repeated := field.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REPEATED
+	if field.GetType() == descriptor.FieldDescriptorProto_TYPE_MESSAGE && !idx.isMap(field) {
+		target := idx.messages[field.GetTypeName()]
+		if target != nil && options.Ent(target) {
+			e := fmt.Sprintf("edge.To(%q, %s.Type)", name, localTypeName(field.GetTypeName()))
+			if !repeated {
+				e += ".Unique()"
+			}
+			s.Edges = append(s.Edges, e)
+			return nil
+		}
+	}
+
+	var expr string
+	switch {
+	case idx.isMap(field):
+		entry := idx.messages[field.GetTypeName()]
+		k, err := s.goType(desc, entry.GetField()[0], idx)
+		if err != nil {
+			return err
+		}
+		v, err := s.goType(desc, entry.GetField()[1], idx)
+		if err != nil {
+			return err
+		}
+		expr = fmt.Sprintf("field.JSON(%q, map[%s]%s{})", name, k, v)
+	case repeated && field.GetType() == descriptor.FieldDescriptorProto_TYPE_STRING:
+		expr = fmt.Sprintf("field.Strings(%q)", name)
+	case repeated:
+		t, err := s.goType(desc, field, idx)
+		if err != nil {
+			return err
+		}
+		expr = fmt.Sprintf("field.JSON(%q, []%s{})", name, t)
+	case field.GetType() == descriptor.FieldDescriptorProto_TYPE_ENUM:
+		enum := idx.enums[field.GetTypeName()]
+		if enum == nil {
+			return fmt.Errorf("%s.%s: unknown enum %s", msg.GetName(), name, field.GetTypeName())
+		}
+		var values []string
+		for _, v := range enum.GetValue() {
+			values = append(values, strconv.Quote(v.GetName()))
+		}
+		expr = fmt.Sprintf("field.Enum(%q).Values(%s)", name, strings.Join(values, ", "))
+	case field.GetTypeName() == ".google.protobuf.Timestamp":
+		expr = fmt.Sprintf("field.Time(%q).Optional()", name)
+	case field.GetType() == descriptor.FieldDescriptorProto_TYPE_MESSAGE:
+		t, err := s.goType(desc, field, idx)
+		if err != nil {
+			return err
+		}
+		expr = fmt.Sprintf("field.JSON(%q, %s{}).Optional()", name, strings.Replace(t, "*", "&", 1))
+	default:
+		ctor, ok := entFieldCtors[field.GetType()]
+		if !ok {
+			return fmt.Errorf("%s.%s: unsupported field type %v", msg.GetName(), name, field.GetType())
+		}
+		expr = fmt.Sprintf("field.%s(%q)", ctor, name)
+	}
+
+	if options.PrimaryKey(field) {
+		// ent requires the identifier field to be called "id".
+		expr = strings.Replace(expr, strconv.Quote(name), `"id"`, 1)
+		if c := options.Column(field); c != "id" {
+			expr += fmt.Sprintf(".StorageKey(%q)", c)
+		}
+		s.Fields = append([]string{expr}, s.Fields...)
+		return nil
+	}
+	if c := options.Column(field); c != name {
+		expr += fmt.Sprintf(".StorageKey(%q)", c)
+	}
+	if field.OneofIndex != nil {
+		expr += ".Optional()"
+		if field.GetProto3Optional() {
+			expr += ".Nillable()"
+		}
+	}
+	if options.Unique(field) {
+		expr += ".Unique()"
+	}
+	if options.Index(field) {
+		s.Indexes = append(s.Indexes, fmt.Sprintf("index.Fields(%q)", name))
+	}
+	if field.GetOptions().GetDeprecated() {
+		expr += ".Deprecated()"
+	}
+	s.Fields = append(s.Fields, expr)
+	return nil
+}
+
+var entFieldCtors = map[descriptor.FieldDescriptorProto_Type]string{
+	descriptor.FieldDescriptorProto_TYPE_STRING:   "String",
+	descriptor.FieldDescriptorProto_TYPE_BOOL:     "Bool",
+	descriptor.FieldDescriptorProto_TYPE_BYTES:    "Bytes",
+	descriptor.FieldDescriptorProto_TYPE_INT32:    "Int32",
+	descriptor.FieldDescriptorProto_TYPE_SINT32:   "Int32",
+	descriptor.FieldDescriptorProto_TYPE_SFIXED32: "Int32",
+	descriptor.FieldDescriptorProto_TYPE_INT64:    "Int64",
+	descriptor.FieldDescriptorProto_TYPE_SINT64:   "Int64",
+	descriptor.FieldDescriptorProto_TYPE_SFIXED64: "Int64",
+	descriptor.FieldDescriptorProto_TYPE_UINT32:   "Uint32",
+	descriptor.FieldDescriptorProto_TYPE_FIXED32:  "Uint32",
+	descriptor.FieldDescriptorProto_TYPE_UINT64:   "Uint64",
+	descriptor.FieldDescriptorProto_TYPE_FIXED64:  "Uint64",
+	descriptor.FieldDescriptorProto_TYPE_FLOAT:    "Float32",
+	descriptor.FieldDescriptorProto_TYPE_DOUBLE:   "Float",
+}
+
+var goScalarTypes = map[descriptor.FieldDescriptorProto_Type]string{
+	descriptor.FieldDescriptorProto_TYPE_STRING:   "string",
+	descriptor.FieldDescriptorProto_TYPE_BOOL:     "bool",
+	descriptor.FieldDescriptorProto_TYPE_BYTES:    "[]byte",
+	descriptor.FieldDescriptorProto_TYPE_INT32:    "int32",
+	descriptor.FieldDescriptorProto_TYPE_SINT32:   "int32",
+	descriptor.FieldDescriptorProto_TYPE_SFIXED32: "int32",
+	descriptor.FieldDescriptorProto_TYPE_INT64:    "int64",
+	descriptor.FieldDescriptorProto_TYPE_SINT64:   "int64",
+	descriptor.FieldDescriptorProto_TYPE_SFIXED64: "int64",
+	descriptor.FieldDescriptorProto_TYPE_UINT32:   "uint32",
+	descriptor.FieldDescriptorProto_TYPE_FIXED32:  "uint32",
+	descriptor.FieldDescriptorProto_TYPE_UINT64:   "uint64",
+	descriptor.FieldDescriptorProto_TYPE_FIXED64:  "uint64",
+	descriptor.FieldDescriptorProto_TYPE_FLOAT:    "float32",
+	descriptor.FieldDescriptorProto_TYPE_DOUBLE:   "float64",
+}
+
+// goType returns the Go type of a single value of field as seen from the
+// schema package. Message and enum types are qualified with the import of
+// the Go package generated from their file.
+func (s *entSchema) goType(desc *descriptor.FileDescriptorProto, field *descriptor.FieldDescriptorProto, idx *typeIndex) (string, error) {
+	if t, ok := goScalarTypes[field.GetType()]; ok {
+		return t, nil
+	}
+	f := idx.files[field.GetTypeName()]
+	imp := goImportPath(f)
+	if imp == "" {
+		return "", fmt.Errorf("%s: go_package with an import path is required to reference %s", f.GetName(), field.GetTypeName())
+	}
+	pkg := defaultGoPackageName(f)
+	if s.Imports == nil {
+		s.Imports = make(map[string]string)
+	}
+	s.Imports[imp] = pkg
+	t := pkg + "." + localTypeName(field.GetTypeName())
+	if field.GetType() == descriptor.FieldDescriptorProto_TYPE_MESSAGE {
+		t = "*" + t
+	}
+	return t, nil
+}
+
+// goImportPath returns the import path of the Go package generated from f,
+// or "" if go_package does not name one.
+func goImportPath(f *descriptor.FileDescriptorProto) string {
+	gopkg := f.GetOptions().GetGoPackage()
+	if sc := strings.IndexByte(gopkg, ';'); sc >= 0 {
+		gopkg = gopkg[:sc]
+	}
+	if !strings.Contains(gopkg, "/") {
+		return ""
+	}
+	return gopkg
+}
+
+// parseParams splits the comma separated key=value plugin parameter.
+func parseParams(param string) map[string]string {
+	params := make(map[string]string)
+	for _, p := range strings.Split(param, ",") {
+		if p == "" {
+			continue
+		}
+		if i := strings.IndexByte(p, '='); i >= 0 {
+			params[p[:i]] = p[i+1:]
+		} else {
+			params[p] = ""
+		}
+	}
+	return params
+}
+
+// qualify returns the full proto name of name, declared in scope.
+func qualify(scope, name string) string {
+	if scope == "" {
+		return name
+	}
+	return scope + "." + name
+}
+
+// typeIndex maps fully-qualified proto type names (".pkg.Msg") to their
+// descriptors and declaring files for every file in the request.
+type typeIndex struct {
+	messages map[string]*descriptor.DescriptorProto
+	enums    map[string]*descriptor.EnumDescriptorProto
+	files    map[string]*descriptor.FileDescriptorProto
+}
+
+func newTypeIndex(files []*descriptor.FileDescriptorProto) *typeIndex {
+	idx := &typeIndex{
+		messages: make(map[string]*descriptor.DescriptorProto),
+		enums:    make(map[string]*descriptor.EnumDescriptorProto),
+		files:    make(map[string]*descriptor.FileDescriptorProto),
+	}
+	for _, f := range files {
+		prefix := ""
+		if f.GetPackage() != "" {
+			prefix = "." + f.GetPackage()
+		}
+		idx.addMessages(f, prefix, f.GetMessageType())
+		idx.addEnums(f, prefix, f.GetEnumType())
+	}
+	return idx
+}
+
+func (idx *typeIndex) addMessages(f *descriptor.FileDescriptorProto, prefix string, msgs []*descriptor.DescriptorProto) {
+	for _, m := range msgs {
+		name := prefix + "." + m.GetName()
+		idx.messages[name] = m
+		idx.files[name] = f
+		idx.addMessages(f, name, m.GetNestedType())
+		idx.addEnums(f, name, m.GetEnumType())
+	}
+}
+
+func (idx *typeIndex) addEnums(f *descriptor.FileDescriptorProto, prefix string, enums []*descriptor.EnumDescriptorProto) {
+	for _, e := range enums {
+		name := prefix + "." + e.GetName()
+		idx.enums[name] = e
+		idx.files[name] = f
+	}
+}
+
+// isMap reports whether field is a map field.
+func (idx *typeIndex) isMap(field *descriptor.FieldDescriptorProto) bool {
+	if field.GetType() != descriptor.FieldDescriptorProto_TYPE_MESSAGE {
+		return false
+	}
+	return idx.messages[field.GetTypeName()].GetOptions().GetMapEntry()
+}
+
+// localTypeName returns the Go name of a type declared in the file being
+// generated, e.g. ".pkg.Outer.Inner" becomes "Outer_Inner".
+func localTypeName(typeName string) string {
+	parts := strings.Split(strings.TrimPrefix(typeName, "."), ".")
+	var names []string
+	for _, p := range parts {
+		if len(p) > 0 && p[0] >= 'A' && p[0] <= 'Z' {
+			names = append(names, p)
+		}
+	}
+	return strings.Join(names, "_")
+}
+
+// sanitizePackageName replaces unallowed character in package name
+// with allowed character.
+func sanitizePackageName(pkgName string) string {
+	pkgName = strings.Replace(pkgName, ".", "_", -1)
+	pkgName = strings.Replace(pkgName, "-", "_", -1)
+	return pkgName
+}
+
+// defaultGoPackageName returns the default go package name to be used for go files generated from "f".
+// You might need to use an unique alias for the package when you import it.  Use ReserveGoPackageAlias to get a unique alias.
+func defaultGoPackageName(f *descriptor.FileDescriptorProto) string {
+	name := packageIdentityName(f)
+	return sanitizePackageName(name)
+}
+
+// packageIdentityName returns the identity of packages.
+// protoc-gen-grpc-gateway rejects CodeGenerationRequests which contains more than one packages
+// as protoc-gen-go does.
+func packageIdentityName(f *descriptor.FileDescriptorProto) string {
+	if f.Options != nil && f.Options.GoPackage != nil {
+		gopkg := f.Options.GetGoPackage()
+		idx := strings.LastIndex(gopkg, "/")
+		if idx < 0 {
+			gopkg = gopkg[idx+1:]
+		}
+
+		gopkg = gopkg[idx+1:]
+		// package name is overrided with the string after the
+		// ';' character
+		sc := strings.IndexByte(gopkg, ';')
+		if sc < 0 {
+			return sanitizePackageName(gopkg)
+
+		}
+		return sanitizePackageName(gopkg[sc+1:])
+	}
+
+	if f.Package == nil {
+		base := filepath.Base(f.GetName())
+		ext := filepath.Ext(base)
+		return strings.TrimSuffix(base, ext)
+	}
+	return f.GetPackage()
+}
+
+func emitFiles(out []*plugin.CodeGeneratorResponse_File) {
+	emitResp(&plugin.CodeGeneratorResponse{File: out})
+}
+
+func emitError(err error) {
+	emitResp(&plugin.CodeGeneratorResponse{Error: proto.String(err.Error())})
+}
+
+func emitResp(resp *plugin.CodeGeneratorResponse) {
+	buf, err := proto.Marshal(resp)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if _, err := os.Stdout.Write(buf); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,331 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+
+	"github.com/f4tq/protoc-go-plugins/protodoc"
+)
+
+var (
+	hdrTmpl = template.Must(template.New("header").Parse(`
+// Code generated by protoc-gen-go-enum. DO NOT EDIT.
+// source: {{.Source}}
+
+package {{.GoPkg}}
+
+import (
+    "flag"
+    "fmt"
+    "strconv"
+)
+`))
+
+	enumTmpl = template.Must(template.New("enum").Parse(`
+var _ flag.Value = (*{{.Name}})(nil)
+
+// Parse{{.Name}} returns the {{.Name}} named s, which is either the name or
+// the number of one of its values.{{.Doc}}
+func Parse{{.Name}}(s string) ({{.Name}}, error) {
+    if v, ok := {{.Name}}_value[s]; ok {
+        return {{.Name}}(v), nil
+    }
+    if n, err := strconv.ParseInt(s, 10, 32); err == nil {
+        if _, ok := {{.Name}}_name[int32(n)]; ok {
+            return {{.Name}}(n), nil
+        }
+    }
+    return 0, fmt.Errorf("{{.FullName}}: unknown value %q", s)
+}
+
+// {{.Name}}Values returns the values of {{.Name}} in declaration order,
+// aliases left out.{{.Doc}}
+func {{.Name}}Values() []{{.Name}} {
+    return []{{.Name}}{
+{{- range .Values}}
+        {{.}},
+{{- end}}
+    }
+}
+
+// MarshalText implements encoding.TextMarshaler, encoding x as its name.
+func (x {{.Name}}) MarshalText() ([]byte, error) {
+    return []byte(x.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler, see Parse{{.Name}}.
+func (x *{{.Name}}) UnmarshalText(b []byte) error {
+    v, err := Parse{{.Name}}(string(b))
+    if err != nil {
+        return err
+    }
+    *x = v
+    return nil
+}
+
+// Set implements flag.Value, see Parse{{.Name}}.
+func (x *{{.Name}}) Set(s string) error {
+    return x.UnmarshalText([]byte(s))
+}
+`))
+)
+
+func main() {
+	input, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	req := new(plugin.CodeGeneratorRequest)
+	if err = proto.Unmarshal(input, req); err != nil {
+		log.Fatal(err)
+	}
+
+	out, err := generate(req)
+
+	if err != nil {
+		emitError(err)
+		return
+	}
+
+	emitFiles(out)
+}
+
+func generate(req *plugin.CodeGeneratorRequest) ([]*plugin.CodeGeneratorResponse_File, error) {
+	var files []*plugin.CodeGeneratorResponse_File
+	docs := protodoc.New(req.GetProtoFile())
+	genFileNames := make(map[string]bool)
+	for _, n := range req.FileToGenerate {
+		genFileNames[n] = true
+	}
+	for _, desc := range req.GetProtoFile() {
+		name := desc.GetName()
+		if _, ok := genFileNames[name]; !ok {
+			// Only emit output for files present in req.FileToGenerate.
+			continue
+		}
+		code, err := genCode(desc, docs)
+		if err != nil {
+			return nil, err
+		}
+		if code == "" {
+			// The file declares no enums.
+			continue
+		}
+		formatted, err := format.Source([]byte(code))
+		if err != nil {
+			log.Printf("%v: %s", err, code)
+			return nil, err
+		}
+
+		ext := filepath.Ext(name)
+		base := strings.TrimSuffix(name, ext)
+		output := fmt.Sprintf("%s.pb.enum.go", base)
+		files = append(files, &plugin.CodeGeneratorResponse_File{
+			Name:    proto.String(output),
+			Content: proto.String(string(formatted)),
+		})
+	}
+
+	return files, nil
+}
+
+func genCode(desc *descriptor.FileDescriptorProto, docs *protodoc.Index) (string, error) {
+	w := bytes.NewBuffer(nil)
+	body := bytes.NewBuffer(nil)
+	if err := enums(body, docs, "", desc.GetPackage(), desc.GetEnumType()); err != nil {
+		return "", err
+	}
+	if err := messages(body, docs, "", desc.GetPackage(), desc.GetMessageType()); err != nil {
+		return "", err
+	}
+	if body.Len() == 0 {
+		return "", nil
+	}
+
+	hdr := &header{
+		Source: desc.GetName(),
+		GoPkg:  defaultGoPackageName(desc),
+	}
+	if err := hdrTmpl.Execute(w, hdr); err != nil {
+		log.Fatal(err)
+	}
+	body.WriteTo(w)
+
+	return w.String(), nil
+}
+
+// messages writes the helpers of the enums nested in msgs. prefix is the Go
+// name of the enclosing message plus "_" and scope its full proto name.
+func messages(w *bytes.Buffer, docs *protodoc.Index, prefix, scope string, msgs []*descriptor.DescriptorProto) error {
+	for _, msg := range msgs {
+		name := prefix + msg.GetName()
+		full := scope + "." + msg.GetName()
+		if err := enums(w, docs, name+"_", full, msg.GetEnumType()); err != nil {
+			return err
+		}
+		if err := messages(w, docs, name+"_", full, msg.GetNestedType()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// enums writes the helpers of decls, declared in the message whose Go name
+// plus "_" is prefix, or at the top level if prefix is empty.
+func enums(w *bytes.Buffer, docs *protodoc.Index, prefix, scope string, decls []*descriptor.EnumDescriptorProto) error {
+	for _, e := range decls {
+		name := prefix + e.GetName()
+		// protoc-gen-go prefixes the value constants of a nested enum with
+		// the name of its message rather than its own.
+		valuePrefix := prefix
+		if valuePrefix == "" {
+			valuePrefix = name + "_"
+		}
+		en := &enum{
+			Name:     name,
+			FullName: strings.TrimPrefix(scope+"."+e.GetName(), "."),
+		}
+		en.Doc = docs.Godoc(en.FullName)
+		seen := make(map[int32]bool)
+		for _, v := range e.GetValue() {
+			if seen[v.GetNumber()] {
+				continue
+			}
+			seen[v.GetNumber()] = true
+			en.Values = append(en.Values, valuePrefix+v.GetName())
+		}
+		if err := enumTmpl.Execute(w, en); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type header struct {
+	Source string
+	GoPkg  string
+}
+
+type enum struct {
+	Name     string
+	FullName string
+	Values   []string
+	// Doc is the comment of the enum, see protodoc.Index.Godoc.
+	Doc string
+}
+
+// camelCase returns the Go field name protoc-gen-go derives from s.
+func camelCase(s string) string {
+	if s == "" {
+		return ""
+	}
+	t := make([]byte, 0, 32)
+	i := 0
+	if s[0] == '_' {
+		// Need a capital letter; drop the '_'.
+		t = append(t, 'X')
+		i++
+	}
+	for ; i < len(s); i++ {
+		c := s[i]
+		if c == '_' && i+1 < len(s) && isASCIILower(s[i+1]) {
+			continue
+		}
+		if isASCIIDigit(c) {
+			t = append(t, c)
+			continue
+		}
+		if isASCIILower(c) {
+			c ^= ' '
+		}
+		t = append(t, c)
+		for i+1 < len(s) && isASCIILower(s[i+1]) {
+			i++
+			t = append(t, s[i])
+		}
+	}
+	return string(t)
+}
+
+func isASCIILower(c byte) bool {
+	return 'a' <= c && c <= 'z'
+}
+
+func isASCIIDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+// sanitizePackageName replaces unallowed character in package name
+// with allowed character.
+func sanitizePackageName(pkgName string) string {
+	pkgName = strings.Replace(pkgName, ".", "_", -1)
+	pkgName = strings.Replace(pkgName, "-", "_", -1)
+	return pkgName
+}
+
+// defaultGoPackageName returns the default go package name to be used for go files generated from "f".
+// You might need to use an unique alias for the package when you import it.  Use ReserveGoPackageAlias to get a unique alias.
+func defaultGoPackageName(f *descriptor.FileDescriptorProto) string {
+	name := packageIdentityName(f)
+	return sanitizePackageName(name)
+}
+
+// packageIdentityName returns the identity of packages.
+// protoc-gen-grpc-gateway rejects CodeGenerationRequests which contains more than one packages
+// as protoc-gen-go does.
+func packageIdentityName(f *descriptor.FileDescriptorProto) string {
+	if f.Options != nil && f.Options.GoPackage != nil {
+		gopkg := f.Options.GetGoPackage()
+		idx := strings.LastIndex(gopkg, "/")
+		if idx < 0 {
+			gopkg = gopkg[idx+1:]
+		}
+
+		gopkg = gopkg[idx+1:]
+		// package name is overrided with the string after the
+		// ';' character
+		sc := strings.IndexByte(gopkg, ';')
+		if sc < 0 {
+			return sanitizePackageName(gopkg)
+
+		}
+		return sanitizePackageName(gopkg[sc+1:])
+	}
+
+	if f.Package == nil {
+		base := filepath.Base(f.GetName())
+		ext := filepath.Ext(base)
+		return strings.TrimSuffix(base, ext)
+	}
+	return f.GetPackage()
+}
+
+func emitFiles(out []*plugin.CodeGeneratorResponse_File) {
+	emitResp(&plugin.CodeGeneratorResponse{File: out})
+}
+
+func emitError(err error) {
+	emitResp(&plugin.CodeGeneratorResponse{Error: proto.String(err.Error())})
+}
+
+func emitResp(resp *plugin.CodeGeneratorResponse) {
+	buf, err := proto.Marshal(resp)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if _, err := os.Stdout.Write(buf); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,528 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+
+	"github.com/f4tq/protoc-go-plugins/goname"
+	"github.com/f4tq/protoc-go-plugins/options"
+	"github.com/f4tq/protoc-go-plugins/protodoc"
+)
+
+// durationType is the proto type parsed with scalarparse.Duration.
+const durationType = ".google.protobuf.Duration"
+
+// parseFuncs are the scalarparse functions parsing the Go types of
+// scalars.
+var parseFuncs = map[string]string{
+	"string":  "String",
+	"bool":    "Bool",
+	"int32":   "Int32",
+	"int64":   "Int64",
+	"uint32":  "Uint32",
+	"uint64":  "Uint64",
+	"float32": "Float32",
+	"float64": "Float64",
+	"[]byte":  "Bytes",
+}
+
+var (
+	hdrTmpl = template.Must(template.New("header").Parse(`
+// Code generated by protoc-gen-go-env. DO NOT EDIT.
+// source: {{.Source}}
+
+package {{.GoPkg}}
+
+import (
+{{- range $path, $name := .Imports}}
+    {{$name}} "{{$path}}"
+{{- end}}
+)
+`))
+
+	envTmpl = template.Must(template.New("env").Parse(`
+{{- if .Config}}
+
+// Load{{.Name}}FromEnv returns the {{.Name}} read from the
+// environment by LoadEnv. The error joins the *envload.ParseError of each
+// variable that does not parse.{{.Doc}}
+func Load{{.Name}}FromEnv(prefix string) (*{{.Name}}, error) {
+    m := new({{.Name}})
+    l := envload.New(os.Environ())
+    m.LoadEnv(l, prefix)
+    return m, l.Err()
+}
+{{- end}}
+
+// LoadEnv sets the fields of m from the variables of l named after them in
+// upper snake case, following prefix: prefix+"FIELD_NAME". Fields whose
+// variable is unset keep their values. The fields of message fields take
+// prefix+"FIELD_NAME__", the message being allocated if one of them is set.
+// Repeated fields are comma separated lists, map<string, string> fields
+// lists of key=value pairs, durations Go durations such as "1m30s" and
+// bytes standard base64. Oneofs, timestamps, repeated messages and other
+// maps are not read.
+func (m *{{.Name}}) LoadEnv(l *envload.Loader, prefix string) {
+{{- range .Fields}}
+{{.}}
+{{- end}}
+}
+`))
+)
+
+func main() {
+	input, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	req := new(plugin.CodeGeneratorRequest)
+	if err = proto.Unmarshal(input, req); err != nil {
+		log.Fatal(err)
+	}
+
+	out, err := generate(req)
+
+	if err != nil {
+		emitError(err)
+		return
+	}
+
+	emitFiles(out)
+}
+
+func generate(req *plugin.CodeGeneratorRequest) ([]*plugin.CodeGeneratorResponse_File, error) {
+	var files []*plugin.CodeGeneratorResponse_File
+	idx := newTypeIndex(req.GetProtoFile())
+	docs := protodoc.New(req.GetProtoFile())
+	genFileNames := make(map[string]bool)
+	for _, n := range req.FileToGenerate {
+		genFileNames[n] = true
+	}
+	has := envMessages(idx, genFileNames)
+	for _, desc := range req.GetProtoFile() {
+		name := desc.GetName()
+		if _, ok := genFileNames[name]; !ok {
+			// Only emit output for files present in req.FileToGenerate.
+			continue
+		}
+		code, err := genCode(desc, idx, docs, has)
+		if err != nil {
+			return nil, err
+		}
+		if code == "" {
+			// The file declares no configuration messages, nor messages
+			// they hold.
+			continue
+		}
+		formatted, err := format.Source([]byte(code))
+		if err != nil {
+			log.Printf("%v: %s", err, code)
+			return nil, err
+		}
+
+		ext := filepath.Ext(name)
+		base := strings.TrimSuffix(name, ext)
+		output := fmt.Sprintf("%s.pb.env.go", base)
+		files = append(files, &plugin.CodeGeneratorResponse_File{
+			Name:    proto.String(output),
+			Content: proto.String(string(formatted)),
+		})
+	}
+
+	return files, nil
+}
+
+func genCode(desc *descriptor.FileDescriptorProto, idx *typeIndex, docs *protodoc.Index, has map[string]bool) (string, error) {
+	g := &envGen{
+		idx:     idx,
+		docs:    docs,
+		proto3:  desc.GetSyntax() == "proto3",
+		has:     has,
+		imports: newImportSet(desc),
+	}
+	body := bytes.NewBuffer(nil)
+	if err := g.messages(body, strings.TrimSuffix("."+desc.GetPackage(), "."), "", desc.GetMessageType()); err != nil {
+		return "", err
+	}
+	if body.Len() == 0 {
+		return "", nil
+	}
+
+	g.imports.names["github.com/f4tq/protoc-go-plugins/runtime/envload"] = "envload"
+	if g.os {
+		g.imports.names["os"] = "os"
+	}
+	if g.scalarparse {
+		g.imports.names["github.com/f4tq/protoc-go-plugins/runtime/scalarparse"] = "scalarparse"
+	}
+	hdr := &header{
+		Source:  desc.GetName(),
+		GoPkg:   defaultGoPackageName(desc),
+		Imports: g.imports.names,
+	}
+	w := bytes.NewBuffer(nil)
+	if err := hdrTmpl.Execute(w, hdr); err != nil {
+		log.Fatal(err)
+	}
+	body.WriteTo(w)
+
+	return w.String(), nil
+}
+
+type envGen struct {
+	idx    *typeIndex
+	docs   *protodoc.Index
+	proto3 bool
+	// has holds the messages with a LoadEnv method.
+	has     map[string]bool
+	imports *importSet
+	// os and scalarparse record that the code uses those packages.
+	os          bool
+	scalarparse bool
+}
+
+// messages writes the LoadEnv methods of msgs and of the messages nested
+// in them, and the Load<Message>FromEnv functions of the configuration
+// messages. scope is the full proto name of their parent and prefix the Go
+// name of the enclosing message plus "_".
+func (g *envGen) messages(w *bytes.Buffer, scope, prefix string, msgs []*descriptor.DescriptorProto) error {
+	for _, msg := range msgs {
+		if msg.GetOptions().GetMapEntry() {
+			continue
+		}
+		typeName := scope + "." + msg.GetName()
+		name := prefix + msg.GetName()
+		if g.has[typeName] {
+			m := &envMessage{Name: name, Config: options.Config(msg), Doc: g.docs.Godoc(typeName)}
+			if m.Config {
+				g.os = true
+			}
+			goNames := goname.Fields(msg)
+			for _, field := range msg.GetField() {
+				stmt, err := g.field(field, goNames[field.GetName()])
+				if err != nil {
+					return fmt.Errorf("%s.%s: %v", name, field.GetName(), err)
+				}
+				if stmt != "" {
+					m.Fields = append(m.Fields, stmt)
+				}
+			}
+			if err := envTmpl.Execute(w, m); err != nil {
+				return err
+			}
+		}
+		if err := g.messages(w, typeName, name+"_", msg.GetNestedType()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// envMessages returns the generated configuration messages and the
+// generated messages of their singular message fields.
+func envMessages(idx *typeIndex, genFileNames map[string]bool) map[string]bool {
+	has := make(map[string]bool)
+	for typeName, msg := range idx.messages {
+		if genFileNames[idx.files[typeName].GetName()] && options.Config(msg) {
+			has[typeName] = true
+		}
+	}
+	// Repeat until no message is added, following the fields of the
+	// messages added last.
+	for changed := true; changed; {
+		changed = false
+		for typeName := range has {
+			for _, field := range idx.messages[typeName].GetField() {
+				t := field.GetTypeName()
+				if field.GetType() != descriptor.FieldDescriptorProto_TYPE_MESSAGE || has[t] || t == durationType ||
+					field.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REPEATED ||
+					(field.OneofIndex != nil && !field.GetProto3Optional()) ||
+					!genFileNames[idx.files[t].GetName()] {
+					continue
+				}
+				has[t] = true
+				changed = true
+			}
+		}
+	}
+	return has
+}
+
+// field returns the statements reading field, whose Go name is goName, from
+// its variable, or "" if it is not read.
+func (g *envGen) field(field *descriptor.FieldDescriptorProto, goName string) (string, error) {
+	if field.OneofIndex != nil && !field.GetProto3Optional() {
+		return "", nil
+	}
+	varName := strings.ToUpper(field.GetName())
+	name := fmt.Sprintf("prefix+%q", varName)
+	if g.idx.isMap(field) {
+		entry := g.idx.messages[field.GetTypeName()]
+		if entry.GetField()[0].GetType() != descriptor.FieldDescriptorProto_TYPE_STRING ||
+			entry.GetField()[1].GetType() != descriptor.FieldDescriptorProto_TYPE_STRING {
+			return "", nil
+		}
+		return fmt.Sprintf("envload.Map(l, %s, &m.%s)", name, goName), nil
+	}
+	if field.GetType() == descriptor.FieldDescriptorProto_TYPE_MESSAGE && g.has[field.GetTypeName()] &&
+		field.GetLabel() != descriptor.FieldDescriptorProto_LABEL_REPEATED {
+		nested := fmt.Sprintf("prefix+%q", varName+"__")
+		return fmt.Sprintf("if l.Any(%s) {\nif m.%s == nil {\nm.%s = new(%s)\n}\nm.%s.LoadEnv(l, %s)\n}",
+			nested, goName, goName, g.imports.goTypeName(g.idx, field.GetTypeName()), goName, nested), nil
+	}
+	parse := g.parseFunc(field)
+	if parse == "" {
+		return "", nil
+	}
+	g.scalarparse = true
+	switch {
+	case field.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REPEATED:
+		return fmt.Sprintf("envload.List(l, %s, &m.%s, %s)", name, goName, parse), nil
+	case field.GetType() != descriptor.FieldDescriptorProto_TYPE_MESSAGE &&
+		field.GetType() != descriptor.FieldDescriptorProto_TYPE_BYTES &&
+		(field.GetProto3Optional() || !g.proto3):
+		// Fields with presence stay nil unless their variable is set.
+		return fmt.Sprintf("envload.Optional(l, %s, &m.%s, %s)", name, goName, parse), nil
+	}
+	return fmt.Sprintf("envload.Value(l, %s, &m.%s, %s)", name, goName, parse), nil
+}
+
+// parseFunc returns the scalarparse function parsing the elements of
+// field, or "" if they have none.
+func (g *envGen) parseFunc(field *descriptor.FieldDescriptorProto) string {
+	switch field.GetType() {
+	case descriptor.FieldDescriptorProto_TYPE_MESSAGE:
+		if field.GetTypeName() == durationType {
+			return "scalarparse.Duration"
+		}
+		return ""
+	case descriptor.FieldDescriptorProto_TYPE_GROUP:
+		return ""
+	case descriptor.FieldDescriptorProto_TYPE_ENUM:
+		typ := g.imports.goTypeName(g.idx, field.GetTypeName())
+		return fmt.Sprintf("scalarparse.Enum[%s](%s_value)", typ, typ)
+	}
+	return "scalarparse." + parseFuncs[scalarGoType(field.GetType())]
+}
+
+// scalarGoType returns the Go type of the scalar proto type t, or "".
+func scalarGoType(t descriptor.FieldDescriptorProto_Type) string {
+	switch t {
+	case descriptor.FieldDescriptorProto_TYPE_DOUBLE:
+		return "float64"
+	case descriptor.FieldDescriptorProto_TYPE_FLOAT:
+		return "float32"
+	case descriptor.FieldDescriptorProto_TYPE_INT64,
+		descriptor.FieldDescriptorProto_TYPE_SINT64,
+		descriptor.FieldDescriptorProto_TYPE_SFIXED64:
+		return "int64"
+	case descriptor.FieldDescriptorProto_TYPE_UINT64,
+		descriptor.FieldDescriptorProto_TYPE_FIXED64:
+		return "uint64"
+	case descriptor.FieldDescriptorProto_TYPE_INT32,
+		descriptor.FieldDescriptorProto_TYPE_SINT32,
+		descriptor.FieldDescriptorProto_TYPE_SFIXED32:
+		return "int32"
+	case descriptor.FieldDescriptorProto_TYPE_UINT32,
+		descriptor.FieldDescriptorProto_TYPE_FIXED32:
+		return "uint32"
+	case descriptor.FieldDescriptorProto_TYPE_BOOL:
+		return "bool"
+	case descriptor.FieldDescriptorProto_TYPE_STRING:
+		return "string"
+	case descriptor.FieldDescriptorProto_TYPE_BYTES:
+		return "[]byte"
+	}
+	return ""
+}
+
+type header struct {
+	Source  string
+	GoPkg   string
+	Imports map[string]string
+}
+
+type envMessage struct {
+	Name   string
+	Config bool
+	Fields []string
+	// Doc is the comment of the message, see protodoc.Index.Godoc.
+	Doc string
+}
+
+// typeIndex maps fully-qualified proto type names (".pkg.Msg") to their
+// descriptors and declaring files for every file in the request.
+type typeIndex struct {
+	messages map[string]*descriptor.DescriptorProto
+	enums    map[string]*descriptor.EnumDescriptorProto
+	files    map[string]*descriptor.FileDescriptorProto
+}
+
+func newTypeIndex(files []*descriptor.FileDescriptorProto) *typeIndex {
+	idx := &typeIndex{
+		messages: make(map[string]*descriptor.DescriptorProto),
+		enums:    make(map[string]*descriptor.EnumDescriptorProto),
+		files:    make(map[string]*descriptor.FileDescriptorProto),
+	}
+	for _, f := range files {
+		prefix := ""
+		if f.GetPackage() != "" {
+			prefix = "." + f.GetPackage()
+		}
+		idx.addMessages(f, prefix, f.GetMessageType())
+		idx.addEnums(f, prefix, f.GetEnumType())
+	}
+	return idx
+}
+
+func (idx *typeIndex) addMessages(f *descriptor.FileDescriptorProto, prefix string, msgs []*descriptor.DescriptorProto) {
+	for _, m := range msgs {
+		name := prefix + "." + m.GetName()
+		idx.messages[name] = m
+		idx.files[name] = f
+		idx.addMessages(f, name, m.GetNestedType())
+		idx.addEnums(f, name, m.GetEnumType())
+	}
+}
+
+func (idx *typeIndex) addEnums(f *descriptor.FileDescriptorProto, prefix string, enums []*descriptor.EnumDescriptorProto) {
+	for _, e := range enums {
+		name := prefix + "." + e.GetName()
+		idx.enums[name] = e
+		idx.files[name] = f
+	}
+}
+
+// isMap reports whether field is a map field.
+func (idx *typeIndex) isMap(field *descriptor.FieldDescriptorProto) bool {
+	if field.GetType() != descriptor.FieldDescriptorProto_TYPE_MESSAGE {
+		return false
+	}
+	return idx.messages[field.GetTypeName()].GetOptions().GetMapEntry()
+}
+
+// goImportPath returns the import path of the Go package generated from f,
+// or "" if go_package does not name one.
+func goImportPath(f *descriptor.FileDescriptorProto) string {
+	gopkg := f.GetOptions().GetGoPackage()
+	if sc := strings.IndexByte(gopkg, ';'); sc >= 0 {
+		gopkg = gopkg[:sc]
+	}
+	if !strings.Contains(gopkg, "/") {
+		return ""
+	}
+	return gopkg
+}
+
+// importSet records the Go packages referenced by the code generated for
+// one file, keyed by import path.
+type importSet struct {
+	self  string
+	names map[string]string
+}
+
+func newImportSet(f *descriptor.FileDescriptorProto) *importSet {
+	return &importSet{self: goImportPath(f), names: make(map[string]string)}
+}
+
+// goTypeName returns the Go name of the message or enum typeName, qualified
+// with its package if it is declared outside the generated package.
+func (s *importSet) goTypeName(idx *typeIndex, typeName string) string {
+	name := localTypeName(typeName)
+	f := idx.files[typeName]
+	path := goImportPath(f)
+	if f == nil || path == "" || path == s.self {
+		return name
+	}
+	pkg := defaultGoPackageName(f)
+	s.names[path] = pkg
+	return pkg + "." + name
+}
+
+// localTypeName returns the Go name of a type declared in the file being
+// generated, e.g. ".pkg.Outer.Inner" becomes "Outer_Inner".
+func localTypeName(typeName string) string {
+	parts := strings.Split(strings.TrimPrefix(typeName, "."), ".")
+	var names []string
+	for _, p := range parts {
+		if len(p) > 0 && p[0] >= 'A' && p[0] <= 'Z' {
+			names = append(names, p)
+		}
+	}
+	return strings.Join(names, "_")
+}
+
+// sanitizePackageName replaces unallowed character in package name
+// with allowed character.
+func sanitizePackageName(pkgName string) string {
+	pkgName = strings.Replace(pkgName, ".", "_", -1)
+	pkgName = strings.Replace(pkgName, "-", "_", -1)
+	return pkgName
+}
+
+// defaultGoPackageName returns the default go package name to be used for go files generated from "f".
+// You might need to use an unique alias for the package when you import it.  Use ReserveGoPackageAlias to get a unique alias.
+func defaultGoPackageName(f *descriptor.FileDescriptorProto) string {
+	name := packageIdentityName(f)
+	return sanitizePackageName(name)
+}
+
+// packageIdentityName returns the identity of packages.
+// protoc-gen-grpc-gateway rejects CodeGenerationRequests which contains more than one packages
+// as protoc-gen-go does.
+func packageIdentityName(f *descriptor.FileDescriptorProto) string {
+	if f.Options != nil && f.Options.GoPackage != nil {
+		gopkg := f.Options.GetGoPackage()
+		idx := strings.LastIndex(gopkg, "/")
+		if idx < 0 {
+			gopkg = gopkg[idx+1:]
+		}
+
+		gopkg = gopkg[idx+1:]
+		// package name is overrided with the string after the
+		// ';' character
+		sc := strings.IndexByte(gopkg, ';')
+		if sc < 0 {
+			return sanitizePackageName(gopkg)
+
+		}
+		return sanitizePackageName(gopkg[sc+1:])
+	}
+
+	if f.Package == nil {
+		base := filepath.Base(f.GetName())
+		ext := filepath.Ext(base)
+		return strings.TrimSuffix(base, ext)
+	}
+	return f.GetPackage()
+}
+
+func emitFiles(out []*plugin.CodeGeneratorResponse_File) {
+	emitResp(&plugin.CodeGeneratorResponse{File: out})
+}
+
+func emitError(err error) {
+	emitResp(&plugin.CodeGeneratorResponse{Error: proto.String(err.Error())})
+}
+
+func emitResp(resp *plugin.CodeGeneratorResponse) {
+	buf, err := proto.Marshal(resp)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if _, err := os.Stdout.Write(buf); err != nil {
+		log.Fatal(err)
+	}
+}
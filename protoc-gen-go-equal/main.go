@@ -0,0 +1,466 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+
+	"github.com/f4tq/protoc-go-plugins/goname"
+)
+
+var (
+	hdrTmpl = template.Must(template.New("header").Parse(`
+// Code generated by protoc-gen-go-equal. DO NOT EDIT.
+// source: {{.Source}}
+
+package {{.GoPkg}}
+{{- if or .Std .Runtime .Imports}}
+
+import (
+{{- range .Std}}
+    "{{.}}"
+{{- end}}
+{{if .Runtime}}
+    "github.com/f4tq/protoc-go-plugins/runtime/equal"
+{{- end}}
+{{- range $path, $name := .Imports}}
+    {{$name}} "{{$path}}"
+{{- end}}
+)
+{{- end}}
+`))
+
+	equalTmpl = template.Must(template.New("equal").Parse(`
+// Equal reports whether m and other are equal field by field. NaNs are
+// equal to each other; unknown fields are ignored.
+func (m *{{.Name}}) Equal(other *{{.Name}}) bool {
+    if m == nil || other == nil {
+        return m == other
+    }
+{{- range .Checks}}
+{{.}}
+{{- end}}
+    return true
+}
+`))
+)
+
+func main() {
+	input, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	req := new(plugin.CodeGeneratorRequest)
+	if err = proto.Unmarshal(input, req); err != nil {
+		log.Fatal(err)
+	}
+
+	out, err := generate(req)
+
+	if err != nil {
+		emitError(err)
+		return
+	}
+
+	emitFiles(out)
+}
+
+func generate(req *plugin.CodeGeneratorRequest) ([]*plugin.CodeGeneratorResponse_File, error) {
+	var files []*plugin.CodeGeneratorResponse_File
+	idx := newTypeIndex(req.GetProtoFile())
+	genFileNames := make(map[string]bool)
+	for _, n := range req.FileToGenerate {
+		genFileNames[n] = true
+	}
+	for _, desc := range req.GetProtoFile() {
+		name := desc.GetName()
+		if _, ok := genFileNames[name]; !ok {
+			// Only emit output for files present in req.FileToGenerate.
+			continue
+		}
+		code, err := genCode(desc, idx, genFileNames)
+		if err != nil {
+			return nil, err
+		}
+		if code == "" {
+			// The file declares no messages.
+			continue
+		}
+		formatted, err := format.Source([]byte(code))
+		if err != nil {
+			log.Printf("%v: %s", err, code)
+			return nil, err
+		}
+
+		ext := filepath.Ext(name)
+		base := strings.TrimSuffix(name, ext)
+		output := fmt.Sprintf("%s.pb.equal.go", base)
+		files = append(files, &plugin.CodeGeneratorResponse_File{
+			Name:    proto.String(output),
+			Content: proto.String(string(formatted)),
+		})
+	}
+
+	return files, nil
+}
+
+func genCode(desc *descriptor.FileDescriptorProto, idx *typeIndex, genFileNames map[string]bool) (string, error) {
+	w := bytes.NewBuffer(nil)
+	g := &eqGen{
+		idx:     idx,
+		imports: newImportSet(desc),
+		gen:     genFileNames,
+		proto3:  desc.GetSyntax() == "proto3",
+		std:     make(map[string]bool),
+	}
+	body := bytes.NewBuffer(nil)
+	if err := g.messages(body, "", desc.GetMessageType()); err != nil {
+		return "", err
+	}
+	if body.Len() == 0 {
+		return "", nil
+	}
+
+	hdr := &header{
+		Source:  desc.GetName(),
+		GoPkg:   defaultGoPackageName(desc),
+		Runtime: g.runtime,
+		Imports: g.imports.names,
+	}
+	for path := range g.std {
+		hdr.Std = append(hdr.Std, path)
+	}
+	sort.Strings(hdr.Std)
+	if err := hdrTmpl.Execute(w, hdr); err != nil {
+		log.Fatal(err)
+	}
+	body.WriteTo(w)
+
+	return w.String(), nil
+}
+
+type eqGen struct {
+	idx     *typeIndex
+	imports *importSet
+	gen     map[string]bool
+	proto3  bool
+	// std and runtime record the packages the comparisons use.
+	std     map[string]bool
+	runtime bool
+}
+
+// messages writes the Equal methods of msgs and of the messages nested in
+// them. prefix is the Go name of the enclosing message plus "_".
+func (g *eqGen) messages(w *bytes.Buffer, prefix string, msgs []*descriptor.DescriptorProto) error {
+	for _, msg := range msgs {
+		if msg.GetOptions().GetMapEntry() {
+			continue
+		}
+		name := prefix + msg.GetName()
+		m := &eqMessage{Name: name}
+		goNames := goname.Fields(msg)
+		done := make(map[int32]bool)
+		for _, field := range msg.GetField() {
+			if field.OneofIndex != nil && !field.GetProto3Optional() {
+				i := field.GetOneofIndex()
+				if !done[i] {
+					done[i] = true
+					m.Checks = append(m.Checks, g.oneof(name, msg, goNames, i))
+				}
+				continue
+			}
+			m.Checks = append(m.Checks, fmt.Sprintf("if %s {\nreturn false\n}", g.differs(field, goNames[field.GetName()])))
+		}
+		if err := equalTmpl.Execute(w, m); err != nil {
+			return err
+		}
+		if err := g.messages(w, name+"_", msg.GetNestedType()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// differs returns a condition holding when field, of Go name goName,
+// differs between m and other.
+func (g *eqGen) differs(field *descriptor.FieldDescriptorProto, goName string) string {
+	a, b := "m."+goName, "other."+goName
+	switch {
+	case g.idx.isMap(field):
+		value := g.idx.messages[field.GetTypeName()].GetField()[1]
+		if eq := g.equalFunc(value); eq != "" {
+			g.std["maps"] = true
+			return fmt.Sprintf("!maps.EqualFunc(%s, %s, %s)", a, b, eq)
+		}
+		g.std["maps"] = true
+		return fmt.Sprintf("!maps.Equal(%s, %s)", a, b)
+	case field.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REPEATED:
+		g.std["slices"] = true
+		if eq := g.equalFunc(field); eq != "" {
+			return fmt.Sprintf("!slices.EqualFunc(%s, %s, %s)", a, b, eq)
+		}
+		return fmt.Sprintf("!slices.Equal(%s, %s)", a, b)
+	case field.GetType() == descriptor.FieldDescriptorProto_TYPE_MESSAGE:
+		return g.notEqual(field, a, b)
+	case field.GetType() == descriptor.FieldDescriptorProto_TYPE_BYTES:
+		g.std["bytes"] = true
+		if field.GetProto3Optional() || !g.proto3 {
+			// A nil slice is unset, an empty one set to no bytes.
+			return fmt.Sprintf("(%s == nil) != (%s == nil) || !bytes.Equal(%s, %s)", a, b, a, b)
+		}
+		return fmt.Sprintf("!bytes.Equal(%s, %s)", a, b)
+	case field.GetProto3Optional() || !g.proto3:
+		// Scalars are pointers; compare presence, then values.
+		getA, getB := "m.Get"+goName+"()", "other.Get"+goName+"()"
+		return fmt.Sprintf("(%s == nil) != (%s == nil) || %s", a, b, g.notEqual(field, getA, getB))
+	}
+	return g.notEqual(field, a, b)
+}
+
+// notEqual returns an expression reporting whether the values a and b of
+// field differ.
+func (g *eqGen) notEqual(field *descriptor.FieldDescriptorProto, a, b string) string {
+	eq := g.equalFunc(field)
+	switch {
+	case eq == "":
+		return fmt.Sprintf("%s != %s", a, b)
+	case strings.HasPrefix(eq, "("):
+		// A method expression: call the method instead.
+		return fmt.Sprintf("!%s.Equal(%s)", a, b)
+	}
+	return fmt.Sprintf("!%s(%s, %s)", eq, a, b)
+}
+
+// equalFunc returns the function comparing two values of field, or "" if
+// they compare with ==.
+func (g *eqGen) equalFunc(field *descriptor.FieldDescriptorProto) string {
+	switch field.GetType() {
+	case descriptor.FieldDescriptorProto_TYPE_DOUBLE:
+		g.runtime = true
+		return "equal.Float64"
+	case descriptor.FieldDescriptorProto_TYPE_FLOAT:
+		g.runtime = true
+		return "equal.Float32"
+	case descriptor.FieldDescriptorProto_TYPE_BYTES:
+		g.std["bytes"] = true
+		return "bytes.Equal"
+	case descriptor.FieldDescriptorProto_TYPE_MESSAGE, descriptor.FieldDescriptorProto_TYPE_GROUP:
+		typ := g.imports.goTypeName(g.idx, field.GetTypeName())
+		if g.gen[g.idx.files[field.GetTypeName()].GetName()] {
+			return fmt.Sprintf("(*%s).Equal", typ)
+		}
+		g.runtime = true
+		return fmt.Sprintf("equal.Proto[*%s]", typ)
+	}
+	return ""
+}
+
+// oneof returns the comparison of the oneof i of msg, whose fields and
+// oneofs have the Go names goNames.
+func (g *eqGen) oneof(msgName string, msg *descriptor.DescriptorProto, goNames map[string]string, i int32) string {
+	oneof := goNames[msg.GetOneofDecl()[i].GetName()]
+	var b strings.Builder
+	fmt.Fprintf(&b, "switch a := m.%s.(type) {\n", oneof)
+	fmt.Fprintf(&b, "case nil:\nif other.%s != nil {\nreturn false\n}\n", oneof)
+	for _, field := range msg.GetField() {
+		if field.OneofIndex == nil || field.GetOneofIndex() != i || field.GetProto3Optional() {
+			continue
+		}
+		goName := goNames[field.GetName()]
+		fmt.Fprintf(&b, "case *%s_%s:\n", msgName, goName)
+		fmt.Fprintf(&b, "b, ok := other.%s.(*%s_%s)\n", oneof, msgName, goName)
+		fmt.Fprintf(&b, "if !ok || %s {\nreturn false\n}\n", g.notEqual(field, "a."+goName, "b."+goName))
+	}
+	b.WriteString("}")
+	return b.String()
+}
+
+type header struct {
+	Source  string
+	GoPkg   string
+	Std     []string
+	Runtime bool
+	Imports map[string]string
+}
+
+type eqMessage struct {
+	Name   string
+	Checks []string
+}
+
+// typeIndex maps fully-qualified proto type names (".pkg.Msg") to their
+// descriptors and declaring files for every file in the request.
+type typeIndex struct {
+	messages map[string]*descriptor.DescriptorProto
+	enums    map[string]*descriptor.EnumDescriptorProto
+	files    map[string]*descriptor.FileDescriptorProto
+}
+
+func newTypeIndex(files []*descriptor.FileDescriptorProto) *typeIndex {
+	idx := &typeIndex{
+		messages: make(map[string]*descriptor.DescriptorProto),
+		enums:    make(map[string]*descriptor.EnumDescriptorProto),
+		files:    make(map[string]*descriptor.FileDescriptorProto),
+	}
+	for _, f := range files {
+		prefix := ""
+		if f.GetPackage() != "" {
+			prefix = "." + f.GetPackage()
+		}
+		idx.addMessages(f, prefix, f.GetMessageType())
+		idx.addEnums(f, prefix, f.GetEnumType())
+	}
+	return idx
+}
+
+func (idx *typeIndex) addMessages(f *descriptor.FileDescriptorProto, prefix string, msgs []*descriptor.DescriptorProto) {
+	for _, m := range msgs {
+		name := prefix + "." + m.GetName()
+		idx.messages[name] = m
+		idx.files[name] = f
+		idx.addMessages(f, name, m.GetNestedType())
+		idx.addEnums(f, name, m.GetEnumType())
+	}
+}
+
+func (idx *typeIndex) addEnums(f *descriptor.FileDescriptorProto, prefix string, enums []*descriptor.EnumDescriptorProto) {
+	for _, e := range enums {
+		name := prefix + "." + e.GetName()
+		idx.enums[name] = e
+		idx.files[name] = f
+	}
+}
+
+// isMap reports whether field is a map field.
+func (idx *typeIndex) isMap(field *descriptor.FieldDescriptorProto) bool {
+	if field.GetType() != descriptor.FieldDescriptorProto_TYPE_MESSAGE {
+		return false
+	}
+	return idx.messages[field.GetTypeName()].GetOptions().GetMapEntry()
+}
+
+// goImportPath returns the import path of the Go package generated from f,
+// or "" if go_package does not name one.
+func goImportPath(f *descriptor.FileDescriptorProto) string {
+	gopkg := f.GetOptions().GetGoPackage()
+	if sc := strings.IndexByte(gopkg, ';'); sc >= 0 {
+		gopkg = gopkg[:sc]
+	}
+	if !strings.Contains(gopkg, "/") {
+		return ""
+	}
+	return gopkg
+}
+
+// importSet records the Go packages referenced by the code generated for
+// one file, keyed by import path.
+type importSet struct {
+	self  string
+	names map[string]string
+}
+
+func newImportSet(f *descriptor.FileDescriptorProto) *importSet {
+	return &importSet{self: goImportPath(f), names: make(map[string]string)}
+}
+
+// goTypeName returns the Go name of the message or enum typeName, qualified
+// with its package if it is declared outside the generated package.
+func (s *importSet) goTypeName(idx *typeIndex, typeName string) string {
+	name := localTypeName(typeName)
+	f := idx.files[typeName]
+	path := goImportPath(f)
+	if f == nil || path == "" || path == s.self {
+		return name
+	}
+	pkg := defaultGoPackageName(f)
+	s.names[path] = pkg
+	return pkg + "." + name
+}
+
+// localTypeName returns the Go name of a type declared in the file being
+// generated, e.g. ".pkg.Outer.Inner" becomes "Outer_Inner".
+func localTypeName(typeName string) string {
+	parts := strings.Split(strings.TrimPrefix(typeName, "."), ".")
+	var names []string
+	for _, p := range parts {
+		if len(p) > 0 && p[0] >= 'A' && p[0] <= 'Z' {
+			names = append(names, p)
+		}
+	}
+	return strings.Join(names, "_")
+}
+
+// sanitizePackageName replaces unallowed character in package name
+// with allowed character.
+func sanitizePackageName(pkgName string) string {
+	pkgName = strings.Replace(pkgName, ".", "_", -1)
+	pkgName = strings.Replace(pkgName, "-", "_", -1)
+	return pkgName
+}
+
+// defaultGoPackageName returns the default go package name to be used for go files generated from "f".
+// You might need to use an unique alias for the package when you import it.  Use ReserveGoPackageAlias to get a unique alias.
+func defaultGoPackageName(f *descriptor.FileDescriptorProto) string {
+	name := packageIdentityName(f)
+	return sanitizePackageName(name)
+}
+
+// packageIdentityName returns the identity of packages.
+// protoc-gen-grpc-gateway rejects CodeGenerationRequests which contains more than one packages
+// as protoc-gen-go does.
+func packageIdentityName(f *descriptor.FileDescriptorProto) string {
+	if f.Options != nil && f.Options.GoPackage != nil {
+		gopkg := f.Options.GetGoPackage()
+		idx := strings.LastIndex(gopkg, "/")
+		if idx < 0 {
+			gopkg = gopkg[idx+1:]
+		}
+
+		gopkg = gopkg[idx+1:]
+		// package name is overrided with the string after the
+		// ';' character
+		sc := strings.IndexByte(gopkg, ';')
+		if sc < 0 {
+			return sanitizePackageName(gopkg)
+
+		}
+		return sanitizePackageName(gopkg[sc+1:])
+	}
+
+	if f.Package == nil {
+		base := filepath.Base(f.GetName())
+		ext := filepath.Ext(base)
+		return strings.TrimSuffix(base, ext)
+	}
+	return f.GetPackage()
+}
+
+func emitFiles(out []*plugin.CodeGeneratorResponse_File) {
+	emitResp(&plugin.CodeGeneratorResponse{File: out})
+}
+
+func emitError(err error) {
+	emitResp(&plugin.CodeGeneratorResponse{Error: proto.String(err.Error())})
+}
+
+func emitResp(resp *plugin.CodeGeneratorResponse) {
+	buf, err := proto.Marshal(resp)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if _, err := os.Stdout.Write(buf); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/f4tq/protoc-go-plugins/internal/plugintest"
+)
+
+// conflictsTest runs in the package generated for plugintest.Conflicts.
+const conflictsTest = `package conflictsv1
+
+import "testing"
+
+func TestEqual(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b *Conflicts
+		want bool
+	}{
+		{"empty", &Conflicts{}, &Conflicts{}, true},
+		{"renamed field", &Conflicts{String_: "a"}, &Conflicts{String_: "b"}, false},
+		{"oneof", &Conflicts{Value: &Conflicts_GetString{GetString: 1}}, &Conflicts{Value: &Conflicts_ExtensionRangeArray_{}}, false},
+		{"optional bytes set empty", &Conflicts{Marshal_: []byte{}}, &Conflicts{}, false},
+		{"optional bytes both empty", &Conflicts{Marshal_: []byte{}}, &Conflicts{Marshal_: []byte{}}, true},
+	}
+	for _, test := range tests {
+		if got := test.a.Equal(test.b); got != test.want {
+			t.Errorf("%s: Equal = %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+`
+
+// TestConflicts runs the Equal methods of a message whose fields
+// protoc-gen-go renames, one of them optional bytes.
+func TestConflicts(t *testing.T) {
+	pkg := plugintest.Package(t, plugintest.ConflictsRequest(t, ""), generate)
+	plugintest.WriteFile(t, pkg, "equal_test.go", conflictsTest)
+	plugintest.Go(t, "test", pkg)
+}
@@ -0,0 +1,485 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+
+	"github.com/f4tq/protoc-go-plugins/options"
+)
+
+var (
+	mermaidTmpl = template.Must(template.New("mermaid").Funcs(template.FuncMap{
+		"generic": func(s string) string {
+			return strings.NewReplacer("<", "~", ">", "~").Replace(s)
+		},
+	}).Parse(`classDiagram
+{{- range .Packages}}
+{{- range .Classes}}
+    class {{.ID}}["{{.Name}}"] {
+{{- if .Stereotype}}
+        <<{{.Stereotype}}>>
+{{- end}}
+{{- range .Members}}
+{{- if .Method}}
+        +{{.Name}}({{.Params}}) {{.Type}}
+{{- else if .Type}}
+        +{{generic .Type}} {{.Name}}
+{{- else}}
+        {{.Name}}
+{{- end}}
+{{- end}}
+    }
+{{- end}}
+{{- end}}
+{{- range .Edges}}
+    {{.From}} {{if .Many}}"1" {{end}}{{.Arrow}}{{if .Many}} "*"{{end}} {{.To}} : {{.Label}}
+{{- end}}
+`))
+
+	plantumlTmpl = template.Must(template.New("plantuml").Parse(`@startuml
+hide empty members
+{{- range .Packages}}
+{{- if .Name}}
+package "{{.Name}}" {
+{{- end}}
+{{- range .Classes}}
+{{- if eq .Stereotype "enumeration"}}
+enum "{{.Name}}" as {{.ID}} {
+{{- else}}
+class "{{.Name}}" as {{.ID}}{{if .Stereotype}} <<{{.Stereotype}}>>{{end}} {
+{{- end}}
+{{- range .Members}}
+{{- if .Method}}
+    +{{.Name}}({{.Params}}) : {{.Type}}
+{{- else if .Type}}
+    +{{.Name}} : {{.Type}}
+{{- else}}
+    {{.Name}}
+{{- end}}
+{{- end}}
+}
+{{- end}}
+{{- if .Name}}
+}
+{{- end}}
+{{- end}}
+{{- range .Edges}}
+{{.From}} {{if .Many}}"1" {{end}}{{.Arrow}}{{if .Many}} "*"{{end}} {{.To}} : {{.Label}}
+{{- end}}
+@enduml
+`))
+)
+
+func main() {
+	input, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	req := new(plugin.CodeGeneratorRequest)
+	if err = proto.Unmarshal(input, req); err != nil {
+		log.Fatal(err)
+	}
+
+	out, err := generate(req)
+
+	if err != nil {
+		emitError(err)
+		return
+	}
+
+	emitFiles(out)
+}
+
+// generate writes one diagram of all the files to generate: their
+// messages and the messages and enums they are composed of, their enums,
+// and their services with the messages their methods exchange and their
+// (f4tq.plugins.health_dependencies). The format parameter selects
+// "mermaid", the default, "plantuml" or both, separated by "+", written to
+// <name>.mmd and <name>.puml; name is the parameter of that name, "schema"
+// by default.
+func generate(req *plugin.CodeGeneratorRequest) ([]*plugin.CodeGeneratorResponse_File, error) {
+	params := parseParams(req.GetParameter())
+	name := params["name"]
+	if name == "" {
+		name = "schema"
+	}
+	format := params["format"]
+	if format == "" {
+		format = "mermaid"
+	}
+	g := &erdGen{
+		idx:       newTypeIndex(req.GetProtoFile()),
+		diagram:   new(diagram),
+		packages:  make(map[string]*erdPackage),
+		inDiagram: make(map[string]bool),
+		deps:      make(map[string]bool),
+	}
+	var descs []*descriptor.FileDescriptorProto
+	genFileNames := make(map[string]bool)
+	for _, n := range req.FileToGenerate {
+		genFileNames[n] = true
+	}
+	for _, desc := range req.GetProtoFile() {
+		if _, ok := genFileNames[desc.GetName()]; !ok {
+			// Only draw the files present in req.FileToGenerate.
+			continue
+		}
+		descs = append(descs, desc)
+		for typeName, f := range g.idx.files {
+			if f == desc {
+				g.inDiagram[typeName] = true
+			}
+		}
+	}
+	if len(descs) == 0 {
+		return nil, nil
+	}
+	for _, desc := range descs {
+		g.file(desc)
+	}
+
+	var files []*plugin.CodeGeneratorResponse_File
+	for _, f := range strings.Split(format, "+") {
+		var tmpl *template.Template
+		var ext string
+		switch f {
+		case "mermaid":
+			tmpl, ext = mermaidTmpl, ".mmd"
+		case "plantuml":
+			tmpl, ext = plantumlTmpl, ".puml"
+		default:
+			return nil, fmt.Errorf("unknown format %q, want mermaid or plantuml", f)
+		}
+		w := bytes.NewBuffer(nil)
+		if err := tmpl.Execute(w, g.diagram); err != nil {
+			return nil, err
+		}
+		files = append(files, &plugin.CodeGeneratorResponse_File{
+			Name:    proto.String(name + ext),
+			Content: proto.String(w.String()),
+		})
+	}
+	return files, nil
+}
+
+type erdGen struct {
+	idx     *typeIndex
+	diagram *diagram
+	// packages maps the proto packages to their part of the diagram.
+	packages map[string]*erdPackage
+	// inDiagram holds the types drawn as classes.
+	inDiagram map[string]bool
+	// deps holds the dependencies drawn.
+	deps map[string]bool
+}
+
+func (g *erdGen) file(desc *descriptor.FileDescriptorProto) {
+	p := g.packages[desc.GetPackage()]
+	if p == nil {
+		p = &erdPackage{Name: desc.GetPackage()}
+		g.packages[desc.GetPackage()] = p
+		g.diagram.Packages = append(g.diagram.Packages, p)
+	}
+	scope := strings.TrimSuffix("."+desc.GetPackage(), ".")
+	g.messages(p, scope, desc.GetMessageType())
+	g.enums(p, scope, desc.GetEnumType())
+	for _, svc := range desc.GetService() {
+		g.service(p, scope, svc)
+	}
+}
+
+// messages draws msgs, and the messages and enums nested in them, in p.
+// scope is the full proto name of their parent.
+func (g *erdGen) messages(p *erdPackage, scope string, msgs []*descriptor.DescriptorProto) {
+	for _, msg := range msgs {
+		if msg.GetOptions().GetMapEntry() {
+			continue
+		}
+		typeName := scope + "." + msg.GetName()
+		c := &class{ID: classID(typeName), Name: g.displayName(p.Name, typeName)}
+		for _, field := range msg.GetField() {
+			c.Members = append(c.Members, &member{Name: field.GetName(), Type: g.fieldType(p.Name, field)})
+			target, many := field.GetTypeName(), field.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REPEATED
+			if g.idx.isMap(field) {
+				target = g.idx.messages[target].GetField()[1].GetTypeName()
+			}
+			if !g.inDiagram[target] {
+				continue
+			}
+			e := &edge{From: c.ID, To: classID(target), Label: field.GetName(), Many: many, Arrow: "*--"}
+			if _, ok := g.idx.enums[target]; ok {
+				e.Arrow = "-->"
+			}
+			g.diagram.Edges = append(g.diagram.Edges, e)
+		}
+		p.Classes = append(p.Classes, c)
+		g.messages(p, typeName, msg.GetNestedType())
+		g.enums(p, typeName, msg.GetEnumType())
+	}
+}
+
+// enums draws enums in p, as messages does.
+func (g *erdGen) enums(p *erdPackage, scope string, enums []*descriptor.EnumDescriptorProto) {
+	for _, enum := range enums {
+		typeName := scope + "." + enum.GetName()
+		c := &class{ID: classID(typeName), Name: g.displayName(p.Name, typeName), Stereotype: "enumeration"}
+		for _, v := range enum.GetValue() {
+			c.Members = append(c.Members, &member{Name: v.GetName()})
+		}
+		p.Classes = append(p.Classes, c)
+	}
+}
+
+func (g *erdGen) service(p *erdPackage, scope string, svc *descriptor.ServiceDescriptorProto) {
+	typeName := scope + "." + svc.GetName()
+	c := &class{ID: classID(typeName), Name: svc.GetName(), Stereotype: "service"}
+	used := make(map[string]bool)
+	for _, m := range svc.GetMethod() {
+		in, out := g.displayName(p.Name, m.GetInputType()), g.displayName(p.Name, m.GetOutputType())
+		if m.GetClientStreaming() {
+			in = "stream " + in
+		}
+		if m.GetServerStreaming() {
+			out = "stream " + out
+		}
+		c.Members = append(c.Members, &member{Name: m.GetName(), Params: in, Type: out, Method: true})
+		for _, t := range []string{m.GetInputType(), m.GetOutputType()} {
+			if g.inDiagram[t] && !used[t] {
+				used[t] = true
+				g.diagram.Edges = append(g.diagram.Edges, &edge{From: c.ID, To: classID(t), Label: "uses", Arrow: "..>"})
+			}
+		}
+	}
+	p.Classes = append(p.Classes, c)
+	for _, dep := range options.HealthDependencies(svc) {
+		id := "dep_" + classID("."+dep)
+		if !g.deps[dep] {
+			g.deps[dep] = true
+			deps := g.packages[""]
+			if deps == nil {
+				deps = new(erdPackage)
+				g.packages[""] = deps
+				g.diagram.Packages = append(g.diagram.Packages, deps)
+			}
+			deps.Classes = append(deps.Classes, &class{ID: id, Name: dep, Stereotype: "dependency"})
+		}
+		g.diagram.Edges = append(g.diagram.Edges, &edge{From: c.ID, To: id, Label: "depends on", Arrow: "..>"})
+	}
+}
+
+// displayName returns the name of typeName as seen from the package pkg:
+// relative to it if typeName is declared in it, and full otherwise.
+func (g *erdGen) displayName(pkg, typeName string) string {
+	if f := g.idx.files[typeName]; f != nil && f.GetPackage() == pkg && pkg != "" {
+		return strings.TrimPrefix(typeName, "."+pkg+".")
+	}
+	return strings.TrimPrefix(typeName, ".")
+}
+
+// fieldType returns the type of field as seen from the package pkg.
+func (g *erdGen) fieldType(pkg string, field *descriptor.FieldDescriptorProto) string {
+	if g.idx.isMap(field) {
+		entry := g.idx.messages[field.GetTypeName()]
+		return fmt.Sprintf("map<%s, %s>", g.fieldType(pkg, entry.GetField()[0]), g.fieldType(pkg, entry.GetField()[1]))
+	}
+	var t string
+	switch field.GetType() {
+	case descriptor.FieldDescriptorProto_TYPE_MESSAGE, descriptor.FieldDescriptorProto_TYPE_GROUP,
+		descriptor.FieldDescriptorProto_TYPE_ENUM:
+		t = g.displayName(pkg, field.GetTypeName())
+	default:
+		t = strings.ToLower(strings.TrimPrefix(field.GetType().String(), "TYPE_"))
+	}
+	if field.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REPEATED {
+		t = "repeated " + t
+	}
+	return t
+}
+
+// classID returns the identifier of the class of typeName, valid in both
+// formats.
+func classID(typeName string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+			return r
+		}
+		return '_'
+	}, strings.TrimPrefix(typeName, "."))
+}
+
+type diagram struct {
+	Packages []*erdPackage
+	Edges    []*edge
+}
+
+// erdPackage holds the classes of a proto package, or of the dependencies
+// for "".
+type erdPackage struct {
+	Name    string
+	Classes []*class
+}
+
+type class struct {
+	ID         string
+	Name       string
+	Stereotype string
+	Members    []*member
+}
+
+// member is a field of a message, a value of an enum, with no Type, or a
+// method of a service.
+type member struct {
+	Name   string
+	Type   string
+	Params string
+	Method bool
+}
+
+type edge struct {
+	From  string
+	To    string
+	Label string
+	Arrow string
+	Many  bool
+}
+
+// typeIndex maps fully-qualified proto type names (".pkg.Msg") to their
+// descriptors and declaring files for every file in the request.
+type typeIndex struct {
+	messages map[string]*descriptor.DescriptorProto
+	enums    map[string]*descriptor.EnumDescriptorProto
+	files    map[string]*descriptor.FileDescriptorProto
+}
+
+func newTypeIndex(files []*descriptor.FileDescriptorProto) *typeIndex {
+	idx := &typeIndex{
+		messages: make(map[string]*descriptor.DescriptorProto),
+		enums:    make(map[string]*descriptor.EnumDescriptorProto),
+		files:    make(map[string]*descriptor.FileDescriptorProto),
+	}
+	for _, f := range files {
+		prefix := ""
+		if f.GetPackage() != "" {
+			prefix = "." + f.GetPackage()
+		}
+		idx.addMessages(f, prefix, f.GetMessageType())
+		idx.addEnums(f, prefix, f.GetEnumType())
+	}
+	return idx
+}
+
+func (idx *typeIndex) addMessages(f *descriptor.FileDescriptorProto, prefix string, msgs []*descriptor.DescriptorProto) {
+	for _, m := range msgs {
+		name := prefix + "." + m.GetName()
+		idx.messages[name] = m
+		idx.files[name] = f
+		idx.addMessages(f, name, m.GetNestedType())
+		idx.addEnums(f, name, m.GetEnumType())
+	}
+}
+
+func (idx *typeIndex) addEnums(f *descriptor.FileDescriptorProto, prefix string, enums []*descriptor.EnumDescriptorProto) {
+	for _, e := range enums {
+		name := prefix + "." + e.GetName()
+		idx.enums[name] = e
+		idx.files[name] = f
+	}
+}
+
+// isMap reports whether field is a map field.
+func (idx *typeIndex) isMap(field *descriptor.FieldDescriptorProto) bool {
+	if field.GetType() != descriptor.FieldDescriptorProto_TYPE_MESSAGE {
+		return false
+	}
+	return idx.messages[field.GetTypeName()].GetOptions().GetMapEntry()
+}
+
+// parseParams splits the comma separated key=value plugin parameter.
+func parseParams(param string) map[string]string {
+	params := make(map[string]string)
+	for _, p := range strings.Split(param, ",") {
+		if p == "" {
+			continue
+		}
+		if i := strings.IndexByte(p, '='); i >= 0 {
+			params[p[:i]] = p[i+1:]
+		} else {
+			params[p] = ""
+		}
+	}
+	return params
+}
+
+// sanitizePackageName replaces unallowed character in package name
+// with allowed character.
+func sanitizePackageName(pkgName string) string {
+	pkgName = strings.Replace(pkgName, ".", "_", -1)
+	pkgName = strings.Replace(pkgName, "-", "_", -1)
+	return pkgName
+}
+
+// defaultGoPackageName returns the default go package name to be used for go files generated from "f".
+// You might need to use an unique alias for the package when you import it.  Use ReserveGoPackageAlias to get a unique alias.
+func defaultGoPackageName(f *descriptor.FileDescriptorProto) string {
+	name := packageIdentityName(f)
+	return sanitizePackageName(name)
+}
+
+// packageIdentityName returns the identity of packages.
+// protoc-gen-grpc-gateway rejects CodeGenerationRequests which contains more than one packages
+// as protoc-gen-go does.
+func packageIdentityName(f *descriptor.FileDescriptorProto) string {
+	if f.Options != nil && f.Options.GoPackage != nil {
+		gopkg := f.Options.GetGoPackage()
+		idx := strings.LastIndex(gopkg, "/")
+		if idx < 0 {
+			gopkg = gopkg[idx+1:]
+		}
+
+		gopkg = gopkg[idx+1:]
+		// package name is overrided with the string after the
+		// ';' character
+		sc := strings.IndexByte(gopkg, ';')
+		if sc < 0 {
+			return sanitizePackageName(gopkg)
+
+		}
+		return sanitizePackageName(gopkg[sc+1:])
+	}
+
+	if f.Package == nil {
+		base := filepath.Base(f.GetName())
+		ext := filepath.Ext(base)
+		return strings.TrimSuffix(base, ext)
+	}
+	return f.GetPackage()
+}
+
+func emitFiles(out []*plugin.CodeGeneratorResponse_File) {
+	emitResp(&plugin.CodeGeneratorResponse{File: out})
+}
+
+func emitError(err error) {
+	emitResp(&plugin.CodeGeneratorResponse{Error: proto.String(err.Error())})
+}
+
+func emitResp(resp *plugin.CodeGeneratorResponse) {
+	buf, err := proto.Marshal(resp)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if _, err := os.Stdout.Write(buf); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,286 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+
+	"github.com/f4tq/protoc-go-plugins/options"
+	"github.com/f4tq/protoc-go-plugins/protodoc"
+)
+
+var (
+	hdrTmpl = template.Must(template.New("header").Parse(`
+// Code generated by protoc-gen-go-errordetails. DO NOT EDIT.
+// source: {{.Source}}
+
+package {{.GoPkg}}
+
+import (
+    "errors"
+
+    "github.com/f4tq/protoc-go-plugins/runtime/errdetail"
+    "github.com/golang/protobuf/proto"
+    "google.golang.org/grpc/codes"
+    "google.golang.org/grpc/status"
+)
+`))
+
+	detailTmpl = template.Must(template.New("detail").Parse(`
+// {{.Name}}Error is a gRPC status error carrying a {{.Name}} detail. Errors
+// received by clients using errdetail.UnaryClientInterceptor are of this
+// type too, so errors.As finds it on both sides of a call.{{.Doc}}
+type {{.Name}}Error struct {
+    Status *status.Status
+    Detail *{{.Name}}
+}
+
+// New{{.Name}}Error returns a status error with code and msg carrying detail.
+// code must not be codes.OK.{{.Doc}}
+func New{{.Name}}Error(code codes.Code, msg string, detail *{{.Name}}) error {
+    st := status.New(code, msg)
+    if withDetail, err := st.WithDetails(detail); err == nil {
+        st = withDetail
+    }
+    return &{{.Name}}Error{Status: st, Detail: detail}
+}
+
+func (e *{{.Name}}Error) Error() string {
+    return e.Status.Err().Error()
+}
+
+// GRPCStatus returns the status of e, for status.FromError.
+func (e *{{.Name}}Error) GRPCStatus() *status.Status {
+    return e.Status
+}
+
+// {{.Name}}DetailFromError returns the first {{.Name}} detail of the status of err,
+// or nil.{{.Doc}}
+func {{.Name}}DetailFromError(err error) *{{.Name}} {
+    var e *{{.Name}}Error
+    if errors.As(err, &e) {
+        return e.Detail
+    }
+    for _, d := range errdetail.Details(err) {
+        if detail, ok := d.(*{{.Name}}); ok {
+            return detail
+        }
+    }
+    return nil
+}
+
+func init() {
+    errdetail.Register({{printf "%q" .FullName}}, func(st *status.Status, detail proto.Message) error {
+        return &{{.Name}}Error{Status: st, Detail: detail.(*{{.Name}})}
+    })
+}
+`))
+)
+
+func main() {
+	input, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	req := new(plugin.CodeGeneratorRequest)
+	if err = proto.Unmarshal(input, req); err != nil {
+		log.Fatal(err)
+	}
+
+	out, err := generate(req)
+
+	if err != nil {
+		emitError(err)
+		return
+	}
+
+	emitFiles(out)
+}
+
+func generate(req *plugin.CodeGeneratorRequest) ([]*plugin.CodeGeneratorResponse_File, error) {
+	var files []*plugin.CodeGeneratorResponse_File
+	docs := protodoc.New(req.GetProtoFile())
+	genFileNames := make(map[string]bool)
+	for _, n := range req.FileToGenerate {
+		genFileNames[n] = true
+	}
+	for _, desc := range req.GetProtoFile() {
+		name := desc.GetName()
+		if _, ok := genFileNames[name]; !ok {
+			// Only emit output for files present in req.FileToGenerate.
+			continue
+		}
+		code, err := genCode(desc, docs)
+		if err != nil {
+			return nil, err
+		}
+		if code == "" {
+			// No message is marked as an error detail.
+			continue
+		}
+		formatted, err := format.Source([]byte(code))
+		if err != nil {
+			log.Printf("%v: %s", err, code)
+			return nil, err
+		}
+
+		ext := filepath.Ext(name)
+		base := strings.TrimSuffix(name, ext)
+		output := fmt.Sprintf("%s.pb.errordetails.go", base)
+		files = append(files, &plugin.CodeGeneratorResponse_File{
+			Name:    proto.String(output),
+			Content: proto.String(string(formatted)),
+		})
+	}
+
+	return files, nil
+}
+
+func genCode(desc *descriptor.FileDescriptorProto, docs *protodoc.Index) (string, error) {
+	w := bytes.NewBuffer(nil)
+	body := bytes.NewBuffer(nil)
+	prefix := ""
+	if desc.GetPackage() != "" {
+		prefix = "." + desc.GetPackage()
+	}
+	if err := genMessages(body, docs, prefix, desc.GetMessageType()); err != nil {
+		return "", err
+	}
+	if body.Len() == 0 {
+		return "", nil
+	}
+
+	hdr := &header{
+		Source: desc.GetName(),
+		GoPkg:  defaultGoPackageName(desc),
+	}
+	if err := hdrTmpl.Execute(w, hdr); err != nil {
+		log.Fatal(err)
+	}
+	body.WriteTo(w)
+
+	return w.String(), nil
+}
+
+// genMessages writes the helpers of the error details among msgs and their
+// nested messages.
+func genMessages(w *bytes.Buffer, docs *protodoc.Index, prefix string, msgs []*descriptor.DescriptorProto) error {
+	for _, m := range msgs {
+		name := prefix + "." + m.GetName()
+		if options.ErrorDetail(m) {
+			d := &detail{
+				Name:     localTypeName(name),
+				FullName: strings.TrimPrefix(name, "."),
+				Doc:      docs.Godoc(name),
+			}
+			if err := detailTmpl.Execute(w, d); err != nil {
+				return err
+			}
+		}
+		if err := genMessages(w, docs, name, m.GetNestedType()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type header struct {
+	Source string
+	GoPkg  string
+}
+
+type detail struct {
+	Name string
+	// FullName is the full name of the message, which the registry is
+	// keyed by.
+	FullName string
+	// Doc is the comment of the message, see protodoc.Index.Godoc.
+	Doc string
+}
+
+// localTypeName returns the Go name of a type declared in the file being
+// generated, e.g. ".pkg.Outer.Inner" becomes "Outer_Inner".
+func localTypeName(typeName string) string {
+	parts := strings.Split(strings.TrimPrefix(typeName, "."), ".")
+	var names []string
+	for _, p := range parts {
+		if len(p) > 0 && p[0] >= 'A' && p[0] <= 'Z' {
+			names = append(names, p)
+		}
+	}
+	return strings.Join(names, "_")
+}
+
+// sanitizePackageName replaces unallowed character in package name
+// with allowed character.
+func sanitizePackageName(pkgName string) string {
+	pkgName = strings.Replace(pkgName, ".", "_", -1)
+	pkgName = strings.Replace(pkgName, "-", "_", -1)
+	return pkgName
+}
+
+// defaultGoPackageName returns the default go package name to be used for go files generated from "f".
+// You might need to use an unique alias for the package when you import it.  Use ReserveGoPackageAlias to get a unique alias.
+func defaultGoPackageName(f *descriptor.FileDescriptorProto) string {
+	name := packageIdentityName(f)
+	return sanitizePackageName(name)
+}
+
+// packageIdentityName returns the identity of packages.
+// protoc-gen-grpc-gateway rejects CodeGenerationRequests which contains more than one packages
+// as protoc-gen-go does.
+func packageIdentityName(f *descriptor.FileDescriptorProto) string {
+	if f.Options != nil && f.Options.GoPackage != nil {
+		gopkg := f.Options.GetGoPackage()
+		idx := strings.LastIndex(gopkg, "/")
+		if idx < 0 {
+			gopkg = gopkg[idx+1:]
+		}
+
+		gopkg = gopkg[idx+1:]
+		// package name is overrided with the string after the
+		// ';' character
+		sc := strings.IndexByte(gopkg, ';')
+		if sc < 0 {
+			return sanitizePackageName(gopkg)
+
+		}
+		return sanitizePackageName(gopkg[sc+1:])
+	}
+
+	if f.Package == nil {
+		base := filepath.Base(f.GetName())
+		ext := filepath.Ext(base)
+		return strings.TrimSuffix(base, ext)
+	}
+	return f.GetPackage()
+}
+
+func emitFiles(out []*plugin.CodeGeneratorResponse_File) {
+	emitResp(&plugin.CodeGeneratorResponse{File: out})
+}
+
+func emitError(err error) {
+	emitResp(&plugin.CodeGeneratorResponse{Error: proto.String(err.Error())})
+}
+
+func emitResp(resp *plugin.CodeGeneratorResponse) {
+	buf, err := proto.Marshal(resp)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if _, err := os.Stdout.Write(buf); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/f4tq/protoc-go-plugins/internal/plugintest"
+)
+
+const docProto = `
+syntax = "proto3";
+
+package doc.v1;
+
+option go_package = "example.com/doc/v1;docv1";
+
+import "options/options.proto";
+
+// NotFound is the detail of the errors of missing documents.
+message NotFound {
+    option (f4tq.plugins.error_detail) = true;
+    string name = 1;
+}
+`
+
+// docTest runs in the generated package, whose init functions register the
+// typed errors before it.
+const docTest = `package docv1
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/f4tq/protoc-go-plugins/runtime/errdetail"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestTyped(t *testing.T) {
+	err := NewNotFoundError(codes.NotFound, "no such document", &NotFound{Name: "docs/readme"})
+	// A client receives a plain status error.
+	received := status.Convert(err).Err()
+	var e *NotFoundError
+	if !errors.As(errdetail.Typed(received), &e) {
+		t.Fatalf("Typed(%v) is not a *NotFoundError", received)
+	}
+	if got := e.Detail.GetName(); got != "docs/readme" {
+		t.Errorf("detail name = %q, want %q", got, "docs/readme")
+	}
+}
+`
+
+// TestGeneratedPackageLoads runs a test in a generated package: its init
+// functions, which register the details by name, must not depend on the
+// order in which those of its files run.
+func TestGeneratedPackageLoads(t *testing.T) {
+	req := plugintest.Request(t, "", map[string]string{"doc/v1/doc.proto": docProto})
+	pkg := plugintest.Package(t, req, generate)
+	plugintest.WriteFile(t, pkg, "typed_test.go", docTest)
+	plugintest.Go(t, "test", pkg)
+}
@@ -0,0 +1,602 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+
+	"github.com/f4tq/protoc-go-plugins/options"
+	"github.com/f4tq/protoc-go-plugins/protodoc"
+)
+
+var (
+	codeTmpl = template.Must(template.New("code").Parse(`
+// Code generated by protoc-gen-go-examples. DO NOT EDIT.
+// source: {{.Source}}
+
+package {{.GoPkg}}
+{{range .Examples}}
+// {{.Name}}ExampleJSON is an example of the JSON encoding of {{.Name}}, also
+// written to {{.File}}.{{.Doc}}
+const {{.Name}}ExampleJSON = {{.Literal}}
+{{end}}
+`))
+)
+
+func main() {
+	input, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	req := new(plugin.CodeGeneratorRequest)
+	if err = proto.Unmarshal(input, req); err != nil {
+		log.Fatal(err)
+	}
+
+	out, err := generate(req)
+
+	if err != nil {
+		emitError(err)
+		return
+	}
+
+	emitFiles(out)
+}
+
+func generate(req *plugin.CodeGeneratorRequest) ([]*plugin.CodeGeneratorResponse_File, error) {
+	var files []*plugin.CodeGeneratorResponse_File
+	g := &exampleGen{
+		idx:      newTypeIndex(req.GetProtoFile()),
+		docs:     protodoc.New(req.GetProtoFile()),
+		visiting: make(map[string]bool),
+	}
+	genFileNames := make(map[string]bool)
+	for _, n := range req.FileToGenerate {
+		genFileNames[n] = true
+	}
+	for _, desc := range req.GetProtoFile() {
+		name := desc.GetName()
+		if _, ok := genFileNames[name]; !ok {
+			// Only emit output for files present in req.FileToGenerate.
+			continue
+		}
+		ext := filepath.Ext(name)
+		base := strings.TrimSuffix(name, ext)
+		var examples []*example
+		scope := strings.TrimSuffix("."+desc.GetPackage(), ".")
+		if err := g.collect(&examples, base, scope, desc.GetMessageType()); err != nil {
+			return nil, fmt.Errorf("%s: %v", name, err)
+		}
+		if len(examples) == 0 {
+			continue
+		}
+		for _, e := range examples {
+			files = append(files, &plugin.CodeGeneratorResponse_File{
+				Name:    proto.String(e.File),
+				Content: proto.String(e.JSON),
+			})
+		}
+
+		w := bytes.NewBuffer(nil)
+		if err := codeTmpl.Execute(w, map[string]interface{}{
+			"Source":   name,
+			"GoPkg":    defaultGoPackageName(desc),
+			"Examples": examples,
+		}); err != nil {
+			return nil, err
+		}
+		formatted, err := format.Source(w.Bytes())
+		if err != nil {
+			log.Printf("%v: %s", err, w.String())
+			return nil, err
+		}
+		files = append(files, &plugin.CodeGeneratorResponse_File{
+			Name:    proto.String(fmt.Sprintf("%s.pb.examples.go", base)),
+			Content: proto.String(string(formatted)),
+		})
+	}
+
+	return files, nil
+}
+
+type example struct {
+	// Name is the Go name of the message.
+	Name string
+	// File is the name of the JSON file of the example.
+	File string
+	JSON string
+	// Doc is the comment of the message, see protodoc.Index.Godoc.
+	Doc string
+}
+
+// Literal returns the Go string literal of the example.
+func (e *example) Literal() string {
+	if strings.ContainsAny(e.JSON, "`\r") {
+		return strconv.Quote(e.JSON)
+	}
+	return "`" + e.JSON + "`"
+}
+
+// exampleGen builds the example JSON of messages: the example in the
+// comment of a message if any, and otherwise an object of the examples of
+// its fields. The example of a field is the one in its comment, its
+// (f4tq.plugins.default_value), or a placeholder of its type.
+type exampleGen struct {
+	idx *typeIndex
+	// docs holds the comments of the messages and fields, which may
+	// give their examples.
+	docs *protodoc.Index
+	// visiting holds the messages whose examples are being built, whose
+	// fields referring back to them are left out.
+	visiting map[string]bool
+}
+
+// collect appends the examples of msgs, and of the messages nested in
+// them, to examples. base is the name of the generated file without its
+// extension and scope the full proto name of the parent of msgs.
+func (g *exampleGen) collect(examples *[]*example, base, scope string, msgs []*descriptor.DescriptorProto) error {
+	for _, msg := range msgs {
+		if msg.GetOptions().GetMapEntry() {
+			continue
+		}
+		typeName := scope + "." + msg.GetName()
+		raw, err := g.message(typeName)
+		if err != nil {
+			return err
+		}
+		var out bytes.Buffer
+		if err := json.Indent(&out, raw, "", "  "); err != nil {
+			return fmt.Errorf("%s: %v", strings.TrimPrefix(typeName, "."), err)
+		}
+		out.WriteByte('\n')
+		name := localTypeName(typeName)
+		*examples = append(*examples, &example{
+			Name: name,
+			File: fmt.Sprintf("%s.examples/%s.json", base, name),
+			JSON: out.String(),
+			Doc:  g.docs.Godoc(typeName),
+		})
+		if err := g.collect(examples, base, typeName, msg.GetNestedType()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// message returns the example of the message typeName.
+func (g *exampleGen) message(typeName string) (json.RawMessage, error) {
+	name := strings.TrimPrefix(typeName, ".")
+	if ex, ok, err := commentExample(g.docs.Comment(typeName)); ok || err != nil {
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", name, err)
+		}
+		return ex, nil
+	}
+	msg := g.idx.messages[typeName]
+	if msg == nil {
+		return nil, fmt.Errorf("unknown message %s", typeName)
+	}
+	g.visiting[typeName] = true
+	defer delete(g.visiting, typeName)
+	w := bytes.NewBuffer(nil)
+	w.WriteByte('{')
+	oneofs := make(map[int32]bool)
+	for _, field := range msg.GetField() {
+		if field.OneofIndex != nil {
+			// Only the first member of a oneof is set.
+			if oneofs[field.GetOneofIndex()] {
+				continue
+			}
+			oneofs[field.GetOneofIndex()] = true
+		}
+		if isMessage(field) && g.visiting[field.GetTypeName()] {
+			continue
+		}
+		v, err := g.field(typeName, field)
+		if err != nil {
+			return nil, fmt.Errorf("%s.%s: %v", name, field.GetName(), err)
+		}
+		if w.Len() > 1 {
+			w.WriteByte(',')
+		}
+		key, _ := json.Marshal(jsonName(field))
+		w.Write(key)
+		w.WriteByte(':')
+		w.Write(v)
+	}
+	w.WriteByte('}')
+	return w.Bytes(), nil
+}
+
+// field returns the example of field of the message typeName.
+func (g *exampleGen) field(typeName string, field *descriptor.FieldDescriptorProto) (json.RawMessage, error) {
+	if ex, ok, err := commentExample(g.docs.Comment(typeName + "." + field.GetName())); ok || err != nil {
+		return ex, err
+	}
+	if g.idx.isMap(field) {
+		entry := g.idx.messages[field.GetTypeName()]
+		v, err := g.value(entry.GetField()[1])
+		if err != nil {
+			return nil, err
+		}
+		var key string
+		switch entry.GetField()[0].GetType() {
+		case descriptor.FieldDescriptorProto_TYPE_STRING:
+			key = "key"
+		case descriptor.FieldDescriptorProto_TYPE_BOOL:
+			key = "true"
+		default:
+			key = "1"
+		}
+		return json.RawMessage(fmt.Sprintf("{%q:%s}", key, v)), nil
+	}
+	if def := options.DefaultValue(field); def != "" {
+		return g.defaultValue(field, def)
+	}
+	v, err := g.value(field)
+	if err != nil {
+		return nil, err
+	}
+	if field.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REPEATED {
+		return json.RawMessage("[" + string(v) + "]"), nil
+	}
+	return v, nil
+}
+
+// wellKnownExamples are the examples of the well-known types, which jsonpb
+// encodes specially.
+var wellKnownExamples = map[string]string{
+	".google.protobuf.Timestamp":   `"1970-01-01T00:00:00Z"`,
+	".google.protobuf.Duration":    `"1s"`,
+	".google.protobuf.FieldMask":   `"field"`,
+	".google.protobuf.Empty":       `{}`,
+	".google.protobuf.Struct":      `{}`,
+	".google.protobuf.Value":       `null`,
+	".google.protobuf.ListValue":   `[]`,
+	".google.protobuf.Any":         `{"@type":"type.googleapis.com/google.protobuf.Empty","value":{}}`,
+	".google.protobuf.DoubleValue": `0`,
+	".google.protobuf.FloatValue":  `0`,
+	".google.protobuf.Int64Value":  `"0"`,
+	".google.protobuf.UInt64Value": `"0"`,
+	".google.protobuf.Int32Value":  `0`,
+	".google.protobuf.UInt32Value": `0`,
+	".google.protobuf.BoolValue":   `true`,
+	".google.protobuf.StringValue": `"string"`,
+	".google.protobuf.BytesValue":  `"Ynl0ZXM="`,
+}
+
+// value returns the placeholder of a value of field, as jsonpb writes it.
+func (g *exampleGen) value(field *descriptor.FieldDescriptorProto) (json.RawMessage, error) {
+	switch field.GetType() {
+	case descriptor.FieldDescriptorProto_TYPE_DOUBLE,
+		descriptor.FieldDescriptorProto_TYPE_FLOAT:
+		return json.RawMessage("0.5"), nil
+	case descriptor.FieldDescriptorProto_TYPE_INT32,
+		descriptor.FieldDescriptorProto_TYPE_SINT32,
+		descriptor.FieldDescriptorProto_TYPE_SFIXED32,
+		descriptor.FieldDescriptorProto_TYPE_UINT32,
+		descriptor.FieldDescriptorProto_TYPE_FIXED32:
+		return json.RawMessage("1"), nil
+	case descriptor.FieldDescriptorProto_TYPE_INT64,
+		descriptor.FieldDescriptorProto_TYPE_SINT64,
+		descriptor.FieldDescriptorProto_TYPE_SFIXED64,
+		descriptor.FieldDescriptorProto_TYPE_UINT64,
+		descriptor.FieldDescriptorProto_TYPE_FIXED64:
+		// jsonpb writes 64-bit integers as strings.
+		return json.RawMessage(`"1"`), nil
+	case descriptor.FieldDescriptorProto_TYPE_BOOL:
+		return json.RawMessage("true"), nil
+	case descriptor.FieldDescriptorProto_TYPE_STRING:
+		return json.RawMessage(strconv.Quote(field.GetName())), nil
+	case descriptor.FieldDescriptorProto_TYPE_BYTES:
+		return json.RawMessage(strconv.Quote(base64.StdEncoding.EncodeToString([]byte(field.GetName())))), nil
+	case descriptor.FieldDescriptorProto_TYPE_ENUM:
+		enum := g.idx.enums[field.GetTypeName()]
+		if enum == nil || len(enum.GetValue()) == 0 {
+			return nil, fmt.Errorf("unknown enum %s", field.GetTypeName())
+		}
+		// The first value other than the zero one, which jsonpb omits.
+		v := enum.GetValue()[0]
+		for _, ev := range enum.GetValue() {
+			if ev.GetNumber() != 0 {
+				v = ev
+				break
+			}
+		}
+		return json.RawMessage(strconv.Quote(v.GetName())), nil
+	case descriptor.FieldDescriptorProto_TYPE_MESSAGE,
+		descriptor.FieldDescriptorProto_TYPE_GROUP:
+		// jsonpb writes groups as the messages they are.
+		if ex, ok := wellKnownExamples[field.GetTypeName()]; ok {
+			return json.RawMessage(ex), nil
+		}
+		return g.message(field.GetTypeName())
+	default:
+		return nil, fmt.Errorf("unsupported field type %v", field.GetType())
+	}
+}
+
+// isMessage reports whether field holds messages, as groups do too.
+func isMessage(field *descriptor.FieldDescriptorProto) bool {
+	return field.GetType() == descriptor.FieldDescriptorProto_TYPE_MESSAGE ||
+		field.GetType() == descriptor.FieldDescriptorProto_TYPE_GROUP
+}
+
+// defaultValue returns the JSON of the (f4tq.plugins.default_value) def of
+// field, written as in the text format.
+func (g *exampleGen) defaultValue(field *descriptor.FieldDescriptorProto, def string) (json.RawMessage, error) {
+	switch field.GetType() {
+	case descriptor.FieldDescriptorProto_TYPE_STRING:
+		b, _ := json.Marshal(def)
+		return b, nil
+	case descriptor.FieldDescriptorProto_TYPE_BYTES:
+		return json.RawMessage(strconv.Quote(base64.StdEncoding.EncodeToString([]byte(def)))), nil
+	case descriptor.FieldDescriptorProto_TYPE_BOOL:
+		b, err := strconv.ParseBool(def)
+		if err != nil {
+			return nil, err
+		}
+		return json.RawMessage(strconv.FormatBool(b)), nil
+	case descriptor.FieldDescriptorProto_TYPE_ENUM:
+		for _, v := range g.idx.enums[field.GetTypeName()].GetValue() {
+			if v.GetName() == def {
+				return json.RawMessage(strconv.Quote(def)), nil
+			}
+		}
+		return nil, fmt.Errorf("%s has no value %s", field.GetTypeName(), def)
+	case descriptor.FieldDescriptorProto_TYPE_DOUBLE, descriptor.FieldDescriptorProto_TYPE_FLOAT:
+		f, err := strconv.ParseFloat(def, 64)
+		if err != nil {
+			return nil, err
+		}
+		return json.RawMessage(strconv.FormatFloat(f, 'g', -1, 64)), nil
+	case descriptor.FieldDescriptorProto_TYPE_INT64,
+		descriptor.FieldDescriptorProto_TYPE_SINT64,
+		descriptor.FieldDescriptorProto_TYPE_SFIXED64:
+		i, err := strconv.ParseInt(def, 0, 64)
+		if err != nil {
+			return nil, err
+		}
+		return json.RawMessage(strconv.Quote(strconv.FormatInt(i, 10))), nil
+	case descriptor.FieldDescriptorProto_TYPE_UINT64,
+		descriptor.FieldDescriptorProto_TYPE_FIXED64:
+		u, err := strconv.ParseUint(def, 0, 64)
+		if err != nil {
+			return nil, err
+		}
+		return json.RawMessage(strconv.Quote(strconv.FormatUint(u, 10))), nil
+	case descriptor.FieldDescriptorProto_TYPE_UINT32,
+		descriptor.FieldDescriptorProto_TYPE_FIXED32:
+		u, err := strconv.ParseUint(def, 0, 32)
+		if err != nil {
+			return nil, err
+		}
+		return json.RawMessage(strconv.FormatUint(u, 10)), nil
+	case descriptor.FieldDescriptorProto_TYPE_INT32,
+		descriptor.FieldDescriptorProto_TYPE_SINT32,
+		descriptor.FieldDescriptorProto_TYPE_SFIXED32:
+		i, err := strconv.ParseInt(def, 0, 32)
+		if err != nil {
+			return nil, err
+		}
+		return json.RawMessage(strconv.FormatInt(i, 10)), nil
+	}
+	return nil, fmt.Errorf("default_value is only supported on scalar and enum fields")
+}
+
+// commentExample returns the JSON following "example:" at the start of a
+// line of comment, up to the next blank line, and whether there is one.
+func commentExample(comment string) (json.RawMessage, bool, error) {
+	lines := strings.Split(comment, "\n")
+	for i, l := range lines {
+		l = strings.TrimSpace(l)
+		if !strings.HasPrefix(l, "example:") {
+			continue
+		}
+		text := strings.TrimSpace(strings.TrimPrefix(l, "example:"))
+		for _, next := range lines[i+1:] {
+			if strings.TrimSpace(next) == "" {
+				break
+			}
+			text += "\n" + next
+		}
+		var compact bytes.Buffer
+		if err := json.Compact(&compact, []byte(text)); err != nil {
+			return nil, true, fmt.Errorf("invalid example %s: %v", text, err)
+		}
+		return compact.Bytes(), true, nil
+	}
+	return nil, false, nil
+}
+
+// jsonName returns the JSON name of field, which protoc sets, or else the
+// lower camel case of its name.
+func jsonName(field *descriptor.FieldDescriptorProto) string {
+	if field.GetJsonName() != "" {
+		return field.GetJsonName()
+	}
+	name := camelCase(field.GetName())
+	return strings.ToLower(name[:1]) + name[1:]
+}
+
+// localTypeName returns the Go name of a type declared in the file being
+// generated, e.g. ".pkg.Outer.Inner" becomes "Outer_Inner".
+func localTypeName(typeName string) string {
+	parts := strings.Split(strings.TrimPrefix(typeName, "."), ".")
+	var names []string
+	for _, p := range parts {
+		if len(p) > 0 && p[0] >= 'A' && p[0] <= 'Z' {
+			names = append(names, p)
+		}
+	}
+	return strings.Join(names, "_")
+}
+
+// typeIndex maps fully-qualified proto type names (".pkg.Msg") to their
+// descriptors and declaring files for every file in the request.
+type typeIndex struct {
+	messages map[string]*descriptor.DescriptorProto
+	enums    map[string]*descriptor.EnumDescriptorProto
+	files    map[string]*descriptor.FileDescriptorProto
+}
+
+func newTypeIndex(files []*descriptor.FileDescriptorProto) *typeIndex {
+	idx := &typeIndex{
+		messages: make(map[string]*descriptor.DescriptorProto),
+		enums:    make(map[string]*descriptor.EnumDescriptorProto),
+		files:    make(map[string]*descriptor.FileDescriptorProto),
+	}
+	for _, f := range files {
+		prefix := ""
+		if f.GetPackage() != "" {
+			prefix = "." + f.GetPackage()
+		}
+		idx.addMessages(f, prefix, f.GetMessageType())
+		idx.addEnums(f, prefix, f.GetEnumType())
+	}
+	return idx
+}
+
+func (idx *typeIndex) addMessages(f *descriptor.FileDescriptorProto, prefix string, msgs []*descriptor.DescriptorProto) {
+	for _, m := range msgs {
+		name := prefix + "." + m.GetName()
+		idx.messages[name] = m
+		idx.files[name] = f
+		idx.addMessages(f, name, m.GetNestedType())
+		idx.addEnums(f, name, m.GetEnumType())
+	}
+}
+
+func (idx *typeIndex) addEnums(f *descriptor.FileDescriptorProto, prefix string, enums []*descriptor.EnumDescriptorProto) {
+	for _, e := range enums {
+		name := prefix + "." + e.GetName()
+		idx.enums[name] = e
+		idx.files[name] = f
+	}
+}
+
+// isMap reports whether field is a map field.
+func (idx *typeIndex) isMap(field *descriptor.FieldDescriptorProto) bool {
+	if field.GetType() != descriptor.FieldDescriptorProto_TYPE_MESSAGE {
+		return false
+	}
+	return idx.messages[field.GetTypeName()].GetOptions().GetMapEntry()
+}
+
+// camelCase returns the Go field name protoc-gen-go derives from s.
+func camelCase(s string) string {
+	if s == "" {
+		return ""
+	}
+	t := make([]byte, 0, 32)
+	i := 0
+	if s[0] == '_' {
+		// Need a capital letter; drop the '_'.
+		t = append(t, 'X')
+		i++
+	}
+	for ; i < len(s); i++ {
+		c := s[i]
+		if c == '_' && i+1 < len(s) && isASCIILower(s[i+1]) {
+			continue
+		}
+		if isASCIIDigit(c) {
+			t = append(t, c)
+			continue
+		}
+		if isASCIILower(c) {
+			c ^= ' '
+		}
+		t = append(t, c)
+		for i+1 < len(s) && isASCIILower(s[i+1]) {
+			i++
+			t = append(t, s[i])
+		}
+	}
+	return string(t)
+}
+
+func isASCIILower(c byte) bool {
+	return 'a' <= c && c <= 'z'
+}
+
+func isASCIIDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+// sanitizePackageName replaces unallowed character in package name
+// with allowed character.
+func sanitizePackageName(pkgName string) string {
+	pkgName = strings.Replace(pkgName, ".", "_", -1)
+	pkgName = strings.Replace(pkgName, "-", "_", -1)
+	return pkgName
+}
+
+// defaultGoPackageName returns the default go package name to be used for go files generated from "f".
+// You might need to use an unique alias for the package when you import it.  Use ReserveGoPackageAlias to get a unique alias.
+func defaultGoPackageName(f *descriptor.FileDescriptorProto) string {
+	name := packageIdentityName(f)
+	return sanitizePackageName(name)
+}
+
+// packageIdentityName returns the identity of packages.
+// protoc-gen-grpc-gateway rejects CodeGenerationRequests which contains more than one packages
+// as protoc-gen-go does.
+func packageIdentityName(f *descriptor.FileDescriptorProto) string {
+	if f.Options != nil && f.Options.GoPackage != nil {
+		gopkg := f.Options.GetGoPackage()
+		idx := strings.LastIndex(gopkg, "/")
+		if idx < 0 {
+			gopkg = gopkg[idx+1:]
+		}
+
+		gopkg = gopkg[idx+1:]
+		// package name is overrided with the string after the
+		// ';' character
+		sc := strings.IndexByte(gopkg, ';')
+		if sc < 0 {
+			return sanitizePackageName(gopkg)
+
+		}
+		return sanitizePackageName(gopkg[sc+1:])
+	}
+
+	if f.Package == nil {
+		base := filepath.Base(f.GetName())
+		ext := filepath.Ext(base)
+		return strings.TrimSuffix(base, ext)
+	}
+	return f.GetPackage()
+}
+
+func emitFiles(out []*plugin.CodeGeneratorResponse_File) {
+	emitResp(&plugin.CodeGeneratorResponse{File: out})
+}
+
+func emitError(err error) {
+	emitResp(&plugin.CodeGeneratorResponse{Error: proto.String(err.Error())})
+}
+
+func emitResp(resp *plugin.CodeGeneratorResponse) {
+	buf, err := proto.Marshal(resp)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if _, err := os.Stdout.Write(buf); err != nil {
+		log.Fatal(err)
+	}
+}
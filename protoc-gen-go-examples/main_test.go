@@ -0,0 +1,77 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/f4tq/protoc-go-plugins/internal/plugintest"
+)
+
+const groupProto = `
+syntax = "proto2";
+
+package doc.v1;
+
+option go_package = "example.com/doc/v1;docv1";
+
+message Doc {
+    optional group Meta = 1 {
+        optional string author = 2;
+        optional Doc origin = 3;
+    }
+    repeated group Part = 4 {
+        optional int32 size = 5;
+    }
+}
+`
+
+// TestGroups checks the examples of group fields are those of their
+// messages.
+func TestGroups(t *testing.T) {
+	req := plugintest.Request(t, "", map[string]string{"doc/v1/doc.proto": groupProto})
+	got := plugintest.Generate(t, req, generate)["doc/v1/doc.examples/Doc.json"]
+	var doc struct {
+		Meta struct {
+			Author string          `json:"author"`
+			Origin json.RawMessage `json:"origin"`
+		} `json:"meta"`
+		Part []struct {
+			Size int `json:"size"`
+		} `json:"part"`
+	}
+	if err := json.Unmarshal([]byte(got), &doc); err != nil {
+		t.Fatalf("%v:\n%s", err, got)
+	}
+	if doc.Meta.Author != "author" || doc.Meta.Origin != nil || len(doc.Part) != 1 || doc.Part[0].Size != 1 {
+		t.Errorf("example of Doc is %s, want meta with an author and no origin, and one part of size 1", got)
+	}
+}
+
+const commentedProto = `
+syntax = "proto3";
+
+package doc.v1;
+
+option go_package = "example.com/doc/v1;docv1";
+
+// Doc is a document.
+message Doc {
+    // title names the document.
+    string title = 1; // Shown in lists.
+}
+`
+
+// TestComments checks the examples carry the leading and trailing comments
+// of the declarations.
+func TestComments(t *testing.T) {
+	req := plugintest.Request(t, "", map[string]string{"doc/v1/doc.proto": commentedProto})
+	got := plugintest.Generate(t, req, generate)["doc/v1/doc.pb.examples.go"]
+	for _, want := range []string{
+		"//\n// Doc is a document.\nconst DocExampleJSON",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("the code does not contain %q:\n%s", want, got)
+		}
+	}
+}
@@ -0,0 +1,632 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+
+	"github.com/f4tq/protoc-go-plugins/protodoc"
+)
+
+var (
+	hdrTmpl = template.Must(template.New("header").Parse(`
+// Code generated by protoc-gen-go-fake. DO NOT EDIT.
+// source: {{.Source}}
+
+package {{.GoPkg}}
+
+import (
+{{- if .Unary}}
+    "context"
+{{- end}}
+{{- if .ClientStreaming}}
+    "io"
+{{- end}}
+    "sync"
+
+    "github.com/golang/protobuf/proto"
+    "google.golang.org/grpc/codes"
+    "google.golang.org/grpc/status"
+{{- range $path, $name := .Imports}}
+    {{$name}} "{{$path}}"
+{{- end}}
+)
+`))
+
+	fakeTmpl = template.Must(template.New("fake").Parse(`
+// {{.Name}}Fake is a programmable in-memory {{.Name}}Server. Each method
+// records the requests it receives and answers with the responses scripted
+// through Enqueue<Method>, in FIFO order. Once the script is exhausted the
+// hook installed with Set<Method>Hook is used, and without a hook the call
+// fails with codes.Unimplemented.{{.Doc}}
+type {{.Name}}Fake struct {
+    Unimplemented{{.Name}}Server
+
+    mu sync.Mutex
+{{- range .Methods}}
+    {{.Field}}Queue []{{$.Lower}}Fake{{.Name}}Result
+    {{.Field}}Hook  {{.Hook}}
+    {{.Field}}Calls {{if .ClientStreaming}}{{if not .ServerStreaming}}[]{{end}}{{end}}[]*{{.Input}}
+{{- end}}
+}
+
+var _ {{.Name}}Server = (*{{.Name}}Fake)(nil)
+
+// New{{.Name}}Fake returns an empty {{.Name}}Fake.{{.Doc}}
+func New{{.Name}}Fake() *{{.Name}}Fake {
+    return &{{.Name}}Fake{}
+}
+
+// Reset discards all scripted responses, hooks and recorded calls.
+func (f *{{.Name}}Fake) Reset() {
+    f.mu.Lock()
+    defer f.mu.Unlock()
+{{- range .Methods}}
+    f.{{.Field}}Queue = nil
+    f.{{.Field}}Hook = nil
+    f.{{.Field}}Calls = nil
+{{- end}}
+}
+{{range .Methods}}
+{{- if .ClientStreaming}}{{if .ServerStreaming}}
+type {{$.Lower}}Fake{{.Name}}Result struct {
+    resp *{{.Output}}
+    err  error
+}
+
+// Enqueue{{.Name}} scripts the reply to the next message received on a
+// {{.Name}} stream. A non-nil err ends the stream with that error.
+func (f *{{$.Name}}Fake) Enqueue{{.Name}}(resp *{{.Output}}, err error) {
+    f.mu.Lock()
+    defer f.mu.Unlock()
+    f.{{.Field}}Queue = append(f.{{.Field}}Queue, {{$.Lower}}Fake{{.Name}}Result{resp: resp, err: err})
+}
+
+// Set{{.Name}}Hook installs fn to serve {{.Name}} streams when no replies
+// are scripted. fn takes over the whole stream.
+func (f *{{$.Name}}Fake) Set{{.Name}}Hook(fn {{.Hook}}) {
+    f.mu.Lock()
+    defer f.mu.Unlock()
+    f.{{.Field}}Hook = fn
+}
+
+// {{.Name}}Calls returns copies of the messages received on {{.Name}}
+// streams, in order.
+func (f *{{$.Name}}Fake) {{.Name}}Calls() []*{{.Input}} {
+    f.mu.Lock()
+    defer f.mu.Unlock()
+    return append([]*{{.Input}}(nil), f.{{.Field}}Calls...)
+}
+
+// {{.Name}} implements {{$.Name}}Server.{{.Doc}}
+func (f *{{$.Name}}Fake) {{.Name}}(stream {{$.Name}}_{{.Name}}Server) error {
+    f.mu.Lock()
+    hook := f.{{.Field}}Hook
+    scripted := len(f.{{.Field}}Queue) > 0
+    f.mu.Unlock()
+    if !scripted && hook != nil {
+        return hook(stream)
+    }
+    for {
+        req, err := stream.Recv()
+        if err == io.EOF {
+            return nil
+        }
+        if err != nil {
+            return err
+        }
+        f.mu.Lock()
+        f.{{.Field}}Calls = append(f.{{.Field}}Calls, proto.Clone(req).(*{{.Input}}))
+        if len(f.{{.Field}}Queue) == 0 {
+            f.mu.Unlock()
+            return status.Error(codes.Unimplemented, "fake: no reply scripted for {{$.Name}}.{{.Name}}")
+        }
+        next := f.{{.Field}}Queue[0]
+        f.{{.Field}}Queue = f.{{.Field}}Queue[1:]
+        f.mu.Unlock()
+        if next.err != nil {
+            return next.err
+        }
+        if err := stream.Send(next.resp); err != nil {
+            return err
+        }
+    }
+}
+{{- else}}
+type {{$.Lower}}Fake{{.Name}}Result struct {
+    resp *{{.Output}}
+    err  error
+}
+
+// Enqueue{{.Name}} scripts the result of the next {{.Name}} call.
+func (f *{{$.Name}}Fake) Enqueue{{.Name}}(resp *{{.Output}}, err error) {
+    f.mu.Lock()
+    defer f.mu.Unlock()
+    f.{{.Field}}Queue = append(f.{{.Field}}Queue, {{$.Lower}}Fake{{.Name}}Result{resp: resp, err: err})
+}
+
+// Set{{.Name}}Hook installs fn to answer {{.Name}} calls once the script is
+// exhausted. fn receives every message sent by the client.
+func (f *{{$.Name}}Fake) Set{{.Name}}Hook(fn {{.Hook}}) {
+    f.mu.Lock()
+    defer f.mu.Unlock()
+    f.{{.Field}}Hook = fn
+}
+
+// {{.Name}}Calls returns copies of the messages received by each {{.Name}}
+// call, in order.
+func (f *{{$.Name}}Fake) {{.Name}}Calls() [][]*{{.Input}} {
+    f.mu.Lock()
+    defer f.mu.Unlock()
+    return append([][]*{{.Input}}(nil), f.{{.Field}}Calls...)
+}
+
+// {{.Name}} implements {{$.Name}}Server.{{.Doc}}
+func (f *{{$.Name}}Fake) {{.Name}}(stream {{$.Name}}_{{.Name}}Server) error {
+    var reqs []*{{.Input}}
+    for {
+        req, err := stream.Recv()
+        if err == io.EOF {
+            break
+        }
+        if err != nil {
+            return err
+        }
+        reqs = append(reqs, proto.Clone(req).(*{{.Input}}))
+    }
+    f.mu.Lock()
+    f.{{.Field}}Calls = append(f.{{.Field}}Calls, reqs)
+    hook := f.{{.Field}}Hook
+    if len(f.{{.Field}}Queue) > 0 {
+        next := f.{{.Field}}Queue[0]
+        f.{{.Field}}Queue = f.{{.Field}}Queue[1:]
+        f.mu.Unlock()
+        if next.err != nil {
+            return next.err
+        }
+        return stream.SendAndClose(next.resp)
+    }
+    f.mu.Unlock()
+    if hook == nil {
+        return status.Error(codes.Unimplemented, "fake: no response scripted for {{$.Name}}.{{.Name}}")
+    }
+    resp, err := hook(stream.Context(), reqs)
+    if err != nil {
+        return err
+    }
+    return stream.SendAndClose(resp)
+}
+{{- end}}
+{{- else if .ServerStreaming}}
+type {{$.Lower}}Fake{{.Name}}Result struct {
+    resps []*{{.Output}}
+    err   error
+}
+
+// Enqueue{{.Name}} scripts the next {{.Name}} call to send resps and then
+// end the stream with err.
+func (f *{{$.Name}}Fake) Enqueue{{.Name}}(resps []*{{.Output}}, err error) {
+    f.mu.Lock()
+    defer f.mu.Unlock()
+    f.{{.Field}}Queue = append(f.{{.Field}}Queue, {{$.Lower}}Fake{{.Name}}Result{resps: resps, err: err})
+}
+
+// Set{{.Name}}Hook installs fn to serve {{.Name}} calls once the script is
+// exhausted.
+func (f *{{$.Name}}Fake) Set{{.Name}}Hook(fn {{.Hook}}) {
+    f.mu.Lock()
+    defer f.mu.Unlock()
+    f.{{.Field}}Hook = fn
+}
+
+// {{.Name}}Calls returns copies of the requests received by {{.Name}}, in
+// order.
+func (f *{{$.Name}}Fake) {{.Name}}Calls() []*{{.Input}} {
+    f.mu.Lock()
+    defer f.mu.Unlock()
+    return append([]*{{.Input}}(nil), f.{{.Field}}Calls...)
+}
+
+// {{.Name}} implements {{$.Name}}Server.{{.Doc}}
+func (f *{{$.Name}}Fake) {{.Name}}(req *{{.Input}}, stream {{$.Name}}_{{.Name}}Server) error {
+    f.mu.Lock()
+    f.{{.Field}}Calls = append(f.{{.Field}}Calls, proto.Clone(req).(*{{.Input}}))
+    hook := f.{{.Field}}Hook
+    if len(f.{{.Field}}Queue) > 0 {
+        next := f.{{.Field}}Queue[0]
+        f.{{.Field}}Queue = f.{{.Field}}Queue[1:]
+        f.mu.Unlock()
+        for _, resp := range next.resps {
+            if err := stream.Send(resp); err != nil {
+                return err
+            }
+        }
+        return next.err
+    }
+    f.mu.Unlock()
+    if hook == nil {
+        return status.Error(codes.Unimplemented, "fake: no response scripted for {{$.Name}}.{{.Name}}")
+    }
+    return hook(req, stream)
+}
+{{- else}}
+type {{$.Lower}}Fake{{.Name}}Result struct {
+    resp *{{.Output}}
+    err  error
+}
+
+// Enqueue{{.Name}} scripts the result of the next {{.Name}} call.
+func (f *{{$.Name}}Fake) Enqueue{{.Name}}(resp *{{.Output}}, err error) {
+    f.mu.Lock()
+    defer f.mu.Unlock()
+    f.{{.Field}}Queue = append(f.{{.Field}}Queue, {{$.Lower}}Fake{{.Name}}Result{resp: resp, err: err})
+}
+
+// Set{{.Name}}Hook installs fn to answer {{.Name}} calls once the script is
+// exhausted.
+func (f *{{$.Name}}Fake) Set{{.Name}}Hook(fn {{.Hook}}) {
+    f.mu.Lock()
+    defer f.mu.Unlock()
+    f.{{.Field}}Hook = fn
+}
+
+// {{.Name}}Calls returns copies of the requests received by {{.Name}}, in
+// order.
+func (f *{{$.Name}}Fake) {{.Name}}Calls() []*{{.Input}} {
+    f.mu.Lock()
+    defer f.mu.Unlock()
+    return append([]*{{.Input}}(nil), f.{{.Field}}Calls...)
+}
+
+// {{.Name}} implements {{$.Name}}Server.{{.Doc}}
+func (f *{{$.Name}}Fake) {{.Name}}(ctx context.Context, req *{{.Input}}) (*{{.Output}}, error) {
+    f.mu.Lock()
+    f.{{.Field}}Calls = append(f.{{.Field}}Calls, proto.Clone(req).(*{{.Input}}))
+    hook := f.{{.Field}}Hook
+    if len(f.{{.Field}}Queue) > 0 {
+        next := f.{{.Field}}Queue[0]
+        f.{{.Field}}Queue = f.{{.Field}}Queue[1:]
+        f.mu.Unlock()
+        return next.resp, next.err
+    }
+    f.mu.Unlock()
+    if hook == nil {
+        return nil, status.Error(codes.Unimplemented, "fake: no response scripted for {{$.Name}}.{{.Name}}")
+    }
+    return hook(ctx, req)
+}
+{{- end}}
+{{end}}
+`))
+)
+
+func main() {
+	input, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	req := new(plugin.CodeGeneratorRequest)
+	if err = proto.Unmarshal(input, req); err != nil {
+		log.Fatal(err)
+	}
+
+	out, err := generate(req)
+
+	if err != nil {
+		emitError(err)
+		return
+	}
+
+	emitFiles(out)
+}
+
+func generate(req *plugin.CodeGeneratorRequest) ([]*plugin.CodeGeneratorResponse_File, error) {
+	var files []*plugin.CodeGeneratorResponse_File
+	idx := newTypeIndex(req.GetProtoFile())
+	docs := protodoc.New(req.GetProtoFile())
+	genFileNames := make(map[string]bool)
+	for _, n := range req.FileToGenerate {
+		genFileNames[n] = true
+	}
+	for _, desc := range req.GetProtoFile() {
+		name := desc.GetName()
+		if _, ok := genFileNames[name]; !ok {
+			// Only emit output for files present in req.FileToGenerate.
+			continue
+		}
+		if len(desc.GetService()) == 0 {
+			continue
+		}
+		code, err := genCode(desc, idx, docs)
+		if err != nil {
+			return nil, err
+		}
+		formatted, err := format.Source([]byte(code))
+		if err != nil {
+			log.Printf("%v: %s", err, code)
+			return nil, err
+		}
+
+		ext := filepath.Ext(name)
+		base := strings.TrimSuffix(name, ext)
+		output := fmt.Sprintf("%s.pb.fake.go", base)
+		files = append(files, &plugin.CodeGeneratorResponse_File{
+			Name:    proto.String(output),
+			Content: proto.String(string(formatted)),
+		})
+	}
+
+	return files, nil
+}
+
+func genCode(desc *descriptor.FileDescriptorProto, idx *typeIndex, docs *protodoc.Index) (string, error) {
+	w := bytes.NewBuffer(nil)
+	imports := newImportSet(desc)
+	hdr := &header{
+		Source:  desc.GetName(),
+		GoPkg:   defaultGoPackageName(desc),
+		Imports: imports.names,
+	}
+	body := bytes.NewBuffer(nil)
+	for _, svc := range desc.GetService() {
+		fullName := qualify(desc.GetPackage(), svc.GetName())
+		s := &fakeService{Name: svc.GetName(), Lower: lowerFirst(svc.GetName()), Doc: docs.Godoc(fullName)}
+		for _, m := range svc.GetMethod() {
+			method := &fakeMethod{
+				Name:            m.GetName(),
+				Field:           lowerFirst(m.GetName()),
+				Input:           imports.goTypeName(idx, m.GetInputType()),
+				Output:          imports.goTypeName(idx, m.GetOutputType()),
+				ClientStreaming: m.GetClientStreaming(),
+				ServerStreaming: m.GetServerStreaming(),
+				Doc:             docs.Godoc(fullName + "." + m.GetName()),
+			}
+			stream := fmt.Sprintf("%s_%sServer", svc.GetName(), m.GetName())
+			switch {
+			case method.ClientStreaming && method.ServerStreaming:
+				method.Hook = fmt.Sprintf("func(%s) error", stream)
+				hdr.ClientStreaming = true
+			case method.ClientStreaming:
+				method.Hook = fmt.Sprintf("func(context.Context, []*%s) (*%s, error)", method.Input, method.Output)
+				hdr.Unary = true
+				hdr.ClientStreaming = true
+			case method.ServerStreaming:
+				method.Hook = fmt.Sprintf("func(*%s, %s) error", method.Input, stream)
+			default:
+				method.Hook = fmt.Sprintf("func(context.Context, *%s) (*%s, error)", method.Input, method.Output)
+				hdr.Unary = true
+			}
+			s.Methods = append(s.Methods, method)
+		}
+		if err := fakeTmpl.Execute(body, s); err != nil {
+			return "", err
+		}
+	}
+
+	if err := hdrTmpl.Execute(w, hdr); err != nil {
+		log.Fatal(err)
+	}
+	body.WriteTo(w)
+
+	return w.String(), nil
+}
+
+// lowerFirst lower-cases the first letter of an exported Go identifier.
+func lowerFirst(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToLower(s[:1]) + s[1:]
+}
+
+type header struct {
+	Source          string
+	GoPkg           string
+	Imports         map[string]string
+	Unary           bool
+	ClientStreaming bool
+}
+
+type fakeService struct {
+	Name    string
+	Lower   string
+	Methods []*fakeMethod
+	// Doc is the comment of the service, see protodoc.Index.Godoc.
+	Doc string
+}
+
+type fakeMethod struct {
+	Name            string
+	Field           string
+	Input           string
+	Output          string
+	Hook            string
+	ClientStreaming bool
+	ServerStreaming bool
+	// Doc is the comment of the method.
+	Doc string
+}
+
+// qualify returns the full proto name of name, declared in scope.
+func qualify(scope, name string) string {
+	if scope == "" {
+		return name
+	}
+	return scope + "." + name
+}
+
+// typeIndex maps fully-qualified proto type names (".pkg.Msg") to their
+// descriptors and declaring files for every file in the request.
+type typeIndex struct {
+	messages map[string]*descriptor.DescriptorProto
+	enums    map[string]*descriptor.EnumDescriptorProto
+	files    map[string]*descriptor.FileDescriptorProto
+}
+
+func newTypeIndex(files []*descriptor.FileDescriptorProto) *typeIndex {
+	idx := &typeIndex{
+		messages: make(map[string]*descriptor.DescriptorProto),
+		enums:    make(map[string]*descriptor.EnumDescriptorProto),
+		files:    make(map[string]*descriptor.FileDescriptorProto),
+	}
+	for _, f := range files {
+		prefix := ""
+		if f.GetPackage() != "" {
+			prefix = "." + f.GetPackage()
+		}
+		idx.addMessages(f, prefix, f.GetMessageType())
+		idx.addEnums(f, prefix, f.GetEnumType())
+	}
+	return idx
+}
+
+func (idx *typeIndex) addMessages(f *descriptor.FileDescriptorProto, prefix string, msgs []*descriptor.DescriptorProto) {
+	for _, m := range msgs {
+		name := prefix + "." + m.GetName()
+		idx.messages[name] = m
+		idx.files[name] = f
+		idx.addMessages(f, name, m.GetNestedType())
+		idx.addEnums(f, name, m.GetEnumType())
+	}
+}
+
+func (idx *typeIndex) addEnums(f *descriptor.FileDescriptorProto, prefix string, enums []*descriptor.EnumDescriptorProto) {
+	for _, e := range enums {
+		name := prefix + "." + e.GetName()
+		idx.enums[name] = e
+		idx.files[name] = f
+	}
+}
+
+// isMap reports whether field is a map field.
+func (idx *typeIndex) isMap(field *descriptor.FieldDescriptorProto) bool {
+	if field.GetType() != descriptor.FieldDescriptorProto_TYPE_MESSAGE {
+		return false
+	}
+	return idx.messages[field.GetTypeName()].GetOptions().GetMapEntry()
+}
+
+// goImportPath returns the import path of the Go package generated from f,
+// or "" if go_package does not name one.
+func goImportPath(f *descriptor.FileDescriptorProto) string {
+	gopkg := f.GetOptions().GetGoPackage()
+	if sc := strings.IndexByte(gopkg, ';'); sc >= 0 {
+		gopkg = gopkg[:sc]
+	}
+	if !strings.Contains(gopkg, "/") {
+		return ""
+	}
+	return gopkg
+}
+
+// importSet records the Go packages referenced by the code generated for
+// one file, keyed by import path.
+type importSet struct {
+	self  string
+	names map[string]string
+}
+
+func newImportSet(f *descriptor.FileDescriptorProto) *importSet {
+	return &importSet{self: goImportPath(f), names: make(map[string]string)}
+}
+
+// goTypeName returns the Go name of the message or enum typeName, qualified
+// with its package if it is declared outside the generated package.
+func (s *importSet) goTypeName(idx *typeIndex, typeName string) string {
+	name := localTypeName(typeName)
+	f := idx.files[typeName]
+	path := goImportPath(f)
+	if f == nil || path == "" || path == s.self {
+		return name
+	}
+	pkg := defaultGoPackageName(f)
+	s.names[path] = pkg
+	return pkg + "." + name
+}
+
+// localTypeName returns the Go name of a type declared in the file being
+// generated, e.g. ".pkg.Outer.Inner" becomes "Outer_Inner".
+func localTypeName(typeName string) string {
+	parts := strings.Split(strings.TrimPrefix(typeName, "."), ".")
+	var names []string
+	for _, p := range parts {
+		if len(p) > 0 && p[0] >= 'A' && p[0] <= 'Z' {
+			names = append(names, p)
+		}
+	}
+	return strings.Join(names, "_")
+}
+
+// sanitizePackageName replaces unallowed character in package name
+// with allowed character.
+func sanitizePackageName(pkgName string) string {
+	pkgName = strings.Replace(pkgName, ".", "_", -1)
+	pkgName = strings.Replace(pkgName, "-", "_", -1)
+	return pkgName
+}
+
+// defaultGoPackageName returns the default go package name to be used for go files generated from "f".
+// You might need to use an unique alias for the package when you import it.  Use ReserveGoPackageAlias to get a unique alias.
+func defaultGoPackageName(f *descriptor.FileDescriptorProto) string {
+	name := packageIdentityName(f)
+	return sanitizePackageName(name)
+}
+
+// packageIdentityName returns the identity of packages.
+// protoc-gen-grpc-gateway rejects CodeGenerationRequests which contains more than one packages
+// as protoc-gen-go does.
+func packageIdentityName(f *descriptor.FileDescriptorProto) string {
+	if f.Options != nil && f.Options.GoPackage != nil {
+		gopkg := f.Options.GetGoPackage()
+		idx := strings.LastIndex(gopkg, "/")
+		if idx < 0 {
+			gopkg = gopkg[idx+1:]
+		}
+
+		gopkg = gopkg[idx+1:]
+		// package name is overrided with the string after the
+		// ';' character
+		sc := strings.IndexByte(gopkg, ';')
+		if sc < 0 {
+			return sanitizePackageName(gopkg)
+
+		}
+		return sanitizePackageName(gopkg[sc+1:])
+	}
+
+	if f.Package == nil {
+		base := filepath.Base(f.GetName())
+		ext := filepath.Ext(base)
+		return strings.TrimSuffix(base, ext)
+	}
+	return f.GetPackage()
+}
+
+func emitFiles(out []*plugin.CodeGeneratorResponse_File) {
+	emitResp(&plugin.CodeGeneratorResponse{File: out})
+}
+
+func emitError(err error) {
+	emitResp(&plugin.CodeGeneratorResponse{Error: proto.String(err.Error())})
+}
+
+func emitResp(resp *plugin.CodeGeneratorResponse) {
+	buf, err := proto.Marshal(resp)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if _, err := os.Stdout.Write(buf); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,819 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+
+	"github.com/f4tq/protoc-go-plugins/goname"
+	"github.com/f4tq/protoc-go-plugins/options"
+	"github.com/f4tq/protoc-go-plugins/protodoc"
+)
+
+var (
+	hdrTmpl = template.Must(template.New("header").Parse(`
+// Code generated by protoc-gen-go-faker. DO NOT EDIT.
+// source: {{.Source}}
+
+package {{.GoPkg}}
+
+import (
+    "github.com/f4tq/protoc-go-plugins/runtime/faker"
+{{- range $path, $name := .Imports}}
+    {{$name}} "{{$path}}"
+{{- end}}
+)
+`))
+
+	fakeTmpl = template.Must(template.New("fake").Parse(`
+// NewFake{{.Name}} returns the {{.Name}} fixture of seed, with its fields set
+// to realistic values satisfying their (f4tq.plugins.validate) bounds: the
+// same seed always returns the same message. Optional message fields nest
+// down to faker.MaxDepth.{{.Doc}}
+func NewFake{{.Name}}(seed int64) *{{.Name}} {
+    return fake{{.Name}}(faker.New(seed), faker.MaxDepth)
+}
+
+// fake{{.Name}} returns the {{.Name}} drawn from f, whose optional message
+// fields nest down to depth.
+func fake{{.Name}}(f *faker.Faker, depth int) *{{.Name}} {
+    m := new({{.Name}})
+{{.Fields -}}
+    return m
+}
+`))
+)
+
+// kinds are the Faker methods drawing the values of the kinds of string
+// fields of (f4tq.plugins.fake).
+var kinds = map[string]string{
+	"email":        "Email",
+	"name":         "Name",
+	"first_name":   "FirstName",
+	"last_name":    "LastName",
+	"username":     "Username",
+	"phone":        "Phone",
+	"url":          "URL",
+	"uuid":         "UUID",
+	"ipv4":         "IPv4",
+	"word":         "Word",
+	"sentence":     "Sentence",
+	"company":      "Company",
+	"city":         "City",
+	"country_code": "CountryCode",
+}
+
+func main() {
+	input, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	req := new(plugin.CodeGeneratorRequest)
+	if err = proto.Unmarshal(input, req); err != nil {
+		log.Fatal(err)
+	}
+
+	out, err := generate(req)
+
+	if err != nil {
+		emitError(err)
+		return
+	}
+
+	emitFiles(out)
+}
+
+// generate writes the fixture constructors of the messages of the files to
+// generate, nested ones included, to <file>.pb.faker.go. The message
+// fields of the messages of other Go packages, or of files not generated,
+// are left unset, or set to empty messages where required; those holding
+// a google.protobuf.Timestamp or Duration are always set.
+func generate(req *plugin.CodeGeneratorRequest) ([]*plugin.CodeGeneratorResponse_File, error) {
+	var files []*plugin.CodeGeneratorResponse_File
+	idx := newTypeIndex(req.GetProtoFile())
+	docs := protodoc.New(req.GetProtoFile())
+	genFileNames := make(map[string]bool)
+	for _, n := range req.FileToGenerate {
+		genFileNames[n] = true
+	}
+	for _, desc := range req.GetProtoFile() {
+		name := desc.GetName()
+		if _, ok := genFileNames[name]; !ok {
+			// Only emit output for files present in req.FileToGenerate.
+			continue
+		}
+		code, err := genCode(desc, idx, docs, genFileNames)
+		if err != nil {
+			return nil, err
+		}
+		if code == "" {
+			// The file declares no messages.
+			continue
+		}
+		formatted, err := format.Source([]byte(code))
+		if err != nil {
+			log.Printf("%v: %s", err, code)
+			return nil, err
+		}
+
+		ext := filepath.Ext(name)
+		base := strings.TrimSuffix(name, ext)
+		output := fmt.Sprintf("%s.pb.faker.go", base)
+		files = append(files, &plugin.CodeGeneratorResponse_File{
+			Name:    proto.String(output),
+			Content: proto.String(string(formatted)),
+		})
+	}
+
+	return files, nil
+}
+
+func genCode(desc *descriptor.FileDescriptorProto, idx *typeIndex, docs *protodoc.Index, genFileNames map[string]bool) (string, error) {
+	g := &fakeGen{
+		desc:         desc,
+		idx:          idx,
+		imports:      newImportSet(desc),
+		genFileNames: genFileNames,
+		proto3:       desc.GetSyntax() == "proto3",
+	}
+	body := bytes.NewBuffer(nil)
+	scope := strings.TrimSuffix("."+desc.GetPackage(), ".")
+	if err := g.messages(body, docs, scope, desc.GetMessageType()); err != nil {
+		return "", fmt.Errorf("%s: %v", desc.GetName(), err)
+	}
+	if body.Len() == 0 {
+		return "", nil
+	}
+
+	w := bytes.NewBuffer(nil)
+	hdr := &header{
+		Source:  desc.GetName(),
+		GoPkg:   defaultGoPackageName(desc),
+		Imports: g.imports.names,
+	}
+	if err := hdrTmpl.Execute(w, hdr); err != nil {
+		log.Fatal(err)
+	}
+	body.WriteTo(w)
+
+	return w.String(), nil
+}
+
+type header struct {
+	Source  string
+	GoPkg   string
+	Imports map[string]string
+}
+
+type fakeMessage struct {
+	Name string
+	// Fields are the statements setting the fields of m.
+	Fields string
+	// Doc is the comment of the message, see protodoc.Index.Godoc.
+	Doc string
+}
+
+type fakeGen struct {
+	desc         *descriptor.FileDescriptorProto
+	idx          *typeIndex
+	imports      *importSet
+	genFileNames map[string]bool
+	proto3       bool
+}
+
+// messages writes the constructors of msgs and of the messages nested in
+// them. scope is the full proto name of their parent, with a leading dot.
+func (g *fakeGen) messages(w *bytes.Buffer, docs *protodoc.Index, scope string, msgs []*descriptor.DescriptorProto) error {
+	for _, msg := range msgs {
+		if msg.GetOptions().GetMapEntry() {
+			continue
+		}
+		typeName := scope + "." + msg.GetName()
+		name := strings.TrimPrefix(typeName, ".")
+		if g.requiredCycle(typeName, typeName, make(map[string]bool)) {
+			return fmt.Errorf("%s: required message fields lead back to it, so no finite message is valid", name)
+		}
+		m := &fakeMessage{Name: localTypeName(typeName), Doc: docs.Godoc(typeName)}
+		fields := bytes.NewBuffer(nil)
+		goNames := goname.Fields(msg)
+		oneofs := make(map[int32][]*descriptor.FieldDescriptorProto)
+		for _, field := range msg.GetField() {
+			if field.OneofIndex != nil && !field.GetProto3Optional() {
+				i := field.GetOneofIndex()
+				if len(oneofs[i]) == 0 {
+					// The oneof is set at the place of its first member.
+					fmt.Fprintf(fields, "%s", oneofMarker(i))
+				}
+				oneofs[i] = append(oneofs[i], field)
+				continue
+			}
+			if err := g.field(fields, field, goNames[field.GetName()]); err != nil {
+				return fmt.Errorf("%s.%s: %v", name, field.GetName(), err)
+			}
+		}
+		code := fields.String()
+		for i, members := range oneofs {
+			oneof := bytes.NewBuffer(nil)
+			if err := g.oneof(oneof, m.Name, goNames, goname.Wrappers(msg), msg.GetOneofDecl()[i], members); err != nil {
+				return fmt.Errorf("%s.%s: %v", name, msg.GetOneofDecl()[i].GetName(), err)
+			}
+			code = strings.Replace(code, oneofMarker(i), oneof.String(), 1)
+		}
+		m.Fields = code
+		if err := fakeTmpl.Execute(w, m); err != nil {
+			return err
+		}
+		if err := g.messages(w, docs, typeName, msg.GetNestedType()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// oneofMarker marks the place of the oneof of index i among the statements
+// of the fields of a message.
+func oneofMarker(i int32) string {
+	return fmt.Sprintf("\x00oneof %d\x00", i)
+}
+
+// field writes the statement setting field, whose Go name is goName, unless
+// it is left unset.
+func (g *fakeGen) field(w *bytes.Buffer, field *descriptor.FieldDescriptorProto, goName string) error {
+	rules := options.Rules(field)
+	if rules == nil {
+		rules = new(options.FieldRules)
+	}
+	required := rules.GetRequired() || field.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REQUIRED
+
+	if field.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REPEATED {
+		min, max := bound(rules.MinItems, 0), bound(rules.MaxItems, -1)
+		if required && min == 0 {
+			min = 1
+		}
+		if max >= 0 && min > max {
+			return fmt.Errorf("min_items is greater than max_items")
+		}
+		// The values of map fields are drawn without rules, as
+		// protoc-gen-go-validate takes none for them.
+		value := field
+		if g.idx.isMap(field) {
+			value = g.idx.messages[field.GetTypeName()].GetField()[1]
+			rules = new(options.FieldRules)
+		}
+		count := fmt.Sprintf("f.Items(%d, %d)", min, max)
+		var elem string
+		switch {
+		case !g.isMessage(value):
+			v, err := g.value(value, rules, false)
+			if err != nil {
+				return err
+			}
+			elem = v
+		case g.local(value.GetTypeName()):
+			elem = fmt.Sprintf("fake%s(f, depth-1)", localTypeName(value.GetTypeName()))
+			count = fmt.Sprintf("f.NestedItems(depth, %d, %d)", min, max)
+		case min > 0:
+			elem = fmt.Sprintf("new(%s)", g.imports.goTypeName(g.idx, value.GetTypeName()))
+			count = strconv.Itoa(min)
+		default:
+			return nil
+		}
+		if g.idx.isMap(field) {
+			key, err := g.value(g.idx.messages[field.GetTypeName()].GetField()[0], new(options.FieldRules), false)
+			if err != nil {
+				return err
+			}
+			// Keys drawn twice leave the map with fewer entries.
+			fmt.Fprintf(w, "m.%s = make(map[%s]%s)\n", goName, g.goType(g.idx.messages[field.GetTypeName()].GetField()[0]), g.goType(value))
+			fmt.Fprintf(w, "for i := %s; i > 0; i-- {\nm.%s[%s] = %s\n}\n", count, goName, key, elem)
+			return nil
+		}
+		fmt.Fprintf(w, "m.%s = make([]%s, %s)\n", goName, g.goType(value), count)
+		fmt.Fprintf(w, "for i := range m.%s {\nm.%s[i] = %s\n}\n", goName, goName, elem)
+		return nil
+	}
+
+	if g.isMessage(field) {
+		switch {
+		case g.local(field.GetTypeName()) && required:
+			fmt.Fprintf(w, "m.%s = fake%s(f, depth-1)\n", goName, localTypeName(field.GetTypeName()))
+		case g.local(field.GetTypeName()):
+			fmt.Fprintf(w, "if depth > 0 {\nm.%s = fake%s(f, depth-1)\n}\n", goName, localTypeName(field.GetTypeName()))
+		case required:
+			fmt.Fprintf(w, "m.%s = new(%s)\n", goName, g.imports.goTypeName(g.idx, field.GetTypeName()))
+		}
+		return nil
+	}
+
+	v, err := g.value(field, rules, required)
+	if err != nil {
+		return err
+	}
+	if g.pointer(field) {
+		v = fmt.Sprintf("faker.Ptr(%s)", v)
+	}
+	fmt.Fprintf(w, "m.%s = %s\n", goName, v)
+	return nil
+}
+
+// oneof writes the statement setting one of the members of oneof, of the
+// message msgName whose fields and oneofs have the Go names goNames and
+// whose oneof fields have the wrapper types wrappers. Message members of
+// this package are only set above the greatest depth, and the others
+// never.
+func (g *fakeGen) oneof(w *bytes.Buffer, msgName string, goNames, wrappers map[string]string, oneof *descriptor.OneofDescriptorProto, members []*descriptor.FieldDescriptorProto) error {
+	fmt.Fprintf(w, "switch f.Intn(%d) {\n", len(members))
+	for i, field := range members {
+		goName := goNames[field.GetName()]
+		set := fmt.Sprintf("m.%s = &%s_%s{%s: %%s}\n", goNames[oneof.GetName()], msgName, wrappers[field.GetName()], goName)
+		fmt.Fprintf(w, "case %d:\n", i)
+		switch {
+		case g.isMessage(field) && g.local(field.GetTypeName()):
+			fmt.Fprintf(w, "if depth > 0 {\n"+set+"}\n", fmt.Sprintf("fake%s(f, depth-1)", localTypeName(field.GetTypeName())))
+		case g.isMessage(field):
+			fmt.Fprintf(w, set, fmt.Sprintf("new(%s)", g.imports.goTypeName(g.idx, field.GetTypeName())))
+		default:
+			rules := options.Rules(field)
+			if rules == nil {
+				rules = new(options.FieldRules)
+			}
+			v, err := g.value(field, rules, false)
+			if err != nil {
+				return fmt.Errorf("%s: %v", field.GetName(), err)
+			}
+			fmt.Fprintf(w, set, v)
+		}
+	}
+	fmt.Fprintf(w, "}\n")
+	return nil
+}
+
+// isMessage reports whether field holds messages other than the
+// timestamps and durations Faker draws, as groups do too.
+func (g *fakeGen) isMessage(field *descriptor.FieldDescriptorProto) bool {
+	switch field.GetTypeName() {
+	case ".google.protobuf.Timestamp", ".google.protobuf.Duration":
+		return false
+	}
+	return field.GetType() == descriptor.FieldDescriptorProto_TYPE_MESSAGE ||
+		field.GetType() == descriptor.FieldDescriptorProto_TYPE_GROUP
+}
+
+// local reports whether the message typeName has a constructor in the Go
+// package being generated.
+func (g *fakeGen) local(typeName string) bool {
+	f := g.idx.files[typeName]
+	return f != nil && g.genFileNames[f.GetName()] &&
+		goImportPath(f) == goImportPath(g.desc) && defaultGoPackageName(f) == defaultGoPackageName(g.desc)
+}
+
+// pointer reports whether the Go field of the singular scalar field is a
+// pointer.
+func (g *fakeGen) pointer(field *descriptor.FieldDescriptorProto) bool {
+	if field.GetType() == descriptor.FieldDescriptorProto_TYPE_MESSAGE ||
+		field.GetType() == descriptor.FieldDescriptorProto_TYPE_GROUP {
+		return false
+	}
+	return (field.GetProto3Optional() || !g.proto3) && field.GetType() != descriptor.FieldDescriptorProto_TYPE_BYTES
+}
+
+// requiredCycle reports whether the required message fields of the
+// message typeName, and those of the messages they hold, lead to target.
+func (g *fakeGen) requiredCycle(typeName, target string, seen map[string]bool) bool {
+	if seen[typeName] {
+		return false
+	}
+	seen[typeName] = true
+	for _, field := range g.idx.messages[typeName].GetField() {
+		value := field
+		if g.idx.isMap(field) {
+			value = g.idx.messages[field.GetTypeName()].GetField()[1]
+		}
+		if !g.isMessage(value) || !g.local(value.GetTypeName()) {
+			continue
+		}
+		if field.OneofIndex != nil && !field.GetProto3Optional() {
+			continue
+		}
+		rules := options.Rules(field)
+		if !rules.GetRequired() && field.GetLabel() != descriptor.FieldDescriptorProto_LABEL_REQUIRED &&
+			(rules == nil || rules.MinItems == nil || *rules.MinItems == 0) {
+			continue
+		}
+		if value.GetTypeName() == target || g.requiredCycle(value.GetTypeName(), target, seen) {
+			return true
+		}
+	}
+	return false
+}
+
+// numberType is the generation of the numbers of a proto type.
+type numberType struct {
+	goType string
+	// method is the Faker method drawing the numbers, and lo and hi the
+	// bounds of goType it may be given.
+	method  string
+	lo, hi  float64
+	integer bool
+	float32 bool
+}
+
+var (
+	int32Type  = &numberType{goType: "int32", method: "Int", lo: math.MinInt32, hi: math.MaxInt32, integer: true}
+	uint32Type = &numberType{goType: "uint32", method: "Uint", lo: 0, hi: math.MaxUint32, integer: true}
+	// The bounds of the 64-bit integers are the greatest float64 they
+	// hold, as their own are rounded up.
+	int64Type  = &numberType{goType: "int64", method: "Int", lo: math.MinInt64, hi: math.Nextafter(1<<63, 0), integer: true}
+	uint64Type = &numberType{goType: "uint64", method: "Uint", lo: 0, hi: math.Nextafter(1<<64, 0), integer: true}
+)
+
+var numberTypes = map[descriptor.FieldDescriptorProto_Type]*numberType{
+	descriptor.FieldDescriptorProto_TYPE_INT32:    int32Type,
+	descriptor.FieldDescriptorProto_TYPE_SINT32:   int32Type,
+	descriptor.FieldDescriptorProto_TYPE_SFIXED32: int32Type,
+	descriptor.FieldDescriptorProto_TYPE_UINT32:   uint32Type,
+	descriptor.FieldDescriptorProto_TYPE_FIXED32:  uint32Type,
+	descriptor.FieldDescriptorProto_TYPE_INT64:    int64Type,
+	descriptor.FieldDescriptorProto_TYPE_SINT64:   int64Type,
+	descriptor.FieldDescriptorProto_TYPE_SFIXED64: int64Type,
+	descriptor.FieldDescriptorProto_TYPE_UINT64:   uint64Type,
+	descriptor.FieldDescriptorProto_TYPE_FIXED64:  uint64Type,
+	descriptor.FieldDescriptorProto_TYPE_FLOAT:    {goType: "float32", method: "Float", lo: -math.MaxFloat32, hi: math.MaxFloat32, float32: true},
+	descriptor.FieldDescriptorProto_TYPE_DOUBLE:   {goType: "float64", method: "Float", lo: -math.MaxFloat64, hi: math.MaxFloat64},
+}
+
+// window is the width of the ranges numbers are drawn from when their
+// rules bound them on one side or none: a thousand above the lower bound,
+// or below the upper one, and [0, window] without bounds.
+const window = 1000
+
+// value returns the expression of a value of field satisfying rules, and
+// non-zero if required.
+func (g *fakeGen) value(field *descriptor.FieldDescriptorProto, rules *options.FieldRules, required bool) (string, error) {
+	typ := field.GetType()
+	kind := options.Fake(field)
+	switch field.GetTypeName() {
+	case ".google.protobuf.Timestamp":
+		if kind != "" && kind != "past" && kind != "future" {
+			return "", fmt.Errorf("fake %q does not apply to timestamps: use \"past\" or \"future\"", kind)
+		}
+		return fmt.Sprintf("f.Time(%q)", kind), nil
+	case ".google.protobuf.Duration":
+		if kind != "" {
+			return "", fmt.Errorf("fake %q does not apply to durations", kind)
+		}
+		return "f.Duration()", nil
+	}
+	if kind != "" && typ != descriptor.FieldDescriptorProto_TYPE_STRING {
+		return "", fmt.Errorf("fake only applies to string and google.protobuf.Timestamp fields")
+	}
+	if rules.MinLen != nil || rules.MaxLen != nil || rules.Pattern != nil {
+		if typ != descriptor.FieldDescriptorProto_TYPE_STRING && typ != descriptor.FieldDescriptorProto_TYPE_BYTES {
+			return "", fmt.Errorf("min_len, max_len and pattern only apply to strings and bytes")
+		}
+	}
+	switch typ {
+	case descriptor.FieldDescriptorProto_TYPE_BOOL:
+		if required {
+			return "true", nil
+		}
+		return "f.Bool()", nil
+	case descriptor.FieldDescriptorProto_TYPE_STRING, descriptor.FieldDescriptorProto_TYPE_BYTES:
+		min, max := bound(rules.MinLen, 0), bound(rules.MaxLen, -1)
+		if required && min == 0 {
+			min = 1
+		}
+		if max >= 0 && min > max {
+			return "", fmt.Errorf("min_len is greater than max_len")
+		}
+		if typ == descriptor.FieldDescriptorProto_TYPE_BYTES {
+			return fmt.Sprintf("f.Bytes(%d, %d)", min, max), nil
+		}
+		if kind == "" {
+			// The fixtures do not follow the patterns of the rules.
+			return fmt.Sprintf("f.Text(%d, %d)", min, max), nil
+		}
+		method, ok := kinds[kind]
+		if !ok {
+			return "", fmt.Errorf("unknown fake %q", kind)
+		}
+		if min > 0 || max >= 0 {
+			return fmt.Sprintf("faker.Clip(f.%s(), %d, %d)", method, min, max), nil
+		}
+		return fmt.Sprintf("f.%s()", method), nil
+	case descriptor.FieldDescriptorProto_TYPE_ENUM:
+		// UNSPECIFIED-like zero values are left out, but for enums that
+		// have no other.
+		var values []string
+		for _, v := range g.idx.enums[field.GetTypeName()].GetValue() {
+			if v.GetNumber() != 0 {
+				values = append(values, strconv.Itoa(int(v.GetNumber())))
+			}
+		}
+		if len(values) == 0 {
+			if required {
+				return "", fmt.Errorf("%s has no value to draw", strings.TrimPrefix(field.GetTypeName(), "."))
+			}
+			values = []string{"0"}
+		}
+		return fmt.Sprintf("%s(f.Pick(%s))", g.imports.goTypeName(g.idx, field.GetTypeName()), strings.Join(values, ", ")), nil
+	}
+	n := numberTypes[typ]
+	if n == nil {
+		return "", fmt.Errorf("%s fields are not supported", strings.ToLower(strings.TrimPrefix(typ.String(), "TYPE_")))
+	}
+	lo, hi, err := n.bounds(rules, required)
+	if err != nil {
+		return "", err
+	}
+	v := fmt.Sprintf("f.%s(%s, %s)", n.method, literal(lo, n.integer), literal(hi, n.integer))
+	switch n.goType {
+	case "int64", "uint64", "float64":
+		return v, nil
+	}
+	return fmt.Sprintf("%s(%s)", n.goType, v), nil
+}
+
+// bounds returns the range to draw the numbers satisfying rules from,
+// leaving 0 out if required.
+func (n *numberType) bounds(rules *options.FieldRules, required bool) (float64, float64, error) {
+	lo, hi := math.Inf(-1), math.Inf(1)
+	if rules.Gte != nil {
+		lo = *rules.Gte
+	}
+	if rules.Gt != nil {
+		gt := *rules.Gt
+		switch {
+		case n.integer:
+			gt = math.Floor(gt) + 1
+		case n.float32:
+			gt = float64(math.Nextafter32(float32(gt), float32(math.Inf(1))))
+		default:
+			gt = math.Nextafter(gt, math.Inf(1))
+		}
+		lo = math.Max(lo, gt)
+	}
+	if rules.Lte != nil {
+		hi = *rules.Lte
+	}
+	if rules.Lt != nil {
+		lt := *rules.Lt
+		switch {
+		case n.integer:
+			lt = math.Ceil(lt) - 1
+		case n.float32:
+			lt = float64(math.Nextafter32(float32(lt), float32(math.Inf(-1))))
+		default:
+			lt = math.Nextafter(lt, math.Inf(-1))
+		}
+		hi = math.Min(hi, lt)
+	}
+	switch {
+	case math.IsInf(lo, -1) && math.IsInf(hi, 1):
+		lo, hi = 0, window
+	case math.IsInf(lo, -1):
+		lo = hi - window
+	case math.IsInf(hi, 1):
+		hi = lo + window
+	}
+	lo, hi = math.Max(lo, n.lo), math.Min(hi, n.hi)
+	if n.integer {
+		lo, hi = math.Ceil(lo), math.Floor(hi)
+	}
+	if required && lo <= 0 && hi >= 0 {
+		switch {
+		case hi > 0:
+			lo = math.Min(1, hi)
+		case lo < 0:
+			hi = math.Max(-1, lo)
+		}
+	}
+	if lo > hi || required && lo == 0 && hi == 0 {
+		return 0, 0, fmt.Errorf("no %s satisfies the bounds", n.goType)
+	}
+	return lo, hi, nil
+}
+
+// bound returns the value of the length or count bound p, or def if unset.
+func bound(p *uint32, def int) int {
+	if p == nil {
+		return def
+	}
+	return int(*p)
+}
+
+// literal returns the Go literal of the number v.
+func literal(v float64, integer bool) string {
+	if integer {
+		return strconv.FormatFloat(v, 'f', 0, 64)
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// scalarTypes are the Go types of the scalar proto types.
+var scalarTypes = map[descriptor.FieldDescriptorProto_Type]string{
+	descriptor.FieldDescriptorProto_TYPE_INT32:    "int32",
+	descriptor.FieldDescriptorProto_TYPE_SINT32:   "int32",
+	descriptor.FieldDescriptorProto_TYPE_SFIXED32: "int32",
+	descriptor.FieldDescriptorProto_TYPE_UINT32:   "uint32",
+	descriptor.FieldDescriptorProto_TYPE_FIXED32:  "uint32",
+	descriptor.FieldDescriptorProto_TYPE_INT64:    "int64",
+	descriptor.FieldDescriptorProto_TYPE_SINT64:   "int64",
+	descriptor.FieldDescriptorProto_TYPE_SFIXED64: "int64",
+	descriptor.FieldDescriptorProto_TYPE_UINT64:   "uint64",
+	descriptor.FieldDescriptorProto_TYPE_FIXED64:  "uint64",
+	descriptor.FieldDescriptorProto_TYPE_FLOAT:    "float32",
+	descriptor.FieldDescriptorProto_TYPE_DOUBLE:   "float64",
+	descriptor.FieldDescriptorProto_TYPE_BOOL:     "bool",
+	descriptor.FieldDescriptorProto_TYPE_STRING:   "string",
+	descriptor.FieldDescriptorProto_TYPE_BYTES:    "[]byte",
+}
+
+// goType returns the Go type of a value of field.
+func (g *fakeGen) goType(field *descriptor.FieldDescriptorProto) string {
+	switch field.GetType() {
+	case descriptor.FieldDescriptorProto_TYPE_MESSAGE, descriptor.FieldDescriptorProto_TYPE_GROUP:
+		return "*" + g.imports.goTypeName(g.idx, field.GetTypeName())
+	case descriptor.FieldDescriptorProto_TYPE_ENUM:
+		return g.imports.goTypeName(g.idx, field.GetTypeName())
+	}
+	return scalarTypes[field.GetType()]
+}
+
+// typeIndex maps fully-qualified proto type names (".pkg.Msg") to their
+// descriptors and declaring files for every file in the request.
+type typeIndex struct {
+	messages map[string]*descriptor.DescriptorProto
+	enums    map[string]*descriptor.EnumDescriptorProto
+	files    map[string]*descriptor.FileDescriptorProto
+}
+
+func newTypeIndex(files []*descriptor.FileDescriptorProto) *typeIndex {
+	idx := &typeIndex{
+		messages: make(map[string]*descriptor.DescriptorProto),
+		enums:    make(map[string]*descriptor.EnumDescriptorProto),
+		files:    make(map[string]*descriptor.FileDescriptorProto),
+	}
+	for _, f := range files {
+		prefix := ""
+		if f.GetPackage() != "" {
+			prefix = "." + f.GetPackage()
+		}
+		idx.addMessages(f, prefix, f.GetMessageType())
+		idx.addEnums(f, prefix, f.GetEnumType())
+	}
+	return idx
+}
+
+func (idx *typeIndex) addMessages(f *descriptor.FileDescriptorProto, prefix string, msgs []*descriptor.DescriptorProto) {
+	for _, m := range msgs {
+		name := prefix + "." + m.GetName()
+		idx.messages[name] = m
+		idx.files[name] = f
+		idx.addMessages(f, name, m.GetNestedType())
+		idx.addEnums(f, name, m.GetEnumType())
+	}
+}
+
+func (idx *typeIndex) addEnums(f *descriptor.FileDescriptorProto, prefix string, enums []*descriptor.EnumDescriptorProto) {
+	for _, e := range enums {
+		name := prefix + "." + e.GetName()
+		idx.enums[name] = e
+		idx.files[name] = f
+	}
+}
+
+// isMap reports whether field is a map field.
+func (idx *typeIndex) isMap(field *descriptor.FieldDescriptorProto) bool {
+	if field.GetType() != descriptor.FieldDescriptorProto_TYPE_MESSAGE {
+		return false
+	}
+	return idx.messages[field.GetTypeName()].GetOptions().GetMapEntry()
+}
+
+// goImportPath returns the import path of the Go package generated from f,
+// or "" if go_package does not name one.
+func goImportPath(f *descriptor.FileDescriptorProto) string {
+	gopkg := f.GetOptions().GetGoPackage()
+	if sc := strings.IndexByte(gopkg, ';'); sc >= 0 {
+		gopkg = gopkg[:sc]
+	}
+	if !strings.Contains(gopkg, "/") {
+		return ""
+	}
+	return gopkg
+}
+
+// importSet records the Go packages referenced by the code generated for
+// one file, keyed by import path.
+type importSet struct {
+	self  string
+	names map[string]string
+}
+
+func newImportSet(f *descriptor.FileDescriptorProto) *importSet {
+	return &importSet{self: goImportPath(f), names: make(map[string]string)}
+}
+
+// goTypeName returns the Go name of the message or enum typeName, qualified
+// with its package if it is declared outside the generated package.
+func (s *importSet) goTypeName(idx *typeIndex, typeName string) string {
+	name := localTypeName(typeName)
+	f := idx.files[typeName]
+	path := goImportPath(f)
+	if f == nil || path == "" || path == s.self {
+		return name
+	}
+	pkg := defaultGoPackageName(f)
+	s.names[path] = pkg
+	return pkg + "." + name
+}
+
+// localTypeName returns the Go name of a type declared in the file being
+// generated, e.g. ".pkg.Outer.Inner" becomes "Outer_Inner".
+func localTypeName(typeName string) string {
+	parts := strings.Split(strings.TrimPrefix(typeName, "."), ".")
+	var names []string
+	for _, p := range parts {
+		if len(p) > 0 && p[0] >= 'A' && p[0] <= 'Z' {
+			names = append(names, p)
+		}
+	}
+	return strings.Join(names, "_")
+}
+
+// sanitizePackageName replaces unallowed character in package name
+// with allowed character.
+func sanitizePackageName(pkgName string) string {
+	pkgName = strings.Replace(pkgName, ".", "_", -1)
+	pkgName = strings.Replace(pkgName, "-", "_", -1)
+	return pkgName
+}
+
+// defaultGoPackageName returns the default go package name to be used for go files generated from "f".
+// You might need to use an unique alias for the package when you import it.  Use ReserveGoPackageAlias to get a unique alias.
+func defaultGoPackageName(f *descriptor.FileDescriptorProto) string {
+	name := packageIdentityName(f)
+	return sanitizePackageName(name)
+}
+
+// packageIdentityName returns the identity of packages.
+// protoc-gen-grpc-gateway rejects CodeGenerationRequests which contains more than one packages
+// as protoc-gen-go does.
+func packageIdentityName(f *descriptor.FileDescriptorProto) string {
+	if f.Options != nil && f.Options.GoPackage != nil {
+		gopkg := f.Options.GetGoPackage()
+		idx := strings.LastIndex(gopkg, "/")
+		if idx < 0 {
+			gopkg = gopkg[idx+1:]
+		}
+
+		gopkg = gopkg[idx+1:]
+		// package name is overrided with the string after the
+		// ';' character
+		sc := strings.IndexByte(gopkg, ';')
+		if sc < 0 {
+			return sanitizePackageName(gopkg)
+
+		}
+		return sanitizePackageName(gopkg[sc+1:])
+	}
+
+	if f.Package == nil {
+		base := filepath.Base(f.GetName())
+		ext := filepath.Ext(base)
+		return strings.TrimSuffix(base, ext)
+	}
+	return f.GetPackage()
+}
+
+func emitFiles(out []*plugin.CodeGeneratorResponse_File) {
+	emitResp(&plugin.CodeGeneratorResponse{File: out})
+}
+
+func emitError(err error) {
+	emitResp(&plugin.CodeGeneratorResponse{Error: proto.String(err.Error())})
+}
+
+func emitResp(resp *plugin.CodeGeneratorResponse) {
+	buf, err := proto.Marshal(resp)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if _, err := os.Stdout.Write(buf); err != nil {
+		log.Fatal(err)
+	}
+}
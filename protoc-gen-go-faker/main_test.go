@@ -0,0 +1,60 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/f4tq/protoc-go-plugins/internal/plugintest"
+)
+
+const groupProto = `
+syntax = "proto2";
+
+package doc.v1;
+
+option go_package = "example.com/doc/v1;docv1";
+
+message Doc {
+    optional group Meta = 1 {
+        optional string author = 2;
+        repeated group Tag = 3 {
+            required string name = 4;
+        }
+    }
+    repeated group Part = 5 {
+        optional bytes data = 6;
+    }
+    oneof body {
+        group Text = 7 {
+            optional string value = 8;
+        }
+    }
+    optional Doc parent = 9;
+}
+`
+
+// groupTest runs in the package generated for groupProto.
+const groupTest = `package docv1
+
+import (
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+)
+
+func TestFake(t *testing.T) {
+	for seed := int64(0); seed < 20; seed++ {
+		if m := NewFakeDoc(seed); !proto.Equal(m, NewFakeDoc(seed)) {
+			t.Errorf("NewFakeDoc(%d) differs from one call to the next", seed)
+		}
+	}
+}
+`
+
+// TestGroups runs the constructors of messages with group fields, which
+// are drawn as the messages they are.
+func TestGroups(t *testing.T) {
+	req := plugintest.Request(t, "", map[string]string{"doc/v1/doc.proto": groupProto})
+	pkg := plugintest.Package(t, req, generate)
+	plugintest.WriteFile(t, pkg, "fake_test.go", groupTest)
+	plugintest.Go(t, "test", pkg)
+}
@@ -0,0 +1,521 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+
+	"github.com/f4tq/protoc-go-plugins/goname"
+	"github.com/f4tq/protoc-go-plugins/protodoc"
+)
+
+const fieldMaskType = ".google.protobuf.FieldMask"
+
+var (
+	hdrTmpl = template.Must(template.New("header").Parse(`
+// Code generated by protoc-gen-go-fieldmask-update. DO NOT EDIT.
+// source: {{.Source}}
+
+package {{.GoPkg}}
+
+import (
+    "strings"
+
+    "github.com/golang/protobuf/proto"
+    "google.golang.org/grpc/codes"
+    "google.golang.org/grpc/status"
+{{- range $path, $name := .Imports}}
+    {{$name}} "{{$path}}"
+{{- end}}
+)
+`))
+
+	resourceTmpl = template.Must(template.New("resource").Parse(`
+// Validate{{.Name}}UpdateMask checks that every path of mask names a field
+// of {{.Name}}. Paths may descend into message fields, and "*" selects every
+// field of the message it ends in. The error is an InvalidArgument status.{{.Doc}}
+func Validate{{.Name}}UpdateMask(mask *{{.Mask}}) error {
+    for _, p := range mask.GetPaths() {
+        if !{{.Lower}}MaskValid(strings.Split(p, ".")) {
+            return status.Errorf(codes.InvalidArgument, "invalid update_mask path %q for {{.Full}}", p)
+        }
+    }
+    return nil
+}
+
+// Apply{{.Name}}Update sets the fields of dst selected by mask to their
+// value in src, clearing those unset in src. An empty mask selects the
+// populated fields of src. Nothing is changed if a path is invalid. dst may
+// share repeated, map and message values with src afterwards.{{.Doc}}
+func Apply{{.Name}}Update(dst, src *{{.Name}}, mask *{{.Mask}}) error {
+    if err := Validate{{.Name}}UpdateMask(mask); err != nil {
+        return err
+    }
+    paths := mask.GetPaths()
+    if len(paths) == 0 {
+        paths = {{.Lower}}MaskPopulated(src)
+    }
+    for _, p := range paths {
+        {{.Lower}}MaskApply(dst, src, strings.Split(p, "."))
+    }
+    return nil
+}
+
+// {{.Lower}}MaskPopulated returns the paths of the fields set in m.
+func {{.Lower}}MaskPopulated(m *{{.Name}}) []string {
+    var paths []string
+{{- range .Fields}}
+    if {{.Populated}} {
+        paths = append(paths, {{printf "%q" .Proto}})
+    }
+{{- end}}
+    return paths
+}
+`))
+
+	messageTmpl = template.Must(template.New("message").Parse(`
+func {{.Lower}}MaskValid(path []string) bool {
+    switch path[0] {
+    case "*":
+        return len(path) == 1
+{{- range .Fields}}
+    case {{printf "%q" .Proto}}:
+{{- if .Nested}}
+        return len(path) == 1 || {{.Nested}}MaskValid(path[1:])
+{{- else}}
+        return len(path) == 1
+{{- end}}
+{{- end}}
+    }
+    return false
+}
+
+func {{.Lower}}MaskApply(dst, src *{{.Name}}, path []string) {
+    switch path[0] {
+    case "*":
+        dst.Reset()
+        proto.Merge(dst, src)
+{{- range .Fields}}
+    case {{printf "%q" .Proto}}:
+{{- if .Wrapper}}
+        if _, ok := src.{{.Oneof}}.(*{{.Wrapper}}); ok {
+            dst.{{.Oneof}} = src.{{.Oneof}}
+        } else if _, ok := dst.{{.Oneof}}.(*{{.Wrapper}}); ok {
+            dst.{{.Oneof}} = nil
+        }
+{{- else if .Nested}}
+        if len(path) == 1 {
+            dst.{{.Go}} = src.{{.Go}}
+            return
+        }
+        from := src.{{.Go}}
+        if from == nil {
+            from = &{{.Type}}{}
+        }
+        if dst.{{.Go}} == nil {
+            dst.{{.Go}} = &{{.Type}}{}
+        }
+        {{.Nested}}MaskApply(dst.{{.Go}}, from, path[1:])
+{{- else}}
+        dst.{{.Go}} = src.{{.Go}}
+{{- end}}
+{{- end}}
+    }
+}
+`))
+)
+
+func main() {
+	input, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	req := new(plugin.CodeGeneratorRequest)
+	if err = proto.Unmarshal(input, req); err != nil {
+		log.Fatal(err)
+	}
+
+	out, err := generate(req)
+
+	if err != nil {
+		emitError(err)
+		return
+	}
+
+	emitFiles(out)
+}
+
+func generate(req *plugin.CodeGeneratorRequest) ([]*plugin.CodeGeneratorResponse_File, error) {
+	var files []*plugin.CodeGeneratorResponse_File
+	idx := newTypeIndex(req.GetProtoFile())
+	docs := protodoc.New(req.GetProtoFile())
+	genFileNames := make(map[string]bool)
+	for _, n := range req.FileToGenerate {
+		genFileNames[n] = true
+	}
+	for _, desc := range req.GetProtoFile() {
+		name := desc.GetName()
+		if _, ok := genFileNames[name]; !ok {
+			// Only emit output for files present in req.FileToGenerate.
+			continue
+		}
+		code, err := genCode(desc, idx, docs)
+		if err != nil {
+			return nil, err
+		}
+		if code == "" {
+			// No Update method with an update_mask.
+			continue
+		}
+		formatted, err := format.Source([]byte(code))
+		if err != nil {
+			log.Printf("%v: %s", err, code)
+			return nil, err
+		}
+
+		ext := filepath.Ext(name)
+		base := strings.TrimSuffix(name, ext)
+		output := fmt.Sprintf("%s.pb.fieldmask.go", base)
+		files = append(files, &plugin.CodeGeneratorResponse_File{
+			Name:    proto.String(output),
+			Content: proto.String(string(formatted)),
+		})
+	}
+
+	return files, nil
+}
+
+func genCode(desc *descriptor.FileDescriptorProto, idx *typeIndex, docs *protodoc.Index) (string, error) {
+	w := bytes.NewBuffer(nil)
+	imports := newImportSet(desc)
+	g := &maskGen{desc: desc, idx: idx, seen: make(map[string]bool)}
+
+	// The resources are the messages returned by unary methods whose
+	// request has an update_mask next to a field of the returned type, as
+	// in AIP-134. Only resources declared in this file get helpers.
+	var resources []string
+	for _, svc := range desc.GetService() {
+		for _, m := range svc.GetMethod() {
+			if m.GetClientStreaming() || m.GetServerStreaming() || !isUpdate(idx, m) {
+				continue
+			}
+			if idx.files[m.GetOutputType()] != desc {
+				log.Printf("%s: %s is declared in another file, skipped", m.GetName(), m.GetOutputType())
+				continue
+			}
+			if !g.seen[m.GetOutputType()] {
+				g.seen[m.GetOutputType()] = true
+				resources = append(resources, m.GetOutputType())
+			}
+		}
+	}
+	if len(resources) == 0 {
+		return "", nil
+	}
+
+	body := bytes.NewBuffer(nil)
+	mask := imports.goTypeName(idx, fieldMaskType)
+	for _, typeName := range resources {
+		msg := g.message(typeName)
+		msg.Mask = mask
+		msg.Full = strings.TrimPrefix(typeName, ".")
+		msg.Doc = docs.Godoc(typeName)
+		if err := resourceTmpl.Execute(body, msg); err != nil {
+			return "", err
+		}
+	}
+	// g.queue grows as nested messages are reached.
+	for i := 0; i < len(g.queue); i++ {
+		if err := messageTmpl.Execute(body, g.queue[i]); err != nil {
+			return "", err
+		}
+	}
+
+	hdr := &header{
+		Source:  desc.GetName(),
+		GoPkg:   defaultGoPackageName(desc),
+		Imports: imports.names,
+	}
+	if err := hdrTmpl.Execute(w, hdr); err != nil {
+		log.Fatal(err)
+	}
+	body.WriteTo(w)
+
+	return w.String(), nil
+}
+
+// isUpdate reports whether the request of m has a FieldMask update_mask
+// and a singular field of the response type.
+func isUpdate(idx *typeIndex, m *descriptor.MethodDescriptorProto) bool {
+	in := idx.messages[m.GetInputType()]
+	var hasMask, hasResource bool
+	for _, f := range in.GetField() {
+		if f.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REPEATED {
+			continue
+		}
+		switch {
+		case f.GetName() == "update_mask" && f.GetTypeName() == fieldMaskType:
+			hasMask = true
+		case f.GetTypeName() == m.GetOutputType():
+			hasResource = true
+		}
+	}
+	return hasMask && hasResource
+}
+
+type maskGen struct {
+	desc  *descriptor.FileDescriptorProto
+	idx   *typeIndex
+	seen  map[string]bool
+	queue []*maskMessage
+}
+
+// message returns the template data of the message typeName and queues it
+// for generation, along with the messages of this file it has fields of.
+func (g *maskGen) message(typeName string) *maskMessage {
+	name := localTypeName(typeName)
+	msg := &maskMessage{Name: name, Lower: strings.ToLower(name[:1]) + name[1:]}
+	g.queue = append(g.queue, msg)
+	desc := g.idx.messages[typeName]
+	proto3 := g.desc.GetSyntax() == "proto3"
+	goNames := goname.Fields(desc)
+	for _, field := range desc.GetField() {
+		goName := goNames[field.GetName()]
+		f := &maskField{Proto: field.GetName(), Go: goName}
+		repeated := field.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REPEATED
+		switch {
+		case field.OneofIndex != nil && !field.GetProto3Optional():
+			f.Oneof = goNames[desc.GetOneofDecl()[field.GetOneofIndex()].GetName()]
+			f.Wrapper = name + "_" + goName
+			f.Populated = fmt.Sprintf("_, ok := m.%s.(*%s); ok", f.Oneof, f.Wrapper)
+		case repeated, field.GetType() == descriptor.FieldDescriptorProto_TYPE_BYTES:
+			f.Populated = fmt.Sprintf("len(m.%s) > 0", goName)
+		case field.GetType() == descriptor.FieldDescriptorProto_TYPE_MESSAGE,
+			field.GetType() == descriptor.FieldDescriptorProto_TYPE_GROUP,
+			field.GetProto3Optional(), !proto3:
+			f.Populated = fmt.Sprintf("m.%s != nil", goName)
+		case field.GetType() == descriptor.FieldDescriptorProto_TYPE_STRING:
+			f.Populated = fmt.Sprintf("m.%s != \"\"", goName)
+		case field.GetType() == descriptor.FieldDescriptorProto_TYPE_BOOL:
+			f.Populated = "m." + goName
+		default:
+			f.Populated = fmt.Sprintf("m.%s != 0", goName)
+		}
+		// Paths only descend into singular messages of this file; others
+		// are replaced as a whole.
+		if f.Wrapper == "" && !repeated && field.GetType() == descriptor.FieldDescriptorProto_TYPE_MESSAGE &&
+			g.idx.files[field.GetTypeName()] == g.desc {
+			nested := localTypeName(field.GetTypeName())
+			f.Type = nested
+			f.Nested = strings.ToLower(nested[:1]) + nested[1:]
+			if !g.seen[field.GetTypeName()] {
+				g.seen[field.GetTypeName()] = true
+				g.message(field.GetTypeName())
+			}
+		}
+		msg.Fields = append(msg.Fields, f)
+	}
+	return msg
+}
+
+type header struct {
+	Source  string
+	GoPkg   string
+	Imports map[string]string
+}
+
+type maskMessage struct {
+	Name   string
+	Lower  string
+	Full   string
+	Mask   string
+	Fields []*maskField
+	// Doc is the comment of the message, see protodoc.Index.Godoc.
+	Doc string
+}
+
+type maskField struct {
+	Proto     string
+	Go        string
+	Oneof     string
+	Wrapper   string
+	Type      string
+	Nested    string
+	Populated string
+}
+
+// typeIndex maps fully-qualified proto type names (".pkg.Msg") to their
+// descriptors and declaring files for every file in the request.
+type typeIndex struct {
+	messages map[string]*descriptor.DescriptorProto
+	enums    map[string]*descriptor.EnumDescriptorProto
+	files    map[string]*descriptor.FileDescriptorProto
+}
+
+func newTypeIndex(files []*descriptor.FileDescriptorProto) *typeIndex {
+	idx := &typeIndex{
+		messages: make(map[string]*descriptor.DescriptorProto),
+		enums:    make(map[string]*descriptor.EnumDescriptorProto),
+		files:    make(map[string]*descriptor.FileDescriptorProto),
+	}
+	for _, f := range files {
+		prefix := ""
+		if f.GetPackage() != "" {
+			prefix = "." + f.GetPackage()
+		}
+		idx.addMessages(f, prefix, f.GetMessageType())
+		idx.addEnums(f, prefix, f.GetEnumType())
+	}
+	return idx
+}
+
+func (idx *typeIndex) addMessages(f *descriptor.FileDescriptorProto, prefix string, msgs []*descriptor.DescriptorProto) {
+	for _, m := range msgs {
+		name := prefix + "." + m.GetName()
+		idx.messages[name] = m
+		idx.files[name] = f
+		idx.addMessages(f, name, m.GetNestedType())
+		idx.addEnums(f, name, m.GetEnumType())
+	}
+}
+
+func (idx *typeIndex) addEnums(f *descriptor.FileDescriptorProto, prefix string, enums []*descriptor.EnumDescriptorProto) {
+	for _, e := range enums {
+		name := prefix + "." + e.GetName()
+		idx.enums[name] = e
+		idx.files[name] = f
+	}
+}
+
+// isMap reports whether field is a map field.
+func (idx *typeIndex) isMap(field *descriptor.FieldDescriptorProto) bool {
+	if field.GetType() != descriptor.FieldDescriptorProto_TYPE_MESSAGE {
+		return false
+	}
+	return idx.messages[field.GetTypeName()].GetOptions().GetMapEntry()
+}
+
+// goImportPath returns the import path of the Go package generated from f,
+// or "" if go_package does not name one.
+func goImportPath(f *descriptor.FileDescriptorProto) string {
+	gopkg := f.GetOptions().GetGoPackage()
+	if sc := strings.IndexByte(gopkg, ';'); sc >= 0 {
+		gopkg = gopkg[:sc]
+	}
+	if !strings.Contains(gopkg, "/") {
+		return ""
+	}
+	return gopkg
+}
+
+// importSet records the Go packages referenced by the code generated for
+// one file, keyed by import path.
+type importSet struct {
+	self  string
+	names map[string]string
+}
+
+func newImportSet(f *descriptor.FileDescriptorProto) *importSet {
+	return &importSet{self: goImportPath(f), names: make(map[string]string)}
+}
+
+// goTypeName returns the Go name of the message or enum typeName, qualified
+// with its package if it is declared outside the generated package.
+func (s *importSet) goTypeName(idx *typeIndex, typeName string) string {
+	name := localTypeName(typeName)
+	f := idx.files[typeName]
+	path := goImportPath(f)
+	if f == nil || path == "" || path == s.self {
+		return name
+	}
+	pkg := defaultGoPackageName(f)
+	s.names[path] = pkg
+	return pkg + "." + name
+}
+
+// localTypeName returns the Go name of a type declared in the file being
+// generated, e.g. ".pkg.Outer.Inner" becomes "Outer_Inner".
+func localTypeName(typeName string) string {
+	parts := strings.Split(strings.TrimPrefix(typeName, "."), ".")
+	var names []string
+	for _, p := range parts {
+		if len(p) > 0 && p[0] >= 'A' && p[0] <= 'Z' {
+			names = append(names, p)
+		}
+	}
+	return strings.Join(names, "_")
+}
+
+// sanitizePackageName replaces unallowed character in package name
+// with allowed character.
+func sanitizePackageName(pkgName string) string {
+	pkgName = strings.Replace(pkgName, ".", "_", -1)
+	pkgName = strings.Replace(pkgName, "-", "_", -1)
+	return pkgName
+}
+
+// defaultGoPackageName returns the default go package name to be used for go files generated from "f".
+// You might need to use an unique alias for the package when you import it.  Use ReserveGoPackageAlias to get a unique alias.
+func defaultGoPackageName(f *descriptor.FileDescriptorProto) string {
+	name := packageIdentityName(f)
+	return sanitizePackageName(name)
+}
+
+// packageIdentityName returns the identity of packages.
+// protoc-gen-grpc-gateway rejects CodeGenerationRequests which contains more than one packages
+// as protoc-gen-go does.
+func packageIdentityName(f *descriptor.FileDescriptorProto) string {
+	if f.Options != nil && f.Options.GoPackage != nil {
+		gopkg := f.Options.GetGoPackage()
+		idx := strings.LastIndex(gopkg, "/")
+		if idx < 0 {
+			gopkg = gopkg[idx+1:]
+		}
+
+		gopkg = gopkg[idx+1:]
+		// package name is overrided with the string after the
+		// ';' character
+		sc := strings.IndexByte(gopkg, ';')
+		if sc < 0 {
+			return sanitizePackageName(gopkg)
+
+		}
+		return sanitizePackageName(gopkg[sc+1:])
+	}
+
+	if f.Package == nil {
+		base := filepath.Base(f.GetName())
+		ext := filepath.Ext(base)
+		return strings.TrimSuffix(base, ext)
+	}
+	return f.GetPackage()
+}
+
+func emitFiles(out []*plugin.CodeGeneratorResponse_File) {
+	emitResp(&plugin.CodeGeneratorResponse{File: out})
+}
+
+func emitError(err error) {
+	emitResp(&plugin.CodeGeneratorResponse{Error: proto.String(err.Error())})
+}
+
+func emitResp(resp *plugin.CodeGeneratorResponse) {
+	buf, err := proto.Marshal(resp)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if _, err := os.Stdout.Write(buf); err != nil {
+		log.Fatal(err)
+	}
+}
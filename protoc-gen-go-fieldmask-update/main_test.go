@@ -0,0 +1,159 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+	gengo "google.golang.org/protobuf/cmd/protoc-gen-go/internal_gengo"
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+
+	"github.com/f4tq/protoc-go-plugins/goname"
+)
+
+func field(name string, number int32, typ descriptor.FieldDescriptorProto_Type, typeName string) *descriptor.FieldDescriptorProto {
+	f := &descriptor.FieldDescriptorProto{
+		Name:     proto.String(name),
+		JsonName: proto.String(goname.CamelCase(name)),
+		Number:   proto.Int32(number),
+		Label:    descriptor.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+		Type:     typ.Enum(),
+	}
+	if typeName != "" {
+		f.TypeName = proto.String(typeName)
+	}
+	return f
+}
+
+// request returns the request of a protoc run on a resource with an
+// Update method, covering the kinds of fields the helpers treat apart and
+// a field protoc-gen-go renames, String_.
+func request() *plugin.CodeGeneratorRequest {
+	optional := func(f *descriptor.FieldDescriptorProto, oneof int32) *descriptor.FieldDescriptorProto {
+		f.Proto3Optional = proto.Bool(true)
+		f.OneofIndex = proto.Int32(oneof)
+		return f
+	}
+	member := func(f *descriptor.FieldDescriptorProto) *descriptor.FieldDescriptorProto {
+		f.OneofIndex = proto.Int32(0)
+		return f
+	}
+	tags := field("tags", 5, descriptor.FieldDescriptorProto_TYPE_STRING, "")
+	tags.Label = descriptor.FieldDescriptorProto_LABEL_REPEATED.Enum()
+	file := &descriptor.FileDescriptorProto{
+		Name:       proto.String("doc/v1/doc.proto"),
+		Package:    proto.String("doc.v1"),
+		Syntax:     proto.String("proto3"),
+		Dependency: []string{"google/protobuf/field_mask.proto"},
+		Options:    &descriptor.FileOptions{GoPackage: proto.String("example.com/doc/v1;docv1")},
+		EnumType: []*descriptor.EnumDescriptorProto{{
+			Name:  proto.String("Color"),
+			Value: []*descriptor.EnumValueDescriptorProto{{Name: proto.String("COLOR_UNSPECIFIED"), Number: proto.Int32(0)}},
+		}},
+		MessageType: []*descriptor.DescriptorProto{
+			{
+				Name: proto.String("Doc"),
+				Field: []*descriptor.FieldDescriptorProto{
+					field("name", 1, descriptor.FieldDescriptorProto_TYPE_STRING, ""),
+					field("data", 2, descriptor.FieldDescriptorProto_TYPE_BYTES, ""),
+					field("parent", 3, descriptor.FieldDescriptorProto_TYPE_MESSAGE, ".doc.v1.Doc"),
+					member(field("url", 4, descriptor.FieldDescriptorProto_TYPE_STRING, "")),
+					tags,
+					optional(field("color", 6, descriptor.FieldDescriptorProto_TYPE_ENUM, ".doc.v1.Color"), 1),
+					optional(field("blob", 7, descriptor.FieldDescriptorProto_TYPE_BYTES, ""), 2),
+					field("string", 8, descriptor.FieldDescriptorProto_TYPE_STRING, ""),
+				},
+				OneofDecl: []*descriptor.OneofDescriptorProto{
+					{Name: proto.String("source")},
+					{Name: proto.String("_color")},
+					{Name: proto.String("_blob")},
+				},
+			},
+			{
+				Name: proto.String("UpdateDocRequest"),
+				Field: []*descriptor.FieldDescriptorProto{
+					field("doc", 1, descriptor.FieldDescriptorProto_TYPE_MESSAGE, ".doc.v1.Doc"),
+					field("update_mask", 2, descriptor.FieldDescriptorProto_TYPE_MESSAGE, fieldMaskType),
+				},
+			},
+		},
+		Service: []*descriptor.ServiceDescriptorProto{{
+			Name: proto.String("Docs"),
+			Method: []*descriptor.MethodDescriptorProto{{
+				Name:       proto.String("UpdateDoc"),
+				InputType:  proto.String(".doc.v1.UpdateDocRequest"),
+				OutputType: proto.String(".doc.v1.Doc"),
+			}},
+		}},
+	}
+	return &plugin.CodeGeneratorRequest{
+		FileToGenerate: []string{file.GetName()},
+		ProtoFile: []*descriptor.FileDescriptorProto{
+			protodesc.ToFileDescriptorProto(fieldmaskpb.File_google_protobuf_field_mask_proto),
+			file,
+		},
+	}
+}
+
+// TestGeneratedCodeBuilds vets the generated helpers along with the
+// protoc-gen-go output of their messages, so that calls to APIs the
+// runtimes do not have fail here rather than in the builds of users.
+func TestGeneratedCodeBuilds(t *testing.T) {
+	goTool, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go command not found")
+	}
+	req := request()
+
+	// The package is written inside this module, so that it builds
+	// against the same versions of the runtimes.
+	dir, err := os.MkdirTemp(".", "_gen")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	write := func(name, content string) {
+		if err := os.WriteFile(filepath.Join(dir, filepath.Base(name)), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	gen, err := protogen.Options{}.New(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range gen.Files {
+		if f.Generate {
+			gengo.GenerateFile(gen, f)
+		}
+	}
+	resp := gen.Response()
+	if resp.Error != nil {
+		t.Fatal(resp.GetError())
+	}
+	for _, f := range resp.GetFile() {
+		write(f.GetName(), f.GetContent())
+	}
+
+	files, err := generate(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 {
+		t.Fatalf("got %d files, want 1", len(files))
+	}
+	for _, f := range files {
+		write(f.GetName(), f.GetContent())
+	}
+
+	cmd := exec.Command(goTool, "vet", "./"+filepath.Base(dir))
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("generated code does not build: %v\n%s", err, out)
+	}
+}
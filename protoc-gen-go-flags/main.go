@@ -0,0 +1,525 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+
+	"github.com/f4tq/protoc-go-plugins/goname"
+	"github.com/f4tq/protoc-go-plugins/options"
+	"github.com/f4tq/protoc-go-plugins/protodoc"
+)
+
+// durationType is the proto type bound with flagbind.Duration.
+const durationType = ".google.protobuf.Duration"
+
+// flagFuncs are the pflag functions binding the Go types of scalars, and
+// sliceFlagFuncs those binding the slices of repeated fields.
+var (
+	flagFuncs = map[string]string{
+		"string":  "String",
+		"bool":    "Bool",
+		"int32":   "Int32",
+		"int64":   "Int64",
+		"uint32":  "Uint32",
+		"uint64":  "Uint64",
+		"float32": "Float32",
+		"float64": "Float64",
+	}
+	sliceFlagFuncs = map[string]string{
+		"string":  "StringSlice",
+		"bool":    "BoolSlice",
+		"int32":   "Int32Slice",
+		"int64":   "Int64Slice",
+		"float32": "Float32Slice",
+		"float64": "Float64Slice",
+	}
+)
+
+var (
+	hdrTmpl = template.Must(template.New("header").Parse(`
+// Code generated by protoc-gen-go-flags. DO NOT EDIT.
+// source: {{.Source}}
+
+package {{.GoPkg}}
+
+import (
+{{- range $path, $name := .Imports}}
+    {{$name}} "{{$path}}"
+{{- end}}
+)
+`))
+
+	flagsTmpl = template.Must(template.New("flags").Parse(`
+// RegisterFlags binds the fields of m to flags of fs named after them in
+// kebab case, following prefix: prefix+"field-name". The flags default to
+// the current values of m, so set its defaults first. The fields of
+// message fields, allocated if nil, take prefix+"field-name.". Oneofs,
+// timestamps, repeated messages and maps other than map<string, string>
+// are not bound.
+func (m *{{.Name}}) RegisterFlags(fs *pflag.FlagSet, prefix string) {
+{{- range .Fields}}
+{{.}}
+{{- end}}
+}
+`))
+)
+
+func main() {
+	input, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	req := new(plugin.CodeGeneratorRequest)
+	if err = proto.Unmarshal(input, req); err != nil {
+		log.Fatal(err)
+	}
+
+	out, err := generate(req)
+
+	if err != nil {
+		emitError(err)
+		return
+	}
+
+	emitFiles(out)
+}
+
+func generate(req *plugin.CodeGeneratorRequest) ([]*plugin.CodeGeneratorResponse_File, error) {
+	var files []*plugin.CodeGeneratorResponse_File
+	idx := newTypeIndex(req.GetProtoFile())
+	docs := protodoc.New(req.GetProtoFile())
+	genFileNames := make(map[string]bool)
+	for _, n := range req.FileToGenerate {
+		genFileNames[n] = true
+	}
+	has := flagMessages(idx, genFileNames)
+	for _, desc := range req.GetProtoFile() {
+		name := desc.GetName()
+		if _, ok := genFileNames[name]; !ok {
+			// Only emit output for files present in req.FileToGenerate.
+			continue
+		}
+		code, err := genCode(desc, idx, docs, has)
+		if err != nil {
+			return nil, err
+		}
+		if code == "" {
+			// The file declares no configuration messages, nor messages
+			// they hold.
+			continue
+		}
+		formatted, err := format.Source([]byte(code))
+		if err != nil {
+			log.Printf("%v: %s", err, code)
+			return nil, err
+		}
+
+		ext := filepath.Ext(name)
+		base := strings.TrimSuffix(name, ext)
+		output := fmt.Sprintf("%s.pb.flags.go", base)
+		files = append(files, &plugin.CodeGeneratorResponse_File{
+			Name:    proto.String(output),
+			Content: proto.String(string(formatted)),
+		})
+	}
+
+	return files, nil
+}
+
+func genCode(desc *descriptor.FileDescriptorProto, idx *typeIndex, docs *protodoc.Index, has map[string]bool) (string, error) {
+	g := &flagsGen{
+		idx:     idx,
+		docs:    docs,
+		proto3:  desc.GetSyntax() == "proto3",
+		has:     has,
+		imports: newImportSet(desc),
+	}
+	body := bytes.NewBuffer(nil)
+	if err := g.messages(body, strings.TrimSuffix("."+desc.GetPackage(), "."), "", desc.GetMessageType()); err != nil {
+		return "", err
+	}
+	if body.Len() == 0 {
+		return "", nil
+	}
+
+	g.imports.names["github.com/spf13/pflag"] = "pflag"
+	if g.flagbind {
+		g.imports.names["github.com/f4tq/protoc-go-plugins/runtime/flagbind"] = "flagbind"
+	}
+	if g.scalarparse {
+		g.imports.names["github.com/f4tq/protoc-go-plugins/runtime/scalarparse"] = "scalarparse"
+	}
+	hdr := &header{
+		Source:  desc.GetName(),
+		GoPkg:   defaultGoPackageName(desc),
+		Imports: g.imports.names,
+	}
+	w := bytes.NewBuffer(nil)
+	if err := hdrTmpl.Execute(w, hdr); err != nil {
+		log.Fatal(err)
+	}
+	body.WriteTo(w)
+
+	return w.String(), nil
+}
+
+type flagsGen struct {
+	idx *typeIndex
+	// docs holds the comments of the fields, their usage.
+	docs   *protodoc.Index
+	proto3 bool
+	// has holds the messages with a RegisterFlags method.
+	has     map[string]bool
+	imports *importSet
+	// flagbind and scalarparse record that the code uses those packages.
+	flagbind    bool
+	scalarparse bool
+}
+
+// messages writes the RegisterFlags methods of msgs and of the messages
+// nested in them. scope is the full proto name of their parent, and prefix
+// the Go name of the enclosing message plus "_".
+func (g *flagsGen) messages(w *bytes.Buffer, scope, prefix string, msgs []*descriptor.DescriptorProto) error {
+	for _, msg := range msgs {
+		if msg.GetOptions().GetMapEntry() {
+			continue
+		}
+		typeName := scope + "." + msg.GetName()
+		name := prefix + msg.GetName()
+		if g.has[typeName] {
+			m := &flagsMessage{Name: name}
+			goNames := goname.Fields(msg)
+			for _, field := range msg.GetField() {
+				// The usage of a flag is the comment of its field, on one
+				// line.
+				usage := strings.Join(strings.Fields(g.docs.Comment(typeName+"."+field.GetName())), " ")
+				stmt, err := g.field(field, goNames[field.GetName()], usage)
+				if err != nil {
+					return fmt.Errorf("%s.%s: %v", name, field.GetName(), err)
+				}
+				if stmt != "" {
+					m.Fields = append(m.Fields, stmt)
+				}
+			}
+			if err := flagsTmpl.Execute(w, m); err != nil {
+				return err
+			}
+		}
+		if err := g.messages(w, typeName, name+"_", msg.GetNestedType()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// flagMessages returns the generated configuration messages and the
+// generated messages of their singular message fields.
+func flagMessages(idx *typeIndex, genFileNames map[string]bool) map[string]bool {
+	has := make(map[string]bool)
+	for typeName, msg := range idx.messages {
+		if genFileNames[idx.files[typeName].GetName()] && options.Config(msg) {
+			has[typeName] = true
+		}
+	}
+	// Repeat until no message is added, following the fields of the
+	// messages added last.
+	for changed := true; changed; {
+		changed = false
+		for typeName := range has {
+			for _, field := range idx.messages[typeName].GetField() {
+				t := field.GetTypeName()
+				if field.GetType() != descriptor.FieldDescriptorProto_TYPE_MESSAGE || has[t] ||
+					field.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REPEATED ||
+					(field.OneofIndex != nil && !field.GetProto3Optional()) ||
+					!genFileNames[idx.files[t].GetName()] {
+					continue
+				}
+				has[t] = true
+				changed = true
+			}
+		}
+	}
+	return has
+}
+
+// field returns the statements binding field, whose Go name is goName, to a
+// flag with the help text usage, or "" if it is not bound.
+func (g *flagsGen) field(field *descriptor.FieldDescriptorProto, goName, usage string) (string, error) {
+	if field.OneofIndex != nil && !field.GetProto3Optional() {
+		return "", nil
+	}
+	name := fmt.Sprintf("prefix+%q", strings.Replace(field.GetName(), "_", "-", -1))
+	pointer := field.GetProto3Optional() || !g.proto3
+	if g.idx.isMap(field) {
+		entry := g.idx.messages[field.GetTypeName()]
+		if entry.GetField()[0].GetType() != descriptor.FieldDescriptorProto_TYPE_STRING ||
+			entry.GetField()[1].GetType() != descriptor.FieldDescriptorProto_TYPE_STRING {
+			return "", nil
+		}
+		return fmt.Sprintf("fs.StringToStringVar(&m.%s, %s, m.%s, %q)", goName, name, goName, usage), nil
+	}
+	if field.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REPEATED {
+		f, ok := sliceFlagFuncs[scalarGoType(field.GetType())]
+		if !ok {
+			return "", nil
+		}
+		return fmt.Sprintf("fs.%sVar(&m.%s, %s, m.%s, %q)", f, goName, name, goName, usage), nil
+	}
+	switch field.GetType() {
+	case descriptor.FieldDescriptorProto_TYPE_MESSAGE:
+		switch {
+		case field.GetTypeName() == durationType:
+			g.flagbind = true
+			return fmt.Sprintf("fs.Var(flagbind.Duration(&m.%s), %s, %q)", goName, name, usage), nil
+		case g.has[field.GetTypeName()]:
+			return fmt.Sprintf("if m.%s == nil {\nm.%s = new(%s)\n}\nm.%s.RegisterFlags(fs, prefix+%q)",
+				goName, goName, g.imports.goTypeName(g.idx, field.GetTypeName()), goName,
+				strings.Replace(field.GetName(), "_", "-", -1)+"."), nil
+		}
+		return "", nil
+	case descriptor.FieldDescriptorProto_TYPE_GROUP:
+		return "", nil
+	case descriptor.FieldDescriptorProto_TYPE_BYTES:
+		return fmt.Sprintf("fs.BytesBase64Var(&m.%s, %s, m.%s, %q)", goName, name, goName, usage), nil
+	case descriptor.FieldDescriptorProto_TYPE_ENUM:
+		g.flagbind, g.scalarparse = true, true
+		typ := g.imports.goTypeName(g.idx, field.GetTypeName())
+		parse := fmt.Sprintf("scalarparse.Enum[%s](%s_value)", typ, typ)
+		bind := "Value"
+		if pointer {
+			bind = "Optional"
+		}
+		return fmt.Sprintf("fs.Var(flagbind.%s(&m.%s, %q, %s), %s, %q)",
+			bind, goName, g.idx.enums[field.GetTypeName()].GetName(), parse, name, usage), nil
+	}
+	typ := scalarGoType(field.GetType())
+	f := flagFuncs[typ]
+	if !pointer {
+		return fmt.Sprintf("fs.%sVar(&m.%s, %s, m.%s, %q)", f, goName, name, goName, usage), nil
+	}
+	// Fields with presence stay nil unless their flag is set.
+	g.flagbind, g.scalarparse = true, true
+	value := fmt.Sprintf("flagbind.Optional(&m.%s, %q, scalarparse.%s)", goName, typ, f)
+	if typ == "bool" {
+		// As for pflag's own bool flags, --name alone means true.
+		return fmt.Sprintf("fs.VarPF(%s, %s, \"\", %q).NoOptDefVal = \"true\"", value, name, usage), nil
+	}
+	return fmt.Sprintf("fs.Var(%s, %s, %q)", value, name, usage), nil
+}
+
+// scalarGoType returns the Go type of the scalar proto type t, or "".
+func scalarGoType(t descriptor.FieldDescriptorProto_Type) string {
+	switch t {
+	case descriptor.FieldDescriptorProto_TYPE_DOUBLE:
+		return "float64"
+	case descriptor.FieldDescriptorProto_TYPE_FLOAT:
+		return "float32"
+	case descriptor.FieldDescriptorProto_TYPE_INT64,
+		descriptor.FieldDescriptorProto_TYPE_SINT64,
+		descriptor.FieldDescriptorProto_TYPE_SFIXED64:
+		return "int64"
+	case descriptor.FieldDescriptorProto_TYPE_UINT64,
+		descriptor.FieldDescriptorProto_TYPE_FIXED64:
+		return "uint64"
+	case descriptor.FieldDescriptorProto_TYPE_INT32,
+		descriptor.FieldDescriptorProto_TYPE_SINT32,
+		descriptor.FieldDescriptorProto_TYPE_SFIXED32:
+		return "int32"
+	case descriptor.FieldDescriptorProto_TYPE_UINT32,
+		descriptor.FieldDescriptorProto_TYPE_FIXED32:
+		return "uint32"
+	case descriptor.FieldDescriptorProto_TYPE_BOOL:
+		return "bool"
+	case descriptor.FieldDescriptorProto_TYPE_STRING:
+		return "string"
+	}
+	return ""
+}
+
+type header struct {
+	Source  string
+	GoPkg   string
+	Imports map[string]string
+}
+
+type flagsMessage struct {
+	Name   string
+	Fields []string
+}
+
+// typeIndex maps fully-qualified proto type names (".pkg.Msg") to their
+// descriptors and declaring files for every file in the request.
+type typeIndex struct {
+	messages map[string]*descriptor.DescriptorProto
+	enums    map[string]*descriptor.EnumDescriptorProto
+	files    map[string]*descriptor.FileDescriptorProto
+}
+
+func newTypeIndex(files []*descriptor.FileDescriptorProto) *typeIndex {
+	idx := &typeIndex{
+		messages: make(map[string]*descriptor.DescriptorProto),
+		enums:    make(map[string]*descriptor.EnumDescriptorProto),
+		files:    make(map[string]*descriptor.FileDescriptorProto),
+	}
+	for _, f := range files {
+		prefix := ""
+		if f.GetPackage() != "" {
+			prefix = "." + f.GetPackage()
+		}
+		idx.addMessages(f, prefix, f.GetMessageType())
+		idx.addEnums(f, prefix, f.GetEnumType())
+	}
+	return idx
+}
+
+func (idx *typeIndex) addMessages(f *descriptor.FileDescriptorProto, prefix string, msgs []*descriptor.DescriptorProto) {
+	for _, m := range msgs {
+		name := prefix + "." + m.GetName()
+		idx.messages[name] = m
+		idx.files[name] = f
+		idx.addMessages(f, name, m.GetNestedType())
+		idx.addEnums(f, name, m.GetEnumType())
+	}
+}
+
+func (idx *typeIndex) addEnums(f *descriptor.FileDescriptorProto, prefix string, enums []*descriptor.EnumDescriptorProto) {
+	for _, e := range enums {
+		name := prefix + "." + e.GetName()
+		idx.enums[name] = e
+		idx.files[name] = f
+	}
+}
+
+// isMap reports whether field is a map field.
+func (idx *typeIndex) isMap(field *descriptor.FieldDescriptorProto) bool {
+	if field.GetType() != descriptor.FieldDescriptorProto_TYPE_MESSAGE {
+		return false
+	}
+	return idx.messages[field.GetTypeName()].GetOptions().GetMapEntry()
+}
+
+// goImportPath returns the import path of the Go package generated from f,
+// or "" if go_package does not name one.
+func goImportPath(f *descriptor.FileDescriptorProto) string {
+	gopkg := f.GetOptions().GetGoPackage()
+	if sc := strings.IndexByte(gopkg, ';'); sc >= 0 {
+		gopkg = gopkg[:sc]
+	}
+	if !strings.Contains(gopkg, "/") {
+		return ""
+	}
+	return gopkg
+}
+
+// importSet records the Go packages referenced by the code generated for
+// one file, keyed by import path.
+type importSet struct {
+	self  string
+	names map[string]string
+}
+
+func newImportSet(f *descriptor.FileDescriptorProto) *importSet {
+	return &importSet{self: goImportPath(f), names: make(map[string]string)}
+}
+
+// goTypeName returns the Go name of the message or enum typeName, qualified
+// with its package if it is declared outside the generated package.
+func (s *importSet) goTypeName(idx *typeIndex, typeName string) string {
+	name := localTypeName(typeName)
+	f := idx.files[typeName]
+	path := goImportPath(f)
+	if f == nil || path == "" || path == s.self {
+		return name
+	}
+	pkg := defaultGoPackageName(f)
+	s.names[path] = pkg
+	return pkg + "." + name
+}
+
+// localTypeName returns the Go name of a type declared in the file being
+// generated, e.g. ".pkg.Outer.Inner" becomes "Outer_Inner".
+func localTypeName(typeName string) string {
+	parts := strings.Split(strings.TrimPrefix(typeName, "."), ".")
+	var names []string
+	for _, p := range parts {
+		if len(p) > 0 && p[0] >= 'A' && p[0] <= 'Z' {
+			names = append(names, p)
+		}
+	}
+	return strings.Join(names, "_")
+}
+
+// sanitizePackageName replaces unallowed character in package name
+// with allowed character.
+func sanitizePackageName(pkgName string) string {
+	pkgName = strings.Replace(pkgName, ".", "_", -1)
+	pkgName = strings.Replace(pkgName, "-", "_", -1)
+	return pkgName
+}
+
+// defaultGoPackageName returns the default go package name to be used for go files generated from "f".
+// You might need to use an unique alias for the package when you import it.  Use ReserveGoPackageAlias to get a unique alias.
+func defaultGoPackageName(f *descriptor.FileDescriptorProto) string {
+	name := packageIdentityName(f)
+	return sanitizePackageName(name)
+}
+
+// packageIdentityName returns the identity of packages.
+// protoc-gen-grpc-gateway rejects CodeGenerationRequests which contains more than one packages
+// as protoc-gen-go does.
+func packageIdentityName(f *descriptor.FileDescriptorProto) string {
+	if f.Options != nil && f.Options.GoPackage != nil {
+		gopkg := f.Options.GetGoPackage()
+		idx := strings.LastIndex(gopkg, "/")
+		if idx < 0 {
+			gopkg = gopkg[idx+1:]
+		}
+
+		gopkg = gopkg[idx+1:]
+		// package name is overrided with the string after the
+		// ';' character
+		sc := strings.IndexByte(gopkg, ';')
+		if sc < 0 {
+			return sanitizePackageName(gopkg)
+
+		}
+		return sanitizePackageName(gopkg[sc+1:])
+	}
+
+	if f.Package == nil {
+		base := filepath.Base(f.GetName())
+		ext := filepath.Ext(base)
+		return strings.TrimSuffix(base, ext)
+	}
+	return f.GetPackage()
+}
+
+func emitFiles(out []*plugin.CodeGeneratorResponse_File) {
+	emitResp(&plugin.CodeGeneratorResponse{File: out})
+}
+
+func emitError(err error) {
+	emitResp(&plugin.CodeGeneratorResponse{Error: proto.String(err.Error())})
+}
+
+func emitResp(resp *plugin.CodeGeneratorResponse) {
+	buf, err := proto.Marshal(resp)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if _, err := os.Stdout.Write(buf); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/f4tq/protoc-go-plugins/internal/plugintest"
+)
+
+const commentedProto = `
+syntax = "proto3";
+
+package doc.v1;
+
+option go_package = "example.com/doc/v1;docv1";
+
+import "options/options.proto";
+
+// Doc is a document.
+message Doc {
+    option (f4tq.plugins.config) = true;
+
+    // title names the document.
+    string title = 1; // Shown in lists.
+}
+`
+
+// TestComments checks the usages of flags carry the leading and trailing
+// comments of the declarations.
+func TestComments(t *testing.T) {
+	req := plugintest.Request(t, "", map[string]string{"doc/v1/doc.proto": commentedProto})
+	got := plugintest.Generate(t, req, generate)["doc/v1/doc.pb.flags.go"]
+	for _, want := range []string{
+		`"title names the document. Shown in lists."`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("the code does not contain %q:\n%s", want, got)
+		}
+	}
+}
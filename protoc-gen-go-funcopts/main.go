@@ -0,0 +1,463 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+
+	"github.com/f4tq/protoc-go-plugins/goname"
+	"github.com/f4tq/protoc-go-plugins/protodoc"
+)
+
+var (
+	hdrTmpl = template.Must(template.New("header").Parse(`
+// Code generated by protoc-gen-go-funcopts. DO NOT EDIT.
+// source: {{.Source}}
+
+package {{.GoPkg}}
+{{- if or .Errors .Imports}}
+
+import (
+{{- if .Errors}}
+    "errors"
+{{end}}
+{{- range $path, $name := .Imports}}
+    {{$name}} "{{$path}}"
+{{- end}}
+)
+{{- end}}
+`))
+
+	optionsTmpl = template.Must(template.New("options").Parse(`
+// {{.Name}}Option sets a field of the {{.Name}} built by New{{.Name}}.{{.Doc}}
+type {{.Name}}Option func(*{{.Name}}) error
+
+// New{{.Name}} returns a {{.Name}} with opts applied in order. It fails if
+// two options set members of the same oneof.{{.Doc}}
+func New{{.Name}}(opts ...{{.Name}}Option) (*{{.Name}}, error) {
+    m := new({{.Name}})
+    for _, opt := range opts {
+        if err := opt(m); err != nil {
+            return nil, err
+        }
+    }
+    return m, nil
+}
+{{range .Fields}}
+// With{{$.Name}}{{.Go}} sets {{.Proto}}{{if .Oneof}}, a member of the oneof {{.Oneof}}{{end}}.{{.Doc}}
+func With{{$.Name}}{{.Go}}(v {{.Param}}) {{$.Name}}Option {
+    return func(m *{{$.Name}}) error {
+{{- if .Oneof}}
+        if m.{{.OneofGo}} != nil {
+            return errors.New({{printf "%q" .Conflict}})
+        }
+{{- end}}
+        {{.Assign}}
+        return nil
+    }
+}
+{{end}}`))
+)
+
+func main() {
+	input, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	req := new(plugin.CodeGeneratorRequest)
+	if err = proto.Unmarshal(input, req); err != nil {
+		log.Fatal(err)
+	}
+
+	out, err := generate(req)
+
+	if err != nil {
+		emitError(err)
+		return
+	}
+
+	emitFiles(out)
+}
+
+func generate(req *plugin.CodeGeneratorRequest) ([]*plugin.CodeGeneratorResponse_File, error) {
+	var files []*plugin.CodeGeneratorResponse_File
+	idx := newTypeIndex(req.GetProtoFile())
+	docs := protodoc.New(req.GetProtoFile())
+	genFileNames := make(map[string]bool)
+	for _, n := range req.FileToGenerate {
+		genFileNames[n] = true
+	}
+	for _, desc := range req.GetProtoFile() {
+		name := desc.GetName()
+		if _, ok := genFileNames[name]; !ok {
+			// Only emit output for files present in req.FileToGenerate.
+			continue
+		}
+		code, err := genCode(desc, idx, docs)
+		if err != nil {
+			return nil, err
+		}
+		if code == "" {
+			// The file declares no messages.
+			continue
+		}
+		formatted, err := format.Source([]byte(code))
+		if err != nil {
+			log.Printf("%v: %s", err, code)
+			return nil, err
+		}
+
+		ext := filepath.Ext(name)
+		base := strings.TrimSuffix(name, ext)
+		output := fmt.Sprintf("%s.pb.funcopts.go", base)
+		files = append(files, &plugin.CodeGeneratorResponse_File{
+			Name:    proto.String(output),
+			Content: proto.String(string(formatted)),
+		})
+	}
+
+	return files, nil
+}
+
+func genCode(desc *descriptor.FileDescriptorProto, idx *typeIndex, docs *protodoc.Index) (string, error) {
+	w := bytes.NewBuffer(nil)
+	g := &optionsGen{
+		idx:     idx,
+		docs:    docs,
+		imports: newImportSet(desc),
+		proto3:  desc.GetSyntax() == "proto3",
+	}
+	body := bytes.NewBuffer(nil)
+	if err := g.messages(body, "", desc.GetPackage(), desc.GetMessageType()); err != nil {
+		return "", err
+	}
+	if body.Len() == 0 {
+		return "", nil
+	}
+
+	hdr := &header{
+		Source:  desc.GetName(),
+		GoPkg:   defaultGoPackageName(desc),
+		Errors:  g.errors,
+		Imports: g.imports.names,
+	}
+	if err := hdrTmpl.Execute(w, hdr); err != nil {
+		log.Fatal(err)
+	}
+	body.WriteTo(w)
+
+	return w.String(), nil
+}
+
+type optionsGen struct {
+	idx     *typeIndex
+	docs    *protodoc.Index
+	imports *importSet
+	proto3  bool
+	// errors records whether an option checks a oneof.
+	errors bool
+}
+
+// messages writes the constructors and options of msgs and of the
+// messages nested in them. prefix is the Go name of the enclosing message
+// plus "_", scope its full proto name.
+func (g *optionsGen) messages(w *bytes.Buffer, prefix, scope string, msgs []*descriptor.DescriptorProto) error {
+	for _, msg := range msgs {
+		if msg.GetOptions().GetMapEntry() {
+			continue
+		}
+		name := prefix + msg.GetName()
+		full := msg.GetName()
+		if scope != "" {
+			full = scope + "." + full
+		}
+		m := &optionsMessage{Name: name, Doc: g.docs.Godoc(full)}
+		goNames := goname.Fields(msg)
+		for _, field := range msg.GetField() {
+			f := g.field(name, msg, goNames, field)
+			f.Doc = g.docs.Godoc(full + "." + field.GetName())
+			m.Fields = append(m.Fields, f)
+		}
+		for _, f := range m.Fields {
+			if f.Oneof != "" {
+				f.Conflict = fmt.Sprintf("%s: oneof %s set more than once", full, f.Oneof)
+			}
+		}
+		if err := optionsTmpl.Execute(w, m); err != nil {
+			return err
+		}
+		if err := g.messages(w, name+"_", full, msg.GetNestedType()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// field returns the template data of the option of field of msg, whose Go
+// name is msgName and whose fields and oneofs have the Go names goNames.
+func (g *optionsGen) field(msgName string, msg *descriptor.DescriptorProto, goNames map[string]string, field *descriptor.FieldDescriptorProto) *optionsField {
+	goName := goNames[field.GetName()]
+	f := &optionsField{Proto: field.GetName(), Go: goName, Param: g.goType(field)}
+	switch {
+	case g.idx.isMap(field):
+		entry := g.idx.messages[field.GetTypeName()]
+		f.Param = fmt.Sprintf("map[%s]%s", g.goType(entry.GetField()[0]), g.goType(entry.GetField()[1]))
+		f.Assign = fmt.Sprintf("m.%s = v", goName)
+	case field.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REPEATED:
+		f.Param = "..." + f.Param
+		f.Assign = fmt.Sprintf("m.%s = v", goName)
+	case field.OneofIndex != nil && !field.GetProto3Optional():
+		f.Oneof = msg.GetOneofDecl()[field.GetOneofIndex()].GetName()
+		f.OneofGo = goNames[f.Oneof]
+		f.Assign = fmt.Sprintf("m.%s = &%s_%s{%s: v}", f.OneofGo, msgName, goName, goName)
+		g.errors = true
+	case field.GetType() == descriptor.FieldDescriptorProto_TYPE_MESSAGE,
+		field.GetType() == descriptor.FieldDescriptorProto_TYPE_GROUP,
+		field.GetType() == descriptor.FieldDescriptorProto_TYPE_BYTES:
+		f.Assign = fmt.Sprintf("m.%s = v", goName)
+	case field.GetProto3Optional() || !g.proto3:
+		// Scalars are pointers.
+		f.Assign = fmt.Sprintf("m.%s = &v", goName)
+	default:
+		f.Assign = fmt.Sprintf("m.%s = v", goName)
+	}
+	return f
+}
+
+// goType returns the Go type of a value of field.
+func (g *optionsGen) goType(field *descriptor.FieldDescriptorProto) string {
+	switch field.GetType() {
+	case descriptor.FieldDescriptorProto_TYPE_MESSAGE, descriptor.FieldDescriptorProto_TYPE_GROUP:
+		return "*" + g.imports.goTypeName(g.idx, field.GetTypeName())
+	case descriptor.FieldDescriptorProto_TYPE_ENUM:
+		return g.imports.goTypeName(g.idx, field.GetTypeName())
+	case descriptor.FieldDescriptorProto_TYPE_BYTES:
+		return "[]byte"
+	}
+	return scalarGoType(field.GetType())
+}
+
+// scalarGoType returns the Go type of the scalar type t.
+func scalarGoType(t descriptor.FieldDescriptorProto_Type) string {
+	switch t {
+	case descriptor.FieldDescriptorProto_TYPE_DOUBLE:
+		return "float64"
+	case descriptor.FieldDescriptorProto_TYPE_FLOAT:
+		return "float32"
+	case descriptor.FieldDescriptorProto_TYPE_INT64, descriptor.FieldDescriptorProto_TYPE_SINT64,
+		descriptor.FieldDescriptorProto_TYPE_SFIXED64:
+		return "int64"
+	case descriptor.FieldDescriptorProto_TYPE_UINT64, descriptor.FieldDescriptorProto_TYPE_FIXED64:
+		return "uint64"
+	case descriptor.FieldDescriptorProto_TYPE_INT32, descriptor.FieldDescriptorProto_TYPE_SINT32,
+		descriptor.FieldDescriptorProto_TYPE_SFIXED32:
+		return "int32"
+	case descriptor.FieldDescriptorProto_TYPE_UINT32, descriptor.FieldDescriptorProto_TYPE_FIXED32:
+		return "uint32"
+	case descriptor.FieldDescriptorProto_TYPE_BOOL:
+		return "bool"
+	}
+	return "string"
+}
+
+type header struct {
+	Source  string
+	GoPkg   string
+	Errors  bool
+	Imports map[string]string
+}
+
+type optionsMessage struct {
+	Name   string
+	Fields []*optionsField
+	// Doc is the comment of the message, see protodoc.Index.Godoc.
+	Doc string
+}
+
+type optionsField struct {
+	Proto    string
+	Go       string
+	Oneof    string
+	OneofGo  string
+	Conflict string
+	Param    string
+	Assign   string
+	// Doc is the comment of the field.
+	Doc string
+}
+
+// typeIndex maps fully-qualified proto type names (".pkg.Msg") to their
+// descriptors and declaring files for every file in the request.
+type typeIndex struct {
+	messages map[string]*descriptor.DescriptorProto
+	enums    map[string]*descriptor.EnumDescriptorProto
+	files    map[string]*descriptor.FileDescriptorProto
+}
+
+func newTypeIndex(files []*descriptor.FileDescriptorProto) *typeIndex {
+	idx := &typeIndex{
+		messages: make(map[string]*descriptor.DescriptorProto),
+		enums:    make(map[string]*descriptor.EnumDescriptorProto),
+		files:    make(map[string]*descriptor.FileDescriptorProto),
+	}
+	for _, f := range files {
+		prefix := ""
+		if f.GetPackage() != "" {
+			prefix = "." + f.GetPackage()
+		}
+		idx.addMessages(f, prefix, f.GetMessageType())
+		idx.addEnums(f, prefix, f.GetEnumType())
+	}
+	return idx
+}
+
+func (idx *typeIndex) addMessages(f *descriptor.FileDescriptorProto, prefix string, msgs []*descriptor.DescriptorProto) {
+	for _, m := range msgs {
+		name := prefix + "." + m.GetName()
+		idx.messages[name] = m
+		idx.files[name] = f
+		idx.addMessages(f, name, m.GetNestedType())
+		idx.addEnums(f, name, m.GetEnumType())
+	}
+}
+
+func (idx *typeIndex) addEnums(f *descriptor.FileDescriptorProto, prefix string, enums []*descriptor.EnumDescriptorProto) {
+	for _, e := range enums {
+		name := prefix + "." + e.GetName()
+		idx.enums[name] = e
+		idx.files[name] = f
+	}
+}
+
+// isMap reports whether field is a map field.
+func (idx *typeIndex) isMap(field *descriptor.FieldDescriptorProto) bool {
+	if field.GetType() != descriptor.FieldDescriptorProto_TYPE_MESSAGE {
+		return false
+	}
+	return idx.messages[field.GetTypeName()].GetOptions().GetMapEntry()
+}
+
+// goImportPath returns the import path of the Go package generated from f,
+// or "" if go_package does not name one.
+func goImportPath(f *descriptor.FileDescriptorProto) string {
+	gopkg := f.GetOptions().GetGoPackage()
+	if sc := strings.IndexByte(gopkg, ';'); sc >= 0 {
+		gopkg = gopkg[:sc]
+	}
+	if !strings.Contains(gopkg, "/") {
+		return ""
+	}
+	return gopkg
+}
+
+// importSet records the Go packages referenced by the code generated for
+// one file, keyed by import path.
+type importSet struct {
+	self  string
+	names map[string]string
+}
+
+func newImportSet(f *descriptor.FileDescriptorProto) *importSet {
+	return &importSet{self: goImportPath(f), names: make(map[string]string)}
+}
+
+// goTypeName returns the Go name of the message or enum typeName, qualified
+// with its package if it is declared outside the generated package.
+func (s *importSet) goTypeName(idx *typeIndex, typeName string) string {
+	name := localTypeName(typeName)
+	f := idx.files[typeName]
+	path := goImportPath(f)
+	if f == nil || path == "" || path == s.self {
+		return name
+	}
+	pkg := defaultGoPackageName(f)
+	s.names[path] = pkg
+	return pkg + "." + name
+}
+
+// localTypeName returns the Go name of a type declared in the file being
+// generated, e.g. ".pkg.Outer.Inner" becomes "Outer_Inner".
+func localTypeName(typeName string) string {
+	parts := strings.Split(strings.TrimPrefix(typeName, "."), ".")
+	var names []string
+	for _, p := range parts {
+		if len(p) > 0 && p[0] >= 'A' && p[0] <= 'Z' {
+			names = append(names, p)
+		}
+	}
+	return strings.Join(names, "_")
+}
+
+// sanitizePackageName replaces unallowed character in package name
+// with allowed character.
+func sanitizePackageName(pkgName string) string {
+	pkgName = strings.Replace(pkgName, ".", "_", -1)
+	pkgName = strings.Replace(pkgName, "-", "_", -1)
+	return pkgName
+}
+
+// defaultGoPackageName returns the default go package name to be used for go files generated from "f".
+// You might need to use an unique alias for the package when you import it.  Use ReserveGoPackageAlias to get a unique alias.
+func defaultGoPackageName(f *descriptor.FileDescriptorProto) string {
+	name := packageIdentityName(f)
+	return sanitizePackageName(name)
+}
+
+// packageIdentityName returns the identity of packages.
+// protoc-gen-grpc-gateway rejects CodeGenerationRequests which contains more than one packages
+// as protoc-gen-go does.
+func packageIdentityName(f *descriptor.FileDescriptorProto) string {
+	if f.Options != nil && f.Options.GoPackage != nil {
+		gopkg := f.Options.GetGoPackage()
+		idx := strings.LastIndex(gopkg, "/")
+		if idx < 0 {
+			gopkg = gopkg[idx+1:]
+		}
+
+		gopkg = gopkg[idx+1:]
+		// package name is overrided with the string after the
+		// ';' character
+		sc := strings.IndexByte(gopkg, ';')
+		if sc < 0 {
+			return sanitizePackageName(gopkg)
+
+		}
+		return sanitizePackageName(gopkg[sc+1:])
+	}
+
+	if f.Package == nil {
+		base := filepath.Base(f.GetName())
+		ext := filepath.Ext(base)
+		return strings.TrimSuffix(base, ext)
+	}
+	return f.GetPackage()
+}
+
+func emitFiles(out []*plugin.CodeGeneratorResponse_File) {
+	emitResp(&plugin.CodeGeneratorResponse{File: out})
+}
+
+func emitError(err error) {
+	emitResp(&plugin.CodeGeneratorResponse{Error: proto.String(err.Error())})
+}
+
+func emitResp(resp *plugin.CodeGeneratorResponse) {
+	buf, err := proto.Marshal(resp)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if _, err := os.Stdout.Write(buf); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/f4tq/protoc-go-plugins/internal/plugintest"
+)
+
+// conflictsTest runs in the package generated for plugintest.Conflicts.
+const conflictsTest = `package conflictsv1
+
+import "testing"
+
+func TestOptions(t *testing.T) {
+	m, err := NewConflicts(WithConflictsString_("s"), WithConflictsGetString(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.String_ != "s" || m.GetGetString() != 1 {
+		t.Errorf("NewConflicts = %v, want string s and get_string 1", m)
+	}
+	if _, err := NewConflicts(WithConflictsGetString(1), WithConflictsExtensionRangeArray_("a")); err == nil {
+		t.Error("NewConflicts setting the oneof twice succeeded")
+	}
+}
+`
+
+// TestConflicts runs the options of fields protoc-gen-go renames, such as
+// String_ for a field named string.
+func TestConflicts(t *testing.T) {
+	pkg := plugintest.Package(t, plugintest.ConflictsRequest(t, ""), generate)
+	plugintest.WriteFile(t, pkg, "options_test.go", conflictsTest)
+	plugintest.Go(t, "test", pkg)
+}
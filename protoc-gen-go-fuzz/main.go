@@ -0,0 +1,301 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+
+	"github.com/f4tq/protoc-go-plugins/protodoc"
+)
+
+var (
+	hdrTmpl = template.Must(template.New("header").Parse(`
+// Code generated by protoc-gen-go-fuzz. DO NOT EDIT.
+// source: {{.Source}}
+
+package {{.GoPkg}}
+
+import (
+    "testing"
+
+    "github.com/f4tq/protoc-go-plugins/runtime/fuzzpb"
+    "github.com/golang/protobuf/proto"
+)
+`))
+
+	fuzzTmpl = template.Must(template.New("fuzz").Parse(`
+// Fuzz{{.Name}}JSONRoundTrip fuzzes the JSON decoding of {{.FullName}},
+// checking that the messages it decodes survive JSON and binary round
+// trips. The corpus is seeded with the example of the message, if any.
+func Fuzz{{.Name}}JSONRoundTrip(f *testing.F) {
+    f.Add([]byte("{}"))
+{{- range .Seeds}}
+    f.Add([]byte({{.}}))
+{{- end}}
+    fuzzpb.AddFiles(f, {{printf "%q" .ExampleFile}})
+    f.Fuzz(func(t *testing.T, data []byte) {
+        fuzzpb.JSONRoundTrip(t, data, func() proto.Message { return new({{.Name}}) })
+    })
+}
+`))
+)
+
+func main() {
+	input, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	req := new(plugin.CodeGeneratorRequest)
+	if err = proto.Unmarshal(input, req); err != nil {
+		log.Fatal(err)
+	}
+
+	out, err := generate(req)
+
+	if err != nil {
+		emitError(err)
+		return
+	}
+
+	emitFiles(out)
+}
+
+// generate writes a native Go fuzz target for each message of the files to
+// generate, nested ones included, to <file>.pb.fuzz_test.go. Run one with
+// go test -fuzz=FuzzOrderJSONRoundTrip.
+func generate(req *plugin.CodeGeneratorRequest) ([]*plugin.CodeGeneratorResponse_File, error) {
+	var files []*plugin.CodeGeneratorResponse_File
+	docs := protodoc.New(req.GetProtoFile())
+	genFileNames := make(map[string]bool)
+	for _, n := range req.FileToGenerate {
+		genFileNames[n] = true
+	}
+	for _, desc := range req.GetProtoFile() {
+		name := desc.GetName()
+		if _, ok := genFileNames[name]; !ok {
+			// Only emit output for files present in req.FileToGenerate.
+			continue
+		}
+		code, err := genCode(desc, docs)
+		if err != nil {
+			return nil, err
+		}
+		if code == "" {
+			// The file declares no messages.
+			continue
+		}
+		formatted, err := format.Source([]byte(code))
+		if err != nil {
+			log.Printf("%v: %s", err, code)
+			return nil, err
+		}
+
+		ext := filepath.Ext(name)
+		base := strings.TrimSuffix(name, ext)
+		output := fmt.Sprintf("%s.pb.fuzz_test.go", base)
+		files = append(files, &plugin.CodeGeneratorResponse_File{
+			Name:    proto.String(output),
+			Content: proto.String(string(formatted)),
+		})
+	}
+
+	return files, nil
+}
+
+func genCode(desc *descriptor.FileDescriptorProto, docs *protodoc.Index) (string, error) {
+	base := strings.TrimSuffix(filepath.Base(desc.GetName()), filepath.Ext(desc.GetName()))
+	var targets []*target
+	var walk func(scope string, msgs []*descriptor.DescriptorProto) error
+	walk = func(scope string, msgs []*descriptor.DescriptorProto) error {
+		for _, msg := range msgs {
+			if msg.GetOptions().GetMapEntry() {
+				continue
+			}
+			fullName := qualify(scope, msg.GetName())
+			t := &target{Name: localTypeName(fullName), FullName: fullName}
+			// The files of protoc-gen-go-examples sit next to the generated
+			// package.
+			t.ExampleFile = fmt.Sprintf("%s.examples/%s.json", base, t.Name)
+			ex, ok, err := commentExample(docs.Comment(fullName))
+			if err != nil {
+				return fmt.Errorf("%s: %v", fullName, err)
+			}
+			if ok {
+				t.Seeds = append(t.Seeds, literal(string(ex)))
+			}
+			targets = append(targets, t)
+			if err := walk(fullName, msg.GetNestedType()); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := walk(desc.GetPackage(), desc.GetMessageType()); err != nil {
+		return "", fmt.Errorf("%s: %v", desc.GetName(), err)
+	}
+	if len(targets) == 0 {
+		return "", nil
+	}
+
+	w := bytes.NewBuffer(nil)
+	hdr := &header{
+		Source: desc.GetName(),
+		GoPkg:  defaultGoPackageName(desc),
+	}
+	if err := hdrTmpl.Execute(w, hdr); err != nil {
+		log.Fatal(err)
+	}
+	for _, t := range targets {
+		if err := fuzzTmpl.Execute(w, t); err != nil {
+			return "", err
+		}
+	}
+	return w.String(), nil
+}
+
+type header struct {
+	Source string
+	GoPkg  string
+}
+
+// target is the fuzz target of a message.
+type target struct {
+	// Name is the Go name of the message.
+	Name     string
+	FullName string
+	// Seeds are the Go literals of the examples in the comment of the
+	// message, and ExampleFile the file protoc-gen-go-examples writes its
+	// example to, relative to the package.
+	Seeds       []string
+	ExampleFile string
+}
+
+// literal returns the Go string literal of s.
+func literal(s string) string {
+	if strings.ContainsAny(s, "`\r") {
+		return strconv.Quote(s)
+	}
+	return "`" + s + "`"
+}
+
+// commentExample returns the JSON following "example:" at the start of a
+// line of comment, up to the next blank line, and whether there is one.
+func commentExample(comment string) (json.RawMessage, bool, error) {
+	lines := strings.Split(comment, "\n")
+	for i, l := range lines {
+		l = strings.TrimSpace(l)
+		if !strings.HasPrefix(l, "example:") {
+			continue
+		}
+		text := strings.TrimSpace(strings.TrimPrefix(l, "example:"))
+		for _, next := range lines[i+1:] {
+			if strings.TrimSpace(next) == "" {
+				break
+			}
+			text += "\n" + next
+		}
+		var compact bytes.Buffer
+		if err := json.Compact(&compact, []byte(text)); err != nil {
+			return nil, true, fmt.Errorf("invalid example %s: %v", text, err)
+		}
+		return compact.Bytes(), true, nil
+	}
+	return nil, false, nil
+}
+
+// qualify returns the full proto name of name, declared in scope.
+func qualify(scope, name string) string {
+	if scope == "" {
+		return name
+	}
+	return scope + "." + name
+}
+
+// localTypeName returns the Go name of a type declared in the file being
+// generated, e.g. ".pkg.Outer.Inner" becomes "Outer_Inner".
+func localTypeName(typeName string) string {
+	parts := strings.Split(strings.TrimPrefix(typeName, "."), ".")
+	var names []string
+	for _, p := range parts {
+		if len(p) > 0 && p[0] >= 'A' && p[0] <= 'Z' {
+			names = append(names, p)
+		}
+	}
+	return strings.Join(names, "_")
+}
+
+// sanitizePackageName replaces unallowed character in package name
+// with allowed character.
+func sanitizePackageName(pkgName string) string {
+	pkgName = strings.Replace(pkgName, ".", "_", -1)
+	pkgName = strings.Replace(pkgName, "-", "_", -1)
+	return pkgName
+}
+
+// defaultGoPackageName returns the default go package name to be used for go files generated from "f".
+// You might need to use an unique alias for the package when you import it.  Use ReserveGoPackageAlias to get a unique alias.
+func defaultGoPackageName(f *descriptor.FileDescriptorProto) string {
+	name := packageIdentityName(f)
+	return sanitizePackageName(name)
+}
+
+// packageIdentityName returns the identity of packages.
+// protoc-gen-grpc-gateway rejects CodeGenerationRequests which contains more than one packages
+// as protoc-gen-go does.
+func packageIdentityName(f *descriptor.FileDescriptorProto) string {
+	if f.Options != nil && f.Options.GoPackage != nil {
+		gopkg := f.Options.GetGoPackage()
+		idx := strings.LastIndex(gopkg, "/")
+		if idx < 0 {
+			gopkg = gopkg[idx+1:]
+		}
+
+		gopkg = gopkg[idx+1:]
+		// package name is overrided with the string after the
+		// ';' character
+		sc := strings.IndexByte(gopkg, ';')
+		if sc < 0 {
+			return sanitizePackageName(gopkg)
+
+		}
+		return sanitizePackageName(gopkg[sc+1:])
+	}
+
+	if f.Package == nil {
+		base := filepath.Base(f.GetName())
+		ext := filepath.Ext(base)
+		return strings.TrimSuffix(base, ext)
+	}
+	return f.GetPackage()
+}
+
+func emitFiles(out []*plugin.CodeGeneratorResponse_File) {
+	emitResp(&plugin.CodeGeneratorResponse{File: out})
+}
+
+func emitError(err error) {
+	emitResp(&plugin.CodeGeneratorResponse{Error: proto.String(err.Error())})
+}
+
+func emitResp(resp *plugin.CodeGeneratorResponse) {
+	buf, err := proto.Marshal(resp)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if _, err := os.Stdout.Write(buf); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,358 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+
+	"github.com/f4tq/protoc-go-plugins/goname"
+	"github.com/f4tq/protoc-go-plugins/options"
+	"github.com/f4tq/protoc-go-plugins/protodoc"
+)
+
+var (
+	hdrTmpl = template.Must(template.New("header").Parse(`
+// Code generated by protoc-gen-go-gcppubsub. DO NOT EDIT.
+// source: {{.Source}}
+
+package {{.GoPkg}}
+
+import (
+    "context"
+{{- if .Strconv}}
+    "strconv"
+{{- end}}
+
+    "cloud.google.com/go/pubsub"
+    "github.com/f4tq/protoc-go-plugins/runtime/pubsubpb"
+    "github.com/golang/protobuf/proto"
+)
+`))
+
+	topicTmpl = template.Must(template.New("topic").Parse(`
+// {{.Name}}PubSubTopic is the ID of the topic {{.Name}} messages are
+// published to.{{.Doc}}
+const {{.Name}}PubSubTopic = {{printf "%q" .Topic}}
+{{if .Attributes}}
+// {{.Lower}}PubSubAttributes returns the attributes {{.Name}} fields are
+// published as.
+func {{.Lower}}PubSubAttributes(msg *{{.Name}}) map[string]string {
+    return map[string]string{
+{{- range .Attributes}}
+        {{printf "%q" .Name}}: {{.Value}},
+{{- end}}
+    }
+}
+{{end}}
+// Publish{{.Name}} publishes msg to {{.Name}}PubSubTopic
+{{- if .OrderingKey}}, ordered by its
+// {{.OrderingField}} field,{{end}} and returns the server-assigned message ID.
+// Call pubsubpb.Stop(client) before closing client.{{.Doc}}
+func Publish{{.Name}}(ctx context.Context, client *pubsub.Client, msg *{{.Name}}) (string, error) {
+    return pubsubpb.Publish(ctx, pubsubpb.Topic(client, {{.Name}}PubSubTopic), msg, {{if .OrderingKey}}{{.OrderingKey}}{{else}}""{{end}}, {{if .Attributes}}{{.Lower}}PubSubAttributes(msg){{else}}nil{{end}})
+}
+
+// {{.Name}}PubSubHandler handles received {{.Name}} messages.
+// pubsubpb.Message(ctx) returns the Pub/Sub message a message was decoded
+// from. Returning an error nacks the message so that it is redelivered.{{.Doc}}
+type {{.Name}}PubSubHandler interface {
+    Handle{{.Name}}(ctx context.Context, msg *{{.Name}}) error
+}
+
+// {{.Name}}PubSubHandlerFunc adapts a function to a {{.Name}}PubSubHandler.{{.Doc}}
+type {{.Name}}PubSubHandlerFunc func(ctx context.Context, msg *{{.Name}}) error
+
+// Handle{{.Name}} calls f(ctx, msg).
+func (f {{.Name}}PubSubHandlerFunc) Handle{{.Name}}(ctx context.Context, msg *{{.Name}}) error {
+    return f(ctx, msg)
+}
+
+// Receive{{.Name}} passes the {{.Name}} messages of subscription to h until
+// ctx is done.{{.Doc}}
+func Receive{{.Name}}(ctx context.Context, client *pubsub.Client, subscription string, h {{.Name}}PubSubHandler) error {
+    return pubsubpb.Receive(ctx, client.Subscription(subscription), func() proto.Message { return new({{.Name}}) }, func(ctx context.Context, msg proto.Message) error {
+        return h.Handle{{.Name}}(ctx, msg.(*{{.Name}}))
+    })
+}
+`))
+)
+
+func main() {
+	input, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	req := new(plugin.CodeGeneratorRequest)
+	if err = proto.Unmarshal(input, req); err != nil {
+		log.Fatal(err)
+	}
+
+	out, err := generate(req)
+
+	if err != nil {
+		emitError(err)
+		return
+	}
+
+	emitFiles(out)
+}
+
+func generate(req *plugin.CodeGeneratorRequest) ([]*plugin.CodeGeneratorResponse_File, error) {
+	var files []*plugin.CodeGeneratorResponse_File
+	docs := protodoc.New(req.GetProtoFile())
+	genFileNames := make(map[string]bool)
+	for _, n := range req.FileToGenerate {
+		genFileNames[n] = true
+	}
+	for _, desc := range req.GetProtoFile() {
+		name := desc.GetName()
+		if _, ok := genFileNames[name]; !ok {
+			// Only emit output for files present in req.FileToGenerate.
+			continue
+		}
+		code, err := genCode(desc, docs)
+		if err != nil {
+			return nil, err
+		}
+		if code == "" {
+			// No message declares a topic.
+			continue
+		}
+		formatted, err := format.Source([]byte(code))
+		if err != nil {
+			log.Printf("%v: %s", err, code)
+			return nil, err
+		}
+
+		ext := filepath.Ext(name)
+		base := strings.TrimSuffix(name, ext)
+		output := fmt.Sprintf("%s.pb.gcppubsub.go", base)
+		files = append(files, &plugin.CodeGeneratorResponse_File{
+			Name:    proto.String(output),
+			Content: proto.String(string(formatted)),
+		})
+	}
+
+	return files, nil
+}
+
+func genCode(desc *descriptor.FileDescriptorProto, docs *protodoc.Index) (string, error) {
+	w := bytes.NewBuffer(nil)
+	hdr := &header{
+		Source: desc.GetName(),
+		GoPkg:  defaultGoPackageName(desc),
+	}
+	prefix := ""
+	if desc.GetPackage() != "" {
+		prefix = "." + desc.GetPackage()
+	}
+	body := bytes.NewBuffer(nil)
+	if err := genTopics(body, hdr, docs, prefix, desc.GetMessageType()); err != nil {
+		return "", err
+	}
+	if body.Len() == 0 {
+		return "", nil
+	}
+
+	if err := hdrTmpl.Execute(w, hdr); err != nil {
+		log.Fatal(err)
+	}
+	body.WriteTo(w)
+
+	return w.String(), nil
+}
+
+// genTopics writes the publishers and subscription handlers of the
+// messages in msgs, and their nested messages, that have a
+// (f4tq.plugins.topic) option.
+func genTopics(w *bytes.Buffer, hdr *header, docs *protodoc.Index, prefix string, msgs []*descriptor.DescriptorProto) error {
+	for _, m := range msgs {
+		name := prefix + "." + m.GetName()
+		if m.GetOptions().GetMapEntry() {
+			continue
+		}
+		if topic := options.Topic(m); topic != "" {
+			goName := localTypeName(name)
+			t := &pubsubTopic{
+				Name:  goName,
+				Lower: strings.ToLower(goName[:1]) + goName[1:],
+				Topic: topic,
+				Doc:   docs.Godoc(name),
+			}
+			seen := make(map[string]bool)
+			goNames := goname.Fields(m)
+			for _, f := range m.GetField() {
+				fieldName := strings.TrimPrefix(name, ".") + "." + f.GetName()
+				if options.MessageKey(f) {
+					if t.OrderingKey != "" {
+						return fmt.Errorf("%s: more than one message_key field", strings.TrimPrefix(name, "."))
+					}
+					key, err := stringExpr(f, goNames[f.GetName()], hdr)
+					if err != nil {
+						return fmt.Errorf("%s: message_key %v", fieldName, err)
+					}
+					t.OrderingKey = key
+					t.OrderingField = f.GetName()
+				}
+				if attr := options.MessageAttribute(f); attr != "" {
+					if seen[attr] || attr == "content-type" || strings.HasPrefix(attr, "goog") {
+						return fmt.Errorf("%s: message_attribute %q is reserved or already used", fieldName, attr)
+					}
+					seen[attr] = true
+					value, err := stringExpr(f, goNames[f.GetName()], hdr)
+					if err != nil {
+						return fmt.Errorf("%s: message_attribute %v", fieldName, err)
+					}
+					t.Attributes = append(t.Attributes, &pubsubAttribute{Name: attr, Value: value})
+				}
+			}
+			if err := topicTmpl.Execute(w, t); err != nil {
+				return err
+			}
+		}
+		if err := genTopics(w, hdr, docs, name, m.GetNestedType()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// stringExpr returns the Go expression formatting field f of msg, whose Go
+// name is goName, as a string.
+func stringExpr(f *descriptor.FieldDescriptorProto, goName string, hdr *header) (string, error) {
+	if f.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REPEATED {
+		return "", fmt.Errorf("field must not be repeated")
+	}
+	get := "msg.Get" + goName + "()"
+	switch f.GetType() {
+	case descriptor.FieldDescriptorProto_TYPE_STRING:
+		return get, nil
+	case descriptor.FieldDescriptorProto_TYPE_ENUM:
+		return get + ".String()", nil
+	case descriptor.FieldDescriptorProto_TYPE_BOOL:
+		hdr.Strconv = true
+		return "strconv.FormatBool(" + get + ")", nil
+	case descriptor.FieldDescriptorProto_TYPE_INT32, descriptor.FieldDescriptorProto_TYPE_INT64,
+		descriptor.FieldDescriptorProto_TYPE_SINT32, descriptor.FieldDescriptorProto_TYPE_SINT64,
+		descriptor.FieldDescriptorProto_TYPE_SFIXED32, descriptor.FieldDescriptorProto_TYPE_SFIXED64:
+		hdr.Strconv = true
+		return "strconv.FormatInt(int64(" + get + "), 10)", nil
+	case descriptor.FieldDescriptorProto_TYPE_UINT32, descriptor.FieldDescriptorProto_TYPE_UINT64,
+		descriptor.FieldDescriptorProto_TYPE_FIXED32, descriptor.FieldDescriptorProto_TYPE_FIXED64:
+		hdr.Strconv = true
+		return "strconv.FormatUint(uint64(" + get + "), 10)", nil
+	case descriptor.FieldDescriptorProto_TYPE_FLOAT, descriptor.FieldDescriptorProto_TYPE_DOUBLE:
+		hdr.Strconv = true
+		return "strconv.FormatFloat(float64(" + get + "), 'g', -1, 64)", nil
+	}
+	return "", fmt.Errorf("field must be a scalar or enum other than bytes")
+}
+
+type header struct {
+	Source  string
+	GoPkg   string
+	Strconv bool
+}
+
+type pubsubTopic struct {
+	Name          string
+	Lower         string
+	Topic         string
+	OrderingKey   string
+	OrderingField string
+	Attributes    []*pubsubAttribute
+	// Doc is the comment of the message, see protodoc.Index.Godoc.
+	Doc string
+}
+
+type pubsubAttribute struct {
+	Name  string
+	Value string
+}
+
+// localTypeName returns the Go name of a type declared in the file being
+// generated, e.g. ".pkg.Outer.Inner" becomes "Outer_Inner".
+func localTypeName(typeName string) string {
+	parts := strings.Split(strings.TrimPrefix(typeName, "."), ".")
+	var names []string
+	for _, p := range parts {
+		if len(p) > 0 && p[0] >= 'A' && p[0] <= 'Z' {
+			names = append(names, p)
+		}
+	}
+	return strings.Join(names, "_")
+}
+
+// sanitizePackageName replaces unallowed character in package name
+// with allowed character.
+func sanitizePackageName(pkgName string) string {
+	pkgName = strings.Replace(pkgName, ".", "_", -1)
+	pkgName = strings.Replace(pkgName, "-", "_", -1)
+	return pkgName
+}
+
+// defaultGoPackageName returns the default go package name to be used for go files generated from "f".
+// You might need to use an unique alias for the package when you import it.  Use ReserveGoPackageAlias to get a unique alias.
+func defaultGoPackageName(f *descriptor.FileDescriptorProto) string {
+	name := packageIdentityName(f)
+	return sanitizePackageName(name)
+}
+
+// packageIdentityName returns the identity of packages.
+// protoc-gen-grpc-gateway rejects CodeGenerationRequests which contains more than one packages
+// as protoc-gen-go does.
+func packageIdentityName(f *descriptor.FileDescriptorProto) string {
+	if f.Options != nil && f.Options.GoPackage != nil {
+		gopkg := f.Options.GetGoPackage()
+		idx := strings.LastIndex(gopkg, "/")
+		if idx < 0 {
+			gopkg = gopkg[idx+1:]
+		}
+
+		gopkg = gopkg[idx+1:]
+		// package name is overrided with the string after the
+		// ';' character
+		sc := strings.IndexByte(gopkg, ';')
+		if sc < 0 {
+			return sanitizePackageName(gopkg)
+
+		}
+		return sanitizePackageName(gopkg[sc+1:])
+	}
+
+	if f.Package == nil {
+		base := filepath.Base(f.GetName())
+		ext := filepath.Ext(base)
+		return strings.TrimSuffix(base, ext)
+	}
+	return f.GetPackage()
+}
+
+func emitFiles(out []*plugin.CodeGeneratorResponse_File) {
+	emitResp(&plugin.CodeGeneratorResponse{File: out})
+}
+
+func emitError(err error) {
+	emitResp(&plugin.CodeGeneratorResponse{Error: proto.String(err.Error())})
+}
+
+func emitResp(resp *plugin.CodeGeneratorResponse) {
+	buf, err := proto.Marshal(resp)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if _, err := os.Stdout.Write(buf); err != nil {
+		log.Fatal(err)
+	}
+}
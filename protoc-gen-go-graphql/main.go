@@ -0,0 +1,841 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+
+	"github.com/f4tq/protoc-go-plugins/options"
+	"github.com/f4tq/protoc-go-plugins/protodoc"
+)
+
+const gqlpbPath = "github.com/f4tq/protoc-go-plugins/runtime/gqlpb"
+
+var (
+	hdrTmpl = template.Must(template.New("header").Parse(`
+// Code generated by protoc-gen-go-graphql. DO NOT EDIT.
+// source: {{.Source}}
+
+package {{.GoPkg}}
+
+import (
+{{- if .Services}}
+    "context"
+{{- end}}
+{{- if .Enums}}
+    "fmt"
+{{- end}}
+{{- if or .Enums .Subscriptions}}
+    "io"
+{{- end}}
+{{- if .Enums}}
+    "strconv"
+{{- end}}
+{{- if .Subscriptions}}
+
+    "github.com/99designs/gqlgen/graphql"
+{{- end}}
+{{- range $path, $name := .Imports}}
+    {{$name}} "{{$path}}"
+{{- end}}
+)
+`))
+
+	enumTmpl = template.Must(template.New("enum").Parse(`
+// MarshalGQL writes x as its GraphQL enum value.
+func (x {{.}}) MarshalGQL(w io.Writer) {
+    io.WriteString(w, strconv.Quote(x.String()))
+}
+
+// UnmarshalGQL reads x from a GraphQL enum value.
+func (x *{{.}}) UnmarshalGQL(v interface{}) error {
+    s, ok := v.(string)
+    if !ok {
+        return fmt.Errorf("enum {{.}} must be a string, not %T", v)
+    }
+    n, ok := {{.}}_value[s]
+    if !ok {
+        return fmt.Errorf("%q is not a valid {{.}}", s)
+    }
+    *x = {{.}}(n)
+    return nil
+}
+`))
+
+	resolverTmpl = template.Must(template.New("resolver").Parse(`
+{{- range .Roots}}
+// {{$.Name}}{{.Root}}Resolver resolves the {{.Lower}} fields of
+// {{$.FullName}} by calling Client. Embed it in the gqlgen {{.Lower}}
+// resolver.{{$.Doc}}
+type {{$.Name}}{{.Root}}Resolver struct {
+    Client {{$.Name}}Client
+}
+{{range .Methods}}
+// {{.Name}} resolves {{.Root}}.{{.Field}}.{{.Doc}}
+{{- if .Subscription}}
+func (r *{{$.Name}}{{.Root}}Resolver) {{.Name}}(ctx context.Context{{if .Input}}, input *{{.Input}}{{end}}) (<-chan *{{.Output}}, error) {
+    {{- template "input" .}}
+    stream, err := r.Client.{{.Name}}(ctx, input)
+    if err != nil {
+        return nil, err
+    }
+    ch := make(chan *{{.Output}})
+    go func() {
+        defer close(ch)
+        for {
+            m, err := stream.Recv()
+            if err != nil {
+                if err != io.EOF {
+                    graphql.AddError(ctx, err)
+                }
+                return
+            }
+            select {
+            case ch <- m:
+            case <-ctx.Done():
+                return
+            }
+        }
+    }()
+    return ch, nil
+}
+{{- else if .Output}}
+func (r *{{$.Name}}{{.Root}}Resolver) {{.Name}}(ctx context.Context{{if .Input}}, input *{{.Input}}{{end}}) (*{{.Output}}, error) {
+    {{- template "input" .}}
+    return r.Client.{{.Name}}(ctx, input)
+}
+{{- else}}
+func (r *{{$.Name}}{{.Root}}Resolver) {{.Name}}(ctx context.Context{{if .Input}}, input *{{.Input}}{{end}}) (bool, error) {
+    {{- template "input" .}}
+    if _, err := r.Client.{{.Name}}(ctx, input); err != nil {
+        return false, err
+    }
+    return true, nil
+}
+{{- end}}
+{{end}}
+{{- end}}
+{{- define "input"}}
+{{- if .Input}}
+    if input == nil {
+        input = new({{.Input}})
+    }
+{{- else}}
+    input := new({{.EmptyInput}})
+{{- end}}
+{{- end}}
+`))
+)
+
+// scalarsSchema declares the gqlpb custom scalars. It is written once, to
+// gqlpb.graphql, so that schemas generated from several files can share
+// it.
+const scalarsSchema = `# Code generated by protoc-gen-go-graphql. DO NOT EDIT.
+
+# 64-bit integers, encoded as strings.
+scalar Int64
+scalar Uint64
+# Unsigned 32-bit integers, encoded as numbers.
+scalar Uint32
+scalar Float32
+# Base64 encoded bytes.
+scalar Bytes
+# RFC 3339 timestamp, e.g. "2006-01-02T15:04:05Z".
+scalar Timestamp
+# Duration in seconds with an "s" suffix, e.g. "1.5s".
+scalar Duration
+# Comma separated field paths.
+scalar FieldMask
+`
+
+// scalarModels maps the gqlpb scalars to their gqlgen models.
+var scalarModels = []string{"Int64", "Uint64", "Uint32", "Float32", "Bytes", "Timestamp", "Duration", "FieldMask"}
+
+// wellKnownScalars maps the well-known types with a GraphQL scalar to it.
+// Other google.protobuf messages are not mapped.
+var wellKnownScalars = map[string]string{
+	".google.protobuf.Timestamp": "Timestamp",
+	".google.protobuf.Duration":  "Duration",
+	".google.protobuf.FieldMask": "FieldMask",
+}
+
+func main() {
+	input, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	req := new(plugin.CodeGeneratorRequest)
+	if err = proto.Unmarshal(input, req); err != nil {
+		log.Fatal(err)
+	}
+
+	out, err := generate(req)
+
+	if err != nil {
+		emitError(err)
+		return
+	}
+
+	emitFiles(out)
+}
+
+func generate(req *plugin.CodeGeneratorRequest) ([]*plugin.CodeGeneratorResponse_File, error) {
+	var files []*plugin.CodeGeneratorResponse_File
+	params := parseParams(req.GetParameter())
+	extend := params["roots"] == "extend"
+	idx := newTypeIndex(req.GetProtoFile())
+	docs := protodoc.New(req.GetProtoFile())
+	genFileNames := make(map[string]bool)
+	for _, n := range req.FileToGenerate {
+		genFileNames[n] = true
+	}
+	for _, desc := range req.GetProtoFile() {
+		name := desc.GetName()
+		if _, ok := genFileNames[name]; !ok {
+			// Only emit output for files present in req.FileToGenerate.
+			continue
+		}
+		g := &generator{idx: idx, docs: docs, desc: desc, extend: extend, empty: make(map[string]bool)}
+		if err := g.gen(); err != nil {
+			return nil, fmt.Errorf("%s: %v", name, err)
+		}
+		if g.schema.Len() == 0 {
+			// No message, enum or method maps to GraphQL.
+			continue
+		}
+
+		ext := filepath.Ext(name)
+		base := strings.TrimSuffix(name, ext)
+		files = append(files, &plugin.CodeGeneratorResponse_File{
+			Name:    proto.String(base + ".graphql"),
+			Content: proto.String(g.schema.String()),
+		}, &plugin.CodeGeneratorResponse_File{
+			Name:    proto.String(base + ".gqlgen.yml"),
+			Content: proto.String(g.models.String()),
+		})
+		if g.code.Len() == 0 {
+			continue
+		}
+		formatted, err := format.Source(g.code.Bytes())
+		if err != nil {
+			log.Printf("%v: %s", err, g.code.String())
+			return nil, err
+		}
+		files = append(files, &plugin.CodeGeneratorResponse_File{
+			Name:    proto.String(fmt.Sprintf("%s.pb.graphql.go", base)),
+			Content: proto.String(string(formatted)),
+		})
+	}
+	if len(files) > 0 {
+		var models bytes.Buffer
+		models.WriteString("# Code generated by protoc-gen-go-graphql. DO NOT EDIT.\n\nmodels:\n")
+		for _, s := range scalarModels {
+			fmt.Fprintf(&models, "  %s:\n    model: %s.%s\n", s, gqlpbPath, s)
+		}
+		files = append(files, &plugin.CodeGeneratorResponse_File{
+			Name:    proto.String("gqlpb.graphql"),
+			Content: proto.String(scalarsSchema),
+		}, &plugin.CodeGeneratorResponse_File{
+			Name:    proto.String("gqlpb.gqlgen.yml"),
+			Content: proto.String(models.String()),
+		})
+	}
+
+	return files, nil
+}
+
+// generator writes the GraphQL schema, the gqlgen model bindings and the Go
+// resolvers of one file.
+type generator struct {
+	idx    *typeIndex
+	docs   *protodoc.Index
+	desc   *descriptor.FileDescriptorProto
+	extend bool
+	// empty memoizes emptyMessage; entries are false while a message is
+	// being visited, so that recursive messages count as non-empty.
+	empty map[string]bool
+
+	schema bytes.Buffer
+	models bytes.Buffer
+	code   bytes.Buffer
+}
+
+func (g *generator) gen() error {
+	goPath := goImportPath(g.desc)
+	if goPath == "" {
+		return fmt.Errorf("go_package must name the import path gqlgen binds models to")
+	}
+	g.models.WriteString("# Code generated by protoc-gen-go-graphql. DO NOT EDIT.\n# source: " + g.desc.GetName() + "\n\nmodels:\n")
+	model := func(gqlName, goName string) {
+		fmt.Fprintf(&g.models, "  %s:\n    model: %s.%s\n", gqlName, goPath, goName)
+	}
+
+	prefix := ""
+	if g.desc.GetPackage() != "" {
+		prefix = "." + g.desc.GetPackage()
+	}
+	var enums []string
+	for _, e := range g.desc.GetEnumType() {
+		enums = append(enums, g.writeEnum(prefix+"."+e.GetName(), e))
+		model(localTypeName(prefix+"."+e.GetName()), localTypeName(prefix+"."+e.GetName()))
+	}
+	if err := g.writeMessages(prefix, g.desc.GetMessageType(), &enums, model); err != nil {
+		return err
+	}
+
+	imports := newImportSet(g.desc)
+	hdr := &header{
+		Source:  g.desc.GetName(),
+		GoPkg:   defaultGoPackageName(g.desc),
+		Imports: imports.names,
+		Enums:   len(enums) > 0,
+	}
+	var body bytes.Buffer
+	for _, e := range enums {
+		if err := enumTmpl.Execute(&body, e); err != nil {
+			return err
+		}
+	}
+	roots := make(map[string][]string)
+	for _, svc := range g.desc.GetService() {
+		fullName := svc.GetName()
+		if g.desc.GetPackage() != "" {
+			fullName = g.desc.GetPackage() + "." + svc.GetName()
+		}
+		s := &gqlService{Name: svc.GetName(), FullName: fullName, Doc: g.docs.Godoc(fullName)}
+		byRoot := make(map[string]*gqlRoot)
+		for _, m := range svc.GetMethod() {
+			root, err := operation(m)
+			if err != nil {
+				return fmt.Errorf("%s.%s: %v", fullName, m.GetName(), err)
+			}
+			if root == "" {
+				continue
+			}
+			method := &gqlMethod{
+				Name:         m.GetName(),
+				Root:         root,
+				Field:        strings.ToLower(m.GetName()[:1]) + m.GetName()[1:],
+				Subscription: root == "Subscription",
+				Doc:          g.docs.Godoc(fullName + "." + m.GetName()),
+			}
+			field := method.Field
+			if g.emptyMessage(m.GetInputType()) {
+				method.EmptyInput = imports.goTypeName(g.idx, m.GetInputType())
+			} else {
+				method.Input = imports.goTypeName(g.idx, m.GetInputType())
+				field += "(input: " + g.typeName(m.GetInputType(), true) + ")"
+			}
+			if g.emptyMessage(m.GetOutputType()) {
+				if method.Subscription {
+					return fmt.Errorf("%s.%s: subscription messages must have fields", fullName, m.GetName())
+				}
+				field += ": Boolean!"
+			} else {
+				method.Output = imports.goTypeName(g.idx, m.GetOutputType())
+				field += ": " + g.typeName(m.GetOutputType(), false)
+			}
+			roots[root] = append(roots[root], field)
+			r := byRoot[root]
+			if r == nil {
+				r = &gqlRoot{Root: root, Lower: strings.ToLower(root)}
+				byRoot[root] = r
+				s.Roots = append(s.Roots, r)
+			}
+			r.Methods = append(r.Methods, method)
+			hdr.Services = true
+			hdr.Subscriptions = hdr.Subscriptions || method.Subscription
+		}
+		if len(s.Roots) == 0 {
+			continue
+		}
+		if err := resolverTmpl.Execute(&body, s); err != nil {
+			return err
+		}
+	}
+	for _, root := range []string{"Query", "Mutation", "Subscription"} {
+		if len(roots[root]) == 0 {
+			continue
+		}
+		if g.extend {
+			g.schema.WriteString("extend ")
+		}
+		fmt.Fprintf(&g.schema, "type %s {\n", root)
+		for _, f := range roots[root] {
+			fmt.Fprintf(&g.schema, "  %s\n", f)
+		}
+		g.schema.WriteString("}\n\n")
+	}
+	if g.schema.Len() > 0 {
+		schema := g.schema.String()
+		g.schema.Reset()
+		fmt.Fprintf(&g.schema, "# Code generated by protoc-gen-go-graphql. DO NOT EDIT.\n# source: %s\n\n", g.desc.GetName())
+		g.schema.WriteString(strings.TrimSuffix(schema, "\n"))
+	}
+
+	if body.Len() == 0 {
+		return nil
+	}
+	if err := hdrTmpl.Execute(&g.code, hdr); err != nil {
+		return err
+	}
+	body.WriteTo(&g.code)
+	return nil
+}
+
+// writeEnum writes the GraphQL enum of e and returns its Go name.
+func (g *generator) writeEnum(name string, e *descriptor.EnumDescriptorProto) string {
+	goName := localTypeName(name)
+	fmt.Fprintf(&g.schema, "enum %s {\n", goName)
+	for _, v := range e.GetValue() {
+		if v.GetOptions().GetDeprecated() {
+			fmt.Fprintf(&g.schema, "  %s @deprecated\n", v.GetName())
+			continue
+		}
+		fmt.Fprintf(&g.schema, "  %s\n", v.GetName())
+	}
+	g.schema.WriteString("}\n\n")
+	return goName
+}
+
+// writeMessages writes the GraphQL object and input types of msgs and their
+// nested messages and enums.
+func (g *generator) writeMessages(prefix string, msgs []*descriptor.DescriptorProto, enums *[]string, model func(gqlName, goName string)) error {
+	for _, m := range msgs {
+		name := prefix + "." + m.GetName()
+		if m.GetOptions().GetMapEntry() {
+			continue
+		}
+		for _, e := range m.GetEnumType() {
+			*enums = append(*enums, g.writeEnum(name+"."+e.GetName(), e))
+			model(localTypeName(name+"."+e.GetName()), localTypeName(name+"."+e.GetName()))
+		}
+		if !g.emptyMessage(name) {
+			goName := localTypeName(name)
+			for _, input := range []bool{false, true} {
+				kind, gqlName := "type", goName
+				if input {
+					kind, gqlName = "input", goName+"Input"
+				}
+				fmt.Fprintf(&g.schema, "%s %s {\n", kind, gqlName)
+				for _, f := range m.GetField() {
+					if !g.mapped(f) {
+						continue
+					}
+					fmt.Fprintf(&g.schema, "  %s: %s\n", gqlFieldName(f), g.fieldType(f, input))
+				}
+				g.schema.WriteString("}\n\n")
+				model(gqlName, goName)
+			}
+		}
+		if err := g.writeMessages(name, m.GetNestedType(), enums, model); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mapped reports whether field has a GraphQL field. Map fields, oneof
+// members, and fields of empty or unmapped well-known message types are
+// left out.
+func (g *generator) mapped(field *descriptor.FieldDescriptorProto) bool {
+	if field.OneofIndex != nil && !field.GetProto3Optional() {
+		return false
+	}
+	if field.GetType() != descriptor.FieldDescriptorProto_TYPE_MESSAGE && field.GetType() != descriptor.FieldDescriptorProto_TYPE_GROUP {
+		return true
+	}
+	if g.idx.isMap(field) {
+		return false
+	}
+	return !g.emptyMessage(field.GetTypeName())
+}
+
+// emptyMessage reports whether the message typeName has no GraphQL fields,
+// in which case it has no GraphQL type.
+func (g *generator) emptyMessage(typeName string) bool {
+	if _, ok := wellKnownScalars[typeName]; ok {
+		return false
+	}
+	if strings.HasPrefix(typeName, ".google.protobuf.") {
+		return true
+	}
+	if empty, ok := g.empty[typeName]; ok {
+		return empty
+	}
+	g.empty[typeName] = false
+	empty := true
+	for _, f := range g.idx.messages[typeName].GetField() {
+		if g.mapped(f) {
+			empty = false
+			break
+		}
+	}
+	g.empty[typeName] = empty
+	return empty
+}
+
+// typeName returns the GraphQL type of the message typeName.
+func (g *generator) typeName(typeName string, input bool) string {
+	if s, ok := wellKnownScalars[typeName]; ok {
+		return s
+	}
+	if input {
+		return localTypeName(typeName) + "Input"
+	}
+	return localTypeName(typeName)
+}
+
+// fieldType returns the GraphQL type of field. Proto3 scalars of output
+// types are non-null, as they always have a value.
+func (g *generator) fieldType(field *descriptor.FieldDescriptorProto, input bool) string {
+	var t string
+	switch field.GetType() {
+	case descriptor.FieldDescriptorProto_TYPE_DOUBLE:
+		t = "Float"
+	case descriptor.FieldDescriptorProto_TYPE_FLOAT:
+		t = "Float32"
+	case descriptor.FieldDescriptorProto_TYPE_INT32, descriptor.FieldDescriptorProto_TYPE_SINT32, descriptor.FieldDescriptorProto_TYPE_SFIXED32:
+		t = "Int"
+	case descriptor.FieldDescriptorProto_TYPE_UINT32, descriptor.FieldDescriptorProto_TYPE_FIXED32:
+		t = "Uint32"
+	case descriptor.FieldDescriptorProto_TYPE_INT64, descriptor.FieldDescriptorProto_TYPE_SINT64, descriptor.FieldDescriptorProto_TYPE_SFIXED64:
+		t = "Int64"
+	case descriptor.FieldDescriptorProto_TYPE_UINT64, descriptor.FieldDescriptorProto_TYPE_FIXED64:
+		t = "Uint64"
+	case descriptor.FieldDescriptorProto_TYPE_BOOL:
+		t = "Boolean"
+	case descriptor.FieldDescriptorProto_TYPE_STRING:
+		t = "String"
+	case descriptor.FieldDescriptorProto_TYPE_BYTES:
+		t = "Bytes"
+	case descriptor.FieldDescriptorProto_TYPE_ENUM:
+		t = localTypeName(field.GetTypeName())
+	default:
+		return wrapList(field, g.typeName(field.GetTypeName(), input))
+	}
+	if field.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REPEATED {
+		return "[" + t + "!]"
+	}
+	if !input && g.desc.GetSyntax() == "proto3" && !field.GetProto3Optional() {
+		return t + "!"
+	}
+	return t
+}
+
+func wrapList(field *descriptor.FieldDescriptorProto, t string) string {
+	if field.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REPEATED {
+		return "[" + t + "!]"
+	}
+	return t
+}
+
+// operation returns the GraphQL root type method is a field of, or "" if
+// it is not exposed.
+func operation(m *descriptor.MethodDescriptorProto) (string, error) {
+	op := options.GraphQLOperation(m)
+	switch op {
+	case "":
+	case "none":
+		return "", nil
+	case "query", "mutation":
+		if m.GetClientStreaming() || m.GetServerStreaming() {
+			return "", fmt.Errorf("streaming methods can only be subscriptions")
+		}
+		return strings.Title(op), nil
+	case "subscription":
+		if m.GetClientStreaming() || !m.GetServerStreaming() {
+			return "", fmt.Errorf("only server-streaming methods can be subscriptions")
+		}
+		return "Subscription", nil
+	default:
+		return "", fmt.Errorf("unknown graphql_operation %q", op)
+	}
+	switch {
+	case m.GetClientStreaming():
+		return "", nil
+	case m.GetServerStreaming():
+		return "Subscription", nil
+	case m.GetOptions().GetIdempotencyLevel() == descriptor.MethodOptions_NO_SIDE_EFFECTS:
+		return "Query", nil
+	}
+	return "Mutation", nil
+}
+
+// gqlFieldName returns the GraphQL name of field, its lowerCamelCase JSON
+// name.
+func gqlFieldName(field *descriptor.FieldDescriptorProto) string {
+	if field.JsonName != nil {
+		return field.GetJsonName()
+	}
+	name := camelCase(field.GetName())
+	return strings.ToLower(name[:1]) + name[1:]
+}
+
+type header struct {
+	Source        string
+	GoPkg         string
+	Imports       map[string]string
+	Services      bool
+	Enums         bool
+	Subscriptions bool
+}
+
+type gqlService struct {
+	Name     string
+	FullName string
+	Roots    []*gqlRoot
+	// Doc is the comment of the service, see protodoc.Index.Godoc.
+	Doc string
+}
+
+type gqlRoot struct {
+	Root    string
+	Lower   string
+	Methods []*gqlMethod
+}
+
+type gqlMethod struct {
+	Name         string
+	Root         string
+	Field        string
+	Input        string
+	EmptyInput   string
+	Output       string
+	Subscription bool
+	// Doc is the comment of the method.
+	Doc string
+}
+
+// typeIndex maps fully-qualified proto type names (".pkg.Msg") to their
+// descriptors and declaring files for every file in the request.
+type typeIndex struct {
+	messages map[string]*descriptor.DescriptorProto
+	enums    map[string]*descriptor.EnumDescriptorProto
+	files    map[string]*descriptor.FileDescriptorProto
+}
+
+func newTypeIndex(files []*descriptor.FileDescriptorProto) *typeIndex {
+	idx := &typeIndex{
+		messages: make(map[string]*descriptor.DescriptorProto),
+		enums:    make(map[string]*descriptor.EnumDescriptorProto),
+		files:    make(map[string]*descriptor.FileDescriptorProto),
+	}
+	for _, f := range files {
+		prefix := ""
+		if f.GetPackage() != "" {
+			prefix = "." + f.GetPackage()
+		}
+		idx.addMessages(f, prefix, f.GetMessageType())
+		idx.addEnums(f, prefix, f.GetEnumType())
+	}
+	return idx
+}
+
+func (idx *typeIndex) addMessages(f *descriptor.FileDescriptorProto, prefix string, msgs []*descriptor.DescriptorProto) {
+	for _, m := range msgs {
+		name := prefix + "." + m.GetName()
+		idx.messages[name] = m
+		idx.files[name] = f
+		idx.addMessages(f, name, m.GetNestedType())
+		idx.addEnums(f, name, m.GetEnumType())
+	}
+}
+
+func (idx *typeIndex) addEnums(f *descriptor.FileDescriptorProto, prefix string, enums []*descriptor.EnumDescriptorProto) {
+	for _, e := range enums {
+		name := prefix + "." + e.GetName()
+		idx.enums[name] = e
+		idx.files[name] = f
+	}
+}
+
+// isMap reports whether field is a map field.
+func (idx *typeIndex) isMap(field *descriptor.FieldDescriptorProto) bool {
+	if field.GetType() != descriptor.FieldDescriptorProto_TYPE_MESSAGE {
+		return false
+	}
+	return idx.messages[field.GetTypeName()].GetOptions().GetMapEntry()
+}
+
+// goImportPath returns the import path of the Go package generated from f,
+// or "" if go_package does not name one.
+func goImportPath(f *descriptor.FileDescriptorProto) string {
+	gopkg := f.GetOptions().GetGoPackage()
+	if sc := strings.IndexByte(gopkg, ';'); sc >= 0 {
+		gopkg = gopkg[:sc]
+	}
+	if !strings.Contains(gopkg, "/") {
+		return ""
+	}
+	return gopkg
+}
+
+// importSet records the Go packages referenced by the code generated for
+// one file, keyed by import path.
+type importSet struct {
+	self  string
+	names map[string]string
+}
+
+func newImportSet(f *descriptor.FileDescriptorProto) *importSet {
+	return &importSet{self: goImportPath(f), names: make(map[string]string)}
+}
+
+// goTypeName returns the Go name of the message or enum typeName, qualified
+// with its package if it is declared outside the generated package.
+func (s *importSet) goTypeName(idx *typeIndex, typeName string) string {
+	name := localTypeName(typeName)
+	f := idx.files[typeName]
+	path := goImportPath(f)
+	if f == nil || path == "" || path == s.self {
+		return name
+	}
+	pkg := defaultGoPackageName(f)
+	s.names[path] = pkg
+	return pkg + "." + name
+}
+
+// localTypeName returns the Go name of a type declared in the file being
+// generated, e.g. ".pkg.Outer.Inner" becomes "Outer_Inner".
+func localTypeName(typeName string) string {
+	parts := strings.Split(strings.TrimPrefix(typeName, "."), ".")
+	var names []string
+	for _, p := range parts {
+		if len(p) > 0 && p[0] >= 'A' && p[0] <= 'Z' {
+			names = append(names, p)
+		}
+	}
+	return strings.Join(names, "_")
+}
+
+// camelCase returns the Go field name protoc-gen-go derives from s.
+func camelCase(s string) string {
+	if s == "" {
+		return ""
+	}
+	t := make([]byte, 0, 32)
+	i := 0
+	if s[0] == '_' {
+		// Need a capital letter; drop the '_'.
+		t = append(t, 'X')
+		i++
+	}
+	for ; i < len(s); i++ {
+		c := s[i]
+		if c == '_' && i+1 < len(s) && isASCIILower(s[i+1]) {
+			continue
+		}
+		if isASCIIDigit(c) {
+			t = append(t, c)
+			continue
+		}
+		if isASCIILower(c) {
+			c ^= ' '
+		}
+		t = append(t, c)
+		for i+1 < len(s) && isASCIILower(s[i+1]) {
+			i++
+			t = append(t, s[i])
+		}
+	}
+	return string(t)
+}
+
+func isASCIILower(c byte) bool {
+	return 'a' <= c && c <= 'z'
+}
+
+func isASCIIDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+// parseParams splits the comma separated key=value plugin parameter.
+func parseParams(param string) map[string]string {
+	params := make(map[string]string)
+	for _, p := range strings.Split(param, ",") {
+		if p == "" {
+			continue
+		}
+		if i := strings.IndexByte(p, '='); i >= 0 {
+			params[p[:i]] = p[i+1:]
+		} else {
+			params[p] = ""
+		}
+	}
+	return params
+}
+
+// sanitizePackageName replaces unallowed character in package name
+// with allowed character.
+func sanitizePackageName(pkgName string) string {
+	pkgName = strings.Replace(pkgName, ".", "_", -1)
+	pkgName = strings.Replace(pkgName, "-", "_", -1)
+	return pkgName
+}
+
+// defaultGoPackageName returns the default go package name to be used for go files generated from "f".
+// You might need to use an unique alias for the package when you import it.  Use ReserveGoPackageAlias to get a unique alias.
+func defaultGoPackageName(f *descriptor.FileDescriptorProto) string {
+	name := packageIdentityName(f)
+	return sanitizePackageName(name)
+}
+
+// packageIdentityName returns the identity of packages.
+// protoc-gen-grpc-gateway rejects CodeGenerationRequests which contains more than one packages
+// as protoc-gen-go does.
+func packageIdentityName(f *descriptor.FileDescriptorProto) string {
+	if f.Options != nil && f.Options.GoPackage != nil {
+		gopkg := f.Options.GetGoPackage()
+		idx := strings.LastIndex(gopkg, "/")
+		if idx < 0 {
+			gopkg = gopkg[idx+1:]
+		}
+
+		gopkg = gopkg[idx+1:]
+		// package name is overrided with the string after the
+		// ';' character
+		sc := strings.IndexByte(gopkg, ';')
+		if sc < 0 {
+			return sanitizePackageName(gopkg)
+
+		}
+		return sanitizePackageName(gopkg[sc+1:])
+	}
+
+	if f.Package == nil {
+		base := filepath.Base(f.GetName())
+		ext := filepath.Ext(base)
+		return strings.TrimSuffix(base, ext)
+	}
+	return f.GetPackage()
+}
+
+func emitFiles(out []*plugin.CodeGeneratorResponse_File) {
+	emitResp(&plugin.CodeGeneratorResponse{File: out})
+}
+
+func emitError(err error) {
+	emitResp(&plugin.CodeGeneratorResponse{Error: proto.String(err.Error())})
+}
+
+func emitResp(resp *plugin.CodeGeneratorResponse) {
+	buf, err := proto.Marshal(resp)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if _, err := os.Stdout.Write(buf); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,461 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+
+	"github.com/f4tq/protoc-go-plugins/goname"
+)
+
+var (
+	hdrTmpl = template.Must(template.New("header").Parse(`
+// Code generated by protoc-gen-go-hash. DO NOT EDIT.
+// source: {{.Source}}
+
+package {{.GoPkg}}
+
+import (
+    "github.com/f4tq/protoc-go-plugins/runtime/fingerprint"
+{{- range $path, $name := .Imports}}
+    {{$name}} "{{$path}}"
+{{- end}}
+)
+`))
+
+	hashTmpl = template.Must(template.New("hash").Parse(`
+// Hash64 returns a fingerprint of m for use in dedup and cache keys. It is
+// stable across processes and releases unless the schema of m changes.
+// Fields with default values and unknown fields do not contribute.
+func (m *{{.Name}}) Hash64() uint64 {
+    h := fingerprint.New()
+    m.HashTo(h)
+    return h.Sum64()
+}
+
+// HashBytes returns the SHA-256 fingerprint of m; see Hash64.
+func (m *{{.Name}}) HashBytes() []byte {
+    h := fingerprint.New()
+    m.HashTo(h)
+    return h.Sum()
+}
+
+// HashTo writes the fields of m to h in field number order.
+func (m *{{.Name}}) HashTo(h *fingerprint.Hasher) {
+    if m != nil {
+{{- range .Fields}}
+{{.}}
+{{- end}}
+    }
+    h.End()
+}
+`))
+)
+
+func main() {
+	input, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	req := new(plugin.CodeGeneratorRequest)
+	if err = proto.Unmarshal(input, req); err != nil {
+		log.Fatal(err)
+	}
+
+	out, err := generate(req)
+
+	if err != nil {
+		emitError(err)
+		return
+	}
+
+	emitFiles(out)
+}
+
+func generate(req *plugin.CodeGeneratorRequest) ([]*plugin.CodeGeneratorResponse_File, error) {
+	var files []*plugin.CodeGeneratorResponse_File
+	idx := newTypeIndex(req.GetProtoFile())
+	genFileNames := make(map[string]bool)
+	for _, n := range req.FileToGenerate {
+		genFileNames[n] = true
+	}
+	for _, desc := range req.GetProtoFile() {
+		name := desc.GetName()
+		if _, ok := genFileNames[name]; !ok {
+			// Only emit output for files present in req.FileToGenerate.
+			continue
+		}
+		code, err := genCode(desc, idx, genFileNames)
+		if err != nil {
+			return nil, err
+		}
+		if code == "" {
+			// The file declares no messages.
+			continue
+		}
+		formatted, err := format.Source([]byte(code))
+		if err != nil {
+			log.Printf("%v: %s", err, code)
+			return nil, err
+		}
+
+		ext := filepath.Ext(name)
+		base := strings.TrimSuffix(name, ext)
+		output := fmt.Sprintf("%s.pb.hash.go", base)
+		files = append(files, &plugin.CodeGeneratorResponse_File{
+			Name:    proto.String(output),
+			Content: proto.String(string(formatted)),
+		})
+	}
+
+	return files, nil
+}
+
+func genCode(desc *descriptor.FileDescriptorProto, idx *typeIndex, genFileNames map[string]bool) (string, error) {
+	w := bytes.NewBuffer(nil)
+	g := &hashGen{
+		idx:     idx,
+		imports: newImportSet(desc),
+		gen:     genFileNames,
+		proto3:  desc.GetSyntax() == "proto3",
+	}
+	body := bytes.NewBuffer(nil)
+	if err := g.messages(body, "", desc.GetMessageType()); err != nil {
+		return "", err
+	}
+	if body.Len() == 0 {
+		return "", nil
+	}
+
+	hdr := &header{
+		Source:  desc.GetName(),
+		GoPkg:   defaultGoPackageName(desc),
+		Imports: g.imports.names,
+	}
+	if err := hdrTmpl.Execute(w, hdr); err != nil {
+		log.Fatal(err)
+	}
+	body.WriteTo(w)
+
+	return w.String(), nil
+}
+
+type hashGen struct {
+	idx     *typeIndex
+	imports *importSet
+	gen     map[string]bool
+	proto3  bool
+}
+
+// messages writes the hash methods of msgs and of the messages nested in
+// them. prefix is the Go name of the enclosing message plus "_".
+func (g *hashGen) messages(w *bytes.Buffer, prefix string, msgs []*descriptor.DescriptorProto) error {
+	for _, msg := range msgs {
+		if msg.GetOptions().GetMapEntry() {
+			continue
+		}
+		name := prefix + msg.GetName()
+		fields := append([]*descriptor.FieldDescriptorProto(nil), msg.GetField()...)
+		sort.Slice(fields, func(i, j int) bool {
+			return fields[i].GetNumber() < fields[j].GetNumber()
+		})
+		m := &hashMessage{Name: name}
+		goNames := goname.Fields(msg)
+		done := make(map[int32]bool)
+		for _, field := range fields {
+			if field.OneofIndex != nil && !field.GetProto3Optional() {
+				// The members of a oneof are hashed where its lowest
+				// numbered field is.
+				i := field.GetOneofIndex()
+				if !done[i] {
+					done[i] = true
+					m.Fields = append(m.Fields, g.oneof(name, msg, goNames, fields, i))
+				}
+				continue
+			}
+			m.Fields = append(m.Fields, g.field(field, "m."+goNames[field.GetName()]))
+		}
+		if err := hashTmpl.Execute(w, m); err != nil {
+			return err
+		}
+		if err := g.messages(w, name+"_", msg.GetNestedType()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// field returns the statements writing field, held in v, to h if it is set.
+func (g *hashGen) field(field *descriptor.FieldDescriptorProto, v string) string {
+	num := field.GetNumber()
+	switch {
+	case g.idx.isMap(field):
+		entry := g.idx.messages[field.GetTypeName()]
+		return fmt.Sprintf("if len(%s) > 0 {\nh.Field(%d)\nentries := make([][]byte, 0, len(%s))\nfor k, v := range %s {\ne := fingerprint.New()\n%s\n%s\nentries = append(entries, e.Sum())\n}\nh.Unordered(entries)\n}",
+			v, num, v, v, g.write("e", entry.GetField()[0], "k"), g.write("e", entry.GetField()[1], "v"))
+	case field.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REPEATED:
+		return fmt.Sprintf("if len(%s) > 0 {\nh.Field(%d)\nh.Len(len(%s))\nfor _, v := range %s {\n%s\n}\n}",
+			v, num, v, v, g.write("h", field, "v"))
+	case field.GetType() == descriptor.FieldDescriptorProto_TYPE_MESSAGE,
+		field.GetType() == descriptor.FieldDescriptorProto_TYPE_GROUP:
+		return fmt.Sprintf("if %s != nil {\nh.Field(%d)\n%s\n}", v, num, g.write("h", field, v))
+	case field.GetType() == descriptor.FieldDescriptorProto_TYPE_BYTES && (field.GetProto3Optional() || !g.proto3):
+		// Bytes are nil when unset.
+		return fmt.Sprintf("if %s != nil {\nh.Field(%d)\n%s\n}", v, num, g.write("h", field, v))
+	case field.GetProto3Optional() || !g.proto3:
+		// Other scalars are pointers.
+		return fmt.Sprintf("if %s != nil {\nh.Field(%d)\n%s\n}", v, num, g.write("h", field, "*"+v))
+	}
+	return fmt.Sprintf("if %s {\nh.Field(%d)\n%s\n}", g.isSet(field, v), num, g.write("h", field, v))
+}
+
+// isSet returns the condition under which the proto3 scalar v differs from
+// its default value.
+func (g *hashGen) isSet(field *descriptor.FieldDescriptorProto, v string) string {
+	switch field.GetType() {
+	case descriptor.FieldDescriptorProto_TYPE_BOOL:
+		return v
+	case descriptor.FieldDescriptorProto_TYPE_STRING:
+		return v + ` != ""`
+	case descriptor.FieldDescriptorProto_TYPE_BYTES:
+		return "len(" + v + ") > 0"
+	}
+	return v + " != 0"
+}
+
+// write returns the statement writing the value v of field to the Hasher h.
+func (g *hashGen) write(h string, field *descriptor.FieldDescriptorProto, v string) string {
+	switch field.GetType() {
+	case descriptor.FieldDescriptorProto_TYPE_MESSAGE, descriptor.FieldDescriptorProto_TYPE_GROUP:
+		if g.gen[g.idx.files[field.GetTypeName()].GetName()] {
+			return fmt.Sprintf("%s.HashTo(%s)", v, h)
+		}
+		// Messages of other packages have no HashTo method.
+		return fmt.Sprintf("%s.Proto(%s)", h, v)
+	case descriptor.FieldDescriptorProto_TYPE_STRING:
+		return fmt.Sprintf("%s.String(%s)", h, v)
+	case descriptor.FieldDescriptorProto_TYPE_BYTES:
+		return fmt.Sprintf("%s.Bytes(%s)", h, v)
+	case descriptor.FieldDescriptorProto_TYPE_BOOL:
+		return fmt.Sprintf("%s.Bool(%s)", h, v)
+	case descriptor.FieldDescriptorProto_TYPE_DOUBLE:
+		return fmt.Sprintf("%s.Float64(%s)", h, v)
+	case descriptor.FieldDescriptorProto_TYPE_FLOAT:
+		return fmt.Sprintf("%s.Float64(float64(%s))", h, v)
+	case descriptor.FieldDescriptorProto_TYPE_UINT64, descriptor.FieldDescriptorProto_TYPE_FIXED64:
+		return fmt.Sprintf("%s.Uint64(%s)", h, v)
+	case descriptor.FieldDescriptorProto_TYPE_UINT32, descriptor.FieldDescriptorProto_TYPE_FIXED32:
+		return fmt.Sprintf("%s.Uint64(uint64(%s))", h, v)
+	case descriptor.FieldDescriptorProto_TYPE_INT64, descriptor.FieldDescriptorProto_TYPE_SINT64,
+		descriptor.FieldDescriptorProto_TYPE_SFIXED64:
+		return fmt.Sprintf("%s.Int64(%s)", h, v)
+	}
+	// 32-bit signed integers and enums.
+	return fmt.Sprintf("%s.Int64(int64(%s))", h, v)
+}
+
+// oneof returns the statements writing the set member of the oneof i of
+// msg. goNames are the Go names of its fields and oneofs, fields its
+// fields in number order.
+func (g *hashGen) oneof(msgName string, msg *descriptor.DescriptorProto, goNames map[string]string, fields []*descriptor.FieldDescriptorProto, i int32) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "switch v := m.%s.(type) {\n", goNames[msg.GetOneofDecl()[i].GetName()])
+	for _, field := range fields {
+		if field.OneofIndex == nil || field.GetOneofIndex() != i || field.GetProto3Optional() {
+			continue
+		}
+		goName := goNames[field.GetName()]
+		fmt.Fprintf(&b, "case *%s_%s:\n", msgName, goName)
+		fmt.Fprintf(&b, "h.Field(%d)\n%s\n", field.GetNumber(), g.write("h", field, "v."+goName))
+	}
+	b.WriteString("}")
+	return b.String()
+}
+
+type header struct {
+	Source  string
+	GoPkg   string
+	Imports map[string]string
+}
+
+type hashMessage struct {
+	Name   string
+	Fields []string
+}
+
+// typeIndex maps fully-qualified proto type names (".pkg.Msg") to their
+// descriptors and declaring files for every file in the request.
+type typeIndex struct {
+	messages map[string]*descriptor.DescriptorProto
+	enums    map[string]*descriptor.EnumDescriptorProto
+	files    map[string]*descriptor.FileDescriptorProto
+}
+
+func newTypeIndex(files []*descriptor.FileDescriptorProto) *typeIndex {
+	idx := &typeIndex{
+		messages: make(map[string]*descriptor.DescriptorProto),
+		enums:    make(map[string]*descriptor.EnumDescriptorProto),
+		files:    make(map[string]*descriptor.FileDescriptorProto),
+	}
+	for _, f := range files {
+		prefix := ""
+		if f.GetPackage() != "" {
+			prefix = "." + f.GetPackage()
+		}
+		idx.addMessages(f, prefix, f.GetMessageType())
+		idx.addEnums(f, prefix, f.GetEnumType())
+	}
+	return idx
+}
+
+func (idx *typeIndex) addMessages(f *descriptor.FileDescriptorProto, prefix string, msgs []*descriptor.DescriptorProto) {
+	for _, m := range msgs {
+		name := prefix + "." + m.GetName()
+		idx.messages[name] = m
+		idx.files[name] = f
+		idx.addMessages(f, name, m.GetNestedType())
+		idx.addEnums(f, name, m.GetEnumType())
+	}
+}
+
+func (idx *typeIndex) addEnums(f *descriptor.FileDescriptorProto, prefix string, enums []*descriptor.EnumDescriptorProto) {
+	for _, e := range enums {
+		name := prefix + "." + e.GetName()
+		idx.enums[name] = e
+		idx.files[name] = f
+	}
+}
+
+// isMap reports whether field is a map field.
+func (idx *typeIndex) isMap(field *descriptor.FieldDescriptorProto) bool {
+	if field.GetType() != descriptor.FieldDescriptorProto_TYPE_MESSAGE {
+		return false
+	}
+	return idx.messages[field.GetTypeName()].GetOptions().GetMapEntry()
+}
+
+// goImportPath returns the import path of the Go package generated from f,
+// or "" if go_package does not name one.
+func goImportPath(f *descriptor.FileDescriptorProto) string {
+	gopkg := f.GetOptions().GetGoPackage()
+	if sc := strings.IndexByte(gopkg, ';'); sc >= 0 {
+		gopkg = gopkg[:sc]
+	}
+	if !strings.Contains(gopkg, "/") {
+		return ""
+	}
+	return gopkg
+}
+
+// importSet records the Go packages referenced by the code generated for
+// one file, keyed by import path.
+type importSet struct {
+	self  string
+	names map[string]string
+}
+
+func newImportSet(f *descriptor.FileDescriptorProto) *importSet {
+	return &importSet{self: goImportPath(f), names: make(map[string]string)}
+}
+
+// goTypeName returns the Go name of the message or enum typeName, qualified
+// with its package if it is declared outside the generated package.
+func (s *importSet) goTypeName(idx *typeIndex, typeName string) string {
+	name := localTypeName(typeName)
+	f := idx.files[typeName]
+	path := goImportPath(f)
+	if f == nil || path == "" || path == s.self {
+		return name
+	}
+	pkg := defaultGoPackageName(f)
+	s.names[path] = pkg
+	return pkg + "." + name
+}
+
+// localTypeName returns the Go name of a type declared in the file being
+// generated, e.g. ".pkg.Outer.Inner" becomes "Outer_Inner".
+func localTypeName(typeName string) string {
+	parts := strings.Split(strings.TrimPrefix(typeName, "."), ".")
+	var names []string
+	for _, p := range parts {
+		if len(p) > 0 && p[0] >= 'A' && p[0] <= 'Z' {
+			names = append(names, p)
+		}
+	}
+	return strings.Join(names, "_")
+}
+
+// sanitizePackageName replaces unallowed character in package name
+// with allowed character.
+func sanitizePackageName(pkgName string) string {
+	pkgName = strings.Replace(pkgName, ".", "_", -1)
+	pkgName = strings.Replace(pkgName, "-", "_", -1)
+	return pkgName
+}
+
+// defaultGoPackageName returns the default go package name to be used for go files generated from "f".
+// You might need to use an unique alias for the package when you import it.  Use ReserveGoPackageAlias to get a unique alias.
+func defaultGoPackageName(f *descriptor.FileDescriptorProto) string {
+	name := packageIdentityName(f)
+	return sanitizePackageName(name)
+}
+
+// packageIdentityName returns the identity of packages.
+// protoc-gen-grpc-gateway rejects CodeGenerationRequests which contains more than one packages
+// as protoc-gen-go does.
+func packageIdentityName(f *descriptor.FileDescriptorProto) string {
+	if f.Options != nil && f.Options.GoPackage != nil {
+		gopkg := f.Options.GetGoPackage()
+		idx := strings.LastIndex(gopkg, "/")
+		if idx < 0 {
+			gopkg = gopkg[idx+1:]
+		}
+
+		gopkg = gopkg[idx+1:]
+		// package name is overrided with the string after the
+		// ';' character
+		sc := strings.IndexByte(gopkg, ';')
+		if sc < 0 {
+			return sanitizePackageName(gopkg)
+
+		}
+		return sanitizePackageName(gopkg[sc+1:])
+	}
+
+	if f.Package == nil {
+		base := filepath.Base(f.GetName())
+		ext := filepath.Ext(base)
+		return strings.TrimSuffix(base, ext)
+	}
+	return f.GetPackage()
+}
+
+func emitFiles(out []*plugin.CodeGeneratorResponse_File) {
+	emitResp(&plugin.CodeGeneratorResponse{File: out})
+}
+
+func emitError(err error) {
+	emitResp(&plugin.CodeGeneratorResponse{Error: proto.String(err.Error())})
+}
+
+func emitResp(resp *plugin.CodeGeneratorResponse) {
+	buf, err := proto.Marshal(resp)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if _, err := os.Stdout.Write(buf); err != nil {
+		log.Fatal(err)
+	}
+}
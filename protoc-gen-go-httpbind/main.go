@@ -0,0 +1,585 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"log"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+
+	"github.com/f4tq/protoc-go-plugins/goname"
+	"github.com/f4tq/protoc-go-plugins/httprule"
+	"github.com/f4tq/protoc-go-plugins/options"
+	"github.com/f4tq/protoc-go-plugins/protodoc"
+)
+
+var (
+	hdrTmpl = template.Must(template.New("header").Parse(`
+// Code generated by protoc-gen-go-httpbind. DO NOT EDIT.
+// source: {{.Source}}
+
+package {{.GoPkg}}
+
+import (
+    "net/http"
+
+    "github.com/f4tq/protoc-go-plugins/runtime/httpbind"
+    "github.com/f4tq/protoc-go-plugins/runtime/httpgw"
+{{- range $path, $name := .Imports}}
+    {{$name}} "{{$path}}"
+{{- end}}
+)
+`))
+
+	bindTmpl = template.Must(template.New("bind").Parse(`
+{{- range .Routes}}
+
+var {{.Pattern}} = httpgw.MustParsePattern({{printf "%q" .Template}})
+{{- end}}
+
+// {{.Func}} returns the {{.Name}} bound from r,
+// following the google.api.http rules of {{.Methods}}:
+// its path variables, query parameters, headers and JSON body. It returns
+// an *httpgw.Error with status 404 if no rule matches r, and otherwise an
+// *httpbind.Error listing the fields that do not bind.{{.Doc}}
+func {{.Func}}(r *http.Request) (*{{.Input}}, error) {
+    req := new({{.Input}})
+    b := new(httpbind.Binder)
+{{- range $i, $r := .Routes}}
+    {{if $i}}} else {{end}}if {{.Params}}, ok := httpbind.Match(r, {{printf "%q" .HTTPMethod}}, {{.Pattern}}); ok {
+{{- .Bind}}
+{{- end}}
+    } else {
+        return nil, httpbind.NoMatch(r)
+    }
+{{- .Headers}}
+    return req, b.Err()
+}
+`))
+)
+
+func main() {
+	input, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	req := new(plugin.CodeGeneratorRequest)
+	if err = proto.Unmarshal(input, req); err != nil {
+		log.Fatal(err)
+	}
+
+	out, err := generate(req)
+
+	if err != nil {
+		emitError(err)
+		return
+	}
+
+	emitFiles(out)
+}
+
+func generate(req *plugin.CodeGeneratorRequest) ([]*plugin.CodeGeneratorResponse_File, error) {
+	var files []*plugin.CodeGeneratorResponse_File
+	idx := newTypeIndex(req.GetProtoFile())
+	docs := protodoc.New(req.GetProtoFile())
+	genFileNames := make(map[string]bool)
+	for _, n := range req.FileToGenerate {
+		genFileNames[n] = true
+	}
+	for _, desc := range req.GetProtoFile() {
+		name := desc.GetName()
+		if _, ok := genFileNames[name]; !ok {
+			// Only emit output for files present in req.FileToGenerate.
+			continue
+		}
+		code, err := genCode(desc, idx, docs)
+		if err != nil {
+			return nil, err
+		}
+		if code == "" {
+			// The file has no methods with google.api.http rules.
+			continue
+		}
+		formatted, err := format.Source([]byte(code))
+		if err != nil {
+			log.Printf("%v: %s", err, code)
+			return nil, err
+		}
+
+		ext := filepath.Ext(name)
+		base := strings.TrimSuffix(name, ext)
+		output := fmt.Sprintf("%s.pb.httpbind.go", base)
+		files = append(files, &plugin.CodeGeneratorResponse_File{
+			Name:    proto.String(output),
+			Content: proto.String(string(formatted)),
+		})
+	}
+
+	return files, nil
+}
+
+func genCode(desc *descriptor.FileDescriptorProto, idx *typeIndex, docs *protodoc.Index) (string, error) {
+	g := &bindGen{idx: idx, imports: newImportSet(desc)}
+	// The binders by input type, in the order of their first method.
+	var binders []*binder
+	byInput := make(map[string]*binder)
+	for _, svc := range desc.GetService() {
+		for _, m := range svc.GetMethod() {
+			bindings, err := httprule.Bindings(m)
+			if err != nil {
+				return "", fmt.Errorf("%s: %s: %v", desc.GetName(), svc.GetName(), err)
+			}
+			if len(bindings) == 0 {
+				continue
+			}
+			if m.GetClientStreaming() || m.GetServerStreaming() {
+				// Their requests are not single JSON bodies.
+				continue
+			}
+			bd := byInput[m.GetInputType()]
+			if bd == nil {
+				input := g.imports.goTypeName(g.idx, m.GetInputType())
+				name := localTypeName(m.GetInputType())
+				bd = &binder{
+					Name:  name,
+					Func:  "Bind" + name,
+					Input: input,
+					Doc:   docs.Godoc(m.GetInputType()),
+				}
+				headers, err := g.headers(m.GetInputType())
+				if err != nil {
+					return "", fmt.Errorf("%s: %s: %v", desc.GetName(), name, err)
+				}
+				bd.Headers = headers
+				byInput[m.GetInputType()] = bd
+				binders = append(binders, bd)
+			}
+			bd.methods = append(bd.methods, svc.GetName()+"."+m.GetName())
+			for i, b := range bindings {
+				bind, err := g.bind(m.GetInputType(), b)
+				if err != nil {
+					return "", fmt.Errorf("%s: %s.%s: %v", desc.GetName(), svc.GetName(), m.GetName(), err)
+				}
+				params := "params"
+				if len(b.Pattern.Vars()) == 0 {
+					params = "_"
+				}
+				bd.Routes = append(bd.Routes, &route{
+					HTTPMethod: b.Method,
+					Template:   b.Template,
+					Pattern:    fmt.Sprintf("bindPattern%s%s%d", svc.GetName(), m.GetName(), i),
+					Params:     params,
+					Bind:       bind,
+				})
+			}
+		}
+	}
+	if len(binders) == 0 {
+		return "", nil
+	}
+
+	body := bytes.NewBuffer(nil)
+	for _, bd := range binders {
+		bd.Methods = strings.Join(bd.methods, ", ")
+		if err := bindTmpl.Execute(body, bd); err != nil {
+			return "", err
+		}
+	}
+	hdr := &header{
+		Source:  desc.GetName(),
+		GoPkg:   defaultGoPackageName(desc),
+		Imports: g.imports.names,
+	}
+	w := bytes.NewBuffer(nil)
+	if err := hdrTmpl.Execute(w, hdr); err != nil {
+		log.Fatal(err)
+	}
+	body.WriteTo(w)
+
+	return w.String(), nil
+}
+
+type bindGen struct {
+	idx     *typeIndex
+	imports *importSet
+}
+
+// bind returns the statements binding the path variables, body and query
+// parameters of a request matching b to req, of type typeName.
+func (g *bindGen) bind(typeName string, b *httprule.Binding) (string, error) {
+	in := g.idx.messages[typeName]
+	w := bytes.NewBuffer(nil)
+	bound := make(map[string]bool)
+	for _, field := range in.GetField() {
+		if options.Header(field) != "" {
+			bound[field.GetName()] = true
+		}
+	}
+	// The body is decoded first so that the path variables take
+	// precedence over the fields it sets.
+	switch b.Body {
+	case "":
+	case "*":
+		w.WriteString("\nif err := httpbind.DecodeBody(r, req); err != nil {\nb.Fail(\"\", \"body\", err)\n}")
+	default:
+		field := findField(in, b.Body)
+		if field == nil || field.GetType() != descriptor.FieldDescriptorProto_TYPE_MESSAGE ||
+			field.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REPEATED {
+			return "", fmt.Errorf("body %q must name a singular message field", b.Body)
+		}
+		goName := goname.Fields(in)[field.GetName()]
+		fmt.Fprintf(w, "\nif req.%s == nil {\nreq.%s = new(%s)\n}\nif err := httpbind.DecodeBody(r, req.%s); err != nil {\nb.Fail(%q, \"body\", err)\n}",
+			goName, goName, g.imports.goTypeName(g.idx, field.GetTypeName()), goName, b.Body)
+		bound[b.Body] = true
+	}
+
+	for _, v := range b.Pattern.Vars() {
+		stmts, err := g.assign("req", typeName, strings.Split(v, "."), fmt.Sprintf("params[%q]", v), "path")
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(w, "\n{\n%s\n}", stmts)
+		bound[strings.Split(v, ".")[0]] = true
+	}
+
+	if b.Body == "*" {
+		// The body holds the fields not bound by the path.
+		return w.String(), nil
+	}
+
+	var cases []string
+	for _, field := range in.GetField() {
+		if bound[field.GetName()] || field.GetType() == descriptor.FieldDescriptorProto_TYPE_MESSAGE ||
+			field.GetType() == descriptor.FieldDescriptorProto_TYPE_GROUP {
+			continue
+		}
+		names := fmt.Sprintf("%q", field.GetName())
+		if json := field.GetJsonName(); json != "" && json != field.GetName() {
+			names += fmt.Sprintf(", %q", json)
+		}
+		if field.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REPEATED {
+			cases = append(cases, fmt.Sprintf("case %s:\n%s", names, g.appendAll(in, field, "values", "query")))
+			continue
+		}
+		stmts, err := g.assign("req", typeName, []string{field.GetName()}, "values[len(values)-1]", "query")
+		if err != nil {
+			return "", err
+		}
+		cases = append(cases, fmt.Sprintf("case %s:\n%s", names, stmts))
+	}
+	if len(cases) > 0 {
+		fmt.Fprintf(w, "\nfor key, values := range r.URL.Query() {\nif len(values) == 0 {\ncontinue\n}\nswitch key {\n%s\n}\n}", strings.Join(cases, "\n"))
+	}
+	return w.String(), nil
+}
+
+// headers returns the statements binding the fields of the message
+// typeName with a (f4tq.plugins.header) to the values of their headers.
+func (g *bindGen) headers(typeName string) (string, error) {
+	w := bytes.NewBuffer(nil)
+	in := g.idx.messages[typeName]
+	for _, field := range in.GetField() {
+		h := options.Header(field)
+		if h == "" {
+			continue
+		}
+		if field.GetType() == descriptor.FieldDescriptorProto_TYPE_MESSAGE ||
+			field.GetType() == descriptor.FieldDescriptorProto_TYPE_GROUP {
+			return "", fmt.Errorf("the header field %s must be a scalar or enum", field.GetName())
+		}
+		values := fmt.Sprintf("r.Header[%q]", textproto.CanonicalMIMEHeaderKey(h))
+		if field.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REPEATED {
+			fmt.Fprintf(w, "\n%s", g.appendAll(in, field, values, "header"))
+			continue
+		}
+		stmts, err := g.assign("req", typeName, []string{field.GetName()}, "values[0]", "header")
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(w, "\nif values := %s; len(values) > 0 {\n%s\n}", values, stmts)
+	}
+	return w.String(), nil
+}
+
+// appendAll returns the statements appending the string slice expression
+// values, parsed, to the repeated field of req, a message in.
+func (g *bindGen) appendAll(in *descriptor.DescriptorProto, field *descriptor.FieldDescriptorProto, values, source string) string {
+	goName := goname.Fields(in)[field.GetName()]
+	conv, expr := g.convert(field, "s")
+	if conv == "" {
+		return fmt.Sprintf("req.%s = append(req.%s, %s...)", goName, goName, values)
+	}
+	return fmt.Sprintf("for _, s := range %s {\n%s\nif err != nil {\nb.Fail(%q, %q, err)\ncontinue\n}\nreq.%s = append(req.%s, %s)\n}",
+		values, conv, field.GetName(), source, goName, goName, expr)
+}
+
+// assign returns the statements setting the field at path, relative to the
+// message expression target of type typeName, to the string expression src,
+// recording the errors parsing it from source. Intermediate messages are
+// allocated as needed.
+func (g *bindGen) assign(target, typeName string, path []string, src, source string) (string, error) {
+	f, err := httprule.ResolveField(g, target, typeName, path)
+	if err != nil {
+		return "", err
+	}
+	w := bytes.NewBufferString(f.Alloc)
+	conv, expr := g.convert(f.Desc, src)
+	if conv == "" {
+		w.WriteString(f.Set(expr))
+	} else {
+		fmt.Fprintf(w, "%s\nif err != nil {\nb.Fail(%q, %q, err)\n} else {\n%s\n}", conv, strings.Join(path, "."), source, f.Set(expr))
+	}
+	return w.String(), nil
+}
+
+// Message implements httprule.Types.
+func (g *bindGen) Message(typeName string) (*descriptor.DescriptorProto, *descriptor.FileDescriptorProto) {
+	return g.idx.messages[typeName], g.idx.files[typeName]
+}
+
+// GoType implements httprule.Types.
+func (g *bindGen) GoType(typeName string) string {
+	return g.imports.goTypeName(g.idx, typeName)
+}
+
+var convertFuncs = map[descriptor.FieldDescriptorProto_Type]string{
+	descriptor.FieldDescriptorProto_TYPE_BOOL:     "Bool",
+	descriptor.FieldDescriptorProto_TYPE_BYTES:    "Bytes",
+	descriptor.FieldDescriptorProto_TYPE_INT32:    "Int32",
+	descriptor.FieldDescriptorProto_TYPE_SINT32:   "Int32",
+	descriptor.FieldDescriptorProto_TYPE_SFIXED32: "Int32",
+	descriptor.FieldDescriptorProto_TYPE_INT64:    "Int64",
+	descriptor.FieldDescriptorProto_TYPE_SINT64:   "Int64",
+	descriptor.FieldDescriptorProto_TYPE_SFIXED64: "Int64",
+	descriptor.FieldDescriptorProto_TYPE_UINT32:   "Uint32",
+	descriptor.FieldDescriptorProto_TYPE_FIXED32:  "Uint32",
+	descriptor.FieldDescriptorProto_TYPE_UINT64:   "Uint64",
+	descriptor.FieldDescriptorProto_TYPE_FIXED64:  "Uint64",
+	descriptor.FieldDescriptorProto_TYPE_FLOAT:    "Float32",
+	descriptor.FieldDescriptorProto_TYPE_DOUBLE:   "Float64",
+}
+
+// convert returns the statement parsing the string expression src into v
+// and err for a scalar or enum field, or "" for strings, and the
+// expression of the converted value.
+func (g *bindGen) convert(field *descriptor.FieldDescriptorProto, src string) (string, string) {
+	switch field.GetType() {
+	case descriptor.FieldDescriptorProto_TYPE_STRING:
+		return "", src
+	case descriptor.FieldDescriptorProto_TYPE_ENUM:
+		enum := g.imports.goTypeName(g.idx, field.GetTypeName())
+		return fmt.Sprintf("v, err := httpgw.Enum(%s, %s_value)", src, enum), enum + "(v)"
+	}
+	return fmt.Sprintf("v, err := httpgw.%s(%s)", convertFuncs[field.GetType()], src), "v"
+}
+
+// findField returns the field of msg called name, or nil.
+func findField(msg *descriptor.DescriptorProto, name string) *descriptor.FieldDescriptorProto {
+	for _, f := range msg.GetField() {
+		if f.GetName() == name {
+			return f
+		}
+	}
+	return nil
+}
+
+type header struct {
+	Source  string
+	GoPkg   string
+	Imports map[string]string
+}
+
+type binder struct {
+	Name    string
+	Func    string
+	Input   string
+	Methods string
+	Routes  []*route
+	Headers string
+	methods []string
+	// Doc is the comment of the message, see protodoc.Index.Godoc.
+	Doc string
+}
+
+type route struct {
+	HTTPMethod string
+	Template   string
+	Pattern    string
+	Params     string
+	Bind       string
+}
+
+// typeIndex maps fully-qualified proto type names (".pkg.Msg") to their
+// descriptors and declaring files for every file in the request.
+type typeIndex struct {
+	messages map[string]*descriptor.DescriptorProto
+	enums    map[string]*descriptor.EnumDescriptorProto
+	files    map[string]*descriptor.FileDescriptorProto
+}
+
+func newTypeIndex(files []*descriptor.FileDescriptorProto) *typeIndex {
+	idx := &typeIndex{
+		messages: make(map[string]*descriptor.DescriptorProto),
+		enums:    make(map[string]*descriptor.EnumDescriptorProto),
+		files:    make(map[string]*descriptor.FileDescriptorProto),
+	}
+	for _, f := range files {
+		prefix := ""
+		if f.GetPackage() != "" {
+			prefix = "." + f.GetPackage()
+		}
+		idx.addMessages(f, prefix, f.GetMessageType())
+		idx.addEnums(f, prefix, f.GetEnumType())
+	}
+	return idx
+}
+
+func (idx *typeIndex) addMessages(f *descriptor.FileDescriptorProto, prefix string, msgs []*descriptor.DescriptorProto) {
+	for _, m := range msgs {
+		name := prefix + "." + m.GetName()
+		idx.messages[name] = m
+		idx.files[name] = f
+		idx.addMessages(f, name, m.GetNestedType())
+		idx.addEnums(f, name, m.GetEnumType())
+	}
+}
+
+func (idx *typeIndex) addEnums(f *descriptor.FileDescriptorProto, prefix string, enums []*descriptor.EnumDescriptorProto) {
+	for _, e := range enums {
+		name := prefix + "." + e.GetName()
+		idx.enums[name] = e
+		idx.files[name] = f
+	}
+}
+
+// isMap reports whether field is a map field.
+func (idx *typeIndex) isMap(field *descriptor.FieldDescriptorProto) bool {
+	if field.GetType() != descriptor.FieldDescriptorProto_TYPE_MESSAGE {
+		return false
+	}
+	return idx.messages[field.GetTypeName()].GetOptions().GetMapEntry()
+}
+
+// goImportPath returns the import path of the Go package generated from f,
+// or "" if go_package does not name one.
+func goImportPath(f *descriptor.FileDescriptorProto) string {
+	gopkg := f.GetOptions().GetGoPackage()
+	if sc := strings.IndexByte(gopkg, ';'); sc >= 0 {
+		gopkg = gopkg[:sc]
+	}
+	if !strings.Contains(gopkg, "/") {
+		return ""
+	}
+	return gopkg
+}
+
+// importSet records the Go packages referenced by the code generated for
+// one file, keyed by import path.
+type importSet struct {
+	self  string
+	names map[string]string
+}
+
+func newImportSet(f *descriptor.FileDescriptorProto) *importSet {
+	return &importSet{self: goImportPath(f), names: make(map[string]string)}
+}
+
+// goTypeName returns the Go name of the message or enum typeName, qualified
+// with its package if it is declared outside the generated package.
+func (s *importSet) goTypeName(idx *typeIndex, typeName string) string {
+	name := localTypeName(typeName)
+	f := idx.files[typeName]
+	path := goImportPath(f)
+	if f == nil || path == "" || path == s.self {
+		return name
+	}
+	pkg := defaultGoPackageName(f)
+	s.names[path] = pkg
+	return pkg + "." + name
+}
+
+// localTypeName returns the Go name of a type declared in the file being
+// generated, e.g. ".pkg.Outer.Inner" becomes "Outer_Inner".
+func localTypeName(typeName string) string {
+	parts := strings.Split(strings.TrimPrefix(typeName, "."), ".")
+	var names []string
+	for _, p := range parts {
+		if len(p) > 0 && p[0] >= 'A' && p[0] <= 'Z' {
+			names = append(names, p)
+		}
+	}
+	return strings.Join(names, "_")
+}
+
+// sanitizePackageName replaces unallowed character in package name
+// with allowed character.
+func sanitizePackageName(pkgName string) string {
+	pkgName = strings.Replace(pkgName, ".", "_", -1)
+	pkgName = strings.Replace(pkgName, "-", "_", -1)
+	return pkgName
+}
+
+// defaultGoPackageName returns the default go package name to be used for go files generated from "f".
+// You might need to use an unique alias for the package when you import it.  Use ReserveGoPackageAlias to get a unique alias.
+func defaultGoPackageName(f *descriptor.FileDescriptorProto) string {
+	name := packageIdentityName(f)
+	return sanitizePackageName(name)
+}
+
+// packageIdentityName returns the identity of packages.
+// protoc-gen-grpc-gateway rejects CodeGenerationRequests which contains more than one packages
+// as protoc-gen-go does.
+func packageIdentityName(f *descriptor.FileDescriptorProto) string {
+	if f.Options != nil && f.Options.GoPackage != nil {
+		gopkg := f.Options.GetGoPackage()
+		idx := strings.LastIndex(gopkg, "/")
+		if idx < 0 {
+			gopkg = gopkg[idx+1:]
+		}
+
+		gopkg = gopkg[idx+1:]
+		// package name is overrided with the string after the
+		// ';' character
+		sc := strings.IndexByte(gopkg, ';')
+		if sc < 0 {
+			return sanitizePackageName(gopkg)
+
+		}
+		return sanitizePackageName(gopkg[sc+1:])
+	}
+
+	if f.Package == nil {
+		base := filepath.Base(f.GetName())
+		ext := filepath.Ext(base)
+		return strings.TrimSuffix(base, ext)
+	}
+	return f.GetPackage()
+}
+
+func emitFiles(out []*plugin.CodeGeneratorResponse_File) {
+	emitResp(&plugin.CodeGeneratorResponse{File: out})
+}
+
+func emitError(err error) {
+	emitResp(&plugin.CodeGeneratorResponse{Error: proto.String(err.Error())})
+}
+
+func emitResp(resp *plugin.CodeGeneratorResponse) {
+	buf, err := proto.Marshal(resp)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if _, err := os.Stdout.Write(buf); err != nil {
+		log.Fatal(err)
+	}
+}
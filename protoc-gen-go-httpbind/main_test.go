@@ -0,0 +1,42 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/f4tq/protoc-go-plugins/internal/plugintest"
+)
+
+// bindTest runs in the package generated for plugintest.Shelf.
+const bindTest = `package shelfv1
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestBind(t *testing.T) {
+	req, err := BindGetBookRequest(httptest.NewRequest("GET", "/v1/s/ZXRhZw==?version=2&level=3", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.String_ != "s" || !bytes.Equal(req.Etag, []byte("etag")) || req.GetVersion() != 2 || req.GetLevel() != 3 {
+		t.Errorf("BindGetBookRequest = %v", req)
+	}
+	legacy, err := BindLegacy(httptest.NewRequest("GET", "/v1/legacy/old/4?flag=true&data=ZGF0YQ%3D%3D", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if legacy.GetLabel() != "old" || legacy.GetCount() != 4 || !legacy.GetFlag() || !bytes.Equal(legacy.Data, []byte("data")) {
+		t.Errorf("BindLegacy = %v", legacy)
+	}
+}
+`
+
+// TestBindings runs the binders of path variables and query parameters
+// setting fields of every kind.
+func TestBindings(t *testing.T) {
+	pkg := plugintest.Package(t, plugintest.ShelfRequest(t, ""), generate)
+	plugintest.WriteFile(t, pkg, "bind_test.go", bindTest)
+	plugintest.Go(t, "test", pkg)
+}
@@ -0,0 +1,580 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+
+	"github.com/f4tq/protoc-go-plugins/goname"
+	"github.com/f4tq/protoc-go-plugins/httprule"
+	"github.com/f4tq/protoc-go-plugins/protodoc"
+)
+
+var (
+	hdrTmpl = template.Must(template.New("header").Parse(`
+// Code generated by protoc-gen-go-httpgateway. DO NOT EDIT.
+// source: {{.Source}}
+
+package {{.GoPkg}}
+
+import (
+    "context"
+    "net/http"
+
+{{if .NeedsProto}}    "github.com/golang/protobuf/proto"
+{{end}}    "github.com/f4tq/protoc-go-plugins/runtime/httpgw"
+{{- range $path, $name := .Imports}}
+    {{$name}} "{{$path}}"
+{{- end}}
+)
+`))
+
+	serviceTmpl = template.Must(template.New("service").Parse(`
+// {{.Name}}HTTPService is the part of {{.Name}} with google.api.http
+// bindings. The {{.Name}}Server interface generated for gRPC satisfies it.{{.Doc}}
+type {{.Name}}HTTPService interface {
+{{- range .Methods}}
+    {{.Name}}(context.Context, *{{.Input}}) (*{{.Output}}, error)
+{{- end}}
+}
+
+// Register{{.Name}}HTTPHandlers registers the HTTP bindings of {{.Name}}
+// on mux.{{.Doc}}
+func Register{{.Name}}HTTPHandlers(mux *httpgw.ServeMux, srv {{.Name}}HTTPService) {
+{{- range .Routes}}
+    mux.Handle({{printf "%q" .HTTPMethod}}, {{printf "%q" .Template}}, func(w http.ResponseWriter, r *http.Request, params map[string]string) {
+        req := new({{.Input}})
+        if err := {{.BindFunc}}(mux, r, params, req); err != nil {
+            mux.Error(w, r, err)
+            return
+        }
+        resp, err := srv.{{.Method}}(r.Context(), req)
+        if err != nil {
+            mux.Error(w, r, err)
+            return
+        }
+        {{.Respond}}
+    })
+{{- end}}
+}
+
+// New{{.Name}}HTTPHandler returns an http.Handler serving the HTTP bindings
+// of {{.Name}} with srv.{{.Doc}}
+func New{{.Name}}HTTPHandler(srv {{.Name}}HTTPService) http.Handler {
+    mux := httpgw.NewServeMux()
+    Register{{.Name}}HTTPHandlers(mux, srv)
+    return mux
+}
+{{range .Routes}}
+// {{.BindFunc}} binds the path variables, query parameters and body of a
+// {{.HTTPMethod}} {{.Template}} request to req.
+func {{.BindFunc}}(mux *httpgw.ServeMux, r *http.Request, params map[string]string, req *{{.Input}}) error {
+{{- .Bind}}
+    return nil
+}
+{{end}}
+`))
+)
+
+func main() {
+	input, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	req := new(plugin.CodeGeneratorRequest)
+	if err = proto.Unmarshal(input, req); err != nil {
+		log.Fatal(err)
+	}
+
+	out, err := generate(req)
+
+	if err != nil {
+		emitError(err)
+		return
+	}
+
+	emitFiles(out)
+}
+
+func generate(req *plugin.CodeGeneratorRequest) ([]*plugin.CodeGeneratorResponse_File, error) {
+	var files []*plugin.CodeGeneratorResponse_File
+	idx := newTypeIndex(req.GetProtoFile())
+	docs := protodoc.New(req.GetProtoFile())
+	genFileNames := make(map[string]bool)
+	for _, n := range req.FileToGenerate {
+		genFileNames[n] = true
+	}
+	for _, desc := range req.GetProtoFile() {
+		name := desc.GetName()
+		if _, ok := genFileNames[name]; !ok {
+			// Only emit output for files present in req.FileToGenerate.
+			continue
+		}
+		code, err := genCode(desc, idx, docs)
+		if err != nil {
+			return nil, err
+		}
+		if code == "" {
+			continue
+		}
+		formatted, err := format.Source([]byte(code))
+		if err != nil {
+			log.Printf("%v: %s", err, code)
+			return nil, err
+		}
+
+		ext := filepath.Ext(name)
+		base := strings.TrimSuffix(name, ext)
+		output := fmt.Sprintf("%s.pb.httpgateway.go", base)
+		files = append(files, &plugin.CodeGeneratorResponse_File{
+			Name:    proto.String(output),
+			Content: proto.String(string(formatted)),
+		})
+	}
+
+	return files, nil
+}
+
+// genCode returns the HTTP handlers of the services in desc, or "" if no
+// method has google.api.http bindings.
+func genCode(desc *descriptor.FileDescriptorProto, idx *typeIndex, docs *protodoc.Index) (string, error) {
+	w := bytes.NewBuffer(nil)
+	g := &gwGen{idx: idx, imports: newImportSet(desc)}
+	body := bytes.NewBuffer(nil)
+	for _, svc := range desc.GetService() {
+		s, err := g.service(svc)
+		if err != nil {
+			return "", err
+		}
+		if len(s.Routes) == 0 {
+			continue
+		}
+		s.Doc = docs.Godoc(qualify(desc.GetPackage(), svc.GetName()))
+		if err := serviceTmpl.Execute(body, s); err != nil {
+			return "", err
+		}
+	}
+	if body.Len() == 0 {
+		return "", nil
+	}
+
+	hdr := &header{
+		Source:     desc.GetName(),
+		GoPkg:      defaultGoPackageName(desc),
+		Imports:    g.imports.names,
+		NeedsProto: g.needsProto,
+	}
+	if err := hdrTmpl.Execute(w, hdr); err != nil {
+		log.Fatal(err)
+	}
+	body.WriteTo(w)
+
+	return w.String(), nil
+}
+
+type header struct {
+	Source     string
+	GoPkg      string
+	Imports    map[string]string
+	NeedsProto bool
+}
+
+type gwService struct {
+	Name    string
+	Methods []*gwMethod
+	Routes  []*gwRoute
+	// Doc is the comment of the service, see protodoc.Index.Godoc.
+	Doc string
+}
+
+type gwMethod struct {
+	Name   string
+	Input  string
+	Output string
+}
+
+type gwRoute struct {
+	Method     string
+	HTTPMethod string
+	Template   string
+	Input      string
+	BindFunc   string
+	Bind       string
+	Respond    string
+}
+
+type gwGen struct {
+	idx        *typeIndex
+	imports    *importSet
+	needsProto bool
+}
+
+func (g *gwGen) service(svc *descriptor.ServiceDescriptorProto) (*gwService, error) {
+	s := &gwService{Name: svc.GetName()}
+	for _, m := range svc.GetMethod() {
+		bindings, err := httprule.Bindings(m)
+		if err != nil {
+			return nil, err
+		}
+		if len(bindings) == 0 {
+			continue
+		}
+		if m.GetClientStreaming() || m.GetServerStreaming() {
+			log.Printf("%s.%s: streaming methods are not served over HTTP", svc.GetName(), m.GetName())
+			continue
+		}
+		method := &gwMethod{
+			Name:   m.GetName(),
+			Input:  g.imports.goTypeName(g.idx, m.GetInputType()),
+			Output: g.imports.goTypeName(g.idx, m.GetOutputType()),
+		}
+		s.Methods = append(s.Methods, method)
+		for i, b := range bindings {
+			r := &gwRoute{
+				Method:     m.GetName(),
+				HTTPMethod: b.Method,
+				Template:   b.Template,
+				Input:      method.Input,
+				BindFunc:   fmt.Sprintf("bind%s%s%d", svc.GetName(), m.GetName(), i),
+				Respond:    "mux.Respond(w, r, resp)",
+			}
+			bind, err := g.bind(m, b)
+			if err != nil {
+				return nil, fmt.Errorf("%s.%s: %v", svc.GetName(), m.GetName(), err)
+			}
+			r.Bind = bind
+			if b.ResponseBody != "" {
+				out := g.idx.messages[m.GetOutputType()]
+				field := findField(out, b.ResponseBody)
+				if field == nil || field.GetType() != descriptor.FieldDescriptorProto_TYPE_MESSAGE || g.idx.isMap(field) {
+					return nil, fmt.Errorf("%s.%s: response_body %q must name a message field", svc.GetName(), m.GetName(), b.ResponseBody)
+				}
+				get := "resp.Get" + goname.Fields(out)[b.ResponseBody] + "()"
+				if field.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REPEATED {
+					g.needsProto = true
+					r.Respond = fmt.Sprintf("list := make([]proto.Message, len(%[1]s))\nfor i, m := range %[1]s {\nlist[i] = m\n}\nmux.RespondList(w, r, list)", get)
+				} else {
+					r.Respond = fmt.Sprintf("mux.Respond(w, r, %s)", get)
+				}
+			}
+			s.Routes = append(s.Routes, r)
+		}
+	}
+	return s, nil
+}
+
+// bind returns the statements binding an HTTP request to req according to b.
+func (g *gwGen) bind(m *descriptor.MethodDescriptorProto, b *httprule.Binding) (string, error) {
+	in := g.idx.messages[m.GetInputType()]
+	w := bytes.NewBuffer(nil)
+	bound := make(map[string]bool)
+	switch b.Body {
+	case "":
+	case "*":
+		w.WriteString("\nif err := mux.DecodeBody(r, req); err != nil {\nreturn err\n}")
+	default:
+		field := findField(in, b.Body)
+		if field == nil || field.GetType() != descriptor.FieldDescriptorProto_TYPE_MESSAGE ||
+			field.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REPEATED {
+			return "", fmt.Errorf("body %q must name a singular message field", b.Body)
+		}
+		goName := goname.Fields(in)[field.GetName()]
+		fmt.Fprintf(w, "\nreq.%s = new(%s)\nif err := mux.DecodeBody(r, req.%s); err != nil {\nreturn err\n}",
+			goName, g.imports.goTypeName(g.idx, field.GetTypeName()), goName)
+		bound[b.Body] = true
+	}
+
+	for _, v := range b.Pattern.Vars() {
+		stmts, err := g.assign("req", m.GetInputType(), strings.Split(v, "."), fmt.Sprintf("params[%q]", v))
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(w, "\n{\n%s\n}", stmts)
+		bound[strings.Split(v, ".")[0]] = true
+	}
+
+	if b.Body == "*" {
+		return w.String(), nil
+	}
+	var cases []string
+	for _, field := range in.GetField() {
+		if bound[field.GetName()] || field.GetType() == descriptor.FieldDescriptorProto_TYPE_MESSAGE ||
+			field.GetType() == descriptor.FieldDescriptorProto_TYPE_GROUP {
+			continue
+		}
+		names := fmt.Sprintf("%q", field.GetName())
+		if json := field.GetJsonName(); json != "" && json != field.GetName() {
+			names += fmt.Sprintf(", %q", json)
+		}
+		if field.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REPEATED {
+			conv, expr := g.convert(field, "s")
+			goName := goname.Fields(in)[field.GetName()]
+			if conv != "" {
+				conv += "\n"
+			}
+			cases = append(cases, fmt.Sprintf("case %s:\nfor _, s := range values {\n%sreq.%s = append(req.%s, %s)\n}", names, conv, goName, goName, expr))
+			continue
+		}
+		stmts, err := g.assign("req", m.GetInputType(), []string{field.GetName()}, "values[len(values)-1]")
+		if err != nil {
+			return "", err
+		}
+		cases = append(cases, fmt.Sprintf("case %s:\n%s", names, stmts))
+	}
+	if len(cases) > 0 {
+		fmt.Fprintf(w, "\nfor key, values := range r.URL.Query() {\nif len(values) == 0 {\ncontinue\n}\nswitch key {\n%s\n}\n}", strings.Join(cases, "\n"))
+	}
+	return w.String(), nil
+}
+
+// assign returns the statements setting the field at path, relative to the
+// message expression target of type typeName, to the string expression src.
+// Intermediate messages are allocated as needed.
+func (g *gwGen) assign(target, typeName string, path []string, src string) (string, error) {
+	f, err := httprule.ResolveField(g, target, typeName, path)
+	if err != nil {
+		return "", err
+	}
+	w := bytes.NewBufferString(f.Alloc)
+	conv, expr := g.convert(f.Desc, src)
+	if conv != "" {
+		w.WriteString(conv + "\n")
+	}
+	w.WriteString(f.Set(expr))
+	return w.String(), nil
+}
+
+// Message implements httprule.Types.
+func (g *gwGen) Message(typeName string) (*descriptor.DescriptorProto, *descriptor.FileDescriptorProto) {
+	return g.idx.messages[typeName], g.idx.files[typeName]
+}
+
+// GoType implements httprule.Types.
+func (g *gwGen) GoType(typeName string) string {
+	return g.imports.goTypeName(g.idx, typeName)
+}
+
+var convertFuncs = map[descriptor.FieldDescriptorProto_Type]string{
+	descriptor.FieldDescriptorProto_TYPE_BOOL:     "Bool",
+	descriptor.FieldDescriptorProto_TYPE_BYTES:    "Bytes",
+	descriptor.FieldDescriptorProto_TYPE_INT32:    "Int32",
+	descriptor.FieldDescriptorProto_TYPE_SINT32:   "Int32",
+	descriptor.FieldDescriptorProto_TYPE_SFIXED32: "Int32",
+	descriptor.FieldDescriptorProto_TYPE_INT64:    "Int64",
+	descriptor.FieldDescriptorProto_TYPE_SINT64:   "Int64",
+	descriptor.FieldDescriptorProto_TYPE_SFIXED64: "Int64",
+	descriptor.FieldDescriptorProto_TYPE_UINT32:   "Uint32",
+	descriptor.FieldDescriptorProto_TYPE_FIXED32:  "Uint32",
+	descriptor.FieldDescriptorProto_TYPE_UINT64:   "Uint64",
+	descriptor.FieldDescriptorProto_TYPE_FIXED64:  "Uint64",
+	descriptor.FieldDescriptorProto_TYPE_FLOAT:    "Float32",
+	descriptor.FieldDescriptorProto_TYPE_DOUBLE:   "Float64",
+}
+
+// convert returns the statements parsing the string expression src into v
+// for a scalar or enum field, and the expression of the converted value.
+func (g *gwGen) convert(field *descriptor.FieldDescriptorProto, src string) (string, string) {
+	const check = "\nif err != nil {\nreturn err\n}"
+	switch field.GetType() {
+	case descriptor.FieldDescriptorProto_TYPE_STRING:
+		return "", src
+	case descriptor.FieldDescriptorProto_TYPE_ENUM:
+		enum := g.imports.goTypeName(g.idx, field.GetTypeName())
+		return fmt.Sprintf("v, err := httpgw.Enum(%s, %s_value)%s", src, enum, check), enum + "(v)"
+	}
+	return fmt.Sprintf("v, err := httpgw.%s(%s)%s", convertFuncs[field.GetType()], src, check), "v"
+}
+
+// findField returns the field of msg called name, or nil.
+func findField(msg *descriptor.DescriptorProto, name string) *descriptor.FieldDescriptorProto {
+	for _, f := range msg.GetField() {
+		if f.GetName() == name {
+			return f
+		}
+	}
+	return nil
+}
+
+// qualify returns the full proto name of name, declared in scope.
+func qualify(scope, name string) string {
+	if scope == "" {
+		return name
+	}
+	return scope + "." + name
+}
+
+// typeIndex maps fully-qualified proto type names (".pkg.Msg") to their
+// descriptors and declaring files for every file in the request.
+type typeIndex struct {
+	messages map[string]*descriptor.DescriptorProto
+	enums    map[string]*descriptor.EnumDescriptorProto
+	files    map[string]*descriptor.FileDescriptorProto
+}
+
+func newTypeIndex(files []*descriptor.FileDescriptorProto) *typeIndex {
+	idx := &typeIndex{
+		messages: make(map[string]*descriptor.DescriptorProto),
+		enums:    make(map[string]*descriptor.EnumDescriptorProto),
+		files:    make(map[string]*descriptor.FileDescriptorProto),
+	}
+	for _, f := range files {
+		prefix := ""
+		if f.GetPackage() != "" {
+			prefix = "." + f.GetPackage()
+		}
+		idx.addMessages(f, prefix, f.GetMessageType())
+		idx.addEnums(f, prefix, f.GetEnumType())
+	}
+	return idx
+}
+
+func (idx *typeIndex) addMessages(f *descriptor.FileDescriptorProto, prefix string, msgs []*descriptor.DescriptorProto) {
+	for _, m := range msgs {
+		name := prefix + "." + m.GetName()
+		idx.messages[name] = m
+		idx.files[name] = f
+		idx.addMessages(f, name, m.GetNestedType())
+		idx.addEnums(f, name, m.GetEnumType())
+	}
+}
+
+func (idx *typeIndex) addEnums(f *descriptor.FileDescriptorProto, prefix string, enums []*descriptor.EnumDescriptorProto) {
+	for _, e := range enums {
+		name := prefix + "." + e.GetName()
+		idx.enums[name] = e
+		idx.files[name] = f
+	}
+}
+
+// isMap reports whether field is a map field.
+func (idx *typeIndex) isMap(field *descriptor.FieldDescriptorProto) bool {
+	if field.GetType() != descriptor.FieldDescriptorProto_TYPE_MESSAGE {
+		return false
+	}
+	return idx.messages[field.GetTypeName()].GetOptions().GetMapEntry()
+}
+
+// goImportPath returns the import path of the Go package generated from f,
+// or "" if go_package does not name one.
+func goImportPath(f *descriptor.FileDescriptorProto) string {
+	gopkg := f.GetOptions().GetGoPackage()
+	if sc := strings.IndexByte(gopkg, ';'); sc >= 0 {
+		gopkg = gopkg[:sc]
+	}
+	if !strings.Contains(gopkg, "/") {
+		return ""
+	}
+	return gopkg
+}
+
+// importSet records the Go packages referenced by the code generated for
+// one file, keyed by import path.
+type importSet struct {
+	self  string
+	names map[string]string
+}
+
+func newImportSet(f *descriptor.FileDescriptorProto) *importSet {
+	return &importSet{self: goImportPath(f), names: make(map[string]string)}
+}
+
+// goTypeName returns the Go name of the message or enum typeName, qualified
+// with its package if it is declared outside the generated package.
+func (s *importSet) goTypeName(idx *typeIndex, typeName string) string {
+	name := localTypeName(typeName)
+	f := idx.files[typeName]
+	path := goImportPath(f)
+	if f == nil || path == "" || path == s.self {
+		return name
+	}
+	pkg := defaultGoPackageName(f)
+	s.names[path] = pkg
+	return pkg + "." + name
+}
+
+// localTypeName returns the Go name of a type declared in the file being
+// generated, e.g. ".pkg.Outer.Inner" becomes "Outer_Inner".
+func localTypeName(typeName string) string {
+	parts := strings.Split(strings.TrimPrefix(typeName, "."), ".")
+	var names []string
+	for _, p := range parts {
+		if len(p) > 0 && p[0] >= 'A' && p[0] <= 'Z' {
+			names = append(names, p)
+		}
+	}
+	return strings.Join(names, "_")
+}
+
+// sanitizePackageName replaces unallowed character in package name
+// with allowed character.
+func sanitizePackageName(pkgName string) string {
+	pkgName = strings.Replace(pkgName, ".", "_", -1)
+	pkgName = strings.Replace(pkgName, "-", "_", -1)
+	return pkgName
+}
+
+// defaultGoPackageName returns the default go package name to be used for go files generated from "f".
+// You might need to use an unique alias for the package when you import it.  Use ReserveGoPackageAlias to get a unique alias.
+func defaultGoPackageName(f *descriptor.FileDescriptorProto) string {
+	name := packageIdentityName(f)
+	return sanitizePackageName(name)
+}
+
+// packageIdentityName returns the identity of packages.
+// protoc-gen-grpc-gateway rejects CodeGenerationRequests which contains more than one packages
+// as protoc-gen-go does.
+func packageIdentityName(f *descriptor.FileDescriptorProto) string {
+	if f.Options != nil && f.Options.GoPackage != nil {
+		gopkg := f.Options.GetGoPackage()
+		idx := strings.LastIndex(gopkg, "/")
+		if idx < 0 {
+			gopkg = gopkg[idx+1:]
+		}
+
+		gopkg = gopkg[idx+1:]
+		// package name is overrided with the string after the
+		// ';' character
+		sc := strings.IndexByte(gopkg, ';')
+		if sc < 0 {
+			return sanitizePackageName(gopkg)
+
+		}
+		return sanitizePackageName(gopkg[sc+1:])
+	}
+
+	if f.Package == nil {
+		base := filepath.Base(f.GetName())
+		ext := filepath.Ext(base)
+		return strings.TrimSuffix(base, ext)
+	}
+	return f.GetPackage()
+}
+
+func emitFiles(out []*plugin.CodeGeneratorResponse_File) {
+	emitResp(&plugin.CodeGeneratorResponse{File: out})
+}
+
+func emitError(err error) {
+	emitResp(&plugin.CodeGeneratorResponse{Error: proto.String(err.Error())})
+}
+
+func emitResp(resp *plugin.CodeGeneratorResponse) {
+	buf, err := proto.Marshal(resp)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if _, err := os.Stdout.Write(buf); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,539 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+
+	"github.com/f4tq/protoc-go-plugins/goname"
+	"github.com/f4tq/protoc-go-plugins/options"
+	"github.com/f4tq/protoc-go-plugins/protodoc"
+)
+
+var (
+	hdrTmpl = template.Must(template.New("header").Parse(`
+// Code generated by protoc-gen-go-i18n. DO NOT EDIT.
+// source: {{.Source}}
+
+package {{.GoPkg}}
+
+import (
+    "github.com/f4tq/protoc-go-plugins/runtime/localize"
+)
+`))
+
+	textsTmpl = template.Must(template.New("texts").Parse(`
+{{- range .Texts}}
+// {{.GoName}}Text returns the text of {{.FullName}} for a Localizer
+// to translate, with its placeholders filled from m.{{.Doc}}
+func (m *{{$.Name}}) {{.GoName}}Text() *localize.Message {
+    return &localize.Message{
+        ID:       {{printf "%q" .FullName}},
+        Template: {{printf "%q" .Template}},
+        Format:   {{printf "%q" .Format}},
+{{- if .Placeholders}}
+        Names:    []string{ {{- range $i, $p := .Placeholders}}{{if $i}}, {{end}}{{printf "%q" $p.Name}}{{end -}} },
+        Args:     []interface{}{ {{- range $i, $p := .Placeholders}}{{if $i}}, {{end}}m.Get{{$p.Name}}(){{end -}} },
+{{- end}}
+    }
+}
+{{end}}
+// Localize sets the user-facing text fields of m to their translations by
+// loc.
+func (m *{{.Name}}) Localize(loc localize.Localizer) error {
+    if m == nil {
+        return nil
+    }
+    s, err := localize.All(loc{{range .Texts}}, m.{{.GoName}}Text(){{end}})
+    if err != nil {
+        return err
+    }
+{{- range $i, $t := .Texts}}
+    m.{{$t.GoName}} = {{if $t.Ptr}}&{{end}}s[{{$i}}]
+{{- end}}
+    return nil
+}
+`))
+)
+
+// placeholderRE matches the placeholders of source texts.
+var placeholderRE = regexp.MustCompile(`\{\{\s*\.([A-Za-z_][A-Za-z0-9_]*)\s*\}\}`)
+
+func main() {
+	input, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	req := new(plugin.CodeGeneratorRequest)
+	if err = proto.Unmarshal(input, req); err != nil {
+		log.Fatal(err)
+	}
+
+	out, err := generate(req)
+
+	if err != nil {
+		emitError(err)
+		return
+	}
+
+	emitFiles(out)
+}
+
+// generate writes, for each file to generate with fields marked
+// (f4tq.plugins.i18n_text), the Localize methods of their messages to
+// <file>.pb.i18n.go and the catalog of their source texts to a file of the
+// format parameter: "go-i18n", the default, writes the message file
+// <file>.i18n.<lang>.json of github.com/nicksnyder/go-i18n, and "gotext"
+// the <file>.i18n.<lang>.gotext.json of golang.org/x/text/cmd/gotext. lang
+// is the parameter of that name, the language of the source texts, "en" by
+// default.
+func generate(req *plugin.CodeGeneratorRequest) ([]*plugin.CodeGeneratorResponse_File, error) {
+	params := parseParams(req.GetParameter())
+	catalogFormat := params["format"]
+	if catalogFormat == "" {
+		catalogFormat = "go-i18n"
+	}
+	if catalogFormat != "go-i18n" && catalogFormat != "gotext" {
+		return nil, fmt.Errorf("invalid format parameter %q", catalogFormat)
+	}
+	lang := params["lang"]
+	if lang == "" {
+		lang = "en"
+	}
+
+	var files []*plugin.CodeGeneratorResponse_File
+	docs := protodoc.New(req.GetProtoFile())
+	genFileNames := make(map[string]bool)
+	for _, n := range req.FileToGenerate {
+		genFileNames[n] = true
+	}
+	for _, desc := range req.GetProtoFile() {
+		name := desc.GetName()
+		if _, ok := genFileNames[name]; !ok {
+			// Only emit output for files present in req.FileToGenerate.
+			continue
+		}
+		msgs, err := textMessages(desc, docs)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", name, err)
+		}
+		if len(msgs) == 0 {
+			// The file has no user-facing text fields.
+			continue
+		}
+		code, err := genCode(desc, msgs)
+		if err != nil {
+			return nil, err
+		}
+		formatted, err := format.Source([]byte(code))
+		if err != nil {
+			log.Printf("%v: %s", err, code)
+			return nil, err
+		}
+		var catalog []byte
+		var catalogName string
+		ext := filepath.Ext(name)
+		base := strings.TrimSuffix(name, ext)
+		if catalogFormat == "gotext" {
+			catalog, err = gotextCatalog(msgs, lang, docs)
+			catalogName = fmt.Sprintf("%s.i18n.%s.gotext.json", base, lang)
+		} else {
+			catalog, err = goI18nCatalog(msgs, docs)
+			catalogName = fmt.Sprintf("%s.i18n.%s.json", base, lang)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		files = append(files,
+			&plugin.CodeGeneratorResponse_File{
+				Name:    proto.String(fmt.Sprintf("%s.pb.i18n.go", base)),
+				Content: proto.String(string(formatted)),
+			},
+			&plugin.CodeGeneratorResponse_File{
+				Name:    proto.String(catalogName),
+				Content: proto.String(string(catalog)),
+			},
+		)
+	}
+
+	return files, nil
+}
+
+func genCode(desc *descriptor.FileDescriptorProto, msgs []*textMessage) (string, error) {
+	w := bytes.NewBuffer(nil)
+	hdr := &header{
+		Source: desc.GetName(),
+		GoPkg:  defaultGoPackageName(desc),
+	}
+	if err := hdrTmpl.Execute(w, hdr); err != nil {
+		log.Fatal(err)
+	}
+	for _, m := range msgs {
+		if err := textsTmpl.Execute(w, m); err != nil {
+			return "", err
+		}
+	}
+	return w.String(), nil
+}
+
+type header struct {
+	Source string
+	GoPkg  string
+}
+
+// textMessage is a message with user-facing text fields.
+type textMessage struct {
+	// Name is the Go name of the message.
+	Name  string
+	Texts []*text
+}
+
+// text is a user-facing text field.
+type text struct {
+	GoName   string
+	FullName string
+	// Ptr reports whether the Go field is a *string.
+	Ptr bool
+	// Template and Format are the (f4tq.plugins.i18n_text) of the field in
+	// the syntax of go-i18n and fmt, and Message in that of gotext.
+	Template, Format, Message string
+	Placeholders              []*placeholder
+	// Doc is the comment of the field, see protodoc.Index.Godoc.
+	Doc string
+}
+
+// placeholder is a field of the message a text refers to.
+type placeholder struct {
+	// Name is the Go name of the field.
+	Name string
+	// Type is the Go type of the field, and Underlying that of its values.
+	Type, Underlying string
+}
+
+// textMessages returns the messages of desc, nested ones included, that
+// have user-facing text fields.
+func textMessages(desc *descriptor.FileDescriptorProto, docs *protodoc.Index) ([]*textMessage, error) {
+	var out []*textMessage
+	var walk func(scope string, msgs []*descriptor.DescriptorProto) error
+	walk = func(scope string, msgs []*descriptor.DescriptorProto) error {
+		for _, msg := range msgs {
+			if msg.GetOptions().GetMapEntry() {
+				continue
+			}
+			fullName := qualify(scope, msg.GetName())
+			m := &textMessage{Name: localTypeName(fullName)}
+			for _, field := range msg.GetField() {
+				source := options.I18nText(field)
+				if source == "" {
+					continue
+				}
+				t, err := newText(desc, msg, field, source)
+				if err != nil {
+					return fmt.Errorf("%s.%s: %v", fullName, field.GetName(), err)
+				}
+				t.FullName = fullName + "." + field.GetName()
+				t.Doc = docs.Godoc(t.FullName)
+				m.Texts = append(m.Texts, t)
+			}
+			if len(m.Texts) > 0 {
+				out = append(out, m)
+			}
+			if err := walk(fullName, msg.GetNestedType()); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := walk(desc.GetPackage(), desc.GetMessageType()); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// newText returns the text of field of msg, whose source text is source.
+func newText(desc *descriptor.FileDescriptorProto, msg *descriptor.DescriptorProto, field *descriptor.FieldDescriptorProto, source string) (*text, error) {
+	if field.GetType() != descriptor.FieldDescriptorProto_TYPE_STRING || field.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REPEATED {
+		return nil, fmt.Errorf("(f4tq.plugins.i18n_text) applies to singular string fields only")
+	}
+	if field.OneofIndex != nil && !field.GetProto3Optional() {
+		return nil, fmt.Errorf("(f4tq.plugins.i18n_text) does not apply to oneof fields")
+	}
+	goNames := goname.Fields(msg)
+	t := &text{
+		GoName: goNames[field.GetName()],
+		Ptr:    desc.GetSyntax() != "proto3" || field.GetProto3Optional(),
+	}
+
+	fields := make(map[string]*descriptor.FieldDescriptorProto)
+	for _, f := range msg.GetField() {
+		fields[goNames[f.GetName()]] = f
+	}
+	argNums := make(map[string]int)
+	var tmpl, fmtText, message strings.Builder
+	last := 0
+	for _, loc := range placeholderRE.FindAllStringSubmatchIndex(source, -1) {
+		lit := source[last:loc[0]]
+		if strings.Contains(lit, "{{") {
+			return nil, fmt.Errorf("%q: only {{.Field}} placeholders are supported", source)
+		}
+		tmpl.WriteString(lit)
+		fmtText.WriteString(strings.Replace(lit, "%", "%%", -1))
+		message.WriteString(lit)
+		last = loc[1]
+
+		name := source[loc[2]:loc[3]]
+		n, ok := argNums[name]
+		if !ok {
+			p, err := newPlaceholder(fields[name])
+			if err != nil {
+				return nil, fmt.Errorf("%q: {{.%s}}: %v", source, name, err)
+			}
+			p.Name = name
+			t.Placeholders = append(t.Placeholders, p)
+			n = len(t.Placeholders)
+			argNums[name] = n
+		}
+		fmt.Fprintf(&tmpl, "{{.%s}}", name)
+		fmt.Fprintf(&fmtText, "%%[%d]v", n)
+		fmt.Fprintf(&message, "{%s}", name)
+	}
+	lit := source[last:]
+	if strings.Contains(lit, "{{") {
+		return nil, fmt.Errorf("%q: only {{.Field}} placeholders are supported", source)
+	}
+	tmpl.WriteString(lit)
+	fmtText.WriteString(strings.Replace(lit, "%", "%%", -1))
+	message.WriteString(lit)
+	t.Template, t.Format, t.Message = tmpl.String(), fmtText.String(), message.String()
+	return t, nil
+}
+
+// goTypes are the Go types of the scalar fields placeholders may refer to.
+var goTypes = map[descriptor.FieldDescriptorProto_Type]string{
+	descriptor.FieldDescriptorProto_TYPE_DOUBLE:   "float64",
+	descriptor.FieldDescriptorProto_TYPE_FLOAT:    "float32",
+	descriptor.FieldDescriptorProto_TYPE_INT64:    "int64",
+	descriptor.FieldDescriptorProto_TYPE_SINT64:   "int64",
+	descriptor.FieldDescriptorProto_TYPE_SFIXED64: "int64",
+	descriptor.FieldDescriptorProto_TYPE_UINT64:   "uint64",
+	descriptor.FieldDescriptorProto_TYPE_FIXED64:  "uint64",
+	descriptor.FieldDescriptorProto_TYPE_INT32:    "int32",
+	descriptor.FieldDescriptorProto_TYPE_SINT32:   "int32",
+	descriptor.FieldDescriptorProto_TYPE_SFIXED32: "int32",
+	descriptor.FieldDescriptorProto_TYPE_UINT32:   "uint32",
+	descriptor.FieldDescriptorProto_TYPE_FIXED32:  "uint32",
+	descriptor.FieldDescriptorProto_TYPE_BOOL:     "bool",
+	descriptor.FieldDescriptorProto_TYPE_STRING:   "string",
+}
+
+// newPlaceholder returns the placeholder of field, which must be a
+// singular scalar or enum field.
+func newPlaceholder(field *descriptor.FieldDescriptorProto) (*placeholder, error) {
+	if field == nil {
+		return nil, fmt.Errorf("no such field")
+	}
+	if field.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REPEATED {
+		return nil, fmt.Errorf("repeated fields cannot be placeholders")
+	}
+	if field.GetType() == descriptor.FieldDescriptorProto_TYPE_ENUM {
+		return &placeholder{Type: localTypeName(field.GetTypeName()), Underlying: "int32"}, nil
+	}
+	typ, ok := goTypes[field.GetType()]
+	if !ok {
+		return nil, fmt.Errorf("%s fields cannot be placeholders", strings.ToLower(strings.TrimPrefix(field.GetType().String(), "TYPE_")))
+	}
+	return &placeholder{Type: typ, Underlying: typ}, nil
+}
+
+// goI18nMessage is a message of a go-i18n message file.
+type goI18nMessage struct {
+	Description string `json:"description,omitempty"`
+	Other       string `json:"other"`
+}
+
+// goI18nCatalog returns the go-i18n message file of the texts of msgs.
+func goI18nCatalog(msgs []*textMessage, docs *protodoc.Index) ([]byte, error) {
+	catalog := make(map[string]*goI18nMessage)
+	for _, m := range msgs {
+		for _, t := range m.Texts {
+			catalog[t.FullName] = &goI18nMessage{
+				Description: docs.Comment(t.FullName),
+				Other:       t.Template,
+			}
+		}
+	}
+	b, err := json.MarshalIndent(catalog, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(b, '\n'), nil
+}
+
+// gotextMessages is a gotext message file, as read and written by
+// golang.org/x/text/message/pipeline.
+type gotextMessages struct {
+	Language string           `json:"language"`
+	Messages []*gotextMessage `json:"messages"`
+}
+
+type gotextMessage struct {
+	ID                string               `json:"id"`
+	Message           string               `json:"message"`
+	Translation       string               `json:"translation"`
+	TranslatorComment string               `json:"translatorComment,omitempty"`
+	Placeholders      []*gotextPlaceholder `json:"placeholders,omitempty"`
+}
+
+type gotextPlaceholder struct {
+	ID             string `json:"id"`
+	String         string `json:"string"`
+	Type           string `json:"type"`
+	UnderlyingType string `json:"underlyingType"`
+	ArgNum         int    `json:"argNum"`
+	Expr           string `json:"expr"`
+}
+
+// gotextCatalog returns the gotext message file of the texts of msgs, in
+// the source language lang.
+func gotextCatalog(msgs []*textMessage, lang string, docs *protodoc.Index) ([]byte, error) {
+	catalog := &gotextMessages{Language: lang, Messages: []*gotextMessage{}}
+	for _, m := range msgs {
+		for _, t := range m.Texts {
+			msg := &gotextMessage{
+				ID:                t.FullName,
+				Message:           t.Message,
+				Translation:       t.Message,
+				TranslatorComment: docs.Comment(t.FullName),
+			}
+			for i, p := range t.Placeholders {
+				msg.Placeholders = append(msg.Placeholders, &gotextPlaceholder{
+					ID:             p.Name,
+					String:         fmt.Sprintf("%%[%d]v", i+1),
+					Type:           p.Type,
+					UnderlyingType: p.Underlying,
+					ArgNum:         i + 1,
+					Expr:           "m.Get" + p.Name + "()",
+				})
+			}
+			catalog.Messages = append(catalog.Messages, msg)
+		}
+	}
+	b, err := json.MarshalIndent(catalog, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(b, '\n'), nil
+}
+
+// qualify returns the full proto name of name, declared in scope.
+func qualify(scope, name string) string {
+	if scope == "" {
+		return name
+	}
+	return scope + "." + name
+}
+
+// localTypeName returns the Go name of a type declared in the file being
+// generated, e.g. ".pkg.Outer.Inner" becomes "Outer_Inner".
+func localTypeName(typeName string) string {
+	parts := strings.Split(strings.TrimPrefix(typeName, "."), ".")
+	var names []string
+	for _, p := range parts {
+		if len(p) > 0 && p[0] >= 'A' && p[0] <= 'Z' {
+			names = append(names, p)
+		}
+	}
+	return strings.Join(names, "_")
+}
+
+// sanitizePackageName replaces unallowed character in package name
+// with allowed character.
+func sanitizePackageName(pkgName string) string {
+	pkgName = strings.Replace(pkgName, ".", "_", -1)
+	pkgName = strings.Replace(pkgName, "-", "_", -1)
+	return pkgName
+}
+
+// defaultGoPackageName returns the default go package name to be used for go files generated from "f".
+// You might need to use an unique alias for the package when you import it.  Use ReserveGoPackageAlias to get a unique alias.
+func defaultGoPackageName(f *descriptor.FileDescriptorProto) string {
+	name := packageIdentityName(f)
+	return sanitizePackageName(name)
+}
+
+// packageIdentityName returns the identity of packages.
+// protoc-gen-grpc-gateway rejects CodeGenerationRequests which contains more than one packages
+// as protoc-gen-go does.
+func packageIdentityName(f *descriptor.FileDescriptorProto) string {
+	if f.Options != nil && f.Options.GoPackage != nil {
+		gopkg := f.Options.GetGoPackage()
+		idx := strings.LastIndex(gopkg, "/")
+		if idx < 0 {
+			gopkg = gopkg[idx+1:]
+		}
+
+		gopkg = gopkg[idx+1:]
+		// package name is overrided with the string after the
+		// ';' character
+		sc := strings.IndexByte(gopkg, ';')
+		if sc < 0 {
+			return sanitizePackageName(gopkg)
+
+		}
+		return sanitizePackageName(gopkg[sc+1:])
+	}
+
+	if f.Package == nil {
+		base := filepath.Base(f.GetName())
+		ext := filepath.Ext(base)
+		return strings.TrimSuffix(base, ext)
+	}
+	return f.GetPackage()
+}
+
+func parseParams(param string) map[string]string {
+	params := make(map[string]string)
+	for _, p := range strings.Split(param, ",") {
+		if p == "" {
+			continue
+		}
+		if i := strings.IndexByte(p, '='); i >= 0 {
+			params[p[:i]] = p[i+1:]
+		} else {
+			params[p] = ""
+		}
+	}
+	return params
+}
+
+func emitFiles(out []*plugin.CodeGeneratorResponse_File) {
+	emitResp(&plugin.CodeGeneratorResponse{File: out})
+}
+
+func emitError(err error) {
+	emitResp(&plugin.CodeGeneratorResponse{Error: proto.String(err.Error())})
+}
+
+func emitResp(resp *plugin.CodeGeneratorResponse) {
+	buf, err := proto.Marshal(resp)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if _, err := os.Stdout.Write(buf); err != nil {
+		log.Fatal(err)
+	}
+}
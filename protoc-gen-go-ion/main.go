@@ -0,0 +1,604 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+
+	"github.com/f4tq/protoc-go-plugins/goname"
+	"github.com/f4tq/protoc-go-plugins/options"
+)
+
+var (
+	hdrTmpl = template.Must(template.New("header").Parse(`
+// Code generated by protoc-gen-go-ion. DO NOT EDIT.
+// source: {{.Source}}
+
+package {{.GoPkg}}
+
+import (
+{{- if .NeedsStrconv}}
+    "strconv"
+
+{{- end}}
+    "github.com/amzn/ion-go/ion"
+    "github.com/f4tq/protoc-go-plugins/runtime/ionpb"
+{{- range $path, $name := .Imports}}
+    {{$name}} "{{$path}}"
+{{- end}}
+)
+`))
+
+	ionTmpl = template.Must(template.New("ion").Parse(`
+// MarshalIon writes msg to w as an Ion struct.
+func (msg *{{.Name}}) MarshalIon(w ion.Writer) error {
+    if msg == nil {
+        return w.WriteNull()
+    }
+    iw := ionpb.NewWriter(w)
+    iw.BeginStruct()
+{{- range .Fields}}
+    {{.Write}}
+{{- end}}
+    iw.EndStruct()
+    return iw.Err()
+}
+
+// UnmarshalIon reads the Ion struct r is positioned on into msg. Unknown
+// fields are ignored.
+func (msg *{{.Name}}) UnmarshalIon(r ion.Reader) error {
+    return ionpb.ReadStruct(r, func(name string) error {
+        switch name {
+{{- range .Fields}}
+        case {{printf "%q" .Name}}:
+            {{.Read}}
+{{- end}}
+        }
+        return nil
+    })
+}
+`))
+)
+
+func main() {
+	input, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	req := new(plugin.CodeGeneratorRequest)
+	if err = proto.Unmarshal(input, req); err != nil {
+		log.Fatal(err)
+	}
+
+	out, err := generate(req)
+
+	if err != nil {
+		emitError(err)
+		return
+	}
+
+	emitFiles(out)
+}
+
+func generate(req *plugin.CodeGeneratorRequest) ([]*plugin.CodeGeneratorResponse_File, error) {
+	var files []*plugin.CodeGeneratorResponse_File
+	idx := newTypeIndex(req.GetProtoFile())
+	genFileNames := make(map[string]bool)
+	for _, n := range req.FileToGenerate {
+		genFileNames[n] = true
+	}
+	for _, desc := range req.GetProtoFile() {
+		name := desc.GetName()
+		if _, ok := genFileNames[name]; !ok {
+			// Only emit output for files present in req.FileToGenerate.
+			continue
+		}
+		code, err := genCode(desc, idx)
+		if err != nil {
+			return nil, err
+		}
+		formatted, err := format.Source([]byte(code))
+		if err != nil {
+			log.Printf("%v: %s", err, code)
+			return nil, err
+		}
+
+		ext := filepath.Ext(name)
+		base := strings.TrimSuffix(name, ext)
+		output := fmt.Sprintf("%s.pb.ion.go", base)
+		files = append(files, &plugin.CodeGeneratorResponse_File{
+			Name:    proto.String(output),
+			Content: proto.String(string(formatted)),
+		})
+	}
+
+	return files, nil
+}
+
+func genCode(desc *descriptor.FileDescriptorProto, idx *typeIndex) (string, error) {
+	w := bytes.NewBuffer(nil)
+	g := &ionGen{idx: idx, imports: newImportSet(desc)}
+	body := bytes.NewBuffer(nil)
+	for _, msg := range desc.GetMessageType() {
+		m := &ionMsg{Name: msg.GetName()}
+		for _, field := range msg.GetField() {
+			f := g.field(msg, field)
+			if f == nil {
+				log.Printf("%s.%s: unsupported type %s, field skipped", msg.GetName(), field.GetName(), field.GetTypeName())
+				continue
+			}
+			m.Fields = append(m.Fields, f)
+		}
+		if err := ionTmpl.Execute(body, m); err != nil {
+			return "", err
+		}
+	}
+
+	hdr := &header{
+		Source:       desc.GetName(),
+		GoPkg:        defaultGoPackageName(desc),
+		Imports:      g.imports.names,
+		NeedsStrconv: g.needsStrconv,
+	}
+	if err := hdrTmpl.Execute(w, hdr); err != nil {
+		log.Fatal(err)
+	}
+	body.WriteTo(w)
+
+	return w.String(), nil
+}
+
+type header struct {
+	Source       string
+	GoPkg        string
+	Imports      map[string]string
+	NeedsStrconv bool
+}
+
+type ionMsg struct {
+	Name   string
+	Fields []*ionField
+}
+
+type ionField struct {
+	Name  string
+	Write string
+	Read  string
+}
+
+type ionGen struct {
+	idx          *typeIndex
+	imports      *importSet
+	needsStrconv bool
+}
+
+// field returns the encoder and decoder statements of field, or nil if its
+// type cannot be represented.
+func (g *ionGen) field(msg *descriptor.DescriptorProto, field *descriptor.FieldDescriptorProto) *ionField {
+	name := field.GetName()
+	goNames := goname.Fields(msg)
+	goName := goNames[name]
+	f := &ionField{Name: name}
+	switch {
+	case g.idx.isMap(field):
+		entry := g.idx.messages[field.GetTypeName()]
+		key, val := entry.GetField()[0], entry.GetField()[1]
+		write, ok := g.writeValue(val, "v")
+		if !ok {
+			return nil
+		}
+		read, expr, ok := g.readValue(val)
+		if !ok {
+			return nil
+		}
+		keyStr, keyParse := g.mapKey(key)
+		f.Write = fmt.Sprintf(`if len(msg.%[1]s) > 0 {
+    iw.Field(%[2]q)
+    iw.BeginStruct()
+    for k, v := range msg.%[1]s {
+        iw.Field(%[3]s)
+        %[4]s
+    }
+    iw.EndStruct()
+}`, goName, name, keyStr, write)
+		f.Read = fmt.Sprintf(`if msg.%[1]s == nil {
+    msg.%[1]s = make(map[%[2]s]%[3]s)
+}
+return ionpb.ReadStruct(r, func(key string) error {
+    %[4]s
+    %[5]s
+    msg.%[1]s[k] = %[6]s
+    return err
+})`, goName, g.goType(key), g.goType(val), keyParse, read, expr)
+	case field.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REPEATED:
+		write, ok := g.writeValue(field, "v")
+		if !ok {
+			return nil
+		}
+		read, expr, ok := g.readValue(field)
+		if !ok {
+			return nil
+		}
+		f.Write = fmt.Sprintf(`if len(msg.%[1]s) > 0 {
+    iw.Field(%[2]q)
+    iw.BeginList()
+    for _, v := range msg.%[1]s {
+        %[3]s
+    }
+    iw.EndList()
+}`, goName, name, write)
+		f.Read = fmt.Sprintf(`return ionpb.ReadList(r, func() error {
+    %s
+    msg.%s = append(msg.%s, %s)
+    return err
+})`, read, goName, goName, expr)
+	case field.OneofIndex != nil && !field.GetProto3Optional():
+		oneof := goNames[msg.GetOneofDecl()[field.GetOneofIndex()].GetName()]
+		wrapper := msg.GetName() + "_" + goName
+		write, ok := g.writeValue(field, "x."+goName)
+		if !ok {
+			return nil
+		}
+		read, expr, ok := g.readValue(field)
+		if !ok {
+			return nil
+		}
+		f.Write = fmt.Sprintf(`if x, ok := msg.%s.(*%s); ok {
+    iw.Field(%q)
+    %s
+}`, oneof, wrapper, name, write)
+		f.Read = fmt.Sprintf(`%s
+msg.%s = &%s{%s: %s}
+return err`, read, oneof, wrapper, goName, expr)
+	case field.GetProto3Optional():
+		write, ok := g.writeValue(field, "*msg."+goName)
+		if !ok {
+			return nil
+		}
+		read, expr, ok := g.readValue(field)
+		if !ok {
+			return nil
+		}
+		f.Write = fmt.Sprintf(`if msg.%s != nil {
+    iw.Field(%q)
+    %s
+}`, goName, name, write)
+		f.Read = fmt.Sprintf(`%s
+x := %s
+msg.%s = &x
+return err`, read, expr, goName)
+	default:
+		write, ok := g.writeValue(field, "msg."+goName)
+		if !ok {
+			return nil
+		}
+		read, expr, ok := g.readValue(field)
+		if !ok {
+			return nil
+		}
+		if field.GetType() == descriptor.FieldDescriptorProto_TYPE_MESSAGE {
+			f.Write = fmt.Sprintf(`if msg.%s != nil {
+    iw.Field(%q)
+    %s
+}`, goName, name, write)
+		} else {
+			f.Write = fmt.Sprintf("iw.Field(%q)\n%s", name, write)
+		}
+		f.Read = fmt.Sprintf("%s\nmsg.%s = %s\nreturn err", read, goName, expr)
+	}
+	return f
+}
+
+// writeValue returns the statement writing the single value v of field.
+func (g *ionGen) writeValue(field *descriptor.FieldDescriptorProto, v string) (string, bool) {
+	switch field.GetType() {
+	case descriptor.FieldDescriptorProto_TYPE_STRING:
+		if options.Decimal(field) {
+			return fmt.Sprintf("iw.Decimal(%s)", v), true
+		}
+		return fmt.Sprintf("iw.String(%s)", v), true
+	case descriptor.FieldDescriptorProto_TYPE_BOOL:
+		return fmt.Sprintf("iw.Bool(%s)", v), true
+	case descriptor.FieldDescriptorProto_TYPE_BYTES:
+		return fmt.Sprintf("iw.Blob(%s)", v), true
+	case descriptor.FieldDescriptorProto_TYPE_UINT64, descriptor.FieldDescriptorProto_TYPE_FIXED64:
+		return fmt.Sprintf("iw.Uint(%s)", v), true
+	case descriptor.FieldDescriptorProto_TYPE_FLOAT, descriptor.FieldDescriptorProto_TYPE_DOUBLE:
+		return fmt.Sprintf("iw.Float(float64(%s))", v), true
+	case descriptor.FieldDescriptorProto_TYPE_ENUM:
+		return fmt.Sprintf("iw.Symbol(%s.String())", v), true
+	case descriptor.FieldDescriptorProto_TYPE_MESSAGE:
+		switch field.GetTypeName() {
+		case ".google.protobuf.Timestamp":
+			return fmt.Sprintf("iw.Timestamp(%s)", v), true
+		case ".google.protobuf.Duration":
+			return fmt.Sprintf("iw.Duration(%s)", v), true
+		}
+		if strings.HasPrefix(field.GetTypeName(), ".google.protobuf.") {
+			return "", false
+		}
+		return fmt.Sprintf("iw.Message(%s)", v), true
+	case descriptor.FieldDescriptorProto_TYPE_GROUP:
+		return "", false
+	}
+	// Remaining integer types.
+	return fmt.Sprintf("iw.Int(int64(%s))", v), true
+}
+
+// readValue returns the statements declaring v and err from the value r is
+// positioned on, and the expression converting v to the Go type of field.
+func (g *ionGen) readValue(field *descriptor.FieldDescriptorProto) (string, string, bool) {
+	switch field.GetType() {
+	case descriptor.FieldDescriptorProto_TYPE_STRING:
+		if options.Decimal(field) {
+			return "v, err := ionpb.Decimal(r)", "v", true
+		}
+		return "v, err := ionpb.String(r)", "v", true
+	case descriptor.FieldDescriptorProto_TYPE_BOOL:
+		return "v, err := ionpb.Bool(r)", "v", true
+	case descriptor.FieldDescriptorProto_TYPE_BYTES:
+		return "v, err := ionpb.Bytes(r)", "v", true
+	case descriptor.FieldDescriptorProto_TYPE_UINT64, descriptor.FieldDescriptorProto_TYPE_FIXED64:
+		return "v, err := ionpb.Uint64(r)", "v", true
+	case descriptor.FieldDescriptorProto_TYPE_UINT32, descriptor.FieldDescriptorProto_TYPE_FIXED32:
+		return "v, err := ionpb.Uint64(r)", "uint32(v)", true
+	case descriptor.FieldDescriptorProto_TYPE_INT64, descriptor.FieldDescriptorProto_TYPE_SINT64, descriptor.FieldDescriptorProto_TYPE_SFIXED64:
+		return "v, err := ionpb.Int64(r)", "v", true
+	case descriptor.FieldDescriptorProto_TYPE_INT32, descriptor.FieldDescriptorProto_TYPE_SINT32, descriptor.FieldDescriptorProto_TYPE_SFIXED32:
+		return "v, err := ionpb.Int64(r)", "int32(v)", true
+	case descriptor.FieldDescriptorProto_TYPE_DOUBLE:
+		return "v, err := ionpb.Float64(r)", "v", true
+	case descriptor.FieldDescriptorProto_TYPE_FLOAT:
+		return "v, err := ionpb.Float64(r)", "float32(v)", true
+	case descriptor.FieldDescriptorProto_TYPE_ENUM:
+		enum := g.imports.goTypeName(g.idx, field.GetTypeName())
+		return fmt.Sprintf("v, err := ionpb.Enum(r, %s_value)", enum), fmt.Sprintf("%s(v)", enum), true
+	case descriptor.FieldDescriptorProto_TYPE_MESSAGE:
+		switch field.GetTypeName() {
+		case ".google.protobuf.Timestamp":
+			return "v, err := ionpb.Timestamp(r)", "v", true
+		case ".google.protobuf.Duration":
+			return "v, err := ionpb.Duration(r)", "v", true
+		}
+		if strings.HasPrefix(field.GetTypeName(), ".google.protobuf.") {
+			return "", "", false
+		}
+		typ := g.imports.goTypeName(g.idx, field.GetTypeName())
+		return fmt.Sprintf("v := new(%s)\nerr := v.UnmarshalIon(r)", typ), "v", true
+	}
+	return "", "", false
+}
+
+// mapKey returns the expression formatting map key k as a struct field
+// name, and the statements parsing key back into k.
+func (g *ionGen) mapKey(key *descriptor.FieldDescriptorProto) (string, string) {
+	switch key.GetType() {
+	case descriptor.FieldDescriptorProto_TYPE_STRING:
+		return "k", "k := key"
+	case descriptor.FieldDescriptorProto_TYPE_BOOL:
+		g.needsStrconv = true
+		return "strconv.FormatBool(k)", `k, kerr := strconv.ParseBool(key)
+if kerr != nil {
+    return kerr
+}`
+	case descriptor.FieldDescriptorProto_TYPE_UINT32, descriptor.FieldDescriptorProto_TYPE_FIXED32,
+		descriptor.FieldDescriptorProto_TYPE_UINT64, descriptor.FieldDescriptorProto_TYPE_FIXED64:
+		g.needsStrconv = true
+		return "strconv.FormatUint(uint64(k), 10)", fmt.Sprintf(`u, kerr := strconv.ParseUint(key, 10, 64)
+if kerr != nil {
+    return kerr
+}
+k := %s(u)`, g.goType(key))
+	}
+	g.needsStrconv = true
+	return "strconv.FormatInt(int64(k), 10)", fmt.Sprintf(`i, kerr := strconv.ParseInt(key, 10, 64)
+if kerr != nil {
+    return kerr
+}
+k := %s(i)`, g.goType(key))
+}
+
+// goType returns the Go type of a map key or value.
+func (g *ionGen) goType(field *descriptor.FieldDescriptorProto) string {
+	switch field.GetType() {
+	case descriptor.FieldDescriptorProto_TYPE_ENUM:
+		return g.imports.goTypeName(g.idx, field.GetTypeName())
+	case descriptor.FieldDescriptorProto_TYPE_MESSAGE:
+		return "*" + g.imports.goTypeName(g.idx, field.GetTypeName())
+	}
+	return goScalarTypes[field.GetType()]
+}
+
+var goScalarTypes = map[descriptor.FieldDescriptorProto_Type]string{
+	descriptor.FieldDescriptorProto_TYPE_STRING:   "string",
+	descriptor.FieldDescriptorProto_TYPE_BOOL:     "bool",
+	descriptor.FieldDescriptorProto_TYPE_BYTES:    "[]byte",
+	descriptor.FieldDescriptorProto_TYPE_INT32:    "int32",
+	descriptor.FieldDescriptorProto_TYPE_SINT32:   "int32",
+	descriptor.FieldDescriptorProto_TYPE_SFIXED32: "int32",
+	descriptor.FieldDescriptorProto_TYPE_INT64:    "int64",
+	descriptor.FieldDescriptorProto_TYPE_SINT64:   "int64",
+	descriptor.FieldDescriptorProto_TYPE_SFIXED64: "int64",
+	descriptor.FieldDescriptorProto_TYPE_UINT32:   "uint32",
+	descriptor.FieldDescriptorProto_TYPE_FIXED32:  "uint32",
+	descriptor.FieldDescriptorProto_TYPE_UINT64:   "uint64",
+	descriptor.FieldDescriptorProto_TYPE_FIXED64:  "uint64",
+	descriptor.FieldDescriptorProto_TYPE_FLOAT:    "float32",
+	descriptor.FieldDescriptorProto_TYPE_DOUBLE:   "float64",
+}
+
+// typeIndex maps fully-qualified proto type names (".pkg.Msg") to their
+// descriptors and declaring files for every file in the request.
+type typeIndex struct {
+	messages map[string]*descriptor.DescriptorProto
+	enums    map[string]*descriptor.EnumDescriptorProto
+	files    map[string]*descriptor.FileDescriptorProto
+}
+
+func newTypeIndex(files []*descriptor.FileDescriptorProto) *typeIndex {
+	idx := &typeIndex{
+		messages: make(map[string]*descriptor.DescriptorProto),
+		enums:    make(map[string]*descriptor.EnumDescriptorProto),
+		files:    make(map[string]*descriptor.FileDescriptorProto),
+	}
+	for _, f := range files {
+		prefix := ""
+		if f.GetPackage() != "" {
+			prefix = "." + f.GetPackage()
+		}
+		idx.addMessages(f, prefix, f.GetMessageType())
+		idx.addEnums(f, prefix, f.GetEnumType())
+	}
+	return idx
+}
+
+func (idx *typeIndex) addMessages(f *descriptor.FileDescriptorProto, prefix string, msgs []*descriptor.DescriptorProto) {
+	for _, m := range msgs {
+		name := prefix + "." + m.GetName()
+		idx.messages[name] = m
+		idx.files[name] = f
+		idx.addMessages(f, name, m.GetNestedType())
+		idx.addEnums(f, name, m.GetEnumType())
+	}
+}
+
+func (idx *typeIndex) addEnums(f *descriptor.FileDescriptorProto, prefix string, enums []*descriptor.EnumDescriptorProto) {
+	for _, e := range enums {
+		name := prefix + "." + e.GetName()
+		idx.enums[name] = e
+		idx.files[name] = f
+	}
+}
+
+// isMap reports whether field is a map field.
+func (idx *typeIndex) isMap(field *descriptor.FieldDescriptorProto) bool {
+	if field.GetType() != descriptor.FieldDescriptorProto_TYPE_MESSAGE {
+		return false
+	}
+	return idx.messages[field.GetTypeName()].GetOptions().GetMapEntry()
+}
+
+// goImportPath returns the import path of the Go package generated from f,
+// or "" if go_package does not name one.
+func goImportPath(f *descriptor.FileDescriptorProto) string {
+	gopkg := f.GetOptions().GetGoPackage()
+	if sc := strings.IndexByte(gopkg, ';'); sc >= 0 {
+		gopkg = gopkg[:sc]
+	}
+	if !strings.Contains(gopkg, "/") {
+		return ""
+	}
+	return gopkg
+}
+
+// importSet records the Go packages referenced by the code generated for
+// one file, keyed by import path.
+type importSet struct {
+	self  string
+	names map[string]string
+}
+
+func newImportSet(f *descriptor.FileDescriptorProto) *importSet {
+	return &importSet{self: goImportPath(f), names: make(map[string]string)}
+}
+
+// goTypeName returns the Go name of the message or enum typeName, qualified
+// with its package if it is declared outside the generated package.
+func (s *importSet) goTypeName(idx *typeIndex, typeName string) string {
+	name := localTypeName(typeName)
+	f := idx.files[typeName]
+	path := goImportPath(f)
+	if f == nil || path == "" || path == s.self {
+		return name
+	}
+	pkg := defaultGoPackageName(f)
+	s.names[path] = pkg
+	return pkg + "." + name
+}
+
+// localTypeName returns the Go name of a type declared in the file being
+// generated, e.g. ".pkg.Outer.Inner" becomes "Outer_Inner".
+func localTypeName(typeName string) string {
+	parts := strings.Split(strings.TrimPrefix(typeName, "."), ".")
+	var names []string
+	for _, p := range parts {
+		if len(p) > 0 && p[0] >= 'A' && p[0] <= 'Z' {
+			names = append(names, p)
+		}
+	}
+	return strings.Join(names, "_")
+}
+
+// sanitizePackageName replaces unallowed character in package name
+// with allowed character.
+func sanitizePackageName(pkgName string) string {
+	pkgName = strings.Replace(pkgName, ".", "_", -1)
+	pkgName = strings.Replace(pkgName, "-", "_", -1)
+	return pkgName
+}
+
+// defaultGoPackageName returns the default go package name to be used for go files generated from "f".
+// You might need to use an unique alias for the package when you import it.  Use ReserveGoPackageAlias to get a unique alias.
+func defaultGoPackageName(f *descriptor.FileDescriptorProto) string {
+	name := packageIdentityName(f)
+	return sanitizePackageName(name)
+}
+
+// packageIdentityName returns the identity of packages.
+// protoc-gen-grpc-gateway rejects CodeGenerationRequests which contains more than one packages
+// as protoc-gen-go does.
+func packageIdentityName(f *descriptor.FileDescriptorProto) string {
+	if f.Options != nil && f.Options.GoPackage != nil {
+		gopkg := f.Options.GetGoPackage()
+		idx := strings.LastIndex(gopkg, "/")
+		if idx < 0 {
+			gopkg = gopkg[idx+1:]
+		}
+
+		gopkg = gopkg[idx+1:]
+		// package name is overrided with the string after the
+		// ';' character
+		sc := strings.IndexByte(gopkg, ';')
+		if sc < 0 {
+			return sanitizePackageName(gopkg)
+
+		}
+		return sanitizePackageName(gopkg[sc+1:])
+	}
+
+	if f.Package == nil {
+		base := filepath.Base(f.GetName())
+		ext := filepath.Ext(base)
+		return strings.TrimSuffix(base, ext)
+	}
+	return f.GetPackage()
+}
+
+func emitFiles(out []*plugin.CodeGeneratorResponse_File) {
+	emitResp(&plugin.CodeGeneratorResponse{File: out})
+}
+
+func emitError(err error) {
+	emitResp(&plugin.CodeGeneratorResponse{Error: proto.String(err.Error())})
+}
+
+func emitResp(resp *plugin.CodeGeneratorResponse) {
+	buf, err := proto.Marshal(resp)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if _, err := os.Stdout.Write(buf); err != nil {
+		log.Fatal(err)
+	}
+}
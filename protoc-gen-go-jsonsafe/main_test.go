@@ -0,0 +1,14 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/f4tq/protoc-go-plugins/internal/plugintest"
+)
+
+// TestConflictingFieldNames vets the MarshalJSONSafe methods of fields
+// protoc-gen-go renames, such as String_ for a field named string.
+func TestConflictingFieldNames(t *testing.T) {
+	req := plugintest.ConflictsRequest(t, "")
+	plugintest.Go(t, "vet", plugintest.Package(t, req, generate))
+}
@@ -0,0 +1,384 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+
+	"github.com/f4tq/protoc-go-plugins/goname"
+	"github.com/f4tq/protoc-go-plugins/options"
+)
+
+// objectMetaType is the proto type of the Kubernetes object metadata.
+const objectMetaType = ".k8s.io.apimachinery.pkg.apis.meta.v1.ObjectMeta"
+
+// objectAttrs are the attributes of metav1.Object, each with a getter and
+// a setter, and their Go types.
+var objectAttrs = []objectAttr{
+	{"Namespace", "string"},
+	{"Name", "string"},
+	{"GenerateName", "string"},
+	{"UID", "types.UID"},
+	{"ResourceVersion", "string"},
+	{"Generation", "int64"},
+	{"SelfLink", "string"},
+	{"CreationTimestamp", "metav1.Time"},
+	{"DeletionTimestamp", "*metav1.Time"},
+	{"DeletionGracePeriodSeconds", "*int64"},
+	{"Labels", "map[string]string"},
+	{"Annotations", "map[string]string"},
+	{"Finalizers", "[]string"},
+	{"OwnerReferences", "[]metav1.OwnerReference"},
+	{"ManagedFields", "[]metav1.ManagedFieldsEntry"},
+}
+
+var (
+	hdrTmpl = template.Must(template.New("header").Parse(`
+// Code generated by protoc-gen-go-k8s. DO NOT EDIT.
+// source: {{.Source}}
+
+package {{.GoPkg}}
+
+import (
+    metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+    "k8s.io/apimachinery/pkg/runtime"
+    "k8s.io/apimachinery/pkg/runtime/schema"
+{{- if .Meta}}
+    "k8s.io/apimachinery/pkg/types"
+{{- end}}
+
+    "github.com/f4tq/protoc-go-plugins/runtime/kubeobject"
+)
+
+// SchemeGroupVersion is the API group and version of the custom resources
+// of {{.Source}}.
+var SchemeGroupVersion = schema.GroupVersion{Group: {{printf "%q" .Group}}, Version: {{printf "%q" .Version}}}
+
+var (
+    // SchemeBuilder collects the functions adding the custom resources to
+    // a scheme.
+    SchemeBuilder = runtime.NewSchemeBuilder(addKnownTypes)
+    // AddToScheme adds the custom resources to a scheme, such as that of a
+    // controller-runtime client.
+    AddToScheme = SchemeBuilder.AddToScheme
+)
+
+func addKnownTypes(scheme *runtime.Scheme) error {
+{{- range .Resources}}
+    scheme.AddKnownTypeWithName(SchemeGroupVersion.WithKind({{printf "%q" .Kind}}), &{{.Name}}{})
+{{- end}}
+    metav1.AddToGroupVersion(scheme, SchemeGroupVersion)
+    return nil
+}
+`))
+
+	resourceTmpl = template.Must(template.New("resource").Parse(`
+var kind{{.Name}} = kubeobject.NewKind(SchemeGroupVersion.WithKind({{printf "%q" .Kind}}))
+
+// GetObjectKind implements runtime.Object. It always reports the kind
+// {{.Kind}} in SchemeGroupVersion.
+func (m *{{.Name}}) GetObjectKind() schema.ObjectKind {
+    return kind{{.Name}}
+}
+
+// DeepCopyObject implements runtime.Object with the DeepCopy method
+// generated by protoc-gen-go-clone.
+func (m *{{.Name}}) DeepCopyObject() runtime.Object {
+    if c := m.DeepCopy(); c != nil {
+        return c
+    }
+    return nil
+}
+{{- if .Meta}}
+
+// objectMeta returns the {{.Meta}} of m, allocating it if nil.
+func (m *{{.Name}}) objectMeta() *metav1.ObjectMeta {
+    if m.{{.Meta}} == nil {
+        m.{{.Meta}} = &metav1.ObjectMeta{}
+    }
+    return m.{{.Meta}}
+}
+{{- $name := .Name}}
+{{- range .Attrs}}
+
+// Get{{.Name}} implements metav1.Object.
+func (m *{{$name}}) Get{{.Name}}() {{.Type}} {
+    return m.objectMeta().Get{{.Name}}()
+}
+
+// Set{{.Name}} implements metav1.Object.
+func (m *{{$name}}) Set{{.Name}}(v {{.Type}}) {
+    m.objectMeta().Set{{.Name}}(v)
+}
+{{- end}}
+{{- end}}
+`))
+)
+
+func main() {
+	input, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	req := new(plugin.CodeGeneratorRequest)
+	if err = proto.Unmarshal(input, req); err != nil {
+		log.Fatal(err)
+	}
+
+	out, err := generate(req)
+
+	if err != nil {
+		emitError(err)
+		return
+	}
+
+	emitFiles(out)
+}
+
+func generate(req *plugin.CodeGeneratorRequest) ([]*plugin.CodeGeneratorResponse_File, error) {
+	var files []*plugin.CodeGeneratorResponse_File
+	genFileNames := make(map[string]bool)
+	for _, n := range req.FileToGenerate {
+		genFileNames[n] = true
+	}
+	// registered maps the directories of the Go packages to the file that
+	// declares their SchemeGroupVersion.
+	registered := make(map[string]string)
+	for _, desc := range req.GetProtoFile() {
+		name := desc.GetName()
+		if _, ok := genFileNames[name]; !ok {
+			// Only emit output for files present in req.FileToGenerate.
+			continue
+		}
+		code, err := genCode(desc)
+		if err != nil {
+			return nil, err
+		}
+		if code == "" {
+			// The file declares no custom resources.
+			continue
+		}
+		dir := filepath.Dir(name)
+		if other, ok := registered[dir]; ok {
+			return nil, fmt.Errorf("%s: custom resources are already declared by %s, of the same package", name, other)
+		}
+		registered[dir] = name
+		formatted, err := format.Source([]byte(code))
+		if err != nil {
+			log.Printf("%v: %s", err, code)
+			return nil, err
+		}
+
+		ext := filepath.Ext(name)
+		base := strings.TrimSuffix(name, ext)
+		output := fmt.Sprintf("%s.pb.k8s.go", base)
+		files = append(files, &plugin.CodeGeneratorResponse_File{
+			Name:    proto.String(output),
+			Content: proto.String(string(formatted)),
+		})
+	}
+
+	return files, nil
+}
+
+func genCode(desc *descriptor.FileDescriptorProto) (string, error) {
+	hdr := &header{
+		Source: desc.GetName(),
+		GoPkg:  defaultGoPackageName(desc),
+	}
+	var group, version, first string
+	err := walkMessages("", desc.GetMessageType(), func(name string, msg *descriptor.DescriptorProto) error {
+		crd := options.CRD(msg)
+		if crd == nil {
+			return nil
+		}
+		if crd.GetVersion() == "" {
+			return fmt.Errorf("%s: (f4tq.plugins.crd) sets no version", name)
+		}
+		if first == "" {
+			group, version, first = crd.GetGroup(), crd.GetVersion(), name
+		} else if crd.GetGroup() != group || crd.GetVersion() != version {
+			return fmt.Errorf("%s: (f4tq.plugins.crd) is not in the group and version of %s", name, first)
+		}
+		r := &resource{Name: name, Kind: crd.GetKind()}
+		meta, err := metaField(msg)
+		if err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+		if meta != "" {
+			r.Meta = meta
+			r.Attrs = objectAttrs
+			hdr.Meta = true
+		}
+		hdr.Resources = append(hdr.Resources, r)
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("%s: %v", desc.GetName(), err)
+	}
+	if len(hdr.Resources) == 0 {
+		return "", nil
+	}
+	hdr.Group, hdr.Version = group, version
+
+	w := bytes.NewBuffer(nil)
+	if err := hdrTmpl.Execute(w, hdr); err != nil {
+		log.Fatal(err)
+	}
+	for _, r := range hdr.Resources {
+		if err := resourceTmpl.Execute(w, r); err != nil {
+			return "", err
+		}
+	}
+
+	return w.String(), nil
+}
+
+// walkMessages calls f with msgs and the messages nested in them, map
+// entries aside, and their Go names. prefix is the Go name of the
+// enclosing message plus "_".
+func walkMessages(prefix string, msgs []*descriptor.DescriptorProto, f func(string, *descriptor.DescriptorProto) error) error {
+	for _, msg := range msgs {
+		if msg.GetOptions().GetMapEntry() {
+			continue
+		}
+		name := prefix + msg.GetName()
+		if err := f(name, msg); err != nil {
+			return err
+		}
+		if err := walkMessages(name+"_", msg.GetNestedType(), f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// metaField returns the Go name of the ObjectMeta field of msg, or "" if
+// it has none. It fails if the getters protoc-gen-go generates for msg
+// clash with those of metav1.Object.
+func metaField(msg *descriptor.DescriptorProto) (string, error) {
+	var meta string
+	goNames := goname.Fields(msg)
+	getters := make(map[string]string)
+	for _, field := range msg.GetField() {
+		getters["Get"+goNames[field.GetName()]] = field.GetName()
+		if field.GetTypeName() != objectMetaType {
+			continue
+		}
+		if field.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REPEATED {
+			return "", fmt.Errorf("the ObjectMeta field %s is repeated", field.GetName())
+		}
+		if meta != "" {
+			return "", fmt.Errorf("more than one ObjectMeta field")
+		}
+		meta = goNames[field.GetName()]
+	}
+	if meta == "" {
+		return "", nil
+	}
+	for _, oneof := range msg.GetOneofDecl() {
+		getters["Get"+goNames[oneof.GetName()]] = oneof.GetName()
+	}
+	for _, attr := range objectAttrs {
+		if name, ok := getters["Get"+attr.Name]; ok {
+			return "", fmt.Errorf("the getter of %s clashes with Get%s of metav1.Object", name, attr.Name)
+		}
+	}
+	return meta, nil
+}
+
+type objectAttr struct {
+	Name string
+	Type string
+}
+
+type header struct {
+	Source    string
+	GoPkg     string
+	Group     string
+	Version   string
+	Meta      bool
+	Resources []*resource
+}
+
+type resource struct {
+	Name string
+	Kind string
+	// Meta is the Go name of the ObjectMeta field, if any.
+	Meta  string
+	Attrs []objectAttr
+}
+
+// sanitizePackageName replaces unallowed character in package name
+// with allowed character.
+func sanitizePackageName(pkgName string) string {
+	pkgName = strings.Replace(pkgName, ".", "_", -1)
+	pkgName = strings.Replace(pkgName, "-", "_", -1)
+	return pkgName
+}
+
+// defaultGoPackageName returns the default go package name to be used for go files generated from "f".
+// You might need to use an unique alias for the package when you import it.  Use ReserveGoPackageAlias to get a unique alias.
+func defaultGoPackageName(f *descriptor.FileDescriptorProto) string {
+	name := packageIdentityName(f)
+	return sanitizePackageName(name)
+}
+
+// packageIdentityName returns the identity of packages.
+// protoc-gen-grpc-gateway rejects CodeGenerationRequests which contains more than one packages
+// as protoc-gen-go does.
+func packageIdentityName(f *descriptor.FileDescriptorProto) string {
+	if f.Options != nil && f.Options.GoPackage != nil {
+		gopkg := f.Options.GetGoPackage()
+		idx := strings.LastIndex(gopkg, "/")
+		if idx < 0 {
+			gopkg = gopkg[idx+1:]
+		}
+
+		gopkg = gopkg[idx+1:]
+		// package name is overrided with the string after the
+		// ';' character
+		sc := strings.IndexByte(gopkg, ';')
+		if sc < 0 {
+			return sanitizePackageName(gopkg)
+
+		}
+		return sanitizePackageName(gopkg[sc+1:])
+	}
+
+	if f.Package == nil {
+		base := filepath.Base(f.GetName())
+		ext := filepath.Ext(base)
+		return strings.TrimSuffix(base, ext)
+	}
+	return f.GetPackage()
+}
+
+func emitFiles(out []*plugin.CodeGeneratorResponse_File) {
+	emitResp(&plugin.CodeGeneratorResponse{File: out})
+}
+
+func emitError(err error) {
+	emitResp(&plugin.CodeGeneratorResponse{Error: proto.String(err.Error())})
+}
+
+func emitResp(resp *plugin.CodeGeneratorResponse) {
+	buf, err := proto.Marshal(resp)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if _, err := os.Stdout.Write(buf); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,341 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+
+	"github.com/f4tq/protoc-go-plugins/goname"
+	"github.com/f4tq/protoc-go-plugins/options"
+	"github.com/f4tq/protoc-go-plugins/protodoc"
+)
+
+var (
+	hdrTmpl = template.Must(template.New("header").Parse(`
+// Code generated by protoc-gen-go-kafka. DO NOT EDIT.
+// source: {{.Source}}
+
+package {{.GoPkg}}
+
+import (
+    "context"
+{{- if .Strconv}}
+    "strconv"
+{{- end}}
+
+    "github.com/Shopify/sarama"
+    "github.com/f4tq/protoc-go-plugins/runtime/kafkapb"
+    "github.com/golang/protobuf/proto"
+)
+`))
+
+	topicTmpl = template.Must(template.New("topic").Parse(`
+// {{.Name}}KafkaTopic is the topic {{.Name}} messages are produced to.{{.Doc}}
+const {{.Name}}KafkaTopic = {{printf "%q" .Topic}}
+{{if .Key}}
+// {{.Lower}}KafkaKey returns the record key of msg, its {{.KeyField}} field.
+func {{.Lower}}KafkaKey(msg *{{.Name}}) []byte {
+    return {{.Key}}
+}
+{{end}}
+// {{.Name}}KafkaProducer produces {{.Name}} messages to {{.Name}}KafkaTopic
+{{- if .Key}}, keyed by
+// their {{.KeyField}} field{{end}}.{{.Doc}}
+type {{.Name}}KafkaProducer struct {
+    producer *kafkapb.Producer
+}
+
+// New{{.Name}}KafkaProducer returns a {{.Name}}KafkaProducer sending records through
+// producer.{{.Doc}}
+func New{{.Name}}KafkaProducer(producer *kafkapb.Producer) *{{.Name}}KafkaProducer {
+    return &{{.Name}}KafkaProducer{producer: producer}
+}
+
+// Produce sends msg to {{.Name}}KafkaTopic.
+func (p *{{.Name}}KafkaProducer) Produce(msg *{{.Name}}) error {
+    return p.producer.Send({{.Name}}KafkaTopic, {{if .Key}}{{.Lower}}KafkaKey(msg){{else}}nil{{end}}, msg)
+}
+
+// {{.Name}}KafkaHandler handles {{.Name}} messages consumed from
+// {{.Name}}KafkaTopic. kafkapb.Record(ctx) returns the record a message was
+// decoded from.{{.Doc}}
+type {{.Name}}KafkaHandler interface {
+    Handle{{.Name}}(ctx context.Context, msg *{{.Name}}) error
+}
+
+// {{.Name}}KafkaHandlerFunc adapts a function to a {{.Name}}KafkaHandler.{{.Doc}}
+type {{.Name}}KafkaHandlerFunc func(ctx context.Context, msg *{{.Name}}) error
+
+// Handle{{.Name}} calls f(ctx, msg).
+func (f {{.Name}}KafkaHandlerFunc) Handle{{.Name}}(ctx context.Context, msg *{{.Name}}) error {
+    return f(ctx, msg)
+}
+
+// New{{.Name}}ConsumerGroupHandler returns a sarama.ConsumerGroupHandler
+// passing the {{.Name}} messages of the claimed partitions to h. Records
+// without a content type header are decoded as binary protobuf.{{.Doc}}
+func New{{.Name}}ConsumerGroupHandler(h {{.Name}}KafkaHandler) sarama.ConsumerGroupHandler {
+    return &kafkapb.ConsumerGroupHandler{
+        NewMessage: func() proto.Message { return new({{.Name}}) },
+        Handle: func(ctx context.Context, msg proto.Message) error {
+            return h.Handle{{.Name}}(ctx, msg.(*{{.Name}}))
+        },
+    }
+}
+`))
+)
+
+func main() {
+	input, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	req := new(plugin.CodeGeneratorRequest)
+	if err = proto.Unmarshal(input, req); err != nil {
+		log.Fatal(err)
+	}
+
+	out, err := generate(req)
+
+	if err != nil {
+		emitError(err)
+		return
+	}
+
+	emitFiles(out)
+}
+
+func generate(req *plugin.CodeGeneratorRequest) ([]*plugin.CodeGeneratorResponse_File, error) {
+	var files []*plugin.CodeGeneratorResponse_File
+	docs := protodoc.New(req.GetProtoFile())
+	genFileNames := make(map[string]bool)
+	for _, n := range req.FileToGenerate {
+		genFileNames[n] = true
+	}
+	for _, desc := range req.GetProtoFile() {
+		name := desc.GetName()
+		if _, ok := genFileNames[name]; !ok {
+			// Only emit output for files present in req.FileToGenerate.
+			continue
+		}
+		code, err := genCode(desc, docs)
+		if err != nil {
+			return nil, err
+		}
+		if code == "" {
+			// No message declares a topic.
+			continue
+		}
+		formatted, err := format.Source([]byte(code))
+		if err != nil {
+			log.Printf("%v: %s", err, code)
+			return nil, err
+		}
+
+		ext := filepath.Ext(name)
+		base := strings.TrimSuffix(name, ext)
+		output := fmt.Sprintf("%s.pb.kafka.go", base)
+		files = append(files, &plugin.CodeGeneratorResponse_File{
+			Name:    proto.String(output),
+			Content: proto.String(string(formatted)),
+		})
+	}
+
+	return files, nil
+}
+
+func genCode(desc *descriptor.FileDescriptorProto, docs *protodoc.Index) (string, error) {
+	w := bytes.NewBuffer(nil)
+	hdr := &header{
+		Source: desc.GetName(),
+		GoPkg:  defaultGoPackageName(desc),
+	}
+	prefix := ""
+	if desc.GetPackage() != "" {
+		prefix = "." + desc.GetPackage()
+	}
+	body := bytes.NewBuffer(nil)
+	if err := genTopics(body, hdr, docs, prefix, desc.GetMessageType()); err != nil {
+		return "", err
+	}
+	if body.Len() == 0 {
+		return "", nil
+	}
+
+	if err := hdrTmpl.Execute(w, hdr); err != nil {
+		log.Fatal(err)
+	}
+	body.WriteTo(w)
+
+	return w.String(), nil
+}
+
+// genTopics writes the producers and consumer group handlers of the
+// messages in msgs, and their nested messages, that have a
+// (f4tq.plugins.topic) option.
+func genTopics(w *bytes.Buffer, hdr *header, docs *protodoc.Index, prefix string, msgs []*descriptor.DescriptorProto) error {
+	for _, m := range msgs {
+		name := prefix + "." + m.GetName()
+		if m.GetOptions().GetMapEntry() {
+			continue
+		}
+		if topic := options.Topic(m); topic != "" {
+			goName := localTypeName(name)
+			t := &kafkaTopic{
+				Name:  goName,
+				Lower: strings.ToLower(goName[:1]) + goName[1:],
+				Topic: topic,
+				Doc:   docs.Godoc(name),
+			}
+			for _, f := range m.GetField() {
+				if !options.MessageKey(f) {
+					continue
+				}
+				if t.Key != "" {
+					return fmt.Errorf("%s: more than one message_key field", strings.TrimPrefix(name, "."))
+				}
+				key, err := keyExpr(f, goname.Fields(m)[f.GetName()], hdr)
+				if err != nil {
+					return fmt.Errorf("%s.%s: %v", strings.TrimPrefix(name, "."), f.GetName(), err)
+				}
+				t.Key = key
+				t.KeyField = f.GetName()
+			}
+			if err := topicTmpl.Execute(w, t); err != nil {
+				return err
+			}
+		}
+		if err := genTopics(w, hdr, docs, name, m.GetNestedType()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// keyExpr returns the Go expression encoding the key field of msg, whose Go
+// name is goName, as bytes.
+func keyExpr(f *descriptor.FieldDescriptorProto, goName string, hdr *header) (string, error) {
+	if f.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REPEATED {
+		return "", fmt.Errorf("message_key field must not be repeated")
+	}
+	get := "msg.Get" + goName + "()"
+	switch f.GetType() {
+	case descriptor.FieldDescriptorProto_TYPE_STRING:
+		return "[]byte(" + get + ")", nil
+	case descriptor.FieldDescriptorProto_TYPE_BYTES:
+		return get, nil
+	case descriptor.FieldDescriptorProto_TYPE_INT32, descriptor.FieldDescriptorProto_TYPE_INT64,
+		descriptor.FieldDescriptorProto_TYPE_SINT32, descriptor.FieldDescriptorProto_TYPE_SINT64,
+		descriptor.FieldDescriptorProto_TYPE_SFIXED32, descriptor.FieldDescriptorProto_TYPE_SFIXED64:
+		hdr.Strconv = true
+		return "[]byte(strconv.FormatInt(int64(" + get + "), 10))", nil
+	case descriptor.FieldDescriptorProto_TYPE_UINT32, descriptor.FieldDescriptorProto_TYPE_UINT64,
+		descriptor.FieldDescriptorProto_TYPE_FIXED32, descriptor.FieldDescriptorProto_TYPE_FIXED64:
+		hdr.Strconv = true
+		return "[]byte(strconv.FormatUint(uint64(" + get + "), 10))", nil
+	}
+	return "", fmt.Errorf("message_key field must be a string, bytes or integer")
+}
+
+type header struct {
+	Source  string
+	GoPkg   string
+	Strconv bool
+}
+
+type kafkaTopic struct {
+	Name     string
+	Lower    string
+	Topic    string
+	Key      string
+	KeyField string
+	// Doc is the comment of the message, see protodoc.Index.Godoc.
+	Doc string
+}
+
+// localTypeName returns the Go name of a type declared in the file being
+// generated, e.g. ".pkg.Outer.Inner" becomes "Outer_Inner".
+func localTypeName(typeName string) string {
+	parts := strings.Split(strings.TrimPrefix(typeName, "."), ".")
+	var names []string
+	for _, p := range parts {
+		if len(p) > 0 && p[0] >= 'A' && p[0] <= 'Z' {
+			names = append(names, p)
+		}
+	}
+	return strings.Join(names, "_")
+}
+
+// sanitizePackageName replaces unallowed character in package name
+// with allowed character.
+func sanitizePackageName(pkgName string) string {
+	pkgName = strings.Replace(pkgName, ".", "_", -1)
+	pkgName = strings.Replace(pkgName, "-", "_", -1)
+	return pkgName
+}
+
+// defaultGoPackageName returns the default go package name to be used for go files generated from "f".
+// You might need to use an unique alias for the package when you import it.  Use ReserveGoPackageAlias to get a unique alias.
+func defaultGoPackageName(f *descriptor.FileDescriptorProto) string {
+	name := packageIdentityName(f)
+	return sanitizePackageName(name)
+}
+
+// packageIdentityName returns the identity of packages.
+// protoc-gen-grpc-gateway rejects CodeGenerationRequests which contains more than one packages
+// as protoc-gen-go does.
+func packageIdentityName(f *descriptor.FileDescriptorProto) string {
+	if f.Options != nil && f.Options.GoPackage != nil {
+		gopkg := f.Options.GetGoPackage()
+		idx := strings.LastIndex(gopkg, "/")
+		if idx < 0 {
+			gopkg = gopkg[idx+1:]
+		}
+
+		gopkg = gopkg[idx+1:]
+		// package name is overrided with the string after the
+		// ';' character
+		sc := strings.IndexByte(gopkg, ';')
+		if sc < 0 {
+			return sanitizePackageName(gopkg)
+
+		}
+		return sanitizePackageName(gopkg[sc+1:])
+	}
+
+	if f.Package == nil {
+		base := filepath.Base(f.GetName())
+		ext := filepath.Ext(base)
+		return strings.TrimSuffix(base, ext)
+	}
+	return f.GetPackage()
+}
+
+func emitFiles(out []*plugin.CodeGeneratorResponse_File) {
+	emitResp(&plugin.CodeGeneratorResponse{File: out})
+}
+
+func emitError(err error) {
+	emitResp(&plugin.CodeGeneratorResponse{Error: proto.String(err.Error())})
+}
+
+func emitResp(resp *plugin.CodeGeneratorResponse) {
+	buf, err := proto.Marshal(resp)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if _, err := os.Stdout.Write(buf); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,461 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+
+	"github.com/f4tq/protoc-go-plugins/goname"
+)
+
+// fieldMaskPath is the import path of the Go FieldMask type.
+const fieldMaskPath = "google.golang.org/genproto/protobuf/field_mask"
+
+var (
+	hdrTmpl = template.Must(template.New("header").Parse(`
+// Code generated by protoc-gen-go-mask. DO NOT EDIT.
+// source: {{.Source}}
+
+package {{.GoPkg}}
+
+import (
+    "strings"
+
+    "github.com/f4tq/protoc-go-plugins/runtime/fieldmask"
+{{- range $path, $name := .Imports}}
+    {{$name}} "{{$path}}"
+{{- end}}
+)
+`))
+
+	messageTmpl = template.Must(template.New("message").Parse(`
+// ApplyMask keeps the fields of m selected by mask and clears the others,
+// along with the unknown fields; an empty mask clears everything. Paths may
+// descend into message fields. Nothing is changed if a path is invalid.
+func (m *{{.Name}}) ApplyMask(mask *field_mask.FieldMask) error {
+    if err := {{.Lower}}MaskCheck(mask); err != nil {
+        return err
+    }
+    {{.Lower}}MaskKeep(m, fieldmask.Parse(mask.GetPaths()))
+    return nil
+}
+
+// PruneToMask clears the fields of m selected by mask and keeps the
+// others. Paths may descend into message fields. Nothing is changed if a
+// path is invalid.
+func (m *{{.Name}}) PruneToMask(mask *field_mask.FieldMask) error {
+    if err := {{.Lower}}MaskCheck(mask); err != nil {
+        return err
+    }
+    {{.Lower}}MaskPrune(m, fieldmask.Parse(mask.GetPaths()))
+    return nil
+}
+
+func {{.Lower}}MaskCheck(mask *field_mask.FieldMask) error {
+    for _, p := range mask.GetPaths() {
+        if !{{.Lower}}MaskPath(strings.Split(p, ".")) {
+            return &fieldmask.PathError{Message: {{printf "%q" .Full}}, Path: p}
+        }
+    }
+    return nil
+}
+
+func {{.Lower}}MaskPath(path []string) bool {
+    switch path[0] {
+{{- range .Fields}}
+    case {{printf "%q" .Proto}}:
+{{- if .Nested}}
+        return len(path) == 1 || {{.Nested}}MaskPath(path[1:])
+{{- else}}
+        return len(path) == 1
+{{- end}}
+{{- end}}
+    }
+    return false
+}
+
+func {{.Lower}}MaskKeep(m *{{.Name}}, t fieldmask.Tree) {
+{{- range .Fields}}
+{{- if .Nested}}
+    if sub, ok := t[{{printf "%q" .Proto}}]; !ok {
+        m.{{.Go}} = nil
+    } else if sub != nil && m.{{.Go}} != nil {
+        {{.Nested}}MaskKeep(m.{{.Go}}, sub)
+    }
+{{- else}}
+    if _, ok := t[{{printf "%q" .Proto}}]; !ok {
+        {{.Clear}}
+    }
+{{- end}}
+{{- end}}
+    m.XXX_unrecognized = nil
+}
+
+func {{.Lower}}MaskPrune(m *{{.Name}}, t fieldmask.Tree) {
+    for name{{if .HasNested}}, sub{{end}} := range t {
+        switch name {
+{{- range .Fields}}
+        case {{printf "%q" .Proto}}:
+{{- if .Nested}}
+            if sub == nil {
+                m.{{.Go}} = nil
+            } else if m.{{.Go}} != nil {
+                {{.Nested}}MaskPrune(m.{{.Go}}, sub)
+            }
+{{- else}}
+            {{.Clear}}
+{{- end}}
+{{- end}}
+        }
+    }
+}
+`))
+)
+
+func main() {
+	input, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	req := new(plugin.CodeGeneratorRequest)
+	if err = proto.Unmarshal(input, req); err != nil {
+		log.Fatal(err)
+	}
+
+	out, err := generate(req)
+
+	if err != nil {
+		emitError(err)
+		return
+	}
+
+	emitFiles(out)
+}
+
+func generate(req *plugin.CodeGeneratorRequest) ([]*plugin.CodeGeneratorResponse_File, error) {
+	var files []*plugin.CodeGeneratorResponse_File
+	idx := newTypeIndex(req.GetProtoFile())
+	genFileNames := make(map[string]bool)
+	for _, n := range req.FileToGenerate {
+		genFileNames[n] = true
+	}
+	for _, desc := range req.GetProtoFile() {
+		name := desc.GetName()
+		if _, ok := genFileNames[name]; !ok {
+			// Only emit output for files present in req.FileToGenerate.
+			continue
+		}
+		code, err := genCode(desc, idx)
+		if err != nil {
+			return nil, err
+		}
+		if code == "" {
+			// The file declares no messages.
+			continue
+		}
+		formatted, err := format.Source([]byte(code))
+		if err != nil {
+			log.Printf("%v: %s", err, code)
+			return nil, err
+		}
+
+		ext := filepath.Ext(name)
+		base := strings.TrimSuffix(name, ext)
+		output := fmt.Sprintf("%s.pb.mask.go", base)
+		files = append(files, &plugin.CodeGeneratorResponse_File{
+			Name:    proto.String(output),
+			Content: proto.String(string(formatted)),
+		})
+	}
+
+	return files, nil
+}
+
+func genCode(desc *descriptor.FileDescriptorProto, idx *typeIndex) (string, error) {
+	w := bytes.NewBuffer(nil)
+	imports := newImportSet(desc)
+	imports.names[fieldMaskPath] = "field_mask"
+	g := &maskGen{desc: desc, idx: idx, proto3: desc.GetSyntax() == "proto3"}
+
+	body := bytes.NewBuffer(nil)
+	if err := g.messages(body, strings.TrimPrefix("."+desc.GetPackage(), "."), desc.GetMessageType()); err != nil {
+		return "", err
+	}
+	if body.Len() == 0 {
+		return "", nil
+	}
+
+	hdr := &header{
+		Source:  desc.GetName(),
+		GoPkg:   defaultGoPackageName(desc),
+		Imports: imports.names,
+	}
+	if err := hdrTmpl.Execute(w, hdr); err != nil {
+		log.Fatal(err)
+	}
+	body.WriteTo(w)
+
+	return w.String(), nil
+}
+
+type maskGen struct {
+	desc   *descriptor.FileDescriptorProto
+	idx    *typeIndex
+	proto3 bool
+}
+
+// messages writes the mask helpers of msgs and of the messages nested in
+// them. scope is the full proto name of their parent.
+func (g *maskGen) messages(w *bytes.Buffer, scope string, msgs []*descriptor.DescriptorProto) error {
+	for _, msg := range msgs {
+		if msg.GetOptions().GetMapEntry() {
+			continue
+		}
+		full := msg.GetName()
+		if scope != "" {
+			full = scope + "." + full
+		}
+		if err := messageTmpl.Execute(w, g.message(full, msg)); err != nil {
+			return err
+		}
+		if err := g.messages(w, full, msg.GetNestedType()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// message returns the template data of msg, whose full proto name is full.
+func (g *maskGen) message(full string, msg *descriptor.DescriptorProto) *maskMessage {
+	name := localTypeName("." + full)
+	m := &maskMessage{Name: name, Lower: strings.ToLower(name[:1]) + name[1:], Full: full}
+	goNames := goname.Fields(msg)
+	for _, field := range msg.GetField() {
+		goName := goNames[field.GetName()]
+		f := &maskField{Proto: field.GetName(), Go: goName}
+		repeated := field.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REPEATED
+		switch {
+		case field.OneofIndex != nil && !field.GetProto3Optional():
+			oneof := goNames[msg.GetOneofDecl()[field.GetOneofIndex()].GetName()]
+			f.Clear = fmt.Sprintf("if _, ok := m.%s.(*%s_%s); ok {\nm.%s = nil\n}", oneof, name, goName, oneof)
+		case repeated, field.GetType() == descriptor.FieldDescriptorProto_TYPE_BYTES,
+			field.GetType() == descriptor.FieldDescriptorProto_TYPE_MESSAGE,
+			field.GetType() == descriptor.FieldDescriptorProto_TYPE_GROUP,
+			field.GetProto3Optional(), !g.proto3:
+			f.Clear = fmt.Sprintf("m.%s = nil", goName)
+		case field.GetType() == descriptor.FieldDescriptorProto_TYPE_STRING:
+			f.Clear = fmt.Sprintf("m.%s = \"\"", goName)
+		case field.GetType() == descriptor.FieldDescriptorProto_TYPE_BOOL:
+			f.Clear = fmt.Sprintf("m.%s = false", goName)
+		default:
+			f.Clear = fmt.Sprintf("m.%s = 0", goName)
+		}
+		// Paths only descend into singular messages of this file; others
+		// are kept or cleared as a whole.
+		if field.OneofIndex == nil && !repeated && field.GetType() == descriptor.FieldDescriptorProto_TYPE_MESSAGE &&
+			g.idx.files[field.GetTypeName()] == g.desc {
+			nested := localTypeName(field.GetTypeName())
+			f.Nested = strings.ToLower(nested[:1]) + nested[1:]
+			m.HasNested = true
+		}
+		m.Fields = append(m.Fields, f)
+	}
+	return m
+}
+
+type header struct {
+	Source  string
+	GoPkg   string
+	Imports map[string]string
+}
+
+type maskMessage struct {
+	Name      string
+	Lower     string
+	Full      string
+	HasNested bool
+	Fields    []*maskField
+}
+
+type maskField struct {
+	Proto  string
+	Go     string
+	Clear  string
+	Nested string
+}
+
+// typeIndex maps fully-qualified proto type names (".pkg.Msg") to their
+// descriptors and declaring files for every file in the request.
+type typeIndex struct {
+	messages map[string]*descriptor.DescriptorProto
+	enums    map[string]*descriptor.EnumDescriptorProto
+	files    map[string]*descriptor.FileDescriptorProto
+}
+
+func newTypeIndex(files []*descriptor.FileDescriptorProto) *typeIndex {
+	idx := &typeIndex{
+		messages: make(map[string]*descriptor.DescriptorProto),
+		enums:    make(map[string]*descriptor.EnumDescriptorProto),
+		files:    make(map[string]*descriptor.FileDescriptorProto),
+	}
+	for _, f := range files {
+		prefix := ""
+		if f.GetPackage() != "" {
+			prefix = "." + f.GetPackage()
+		}
+		idx.addMessages(f, prefix, f.GetMessageType())
+		idx.addEnums(f, prefix, f.GetEnumType())
+	}
+	return idx
+}
+
+func (idx *typeIndex) addMessages(f *descriptor.FileDescriptorProto, prefix string, msgs []*descriptor.DescriptorProto) {
+	for _, m := range msgs {
+		name := prefix + "." + m.GetName()
+		idx.messages[name] = m
+		idx.files[name] = f
+		idx.addMessages(f, name, m.GetNestedType())
+		idx.addEnums(f, name, m.GetEnumType())
+	}
+}
+
+func (idx *typeIndex) addEnums(f *descriptor.FileDescriptorProto, prefix string, enums []*descriptor.EnumDescriptorProto) {
+	for _, e := range enums {
+		name := prefix + "." + e.GetName()
+		idx.enums[name] = e
+		idx.files[name] = f
+	}
+}
+
+// isMap reports whether field is a map field.
+func (idx *typeIndex) isMap(field *descriptor.FieldDescriptorProto) bool {
+	if field.GetType() != descriptor.FieldDescriptorProto_TYPE_MESSAGE {
+		return false
+	}
+	return idx.messages[field.GetTypeName()].GetOptions().GetMapEntry()
+}
+
+// goImportPath returns the import path of the Go package generated from f,
+// or "" if go_package does not name one.
+func goImportPath(f *descriptor.FileDescriptorProto) string {
+	gopkg := f.GetOptions().GetGoPackage()
+	if sc := strings.IndexByte(gopkg, ';'); sc >= 0 {
+		gopkg = gopkg[:sc]
+	}
+	if !strings.Contains(gopkg, "/") {
+		return ""
+	}
+	return gopkg
+}
+
+// importSet records the Go packages referenced by the code generated for
+// one file, keyed by import path.
+type importSet struct {
+	self  string
+	names map[string]string
+}
+
+func newImportSet(f *descriptor.FileDescriptorProto) *importSet {
+	return &importSet{self: goImportPath(f), names: make(map[string]string)}
+}
+
+// goTypeName returns the Go name of the message or enum typeName, qualified
+// with its package if it is declared outside the generated package.
+func (s *importSet) goTypeName(idx *typeIndex, typeName string) string {
+	name := localTypeName(typeName)
+	f := idx.files[typeName]
+	path := goImportPath(f)
+	if f == nil || path == "" || path == s.self {
+		return name
+	}
+	pkg := defaultGoPackageName(f)
+	s.names[path] = pkg
+	return pkg + "." + name
+}
+
+// localTypeName returns the Go name of a type declared in the file being
+// generated, e.g. ".pkg.Outer.Inner" becomes "Outer_Inner".
+func localTypeName(typeName string) string {
+	parts := strings.Split(strings.TrimPrefix(typeName, "."), ".")
+	var names []string
+	for _, p := range parts {
+		if len(p) > 0 && p[0] >= 'A' && p[0] <= 'Z' {
+			names = append(names, p)
+		}
+	}
+	return strings.Join(names, "_")
+}
+
+// sanitizePackageName replaces unallowed character in package name
+// with allowed character.
+func sanitizePackageName(pkgName string) string {
+	pkgName = strings.Replace(pkgName, ".", "_", -1)
+	pkgName = strings.Replace(pkgName, "-", "_", -1)
+	return pkgName
+}
+
+// defaultGoPackageName returns the default go package name to be used for go files generated from "f".
+// You might need to use an unique alias for the package when you import it.  Use ReserveGoPackageAlias to get a unique alias.
+func defaultGoPackageName(f *descriptor.FileDescriptorProto) string {
+	name := packageIdentityName(f)
+	return sanitizePackageName(name)
+}
+
+// packageIdentityName returns the identity of packages.
+// protoc-gen-grpc-gateway rejects CodeGenerationRequests which contains more than one packages
+// as protoc-gen-go does.
+func packageIdentityName(f *descriptor.FileDescriptorProto) string {
+	if f.Options != nil && f.Options.GoPackage != nil {
+		gopkg := f.Options.GetGoPackage()
+		idx := strings.LastIndex(gopkg, "/")
+		if idx < 0 {
+			gopkg = gopkg[idx+1:]
+		}
+
+		gopkg = gopkg[idx+1:]
+		// package name is overrided with the string after the
+		// ';' character
+		sc := strings.IndexByte(gopkg, ';')
+		if sc < 0 {
+			return sanitizePackageName(gopkg)
+
+		}
+		return sanitizePackageName(gopkg[sc+1:])
+	}
+
+	if f.Package == nil {
+		base := filepath.Base(f.GetName())
+		ext := filepath.Ext(base)
+		return strings.TrimSuffix(base, ext)
+	}
+	return f.GetPackage()
+}
+
+func emitFiles(out []*plugin.CodeGeneratorResponse_File) {
+	emitResp(&plugin.CodeGeneratorResponse{File: out})
+}
+
+func emitError(err error) {
+	emitResp(&plugin.CodeGeneratorResponse{Error: proto.String(err.Error())})
+}
+
+func emitResp(resp *plugin.CodeGeneratorResponse) {
+	buf, err := proto.Marshal(resp)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if _, err := os.Stdout.Write(buf); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,461 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+
+	"github.com/f4tq/protoc-go-plugins/protodoc"
+)
+
+// fieldMaskPath is the import path of the Go FieldMask type.
+const fieldMaskPath = "google.golang.org/genproto/protobuf/field_mask"
+
+var (
+	hdrTmpl = template.Must(template.New("header").Parse(`
+// Code generated by protoc-gen-go-maskpath. DO NOT EDIT.
+// source: {{.Source}}
+
+package {{.GoPkg}}
+
+import (
+{{- if .Cut}}
+    "strings"
+{{end}}
+    "github.com/f4tq/protoc-go-plugins/runtime/fieldmask"
+{{- range $path, $name := .Imports}}
+    {{$name}} "{{$path}}"
+{{- end}}
+)
+`))
+
+	pathTmpl = template.Must(template.New("path").Parse(`
+// Valid{{.Name}}MaskPath reports whether path is a FieldMask path of
+// {{.Full}}: a field name, then for a singular message field an
+// optional path of that message, for a map field an optional key or "*",
+// and for a repeated field an optional "*". Keys and "*" may be followed by
+// a path of the element message.{{.Doc}}
+func Valid{{.Name}}MaskPath(path string) bool {
+{{- if .Fields}}
+    name, {{if .Descends}}rest{{else}}_{{end}}, more := strings.Cut(path, ".")
+    switch name {
+{{- range .Fields}}
+    case {{printf "%q" .Proto}}:
+        return {{.Check}}
+{{- end}}
+    }
+{{- end}}
+    return false
+}
+
+// ValidateMask returns a *fieldmask.PathError for the first path of mask
+// that is not valid for {{.Name}}, see Valid{{.Name}}MaskPath. m is not used
+// and may be nil.
+func (m *{{.Name}}) ValidateMask(mask *field_mask.FieldMask) error {
+    for _, p := range mask.GetPaths() {
+        if !Valid{{.Name}}MaskPath(p) {
+            return &fieldmask.PathError{Message: {{printf "%q" .Full}}, Path: p}
+        }
+    }
+    return nil
+}
+`))
+)
+
+func main() {
+	input, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	req := new(plugin.CodeGeneratorRequest)
+	if err = proto.Unmarshal(input, req); err != nil {
+		log.Fatal(err)
+	}
+
+	out, err := generate(req)
+
+	if err != nil {
+		emitError(err)
+		return
+	}
+
+	emitFiles(out)
+}
+
+func generate(req *plugin.CodeGeneratorRequest) ([]*plugin.CodeGeneratorResponse_File, error) {
+	var files []*plugin.CodeGeneratorResponse_File
+	idx := newTypeIndex(req.GetProtoFile())
+	docs := protodoc.New(req.GetProtoFile())
+	genFileNames := make(map[string]bool)
+	for _, n := range req.FileToGenerate {
+		genFileNames[n] = true
+	}
+	for _, desc := range req.GetProtoFile() {
+		name := desc.GetName()
+		if _, ok := genFileNames[name]; !ok {
+			// Only emit output for files present in req.FileToGenerate.
+			continue
+		}
+		code, err := genCode(desc, idx, docs, genFileNames)
+		if err != nil {
+			return nil, err
+		}
+		if code == "" {
+			// The file declares no messages.
+			continue
+		}
+		formatted, err := format.Source([]byte(code))
+		if err != nil {
+			log.Printf("%v: %s", err, code)
+			return nil, err
+		}
+
+		ext := filepath.Ext(name)
+		base := strings.TrimSuffix(name, ext)
+		output := fmt.Sprintf("%s.pb.maskpath.go", base)
+		files = append(files, &plugin.CodeGeneratorResponse_File{
+			Name:    proto.String(output),
+			Content: proto.String(string(formatted)),
+		})
+	}
+
+	return files, nil
+}
+
+func genCode(desc *descriptor.FileDescriptorProto, idx *typeIndex, docs *protodoc.Index, genFileNames map[string]bool) (string, error) {
+	w := bytes.NewBuffer(nil)
+	imports := newImportSet(desc)
+	imports.names[fieldMaskPath] = "field_mask"
+	g := &pathGen{idx: idx, docs: docs, imports: imports, gen: genFileNames}
+
+	body := bytes.NewBuffer(nil)
+	if err := g.messages(body, strings.TrimPrefix("."+desc.GetPackage(), "."), desc.GetMessageType()); err != nil {
+		return "", err
+	}
+	if body.Len() == 0 {
+		return "", nil
+	}
+
+	hdr := &header{
+		Source:  desc.GetName(),
+		GoPkg:   defaultGoPackageName(desc),
+		Imports: g.imports.names,
+		Cut:     g.cut,
+	}
+	if err := hdrTmpl.Execute(w, hdr); err != nil {
+		log.Fatal(err)
+	}
+	body.WriteTo(w)
+
+	return w.String(), nil
+}
+
+type pathGen struct {
+	idx     *typeIndex
+	docs    *protodoc.Index
+	imports *importSet
+	gen     map[string]bool
+	// cut records whether a message has fields, whose paths are split
+	// with strings.Cut.
+	cut bool
+}
+
+// messages writes the path validators of msgs and of the messages nested
+// in them. scope is the full proto name of their parent.
+func (g *pathGen) messages(w *bytes.Buffer, scope string, msgs []*descriptor.DescriptorProto) error {
+	for _, msg := range msgs {
+		if msg.GetOptions().GetMapEntry() {
+			continue
+		}
+		full := msg.GetName()
+		if scope != "" {
+			full = scope + "." + full
+		}
+		if err := pathTmpl.Execute(w, g.message(full, msg)); err != nil {
+			return err
+		}
+		if err := g.messages(w, full, msg.GetNestedType()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// message returns the template data of msg, whose full proto name is full.
+func (g *pathGen) message(full string, msg *descriptor.DescriptorProto) *pathMessage {
+	m := &pathMessage{Name: localTypeName("." + full), Full: full, Doc: g.docs.Godoc(full)}
+	for _, field := range msg.GetField() {
+		f := &pathField{Proto: field.GetName(), Check: "!more"}
+		switch {
+		case g.idx.isMap(field):
+			value := g.idx.messages[field.GetTypeName()].GetField()[1]
+			f.Check = fmt.Sprintf("!more || fieldmask.Key(rest, %s)", g.validFunc(value))
+		case field.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REPEATED:
+			f.Check = fmt.Sprintf("!more || fieldmask.Elem(rest, %s)", g.validFunc(field))
+		default:
+			if v := g.validFunc(field); v != "nil" {
+				f.Check = fmt.Sprintf("!more || %s(rest)", v)
+			}
+		}
+		if f.Check != "!more" {
+			m.Descends = true
+		}
+		m.Fields = append(m.Fields, f)
+		g.cut = true
+	}
+	return m
+}
+
+// validFunc returns the path validator of the message type of field, or
+// "nil" if field is not a message generated by this plugin. Paths only
+// descend into the latter; others are selected as a whole.
+func (g *pathGen) validFunc(field *descriptor.FieldDescriptorProto) string {
+	if field.GetType() != descriptor.FieldDescriptorProto_TYPE_MESSAGE &&
+		field.GetType() != descriptor.FieldDescriptorProto_TYPE_GROUP {
+		return "nil"
+	}
+	if !g.gen[g.idx.files[field.GetTypeName()].GetName()] {
+		return "nil"
+	}
+	name := g.imports.goTypeName(g.idx, field.GetTypeName())
+	i := strings.LastIndex(name, ".")
+	return name[:i+1] + "Valid" + name[i+1:] + "MaskPath"
+}
+
+type header struct {
+	Source  string
+	GoPkg   string
+	Imports map[string]string
+	Cut     bool
+}
+
+type pathMessage struct {
+	Name     string
+	Full     string
+	Descends bool
+	Fields   []*pathField
+	// Doc is the comment of the message, see protodoc.Index.Godoc.
+	Doc string
+}
+
+type pathField struct {
+	Proto string
+	Check string
+}
+
+// typeIndex maps fully-qualified proto type names (".pkg.Msg") to their
+// descriptors and declaring files for every file in the request.
+type typeIndex struct {
+	messages map[string]*descriptor.DescriptorProto
+	enums    map[string]*descriptor.EnumDescriptorProto
+	files    map[string]*descriptor.FileDescriptorProto
+}
+
+func newTypeIndex(files []*descriptor.FileDescriptorProto) *typeIndex {
+	idx := &typeIndex{
+		messages: make(map[string]*descriptor.DescriptorProto),
+		enums:    make(map[string]*descriptor.EnumDescriptorProto),
+		files:    make(map[string]*descriptor.FileDescriptorProto),
+	}
+	for _, f := range files {
+		prefix := ""
+		if f.GetPackage() != "" {
+			prefix = "." + f.GetPackage()
+		}
+		idx.addMessages(f, prefix, f.GetMessageType())
+		idx.addEnums(f, prefix, f.GetEnumType())
+	}
+	return idx
+}
+
+func (idx *typeIndex) addMessages(f *descriptor.FileDescriptorProto, prefix string, msgs []*descriptor.DescriptorProto) {
+	for _, m := range msgs {
+		name := prefix + "." + m.GetName()
+		idx.messages[name] = m
+		idx.files[name] = f
+		idx.addMessages(f, name, m.GetNestedType())
+		idx.addEnums(f, name, m.GetEnumType())
+	}
+}
+
+func (idx *typeIndex) addEnums(f *descriptor.FileDescriptorProto, prefix string, enums []*descriptor.EnumDescriptorProto) {
+	for _, e := range enums {
+		name := prefix + "." + e.GetName()
+		idx.enums[name] = e
+		idx.files[name] = f
+	}
+}
+
+// isMap reports whether field is a map field.
+func (idx *typeIndex) isMap(field *descriptor.FieldDescriptorProto) bool {
+	if field.GetType() != descriptor.FieldDescriptorProto_TYPE_MESSAGE {
+		return false
+	}
+	return idx.messages[field.GetTypeName()].GetOptions().GetMapEntry()
+}
+
+// goImportPath returns the import path of the Go package generated from f,
+// or "" if go_package does not name one.
+func goImportPath(f *descriptor.FileDescriptorProto) string {
+	gopkg := f.GetOptions().GetGoPackage()
+	if sc := strings.IndexByte(gopkg, ';'); sc >= 0 {
+		gopkg = gopkg[:sc]
+	}
+	if !strings.Contains(gopkg, "/") {
+		return ""
+	}
+	return gopkg
+}
+
+// importSet records the Go packages referenced by the code generated for
+// one file, keyed by import path.
+type importSet struct {
+	self  string
+	names map[string]string
+}
+
+func newImportSet(f *descriptor.FileDescriptorProto) *importSet {
+	return &importSet{self: goImportPath(f), names: make(map[string]string)}
+}
+
+// goTypeName returns the Go name of the message or enum typeName, qualified
+// with its package if it is declared outside the generated package.
+func (s *importSet) goTypeName(idx *typeIndex, typeName string) string {
+	name := localTypeName(typeName)
+	f := idx.files[typeName]
+	path := goImportPath(f)
+	if f == nil || path == "" || path == s.self {
+		return name
+	}
+	pkg := defaultGoPackageName(f)
+	s.names[path] = pkg
+	return pkg + "." + name
+}
+
+// localTypeName returns the Go name of a type declared in the file being
+// generated, e.g. ".pkg.Outer.Inner" becomes "Outer_Inner".
+func localTypeName(typeName string) string {
+	parts := strings.Split(strings.TrimPrefix(typeName, "."), ".")
+	var names []string
+	for _, p := range parts {
+		if len(p) > 0 && p[0] >= 'A' && p[0] <= 'Z' {
+			names = append(names, p)
+		}
+	}
+	return strings.Join(names, "_")
+}
+
+// camelCase returns the Go field name protoc-gen-go derives from s.
+func camelCase(s string) string {
+	if s == "" {
+		return ""
+	}
+	t := make([]byte, 0, 32)
+	i := 0
+	if s[0] == '_' {
+		// Need a capital letter; drop the '_'.
+		t = append(t, 'X')
+		i++
+	}
+	for ; i < len(s); i++ {
+		c := s[i]
+		if c == '_' && i+1 < len(s) && isASCIILower(s[i+1]) {
+			continue
+		}
+		if isASCIIDigit(c) {
+			t = append(t, c)
+			continue
+		}
+		if isASCIILower(c) {
+			c ^= ' '
+		}
+		t = append(t, c)
+		for i+1 < len(s) && isASCIILower(s[i+1]) {
+			i++
+			t = append(t, s[i])
+		}
+	}
+	return string(t)
+}
+
+func isASCIILower(c byte) bool {
+	return 'a' <= c && c <= 'z'
+}
+
+func isASCIIDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+// sanitizePackageName replaces unallowed character in package name
+// with allowed character.
+func sanitizePackageName(pkgName string) string {
+	pkgName = strings.Replace(pkgName, ".", "_", -1)
+	pkgName = strings.Replace(pkgName, "-", "_", -1)
+	return pkgName
+}
+
+// defaultGoPackageName returns the default go package name to be used for go files generated from "f".
+// You might need to use an unique alias for the package when you import it.  Use ReserveGoPackageAlias to get a unique alias.
+func defaultGoPackageName(f *descriptor.FileDescriptorProto) string {
+	name := packageIdentityName(f)
+	return sanitizePackageName(name)
+}
+
+// packageIdentityName returns the identity of packages.
+// protoc-gen-grpc-gateway rejects CodeGenerationRequests which contains more than one packages
+// as protoc-gen-go does.
+func packageIdentityName(f *descriptor.FileDescriptorProto) string {
+	if f.Options != nil && f.Options.GoPackage != nil {
+		gopkg := f.Options.GetGoPackage()
+		idx := strings.LastIndex(gopkg, "/")
+		if idx < 0 {
+			gopkg = gopkg[idx+1:]
+		}
+
+		gopkg = gopkg[idx+1:]
+		// package name is overrided with the string after the
+		// ';' character
+		sc := strings.IndexByte(gopkg, ';')
+		if sc < 0 {
+			return sanitizePackageName(gopkg)
+
+		}
+		return sanitizePackageName(gopkg[sc+1:])
+	}
+
+	if f.Package == nil {
+		base := filepath.Base(f.GetName())
+		ext := filepath.Ext(base)
+		return strings.TrimSuffix(base, ext)
+	}
+	return f.GetPackage()
+}
+
+func emitFiles(out []*plugin.CodeGeneratorResponse_File) {
+	emitResp(&plugin.CodeGeneratorResponse{File: out})
+}
+
+func emitError(err error) {
+	emitResp(&plugin.CodeGeneratorResponse{Error: proto.String(err.Error())})
+}
+
+func emitResp(resp *plugin.CodeGeneratorResponse) {
+	buf, err := proto.Marshal(resp)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if _, err := os.Stdout.Write(buf); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,529 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+
+	"github.com/f4tq/protoc-go-plugins/goname"
+	"github.com/f4tq/protoc-go-plugins/options"
+)
+
+var (
+	hdrTmpl = template.Must(template.New("header").Parse(`
+// Code generated by protoc-gen-go-merge. DO NOT EDIT.
+// source: {{.Source}}
+
+package {{.GoPkg}}
+
+import (
+{{- range .Std}}
+    "{{.}}"
+{{- end}}
+{{if .Imports}}
+{{- end}}
+{{- range $path, $name := .Imports}}
+    {{$name}} "{{$path}}"
+{{- end}}
+)
+`))
+
+	mergeTmpl = template.Must(template.New("merge").Parse(`
+// Merge overlays the fields set in src onto m. Scalars are copied when src
+// has them set, or non-zero if they have no presence; messages are merged
+// recursively; repeated and map fields are appended to, or replaced if
+// marked (f4tq.plugins.merge_replace). m must not be nil.
+func (m *{{.Name}}) Merge(src *{{.Name}}) {
+    if src == nil {
+        return
+    }
+{{- range .Fields}}
+{{.}}
+{{- end}}
+    m.XXX_unrecognized = append(m.XXX_unrecognized, src.XXX_unrecognized...)
+}
+`))
+)
+
+func main() {
+	input, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	req := new(plugin.CodeGeneratorRequest)
+	if err = proto.Unmarshal(input, req); err != nil {
+		log.Fatal(err)
+	}
+
+	out, err := generate(req)
+
+	if err != nil {
+		emitError(err)
+		return
+	}
+
+	emitFiles(out)
+}
+
+func generate(req *plugin.CodeGeneratorRequest) ([]*plugin.CodeGeneratorResponse_File, error) {
+	var files []*plugin.CodeGeneratorResponse_File
+	idx := newTypeIndex(req.GetProtoFile())
+	genFileNames := make(map[string]bool)
+	for _, n := range req.FileToGenerate {
+		genFileNames[n] = true
+	}
+	for _, desc := range req.GetProtoFile() {
+		name := desc.GetName()
+		if _, ok := genFileNames[name]; !ok {
+			// Only emit output for files present in req.FileToGenerate.
+			continue
+		}
+		code, err := genCode(desc, idx, genFileNames)
+		if err != nil {
+			return nil, err
+		}
+		if code == "" {
+			// The file declares no messages.
+			continue
+		}
+		formatted, err := format.Source([]byte(code))
+		if err != nil {
+			log.Printf("%v: %s", err, code)
+			return nil, err
+		}
+
+		ext := filepath.Ext(name)
+		base := strings.TrimSuffix(name, ext)
+		output := fmt.Sprintf("%s.pb.merge.go", base)
+		files = append(files, &plugin.CodeGeneratorResponse_File{
+			Name:    proto.String(output),
+			Content: proto.String(string(formatted)),
+		})
+	}
+
+	return files, nil
+}
+
+func genCode(desc *descriptor.FileDescriptorProto, idx *typeIndex, genFileNames map[string]bool) (string, error) {
+	w := bytes.NewBuffer(nil)
+	g := &mergeGen{
+		idx:     idx,
+		imports: newImportSet(desc),
+		gen:     genFileNames,
+		proto3:  desc.GetSyntax() == "proto3",
+		std:     make(map[string]bool),
+	}
+	body := bytes.NewBuffer(nil)
+	if err := g.messages(body, "", desc.GetMessageType()); err != nil {
+		return "", err
+	}
+	if body.Len() == 0 {
+		return "", nil
+	}
+
+	hdr := &header{
+		Source:  desc.GetName(),
+		GoPkg:   defaultGoPackageName(desc),
+		Imports: g.imports.names,
+	}
+	for path := range g.std {
+		hdr.Std = append(hdr.Std, path)
+	}
+	sort.Strings(hdr.Std)
+	if err := hdrTmpl.Execute(w, hdr); err != nil {
+		log.Fatal(err)
+	}
+	body.WriteTo(w)
+
+	return w.String(), nil
+}
+
+type mergeGen struct {
+	idx     *typeIndex
+	imports *importSet
+	gen     map[string]bool
+	proto3  bool
+	// std records the standard packages the generated code uses.
+	std map[string]bool
+}
+
+// messages writes the Merge methods of msgs and of the messages nested in
+// them. prefix is the Go name of the enclosing message plus "_".
+func (g *mergeGen) messages(w *bytes.Buffer, prefix string, msgs []*descriptor.DescriptorProto) error {
+	for _, msg := range msgs {
+		if msg.GetOptions().GetMapEntry() {
+			continue
+		}
+		name := prefix + msg.GetName()
+		m := &mergeMessage{Name: name}
+		goNames := goname.Fields(msg)
+		done := make(map[int32]bool)
+		for _, field := range msg.GetField() {
+			if field.OneofIndex != nil && !field.GetProto3Optional() {
+				i := field.GetOneofIndex()
+				if !done[i] {
+					done[i] = true
+					m.Fields = append(m.Fields, g.oneof(name, msg, i))
+				}
+				continue
+			}
+			m.Fields = append(m.Fields, g.field(field, goNames[field.GetName()]))
+		}
+		if err := mergeTmpl.Execute(w, m); err != nil {
+			return err
+		}
+		if err := g.messages(w, name+"_", msg.GetNestedType()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// field returns the statements merging the field named goName of src into
+// m.
+func (g *mergeGen) field(field *descriptor.FieldDescriptorProto, goName string) string {
+	dst, src := "m."+goName, "src."+goName
+	switch {
+	case g.idx.isMap(field):
+		value := g.idx.messages[field.GetTypeName()].GetField()[1]
+		var b strings.Builder
+		fmt.Fprintf(&b, "if len(%s) > 0 {\n", src)
+		if options.MergeReplace(field) {
+			fmt.Fprintf(&b, "%s = make(%s, len(%s))\n", dst, g.mapType(field), src)
+		} else {
+			fmt.Fprintf(&b, "if %s == nil {\n%s = make(%s, len(%s))\n}\n", dst, dst, g.mapType(field), src)
+		}
+		fmt.Fprintf(&b, "for k, v := range %s {\n", src)
+		if g.isMessage(value) {
+			fmt.Fprintf(&b, "w := new(%s)\n%s\n%s[k] = w\n", strings.TrimPrefix(g.goType(value), "*"), g.mergeMessage(value, "w", "v"), dst)
+		} else {
+			fmt.Fprintf(&b, "%s[k] = %s\n", dst, g.copyValue(value, "v"))
+		}
+		b.WriteString("}\n}")
+		return b.String()
+	case field.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REPEATED:
+		var b strings.Builder
+		fmt.Fprintf(&b, "if len(%s) > 0 {\n", src)
+		if options.MergeReplace(field) {
+			fmt.Fprintf(&b, "%s = make(%s, 0, len(%s))\n", dst, "[]"+g.goType(field), src)
+		}
+		switch {
+		case g.isMessage(field):
+			fmt.Fprintf(&b, "for _, v := range %s {\nw := new(%s)\n%s\n%s = append(%s, w)\n}\n",
+				src, strings.TrimPrefix(g.goType(field), "*"), g.mergeMessage(field, "w", "v"), dst, dst)
+		case field.GetType() == descriptor.FieldDescriptorProto_TYPE_BYTES:
+			fmt.Fprintf(&b, "for _, v := range %s {\n%s = append(%s, %s)\n}\n", src, dst, dst, g.copyValue(field, "v"))
+		default:
+			fmt.Fprintf(&b, "%s = append(%s, %s...)\n", dst, dst, src)
+		}
+		b.WriteString("}")
+		return b.String()
+	case g.isMessage(field):
+		return fmt.Sprintf("if %s != nil {\nif %s == nil {\n%s = new(%s)\n}\n%s\n}",
+			src, dst, dst, strings.TrimPrefix(g.goType(field), "*"), g.mergeMessage(field, dst, src))
+	case field.GetProto3Optional() || !g.proto3:
+		// Scalars are pointers; bytes are nil when unset.
+		if field.GetType() == descriptor.FieldDescriptorProto_TYPE_BYTES {
+			return fmt.Sprintf("if %s != nil {\n%s = %s\n}", src, dst, g.copyValue(field, src))
+		}
+		return fmt.Sprintf("if %s != nil {\nv := *%s\n%s = &v\n}", src, src, dst)
+	}
+	return fmt.Sprintf("if %s {\n%s = %s\n}", g.isSet(field, src), dst, g.copyValue(field, src))
+}
+
+// isSet returns the condition under which the proto3 scalar v differs from
+// its default value.
+func (g *mergeGen) isSet(field *descriptor.FieldDescriptorProto, v string) string {
+	switch field.GetType() {
+	case descriptor.FieldDescriptorProto_TYPE_BOOL:
+		return v
+	case descriptor.FieldDescriptorProto_TYPE_STRING:
+		return v + ` != ""`
+	case descriptor.FieldDescriptorProto_TYPE_BYTES:
+		return "len(" + v + ") > 0"
+	}
+	return v + " != 0"
+}
+
+func (g *mergeGen) isMessage(field *descriptor.FieldDescriptorProto) bool {
+	return field.GetType() == descriptor.FieldDescriptorProto_TYPE_MESSAGE ||
+		field.GetType() == descriptor.FieldDescriptorProto_TYPE_GROUP
+}
+
+// copyValue returns an expression copying the scalar value v of field.
+func (g *mergeGen) copyValue(field *descriptor.FieldDescriptorProto, v string) string {
+	if field.GetType() == descriptor.FieldDescriptorProto_TYPE_BYTES {
+		g.std["bytes"] = true
+		return fmt.Sprintf("bytes.Clone(%s)", v)
+	}
+	return v
+}
+
+// mergeMessage returns the statement merging the message src of field into
+// dst, which is not nil.
+func (g *mergeGen) mergeMessage(field *descriptor.FieldDescriptorProto, dst, src string) string {
+	if g.gen[g.idx.files[field.GetTypeName()].GetName()] {
+		return fmt.Sprintf("%s.Merge(%s)", dst, src)
+	}
+	// Messages of other packages have no Merge method.
+	g.imports.names["github.com/golang/protobuf/proto"] = "proto"
+	return fmt.Sprintf("proto.Merge(%s, %s)", dst, src)
+}
+
+// goType returns the Go type of a value of field.
+func (g *mergeGen) goType(field *descriptor.FieldDescriptorProto) string {
+	switch field.GetType() {
+	case descriptor.FieldDescriptorProto_TYPE_MESSAGE, descriptor.FieldDescriptorProto_TYPE_GROUP:
+		return "*" + g.imports.goTypeName(g.idx, field.GetTypeName())
+	case descriptor.FieldDescriptorProto_TYPE_ENUM:
+		return g.imports.goTypeName(g.idx, field.GetTypeName())
+	case descriptor.FieldDescriptorProto_TYPE_BYTES:
+		return "[]byte"
+	}
+	return scalarGoType(field.GetType())
+}
+
+// mapType returns the Go type of the map field.
+func (g *mergeGen) mapType(field *descriptor.FieldDescriptorProto) string {
+	entry := g.idx.messages[field.GetTypeName()]
+	return fmt.Sprintf("map[%s]%s", g.goType(entry.GetField()[0]), g.goType(entry.GetField()[1]))
+}
+
+// oneof returns the statements merging the oneof i of msg. A message
+// member is merged into the same member of m, and replaces any other.
+func (g *mergeGen) oneof(msgName string, msg *descriptor.DescriptorProto, i int32) string {
+	goNames := goname.Fields(msg)
+	oneof := goNames[msg.GetOneofDecl()[i].GetName()]
+	var b strings.Builder
+	fmt.Fprintf(&b, "switch v := src.%s.(type) {\n", oneof)
+	for _, field := range msg.GetField() {
+		if field.OneofIndex == nil || field.GetOneofIndex() != i || field.GetProto3Optional() {
+			continue
+		}
+		goName := goNames[field.GetName()]
+		wrapper := msgName + "_" + goName
+		fmt.Fprintf(&b, "case *%s:\n", wrapper)
+		if !g.isMessage(field) {
+			fmt.Fprintf(&b, "m.%s = &%s{%s: %s}\n", oneof, wrapper, goName, g.copyValue(field, "v."+goName))
+			continue
+		}
+		fmt.Fprintf(&b, "if v.%s == nil {\nm.%s = &%s{}\nbreak\n}\n", goName, oneof, wrapper)
+		fmt.Fprintf(&b, "w, ok := m.%s.(*%s)\nif !ok || w.%s == nil {\nw = &%s{%s: new(%s)}\nm.%s = w\n}\n",
+			oneof, wrapper, goName, wrapper, goName, strings.TrimPrefix(g.goType(field), "*"), oneof)
+		fmt.Fprintf(&b, "%s\n", g.mergeMessage(field, "w."+goName, "v."+goName))
+	}
+	b.WriteString("}")
+	return b.String()
+}
+
+// scalarGoType returns the Go type of the scalar type t.
+func scalarGoType(t descriptor.FieldDescriptorProto_Type) string {
+	switch t {
+	case descriptor.FieldDescriptorProto_TYPE_DOUBLE:
+		return "float64"
+	case descriptor.FieldDescriptorProto_TYPE_FLOAT:
+		return "float32"
+	case descriptor.FieldDescriptorProto_TYPE_INT64, descriptor.FieldDescriptorProto_TYPE_SINT64,
+		descriptor.FieldDescriptorProto_TYPE_SFIXED64:
+		return "int64"
+	case descriptor.FieldDescriptorProto_TYPE_UINT64, descriptor.FieldDescriptorProto_TYPE_FIXED64:
+		return "uint64"
+	case descriptor.FieldDescriptorProto_TYPE_INT32, descriptor.FieldDescriptorProto_TYPE_SINT32,
+		descriptor.FieldDescriptorProto_TYPE_SFIXED32:
+		return "int32"
+	case descriptor.FieldDescriptorProto_TYPE_UINT32, descriptor.FieldDescriptorProto_TYPE_FIXED32:
+		return "uint32"
+	case descriptor.FieldDescriptorProto_TYPE_BOOL:
+		return "bool"
+	}
+	return "string"
+}
+
+type header struct {
+	Source  string
+	GoPkg   string
+	Std     []string
+	Imports map[string]string
+}
+
+type mergeMessage struct {
+	Name   string
+	Fields []string
+}
+
+// typeIndex maps fully-qualified proto type names (".pkg.Msg") to their
+// descriptors and declaring files for every file in the request.
+type typeIndex struct {
+	messages map[string]*descriptor.DescriptorProto
+	enums    map[string]*descriptor.EnumDescriptorProto
+	files    map[string]*descriptor.FileDescriptorProto
+}
+
+func newTypeIndex(files []*descriptor.FileDescriptorProto) *typeIndex {
+	idx := &typeIndex{
+		messages: make(map[string]*descriptor.DescriptorProto),
+		enums:    make(map[string]*descriptor.EnumDescriptorProto),
+		files:    make(map[string]*descriptor.FileDescriptorProto),
+	}
+	for _, f := range files {
+		prefix := ""
+		if f.GetPackage() != "" {
+			prefix = "." + f.GetPackage()
+		}
+		idx.addMessages(f, prefix, f.GetMessageType())
+		idx.addEnums(f, prefix, f.GetEnumType())
+	}
+	return idx
+}
+
+func (idx *typeIndex) addMessages(f *descriptor.FileDescriptorProto, prefix string, msgs []*descriptor.DescriptorProto) {
+	for _, m := range msgs {
+		name := prefix + "." + m.GetName()
+		idx.messages[name] = m
+		idx.files[name] = f
+		idx.addMessages(f, name, m.GetNestedType())
+		idx.addEnums(f, name, m.GetEnumType())
+	}
+}
+
+func (idx *typeIndex) addEnums(f *descriptor.FileDescriptorProto, prefix string, enums []*descriptor.EnumDescriptorProto) {
+	for _, e := range enums {
+		name := prefix + "." + e.GetName()
+		idx.enums[name] = e
+		idx.files[name] = f
+	}
+}
+
+// isMap reports whether field is a map field.
+func (idx *typeIndex) isMap(field *descriptor.FieldDescriptorProto) bool {
+	if field.GetType() != descriptor.FieldDescriptorProto_TYPE_MESSAGE {
+		return false
+	}
+	return idx.messages[field.GetTypeName()].GetOptions().GetMapEntry()
+}
+
+// goImportPath returns the import path of the Go package generated from f,
+// or "" if go_package does not name one.
+func goImportPath(f *descriptor.FileDescriptorProto) string {
+	gopkg := f.GetOptions().GetGoPackage()
+	if sc := strings.IndexByte(gopkg, ';'); sc >= 0 {
+		gopkg = gopkg[:sc]
+	}
+	if !strings.Contains(gopkg, "/") {
+		return ""
+	}
+	return gopkg
+}
+
+// importSet records the Go packages referenced by the code generated for
+// one file, keyed by import path.
+type importSet struct {
+	self  string
+	names map[string]string
+}
+
+func newImportSet(f *descriptor.FileDescriptorProto) *importSet {
+	return &importSet{self: goImportPath(f), names: make(map[string]string)}
+}
+
+// goTypeName returns the Go name of the message or enum typeName, qualified
+// with its package if it is declared outside the generated package.
+func (s *importSet) goTypeName(idx *typeIndex, typeName string) string {
+	name := localTypeName(typeName)
+	f := idx.files[typeName]
+	path := goImportPath(f)
+	if f == nil || path == "" || path == s.self {
+		return name
+	}
+	pkg := defaultGoPackageName(f)
+	s.names[path] = pkg
+	return pkg + "." + name
+}
+
+// localTypeName returns the Go name of a type declared in the file being
+// generated, e.g. ".pkg.Outer.Inner" becomes "Outer_Inner".
+func localTypeName(typeName string) string {
+	parts := strings.Split(strings.TrimPrefix(typeName, "."), ".")
+	var names []string
+	for _, p := range parts {
+		if len(p) > 0 && p[0] >= 'A' && p[0] <= 'Z' {
+			names = append(names, p)
+		}
+	}
+	return strings.Join(names, "_")
+}
+
+// sanitizePackageName replaces unallowed character in package name
+// with allowed character.
+func sanitizePackageName(pkgName string) string {
+	pkgName = strings.Replace(pkgName, ".", "_", -1)
+	pkgName = strings.Replace(pkgName, "-", "_", -1)
+	return pkgName
+}
+
+// defaultGoPackageName returns the default go package name to be used for go files generated from "f".
+// You might need to use an unique alias for the package when you import it.  Use ReserveGoPackageAlias to get a unique alias.
+func defaultGoPackageName(f *descriptor.FileDescriptorProto) string {
+	name := packageIdentityName(f)
+	return sanitizePackageName(name)
+}
+
+// packageIdentityName returns the identity of packages.
+// protoc-gen-grpc-gateway rejects CodeGenerationRequests which contains more than one packages
+// as protoc-gen-go does.
+func packageIdentityName(f *descriptor.FileDescriptorProto) string {
+	if f.Options != nil && f.Options.GoPackage != nil {
+		gopkg := f.Options.GetGoPackage()
+		idx := strings.LastIndex(gopkg, "/")
+		if idx < 0 {
+			gopkg = gopkg[idx+1:]
+		}
+
+		gopkg = gopkg[idx+1:]
+		// package name is overrided with the string after the
+		// ';' character
+		sc := strings.IndexByte(gopkg, ';')
+		if sc < 0 {
+			return sanitizePackageName(gopkg)
+
+		}
+		return sanitizePackageName(gopkg[sc+1:])
+	}
+
+	if f.Package == nil {
+		base := filepath.Base(f.GetName())
+		ext := filepath.Ext(base)
+		return strings.TrimSuffix(base, ext)
+	}
+	return f.GetPackage()
+}
+
+func emitFiles(out []*plugin.CodeGeneratorResponse_File) {
+	emitResp(&plugin.CodeGeneratorResponse{File: out})
+}
+
+func emitError(err error) {
+	emitResp(&plugin.CodeGeneratorResponse{Error: proto.String(err.Error())})
+}
+
+func emitResp(resp *plugin.CodeGeneratorResponse) {
+	buf, err := proto.Marshal(resp)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if _, err := os.Stdout.Write(buf); err != nil {
+		log.Fatal(err)
+	}
+}
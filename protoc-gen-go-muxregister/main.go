@@ -0,0 +1,875 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+
+	"github.com/f4tq/protoc-go-plugins/goname"
+	"github.com/f4tq/protoc-go-plugins/httprule"
+	"github.com/f4tq/protoc-go-plugins/protodoc"
+)
+
+var (
+	hdrTmpl = template.Must(template.New("header").Parse(`
+// Code generated by protoc-gen-go-muxregister. DO NOT EDIT.
+// source: {{.Source}}
+
+package {{.GoPkg}}
+
+import (
+    "context"
+    "net/http"
+{{- if .NeedsProto}}
+
+    "github.com/golang/protobuf/proto"
+{{- end}}
+{{- range $path, $name := .Imports}}
+    {{$name}} "{{$path}}"
+{{- end}}
+)
+`))
+
+	serviceTmpl = template.Must(template.New("service").Parse(`
+// {{.Name}}Handler is the part of {{.Name}} with google.api.http bindings.
+// The {{.Name}}Server interface generated for gRPC satisfies it.{{.Doc}}
+type {{.Name}}Handler interface {
+{{- range .Methods}}
+    {{.Name}}(context.Context, *{{.Input}}) (*{{.Output}}, error)
+{{- end}}
+}
+
+// Register{{.Name}}Routes registers the HTTP bindings of {{.Name}} on mux
+// with Go 1.22 method and wildcard patterns. Like mux.Handle, it panics if a
+// pattern conflicts with one already registered.{{.Doc}}
+func Register{{.Name}}Routes(mux *http.ServeMux, impl {{.Name}}Handler) {
+{{- range .Routes}}
+    mux.HandleFunc({{printf "%q" .Pattern}}, func(w http.ResponseWriter, r *http.Request) {
+        req := new({{.Input}})
+        if err := {{.BindFunc}}(r, req); err != nil {
+            muxWriteError(w, err)
+            return
+        }
+        resp, err := impl.{{.Method}}(r.Context(), req)
+        if err != nil {
+            muxWriteError(w, err)
+            return
+        }
+        {{.Respond}}
+    })
+{{- end}}
+}
+{{range .Routes}}
+// {{.BindFunc}} binds the path variables, query parameters and body of a
+// {{.HTTPMethod}} {{.Template}} request to req.
+func {{.BindFunc}}(r *http.Request, req *{{.Input}}) error {
+{{- .Bind}}
+    return nil
+}
+{{end}}
+`))
+
+	// helpersTmpl is written once per directory, to muxregister.pb.go, so
+	// that the files of a package share it.
+	helpersTmpl = template.Must(template.New("helpers").Parse(`
+// Code generated by protoc-gen-go-muxregister. DO NOT EDIT.
+
+package {{.}}
+
+import (
+    "bytes"
+    "encoding/base64"
+    "encoding/json"
+    "fmt"
+    "io/ioutil"
+    "net/http"
+    "strconv"
+
+    "github.com/golang/protobuf/jsonpb"
+    "github.com/golang/protobuf/proto"
+)
+
+// muxError is an error with an HTTP status.
+type muxError struct {
+    status  int
+    message string
+}
+
+func (e *muxError) Error() string {
+    return e.message
+}
+
+// HTTPStatus returns the HTTP status of e.
+func (e *muxError) HTTPStatus() int {
+    return e.status
+}
+
+func muxBadRequest(format string, args ...interface{}) error {
+    return &muxError{http.StatusBadRequest, fmt.Sprintf(format, args...)}
+}
+
+// muxWriteError writes err as a JSON object with "code" and "message". The
+// status is taken from an HTTPStatus() int method of err, and is 500 for
+// other errors.
+func muxWriteError(w http.ResponseWriter, err error) {
+    status := http.StatusInternalServerError
+    if s, ok := err.(interface{ HTTPStatus() int }); ok {
+        status = s.HTTPStatus()
+    }
+    body, _ := json.Marshal(struct {
+        Code    int    ` + "`json:\"code\"`" + `
+        Message string ` + "`json:\"message\"`" + `
+    }{status, err.Error()})
+    w.Header().Set("Content-Type", "application/json")
+    w.WriteHeader(status)
+    w.Write(body)
+}
+
+// muxDecode unmarshals the JSON request body, if any, into msg.
+func muxDecode(r *http.Request, msg proto.Message) error {
+    body, err := ioutil.ReadAll(r.Body)
+    if err != nil {
+        return muxBadRequest("reading body: %v", err)
+    }
+    if len(bytes.TrimSpace(body)) == 0 {
+        return nil
+    }
+    if err := jsonpb.Unmarshal(bytes.NewReader(body), msg); err != nil {
+        return muxBadRequest("decoding body: %v", err)
+    }
+    return nil
+}
+
+// muxRespond writes msg as the JSON response.
+func muxRespond(w http.ResponseWriter, msg proto.Message) {
+    var buf bytes.Buffer
+    if err := new(jsonpb.Marshaler).Marshal(&buf, msg); err != nil {
+        muxWriteError(w, err)
+        return
+    }
+    w.Header().Set("Content-Type", "application/json")
+    w.Write(buf.Bytes())
+}
+
+// muxRespondList writes msgs as a JSON array, for response_body bindings
+// naming a repeated field.
+func muxRespondList(w http.ResponseWriter, msgs []proto.Message) {
+    var buf bytes.Buffer
+    buf.WriteByte('[')
+    for i, msg := range msgs {
+        if i > 0 {
+            buf.WriteByte(',')
+        }
+        if err := new(jsonpb.Marshaler).Marshal(&buf, msg); err != nil {
+            muxWriteError(w, err)
+            return
+        }
+    }
+    buf.WriteByte(']')
+    w.Header().Set("Content-Type", "application/json")
+    w.Write(buf.Bytes())
+}
+
+func muxBool(s string) (bool, error) {
+    v, err := strconv.ParseBool(s)
+    if err != nil {
+        return false, muxBadRequest("invalid bool %q", s)
+    }
+    return v, nil
+}
+
+func muxInt32(s string) (int32, error) {
+    v, err := strconv.ParseInt(s, 10, 32)
+    if err != nil {
+        return 0, muxBadRequest("invalid int32 %q", s)
+    }
+    return int32(v), nil
+}
+
+func muxInt64(s string) (int64, error) {
+    v, err := strconv.ParseInt(s, 10, 64)
+    if err != nil {
+        return 0, muxBadRequest("invalid int64 %q", s)
+    }
+    return v, nil
+}
+
+func muxUint32(s string) (uint32, error) {
+    v, err := strconv.ParseUint(s, 10, 32)
+    if err != nil {
+        return 0, muxBadRequest("invalid uint32 %q", s)
+    }
+    return uint32(v), nil
+}
+
+func muxUint64(s string) (uint64, error) {
+    v, err := strconv.ParseUint(s, 10, 64)
+    if err != nil {
+        return 0, muxBadRequest("invalid uint64 %q", s)
+    }
+    return v, nil
+}
+
+func muxFloat32(s string) (float32, error) {
+    v, err := strconv.ParseFloat(s, 32)
+    if err != nil {
+        return 0, muxBadRequest("invalid float %q", s)
+    }
+    return float32(v), nil
+}
+
+func muxFloat64(s string) (float64, error) {
+    v, err := strconv.ParseFloat(s, 64)
+    if err != nil {
+        return 0, muxBadRequest("invalid double %q", s)
+    }
+    return v, nil
+}
+
+// muxBytes decodes standard or URL-safe base64.
+func muxBytes(s string) ([]byte, error) {
+    if v, err := base64.StdEncoding.DecodeString(s); err == nil {
+        return v, nil
+    }
+    v, err := base64.URLEncoding.DecodeString(s)
+    if err != nil {
+        return nil, muxBadRequest("invalid base64 %q", s)
+    }
+    return v, nil
+}
+
+// muxEnum parses an enum value given by name or number.
+func muxEnum(s string, values map[string]int32) (int32, error) {
+    if v, ok := values[s]; ok {
+        return v, nil
+    }
+    v, err := strconv.ParseInt(s, 10, 32)
+    if err != nil {
+        return 0, muxBadRequest("invalid enum value %q", s)
+    }
+    return int32(v), nil
+}
+`))
+)
+
+func main() {
+	input, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	req := new(plugin.CodeGeneratorRequest)
+	if err = proto.Unmarshal(input, req); err != nil {
+		log.Fatal(err)
+	}
+
+	out, err := generate(req)
+
+	if err != nil {
+		emitError(err)
+		return
+	}
+
+	emitFiles(out)
+}
+
+func generate(req *plugin.CodeGeneratorRequest) ([]*plugin.CodeGeneratorResponse_File, error) {
+	var files []*plugin.CodeGeneratorResponse_File
+	idx := newTypeIndex(req.GetProtoFile())
+	docs := protodoc.New(req.GetProtoFile())
+	genFileNames := make(map[string]bool)
+	for _, n := range req.FileToGenerate {
+		genFileNames[n] = true
+	}
+	// helpers maps the directories written to to their package names.
+	helpers := make(map[string]string)
+	var dirs []string
+	for _, desc := range req.GetProtoFile() {
+		name := desc.GetName()
+		if _, ok := genFileNames[name]; !ok {
+			// Only emit output for files present in req.FileToGenerate.
+			continue
+		}
+		code, err := genCode(desc, idx, docs)
+		if err != nil {
+			return nil, err
+		}
+		if code == "" {
+			// No method has google.api.http bindings.
+			continue
+		}
+		formatted, err := format.Source([]byte(code))
+		if err != nil {
+			log.Printf("%v: %s", err, code)
+			return nil, err
+		}
+
+		ext := filepath.Ext(name)
+		base := strings.TrimSuffix(name, ext)
+		output := fmt.Sprintf("%s.pb.muxregister.go", base)
+		files = append(files, &plugin.CodeGeneratorResponse_File{
+			Name:    proto.String(output),
+			Content: proto.String(string(formatted)),
+		})
+		dir := path.Dir(name)
+		if _, ok := helpers[dir]; !ok {
+			dirs = append(dirs, dir)
+		}
+		helpers[dir] = defaultGoPackageName(desc)
+	}
+	for _, dir := range dirs {
+		var buf bytes.Buffer
+		if err := helpersTmpl.Execute(&buf, helpers[dir]); err != nil {
+			return nil, err
+		}
+		formatted, err := format.Source(buf.Bytes())
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, &plugin.CodeGeneratorResponse_File{
+			Name:    proto.String(path.Join(dir, "muxregister.pb.go")),
+			Content: proto.String(string(formatted)),
+		})
+	}
+
+	return files, nil
+}
+
+// genCode returns the HTTP handlers of the services in desc, or "" if no
+// method has google.api.http bindings.
+func genCode(desc *descriptor.FileDescriptorProto, idx *typeIndex, docs *protodoc.Index) (string, error) {
+	w := bytes.NewBuffer(nil)
+	g := &muxGen{idx: idx, imports: newImportSet(desc)}
+	body := bytes.NewBuffer(nil)
+	for _, svc := range desc.GetService() {
+		s, err := g.service(svc)
+		if err != nil {
+			return "", err
+		}
+		if len(s.Routes) == 0 {
+			continue
+		}
+		s.Doc = docs.Godoc(qualify(desc.GetPackage(), svc.GetName()))
+		if err := serviceTmpl.Execute(body, s); err != nil {
+			return "", err
+		}
+	}
+	if body.Len() == 0 {
+		return "", nil
+	}
+
+	hdr := &header{
+		Source:     desc.GetName(),
+		GoPkg:      defaultGoPackageName(desc),
+		Imports:    g.imports.names,
+		NeedsProto: g.needsProto,
+	}
+	if err := hdrTmpl.Execute(w, hdr); err != nil {
+		log.Fatal(err)
+	}
+	body.WriteTo(w)
+
+	return w.String(), nil
+}
+
+type header struct {
+	Source     string
+	GoPkg      string
+	Imports    map[string]string
+	NeedsProto bool
+}
+
+type muxService struct {
+	Name    string
+	Methods []*muxMethod
+	Routes  []*muxRoute
+	// Doc is the comment of the service, see protodoc.Index.Godoc.
+	Doc string
+}
+
+type muxMethod struct {
+	Name   string
+	Input  string
+	Output string
+}
+
+type muxRoute struct {
+	Pattern    string
+	Method     string
+	HTTPMethod string
+	Template   string
+	Input      string
+	BindFunc   string
+	Bind       string
+	Respond    string
+}
+
+type muxGen struct {
+	idx        *typeIndex
+	imports    *importSet
+	needsProto bool
+}
+
+func (g *muxGen) service(svc *descriptor.ServiceDescriptorProto) (*muxService, error) {
+	s := &muxService{Name: svc.GetName()}
+	for _, m := range svc.GetMethod() {
+		bindings, err := httprule.Bindings(m)
+		if err != nil {
+			return nil, err
+		}
+		if len(bindings) == 0 {
+			continue
+		}
+		if m.GetClientStreaming() || m.GetServerStreaming() {
+			log.Printf("%s.%s: streaming methods are not served over HTTP", svc.GetName(), m.GetName())
+			continue
+		}
+		method := &muxMethod{
+			Name:   m.GetName(),
+			Input:  g.imports.goTypeName(g.idx, m.GetInputType()),
+			Output: g.imports.goTypeName(g.idx, m.GetOutputType()),
+		}
+		for i, b := range bindings {
+			pattern, vars, err := servePattern(b)
+			if err != nil {
+				log.Printf("%s.%s: %v", svc.GetName(), m.GetName(), err)
+				continue
+			}
+			r := &muxRoute{
+				Pattern:    pattern,
+				Method:     m.GetName(),
+				HTTPMethod: b.Method,
+				Template:   b.Template,
+				Input:      method.Input,
+				BindFunc:   fmt.Sprintf("muxBind%s%s%d", svc.GetName(), m.GetName(), i),
+				Respond:    "muxRespond(w, resp)",
+			}
+			bind, err := g.bind(m, b, vars)
+			if err != nil {
+				return nil, fmt.Errorf("%s.%s: %v", svc.GetName(), m.GetName(), err)
+			}
+			r.Bind = bind
+			if b.ResponseBody != "" {
+				out := g.idx.messages[m.GetOutputType()]
+				field := findField(out, b.ResponseBody)
+				if field == nil || field.GetType() != descriptor.FieldDescriptorProto_TYPE_MESSAGE || g.idx.isMap(field) {
+					return nil, fmt.Errorf("%s.%s: response_body %q must name a message field", svc.GetName(), m.GetName(), b.ResponseBody)
+				}
+				get := "resp.Get" + goname.Fields(out)[b.ResponseBody] + "()"
+				if field.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REPEATED {
+					g.needsProto = true
+					r.Respond = fmt.Sprintf("list := make([]proto.Message, len(%[1]s))\nfor i, m := range %[1]s {\nlist[i] = m\n}\nmuxRespondList(w, list)", get)
+				} else {
+					r.Respond = fmt.Sprintf("muxRespond(w, %s)", get)
+				}
+			}
+			s.Routes = append(s.Routes, r)
+		}
+		if len(s.Routes) > 0 && s.Routes[len(s.Routes)-1].Method == m.GetName() {
+			s.Methods = append(s.Methods, method)
+		}
+	}
+	return s, nil
+}
+
+// bind returns the statements binding an HTTP request to req according to
+// b. vars holds the expressions of the path variables.
+func (g *muxGen) bind(m *descriptor.MethodDescriptorProto, b *httprule.Binding, vars map[string]string) (string, error) {
+	in := g.idx.messages[m.GetInputType()]
+	w := bytes.NewBuffer(nil)
+	bound := make(map[string]bool)
+	switch b.Body {
+	case "":
+	case "*":
+		w.WriteString("\nif err := muxDecode(r, req); err != nil {\nreturn err\n}")
+	default:
+		field := findField(in, b.Body)
+		if field == nil || field.GetType() != descriptor.FieldDescriptorProto_TYPE_MESSAGE ||
+			field.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REPEATED {
+			return "", fmt.Errorf("body %q must name a singular message field", b.Body)
+		}
+		goName := goname.Fields(in)[field.GetName()]
+		fmt.Fprintf(w, "\nreq.%s = new(%s)\nif err := muxDecode(r, req.%s); err != nil {\nreturn err\n}",
+			goName, g.imports.goTypeName(g.idx, field.GetTypeName()), goName)
+		bound[b.Body] = true
+	}
+
+	for _, v := range b.Pattern.Vars() {
+		stmts, err := g.assign("req", m.GetInputType(), strings.Split(v, "."), vars[v])
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(w, "\n{\n%s\n}", stmts)
+		bound[strings.Split(v, ".")[0]] = true
+	}
+
+	if b.Body == "*" {
+		return w.String(), nil
+	}
+	var cases []string
+	for _, field := range in.GetField() {
+		if bound[field.GetName()] || field.GetType() == descriptor.FieldDescriptorProto_TYPE_MESSAGE ||
+			field.GetType() == descriptor.FieldDescriptorProto_TYPE_GROUP {
+			continue
+		}
+		names := fmt.Sprintf("%q", field.GetName())
+		if json := field.GetJsonName(); json != "" && json != field.GetName() {
+			names += fmt.Sprintf(", %q", json)
+		}
+		if field.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REPEATED {
+			conv, expr := g.convert(field, "s")
+			goName := goname.Fields(in)[field.GetName()]
+			if conv != "" {
+				conv += "\n"
+			}
+			cases = append(cases, fmt.Sprintf("case %s:\nfor _, s := range values {\n%sreq.%s = append(req.%s, %s)\n}", names, conv, goName, goName, expr))
+			continue
+		}
+		stmts, err := g.assign("req", m.GetInputType(), []string{field.GetName()}, "values[len(values)-1]")
+		if err != nil {
+			return "", err
+		}
+		cases = append(cases, fmt.Sprintf("case %s:\n%s", names, stmts))
+	}
+	if len(cases) > 0 {
+		fmt.Fprintf(w, "\nfor key, values := range r.URL.Query() {\nif len(values) == 0 {\ncontinue\n}\nswitch key {\n%s\n}\n}", strings.Join(cases, "\n"))
+	}
+	return w.String(), nil
+}
+
+// assign returns the statements setting the field at path, relative to the
+// message expression target of type typeName, to the string expression src.
+// Intermediate messages are allocated as needed.
+func (g *muxGen) assign(target, typeName string, path []string, src string) (string, error) {
+	f, err := httprule.ResolveField(g, target, typeName, path)
+	if err != nil {
+		return "", err
+	}
+	w := bytes.NewBufferString(f.Alloc)
+	conv, expr := g.convert(f.Desc, src)
+	if conv != "" {
+		w.WriteString(conv + "\n")
+	}
+	w.WriteString(f.Set(expr))
+	return w.String(), nil
+}
+
+// Message implements httprule.Types.
+func (g *muxGen) Message(typeName string) (*descriptor.DescriptorProto, *descriptor.FileDescriptorProto) {
+	return g.idx.messages[typeName], g.idx.files[typeName]
+}
+
+// GoType implements httprule.Types.
+func (g *muxGen) GoType(typeName string) string {
+	return g.imports.goTypeName(g.idx, typeName)
+}
+
+var convertFuncs = map[descriptor.FieldDescriptorProto_Type]string{
+	descriptor.FieldDescriptorProto_TYPE_BOOL:     "Bool",
+	descriptor.FieldDescriptorProto_TYPE_BYTES:    "Bytes",
+	descriptor.FieldDescriptorProto_TYPE_INT32:    "Int32",
+	descriptor.FieldDescriptorProto_TYPE_SINT32:   "Int32",
+	descriptor.FieldDescriptorProto_TYPE_SFIXED32: "Int32",
+	descriptor.FieldDescriptorProto_TYPE_INT64:    "Int64",
+	descriptor.FieldDescriptorProto_TYPE_SINT64:   "Int64",
+	descriptor.FieldDescriptorProto_TYPE_SFIXED64: "Int64",
+	descriptor.FieldDescriptorProto_TYPE_UINT32:   "Uint32",
+	descriptor.FieldDescriptorProto_TYPE_FIXED32:  "Uint32",
+	descriptor.FieldDescriptorProto_TYPE_UINT64:   "Uint64",
+	descriptor.FieldDescriptorProto_TYPE_FIXED64:  "Uint64",
+	descriptor.FieldDescriptorProto_TYPE_FLOAT:    "Float32",
+	descriptor.FieldDescriptorProto_TYPE_DOUBLE:   "Float64",
+}
+
+// convert returns the statements parsing the string expression src into v
+// for a scalar or enum field, and the expression of the converted value.
+func (g *muxGen) convert(field *descriptor.FieldDescriptorProto, src string) (string, string) {
+	const check = "\nif err != nil {\nreturn err\n}"
+	switch field.GetType() {
+	case descriptor.FieldDescriptorProto_TYPE_STRING:
+		return "", src
+	case descriptor.FieldDescriptorProto_TYPE_ENUM:
+		enum := g.imports.goTypeName(g.idx, field.GetTypeName())
+		return fmt.Sprintf("v, err := muxEnum(%s, %s_value)%s", src, enum, check), enum + "(v)"
+	}
+	return fmt.Sprintf("v, err := mux%s(%s)%s", convertFuncs[field.GetType()], src, check), "v"
+}
+
+// servePattern returns the http.ServeMux pattern of b, e.g.
+// "GET /v1/users/{name0}" for "/v1/{name=users/*}", and the expressions of
+// its variables keyed by field path. Variables capturing "**" become
+// trailing "{x...}" wildcards; a custom verb is only supported after a
+// literal segment.
+func servePattern(b *httprule.Binding) (string, map[string]string, error) {
+	segs := b.Pattern.Segments()
+	names := b.Pattern.Vars()
+	wildcards := make([]int, len(names))
+	for _, seg := range segs {
+		if seg.Var >= 0 && seg.Literal == "" {
+			wildcards[seg.Var]++
+		}
+	}
+	var pattern bytes.Buffer
+	pattern.WriteString(b.Method + " ")
+	// parts holds the literal text and wildcards of each variable.
+	parts := make([][]string, len(names))
+	for i, seg := range segs {
+		pattern.WriteByte('/')
+		if seg.Literal != "" {
+			pattern.WriteString(seg.Literal)
+			if seg.Var >= 0 {
+				parts[seg.Var] = append(parts[seg.Var], strconv.Quote(seg.Literal))
+			}
+			continue
+		}
+		wildcard := fmt.Sprintf("seg%d", i)
+		if seg.Var >= 0 {
+			wildcard = strings.Replace(names[seg.Var], ".", "_", -1)
+			if wildcards[seg.Var] > 1 {
+				wildcard += strconv.Itoa(len(parts[seg.Var]))
+			}
+			parts[seg.Var] = append(parts[seg.Var], fmt.Sprintf("r.PathValue(%q)", wildcard))
+		}
+		if seg.Deep {
+			if i != len(segs)-1 {
+				return "", nil, fmt.Errorf("%s: ** must be the last segment", b.Template)
+			}
+			wildcard += "..."
+		}
+		fmt.Fprintf(&pattern, "{%s}", wildcard)
+	}
+	if verb := b.Pattern.Verb(); verb != "" {
+		if len(segs) == 0 || segs[len(segs)-1].Literal == "" {
+			return "", nil, fmt.Errorf("%s: custom verbs after a wildcard cannot be expressed as ServeMux patterns", b.Template)
+		}
+		pattern.WriteString(":" + verb)
+	}
+	if len(segs) == 0 {
+		pattern.WriteString("/{$}")
+	}
+	vars := make(map[string]string, len(names))
+	for i, name := range names {
+		vars[name] = joinSegments(parts[i])
+	}
+	return pattern.String(), vars, nil
+}
+
+// joinSegments returns the expression joining the segment expressions
+// with "/", merging adjacent string literals.
+func joinSegments(exprs []string) string {
+	var out []string
+	lit := ""
+	for i, e := range exprs {
+		if i > 0 {
+			lit += "/"
+		}
+		if s, err := strconv.Unquote(e); err == nil {
+			lit += s
+			continue
+		}
+		if lit != "" {
+			out = append(out, strconv.Quote(lit))
+			lit = ""
+		}
+		out = append(out, e)
+	}
+	if lit != "" {
+		out = append(out, strconv.Quote(lit))
+	}
+	return strings.Join(out, " + ")
+}
+
+// findField returns the field of msg called name, or nil.
+func findField(msg *descriptor.DescriptorProto, name string) *descriptor.FieldDescriptorProto {
+	for _, f := range msg.GetField() {
+		if f.GetName() == name {
+			return f
+		}
+	}
+	return nil
+}
+
+// qualify returns the full proto name of name, declared in scope.
+func qualify(scope, name string) string {
+	if scope == "" {
+		return name
+	}
+	return scope + "." + name
+}
+
+// typeIndex maps fully-qualified proto type names (".pkg.Msg") to their
+// descriptors and declaring files for every file in the request.
+type typeIndex struct {
+	messages map[string]*descriptor.DescriptorProto
+	enums    map[string]*descriptor.EnumDescriptorProto
+	files    map[string]*descriptor.FileDescriptorProto
+}
+
+func newTypeIndex(files []*descriptor.FileDescriptorProto) *typeIndex {
+	idx := &typeIndex{
+		messages: make(map[string]*descriptor.DescriptorProto),
+		enums:    make(map[string]*descriptor.EnumDescriptorProto),
+		files:    make(map[string]*descriptor.FileDescriptorProto),
+	}
+	for _, f := range files {
+		prefix := ""
+		if f.GetPackage() != "" {
+			prefix = "." + f.GetPackage()
+		}
+		idx.addMessages(f, prefix, f.GetMessageType())
+		idx.addEnums(f, prefix, f.GetEnumType())
+	}
+	return idx
+}
+
+func (idx *typeIndex) addMessages(f *descriptor.FileDescriptorProto, prefix string, msgs []*descriptor.DescriptorProto) {
+	for _, m := range msgs {
+		name := prefix + "." + m.GetName()
+		idx.messages[name] = m
+		idx.files[name] = f
+		idx.addMessages(f, name, m.GetNestedType())
+		idx.addEnums(f, name, m.GetEnumType())
+	}
+}
+
+func (idx *typeIndex) addEnums(f *descriptor.FileDescriptorProto, prefix string, enums []*descriptor.EnumDescriptorProto) {
+	for _, e := range enums {
+		name := prefix + "." + e.GetName()
+		idx.enums[name] = e
+		idx.files[name] = f
+	}
+}
+
+// isMap reports whether field is a map field.
+func (idx *typeIndex) isMap(field *descriptor.FieldDescriptorProto) bool {
+	if field.GetType() != descriptor.FieldDescriptorProto_TYPE_MESSAGE {
+		return false
+	}
+	return idx.messages[field.GetTypeName()].GetOptions().GetMapEntry()
+}
+
+// goImportPath returns the import path of the Go package generated from f,
+// or "" if go_package does not name one.
+func goImportPath(f *descriptor.FileDescriptorProto) string {
+	gopkg := f.GetOptions().GetGoPackage()
+	if sc := strings.IndexByte(gopkg, ';'); sc >= 0 {
+		gopkg = gopkg[:sc]
+	}
+	if !strings.Contains(gopkg, "/") {
+		return ""
+	}
+	return gopkg
+}
+
+// importSet records the Go packages referenced by the code generated for
+// one file, keyed by import path.
+type importSet struct {
+	self  string
+	names map[string]string
+}
+
+func newImportSet(f *descriptor.FileDescriptorProto) *importSet {
+	return &importSet{self: goImportPath(f), names: make(map[string]string)}
+}
+
+// goTypeName returns the Go name of the message or enum typeName, qualified
+// with its package if it is declared outside the generated package.
+func (s *importSet) goTypeName(idx *typeIndex, typeName string) string {
+	name := localTypeName(typeName)
+	f := idx.files[typeName]
+	path := goImportPath(f)
+	if f == nil || path == "" || path == s.self {
+		return name
+	}
+	pkg := defaultGoPackageName(f)
+	s.names[path] = pkg
+	return pkg + "." + name
+}
+
+// localTypeName returns the Go name of a type declared in the file being
+// generated, e.g. ".pkg.Outer.Inner" becomes "Outer_Inner".
+func localTypeName(typeName string) string {
+	parts := strings.Split(strings.TrimPrefix(typeName, "."), ".")
+	var names []string
+	for _, p := range parts {
+		if len(p) > 0 && p[0] >= 'A' && p[0] <= 'Z' {
+			names = append(names, p)
+		}
+	}
+	return strings.Join(names, "_")
+}
+
+// sanitizePackageName replaces unallowed character in package name
+// with allowed character.
+func sanitizePackageName(pkgName string) string {
+	pkgName = strings.Replace(pkgName, ".", "_", -1)
+	pkgName = strings.Replace(pkgName, "-", "_", -1)
+	return pkgName
+}
+
+// defaultGoPackageName returns the default go package name to be used for go files generated from "f".
+// You might need to use an unique alias for the package when you import it.  Use ReserveGoPackageAlias to get a unique alias.
+func defaultGoPackageName(f *descriptor.FileDescriptorProto) string {
+	name := packageIdentityName(f)
+	return sanitizePackageName(name)
+}
+
+// packageIdentityName returns the identity of packages.
+// protoc-gen-grpc-gateway rejects CodeGenerationRequests which contains more than one packages
+// as protoc-gen-go does.
+func packageIdentityName(f *descriptor.FileDescriptorProto) string {
+	if f.Options != nil && f.Options.GoPackage != nil {
+		gopkg := f.Options.GetGoPackage()
+		idx := strings.LastIndex(gopkg, "/")
+		if idx < 0 {
+			gopkg = gopkg[idx+1:]
+		}
+
+		gopkg = gopkg[idx+1:]
+		// package name is overrided with the string after the
+		// ';' character
+		sc := strings.IndexByte(gopkg, ';')
+		if sc < 0 {
+			return sanitizePackageName(gopkg)
+
+		}
+		return sanitizePackageName(gopkg[sc+1:])
+	}
+
+	if f.Package == nil {
+		base := filepath.Base(f.GetName())
+		ext := filepath.Ext(base)
+		return strings.TrimSuffix(base, ext)
+	}
+	return f.GetPackage()
+}
+
+func emitFiles(out []*plugin.CodeGeneratorResponse_File) {
+	emitResp(&plugin.CodeGeneratorResponse{File: out})
+}
+
+func emitError(err error) {
+	emitResp(&plugin.CodeGeneratorResponse{Error: proto.String(err.Error())})
+}
+
+func emitResp(resp *plugin.CodeGeneratorResponse) {
+	buf, err := proto.Marshal(resp)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if _, err := os.Stdout.Write(buf); err != nil {
+		log.Fatal(err)
+	}
+}
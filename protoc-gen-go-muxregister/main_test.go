@@ -0,0 +1,35 @@
+package main
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+
+	"github.com/f4tq/protoc-go-plugins/internal/plugintest"
+)
+
+// TestBindings vets the binders of path variables and query parameters
+// setting fields of every kind.
+func TestBindings(t *testing.T) {
+	req := plugintest.ShelfRequest(t, "")
+	plugintest.Go(t, "vet", plugintest.Package(t, req, generate))
+}
+
+// TestUnexportedNamesPrefixed checks the unexported declarations start with
+// "mux", so that they do not collide with those the other plugins generate
+// into the same package, such as the binders of protoc-gen-go-httpgateway.
+func TestUnexportedNamesPrefixed(t *testing.T) {
+	for name, content := range plugintest.Generate(t, plugintest.ShelfRequest(t, ""), generate) {
+		f, err := parser.ParseFile(token.NewFileSet(), name, content, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for ident := range f.Scope.Objects {
+			if !ast.IsExported(ident) && !strings.HasPrefix(ident, "mux") {
+				t.Errorf("%s declares %s, without the mux prefix", name, ident)
+			}
+		}
+	}
+}
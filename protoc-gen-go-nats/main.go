@@ -0,0 +1,451 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+
+	"github.com/f4tq/protoc-go-plugins/options"
+	"github.com/f4tq/protoc-go-plugins/protodoc"
+)
+
+var (
+	hdrTmpl = template.Must(template.New("header").Parse(`
+// Code generated by protoc-gen-go-nats. DO NOT EDIT.
+// source: {{.Source}}
+
+package {{.GoPkg}}
+
+import (
+{{- if .Services}}
+    "context"
+
+{{end}}
+    "github.com/f4tq/protoc-go-plugins/runtime/natsrpc"
+    "github.com/golang/protobuf/proto"
+    "github.com/nats-io/nats.go"
+{{- range $path, $name := .Imports}}
+    {{$name}} "{{$path}}"
+{{- end}}
+)
+`))
+
+	serviceTmpl = template.Must(template.New("service").Parse(`
+// Subjects the unary methods of {{.FullName}} are served on over NATS.{{.Doc}}
+const (
+{{- range .Methods}}
+    {{$.Name}}{{.Name}}NATSSubject = {{printf "%q" .Subject}}
+{{- end}}
+)
+
+// Serve{{.Name}}NATS answers requests to the unary methods of srv on nc
+// until ctx is done. Servers sharing a non-empty queue group split the
+// requests between them. Streaming methods are served over gRPC only.{{.Doc}}
+func Serve{{.Name}}NATS(ctx context.Context, nc *nats.Conn, queue string, srv {{.Name}}Server) error {
+    return natsrpc.Serve(ctx, nc, queue, []natsrpc.Method{
+{{- range .Methods}}
+        {
+            Subject:    {{$.Name}}{{.Name}}NATSSubject,
+            NewRequest: func() proto.Message { return new({{.Input}}) },
+            Call: func(ctx context.Context, req proto.Message) (proto.Message, error) {
+                return srv.{{.Name}}(ctx, req.(*{{.Input}}))
+            },
+        },
+{{- end}}
+    })
+}
+
+// {{.Name}}NATSClient calls the unary methods of {{.FullName}} over NATS.{{.Doc}}
+type {{.Name}}NATSClient struct {
+    client *natsrpc.Client
+}
+
+// New{{.Name}}NATSClient returns a {{.Name}}NATSClient sending its requests
+// through client.{{.Doc}}
+func New{{.Name}}NATSClient(client *natsrpc.Client) *{{.Name}}NATSClient {
+    return &{{.Name}}NATSClient{client: client}
+}
+{{range .Methods}}
+// {{.Name}} calls {{$.FullName}}.{{.Name}}.{{.Doc}}
+func (c *{{$.Name}}NATSClient) {{.Name}}(ctx context.Context, in *{{.Input}}) (*{{.Output}}, error) {
+    out := new({{.Output}})
+    if err := c.client.Call(ctx, {{$.Name}}{{.Name}}NATSSubject, in, out); err != nil {
+        return nil, err
+    }
+    return out, nil
+}
+{{end}}
+`))
+
+	topicTmpl = template.Must(template.New("topic").Parse(`
+// {{.Name}}NATSSubject is the subject {{.Name}} messages are published on.{{.Doc}}
+const {{.Name}}NATSSubject = {{printf "%q" .Topic}}
+
+// Publish{{.Name}}NATS publishes msg on {{.Name}}NATSSubject.{{.Doc}}
+func Publish{{.Name}}NATS(nc *nats.Conn, msg *{{.Name}}) error {
+    return natsrpc.Publish(nc, {{.Name}}NATSSubject, msg)
+}
+
+// Subscribe{{.Name}}NATS calls fn with each message published on
+// {{.Name}}NATSSubject. Subscribers sharing a non-empty queue group split
+// the messages between them.{{.Doc}}
+func Subscribe{{.Name}}NATS(nc *nats.Conn, queue string, fn func(msg *{{.Name}})) (*nats.Subscription, error) {
+    return natsrpc.Subscribe(nc, {{.Name}}NATSSubject, queue, func() proto.Message { return new({{.Name}}) }, func(msg proto.Message) {
+        fn(msg.(*{{.Name}}))
+    })
+}
+`))
+)
+
+func main() {
+	input, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	req := new(plugin.CodeGeneratorRequest)
+	if err = proto.Unmarshal(input, req); err != nil {
+		log.Fatal(err)
+	}
+
+	out, err := generate(req)
+
+	if err != nil {
+		emitError(err)
+		return
+	}
+
+	emitFiles(out)
+}
+
+func generate(req *plugin.CodeGeneratorRequest) ([]*plugin.CodeGeneratorResponse_File, error) {
+	var files []*plugin.CodeGeneratorResponse_File
+	idx := newTypeIndex(req.GetProtoFile())
+	docs := protodoc.New(req.GetProtoFile())
+	genFileNames := make(map[string]bool)
+	for _, n := range req.FileToGenerate {
+		genFileNames[n] = true
+	}
+	for _, desc := range req.GetProtoFile() {
+		name := desc.GetName()
+		if _, ok := genFileNames[name]; !ok {
+			// Only emit output for files present in req.FileToGenerate.
+			continue
+		}
+		code, err := genCode(desc, idx, docs)
+		if err != nil {
+			return nil, err
+		}
+		if code == "" {
+			// Neither a unary method nor a message with a topic.
+			continue
+		}
+		formatted, err := format.Source([]byte(code))
+		if err != nil {
+			log.Printf("%v: %s", err, code)
+			return nil, err
+		}
+
+		ext := filepath.Ext(name)
+		base := strings.TrimSuffix(name, ext)
+		output := fmt.Sprintf("%s.pb.nats.go", base)
+		files = append(files, &plugin.CodeGeneratorResponse_File{
+			Name:    proto.String(output),
+			Content: proto.String(string(formatted)),
+		})
+	}
+
+	return files, nil
+}
+
+func genCode(desc *descriptor.FileDescriptorProto, idx *typeIndex, docs *protodoc.Index) (string, error) {
+	w := bytes.NewBuffer(nil)
+	imports := newImportSet(desc)
+	hdr := &header{
+		Source:  desc.GetName(),
+		GoPkg:   defaultGoPackageName(desc),
+		Imports: imports.names,
+	}
+	body := bytes.NewBuffer(nil)
+	for _, svc := range desc.GetService() {
+		fullName := svc.GetName()
+		if desc.GetPackage() != "" {
+			fullName = desc.GetPackage() + "." + svc.GetName()
+		}
+		s := &natsService{
+			Name:     svc.GetName(),
+			FullName: fullName,
+			Doc:      docs.Godoc(fullName),
+		}
+		subjects := make(map[string]string)
+		for _, m := range svc.GetMethod() {
+			if m.GetClientStreaming() || m.GetServerStreaming() {
+				// NATS request/reply carries a single reply.
+				continue
+			}
+			subject := options.Subject(fullName, svc, m)
+			if other, ok := subjects[subject]; ok {
+				return "", fmt.Errorf("%s: methods %s and %s share subject %q", fullName, other, m.GetName(), subject)
+			}
+			subjects[subject] = m.GetName()
+			s.Methods = append(s.Methods, &natsMethod{
+				Name:    m.GetName(),
+				Subject: subject,
+				Input:   imports.goTypeName(idx, m.GetInputType()),
+				Output:  imports.goTypeName(idx, m.GetOutputType()),
+				Doc:     docs.Godoc(fullName + "." + m.GetName()),
+			})
+		}
+		if len(s.Methods) == 0 {
+			continue
+		}
+		hdr.Services = true
+		if err := serviceTmpl.Execute(body, s); err != nil {
+			return "", err
+		}
+	}
+	prefix := ""
+	if desc.GetPackage() != "" {
+		prefix = "." + desc.GetPackage()
+	}
+	if err := genTopics(body, docs, prefix, desc.GetMessageType()); err != nil {
+		return "", err
+	}
+	if body.Len() == 0 {
+		return "", nil
+	}
+
+	if err := hdrTmpl.Execute(w, hdr); err != nil {
+		log.Fatal(err)
+	}
+	body.WriteTo(w)
+
+	return w.String(), nil
+}
+
+// genTopics writes the publish and subscribe helpers of the messages in
+// msgs, and their nested messages, that have a (f4tq.plugins.topic) option.
+func genTopics(w *bytes.Buffer, docs *protodoc.Index, prefix string, msgs []*descriptor.DescriptorProto) error {
+	for _, m := range msgs {
+		name := prefix + "." + m.GetName()
+		if m.GetOptions().GetMapEntry() {
+			continue
+		}
+		if topic := options.Topic(m); topic != "" {
+			t := &natsTopic{Name: localTypeName(name), Topic: topic, Doc: docs.Godoc(name)}
+			if err := topicTmpl.Execute(w, t); err != nil {
+				return err
+			}
+		}
+		if err := genTopics(w, docs, name, m.GetNestedType()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type header struct {
+	Source   string
+	GoPkg    string
+	Imports  map[string]string
+	Services bool
+}
+
+type natsService struct {
+	Name     string
+	FullName string
+	Methods  []*natsMethod
+	// Doc is the comment of the service, see protodoc.Index.Godoc.
+	Doc string
+}
+
+type natsMethod struct {
+	Name    string
+	Subject string
+	Input   string
+	Output  string
+	// Doc is the comment of the method.
+	Doc string
+}
+
+type natsTopic struct {
+	Name  string
+	Topic string
+	// Doc is the comment of the message, see protodoc.Index.Godoc.
+	Doc string
+}
+
+// typeIndex maps fully-qualified proto type names (".pkg.Msg") to their
+// descriptors and declaring files for every file in the request.
+type typeIndex struct {
+	messages map[string]*descriptor.DescriptorProto
+	enums    map[string]*descriptor.EnumDescriptorProto
+	files    map[string]*descriptor.FileDescriptorProto
+}
+
+func newTypeIndex(files []*descriptor.FileDescriptorProto) *typeIndex {
+	idx := &typeIndex{
+		messages: make(map[string]*descriptor.DescriptorProto),
+		enums:    make(map[string]*descriptor.EnumDescriptorProto),
+		files:    make(map[string]*descriptor.FileDescriptorProto),
+	}
+	for _, f := range files {
+		prefix := ""
+		if f.GetPackage() != "" {
+			prefix = "." + f.GetPackage()
+		}
+		idx.addMessages(f, prefix, f.GetMessageType())
+		idx.addEnums(f, prefix, f.GetEnumType())
+	}
+	return idx
+}
+
+func (idx *typeIndex) addMessages(f *descriptor.FileDescriptorProto, prefix string, msgs []*descriptor.DescriptorProto) {
+	for _, m := range msgs {
+		name := prefix + "." + m.GetName()
+		idx.messages[name] = m
+		idx.files[name] = f
+		idx.addMessages(f, name, m.GetNestedType())
+		idx.addEnums(f, name, m.GetEnumType())
+	}
+}
+
+func (idx *typeIndex) addEnums(f *descriptor.FileDescriptorProto, prefix string, enums []*descriptor.EnumDescriptorProto) {
+	for _, e := range enums {
+		name := prefix + "." + e.GetName()
+		idx.enums[name] = e
+		idx.files[name] = f
+	}
+}
+
+// isMap reports whether field is a map field.
+func (idx *typeIndex) isMap(field *descriptor.FieldDescriptorProto) bool {
+	if field.GetType() != descriptor.FieldDescriptorProto_TYPE_MESSAGE {
+		return false
+	}
+	return idx.messages[field.GetTypeName()].GetOptions().GetMapEntry()
+}
+
+// goImportPath returns the import path of the Go package generated from f,
+// or "" if go_package does not name one.
+func goImportPath(f *descriptor.FileDescriptorProto) string {
+	gopkg := f.GetOptions().GetGoPackage()
+	if sc := strings.IndexByte(gopkg, ';'); sc >= 0 {
+		gopkg = gopkg[:sc]
+	}
+	if !strings.Contains(gopkg, "/") {
+		return ""
+	}
+	return gopkg
+}
+
+// importSet records the Go packages referenced by the code generated for
+// one file, keyed by import path.
+type importSet struct {
+	self  string
+	names map[string]string
+}
+
+func newImportSet(f *descriptor.FileDescriptorProto) *importSet {
+	return &importSet{self: goImportPath(f), names: make(map[string]string)}
+}
+
+// goTypeName returns the Go name of the message or enum typeName, qualified
+// with its package if it is declared outside the generated package.
+func (s *importSet) goTypeName(idx *typeIndex, typeName string) string {
+	name := localTypeName(typeName)
+	f := idx.files[typeName]
+	path := goImportPath(f)
+	if f == nil || path == "" || path == s.self {
+		return name
+	}
+	pkg := defaultGoPackageName(f)
+	s.names[path] = pkg
+	return pkg + "." + name
+}
+
+// localTypeName returns the Go name of a type declared in the file being
+// generated, e.g. ".pkg.Outer.Inner" becomes "Outer_Inner".
+func localTypeName(typeName string) string {
+	parts := strings.Split(strings.TrimPrefix(typeName, "."), ".")
+	var names []string
+	for _, p := range parts {
+		if len(p) > 0 && p[0] >= 'A' && p[0] <= 'Z' {
+			names = append(names, p)
+		}
+	}
+	return strings.Join(names, "_")
+}
+
+// sanitizePackageName replaces unallowed character in package name
+// with allowed character.
+func sanitizePackageName(pkgName string) string {
+	pkgName = strings.Replace(pkgName, ".", "_", -1)
+	pkgName = strings.Replace(pkgName, "-", "_", -1)
+	return pkgName
+}
+
+// defaultGoPackageName returns the default go package name to be used for go files generated from "f".
+// You might need to use an unique alias for the package when you import it.  Use ReserveGoPackageAlias to get a unique alias.
+func defaultGoPackageName(f *descriptor.FileDescriptorProto) string {
+	name := packageIdentityName(f)
+	return sanitizePackageName(name)
+}
+
+// packageIdentityName returns the identity of packages.
+// protoc-gen-grpc-gateway rejects CodeGenerationRequests which contains more than one packages
+// as protoc-gen-go does.
+func packageIdentityName(f *descriptor.FileDescriptorProto) string {
+	if f.Options != nil && f.Options.GoPackage != nil {
+		gopkg := f.Options.GetGoPackage()
+		idx := strings.LastIndex(gopkg, "/")
+		if idx < 0 {
+			gopkg = gopkg[idx+1:]
+		}
+
+		gopkg = gopkg[idx+1:]
+		// package name is overrided with the string after the
+		// ';' character
+		sc := strings.IndexByte(gopkg, ';')
+		if sc < 0 {
+			return sanitizePackageName(gopkg)
+
+		}
+		return sanitizePackageName(gopkg[sc+1:])
+	}
+
+	if f.Package == nil {
+		base := filepath.Base(f.GetName())
+		ext := filepath.Ext(base)
+		return strings.TrimSuffix(base, ext)
+	}
+	return f.GetPackage()
+}
+
+func emitFiles(out []*plugin.CodeGeneratorResponse_File) {
+	emitResp(&plugin.CodeGeneratorResponse{File: out})
+}
+
+func emitError(err error) {
+	emitResp(&plugin.CodeGeneratorResponse{Error: proto.String(err.Error())})
+}
+
+func emitResp(resp *plugin.CodeGeneratorResponse) {
+	buf, err := proto.Marshal(resp)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if _, err := os.Stdout.Write(buf); err != nil {
+		log.Fatal(err)
+	}
+}
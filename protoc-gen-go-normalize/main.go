@@ -0,0 +1,416 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+
+	"github.com/f4tq/protoc-go-plugins/goname"
+	"github.com/f4tq/protoc-go-plugins/options"
+)
+
+var (
+	hdrTmpl = template.Must(template.New("header").Parse(`
+// Code generated by protoc-gen-go-normalize. DO NOT EDIT.
+// source: {{.Source}}
+
+package {{.GoPkg}}
+{{- if or .Strings .Norm}}
+
+import (
+{{- if .Strings}}
+    "strings"
+{{- end}}
+{{- if .Norm}}
+{{if .Strings}}
+{{end}}
+    "golang.org/x/text/unicode/norm"
+{{- end}}
+)
+{{- end}}
+`))
+
+	normalizeTmpl = template.Must(template.New("normalize").Parse(`
+// Normalize applies the (f4tq.plugins.normalize) steps of the string fields
+// of m to them in place, and normalizes the messages m holds.
+func (m *{{.Name}}) Normalize() {
+    if m == nil {
+        return
+    }
+{{- range .Fields}}
+{{.}}
+{{- end}}
+}
+`))
+)
+
+func main() {
+	input, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	req := new(plugin.CodeGeneratorRequest)
+	if err = proto.Unmarshal(input, req); err != nil {
+		log.Fatal(err)
+	}
+
+	out, err := generate(req)
+
+	if err != nil {
+		emitError(err)
+		return
+	}
+
+	emitFiles(out)
+}
+
+func generate(req *plugin.CodeGeneratorRequest) ([]*plugin.CodeGeneratorResponse_File, error) {
+	var files []*plugin.CodeGeneratorResponse_File
+	idx := newTypeIndex(req.GetProtoFile())
+	genFileNames := make(map[string]bool)
+	for _, n := range req.FileToGenerate {
+		genFileNames[n] = true
+	}
+	has := normalizeMessages(idx, genFileNames)
+	for _, desc := range req.GetProtoFile() {
+		name := desc.GetName()
+		if _, ok := genFileNames[name]; !ok {
+			// Only emit output for files present in req.FileToGenerate.
+			continue
+		}
+		code, err := genCode(desc, idx, has)
+		if err != nil {
+			return nil, err
+		}
+		if code == "" {
+			// No message of the file has fields to normalize.
+			continue
+		}
+		formatted, err := format.Source([]byte(code))
+		if err != nil {
+			log.Printf("%v: %s", err, code)
+			return nil, err
+		}
+
+		ext := filepath.Ext(name)
+		base := strings.TrimSuffix(name, ext)
+		output := fmt.Sprintf("%s.pb.normalize.go", base)
+		files = append(files, &plugin.CodeGeneratorResponse_File{
+			Name:    proto.String(output),
+			Content: proto.String(string(formatted)),
+		})
+	}
+
+	return files, nil
+}
+
+func genCode(desc *descriptor.FileDescriptorProto, idx *typeIndex, has map[string]bool) (string, error) {
+	w := bytes.NewBuffer(nil)
+	g := &normalizeGen{
+		idx:    idx,
+		proto3: desc.GetSyntax() == "proto3",
+		has:    has,
+	}
+	body := bytes.NewBuffer(nil)
+	if err := g.messages(body, strings.TrimSuffix("."+desc.GetPackage(), "."), "", desc.GetMessageType()); err != nil {
+		return "", err
+	}
+	if body.Len() == 0 {
+		return "", nil
+	}
+
+	hdr := &header{
+		Source:  desc.GetName(),
+		GoPkg:   defaultGoPackageName(desc),
+		Strings: g.strings,
+		Norm:    g.norm,
+	}
+	if err := hdrTmpl.Execute(w, hdr); err != nil {
+		log.Fatal(err)
+	}
+	body.WriteTo(w)
+
+	return w.String(), nil
+}
+
+type normalizeGen struct {
+	idx    *typeIndex
+	proto3 bool
+	// has holds the messages with a Normalize method.
+	has map[string]bool
+	// strings and norm record the packages the code uses.
+	strings, norm bool
+}
+
+// messages writes the Normalize methods of msgs and of the messages nested
+// in them. scope is the full proto name of their parent, prefix the Go name
+// of the enclosing message plus "_".
+func (g *normalizeGen) messages(w *bytes.Buffer, scope, prefix string, msgs []*descriptor.DescriptorProto) error {
+	for _, msg := range msgs {
+		if msg.GetOptions().GetMapEntry() {
+			continue
+		}
+		typeName := scope + "." + msg.GetName()
+		name := prefix + msg.GetName()
+		if g.has[typeName] {
+			m := &normalizeMessage{Name: name}
+			for _, field := range msg.GetField() {
+				stmt, err := g.field(name, msg, field)
+				if err != nil {
+					return fmt.Errorf("%s.%s: %v", name, field.GetName(), err)
+				}
+				if stmt != "" {
+					m.Fields = append(m.Fields, stmt)
+				}
+			}
+			if err := normalizeTmpl.Execute(w, m); err != nil {
+				return err
+			}
+		}
+		if err := g.messages(w, typeName, name+"_", msg.GetNestedType()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// normalizeMessages returns the generated messages that have a field to
+// normalize, or a message field of such a type.
+func normalizeMessages(idx *typeIndex, genFileNames map[string]bool) map[string]bool {
+	has := make(map[string]bool)
+	// Repeat until no message is added, as messages may refer to each
+	// other in cycles.
+	for changed := true; changed; {
+		changed = false
+		for typeName, msg := range idx.messages {
+			if has[typeName] || !genFileNames[idx.files[typeName].GetName()] {
+				continue
+			}
+			for _, field := range msg.GetField() {
+				if len(options.Normalize(field)) > 0 {
+					has[typeName] = true
+					changed = true
+					break
+				}
+				if idx.isMap(field) {
+					field = idx.messages[field.GetTypeName()].GetField()[1]
+				}
+				if has[field.GetTypeName()] {
+					has[typeName] = true
+					changed = true
+					break
+				}
+			}
+		}
+	}
+	return has
+}
+
+// field returns the statements normalizing field of msg, whose Go name is
+// msgName, or "" if it has nothing to normalize.
+func (g *normalizeGen) field(msgName string, msg *descriptor.DescriptorProto, field *descriptor.FieldDescriptorProto) (string, error) {
+	goNames := goname.Fields(msg)
+	goName := goNames[field.GetName()]
+	oneof := field.OneofIndex != nil && !field.GetProto3Optional()
+	steps := options.Normalize(field)
+	if len(steps) == 0 {
+		value := field
+		if g.idx.isMap(field) {
+			value = g.idx.messages[field.GetTypeName()].GetField()[1]
+		}
+		if value.GetType() != descriptor.FieldDescriptorProto_TYPE_MESSAGE || !g.has[value.GetTypeName()] {
+			return "", nil
+		}
+		switch {
+		case field.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REPEATED:
+			return fmt.Sprintf("for _, v := range m.%s {\nv.Normalize()\n}", goName), nil
+		case oneof:
+			return fmt.Sprintf("if v, ok := m.%s.(*%s_%s); ok {\nv.%s.Normalize()\n}",
+				goNames[msg.GetOneofDecl()[field.GetOneofIndex()].GetName()], msgName, goName, goName), nil
+		}
+		return fmt.Sprintf("m.%s.Normalize()", goName), nil
+	}
+	value := field
+	if g.idx.isMap(field) {
+		value = g.idx.messages[field.GetTypeName()].GetField()[1]
+	}
+	if value.GetType() != descriptor.FieldDescriptorProto_TYPE_STRING {
+		return "", fmt.Errorf("normalize only applies to string fields and maps with string values")
+	}
+	apply := func(v string) (string, error) {
+		for _, step := range steps {
+			switch step {
+			case "trim":
+				g.strings = true
+				v = fmt.Sprintf("strings.TrimSpace(%s)", v)
+			case "lower":
+				g.strings = true
+				v = fmt.Sprintf("strings.ToLower(%s)", v)
+			case "upper":
+				g.strings = true
+				v = fmt.Sprintf("strings.ToUpper(%s)", v)
+			case "nfc", "nfd", "nfkc", "nfkd":
+				g.norm = true
+				v = fmt.Sprintf("norm.%s.String(%s)", strings.ToUpper(step), v)
+			default:
+				return "", fmt.Errorf("unknown normalize step %q", step)
+			}
+		}
+		return v, nil
+	}
+	switch {
+	case g.idx.isMap(field):
+		expr, err := apply("v")
+		return fmt.Sprintf("for k, v := range m.%s {\nm.%s[k] = %s\n}", goName, goName, expr), err
+	case field.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REPEATED:
+		expr, err := apply("v")
+		return fmt.Sprintf("for i, v := range m.%s {\nm.%s[i] = %s\n}", goName, goName, expr), err
+	case oneof:
+		expr, err := apply("v." + goName)
+		return fmt.Sprintf("if v, ok := m.%s.(*%s_%s); ok {\nv.%s = %s\n}",
+			goNames[msg.GetOneofDecl()[field.GetOneofIndex()].GetName()], msgName, goName, goName, expr), err
+	case field.GetProto3Optional() || !g.proto3:
+		// The field is a pointer.
+		expr, err := apply("*m." + goName)
+		return fmt.Sprintf("if m.%s != nil {\n*m.%s = %s\n}", goName, goName, expr), err
+	}
+	expr, err := apply("m." + goName)
+	return fmt.Sprintf("m.%s = %s", goName, expr), err
+}
+
+type header struct {
+	Source  string
+	GoPkg   string
+	Strings bool
+	Norm    bool
+}
+
+type normalizeMessage struct {
+	Name   string
+	Fields []string
+}
+
+// typeIndex maps fully-qualified proto type names (".pkg.Msg") to their
+// descriptors and declaring files for every file in the request.
+type typeIndex struct {
+	messages map[string]*descriptor.DescriptorProto
+	enums    map[string]*descriptor.EnumDescriptorProto
+	files    map[string]*descriptor.FileDescriptorProto
+}
+
+func newTypeIndex(files []*descriptor.FileDescriptorProto) *typeIndex {
+	idx := &typeIndex{
+		messages: make(map[string]*descriptor.DescriptorProto),
+		enums:    make(map[string]*descriptor.EnumDescriptorProto),
+		files:    make(map[string]*descriptor.FileDescriptorProto),
+	}
+	for _, f := range files {
+		prefix := ""
+		if f.GetPackage() != "" {
+			prefix = "." + f.GetPackage()
+		}
+		idx.addMessages(f, prefix, f.GetMessageType())
+		idx.addEnums(f, prefix, f.GetEnumType())
+	}
+	return idx
+}
+
+func (idx *typeIndex) addMessages(f *descriptor.FileDescriptorProto, prefix string, msgs []*descriptor.DescriptorProto) {
+	for _, m := range msgs {
+		name := prefix + "." + m.GetName()
+		idx.messages[name] = m
+		idx.files[name] = f
+		idx.addMessages(f, name, m.GetNestedType())
+		idx.addEnums(f, name, m.GetEnumType())
+	}
+}
+
+func (idx *typeIndex) addEnums(f *descriptor.FileDescriptorProto, prefix string, enums []*descriptor.EnumDescriptorProto) {
+	for _, e := range enums {
+		name := prefix + "." + e.GetName()
+		idx.enums[name] = e
+		idx.files[name] = f
+	}
+}
+
+// isMap reports whether field is a map field.
+func (idx *typeIndex) isMap(field *descriptor.FieldDescriptorProto) bool {
+	if field.GetType() != descriptor.FieldDescriptorProto_TYPE_MESSAGE {
+		return false
+	}
+	return idx.messages[field.GetTypeName()].GetOptions().GetMapEntry()
+}
+
+// sanitizePackageName replaces unallowed character in package name
+// with allowed character.
+func sanitizePackageName(pkgName string) string {
+	pkgName = strings.Replace(pkgName, ".", "_", -1)
+	pkgName = strings.Replace(pkgName, "-", "_", -1)
+	return pkgName
+}
+
+// defaultGoPackageName returns the default go package name to be used for go files generated from "f".
+// You might need to use an unique alias for the package when you import it.  Use ReserveGoPackageAlias to get a unique alias.
+func defaultGoPackageName(f *descriptor.FileDescriptorProto) string {
+	name := packageIdentityName(f)
+	return sanitizePackageName(name)
+}
+
+// packageIdentityName returns the identity of packages.
+// protoc-gen-grpc-gateway rejects CodeGenerationRequests which contains more than one packages
+// as protoc-gen-go does.
+func packageIdentityName(f *descriptor.FileDescriptorProto) string {
+	if f.Options != nil && f.Options.GoPackage != nil {
+		gopkg := f.Options.GetGoPackage()
+		idx := strings.LastIndex(gopkg, "/")
+		if idx < 0 {
+			gopkg = gopkg[idx+1:]
+		}
+
+		gopkg = gopkg[idx+1:]
+		// package name is overrided with the string after the
+		// ';' character
+		sc := strings.IndexByte(gopkg, ';')
+		if sc < 0 {
+			return sanitizePackageName(gopkg)
+
+		}
+		return sanitizePackageName(gopkg[sc+1:])
+	}
+
+	if f.Package == nil {
+		base := filepath.Base(f.GetName())
+		ext := filepath.Ext(base)
+		return strings.TrimSuffix(base, ext)
+	}
+	return f.GetPackage()
+}
+
+func emitFiles(out []*plugin.CodeGeneratorResponse_File) {
+	emitResp(&plugin.CodeGeneratorResponse{File: out})
+}
+
+func emitError(err error) {
+	emitResp(&plugin.CodeGeneratorResponse{Error: proto.String(err.Error())})
+}
+
+func emitResp(resp *plugin.CodeGeneratorResponse) {
+	buf, err := proto.Marshal(resp)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if _, err := os.Stdout.Write(buf); err != nil {
+		log.Fatal(err)
+	}
+}
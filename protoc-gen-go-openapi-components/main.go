@@ -0,0 +1,648 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+
+	"github.com/f4tq/protoc-go-plugins/options"
+	"github.com/f4tq/protoc-go-plugins/protodoc"
+)
+
+var (
+	codeTmpl = template.Must(template.New("code").Parse(`
+// Code generated by protoc-gen-go-openapi-components. DO NOT EDIT.
+// source: {{.Source}}
+
+package {{.GoPkg}}
+
+import _ "embed"
+
+//go:embed {{.YAMLName}}
+var {{.Var}} string
+
+// {{.Func}} returns the OpenAPI components.schemas of the
+// messages and enums of {{.Source}}, as the YAML document {{.YAMLName}},
+// for merging into OpenAPI documents. The schemas describe the JSON
+// encoding of jsonpb and are named by full proto name; they refer to
+// those of other files by the same names.
+func {{.Func}}() string {
+    return {{.Var}}
+}
+`))
+
+	// plainKey matches the YAML keys and scalars written without quotes.
+	plainKey = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.]*$`)
+)
+
+func main() {
+	input, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	req := new(plugin.CodeGeneratorRequest)
+	if err = proto.Unmarshal(input, req); err != nil {
+		log.Fatal(err)
+	}
+
+	out, err := generate(req)
+
+	if err != nil {
+		emitError(err)
+		return
+	}
+
+	emitFiles(out)
+}
+
+func generate(req *plugin.CodeGeneratorRequest) ([]*plugin.CodeGeneratorResponse_File, error) {
+	var files []*plugin.CodeGeneratorResponse_File
+	idx := newTypeIndex(req.GetProtoFile())
+	docs := protodoc.New(req.GetProtoFile())
+	genFileNames := make(map[string]bool)
+	for _, n := range req.FileToGenerate {
+		genFileNames[n] = true
+	}
+	for _, desc := range req.GetProtoFile() {
+		name := desc.GetName()
+		if _, ok := genFileNames[name]; !ok {
+			// Only emit output for files present in req.FileToGenerate.
+			continue
+		}
+		code, yaml, err := genCode(desc, idx, docs)
+		if err != nil {
+			return nil, err
+		}
+		if code == "" {
+			// The file declares no messages or enums.
+			continue
+		}
+		formatted, err := format.Source([]byte(code))
+		if err != nil {
+			log.Printf("%v: %s", err, code)
+			return nil, err
+		}
+
+		ext := filepath.Ext(name)
+		base := strings.TrimSuffix(name, ext)
+		files = append(files,
+			&plugin.CodeGeneratorResponse_File{
+				Name:    proto.String(fmt.Sprintf("%s.pb.openapi.go", base)),
+				Content: proto.String(string(formatted)),
+			},
+			&plugin.CodeGeneratorResponse_File{
+				Name:    proto.String(fmt.Sprintf("%s.components.yaml", base)),
+				Content: proto.String(yaml),
+			},
+		)
+	}
+
+	return files, nil
+}
+
+func genCode(desc *descriptor.FileDescriptorProto, idx *typeIndex, docs *protodoc.Index) (string, string, error) {
+	g := &schemaGen{idx: idx, docs: docs}
+	scope := strings.TrimSuffix("."+desc.GetPackage(), ".")
+	if err := g.messages(scope, desc.GetMessageType()); err != nil {
+		return "", "", fmt.Errorf("%s: %v", desc.GetName(), err)
+	}
+	g.enums(scope, desc.GetEnumType())
+	if len(g.schemas) == 0 {
+		return "", "", nil
+	}
+
+	y := bytes.NewBuffer(nil)
+	fmt.Fprintf(y, "# Code generated by protoc-gen-go-openapi-components. DO NOT EDIT.\n# source: %s\ncomponents:\n  schemas:\n", desc.GetName())
+	for _, p := range g.schemas {
+		writeYAMLEntry(y, "    ", p.Name, p.Schema)
+	}
+
+	base := strings.TrimSuffix(filepath.Base(desc.GetName()), filepath.Ext(desc.GetName()))
+	fileName := camelCase(strings.NewReplacer("-", "_", ".", "_").Replace(base))
+	w := bytes.NewBuffer(nil)
+	if err := codeTmpl.Execute(w, map[string]string{
+		"Source":   desc.GetName(),
+		"GoPkg":    defaultGoPackageName(desc),
+		"YAMLName": base + ".components.yaml",
+		"Var":      "openAPIComponents" + fileName,
+		"Func":     fileName + "OpenAPIComponents",
+	}); err != nil {
+		return "", "", err
+	}
+	return w.String(), y.String(), nil
+}
+
+// wellKnownSchemas are the schemas of the well-known types, which jsonpb
+// encodes specially.
+var wellKnownSchemas = map[string]func() *schema{
+	".google.protobuf.Timestamp": func() *schema { return &schema{Type: "string", Format: "date-time"} },
+	".google.protobuf.Duration": func() *schema {
+		return &schema{Type: "string", Pattern: `^-?[0-9]+(\.[0-9]+)?s$`}
+	},
+	".google.protobuf.FieldMask": func() *schema { return &schema{Type: "string"} },
+	".google.protobuf.Empty":     func() *schema { return &schema{Type: "object"} },
+	".google.protobuf.Struct":    func() *schema { return &schema{Type: "object", AnyProperties: true} },
+	".google.protobuf.Value":     func() *schema { return &schema{} },
+	".google.protobuf.ListValue": func() *schema { return &schema{Type: "array", Items: &schema{}} },
+	".google.protobuf.Any": func() *schema {
+		return &schema{
+			Type:          "object",
+			Properties:    []*property{{Name: "@type", Schema: &schema{Type: "string"}}},
+			Required:      []string{"@type"},
+			AnyProperties: true,
+		}
+	},
+	".google.protobuf.DoubleValue": func() *schema { return &schema{Type: "number", Format: "double", Nullable: true} },
+	".google.protobuf.FloatValue":  func() *schema { return &schema{Type: "number", Format: "float", Nullable: true} },
+	".google.protobuf.Int64Value":  func() *schema { return &schema{Type: "string", Format: "int64", Nullable: true} },
+	".google.protobuf.UInt64Value": func() *schema { return &schema{Type: "string", Format: "uint64", Nullable: true} },
+	".google.protobuf.Int32Value":  func() *schema { return &schema{Type: "integer", Format: "int32", Nullable: true} },
+	".google.protobuf.UInt32Value": func() *schema { return &schema{Type: "integer", Format: "uint32", Nullable: true} },
+	".google.protobuf.BoolValue":   func() *schema { return &schema{Type: "boolean", Nullable: true} },
+	".google.protobuf.StringValue": func() *schema { return &schema{Type: "string", Nullable: true} },
+	".google.protobuf.BytesValue":  func() *schema { return &schema{Type: "string", Format: "byte", Nullable: true} },
+}
+
+// schemaGen builds the schemas of the messages and enums of a file as
+// jsonpb encodes them: fields under their JSON names, 64-bit integers as
+// strings, enums by name and bytes in base64.
+type schemaGen struct {
+	idx *typeIndex
+	// docs holds the comments of the declarations.
+	docs    *protodoc.Index
+	schemas []*property
+}
+
+// messages adds the schemas of msgs, and of the messages and enums nested
+// in them. scope is the full proto name of their parent.
+func (g *schemaGen) messages(scope string, msgs []*descriptor.DescriptorProto) error {
+	for _, msg := range msgs {
+		if msg.GetOptions().GetMapEntry() {
+			continue
+		}
+		typeName := scope + "." + msg.GetName()
+		s := &schema{
+			Type:        "object",
+			Description: g.docs.Comment(typeName),
+			Deprecated:  msg.GetOptions().GetDeprecated(),
+		}
+		for _, field := range msg.GetField() {
+			fs, err := g.field(field)
+			if err != nil {
+				return fmt.Errorf("%s.%s: %v", strings.TrimPrefix(typeName, "."), field.GetName(), err)
+			}
+			fs.Description = g.docs.Comment(typeName + "." + field.GetName())
+			fs.Deprecated = field.GetOptions().GetDeprecated()
+			s.Properties = append(s.Properties, &property{Name: jsonName(field), Schema: fs})
+			if options.Rules(field).GetRequired() {
+				s.Required = append(s.Required, jsonName(field))
+			}
+		}
+		g.schemas = append(g.schemas, &property{Name: strings.TrimPrefix(typeName, "."), Schema: s})
+		if err := g.messages(typeName, msg.GetNestedType()); err != nil {
+			return err
+		}
+		g.enums(typeName, msg.GetEnumType())
+	}
+	return nil
+}
+
+// enums adds the schemas of enums, as messages does.
+func (g *schemaGen) enums(scope string, enums []*descriptor.EnumDescriptorProto) {
+	for _, enum := range enums {
+		typeName := scope + "." + enum.GetName()
+		s := &schema{
+			Type:        "string",
+			Description: g.docs.Comment(typeName),
+			Deprecated:  enum.GetOptions().GetDeprecated(),
+		}
+		for _, v := range enum.GetValue() {
+			s.Enum = append(s.Enum, v.GetName())
+		}
+		g.schemas = append(g.schemas, &property{Name: strings.TrimPrefix(typeName, "."), Schema: s})
+	}
+}
+
+// field returns the schema of field, with its (f4tq.plugins.validate)
+// rules.
+func (g *schemaGen) field(field *descriptor.FieldDescriptorProto) (*schema, error) {
+	rules := options.Rules(field)
+	if rules == nil {
+		rules = &options.FieldRules{}
+	}
+	if g.idx.isMap(field) {
+		// Map values only take the item bounds, as in protoc-gen-go-validate.
+		v, err := g.value(g.idx.messages[field.GetTypeName()].GetField()[1], nil)
+		if err != nil {
+			return nil, err
+		}
+		return &schema{
+			Type:                 "object",
+			AdditionalProperties: v,
+			MinProperties:        uint32Ptr(rules.MinItems),
+			MaxProperties:        uint32Ptr(rules.MaxItems),
+		}, nil
+	}
+	if field.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REPEATED {
+		v, err := g.value(field, rules)
+		if err != nil {
+			return nil, err
+		}
+		return &schema{
+			Type:     "array",
+			Items:    v,
+			MinItems: uint32Ptr(rules.MinItems),
+			MaxItems: uint32Ptr(rules.MaxItems),
+		}, nil
+	}
+	return g.value(field, rules)
+}
+
+// value returns the schema of a value of field, bounded by rules.
+func (g *schemaGen) value(field *descriptor.FieldDescriptorProto, rules *options.FieldRules) (*schema, error) {
+	var s *schema
+	switch field.GetType() {
+	case descriptor.FieldDescriptorProto_TYPE_DOUBLE:
+		s = &schema{Type: "number", Format: "double"}
+	case descriptor.FieldDescriptorProto_TYPE_FLOAT:
+		s = &schema{Type: "number", Format: "float"}
+	case descriptor.FieldDescriptorProto_TYPE_INT32,
+		descriptor.FieldDescriptorProto_TYPE_SINT32,
+		descriptor.FieldDescriptorProto_TYPE_SFIXED32:
+		s = &schema{Type: "integer", Format: "int32"}
+	case descriptor.FieldDescriptorProto_TYPE_UINT32,
+		descriptor.FieldDescriptorProto_TYPE_FIXED32:
+		s = &schema{Type: "integer", Format: "uint32"}
+	case descriptor.FieldDescriptorProto_TYPE_INT64,
+		descriptor.FieldDescriptorProto_TYPE_SINT64,
+		descriptor.FieldDescriptorProto_TYPE_SFIXED64:
+		// jsonpb writes 64-bit integers as strings, which the numeric
+		// bounds do not apply to.
+		return &schema{Type: "string", Format: "int64"}, nil
+	case descriptor.FieldDescriptorProto_TYPE_UINT64,
+		descriptor.FieldDescriptorProto_TYPE_FIXED64:
+		return &schema{Type: "string", Format: "uint64"}, nil
+	case descriptor.FieldDescriptorProto_TYPE_BOOL:
+		s = &schema{Type: "boolean"}
+	case descriptor.FieldDescriptorProto_TYPE_STRING:
+		s = &schema{Type: "string"}
+	case descriptor.FieldDescriptorProto_TYPE_BYTES:
+		s = &schema{Type: "string", Format: "byte"}
+	case descriptor.FieldDescriptorProto_TYPE_ENUM:
+		return &schema{Ref: strings.TrimPrefix(field.GetTypeName(), ".")}, nil
+	case descriptor.FieldDescriptorProto_TYPE_MESSAGE,
+		descriptor.FieldDescriptorProto_TYPE_GROUP:
+		// jsonpb encodes groups as the messages they are.
+		if wkt, ok := wellKnownSchemas[field.GetTypeName()]; ok {
+			return wkt(), nil
+		}
+		if _, ok := g.idx.messages[field.GetTypeName()]; !ok {
+			return nil, fmt.Errorf("unknown message %s", field.GetTypeName())
+		}
+		return &schema{Ref: strings.TrimPrefix(field.GetTypeName(), ".")}, nil
+	default:
+		return nil, fmt.Errorf("unsupported field type %v", field.GetType())
+	}
+	if rules == nil {
+		return s, nil
+	}
+	// Lengths count the characters of strings; those of bytes, encoded in
+	// base64, are left to the generated Validate methods.
+	if s.Type == "string" && s.Format == "" {
+		s.MinLength = uint32Ptr(rules.MinLen)
+		s.MaxLength = uint32Ptr(rules.MaxLen)
+		s.Pattern = rules.GetPattern()
+	}
+	if s.Type == "number" || s.Type == "integer" {
+		switch {
+		case rules.Gt != nil:
+			s.Minimum, s.ExclusiveMinimum = rules.Gt, true
+		case rules.Gte != nil:
+			s.Minimum = rules.Gte
+		}
+		switch {
+		case rules.Lt != nil:
+			s.Maximum, s.ExclusiveMaximum = rules.Lt, true
+		case rules.Lte != nil:
+			s.Maximum = rules.Lte
+		}
+	}
+	return s, nil
+}
+
+// jsonName returns the JSON name of field, which protoc sets, or else the
+// lower camel case of its name.
+func jsonName(field *descriptor.FieldDescriptorProto) string {
+	if field.GetJsonName() != "" {
+		return field.GetJsonName()
+	}
+	name := camelCase(field.GetName())
+	return strings.ToLower(name[:1]) + name[1:]
+}
+
+func uint32Ptr(v *uint32) *int64 {
+	if v == nil {
+		return nil
+	}
+	n := int64(*v)
+	return &n
+}
+
+// schema is an OpenAPI 3.0 schema.
+type schema struct {
+	// Ref is the name of the schema of a message or enum in
+	// components.schemas.
+	Ref                                string
+	Type, Format                       string
+	Description                        string
+	Properties                         []*property
+	Required                           []string
+	Items, AdditionalProperties        *schema
+	AnyProperties                      bool // additionalProperties: true
+	Enum                               []string
+	Pattern                            string
+	MinLength, MaxLength               *int64
+	MinItems, MaxItems                 *int64
+	MinProperties, MaxProperties       *int64
+	Minimum, Maximum                   *float64
+	ExclusiveMinimum, ExclusiveMaximum bool
+	Nullable, Deprecated               bool
+}
+
+type property struct {
+	Name   string
+	Schema *schema
+}
+
+// writeYAMLEntry writes the entry of key and s in a YAML mapping indented
+// by indent.
+func writeYAMLEntry(w *bytes.Buffer, indent, key string, s *schema) {
+	var value bytes.Buffer
+	s.writeYAML(&value, indent+"  ")
+	if value.Len() == 0 {
+		fmt.Fprintf(w, "%s%s: {}\n", indent, yamlString(key))
+		return
+	}
+	fmt.Fprintf(w, "%s%s:\n", indent, yamlString(key))
+	w.Write(value.Bytes())
+}
+
+// writeYAML writes s as a YAML mapping indented by indent.
+func (s *schema) writeYAML(w *bytes.Buffer, indent string) {
+	line := func(key, v string) {
+		fmt.Fprintf(w, "%s%s: %s\n", indent, key, v)
+	}
+	flag := func(key string, v bool) {
+		if v {
+			line(key, "true")
+		}
+	}
+	integer := func(key string, v *int64) {
+		if v != nil {
+			line(key, strconv.FormatInt(*v, 10))
+		}
+	}
+	number := func(key string, v *float64) {
+		if v != nil {
+			line(key, strconv.FormatFloat(*v, 'g', -1, 64))
+		}
+	}
+	list := func(key string, items []string) {
+		if len(items) > 0 {
+			fmt.Fprintf(w, "%s%s:\n", indent, key)
+			for _, v := range items {
+				fmt.Fprintf(w, "%s- %s\n", indent, yamlString(v))
+			}
+		}
+	}
+	if s.Ref != "" {
+		ref := strconv.Quote("#/components/schemas/" + s.Ref)
+		if s.Description == "" && !s.Deprecated {
+			line("$ref", ref)
+			return
+		}
+		// The siblings of $ref are ignored.
+		fmt.Fprintf(w, "%sallOf:\n%s- $ref: %s\n", indent, indent, ref)
+	}
+	if s.Description != "" {
+		line("description", strconv.Quote(s.Description))
+	}
+	if s.Type != "" {
+		line("type", s.Type)
+	}
+	if s.Format != "" {
+		line("format", s.Format)
+	}
+	flag("nullable", s.Nullable)
+	flag("deprecated", s.Deprecated)
+	number("minimum", s.Minimum)
+	flag("exclusiveMinimum", s.ExclusiveMinimum)
+	number("maximum", s.Maximum)
+	flag("exclusiveMaximum", s.ExclusiveMaximum)
+	integer("minLength", s.MinLength)
+	integer("maxLength", s.MaxLength)
+	if s.Pattern != "" {
+		// Go quoting is valid in YAML double-quoted scalars.
+		line("pattern", strconv.Quote(s.Pattern))
+	}
+	integer("minItems", s.MinItems)
+	integer("maxItems", s.MaxItems)
+	integer("minProperties", s.MinProperties)
+	integer("maxProperties", s.MaxProperties)
+	list("enum", s.Enum)
+	list("required", s.Required)
+	if len(s.Properties) > 0 {
+		fmt.Fprintf(w, "%sproperties:\n", indent)
+		for _, p := range s.Properties {
+			writeYAMLEntry(w, indent+"  ", p.Name, p.Schema)
+		}
+	}
+	if s.Items != nil {
+		writeYAMLEntry(w, indent, "items", s.Items)
+	}
+	if s.AdditionalProperties != nil {
+		writeYAMLEntry(w, indent, "additionalProperties", s.AdditionalProperties)
+	}
+	flag("additionalProperties", s.AnyProperties)
+}
+
+// yamlString returns s as a YAML scalar: plain if it cannot be read as
+// anything but that string, and double-quoted otherwise.
+func yamlString(s string) string {
+	switch strings.ToLower(s) {
+	case "y", "n", "yes", "no", "on", "off", "true", "false", "null":
+		return strconv.Quote(s)
+	}
+	if plainKey.MatchString(s) {
+		return s
+	}
+	return strconv.Quote(s)
+}
+
+// typeIndex maps fully-qualified proto type names (".pkg.Msg") to their
+// descriptors and declaring files for every file in the request.
+type typeIndex struct {
+	messages map[string]*descriptor.DescriptorProto
+	enums    map[string]*descriptor.EnumDescriptorProto
+	files    map[string]*descriptor.FileDescriptorProto
+}
+
+func newTypeIndex(files []*descriptor.FileDescriptorProto) *typeIndex {
+	idx := &typeIndex{
+		messages: make(map[string]*descriptor.DescriptorProto),
+		enums:    make(map[string]*descriptor.EnumDescriptorProto),
+		files:    make(map[string]*descriptor.FileDescriptorProto),
+	}
+	for _, f := range files {
+		prefix := ""
+		if f.GetPackage() != "" {
+			prefix = "." + f.GetPackage()
+		}
+		idx.addMessages(f, prefix, f.GetMessageType())
+		idx.addEnums(f, prefix, f.GetEnumType())
+	}
+	return idx
+}
+
+func (idx *typeIndex) addMessages(f *descriptor.FileDescriptorProto, prefix string, msgs []*descriptor.DescriptorProto) {
+	for _, m := range msgs {
+		name := prefix + "." + m.GetName()
+		idx.messages[name] = m
+		idx.files[name] = f
+		idx.addMessages(f, name, m.GetNestedType())
+		idx.addEnums(f, name, m.GetEnumType())
+	}
+}
+
+func (idx *typeIndex) addEnums(f *descriptor.FileDescriptorProto, prefix string, enums []*descriptor.EnumDescriptorProto) {
+	for _, e := range enums {
+		name := prefix + "." + e.GetName()
+		idx.enums[name] = e
+		idx.files[name] = f
+	}
+}
+
+// isMap reports whether field is a map field.
+func (idx *typeIndex) isMap(field *descriptor.FieldDescriptorProto) bool {
+	if field.GetType() != descriptor.FieldDescriptorProto_TYPE_MESSAGE {
+		return false
+	}
+	return idx.messages[field.GetTypeName()].GetOptions().GetMapEntry()
+}
+
+// camelCase returns the Go field name protoc-gen-go derives from s.
+func camelCase(s string) string {
+	if s == "" {
+		return ""
+	}
+	t := make([]byte, 0, 32)
+	i := 0
+	if s[0] == '_' {
+		// Need a capital letter; drop the '_'.
+		t = append(t, 'X')
+		i++
+	}
+	for ; i < len(s); i++ {
+		c := s[i]
+		if c == '_' && i+1 < len(s) && isASCIILower(s[i+1]) {
+			continue
+		}
+		if isASCIIDigit(c) {
+			t = append(t, c)
+			continue
+		}
+		if isASCIILower(c) {
+			c ^= ' '
+		}
+		t = append(t, c)
+		for i+1 < len(s) && isASCIILower(s[i+1]) {
+			i++
+			t = append(t, s[i])
+		}
+	}
+	return string(t)
+}
+
+func isASCIILower(c byte) bool {
+	return 'a' <= c && c <= 'z'
+}
+
+func isASCIIDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+// sanitizePackageName replaces unallowed character in package name
+// with allowed character.
+func sanitizePackageName(pkgName string) string {
+	pkgName = strings.Replace(pkgName, ".", "_", -1)
+	pkgName = strings.Replace(pkgName, "-", "_", -1)
+	return pkgName
+}
+
+// defaultGoPackageName returns the default go package name to be used for go files generated from "f".
+// You might need to use an unique alias for the package when you import it.  Use ReserveGoPackageAlias to get a unique alias.
+func defaultGoPackageName(f *descriptor.FileDescriptorProto) string {
+	name := packageIdentityName(f)
+	return sanitizePackageName(name)
+}
+
+// packageIdentityName returns the identity of packages.
+// protoc-gen-grpc-gateway rejects CodeGenerationRequests which contains more than one packages
+// as protoc-gen-go does.
+func packageIdentityName(f *descriptor.FileDescriptorProto) string {
+	if f.Options != nil && f.Options.GoPackage != nil {
+		gopkg := f.Options.GetGoPackage()
+		idx := strings.LastIndex(gopkg, "/")
+		if idx < 0 {
+			gopkg = gopkg[idx+1:]
+		}
+
+		gopkg = gopkg[idx+1:]
+		// package name is overrided with the string after the
+		// ';' character
+		sc := strings.IndexByte(gopkg, ';')
+		if sc < 0 {
+			return sanitizePackageName(gopkg)
+
+		}
+		return sanitizePackageName(gopkg[sc+1:])
+	}
+
+	if f.Package == nil {
+		base := filepath.Base(f.GetName())
+		ext := filepath.Ext(base)
+		return strings.TrimSuffix(base, ext)
+	}
+	return f.GetPackage()
+}
+
+func emitFiles(out []*plugin.CodeGeneratorResponse_File) {
+	emitResp(&plugin.CodeGeneratorResponse{File: out})
+}
+
+func emitError(err error) {
+	emitResp(&plugin.CodeGeneratorResponse{Error: proto.String(err.Error())})
+}
+
+func emitResp(resp *plugin.CodeGeneratorResponse) {
+	buf, err := proto.Marshal(resp)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if _, err := os.Stdout.Write(buf); err != nil {
+		log.Fatal(err)
+	}
+}
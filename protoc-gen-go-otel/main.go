@@ -0,0 +1,466 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+
+	"github.com/f4tq/protoc-go-plugins/goname"
+	"github.com/f4tq/protoc-go-plugins/options"
+	"github.com/f4tq/protoc-go-plugins/protodoc"
+)
+
+var (
+	hdrTmpl = template.Must(template.New("header").Parse(`
+// Code generated by protoc-gen-go-otel. DO NOT EDIT.
+// source: {{.Source}}
+
+package {{.GoPkg}}
+
+import (
+    "context"
+
+    "go.opentelemetry.io/otel"
+    "go.opentelemetry.io/otel/attribute"
+    otelcodes "go.opentelemetry.io/otel/codes"
+    "go.opentelemetry.io/otel/trace"
+    "google.golang.org/grpc"
+    "google.golang.org/grpc/status"
+{{- range $path, $name := .Imports}}
+    {{$name}} "{{$path}}"
+{{- end}}
+)
+`))
+
+	otelTmpl = template.Must(template.New("otel").Parse(`
+// {{.Name}}TracerName is the instrumentation name of the {{.Name}} tracers.{{.Doc}}
+const {{.Name}}TracerName = {{printf "%q" .FullName}}
+
+// New{{.Name}}TracingClient wraps next so that every unary call runs in a
+// client span named after the RPC. A nil tp uses the global provider.
+// Streaming calls are passed through untraced.{{.Doc}}
+func New{{.Name}}TracingClient(next {{.Name}}Client, tp trace.TracerProvider) {{.Name}}Client {
+    if tp == nil {
+        tp = otel.GetTracerProvider()
+    }
+    return &{{.Lower}}TracingClient{ {{.Name}}Client: next, tracer: tp.Tracer({{.Name}}TracerName)}
+}
+
+type {{.Lower}}TracingClient struct {
+    {{.Name}}Client
+    tracer trace.Tracer
+}
+{{range .Methods}}
+func (c *{{$.Lower}}TracingClient) {{.Name}}(ctx context.Context, in *{{.Input}}, opts ...grpc.CallOption) (*{{.Output}}, error) {
+    ctx, span := c.tracer.Start(ctx, {{printf "%q" .Span}}, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes({{$.Lower}}{{.Name}}Attributes(in)...))
+    defer span.End()
+    resp, err := c.{{$.Name}}Client.{{.Name}}(ctx, in, opts...)
+    end{{$.Name}}Span(span, err)
+    return resp, err
+}
+{{end}}
+// New{{.Name}}TracingServer wraps next so that every unary call runs in a
+// server span named after the RPC. A nil tp uses the global provider.
+// Streaming calls are passed through untraced.{{.Doc}}
+func New{{.Name}}TracingServer(next {{.Name}}Server, tp trace.TracerProvider) {{.Name}}Server {
+    if tp == nil {
+        tp = otel.GetTracerProvider()
+    }
+    return &{{.Lower}}TracingServer{ {{.Name}}Server: next, tracer: tp.Tracer({{.Name}}TracerName)}
+}
+
+type {{.Lower}}TracingServer struct {
+    {{.Name}}Server
+    tracer trace.Tracer
+}
+{{range .Methods}}
+func (s *{{$.Lower}}TracingServer) {{.Name}}(ctx context.Context, in *{{.Input}}) (*{{.Output}}, error) {
+    ctx, span := s.tracer.Start(ctx, {{printf "%q" .Span}}, trace.WithSpanKind(trace.SpanKindServer), trace.WithAttributes({{$.Lower}}{{.Name}}Attributes(in)...))
+    defer span.End()
+    resp, err := s.{{$.Name}}Server.{{.Name}}(ctx, in)
+    end{{$.Name}}Span(span, err)
+    return resp, err
+}
+{{end}}
+{{- range .Methods}}
+// {{$.Lower}}{{.Name}}Attributes returns the span attributes for a
+// {{.Name}} call, including the request fields marked trace_attribute.
+func {{$.Lower}}{{.Name}}Attributes(in *{{.Input}}) []attribute.KeyValue {
+    return []attribute.KeyValue{
+        attribute.String("rpc.system", "grpc"),
+        attribute.String("rpc.service", {{printf "%q" $.FullName}}),
+        attribute.String("rpc.method", {{printf "%q" .Name}}),
+{{- range .Attrs}}
+        {{.}},
+{{- end}}
+    }
+}
+{{end}}
+// end{{.Name}}Span records the outcome of a {{.Name}} call on span.
+func end{{.Name}}Span(span trace.Span, err error) {
+    span.SetAttributes(attribute.Int64("rpc.grpc.status_code", int64(status.Code(err))))
+    if err != nil {
+        span.RecordError(err)
+        span.SetStatus(otelcodes.Error, err.Error())
+    }
+}
+`))
+)
+
+func main() {
+	input, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	req := new(plugin.CodeGeneratorRequest)
+	if err = proto.Unmarshal(input, req); err != nil {
+		log.Fatal(err)
+	}
+
+	out, err := generate(req)
+
+	if err != nil {
+		emitError(err)
+		return
+	}
+
+	emitFiles(out)
+}
+
+func generate(req *plugin.CodeGeneratorRequest) ([]*plugin.CodeGeneratorResponse_File, error) {
+	var files []*plugin.CodeGeneratorResponse_File
+	idx := newTypeIndex(req.GetProtoFile())
+	docs := protodoc.New(req.GetProtoFile())
+	genFileNames := make(map[string]bool)
+	for _, n := range req.FileToGenerate {
+		genFileNames[n] = true
+	}
+	for _, desc := range req.GetProtoFile() {
+		name := desc.GetName()
+		if _, ok := genFileNames[name]; !ok {
+			// Only emit output for files present in req.FileToGenerate.
+			continue
+		}
+		if len(desc.GetService()) == 0 {
+			continue
+		}
+		code, err := genCode(desc, idx, docs)
+		if err != nil {
+			return nil, err
+		}
+		if code == "" {
+			// Only streaming methods, which are not traced.
+			continue
+		}
+		formatted, err := format.Source([]byte(code))
+		if err != nil {
+			log.Printf("%v: %s", err, code)
+			return nil, err
+		}
+
+		ext := filepath.Ext(name)
+		base := strings.TrimSuffix(name, ext)
+		output := fmt.Sprintf("%s.pb.otel.go", base)
+		files = append(files, &plugin.CodeGeneratorResponse_File{
+			Name:    proto.String(output),
+			Content: proto.String(string(formatted)),
+		})
+	}
+
+	return files, nil
+}
+
+func genCode(desc *descriptor.FileDescriptorProto, idx *typeIndex, docs *protodoc.Index) (string, error) {
+	w := bytes.NewBuffer(nil)
+	imports := newImportSet(desc)
+	body := bytes.NewBuffer(nil)
+	for _, svc := range desc.GetService() {
+		fullName := svc.GetName()
+		if desc.GetPackage() != "" {
+			fullName = desc.GetPackage() + "." + svc.GetName()
+		}
+		s := &otelService{
+			Name:     svc.GetName(),
+			Lower:    strings.ToLower(svc.GetName()[:1]) + svc.GetName()[1:],
+			FullName: fullName,
+			Doc:      docs.Godoc(fullName),
+		}
+		for _, m := range svc.GetMethod() {
+			if m.GetClientStreaming() || m.GetServerStreaming() {
+				continue
+			}
+			s.Methods = append(s.Methods, &otelMethod{
+				Name:   m.GetName(),
+				Span:   fullName + "/" + m.GetName(),
+				Input:  imports.goTypeName(idx, m.GetInputType()),
+				Output: imports.goTypeName(idx, m.GetOutputType()),
+				Attrs:  traceAttributes(idx, m.GetInputType()),
+			})
+		}
+		if len(s.Methods) == 0 {
+			continue
+		}
+		if err := otelTmpl.Execute(body, s); err != nil {
+			return "", err
+		}
+	}
+	if body.Len() == 0 {
+		return "", nil
+	}
+
+	hdr := &header{
+		Source:  desc.GetName(),
+		GoPkg:   defaultGoPackageName(desc),
+		Imports: imports.names,
+	}
+	if err := hdrTmpl.Execute(w, hdr); err != nil {
+		log.Fatal(err)
+	}
+	body.WriteTo(w)
+
+	return w.String(), nil
+}
+
+// traceAttributes returns attribute.KeyValue expressions for the fields of
+// the request message typeName marked (f4tq.plugins.trace_attribute). Only
+// singular scalar and enum fields can be recorded.
+func traceAttributes(idx *typeIndex, typeName string) []string {
+	var attrs []string
+	msg := idx.messages[typeName]
+	goNames := goname.Fields(msg)
+	for _, field := range msg.GetField() {
+		if !options.TraceAttribute(field) {
+			continue
+		}
+		key := fmt.Sprintf("%q", "rpc.request."+field.GetName())
+		get := fmt.Sprintf("in.Get%s()", goNames[field.GetName()])
+		if field.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REPEATED {
+			log.Printf("%s.%s: repeated fields cannot be trace attributes", typeName, field.GetName())
+			continue
+		}
+		switch field.GetType() {
+		case descriptor.FieldDescriptorProto_TYPE_STRING:
+			attrs = append(attrs, fmt.Sprintf("attribute.String(%s, %s)", key, get))
+		case descriptor.FieldDescriptorProto_TYPE_BOOL:
+			attrs = append(attrs, fmt.Sprintf("attribute.Bool(%s, %s)", key, get))
+		case descriptor.FieldDescriptorProto_TYPE_INT32,
+			descriptor.FieldDescriptorProto_TYPE_SINT32,
+			descriptor.FieldDescriptorProto_TYPE_SFIXED32,
+			descriptor.FieldDescriptorProto_TYPE_UINT32,
+			descriptor.FieldDescriptorProto_TYPE_FIXED32,
+			descriptor.FieldDescriptorProto_TYPE_INT64,
+			descriptor.FieldDescriptorProto_TYPE_SINT64,
+			descriptor.FieldDescriptorProto_TYPE_SFIXED64,
+			descriptor.FieldDescriptorProto_TYPE_UINT64,
+			descriptor.FieldDescriptorProto_TYPE_FIXED64:
+			attrs = append(attrs, fmt.Sprintf("attribute.Int64(%s, int64(%s))", key, get))
+		case descriptor.FieldDescriptorProto_TYPE_FLOAT,
+			descriptor.FieldDescriptorProto_TYPE_DOUBLE:
+			attrs = append(attrs, fmt.Sprintf("attribute.Float64(%s, float64(%s))", key, get))
+		case descriptor.FieldDescriptorProto_TYPE_ENUM:
+			attrs = append(attrs, fmt.Sprintf("attribute.String(%s, %s.String())", key, get))
+		default:
+			log.Printf("%s.%s: %s fields cannot be trace attributes", typeName, field.GetName(), field.GetType())
+		}
+	}
+	return attrs
+}
+
+type header struct {
+	Source  string
+	GoPkg   string
+	Imports map[string]string
+}
+
+type otelService struct {
+	Name     string
+	Lower    string
+	FullName string
+	Methods  []*otelMethod
+	// Doc is the comment of the service, see protodoc.Index.Godoc.
+	Doc string
+}
+
+type otelMethod struct {
+	Name   string
+	Span   string
+	Input  string
+	Output string
+	Attrs  []string
+}
+
+// typeIndex maps fully-qualified proto type names (".pkg.Msg") to their
+// descriptors and declaring files for every file in the request.
+type typeIndex struct {
+	messages map[string]*descriptor.DescriptorProto
+	enums    map[string]*descriptor.EnumDescriptorProto
+	files    map[string]*descriptor.FileDescriptorProto
+}
+
+func newTypeIndex(files []*descriptor.FileDescriptorProto) *typeIndex {
+	idx := &typeIndex{
+		messages: make(map[string]*descriptor.DescriptorProto),
+		enums:    make(map[string]*descriptor.EnumDescriptorProto),
+		files:    make(map[string]*descriptor.FileDescriptorProto),
+	}
+	for _, f := range files {
+		prefix := ""
+		if f.GetPackage() != "" {
+			prefix = "." + f.GetPackage()
+		}
+		idx.addMessages(f, prefix, f.GetMessageType())
+		idx.addEnums(f, prefix, f.GetEnumType())
+	}
+	return idx
+}
+
+func (idx *typeIndex) addMessages(f *descriptor.FileDescriptorProto, prefix string, msgs []*descriptor.DescriptorProto) {
+	for _, m := range msgs {
+		name := prefix + "." + m.GetName()
+		idx.messages[name] = m
+		idx.files[name] = f
+		idx.addMessages(f, name, m.GetNestedType())
+		idx.addEnums(f, name, m.GetEnumType())
+	}
+}
+
+func (idx *typeIndex) addEnums(f *descriptor.FileDescriptorProto, prefix string, enums []*descriptor.EnumDescriptorProto) {
+	for _, e := range enums {
+		name := prefix + "." + e.GetName()
+		idx.enums[name] = e
+		idx.files[name] = f
+	}
+}
+
+// isMap reports whether field is a map field.
+func (idx *typeIndex) isMap(field *descriptor.FieldDescriptorProto) bool {
+	if field.GetType() != descriptor.FieldDescriptorProto_TYPE_MESSAGE {
+		return false
+	}
+	return idx.messages[field.GetTypeName()].GetOptions().GetMapEntry()
+}
+
+// goImportPath returns the import path of the Go package generated from f,
+// or "" if go_package does not name one.
+func goImportPath(f *descriptor.FileDescriptorProto) string {
+	gopkg := f.GetOptions().GetGoPackage()
+	if sc := strings.IndexByte(gopkg, ';'); sc >= 0 {
+		gopkg = gopkg[:sc]
+	}
+	if !strings.Contains(gopkg, "/") {
+		return ""
+	}
+	return gopkg
+}
+
+// importSet records the Go packages referenced by the code generated for
+// one file, keyed by import path.
+type importSet struct {
+	self  string
+	names map[string]string
+}
+
+func newImportSet(f *descriptor.FileDescriptorProto) *importSet {
+	return &importSet{self: goImportPath(f), names: make(map[string]string)}
+}
+
+// goTypeName returns the Go name of the message or enum typeName, qualified
+// with its package if it is declared outside the generated package.
+func (s *importSet) goTypeName(idx *typeIndex, typeName string) string {
+	name := localTypeName(typeName)
+	f := idx.files[typeName]
+	path := goImportPath(f)
+	if f == nil || path == "" || path == s.self {
+		return name
+	}
+	pkg := defaultGoPackageName(f)
+	s.names[path] = pkg
+	return pkg + "." + name
+}
+
+// localTypeName returns the Go name of a type declared in the file being
+// generated, e.g. ".pkg.Outer.Inner" becomes "Outer_Inner".
+func localTypeName(typeName string) string {
+	parts := strings.Split(strings.TrimPrefix(typeName, "."), ".")
+	var names []string
+	for _, p := range parts {
+		if len(p) > 0 && p[0] >= 'A' && p[0] <= 'Z' {
+			names = append(names, p)
+		}
+	}
+	return strings.Join(names, "_")
+}
+
+// sanitizePackageName replaces unallowed character in package name
+// with allowed character.
+func sanitizePackageName(pkgName string) string {
+	pkgName = strings.Replace(pkgName, ".", "_", -1)
+	pkgName = strings.Replace(pkgName, "-", "_", -1)
+	return pkgName
+}
+
+// defaultGoPackageName returns the default go package name to be used for go files generated from "f".
+// You might need to use an unique alias for the package when you import it.  Use ReserveGoPackageAlias to get a unique alias.
+func defaultGoPackageName(f *descriptor.FileDescriptorProto) string {
+	name := packageIdentityName(f)
+	return sanitizePackageName(name)
+}
+
+// packageIdentityName returns the identity of packages.
+// protoc-gen-grpc-gateway rejects CodeGenerationRequests which contains more than one packages
+// as protoc-gen-go does.
+func packageIdentityName(f *descriptor.FileDescriptorProto) string {
+	if f.Options != nil && f.Options.GoPackage != nil {
+		gopkg := f.Options.GetGoPackage()
+		idx := strings.LastIndex(gopkg, "/")
+		if idx < 0 {
+			gopkg = gopkg[idx+1:]
+		}
+
+		gopkg = gopkg[idx+1:]
+		// package name is overrided with the string after the
+		// ';' character
+		sc := strings.IndexByte(gopkg, ';')
+		if sc < 0 {
+			return sanitizePackageName(gopkg)
+
+		}
+		return sanitizePackageName(gopkg[sc+1:])
+	}
+
+	if f.Package == nil {
+		base := filepath.Base(f.GetName())
+		ext := filepath.Ext(base)
+		return strings.TrimSuffix(base, ext)
+	}
+	return f.GetPackage()
+}
+
+func emitFiles(out []*plugin.CodeGeneratorResponse_File) {
+	emitResp(&plugin.CodeGeneratorResponse{File: out})
+}
+
+func emitError(err error) {
+	emitResp(&plugin.CodeGeneratorResponse{Error: proto.String(err.Error())})
+}
+
+func emitResp(resp *plugin.CodeGeneratorResponse) {
+	buf, err := proto.Marshal(resp)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if _, err := os.Stdout.Write(buf); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,438 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+
+	"github.com/f4tq/protoc-go-plugins/goname"
+	"github.com/f4tq/protoc-go-plugins/protodoc"
+)
+
+var (
+	hdrTmpl = template.Must(template.New("header").Parse(`//go:build go1.23
+
+// Code generated by protoc-gen-go-pagination. DO NOT EDIT.
+// source: {{.Source}}
+
+package {{.GoPkg}}
+
+import (
+    "context"
+    "iter"
+
+    "github.com/f4tq/protoc-go-plugins/runtime/pagination"
+    "github.com/golang/protobuf/proto"
+    "google.golang.org/grpc"
+{{- range $path, $name := .Imports}}
+    {{$name}} "{{$path}}"
+{{- end}}
+)
+`))
+
+	pageTmpl = template.Must(template.New("page").Parse(`
+// {{.Prefix}}All returns an iterator over the {{.ItemsProto}} of every page
+// of {{.Method}}, starting at req.{{.Token}}. req is not modified. An error
+// is yielded once, after which the iteration ends.{{.Doc}}
+func {{.Prefix}}All(ctx context.Context, client {{.Service}}Client, req *{{.Input}}, opts ...grpc.CallOption) iter.Seq2[*{{.Item}}, error] {
+    return pagination.All(req.{{.Token}}, func(token string) ([]*{{.Item}}, string, error) {
+        page := proto.Clone(req).(*{{.Input}})
+        page.{{.Token}} = token
+        resp, err := client.{{.Method}}(ctx, page, opts...)
+        if err != nil {
+            return nil, "", err
+        }
+        return resp.{{.Items}}, resp.{{.NextToken}}, nil
+    })
+}
+
+// Encode{{.Prefix}}PageToken returns the {{.NextTokenProto}} continuing req
+// at cursor. The token is only accepted back for the same request with
+// another {{.SizeProto}}. Leave {{.NextTokenProto}} empty after the last
+// page instead of encoding a cursor.{{.Doc}}
+func Encode{{.Prefix}}PageToken(s *pagination.Signer, req *{{.Input}}, cursor string) (string, error) {
+    return s.Encode(cursor, {{.Lower}}PageKey(req))
+}
+
+// Decode{{.Prefix}}PageToken returns the cursor of req.{{.Token}}, "" for
+// the first page. A token that was not issued for req is an InvalidArgument
+// error.{{.Doc}}
+func Decode{{.Prefix}}PageToken(s *pagination.Signer, req *{{.Input}}) (string, error) {
+    if req.{{.Token}} == "" {
+        return "", nil
+    }
+    return s.Decode(req.{{.Token}}, {{.Lower}}PageKey(req))
+}
+
+// {{.Lower}}PageKey returns req without the fields that may change from
+// one page to the next.
+func {{.Lower}}PageKey(req *{{.Input}}) *{{.Input}} {
+    key := proto.Clone(req).(*{{.Input}})
+    key.{{.Size}} = 0
+    key.{{.Token}} = ""
+    return key
+}
+`))
+)
+
+func main() {
+	input, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	req := new(plugin.CodeGeneratorRequest)
+	if err = proto.Unmarshal(input, req); err != nil {
+		log.Fatal(err)
+	}
+
+	out, err := generate(req)
+
+	if err != nil {
+		emitError(err)
+		return
+	}
+
+	emitFiles(out)
+}
+
+func generate(req *plugin.CodeGeneratorRequest) ([]*plugin.CodeGeneratorResponse_File, error) {
+	var files []*plugin.CodeGeneratorResponse_File
+	idx := newTypeIndex(req.GetProtoFile())
+	docs := protodoc.New(req.GetProtoFile())
+	genFileNames := make(map[string]bool)
+	for _, n := range req.FileToGenerate {
+		genFileNames[n] = true
+	}
+	for _, desc := range req.GetProtoFile() {
+		name := desc.GetName()
+		if _, ok := genFileNames[name]; !ok {
+			// Only emit output for files present in req.FileToGenerate.
+			continue
+		}
+		code, err := genCode(desc, idx, docs)
+		if err != nil {
+			return nil, err
+		}
+		if code == "" {
+			// No method is paginated.
+			continue
+		}
+		formatted, err := format.Source([]byte(code))
+		if err != nil {
+			log.Printf("%v: %s", err, code)
+			return nil, err
+		}
+
+		ext := filepath.Ext(name)
+		base := strings.TrimSuffix(name, ext)
+		output := fmt.Sprintf("%s.pb.pagination.go", base)
+		files = append(files, &plugin.CodeGeneratorResponse_File{
+			Name:    proto.String(output),
+			Content: proto.String(string(formatted)),
+		})
+	}
+
+	return files, nil
+}
+
+func genCode(desc *descriptor.FileDescriptorProto, idx *typeIndex, docs *protodoc.Index) (string, error) {
+	w := bytes.NewBuffer(nil)
+	imports := newImportSet(desc)
+	body := bytes.NewBuffer(nil)
+	for _, svc := range desc.GetService() {
+		for _, m := range svc.GetMethod() {
+			if m.GetClientStreaming() || m.GetServerStreaming() {
+				continue
+			}
+			p := pagedMethod(idx, m)
+			if p == nil {
+				continue
+			}
+			p.Service = svc.GetName()
+			p.Method = m.GetName()
+			p.Prefix = svc.GetName() + m.GetName()
+			p.Lower = strings.ToLower(p.Prefix[:1]) + p.Prefix[1:]
+			p.Input = imports.goTypeName(idx, m.GetInputType())
+			p.Item = imports.goTypeName(idx, p.itemType)
+			p.Doc = docs.Godoc(qualify(desc.GetPackage(), svc.GetName()) + "." + m.GetName())
+			if err := pageTmpl.Execute(body, p); err != nil {
+				return "", err
+			}
+		}
+	}
+	if body.Len() == 0 {
+		return "", nil
+	}
+
+	hdr := &header{
+		Source:  desc.GetName(),
+		GoPkg:   defaultGoPackageName(desc),
+		Imports: imports.names,
+	}
+	if err := hdrTmpl.Execute(w, hdr); err != nil {
+		log.Fatal(err)
+	}
+	body.WriteTo(w)
+
+	return w.String(), nil
+}
+
+// pagedMethod returns the pagination fields of m if it follows AIP-158: the
+// request has an int32 page_size and a string page_token, and the response
+// has a string next_page_token and a repeated message field, the first of
+// which holds the items.
+func pagedMethod(idx *typeIndex, m *descriptor.MethodDescriptorProto) *page {
+	in, out := idx.messages[m.GetInputType()], idx.messages[m.GetOutputType()]
+	if in == nil || out == nil {
+		return nil
+	}
+	size := singularField(in, "page_size", descriptor.FieldDescriptorProto_TYPE_INT32)
+	token := singularField(in, "page_token", descriptor.FieldDescriptorProto_TYPE_STRING)
+	next := singularField(out, "next_page_token", descriptor.FieldDescriptorProto_TYPE_STRING)
+	if size == nil || token == nil || next == nil {
+		return nil
+	}
+	for _, f := range out.GetField() {
+		if f.GetLabel() != descriptor.FieldDescriptorProto_LABEL_REPEATED ||
+			f.GetType() != descriptor.FieldDescriptorProto_TYPE_MESSAGE || idx.isMap(f) {
+			continue
+		}
+		return &page{
+			Size:           goname.Fields(in)[size.GetName()],
+			SizeProto:      size.GetName(),
+			Token:          goname.Fields(in)[token.GetName()],
+			NextToken:      goname.Fields(out)[next.GetName()],
+			NextTokenProto: next.GetName(),
+			Items:          goname.Fields(out)[f.GetName()],
+			ItemsProto:     f.GetName(),
+			itemType:       f.GetTypeName(),
+		}
+	}
+	log.Printf("%s: %s has no repeated message field, skipped", m.GetName(), m.GetOutputType())
+	return nil
+}
+
+// singularField returns the field name of msg if it is a singular field of
+// type typ outside any oneof.
+func singularField(msg *descriptor.DescriptorProto, name string, typ descriptor.FieldDescriptorProto_Type) *descriptor.FieldDescriptorProto {
+	for _, f := range msg.GetField() {
+		if f.GetName() != name {
+			continue
+		}
+		if f.GetType() != typ || f.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REPEATED || f.OneofIndex != nil {
+			return nil
+		}
+		return f
+	}
+	return nil
+}
+
+type header struct {
+	Source  string
+	GoPkg   string
+	Imports map[string]string
+}
+
+type page struct {
+	Service        string
+	Method         string
+	Prefix         string
+	Lower          string
+	Input          string
+	Item           string
+	Size           string
+	SizeProto      string
+	Token          string
+	NextToken      string
+	NextTokenProto string
+	Items          string
+	ItemsProto     string
+	// Doc is the comment of the method, see protodoc.Index.Godoc.
+	Doc string
+
+	itemType string
+}
+
+// qualify returns the full proto name of name, declared in scope.
+func qualify(scope, name string) string {
+	if scope == "" {
+		return name
+	}
+	return scope + "." + name
+}
+
+// typeIndex maps fully-qualified proto type names (".pkg.Msg") to their
+// descriptors and declaring files for every file in the request.
+type typeIndex struct {
+	messages map[string]*descriptor.DescriptorProto
+	enums    map[string]*descriptor.EnumDescriptorProto
+	files    map[string]*descriptor.FileDescriptorProto
+}
+
+func newTypeIndex(files []*descriptor.FileDescriptorProto) *typeIndex {
+	idx := &typeIndex{
+		messages: make(map[string]*descriptor.DescriptorProto),
+		enums:    make(map[string]*descriptor.EnumDescriptorProto),
+		files:    make(map[string]*descriptor.FileDescriptorProto),
+	}
+	for _, f := range files {
+		prefix := ""
+		if f.GetPackage() != "" {
+			prefix = "." + f.GetPackage()
+		}
+		idx.addMessages(f, prefix, f.GetMessageType())
+		idx.addEnums(f, prefix, f.GetEnumType())
+	}
+	return idx
+}
+
+func (idx *typeIndex) addMessages(f *descriptor.FileDescriptorProto, prefix string, msgs []*descriptor.DescriptorProto) {
+	for _, m := range msgs {
+		name := prefix + "." + m.GetName()
+		idx.messages[name] = m
+		idx.files[name] = f
+		idx.addMessages(f, name, m.GetNestedType())
+		idx.addEnums(f, name, m.GetEnumType())
+	}
+}
+
+func (idx *typeIndex) addEnums(f *descriptor.FileDescriptorProto, prefix string, enums []*descriptor.EnumDescriptorProto) {
+	for _, e := range enums {
+		name := prefix + "." + e.GetName()
+		idx.enums[name] = e
+		idx.files[name] = f
+	}
+}
+
+// isMap reports whether field is a map field.
+func (idx *typeIndex) isMap(field *descriptor.FieldDescriptorProto) bool {
+	if field.GetType() != descriptor.FieldDescriptorProto_TYPE_MESSAGE {
+		return false
+	}
+	return idx.messages[field.GetTypeName()].GetOptions().GetMapEntry()
+}
+
+// goImportPath returns the import path of the Go package generated from f,
+// or "" if go_package does not name one.
+func goImportPath(f *descriptor.FileDescriptorProto) string {
+	gopkg := f.GetOptions().GetGoPackage()
+	if sc := strings.IndexByte(gopkg, ';'); sc >= 0 {
+		gopkg = gopkg[:sc]
+	}
+	if !strings.Contains(gopkg, "/") {
+		return ""
+	}
+	return gopkg
+}
+
+// importSet records the Go packages referenced by the code generated for
+// one file, keyed by import path.
+type importSet struct {
+	self  string
+	names map[string]string
+}
+
+func newImportSet(f *descriptor.FileDescriptorProto) *importSet {
+	return &importSet{self: goImportPath(f), names: make(map[string]string)}
+}
+
+// goTypeName returns the Go name of the message or enum typeName, qualified
+// with its package if it is declared outside the generated package.
+func (s *importSet) goTypeName(idx *typeIndex, typeName string) string {
+	name := localTypeName(typeName)
+	f := idx.files[typeName]
+	path := goImportPath(f)
+	if f == nil || path == "" || path == s.self {
+		return name
+	}
+	pkg := defaultGoPackageName(f)
+	s.names[path] = pkg
+	return pkg + "." + name
+}
+
+// localTypeName returns the Go name of a type declared in the file being
+// generated, e.g. ".pkg.Outer.Inner" becomes "Outer_Inner".
+func localTypeName(typeName string) string {
+	parts := strings.Split(strings.TrimPrefix(typeName, "."), ".")
+	var names []string
+	for _, p := range parts {
+		if len(p) > 0 && p[0] >= 'A' && p[0] <= 'Z' {
+			names = append(names, p)
+		}
+	}
+	return strings.Join(names, "_")
+}
+
+// sanitizePackageName replaces unallowed character in package name
+// with allowed character.
+func sanitizePackageName(pkgName string) string {
+	pkgName = strings.Replace(pkgName, ".", "_", -1)
+	pkgName = strings.Replace(pkgName, "-", "_", -1)
+	return pkgName
+}
+
+// defaultGoPackageName returns the default go package name to be used for go files generated from "f".
+// You might need to use an unique alias for the package when you import it.  Use ReserveGoPackageAlias to get a unique alias.
+func defaultGoPackageName(f *descriptor.FileDescriptorProto) string {
+	name := packageIdentityName(f)
+	return sanitizePackageName(name)
+}
+
+// packageIdentityName returns the identity of packages.
+// protoc-gen-grpc-gateway rejects CodeGenerationRequests which contains more than one packages
+// as protoc-gen-go does.
+func packageIdentityName(f *descriptor.FileDescriptorProto) string {
+	if f.Options != nil && f.Options.GoPackage != nil {
+		gopkg := f.Options.GetGoPackage()
+		idx := strings.LastIndex(gopkg, "/")
+		if idx < 0 {
+			gopkg = gopkg[idx+1:]
+		}
+
+		gopkg = gopkg[idx+1:]
+		// package name is overrided with the string after the
+		// ';' character
+		sc := strings.IndexByte(gopkg, ';')
+		if sc < 0 {
+			return sanitizePackageName(gopkg)
+
+		}
+		return sanitizePackageName(gopkg[sc+1:])
+	}
+
+	if f.Package == nil {
+		base := filepath.Base(f.GetName())
+		ext := filepath.Ext(base)
+		return strings.TrimSuffix(base, ext)
+	}
+	return f.GetPackage()
+}
+
+func emitFiles(out []*plugin.CodeGeneratorResponse_File) {
+	emitResp(&plugin.CodeGeneratorResponse{File: out})
+}
+
+func emitError(err error) {
+	emitResp(&plugin.CodeGeneratorResponse{Error: proto.String(err.Error())})
+}
+
+func emitResp(resp *plugin.CodeGeneratorResponse) {
+	buf, err := proto.Marshal(resp)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if _, err := os.Stdout.Write(buf); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,467 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+
+	"github.com/f4tq/protoc-go-plugins/options"
+	"github.com/f4tq/protoc-go-plugins/protodoc"
+)
+
+var (
+	hdrTmpl = template.Must(template.New("header").Parse(`
+// Code generated by protoc-gen-go-prometheus. DO NOT EDIT.
+// source: {{.Source}}
+
+package {{.GoPkg}}
+
+import (
+    "context"
+    "time"
+
+    "github.com/prometheus/client_golang/prometheus"
+    "google.golang.org/grpc"
+    "google.golang.org/grpc/status"
+{{- range $path, $name := .Imports}}
+    {{$name}} "{{$path}}"
+{{- end}}
+)
+`))
+
+	metricsTmpl = template.Must(template.New("metrics").Parse(`
+// {{.Name}}Metrics holds the Prometheus collectors of {{.FullName}}.
+// Requests are counted and timed per method and gRPC status code.{{.Doc}}
+type {{.Name}}Metrics struct {
+    ClientRequests *prometheus.CounterVec
+    ClientLatency  *prometheus.HistogramVec
+    ServerRequests *prometheus.CounterVec
+    ServerLatency  *prometheus.HistogramVec
+}
+
+// New{{.Name}}Metrics creates the {{.Name}} collectors. They must be
+// registered with RegisterMetrics before they are exported.{{.Doc}}
+func New{{.Name}}Metrics() *{{.Name}}Metrics {
+    buckets := {{.Buckets}}
+    return &{{.Name}}Metrics{
+        ClientRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+            Subsystem: {{printf "%q" .Subsystem}},
+            Name:      "client_requests_total",
+            Help:      "Number of {{.FullName}} calls completed by the client.",
+        }, []string{"method", "code"}),
+        ClientLatency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+            Subsystem: {{printf "%q" .Subsystem}},
+            Name:      "client_request_duration_seconds",
+            Help:      "Latency of {{.FullName}} calls seen by the client.",
+            Buckets:   buckets,
+        }, []string{"method", "code"}),
+        ServerRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+            Subsystem: {{printf "%q" .Subsystem}},
+            Name:      "server_requests_total",
+            Help:      "Number of {{.FullName}} calls handled by the server.",
+        }, []string{"method", "code"}),
+        ServerLatency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+            Subsystem: {{printf "%q" .Subsystem}},
+            Name:      "server_request_duration_seconds",
+            Help:      "Latency of {{.FullName}} calls handled by the server.",
+            Buckets:   buckets,
+        }, []string{"method", "code"}),
+    }
+}
+
+// RegisterMetrics registers the collectors of m with registerer.
+func (m *{{.Name}}Metrics) RegisterMetrics(registerer prometheus.Registerer) error {
+    for _, c := range []prometheus.Collector{m.ClientRequests, m.ClientLatency, m.ServerRequests, m.ServerLatency} {
+        if err := registerer.Register(c); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// Client wraps next so that its unary calls are recorded in m. Streaming
+// calls are passed through.
+func (m *{{.Name}}Metrics) Client(next {{.Name}}Client) {{.Name}}Client {
+    return &{{.Lower}}MetricsClient{ {{.Name}}Client: next, metrics: m}
+}
+
+// Server wraps next so that its unary calls are recorded in m. Streaming
+// calls are passed through.
+func (m *{{.Name}}Metrics) Server(next {{.Name}}Server) {{.Name}}Server {
+    return &{{.Lower}}MetricsServer{ {{.Name}}Server: next, metrics: m}
+}
+
+type {{.Lower}}MetricsClient struct {
+    {{.Name}}Client
+    metrics *{{.Name}}Metrics
+}
+{{range .Methods}}
+func (c *{{$.Lower}}MetricsClient) {{.Name}}(ctx context.Context, in *{{.Input}}, opts ...grpc.CallOption) (*{{.Output}}, error) {
+    start := time.Now()
+    resp, err := c.{{$.Name}}Client.{{.Name}}(ctx, in, opts...)
+    observe{{$.Name}}(c.metrics.ClientRequests, c.metrics.ClientLatency, {{printf "%q" .Name}}, start, err)
+    return resp, err
+}
+{{end}}
+type {{.Lower}}MetricsServer struct {
+    {{.Name}}Server
+    metrics *{{.Name}}Metrics
+}
+{{range .Methods}}
+func (s *{{$.Lower}}MetricsServer) {{.Name}}(ctx context.Context, in *{{.Input}}) (*{{.Output}}, error) {
+    start := time.Now()
+    resp, err := s.{{$.Name}}Server.{{.Name}}(ctx, in)
+    observe{{$.Name}}(s.metrics.ServerRequests, s.metrics.ServerLatency, {{printf "%q" .Name}}, start, err)
+    return resp, err
+}
+{{end}}
+func observe{{.Name}}(requests *prometheus.CounterVec, latency *prometheus.HistogramVec, method string, start time.Time, err error) {
+    code := status.Code(err).String()
+    requests.WithLabelValues(method, code).Inc()
+    latency.WithLabelValues(method, code).Observe(time.Since(start).Seconds())
+}
+`))
+)
+
+func main() {
+	input, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	req := new(plugin.CodeGeneratorRequest)
+	if err = proto.Unmarshal(input, req); err != nil {
+		log.Fatal(err)
+	}
+
+	out, err := generate(req)
+
+	if err != nil {
+		emitError(err)
+		return
+	}
+
+	emitFiles(out)
+}
+
+func generate(req *plugin.CodeGeneratorRequest) ([]*plugin.CodeGeneratorResponse_File, error) {
+	var files []*plugin.CodeGeneratorResponse_File
+	idx := newTypeIndex(req.GetProtoFile())
+	docs := protodoc.New(req.GetProtoFile())
+	genFileNames := make(map[string]bool)
+	for _, n := range req.FileToGenerate {
+		genFileNames[n] = true
+	}
+	for _, desc := range req.GetProtoFile() {
+		name := desc.GetName()
+		if _, ok := genFileNames[name]; !ok {
+			// Only emit output for files present in req.FileToGenerate.
+			continue
+		}
+		code, err := genCode(desc, idx, docs)
+		if err != nil {
+			return nil, err
+		}
+		if code == "" {
+			// No service with unary methods to instrument.
+			continue
+		}
+		formatted, err := format.Source([]byte(code))
+		if err != nil {
+			log.Printf("%v: %s", err, code)
+			return nil, err
+		}
+
+		ext := filepath.Ext(name)
+		base := strings.TrimSuffix(name, ext)
+		output := fmt.Sprintf("%s.pb.prometheus.go", base)
+		files = append(files, &plugin.CodeGeneratorResponse_File{
+			Name:    proto.String(output),
+			Content: proto.String(string(formatted)),
+		})
+	}
+
+	return files, nil
+}
+
+func genCode(desc *descriptor.FileDescriptorProto, idx *typeIndex, docs *protodoc.Index) (string, error) {
+	w := bytes.NewBuffer(nil)
+	imports := newImportSet(desc)
+	body := bytes.NewBuffer(nil)
+	for _, svc := range desc.GetService() {
+		fullName := svc.GetName()
+		if desc.GetPackage() != "" {
+			fullName = desc.GetPackage() + "." + svc.GetName()
+		}
+		s := &metricsService{
+			Name:      svc.GetName(),
+			Lower:     strings.ToLower(svc.GetName()[:1]) + svc.GetName()[1:],
+			FullName:  fullName,
+			Subsystem: snakeCase(fullName),
+			Buckets:   "prometheus.DefBuckets",
+			Doc:       docs.Godoc(fullName),
+		}
+		if buckets := options.LatencyBuckets(svc); len(buckets) > 0 {
+			var bounds []string
+			for i, b := range buckets {
+				if i > 0 && b <= buckets[i-1] {
+					return "", fmt.Errorf("%s: latency_buckets must be increasing", fullName)
+				}
+				bounds = append(bounds, strconv.FormatFloat(b, 'g', -1, 64))
+			}
+			s.Buckets = fmt.Sprintf("[]float64{%s}", strings.Join(bounds, ", "))
+		}
+		for _, m := range svc.GetMethod() {
+			if m.GetClientStreaming() || m.GetServerStreaming() {
+				continue
+			}
+			s.Methods = append(s.Methods, &metricsMethod{
+				Name:   m.GetName(),
+				Input:  imports.goTypeName(idx, m.GetInputType()),
+				Output: imports.goTypeName(idx, m.GetOutputType()),
+			})
+		}
+		if len(s.Methods) == 0 {
+			continue
+		}
+		if err := metricsTmpl.Execute(body, s); err != nil {
+			return "", err
+		}
+	}
+	if body.Len() == 0 {
+		return "", nil
+	}
+
+	hdr := &header{
+		Source:  desc.GetName(),
+		GoPkg:   defaultGoPackageName(desc),
+		Imports: imports.names,
+	}
+	if err := hdrTmpl.Execute(w, hdr); err != nil {
+		log.Fatal(err)
+	}
+	body.WriteTo(w)
+
+	return w.String(), nil
+}
+
+// snakeCase converts a dotted, CamelCase proto name such as
+// "example.v1.UserService" to a metric name component such as
+// "example_v1_user_service".
+func snakeCase(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '.':
+			b.WriteByte('_')
+		case 'A' <= c && c <= 'Z':
+			if i > 0 && s[i-1] != '.' && !('A' <= s[i-1] && s[i-1] <= 'Z') {
+				b.WriteByte('_')
+			}
+			b.WriteByte(c + 'a' - 'A')
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+type header struct {
+	Source  string
+	GoPkg   string
+	Imports map[string]string
+}
+
+type metricsService struct {
+	Name      string
+	Lower     string
+	FullName  string
+	Subsystem string
+	Buckets   string
+	Methods   []*metricsMethod
+	// Doc is the comment of the service, see protodoc.Index.Godoc.
+	Doc string
+}
+
+type metricsMethod struct {
+	Name   string
+	Input  string
+	Output string
+}
+
+// typeIndex maps fully-qualified proto type names (".pkg.Msg") to their
+// descriptors and declaring files for every file in the request.
+type typeIndex struct {
+	messages map[string]*descriptor.DescriptorProto
+	enums    map[string]*descriptor.EnumDescriptorProto
+	files    map[string]*descriptor.FileDescriptorProto
+}
+
+func newTypeIndex(files []*descriptor.FileDescriptorProto) *typeIndex {
+	idx := &typeIndex{
+		messages: make(map[string]*descriptor.DescriptorProto),
+		enums:    make(map[string]*descriptor.EnumDescriptorProto),
+		files:    make(map[string]*descriptor.FileDescriptorProto),
+	}
+	for _, f := range files {
+		prefix := ""
+		if f.GetPackage() != "" {
+			prefix = "." + f.GetPackage()
+		}
+		idx.addMessages(f, prefix, f.GetMessageType())
+		idx.addEnums(f, prefix, f.GetEnumType())
+	}
+	return idx
+}
+
+func (idx *typeIndex) addMessages(f *descriptor.FileDescriptorProto, prefix string, msgs []*descriptor.DescriptorProto) {
+	for _, m := range msgs {
+		name := prefix + "." + m.GetName()
+		idx.messages[name] = m
+		idx.files[name] = f
+		idx.addMessages(f, name, m.GetNestedType())
+		idx.addEnums(f, name, m.GetEnumType())
+	}
+}
+
+func (idx *typeIndex) addEnums(f *descriptor.FileDescriptorProto, prefix string, enums []*descriptor.EnumDescriptorProto) {
+	for _, e := range enums {
+		name := prefix + "." + e.GetName()
+		idx.enums[name] = e
+		idx.files[name] = f
+	}
+}
+
+// isMap reports whether field is a map field.
+func (idx *typeIndex) isMap(field *descriptor.FieldDescriptorProto) bool {
+	if field.GetType() != descriptor.FieldDescriptorProto_TYPE_MESSAGE {
+		return false
+	}
+	return idx.messages[field.GetTypeName()].GetOptions().GetMapEntry()
+}
+
+// goImportPath returns the import path of the Go package generated from f,
+// or "" if go_package does not name one.
+func goImportPath(f *descriptor.FileDescriptorProto) string {
+	gopkg := f.GetOptions().GetGoPackage()
+	if sc := strings.IndexByte(gopkg, ';'); sc >= 0 {
+		gopkg = gopkg[:sc]
+	}
+	if !strings.Contains(gopkg, "/") {
+		return ""
+	}
+	return gopkg
+}
+
+// importSet records the Go packages referenced by the code generated for
+// one file, keyed by import path.
+type importSet struct {
+	self  string
+	names map[string]string
+}
+
+func newImportSet(f *descriptor.FileDescriptorProto) *importSet {
+	return &importSet{self: goImportPath(f), names: make(map[string]string)}
+}
+
+// goTypeName returns the Go name of the message or enum typeName, qualified
+// with its package if it is declared outside the generated package.
+func (s *importSet) goTypeName(idx *typeIndex, typeName string) string {
+	name := localTypeName(typeName)
+	f := idx.files[typeName]
+	path := goImportPath(f)
+	if f == nil || path == "" || path == s.self {
+		return name
+	}
+	pkg := defaultGoPackageName(f)
+	s.names[path] = pkg
+	return pkg + "." + name
+}
+
+// localTypeName returns the Go name of a type declared in the file being
+// generated, e.g. ".pkg.Outer.Inner" becomes "Outer_Inner".
+func localTypeName(typeName string) string {
+	parts := strings.Split(strings.TrimPrefix(typeName, "."), ".")
+	var names []string
+	for _, p := range parts {
+		if len(p) > 0 && p[0] >= 'A' && p[0] <= 'Z' {
+			names = append(names, p)
+		}
+	}
+	return strings.Join(names, "_")
+}
+
+// sanitizePackageName replaces unallowed character in package name
+// with allowed character.
+func sanitizePackageName(pkgName string) string {
+	pkgName = strings.Replace(pkgName, ".", "_", -1)
+	pkgName = strings.Replace(pkgName, "-", "_", -1)
+	return pkgName
+}
+
+// defaultGoPackageName returns the default go package name to be used for go files generated from "f".
+// You might need to use an unique alias for the package when you import it.  Use ReserveGoPackageAlias to get a unique alias.
+func defaultGoPackageName(f *descriptor.FileDescriptorProto) string {
+	name := packageIdentityName(f)
+	return sanitizePackageName(name)
+}
+
+// packageIdentityName returns the identity of packages.
+// protoc-gen-grpc-gateway rejects CodeGenerationRequests which contains more than one packages
+// as protoc-gen-go does.
+func packageIdentityName(f *descriptor.FileDescriptorProto) string {
+	if f.Options != nil && f.Options.GoPackage != nil {
+		gopkg := f.Options.GetGoPackage()
+		idx := strings.LastIndex(gopkg, "/")
+		if idx < 0 {
+			gopkg = gopkg[idx+1:]
+		}
+
+		gopkg = gopkg[idx+1:]
+		// package name is overrided with the string after the
+		// ';' character
+		sc := strings.IndexByte(gopkg, ';')
+		if sc < 0 {
+			return sanitizePackageName(gopkg)
+
+		}
+		return sanitizePackageName(gopkg[sc+1:])
+	}
+
+	if f.Package == nil {
+		base := filepath.Base(f.GetName())
+		ext := filepath.Ext(base)
+		return strings.TrimSuffix(base, ext)
+	}
+	return f.GetPackage()
+}
+
+func emitFiles(out []*plugin.CodeGeneratorResponse_File) {
+	emitResp(&plugin.CodeGeneratorResponse{File: out})
+}
+
+func emitError(err error) {
+	emitResp(&plugin.CodeGeneratorResponse{Error: proto.String(err.Error())})
+}
+
+func emitResp(resp *plugin.CodeGeneratorResponse) {
+	buf, err := proto.Marshal(resp)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if _, err := os.Stdout.Write(buf); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,738 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+
+	"github.com/f4tq/protoc-go-plugins/goname"
+	"github.com/f4tq/protoc-go-plugins/options"
+	"github.com/f4tq/protoc-go-plugins/protodoc"
+)
+
+var (
+	hdrTmpl = template.Must(template.New("header").Parse(`
+// Code generated by protoc-gen-go-rapid. DO NOT EDIT.
+// source: {{.Source}}
+
+package {{.GoPkg}}
+
+import (
+{{- if .Math}}
+    "math"
+{{- end}}
+
+    "github.com/f4tq/protoc-go-plugins/runtime/rapidpb"
+    "pgregory.net/rapid"
+{{- range $path, $name := .Imports}}
+    {{$name}} "{{$path}}"
+{{- end}}
+)
+`))
+
+	genTmpl = template.Must(template.New("gen").Parse(`
+// Gen{{.Name}} returns a generator of random {{.Name}} messages satisfying
+// their (f4tq.plugins.validate) rules, whose optional message fields nest
+// down to rapidpb.MaxDepth.{{.Doc}}
+func Gen{{.Name}}() *rapid.Generator[*{{.Name}}] {
+    return gen{{.Name}}(rapidpb.MaxDepth)
+}
+
+// gen{{.Name}} returns the generator of {{.Name}} messages whose optional
+// message fields nest down to depth.
+func gen{{.Name}}(depth int) *rapid.Generator[*{{.Name}}] {
+    return rapid.Custom(func(t *rapid.T) *{{.Name}} {
+        m := new({{.Name}})
+{{.Fields -}}
+        return m
+    })
+}
+`))
+)
+
+func main() {
+	input, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	req := new(plugin.CodeGeneratorRequest)
+	if err = proto.Unmarshal(input, req); err != nil {
+		log.Fatal(err)
+	}
+
+	out, err := generate(req)
+
+	if err != nil {
+		emitError(err)
+		return
+	}
+
+	emitFiles(out)
+}
+
+// generate writes the generators of the messages of the files to
+// generate, nested ones included, to <file>.pb.rapid.go. The message
+// fields of the messages of other Go packages, or of files not generated,
+// are left unset, or set to empty messages where required.
+func generate(req *plugin.CodeGeneratorRequest) ([]*plugin.CodeGeneratorResponse_File, error) {
+	var files []*plugin.CodeGeneratorResponse_File
+	idx := newTypeIndex(req.GetProtoFile())
+	docs := protodoc.New(req.GetProtoFile())
+	genFileNames := make(map[string]bool)
+	for _, n := range req.FileToGenerate {
+		genFileNames[n] = true
+	}
+	for _, desc := range req.GetProtoFile() {
+		name := desc.GetName()
+		if _, ok := genFileNames[name]; !ok {
+			// Only emit output for files present in req.FileToGenerate.
+			continue
+		}
+		code, err := genCode(desc, idx, docs, genFileNames)
+		if err != nil {
+			return nil, err
+		}
+		if code == "" {
+			// The file declares no messages.
+			continue
+		}
+		formatted, err := format.Source([]byte(code))
+		if err != nil {
+			log.Printf("%v: %s", err, code)
+			return nil, err
+		}
+
+		ext := filepath.Ext(name)
+		base := strings.TrimSuffix(name, ext)
+		output := fmt.Sprintf("%s.pb.rapid.go", base)
+		files = append(files, &plugin.CodeGeneratorResponse_File{
+			Name:    proto.String(output),
+			Content: proto.String(string(formatted)),
+		})
+	}
+
+	return files, nil
+}
+
+func genCode(desc *descriptor.FileDescriptorProto, idx *typeIndex, docs *protodoc.Index, genFileNames map[string]bool) (string, error) {
+	g := &rapidGen{
+		desc:         desc,
+		idx:          idx,
+		imports:      newImportSet(desc),
+		genFileNames: genFileNames,
+		proto3:       desc.GetSyntax() == "proto3",
+	}
+	body := bytes.NewBuffer(nil)
+	scope := strings.TrimSuffix("."+desc.GetPackage(), ".")
+	if err := g.messages(body, docs, scope, desc.GetMessageType()); err != nil {
+		return "", fmt.Errorf("%s: %v", desc.GetName(), err)
+	}
+	if body.Len() == 0 {
+		return "", nil
+	}
+
+	w := bytes.NewBuffer(nil)
+	hdr := &header{
+		Source:  desc.GetName(),
+		GoPkg:   defaultGoPackageName(desc),
+		Imports: g.imports.names,
+		Math:    g.math,
+	}
+	if err := hdrTmpl.Execute(w, hdr); err != nil {
+		log.Fatal(err)
+	}
+	body.WriteTo(w)
+
+	return w.String(), nil
+}
+
+type header struct {
+	Source  string
+	GoPkg   string
+	Imports map[string]string
+	Math    bool
+}
+
+type genMessage struct {
+	Name string
+	// Fields are the statements setting the fields of m.
+	Fields string
+	// Doc is the comment of the message, see protodoc.Index.Godoc.
+	Doc string
+}
+
+type rapidGen struct {
+	desc         *descriptor.FileDescriptorProto
+	idx          *typeIndex
+	imports      *importSet
+	genFileNames map[string]bool
+	proto3       bool
+	// math records whether the bounds of a generator refer to package math.
+	math bool
+}
+
+// messages writes the generators of msgs and of the messages nested in
+// them. scope is the full proto name of their parent, with a leading dot.
+func (g *rapidGen) messages(w *bytes.Buffer, docs *protodoc.Index, scope string, msgs []*descriptor.DescriptorProto) error {
+	for _, msg := range msgs {
+		if msg.GetOptions().GetMapEntry() {
+			continue
+		}
+		typeName := scope + "." + msg.GetName()
+		name := strings.TrimPrefix(typeName, ".")
+		if g.requiredCycle(typeName, typeName, make(map[string]bool)) {
+			return fmt.Errorf("%s: required message fields lead back to it, so no finite message is valid", name)
+		}
+		m := &genMessage{Name: localTypeName(typeName), Doc: docs.Godoc(typeName)}
+		fields := bytes.NewBuffer(nil)
+		goNames := goname.Fields(msg)
+		oneofs := make(map[int32][]*descriptor.FieldDescriptorProto)
+		for _, field := range msg.GetField() {
+			if field.OneofIndex != nil && !field.GetProto3Optional() {
+				i := field.GetOneofIndex()
+				if len(oneofs[i]) == 0 {
+					// The oneof is drawn at the place of its first member.
+					fmt.Fprintf(fields, "%s", oneofMarker(i))
+				}
+				oneofs[i] = append(oneofs[i], field)
+				continue
+			}
+			if err := g.field(fields, field, goNames[field.GetName()]); err != nil {
+				return fmt.Errorf("%s.%s: %v", name, field.GetName(), err)
+			}
+		}
+		code := fields.String()
+		for i, members := range oneofs {
+			oneof := bytes.NewBuffer(nil)
+			if err := g.oneof(oneof, m.Name, goNames, goname.Wrappers(msg), msg.GetOneofDecl()[i], members); err != nil {
+				return fmt.Errorf("%s.%s: %v", name, msg.GetOneofDecl()[i].GetName(), err)
+			}
+			code = strings.Replace(code, oneofMarker(i), oneof.String(), 1)
+		}
+		m.Fields = code
+		if err := genTmpl.Execute(w, m); err != nil {
+			return err
+		}
+		if err := g.messages(w, docs, typeName, msg.GetNestedType()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// oneofMarker marks the place of the oneof of index i among the statements
+// of the fields of a message.
+func oneofMarker(i int32) string {
+	return fmt.Sprintf("\x00oneof %d\x00", i)
+}
+
+// field writes the statement setting field, whose Go name is goName, unless
+// it is left unset.
+func (g *rapidGen) field(w *bytes.Buffer, field *descriptor.FieldDescriptorProto, goName string) error {
+	rules := options.Rules(field)
+	if rules == nil {
+		rules = new(options.FieldRules)
+	}
+	label := strconv.Quote(field.GetName())
+	required := rules.GetRequired() || field.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REQUIRED
+
+	if field.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REPEATED {
+		min, max := bound(rules.MinItems, 0), bound(rules.MaxItems, -1)
+		if required && min == 0 {
+			min = 1
+		}
+		if max >= 0 && min > max {
+			return fmt.Errorf("min_items is greater than max_items")
+		}
+		// The values of map fields are drawn without rules, as
+		// protoc-gen-go-validate takes none for them.
+		value := field
+		if g.idx.isMap(field) {
+			value = g.idx.messages[field.GetTypeName()].GetField()[1]
+			rules = new(options.FieldRules)
+		}
+		var elem, maxExpr string
+		switch {
+		case !isMessage(value):
+			gen, err := g.valueGen(value, rules, false)
+			if err != nil {
+				return err
+			}
+			elem, maxExpr = gen, strconv.Itoa(max)
+		case g.local(value.GetTypeName()):
+			elem = fmt.Sprintf("gen%s(depth-1)", localTypeName(value.GetTypeName()))
+			maxExpr = fmt.Sprintf("rapidpb.MaxItems(depth, %d, %d)", min, max)
+		case min > 0:
+			typ := g.imports.goTypeName(g.idx, value.GetTypeName())
+			elem = fmt.Sprintf("rapid.Custom(func(*rapid.T) *%s { return new(%s) })", typ, typ)
+			maxExpr = strconv.Itoa(min)
+		default:
+			return nil
+		}
+		if g.idx.isMap(field) {
+			key, err := g.valueGen(g.idx.messages[field.GetTypeName()].GetField()[0], new(options.FieldRules), false)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(w, "m.%s = rapid.MapOfN(%s, %s, %d, %s).Draw(t, %s)\n", goName, key, elem, min, maxExpr, label)
+			return nil
+		}
+		fmt.Fprintf(w, "m.%s = rapid.SliceOfN(%s, %d, %s).Draw(t, %s)\n", goName, elem, min, maxExpr, label)
+		return nil
+	}
+
+	if isMessage(field) {
+		switch {
+		case g.local(field.GetTypeName()) && required:
+			fmt.Fprintf(w, "m.%s = gen%s(depth-1).Draw(t, %s)\n", goName, localTypeName(field.GetTypeName()), label)
+		case g.local(field.GetTypeName()):
+			fmt.Fprintf(w, "if depth > 0 && rapid.Bool().Draw(t, %s) {\n", strconv.Quote(field.GetName()+" set"))
+			fmt.Fprintf(w, "m.%s = gen%s(depth-1).Draw(t, %s)\n}\n", goName, localTypeName(field.GetTypeName()), label)
+		case required:
+			fmt.Fprintf(w, "m.%s = new(%s)\n", goName, g.imports.goTypeName(g.idx, field.GetTypeName()))
+		}
+		return nil
+	}
+
+	gen, err := g.valueGen(field, rules, required)
+	if err != nil {
+		return err
+	}
+	pointer := (field.GetProto3Optional() || !g.proto3) && field.GetType() != descriptor.FieldDescriptorProto_TYPE_BYTES
+	switch {
+	case pointer && required:
+		fmt.Fprintf(w, "{\nv := %s.Draw(t, %s)\nm.%s = &v\n}\n", gen, label, goName)
+	case pointer:
+		fmt.Fprintf(w, "if rapid.Bool().Draw(t, %s) {\n", strconv.Quote(field.GetName()+" set"))
+		fmt.Fprintf(w, "v := %s.Draw(t, %s)\nm.%s = &v\n}\n", gen, label, goName)
+	default:
+		fmt.Fprintf(w, "m.%s = %s.Draw(t, %s)\n", goName, gen, label)
+	}
+	return nil
+}
+
+// oneof writes the statement setting one of the members of oneof, of the
+// message msgName whose fields and oneofs have the Go names goNames and
+// whose oneof fields have the wrapper types wrappers, or none.
+func (g *rapidGen) oneof(w *bytes.Buffer, msgName string, goNames, wrappers map[string]string, oneof *descriptor.OneofDescriptorProto, members []*descriptor.FieldDescriptorProto) error {
+	fmt.Fprintf(w, "switch rapid.IntRange(0, %d).Draw(t, %q) {\n", len(members), oneof.GetName())
+	for i, field := range members {
+		goName := goNames[field.GetName()]
+		wrapper := msgName + "_" + wrappers[field.GetName()]
+		label := strconv.Quote(field.GetName())
+		fmt.Fprintf(w, "case %d:\n", i+1)
+		if isMessage(field) {
+			if g.local(field.GetTypeName()) {
+				fmt.Fprintf(w, "if depth > 0 {\n")
+				fmt.Fprintf(w, "m.%s = &%s{%s: gen%s(depth-1).Draw(t, %s)}\n}\n", goNames[oneof.GetName()], wrapper, goName, localTypeName(field.GetTypeName()), label)
+			} else {
+				fmt.Fprintf(w, "m.%s = &%s{%s: new(%s)}\n", goNames[oneof.GetName()], wrapper, goName, g.imports.goTypeName(g.idx, field.GetTypeName()))
+			}
+			continue
+		}
+		rules := options.Rules(field)
+		if rules == nil {
+			rules = new(options.FieldRules)
+		}
+		gen, err := g.valueGen(field, rules, false)
+		if err != nil {
+			return fmt.Errorf("%s: %v", field.GetName(), err)
+		}
+		fmt.Fprintf(w, "m.%s = &%s{%s: %s.Draw(t, %s)}\n", goNames[oneof.GetName()], wrapper, goName, gen, label)
+	}
+	fmt.Fprintf(w, "}\n")
+	return nil
+}
+
+// isMessage reports whether field holds messages, as groups do too.
+func isMessage(field *descriptor.FieldDescriptorProto) bool {
+	return field.GetType() == descriptor.FieldDescriptorProto_TYPE_MESSAGE ||
+		field.GetType() == descriptor.FieldDescriptorProto_TYPE_GROUP
+}
+
+// local reports whether the message typeName has a generator in the Go
+// package being generated.
+func (g *rapidGen) local(typeName string) bool {
+	f := g.idx.files[typeName]
+	return f != nil && g.genFileNames[f.GetName()] &&
+		goImportPath(f) == goImportPath(g.desc) && defaultGoPackageName(f) == defaultGoPackageName(g.desc)
+}
+
+// requiredCycle reports whether the required message fields of the
+// message typeName, and those of the messages they hold, lead to target.
+func (g *rapidGen) requiredCycle(typeName, target string, seen map[string]bool) bool {
+	if seen[typeName] {
+		return false
+	}
+	seen[typeName] = true
+	for _, field := range g.idx.messages[typeName].GetField() {
+		value := field
+		if g.idx.isMap(field) {
+			value = g.idx.messages[field.GetTypeName()].GetField()[1]
+		}
+		if !isMessage(value) || !g.local(value.GetTypeName()) {
+			continue
+		}
+		if field.OneofIndex != nil && !field.GetProto3Optional() {
+			continue
+		}
+		rules := options.Rules(field)
+		if !rules.GetRequired() && field.GetLabel() != descriptor.FieldDescriptorProto_LABEL_REQUIRED &&
+			(rules == nil || rules.MinItems == nil || *rules.MinItems == 0) {
+			continue
+		}
+		if value.GetTypeName() == target || g.requiredCycle(value.GetTypeName(), target, seen) {
+			return true
+		}
+	}
+	return false
+}
+
+// numberType is the generation of the numbers of a proto type.
+type numberType struct {
+	goType string
+	// gen is the rapid function drawing numbers of goType in a range, and
+	// lo and hi the literals of the bounds of goType.
+	gen, lo, hi string
+	integer     bool
+	float32     bool
+}
+
+var numberTypes = map[descriptor.FieldDescriptorProto_Type]*numberType{
+	descriptor.FieldDescriptorProto_TYPE_INT32:    {goType: "int32", gen: "Int32Range", lo: "-2147483648", hi: "2147483647", integer: true},
+	descriptor.FieldDescriptorProto_TYPE_SINT32:   {goType: "int32", gen: "Int32Range", lo: "-2147483648", hi: "2147483647", integer: true},
+	descriptor.FieldDescriptorProto_TYPE_SFIXED32: {goType: "int32", gen: "Int32Range", lo: "-2147483648", hi: "2147483647", integer: true},
+	descriptor.FieldDescriptorProto_TYPE_UINT32:   {goType: "uint32", gen: "Uint32Range", lo: "0", hi: "4294967295", integer: true},
+	descriptor.FieldDescriptorProto_TYPE_FIXED32:  {goType: "uint32", gen: "Uint32Range", lo: "0", hi: "4294967295", integer: true},
+	descriptor.FieldDescriptorProto_TYPE_INT64:    {goType: "int64", gen: "Int64Range", lo: "-9223372036854775808", hi: "9223372036854775807", integer: true},
+	descriptor.FieldDescriptorProto_TYPE_SINT64:   {goType: "int64", gen: "Int64Range", lo: "-9223372036854775808", hi: "9223372036854775807", integer: true},
+	descriptor.FieldDescriptorProto_TYPE_SFIXED64: {goType: "int64", gen: "Int64Range", lo: "-9223372036854775808", hi: "9223372036854775807", integer: true},
+	descriptor.FieldDescriptorProto_TYPE_UINT64:   {goType: "uint64", gen: "Uint64Range", lo: "0", hi: "18446744073709551615", integer: true},
+	descriptor.FieldDescriptorProto_TYPE_FIXED64:  {goType: "uint64", gen: "Uint64Range", lo: "0", hi: "18446744073709551615", integer: true},
+	descriptor.FieldDescriptorProto_TYPE_FLOAT:    {goType: "float32", gen: "Float32Range", lo: "-math.MaxFloat32", hi: "math.MaxFloat32", float32: true},
+	descriptor.FieldDescriptorProto_TYPE_DOUBLE:   {goType: "float64", gen: "Float64Range", lo: "-math.MaxFloat64", hi: "math.MaxFloat64"},
+}
+
+// valueGen returns the expression of the generator of the values of
+// field satisfying rules, and non-zero if required.
+func (g *rapidGen) valueGen(field *descriptor.FieldDescriptorProto, rules *options.FieldRules, required bool) (string, error) {
+	typ := field.GetType()
+	if rules.MinLen != nil || rules.MaxLen != nil || rules.Pattern != nil {
+		if typ != descriptor.FieldDescriptorProto_TYPE_STRING && typ != descriptor.FieldDescriptorProto_TYPE_BYTES {
+			return "", fmt.Errorf("min_len, max_len and pattern only apply to strings and bytes")
+		}
+	}
+	switch typ {
+	case descriptor.FieldDescriptorProto_TYPE_BOOL:
+		if required {
+			return "rapid.Just(true)", nil
+		}
+		return "rapid.Bool()", nil
+	case descriptor.FieldDescriptorProto_TYPE_STRING, descriptor.FieldDescriptorProto_TYPE_BYTES:
+		min, max := bound(rules.MinLen, 0), bound(rules.MaxLen, -1)
+		if required && min == 0 {
+			min = 1
+		}
+		if max >= 0 && min > max {
+			return "", fmt.Errorf("min_len is greater than max_len")
+		}
+		if typ == descriptor.FieldDescriptorProto_TYPE_BYTES {
+			return fmt.Sprintf("rapid.SliceOfN(rapid.Byte(), %d, %d)", min, max), nil
+		}
+		if rules.Pattern == nil {
+			return fmt.Sprintf("rapid.StringN(%d, %d, -1)", min, max), nil
+		}
+		if _, err := regexp.Compile(rules.GetPattern()); err != nil {
+			return "", fmt.Errorf("pattern: %v", err)
+		}
+		gen := fmt.Sprintf("rapid.StringMatching(%q)", rules.GetPattern())
+		if min > 0 || max >= 0 {
+			gen += fmt.Sprintf(".Filter(rapidpb.RuneCount(%d, %d))", min, max)
+		}
+		return gen, nil
+	case descriptor.FieldDescriptorProto_TYPE_ENUM:
+		enum := g.idx.enums[field.GetTypeName()]
+		var values []string
+		for _, v := range enum.GetValue() {
+			if required && v.GetNumber() == 0 {
+				continue
+			}
+			values = append(values, strconv.Itoa(int(v.GetNumber())))
+		}
+		if len(values) == 0 {
+			return "", fmt.Errorf("%s has no value to draw", strings.TrimPrefix(field.GetTypeName(), "."))
+		}
+		return fmt.Sprintf("rapid.SampledFrom([]%s{%s})", g.imports.goTypeName(g.idx, field.GetTypeName()), strings.Join(values, ", ")), nil
+	}
+	n := numberTypes[typ]
+	if n == nil {
+		return "", fmt.Errorf("%s fields are not supported", strings.ToLower(strings.TrimPrefix(typ.String(), "TYPE_")))
+	}
+	lo, hi, zero, err := n.bounds(rules)
+	if err != nil {
+		return "", err
+	}
+	if strings.Contains(lo+hi, "math.") {
+		g.math = true
+	}
+	gen := fmt.Sprintf("rapid.%s(%s, %s)", n.gen, lo, hi)
+	if required && zero {
+		gen += fmt.Sprintf(".Filter(rapidpb.NonZero[%s])", n.goType)
+	}
+	return gen, nil
+}
+
+// bounds returns the literals of the least and greatest numbers satisfying
+// rules, and whether 0 is among them.
+func (n *numberType) bounds(rules *options.FieldRules) (string, string, bool, error) {
+	if rules.Gt == nil && rules.Gte == nil && rules.Lt == nil && rules.Lte == nil {
+		return n.lo, n.hi, true, nil
+	}
+	lo, hi := math.Inf(-1), math.Inf(1)
+	if rules.Gte != nil {
+		lo = *rules.Gte
+	}
+	if rules.Gt != nil {
+		gt := *rules.Gt
+		switch {
+		case n.integer:
+			gt = math.Floor(gt) + 1
+		case n.float32:
+			gt = float64(math.Nextafter32(float32(gt), float32(math.Inf(1))))
+		default:
+			gt = math.Nextafter(gt, math.Inf(1))
+		}
+		lo = math.Max(lo, gt)
+	}
+	if rules.Lte != nil {
+		hi = *rules.Lte
+	}
+	if rules.Lt != nil {
+		lt := *rules.Lt
+		switch {
+		case n.integer:
+			lt = math.Ceil(lt) - 1
+		case n.float32:
+			lt = float64(math.Nextafter32(float32(lt), float32(math.Inf(-1))))
+		default:
+			lt = math.Nextafter(lt, math.Inf(-1))
+		}
+		hi = math.Min(hi, lt)
+	}
+	// The bounds of the type are kept as literals, which float64 does not
+	// hold exactly for 64-bit integers.
+	loLit, hiLit := n.lo, n.hi
+	typeLo, typeHi := -math.MaxFloat64, math.MaxFloat64
+	if n.integer {
+		lo, hi = math.Ceil(lo), math.Floor(hi)
+		typeLo, _ = strconv.ParseFloat(n.lo, 64)
+		typeHi, _ = strconv.ParseFloat(n.hi, 64)
+	} else if n.float32 {
+		typeLo, typeHi = -math.MaxFloat32, math.MaxFloat32
+	}
+	if math.Max(lo, typeLo) > math.Min(hi, typeHi) {
+		return "", "", false, fmt.Errorf("no %s satisfies the bounds", n.goType)
+	}
+	if lo > typeLo {
+		loLit = literal(lo, n.integer)
+	}
+	if hi < typeHi {
+		hiLit = literal(hi, n.integer)
+	}
+	return loLit, hiLit, lo <= 0 && hi >= 0, nil
+}
+
+// bound returns the value of the length or count bound p, or def if unset.
+func bound(p *uint32, def int) int {
+	if p == nil {
+		return def
+	}
+	return int(*p)
+}
+
+// literal returns the Go literal of the number v.
+func literal(v float64, integer bool) string {
+	if integer {
+		return strconv.FormatFloat(v, 'f', 0, 64)
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// typeIndex maps fully-qualified proto type names (".pkg.Msg") to their
+// descriptors and declaring files for every file in the request.
+type typeIndex struct {
+	messages map[string]*descriptor.DescriptorProto
+	enums    map[string]*descriptor.EnumDescriptorProto
+	files    map[string]*descriptor.FileDescriptorProto
+}
+
+func newTypeIndex(files []*descriptor.FileDescriptorProto) *typeIndex {
+	idx := &typeIndex{
+		messages: make(map[string]*descriptor.DescriptorProto),
+		enums:    make(map[string]*descriptor.EnumDescriptorProto),
+		files:    make(map[string]*descriptor.FileDescriptorProto),
+	}
+	for _, f := range files {
+		prefix := ""
+		if f.GetPackage() != "" {
+			prefix = "." + f.GetPackage()
+		}
+		idx.addMessages(f, prefix, f.GetMessageType())
+		idx.addEnums(f, prefix, f.GetEnumType())
+	}
+	return idx
+}
+
+func (idx *typeIndex) addMessages(f *descriptor.FileDescriptorProto, prefix string, msgs []*descriptor.DescriptorProto) {
+	for _, m := range msgs {
+		name := prefix + "." + m.GetName()
+		idx.messages[name] = m
+		idx.files[name] = f
+		idx.addMessages(f, name, m.GetNestedType())
+		idx.addEnums(f, name, m.GetEnumType())
+	}
+}
+
+func (idx *typeIndex) addEnums(f *descriptor.FileDescriptorProto, prefix string, enums []*descriptor.EnumDescriptorProto) {
+	for _, e := range enums {
+		name := prefix + "." + e.GetName()
+		idx.enums[name] = e
+		idx.files[name] = f
+	}
+}
+
+// isMap reports whether field is a map field.
+func (idx *typeIndex) isMap(field *descriptor.FieldDescriptorProto) bool {
+	if field.GetType() != descriptor.FieldDescriptorProto_TYPE_MESSAGE {
+		return false
+	}
+	return idx.messages[field.GetTypeName()].GetOptions().GetMapEntry()
+}
+
+// goImportPath returns the import path of the Go package generated from f,
+// or "" if go_package does not name one.
+func goImportPath(f *descriptor.FileDescriptorProto) string {
+	gopkg := f.GetOptions().GetGoPackage()
+	if sc := strings.IndexByte(gopkg, ';'); sc >= 0 {
+		gopkg = gopkg[:sc]
+	}
+	if !strings.Contains(gopkg, "/") {
+		return ""
+	}
+	return gopkg
+}
+
+// importSet records the Go packages referenced by the code generated for
+// one file, keyed by import path.
+type importSet struct {
+	self  string
+	names map[string]string
+}
+
+func newImportSet(f *descriptor.FileDescriptorProto) *importSet {
+	return &importSet{self: goImportPath(f), names: make(map[string]string)}
+}
+
+// goTypeName returns the Go name of the message or enum typeName, qualified
+// with its package if it is declared outside the generated package.
+func (s *importSet) goTypeName(idx *typeIndex, typeName string) string {
+	name := localTypeName(typeName)
+	f := idx.files[typeName]
+	path := goImportPath(f)
+	if f == nil || path == "" || path == s.self {
+		return name
+	}
+	pkg := defaultGoPackageName(f)
+	s.names[path] = pkg
+	return pkg + "." + name
+}
+
+// localTypeName returns the Go name of a type declared in the file being
+// generated, e.g. ".pkg.Outer.Inner" becomes "Outer_Inner".
+func localTypeName(typeName string) string {
+	parts := strings.Split(strings.TrimPrefix(typeName, "."), ".")
+	var names []string
+	for _, p := range parts {
+		if len(p) > 0 && p[0] >= 'A' && p[0] <= 'Z' {
+			names = append(names, p)
+		}
+	}
+	return strings.Join(names, "_")
+}
+
+// sanitizePackageName replaces unallowed character in package name
+// with allowed character.
+func sanitizePackageName(pkgName string) string {
+	pkgName = strings.Replace(pkgName, ".", "_", -1)
+	pkgName = strings.Replace(pkgName, "-", "_", -1)
+	return pkgName
+}
+
+// defaultGoPackageName returns the default go package name to be used for go files generated from "f".
+// You might need to use an unique alias for the package when you import it.  Use ReserveGoPackageAlias to get a unique alias.
+func defaultGoPackageName(f *descriptor.FileDescriptorProto) string {
+	name := packageIdentityName(f)
+	return sanitizePackageName(name)
+}
+
+// packageIdentityName returns the identity of packages.
+// protoc-gen-grpc-gateway rejects CodeGenerationRequests which contains more than one packages
+// as protoc-gen-go does.
+func packageIdentityName(f *descriptor.FileDescriptorProto) string {
+	if f.Options != nil && f.Options.GoPackage != nil {
+		gopkg := f.Options.GetGoPackage()
+		idx := strings.LastIndex(gopkg, "/")
+		if idx < 0 {
+			gopkg = gopkg[idx+1:]
+		}
+
+		gopkg = gopkg[idx+1:]
+		// package name is overrided with the string after the
+		// ';' character
+		sc := strings.IndexByte(gopkg, ';')
+		if sc < 0 {
+			return sanitizePackageName(gopkg)
+
+		}
+		return sanitizePackageName(gopkg[sc+1:])
+	}
+
+	if f.Package == nil {
+		base := filepath.Base(f.GetName())
+		ext := filepath.Ext(base)
+		return strings.TrimSuffix(base, ext)
+	}
+	return f.GetPackage()
+}
+
+func emitFiles(out []*plugin.CodeGeneratorResponse_File) {
+	emitResp(&plugin.CodeGeneratorResponse{File: out})
+}
+
+func emitError(err error) {
+	emitResp(&plugin.CodeGeneratorResponse{Error: proto.String(err.Error())})
+}
+
+func emitResp(resp *plugin.CodeGeneratorResponse) {
+	buf, err := proto.Marshal(resp)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if _, err := os.Stdout.Write(buf); err != nil {
+		log.Fatal(err)
+	}
+}
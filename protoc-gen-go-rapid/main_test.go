@@ -0,0 +1,89 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/f4tq/protoc-go-plugins/internal/plugintest"
+)
+
+const optionalProto = `
+syntax = "proto3";
+
+package doc.v1;
+
+option go_package = "example.com/doc/v1;docv1";
+
+enum Kind {
+    KIND_UNSPECIFIED = 0;
+    KIND_TEXT = 1;
+}
+
+message Doc {
+    optional bytes blob = 1;
+    optional Kind kind = 2;
+    optional string title = 3;
+    Doc parent = 4;
+}
+`
+
+const groupProto = `
+syntax = "proto2";
+
+package doc.v1;
+
+option go_package = "example.com/doc/v1;docv1";
+
+message Doc {
+    optional group Meta = 1 {
+        optional string author = 2;
+        repeated group Tag = 3 {
+            required string name = 4;
+        }
+    }
+    repeated group Part = 5 {
+        optional bytes data = 6;
+    }
+    oneof body {
+        group Text = 7 {
+            optional string value = 8;
+        }
+    }
+    optional Doc parent = 9;
+}
+`
+
+// drawTest runs in the package generated for the Doc messages.
+const drawTest = `package docv1
+
+import (
+	"testing"
+
+	"pgregory.net/rapid"
+)
+
+func TestDraw(t *testing.T) {
+	rapid.Check(t, func(t *rapid.T) {
+		GenDoc().Draw(t, "doc")
+	})
+}
+`
+
+// TestGeneratedGenerators draws messages from the generators of fields of
+// the kinds set apart.
+func TestGeneratedGenerators(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+	}{
+		{"proto3 optional fields", optionalProto},
+		{"proto2 groups", groupProto},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := plugintest.Request(t, "", map[string]string{"doc/v1/doc.proto": test.source})
+			pkg := plugintest.Package(t, req, generate)
+			plugintest.WriteFile(t, pkg, "draw_test.go", drawTest)
+			plugintest.Go(t, "test", pkg)
+		})
+	}
+}
@@ -0,0 +1,403 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+
+	"github.com/f4tq/protoc-go-plugins/options"
+	"github.com/f4tq/protoc-go-plugins/protodoc"
+)
+
+var (
+	hdrTmpl = template.Must(template.New("header").Parse(`
+// Code generated by protoc-gen-go-ratelimit. DO NOT EDIT.
+// source: {{.Source}}
+
+package {{.GoPkg}}
+
+import (
+{{- if .Unary}}
+    "context"
+
+{{end}}
+    "github.com/f4tq/protoc-go-plugins/runtime/ratelimit"
+{{- range $path, $name := .Imports}}
+    {{$name}} "{{$path}}"
+{{- end}}
+)
+`))
+
+	limitTmpl = template.Must(template.New("limit").Parse(`
+// New{{.Name}}RateLimitServer wraps next so that calls to the methods with a
+// (f4tq.plugins.rate_limit) option are admitted by limiter, such as a
+// ratelimit.MemoryLimiter or ratelimit.RedisLimiter. Rejected calls fail
+// with RESOURCE_EXHAUSTED and a RetryInfo detail.{{.Doc}}
+func New{{.Name}}RateLimitServer(next {{.Name}}Server, limiter ratelimit.Limiter) {{.Name}}Server {
+    return &{{.Lower}}RateLimitServer{ {{.Name}}Server: next, limiter: limiter}
+}
+
+type {{.Lower}}RateLimitServer struct {
+    {{.Name}}Server
+    limiter ratelimit.Limiter
+}
+{{range .Methods}}
+// {{$.Lower}}{{.Name}}Limit is the rate limit of {{$.Name}}.{{.Name}}.
+var {{$.Lower}}{{.Name}}Limit = ratelimit.Limit{Rate: {{.Rate}}, Burst: {{.Burst}}}
+{{if .ClientStreaming}}
+func (s *{{$.Lower}}RateLimitServer) {{.Name}}(stream {{$.Name}}_{{.Name}}Server) error {
+    if err := ratelimit.Check(stream.Context(), s.limiter, {{printf "%q" .FullName}}, {{$.Lower}}{{.Name}}Limit); err != nil {
+        return err
+    }
+    return s.{{$.Name}}Server.{{.Name}}(stream)
+}
+{{- else if .ServerStreaming}}
+func (s *{{$.Lower}}RateLimitServer) {{.Name}}(in *{{.Input}}, stream {{$.Name}}_{{.Name}}Server) error {
+    if err := ratelimit.Check(stream.Context(), s.limiter, {{printf "%q" .FullName}}, {{$.Lower}}{{.Name}}Limit); err != nil {
+        return err
+    }
+    return s.{{$.Name}}Server.{{.Name}}(in, stream)
+}
+{{- else}}
+func (s *{{$.Lower}}RateLimitServer) {{.Name}}(ctx context.Context, in *{{.Input}}) (*{{.Output}}, error) {
+    if err := ratelimit.Check(ctx, s.limiter, {{printf "%q" .FullName}}, {{$.Lower}}{{.Name}}Limit); err != nil {
+        return nil, err
+    }
+    return s.{{$.Name}}Server.{{.Name}}(ctx, in)
+}
+{{- end}}
+{{end}}
+`))
+)
+
+func main() {
+	input, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	req := new(plugin.CodeGeneratorRequest)
+	if err = proto.Unmarshal(input, req); err != nil {
+		log.Fatal(err)
+	}
+
+	out, err := generate(req)
+
+	if err != nil {
+		emitError(err)
+		return
+	}
+
+	emitFiles(out)
+}
+
+func generate(req *plugin.CodeGeneratorRequest) ([]*plugin.CodeGeneratorResponse_File, error) {
+	var files []*plugin.CodeGeneratorResponse_File
+	idx := newTypeIndex(req.GetProtoFile())
+	docs := protodoc.New(req.GetProtoFile())
+	genFileNames := make(map[string]bool)
+	for _, n := range req.FileToGenerate {
+		genFileNames[n] = true
+	}
+	for _, desc := range req.GetProtoFile() {
+		name := desc.GetName()
+		if _, ok := genFileNames[name]; !ok {
+			// Only emit output for files present in req.FileToGenerate.
+			continue
+		}
+		code, err := genCode(desc, idx, docs)
+		if err != nil {
+			return nil, err
+		}
+		if code == "" {
+			// No method declares a rate limit.
+			continue
+		}
+		formatted, err := format.Source([]byte(code))
+		if err != nil {
+			log.Printf("%v: %s", err, code)
+			return nil, err
+		}
+
+		ext := filepath.Ext(name)
+		base := strings.TrimSuffix(name, ext)
+		output := fmt.Sprintf("%s.pb.ratelimit.go", base)
+		files = append(files, &plugin.CodeGeneratorResponse_File{
+			Name:    proto.String(output),
+			Content: proto.String(string(formatted)),
+		})
+	}
+
+	return files, nil
+}
+
+func genCode(desc *descriptor.FileDescriptorProto, idx *typeIndex, docs *protodoc.Index) (string, error) {
+	w := bytes.NewBuffer(nil)
+	imports := newImportSet(desc)
+	hdr := &header{
+		Source:  desc.GetName(),
+		GoPkg:   defaultGoPackageName(desc),
+		Imports: imports.names,
+	}
+	body := bytes.NewBuffer(nil)
+	for _, svc := range desc.GetService() {
+		fullName := svc.GetName()
+		if desc.GetPackage() != "" {
+			fullName = desc.GetPackage() + "." + svc.GetName()
+		}
+		s := &limitService{
+			Name:  svc.GetName(),
+			Lower: strings.ToLower(svc.GetName()[:1]) + svc.GetName()[1:],
+			Doc:   docs.Godoc(fullName),
+		}
+		for _, m := range svc.GetMethod() {
+			rl := options.MethodRateLimit(m)
+			if rl == nil {
+				continue
+			}
+			rate := rl.GetRequestsPerSecond()
+			if rate <= 0 || math.IsInf(rate, 0) || math.IsNaN(rate) {
+				return "", fmt.Errorf("%s.%s: rate_limit.requests_per_second must be positive", svc.GetName(), m.GetName())
+			}
+			burst := int(rl.GetBurst())
+			if burst == 0 {
+				burst = int(math.Ceil(rate))
+			}
+			method := &limitMethod{
+				Name:            m.GetName(),
+				FullName:        "/" + fullName + "/" + m.GetName(),
+				Rate:            strconv.FormatFloat(rate, 'g', -1, 64),
+				Burst:           burst,
+				ClientStreaming: m.GetClientStreaming(),
+				ServerStreaming: m.GetServerStreaming(),
+			}
+			if !m.GetClientStreaming() {
+				method.Input = imports.goTypeName(idx, m.GetInputType())
+			}
+			if !m.GetClientStreaming() && !m.GetServerStreaming() {
+				method.Output = imports.goTypeName(idx, m.GetOutputType())
+				hdr.Unary = true
+			}
+			s.Methods = append(s.Methods, method)
+		}
+		if len(s.Methods) == 0 {
+			continue
+		}
+		if err := limitTmpl.Execute(body, s); err != nil {
+			return "", err
+		}
+	}
+	if body.Len() == 0 {
+		return "", nil
+	}
+
+	if err := hdrTmpl.Execute(w, hdr); err != nil {
+		log.Fatal(err)
+	}
+	body.WriteTo(w)
+
+	return w.String(), nil
+}
+
+type header struct {
+	Source  string
+	GoPkg   string
+	Imports map[string]string
+	Unary   bool
+}
+
+type limitService struct {
+	Name    string
+	Lower   string
+	Methods []*limitMethod
+	// Doc is the comment of the service, see protodoc.Index.Godoc.
+	Doc string
+}
+
+type limitMethod struct {
+	Name            string
+	FullName        string
+	Input           string
+	Output          string
+	Rate            string
+	Burst           int
+	ClientStreaming bool
+	ServerStreaming bool
+}
+
+// typeIndex maps fully-qualified proto type names (".pkg.Msg") to their
+// descriptors and declaring files for every file in the request.
+type typeIndex struct {
+	messages map[string]*descriptor.DescriptorProto
+	enums    map[string]*descriptor.EnumDescriptorProto
+	files    map[string]*descriptor.FileDescriptorProto
+}
+
+func newTypeIndex(files []*descriptor.FileDescriptorProto) *typeIndex {
+	idx := &typeIndex{
+		messages: make(map[string]*descriptor.DescriptorProto),
+		enums:    make(map[string]*descriptor.EnumDescriptorProto),
+		files:    make(map[string]*descriptor.FileDescriptorProto),
+	}
+	for _, f := range files {
+		prefix := ""
+		if f.GetPackage() != "" {
+			prefix = "." + f.GetPackage()
+		}
+		idx.addMessages(f, prefix, f.GetMessageType())
+		idx.addEnums(f, prefix, f.GetEnumType())
+	}
+	return idx
+}
+
+func (idx *typeIndex) addMessages(f *descriptor.FileDescriptorProto, prefix string, msgs []*descriptor.DescriptorProto) {
+	for _, m := range msgs {
+		name := prefix + "." + m.GetName()
+		idx.messages[name] = m
+		idx.files[name] = f
+		idx.addMessages(f, name, m.GetNestedType())
+		idx.addEnums(f, name, m.GetEnumType())
+	}
+}
+
+func (idx *typeIndex) addEnums(f *descriptor.FileDescriptorProto, prefix string, enums []*descriptor.EnumDescriptorProto) {
+	for _, e := range enums {
+		name := prefix + "." + e.GetName()
+		idx.enums[name] = e
+		idx.files[name] = f
+	}
+}
+
+// isMap reports whether field is a map field.
+func (idx *typeIndex) isMap(field *descriptor.FieldDescriptorProto) bool {
+	if field.GetType() != descriptor.FieldDescriptorProto_TYPE_MESSAGE {
+		return false
+	}
+	return idx.messages[field.GetTypeName()].GetOptions().GetMapEntry()
+}
+
+// goImportPath returns the import path of the Go package generated from f,
+// or "" if go_package does not name one.
+func goImportPath(f *descriptor.FileDescriptorProto) string {
+	gopkg := f.GetOptions().GetGoPackage()
+	if sc := strings.IndexByte(gopkg, ';'); sc >= 0 {
+		gopkg = gopkg[:sc]
+	}
+	if !strings.Contains(gopkg, "/") {
+		return ""
+	}
+	return gopkg
+}
+
+// importSet records the Go packages referenced by the code generated for
+// one file, keyed by import path.
+type importSet struct {
+	self  string
+	names map[string]string
+}
+
+func newImportSet(f *descriptor.FileDescriptorProto) *importSet {
+	return &importSet{self: goImportPath(f), names: make(map[string]string)}
+}
+
+// goTypeName returns the Go name of the message or enum typeName, qualified
+// with its package if it is declared outside the generated package.
+func (s *importSet) goTypeName(idx *typeIndex, typeName string) string {
+	name := localTypeName(typeName)
+	f := idx.files[typeName]
+	path := goImportPath(f)
+	if f == nil || path == "" || path == s.self {
+		return name
+	}
+	pkg := defaultGoPackageName(f)
+	s.names[path] = pkg
+	return pkg + "." + name
+}
+
+// localTypeName returns the Go name of a type declared in the file being
+// generated, e.g. ".pkg.Outer.Inner" becomes "Outer_Inner".
+func localTypeName(typeName string) string {
+	parts := strings.Split(strings.TrimPrefix(typeName, "."), ".")
+	var names []string
+	for _, p := range parts {
+		if len(p) > 0 && p[0] >= 'A' && p[0] <= 'Z' {
+			names = append(names, p)
+		}
+	}
+	return strings.Join(names, "_")
+}
+
+// sanitizePackageName replaces unallowed character in package name
+// with allowed character.
+func sanitizePackageName(pkgName string) string {
+	pkgName = strings.Replace(pkgName, ".", "_", -1)
+	pkgName = strings.Replace(pkgName, "-", "_", -1)
+	return pkgName
+}
+
+// defaultGoPackageName returns the default go package name to be used for go files generated from "f".
+// You might need to use an unique alias for the package when you import it.  Use ReserveGoPackageAlias to get a unique alias.
+func defaultGoPackageName(f *descriptor.FileDescriptorProto) string {
+	name := packageIdentityName(f)
+	return sanitizePackageName(name)
+}
+
+// packageIdentityName returns the identity of packages.
+// protoc-gen-grpc-gateway rejects CodeGenerationRequests which contains more than one packages
+// as protoc-gen-go does.
+func packageIdentityName(f *descriptor.FileDescriptorProto) string {
+	if f.Options != nil && f.Options.GoPackage != nil {
+		gopkg := f.Options.GetGoPackage()
+		idx := strings.LastIndex(gopkg, "/")
+		if idx < 0 {
+			gopkg = gopkg[idx+1:]
+		}
+
+		gopkg = gopkg[idx+1:]
+		// package name is overrided with the string after the
+		// ';' character
+		sc := strings.IndexByte(gopkg, ';')
+		if sc < 0 {
+			return sanitizePackageName(gopkg)
+
+		}
+		return sanitizePackageName(gopkg[sc+1:])
+	}
+
+	if f.Package == nil {
+		base := filepath.Base(f.GetName())
+		ext := filepath.Ext(base)
+		return strings.TrimSuffix(base, ext)
+	}
+	return f.GetPackage()
+}
+
+func emitFiles(out []*plugin.CodeGeneratorResponse_File) {
+	emitResp(&plugin.CodeGeneratorResponse{File: out})
+}
+
+func emitError(err error) {
+	emitResp(&plugin.CodeGeneratorResponse{Error: proto.String(err.Error())})
+}
+
+func emitResp(resp *plugin.CodeGeneratorResponse) {
+	buf, err := proto.Marshal(resp)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if _, err := os.Stdout.Write(buf); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,440 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+
+	"github.com/f4tq/protoc-go-plugins/goname"
+	"github.com/f4tq/protoc-go-plugins/options"
+)
+
+var (
+	hdrTmpl = template.Must(template.New("header").Parse(`
+// Code generated by protoc-gen-go-redact. DO NOT EDIT.
+// source: {{.Source}}
+
+package {{.GoPkg}}
+
+import (
+    "github.com/golang/protobuf/proto"
+{{- if .Runtime}}
+
+    "github.com/f4tq/protoc-go-plugins/runtime/redact"
+{{- end}}
+)
+`))
+
+	redactTmpl = template.Must(template.New("redact").Parse(`
+// Redact returns a copy of m scrubbed by RedactInPlace, for logging and
+// error reporting.
+func (m *{{.Name}}) Redact() *{{.Name}} {
+    if m == nil {
+        return nil
+    }
+    c := proto.Clone(m).(*{{.Name}})
+    c.RedactInPlace()
+    return c
+}
+
+// RedactInPlace scrubs the (f4tq.plugins.sensitive) fields of m and of the
+// messages it holds: strings become redact.Mask, bytes are zeroed, numbers
+// and enums set to zero, and messages, lists of messages and maps cleared.
+// Unknown fields are dropped, as they cannot be told apart.
+func (m *{{.Name}}) RedactInPlace() {
+    if m == nil {
+        return
+    }
+{{- range .Fields}}
+{{.}}
+{{- end}}
+    m.XXX_unrecognized = nil
+}
+`))
+)
+
+func main() {
+	input, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	req := new(plugin.CodeGeneratorRequest)
+	if err = proto.Unmarshal(input, req); err != nil {
+		log.Fatal(err)
+	}
+
+	out, err := generate(req)
+
+	if err != nil {
+		emitError(err)
+		return
+	}
+
+	emitFiles(out)
+}
+
+func generate(req *plugin.CodeGeneratorRequest) ([]*plugin.CodeGeneratorResponse_File, error) {
+	var files []*plugin.CodeGeneratorResponse_File
+	idx := newTypeIndex(req.GetProtoFile())
+	genFileNames := make(map[string]bool)
+	for _, n := range req.FileToGenerate {
+		genFileNames[n] = true
+	}
+	for _, desc := range req.GetProtoFile() {
+		name := desc.GetName()
+		if _, ok := genFileNames[name]; !ok {
+			// Only emit output for files present in req.FileToGenerate.
+			continue
+		}
+		code, err := genCode(desc, idx, genFileNames)
+		if err != nil {
+			return nil, err
+		}
+		if code == "" {
+			// The file declares no messages.
+			continue
+		}
+		formatted, err := format.Source([]byte(code))
+		if err != nil {
+			log.Printf("%v: %s", err, code)
+			return nil, err
+		}
+
+		ext := filepath.Ext(name)
+		base := strings.TrimSuffix(name, ext)
+		output := fmt.Sprintf("%s.pb.redact.go", base)
+		files = append(files, &plugin.CodeGeneratorResponse_File{
+			Name:    proto.String(output),
+			Content: proto.String(string(formatted)),
+		})
+	}
+
+	return files, nil
+}
+
+func genCode(desc *descriptor.FileDescriptorProto, idx *typeIndex, genFileNames map[string]bool) (string, error) {
+	w := bytes.NewBuffer(nil)
+	g := &redactGen{
+		idx:    idx,
+		gen:    genFileNames,
+		proto3: desc.GetSyntax() == "proto3",
+	}
+	body := bytes.NewBuffer(nil)
+	if err := g.messages(body, "", desc.GetMessageType()); err != nil {
+		return "", err
+	}
+	if body.Len() == 0 {
+		return "", nil
+	}
+
+	hdr := &header{
+		Source:  desc.GetName(),
+		GoPkg:   defaultGoPackageName(desc),
+		Runtime: g.runtime,
+	}
+	if err := hdrTmpl.Execute(w, hdr); err != nil {
+		log.Fatal(err)
+	}
+	body.WriteTo(w)
+
+	return w.String(), nil
+}
+
+type redactGen struct {
+	idx    *typeIndex
+	gen    map[string]bool
+	proto3 bool
+	// runtime records whether the generated code uses runtime/redact.
+	runtime bool
+}
+
+// messages writes the Redact methods of msgs and of the messages nested in
+// them. prefix is the Go name of the enclosing message plus "_".
+func (g *redactGen) messages(w *bytes.Buffer, prefix string, msgs []*descriptor.DescriptorProto) error {
+	for _, msg := range msgs {
+		if msg.GetOptions().GetMapEntry() {
+			continue
+		}
+		name := prefix + msg.GetName()
+		m := &redactMessage{Name: name}
+		for _, field := range msg.GetField() {
+			var stmt string
+			if options.Sensitive(field) {
+				stmt = g.scrub(name, msg, field)
+			} else {
+				stmt = g.descend(name, msg, field)
+			}
+			if stmt != "" {
+				m.Fields = append(m.Fields, stmt)
+			}
+		}
+		if err := redactTmpl.Execute(w, m); err != nil {
+			return err
+		}
+		if err := g.messages(w, name+"_", msg.GetNestedType()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// scrub returns the statements scrubbing the sensitive field of msg, whose
+// Go name is msgName.
+func (g *redactGen) scrub(msgName string, msg *descriptor.DescriptorProto, field *descriptor.FieldDescriptorProto) string {
+	goNames := goname.Fields(msg)
+	goName := goNames[field.GetName()]
+	v := "m." + goName
+	switch {
+	case g.idx.isMap(field):
+		return v + " = nil"
+	case field.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REPEATED:
+		if g.isMessage(field) {
+			return v + " = nil"
+		}
+		if field.GetType() == descriptor.FieldDescriptorProto_TYPE_BYTES {
+			return fmt.Sprintf("for _, v := range %s {\n%s\n}", v, g.scrubValue(field, "v"))
+		}
+		return fmt.Sprintf("for i := range %s {\n%s\n}", v, g.scrubValue(field, v+"[i]"))
+	case field.OneofIndex != nil && !field.GetProto3Optional():
+		oneof := goNames[msg.GetOneofDecl()[field.GetOneofIndex()].GetName()]
+		return fmt.Sprintf("if v, ok := m.%s.(*%s_%s); ok {\n%s\n}", oneof, msgName, goName, g.scrubValue(field, "v."+goName))
+	case g.isMessage(field), field.GetType() == descriptor.FieldDescriptorProto_TYPE_BYTES:
+		return g.scrubValue(field, v)
+	case field.GetProto3Optional() || !g.proto3:
+		// Scalars are pointers; keep them set.
+		return fmt.Sprintf("if %s != nil {\n%s\n}", v, g.scrubValue(field, "*"+v))
+	}
+	return g.scrubValue(field, v)
+}
+
+// scrubValue returns the statement scrubbing the value v of field.
+func (g *redactGen) scrubValue(field *descriptor.FieldDescriptorProto, v string) string {
+	switch field.GetType() {
+	case descriptor.FieldDescriptorProto_TYPE_MESSAGE, descriptor.FieldDescriptorProto_TYPE_GROUP:
+		return v + " = nil"
+	case descriptor.FieldDescriptorProto_TYPE_STRING:
+		g.runtime = true
+		return fmt.Sprintf("%s = redact.String(%s)", v, v)
+	case descriptor.FieldDescriptorProto_TYPE_BYTES:
+		g.runtime = true
+		return fmt.Sprintf("redact.Bytes(%s)", v)
+	case descriptor.FieldDescriptorProto_TYPE_BOOL:
+		return v + " = false"
+	}
+	return v + " = 0"
+}
+
+// descend returns the statements scrubbing the messages held by the field
+// of msg that is not sensitive itself, or "" if there are none to scrub.
+// Only generated messages have a RedactInPlace method.
+func (g *redactGen) descend(msgName string, msg *descriptor.DescriptorProto, field *descriptor.FieldDescriptorProto) string {
+	value := field
+	if g.idx.isMap(field) {
+		value = g.idx.messages[field.GetTypeName()].GetField()[1]
+	}
+	if !g.isMessage(value) || !g.gen[g.idx.files[value.GetTypeName()].GetName()] {
+		return ""
+	}
+	goNames := goname.Fields(msg)
+	goName := goNames[field.GetName()]
+	switch {
+	case field.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REPEATED:
+		return fmt.Sprintf("for _, v := range m.%s {\nv.RedactInPlace()\n}", goName)
+	case field.OneofIndex != nil && !field.GetProto3Optional():
+		oneof := goNames[msg.GetOneofDecl()[field.GetOneofIndex()].GetName()]
+		return fmt.Sprintf("if v, ok := m.%s.(*%s_%s); ok {\nv.%s.RedactInPlace()\n}", oneof, msgName, goName, goName)
+	}
+	return fmt.Sprintf("m.%s.RedactInPlace()", goName)
+}
+
+func (g *redactGen) isMessage(field *descriptor.FieldDescriptorProto) bool {
+	return field.GetType() == descriptor.FieldDescriptorProto_TYPE_MESSAGE ||
+		field.GetType() == descriptor.FieldDescriptorProto_TYPE_GROUP
+}
+
+type header struct {
+	Source  string
+	GoPkg   string
+	Runtime bool
+}
+
+type redactMessage struct {
+	Name   string
+	Fields []string
+}
+
+// typeIndex maps fully-qualified proto type names (".pkg.Msg") to their
+// descriptors and declaring files for every file in the request.
+type typeIndex struct {
+	messages map[string]*descriptor.DescriptorProto
+	enums    map[string]*descriptor.EnumDescriptorProto
+	files    map[string]*descriptor.FileDescriptorProto
+}
+
+func newTypeIndex(files []*descriptor.FileDescriptorProto) *typeIndex {
+	idx := &typeIndex{
+		messages: make(map[string]*descriptor.DescriptorProto),
+		enums:    make(map[string]*descriptor.EnumDescriptorProto),
+		files:    make(map[string]*descriptor.FileDescriptorProto),
+	}
+	for _, f := range files {
+		prefix := ""
+		if f.GetPackage() != "" {
+			prefix = "." + f.GetPackage()
+		}
+		idx.addMessages(f, prefix, f.GetMessageType())
+		idx.addEnums(f, prefix, f.GetEnumType())
+	}
+	return idx
+}
+
+func (idx *typeIndex) addMessages(f *descriptor.FileDescriptorProto, prefix string, msgs []*descriptor.DescriptorProto) {
+	for _, m := range msgs {
+		name := prefix + "." + m.GetName()
+		idx.messages[name] = m
+		idx.files[name] = f
+		idx.addMessages(f, name, m.GetNestedType())
+		idx.addEnums(f, name, m.GetEnumType())
+	}
+}
+
+func (idx *typeIndex) addEnums(f *descriptor.FileDescriptorProto, prefix string, enums []*descriptor.EnumDescriptorProto) {
+	for _, e := range enums {
+		name := prefix + "." + e.GetName()
+		idx.enums[name] = e
+		idx.files[name] = f
+	}
+}
+
+// isMap reports whether field is a map field.
+func (idx *typeIndex) isMap(field *descriptor.FieldDescriptorProto) bool {
+	if field.GetType() != descriptor.FieldDescriptorProto_TYPE_MESSAGE {
+		return false
+	}
+	return idx.messages[field.GetTypeName()].GetOptions().GetMapEntry()
+}
+
+// goImportPath returns the import path of the Go package generated from f,
+// or "" if go_package does not name one.
+func goImportPath(f *descriptor.FileDescriptorProto) string {
+	gopkg := f.GetOptions().GetGoPackage()
+	if sc := strings.IndexByte(gopkg, ';'); sc >= 0 {
+		gopkg = gopkg[:sc]
+	}
+	if !strings.Contains(gopkg, "/") {
+		return ""
+	}
+	return gopkg
+}
+
+// importSet records the Go packages referenced by the code generated for
+// one file, keyed by import path.
+type importSet struct {
+	self  string
+	names map[string]string
+}
+
+func newImportSet(f *descriptor.FileDescriptorProto) *importSet {
+	return &importSet{self: goImportPath(f), names: make(map[string]string)}
+}
+
+// goTypeName returns the Go name of the message or enum typeName, qualified
+// with its package if it is declared outside the generated package.
+func (s *importSet) goTypeName(idx *typeIndex, typeName string) string {
+	name := localTypeName(typeName)
+	f := idx.files[typeName]
+	path := goImportPath(f)
+	if f == nil || path == "" || path == s.self {
+		return name
+	}
+	pkg := defaultGoPackageName(f)
+	s.names[path] = pkg
+	return pkg + "." + name
+}
+
+// localTypeName returns the Go name of a type declared in the file being
+// generated, e.g. ".pkg.Outer.Inner" becomes "Outer_Inner".
+func localTypeName(typeName string) string {
+	parts := strings.Split(strings.TrimPrefix(typeName, "."), ".")
+	var names []string
+	for _, p := range parts {
+		if len(p) > 0 && p[0] >= 'A' && p[0] <= 'Z' {
+			names = append(names, p)
+		}
+	}
+	return strings.Join(names, "_")
+}
+
+// sanitizePackageName replaces unallowed character in package name
+// with allowed character.
+func sanitizePackageName(pkgName string) string {
+	pkgName = strings.Replace(pkgName, ".", "_", -1)
+	pkgName = strings.Replace(pkgName, "-", "_", -1)
+	return pkgName
+}
+
+// defaultGoPackageName returns the default go package name to be used for go files generated from "f".
+// You might need to use an unique alias for the package when you import it.  Use ReserveGoPackageAlias to get a unique alias.
+func defaultGoPackageName(f *descriptor.FileDescriptorProto) string {
+	name := packageIdentityName(f)
+	return sanitizePackageName(name)
+}
+
+// packageIdentityName returns the identity of packages.
+// protoc-gen-grpc-gateway rejects CodeGenerationRequests which contains more than one packages
+// as protoc-gen-go does.
+func packageIdentityName(f *descriptor.FileDescriptorProto) string {
+	if f.Options != nil && f.Options.GoPackage != nil {
+		gopkg := f.Options.GetGoPackage()
+		idx := strings.LastIndex(gopkg, "/")
+		if idx < 0 {
+			gopkg = gopkg[idx+1:]
+		}
+
+		gopkg = gopkg[idx+1:]
+		// package name is overrided with the string after the
+		// ';' character
+		sc := strings.IndexByte(gopkg, ';')
+		if sc < 0 {
+			return sanitizePackageName(gopkg)
+
+		}
+		return sanitizePackageName(gopkg[sc+1:])
+	}
+
+	if f.Package == nil {
+		base := filepath.Base(f.GetName())
+		ext := filepath.Ext(base)
+		return strings.TrimSuffix(base, ext)
+	}
+	return f.GetPackage()
+}
+
+func emitFiles(out []*plugin.CodeGeneratorResponse_File) {
+	emitResp(&plugin.CodeGeneratorResponse{File: out})
+}
+
+func emitError(err error) {
+	emitResp(&plugin.CodeGeneratorResponse{Error: proto.String(err.Error())})
+}
+
+func emitResp(resp *plugin.CodeGeneratorResponse) {
+	buf, err := proto.Marshal(resp)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if _, err := os.Stdout.Write(buf); err != nil {
+		log.Fatal(err)
+	}
+}
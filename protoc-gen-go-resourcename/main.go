@@ -0,0 +1,667 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"go/token"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+
+	"github.com/f4tq/protoc-go-plugins/httprule"
+	"github.com/f4tq/protoc-go-plugins/protodoc"
+	"github.com/f4tq/protoc-go-plugins/runtime/httpgw"
+)
+
+var (
+	hdrTmpl = template.Must(template.New("header").Parse(`
+// Code generated by protoc-gen-go-resourcename. DO NOT EDIT.
+// source: {{.Source}}
+
+package {{.GoPkg}}
+
+import (
+{{- if .Names}}
+    "fmt"
+    "strings"
+{{end}}
+{{- if .Binders}}
+    "github.com/f4tq/protoc-go-plugins/runtime/httpgw"
+{{- end}}
+{{- range $path, $name := .Imports}}
+    {{$name}} "{{$path}}"
+{{- end}}
+)
+`))
+
+	nameTmpl = template.Must(template.New("name").Parse(`
+// {{.Type}} is a resource name of the pattern {{.Pattern}}.
+type {{.Type}} struct {
+{{- range .IDs}}
+    {{.Field}} string
+{{- end}}
+}
+
+// Parse{{.Type}} parses name, which must match {{.Pattern}}
+// with non-empty IDs.
+func Parse{{.Type}}(name string) ({{.Type}}, error) {
+    segs := strings.Split(name, "/")
+    if {{.Cond}} {
+        return {{.Type}}{}, fmt.Errorf("resource name %q does not match {{.Pattern}}", name)
+    }
+    return {{.Type}}{
+{{- range .IDs}}
+        {{.Field}}: segs[{{.Index}}],
+{{- end}}
+    }, nil
+}
+
+// Format{{.Type}} returns the resource name {{.Pattern}}
+// with the given IDs.
+func Format{{.Type}}({{.Params}} string) string {
+    return {{.Format}}
+}
+
+// String returns the resource name n.
+func (n {{.Type}}) String() string {
+    return Format{{.Type}}({{.Args}})
+}
+`))
+
+	binderTmpl = template.Must(template.New("binder").Parse(`
+var {{.Pattern}} = httpgw.MustParsePattern({{printf "%q" .Template}})
+
+// {{.Func}} binds path, an escaped URL path, to req
+// following {{.Template}}. It returns an *httpgw.Error with status
+// 400 if path does not match or a variable does not parse.{{.Doc}}
+func {{.Func}}(path string, req *{{.Input}}) error {
+    params, ok := {{.Pattern}}.Match(path)
+    if !ok {
+        return httpgw.BadRequest("path %q does not match %s", path, {{.Pattern}})
+    }
+{{- .Bind}}
+    return nil
+}
+`))
+)
+
+func main() {
+	input, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	req := new(plugin.CodeGeneratorRequest)
+	if err = proto.Unmarshal(input, req); err != nil {
+		log.Fatal(err)
+	}
+
+	out, err := generate(req)
+
+	if err != nil {
+		emitError(err)
+		return
+	}
+
+	emitFiles(out)
+}
+
+func generate(req *plugin.CodeGeneratorRequest) ([]*plugin.CodeGeneratorResponse_File, error) {
+	var files []*plugin.CodeGeneratorResponse_File
+	idx := newTypeIndex(req.GetProtoFile())
+	docs := protodoc.New(req.GetProtoFile())
+	genFileNames := make(map[string]bool)
+	for _, n := range req.FileToGenerate {
+		genFileNames[n] = true
+	}
+	for _, desc := range req.GetProtoFile() {
+		name := desc.GetName()
+		if _, ok := genFileNames[name]; !ok {
+			// Only emit output for files present in req.FileToGenerate.
+			continue
+		}
+		code, err := genCode(desc, idx, docs)
+		if err != nil {
+			return nil, err
+		}
+		if code == "" {
+			// The file has no methods with google.api.http path variables.
+			continue
+		}
+		formatted, err := format.Source([]byte(code))
+		if err != nil {
+			log.Printf("%v: %s", err, code)
+			return nil, err
+		}
+
+		ext := filepath.Ext(name)
+		base := strings.TrimSuffix(name, ext)
+		output := fmt.Sprintf("%s.pb.resourcename.go", base)
+		files = append(files, &plugin.CodeGeneratorResponse_File{
+			Name:    proto.String(output),
+			Content: proto.String(string(formatted)),
+		})
+	}
+
+	return files, nil
+}
+
+func genCode(desc *descriptor.FileDescriptorProto, idx *typeIndex, docs *protodoc.Index) (string, error) {
+	g := &nameGen{
+		idx:      idx,
+		imports:  newImportSet(desc),
+		patterns: make(map[string]string),
+	}
+	names := bytes.NewBuffer(nil)
+	binders := bytes.NewBuffer(nil)
+	for _, svc := range desc.GetService() {
+		for _, m := range svc.GetMethod() {
+			bindings, err := httprule.Bindings(m)
+			if err != nil {
+				return "", fmt.Errorf("%s: %s: %v", desc.GetName(), svc.GetName(), err)
+			}
+			for i, b := range bindings {
+				if len(b.Pattern.Vars()) == 0 {
+					continue
+				}
+				if err := g.names(names, b.Pattern); err != nil {
+					return "", fmt.Errorf("%s: %s.%s: %v", desc.GetName(), svc.GetName(), m.GetName(), err)
+				}
+				bind, err := g.bind(m, b.Pattern)
+				if err != nil {
+					return "", fmt.Errorf("%s: %s.%s: %v", desc.GetName(), svc.GetName(), m.GetName(), err)
+				}
+				suffix := ""
+				if i > 0 {
+					suffix = fmt.Sprint(i)
+				}
+				r := &binder{
+					Func:     fmt.Sprintf("Bind%s%sPath%s", svc.GetName(), m.GetName(), suffix),
+					Pattern:  fmt.Sprintf("path%s%s%d", svc.GetName(), m.GetName(), i),
+					Template: b.Template,
+					Input:    g.imports.goTypeName(g.idx, m.GetInputType()),
+					Bind:     bind,
+					Doc:      docs.Godoc(qualify(desc.GetPackage(), svc.GetName()) + "." + m.GetName()),
+				}
+				if err := binderTmpl.Execute(binders, r); err != nil {
+					return "", err
+				}
+			}
+		}
+	}
+	if binders.Len() == 0 {
+		return "", nil
+	}
+
+	hdr := &header{
+		Source:  desc.GetName(),
+		GoPkg:   defaultGoPackageName(desc),
+		Imports: g.imports.names,
+		Names:   names.Len() > 0,
+		Binders: true,
+	}
+	w := bytes.NewBuffer(nil)
+	if err := hdrTmpl.Execute(w, hdr); err != nil {
+		log.Fatal(err)
+	}
+	names.WriteTo(w)
+	binders.WriteTo(w)
+
+	return w.String(), nil
+}
+
+type nameGen struct {
+	idx     *typeIndex
+	imports *importSet
+	// patterns maps the types of the resource names written to their
+	// patterns.
+	patterns map[string]string
+}
+
+// names writes the resource name types of the variables of p whose
+// segments follow the AIP pattern of collections and IDs, such as
+// {name=shelves/*/books/*}, unless already written. Variables of a single
+// "*", or with "**", are not resource names.
+func (g *nameGen) names(w *bytes.Buffer, p *httpgw.Pattern) error {
+	segs := p.Segments()
+	for v := range p.Vars() {
+		var sub []httpgw.Segment
+		for _, s := range segs {
+			if s.Var == v {
+				sub = append(sub, s)
+			}
+		}
+		r := resourceName(sub)
+		if r == nil {
+			continue
+		}
+		if other, ok := g.patterns[r.Type]; ok {
+			if other != r.Pattern {
+				return fmt.Errorf("the resource name %s of %s is also that of %s", r.Type, r.Pattern, other)
+			}
+			continue
+		}
+		g.patterns[r.Type] = r.Pattern
+		if err := nameTmpl.Execute(w, r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resourceName returns the resource name of the pattern segs, or nil if it
+// is not one: each "*" must follow a literal collection, which names its
+// ID, and the type is named after the last collection.
+func resourceName(segs []httpgw.Segment) *resource {
+	r := &resource{}
+	var pattern, format, cond, params, args []string
+	last := ""
+	for i, s := range segs {
+		switch {
+		case s.Deep:
+			return nil
+		case s.Literal != "":
+			pattern = append(pattern, s.Literal)
+			format = append(format, s.Literal)
+			cond = append(cond, fmt.Sprintf("segs[%d] != %q", i, s.Literal))
+			last = camelCase(s.Literal)
+		case i == 0 || segs[i-1].Literal == "":
+			return nil
+		default:
+			id := singular(segs[i-1].Literal)
+			param := lowerFirst(camelCase(id))
+			if token.IsKeyword(param) {
+				param += "ID"
+			}
+			field := camelCase(id)
+			r.IDs = append(r.IDs, &resourceID{Field: field, Index: i})
+			pattern = append(pattern, "{"+id+"}")
+			format = append(format, "\x00"+param)
+			cond = append(cond, fmt.Sprintf("segs[%d] == \"\"", i))
+			params = append(params, param)
+			args = append(args, "n."+field)
+			last = field
+		}
+	}
+	if len(r.IDs) == 0 {
+		return nil
+	}
+	r.Type = last + "Name"
+	r.Pattern = strings.Join(pattern, "/")
+	r.Cond = fmt.Sprintf("len(segs) != %d || %s", len(segs), strings.Join(cond, " || "))
+	r.Params = strings.Join(params, ", ")
+	r.Args = strings.Join(args, ", ")
+	// Join the literals and the parameters into one concatenation.
+	var parts []string
+	lit := ""
+	for i, f := range format {
+		if i > 0 {
+			lit += "/"
+		}
+		if strings.HasPrefix(f, "\x00") {
+			if lit != "" {
+				parts = append(parts, fmt.Sprintf("%q", lit))
+			}
+			parts = append(parts, f[1:])
+			lit = ""
+			continue
+		}
+		lit += f
+	}
+	if lit != "" {
+		parts = append(parts, fmt.Sprintf("%q", lit))
+	}
+	r.Format = strings.Join(parts, " + ")
+	return r
+}
+
+// singular returns the singular of the collection name s, by the common
+// English plurals.
+func singular(s string) string {
+	switch {
+	case strings.HasSuffix(s, "ies"):
+		return s[:len(s)-3] + "y"
+	case strings.HasSuffix(s, "lves"):
+		return s[:len(s)-3] + "f"
+	case strings.HasSuffix(s, "sses"), strings.HasSuffix(s, "xes"),
+		strings.HasSuffix(s, "ches"), strings.HasSuffix(s, "shes"):
+		return s[:len(s)-2]
+	case strings.HasSuffix(s, "s") && !strings.HasSuffix(s, "ss"):
+		return s[:len(s)-1]
+	}
+	return s
+}
+
+func lowerFirst(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToLower(s[:1]) + s[1:]
+}
+
+// bind returns the statements setting the fields of req bound to the
+// variables of p from params.
+func (g *nameGen) bind(m *descriptor.MethodDescriptorProto, p *httpgw.Pattern) (string, error) {
+	w := bytes.NewBuffer(nil)
+	for _, v := range p.Vars() {
+		stmts, err := g.assign("req", m.GetInputType(), strings.Split(v, "."), fmt.Sprintf("params[%q]", v))
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(w, "\n{\n%s\n}", stmts)
+	}
+	return w.String(), nil
+}
+
+// assign returns the statements setting the field at path, relative to the
+// message expression target of type typeName, to the string expression src.
+// Intermediate messages are allocated as needed.
+func (g *nameGen) assign(target, typeName string, path []string, src string) (string, error) {
+	f, err := httprule.ResolveField(g, target, typeName, path)
+	if err != nil {
+		return "", err
+	}
+	w := bytes.NewBufferString(f.Alloc)
+	conv, expr := g.convert(f.Desc, src)
+	if conv != "" {
+		w.WriteString(conv + "\n")
+	}
+	w.WriteString(f.Set(expr))
+	return w.String(), nil
+}
+
+// Message implements httprule.Types.
+func (g *nameGen) Message(typeName string) (*descriptor.DescriptorProto, *descriptor.FileDescriptorProto) {
+	return g.idx.messages[typeName], g.idx.files[typeName]
+}
+
+// GoType implements httprule.Types.
+func (g *nameGen) GoType(typeName string) string {
+	return g.imports.goTypeName(g.idx, typeName)
+}
+
+var convertFuncs = map[descriptor.FieldDescriptorProto_Type]string{
+	descriptor.FieldDescriptorProto_TYPE_BOOL:     "Bool",
+	descriptor.FieldDescriptorProto_TYPE_BYTES:    "Bytes",
+	descriptor.FieldDescriptorProto_TYPE_INT32:    "Int32",
+	descriptor.FieldDescriptorProto_TYPE_SINT32:   "Int32",
+	descriptor.FieldDescriptorProto_TYPE_SFIXED32: "Int32",
+	descriptor.FieldDescriptorProto_TYPE_INT64:    "Int64",
+	descriptor.FieldDescriptorProto_TYPE_SINT64:   "Int64",
+	descriptor.FieldDescriptorProto_TYPE_SFIXED64: "Int64",
+	descriptor.FieldDescriptorProto_TYPE_UINT32:   "Uint32",
+	descriptor.FieldDescriptorProto_TYPE_FIXED32:  "Uint32",
+	descriptor.FieldDescriptorProto_TYPE_UINT64:   "Uint64",
+	descriptor.FieldDescriptorProto_TYPE_FIXED64:  "Uint64",
+	descriptor.FieldDescriptorProto_TYPE_FLOAT:    "Float32",
+	descriptor.FieldDescriptorProto_TYPE_DOUBLE:   "Float64",
+}
+
+// convert returns the statements parsing the string expression src into v
+// for a scalar or enum field, and the expression of the converted value.
+func (g *nameGen) convert(field *descriptor.FieldDescriptorProto, src string) (string, string) {
+	const check = "\nif err != nil {\nreturn err\n}"
+	switch field.GetType() {
+	case descriptor.FieldDescriptorProto_TYPE_STRING:
+		return "", src
+	case descriptor.FieldDescriptorProto_TYPE_ENUM:
+		enum := g.imports.goTypeName(g.idx, field.GetTypeName())
+		return fmt.Sprintf("v, err := httpgw.Enum(%s, %s_value)%s", src, enum, check), enum + "(v)"
+	}
+	return fmt.Sprintf("v, err := httpgw.%s(%s)%s", convertFuncs[field.GetType()], src, check), "v"
+}
+
+type header struct {
+	Source  string
+	GoPkg   string
+	Imports map[string]string
+	Names   bool
+	Binders bool
+}
+
+type resource struct {
+	Type    string
+	Pattern string
+	IDs     []*resourceID
+	Cond    string
+	Params  string
+	Args    string
+	Format  string
+}
+
+type resourceID struct {
+	Field string
+	Index int
+}
+
+type binder struct {
+	Func     string
+	Pattern  string
+	Template string
+	Input    string
+	Bind     string
+	// Doc is the comment of the method, see protodoc.Index.Godoc.
+	Doc string
+}
+
+// qualify returns the full proto name of name, declared in scope.
+func qualify(scope, name string) string {
+	if scope == "" {
+		return name
+	}
+	return scope + "." + name
+}
+
+// typeIndex maps fully-qualified proto type names (".pkg.Msg") to their
+// descriptors and declaring files for every file in the request.
+type typeIndex struct {
+	messages map[string]*descriptor.DescriptorProto
+	enums    map[string]*descriptor.EnumDescriptorProto
+	files    map[string]*descriptor.FileDescriptorProto
+}
+
+func newTypeIndex(files []*descriptor.FileDescriptorProto) *typeIndex {
+	idx := &typeIndex{
+		messages: make(map[string]*descriptor.DescriptorProto),
+		enums:    make(map[string]*descriptor.EnumDescriptorProto),
+		files:    make(map[string]*descriptor.FileDescriptorProto),
+	}
+	for _, f := range files {
+		prefix := ""
+		if f.GetPackage() != "" {
+			prefix = "." + f.GetPackage()
+		}
+		idx.addMessages(f, prefix, f.GetMessageType())
+		idx.addEnums(f, prefix, f.GetEnumType())
+	}
+	return idx
+}
+
+func (idx *typeIndex) addMessages(f *descriptor.FileDescriptorProto, prefix string, msgs []*descriptor.DescriptorProto) {
+	for _, m := range msgs {
+		name := prefix + "." + m.GetName()
+		idx.messages[name] = m
+		idx.files[name] = f
+		idx.addMessages(f, name, m.GetNestedType())
+		idx.addEnums(f, name, m.GetEnumType())
+	}
+}
+
+func (idx *typeIndex) addEnums(f *descriptor.FileDescriptorProto, prefix string, enums []*descriptor.EnumDescriptorProto) {
+	for _, e := range enums {
+		name := prefix + "." + e.GetName()
+		idx.enums[name] = e
+		idx.files[name] = f
+	}
+}
+
+// isMap reports whether field is a map field.
+func (idx *typeIndex) isMap(field *descriptor.FieldDescriptorProto) bool {
+	if field.GetType() != descriptor.FieldDescriptorProto_TYPE_MESSAGE {
+		return false
+	}
+	return idx.messages[field.GetTypeName()].GetOptions().GetMapEntry()
+}
+
+// goImportPath returns the import path of the Go package generated from f,
+// or "" if go_package does not name one.
+func goImportPath(f *descriptor.FileDescriptorProto) string {
+	gopkg := f.GetOptions().GetGoPackage()
+	if sc := strings.IndexByte(gopkg, ';'); sc >= 0 {
+		gopkg = gopkg[:sc]
+	}
+	if !strings.Contains(gopkg, "/") {
+		return ""
+	}
+	return gopkg
+}
+
+// importSet records the Go packages referenced by the code generated for
+// one file, keyed by import path.
+type importSet struct {
+	self  string
+	names map[string]string
+}
+
+func newImportSet(f *descriptor.FileDescriptorProto) *importSet {
+	return &importSet{self: goImportPath(f), names: make(map[string]string)}
+}
+
+// goTypeName returns the Go name of the message or enum typeName, qualified
+// with its package if it is declared outside the generated package.
+func (s *importSet) goTypeName(idx *typeIndex, typeName string) string {
+	name := localTypeName(typeName)
+	f := idx.files[typeName]
+	path := goImportPath(f)
+	if f == nil || path == "" || path == s.self {
+		return name
+	}
+	pkg := defaultGoPackageName(f)
+	s.names[path] = pkg
+	return pkg + "." + name
+}
+
+// localTypeName returns the Go name of a type declared in the file being
+// generated, e.g. ".pkg.Outer.Inner" becomes "Outer_Inner".
+func localTypeName(typeName string) string {
+	parts := strings.Split(strings.TrimPrefix(typeName, "."), ".")
+	var names []string
+	for _, p := range parts {
+		if len(p) > 0 && p[0] >= 'A' && p[0] <= 'Z' {
+			names = append(names, p)
+		}
+	}
+	return strings.Join(names, "_")
+}
+
+// camelCase returns the Go field name protoc-gen-go derives from s.
+func camelCase(s string) string {
+	if s == "" {
+		return ""
+	}
+	t := make([]byte, 0, 32)
+	i := 0
+	if s[0] == '_' {
+		// Need a capital letter; drop the '_'.
+		t = append(t, 'X')
+		i++
+	}
+	for ; i < len(s); i++ {
+		c := s[i]
+		if c == '_' && i+1 < len(s) && isASCIILower(s[i+1]) {
+			continue
+		}
+		if isASCIIDigit(c) {
+			t = append(t, c)
+			continue
+		}
+		if isASCIILower(c) {
+			c ^= ' '
+		}
+		t = append(t, c)
+		for i+1 < len(s) && isASCIILower(s[i+1]) {
+			i++
+			t = append(t, s[i])
+		}
+	}
+	return string(t)
+}
+
+func isASCIILower(c byte) bool {
+	return 'a' <= c && c <= 'z'
+}
+
+func isASCIIDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+// sanitizePackageName replaces unallowed character in package name
+// with allowed character.
+func sanitizePackageName(pkgName string) string {
+	pkgName = strings.Replace(pkgName, ".", "_", -1)
+	pkgName = strings.Replace(pkgName, "-", "_", -1)
+	return pkgName
+}
+
+// defaultGoPackageName returns the default go package name to be used for go files generated from "f".
+// You might need to use an unique alias for the package when you import it.  Use ReserveGoPackageAlias to get a unique alias.
+func defaultGoPackageName(f *descriptor.FileDescriptorProto) string {
+	name := packageIdentityName(f)
+	return sanitizePackageName(name)
+}
+
+// packageIdentityName returns the identity of packages.
+// protoc-gen-grpc-gateway rejects CodeGenerationRequests which contains more than one packages
+// as protoc-gen-go does.
+func packageIdentityName(f *descriptor.FileDescriptorProto) string {
+	if f.Options != nil && f.Options.GoPackage != nil {
+		gopkg := f.Options.GetGoPackage()
+		idx := strings.LastIndex(gopkg, "/")
+		if idx < 0 {
+			gopkg = gopkg[idx+1:]
+		}
+
+		gopkg = gopkg[idx+1:]
+		// package name is overrided with the string after the
+		// ';' character
+		sc := strings.IndexByte(gopkg, ';')
+		if sc < 0 {
+			return sanitizePackageName(gopkg)
+
+		}
+		return sanitizePackageName(gopkg[sc+1:])
+	}
+
+	if f.Package == nil {
+		base := filepath.Base(f.GetName())
+		ext := filepath.Ext(base)
+		return strings.TrimSuffix(base, ext)
+	}
+	return f.GetPackage()
+}
+
+func emitFiles(out []*plugin.CodeGeneratorResponse_File) {
+	emitResp(&plugin.CodeGeneratorResponse{File: out})
+}
+
+func emitError(err error) {
+	emitResp(&plugin.CodeGeneratorResponse{Error: proto.String(err.Error())})
+}
+
+func emitResp(resp *plugin.CodeGeneratorResponse) {
+	buf, err := proto.Marshal(resp)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if _, err := os.Stdout.Write(buf); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,14 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/f4tq/protoc-go-plugins/internal/plugintest"
+)
+
+// TestBindings vets the binders of path variables setting fields of every
+// kind.
+func TestBindings(t *testing.T) {
+	req := plugintest.ShelfRequest(t, "")
+	plugintest.Go(t, "vet", plugintest.Package(t, req, generate))
+}
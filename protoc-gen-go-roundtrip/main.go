@@ -0,0 +1,702 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+
+	"github.com/f4tq/protoc-go-plugins/goname"
+	"github.com/f4tq/protoc-go-plugins/protodoc"
+)
+
+var (
+	hdrTmpl = template.Must(template.New("header").Parse(`
+// Code generated by protoc-gen-go-roundtrip. DO NOT EDIT.
+// source: {{.Source}}
+
+package {{.GoPkg}}
+
+import (
+{{- if .Math}}
+    "math"
+{{- end}}
+    "testing"
+
+    "github.com/f4tq/protoc-go-plugins/runtime/roundtrip"
+    "github.com/golang/protobuf/proto"
+{{- range $path, $name := .Imports}}
+    {{$name}} "{{$path}}"
+{{- end}}
+)
+`))
+
+	testTmpl = template.Must(template.New("test").Parse(`
+// Test{{.Name}}RoundTrip checks that {{.FullName}} messages survive JSON
+// and binary round trips unchanged.{{.Doc}}
+func Test{{.Name}}RoundTrip(t *testing.T) {
+    newMsg := func() proto.Message { return new({{.Name}}) }
+    for _, tc := range []struct {
+        name string
+        msg  *{{.Name}}
+    }{
+{{- range .Cases}}
+        {name: {{printf "%q" .Name}}, msg: {{.Msg}}},
+{{- end}}
+    } {
+        t.Run(tc.name, func(t *testing.T) {
+            roundtrip.JSON(t, tc.msg, newMsg)
+            roundtrip.Binary(t, tc.msg, newMsg)
+        })
+    }
+}
+`))
+
+	nestTmpl = template.Must(template.New("nest").Parse(`
+// nest{{.Name}} returns a {{.Name}} holding messages nested depth deep.
+func nest{{.Name}}(depth int) *{{.Name}} {
+    m := {{.Base}}
+    if depth > 0 {
+        m.{{.Field}} = {{.Value}}
+    }
+    return m
+}
+`))
+)
+
+func main() {
+	input, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	req := new(plugin.CodeGeneratorRequest)
+	if err = proto.Unmarshal(input, req); err != nil {
+		log.Fatal(err)
+	}
+
+	out, err := generate(req)
+
+	if err != nil {
+		emitError(err)
+		return
+	}
+
+	emitFiles(out)
+}
+
+// generate writes a table-driven round-trip test for each message of the
+// files to generate, nested ones included, to <file>.pb.roundtrip_test.go.
+// Each table holds the corner cases that apply to the message: empty, max
+// values, unicode and deep nesting.
+func generate(req *plugin.CodeGeneratorRequest) ([]*plugin.CodeGeneratorResponse_File, error) {
+	var files []*plugin.CodeGeneratorResponse_File
+	idx := newTypeIndex(req.GetProtoFile())
+	docs := protodoc.New(req.GetProtoFile())
+	genFileNames := make(map[string]bool)
+	for _, n := range req.FileToGenerate {
+		genFileNames[n] = true
+	}
+	for _, desc := range req.GetProtoFile() {
+		name := desc.GetName()
+		if _, ok := genFileNames[name]; !ok {
+			// Only emit output for files present in req.FileToGenerate.
+			continue
+		}
+		code, err := genCode(desc, idx, docs, genFileNames)
+		if err != nil {
+			return nil, err
+		}
+		if code == "" {
+			// The file declares no messages.
+			continue
+		}
+		formatted, err := format.Source([]byte(code))
+		if err != nil {
+			log.Printf("%v: %s", err, code)
+			return nil, err
+		}
+
+		ext := filepath.Ext(name)
+		base := strings.TrimSuffix(name, ext)
+		output := fmt.Sprintf("%s.pb.roundtrip_test.go", base)
+		files = append(files, &plugin.CodeGeneratorResponse_File{
+			Name:    proto.String(output),
+			Content: proto.String(string(formatted)),
+		})
+	}
+
+	return files, nil
+}
+
+func genCode(desc *descriptor.FileDescriptorProto, idx *typeIndex, docs *protodoc.Index, genFileNames map[string]bool) (string, error) {
+	g := &suiteGen{
+		desc:         desc,
+		idx:          idx,
+		docs:         docs,
+		imports:      newImportSet(desc),
+		genFileNames: genFileNames,
+		proto3:       desc.GetSyntax() == "proto3",
+		building:     make(map[string]bool),
+	}
+	body := bytes.NewBuffer(nil)
+	scope := strings.TrimSuffix("."+desc.GetPackage(), ".")
+	if err := g.messages(body, scope, desc.GetMessageType()); err != nil {
+		return "", fmt.Errorf("%s: %v", desc.GetName(), err)
+	}
+	if body.Len() == 0 {
+		return "", nil
+	}
+
+	w := bytes.NewBuffer(nil)
+	hdr := &header{
+		Source:  desc.GetName(),
+		GoPkg:   defaultGoPackageName(desc),
+		Imports: g.imports.names,
+		Math:    g.math,
+	}
+	if err := hdrTmpl.Execute(w, hdr); err != nil {
+		log.Fatal(err)
+	}
+	body.WriteTo(w)
+
+	return w.String(), nil
+}
+
+type header struct {
+	Source  string
+	GoPkg   string
+	Imports map[string]string
+	Math    bool
+}
+
+// suite is the round-trip test of a message.
+type suite struct {
+	// Name is the Go name of the message.
+	Name     string
+	FullName string
+	Cases    []*testCase
+	// Doc is the comment of the message, see protodoc.Index.Godoc.
+	Doc string
+}
+
+type testCase struct {
+	Name string
+	// Msg is the Go expression of the message.
+	Msg string
+}
+
+// nestFunc is the function building the deep nesting fixture of a message,
+// which nests its messages through Field.
+type nestFunc struct {
+	Name string
+	// Base is the message with its required fields set, and Value the
+	// expression of Field nesting depth-1 deep.
+	Base, Field, Value string
+}
+
+type suiteGen struct {
+	desc         *descriptor.FileDescriptorProto
+	idx          *typeIndex
+	docs         *protodoc.Index
+	imports      *importSet
+	genFileNames map[string]bool
+	proto3       bool
+	// math records whether the fixtures refer to package math.
+	math bool
+	// building holds the messages whose literals are being built.
+	building map[string]bool
+}
+
+// messages writes the tests of msgs and of the messages nested in them.
+// scope is the full proto name of their parent, with a leading dot.
+func (g *suiteGen) messages(w *bytes.Buffer, scope string, msgs []*descriptor.DescriptorProto) error {
+	for _, msg := range msgs {
+		if msg.GetOptions().GetMapEntry() {
+			continue
+		}
+		typeName := scope + "." + msg.GetName()
+		s := &suite{Name: localTypeName(typeName), FullName: strings.TrimPrefix(typeName, "."), Doc: g.docs.Godoc(typeName)}
+		s.Cases = append(s.Cases, &testCase{Name: "empty", Msg: g.literal(typeName, "")})
+		if lit := g.literal(typeName, "max"); lit != "" {
+			s.Cases = append(s.Cases, &testCase{Name: "max values", Msg: lit})
+		}
+		if lit := g.literal(typeName, "unicode"); lit != "" {
+			s.Cases = append(s.Cases, &testCase{Name: "unicode", Msg: lit})
+		}
+		nest := g.nestFunc(typeName)
+		if nest != nil {
+			s.Cases = append(s.Cases, &testCase{Name: "deep nesting", Msg: fmt.Sprintf("nest%s(roundtrip.Depth)", s.Name)})
+		}
+		if err := testTmpl.Execute(w, s); err != nil {
+			return err
+		}
+		if nest != nil {
+			if err := nestTmpl.Execute(w, nest); err != nil {
+				return err
+			}
+		}
+		if err := g.messages(w, typeName, msg.GetNestedType()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// literal returns the Go expression of the message typeName with the
+// fields of the fixture kind set, "max" or "unicode", or "" if none
+// applies. The fields proto2 requires are set in every fixture, and are
+// all the kind "" sets.
+func (g *suiteGen) literal(typeName, kind string) string {
+	msg := g.idx.messages[typeName]
+	name := g.imports.goTypeName(g.idx, typeName)
+	if g.building[typeName] {
+		// The required fields of the message lead back to it: no finite
+		// message has them all set.
+		return fmt.Sprintf("&%s{}", name)
+	}
+	g.building[typeName] = true
+	defer delete(g.building, typeName)
+	var fields []string
+	set := false
+	oneofs := make(map[int32]bool)
+	goNames := goname.Fields(msg)
+	for _, field := range msg.GetField() {
+		goName := goNames[field.GetName()]
+		required := field.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REQUIRED
+		if field.OneofIndex != nil && !field.GetProto3Optional() {
+			i := field.GetOneofIndex()
+			if oneofs[i] || field.GetType() == descriptor.FieldDescriptorProto_TYPE_MESSAGE {
+				continue
+			}
+			if v := g.value(field, kind); v != "" {
+				oneofs[i] = true
+				set = true
+				fields = append(fields, fmt.Sprintf("%s: &%s_%s{%s: %s}", goNames[msg.GetOneofDecl()[i].GetName()], localTypeName(typeName), goName, goName, v))
+			}
+			continue
+		}
+		if expr := g.fieldExpr(field, kind); expr != "" {
+			set = true
+			fields = append(fields, fmt.Sprintf("%s: %s", goName, expr))
+		} else if required {
+			fields = append(fields, fmt.Sprintf("%s: %s", goName, g.fieldExpr(field, "zero")))
+		}
+	}
+	if kind != "" && !set {
+		return ""
+	}
+	if len(fields) == 0 {
+		return fmt.Sprintf("&%s{}", name)
+	}
+	return fmt.Sprintf("&%s{\n%s,\n}", name, strings.Join(fields, ",\n"))
+}
+
+// fieldExpr returns the Go expression of field in the fixture kind, or ""
+// if kind leaves it unset.
+func (g *suiteGen) fieldExpr(field *descriptor.FieldDescriptorProto, kind string) string {
+	if g.idx.isMap(field) {
+		entry := g.idx.messages[field.GetTypeName()]
+		key, value := g.value(entry.GetField()[0], kind), g.value(entry.GetField()[1], kind)
+		if key == "" && value == "" || entry.GetField()[1].GetType() == descriptor.FieldDescriptorProto_TYPE_MESSAGE {
+			// Message values are left to the deep nesting fixture.
+			return ""
+		}
+		if key == "" {
+			key = g.value(entry.GetField()[0], "zero")
+		}
+		if value == "" {
+			value = g.value(entry.GetField()[1], "zero")
+		}
+		return fmt.Sprintf("map[%s]%s{%s: %s}", g.goType(entry.GetField()[0]), g.goType(entry.GetField()[1]), key, value)
+	}
+	v := g.value(field, kind)
+	switch {
+	case v == "":
+		return ""
+	case field.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REPEATED:
+		return fmt.Sprintf("[]%s{%s}", g.goType(field), v)
+	case g.pointer(field):
+		return fmt.Sprintf("roundtrip.Ptr[%s](%s)", g.goType(field), v)
+	}
+	return v
+}
+
+// value returns the Go expression of a value of field in the fixture
+// kind, "max", "unicode" or "zero", or "" if kind leaves it unset. Message
+// values are empty messages in the zero fixture, and unset in the others.
+func (g *suiteGen) value(field *descriptor.FieldDescriptorProto, kind string) string {
+	typ := field.GetType()
+	switch {
+	case typ == descriptor.FieldDescriptorProto_TYPE_MESSAGE:
+		if kind == "zero" {
+			return g.literal(field.GetTypeName(), "")
+		}
+		return ""
+	case kind == "zero":
+		switch typ {
+		case descriptor.FieldDescriptorProto_TYPE_STRING:
+			return `""`
+		case descriptor.FieldDescriptorProto_TYPE_BYTES:
+			return "[]byte{}"
+		case descriptor.FieldDescriptorProto_TYPE_BOOL:
+			return "false"
+		}
+		return "0"
+	case kind == "unicode":
+		switch typ {
+		case descriptor.FieldDescriptorProto_TYPE_STRING:
+			return "roundtrip.Unicode"
+		case descriptor.FieldDescriptorProto_TYPE_BYTES:
+			return "[]byte(roundtrip.Unicode)"
+		}
+		return ""
+	case kind != "max":
+		return ""
+	case typ == descriptor.FieldDescriptorProto_TYPE_STRING:
+		return "roundtrip.Long"
+	case typ == descriptor.FieldDescriptorProto_TYPE_BYTES:
+		return "[]byte(roundtrip.Long)"
+	case typ == descriptor.FieldDescriptorProto_TYPE_BOOL:
+		return "true"
+	case typ == descriptor.FieldDescriptorProto_TYPE_ENUM:
+		var max int32
+		for i, v := range g.idx.enums[field.GetTypeName()].GetValue() {
+			if i == 0 || v.GetNumber() > max {
+				max = v.GetNumber()
+			}
+		}
+		return strconv.Itoa(int(max))
+	}
+	g.math = true
+	return maxValues[typ]
+}
+
+// maxValues are the greatest values of the numeric proto types.
+var maxValues = map[descriptor.FieldDescriptorProto_Type]string{
+	descriptor.FieldDescriptorProto_TYPE_INT32:    "math.MaxInt32",
+	descriptor.FieldDescriptorProto_TYPE_SINT32:   "math.MaxInt32",
+	descriptor.FieldDescriptorProto_TYPE_SFIXED32: "math.MaxInt32",
+	descriptor.FieldDescriptorProto_TYPE_UINT32:   "math.MaxUint32",
+	descriptor.FieldDescriptorProto_TYPE_FIXED32:  "math.MaxUint32",
+	descriptor.FieldDescriptorProto_TYPE_INT64:    "math.MaxInt64",
+	descriptor.FieldDescriptorProto_TYPE_SINT64:   "math.MaxInt64",
+	descriptor.FieldDescriptorProto_TYPE_SFIXED64: "math.MaxInt64",
+	descriptor.FieldDescriptorProto_TYPE_UINT64:   "math.MaxUint64",
+	descriptor.FieldDescriptorProto_TYPE_FIXED64:  "math.MaxUint64",
+	descriptor.FieldDescriptorProto_TYPE_FLOAT:    "math.MaxFloat32",
+	descriptor.FieldDescriptorProto_TYPE_DOUBLE:   "math.MaxFloat64",
+}
+
+// scalarTypes are the Go types of the scalar proto types.
+var scalarTypes = map[descriptor.FieldDescriptorProto_Type]string{
+	descriptor.FieldDescriptorProto_TYPE_INT32:    "int32",
+	descriptor.FieldDescriptorProto_TYPE_SINT32:   "int32",
+	descriptor.FieldDescriptorProto_TYPE_SFIXED32: "int32",
+	descriptor.FieldDescriptorProto_TYPE_UINT32:   "uint32",
+	descriptor.FieldDescriptorProto_TYPE_FIXED32:  "uint32",
+	descriptor.FieldDescriptorProto_TYPE_INT64:    "int64",
+	descriptor.FieldDescriptorProto_TYPE_SINT64:   "int64",
+	descriptor.FieldDescriptorProto_TYPE_SFIXED64: "int64",
+	descriptor.FieldDescriptorProto_TYPE_UINT64:   "uint64",
+	descriptor.FieldDescriptorProto_TYPE_FIXED64:  "uint64",
+	descriptor.FieldDescriptorProto_TYPE_FLOAT:    "float32",
+	descriptor.FieldDescriptorProto_TYPE_DOUBLE:   "float64",
+	descriptor.FieldDescriptorProto_TYPE_BOOL:     "bool",
+	descriptor.FieldDescriptorProto_TYPE_STRING:   "string",
+	descriptor.FieldDescriptorProto_TYPE_BYTES:    "[]byte",
+}
+
+// goType returns the Go type of a value of field.
+func (g *suiteGen) goType(field *descriptor.FieldDescriptorProto) string {
+	switch field.GetType() {
+	case descriptor.FieldDescriptorProto_TYPE_MESSAGE:
+		return "*" + g.imports.goTypeName(g.idx, field.GetTypeName())
+	case descriptor.FieldDescriptorProto_TYPE_ENUM:
+		return g.imports.goTypeName(g.idx, field.GetTypeName())
+	}
+	return scalarTypes[field.GetType()]
+}
+
+// pointer reports whether the Go field of the singular scalar field is a
+// pointer.
+func (g *suiteGen) pointer(field *descriptor.FieldDescriptorProto) bool {
+	if field.GetType() == descriptor.FieldDescriptorProto_TYPE_MESSAGE {
+		return false
+	}
+	return (field.GetProto3Optional() || !g.proto3) && field.GetType() != descriptor.FieldDescriptorProto_TYPE_BYTES
+}
+
+// nestFunc returns the function building the deep nesting fixture of the
+// message typeName, or nil if it holds no message of the package. It nests
+// through the first message field leading back to the message, or else
+// through the first message field.
+func (g *suiteGen) nestFunc(typeName string) *nestFunc {
+	msg := g.idx.messages[typeName]
+	var through *descriptor.FieldDescriptorProto
+	for _, field := range msg.GetField() {
+		value := field
+		if g.idx.isMap(field) {
+			value = g.idx.messages[field.GetTypeName()].GetField()[1]
+		}
+		if value.GetType() != descriptor.FieldDescriptorProto_TYPE_MESSAGE || !g.local(value.GetTypeName()) {
+			continue
+		}
+		if through == nil {
+			through = field
+		}
+		if g.reaches(value.GetTypeName(), typeName, make(map[string]bool)) {
+			through = field
+			break
+		}
+	}
+	if through == nil {
+		return nil
+	}
+	value := through
+	if g.idx.isMap(through) {
+		value = g.idx.messages[through.GetTypeName()].GetField()[1]
+	}
+	elem := g.literal(value.GetTypeName(), "")
+	if g.nests(value.GetTypeName()) {
+		elem = fmt.Sprintf("nest%s(depth-1)", localTypeName(value.GetTypeName()))
+	}
+	goNames := goname.Fields(msg)
+	n := &nestFunc{
+		Name:  localTypeName(typeName),
+		Base:  g.literal(typeName, ""),
+		Field: goNames[through.GetName()],
+		Value: elem,
+	}
+	switch {
+	case g.idx.isMap(through):
+		key := g.idx.messages[through.GetTypeName()].GetField()[0]
+		n.Value = fmt.Sprintf("map[%s]%s{%s: %s}", g.goType(key), g.goType(value), g.value(key, "zero"), elem)
+	case through.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REPEATED:
+		n.Value = fmt.Sprintf("[]%s{%s}", g.goType(value), elem)
+	case through.OneofIndex != nil && !through.GetProto3Optional():
+		n.Field = goNames[msg.GetOneofDecl()[through.GetOneofIndex()].GetName()]
+		n.Value = fmt.Sprintf("&%s_%s{%s: %s}", n.Name, goNames[through.GetName()], goNames[through.GetName()], elem)
+	}
+	return n
+}
+
+// nests reports whether the message typeName has a nest function.
+func (g *suiteGen) nests(typeName string) bool {
+	for _, field := range g.idx.messages[typeName].GetField() {
+		value := field
+		if g.idx.isMap(field) {
+			value = g.idx.messages[field.GetTypeName()].GetField()[1]
+		}
+		if value.GetType() == descriptor.FieldDescriptorProto_TYPE_MESSAGE && g.local(value.GetTypeName()) {
+			return true
+		}
+	}
+	return false
+}
+
+// reaches reports whether the message fields of the message typeName, and
+// those of the messages they hold, lead to target.
+func (g *suiteGen) reaches(typeName, target string, seen map[string]bool) bool {
+	if typeName == target {
+		return true
+	}
+	if seen[typeName] {
+		return false
+	}
+	seen[typeName] = true
+	for _, field := range g.idx.messages[typeName].GetField() {
+		value := field
+		if g.idx.isMap(field) {
+			value = g.idx.messages[field.GetTypeName()].GetField()[1]
+		}
+		if value.GetType() == descriptor.FieldDescriptorProto_TYPE_MESSAGE && g.local(value.GetTypeName()) &&
+			g.reaches(value.GetTypeName(), target, seen) {
+			return true
+		}
+	}
+	return false
+}
+
+// local reports whether the message typeName is declared in the Go
+// package being generated, by a file whose tests are generated.
+func (g *suiteGen) local(typeName string) bool {
+	f := g.idx.files[typeName]
+	return f != nil && g.genFileNames[f.GetName()] &&
+		goImportPath(f) == goImportPath(g.desc) && defaultGoPackageName(f) == defaultGoPackageName(g.desc)
+}
+
+// typeIndex maps fully-qualified proto type names (".pkg.Msg") to their
+// descriptors and declaring files for every file in the request.
+type typeIndex struct {
+	messages map[string]*descriptor.DescriptorProto
+	enums    map[string]*descriptor.EnumDescriptorProto
+	files    map[string]*descriptor.FileDescriptorProto
+}
+
+func newTypeIndex(files []*descriptor.FileDescriptorProto) *typeIndex {
+	idx := &typeIndex{
+		messages: make(map[string]*descriptor.DescriptorProto),
+		enums:    make(map[string]*descriptor.EnumDescriptorProto),
+		files:    make(map[string]*descriptor.FileDescriptorProto),
+	}
+	for _, f := range files {
+		prefix := ""
+		if f.GetPackage() != "" {
+			prefix = "." + f.GetPackage()
+		}
+		idx.addMessages(f, prefix, f.GetMessageType())
+		idx.addEnums(f, prefix, f.GetEnumType())
+	}
+	return idx
+}
+
+func (idx *typeIndex) addMessages(f *descriptor.FileDescriptorProto, prefix string, msgs []*descriptor.DescriptorProto) {
+	for _, m := range msgs {
+		name := prefix + "." + m.GetName()
+		idx.messages[name] = m
+		idx.files[name] = f
+		idx.addMessages(f, name, m.GetNestedType())
+		idx.addEnums(f, name, m.GetEnumType())
+	}
+}
+
+func (idx *typeIndex) addEnums(f *descriptor.FileDescriptorProto, prefix string, enums []*descriptor.EnumDescriptorProto) {
+	for _, e := range enums {
+		name := prefix + "." + e.GetName()
+		idx.enums[name] = e
+		idx.files[name] = f
+	}
+}
+
+// isMap reports whether field is a map field.
+func (idx *typeIndex) isMap(field *descriptor.FieldDescriptorProto) bool {
+	if field.GetType() != descriptor.FieldDescriptorProto_TYPE_MESSAGE {
+		return false
+	}
+	return idx.messages[field.GetTypeName()].GetOptions().GetMapEntry()
+}
+
+// goImportPath returns the import path of the Go package generated from f,
+// or "" if go_package does not name one.
+func goImportPath(f *descriptor.FileDescriptorProto) string {
+	gopkg := f.GetOptions().GetGoPackage()
+	if sc := strings.IndexByte(gopkg, ';'); sc >= 0 {
+		gopkg = gopkg[:sc]
+	}
+	if !strings.Contains(gopkg, "/") {
+		return ""
+	}
+	return gopkg
+}
+
+// importSet records the Go packages referenced by the code generated for
+// one file, keyed by import path.
+type importSet struct {
+	self  string
+	names map[string]string
+}
+
+func newImportSet(f *descriptor.FileDescriptorProto) *importSet {
+	return &importSet{self: goImportPath(f), names: make(map[string]string)}
+}
+
+// goTypeName returns the Go name of the message or enum typeName, qualified
+// with its package if it is declared outside the generated package.
+func (s *importSet) goTypeName(idx *typeIndex, typeName string) string {
+	name := localTypeName(typeName)
+	f := idx.files[typeName]
+	path := goImportPath(f)
+	if f == nil || path == "" || path == s.self {
+		return name
+	}
+	pkg := defaultGoPackageName(f)
+	s.names[path] = pkg
+	return pkg + "." + name
+}
+
+// localTypeName returns the Go name of a type declared in the file being
+// generated, e.g. ".pkg.Outer.Inner" becomes "Outer_Inner".
+func localTypeName(typeName string) string {
+	parts := strings.Split(strings.TrimPrefix(typeName, "."), ".")
+	var names []string
+	for _, p := range parts {
+		if len(p) > 0 && p[0] >= 'A' && p[0] <= 'Z' {
+			names = append(names, p)
+		}
+	}
+	return strings.Join(names, "_")
+}
+
+// sanitizePackageName replaces unallowed character in package name
+// with allowed character.
+func sanitizePackageName(pkgName string) string {
+	pkgName = strings.Replace(pkgName, ".", "_", -1)
+	pkgName = strings.Replace(pkgName, "-", "_", -1)
+	return pkgName
+}
+
+// defaultGoPackageName returns the default go package name to be used for go files generated from "f".
+// You might need to use an unique alias for the package when you import it.  Use ReserveGoPackageAlias to get a unique alias.
+func defaultGoPackageName(f *descriptor.FileDescriptorProto) string {
+	name := packageIdentityName(f)
+	return sanitizePackageName(name)
+}
+
+// packageIdentityName returns the identity of packages.
+// protoc-gen-grpc-gateway rejects CodeGenerationRequests which contains more than one packages
+// as protoc-gen-go does.
+func packageIdentityName(f *descriptor.FileDescriptorProto) string {
+	if f.Options != nil && f.Options.GoPackage != nil {
+		gopkg := f.Options.GetGoPackage()
+		idx := strings.LastIndex(gopkg, "/")
+		if idx < 0 {
+			gopkg = gopkg[idx+1:]
+		}
+
+		gopkg = gopkg[idx+1:]
+		// package name is overrided with the string after the
+		// ';' character
+		sc := strings.IndexByte(gopkg, ';')
+		if sc < 0 {
+			return sanitizePackageName(gopkg)
+
+		}
+		return sanitizePackageName(gopkg[sc+1:])
+	}
+
+	if f.Package == nil {
+		base := filepath.Base(f.GetName())
+		ext := filepath.Ext(base)
+		return strings.TrimSuffix(base, ext)
+	}
+	return f.GetPackage()
+}
+
+func emitFiles(out []*plugin.CodeGeneratorResponse_File) {
+	emitResp(&plugin.CodeGeneratorResponse{File: out})
+}
+
+func emitError(err error) {
+	emitResp(&plugin.CodeGeneratorResponse{Error: proto.String(err.Error())})
+}
+
+func emitResp(resp *plugin.CodeGeneratorResponse) {
+	buf, err := proto.Marshal(resp)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if _, err := os.Stdout.Write(buf); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/f4tq/protoc-go-plugins/internal/plugintest"
+)
+
+const docProto = `
+syntax = "proto3";
+
+package doc.v1;
+
+option go_package = "example.com/doc/v1;docv1";
+
+// Doc is a document.
+message Doc {
+    optional bytes blob = 1;
+    optional int32 size = 2;
+    optional string title = 3;
+    Doc parent = 4;
+}
+`
+
+// TestGeneratedTestsPass runs the generated suites of messages with fields
+// of the kinds literals set apart.
+func TestGeneratedTestsPass(t *testing.T) {
+	tests := []struct {
+		name string
+		pkg  func(t *testing.T) string
+	}{
+		{"optional fields", func(t *testing.T) string {
+			req := plugintest.Request(t, "", map[string]string{"doc/v1/doc.proto": docProto})
+			return plugintest.Package(t, req, generate)
+		}},
+		{"conflicting field names", func(t *testing.T) string {
+			return plugintest.Package(t, plugintest.ConflictsRequest(t, ""), generate)
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			plugintest.Go(t, "test", test.pkg(t))
+		})
+	}
+}
+
+// TestDoc checks the suites carry the comments of their messages.
+func TestDoc(t *testing.T) {
+	req := plugintest.Request(t, "", map[string]string{"doc/v1/doc.proto": docProto})
+	for name, content := range plugintest.Generate(t, req, generate) {
+		if !strings.Contains(content, "// Doc is a document.") {
+			t.Errorf("%s does not carry the comment of Doc:\n%s", name, content)
+		}
+	}
+}
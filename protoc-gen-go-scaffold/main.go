@@ -0,0 +1,552 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"unicode"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+)
+
+// The scaffold is a starting point that is meant to be edited, so it is not
+// marked as generated code.
+const scaffoldHeader = `
+// Scaffolded by protoc-gen-go-scaffold for {{.FullName}}
+// from {{.Source}}. Edit freely; regenerating overwrites this file.
+
+package main
+`
+
+var (
+	mainTmpl = template.Must(template.New("main").Parse(scaffoldHeader + `
+import (
+    "context"
+    "log"
+    "net"
+    "os"
+    "os/signal"
+    "runtime/debug"
+    "syscall"
+    "time"
+
+    "google.golang.org/grpc"
+    "google.golang.org/grpc/codes"
+    "google.golang.org/grpc/credentials"
+    "google.golang.org/grpc/status"
+    {{.PkgName}} "{{.PkgPath}}"
+)
+
+func main() {
+    cfg, err := loadConfig()
+    if err != nil {
+        log.Fatal(err)
+    }
+
+    opts := []grpc.ServerOption{
+        grpc.ChainUnaryInterceptor(unaryInterceptors()...),
+        grpc.ChainStreamInterceptor(streamInterceptors()...),
+    }
+    if cfg.TLSCert != "" {
+        creds, err := credentials.NewServerTLSFromFile(cfg.TLSCert, cfg.TLSKey)
+        if err != nil {
+            log.Fatal(err)
+        }
+        opts = append(opts, grpc.Creds(creds))
+    }
+    s := grpc.NewServer(opts...)
+    {{.PkgName}}.Register{{.Name}}Server(s, newServer())
+
+    lis, err := net.Listen("tcp", cfg.Addr)
+    if err != nil {
+        log.Fatal(err)
+    }
+
+    stopped := make(chan struct{})
+    go func() {
+        defer close(stopped)
+        sig := make(chan os.Signal, 1)
+        signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+        log.Printf("received %v, shutting down", <-sig)
+
+        done := make(chan struct{})
+        go func() {
+            s.GracefulStop()
+            close(done)
+        }()
+        select {
+        case <-done:
+        case <-time.After(cfg.ShutdownTimeout):
+            log.Printf("shutdown timed out after %v, closing open calls", cfg.ShutdownTimeout)
+            s.Stop()
+        }
+    }()
+
+    log.Printf("serving {{.FullName}} on %s", lis.Addr())
+    if err := s.Serve(lis); err != nil {
+        log.Fatal(err)
+    }
+    <-stopped
+}
+
+// unaryInterceptors returns the unary interceptors of the server, outermost
+// first.
+func unaryInterceptors() []grpc.UnaryServerInterceptor {
+    // TODO: add authentication, metrics, tracing and validation.
+    return []grpc.UnaryServerInterceptor{recoverUnary, logUnary}
+}
+
+// streamInterceptors returns the stream interceptors of the server,
+// outermost first.
+func streamInterceptors() []grpc.StreamServerInterceptor {
+    // TODO: add authentication, metrics, tracing and validation.
+    return []grpc.StreamServerInterceptor{recoverStream, logStream}
+}
+
+func recoverUnary(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+    defer func() {
+        if r := recover(); r != nil {
+            log.Printf("%s: panic: %v\n%s", info.FullMethod, r, debug.Stack())
+            err = status.Error(codes.Internal, "internal error")
+        }
+    }()
+    return handler(ctx, req)
+}
+
+func recoverStream(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+    defer func() {
+        if r := recover(); r != nil {
+            log.Printf("%s: panic: %v\n%s", info.FullMethod, r, debug.Stack())
+            err = status.Error(codes.Internal, "internal error")
+        }
+    }()
+    return handler(srv, ss)
+}
+
+func logUnary(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+    start := time.Now()
+    resp, err := handler(ctx, req)
+    log.Printf("%s %v %v", info.FullMethod, status.Code(err), time.Since(start))
+    return resp, err
+}
+
+func logStream(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+    start := time.Now()
+    err := handler(srv, ss)
+    log.Printf("%s %v %v", info.FullMethod, status.Code(err), time.Since(start))
+    return err
+}
+`))
+
+	configTmpl = template.Must(template.New("config").Parse(scaffoldHeader + `
+import (
+    "errors"
+    "flag"
+    "fmt"
+    "os"
+    "time"
+)
+
+// Config is the configuration of the server. Each setting is read from its
+// flag, which defaults to the environment variable named in its usage.
+type Config struct {
+    // Addr is the address the server listens on.
+    Addr string
+    // TLSCert and TLSKey are the PEM files of the server certificate and
+    // key. The server uses plaintext if both are empty.
+    TLSCert string
+    TLSKey  string
+    // ShutdownTimeout is how long open calls may run after a shutdown
+    // signal before they are canceled.
+    ShutdownTimeout time.Duration
+    // TODO: add the settings of the service, e.g. database addresses.
+}
+
+func loadConfig() (*Config, error) {
+    cfg := &Config{
+        Addr:    envOr("{{.EnvPrefix}}_ADDR", ":8080"),
+        TLSCert: os.Getenv("{{.EnvPrefix}}_TLS_CERT"),
+        TLSKey:  os.Getenv("{{.EnvPrefix}}_TLS_KEY"),
+    }
+    timeout, err := time.ParseDuration(envOr("{{.EnvPrefix}}_SHUTDOWN_TIMEOUT", "30s"))
+    if err != nil {
+        return nil, fmt.Errorf("{{.EnvPrefix}}_SHUTDOWN_TIMEOUT: %v", err)
+    }
+
+    flag.StringVar(&cfg.Addr, "addr", cfg.Addr, "listen address (${{.EnvPrefix}}_ADDR)")
+    flag.StringVar(&cfg.TLSCert, "tls-cert", cfg.TLSCert, "TLS certificate file (${{.EnvPrefix}}_TLS_CERT)")
+    flag.StringVar(&cfg.TLSKey, "tls-key", cfg.TLSKey, "TLS key file (${{.EnvPrefix}}_TLS_KEY)")
+    flag.DurationVar(&cfg.ShutdownTimeout, "shutdown-timeout", timeout, "time open calls may run after a shutdown signal (${{.EnvPrefix}}_SHUTDOWN_TIMEOUT)")
+    flag.Parse()
+
+    if (cfg.TLSCert == "") != (cfg.TLSKey == "") {
+        return nil, errors.New("-tls-cert and -tls-key must be set together")
+    }
+    return cfg, nil
+}
+
+func envOr(key, def string) string {
+    if v, ok := os.LookupEnv(key); ok {
+        return v
+    }
+    return def
+}
+`))
+
+	serverTmpl = template.Must(template.New("server").Parse(scaffoldHeader + `
+import (
+{{- if .Unary}}
+    "context"
+{{end}}
+    "google.golang.org/grpc/codes"
+    "google.golang.org/grpc/status"
+{{- range $path, $name := .Imports}}
+    {{$name}} "{{$path}}"
+{{- end}}
+)
+
+// server implements {{.PkgName}}.{{.Name}}Server.
+type server struct {
+    {{.PkgName}}.Unimplemented{{.Name}}Server
+
+    // TODO: add the dependencies of the service, e.g. a database handle.
+}
+
+func newServer() *server {
+    return &server{}
+}
+{{range .Methods}}
+{{- if .ClientStreaming}}
+func (s *server) {{.Name}}(stream {{$.PkgName}}.{{$.Name}}_{{.Name}}Server) error {
+{{- else if .ServerStreaming}}
+func (s *server) {{.Name}}(req *{{.Input}}, stream {{$.PkgName}}.{{$.Name}}_{{.Name}}Server) error {
+{{- else}}
+func (s *server) {{.Name}}(ctx context.Context, req *{{.Input}}) (*{{.Output}}, error) {
+{{- end}}
+    // TODO: implement {{.Name}}.
+    return {{if not .Streaming}}nil, {{end}}status.Error(codes.Unimplemented, "{{.Name}} is not implemented")
+}
+{{end}}
+`))
+)
+
+func main() {
+	input, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	req := new(plugin.CodeGeneratorRequest)
+	if err = proto.Unmarshal(input, req); err != nil {
+		log.Fatal(err)
+	}
+
+	out, err := generate(req)
+
+	if err != nil {
+		emitError(err)
+		return
+	}
+
+	emitFiles(out)
+}
+
+func generate(req *plugin.CodeGeneratorRequest) ([]*plugin.CodeGeneratorResponse_File, error) {
+	var files []*plugin.CodeGeneratorResponse_File
+	idx := newTypeIndex(req.GetProtoFile())
+	genFileNames := make(map[string]bool)
+	for _, n := range req.FileToGenerate {
+		genFileNames[n] = true
+	}
+	for _, desc := range req.GetProtoFile() {
+		name := desc.GetName()
+		if _, ok := genFileNames[name]; !ok {
+			// Only emit output for files present in req.FileToGenerate.
+			continue
+		}
+		if len(desc.GetService()) == 0 {
+			// Nothing to serve.
+			continue
+		}
+		if goImportPath(desc) == "" {
+			return nil, fmt.Errorf("%s: go_package must name the import path the scaffold imports", name)
+		}
+		for _, svc := range desc.GetService() {
+			out, err := genService(desc, svc, idx)
+			if err != nil {
+				return nil, err
+			}
+			files = append(files, out...)
+		}
+	}
+
+	return files, nil
+}
+
+// genService returns main.go, config.go and server.go of the server of svc,
+// in cmd/<service-name> next to the proto file.
+func genService(desc *descriptor.FileDescriptorProto, svc *descriptor.ServiceDescriptorProto, idx *typeIndex) ([]*plugin.CodeGeneratorResponse_File, error) {
+	fullName := svc.GetName()
+	if desc.GetPackage() != "" {
+		fullName = desc.GetPackage() + "." + svc.GetName()
+	}
+	kebab := kebabCase(svc.GetName())
+	s := &scaffoldService{
+		Source:    desc.GetName(),
+		Name:      svc.GetName(),
+		FullName:  fullName,
+		PkgName:   defaultGoPackageName(desc),
+		PkgPath:   goImportPath(desc),
+		EnvPrefix: strings.ToUpper(strings.Replace(kebab, "-", "_", -1)),
+	}
+	// The scaffold is package main, so every message is qualified.
+	imports := &importSet{names: map[string]string{s.PkgPath: s.PkgName}}
+	for _, m := range svc.GetMethod() {
+		streaming := m.GetClientStreaming() || m.GetServerStreaming()
+		s.Methods = append(s.Methods, &scaffoldMethod{
+			Name:            m.GetName(),
+			Input:           imports.goTypeName(idx, m.GetInputType()),
+			Output:          imports.goTypeName(idx, m.GetOutputType()),
+			ClientStreaming: m.GetClientStreaming(),
+			ServerStreaming: m.GetServerStreaming(),
+			Streaming:       streaming,
+		})
+		s.Unary = s.Unary || !streaming
+	}
+	s.Imports = imports.names
+
+	dir := path.Join(path.Dir(desc.GetName()), "cmd", kebab)
+	var files []*plugin.CodeGeneratorResponse_File
+	for _, f := range []struct {
+		name string
+		tmpl *template.Template
+	}{
+		{"main.go", mainTmpl},
+		{"config.go", configTmpl},
+		{"server.go", serverTmpl},
+	} {
+		var buf bytes.Buffer
+		if err := f.tmpl.Execute(&buf, s); err != nil {
+			return nil, err
+		}
+		formatted, err := format.Source(buf.Bytes())
+		if err != nil {
+			log.Printf("%v: %s", err, buf.String())
+			return nil, err
+		}
+		files = append(files, &plugin.CodeGeneratorResponse_File{
+			Name:    proto.String(path.Join(dir, f.name)),
+			Content: proto.String(string(formatted)),
+		})
+	}
+	return files, nil
+}
+
+// kebabCase converts a CamelCase service name to kebab-case, keeping
+// acronyms together: "HTTPProxyService" becomes "http-proxy-service".
+func kebabCase(s string) string {
+	var b strings.Builder
+	r := []rune(s)
+	for i, c := range r {
+		if unicode.IsUpper(c) && i > 0 && (unicode.IsLower(r[i-1]) || i+1 < len(r) && unicode.IsLower(r[i+1])) {
+			b.WriteByte('-')
+		}
+		b.WriteRune(unicode.ToLower(c))
+	}
+	return b.String()
+}
+
+type scaffoldService struct {
+	Source    string
+	Name      string
+	FullName  string
+	PkgName   string
+	PkgPath   string
+	EnvPrefix string
+	Imports   map[string]string
+	Unary     bool
+	Methods   []*scaffoldMethod
+}
+
+type scaffoldMethod struct {
+	Name            string
+	Input           string
+	Output          string
+	ClientStreaming bool
+	ServerStreaming bool
+	Streaming       bool
+}
+
+// typeIndex maps fully-qualified proto type names (".pkg.Msg") to their
+// descriptors and declaring files for every file in the request.
+type typeIndex struct {
+	messages map[string]*descriptor.DescriptorProto
+	enums    map[string]*descriptor.EnumDescriptorProto
+	files    map[string]*descriptor.FileDescriptorProto
+}
+
+func newTypeIndex(files []*descriptor.FileDescriptorProto) *typeIndex {
+	idx := &typeIndex{
+		messages: make(map[string]*descriptor.DescriptorProto),
+		enums:    make(map[string]*descriptor.EnumDescriptorProto),
+		files:    make(map[string]*descriptor.FileDescriptorProto),
+	}
+	for _, f := range files {
+		prefix := ""
+		if f.GetPackage() != "" {
+			prefix = "." + f.GetPackage()
+		}
+		idx.addMessages(f, prefix, f.GetMessageType())
+		idx.addEnums(f, prefix, f.GetEnumType())
+	}
+	return idx
+}
+
+func (idx *typeIndex) addMessages(f *descriptor.FileDescriptorProto, prefix string, msgs []*descriptor.DescriptorProto) {
+	for _, m := range msgs {
+		name := prefix + "." + m.GetName()
+		idx.messages[name] = m
+		idx.files[name] = f
+		idx.addMessages(f, name, m.GetNestedType())
+		idx.addEnums(f, name, m.GetEnumType())
+	}
+}
+
+func (idx *typeIndex) addEnums(f *descriptor.FileDescriptorProto, prefix string, enums []*descriptor.EnumDescriptorProto) {
+	for _, e := range enums {
+		name := prefix + "." + e.GetName()
+		idx.enums[name] = e
+		idx.files[name] = f
+	}
+}
+
+// isMap reports whether field is a map field.
+func (idx *typeIndex) isMap(field *descriptor.FieldDescriptorProto) bool {
+	if field.GetType() != descriptor.FieldDescriptorProto_TYPE_MESSAGE {
+		return false
+	}
+	return idx.messages[field.GetTypeName()].GetOptions().GetMapEntry()
+}
+
+// goImportPath returns the import path of the Go package generated from f,
+// or "" if go_package does not name one.
+func goImportPath(f *descriptor.FileDescriptorProto) string {
+	gopkg := f.GetOptions().GetGoPackage()
+	if sc := strings.IndexByte(gopkg, ';'); sc >= 0 {
+		gopkg = gopkg[:sc]
+	}
+	if !strings.Contains(gopkg, "/") {
+		return ""
+	}
+	return gopkg
+}
+
+// importSet records the Go packages referenced by the code generated for
+// one file, keyed by import path.
+type importSet struct {
+	self  string
+	names map[string]string
+}
+
+func newImportSet(f *descriptor.FileDescriptorProto) *importSet {
+	return &importSet{self: goImportPath(f), names: make(map[string]string)}
+}
+
+// goTypeName returns the Go name of the message or enum typeName, qualified
+// with its package if it is declared outside the generated package.
+func (s *importSet) goTypeName(idx *typeIndex, typeName string) string {
+	name := localTypeName(typeName)
+	f := idx.files[typeName]
+	path := goImportPath(f)
+	if f == nil || path == "" || path == s.self {
+		return name
+	}
+	pkg := defaultGoPackageName(f)
+	s.names[path] = pkg
+	return pkg + "." + name
+}
+
+// localTypeName returns the Go name of a type declared in the file being
+// generated, e.g. ".pkg.Outer.Inner" becomes "Outer_Inner".
+func localTypeName(typeName string) string {
+	parts := strings.Split(strings.TrimPrefix(typeName, "."), ".")
+	var names []string
+	for _, p := range parts {
+		if len(p) > 0 && p[0] >= 'A' && p[0] <= 'Z' {
+			names = append(names, p)
+		}
+	}
+	return strings.Join(names, "_")
+}
+
+// sanitizePackageName replaces unallowed character in package name
+// with allowed character.
+func sanitizePackageName(pkgName string) string {
+	pkgName = strings.Replace(pkgName, ".", "_", -1)
+	pkgName = strings.Replace(pkgName, "-", "_", -1)
+	return pkgName
+}
+
+// defaultGoPackageName returns the default go package name to be used for go files generated from "f".
+// You might need to use an unique alias for the package when you import it.  Use ReserveGoPackageAlias to get a unique alias.
+func defaultGoPackageName(f *descriptor.FileDescriptorProto) string {
+	name := packageIdentityName(f)
+	return sanitizePackageName(name)
+}
+
+// packageIdentityName returns the identity of packages.
+// protoc-gen-grpc-gateway rejects CodeGenerationRequests which contains more than one packages
+// as protoc-gen-go does.
+func packageIdentityName(f *descriptor.FileDescriptorProto) string {
+	if f.Options != nil && f.Options.GoPackage != nil {
+		gopkg := f.Options.GetGoPackage()
+		idx := strings.LastIndex(gopkg, "/")
+		if idx < 0 {
+			gopkg = gopkg[idx+1:]
+		}
+
+		gopkg = gopkg[idx+1:]
+		// package name is overrided with the string after the
+		// ';' character
+		sc := strings.IndexByte(gopkg, ';')
+		if sc < 0 {
+			return sanitizePackageName(gopkg)
+
+		}
+		return sanitizePackageName(gopkg[sc+1:])
+	}
+
+	if f.Package == nil {
+		base := filepath.Base(f.GetName())
+		ext := filepath.Ext(base)
+		return strings.TrimSuffix(base, ext)
+	}
+	return f.GetPackage()
+}
+
+func emitFiles(out []*plugin.CodeGeneratorResponse_File) {
+	emitResp(&plugin.CodeGeneratorResponse{File: out})
+}
+
+func emitError(err error) {
+	emitResp(&plugin.CodeGeneratorResponse{Error: proto.String(err.Error())})
+}
+
+func emitResp(resp *plugin.CodeGeneratorResponse) {
+	buf, err := proto.Marshal(resp)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if _, err := os.Stdout.Write(buf); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,520 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+
+	"github.com/f4tq/protoc-go-plugins/options"
+	"github.com/f4tq/protoc-go-plugins/protodoc"
+)
+
+var (
+	hdrTmpl = template.Must(template.New("header").Parse(`
+// Code generated by protoc-gen-go-schemaregistry. DO NOT EDIT.
+// source: {{.Source}}
+
+package {{.GoPkg}}
+
+import (
+    "context"
+
+    "github.com/f4tq/protoc-go-plugins/runtime/schemaregistry"
+)
+
+// {{.SchemaVar}} is {{.Source}} as registered with the schema registry.
+var {{.SchemaVar}} = {{.Schema}}
+`))
+
+	registryTmpl = template.Must(template.New("registry").Parse(`
+{{- if .Topic}}
+// {{.Name}}Topic is the topic {{.Name}} messages are published to.{{.Doc}}
+const {{.Name}}Topic = {{printf "%q" .Topic}}
+{{end}}
+// {{.Name}}Subject returns the schema registry subject of {{.Name}} values
+// written to topic.{{.Doc}}
+func {{.Name}}Subject(topic string) (string, error) {
+    return schemaregistry.SubjectName({{printf "%q" .Strategy}}, topic, {{printf "%q" .FullName}}, false)
+}
+
+// Register{{.Name}}Schema registers the schema of {{.Name}} values written
+// to topic and returns its schema ID.{{.Doc}}
+func Register{{.Name}}Schema(ctx context.Context, c *schemaregistry.Client, topic string) (int, error) {
+    subject, err := {{.Name}}Subject(topic)
+    if err != nil {
+        return 0, err
+    }
+    return c.Register(ctx, subject, {{.SchemaVar}})
+}
+
+// New{{.Name}}Serializer returns a Serializer producing {{.Name}} values
+// for topic.{{.Doc}}
+func New{{.Name}}Serializer(c *schemaregistry.Client, topic string) (*schemaregistry.Serializer, error) {
+    subject, err := {{.Name}}Subject(topic)
+    if err != nil {
+        return nil, err
+    }
+    return &schemaregistry.Serializer{
+        Client:  c,
+        Subject: subject,
+        Schema:  {{.SchemaVar}},
+        Indexes: []int{ {{- .Index -}} },
+    }, nil
+}
+
+// Deserialize{{.Name}} decodes a {{.Name}} value and returns the ID of the
+// schema it was written with.{{.Doc}}
+func Deserialize{{.Name}}(b []byte) (*{{.Name}}, int, error) {
+    msg := new({{.Name}})
+    id, err := schemaregistry.Deserialize(b, msg)
+    if err != nil {
+        return nil, 0, err
+    }
+    return msg, id, nil
+}
+`))
+)
+
+func main() {
+	input, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	req := new(plugin.CodeGeneratorRequest)
+	if err = proto.Unmarshal(input, req); err != nil {
+		log.Fatal(err)
+	}
+
+	out, err := generate(req)
+
+	if err != nil {
+		emitError(err)
+		return
+	}
+
+	emitFiles(out)
+}
+
+func generate(req *plugin.CodeGeneratorRequest) ([]*plugin.CodeGeneratorResponse_File, error) {
+	var files []*plugin.CodeGeneratorResponse_File
+	idx := newTypeIndex(req.GetProtoFile())
+	docs := protodoc.New(req.GetProtoFile())
+	genFileNames := make(map[string]bool)
+	for _, n := range req.FileToGenerate {
+		genFileNames[n] = true
+	}
+	for _, desc := range req.GetProtoFile() {
+		name := desc.GetName()
+		if _, ok := genFileNames[name]; !ok {
+			// Only emit output for files present in req.FileToGenerate.
+			continue
+		}
+		code, err := genCode(desc, idx, docs)
+		if err != nil {
+			return nil, err
+		}
+		if code == "" {
+			continue
+		}
+		formatted, err := format.Source([]byte(code))
+		if err != nil {
+			log.Printf("%v: %s", err, code)
+			return nil, err
+		}
+
+		ext := filepath.Ext(name)
+		base := strings.TrimSuffix(name, ext)
+		output := fmt.Sprintf("%s.pb.schemaregistry.go", base)
+		files = append(files, &plugin.CodeGeneratorResponse_File{
+			Name:    proto.String(output),
+			Content: proto.String(string(formatted)),
+		})
+	}
+
+	return files, nil
+}
+
+// genCode returns the registry helpers for the messages of desc annotated
+// with a topic or subject name strategy, or "" if there are none.
+func genCode(desc *descriptor.FileDescriptorProto, idx *typeIndex, docs *protodoc.Index) (string, error) {
+	w := bytes.NewBuffer(nil)
+	hdr := &header{
+		Source:    desc.GetName(),
+		GoPkg:     defaultGoPackageName(desc),
+		SchemaVar: "registrySchema_" + strings.Map(identRune, desc.GetName()),
+		Schema:    schemaLiteral(desc, idx),
+	}
+
+	var msgs []*registryMsg
+	for i, msg := range desc.GetMessageType() {
+		topic := options.Topic(msg)
+		strategy := options.SubjectNameStrategy(msg)
+		if topic == "" && strategy == "" {
+			continue
+		}
+		fullName := msg.GetName()
+		if desc.GetPackage() != "" {
+			fullName = desc.GetPackage() + "." + fullName
+		}
+		msgs = append(msgs, &registryMsg{
+			Name:      msg.GetName(),
+			FullName:  fullName,
+			Topic:     topic,
+			Strategy:  strategy,
+			Index:     i,
+			SchemaVar: hdr.SchemaVar,
+			Doc:       docs.Godoc(fullName),
+		})
+	}
+	if len(msgs) == 0 {
+		return "", nil
+	}
+
+	if err := hdrTmpl.Execute(w, hdr); err != nil {
+		log.Fatal(err)
+	}
+
+	for _, m := range msgs {
+		if err := registryTmpl.Execute(w, m); err != nil {
+			return "", err
+		}
+	}
+
+	return w.String(), nil
+}
+
+type header struct {
+	Source    string
+	GoPkg     string
+	SchemaVar string
+	Schema    string
+}
+
+type registryMsg struct {
+	Name      string
+	FullName  string
+	Topic     string
+	Strategy  string
+	Index     int
+	SchemaVar string
+	// Doc is the comment of the message, see protodoc.Index.Godoc.
+	Doc string
+}
+
+// schemaLiteral returns a schemaregistry.Schema literal for f. Imports other
+// than the well-known types, which the registry provides, become references
+// registered under their file name.
+func schemaLiteral(f *descriptor.FileDescriptorProto, idx *typeIndex) string {
+	w := bytes.NewBuffer(nil)
+	fmt.Fprintf(w, "schemaregistry.Schema{\nSchema: %s,\n", strconv.Quote(protoSource(f, idx)))
+	var refs []string
+	for _, dep := range protoImports(f, idx) {
+		if strings.HasPrefix(dep, "google/protobuf/") {
+			continue
+		}
+		depFile := fileByName(idx, dep)
+		if depFile == nil {
+			continue
+		}
+		refs = append(refs, fmt.Sprintf("{\nName: %q,\nSubject: %q,\nSchema: &%s,\n},\n", dep, dep, schemaLiteral(depFile, idx)))
+	}
+	if len(refs) > 0 {
+		fmt.Fprintf(w, "References: []schemaregistry.Reference{\n%s},\n", strings.Join(refs, ""))
+	}
+	w.WriteString("}")
+	return w.String()
+}
+
+// fileByName returns the file called name among those declaring types in idx.
+func fileByName(idx *typeIndex, name string) *descriptor.FileDescriptorProto {
+	for _, f := range idx.files {
+		if f.GetName() == name {
+			return f
+		}
+	}
+	return nil
+}
+
+// identRune maps r to itself if it is valid in a Go identifier, otherwise '_'.
+func identRune(r rune) rune {
+	switch {
+	case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		return r
+	}
+	return '_'
+}
+
+// typeIndex maps fully-qualified proto type names (".pkg.Msg") to their
+// descriptors and declaring files for every file in the request.
+type typeIndex struct {
+	messages map[string]*descriptor.DescriptorProto
+	enums    map[string]*descriptor.EnumDescriptorProto
+	files    map[string]*descriptor.FileDescriptorProto
+}
+
+func newTypeIndex(files []*descriptor.FileDescriptorProto) *typeIndex {
+	idx := &typeIndex{
+		messages: make(map[string]*descriptor.DescriptorProto),
+		enums:    make(map[string]*descriptor.EnumDescriptorProto),
+		files:    make(map[string]*descriptor.FileDescriptorProto),
+	}
+	for _, f := range files {
+		prefix := ""
+		if f.GetPackage() != "" {
+			prefix = "." + f.GetPackage()
+		}
+		idx.addMessages(f, prefix, f.GetMessageType())
+		idx.addEnums(f, prefix, f.GetEnumType())
+	}
+	return idx
+}
+
+func (idx *typeIndex) addMessages(f *descriptor.FileDescriptorProto, prefix string, msgs []*descriptor.DescriptorProto) {
+	for _, m := range msgs {
+		name := prefix + "." + m.GetName()
+		idx.messages[name] = m
+		idx.files[name] = f
+		idx.addMessages(f, name, m.GetNestedType())
+		idx.addEnums(f, name, m.GetEnumType())
+	}
+}
+
+func (idx *typeIndex) addEnums(f *descriptor.FileDescriptorProto, prefix string, enums []*descriptor.EnumDescriptorProto) {
+	for _, e := range enums {
+		name := prefix + "." + e.GetName()
+		idx.enums[name] = e
+		idx.files[name] = f
+	}
+}
+
+// isMap reports whether field is a map field.
+func (idx *typeIndex) isMap(field *descriptor.FieldDescriptorProto) bool {
+	if field.GetType() != descriptor.FieldDescriptorProto_TYPE_MESSAGE {
+		return false
+	}
+	return idx.messages[field.GetTypeName()].GetOptions().GetMapEntry()
+}
+
+var protoScalarNames = map[descriptor.FieldDescriptorProto_Type]string{
+	descriptor.FieldDescriptorProto_TYPE_DOUBLE:   "double",
+	descriptor.FieldDescriptorProto_TYPE_FLOAT:    "float",
+	descriptor.FieldDescriptorProto_TYPE_INT64:    "int64",
+	descriptor.FieldDescriptorProto_TYPE_UINT64:   "uint64",
+	descriptor.FieldDescriptorProto_TYPE_INT32:    "int32",
+	descriptor.FieldDescriptorProto_TYPE_FIXED64:  "fixed64",
+	descriptor.FieldDescriptorProto_TYPE_FIXED32:  "fixed32",
+	descriptor.FieldDescriptorProto_TYPE_BOOL:     "bool",
+	descriptor.FieldDescriptorProto_TYPE_STRING:   "string",
+	descriptor.FieldDescriptorProto_TYPE_BYTES:    "bytes",
+	descriptor.FieldDescriptorProto_TYPE_UINT32:   "uint32",
+	descriptor.FieldDescriptorProto_TYPE_SFIXED32: "sfixed32",
+	descriptor.FieldDescriptorProto_TYPE_SFIXED64: "sfixed64",
+	descriptor.FieldDescriptorProto_TYPE_SINT32:   "sint32",
+	descriptor.FieldDescriptorProto_TYPE_SINT64:   "sint64",
+}
+
+// protoSource renders f back into .proto source. Options, comments and
+// services are dropped, and only imports providing referenced types are
+// kept; the result describes the same messages and wire format.
+func protoSource(f *descriptor.FileDescriptorProto, idx *typeIndex) string {
+	w := bytes.NewBuffer(nil)
+	syntax := f.GetSyntax()
+	if syntax == "" {
+		syntax = "proto2"
+	}
+	fmt.Fprintf(w, "syntax = %q;\n", syntax)
+	if f.GetPackage() != "" {
+		fmt.Fprintf(w, "package %s;\n", f.GetPackage())
+	}
+	for _, dep := range protoImports(f, idx) {
+		fmt.Fprintf(w, "import %q;\n", dep)
+	}
+	for _, e := range f.GetEnumType() {
+		writeProtoEnum(w, e, "")
+	}
+	for _, m := range f.GetMessageType() {
+		writeProtoMessage(w, m, "", syntax, idx)
+	}
+	return w.String()
+}
+
+// protoImports returns the dependencies of f that declare types referenced
+// by its messages.
+func protoImports(f *descriptor.FileDescriptorProto, idx *typeIndex) []string {
+	used := make(map[string]bool)
+	var walk func(msgs []*descriptor.DescriptorProto)
+	walk = func(msgs []*descriptor.DescriptorProto) {
+		for _, m := range msgs {
+			for _, field := range m.GetField() {
+				if dep, ok := idx.files[field.GetTypeName()]; ok {
+					used[dep.GetName()] = true
+				}
+			}
+			walk(m.GetNestedType())
+		}
+	}
+	walk(f.GetMessageType())
+	var deps []string
+	for _, dep := range f.GetDependency() {
+		if used[dep] {
+			deps = append(deps, dep)
+		}
+	}
+	return deps
+}
+
+func writeProtoEnum(w *bytes.Buffer, e *descriptor.EnumDescriptorProto, indent string) {
+	fmt.Fprintf(w, "%senum %s {\n", indent, e.GetName())
+	if e.GetOptions().GetAllowAlias() {
+		fmt.Fprintf(w, "%s  option allow_alias = true;\n", indent)
+	}
+	for _, v := range e.GetValue() {
+		fmt.Fprintf(w, "%s  %s = %d;\n", indent, v.GetName(), v.GetNumber())
+	}
+	fmt.Fprintf(w, "%s}\n", indent)
+}
+
+func writeProtoMessage(w *bytes.Buffer, m *descriptor.DescriptorProto, indent, syntax string, idx *typeIndex) {
+	fmt.Fprintf(w, "%smessage %s {\n", indent, m.GetName())
+	inner := indent + "  "
+	for _, e := range m.GetEnumType() {
+		writeProtoEnum(w, e, inner)
+	}
+	for _, n := range m.GetNestedType() {
+		if n.GetOptions().GetMapEntry() {
+			continue
+		}
+		writeProtoMessage(w, n, inner, syntax, idx)
+	}
+	printed := make(map[int32]bool)
+	for _, field := range m.GetField() {
+		if field.OneofIndex == nil || field.GetProto3Optional() {
+			writeProtoField(w, field, inner, syntax, idx)
+			continue
+		}
+		oneof := field.GetOneofIndex()
+		if printed[oneof] {
+			continue
+		}
+		printed[oneof] = true
+		fmt.Fprintf(w, "%soneof %s {\n", inner, m.GetOneofDecl()[oneof].GetName())
+		for _, member := range m.GetField() {
+			if member.OneofIndex != nil && member.GetOneofIndex() == oneof {
+				writeProtoField(w, member, inner+"  ", "oneof", idx)
+			}
+		}
+		fmt.Fprintf(w, "%s}\n", inner)
+	}
+	fmt.Fprintf(w, "%s}\n", indent)
+}
+
+func writeProtoField(w *bytes.Buffer, field *descriptor.FieldDescriptorProto, indent, syntax string, idx *typeIndex) {
+	typ := protoScalarNames[field.GetType()]
+	if typ == "" {
+		typ = field.GetTypeName()
+	}
+	label := ""
+	switch {
+	case idx.isMap(field):
+		entry := idx.messages[field.GetTypeName()]
+		k, v := entry.GetField()[0], entry.GetField()[1]
+		vt := protoScalarNames[v.GetType()]
+		if vt == "" {
+			vt = v.GetTypeName()
+		}
+		typ = fmt.Sprintf("map<%s, %s>", protoScalarNames[k.GetType()], vt)
+	case field.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REPEATED:
+		label = "repeated "
+	case syntax == "proto2" && field.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REQUIRED:
+		label = "required "
+	case syntax == "proto2" || field.GetProto3Optional():
+		label = "optional "
+	}
+	def := ""
+	if field.DefaultValue != nil {
+		v := field.GetDefaultValue()
+		switch field.GetType() {
+		case descriptor.FieldDescriptorProto_TYPE_STRING:
+			v = strconv.Quote(v)
+		case descriptor.FieldDescriptorProto_TYPE_BYTES:
+			v = `"` + v + `"`
+		}
+		def = fmt.Sprintf(" [default = %s]", v)
+	}
+	fmt.Fprintf(w, "%s%s%s %s = %d%s;\n", indent, label, typ, field.GetName(), field.GetNumber(), def)
+}
+
+// sanitizePackageName replaces unallowed character in package name
+// with allowed character.
+func sanitizePackageName(pkgName string) string {
+	pkgName = strings.Replace(pkgName, ".", "_", -1)
+	pkgName = strings.Replace(pkgName, "-", "_", -1)
+	return pkgName
+}
+
+// defaultGoPackageName returns the default go package name to be used for go files generated from "f".
+// You might need to use an unique alias for the package when you import it.  Use ReserveGoPackageAlias to get a unique alias.
+func defaultGoPackageName(f *descriptor.FileDescriptorProto) string {
+	name := packageIdentityName(f)
+	return sanitizePackageName(name)
+}
+
+// packageIdentityName returns the identity of packages.
+// protoc-gen-grpc-gateway rejects CodeGenerationRequests which contains more than one packages
+// as protoc-gen-go does.
+func packageIdentityName(f *descriptor.FileDescriptorProto) string {
+	if f.Options != nil && f.Options.GoPackage != nil {
+		gopkg := f.Options.GetGoPackage()
+		idx := strings.LastIndex(gopkg, "/")
+		if idx < 0 {
+			gopkg = gopkg[idx+1:]
+		}
+
+		gopkg = gopkg[idx+1:]
+		// package name is overrided with the string after the
+		// ';' character
+		sc := strings.IndexByte(gopkg, ';')
+		if sc < 0 {
+			return sanitizePackageName(gopkg)
+
+		}
+		return sanitizePackageName(gopkg[sc+1:])
+	}
+
+	if f.Package == nil {
+		base := filepath.Base(f.GetName())
+		ext := filepath.Ext(base)
+		return strings.TrimSuffix(base, ext)
+	}
+	return f.GetPackage()
+}
+
+func emitFiles(out []*plugin.CodeGeneratorResponse_File) {
+	emitResp(&plugin.CodeGeneratorResponse{File: out})
+}
+
+func emitError(err error) {
+	emitResp(&plugin.CodeGeneratorResponse{Error: proto.String(err.Error())})
+}
+
+func emitResp(resp *plugin.CodeGeneratorResponse) {
+	buf, err := proto.Marshal(resp)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if _, err := os.Stdout.Write(buf); err != nil {
+		log.Fatal(err)
+	}
+}
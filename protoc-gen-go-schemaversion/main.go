@@ -0,0 +1,207 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+
+	"github.com/f4tq/protoc-go-plugins/options"
+	"github.com/f4tq/protoc-go-plugins/runtime/schemaver"
+)
+
+var versionTmpl = template.Must(template.New("version").Parse(`
+// Code generated by protoc-gen-go-schemaversion. DO NOT EDIT.
+// source: {{.Source}}
+
+package {{.GoPkg}}
+
+import (
+    "github.com/f4tq/protoc-go-plugins/runtime/schemaver"
+)
+
+// SchemaVersion is the (f4tq.plugins.schema_version) of {{.Source}}.
+const SchemaVersion = {{printf "%q" .Version.String}}
+
+// The parts of SchemaVersion.
+const (
+    SchemaVersionMajor = {{.Version.Major}}
+    SchemaVersionMinor = {{.Version.Minor}}
+    SchemaVersionPatch = {{.Version.Patch}}
+    SchemaVersionPre   = {{printf "%q" .Version.Pre}}
+)
+
+// CheckCompat returns nil if a peer at schema version remoteVersion, as it
+// announces it when connecting, can exchange the messages of this package
+// with us. See schemaver.Check for the rules.
+func CheckCompat(remoteVersion string) error {
+    return schemaver.Check(SchemaVersion, remoteVersion)
+}
+`))
+
+func main() {
+	input, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	req := new(plugin.CodeGeneratorRequest)
+	if err = proto.Unmarshal(input, req); err != nil {
+		log.Fatal(err)
+	}
+
+	out, err := generate(req)
+
+	if err != nil {
+		emitError(err)
+		return
+	}
+
+	emitFiles(out)
+}
+
+func generate(req *plugin.CodeGeneratorRequest) ([]*plugin.CodeGeneratorResponse_File, error) {
+	var files []*plugin.CodeGeneratorResponse_File
+	genFileNames := make(map[string]bool)
+	for _, n := range req.FileToGenerate {
+		genFileNames[n] = true
+	}
+	// versioned maps the directories of the Go packages to the file that
+	// sets their version.
+	versioned := make(map[string]string)
+	for _, desc := range req.GetProtoFile() {
+		name := desc.GetName()
+		if _, ok := genFileNames[name]; !ok {
+			// Only emit output for files present in req.FileToGenerate.
+			continue
+		}
+		code, err := genCode(desc)
+		if err != nil {
+			return nil, err
+		}
+		if code == "" {
+			// The file sets no (f4tq.plugins.schema_version).
+			continue
+		}
+		dir := filepath.Dir(name)
+		if other, ok := versioned[dir]; ok {
+			return nil, fmt.Errorf("%s: (f4tq.plugins.schema_version) is already set by %s, of the same package", name, other)
+		}
+		versioned[dir] = name
+		formatted, err := format.Source([]byte(code))
+		if err != nil {
+			log.Printf("%v: %s", err, code)
+			return nil, err
+		}
+
+		ext := filepath.Ext(name)
+		base := strings.TrimSuffix(name, ext)
+		output := fmt.Sprintf("%s.pb.schemaversion.go", base)
+		files = append(files, &plugin.CodeGeneratorResponse_File{
+			Name:    proto.String(output),
+			Content: proto.String(string(formatted)),
+		})
+	}
+
+	return files, nil
+}
+
+func genCode(desc *descriptor.FileDescriptorProto) (string, error) {
+	opt := options.SchemaVersion(desc)
+	if opt == "" {
+		return "", nil
+	}
+	v, err := schemaver.Parse(opt)
+	if err != nil {
+		return "", fmt.Errorf("%s: (f4tq.plugins.schema_version): %v", desc.GetName(), err)
+	}
+	w := bytes.NewBuffer(nil)
+	f := &versionFile{
+		Source:  desc.GetName(),
+		GoPkg:   defaultGoPackageName(desc),
+		Version: v,
+	}
+	if err := versionTmpl.Execute(w, f); err != nil {
+		log.Fatal(err)
+	}
+
+	return w.String(), nil
+}
+
+type versionFile struct {
+	Source  string
+	GoPkg   string
+	Version schemaver.Version
+}
+
+// sanitizePackageName replaces unallowed character in package name
+// with allowed character.
+func sanitizePackageName(pkgName string) string {
+	pkgName = strings.Replace(pkgName, ".", "_", -1)
+	pkgName = strings.Replace(pkgName, "-", "_", -1)
+	return pkgName
+}
+
+// defaultGoPackageName returns the default go package name to be used for go files generated from "f".
+// You might need to use an unique alias for the package when you import it.  Use ReserveGoPackageAlias to get a unique alias.
+func defaultGoPackageName(f *descriptor.FileDescriptorProto) string {
+	name := packageIdentityName(f)
+	return sanitizePackageName(name)
+}
+
+// packageIdentityName returns the identity of packages.
+// protoc-gen-grpc-gateway rejects CodeGenerationRequests which contains more than one packages
+// as protoc-gen-go does.
+func packageIdentityName(f *descriptor.FileDescriptorProto) string {
+	if f.Options != nil && f.Options.GoPackage != nil {
+		gopkg := f.Options.GetGoPackage()
+		idx := strings.LastIndex(gopkg, "/")
+		if idx < 0 {
+			gopkg = gopkg[idx+1:]
+		}
+
+		gopkg = gopkg[idx+1:]
+		// package name is overrided with the string after the
+		// ';' character
+		sc := strings.IndexByte(gopkg, ';')
+		if sc < 0 {
+			return sanitizePackageName(gopkg)
+
+		}
+		return sanitizePackageName(gopkg[sc+1:])
+	}
+
+	if f.Package == nil {
+		base := filepath.Base(f.GetName())
+		ext := filepath.Ext(base)
+		return strings.TrimSuffix(base, ext)
+	}
+	return f.GetPackage()
+}
+
+func emitFiles(out []*plugin.CodeGeneratorResponse_File) {
+	emitResp(&plugin.CodeGeneratorResponse{File: out})
+}
+
+func emitError(err error) {
+	emitResp(&plugin.CodeGeneratorResponse{Error: proto.String(err.Error())})
+}
+
+func emitResp(resp *plugin.CodeGeneratorResponse) {
+	buf, err := proto.Marshal(resp)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if _, err := os.Stdout.Write(buf); err != nil {
+		log.Fatal(err)
+	}
+}
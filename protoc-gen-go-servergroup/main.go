@@ -0,0 +1,288 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"unicode"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+
+	"github.com/f4tq/protoc-go-plugins/protodoc"
+)
+
+var (
+	hdrTmpl = template.Must(template.New("header").Parse(`
+// Code generated by protoc-gen-go-servergroup. DO NOT EDIT.
+// source: {{.Source}}
+
+package {{.GoPkg}}
+
+import (
+    "github.com/f4tq/protoc-go-plugins/runtime/servergroup"
+    "google.golang.org/grpc"
+)
+`))
+
+	serviceTmpl = template.Must(template.New("service").Parse(`
+// Add{{.Name}} adds impl to g as the {{.FullName}} service, which can be
+// turned off with -enable-{{.Flag}}=false once g.RegisterFlags is called.
+// If impl has Start or Stop methods, they run as its hooks. Set
+// RegisterHTTP on the result to also serve HTTP handlers.{{.Doc}}
+func Add{{.Name}}(g *servergroup.Group, impl {{.Name}}Server) *servergroup.Service {
+    return g.Add(&servergroup.Service{
+        Name: {{printf "%q" .FullName}},
+        Flag: {{printf "%q" .Flag}},
+        Impl: impl,
+        RegisterGRPC: func(s *grpc.Server) {
+            Register{{.Name}}Server(s, impl)
+        },
+    })
+}
+`))
+
+	// groupTmpl is written once per directory, to servergroup.pb.go, and
+	// covers the services of every file generated in it.
+	groupTmpl = template.Must(template.New("group").Parse(`// Code generated by protoc-gen-go-servergroup. DO NOT EDIT.
+
+package {{.GoPkg}}
+
+import "github.com/f4tq/protoc-go-plugins/runtime/servergroup"
+
+// Services holds the implementations of the services of this package. Nil
+// implementations are left out.
+type Services struct {
+{{- range .Services}}
+    {{.Name}} {{.Name}}Server
+{{- end}}
+}
+
+// AddServices adds the implementations in s to g, in the order the
+// services are declared.
+func AddServices(g *servergroup.Group, s *Services) {
+{{- range .Services}}
+    if s.{{.Name}} != nil {
+        Add{{.Name}}(g, s.{{.Name}})
+    }
+{{- end}}
+}
+`))
+)
+
+func main() {
+	input, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	req := new(plugin.CodeGeneratorRequest)
+	if err = proto.Unmarshal(input, req); err != nil {
+		log.Fatal(err)
+	}
+
+	out, err := generate(req)
+
+	if err != nil {
+		emitError(err)
+		return
+	}
+
+	emitFiles(out)
+}
+
+func generate(req *plugin.CodeGeneratorRequest) ([]*plugin.CodeGeneratorResponse_File, error) {
+	var files []*plugin.CodeGeneratorResponse_File
+	docs := protodoc.New(req.GetProtoFile())
+	genFileNames := make(map[string]bool)
+	for _, n := range req.FileToGenerate {
+		genFileNames[n] = true
+	}
+	// groups maps the directories written to to their services.
+	groups := make(map[string]*group)
+	var dirs []string
+	for _, desc := range req.GetProtoFile() {
+		name := desc.GetName()
+		if _, ok := genFileNames[name]; !ok {
+			// Only emit output for files present in req.FileToGenerate.
+			continue
+		}
+		if len(desc.GetService()) == 0 {
+			continue
+		}
+		code, services, err := genCode(desc, docs)
+		if err != nil {
+			return nil, err
+		}
+		formatted, err := format.Source([]byte(code))
+		if err != nil {
+			log.Printf("%v: %s", err, code)
+			return nil, err
+		}
+
+		ext := filepath.Ext(name)
+		base := strings.TrimSuffix(name, ext)
+		output := fmt.Sprintf("%s.pb.servergroup.go", base)
+		files = append(files, &plugin.CodeGeneratorResponse_File{
+			Name:    proto.String(output),
+			Content: proto.String(string(formatted)),
+		})
+		dir := path.Dir(name)
+		g, ok := groups[dir]
+		if !ok {
+			g = &group{GoPkg: defaultGoPackageName(desc)}
+			groups[dir] = g
+			dirs = append(dirs, dir)
+		}
+		g.Services = append(g.Services, services...)
+	}
+	for _, dir := range dirs {
+		var buf bytes.Buffer
+		if err := groupTmpl.Execute(&buf, groups[dir]); err != nil {
+			return nil, err
+		}
+		formatted, err := format.Source(buf.Bytes())
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, &plugin.CodeGeneratorResponse_File{
+			Name:    proto.String(path.Join(dir, "servergroup.pb.go")),
+			Content: proto.String(string(formatted)),
+		})
+	}
+
+	return files, nil
+}
+
+func genCode(desc *descriptor.FileDescriptorProto, docs *protodoc.Index) (string, []*groupService, error) {
+	w := bytes.NewBuffer(nil)
+	hdr := &header{
+		Source: desc.GetName(),
+		GoPkg:  defaultGoPackageName(desc),
+	}
+	if err := hdrTmpl.Execute(w, hdr); err != nil {
+		log.Fatal(err)
+	}
+	var services []*groupService
+	for _, svc := range desc.GetService() {
+		fullName := svc.GetName()
+		if desc.GetPackage() != "" {
+			fullName = desc.GetPackage() + "." + svc.GetName()
+		}
+		s := &groupService{
+			Name:     svc.GetName(),
+			FullName: fullName,
+			Flag:     kebabCase(svc.GetName()),
+			Doc:      docs.Godoc(fullName),
+		}
+		if err := serviceTmpl.Execute(w, s); err != nil {
+			return "", nil, err
+		}
+		services = append(services, s)
+	}
+
+	return w.String(), services, nil
+}
+
+// kebabCase converts a CamelCase service name to kebab-case, keeping
+// acronyms together: "HTTPProxyService" becomes "http-proxy-service".
+func kebabCase(s string) string {
+	var b strings.Builder
+	r := []rune(s)
+	for i, c := range r {
+		if unicode.IsUpper(c) && i > 0 && (unicode.IsLower(r[i-1]) || i+1 < len(r) && unicode.IsLower(r[i+1])) {
+			b.WriteByte('-')
+		}
+		b.WriteRune(unicode.ToLower(c))
+	}
+	return b.String()
+}
+
+type header struct {
+	Source string
+	GoPkg  string
+}
+
+type group struct {
+	GoPkg    string
+	Services []*groupService
+}
+
+type groupService struct {
+	Name     string
+	FullName string
+	Flag     string
+	// Doc is the comment of the service, see protodoc.Index.Godoc.
+	Doc string
+}
+
+// sanitizePackageName replaces unallowed character in package name
+// with allowed character.
+func sanitizePackageName(pkgName string) string {
+	pkgName = strings.Replace(pkgName, ".", "_", -1)
+	pkgName = strings.Replace(pkgName, "-", "_", -1)
+	return pkgName
+}
+
+// defaultGoPackageName returns the default go package name to be used for go files generated from "f".
+// You might need to use an unique alias for the package when you import it.  Use ReserveGoPackageAlias to get a unique alias.
+func defaultGoPackageName(f *descriptor.FileDescriptorProto) string {
+	name := packageIdentityName(f)
+	return sanitizePackageName(name)
+}
+
+// packageIdentityName returns the identity of packages.
+// protoc-gen-grpc-gateway rejects CodeGenerationRequests which contains more than one packages
+// as protoc-gen-go does.
+func packageIdentityName(f *descriptor.FileDescriptorProto) string {
+	if f.Options != nil && f.Options.GoPackage != nil {
+		gopkg := f.Options.GetGoPackage()
+		idx := strings.LastIndex(gopkg, "/")
+		if idx < 0 {
+			gopkg = gopkg[idx+1:]
+		}
+
+		gopkg = gopkg[idx+1:]
+		// package name is overrided with the string after the
+		// ';' character
+		sc := strings.IndexByte(gopkg, ';')
+		if sc < 0 {
+			return sanitizePackageName(gopkg)
+
+		}
+		return sanitizePackageName(gopkg[sc+1:])
+	}
+
+	if f.Package == nil {
+		base := filepath.Base(f.GetName())
+		ext := filepath.Ext(base)
+		return strings.TrimSuffix(base, ext)
+	}
+	return f.GetPackage()
+}
+
+func emitFiles(out []*plugin.CodeGeneratorResponse_File) {
+	emitResp(&plugin.CodeGeneratorResponse{File: out})
+}
+
+func emitError(err error) {
+	emitResp(&plugin.CodeGeneratorResponse{Error: proto.String(err.Error())})
+}
+
+func emitResp(resp *plugin.CodeGeneratorResponse) {
+	buf, err := proto.Marshal(resp)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if _, err := os.Stdout.Write(buf); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+
+	"github.com/f4tq/protoc-go-plugins/goname"
+	"github.com/f4tq/protoc-go-plugins/options"
+)
+
+var (
+	hdrTmpl = template.Must(template.New("header").Parse(`
+// Code generated by protoc-gen-go-sign. DO NOT EDIT.
+// source: {{.Source}}
+
+package {{.GoPkg}}
+
+import (
+    "github.com/f4tq/protoc-go-plugins/runtime/canonical"
+    "github.com/f4tq/protoc-go-plugins/runtime/signing"
+)
+`))
+
+	signTmpl = template.Must(template.New("sign").Parse(`
+// Sign sets the {{.Field}} field of m to the signature by key of the
+// canonical encoding of m without that field.
+func (m *{{.Name}}) Sign(key signing.Signer) error {
+    sig, err := key.Sign(m.signedBytes())
+    if err != nil {
+        return err
+    }
+    m.{{.GoName}} = sig
+    return nil
+}
+
+// Verify returns nil if the {{.Field}} field of m holds the signature,
+// checked by key, of the canonical encoding of m without that field, and
+// signing.ErrBadSignature otherwise.
+func (m *{{.Name}}) Verify(key signing.Verifier) error {
+    return key.Verify(m.signedBytes(), m.Get{{.GoName}}())
+}
+
+// signedBytes returns the canonical encoding of m without its {{.Field}}
+// field.
+func (m *{{.Name}}) signedBytes() []byte {
+    var e canonical.Encoder
+    e.Omit({{.Number}})
+    m.EncodeCanonical(&e)
+    return e.Result()
+}
+`))
+)
+
+func main() {
+	input, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	req := new(plugin.CodeGeneratorRequest)
+	if err = proto.Unmarshal(input, req); err != nil {
+		log.Fatal(err)
+	}
+
+	out, err := generate(req)
+
+	if err != nil {
+		emitError(err)
+		return
+	}
+
+	emitFiles(out)
+}
+
+func generate(req *plugin.CodeGeneratorRequest) ([]*plugin.CodeGeneratorResponse_File, error) {
+	var files []*plugin.CodeGeneratorResponse_File
+	genFileNames := make(map[string]bool)
+	for _, n := range req.FileToGenerate {
+		genFileNames[n] = true
+	}
+	for _, desc := range req.GetProtoFile() {
+		name := desc.GetName()
+		if _, ok := genFileNames[name]; !ok {
+			// Only emit output for files present in req.FileToGenerate.
+			continue
+		}
+		code, err := genCode(desc)
+		if err != nil {
+			return nil, err
+		}
+		if code == "" {
+			// The file declares no signed messages.
+			continue
+		}
+		formatted, err := format.Source([]byte(code))
+		if err != nil {
+			log.Printf("%v: %s", err, code)
+			return nil, err
+		}
+
+		ext := filepath.Ext(name)
+		base := strings.TrimSuffix(name, ext)
+		output := fmt.Sprintf("%s.pb.sign.go", base)
+		files = append(files, &plugin.CodeGeneratorResponse_File{
+			Name:    proto.String(output),
+			Content: proto.String(string(formatted)),
+		})
+	}
+
+	return files, nil
+}
+
+func genCode(desc *descriptor.FileDescriptorProto) (string, error) {
+	body := bytes.NewBuffer(nil)
+	if err := messages(body, "", desc.GetMessageType()); err != nil {
+		return "", err
+	}
+	if body.Len() == 0 {
+		return "", nil
+	}
+	hdr := &header{
+		Source: desc.GetName(),
+		GoPkg:  defaultGoPackageName(desc),
+	}
+	w := bytes.NewBuffer(nil)
+	if err := hdrTmpl.Execute(w, hdr); err != nil {
+		log.Fatal(err)
+	}
+	body.WriteTo(w)
+
+	return w.String(), nil
+}
+
+// messages writes the signing methods of the messages of msgs, and of the
+// messages nested in them, that have a signature field. prefix is the Go
+// name of the enclosing message plus "_".
+func messages(w *bytes.Buffer, prefix string, msgs []*descriptor.DescriptorProto) error {
+	for _, msg := range msgs {
+		if msg.GetOptions().GetMapEntry() {
+			continue
+		}
+		name := prefix + msg.GetName()
+		var m *signMessage
+		for _, field := range msg.GetField() {
+			if !options.Signature(field) {
+				continue
+			}
+			switch {
+			case m != nil:
+				return fmt.Errorf("%s: fields %s and %s both set (f4tq.plugins.signature)", name, m.Field, field.GetName())
+			case field.GetType() != descriptor.FieldDescriptorProto_TYPE_BYTES,
+				field.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REPEATED,
+				field.OneofIndex != nil && !field.GetProto3Optional():
+				return fmt.Errorf("%s.%s: (f4tq.plugins.signature) applies to singular bytes fields outside oneofs", name, field.GetName())
+			}
+			m = &signMessage{
+				Name:   name,
+				Field:  field.GetName(),
+				GoName: goname.Fields(msg)[field.GetName()],
+				Number: field.GetNumber(),
+			}
+		}
+		if m != nil {
+			if err := signTmpl.Execute(w, m); err != nil {
+				return err
+			}
+		}
+		if err := messages(w, name+"_", msg.GetNestedType()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type header struct {
+	Source string
+	GoPkg  string
+}
+
+type signMessage struct {
+	Name   string
+	Field  string
+	GoName string
+	Number int32
+}
+
+// sanitizePackageName replaces unallowed character in package name
+// with allowed character.
+func sanitizePackageName(pkgName string) string {
+	pkgName = strings.Replace(pkgName, ".", "_", -1)
+	pkgName = strings.Replace(pkgName, "-", "_", -1)
+	return pkgName
+}
+
+// defaultGoPackageName returns the default go package name to be used for go files generated from "f".
+// You might need to use an unique alias for the package when you import it.  Use ReserveGoPackageAlias to get a unique alias.
+func defaultGoPackageName(f *descriptor.FileDescriptorProto) string {
+	name := packageIdentityName(f)
+	return sanitizePackageName(name)
+}
+
+// packageIdentityName returns the identity of packages.
+// protoc-gen-grpc-gateway rejects CodeGenerationRequests which contains more than one packages
+// as protoc-gen-go does.
+func packageIdentityName(f *descriptor.FileDescriptorProto) string {
+	if f.Options != nil && f.Options.GoPackage != nil {
+		gopkg := f.Options.GetGoPackage()
+		idx := strings.LastIndex(gopkg, "/")
+		if idx < 0 {
+			gopkg = gopkg[idx+1:]
+		}
+
+		gopkg = gopkg[idx+1:]
+		// package name is overrided with the string after the
+		// ';' character
+		sc := strings.IndexByte(gopkg, ';')
+		if sc < 0 {
+			return sanitizePackageName(gopkg)
+
+		}
+		return sanitizePackageName(gopkg[sc+1:])
+	}
+
+	if f.Package == nil {
+		base := filepath.Base(f.GetName())
+		ext := filepath.Ext(base)
+		return strings.TrimSuffix(base, ext)
+	}
+	return f.GetPackage()
+}
+
+func emitFiles(out []*plugin.CodeGeneratorResponse_File) {
+	emitResp(&plugin.CodeGeneratorResponse{File: out})
+}
+
+func emitError(err error) {
+	emitResp(&plugin.CodeGeneratorResponse{Error: proto.String(err.Error())})
+}
+
+func emitResp(resp *plugin.CodeGeneratorResponse) {
+	buf, err := proto.Marshal(resp)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if _, err := os.Stdout.Write(buf); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,467 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+
+	"github.com/f4tq/protoc-go-plugins/goname"
+)
+
+var (
+	hdrTmpl = template.Must(template.New("header").Parse(`
+// Code generated by protoc-gen-go-size. DO NOT EDIT.
+// source: {{.Source}}
+
+package {{.GoPkg}}
+
+import (
+    "github.com/f4tq/protoc-go-plugins/runtime/sizeest"
+)
+`))
+
+	sizeTmpl = template.Must(template.New("size").Parse(`
+// EstimateSize returns the size of m in the binary wire format, the one
+// proto.Marshal produces, and approximately in JSON, without encoding it.
+func (m *{{.Name}}) EstimateSize() sizeest.Size {
+    var s sizeest.Size
+    if m == nil {
+        return s
+    }
+    s.JSON = 2
+{{- range .Fields}}
+{{.}}
+{{- end}}
+    s.Wire += len(m.XXX_unrecognized)
+    return s
+}
+`))
+)
+
+func main() {
+	input, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	req := new(plugin.CodeGeneratorRequest)
+	if err = proto.Unmarshal(input, req); err != nil {
+		log.Fatal(err)
+	}
+
+	out, err := generate(req)
+
+	if err != nil {
+		emitError(err)
+		return
+	}
+
+	emitFiles(out)
+}
+
+func generate(req *plugin.CodeGeneratorRequest) ([]*plugin.CodeGeneratorResponse_File, error) {
+	var files []*plugin.CodeGeneratorResponse_File
+	idx := newTypeIndex(req.GetProtoFile())
+	genFileNames := make(map[string]bool)
+	for _, n := range req.FileToGenerate {
+		genFileNames[n] = true
+	}
+	for _, desc := range req.GetProtoFile() {
+		name := desc.GetName()
+		if _, ok := genFileNames[name]; !ok {
+			// Only emit output for files present in req.FileToGenerate.
+			continue
+		}
+		code, err := genCode(desc, idx, genFileNames)
+		if err != nil {
+			return nil, err
+		}
+		if code == "" {
+			// The file declares no messages.
+			continue
+		}
+		formatted, err := format.Source([]byte(code))
+		if err != nil {
+			log.Printf("%v: %s", err, code)
+			return nil, err
+		}
+
+		ext := filepath.Ext(name)
+		base := strings.TrimSuffix(name, ext)
+		output := fmt.Sprintf("%s.pb.size.go", base)
+		files = append(files, &plugin.CodeGeneratorResponse_File{
+			Name:    proto.String(output),
+			Content: proto.String(string(formatted)),
+		})
+	}
+
+	return files, nil
+}
+
+func genCode(desc *descriptor.FileDescriptorProto, idx *typeIndex, genFileNames map[string]bool) (string, error) {
+	w := bytes.NewBuffer(nil)
+	g := &sizeGen{
+		idx:    idx,
+		gen:    genFileNames,
+		proto3: desc.GetSyntax() == "proto3",
+	}
+	body := bytes.NewBuffer(nil)
+	if err := g.messages(body, "", desc.GetMessageType()); err != nil {
+		return "", err
+	}
+	if body.Len() == 0 {
+		return "", nil
+	}
+
+	hdr := &header{
+		Source: desc.GetName(),
+		GoPkg:  defaultGoPackageName(desc),
+	}
+	if err := hdrTmpl.Execute(w, hdr); err != nil {
+		log.Fatal(err)
+	}
+	body.WriteTo(w)
+
+	return w.String(), nil
+}
+
+type sizeGen struct {
+	idx    *typeIndex
+	gen    map[string]bool
+	proto3 bool
+}
+
+// messages writes the EstimateSize methods of msgs and of the messages
+// nested in them. prefix is the Go name of the enclosing message plus "_".
+func (g *sizeGen) messages(w *bytes.Buffer, prefix string, msgs []*descriptor.DescriptorProto) error {
+	for _, msg := range msgs {
+		if msg.GetOptions().GetMapEntry() {
+			continue
+		}
+		name := prefix + msg.GetName()
+		m := &sizeMessage{Name: name}
+		for _, field := range msg.GetField() {
+			m.Fields = append(m.Fields, g.field(name, msg, field))
+		}
+		if err := sizeTmpl.Execute(w, m); err != nil {
+			return err
+		}
+		if err := g.messages(w, name+"_", msg.GetNestedType()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// field returns the statements adding the size of field of msg, whose Go
+// name is msgName, if it is encoded.
+func (g *sizeGen) field(msgName string, msg *descriptor.DescriptorProto, field *descriptor.FieldDescriptorProto) string {
+	goNames := goname.Fields(msg)
+	goName := goNames[field.GetName()]
+	v := "m." + goName
+	// key is the size of the JSON key, with its quotes, colon and comma.
+	key := len(field.GetJsonName()) + 4
+	switch {
+	case g.idx.isMap(field):
+		entry := g.idx.messages[field.GetTypeName()].GetField()
+		kStmts, kWire, kJSON := g.value(entry[0], "k")
+		vStmts, vWire, vJSON := g.value(entry[1], "e")
+		if entry[0].GetType() != descriptor.FieldDescriptorProto_TYPE_STRING {
+			// JSON quotes the keys that are not strings.
+			kJSON += " + 2"
+		}
+		stmts := append(kStmts, vStmts...)
+		stmts = append(stmts,
+			fmt.Sprintf("entry := %d + %s + %d + %s", tagSize(1, entry[0]), kWire, tagSize(2, entry[1]), vWire),
+			fmt.Sprintf("s.Wire += %d + sizeest.Varint(uint64(entry)) + entry", tagSize(field.GetNumber(), field)),
+			fmt.Sprintf("s.JSON += %s + 1 + %s + 1", kJSON, vJSON))
+		return fmt.Sprintf("if len(%s) > 0 {\ns.JSON += %d + 1\nfor k, e := range %s {\n%s\n}\n}",
+			v, key, v, strings.Join(stmts, "\n"))
+	case field.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REPEATED:
+		stmts, wire, json := g.value(field, "e")
+		if g.packed(field) {
+			return fmt.Sprintf(`if len(%s) > 0 {
+s.JSON += %d + 1
+packed := 0
+for _, e := range %s {
+packed += %s
+s.JSON += %s + 1
+}
+s.Wire += %d + sizeest.Varint(uint64(packed)) + packed
+}`, v, key, v, wire, json, lenTagSize(field))
+		}
+		stmts = append(stmts,
+			fmt.Sprintf("s.Wire += %d + %s", tagSize(field.GetNumber(), field), wire),
+			fmt.Sprintf("s.JSON += %s + 1", json))
+		return fmt.Sprintf("if len(%s) > 0 {\ns.JSON += %d + 1\nfor _, e := range %s {\n%s\n}\n}",
+			v, key, v, strings.Join(stmts, "\n"))
+	}
+	var cond string
+	switch {
+	case field.OneofIndex != nil && !field.GetProto3Optional():
+		oneof := goNames[msg.GetOneofDecl()[field.GetOneofIndex()].GetName()]
+		cond = fmt.Sprintf("v, ok := m.%s.(*%s_%s); ok", oneof, msgName, goName)
+		v = "v." + goName
+	case field.GetType() == descriptor.FieldDescriptorProto_TYPE_BYTES:
+		cond = fmt.Sprintf("len(%s) > 0", v)
+		if !g.proto3 {
+			// proto2 encodes a set bytes field even if it is empty.
+			cond = v + " != nil"
+		}
+	case g.isMessage(field), field.GetProto3Optional() || !g.proto3:
+		cond = v + " != nil"
+		if !g.isMessage(field) {
+			v = "*" + v
+		}
+	case field.GetType() == descriptor.FieldDescriptorProto_TYPE_STRING:
+		cond = v + ` != ""`
+	case field.GetType() == descriptor.FieldDescriptorProto_TYPE_BOOL:
+		cond = v
+	default:
+		cond = v + " != 0"
+	}
+	stmts, wire, json := g.value(field, v)
+	stmts = append(stmts,
+		fmt.Sprintf("s.Wire += %d + %s", tagSize(field.GetNumber(), field), wire),
+		fmt.Sprintf("s.JSON += %d + %s", key, json))
+	return fmt.Sprintf("if %s {\n%s\n}", cond, strings.Join(stmts, "\n"))
+}
+
+// value returns the statements and the expressions computing the wire and
+// JSON sizes of the value v of field, without its tag or key.
+func (g *sizeGen) value(field *descriptor.FieldDescriptorProto, v string) ([]string, string, string) {
+	switch field.GetType() {
+	case descriptor.FieldDescriptorProto_TYPE_MESSAGE, descriptor.FieldDescriptorProto_TYPE_GROUP:
+		n := fmt.Sprintf("n := sizeest.Proto(%s)", v)
+		if g.gen[g.idx.files[field.GetTypeName()].GetName()] {
+			n = fmt.Sprintf("n := %s.EstimateSize()", v)
+		}
+		return []string{n}, "sizeest.Varint(uint64(n.Wire)) + n.Wire", "n.JSON"
+	case descriptor.FieldDescriptorProto_TYPE_STRING:
+		return nil, fmt.Sprintf("sizeest.Varint(uint64(len(%s))) + len(%s)", v, v), fmt.Sprintf("len(%s) + 2", v)
+	case descriptor.FieldDescriptorProto_TYPE_BYTES:
+		return nil, fmt.Sprintf("sizeest.Varint(uint64(len(%s))) + len(%s)", v, v), fmt.Sprintf("sizeest.Base64(len(%s))", v)
+	case descriptor.FieldDescriptorProto_TYPE_ENUM:
+		s := v
+		if strings.HasPrefix(v, "*") {
+			// The String method is called on the enum, not the pointer.
+			s = "(" + v + ")"
+		}
+		return nil, fmt.Sprintf("sizeest.Varint(uint64(%s))", v), fmt.Sprintf("len(%s.String()) + 2", s)
+	case descriptor.FieldDescriptorProto_TYPE_BOOL:
+		return nil, "1", fmt.Sprintf("sizeest.Bool(%s)", v)
+	case descriptor.FieldDescriptorProto_TYPE_DOUBLE:
+		return nil, "8", fmt.Sprintf("sizeest.Float(%s)", v)
+	case descriptor.FieldDescriptorProto_TYPE_FLOAT:
+		return nil, "4", fmt.Sprintf("sizeest.Float(float64(%s))", v)
+	case descriptor.FieldDescriptorProto_TYPE_FIXED32:
+		return nil, "4", fmt.Sprintf("sizeest.Uint(uint64(%s))", v)
+	case descriptor.FieldDescriptorProto_TYPE_SFIXED32:
+		return nil, "4", fmt.Sprintf("sizeest.Int(int64(%s))", v)
+	case descriptor.FieldDescriptorProto_TYPE_FIXED64:
+		return nil, "8", fmt.Sprintf("sizeest.Uint(%s) + 2", v)
+	case descriptor.FieldDescriptorProto_TYPE_SFIXED64:
+		return nil, "8", fmt.Sprintf("sizeest.Int(%s) + 2", v)
+	case descriptor.FieldDescriptorProto_TYPE_SINT32:
+		return nil, fmt.Sprintf("sizeest.Zigzag(int64(%s))", v), fmt.Sprintf("sizeest.Int(int64(%s))", v)
+	case descriptor.FieldDescriptorProto_TYPE_SINT64:
+		// JSON quotes 64-bit integers.
+		return nil, fmt.Sprintf("sizeest.Zigzag(%s)", v), fmt.Sprintf("sizeest.Int(%s) + 2", v)
+	case descriptor.FieldDescriptorProto_TYPE_UINT32:
+		return nil, fmt.Sprintf("sizeest.Varint(uint64(%s))", v), fmt.Sprintf("sizeest.Uint(uint64(%s))", v)
+	case descriptor.FieldDescriptorProto_TYPE_UINT64:
+		return nil, fmt.Sprintf("sizeest.Varint(%s)", v), fmt.Sprintf("sizeest.Uint(%s) + 2", v)
+	case descriptor.FieldDescriptorProto_TYPE_INT64:
+		return nil, fmt.Sprintf("sizeest.Varint(uint64(%s))", v), fmt.Sprintf("sizeest.Int(%s) + 2", v)
+	}
+	// A negative int32 is sign extended to ten bytes.
+	return nil, fmt.Sprintf("sizeest.Varint(uint64(%s))", v), fmt.Sprintf("sizeest.Int(int64(%s))", v)
+}
+
+// packed reports whether the repeated field is encoded packed.
+func (g *sizeGen) packed(field *descriptor.FieldDescriptorProto) bool {
+	switch field.GetType() {
+	case descriptor.FieldDescriptorProto_TYPE_STRING, descriptor.FieldDescriptorProto_TYPE_BYTES,
+		descriptor.FieldDescriptorProto_TYPE_MESSAGE, descriptor.FieldDescriptorProto_TYPE_GROUP:
+		return false
+	}
+	if field.GetOptions() != nil && field.GetOptions().Packed != nil {
+		return field.GetOptions().GetPacked()
+	}
+	return g.proto3
+}
+
+// tagSize returns the size of the tag of the field numbered num, encoded
+// as field is.
+func tagSize(num int32, field *descriptor.FieldDescriptorProto) int {
+	return varintSize(uint64(num)<<3 | wireType(field))
+}
+
+// lenTagSize returns the size of the tag of field encoded packed.
+func lenTagSize(field *descriptor.FieldDescriptorProto) int {
+	return varintSize(uint64(field.GetNumber())<<3 | 2)
+}
+
+// wireType returns the wire type of a value of field.
+func wireType(field *descriptor.FieldDescriptorProto) uint64 {
+	switch field.GetType() {
+	case descriptor.FieldDescriptorProto_TYPE_DOUBLE, descriptor.FieldDescriptorProto_TYPE_FIXED64,
+		descriptor.FieldDescriptorProto_TYPE_SFIXED64:
+		return 1
+	case descriptor.FieldDescriptorProto_TYPE_STRING, descriptor.FieldDescriptorProto_TYPE_BYTES,
+		descriptor.FieldDescriptorProto_TYPE_MESSAGE, descriptor.FieldDescriptorProto_TYPE_GROUP:
+		return 2
+	case descriptor.FieldDescriptorProto_TYPE_FLOAT, descriptor.FieldDescriptorProto_TYPE_FIXED32,
+		descriptor.FieldDescriptorProto_TYPE_SFIXED32:
+		return 5
+	}
+	return 0
+}
+
+func varintSize(v uint64) int {
+	n := 1
+	for v >= 0x80 {
+		v >>= 7
+		n++
+	}
+	return n
+}
+
+func (g *sizeGen) isMessage(field *descriptor.FieldDescriptorProto) bool {
+	return field.GetType() == descriptor.FieldDescriptorProto_TYPE_MESSAGE ||
+		field.GetType() == descriptor.FieldDescriptorProto_TYPE_GROUP
+}
+
+type header struct {
+	Source string
+	GoPkg  string
+}
+
+type sizeMessage struct {
+	Name   string
+	Fields []string
+}
+
+// typeIndex maps fully-qualified proto type names (".pkg.Msg") to their
+// descriptors and declaring files for every file in the request.
+type typeIndex struct {
+	messages map[string]*descriptor.DescriptorProto
+	enums    map[string]*descriptor.EnumDescriptorProto
+	files    map[string]*descriptor.FileDescriptorProto
+}
+
+func newTypeIndex(files []*descriptor.FileDescriptorProto) *typeIndex {
+	idx := &typeIndex{
+		messages: make(map[string]*descriptor.DescriptorProto),
+		enums:    make(map[string]*descriptor.EnumDescriptorProto),
+		files:    make(map[string]*descriptor.FileDescriptorProto),
+	}
+	for _, f := range files {
+		prefix := ""
+		if f.GetPackage() != "" {
+			prefix = "." + f.GetPackage()
+		}
+		idx.addMessages(f, prefix, f.GetMessageType())
+		idx.addEnums(f, prefix, f.GetEnumType())
+	}
+	return idx
+}
+
+func (idx *typeIndex) addMessages(f *descriptor.FileDescriptorProto, prefix string, msgs []*descriptor.DescriptorProto) {
+	for _, m := range msgs {
+		name := prefix + "." + m.GetName()
+		idx.messages[name] = m
+		idx.files[name] = f
+		idx.addMessages(f, name, m.GetNestedType())
+		idx.addEnums(f, name, m.GetEnumType())
+	}
+}
+
+func (idx *typeIndex) addEnums(f *descriptor.FileDescriptorProto, prefix string, enums []*descriptor.EnumDescriptorProto) {
+	for _, e := range enums {
+		name := prefix + "." + e.GetName()
+		idx.enums[name] = e
+		idx.files[name] = f
+	}
+}
+
+// isMap reports whether field is a map field.
+func (idx *typeIndex) isMap(field *descriptor.FieldDescriptorProto) bool {
+	if field.GetType() != descriptor.FieldDescriptorProto_TYPE_MESSAGE {
+		return false
+	}
+	return idx.messages[field.GetTypeName()].GetOptions().GetMapEntry()
+}
+
+// sanitizePackageName replaces unallowed character in package name
+// with allowed character.
+func sanitizePackageName(pkgName string) string {
+	pkgName = strings.Replace(pkgName, ".", "_", -1)
+	pkgName = strings.Replace(pkgName, "-", "_", -1)
+	return pkgName
+}
+
+// defaultGoPackageName returns the default go package name to be used for go files generated from "f".
+// You might need to use an unique alias for the package when you import it.  Use ReserveGoPackageAlias to get a unique alias.
+func defaultGoPackageName(f *descriptor.FileDescriptorProto) string {
+	name := packageIdentityName(f)
+	return sanitizePackageName(name)
+}
+
+// packageIdentityName returns the identity of packages.
+// protoc-gen-grpc-gateway rejects CodeGenerationRequests which contains more than one packages
+// as protoc-gen-go does.
+func packageIdentityName(f *descriptor.FileDescriptorProto) string {
+	if f.Options != nil && f.Options.GoPackage != nil {
+		gopkg := f.Options.GetGoPackage()
+		idx := strings.LastIndex(gopkg, "/")
+		if idx < 0 {
+			gopkg = gopkg[idx+1:]
+		}
+
+		gopkg = gopkg[idx+1:]
+		// package name is overrided with the string after the
+		// ';' character
+		sc := strings.IndexByte(gopkg, ';')
+		if sc < 0 {
+			return sanitizePackageName(gopkg)
+
+		}
+		return sanitizePackageName(gopkg[sc+1:])
+	}
+
+	if f.Package == nil {
+		base := filepath.Base(f.GetName())
+		ext := filepath.Ext(base)
+		return strings.TrimSuffix(base, ext)
+	}
+	return f.GetPackage()
+}
+
+func emitFiles(out []*plugin.CodeGeneratorResponse_File) {
+	emitResp(&plugin.CodeGeneratorResponse{File: out})
+}
+
+func emitError(err error) {
+	emitResp(&plugin.CodeGeneratorResponse{Error: proto.String(err.Error())})
+}
+
+func emitResp(resp *plugin.CodeGeneratorResponse) {
+	buf, err := proto.Marshal(resp)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if _, err := os.Stdout.Write(buf); err != nil {
+		log.Fatal(err)
+	}
+}
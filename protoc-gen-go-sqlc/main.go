@@ -0,0 +1,501 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+
+	"github.com/f4tq/protoc-go-plugins/goname"
+	"github.com/f4tq/protoc-go-plugins/options"
+	"github.com/f4tq/protoc-go-plugins/protodoc"
+)
+
+var (
+	hdrTmpl = template.Must(template.New("header").Parse(`
+// Code generated by protoc-gen-go-sqlc. DO NOT EDIT.
+// source: {{.Source}}
+
+package {{.GoPkg}}
+
+import (
+{{- if .NeedsSQL}}
+    "database/sql"
+{{- end}}
+{{- if .NeedsPtypes}}
+
+    "github.com/golang/protobuf/ptypes"
+{{- end}}
+)
+`))
+
+	modelTmpl = template.Must(template.New("model").Parse(`
+// {{.Msg}}Row is the sqlc model of the {{.Table}} table.{{.Doc}}
+type {{.Msg}}Row struct {
+{{- range .Columns}}
+    {{.RowField}} {{.GoType}} ` + "`" + `db:"{{.Name}}" json:"{{.Name}}"` + "`" + `
+{{- end}}
+}
+
+// To{{.Msg}}Row converts msg into its {{.Table}} row.
+func (msg *{{.Msg}}) To{{.Msg}}Row() ({{.Msg}}Row, error) {
+    var row {{.Msg}}Row
+{{- range .Columns}}
+{{- if .IsTime}}
+    if ts := msg.Get{{.MsgField}}(); ts != nil {
+        t, err := ptypes.Timestamp(ts)
+        if err != nil {
+            return row, err
+        }
+        row.{{.RowField}} = sql.NullTime{Time: t, Valid: true}
+    }
+{{- else}}
+    row.{{.RowField}} = {{.ToRow}}
+{{- end}}
+{{- end}}
+    return row, nil
+}
+
+// {{.Msg}}FromRow converts a {{.Table}} row into a {{.Msg}}.{{.Doc}}
+func {{.Msg}}FromRow(row {{.Msg}}Row) (*{{.Msg}}, error) {
+    msg := new({{.Msg}})
+{{- range .Columns}}
+{{- if .IsTime}}
+    if row.{{.RowField}}.Valid {
+        ts, err := ptypes.TimestampProto(row.{{.RowField}}.Time)
+        if err != nil {
+            return nil, err
+        }
+        msg.{{.MsgField}} = ts
+    }
+{{- else}}
+    msg.{{.MsgField}} = {{.FromRow}}
+{{- end}}
+{{- end}}
+    return msg, nil
+}
+`))
+
+	schemaTmpl = template.Must(template.New("schema").Parse(`
+CREATE TABLE {{.Table}} (
+{{- range $i, $c := .Columns}}{{if $i}},{{end}}
+    {{$c.Name}} {{$c.SQLType}}{{if not $c.Nullable}} NOT NULL{{end}}
+{{- end}}
+{{- if .Keys}},
+    PRIMARY KEY ({{range $i, $c := .Keys}}{{if $i}}, {{end}}{{$c.Name}}{{end}})
+{{- end}}
+);
+`))
+
+	queryTmpl = template.Must(template.New("query").Parse(`
+-- name: Create{{.Msg}} :exec
+INSERT INTO {{.Table}} (
+    {{range $i, $c := .Columns}}{{if $i}}, {{end}}{{$c.Name}}{{end}}
+) VALUES (
+    {{range $i, $c := .Columns}}{{if $i}}, {{end}}${{$c.Param}}{{end}}
+);
+
+-- name: List{{.Msg}}s :many
+SELECT {{range $i, $c := .Columns}}{{if $i}}, {{end}}{{$c.Name}}{{end}}
+FROM {{.Table}}
+{{- if .Keys}}
+ORDER BY {{range $i, $c := .Keys}}{{if $i}}, {{end}}{{$c.Name}}{{end}}
+{{- end}};
+{{- if .Keys}}
+
+-- name: Get{{.Msg}} :one
+SELECT {{range $i, $c := .Columns}}{{if $i}}, {{end}}{{$c.Name}}{{end}}
+FROM {{.Table}}
+WHERE {{range $i, $c := .Keys}}{{if $i}} AND {{end}}{{$c.Name}} = ${{$c.KeyParam}}{{end}};
+{{- if .NonKeys}}
+
+-- name: Update{{.Msg}} :exec
+UPDATE {{.Table}}
+SET {{range $i, $c := .NonKeys}}{{if $i}}, {{end}}{{$c.Name}} = ${{$c.Param}}{{end}}
+WHERE {{range $i, $c := .Keys}}{{if $i}} AND {{end}}{{$c.Name}} = ${{$c.Param}}{{end}};
+{{- end}}
+
+-- name: Delete{{.Msg}} :exec
+DELETE FROM {{.Table}}
+WHERE {{range $i, $c := .Keys}}{{if $i}} AND {{end}}{{$c.Name}} = ${{$c.KeyParam}}{{end}};
+{{- end}}
+`))
+)
+
+func main() {
+	input, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	req := new(plugin.CodeGeneratorRequest)
+	if err = proto.Unmarshal(input, req); err != nil {
+		log.Fatal(err)
+	}
+
+	out, err := generate(req)
+
+	if err != nil {
+		emitError(err)
+		return
+	}
+
+	emitFiles(out)
+}
+
+func generate(req *plugin.CodeGeneratorRequest) ([]*plugin.CodeGeneratorResponse_File, error) {
+	var files []*plugin.CodeGeneratorResponse_File
+	docs := protodoc.New(req.GetProtoFile())
+	genFileNames := make(map[string]bool)
+	for _, n := range req.FileToGenerate {
+		genFileNames[n] = true
+	}
+	for _, desc := range req.GetProtoFile() {
+		name := desc.GetName()
+		if _, ok := genFileNames[name]; !ok {
+			// Only emit output for files present in req.FileToGenerate.
+			continue
+		}
+		tables, err := buildTables(desc, docs)
+		if err != nil {
+			return nil, err
+		}
+		if len(tables) == 0 {
+			// Nothing annotated with (f4tq.plugins.table) in this file.
+			continue
+		}
+		code, schema, queries, err := genCode(desc, tables)
+		if err != nil {
+			return nil, err
+		}
+		formatted, err := format.Source([]byte(code))
+		if err != nil {
+			log.Printf("%v: %s", err, code)
+			return nil, err
+		}
+
+		ext := filepath.Ext(name)
+		base := strings.TrimSuffix(name, ext)
+		files = append(files,
+			&plugin.CodeGeneratorResponse_File{
+				Name:    proto.String(fmt.Sprintf("%s.pb.sqlc.go", base)),
+				Content: proto.String(string(formatted)),
+			},
+			&plugin.CodeGeneratorResponse_File{
+				Name:    proto.String(fmt.Sprintf("%s.sqlc.schema.sql", base)),
+				Content: proto.String(schema),
+			},
+			&plugin.CodeGeneratorResponse_File{
+				Name:    proto.String(fmt.Sprintf("%s.sqlc.query.sql", base)),
+				Content: proto.String(queries),
+			},
+		)
+	}
+
+	return files, nil
+}
+
+func genCode(desc *descriptor.FileDescriptorProto, tables []*table) (string, string, string, error) {
+	w := bytes.NewBuffer(nil)
+	hdr := &header{
+		Source: desc.GetName(),
+		GoPkg:  defaultGoPackageName(desc),
+	}
+	for _, t := range tables {
+		for _, c := range t.Columns {
+			if c.IsTime {
+				hdr.NeedsSQL = true
+				hdr.NeedsPtypes = true
+			}
+		}
+	}
+
+	if err := hdrTmpl.Execute(w, hdr); err != nil {
+		log.Fatal(err)
+	}
+
+	sqlHdr := fmt.Sprintf("-- Code generated by protoc-gen-go-sqlc. DO NOT EDIT.\n-- source: %s\n", desc.GetName())
+	schema := bytes.NewBufferString(sqlHdr)
+	queries := bytes.NewBufferString(sqlHdr)
+	for _, t := range tables {
+		if err := modelTmpl.Execute(w, t); err != nil {
+			return "", "", "", err
+		}
+		if err := schemaTmpl.Execute(schema, t); err != nil {
+			return "", "", "", err
+		}
+		if err := queryTmpl.Execute(queries, t); err != nil {
+			return "", "", "", err
+		}
+	}
+
+	return w.String(), schema.String(), queries.String(), nil
+}
+
+type header struct {
+	Source      string
+	GoPkg       string
+	NeedsSQL    bool
+	NeedsPtypes bool
+}
+
+type table struct {
+	Msg     string
+	Table   string
+	Columns []*column
+	Keys    []*column
+	NonKeys []*column
+	// Doc is the comment of the message, see protodoc.Index.Godoc.
+	Doc string
+}
+
+// qualify returns the full proto name of name, declared in scope.
+func qualify(scope, name string) string {
+	if scope == "" {
+		return name
+	}
+	return scope + "." + name
+}
+
+type column struct {
+	Name     string
+	SQLType  string
+	Nullable bool
+	GoType   string
+	RowField string
+	MsgField string
+	ToRow    string
+	FromRow  string
+	IsTime   bool
+	// Param is the placeholder index in INSERT and UPDATE statements,
+	// KeyParam the index in statements filtering by primary key only.
+	Param    int
+	KeyParam int
+}
+
+// buildTables collects the messages of desc annotated with a table option.
+func buildTables(desc *descriptor.FileDescriptorProto, docs *protodoc.Index) ([]*table, error) {
+	var tables []*table
+	for _, msg := range desc.GetMessageType() {
+		name := options.Table(msg)
+		if name == "" {
+			continue
+		}
+		t := &table{Msg: msg.GetName(), Table: name, Doc: docs.Godoc(qualify(desc.GetPackage(), msg.GetName()))}
+		for _, field := range msg.GetField() {
+			if field.OneofIndex != nil {
+				// Oneof members (including proto3 optional) have no
+				// single column representation.
+				continue
+			}
+			c, err := buildColumn(msg, field)
+			if err != nil {
+				return nil, err
+			}
+			if c == nil {
+				continue
+			}
+			t.Columns = append(t.Columns, c)
+			c.Param = len(t.Columns)
+			if options.PrimaryKey(field) {
+				t.Keys = append(t.Keys, c)
+				c.KeyParam = len(t.Keys)
+			} else {
+				t.NonKeys = append(t.NonKeys, c)
+			}
+		}
+		if len(t.Columns) == 0 {
+			return nil, fmt.Errorf("%s: table %q has no columns", msg.GetName(), name)
+		}
+		tables = append(tables, t)
+	}
+	return tables, nil
+}
+
+// buildColumn maps field onto a column, or returns nil if the field has no
+// column representation.
+func buildColumn(msg *descriptor.DescriptorProto, field *descriptor.FieldDescriptorProto) (*column, error) {
+	if field.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REPEATED {
+		return nil, nil
+	}
+	name := options.Column(field)
+	c := &column{
+		Name:     name,
+		RowField: camelCase(name),
+		MsgField: goname.Fields(msg)[field.GetName()],
+	}
+	get := fmt.Sprintf("msg.Get%s()", c.MsgField)
+	from := "row." + c.RowField
+	switch field.GetType() {
+	case descriptor.FieldDescriptorProto_TYPE_STRING:
+		c.SQLType, c.GoType = "text", "string"
+	case descriptor.FieldDescriptorProto_TYPE_BOOL:
+		c.SQLType, c.GoType = "boolean", "bool"
+	case descriptor.FieldDescriptorProto_TYPE_BYTES:
+		c.SQLType, c.GoType = "bytea", "[]byte"
+	case descriptor.FieldDescriptorProto_TYPE_INT32,
+		descriptor.FieldDescriptorProto_TYPE_SINT32,
+		descriptor.FieldDescriptorProto_TYPE_SFIXED32:
+		c.SQLType, c.GoType = "integer", "int32"
+	case descriptor.FieldDescriptorProto_TYPE_INT64,
+		descriptor.FieldDescriptorProto_TYPE_SINT64,
+		descriptor.FieldDescriptorProto_TYPE_SFIXED64:
+		c.SQLType, c.GoType = "bigint", "int64"
+	case descriptor.FieldDescriptorProto_TYPE_UINT32,
+		descriptor.FieldDescriptorProto_TYPE_FIXED32:
+		c.SQLType, c.GoType = "bigint", "int64"
+		get = "int64(" + get + ")"
+		from = "uint32(" + from + ")"
+	case descriptor.FieldDescriptorProto_TYPE_UINT64,
+		descriptor.FieldDescriptorProto_TYPE_FIXED64:
+		c.SQLType, c.GoType = "bigint", "int64"
+		get = "int64(" + get + ")"
+		from = "uint64(" + from + ")"
+	case descriptor.FieldDescriptorProto_TYPE_FLOAT:
+		c.SQLType, c.GoType = "real", "float32"
+	case descriptor.FieldDescriptorProto_TYPE_DOUBLE:
+		c.SQLType, c.GoType = "double precision", "float64"
+	case descriptor.FieldDescriptorProto_TYPE_ENUM:
+		c.SQLType, c.GoType = "integer", "int32"
+		get = "int32(" + get + ")"
+		from = localTypeName(field.GetTypeName()) + "(" + from + ")"
+	case descriptor.FieldDescriptorProto_TYPE_MESSAGE:
+		if field.GetTypeName() != ".google.protobuf.Timestamp" {
+			return nil, nil
+		}
+		c.SQLType, c.GoType = "timestamptz", "sql.NullTime"
+		c.Nullable = true
+		c.IsTime = true
+	default:
+		return nil, fmt.Errorf("%s.%s: unsupported column type %v", msg.GetName(), field.GetName(), field.GetType())
+	}
+	c.ToRow = get
+	c.FromRow = from
+	return c, nil
+}
+
+// localTypeName returns the Go name of a type declared in the file being
+// generated, e.g. ".pkg.Outer.Inner" becomes "Outer_Inner".
+func localTypeName(typeName string) string {
+	parts := strings.Split(strings.TrimPrefix(typeName, "."), ".")
+	var names []string
+	for _, p := range parts {
+		if len(p) > 0 && p[0] >= 'A' && p[0] <= 'Z' {
+			names = append(names, p)
+		}
+	}
+	return strings.Join(names, "_")
+}
+
+// camelCase returns the Go field name protoc-gen-go derives from s.
+func camelCase(s string) string {
+	if s == "" {
+		return ""
+	}
+	t := make([]byte, 0, 32)
+	i := 0
+	if s[0] == '_' {
+		// Need a capital letter; drop the '_'.
+		t = append(t, 'X')
+		i++
+	}
+	for ; i < len(s); i++ {
+		c := s[i]
+		if c == '_' && i+1 < len(s) && isASCIILower(s[i+1]) {
+			continue
+		}
+		if isASCIIDigit(c) {
+			t = append(t, c)
+			continue
+		}
+		if isASCIILower(c) {
+			c ^= ' '
+		}
+		t = append(t, c)
+		for i+1 < len(s) && isASCIILower(s[i+1]) {
+			i++
+			t = append(t, s[i])
+		}
+	}
+	return string(t)
+}
+
+func isASCIILower(c byte) bool {
+	return 'a' <= c && c <= 'z'
+}
+
+func isASCIIDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+// sanitizePackageName replaces unallowed character in package name
+// with allowed character.
+func sanitizePackageName(pkgName string) string {
+	pkgName = strings.Replace(pkgName, ".", "_", -1)
+	pkgName = strings.Replace(pkgName, "-", "_", -1)
+	return pkgName
+}
+
+// defaultGoPackageName returns the default go package name to be used for go files generated from "f".
+// You might need to use an unique alias for the package when you import it.  Use ReserveGoPackageAlias to get a unique alias.
+func defaultGoPackageName(f *descriptor.FileDescriptorProto) string {
+	name := packageIdentityName(f)
+	return sanitizePackageName(name)
+}
+
+// packageIdentityName returns the identity of packages.
+// protoc-gen-grpc-gateway rejects CodeGenerationRequests which contains more than one packages
+// as protoc-gen-go does.
+func packageIdentityName(f *descriptor.FileDescriptorProto) string {
+	if f.Options != nil && f.Options.GoPackage != nil {
+		gopkg := f.Options.GetGoPackage()
+		idx := strings.LastIndex(gopkg, "/")
+		if idx < 0 {
+			gopkg = gopkg[idx+1:]
+		}
+
+		gopkg = gopkg[idx+1:]
+		// package name is overrided with the string after the
+		// ';' character
+		sc := strings.IndexByte(gopkg, ';')
+		if sc < 0 {
+			return sanitizePackageName(gopkg)
+
+		}
+		return sanitizePackageName(gopkg[sc+1:])
+	}
+
+	if f.Package == nil {
+		base := filepath.Base(f.GetName())
+		ext := filepath.Ext(base)
+		return strings.TrimSuffix(base, ext)
+	}
+	return f.GetPackage()
+}
+
+func emitFiles(out []*plugin.CodeGeneratorResponse_File) {
+	emitResp(&plugin.CodeGeneratorResponse{File: out})
+}
+
+func emitError(err error) {
+	emitResp(&plugin.CodeGeneratorResponse{Error: proto.String(err.Error())})
+}
+
+func emitResp(resp *plugin.CodeGeneratorResponse) {
+	buf, err := proto.Marshal(resp)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if _, err := os.Stdout.Write(buf); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,393 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+
+	"github.com/f4tq/protoc-go-plugins/goname"
+	"github.com/f4tq/protoc-go-plugins/options"
+	"github.com/f4tq/protoc-go-plugins/protodoc"
+)
+
+var (
+	hdrTmpl = template.Must(template.New("header").Parse(`
+// Code generated by protoc-gen-go-sqs. DO NOT EDIT.
+// source: {{.Source}}
+
+package {{.GoPkg}}
+
+import (
+    "context"
+{{- if .Strconv}}
+    "strconv"
+{{- end}}
+
+    "github.com/f4tq/protoc-go-plugins/runtime/sqspb"
+    "github.com/golang/protobuf/proto"
+)
+`))
+
+	topicTmpl = template.Must(template.New("topic").Parse(`
+{{- if .Attributes}}
+// {{.Lower}}SQSAttributes returns the message attributes {{.Name}} fields
+// are sent as.
+func {{.Lower}}SQSAttributes(msg *{{.Name}}) []sqspb.Attribute {
+    return []sqspb.Attribute{
+{{- range .Attributes}}
+        {Name: {{printf "%q" .Name}}, Type: {{printf "%q" .Type}}, Value: {{.Value}}},
+{{- end}}
+    }
+}
+{{end}}
+// {{.Name}}SQSSender sends {{.Name}} messages to an SQS queue
+{{- if .GroupID}}, grouped on
+// FIFO queues by their {{.GroupField}} field{{end}}.{{.Doc}}
+type {{.Name}}SQSSender struct {
+    sender *sqspb.Sender
+}
+
+// New{{.Name}}SQSSender returns a {{.Name}}SQSSender sending through sender.{{.Doc}}
+func New{{.Name}}SQSSender(sender *sqspb.Sender) *{{.Name}}SQSSender {
+    return &{{.Name}}SQSSender{sender: sender}
+}
+
+// Send sends msg and returns its message ID.
+func (s *{{.Name}}SQSSender) Send(ctx context.Context, msg *{{.Name}}) (string, error) {
+    return s.sender.Send(ctx, msg, {{template "group" .}}, {{template "attrs" .}})
+}
+
+// SendBatch sends msgs in batches of up to sqspb.MaxBatchSize. Messages
+// the queue rejects are reported by a *sqspb.BatchError.
+func (s *{{.Name}}SQSSender) SendBatch(ctx context.Context, msgs []*{{.Name}}) error {
+    entries := make([]sqspb.Entry, len(msgs))
+    for i, msg := range msgs {
+        entries[i] = sqspb.Entry{Message: msg, GroupID: {{template "group" .}}, Attributes: {{template "attrs" .}}}
+    }
+    return s.sender.SendBatch(ctx, entries)
+}
+
+// {{.Name}}SNSPublisher publishes {{.Name}} messages to an SNS topic
+{{- if .GroupID}},
+// grouped on FIFO topics by their {{.GroupField}} field{{end}}.{{.Doc}}
+type {{.Name}}SNSPublisher struct {
+    publisher *sqspb.Publisher
+}
+
+// New{{.Name}}SNSPublisher returns a {{.Name}}SNSPublisher publishing
+// through publisher.{{.Doc}}
+func New{{.Name}}SNSPublisher(publisher *sqspb.Publisher) *{{.Name}}SNSPublisher {
+    return &{{.Name}}SNSPublisher{publisher: publisher}
+}
+
+// Publish publishes msg and returns its message ID.
+func (p *{{.Name}}SNSPublisher) Publish(ctx context.Context, msg *{{.Name}}) (string, error) {
+    return p.publisher.Publish(ctx, msg, {{template "group" .}}, {{template "attrs" .}})
+}
+
+// {{.Name}}SQSHandler handles polled {{.Name}} messages. sqspb.Message(ctx)
+// returns the SQS message a message was decoded from. Returning an error
+// leaves the message on the queue to be received again, or moved to its
+// dead letter queue; wrap it with sqspb.RetryAfter to choose when.{{.Doc}}
+type {{.Name}}SQSHandler interface {
+    Handle{{.Name}}(ctx context.Context, msg *{{.Name}}) error
+}
+
+// {{.Name}}SQSHandlerFunc adapts a function to a {{.Name}}SQSHandler.{{.Doc}}
+type {{.Name}}SQSHandlerFunc func(ctx context.Context, msg *{{.Name}}) error
+
+// Handle{{.Name}} calls f(ctx, msg).
+func (f {{.Name}}SQSHandlerFunc) Handle{{.Name}}(ctx context.Context, msg *{{.Name}}) error {
+    return f(ctx, msg)
+}
+
+// Poll{{.Name}} passes the {{.Name}} messages polled by poller to h until
+// ctx is done.{{.Doc}}
+func Poll{{.Name}}(ctx context.Context, poller *sqspb.Poller, h {{.Name}}SQSHandler) error {
+    return poller.Poll(ctx, func() proto.Message { return new({{.Name}}) }, func(ctx context.Context, msg proto.Message) error {
+        return h.Handle{{.Name}}(ctx, msg.(*{{.Name}}))
+    })
+}
+{{- define "group"}}{{if .GroupID}}{{.GroupID}}{{else}}""{{end}}{{end}}
+{{- define "attrs"}}{{if .Attributes}}{{.Lower}}SQSAttributes(msg){{else}}nil{{end}}{{end}}
+`))
+)
+
+func main() {
+	input, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	req := new(plugin.CodeGeneratorRequest)
+	if err = proto.Unmarshal(input, req); err != nil {
+		log.Fatal(err)
+	}
+
+	out, err := generate(req)
+
+	if err != nil {
+		emitError(err)
+		return
+	}
+
+	emitFiles(out)
+}
+
+func generate(req *plugin.CodeGeneratorRequest) ([]*plugin.CodeGeneratorResponse_File, error) {
+	var files []*plugin.CodeGeneratorResponse_File
+	docs := protodoc.New(req.GetProtoFile())
+	genFileNames := make(map[string]bool)
+	for _, n := range req.FileToGenerate {
+		genFileNames[n] = true
+	}
+	for _, desc := range req.GetProtoFile() {
+		name := desc.GetName()
+		if _, ok := genFileNames[name]; !ok {
+			// Only emit output for files present in req.FileToGenerate.
+			continue
+		}
+		code, err := genCode(desc, docs)
+		if err != nil {
+			return nil, err
+		}
+		if code == "" {
+			// No message declares a topic.
+			continue
+		}
+		formatted, err := format.Source([]byte(code))
+		if err != nil {
+			log.Printf("%v: %s", err, code)
+			return nil, err
+		}
+
+		ext := filepath.Ext(name)
+		base := strings.TrimSuffix(name, ext)
+		output := fmt.Sprintf("%s.pb.sqs.go", base)
+		files = append(files, &plugin.CodeGeneratorResponse_File{
+			Name:    proto.String(output),
+			Content: proto.String(string(formatted)),
+		})
+	}
+
+	return files, nil
+}
+
+func genCode(desc *descriptor.FileDescriptorProto, docs *protodoc.Index) (string, error) {
+	w := bytes.NewBuffer(nil)
+	hdr := &header{
+		Source: desc.GetName(),
+		GoPkg:  defaultGoPackageName(desc),
+	}
+	prefix := ""
+	if desc.GetPackage() != "" {
+		prefix = "." + desc.GetPackage()
+	}
+	body := bytes.NewBuffer(nil)
+	if err := genMessages(body, hdr, docs, prefix, desc.GetMessageType()); err != nil {
+		return "", err
+	}
+	if body.Len() == 0 {
+		return "", nil
+	}
+
+	if err := hdrTmpl.Execute(w, hdr); err != nil {
+		log.Fatal(err)
+	}
+	body.WriteTo(w)
+
+	return w.String(), nil
+}
+
+// genMessages writes the senders, publishers and pollers of the messages in
+// msgs, and their nested messages, that have a (f4tq.plugins.topic)
+// option.
+func genMessages(w *bytes.Buffer, hdr *header, docs *protodoc.Index, prefix string, msgs []*descriptor.DescriptorProto) error {
+	for _, m := range msgs {
+		name := prefix + "." + m.GetName()
+		if m.GetOptions().GetMapEntry() {
+			continue
+		}
+		if options.Topic(m) != "" {
+			goName := localTypeName(name)
+			t := &sqsMessage{
+				Name:  goName,
+				Lower: strings.ToLower(goName[:1]) + goName[1:],
+				Doc:   docs.Godoc(name),
+			}
+			seen := make(map[string]bool)
+			goNames := goname.Fields(m)
+			for _, f := range m.GetField() {
+				fieldName := strings.TrimPrefix(name, ".") + "." + f.GetName()
+				if options.MessageKey(f) {
+					if t.GroupID != "" {
+						return fmt.Errorf("%s: more than one message_key field", strings.TrimPrefix(name, "."))
+					}
+					key, _, err := stringExpr(f, goNames[f.GetName()], hdr)
+					if err != nil {
+						return fmt.Errorf("%s: message_key %v", fieldName, err)
+					}
+					t.GroupID = key
+					t.GroupField = f.GetName()
+				}
+				if attr := options.MessageAttribute(f); attr != "" {
+					if seen[attr] || attr == "content-type" || strings.HasPrefix(strings.ToLower(attr), "aws.") {
+						return fmt.Errorf("%s: message_attribute %q is reserved or already used", fieldName, attr)
+					}
+					seen[attr] = true
+					value, typ, err := stringExpr(f, goNames[f.GetName()], hdr)
+					if err != nil {
+						return fmt.Errorf("%s: message_attribute %v", fieldName, err)
+					}
+					t.Attributes = append(t.Attributes, &sqsAttribute{Name: attr, Type: typ, Value: value})
+				}
+			}
+			if err := topicTmpl.Execute(w, t); err != nil {
+				return err
+			}
+		}
+		if err := genMessages(w, hdr, docs, name, m.GetNestedType()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// stringExpr returns the Go expression formatting field f of msg, whose Go
+// name is goName, as a string, and the SQS data type of the value.
+func stringExpr(f *descriptor.FieldDescriptorProto, goName string, hdr *header) (string, string, error) {
+	if f.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REPEATED {
+		return "", "", fmt.Errorf("field must not be repeated")
+	}
+	get := "msg.Get" + goName + "()"
+	switch f.GetType() {
+	case descriptor.FieldDescriptorProto_TYPE_STRING:
+		return get, "String", nil
+	case descriptor.FieldDescriptorProto_TYPE_ENUM:
+		return get + ".String()", "String", nil
+	case descriptor.FieldDescriptorProto_TYPE_BOOL:
+		hdr.Strconv = true
+		return "strconv.FormatBool(" + get + ")", "String", nil
+	case descriptor.FieldDescriptorProto_TYPE_INT32, descriptor.FieldDescriptorProto_TYPE_INT64,
+		descriptor.FieldDescriptorProto_TYPE_SINT32, descriptor.FieldDescriptorProto_TYPE_SINT64,
+		descriptor.FieldDescriptorProto_TYPE_SFIXED32, descriptor.FieldDescriptorProto_TYPE_SFIXED64:
+		hdr.Strconv = true
+		return "strconv.FormatInt(int64(" + get + "), 10)", "Number", nil
+	case descriptor.FieldDescriptorProto_TYPE_UINT32, descriptor.FieldDescriptorProto_TYPE_UINT64,
+		descriptor.FieldDescriptorProto_TYPE_FIXED32, descriptor.FieldDescriptorProto_TYPE_FIXED64:
+		hdr.Strconv = true
+		return "strconv.FormatUint(uint64(" + get + "), 10)", "Number", nil
+	case descriptor.FieldDescriptorProto_TYPE_FLOAT, descriptor.FieldDescriptorProto_TYPE_DOUBLE:
+		hdr.Strconv = true
+		return "strconv.FormatFloat(float64(" + get + "), 'g', -1, 64)", "Number", nil
+	}
+	return "", "", fmt.Errorf("field must be a scalar or enum other than bytes")
+}
+
+type header struct {
+	Source  string
+	GoPkg   string
+	Strconv bool
+}
+
+type sqsMessage struct {
+	Name       string
+	Lower      string
+	GroupID    string
+	GroupField string
+	Attributes []*sqsAttribute
+	// Doc is the comment of the message, see protodoc.Index.Godoc.
+	Doc string
+}
+
+type sqsAttribute struct {
+	Name  string
+	Type  string
+	Value string
+}
+
+// localTypeName returns the Go name of a type declared in the file being
+// generated, e.g. ".pkg.Outer.Inner" becomes "Outer_Inner".
+func localTypeName(typeName string) string {
+	parts := strings.Split(strings.TrimPrefix(typeName, "."), ".")
+	var names []string
+	for _, p := range parts {
+		if len(p) > 0 && p[0] >= 'A' && p[0] <= 'Z' {
+			names = append(names, p)
+		}
+	}
+	return strings.Join(names, "_")
+}
+
+// sanitizePackageName replaces unallowed character in package name
+// with allowed character.
+func sanitizePackageName(pkgName string) string {
+	pkgName = strings.Replace(pkgName, ".", "_", -1)
+	pkgName = strings.Replace(pkgName, "-", "_", -1)
+	return pkgName
+}
+
+// defaultGoPackageName returns the default go package name to be used for go files generated from "f".
+// You might need to use an unique alias for the package when you import it.  Use ReserveGoPackageAlias to get a unique alias.
+func defaultGoPackageName(f *descriptor.FileDescriptorProto) string {
+	name := packageIdentityName(f)
+	return sanitizePackageName(name)
+}
+
+// packageIdentityName returns the identity of packages.
+// protoc-gen-grpc-gateway rejects CodeGenerationRequests which contains more than one packages
+// as protoc-gen-go does.
+func packageIdentityName(f *descriptor.FileDescriptorProto) string {
+	if f.Options != nil && f.Options.GoPackage != nil {
+		gopkg := f.Options.GetGoPackage()
+		idx := strings.LastIndex(gopkg, "/")
+		if idx < 0 {
+			gopkg = gopkg[idx+1:]
+		}
+
+		gopkg = gopkg[idx+1:]
+		// package name is overrided with the string after the
+		// ';' character
+		sc := strings.IndexByte(gopkg, ';')
+		if sc < 0 {
+			return sanitizePackageName(gopkg)
+
+		}
+		return sanitizePackageName(gopkg[sc+1:])
+	}
+
+	if f.Package == nil {
+		base := filepath.Base(f.GetName())
+		ext := filepath.Ext(base)
+		return strings.TrimSuffix(base, ext)
+	}
+	return f.GetPackage()
+}
+
+func emitFiles(out []*plugin.CodeGeneratorResponse_File) {
+	emitResp(&plugin.CodeGeneratorResponse{File: out})
+}
+
+func emitError(err error) {
+	emitResp(&plugin.CodeGeneratorResponse{Error: proto.String(err.Error())})
+}
+
+func emitResp(resp *plugin.CodeGeneratorResponse) {
+	buf, err := proto.Marshal(resp)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if _, err := os.Stdout.Write(buf); err != nil {
+		log.Fatal(err)
+	}
+}
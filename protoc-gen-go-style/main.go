@@ -0,0 +1,418 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+)
+
+func main() {
+	input, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	req := new(plugin.CodeGeneratorRequest)
+	if err = proto.Unmarshal(input, req); err != nil {
+		log.Fatal(err)
+	}
+
+	out, err := generate(req)
+
+	if err != nil {
+		emitError(err)
+		return
+	}
+
+	emitFiles(out)
+}
+
+// ruleDocs describes the rules of the report, by name.
+var ruleDocs = map[string]string{
+	"type_name":         "messages, enums, services and methods are UpperCamelCase",
+	"field_name":        "fields are lower_snake_case",
+	"json_name":         "the JSON names of fields are lowerCamelCase",
+	"enum_value":        "enum values are UPPER_SNAKE_CASE",
+	"enum_value_prefix": "enum values are prefixed with the UPPER_SNAKE_CASE name of their enum",
+	"comment":           "messages, enums, services and methods have comments",
+}
+
+// generate writes one report of the style violations of the files to
+// generate. The rules parameter selects the rules of ruleDocs checked,
+// separated by "+", all of them by default. The format parameter selects
+// "json", "text" or both, the default, separated by "+", written to
+// <name>.json and <name>.txt; name is the parameter of that name, "style"
+// by default. With the fail parameter, violations fail the generation with
+// the text report instead.
+func generate(req *plugin.CodeGeneratorRequest) ([]*plugin.CodeGeneratorResponse_File, error) {
+	params := parseParams(req.GetParameter())
+	name := params["name"]
+	if name == "" {
+		name = "style"
+	}
+	format := params["format"]
+	if format == "" {
+		format = "json+text"
+	}
+	g := &styleGen{rules: make(map[string]bool), report: new(report)}
+	if r := params["rules"]; r != "" {
+		for _, rule := range strings.Split(r, "+") {
+			if _, ok := ruleDocs[rule]; !ok {
+				return nil, fmt.Errorf("unknown rule %q", rule)
+			}
+			g.rules[rule] = true
+		}
+	} else {
+		for rule := range ruleDocs {
+			g.rules[rule] = true
+		}
+	}
+	for rule := range g.rules {
+		g.report.Rules = append(g.report.Rules, rule)
+	}
+	sort.Strings(g.report.Rules)
+	_, fail := params["fail"]
+
+	genFileNames := make(map[string]bool)
+	for _, n := range req.FileToGenerate {
+		genFileNames[n] = true
+	}
+	checked := false
+	for _, desc := range req.GetProtoFile() {
+		if _, ok := genFileNames[desc.GetName()]; !ok {
+			// Only check the files present in req.FileToGenerate.
+			continue
+		}
+		checked = true
+		g.file(desc)
+	}
+	if !checked {
+		return nil, nil
+	}
+	if g.report.Violations == nil {
+		// Written as [] rather than null.
+		g.report.Violations = []*violation{}
+	}
+
+	if fail && len(g.report.Violations) > 0 {
+		return nil, fmt.Errorf("%d style violations:\n%s", len(g.report.Violations), g.report.text())
+	}
+	var files []*plugin.CodeGeneratorResponse_File
+	for _, f := range strings.Split(format, "+") {
+		var content, ext string
+		switch f {
+		case "json":
+			b, err := json.MarshalIndent(g.report, "", "  ")
+			if err != nil {
+				return nil, err
+			}
+			content, ext = string(b)+"\n", ".json"
+		case "text":
+			content, ext = g.report.text(), ".txt"
+		default:
+			return nil, fmt.Errorf("unknown format %q, want json or text", f)
+		}
+		files = append(files, &plugin.CodeGeneratorResponse_File{
+			Name:    proto.String(name + ext),
+			Content: proto.String(content),
+		})
+	}
+	return files, nil
+}
+
+// report is the JSON of the report.
+type report struct {
+	// Rules are the names of the rules checked.
+	Rules      []string     `json:"rules"`
+	Violations []*violation `json:"violations"`
+}
+
+// violation is a declaration breaking a rule. Line and Column, from 1,
+// are only set when the file has its source info.
+type violation struct {
+	File    string `json:"file"`
+	Line    int32  `json:"line,omitempty"`
+	Column  int32  `json:"column,omitempty"`
+	Element string `json:"element"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// text returns the report as lines of "file:line:column: element: message
+// (rule)", in the order of the declarations, followed by the number of
+// violations of each rule.
+func (r *report) text() string {
+	w := bytes.NewBuffer(nil)
+	counts := make(map[string]int)
+	for _, v := range r.Violations {
+		pos := v.File
+		if v.Line > 0 {
+			pos = fmt.Sprintf("%s:%d:%d", v.File, v.Line, v.Column)
+		}
+		fmt.Fprintf(w, "%s: %s: %s (%s)\n", pos, v.Element, v.Message, v.Rule)
+		counts[v.Rule]++
+	}
+	if len(r.Violations) > 0 {
+		w.WriteString("\n")
+	}
+	for _, rule := range r.Rules {
+		fmt.Fprintf(w, "%-17s %4d  %s\n", rule, counts[rule], ruleDocs[rule])
+	}
+	return w.String()
+}
+
+var (
+	upperCamel = regexp.MustCompile(`^[A-Z][a-zA-Z0-9]*$`)
+	lowerCamel = regexp.MustCompile(`^[a-z][a-zA-Z0-9]*$`)
+	lowerSnake = regexp.MustCompile(`^[a-z][a-z0-9]*(_[a-z0-9]+)*$`)
+	upperSnake = regexp.MustCompile(`^[A-Z][A-Z0-9]*(_[A-Z0-9]+)*$`)
+)
+
+type styleGen struct {
+	// rules holds the names of the rules checked.
+	rules  map[string]bool
+	report *report
+	desc   *descriptor.FileDescriptorProto
+	// locations maps the source paths of the declarations of desc to their
+	// locations.
+	locations map[string]*descriptor.SourceCodeInfo_Location
+}
+
+func (g *styleGen) file(desc *descriptor.FileDescriptorProto) {
+	g.desc = desc
+	g.locations = make(map[string]*descriptor.SourceCodeInfo_Location)
+	for _, loc := range desc.GetSourceCodeInfo().GetLocation() {
+		g.locations[fmt.Sprint(loc.GetPath())] = loc
+	}
+	scope := desc.GetPackage()
+	g.messages(scope, []int32{4}, desc.GetMessageType())
+	g.enums(scope, []int32{5}, desc.GetEnumType())
+	for i, svc := range desc.GetService() {
+		path := []int32{6, int32(i)}
+		name := qualify(scope, svc.GetName())
+		g.typeName(path, name, "service", svc.GetName())
+		g.comment(path, name, "service")
+		for j, m := range svc.GetMethod() {
+			mPath := []int32{6, int32(i), 2, int32(j)}
+			mName := name + "." + m.GetName()
+			g.typeName(mPath, mName, "method", m.GetName())
+			g.comment(mPath, mName, "method")
+		}
+	}
+}
+
+// messages checks msgs, and the messages and enums nested in them. scope
+// is the full proto name of their parent and path the source path of msgs.
+func (g *styleGen) messages(scope string, path []int32, msgs []*descriptor.DescriptorProto) {
+	for i, msg := range msgs {
+		if msg.GetOptions().GetMapEntry() {
+			// Declared by protoc for a map field.
+			continue
+		}
+		msgPath := appendPath(path, int32(i))
+		name := qualify(scope, msg.GetName())
+		g.typeName(msgPath, name, "message", msg.GetName())
+		g.comment(msgPath, name, "message")
+		for j, field := range msg.GetField() {
+			fieldPath := appendPath(msgPath, 2, int32(j))
+			fieldName := name + "." + field.GetName()
+			if !lowerSnake.MatchString(field.GetName()) {
+				g.add(fieldPath, fieldName, "field_name", "field name %q is not lower_snake_case", field.GetName())
+			}
+			if json := jsonName(field); !lowerCamel.MatchString(json) {
+				g.add(fieldPath, fieldName, "json_name", "JSON name %q is not lowerCamelCase", json)
+			}
+		}
+		g.messages(name, appendPath(msgPath, 3), msg.GetNestedType())
+		g.enums(name, appendPath(msgPath, 4), msg.GetEnumType())
+	}
+}
+
+// enums checks enums and their values, as messages does.
+func (g *styleGen) enums(scope string, path []int32, enums []*descriptor.EnumDescriptorProto) {
+	for i, enum := range enums {
+		enumPath := appendPath(path, int32(i))
+		name := qualify(scope, enum.GetName())
+		g.typeName(enumPath, name, "enum", enum.GetName())
+		g.comment(enumPath, name, "enum")
+		prefix := upperSnakeCase(enum.GetName()) + "_"
+		for j, v := range enum.GetValue() {
+			valuePath := appendPath(enumPath, 2, int32(j))
+			valueName := name + "." + v.GetName()
+			if !upperSnake.MatchString(v.GetName()) {
+				g.add(valuePath, valueName, "enum_value", "enum value %q is not UPPER_SNAKE_CASE", v.GetName())
+			}
+			if !strings.HasPrefix(v.GetName(), prefix) {
+				g.add(valuePath, valueName, "enum_value_prefix", "enum value %q is not prefixed with %q", v.GetName(), prefix)
+			}
+		}
+	}
+}
+
+// typeName checks the name of the declaration of kind at path.
+func (g *styleGen) typeName(path []int32, element, kind, name string) {
+	if !upperCamel.MatchString(name) {
+		g.add(path, element, "type_name", "%s name %q is not UpperCamelCase", kind, name)
+	}
+}
+
+// comment checks that the declaration of kind at path has a leading or
+// trailing comment.
+func (g *styleGen) comment(path []int32, element, kind string) {
+	loc := g.locations[fmt.Sprint(path)]
+	if strings.TrimSpace(loc.GetLeadingComments()+loc.GetTrailingComments()) == "" {
+		g.add(path, element, "comment", "%s has no comment", kind)
+	}
+}
+
+// add reports the violation of rule by the declaration at path, if the
+// rule is checked.
+func (g *styleGen) add(path []int32, element, rule, format string, args ...interface{}) {
+	if !g.rules[rule] {
+		return
+	}
+	v := &violation{
+		File:    g.desc.GetName(),
+		Element: element,
+		Rule:    rule,
+		Message: fmt.Sprintf(format, args...),
+	}
+	// The span is [start line, start column, (end line,) end column],
+	// from 0.
+	if span := g.locations[fmt.Sprint(path)].GetSpan(); len(span) >= 3 {
+		v.Line, v.Column = span[0]+1, span[1]+1
+	}
+	g.report.Violations = append(g.report.Violations, v)
+}
+
+// qualify returns the full proto name of name, declared in scope.
+func qualify(scope, name string) string {
+	if scope == "" {
+		return name
+	}
+	return scope + "." + name
+}
+
+func appendPath(path []int32, elems ...int32) []int32 {
+	return append(append([]int32(nil), path...), elems...)
+}
+
+// upperSnakeCase returns the UPPER_SNAKE_CASE of the UpperCamelCase name,
+// starting a word at each upper case letter following a lower case letter
+// or digit, or followed by a lower case letter.
+func upperSnakeCase(name string) string {
+	var b strings.Builder
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if i > 0 && isASCIIUpper(c) {
+			prev := name[i-1]
+			if isASCIILower(prev) || isASCIIDigit(prev) || i+1 < len(name) && isASCIILower(name[i+1]) && isASCIIUpper(prev) {
+				b.WriteByte('_')
+			}
+		}
+		if isASCIILower(c) {
+			c ^= ' '
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
+
+func isASCIIUpper(c byte) bool {
+	return 'A' <= c && c <= 'Z'
+}
+
+// jsonName returns the JSON name of field, which protoc sets, or else the
+// lower camel case of its name.
+func jsonName(field *descriptor.FieldDescriptorProto) string {
+	if field.GetJsonName() != "" {
+		return field.GetJsonName()
+	}
+	name := camelCase(field.GetName())
+	return strings.ToLower(name[:1]) + name[1:]
+}
+
+// parseParams splits the comma separated key=value plugin parameter.
+func parseParams(param string) map[string]string {
+	params := make(map[string]string)
+	for _, p := range strings.Split(param, ",") {
+		if p == "" {
+			continue
+		}
+		if i := strings.IndexByte(p, '='); i >= 0 {
+			params[p[:i]] = p[i+1:]
+		} else {
+			params[p] = ""
+		}
+	}
+	return params
+}
+
+// camelCase returns the Go field name protoc-gen-go derives from s.
+func camelCase(s string) string {
+	if s == "" {
+		return ""
+	}
+	t := make([]byte, 0, 32)
+	i := 0
+	if s[0] == '_' {
+		// Need a capital letter; drop the '_'.
+		t = append(t, 'X')
+		i++
+	}
+	for ; i < len(s); i++ {
+		c := s[i]
+		if c == '_' && i+1 < len(s) && isASCIILower(s[i+1]) {
+			continue
+		}
+		if isASCIIDigit(c) {
+			t = append(t, c)
+			continue
+		}
+		if isASCIILower(c) {
+			c ^= ' '
+		}
+		t = append(t, c)
+		for i+1 < len(s) && isASCIILower(s[i+1]) {
+			i++
+			t = append(t, s[i])
+		}
+	}
+	return string(t)
+}
+
+func isASCIILower(c byte) bool {
+	return 'a' <= c && c <= 'z'
+}
+
+func isASCIIDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+func emitFiles(out []*plugin.CodeGeneratorResponse_File) {
+	emitResp(&plugin.CodeGeneratorResponse{File: out})
+}
+
+func emitError(err error) {
+	emitResp(&plugin.CodeGeneratorResponse{Error: proto.String(err.Error())})
+}
+
+func emitResp(resp *plugin.CodeGeneratorResponse) {
+	buf, err := proto.Marshal(resp)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if _, err := os.Stdout.Write(buf); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/f4tq/protoc-go-plugins/internal/plugintest"
+)
+
+// TestConflictingFieldNames vets the summaries of fields protoc-gen-go
+// renames, such as String_ for a field named string.
+func TestConflictingFieldNames(t *testing.T) {
+	req := plugintest.ConflictsRequest(t, "")
+	plugintest.Go(t, "vet", plugintest.Package(t, req, generate))
+}
+
+const eventProto = `
+syntax = "proto3";
+
+package event.v1;
+
+option go_package = "example.com/event/v1;eventv1";
+
+import "google/protobuf/duration.proto";
+import "google/protobuf/timestamp.proto";
+
+message Event {
+    google.protobuf.Timestamp created = 1;
+    google.protobuf.Duration timeout = 2;
+    repeated google.protobuf.Timestamp retries = 3;
+}
+`
+
+// eventTest runs in the package generated for eventProto.
+const eventTest = `package eventv1
+
+import (
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestSummary(t *testing.T) {
+	created := time.Date(2024, 5, 1, 12, 30, 0, 500, time.UTC)
+	m := &Event{
+		Created: timestamppb.New(created),
+		Timeout: durationpb.New(90 * time.Second),
+		Retries: []*timestamppb.Timestamp{timestamppb.New(created)},
+	}
+	want := "Event{created: 2024-05-01T12:30:00.0000005Z, timeout: 1m30s, retries: [2024-05-01T12:30:00.0000005Z]}"
+	if got := m.Summary(); got != want {
+		t.Errorf("Summary() = %q, want %q", got, want)
+	}
+}
+`
+
+// TestTimestampsAndDurations summarizes a message with timestamp and
+// duration fields, which read as RFC 3339 times and Go durations.
+func TestTimestampsAndDurations(t *testing.T) {
+	req := plugintest.Request(t, "", map[string]string{"event/v1/event.proto": eventProto})
+	pkg := plugintest.Package(t, req, generate)
+	plugintest.WriteFile(t, pkg, "summary_test.go", eventTest)
+	plugintest.Go(t, "test", pkg)
+}
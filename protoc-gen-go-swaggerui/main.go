@@ -0,0 +1,1004 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+
+	"github.com/f4tq/protoc-go-plugins/httprule"
+	"github.com/f4tq/protoc-go-plugins/options"
+	"github.com/f4tq/protoc-go-plugins/protodoc"
+)
+
+var (
+	codeTmpl = template.Must(template.New("code").Parse(`
+// Code generated by protoc-gen-go-swaggerui. DO NOT EDIT.
+// source: {{.Source}}
+
+package {{.GoPkg}}
+
+import (
+    _ "embed"
+    "net/http"
+
+    "github.com/f4tq/protoc-go-plugins/runtime/swaggerui"
+)
+
+//go:embed {{.YAMLName}}
+var {{.Var}} string
+
+// {{.Name}}OpenAPI returns the OpenAPI document of the HTTP bindings of
+// the services of {{.Source}}, as the YAML document {{.YAMLName}}.
+func {{.Name}}OpenAPI() string {
+    return {{.Var}}
+}
+
+// {{.Name}}SwaggerUIHandler returns a handler serving Swagger UI for
+// {{.Name}}OpenAPI at prefix + "/", and the document at
+// prefix + "/openapi.yaml".
+func {{.Name}}SwaggerUIHandler(prefix string) http.Handler {
+    return swaggerui.Handler(prefix, {{printf "%q" .Title}}, {{.Var}})
+}
+
+// Register{{.Name}}SwaggerUI serves {{.Name}}SwaggerUIHandler on mux
+// under /docs.
+func Register{{.Name}}SwaggerUI(mux *http.ServeMux) {
+    swaggerui.Register(mux, swaggerui.DefaultPrefix, {{printf "%q" .Title}}, {{.Var}})
+}
+`))
+
+	// plainKey matches the YAML keys and scalars written without quotes.
+	plainKey = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.]*$`)
+
+	// templateVar matches the variables of path templates, with their
+	// optional segment patterns.
+	templateVar = regexp.MustCompile(`\{([^=}]+)(=[^}]*)?\}`)
+)
+
+func main() {
+	input, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	req := new(plugin.CodeGeneratorRequest)
+	if err = proto.Unmarshal(input, req); err != nil {
+		log.Fatal(err)
+	}
+
+	out, err := generate(req)
+
+	if err != nil {
+		emitError(err)
+		return
+	}
+
+	emitFiles(out)
+}
+
+// generate writes, for each file to generate with google.api.http
+// bindings, the OpenAPI 3 document of its services to <base>.openapi.yaml
+// and the Go file embedding it with a Swagger UI handler to
+// <base>.pb.swaggerui.go. The version parameter is the info.version of
+// the documents, "0.0.0" by default, and the title parameter their
+// info.title, the proto package of the file by default.
+func generate(req *plugin.CodeGeneratorRequest) ([]*plugin.CodeGeneratorResponse_File, error) {
+	params := parseParams(req.GetParameter())
+	if params["version"] == "" {
+		params["version"] = "0.0.0"
+	}
+	var files []*plugin.CodeGeneratorResponse_File
+	idx := newTypeIndex(req.GetProtoFile())
+	docs := protodoc.New(req.GetProtoFile())
+	genFileNames := make(map[string]bool)
+	for _, n := range req.FileToGenerate {
+		genFileNames[n] = true
+	}
+	for _, desc := range req.GetProtoFile() {
+		name := desc.GetName()
+		if _, ok := genFileNames[name]; !ok {
+			// Only emit output for files present in req.FileToGenerate.
+			continue
+		}
+		code, yaml, err := genCode(desc, idx, docs, params)
+		if err != nil {
+			return nil, err
+		}
+		if code == "" {
+			// The file has no HTTP bindings.
+			continue
+		}
+		formatted, err := format.Source([]byte(code))
+		if err != nil {
+			log.Printf("%v: %s", err, code)
+			return nil, err
+		}
+
+		ext := filepath.Ext(name)
+		base := strings.TrimSuffix(name, ext)
+		files = append(files,
+			&plugin.CodeGeneratorResponse_File{
+				Name:    proto.String(fmt.Sprintf("%s.pb.swaggerui.go", base)),
+				Content: proto.String(string(formatted)),
+			},
+			&plugin.CodeGeneratorResponse_File{
+				Name:    proto.String(fmt.Sprintf("%s.openapi.yaml", base)),
+				Content: proto.String(yaml),
+			},
+		)
+	}
+
+	return files, nil
+}
+
+func genCode(desc *descriptor.FileDescriptorProto, idx *typeIndex, docs *protodoc.Index, params map[string]string) (string, string, error) {
+	g := &specGen{idx: idx, docs: docs, referenced: make(map[string]bool)}
+	scope := strings.TrimSuffix("."+desc.GetPackage(), ".")
+	var tags []*tag
+	for _, svc := range desc.GetService() {
+		svcName := scope + "." + svc.GetName()
+		bound := false
+		for _, m := range svc.GetMethod() {
+			if m.GetClientStreaming() || m.GetServerStreaming() {
+				// Streaming methods are not served over HTTP.
+				continue
+			}
+			bindings, err := httprule.Bindings(m)
+			if err != nil {
+				return "", "", fmt.Errorf("%s: %s: %v", desc.GetName(), svc.GetName(), err)
+			}
+			for i, b := range bindings {
+				op, err := g.operation(svcName, m, b)
+				if err != nil {
+					return "", "", fmt.Errorf("%s: %s.%s: %v", desc.GetName(), svc.GetName(), m.GetName(), err)
+				}
+				op.ID = svc.GetName() + "_" + m.GetName()
+				if i > 0 {
+					op.ID += strconv.Itoa(i)
+				}
+				if err := g.add(templateVar.ReplaceAllString(b.Template, "{$1}"), op); err != nil {
+					return "", "", fmt.Errorf("%s: %s.%s: %v", desc.GetName(), svc.GetName(), m.GetName(), err)
+				}
+				bound = true
+			}
+		}
+		if bound {
+			tags = append(tags, &tag{Name: strings.TrimPrefix(svcName, "."), Description: docs.Comment(svcName)})
+		}
+	}
+	if len(g.paths) == 0 {
+		return "", "", nil
+	}
+	schemas, err := g.schemas()
+	if err != nil {
+		return "", "", fmt.Errorf("%s: %v", desc.GetName(), err)
+	}
+
+	title := params["title"]
+	if title == "" {
+		title = desc.GetPackage()
+	}
+	if title == "" {
+		title = desc.GetName()
+	}
+	y := bytes.NewBuffer(nil)
+	fmt.Fprintf(y, "# Code generated by protoc-gen-go-swaggerui. DO NOT EDIT.\n# source: %s\nopenapi: 3.0.3\n", desc.GetName())
+	fmt.Fprintf(y, "info:\n  title: %s\n  version: %s\n", strconv.Quote(title), strconv.Quote(params["version"]))
+	y.WriteString("tags:\n")
+	for _, t := range tags {
+		fmt.Fprintf(y, "- name: %s\n", yamlString(t.Name))
+		if t.Description != "" {
+			fmt.Fprintf(y, "  description: %s\n", strconv.Quote(t.Description))
+		}
+	}
+	y.WriteString("paths:\n")
+	for _, p := range g.paths {
+		fmt.Fprintf(y, "  %s:\n", strconv.Quote(p.Path))
+		for _, op := range p.Operations {
+			op.writeYAML(y, "    ")
+		}
+	}
+	y.WriteString("components:\n  schemas:\n")
+	writeYAMLEntry(y, "    ", errorSchemaName, errorSchema)
+	for _, p := range schemas {
+		writeYAMLEntry(y, "    ", p.Name, p.Schema)
+	}
+
+	base := strings.TrimSuffix(filepath.Base(desc.GetName()), filepath.Ext(desc.GetName()))
+	fileName := camelCase(strings.NewReplacer("-", "_", ".", "_").Replace(base))
+	w := bytes.NewBuffer(nil)
+	if err := codeTmpl.Execute(w, map[string]string{
+		"Source":   desc.GetName(),
+		"GoPkg":    defaultGoPackageName(desc),
+		"YAMLName": base + ".openapi.yaml",
+		"Var":      "openAPI" + fileName,
+		"Name":     fileName,
+		"Title":    title,
+	}); err != nil {
+		return "", "", err
+	}
+	return w.String(), y.String(), nil
+}
+
+// errorSchemaName names the schema of the errors runtime/httpgw writes,
+// in the proto package of the options of this repo, which declares no
+// Error message.
+const errorSchemaName = "f4tq.plugins.httpgw.Error"
+
+var errorSchema = &schema{
+	Type:        "object",
+	Description: "An error, with its HTTP status as code.",
+	Properties: []*property{
+		{Name: "code", Schema: &schema{Type: "integer", Format: "int32"}},
+		{Name: "message", Schema: &schema{Type: "string"}},
+	},
+}
+
+type tag struct {
+	Name, Description string
+}
+
+// path holds the operations of a path, in the order of the methods.
+type path struct {
+	Path       string
+	Operations []*operation
+}
+
+type operation struct {
+	// Method is the HTTP method, in lower case.
+	Method      string
+	ID          string
+	Tag         string
+	Description string
+	Deprecated  bool
+	Parameters  []*parameter
+	// Body is the schema of the request body, or nil.
+	Body     *schema
+	Response *schema
+}
+
+type parameter struct {
+	Name string
+	// In is "path" or "query".
+	In          string
+	Description string
+	Required    bool
+	Deprecated  bool
+	Schema      *schema
+}
+
+// specGen builds the paths of the methods of a file, and the schemas of
+// the messages and enums they refer to, as jsonpb encodes them.
+type specGen struct {
+	idx *typeIndex
+	// docs holds the comments of the declarations.
+	docs  *protodoc.Index
+	paths []*path
+	// referenced holds the names of the messages and enums the schemas refer
+	// to.
+	referenced map[string]bool
+}
+
+// operation returns the operation of the binding b of the method m of the
+// service svcName.
+func (g *specGen) operation(svcName string, m *descriptor.MethodDescriptorProto, b *httprule.Binding) (*operation, error) {
+	in := g.idx.messages[m.GetInputType()]
+	out := g.idx.messages[m.GetOutputType()]
+	if in == nil || out == nil {
+		return nil, fmt.Errorf("unknown message %s or %s", m.GetInputType(), m.GetOutputType())
+	}
+	op := &operation{
+		Method:      strings.ToLower(b.Method),
+		Tag:         strings.TrimPrefix(svcName, "."),
+		Description: g.docs.Comment(svcName + "." + m.GetName()),
+		Deprecated:  m.GetOptions().GetDeprecated(),
+	}
+
+	bound := make(map[string]bool)
+	for _, v := range b.Pattern.Vars() {
+		typeName, field, err := g.resolve(m.GetInputType(), strings.Split(v, "."))
+		if err != nil {
+			return nil, err
+		}
+		s, err := g.value(field, options.Rules(field))
+		if err != nil {
+			return nil, err
+		}
+		op.Parameters = append(op.Parameters, &parameter{
+			Name:        v,
+			In:          "path",
+			Description: g.docs.Comment(typeName + "." + field.GetName()),
+			Required:    true,
+			Deprecated:  field.GetOptions().GetDeprecated(),
+			Schema:      s,
+		})
+		bound[strings.Split(v, ".")[0]] = true
+	}
+
+	switch b.Body {
+	case "":
+	case "*":
+		op.Body = g.ref(m.GetInputType())
+	default:
+		field := findField(in, b.Body)
+		if field == nil {
+			return nil, fmt.Errorf("%s has no field %q", m.GetInputType(), b.Body)
+		}
+		s, err := g.field(field)
+		if err != nil {
+			return nil, err
+		}
+		op.Body = s
+		bound[b.Body] = true
+	}
+
+	// The fields bound neither to the path nor to the body are read from
+	// the query, as protoc-gen-go-httpgateway does for the scalar ones.
+	if b.Body != "*" {
+		for _, field := range in.GetField() {
+			if bound[field.GetName()] || field.GetType() == descriptor.FieldDescriptorProto_TYPE_MESSAGE ||
+				field.GetType() == descriptor.FieldDescriptorProto_TYPE_GROUP {
+				continue
+			}
+			s, err := g.field(field)
+			if err != nil {
+				return nil, err
+			}
+			op.Parameters = append(op.Parameters, &parameter{
+				Name:        field.GetName(),
+				In:          "query",
+				Description: g.docs.Comment(m.GetInputType() + "." + field.GetName()),
+				Required:    options.Rules(field).GetRequired(),
+				Deprecated:  field.GetOptions().GetDeprecated(),
+				Schema:      s,
+			})
+		}
+	}
+
+	if b.ResponseBody == "" {
+		op.Response = g.ref(m.GetOutputType())
+	} else {
+		field := findField(out, b.ResponseBody)
+		if field == nil {
+			return nil, fmt.Errorf("%s has no field %q", m.GetOutputType(), b.ResponseBody)
+		}
+		s, err := g.field(field)
+		if err != nil {
+			return nil, err
+		}
+		op.Response = s
+	}
+	return op, nil
+}
+
+// resolve returns the field at path from the message typeName, and the
+// name of the message declaring it.
+func (g *specGen) resolve(typeName string, path []string) (string, *descriptor.FieldDescriptorProto, error) {
+	for i, name := range path {
+		field := findField(g.idx.messages[typeName], name)
+		if field == nil {
+			return "", nil, fmt.Errorf("%s has no field %q", typeName, name)
+		}
+		if i == len(path)-1 {
+			return typeName, field, nil
+		}
+		if field.GetType() != descriptor.FieldDescriptorProto_TYPE_MESSAGE {
+			return "", nil, fmt.Errorf("field %q cannot be traversed", strings.Join(path[:i+1], "."))
+		}
+		typeName = field.GetTypeName()
+	}
+	return "", nil, fmt.Errorf("empty field path")
+}
+
+// add adds op to the operations of the OpenAPI path p.
+func (g *specGen) add(p string, op *operation) error {
+	for _, existing := range g.paths {
+		if existing.Path != p {
+			continue
+		}
+		for _, o := range existing.Operations {
+			if o.Method == op.Method {
+				return fmt.Errorf("%s %s is also bound by %s", strings.ToUpper(op.Method), p, o.ID)
+			}
+		}
+		existing.Operations = append(existing.Operations, op)
+		return nil
+	}
+	g.paths = append(g.paths, &path{Path: p, Operations: []*operation{op}})
+	return nil
+}
+
+// ref returns the schema referring to the message or enum typeName, or the
+// schema of typeName if it is a well-known type.
+func (g *specGen) ref(typeName string) *schema {
+	if wkt, ok := wellKnownSchemas[typeName]; ok {
+		return wkt()
+	}
+	g.referenced[typeName] = true
+	return &schema{Ref: strings.TrimPrefix(typeName, ".")}
+}
+
+// schemas returns the schemas of the messages and enums referred to,
+// directly or not, sorted by name.
+func (g *specGen) schemas() ([]*property, error) {
+	var schemas []*property
+	done := make(map[string]bool)
+	for {
+		var pending []string
+		for typeName := range g.referenced {
+			if !done[typeName] {
+				pending = append(pending, typeName)
+			}
+		}
+		if len(pending) == 0 {
+			break
+		}
+		sort.Strings(pending)
+		for _, typeName := range pending {
+			done[typeName] = true
+			s, err := g.typeSchema(typeName)
+			if err != nil {
+				return nil, err
+			}
+			schemas = append(schemas, &property{Name: strings.TrimPrefix(typeName, "."), Schema: s})
+		}
+	}
+	sort.Slice(schemas, func(i, j int) bool { return schemas[i].Name < schemas[j].Name })
+	return schemas, nil
+}
+
+// typeSchema returns the schema of the message or enum typeName.
+func (g *specGen) typeSchema(typeName string) (*schema, error) {
+	if enum, ok := g.idx.enums[typeName]; ok {
+		s := &schema{
+			Type:        "string",
+			Description: g.docs.Comment(typeName),
+			Deprecated:  enum.GetOptions().GetDeprecated(),
+		}
+		for _, v := range enum.GetValue() {
+			s.Enum = append(s.Enum, v.GetName())
+		}
+		return s, nil
+	}
+	msg, ok := g.idx.messages[typeName]
+	if !ok {
+		return nil, fmt.Errorf("unknown type %s", typeName)
+	}
+	s := &schema{
+		Type:        "object",
+		Description: g.docs.Comment(typeName),
+		Deprecated:  msg.GetOptions().GetDeprecated(),
+	}
+	for _, field := range msg.GetField() {
+		fs, err := g.field(field)
+		if err != nil {
+			return nil, fmt.Errorf("%s.%s: %v", strings.TrimPrefix(typeName, "."), field.GetName(), err)
+		}
+		fs.Description = g.docs.Comment(typeName + "." + field.GetName())
+		fs.Deprecated = field.GetOptions().GetDeprecated()
+		s.Properties = append(s.Properties, &property{Name: jsonName(field), Schema: fs})
+		if options.Rules(field).GetRequired() {
+			s.Required = append(s.Required, jsonName(field))
+		}
+	}
+	return s, nil
+}
+
+// field returns the schema of field, with its (f4tq.plugins.validate)
+// rules.
+func (g *specGen) field(field *descriptor.FieldDescriptorProto) (*schema, error) {
+	rules := options.Rules(field)
+	if rules == nil {
+		rules = &options.FieldRules{}
+	}
+	if g.idx.isMap(field) {
+		// Map values only take the item bounds, as in protoc-gen-go-validate.
+		v, err := g.value(g.idx.messages[field.GetTypeName()].GetField()[1], nil)
+		if err != nil {
+			return nil, err
+		}
+		return &schema{
+			Type:                 "object",
+			AdditionalProperties: v,
+			MinProperties:        uint32Ptr(rules.MinItems),
+			MaxProperties:        uint32Ptr(rules.MaxItems),
+		}, nil
+	}
+	if field.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REPEATED {
+		v, err := g.value(field, rules)
+		if err != nil {
+			return nil, err
+		}
+		return &schema{
+			Type:     "array",
+			Items:    v,
+			MinItems: uint32Ptr(rules.MinItems),
+			MaxItems: uint32Ptr(rules.MaxItems),
+		}, nil
+	}
+	return g.value(field, rules)
+}
+
+// value returns the schema of a value of field, bounded by rules.
+func (g *specGen) value(field *descriptor.FieldDescriptorProto, rules *options.FieldRules) (*schema, error) {
+	var s *schema
+	switch field.GetType() {
+	case descriptor.FieldDescriptorProto_TYPE_DOUBLE:
+		s = &schema{Type: "number", Format: "double"}
+	case descriptor.FieldDescriptorProto_TYPE_FLOAT:
+		s = &schema{Type: "number", Format: "float"}
+	case descriptor.FieldDescriptorProto_TYPE_INT32,
+		descriptor.FieldDescriptorProto_TYPE_SINT32,
+		descriptor.FieldDescriptorProto_TYPE_SFIXED32:
+		s = &schema{Type: "integer", Format: "int32"}
+	case descriptor.FieldDescriptorProto_TYPE_UINT32,
+		descriptor.FieldDescriptorProto_TYPE_FIXED32:
+		s = &schema{Type: "integer", Format: "uint32"}
+	case descriptor.FieldDescriptorProto_TYPE_INT64,
+		descriptor.FieldDescriptorProto_TYPE_SINT64,
+		descriptor.FieldDescriptorProto_TYPE_SFIXED64:
+		// jsonpb writes 64-bit integers as strings, which the numeric
+		// bounds do not apply to.
+		return &schema{Type: "string", Format: "int64"}, nil
+	case descriptor.FieldDescriptorProto_TYPE_UINT64,
+		descriptor.FieldDescriptorProto_TYPE_FIXED64:
+		return &schema{Type: "string", Format: "uint64"}, nil
+	case descriptor.FieldDescriptorProto_TYPE_BOOL:
+		s = &schema{Type: "boolean"}
+	case descriptor.FieldDescriptorProto_TYPE_STRING:
+		s = &schema{Type: "string"}
+	case descriptor.FieldDescriptorProto_TYPE_BYTES:
+		s = &schema{Type: "string", Format: "byte"}
+	case descriptor.FieldDescriptorProto_TYPE_ENUM:
+		return g.ref(field.GetTypeName()), nil
+	case descriptor.FieldDescriptorProto_TYPE_MESSAGE:
+		if _, ok := wellKnownSchemas[field.GetTypeName()]; !ok {
+			if _, ok := g.idx.messages[field.GetTypeName()]; !ok {
+				return nil, fmt.Errorf("unknown message %s", field.GetTypeName())
+			}
+		}
+		return g.ref(field.GetTypeName()), nil
+	default:
+		return nil, fmt.Errorf("groups are not supported")
+	}
+	if rules == nil {
+		return s, nil
+	}
+	// Lengths count the characters of strings; those of bytes, encoded in
+	// base64, are left to the generated Validate methods.
+	if s.Type == "string" && s.Format == "" {
+		s.MinLength = uint32Ptr(rules.MinLen)
+		s.MaxLength = uint32Ptr(rules.MaxLen)
+		s.Pattern = rules.GetPattern()
+	}
+	if s.Type == "number" || s.Type == "integer" {
+		switch {
+		case rules.Gt != nil:
+			s.Minimum, s.ExclusiveMinimum = rules.Gt, true
+		case rules.Gte != nil:
+			s.Minimum = rules.Gte
+		}
+		switch {
+		case rules.Lt != nil:
+			s.Maximum, s.ExclusiveMaximum = rules.Lt, true
+		case rules.Lte != nil:
+			s.Maximum = rules.Lte
+		}
+	}
+	return s, nil
+}
+
+// wellKnownSchemas are the schemas of the well-known types, which jsonpb
+// encodes specially.
+var wellKnownSchemas = map[string]func() *schema{
+	".google.protobuf.Timestamp": func() *schema { return &schema{Type: "string", Format: "date-time"} },
+	".google.protobuf.Duration": func() *schema {
+		return &schema{Type: "string", Pattern: `^-?[0-9]+(\.[0-9]+)?s$`}
+	},
+	".google.protobuf.FieldMask": func() *schema { return &schema{Type: "string"} },
+	".google.protobuf.Empty":     func() *schema { return &schema{Type: "object"} },
+	".google.protobuf.Struct":    func() *schema { return &schema{Type: "object", AnyProperties: true} },
+	".google.protobuf.Value":     func() *schema { return &schema{} },
+	".google.protobuf.ListValue": func() *schema { return &schema{Type: "array", Items: &schema{}} },
+	".google.protobuf.Any": func() *schema {
+		return &schema{
+			Type:          "object",
+			Properties:    []*property{{Name: "@type", Schema: &schema{Type: "string"}}},
+			Required:      []string{"@type"},
+			AnyProperties: true,
+		}
+	},
+	".google.protobuf.DoubleValue": func() *schema { return &schema{Type: "number", Format: "double", Nullable: true} },
+	".google.protobuf.FloatValue":  func() *schema { return &schema{Type: "number", Format: "float", Nullable: true} },
+	".google.protobuf.Int64Value":  func() *schema { return &schema{Type: "string", Format: "int64", Nullable: true} },
+	".google.protobuf.UInt64Value": func() *schema { return &schema{Type: "string", Format: "uint64", Nullable: true} },
+	".google.protobuf.Int32Value":  func() *schema { return &schema{Type: "integer", Format: "int32", Nullable: true} },
+	".google.protobuf.UInt32Value": func() *schema { return &schema{Type: "integer", Format: "uint32", Nullable: true} },
+	".google.protobuf.BoolValue":   func() *schema { return &schema{Type: "boolean", Nullable: true} },
+	".google.protobuf.StringValue": func() *schema { return &schema{Type: "string", Nullable: true} },
+	".google.protobuf.BytesValue":  func() *schema { return &schema{Type: "string", Format: "byte", Nullable: true} },
+}
+
+// writeYAML writes op as the entry of its method in a path item indented
+// by indent.
+func (op *operation) writeYAML(w *bytes.Buffer, indent string) {
+	fmt.Fprintf(w, "%s%s:\n", indent, op.Method)
+	indent += "  "
+	fmt.Fprintf(w, "%stags:\n%s- %s\n", indent, indent, yamlString(op.Tag))
+	fmt.Fprintf(w, "%soperationId: %s\n", indent, yamlString(op.ID))
+	if op.Description != "" {
+		fmt.Fprintf(w, "%sdescription: %s\n", indent, strconv.Quote(op.Description))
+	}
+	if op.Deprecated {
+		fmt.Fprintf(w, "%sdeprecated: true\n", indent)
+	}
+	if len(op.Parameters) > 0 {
+		fmt.Fprintf(w, "%sparameters:\n", indent)
+		for _, p := range op.Parameters {
+			fmt.Fprintf(w, "%s- name: %s\n", indent, yamlString(p.Name))
+			fmt.Fprintf(w, "%s  in: %s\n", indent, p.In)
+			if p.Description != "" {
+				fmt.Fprintf(w, "%s  description: %s\n", indent, strconv.Quote(p.Description))
+			}
+			if p.Required {
+				fmt.Fprintf(w, "%s  required: true\n", indent)
+			}
+			if p.Deprecated {
+				fmt.Fprintf(w, "%s  deprecated: true\n", indent)
+			}
+			writeYAMLEntry(w, indent+"  ", "schema", p.Schema)
+		}
+	}
+	if op.Body != nil {
+		fmt.Fprintf(w, "%srequestBody:\n%s  required: true\n%s  content:\n%s    application/json:\n", indent, indent, indent, indent)
+		writeYAMLEntry(w, indent+"      ", "schema", op.Body)
+	}
+	fmt.Fprintf(w, "%sresponses:\n", indent)
+	fmt.Fprintf(w, "%s  \"200\":\n%s    description: OK\n%s    content:\n%s      application/json:\n", indent, indent, indent, indent)
+	writeYAMLEntry(w, indent+"        ", "schema", op.Response)
+	fmt.Fprintf(w, "%s  default:\n%s    description: An error.\n%s    content:\n%s      application/json:\n", indent, indent, indent, indent)
+	writeYAMLEntry(w, indent+"        ", "schema", &schema{Ref: errorSchemaName})
+}
+
+// findField returns the field of msg called name, or nil.
+func findField(msg *descriptor.DescriptorProto, name string) *descriptor.FieldDescriptorProto {
+	for _, f := range msg.GetField() {
+		if f.GetName() == name {
+			return f
+		}
+	}
+	return nil
+}
+
+// jsonName returns the JSON name of field, which protoc sets, or else the
+// lower camel case of its name.
+func jsonName(field *descriptor.FieldDescriptorProto) string {
+	if field.GetJsonName() != "" {
+		return field.GetJsonName()
+	}
+	name := camelCase(field.GetName())
+	return strings.ToLower(name[:1]) + name[1:]
+}
+
+func uint32Ptr(v *uint32) *int64 {
+	if v == nil {
+		return nil
+	}
+	n := int64(*v)
+	return &n
+}
+
+// schema is an OpenAPI 3.0 schema.
+type schema struct {
+	// Ref is the name of the schema of a message or enum in
+	// components.schemas.
+	Ref                                string
+	Type, Format                       string
+	Description                        string
+	Properties                         []*property
+	Required                           []string
+	Items, AdditionalProperties        *schema
+	AnyProperties                      bool // additionalProperties: true
+	Enum                               []string
+	Pattern                            string
+	MinLength, MaxLength               *int64
+	MinItems, MaxItems                 *int64
+	MinProperties, MaxProperties       *int64
+	Minimum, Maximum                   *float64
+	ExclusiveMinimum, ExclusiveMaximum bool
+	Nullable, Deprecated               bool
+}
+
+type property struct {
+	Name   string
+	Schema *schema
+}
+
+// writeYAMLEntry writes the entry of key and s in a YAML mapping indented
+// by indent.
+func writeYAMLEntry(w *bytes.Buffer, indent, key string, s *schema) {
+	var value bytes.Buffer
+	s.writeYAML(&value, indent+"  ")
+	if value.Len() == 0 {
+		fmt.Fprintf(w, "%s%s: {}\n", indent, yamlString(key))
+		return
+	}
+	fmt.Fprintf(w, "%s%s:\n", indent, yamlString(key))
+	w.Write(value.Bytes())
+}
+
+// writeYAML writes s as a YAML mapping indented by indent.
+func (s *schema) writeYAML(w *bytes.Buffer, indent string) {
+	line := func(key, v string) {
+		fmt.Fprintf(w, "%s%s: %s\n", indent, key, v)
+	}
+	flag := func(key string, v bool) {
+		if v {
+			line(key, "true")
+		}
+	}
+	integer := func(key string, v *int64) {
+		if v != nil {
+			line(key, strconv.FormatInt(*v, 10))
+		}
+	}
+	number := func(key string, v *float64) {
+		if v != nil {
+			line(key, strconv.FormatFloat(*v, 'g', -1, 64))
+		}
+	}
+	list := func(key string, items []string) {
+		if len(items) > 0 {
+			fmt.Fprintf(w, "%s%s:\n", indent, key)
+			for _, v := range items {
+				fmt.Fprintf(w, "%s- %s\n", indent, yamlString(v))
+			}
+		}
+	}
+	if s.Ref != "" {
+		ref := strconv.Quote("#/components/schemas/" + s.Ref)
+		if s.Description == "" && !s.Deprecated {
+			line("$ref", ref)
+			return
+		}
+		// The siblings of $ref are ignored.
+		fmt.Fprintf(w, "%sallOf:\n%s- $ref: %s\n", indent, indent, ref)
+	}
+	if s.Description != "" {
+		line("description", strconv.Quote(s.Description))
+	}
+	if s.Type != "" {
+		line("type", s.Type)
+	}
+	if s.Format != "" {
+		line("format", s.Format)
+	}
+	flag("nullable", s.Nullable)
+	flag("deprecated", s.Deprecated)
+	number("minimum", s.Minimum)
+	flag("exclusiveMinimum", s.ExclusiveMinimum)
+	number("maximum", s.Maximum)
+	flag("exclusiveMaximum", s.ExclusiveMaximum)
+	integer("minLength", s.MinLength)
+	integer("maxLength", s.MaxLength)
+	if s.Pattern != "" {
+		// Go quoting is valid in YAML double-quoted scalars.
+		line("pattern", strconv.Quote(s.Pattern))
+	}
+	integer("minItems", s.MinItems)
+	integer("maxItems", s.MaxItems)
+	integer("minProperties", s.MinProperties)
+	integer("maxProperties", s.MaxProperties)
+	list("enum", s.Enum)
+	list("required", s.Required)
+	if len(s.Properties) > 0 {
+		fmt.Fprintf(w, "%sproperties:\n", indent)
+		for _, p := range s.Properties {
+			writeYAMLEntry(w, indent+"  ", p.Name, p.Schema)
+		}
+	}
+	if s.Items != nil {
+		writeYAMLEntry(w, indent, "items", s.Items)
+	}
+	if s.AdditionalProperties != nil {
+		writeYAMLEntry(w, indent, "additionalProperties", s.AdditionalProperties)
+	}
+	flag("additionalProperties", s.AnyProperties)
+}
+
+// yamlString returns s as a YAML scalar: plain if it cannot be read as
+// anything but that string, and double-quoted otherwise.
+func yamlString(s string) string {
+	switch strings.ToLower(s) {
+	case "y", "n", "yes", "no", "on", "off", "true", "false", "null":
+		return strconv.Quote(s)
+	}
+	if plainKey.MatchString(s) {
+		return s
+	}
+	return strconv.Quote(s)
+}
+
+// typeIndex maps fully-qualified proto type names (".pkg.Msg") to their
+// descriptors and declaring files for every file in the request.
+type typeIndex struct {
+	messages map[string]*descriptor.DescriptorProto
+	enums    map[string]*descriptor.EnumDescriptorProto
+	files    map[string]*descriptor.FileDescriptorProto
+}
+
+func newTypeIndex(files []*descriptor.FileDescriptorProto) *typeIndex {
+	idx := &typeIndex{
+		messages: make(map[string]*descriptor.DescriptorProto),
+		enums:    make(map[string]*descriptor.EnumDescriptorProto),
+		files:    make(map[string]*descriptor.FileDescriptorProto),
+	}
+	for _, f := range files {
+		prefix := ""
+		if f.GetPackage() != "" {
+			prefix = "." + f.GetPackage()
+		}
+		idx.addMessages(f, prefix, f.GetMessageType())
+		idx.addEnums(f, prefix, f.GetEnumType())
+	}
+	return idx
+}
+
+func (idx *typeIndex) addMessages(f *descriptor.FileDescriptorProto, prefix string, msgs []*descriptor.DescriptorProto) {
+	for _, m := range msgs {
+		name := prefix + "." + m.GetName()
+		idx.messages[name] = m
+		idx.files[name] = f
+		idx.addMessages(f, name, m.GetNestedType())
+		idx.addEnums(f, name, m.GetEnumType())
+	}
+}
+
+func (idx *typeIndex) addEnums(f *descriptor.FileDescriptorProto, prefix string, enums []*descriptor.EnumDescriptorProto) {
+	for _, e := range enums {
+		name := prefix + "." + e.GetName()
+		idx.enums[name] = e
+		idx.files[name] = f
+	}
+}
+
+// isMap reports whether field is a map field.
+func (idx *typeIndex) isMap(field *descriptor.FieldDescriptorProto) bool {
+	if field.GetType() != descriptor.FieldDescriptorProto_TYPE_MESSAGE {
+		return false
+	}
+	return idx.messages[field.GetTypeName()].GetOptions().GetMapEntry()
+}
+
+// camelCase returns the Go field name protoc-gen-go derives from s.
+func camelCase(s string) string {
+	if s == "" {
+		return ""
+	}
+	t := make([]byte, 0, 32)
+	i := 0
+	if s[0] == '_' {
+		// Need a capital letter; drop the '_'.
+		t = append(t, 'X')
+		i++
+	}
+	for ; i < len(s); i++ {
+		c := s[i]
+		if c == '_' && i+1 < len(s) && isASCIILower(s[i+1]) {
+			continue
+		}
+		if isASCIIDigit(c) {
+			t = append(t, c)
+			continue
+		}
+		if isASCIILower(c) {
+			c ^= ' '
+		}
+		t = append(t, c)
+		for i+1 < len(s) && isASCIILower(s[i+1]) {
+			i++
+			t = append(t, s[i])
+		}
+	}
+	return string(t)
+}
+
+func isASCIILower(c byte) bool {
+	return 'a' <= c && c <= 'z'
+}
+
+func isASCIIDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+// parseParams splits the comma separated key=value plugin parameter.
+func parseParams(param string) map[string]string {
+	params := make(map[string]string)
+	for _, p := range strings.Split(param, ",") {
+		if p == "" {
+			continue
+		}
+		if i := strings.IndexByte(p, '='); i >= 0 {
+			params[p[:i]] = p[i+1:]
+		} else {
+			params[p] = ""
+		}
+	}
+	return params
+}
+
+// sanitizePackageName replaces unallowed character in package name
+// with allowed character.
+func sanitizePackageName(pkgName string) string {
+	pkgName = strings.Replace(pkgName, ".", "_", -1)
+	pkgName = strings.Replace(pkgName, "-", "_", -1)
+	return pkgName
+}
+
+// defaultGoPackageName returns the default go package name to be used for go files generated from "f".
+// You might need to use an unique alias for the package when you import it.  Use ReserveGoPackageAlias to get a unique alias.
+func defaultGoPackageName(f *descriptor.FileDescriptorProto) string {
+	name := packageIdentityName(f)
+	return sanitizePackageName(name)
+}
+
+// packageIdentityName returns the identity of packages.
+// protoc-gen-grpc-gateway rejects CodeGenerationRequests which contains more than one packages
+// as protoc-gen-go does.
+func packageIdentityName(f *descriptor.FileDescriptorProto) string {
+	if f.Options != nil && f.Options.GoPackage != nil {
+		gopkg := f.Options.GetGoPackage()
+		idx := strings.LastIndex(gopkg, "/")
+		if idx < 0 {
+			gopkg = gopkg[idx+1:]
+		}
+
+		gopkg = gopkg[idx+1:]
+		// package name is overrided with the string after the
+		// ';' character
+		sc := strings.IndexByte(gopkg, ';')
+		if sc < 0 {
+			return sanitizePackageName(gopkg)
+
+		}
+		return sanitizePackageName(gopkg[sc+1:])
+	}
+
+	if f.Package == nil {
+		base := filepath.Base(f.GetName())
+		ext := filepath.Ext(base)
+		return strings.TrimSuffix(base, ext)
+	}
+	return f.GetPackage()
+}
+
+func emitFiles(out []*plugin.CodeGeneratorResponse_File) {
+	emitResp(&plugin.CodeGeneratorResponse{File: out})
+}
+
+func emitError(err error) {
+	emitResp(&plugin.CodeGeneratorResponse{Error: proto.String(err.Error())})
+}
+
+func emitResp(resp *plugin.CodeGeneratorResponse) {
+	buf, err := proto.Marshal(resp)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if _, err := os.Stdout.Write(buf); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/f4tq/protoc-go-plugins/internal/plugintest"
+)
+
+const commentedProto = `
+syntax = "proto3";
+
+package doc.v1;
+
+option go_package = "example.com/doc/v1;docv1";
+
+import "google/api/annotations.proto";
+
+// Doc is a document.
+message Doc {
+    // title names the document.
+    string title = 1; // Shown in lists.
+}
+
+// Docs serves documents.
+service Docs {
+    // GetDoc returns a document.
+    rpc GetDoc(Doc) returns (Doc) {
+        option (google.api.http) = {get: "/v1/{title}"};
+    }
+}
+`
+
+// TestComments checks the operations, parameters and schemas carry the
+// leading and trailing comments of the declarations.
+func TestComments(t *testing.T) {
+	req := plugintest.Request(t, "", map[string]string{"doc/v1/doc.proto": commentedProto})
+	got := plugintest.Generate(t, req, generate)["doc/v1/doc.openapi.yaml"]
+	for _, want := range []string{
+		`description: "Docs serves documents."`,
+		`description: "GetDoc returns a document."`,
+		`description: "title names the document.\n\nShown in lists."`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("the document does not contain %q:\n%s", want, got)
+		}
+	}
+}
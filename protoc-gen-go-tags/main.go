@@ -0,0 +1,382 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+
+	"github.com/f4tq/protoc-go-plugins/goname"
+	"github.com/f4tq/protoc-go-plugins/options"
+)
+
+func main() {
+	input, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	req := new(plugin.CodeGeneratorRequest)
+	if err = proto.Unmarshal(input, req); err != nil {
+		log.Fatal(err)
+	}
+
+	out, err := generate(req)
+
+	if err != nil {
+		emitError(err)
+		return
+	}
+
+	emitFiles(out)
+}
+
+// generate post-processes the protoc-gen-go output, which declares no
+// insertion points in its structs: it reads the .pb.go files written by an
+// earlier protoc run with paths=source_relative from the outdir parameter,
+// "." by default, and emits them again with the (f4tq.plugins.tags) struct
+// tags added. It thus runs in a protoc invocation of its own:
+//
+//	protoc --go_out=paths=source_relative:. example/v1/user.proto
+//	protoc --go-tags_out=outdir=.:. example/v1/user.proto
+//
+// The xxx_skip parameter, keys separated by "+" such as xxx_skip=yaml+bson,
+// also tags the XXX_ fields of the messages with key:"-" so that encoders
+// of those formats leave them out.
+func generate(req *plugin.CodeGeneratorRequest) ([]*plugin.CodeGeneratorResponse_File, error) {
+	var files []*plugin.CodeGeneratorResponse_File
+	params := parseParams(req.GetParameter())
+	outdir := params["outdir"]
+	if outdir == "" {
+		outdir = "."
+	}
+	var skip []string
+	if s := params["xxx_skip"]; s != "" {
+		skip = strings.Split(s, "+")
+		for _, key := range skip {
+			if err := checkKey(key); err != nil {
+				return nil, fmt.Errorf("xxx_skip: %v", err)
+			}
+		}
+	}
+	genFileNames := make(map[string]bool)
+	for _, n := range req.FileToGenerate {
+		genFileNames[n] = true
+	}
+	for _, desc := range req.GetProtoFile() {
+		name := desc.GetName()
+		if _, ok := genFileNames[name]; !ok {
+			// Only emit output for files present in req.FileToGenerate.
+			continue
+		}
+		tags := make(map[string]map[string][]tagPair)
+		if err := fileTags(tags, "", desc.GetMessageType()); err != nil {
+			return nil, fmt.Errorf("%s: %v", name, err)
+		}
+		if len(tags) == 0 && len(skip) == 0 {
+			// The file has nothing to tag.
+			continue
+		}
+
+		ext := filepath.Ext(name)
+		base := strings.TrimSuffix(name, ext)
+		output := fmt.Sprintf("%s.pb.go", base)
+		src, err := ioutil.ReadFile(filepath.Join(outdir, output))
+		if err != nil {
+			return nil, fmt.Errorf("%v: run protoc-gen-go with paths=source_relative first", err)
+		}
+		code, err := inject(output, src, tags, skip)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, &plugin.CodeGeneratorResponse_File{
+			Name:    proto.String(output),
+			Content: proto.String(code),
+		})
+	}
+
+	return files, nil
+}
+
+// fileTags records in tags the struct tags to add to the Go fields of msgs
+// and of the messages nested in them, by Go type and field name. prefix is
+// the Go name of the enclosing message plus "_".
+func fileTags(tags map[string]map[string][]tagPair, prefix string, msgs []*descriptor.DescriptorProto) error {
+	for _, msg := range msgs {
+		if msg.GetOptions().GetMapEntry() {
+			continue
+		}
+		name := prefix + msg.GetName()
+		for _, field := range msg.GetField() {
+			s := options.Tags(field)
+			if s == "" {
+				continue
+			}
+			pairs, err := parseTag(s)
+			if err != nil {
+				return fmt.Errorf("%s.%s: bad tags %q: %v", name, field.GetName(), s, err)
+			}
+			for _, p := range pairs {
+				if err := checkKey(p.key); err != nil {
+					return fmt.Errorf("%s.%s: %v", name, field.GetName(), err)
+				}
+			}
+			goType, goName := name, goname.Fields(msg)[field.GetName()]
+			if field.OneofIndex != nil && !field.GetProto3Optional() {
+				// The field is that of the oneof wrapper.
+				goType = name + "_" + goName
+			}
+			if tags[goType] == nil {
+				tags[goType] = make(map[string][]tagPair)
+			}
+			tags[goType][goName] = pairs
+		}
+		if err := fileTags(tags, name+"_", msg.GetNestedType()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// inject returns src, the protoc-gen-go output name, with tags added to
+// its struct fields and the skip keys to their XXX_ fields.
+func inject(name string, src []byte, tags map[string]map[string][]tagPair, skip []string) (string, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, name, src, parser.ParseComments)
+	if err != nil {
+		return "", err
+	}
+	found := 0
+	ast.Inspect(f, func(n ast.Node) bool {
+		if err != nil {
+			return false
+		}
+		spec, ok := n.(*ast.TypeSpec)
+		if !ok {
+			return true
+		}
+		st, ok := spec.Type.(*ast.StructType)
+		if !ok {
+			return false
+		}
+		for _, field := range st.Fields.List {
+			if len(field.Names) != 1 {
+				continue
+			}
+			goName := field.Names[0].Name
+			var add []tagPair
+			if strings.HasPrefix(goName, "XXX_") {
+				for _, key := range skip {
+					add = append(add, tagPair{key: key, value: `"-"`})
+				}
+			}
+			if pairs, ok := tags[spec.Name.Name][goName]; ok {
+				add = append(add, pairs...)
+				found++
+			}
+			if len(add) == 0 {
+				continue
+			}
+			var old string
+			if field.Tag == nil {
+				field.Tag = &ast.BasicLit{ValuePos: field.Type.End(), Kind: token.STRING}
+			} else if old, err = strconv.Unquote(field.Tag.Value); err != nil {
+				err = fmt.Errorf("%s: %v", fset.Position(field.Tag.Pos()), err)
+				return false
+			}
+			tag, perr := mergeTags(old, add)
+			if perr != nil {
+				err = fmt.Errorf("%s: %s: %v", fset.Position(field.Pos()), goName, perr)
+				return false
+			}
+			if strings.ContainsRune(tag, '`') {
+				field.Tag.Value = strconv.Quote(tag)
+			} else {
+				field.Tag.Value = "`" + tag + "`"
+			}
+		}
+		return false
+	})
+	if err != nil {
+		return "", err
+	}
+	want := 0
+	for _, fields := range tags {
+		want += len(fields)
+	}
+	if found != want {
+		return "", fmt.Errorf("%s: %d of the %d tagged fields not found: is it the protoc-gen-go output of its proto file?", name, want-found, want)
+	}
+
+	var buf bytes.Buffer
+	if err := format.Node(&buf, fset, f); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// tagPair is a key of a struct tag and its value, quoted.
+type tagPair struct {
+	key, value string
+}
+
+// parseTag splits the struct tag s into its key and value pairs, following
+// the conventional syntax of reflect.StructTag.
+func parseTag(s string) ([]tagPair, error) {
+	var pairs []tagPair
+	for {
+		s = strings.TrimLeft(s, " ")
+		if s == "" {
+			return pairs, nil
+		}
+		i := 0
+		for i < len(s) && s[i] > ' ' && s[i] != ':' && s[i] != '"' && s[i] != 0x7f {
+			i++
+		}
+		if i == 0 || i+1 >= len(s) || s[i] != ':' || s[i+1] != '"' {
+			return nil, fmt.Errorf("want key:\"value\" at %q", s)
+		}
+		key := s[:i]
+		s = s[i+1:]
+		i = 1
+		for i < len(s) && s[i] != '"' {
+			if s[i] == '\\' {
+				i++
+			}
+			i++
+		}
+		if i >= len(s) {
+			return nil, fmt.Errorf("unterminated value of %s", key)
+		}
+		value := s[:i+1]
+		s = s[i+1:]
+		if _, err := strconv.Unquote(value); err != nil {
+			return nil, fmt.Errorf("bad value of %s: %v", key, err)
+		}
+		pairs = append(pairs, tagPair{key: key, value: value})
+	}
+}
+
+// checkKey rejects the keys of the protobuf runtime, which must not be
+// replaced.
+func checkKey(key string) error {
+	if strings.HasPrefix(key, "protobuf") {
+		return fmt.Errorf("the %s tag key is reserved to protoc-gen-go", key)
+	}
+	return nil
+}
+
+// mergeTags returns the struct tag old with the pairs of add, which
+// replace those of the same key.
+func mergeTags(old string, add []tagPair) (string, error) {
+	pairs, err := parseTag(old)
+	if err != nil {
+		return "", err
+	}
+Add:
+	for _, a := range add {
+		for i := range pairs {
+			if pairs[i].key == a.key {
+				pairs[i] = a
+				continue Add
+			}
+		}
+		pairs = append(pairs, a)
+	}
+	parts := make([]string, len(pairs))
+	for i, p := range pairs {
+		parts[i] = p.key + ":" + p.value
+	}
+	return strings.Join(parts, " "), nil
+}
+
+// parseParams splits the comma separated key=value plugin parameter.
+func parseParams(param string) map[string]string {
+	params := make(map[string]string)
+	for _, p := range strings.Split(param, ",") {
+		if p == "" {
+			continue
+		}
+		if i := strings.IndexByte(p, '='); i >= 0 {
+			params[p[:i]] = p[i+1:]
+		} else {
+			params[p] = ""
+		}
+	}
+	return params
+}
+
+// sanitizePackageName replaces unallowed character in package name
+// with allowed character.
+func sanitizePackageName(pkgName string) string {
+	pkgName = strings.Replace(pkgName, ".", "_", -1)
+	pkgName = strings.Replace(pkgName, "-", "_", -1)
+	return pkgName
+}
+
+// defaultGoPackageName returns the default go package name to be used for go files generated from "f".
+// You might need to use an unique alias for the package when you import it.  Use ReserveGoPackageAlias to get a unique alias.
+func defaultGoPackageName(f *descriptor.FileDescriptorProto) string {
+	name := packageIdentityName(f)
+	return sanitizePackageName(name)
+}
+
+// packageIdentityName returns the identity of packages.
+// protoc-gen-grpc-gateway rejects CodeGenerationRequests which contains more than one packages
+// as protoc-gen-go does.
+func packageIdentityName(f *descriptor.FileDescriptorProto) string {
+	if f.Options != nil && f.Options.GoPackage != nil {
+		gopkg := f.Options.GetGoPackage()
+		idx := strings.LastIndex(gopkg, "/")
+		if idx < 0 {
+			gopkg = gopkg[idx+1:]
+		}
+
+		gopkg = gopkg[idx+1:]
+		// package name is overrided with the string after the
+		// ';' character
+		sc := strings.IndexByte(gopkg, ';')
+		if sc < 0 {
+			return sanitizePackageName(gopkg)
+
+		}
+		return sanitizePackageName(gopkg[sc+1:])
+	}
+
+	if f.Package == nil {
+		base := filepath.Base(f.GetName())
+		ext := filepath.Ext(base)
+		return strings.TrimSuffix(base, ext)
+	}
+	return f.GetPackage()
+}
+
+func emitFiles(out []*plugin.CodeGeneratorResponse_File) {
+	emitResp(&plugin.CodeGeneratorResponse{File: out})
+}
+
+func emitError(err error) {
+	emitResp(&plugin.CodeGeneratorResponse{Error: proto.String(err.Error())})
+}
+
+func emitResp(resp *plugin.CodeGeneratorResponse) {
+	buf, err := proto.Marshal(resp)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if _, err := os.Stdout.Write(buf); err != nil {
+		log.Fatal(err)
+	}
+}
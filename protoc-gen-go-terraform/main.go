@@ -0,0 +1,780 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+
+	"github.com/f4tq/protoc-go-plugins/goname"
+	"github.com/f4tq/protoc-go-plugins/options"
+	"github.com/f4tq/protoc-go-plugins/protodoc"
+)
+
+var (
+	hdrTmpl = template.Must(template.New("header").Parse(`
+// Code generated by protoc-gen-go-terraform. DO NOT EDIT.
+// source: {{.Source}}
+
+package {{.GoPkg}}
+
+import (
+{{- if .Fmt}}
+    "fmt"
+
+{{end}}
+{{- if .Tfconv}}
+    "github.com/f4tq/protoc-go-plugins/runtime/tfconv"
+{{- end}}
+    "github.com/hashicorp/terraform-plugin-framework/resource/schema"
+{{- if .Types}}
+    "github.com/hashicorp/terraform-plugin-framework/types"
+{{- end}}
+{{- range $path, $name := .Imports}}
+    {{$name}} "{{$path}}"
+{{- end}}
+)
+`))
+
+	modelTmpl = template.Must(template.New("model").Parse(`
+// {{.Name}}Model is the Terraform state of {{.FullName}}.{{.Doc}}
+type {{.Name}}Model struct {
+{{- range .Fields}}
+    {{.GoName}} {{.ModelType}} ` + "`" + `tfsdk:"{{.Attr}}"` + "`" + `
+{{- end}}
+}
+
+// {{.Name}}TerraformAttributes returns the schema attributes of
+// {{.Name}}Model.{{.Doc}}
+func {{.Name}}TerraformAttributes() map[string]schema.Attribute {
+    return map[string]schema.Attribute{
+{{- range .Fields}}
+        {{printf "%q" .Attr}}: {{.Schema}},
+{{- end}}
+    }
+}
+
+// {{.Name}}ToTerraform returns the Terraform state of msg.{{.Doc}}
+func {{.Name}}ToTerraform(msg *{{.GoType}}) *{{.Name}}Model {
+    m := new({{.Name}}Model)
+{{- range .Fields}}
+    {{.To}}
+{{- end}}
+    return m
+}
+
+// {{.Name}}FromTerraform returns the {{.Name}} of the Terraform state m.
+// The fields of null and unknown attributes are left unset.{{.Doc}}
+func {{.Name}}FromTerraform(m *{{.Name}}Model) (*{{.GoType}}, error) {
+    msg := new({{.GoType}})
+{{- range .Fields}}
+    {{.From}}
+{{- end}}
+    return msg, nil
+}
+{{- if .Resource}}
+
+// {{.Name}}TerraformTypeName is the type name of the {{.Name}} resource,
+// without the provider's prefix.{{.Doc}}
+const {{.Name}}TerraformTypeName = {{printf "%q" .Resource}}
+
+// {{.Name}}TerraformSchema returns the schema of the {{.Name}} resource.{{.Doc}}
+func {{.Name}}TerraformSchema() schema.Schema {
+    return schema.Schema{
+{{- if .Description}}
+        MarkdownDescription: {{printf "%q" .Description}},
+{{- end}}
+        Attributes: {{.Name}}TerraformAttributes(),
+    }
+}
+{{- end}}
+`))
+)
+
+func main() {
+	input, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	req := new(plugin.CodeGeneratorRequest)
+	if err = proto.Unmarshal(input, req); err != nil {
+		log.Fatal(err)
+	}
+
+	out, err := generate(req)
+
+	if err != nil {
+		emitError(err)
+		return
+	}
+
+	emitFiles(out)
+}
+
+func generate(req *plugin.CodeGeneratorRequest) ([]*plugin.CodeGeneratorResponse_File, error) {
+	g := &tfGen{
+		idx:      newTypeIndex(req.GetProtoFile()),
+		docs:     protodoc.New(req.GetProtoFile()),
+		gen:      make(map[string]bool),
+		out:      make(map[*descriptor.FileDescriptorProto]*output),
+		models:   make(map[string]*model),
+		visiting: make(map[string]bool),
+	}
+	for _, n := range req.FileToGenerate {
+		g.gen[n] = true
+	}
+	var gen []*descriptor.FileDescriptorProto
+	for _, desc := range req.GetProtoFile() {
+		if !g.gen[desc.GetName()] {
+			// Only emit output for files present in req.FileToGenerate.
+			continue
+		}
+		gen = append(gen, desc)
+		g.out[desc] = &output{
+			hdr:     &header{Source: desc.GetName(), GoPkg: defaultGoPackageName(desc)},
+			imports: newImportSet(desc),
+		}
+	}
+	// The models of all the files are generated first, as those of the
+	// messages a resource is composed of go to the files declaring them.
+	for _, desc := range gen {
+		g.file = desc
+		scope := strings.TrimSuffix("."+desc.GetPackage(), ".")
+		if err := g.resources(scope, desc.GetMessageType()); err != nil {
+			return nil, fmt.Errorf("%s: %v", desc.GetName(), err)
+		}
+	}
+
+	var files []*plugin.CodeGeneratorResponse_File
+	for _, desc := range gen {
+		name := desc.GetName()
+		code, err := genCode(g.out[desc])
+		if err != nil {
+			return nil, err
+		}
+		if code == "" {
+			// No message is a (f4tq.plugins.terraform_resource), nor
+			// composes one.
+			continue
+		}
+		formatted, err := format.Source([]byte(code))
+		if err != nil {
+			log.Printf("%v: %s", err, code)
+			return nil, err
+		}
+
+		ext := filepath.Ext(name)
+		base := strings.TrimSuffix(name, ext)
+		output := fmt.Sprintf("%s.pb.terraform.go", base)
+		files = append(files, &plugin.CodeGeneratorResponse_File{
+			Name:    proto.String(output),
+			Content: proto.String(string(formatted)),
+		})
+	}
+
+	return files, nil
+}
+
+func genCode(out *output) (string, error) {
+	if len(out.models) == 0 {
+		return "", nil
+	}
+	out.hdr.Imports = out.imports.names
+	w := bytes.NewBuffer(nil)
+	if err := hdrTmpl.Execute(w, out.hdr); err != nil {
+		return "", err
+	}
+	for _, m := range out.models {
+		if err := modelTmpl.Execute(w, m); err != nil {
+			return "", err
+		}
+	}
+	return w.String(), nil
+}
+
+// output is the code generated for one file.
+type output struct {
+	hdr     *header
+	imports *importSet
+	models  []*model
+}
+
+type header struct {
+	Source string
+	GoPkg  string
+	// Fmt, Tfconv and Types are set when the code uses those packages.
+	Fmt, Tfconv, Types bool
+	// Imports maps the import paths of the packages of the messages and
+	// models declared elsewhere to their names.
+	Imports map[string]string
+}
+
+type model struct {
+	Name string
+	// GoType is the Go type of the message, qualified with its package if
+	// that is not the one of the model.
+	GoType   string
+	FullName string
+	// Resource is the (f4tq.plugins.terraform_resource) of the message,
+	// "" for the messages the resources are composed of.
+	Resource    string
+	Description string
+	Fields      []*tfField
+	// Doc is the comment of the message, see protodoc.Index.Godoc.
+	Doc string
+
+	// file is the file whose output declares the model.
+	file *descriptor.FileDescriptorProto
+}
+
+type tfField struct {
+	GoName    string
+	Attr      string
+	ModelType string
+	// Schema is the schema.Attribute of the field, and To and From the
+	// statements converting it.
+	Schema, To, From string
+}
+
+type tfGen struct {
+	idx  *typeIndex
+	docs *protodoc.Index
+	// gen holds the names of the files being generated, and out their
+	// outputs.
+	gen map[string]bool
+	out map[*descriptor.FileDescriptorProto]*output
+	// models holds the models generated, keyed by message and the import
+	// path of their package, and visiting the messages being generated.
+	models   map[string]*model
+	visiting map[string]bool
+	// file is the file whose output the code being generated goes to,
+	// and syntax the syntax of the file declaring its message.
+	file   *descriptor.FileDescriptorProto
+	syntax string
+}
+
+// resources generates the models of the messages of msgs, and of the
+// messages nested in them, that are Terraform resources.
+func (g *tfGen) resources(scope string, msgs []*descriptor.DescriptorProto) error {
+	for _, msg := range msgs {
+		typeName := scope + "." + msg.GetName()
+		if options.TerraformResource(msg) != "" {
+			if _, err := g.model(typeName); err != nil {
+				return err
+			}
+		}
+		if err := g.resources(typeName, msg.GetNestedType()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// model generates the model of the message typeName, after those of the
+// messages it is composed of, and returns its name in the output of
+// g.file. The model goes to the output of the file declaring the message
+// if that is generated, or else to that of g.file. Oneof members other
+// than proto3 optional fields have no attributes.
+func (g *tfGen) model(typeName string) (string, error) {
+	name := strings.TrimPrefix(typeName, ".")
+	msg := g.idx.messages[typeName]
+	if msg == nil {
+		return "", fmt.Errorf("%s is not declared in the request", name)
+	}
+	owner := g.file
+	if decl := g.idx.files[typeName]; g.gen[decl.GetName()] {
+		owner = decl
+	}
+	key := typeName + " " + goImportPath(owner)
+	if m := g.models[key]; m != nil {
+		return g.modelName(m), nil
+	}
+	if g.visiting[typeName] {
+		return "", fmt.Errorf("%s: Terraform schemas cannot be recursive", name)
+	}
+	g.visiting[typeName] = true
+	defer delete(g.visiting, typeName)
+	file, syntax := g.file, g.syntax
+	g.file, g.syntax = owner, g.idx.files[typeName].GetSyntax()
+	defer func() { g.file, g.syntax = file, syntax }()
+	out := g.out[owner]
+	m := &model{
+		Name:        localTypeName(typeName),
+		GoType:      out.imports.goTypeName(g.idx, typeName),
+		FullName:    name,
+		Resource:    options.TerraformResource(msg),
+		Description: g.docs.Comment(typeName),
+		Doc:         g.docs.Godoc(typeName),
+		file:        owner,
+	}
+	goNames := goname.Fields(msg)
+	for _, field := range msg.GetField() {
+		if field.OneofIndex != nil && !field.GetProto3Optional() {
+			continue
+		}
+		comment := g.docs.Comment(typeName + "." + field.GetName())
+		f, err := g.field(field, goNames[field.GetName()], comment)
+		if err != nil {
+			return "", fmt.Errorf("%s.%s: %v", name, field.GetName(), err)
+		}
+		if strings.Contains(f.From, "fmt.Errorf") {
+			out.hdr.Fmt = true
+		}
+		m.Fields = append(m.Fields, f)
+	}
+	g.models[key] = m
+	out.models = append(out.models, m)
+	// The name is the one in the output of the caller.
+	g.file = file
+	return g.modelName(m), nil
+}
+
+// modelName returns the name of the model m in the output of g.file,
+// qualified with its package if it is declared in another.
+func (g *tfGen) modelName(m *model) string {
+	path := goImportPath(m.file)
+	if path == "" || path == goImportPath(g.file) {
+		return m.Name
+	}
+	pkg := defaultGoPackageName(m.file)
+	g.out[g.file].imports.names[path] = pkg
+	return pkg + "." + m.Name
+}
+
+// goType returns the Go type of the message or enum typeName in the
+// output of g.file.
+func (g *tfGen) goType(typeName string) string {
+	return g.out[g.file].imports.goTypeName(g.idx, typeName)
+}
+
+// hdr returns the header of the output of g.file.
+func (g *tfGen) hdr() *header {
+	return g.out[g.file].hdr
+}
+
+// field returns the attribute of field, whose Go name is goName, described
+// by comment.
+func (g *tfGen) field(field *descriptor.FieldDescriptorProto, goName, comment string) (*tfField, error) {
+	f := &tfField{GoName: goName, Attr: field.GetName()}
+	var flags []string
+	if comment != "" {
+		flags = append(flags, "MarkdownDescription: "+strconv.Quote(comment))
+	}
+	computed := options.TerraformComputed(field)
+	required := options.Rules(field).GetRequired()
+	switch {
+	case computed:
+		flags = append(flags, "Computed: true")
+	case required:
+		flags = append(flags, "Required: true")
+	default:
+		flags = append(flags, "Optional: true")
+	}
+	if options.Sensitive(field) {
+		flags = append(flags, "Sensitive: true")
+	}
+	if field.GetOptions().GetDeprecated() {
+		flags = append(flags, `DeprecationMessage: "Deprecated."`)
+	}
+	attrs := strings.Join(flags, ", ")
+	repeated := field.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REPEATED
+	// fail returns the statement returning the error of a conversion,
+	// naming the key k of map entries.
+	fail := func(keyed bool) string {
+		if keyed {
+			return fmt.Sprintf("if err != nil {\nreturn nil, fmt.Errorf(%q, k, err)\n}", field.GetName()+"[%q]: %v")
+		}
+		return fmt.Sprintf("if err != nil {\nreturn nil, fmt.Errorf(%q, err)\n}", field.GetName()+": %v")
+	}
+
+	if g.idx.isMap(field) {
+		entry := g.idx.messages[field.GetTypeName()]
+		if entry.GetField()[0].GetType() != descriptor.FieldDescriptorProto_TYPE_STRING {
+			return nil, fmt.Errorf("the keys of Terraform maps are strings")
+		}
+		value := entry.GetField()[1]
+		if value.GetType() == descriptor.FieldDescriptorProto_TYPE_MESSAGE && !isWellKnown(value) {
+			vn, err := g.model(value.GetTypeName())
+			if err != nil {
+				return nil, err
+			}
+			f.ModelType = "map[string]" + vn + "Model"
+			f.Schema = fmt.Sprintf("schema.MapNestedAttribute{NestedObject: schema.NestedAttributeObject{Attributes: %sTerraformAttributes()}, %s}", vn, attrs)
+			f.To = fmt.Sprintf("for k, v := range msg.Get%s() {\nif m.%s == nil {\nm.%s = make(map[string]%sModel)\n}\nm.%s[k] = *%sToTerraform(v)\n}",
+				goName, goName, goName, vn, goName, vn)
+			f.From = fmt.Sprintf("for k, e := range m.%s {\ne := e\nv, err := %sFromTerraform(&e)\n%s\nif msg.%s == nil {\nmsg.%s = make(map[string]*%s)\n}\nmsg.%s[k] = v\n}",
+				goName, vn, fail(true), goName, goName, g.goType(value.GetTypeName()), goName)
+			return f, nil
+		}
+		s, err := g.scalar(value)
+		if err != nil {
+			return nil, err
+		}
+		goType := s.goType
+		if isWellKnown(value) {
+			goType = "*" + g.goType(value.GetTypeName())
+		}
+		g.hdr().Types, g.hdr().Tfconv = true, true
+		f.ModelType = "map[string]types." + s.kind
+		f.Schema = fmt.Sprintf("schema.MapAttribute{ElementType: types.%sType, %s}", s.kind, attrs)
+		f.To = fmt.Sprintf("for k, v := range msg.Get%s() {\nif m.%s == nil {\nm.%s = make(map[string]types.%s)\n}\nm.%s[k] = %s\n}",
+			goName, goName, goName, s.kind, goName, s.to("v", "false"))
+		pre, val := s.from("e", fail(true))
+		f.From = fmt.Sprintf("for k, e := range m.%s {\nif !tfconv.Known(e) {\ncontinue\n}\n%sif msg.%s == nil {\nmsg.%s = make(map[string]%s)\n}\nmsg.%s[k] = %s\n}",
+			goName, pre, goName, goName, goType, goName, val)
+		return f, nil
+	}
+
+	if field.GetType() == descriptor.FieldDescriptorProto_TYPE_MESSAGE && !isWellKnown(field) {
+		vn, err := g.model(field.GetTypeName())
+		if err != nil {
+			return nil, err
+		}
+		if repeated {
+			f.ModelType = "[]" + vn + "Model"
+			f.Schema = fmt.Sprintf("schema.ListNestedAttribute{NestedObject: schema.NestedAttributeObject{Attributes: %sTerraformAttributes()}, %s}", vn, attrs)
+			f.To = fmt.Sprintf("for _, v := range msg.Get%s() {\nm.%s = append(m.%s, *%sToTerraform(v))\n}", goName, goName, goName, vn)
+			f.From = fmt.Sprintf("for i := range m.%s {\nv, err := %sFromTerraform(&m.%s[i])\n%s\nmsg.%s = append(msg.%s, v)\n}",
+				goName, vn, goName, fail(false), goName, goName)
+			return f, nil
+		}
+		f.ModelType = "*" + vn + "Model"
+		f.Schema = fmt.Sprintf("schema.SingleNestedAttribute{Attributes: %sTerraformAttributes(), %s}", vn, attrs)
+		f.To = fmt.Sprintf("if msg.Get%s() != nil {\nm.%s = %sToTerraform(msg.Get%s())\n}", goName, goName, vn, goName)
+		f.From = fmt.Sprintf("if m.%s != nil {\nv, err := %sFromTerraform(m.%s)\n%s\nmsg.%s = v\n}",
+			goName, vn, goName, fail(false), goName)
+		return f, nil
+	}
+
+	s, err := g.scalar(field)
+	if err != nil {
+		return nil, err
+	}
+	g.hdr().Types, g.hdr().Tfconv = true, true
+	if repeated {
+		f.ModelType = "[]types." + s.kind
+		f.Schema = fmt.Sprintf("schema.ListAttribute{ElementType: types.%sType, %s}", s.kind, attrs)
+		f.To = fmt.Sprintf("for _, v := range msg.Get%s() {\nm.%s = append(m.%s, %s)\n}", goName, goName, goName, s.to("v", "false"))
+		pre, val := s.from("e", fail(false))
+		f.From = fmt.Sprintf("for _, e := range m.%s {\nif !tfconv.Known(e) {\ncontinue\n}\n%smsg.%s = append(msg.%s, %s)\n}",
+			goName, pre, goName, goName, val)
+		return f, nil
+	}
+	f.ModelType = "types." + s.kind
+	pre, val := s.from("m."+goName, fail(false))
+	// Scalars with presence are pointers, but for bytes; the well-known
+	// types are ones already.
+	pointer := (field.GetProto3Optional() || g.syntax != "proto3") &&
+		field.GetType() != descriptor.FieldDescriptorProto_TYPE_BYTES && !isWellKnown(field)
+	if pointer {
+		f.To = fmt.Sprintf("if msg.%s != nil {\nm.%s = %s\n} else {\nm.%s = types.%sNull()\n}",
+			goName, goName, s.to("*msg."+goName, "false"), goName, s.kind)
+		f.From = fmt.Sprintf("if tfconv.Known(m.%s) {\n%sv := %s\nmsg.%s = &v\n}", goName, pre, val, goName)
+	} else {
+		omitZero := strconv.FormatBool(!computed && !required)
+		f.To = fmt.Sprintf("m.%s = %s", goName, s.to("msg.Get"+goName+"()", omitZero))
+		f.From = fmt.Sprintf("if tfconv.Known(m.%s) {\n%smsg.%s = %s\n}", goName, pre, goName, val)
+	}
+	f.Schema = fmt.Sprintf("schema.%sAttribute{%s}", s.kind, attrs)
+	return f, nil
+}
+
+// scalar describes the conversions of the values of a scalar, enum or
+// well-known type field.
+type scalar struct {
+	// kind is the framework type: String, Bool, Int64 or Float64.
+	kind string
+	// goType is the Go type of the values of the field, "" for the
+	// well-known types, which are pointers to their messages.
+	goType string
+	// to returns the expression of the attribute value of the Go value v,
+	// null if omitZero and v is zero.
+	to func(v, omitZero string) string
+	// from returns the statements converting the known attribute value x,
+	// running onErr if that fails, and the expression of the Go value.
+	from func(x, onErr string) (string, string)
+}
+
+func (g *tfGen) scalar(field *descriptor.FieldDescriptorProto) (*scalar, error) {
+	simple := func(kind, goType, fromMethod string) *scalar {
+		return &scalar{
+			kind:   kind,
+			goType: goType,
+			to: func(v, omitZero string) string {
+				if kind == "String" || kind == "Bool" || goType == kind2Go[kind] {
+					return fmt.Sprintf("tfconv.%s(%s, %s)", kind, v, omitZero)
+				}
+				return fmt.Sprintf("tfconv.%s(%s(%s), %s)", kind, kind2Go[kind], v, omitZero)
+			},
+			from: func(x, onErr string) (string, string) {
+				if goType == kind2Go[kind] || kind == "String" || kind == "Bool" {
+					return "", x + "." + fromMethod + "()"
+				}
+				return "", fmt.Sprintf("%s(%s.%s())", goType, x, fromMethod)
+			},
+		}
+	}
+	switch field.GetType() {
+	case descriptor.FieldDescriptorProto_TYPE_STRING:
+		return simple("String", "string", "ValueString"), nil
+	case descriptor.FieldDescriptorProto_TYPE_BOOL:
+		return simple("Bool", "bool", "ValueBool"), nil
+	case descriptor.FieldDescriptorProto_TYPE_INT32,
+		descriptor.FieldDescriptorProto_TYPE_SINT32,
+		descriptor.FieldDescriptorProto_TYPE_SFIXED32:
+		return simple("Int64", "int32", "ValueInt64"), nil
+	case descriptor.FieldDescriptorProto_TYPE_INT64,
+		descriptor.FieldDescriptorProto_TYPE_SINT64,
+		descriptor.FieldDescriptorProto_TYPE_SFIXED64:
+		return simple("Int64", "int64", "ValueInt64"), nil
+	case descriptor.FieldDescriptorProto_TYPE_UINT32,
+		descriptor.FieldDescriptorProto_TYPE_FIXED32:
+		return simple("Int64", "uint32", "ValueInt64"), nil
+	case descriptor.FieldDescriptorProto_TYPE_UINT64,
+		descriptor.FieldDescriptorProto_TYPE_FIXED64:
+		return simple("Int64", "uint64", "ValueInt64"), nil
+	case descriptor.FieldDescriptorProto_TYPE_FLOAT:
+		return simple("Float64", "float32", "ValueFloat64"), nil
+	case descriptor.FieldDescriptorProto_TYPE_DOUBLE:
+		return simple("Float64", "float64", "ValueFloat64"), nil
+	case descriptor.FieldDescriptorProto_TYPE_BYTES:
+		return &scalar{
+			kind:   "String",
+			goType: "[]byte",
+			to: func(v, omitZero string) string {
+				return fmt.Sprintf("tfconv.Bytes(%s, %s)", v, omitZero)
+			},
+			from: func(x, onErr string) (string, string) {
+				return fmt.Sprintf("b, err := tfconv.ParseBytes(%s)\n%s\n", x, onErr), "b"
+			},
+		}, nil
+	case descriptor.FieldDescriptorProto_TYPE_ENUM:
+		enum := g.goType(field.GetTypeName())
+		return &scalar{
+			kind:   "String",
+			goType: enum,
+			to: func(v, omitZero string) string {
+				return fmt.Sprintf("tfconv.EnumName(int32(%s), %s_name, %s)", v, enum, omitZero)
+			},
+			from: func(x, onErr string) (string, string) {
+				return fmt.Sprintf("n, err := tfconv.EnumValue(%s, %s_value)\n%s\n", x, enum, onErr), enum + "(n)"
+			},
+		}, nil
+	case descriptor.FieldDescriptorProto_TYPE_MESSAGE:
+		if field.GetTypeName() == ".google.protobuf.Duration" {
+			// The Duration, as a Go duration.
+			return &scalar{
+				kind: "String",
+				to: func(v, omitZero string) string {
+					return fmt.Sprintf("tfconv.Duration(%s)", strings.TrimPrefix(v, "*"))
+				},
+				from: func(x, onErr string) (string, string) {
+					return fmt.Sprintf("d, err := tfconv.ParseDuration(%s)\n%s\n", x, onErr), "d"
+				},
+			}, nil
+		}
+		// The Timestamp, in RFC 3339.
+		return &scalar{
+			kind: "String",
+			to: func(v, omitZero string) string {
+				return fmt.Sprintf("tfconv.Timestamp(%s)", strings.TrimPrefix(v, "*"))
+			},
+			from: func(x, onErr string) (string, string) {
+				return fmt.Sprintf("ts, err := tfconv.ParseTimestamp(%s)\n%s\n", x, onErr), "ts"
+			},
+		}, nil
+	}
+	return nil, fmt.Errorf("unsupported field type %v", field.GetType())
+}
+
+// kind2Go maps the framework types to the Go types of their values.
+var kind2Go = map[string]string{
+	"Int64":   "int64",
+	"Float64": "float64",
+}
+
+// isWellKnown reports whether field is a Timestamp or a Duration, whose
+// attributes are strings.
+func isWellKnown(field *descriptor.FieldDescriptorProto) bool {
+	switch field.GetTypeName() {
+	case ".google.protobuf.Timestamp", ".google.protobuf.Duration":
+		return true
+	}
+	return false
+}
+
+// goImportPath returns the import path of the Go package generated from f,
+// or "" if go_package does not name one.
+func goImportPath(f *descriptor.FileDescriptorProto) string {
+	gopkg := f.GetOptions().GetGoPackage()
+	if sc := strings.IndexByte(gopkg, ';'); sc >= 0 {
+		gopkg = gopkg[:sc]
+	}
+	if !strings.Contains(gopkg, "/") {
+		return ""
+	}
+	return gopkg
+}
+
+// importSet records the Go packages referenced by the code generated for
+// one file, keyed by import path.
+type importSet struct {
+	self  string
+	names map[string]string
+}
+
+func newImportSet(f *descriptor.FileDescriptorProto) *importSet {
+	return &importSet{self: goImportPath(f), names: make(map[string]string)}
+}
+
+// goTypeName returns the Go name of the message or enum typeName, qualified
+// with its package if it is declared outside the generated package.
+func (s *importSet) goTypeName(idx *typeIndex, typeName string) string {
+	name := localTypeName(typeName)
+	f := idx.files[typeName]
+	path := goImportPath(f)
+	if f == nil || path == "" || path == s.self {
+		return name
+	}
+	pkg := defaultGoPackageName(f)
+	s.names[path] = pkg
+	return pkg + "." + name
+}
+
+// localTypeName returns the Go name of a type declared in the file being
+// generated, e.g. ".pkg.Outer.Inner" becomes "Outer_Inner".
+func localTypeName(typeName string) string {
+	parts := strings.Split(strings.TrimPrefix(typeName, "."), ".")
+	var names []string
+	for _, p := range parts {
+		if len(p) > 0 && p[0] >= 'A' && p[0] <= 'Z' {
+			names = append(names, p)
+		}
+	}
+	return strings.Join(names, "_")
+}
+
+// typeIndex maps fully-qualified proto type names (".pkg.Msg") to their
+// descriptors and declaring files for every file in the request.
+type typeIndex struct {
+	messages map[string]*descriptor.DescriptorProto
+	enums    map[string]*descriptor.EnumDescriptorProto
+	files    map[string]*descriptor.FileDescriptorProto
+}
+
+func newTypeIndex(files []*descriptor.FileDescriptorProto) *typeIndex {
+	idx := &typeIndex{
+		messages: make(map[string]*descriptor.DescriptorProto),
+		enums:    make(map[string]*descriptor.EnumDescriptorProto),
+		files:    make(map[string]*descriptor.FileDescriptorProto),
+	}
+	for _, f := range files {
+		prefix := ""
+		if f.GetPackage() != "" {
+			prefix = "." + f.GetPackage()
+		}
+		idx.addMessages(f, prefix, f.GetMessageType())
+		idx.addEnums(f, prefix, f.GetEnumType())
+	}
+	return idx
+}
+
+func (idx *typeIndex) addMessages(f *descriptor.FileDescriptorProto, prefix string, msgs []*descriptor.DescriptorProto) {
+	for _, m := range msgs {
+		name := prefix + "." + m.GetName()
+		idx.messages[name] = m
+		idx.files[name] = f
+		idx.addMessages(f, name, m.GetNestedType())
+		idx.addEnums(f, name, m.GetEnumType())
+	}
+}
+
+func (idx *typeIndex) addEnums(f *descriptor.FileDescriptorProto, prefix string, enums []*descriptor.EnumDescriptorProto) {
+	for _, e := range enums {
+		name := prefix + "." + e.GetName()
+		idx.enums[name] = e
+		idx.files[name] = f
+	}
+}
+
+// isMap reports whether field is a map field.
+func (idx *typeIndex) isMap(field *descriptor.FieldDescriptorProto) bool {
+	if field.GetType() != descriptor.FieldDescriptorProto_TYPE_MESSAGE {
+		return false
+	}
+	return idx.messages[field.GetTypeName()].GetOptions().GetMapEntry()
+}
+
+// sanitizePackageName replaces unallowed character in package name
+// with allowed character.
+func sanitizePackageName(pkgName string) string {
+	pkgName = strings.Replace(pkgName, ".", "_", -1)
+	pkgName = strings.Replace(pkgName, "-", "_", -1)
+	return pkgName
+}
+
+// defaultGoPackageName returns the default go package name to be used for go files generated from "f".
+// You might need to use an unique alias for the package when you import it.  Use ReserveGoPackageAlias to get a unique alias.
+func defaultGoPackageName(f *descriptor.FileDescriptorProto) string {
+	name := packageIdentityName(f)
+	return sanitizePackageName(name)
+}
+
+// packageIdentityName returns the identity of packages.
+// protoc-gen-grpc-gateway rejects CodeGenerationRequests which contains more than one packages
+// as protoc-gen-go does.
+func packageIdentityName(f *descriptor.FileDescriptorProto) string {
+	if f.Options != nil && f.Options.GoPackage != nil {
+		gopkg := f.Options.GetGoPackage()
+		idx := strings.LastIndex(gopkg, "/")
+		if idx < 0 {
+			gopkg = gopkg[idx+1:]
+		}
+
+		gopkg = gopkg[idx+1:]
+		// package name is overrided with the string after the
+		// ';' character
+		sc := strings.IndexByte(gopkg, ';')
+		if sc < 0 {
+			return sanitizePackageName(gopkg)
+
+		}
+		return sanitizePackageName(gopkg[sc+1:])
+	}
+
+	if f.Package == nil {
+		base := filepath.Base(f.GetName())
+		ext := filepath.Ext(base)
+		return strings.TrimSuffix(base, ext)
+	}
+	return f.GetPackage()
+}
+
+func emitFiles(out []*plugin.CodeGeneratorResponse_File) {
+	emitResp(&plugin.CodeGeneratorResponse{File: out})
+}
+
+func emitError(err error) {
+	emitResp(&plugin.CodeGeneratorResponse{Error: proto.String(err.Error())})
+}
+
+func emitResp(resp *plugin.CodeGeneratorResponse) {
+	buf, err := proto.Marshal(resp)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if _, err := os.Stdout.Write(buf); err != nil {
+		log.Fatal(err)
+	}
+}
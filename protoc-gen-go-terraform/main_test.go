@@ -0,0 +1,199 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/f4tq/protoc-go-plugins/internal/plugintest"
+)
+
+const clusterProto = `
+syntax = "proto3";
+
+package infra.v1;
+
+option go_package = "example.com/infra/v1;infrav1";
+
+import "google/protobuf/duration.proto";
+import "google/protobuf/timestamp.proto";
+import "infra/v1/node.proto";
+import "options/options.proto";
+
+message Cluster {
+    option (f4tq.plugins.terraform_resource) = "cluster";
+
+    string name = 1;
+    NodePool pool = 2;
+    repeated NodePool extra_pools = 3;
+    Tier tier = 4;
+    google.protobuf.Timestamp created = 5;
+    google.protobuf.Duration drain_timeout = 6;
+    map<string, google.protobuf.Duration> timeouts = 7;
+}
+`
+
+const nodeProto = `
+syntax = "proto3";
+
+package infra.v1;
+
+option go_package = "example.com/infra/v1;infrav1";
+
+import "google/protobuf/duration.proto";
+
+enum Tier {
+    TIER_UNSPECIFIED = 0;
+    TIER_STANDARD = 1;
+}
+
+message NodePool {
+    int32 size = 1;
+    optional google.protobuf.Duration ttl = 2;
+}
+`
+
+// TestTypesOfOtherFiles vets a resource composed of a message and an enum
+// declared in another file of its package, and of well-known types.
+func TestTypesOfOtherFiles(t *testing.T) {
+	req := plugintest.Request(t, "", map[string]string{
+		"infra/v1/cluster.proto": clusterProto,
+		"infra/v1/node.proto":    nodeProto,
+	})
+	plugintest.Go(t, "vet", plugintest.Package(t, req, generate))
+}
+
+const zoneProto = `
+syntax = "proto3";
+
+package geo.v1;
+
+option go_package = "example.com/geo/v1;geov1";
+
+enum Region {
+    REGION_UNSPECIFIED = 0;
+    REGION_EU = 1;
+}
+
+message Zone {
+    string name = 1;
+    Region region = 2;
+}
+`
+
+const siteProto = `
+syntax = "proto3";
+
+package site.v1;
+
+option go_package = "example.com/site/v1;sitev1";
+
+import "geo/v1/zone.proto";
+import "options/options.proto";
+
+message Site {
+    option (f4tq.plugins.terraform_resource) = "site";
+
+    geo.v1.Zone zone = 1;
+    geo.v1.Region region = 2;
+}
+`
+
+// TestTypesOfOtherPackages checks the models of messages of another Go
+// package go to the files declaring them if those are generated, and to
+// the files using them otherwise.
+func TestTypesOfOtherPackages(t *testing.T) {
+	sources := map[string]string{
+		"geo/v1/zone.proto":  zoneProto,
+		"site/v1/site.proto": siteProto,
+	}
+	tests := []struct {
+		name     string
+		generate []string
+		want     map[string][]string
+	}{
+		{
+			name:     "both generated",
+			generate: []string{"geo/v1/zone.proto", "site/v1/site.proto"},
+			want: map[string][]string{
+				"geo/v1/zone.pb.terraform.go": {
+					"func ZoneToTerraform(msg *Zone) *ZoneModel {",
+					"tfconv.EnumName(int32(msg.GetRegion()), Region_name, true)",
+				},
+				"site/v1/site.pb.terraform.go": {
+					`geov1 "example.com/geo/v1"`,
+					"*geov1.ZoneModel `tfsdk:\"zone\"`",
+					"geov1.ZoneFromTerraform(m.Zone)",
+					"geov1.Region_value",
+				},
+			},
+		},
+		{
+			name:     "user generated",
+			generate: []string{"site/v1/site.proto"},
+			want: map[string][]string{
+				"site/v1/site.pb.terraform.go": {
+					`geov1 "example.com/geo/v1"`,
+					"*ZoneModel   `tfsdk:\"zone\"`",
+					"func ZoneToTerraform(msg *geov1.Zone) *ZoneModel {",
+					"geov1.Region_name",
+				},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := plugintest.Request(t, "", sources)
+			req.FileToGenerate = test.generate
+			got := plugintest.Generate(t, req, generate)
+			if len(got) != len(test.want) {
+				t.Errorf("generated %d files, want %d", len(got), len(test.want))
+			}
+			for name, want := range test.want {
+				content, ok := got[name]
+				if !ok {
+					t.Errorf("%s is not generated", name)
+					continue
+				}
+				for _, s := range want {
+					if !strings.Contains(content, s) {
+						t.Errorf("%s does not contain %q:\n%s", name, s, content)
+					}
+				}
+			}
+		})
+	}
+}
+
+const commentedProto = `
+syntax = "proto3";
+
+package doc.v1;
+
+option go_package = "example.com/doc/v1;docv1";
+
+import "options/options.proto";
+
+// Doc is a document.
+message Doc {
+    option (f4tq.plugins.terraform_resource) = "doc";
+
+    // title names the document.
+    string title = 1; // Shown in lists.
+}
+`
+
+// TestComments checks the models and schemas carry the leading and trailing
+// comments of the declarations.
+func TestComments(t *testing.T) {
+	req := plugintest.Request(t, "", map[string]string{"doc/v1/doc.proto": commentedProto})
+	got := plugintest.Generate(t, req, generate)["doc/v1/doc.pb.terraform.go"]
+	for _, want := range []string{
+		"//\n// Doc is a document.\ntype DocModel struct {",
+		`MarkdownDescription: "Doc is a document."`,
+		`MarkdownDescription: "title names the document.\n\nShown in lists."`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("the code does not contain %q:\n%s", want, got)
+		}
+	}
+}
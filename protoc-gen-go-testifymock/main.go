@@ -0,0 +1,427 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+
+	"github.com/f4tq/protoc-go-plugins/protodoc"
+)
+
+var (
+	hdrTmpl = template.Must(template.New("header").Parse(`
+// Code generated by protoc-gen-go-testifymock. DO NOT EDIT.
+// source: {{.Source}}
+
+package {{.GoPkg}}
+
+import (
+    "context"
+{{if .Matchers}}
+    "github.com/golang/protobuf/proto"
+{{- else}}
+{{end}}
+    "github.com/stretchr/testify/mock"
+    "google.golang.org/grpc"
+{{- range $path, $name := .Imports}}
+    {{$name}} "{{$path}}"
+{{- end}}
+)
+`))
+
+	mockTmpl = template.Must(template.New("mock").Parse(`
+// {{.Name}}ClientMock is a testify mock implementing {{.Name}}Client. Set
+// expectations with the typed On<Method> helpers.{{.Doc}}
+type {{.Name}}ClientMock struct {
+    mock.Mock
+}
+
+var _ {{.Name}}Client = (*{{.Name}}ClientMock)(nil)
+{{range .Methods}}
+{{- if .ClientStreaming}}
+// {{.Name}} implements {{$.Name}}Client.{{.Doc}}
+func (m *{{$.Name}}ClientMock) {{.Name}}(ctx context.Context, opts ...grpc.CallOption) ({{.Result}}, error) {
+    args := m.Called(ctx)
+    if fn, ok := args.Get(0).(func(context.Context) ({{.Result}}, error)); ok {
+        return fn(ctx)
+    }
+    stream, _ := args.Get(0).({{.Result}})
+    return stream, args.Error(1)
+}
+
+// On{{.Name}} sets up an expectation for a call to {{.Name}}.{{.Doc}}
+func (m *{{$.Name}}ClientMock) On{{.Name}}() *{{$.Name}}ClientMock{{.Name}}Call {
+    return &{{$.Name}}ClientMock{{.Name}}Call{Call: m.On({{printf "%q" .Name}}, mock.Anything)}
+}
+
+// {{$.Name}}ClientMock{{.Name}}Call is an expectation for {{.Name}}.
+type {{$.Name}}ClientMock{{.Name}}Call struct {
+    *mock.Call
+}
+
+// Return sets the values returned by {{.Name}}.
+func (c *{{$.Name}}ClientMock{{.Name}}Call) Return(stream {{.Result}}, err error) *{{$.Name}}ClientMock{{.Name}}Call {
+    c.Call.Return(stream, err)
+    return c
+}
+
+// ReturnFn makes {{.Name}} return the result of fn.
+func (c *{{$.Name}}ClientMock{{.Name}}Call) ReturnFn(fn func(context.Context) ({{.Result}}, error)) *{{$.Name}}ClientMock{{.Name}}Call {
+    c.Call.Return(fn, nil)
+    return c
+}
+{{- else}}
+// {{.Name}} implements {{$.Name}}Client.{{.Doc}}
+func (m *{{$.Name}}ClientMock) {{.Name}}(ctx context.Context, in *{{.Input}}, opts ...grpc.CallOption) ({{.Result}}, error) {
+    args := m.Called(ctx, in)
+    if fn, ok := args.Get(0).(func(context.Context, *{{.Input}}) ({{.Result}}, error)); ok {
+        return fn(ctx, in)
+    }
+    resp, _ := args.Get(0).({{.Result}})
+    return resp, args.Error(1)
+}
+
+// On{{.Name}} sets up an expectation for a call to {{.Name}}. req is a
+// *{{.Input}} compared with proto.Equal, a func(*{{.Input}}) bool
+// predicate, or any testify argument matcher such as mock.Anything.{{.Doc}}
+func (m *{{$.Name}}ClientMock) On{{.Name}}(req interface{}) *{{$.Name}}ClientMock{{.Name}}Call {
+    arg := req
+    switch r := req.(type) {
+    case *{{.Input}}:
+        arg = mock.MatchedBy(func(in *{{.Input}}) bool { return proto.Equal(in, r) })
+    case func(*{{.Input}}) bool:
+        arg = mock.MatchedBy(r)
+    }
+    return &{{$.Name}}ClientMock{{.Name}}Call{Call: m.On({{printf "%q" .Name}}, mock.Anything, arg)}
+}
+
+// {{$.Name}}ClientMock{{.Name}}Call is an expectation for {{.Name}}.
+type {{$.Name}}ClientMock{{.Name}}Call struct {
+    *mock.Call
+}
+
+// Return sets the values returned by {{.Name}}.
+func (c *{{$.Name}}ClientMock{{.Name}}Call) Return(resp {{.Result}}, err error) *{{$.Name}}ClientMock{{.Name}}Call {
+    c.Call.Return(resp, err)
+    return c
+}
+
+// ReturnFn makes {{.Name}} return the result of fn.
+func (c *{{$.Name}}ClientMock{{.Name}}Call) ReturnFn(fn func(context.Context, *{{.Input}}) ({{.Result}}, error)) *{{$.Name}}ClientMock{{.Name}}Call {
+    c.Call.Return(fn, nil)
+    return c
+}
+{{- end}}
+{{end}}
+`))
+)
+
+func main() {
+	input, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	req := new(plugin.CodeGeneratorRequest)
+	if err = proto.Unmarshal(input, req); err != nil {
+		log.Fatal(err)
+	}
+
+	out, err := generate(req)
+
+	if err != nil {
+		emitError(err)
+		return
+	}
+
+	emitFiles(out)
+}
+
+func generate(req *plugin.CodeGeneratorRequest) ([]*plugin.CodeGeneratorResponse_File, error) {
+	var files []*plugin.CodeGeneratorResponse_File
+	idx := newTypeIndex(req.GetProtoFile())
+	docs := protodoc.New(req.GetProtoFile())
+	genFileNames := make(map[string]bool)
+	for _, n := range req.FileToGenerate {
+		genFileNames[n] = true
+	}
+	for _, desc := range req.GetProtoFile() {
+		name := desc.GetName()
+		if _, ok := genFileNames[name]; !ok {
+			// Only emit output for files present in req.FileToGenerate.
+			continue
+		}
+		if len(desc.GetService()) == 0 {
+			continue
+		}
+		code, err := genCode(desc, idx, docs)
+		if err != nil {
+			return nil, err
+		}
+		formatted, err := format.Source([]byte(code))
+		if err != nil {
+			log.Printf("%v: %s", err, code)
+			return nil, err
+		}
+
+		ext := filepath.Ext(name)
+		base := strings.TrimSuffix(name, ext)
+		output := fmt.Sprintf("%s.pb.testifymock.go", base)
+		files = append(files, &plugin.CodeGeneratorResponse_File{
+			Name:    proto.String(output),
+			Content: proto.String(string(formatted)),
+		})
+	}
+
+	return files, nil
+}
+
+func genCode(desc *descriptor.FileDescriptorProto, idx *typeIndex, docs *protodoc.Index) (string, error) {
+	w := bytes.NewBuffer(nil)
+	imports := newImportSet(desc)
+	body := bytes.NewBuffer(nil)
+	matchers := false
+	for _, svc := range desc.GetService() {
+		name := qualify(desc.GetPackage(), svc.GetName())
+		s := &mockService{Name: svc.GetName(), Doc: docs.Godoc(name)}
+		for _, m := range svc.GetMethod() {
+			method := &mockMethod{
+				Name:            m.GetName(),
+				Input:           imports.goTypeName(idx, m.GetInputType()),
+				ClientStreaming: m.GetClientStreaming(),
+				Doc:             docs.Godoc(name + "." + m.GetName()),
+			}
+			if !m.GetClientStreaming() {
+				matchers = true
+			}
+			if m.GetClientStreaming() || m.GetServerStreaming() {
+				method.Result = fmt.Sprintf("%s_%sClient", svc.GetName(), m.GetName())
+			} else {
+				method.Result = "*" + imports.goTypeName(idx, m.GetOutputType())
+			}
+			s.Methods = append(s.Methods, method)
+		}
+		if err := mockTmpl.Execute(body, s); err != nil {
+			return "", err
+		}
+	}
+
+	hdr := &header{
+		Source:   desc.GetName(),
+		GoPkg:    defaultGoPackageName(desc),
+		Imports:  imports.names,
+		Matchers: matchers,
+	}
+	if err := hdrTmpl.Execute(w, hdr); err != nil {
+		log.Fatal(err)
+	}
+	body.WriteTo(w)
+
+	return w.String(), nil
+}
+
+type header struct {
+	Source   string
+	GoPkg    string
+	Imports  map[string]string
+	Matchers bool
+}
+
+type mockService struct {
+	Name    string
+	Methods []*mockMethod
+	// Doc is the comment of the service, see protodoc.Index.Godoc.
+	Doc string
+}
+
+type mockMethod struct {
+	Name            string
+	Input           string
+	Result          string
+	ClientStreaming bool
+	// Doc is the comment of the method.
+	Doc string
+}
+
+// qualify returns the full proto name of name, declared in scope.
+func qualify(scope, name string) string {
+	if scope == "" {
+		return name
+	}
+	return scope + "." + name
+}
+
+// typeIndex maps fully-qualified proto type names (".pkg.Msg") to their
+// descriptors and declaring files for every file in the request.
+type typeIndex struct {
+	messages map[string]*descriptor.DescriptorProto
+	enums    map[string]*descriptor.EnumDescriptorProto
+	files    map[string]*descriptor.FileDescriptorProto
+}
+
+func newTypeIndex(files []*descriptor.FileDescriptorProto) *typeIndex {
+	idx := &typeIndex{
+		messages: make(map[string]*descriptor.DescriptorProto),
+		enums:    make(map[string]*descriptor.EnumDescriptorProto),
+		files:    make(map[string]*descriptor.FileDescriptorProto),
+	}
+	for _, f := range files {
+		prefix := ""
+		if f.GetPackage() != "" {
+			prefix = "." + f.GetPackage()
+		}
+		idx.addMessages(f, prefix, f.GetMessageType())
+		idx.addEnums(f, prefix, f.GetEnumType())
+	}
+	return idx
+}
+
+func (idx *typeIndex) addMessages(f *descriptor.FileDescriptorProto, prefix string, msgs []*descriptor.DescriptorProto) {
+	for _, m := range msgs {
+		name := prefix + "." + m.GetName()
+		idx.messages[name] = m
+		idx.files[name] = f
+		idx.addMessages(f, name, m.GetNestedType())
+		idx.addEnums(f, name, m.GetEnumType())
+	}
+}
+
+func (idx *typeIndex) addEnums(f *descriptor.FileDescriptorProto, prefix string, enums []*descriptor.EnumDescriptorProto) {
+	for _, e := range enums {
+		name := prefix + "." + e.GetName()
+		idx.enums[name] = e
+		idx.files[name] = f
+	}
+}
+
+// isMap reports whether field is a map field.
+func (idx *typeIndex) isMap(field *descriptor.FieldDescriptorProto) bool {
+	if field.GetType() != descriptor.FieldDescriptorProto_TYPE_MESSAGE {
+		return false
+	}
+	return idx.messages[field.GetTypeName()].GetOptions().GetMapEntry()
+}
+
+// goImportPath returns the import path of the Go package generated from f,
+// or "" if go_package does not name one.
+func goImportPath(f *descriptor.FileDescriptorProto) string {
+	gopkg := f.GetOptions().GetGoPackage()
+	if sc := strings.IndexByte(gopkg, ';'); sc >= 0 {
+		gopkg = gopkg[:sc]
+	}
+	if !strings.Contains(gopkg, "/") {
+		return ""
+	}
+	return gopkg
+}
+
+// importSet records the Go packages referenced by the code generated for
+// one file, keyed by import path.
+type importSet struct {
+	self  string
+	names map[string]string
+}
+
+func newImportSet(f *descriptor.FileDescriptorProto) *importSet {
+	return &importSet{self: goImportPath(f), names: make(map[string]string)}
+}
+
+// goTypeName returns the Go name of the message or enum typeName, qualified
+// with its package if it is declared outside the generated package.
+func (s *importSet) goTypeName(idx *typeIndex, typeName string) string {
+	name := localTypeName(typeName)
+	f := idx.files[typeName]
+	path := goImportPath(f)
+	if f == nil || path == "" || path == s.self {
+		return name
+	}
+	pkg := defaultGoPackageName(f)
+	s.names[path] = pkg
+	return pkg + "." + name
+}
+
+// localTypeName returns the Go name of a type declared in the file being
+// generated, e.g. ".pkg.Outer.Inner" becomes "Outer_Inner".
+func localTypeName(typeName string) string {
+	parts := strings.Split(strings.TrimPrefix(typeName, "."), ".")
+	var names []string
+	for _, p := range parts {
+		if len(p) > 0 && p[0] >= 'A' && p[0] <= 'Z' {
+			names = append(names, p)
+		}
+	}
+	return strings.Join(names, "_")
+}
+
+// sanitizePackageName replaces unallowed character in package name
+// with allowed character.
+func sanitizePackageName(pkgName string) string {
+	pkgName = strings.Replace(pkgName, ".", "_", -1)
+	pkgName = strings.Replace(pkgName, "-", "_", -1)
+	return pkgName
+}
+
+// defaultGoPackageName returns the default go package name to be used for go files generated from "f".
+// You might need to use an unique alias for the package when you import it.  Use ReserveGoPackageAlias to get a unique alias.
+func defaultGoPackageName(f *descriptor.FileDescriptorProto) string {
+	name := packageIdentityName(f)
+	return sanitizePackageName(name)
+}
+
+// packageIdentityName returns the identity of packages.
+// protoc-gen-grpc-gateway rejects CodeGenerationRequests which contains more than one packages
+// as protoc-gen-go does.
+func packageIdentityName(f *descriptor.FileDescriptorProto) string {
+	if f.Options != nil && f.Options.GoPackage != nil {
+		gopkg := f.Options.GetGoPackage()
+		idx := strings.LastIndex(gopkg, "/")
+		if idx < 0 {
+			gopkg = gopkg[idx+1:]
+		}
+
+		gopkg = gopkg[idx+1:]
+		// package name is overrided with the string after the
+		// ';' character
+		sc := strings.IndexByte(gopkg, ';')
+		if sc < 0 {
+			return sanitizePackageName(gopkg)
+
+		}
+		return sanitizePackageName(gopkg[sc+1:])
+	}
+
+	if f.Package == nil {
+		base := filepath.Base(f.GetName())
+		ext := filepath.Ext(base)
+		return strings.TrimSuffix(base, ext)
+	}
+	return f.GetPackage()
+}
+
+func emitFiles(out []*plugin.CodeGeneratorResponse_File) {
+	emitResp(&plugin.CodeGeneratorResponse{File: out})
+}
+
+func emitError(err error) {
+	emitResp(&plugin.CodeGeneratorResponse{Error: proto.String(err.Error())})
+}
+
+func emitResp(resp *plugin.CodeGeneratorResponse) {
+	buf, err := proto.Marshal(resp)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if _, err := os.Stdout.Write(buf); err != nil {
+		log.Fatal(err)
+	}
+}
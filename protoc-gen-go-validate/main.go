@@ -0,0 +1,526 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+
+	"github.com/f4tq/protoc-go-plugins/goname"
+	"github.com/f4tq/protoc-go-plugins/options"
+)
+
+var (
+	hdrTmpl = template.Must(template.New("header").Parse(`
+// Code generated by protoc-gen-go-validate. DO NOT EDIT.
+// source: {{.Source}}
+
+package {{.GoPkg}}
+
+import (
+{{- if .Regexp}}
+    "regexp"
+{{- end}}
+{{- if .UTF8}}
+    "unicode/utf8"
+{{- end}}
+
+    "github.com/f4tq/protoc-go-plugins/runtime/validate"
+)
+{{- if .Patterns}}
+
+var (
+{{- range .Patterns}}
+    {{.Name}} = regexp.MustCompile({{printf "%q" .Expr}})
+{{- end}}
+)
+{{- end}}
+`))
+
+	messageTmpl = template.Must(template.New("message").Parse(`
+// Validate checks the field rules of m and returns the first violation, or
+// nil if m is valid.
+func (m *{{.Name}}) Validate() error {
+    return m.validate(false)
+}
+
+// ValidateAll checks the field rules of m and returns every violation as a
+// validate.MultiError, or nil if m is valid.
+func (m *{{.Name}}) ValidateAll() error {
+    return m.validate(true)
+}
+
+func (m *{{.Name}}) validate(all bool) error {
+{{- if .Checks}}
+    if m == nil {
+        return nil
+    }
+    var errs validate.MultiError
+{{.Checks -}}
+    if len(errs) == 0 {
+        return nil
+    }
+    return errs
+{{- else}}
+    return nil
+{{- end}}
+}
+`))
+)
+
+func main() {
+	input, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	req := new(plugin.CodeGeneratorRequest)
+	if err = proto.Unmarshal(input, req); err != nil {
+		log.Fatal(err)
+	}
+
+	out, err := generate(req)
+
+	if err != nil {
+		emitError(err)
+		return
+	}
+
+	emitFiles(out)
+}
+
+func generate(req *plugin.CodeGeneratorRequest) ([]*plugin.CodeGeneratorResponse_File, error) {
+	var files []*plugin.CodeGeneratorResponse_File
+	idx := newTypeIndex(req.GetProtoFile())
+	genFileNames := make(map[string]bool)
+	for _, n := range req.FileToGenerate {
+		genFileNames[n] = true
+	}
+	for _, desc := range req.GetProtoFile() {
+		name := desc.GetName()
+		if _, ok := genFileNames[name]; !ok {
+			// Only emit output for files present in req.FileToGenerate.
+			continue
+		}
+		code, err := genCode(desc, idx)
+		if err != nil {
+			return nil, err
+		}
+		if code == "" {
+			// The file declares no messages.
+			continue
+		}
+		formatted, err := format.Source([]byte(code))
+		if err != nil {
+			log.Printf("%v: %s", err, code)
+			return nil, err
+		}
+
+		ext := filepath.Ext(name)
+		base := strings.TrimSuffix(name, ext)
+		output := fmt.Sprintf("%s.pb.validate.go", base)
+		files = append(files, &plugin.CodeGeneratorResponse_File{
+			Name:    proto.String(output),
+			Content: proto.String(string(formatted)),
+		})
+	}
+
+	return files, nil
+}
+
+func genCode(desc *descriptor.FileDescriptorProto, idx *typeIndex) (string, error) {
+	w := bytes.NewBuffer(nil)
+	g := &valGen{
+		idx:    idx,
+		proto3: desc.GetSyntax() == "proto3",
+		hdr: &header{
+			Source: desc.GetName(),
+			GoPkg:  defaultGoPackageName(desc),
+		},
+	}
+	body := bytes.NewBuffer(nil)
+	if err := g.messages(body, "", desc.GetMessageType()); err != nil {
+		return "", err
+	}
+	if body.Len() == 0 {
+		return "", nil
+	}
+
+	if err := hdrTmpl.Execute(w, g.hdr); err != nil {
+		log.Fatal(err)
+	}
+	body.WriteTo(w)
+
+	return w.String(), nil
+}
+
+type valGen struct {
+	idx    *typeIndex
+	proto3 bool
+	hdr    *header
+}
+
+// messages writes the validation methods of msgs and of the messages
+// nested in them. prefix is the Go name of the enclosing message plus "_".
+func (g *valGen) messages(w *bytes.Buffer, prefix string, msgs []*descriptor.DescriptorProto) error {
+	for _, msg := range msgs {
+		if msg.GetOptions().GetMapEntry() {
+			continue
+		}
+		name := prefix + msg.GetName()
+		checks := bytes.NewBuffer(nil)
+		for _, field := range msg.GetField() {
+			if err := g.field(checks, name, msg, field); err != nil {
+				return fmt.Errorf("%s.%s: %v", name, field.GetName(), err)
+			}
+		}
+		if err := messageTmpl.Execute(w, &valMessage{Name: name, Checks: checks.String()}); err != nil {
+			return err
+		}
+		if err := g.messages(w, name+"_", msg.GetNestedType()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// field writes the checks of the rules of field, and the validation of the
+// messages it holds.
+func (g *valGen) field(w *bytes.Buffer, msgName string, msg *descriptor.DescriptorProto, field *descriptor.FieldDescriptorProto) error {
+	rules := options.Rules(field)
+	if rules == nil {
+		rules = new(options.FieldRules)
+	}
+	typ := field.GetType()
+	goNames := goname.Fields(msg)
+	goName := goNames[field.GetName()]
+	get := "m.Get" + goName + "()"
+	path := strconv.Quote(field.GetName())
+	repeated := field.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REPEATED
+	isMap := g.idx.isMap(field)
+	isMessage := typ == descriptor.FieldDescriptorProto_TYPE_MESSAGE
+	oneof := field.OneofIndex != nil && !field.GetProto3Optional()
+	// pointer reports whether an unset field is a nil pointer in Go.
+	pointer := !repeated && !oneof && (isMessage || field.GetProto3Optional() ||
+		!g.proto3 && typ != descriptor.FieldDescriptorProto_TYPE_BYTES)
+
+	if rules.GetRequired() {
+		var cond string
+		switch {
+		case oneof:
+			return fmt.Errorf("required is not supported on oneof members")
+		case pointer:
+			cond = fmt.Sprintf("m.%s == nil", goName)
+		case repeated, typ == descriptor.FieldDescriptorProto_TYPE_BYTES:
+			cond = fmt.Sprintf("len(%s) == 0", get)
+		case typ == descriptor.FieldDescriptorProto_TYPE_STRING:
+			cond = get + ` == ""`
+		case typ == descriptor.FieldDescriptorProto_TYPE_BOOL:
+			cond = "!" + get
+		default:
+			cond = get + " == 0"
+		}
+		check(w, cond, path, "is required")
+	}
+	if rules.MinItems != nil || rules.MaxItems != nil {
+		if !repeated {
+			return fmt.Errorf("min_items and max_items only apply to repeated and map fields")
+		}
+		if rules.MinItems != nil {
+			check(w, fmt.Sprintf("len(%s) < %d", get, *rules.MinItems), path, fmt.Sprintf("must have at least %d items", *rules.MinItems))
+		}
+		if rules.MaxItems != nil {
+			check(w, fmt.Sprintf("len(%s) > %d", get, *rules.MaxItems), path, fmt.Sprintf("must have at most %d items", *rules.MaxItems))
+		}
+	}
+
+	// The remaining rules apply to the value of set fields, or to each
+	// element of repeated fields.
+	inner := bytes.NewBuffer(nil)
+	value, valuePath := get, path
+	if repeated && !isMap {
+		value, valuePath = "v", `validate.Index(`+path+`, i)`
+	}
+	if err := g.valueRules(inner, msgName, goName, field, rules, value, valuePath); err != nil {
+		return err
+	}
+	if isMap && inner.Len() > 0 {
+		return fmt.Errorf("only min_items, max_items and required apply to map fields")
+	}
+	if isMessage && !rules.GetSkip() {
+		switch {
+		case isMap:
+			entry := g.idx.messages[field.GetTypeName()]
+			if v := entry.GetField()[1]; v.GetType() == descriptor.FieldDescriptorProto_TYPE_MESSAGE {
+				fmt.Fprintf(w, "for k, v := range %s {\n", get)
+				nested(w, "validate.Index("+path+", k)", "v")
+				fmt.Fprintf(w, "}\n")
+			}
+		default:
+			nested(inner, valuePath, value)
+		}
+	}
+	if inner.Len() == 0 {
+		return nil
+	}
+
+	switch {
+	case repeated:
+		fmt.Fprintf(w, "for i, v := range %s {\n", get)
+		inner.WriteTo(w)
+		fmt.Fprintf(w, "}\n")
+	case oneof:
+		wrapper := msgName + "_" + goName
+		fmt.Fprintf(w, "if _, ok := m.%s.(*%s); ok {\n", goNames[msg.GetOneofDecl()[field.GetOneofIndex()].GetName()], wrapper)
+		inner.WriteTo(w)
+		fmt.Fprintf(w, "}\n")
+	case pointer && !isMessage:
+		fmt.Fprintf(w, "if m.%s != nil {\n", goName)
+		inner.WriteTo(w)
+		fmt.Fprintf(w, "}\n")
+	default:
+		inner.WriteTo(w)
+	}
+	return nil
+}
+
+// valueRules writes the length, pattern and range checks of value.
+func (g *valGen) valueRules(w *bytes.Buffer, msgName, goName string, field *descriptor.FieldDescriptorProto, rules *options.FieldRules, value, path string) error {
+	typ := field.GetType()
+	if rules.MinLen != nil || rules.MaxLen != nil {
+		var length, unit string
+		switch typ {
+		case descriptor.FieldDescriptorProto_TYPE_STRING:
+			length, unit = fmt.Sprintf("utf8.RuneCountInString(%s)", value), "characters"
+			g.hdr.UTF8 = true
+		case descriptor.FieldDescriptorProto_TYPE_BYTES:
+			length, unit = fmt.Sprintf("len(%s)", value), "bytes"
+		default:
+			return fmt.Errorf("min_len and max_len only apply to strings and bytes")
+		}
+		if rules.MinLen != nil {
+			check(w, fmt.Sprintf("%s < %d", length, *rules.MinLen), path, fmt.Sprintf("must be at least %d %s long", *rules.MinLen, unit))
+		}
+		if rules.MaxLen != nil {
+			check(w, fmt.Sprintf("%s > %d", length, *rules.MaxLen), path, fmt.Sprintf("must be at most %d %s long", *rules.MaxLen, unit))
+		}
+	}
+	if rules.Pattern != nil {
+		if typ != descriptor.FieldDescriptorProto_TYPE_STRING {
+			return fmt.Errorf("pattern only applies to strings")
+		}
+		if _, err := regexp.Compile(rules.GetPattern()); err != nil {
+			return fmt.Errorf("pattern: %v", err)
+		}
+		name := strings.ToLower(msgName[:1]) + msgName[1:] + goName + "Pattern"
+		g.hdr.Regexp = true
+		g.hdr.Patterns = append(g.hdr.Patterns, &valPattern{Name: name, Expr: rules.GetPattern()})
+		check(w, fmt.Sprintf("!%s.MatchString(%s)", name, value), path, fmt.Sprintf("must match %q", rules.GetPattern()))
+	}
+	bounds := []struct {
+		bound *float64
+		op    string
+		desc  string
+	}{
+		{rules.Gt, "<=", "greater than"},
+		{rules.Gte, "<", "at least"},
+		{rules.Lt, ">=", "less than"},
+		{rules.Lte, ">", "at most"},
+	}
+	for _, b := range bounds {
+		if b.bound == nil {
+			continue
+		}
+		lo, hi, integer, ok := numericRange(typ)
+		if !ok {
+			return fmt.Errorf("gt, gte, lt and lte only apply to numbers")
+		}
+		if *b.bound < lo || *b.bound > hi || integer && *b.bound != math.Trunc(*b.bound) {
+			return fmt.Errorf("bound %v is out of range for %s", *b.bound, strings.ToLower(strings.TrimPrefix(typ.String(), "TYPE_")))
+		}
+		lit := strconv.FormatFloat(*b.bound, 'g', -1, 64)
+		check(w, fmt.Sprintf("%s %s %s", value, b.op, lit), path, fmt.Sprintf("must be %s %s", b.desc, lit))
+	}
+	return nil
+}
+
+// numericRange returns the range of the Go type of typ, and whether it is
+// an integer type. ok is false for other than number types.
+func numericRange(typ descriptor.FieldDescriptorProto_Type) (lo, hi float64, integer, ok bool) {
+	switch typ {
+	case descriptor.FieldDescriptorProto_TYPE_INT32, descriptor.FieldDescriptorProto_TYPE_SINT32,
+		descriptor.FieldDescriptorProto_TYPE_SFIXED32:
+		return math.MinInt32, math.MaxInt32, true, true
+	case descriptor.FieldDescriptorProto_TYPE_UINT32, descriptor.FieldDescriptorProto_TYPE_FIXED32:
+		return 0, math.MaxUint32, true, true
+	case descriptor.FieldDescriptorProto_TYPE_INT64, descriptor.FieldDescriptorProto_TYPE_SINT64,
+		descriptor.FieldDescriptorProto_TYPE_SFIXED64:
+		// float64(math.MaxInt64) rounds up to 2^63, which overflows.
+		return math.MinInt64, math.Nextafter(math.MaxInt64, 0), true, true
+	case descriptor.FieldDescriptorProto_TYPE_UINT64, descriptor.FieldDescriptorProto_TYPE_FIXED64:
+		return 0, math.Nextafter(math.MaxUint64, 0), true, true
+	case descriptor.FieldDescriptorProto_TYPE_FLOAT:
+		return -math.MaxFloat32, math.MaxFloat32, false, true
+	case descriptor.FieldDescriptorProto_TYPE_DOUBLE:
+		return -math.MaxFloat64, math.MaxFloat64, false, true
+	}
+	return 0, 0, false, false
+}
+
+// check writes a check adding a violation of reason at path when cond
+// holds.
+func check(w *bytes.Buffer, cond, path, reason string) {
+	fmt.Fprintf(w, "if %s {\n", cond)
+	fmt.Fprintf(w, "errs = append(errs, validate.Violation(%s, %q))\n", path, reason)
+	fmt.Fprintf(w, "if !all {\nreturn errs[0]\n}\n}\n")
+}
+
+// nested writes the validation of the message value at path.
+func nested(w *bytes.Buffer, path, value string) {
+	fmt.Fprintf(w, "if errs = append(errs, validate.Message(%s, %s, all)...); len(errs) > 0 && !all {\n", path, value)
+	fmt.Fprintf(w, "return errs[0]\n}\n")
+}
+
+type header struct {
+	Source   string
+	GoPkg    string
+	Regexp   bool
+	UTF8     bool
+	Patterns []*valPattern
+}
+
+type valPattern struct {
+	Name string
+	Expr string
+}
+
+type valMessage struct {
+	Name   string
+	Checks string
+}
+
+// typeIndex maps fully-qualified proto type names (".pkg.Msg") to their
+// descriptors and declaring files for every file in the request.
+type typeIndex struct {
+	messages map[string]*descriptor.DescriptorProto
+	enums    map[string]*descriptor.EnumDescriptorProto
+	files    map[string]*descriptor.FileDescriptorProto
+}
+
+func newTypeIndex(files []*descriptor.FileDescriptorProto) *typeIndex {
+	idx := &typeIndex{
+		messages: make(map[string]*descriptor.DescriptorProto),
+		enums:    make(map[string]*descriptor.EnumDescriptorProto),
+		files:    make(map[string]*descriptor.FileDescriptorProto),
+	}
+	for _, f := range files {
+		prefix := ""
+		if f.GetPackage() != "" {
+			prefix = "." + f.GetPackage()
+		}
+		idx.addMessages(f, prefix, f.GetMessageType())
+		idx.addEnums(f, prefix, f.GetEnumType())
+	}
+	return idx
+}
+
+func (idx *typeIndex) addMessages(f *descriptor.FileDescriptorProto, prefix string, msgs []*descriptor.DescriptorProto) {
+	for _, m := range msgs {
+		name := prefix + "." + m.GetName()
+		idx.messages[name] = m
+		idx.files[name] = f
+		idx.addMessages(f, name, m.GetNestedType())
+		idx.addEnums(f, name, m.GetEnumType())
+	}
+}
+
+func (idx *typeIndex) addEnums(f *descriptor.FileDescriptorProto, prefix string, enums []*descriptor.EnumDescriptorProto) {
+	for _, e := range enums {
+		name := prefix + "." + e.GetName()
+		idx.enums[name] = e
+		idx.files[name] = f
+	}
+}
+
+// isMap reports whether field is a map field.
+func (idx *typeIndex) isMap(field *descriptor.FieldDescriptorProto) bool {
+	if field.GetType() != descriptor.FieldDescriptorProto_TYPE_MESSAGE {
+		return false
+	}
+	return idx.messages[field.GetTypeName()].GetOptions().GetMapEntry()
+}
+
+// sanitizePackageName replaces unallowed character in package name
+// with allowed character.
+func sanitizePackageName(pkgName string) string {
+	pkgName = strings.Replace(pkgName, ".", "_", -1)
+	pkgName = strings.Replace(pkgName, "-", "_", -1)
+	return pkgName
+}
+
+// defaultGoPackageName returns the default go package name to be used for go files generated from "f".
+// You might need to use an unique alias for the package when you import it.  Use ReserveGoPackageAlias to get a unique alias.
+func defaultGoPackageName(f *descriptor.FileDescriptorProto) string {
+	name := packageIdentityName(f)
+	return sanitizePackageName(name)
+}
+
+// packageIdentityName returns the identity of packages.
+// protoc-gen-grpc-gateway rejects CodeGenerationRequests which contains more than one packages
+// as protoc-gen-go does.
+func packageIdentityName(f *descriptor.FileDescriptorProto) string {
+	if f.Options != nil && f.Options.GoPackage != nil {
+		gopkg := f.Options.GetGoPackage()
+		idx := strings.LastIndex(gopkg, "/")
+		if idx < 0 {
+			gopkg = gopkg[idx+1:]
+		}
+
+		gopkg = gopkg[idx+1:]
+		// package name is overrided with the string after the
+		// ';' character
+		sc := strings.IndexByte(gopkg, ';')
+		if sc < 0 {
+			return sanitizePackageName(gopkg)
+
+		}
+		return sanitizePackageName(gopkg[sc+1:])
+	}
+
+	if f.Package == nil {
+		base := filepath.Base(f.GetName())
+		ext := filepath.Ext(base)
+		return strings.TrimSuffix(base, ext)
+	}
+	return f.GetPackage()
+}
+
+func emitFiles(out []*plugin.CodeGeneratorResponse_File) {
+	emitResp(&plugin.CodeGeneratorResponse{File: out})
+}
+
+func emitError(err error) {
+	emitResp(&plugin.CodeGeneratorResponse{Error: proto.String(err.Error())})
+}
+
+func emitResp(resp *plugin.CodeGeneratorResponse) {
+	buf, err := proto.Marshal(resp)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if _, err := os.Stdout.Write(buf); err != nil {
+		log.Fatal(err)
+	}
+}
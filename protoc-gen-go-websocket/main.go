@@ -0,0 +1,448 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+
+	"github.com/f4tq/protoc-go-plugins/protodoc"
+)
+
+var (
+	hdrTmpl = template.Must(template.New("header").Parse(`
+// Code generated by protoc-gen-go-websocket. DO NOT EDIT.
+// source: {{.Source}}
+
+package {{.GoPkg}}
+
+import (
+    "context"
+    "net/http"
+
+    "github.com/f4tq/protoc-go-plugins/runtime/wsbridge"
+{{- range $path, $name := .Imports}}
+    {{$name}} "{{$path}}"
+{{- end}}
+)
+`))
+
+	wsTmpl = template.Must(template.New("ws").Parse(`
+// Register{{.Name}}WebSocketHandlers registers WebSocket bridges for the
+// server-streaming and bidirectional methods of srv on mux, at their gRPC
+// paths. A server-streaming call takes its request from the first message
+// the client sends.{{.Doc}}
+func Register{{.Name}}WebSocketHandlers(mux *http.ServeMux, srv {{.Name}}Server) {
+{{- range .Methods}}
+    mux.Handle({{printf "%q" .Path}}, wsbridge.Handle(func(stream *wsbridge.ServerStream) error {
+{{- if .ClientStreaming}}
+        return srv.{{.Name}}(&{{$.Lower}}{{.Name}}WebSocketServer{stream})
+{{- else}}
+        in := new({{.Input}})
+        if err := stream.RecvMsg(in); err != nil {
+            return err
+        }
+        return srv.{{.Name}}(in, &{{$.Lower}}{{.Name}}WebSocketServer{stream})
+{{- end}}
+    }))
+{{- end}}
+}
+{{range .Methods}}
+type {{$.Lower}}{{.Name}}WebSocketServer struct {
+    *wsbridge.ServerStream
+}
+
+func (s *{{$.Lower}}{{.Name}}WebSocketServer) Send(m *{{.Output}}) error {
+    return s.SendMsg(m)
+}
+{{if .ClientStreaming}}
+func (s *{{$.Lower}}{{.Name}}WebSocketServer) Recv() (*{{.Input}}, error) {
+    m := new({{.Input}})
+    if err := s.RecvMsg(m); err != nil {
+        return nil, err
+    }
+    return m, nil
+}
+{{end}}{{end}}
+// {{.Name}}WebSocketClient opens {{.FullName}} streams over WebSocket.{{.Doc}}
+type {{.Name}}WebSocketClient struct {
+    dialer *wsbridge.Dialer
+}
+
+// New{{.Name}}WebSocketClient returns a {{.Name}}WebSocketClient opening
+// its streams with dialer.{{.Doc}}
+func New{{.Name}}WebSocketClient(dialer *wsbridge.Dialer) *{{.Name}}WebSocketClient {
+    return &{{.Name}}WebSocketClient{dialer: dialer}
+}
+{{range .Methods}}
+{{- if .ClientStreaming}}
+// {{.Name}} opens a stream to {{$.FullName}}.{{.Name}}.{{.Doc}}
+func (c *{{$.Name}}WebSocketClient) {{.Name}}(ctx context.Context) (*{{$.Name}}{{.Name}}WebSocketStream, error) {
+    stream, err := c.dialer.Dial(ctx, {{printf "%q" .Path}})
+    if err != nil {
+        return nil, err
+    }
+    return &{{$.Name}}{{.Name}}WebSocketStream{stream}, nil
+}
+
+// {{$.Name}}{{.Name}}WebSocketStream is the client side of a {{.Name}}
+// stream.
+type {{$.Name}}{{.Name}}WebSocketStream struct {
+    *wsbridge.ClientStream
+}
+
+// Send sends m to the server.
+func (s *{{$.Name}}{{.Name}}WebSocketStream) Send(m *{{.Input}}) error {
+    return s.SendMsg(m)
+}
+{{- else}}
+// {{.Name}} calls {{$.FullName}}.{{.Name}} and returns the stream of its
+// responses.{{.Doc}}
+func (c *{{$.Name}}WebSocketClient) {{.Name}}(ctx context.Context, in *{{.Input}}) (*{{$.Name}}{{.Name}}WebSocketStream, error) {
+    stream, err := c.dialer.Dial(ctx, {{printf "%q" .Path}})
+    if err != nil {
+        return nil, err
+    }
+    if err := stream.SendMsg(in); err != nil {
+        stream.Close()
+        return nil, err
+    }
+    if err := stream.CloseSend(); err != nil {
+        stream.Close()
+        return nil, err
+    }
+    return &{{$.Name}}{{.Name}}WebSocketStream{stream}, nil
+}
+
+// {{$.Name}}{{.Name}}WebSocketStream receives the responses of a
+// {{.Name}} call.
+type {{$.Name}}{{.Name}}WebSocketStream struct {
+    *wsbridge.ClientStream
+}
+{{- end}}
+
+// Recv returns the next response, or io.EOF once the stream ended
+// successfully.
+func (s *{{$.Name}}{{.Name}}WebSocketStream) Recv() (*{{.Output}}, error) {
+    m := new({{.Output}})
+    if err := s.RecvMsg(m); err != nil {
+        return nil, err
+    }
+    return m, nil
+}
+{{end}}
+`))
+)
+
+func main() {
+	input, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	req := new(plugin.CodeGeneratorRequest)
+	if err = proto.Unmarshal(input, req); err != nil {
+		log.Fatal(err)
+	}
+
+	out, err := generate(req)
+
+	if err != nil {
+		emitError(err)
+		return
+	}
+
+	emitFiles(out)
+}
+
+func generate(req *plugin.CodeGeneratorRequest) ([]*plugin.CodeGeneratorResponse_File, error) {
+	var files []*plugin.CodeGeneratorResponse_File
+	idx := newTypeIndex(req.GetProtoFile())
+	docs := protodoc.New(req.GetProtoFile())
+	genFileNames := make(map[string]bool)
+	for _, n := range req.FileToGenerate {
+		genFileNames[n] = true
+	}
+	for _, desc := range req.GetProtoFile() {
+		name := desc.GetName()
+		if _, ok := genFileNames[name]; !ok {
+			// Only emit output for files present in req.FileToGenerate.
+			continue
+		}
+		code, err := genCode(desc, idx, docs)
+		if err != nil {
+			return nil, err
+		}
+		if code == "" {
+			// No server-streaming or bidirectional method.
+			continue
+		}
+		formatted, err := format.Source([]byte(code))
+		if err != nil {
+			log.Printf("%v: %s", err, code)
+			return nil, err
+		}
+
+		ext := filepath.Ext(name)
+		base := strings.TrimSuffix(name, ext)
+		output := fmt.Sprintf("%s.pb.websocket.go", base)
+		files = append(files, &plugin.CodeGeneratorResponse_File{
+			Name:    proto.String(output),
+			Content: proto.String(string(formatted)),
+		})
+	}
+
+	return files, nil
+}
+
+func genCode(desc *descriptor.FileDescriptorProto, idx *typeIndex, docs *protodoc.Index) (string, error) {
+	w := bytes.NewBuffer(nil)
+	imports := newImportSet(desc)
+	body := bytes.NewBuffer(nil)
+	for _, svc := range desc.GetService() {
+		fullName := svc.GetName()
+		if desc.GetPackage() != "" {
+			fullName = desc.GetPackage() + "." + svc.GetName()
+		}
+		s := &wsService{
+			Name:     svc.GetName(),
+			Lower:    strings.ToLower(svc.GetName()[:1]) + svc.GetName()[1:],
+			FullName: fullName,
+			Doc:      docs.Godoc(fullName),
+		}
+		for _, m := range svc.GetMethod() {
+			if !m.GetServerStreaming() {
+				// Unary and client-streaming methods are not bridged.
+				continue
+			}
+			s.Methods = append(s.Methods, &wsMethod{
+				Name:            m.GetName(),
+				Path:            "/" + fullName + "/" + m.GetName(),
+				Input:           imports.goTypeName(idx, m.GetInputType()),
+				Output:          imports.goTypeName(idx, m.GetOutputType()),
+				ClientStreaming: m.GetClientStreaming(),
+				Doc:             docs.Godoc(fullName + "." + m.GetName()),
+			})
+		}
+		if len(s.Methods) == 0 {
+			continue
+		}
+		if err := wsTmpl.Execute(body, s); err != nil {
+			return "", err
+		}
+	}
+	if body.Len() == 0 {
+		return "", nil
+	}
+
+	hdr := &header{
+		Source:  desc.GetName(),
+		GoPkg:   defaultGoPackageName(desc),
+		Imports: imports.names,
+	}
+	if err := hdrTmpl.Execute(w, hdr); err != nil {
+		log.Fatal(err)
+	}
+	body.WriteTo(w)
+
+	return w.String(), nil
+}
+
+type header struct {
+	Source  string
+	GoPkg   string
+	Imports map[string]string
+}
+
+type wsService struct {
+	Name     string
+	Lower    string
+	FullName string
+	Methods  []*wsMethod
+	// Doc is the comment of the service, see protodoc.Index.Godoc.
+	Doc string
+}
+
+type wsMethod struct {
+	Name            string
+	Path            string
+	Input           string
+	Output          string
+	ClientStreaming bool
+	// Doc is the comment of the method.
+	Doc string
+}
+
+// typeIndex maps fully-qualified proto type names (".pkg.Msg") to their
+// descriptors and declaring files for every file in the request.
+type typeIndex struct {
+	messages map[string]*descriptor.DescriptorProto
+	enums    map[string]*descriptor.EnumDescriptorProto
+	files    map[string]*descriptor.FileDescriptorProto
+}
+
+func newTypeIndex(files []*descriptor.FileDescriptorProto) *typeIndex {
+	idx := &typeIndex{
+		messages: make(map[string]*descriptor.DescriptorProto),
+		enums:    make(map[string]*descriptor.EnumDescriptorProto),
+		files:    make(map[string]*descriptor.FileDescriptorProto),
+	}
+	for _, f := range files {
+		prefix := ""
+		if f.GetPackage() != "" {
+			prefix = "." + f.GetPackage()
+		}
+		idx.addMessages(f, prefix, f.GetMessageType())
+		idx.addEnums(f, prefix, f.GetEnumType())
+	}
+	return idx
+}
+
+func (idx *typeIndex) addMessages(f *descriptor.FileDescriptorProto, prefix string, msgs []*descriptor.DescriptorProto) {
+	for _, m := range msgs {
+		name := prefix + "." + m.GetName()
+		idx.messages[name] = m
+		idx.files[name] = f
+		idx.addMessages(f, name, m.GetNestedType())
+		idx.addEnums(f, name, m.GetEnumType())
+	}
+}
+
+func (idx *typeIndex) addEnums(f *descriptor.FileDescriptorProto, prefix string, enums []*descriptor.EnumDescriptorProto) {
+	for _, e := range enums {
+		name := prefix + "." + e.GetName()
+		idx.enums[name] = e
+		idx.files[name] = f
+	}
+}
+
+// isMap reports whether field is a map field.
+func (idx *typeIndex) isMap(field *descriptor.FieldDescriptorProto) bool {
+	if field.GetType() != descriptor.FieldDescriptorProto_TYPE_MESSAGE {
+		return false
+	}
+	return idx.messages[field.GetTypeName()].GetOptions().GetMapEntry()
+}
+
+// goImportPath returns the import path of the Go package generated from f,
+// or "" if go_package does not name one.
+func goImportPath(f *descriptor.FileDescriptorProto) string {
+	gopkg := f.GetOptions().GetGoPackage()
+	if sc := strings.IndexByte(gopkg, ';'); sc >= 0 {
+		gopkg = gopkg[:sc]
+	}
+	if !strings.Contains(gopkg, "/") {
+		return ""
+	}
+	return gopkg
+}
+
+// importSet records the Go packages referenced by the code generated for
+// one file, keyed by import path.
+type importSet struct {
+	self  string
+	names map[string]string
+}
+
+func newImportSet(f *descriptor.FileDescriptorProto) *importSet {
+	return &importSet{self: goImportPath(f), names: make(map[string]string)}
+}
+
+// goTypeName returns the Go name of the message or enum typeName, qualified
+// with its package if it is declared outside the generated package.
+func (s *importSet) goTypeName(idx *typeIndex, typeName string) string {
+	name := localTypeName(typeName)
+	f := idx.files[typeName]
+	path := goImportPath(f)
+	if f == nil || path == "" || path == s.self {
+		return name
+	}
+	pkg := defaultGoPackageName(f)
+	s.names[path] = pkg
+	return pkg + "." + name
+}
+
+// localTypeName returns the Go name of a type declared in the file being
+// generated, e.g. ".pkg.Outer.Inner" becomes "Outer_Inner".
+func localTypeName(typeName string) string {
+	parts := strings.Split(strings.TrimPrefix(typeName, "."), ".")
+	var names []string
+	for _, p := range parts {
+		if len(p) > 0 && p[0] >= 'A' && p[0] <= 'Z' {
+			names = append(names, p)
+		}
+	}
+	return strings.Join(names, "_")
+}
+
+// sanitizePackageName replaces unallowed character in package name
+// with allowed character.
+func sanitizePackageName(pkgName string) string {
+	pkgName = strings.Replace(pkgName, ".", "_", -1)
+	pkgName = strings.Replace(pkgName, "-", "_", -1)
+	return pkgName
+}
+
+// defaultGoPackageName returns the default go package name to be used for go files generated from "f".
+// You might need to use an unique alias for the package when you import it.  Use ReserveGoPackageAlias to get a unique alias.
+func defaultGoPackageName(f *descriptor.FileDescriptorProto) string {
+	name := packageIdentityName(f)
+	return sanitizePackageName(name)
+}
+
+// packageIdentityName returns the identity of packages.
+// protoc-gen-grpc-gateway rejects CodeGenerationRequests which contains more than one packages
+// as protoc-gen-go does.
+func packageIdentityName(f *descriptor.FileDescriptorProto) string {
+	if f.Options != nil && f.Options.GoPackage != nil {
+		gopkg := f.Options.GetGoPackage()
+		idx := strings.LastIndex(gopkg, "/")
+		if idx < 0 {
+			gopkg = gopkg[idx+1:]
+		}
+
+		gopkg = gopkg[idx+1:]
+		// package name is overrided with the string after the
+		// ';' character
+		sc := strings.IndexByte(gopkg, ';')
+		if sc < 0 {
+			return sanitizePackageName(gopkg)
+
+		}
+		return sanitizePackageName(gopkg[sc+1:])
+	}
+
+	if f.Package == nil {
+		base := filepath.Base(f.GetName())
+		ext := filepath.Ext(base)
+		return strings.TrimSuffix(base, ext)
+	}
+	return f.GetPackage()
+}
+
+func emitFiles(out []*plugin.CodeGeneratorResponse_File) {
+	emitResp(&plugin.CodeGeneratorResponse{File: out})
+}
+
+func emitError(err error) {
+	emitResp(&plugin.CodeGeneratorResponse{Error: proto.String(err.Error())})
+}
+
+func emitResp(resp *plugin.CodeGeneratorResponse) {
+	buf, err := proto.Marshal(resp)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if _, err := os.Stdout.Write(buf); err != nil {
+		log.Fatal(err)
+	}
+}
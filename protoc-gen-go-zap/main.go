@@ -0,0 +1,522 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+
+	"github.com/f4tq/protoc-go-plugins/goname"
+	"github.com/f4tq/protoc-go-plugins/options"
+)
+
+var (
+	hdrTmpl = template.Must(template.New("header").Parse(`
+// Code generated by protoc-gen-go-zap. DO NOT EDIT.
+// source: {{.Source}}
+
+package {{.GoPkg}}
+
+import (
+{{- range $path, $name := .Imports}}
+    {{$name}} "{{$path}}"
+{{- end}}
+    "go.uber.org/zap/zapcore"
+{{if or .Logattr .Redact .Zaplog}}
+{{- end}}
+{{- if .Logattr}}
+    "github.com/f4tq/protoc-go-plugins/runtime/logattr"
+{{- end}}
+{{- if .Redact}}
+    "github.com/f4tq/protoc-go-plugins/runtime/redact"
+{{- end}}
+{{- if .Zaplog}}
+    "github.com/f4tq/protoc-go-plugins/runtime/zaplog"
+{{- end}}
+)
+`))
+
+	zapTmpl = template.Must(template.New("zap").Parse(`
+// MarshalLogObject implements zapcore.ObjectMarshaler. It adds the populated
+// fields of m to enc, with (f4tq.plugins.sensitive) fields redacted and long
+// strings, bytes, repeated and map fields summarized by packages logattr
+// and zaplog.
+func (m *{{.Name}}) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+    if m == nil {
+        return nil
+    }
+{{- range .Fields}}
+{{.}}
+{{- end}}
+    return nil
+}
+`))
+)
+
+func main() {
+	input, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	req := new(plugin.CodeGeneratorRequest)
+	if err = proto.Unmarshal(input, req); err != nil {
+		log.Fatal(err)
+	}
+
+	out, err := generate(req)
+
+	if err != nil {
+		emitError(err)
+		return
+	}
+
+	emitFiles(out)
+}
+
+func generate(req *plugin.CodeGeneratorRequest) ([]*plugin.CodeGeneratorResponse_File, error) {
+	var files []*plugin.CodeGeneratorResponse_File
+	idx := newTypeIndex(req.GetProtoFile())
+	genFileNames := make(map[string]bool)
+	for _, n := range req.FileToGenerate {
+		genFileNames[n] = true
+	}
+	for _, desc := range req.GetProtoFile() {
+		name := desc.GetName()
+		if _, ok := genFileNames[name]; !ok {
+			// Only emit output for files present in req.FileToGenerate.
+			continue
+		}
+		code, err := genCode(desc, idx, genFileNames)
+		if err != nil {
+			return nil, err
+		}
+		if code == "" {
+			// The file declares no messages.
+			continue
+		}
+		formatted, err := format.Source([]byte(code))
+		if err != nil {
+			log.Printf("%v: %s", err, code)
+			return nil, err
+		}
+
+		ext := filepath.Ext(name)
+		base := strings.TrimSuffix(name, ext)
+		output := fmt.Sprintf("%s.pb.zap.go", base)
+		files = append(files, &plugin.CodeGeneratorResponse_File{
+			Name:    proto.String(output),
+			Content: proto.String(string(formatted)),
+		})
+	}
+
+	return files, nil
+}
+
+func genCode(desc *descriptor.FileDescriptorProto, idx *typeIndex, genFileNames map[string]bool) (string, error) {
+	w := bytes.NewBuffer(nil)
+	g := &zapGen{
+		idx:     idx,
+		imports: newImportSet(desc),
+		gen:     genFileNames,
+		proto3:  desc.GetSyntax() == "proto3",
+	}
+	body := bytes.NewBuffer(nil)
+	if err := g.messages(body, "", desc.GetMessageType()); err != nil {
+		return "", err
+	}
+	if body.Len() == 0 {
+		return "", nil
+	}
+
+	hdr := &header{
+		Source:  desc.GetName(),
+		GoPkg:   defaultGoPackageName(desc),
+		Imports: g.imports.names,
+		Logattr: g.logattr,
+		Redact:  g.redact,
+		Zaplog:  g.zaplog,
+	}
+	if err := hdrTmpl.Execute(w, hdr); err != nil {
+		log.Fatal(err)
+	}
+	body.WriteTo(w)
+
+	return w.String(), nil
+}
+
+type zapGen struct {
+	idx     *typeIndex
+	imports *importSet
+	gen     map[string]bool
+	proto3  bool
+	// logattr, redact and zaplog record the runtime packages the code uses.
+	logattr bool
+	redact  bool
+	zaplog  bool
+}
+
+// messages writes the MarshalLogObject methods of msgs and of the messages
+// nested in them. prefix is the Go name of the enclosing message plus "_".
+func (g *zapGen) messages(w *bytes.Buffer, prefix string, msgs []*descriptor.DescriptorProto) error {
+	for _, msg := range msgs {
+		if msg.GetOptions().GetMapEntry() {
+			continue
+		}
+		name := prefix + msg.GetName()
+		m := &zapMessage{Name: name}
+		goNames := goname.Fields(msg)
+		for _, field := range msg.GetField() {
+			m.Fields = append(m.Fields, g.field(name, msg, goNames, field))
+		}
+		if err := zapTmpl.Execute(w, m); err != nil {
+			return err
+		}
+		if err := g.messages(w, name+"_", msg.GetNestedType()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// field returns the statements adding field of msg, whose Go name is
+// msgName and whose fields and oneofs have the Go names goNames, to enc if
+// it is populated.
+func (g *zapGen) field(msgName string, msg *descriptor.DescriptorProto, goNames map[string]string, field *descriptor.FieldDescriptorProto) string {
+	goName := goNames[field.GetName()]
+	key := fmt.Sprintf("%q", field.GetName())
+	v := "m." + goName
+	var cond string
+	switch {
+	case field.OneofIndex != nil && !field.GetProto3Optional():
+		oneof := goNames[msg.GetOneofDecl()[field.GetOneofIndex()].GetName()]
+		cond = fmt.Sprintf("v, ok := m.%s.(*%s_%s); ok", oneof, msgName, goName)
+		v = "v." + goName
+	case field.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REPEATED,
+		field.GetType() == descriptor.FieldDescriptorProto_TYPE_BYTES:
+		cond = fmt.Sprintf("len(%s) > 0", v)
+	case g.isMessage(field), field.GetProto3Optional() || !g.proto3:
+		cond = v + " != nil"
+		switch {
+		case field.GetType() == descriptor.FieldDescriptorProto_TYPE_ENUM:
+			// The String method is called on the enum, not the pointer.
+			v = "(*" + v + ")"
+		case !g.isMessage(field):
+			v = "*" + v
+		}
+	case field.GetType() == descriptor.FieldDescriptorProto_TYPE_STRING:
+		cond = v + ` != ""`
+	case field.GetType() == descriptor.FieldDescriptorProto_TYPE_BOOL:
+		cond = v
+	default:
+		cond = v + " != 0"
+	}
+	var call string
+	var fallible bool
+	switch {
+	case options.Sensitive(field):
+		g.redact = true
+		call = fmt.Sprintf("enc.AddString(%s, redact.Mask)", key)
+		cond = strings.Replace(cond, "v, ok :=", "_, ok :=", 1)
+	case g.idx.isMap(field):
+		g.zaplog = true
+		entry := g.idx.messages[field.GetTypeName()]
+		value := entry.GetField()[1]
+		call, fallible = fmt.Sprintf("enc.AddObject(%s, zaplog.Map(%s, func(enc zapcore.ObjectEncoder, k string, v %s) error {\n%s\n}))",
+			key, v, g.goType(value), g.body(value, "k", "v")), true
+	case field.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REPEATED:
+		g.zaplog = true
+		call, fallible = fmt.Sprintf("enc.AddArray(%s, zaplog.Array(%s, func(enc zapcore.ArrayEncoder, v %s) error {\n%s\n}))",
+			key, v, g.goType(field), g.body(field, "", "v")), true
+	default:
+		call, fallible = g.add(field, key, v)
+	}
+	if fallible {
+		call = fmt.Sprintf("if err := %s; err != nil {\nreturn err\n}", call)
+	}
+	return fmt.Sprintf("if %s {\n%s\n}", cond, call)
+}
+
+// body returns the body of a function literal adding the value v of field
+// to enc under key, or appending it if key is empty.
+func (g *zapGen) body(field *descriptor.FieldDescriptorProto, key, v string) string {
+	call, fallible := g.add(field, key, v)
+	if fallible {
+		return "return " + call
+	}
+	return call + "\nreturn nil"
+}
+
+// add returns the call adding the value v of field to enc under key, or
+// appending it to the array encoder enc if key is empty, and whether the
+// call returns an error.
+func (g *zapGen) add(field *descriptor.FieldDescriptorProto, key, v string) (string, bool) {
+	method, value, fallible := g.method(field, v)
+	if key == "" {
+		return fmt.Sprintf("enc.Append%s(%s)", method, value), fallible
+	}
+	return fmt.Sprintf("enc.Add%s(%s, %s)", method, key, value), fallible
+}
+
+// method returns the suffix of the encoder method taking the value v of
+// field, the argument to pass it and whether the method returns an error.
+func (g *zapGen) method(field *descriptor.FieldDescriptorProto, v string) (string, string, bool) {
+	switch field.GetType() {
+	case descriptor.FieldDescriptorProto_TYPE_MESSAGE, descriptor.FieldDescriptorProto_TYPE_GROUP:
+		if g.gen[g.idx.files[field.GetTypeName()].GetName()] {
+			return "Object", v, true
+		}
+		return "Reflected", v, true
+	case descriptor.FieldDescriptorProto_TYPE_ENUM:
+		return "String", v + ".String()", false
+	case descriptor.FieldDescriptorProto_TYPE_STRING:
+		g.logattr = true
+		return "String", fmt.Sprintf("logattr.Truncate(%s)", v), false
+	case descriptor.FieldDescriptorProto_TYPE_BYTES:
+		g.logattr = true
+		return "String", fmt.Sprintf("logattr.ByteCount(%s)", v), false
+	case descriptor.FieldDescriptorProto_TYPE_BOOL:
+		return "Bool", v, false
+	case descriptor.FieldDescriptorProto_TYPE_DOUBLE:
+		return "Float64", v, false
+	case descriptor.FieldDescriptorProto_TYPE_FLOAT:
+		return "Float32", v, false
+	case descriptor.FieldDescriptorProto_TYPE_UINT64, descriptor.FieldDescriptorProto_TYPE_FIXED64:
+		return "Uint64", v, false
+	case descriptor.FieldDescriptorProto_TYPE_UINT32, descriptor.FieldDescriptorProto_TYPE_FIXED32:
+		return "Uint32", v, false
+	case descriptor.FieldDescriptorProto_TYPE_INT64, descriptor.FieldDescriptorProto_TYPE_SINT64,
+		descriptor.FieldDescriptorProto_TYPE_SFIXED64:
+		return "Int64", v, false
+	}
+	return "Int32", v, false
+}
+
+func (g *zapGen) isMessage(field *descriptor.FieldDescriptorProto) bool {
+	return field.GetType() == descriptor.FieldDescriptorProto_TYPE_MESSAGE ||
+		field.GetType() == descriptor.FieldDescriptorProto_TYPE_GROUP
+}
+
+// goType returns the Go type of a value of field.
+func (g *zapGen) goType(field *descriptor.FieldDescriptorProto) string {
+	switch field.GetType() {
+	case descriptor.FieldDescriptorProto_TYPE_MESSAGE, descriptor.FieldDescriptorProto_TYPE_GROUP:
+		return "*" + g.imports.goTypeName(g.idx, field.GetTypeName())
+	case descriptor.FieldDescriptorProto_TYPE_ENUM:
+		return g.imports.goTypeName(g.idx, field.GetTypeName())
+	case descriptor.FieldDescriptorProto_TYPE_BYTES:
+		return "[]byte"
+	}
+	return scalarGoType(field.GetType())
+}
+
+// scalarGoType returns the Go type of the scalar type t.
+func scalarGoType(t descriptor.FieldDescriptorProto_Type) string {
+	switch t {
+	case descriptor.FieldDescriptorProto_TYPE_DOUBLE:
+		return "float64"
+	case descriptor.FieldDescriptorProto_TYPE_FLOAT:
+		return "float32"
+	case descriptor.FieldDescriptorProto_TYPE_INT64, descriptor.FieldDescriptorProto_TYPE_SINT64,
+		descriptor.FieldDescriptorProto_TYPE_SFIXED64:
+		return "int64"
+	case descriptor.FieldDescriptorProto_TYPE_UINT64, descriptor.FieldDescriptorProto_TYPE_FIXED64:
+		return "uint64"
+	case descriptor.FieldDescriptorProto_TYPE_INT32, descriptor.FieldDescriptorProto_TYPE_SINT32,
+		descriptor.FieldDescriptorProto_TYPE_SFIXED32:
+		return "int32"
+	case descriptor.FieldDescriptorProto_TYPE_UINT32, descriptor.FieldDescriptorProto_TYPE_FIXED32:
+		return "uint32"
+	case descriptor.FieldDescriptorProto_TYPE_BOOL:
+		return "bool"
+	}
+	return "string"
+}
+
+type header struct {
+	Source  string
+	GoPkg   string
+	Imports map[string]string
+	Logattr bool
+	Redact  bool
+	Zaplog  bool
+}
+
+type zapMessage struct {
+	Name   string
+	Fields []string
+}
+
+// typeIndex maps fully-qualified proto type names (".pkg.Msg") to their
+// descriptors and declaring files for every file in the request.
+type typeIndex struct {
+	messages map[string]*descriptor.DescriptorProto
+	enums    map[string]*descriptor.EnumDescriptorProto
+	files    map[string]*descriptor.FileDescriptorProto
+}
+
+func newTypeIndex(files []*descriptor.FileDescriptorProto) *typeIndex {
+	idx := &typeIndex{
+		messages: make(map[string]*descriptor.DescriptorProto),
+		enums:    make(map[string]*descriptor.EnumDescriptorProto),
+		files:    make(map[string]*descriptor.FileDescriptorProto),
+	}
+	for _, f := range files {
+		prefix := ""
+		if f.GetPackage() != "" {
+			prefix = "." + f.GetPackage()
+		}
+		idx.addMessages(f, prefix, f.GetMessageType())
+		idx.addEnums(f, prefix, f.GetEnumType())
+	}
+	return idx
+}
+
+func (idx *typeIndex) addMessages(f *descriptor.FileDescriptorProto, prefix string, msgs []*descriptor.DescriptorProto) {
+	for _, m := range msgs {
+		name := prefix + "." + m.GetName()
+		idx.messages[name] = m
+		idx.files[name] = f
+		idx.addMessages(f, name, m.GetNestedType())
+		idx.addEnums(f, name, m.GetEnumType())
+	}
+}
+
+func (idx *typeIndex) addEnums(f *descriptor.FileDescriptorProto, prefix string, enums []*descriptor.EnumDescriptorProto) {
+	for _, e := range enums {
+		name := prefix + "." + e.GetName()
+		idx.enums[name] = e
+		idx.files[name] = f
+	}
+}
+
+// isMap reports whether field is a map field.
+func (idx *typeIndex) isMap(field *descriptor.FieldDescriptorProto) bool {
+	if field.GetType() != descriptor.FieldDescriptorProto_TYPE_MESSAGE {
+		return false
+	}
+	return idx.messages[field.GetTypeName()].GetOptions().GetMapEntry()
+}
+
+// goImportPath returns the import path of the Go package generated from f,
+// or "" if go_package does not name one.
+func goImportPath(f *descriptor.FileDescriptorProto) string {
+	gopkg := f.GetOptions().GetGoPackage()
+	if sc := strings.IndexByte(gopkg, ';'); sc >= 0 {
+		gopkg = gopkg[:sc]
+	}
+	if !strings.Contains(gopkg, "/") {
+		return ""
+	}
+	return gopkg
+}
+
+// importSet records the Go packages referenced by the code generated for
+// one file, keyed by import path.
+type importSet struct {
+	self  string
+	names map[string]string
+}
+
+func newImportSet(f *descriptor.FileDescriptorProto) *importSet {
+	return &importSet{self: goImportPath(f), names: make(map[string]string)}
+}
+
+// goTypeName returns the Go name of the message or enum typeName, qualified
+// with its package if it is declared outside the generated package.
+func (s *importSet) goTypeName(idx *typeIndex, typeName string) string {
+	name := localTypeName(typeName)
+	f := idx.files[typeName]
+	path := goImportPath(f)
+	if f == nil || path == "" || path == s.self {
+		return name
+	}
+	pkg := defaultGoPackageName(f)
+	s.names[path] = pkg
+	return pkg + "." + name
+}
+
+// localTypeName returns the Go name of a type declared in the file being
+// generated, e.g. ".pkg.Outer.Inner" becomes "Outer_Inner".
+func localTypeName(typeName string) string {
+	parts := strings.Split(strings.TrimPrefix(typeName, "."), ".")
+	var names []string
+	for _, p := range parts {
+		if len(p) > 0 && p[0] >= 'A' && p[0] <= 'Z' {
+			names = append(names, p)
+		}
+	}
+	return strings.Join(names, "_")
+}
+
+// sanitizePackageName replaces unallowed character in package name
+// with allowed character.
+func sanitizePackageName(pkgName string) string {
+	pkgName = strings.Replace(pkgName, ".", "_", -1)
+	pkgName = strings.Replace(pkgName, "-", "_", -1)
+	return pkgName
+}
+
+// defaultGoPackageName returns the default go package name to be used for go files generated from "f".
+// You might need to use an unique alias for the package when you import it.  Use ReserveGoPackageAlias to get a unique alias.
+func defaultGoPackageName(f *descriptor.FileDescriptorProto) string {
+	name := packageIdentityName(f)
+	return sanitizePackageName(name)
+}
+
+// packageIdentityName returns the identity of packages.
+// protoc-gen-grpc-gateway rejects CodeGenerationRequests which contains more than one packages
+// as protoc-gen-go does.
+func packageIdentityName(f *descriptor.FileDescriptorProto) string {
+	if f.Options != nil && f.Options.GoPackage != nil {
+		gopkg := f.Options.GetGoPackage()
+		idx := strings.LastIndex(gopkg, "/")
+		if idx < 0 {
+			gopkg = gopkg[idx+1:]
+		}
+
+		gopkg = gopkg[idx+1:]
+		// package name is overrided with the string after the
+		// ';' character
+		sc := strings.IndexByte(gopkg, ';')
+		if sc < 0 {
+			return sanitizePackageName(gopkg)
+
+		}
+		return sanitizePackageName(gopkg[sc+1:])
+	}
+
+	if f.Package == nil {
+		base := filepath.Base(f.GetName())
+		ext := filepath.Ext(base)
+		return strings.TrimSuffix(base, ext)
+	}
+	return f.GetPackage()
+}
+
+func emitFiles(out []*plugin.CodeGeneratorResponse_File) {
+	emitResp(&plugin.CodeGeneratorResponse{File: out})
+}
+
+func emitError(err error) {
+	emitResp(&plugin.CodeGeneratorResponse{Error: proto.String(err.Error())})
+}
+
+func emitResp(resp *plugin.CodeGeneratorResponse) {
+	buf, err := proto.Marshal(resp)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if _, err := os.Stdout.Write(buf); err != nil {
+		log.Fatal(err)
+	}
+}
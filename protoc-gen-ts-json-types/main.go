@@ -0,0 +1,544 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+
+	"github.com/f4tq/protoc-go-plugins/protodoc"
+)
+
+func main() {
+	input, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	req := new(plugin.CodeGeneratorRequest)
+	if err = proto.Unmarshal(input, req); err != nil {
+		log.Fatal(err)
+	}
+
+	out, err := generate(req)
+
+	if err != nil {
+		emitError(err)
+		return
+	}
+
+	emitFiles(out)
+}
+
+// generate writes <name>.jsontypes.d.ts for each file to generate: the
+// TypeScript types of the JSON the jsonpb marshalers write for its
+// messages and enums. The emit_defaults and orig_name parameters mirror
+// the options of the jsonpb.Marshaler the JSON is written with.
+func generate(req *plugin.CodeGeneratorRequest) ([]*plugin.CodeGeneratorResponse_File, error) {
+	params := parseParams(req.GetParameter())
+	opts := &marshalOptions{}
+	for k, v := range params {
+		b := v == "" || v == "true"
+		switch k {
+		case "emit_defaults":
+			opts.EmitDefaults = b
+		case "orig_name":
+			opts.OrigName = b
+		default:
+			return nil, fmt.Errorf("unknown parameter %q", k)
+		}
+	}
+	var files []*plugin.CodeGeneratorResponse_File
+	idx := newTypeIndex(req.GetProtoFile())
+	docs := protodoc.New(req.GetProtoFile())
+	genFileNames := make(map[string]bool)
+	for _, n := range req.FileToGenerate {
+		genFileNames[n] = true
+	}
+	for _, desc := range req.GetProtoFile() {
+		name := desc.GetName()
+		if _, ok := genFileNames[name]; !ok {
+			// Only emit output for files present in req.FileToGenerate.
+			continue
+		}
+		code, err := genCode(desc, idx, docs, opts)
+		if err != nil {
+			return nil, err
+		}
+		if code == "" {
+			// The file declares no messages or enums.
+			continue
+		}
+		files = append(files, &plugin.CodeGeneratorResponse_File{
+			Name:    proto.String(tsModule(name) + ".d.ts"),
+			Content: proto.String(code),
+		})
+	}
+
+	return files, nil
+}
+
+// marshalOptions are the options of jsonpb.Marshaler that change the shape
+// of the JSON.
+type marshalOptions struct {
+	// EmitDefaults writes the fields with zero values, and null for unset
+	// messages.
+	EmitDefaults bool
+	// OrigName names the fields by their proto names rather than their
+	// JSON names.
+	OrigName bool
+}
+
+func genCode(desc *descriptor.FileDescriptorProto, idx *typeIndex, docs *protodoc.Index, opts *marshalOptions) (string, error) {
+	g := &tsGen{
+		desc:    desc,
+		idx:     idx,
+		docs:    docs,
+		opts:    opts,
+		imports: make(map[string]string),
+		body:    bytes.NewBuffer(nil),
+	}
+	scope := strings.TrimSuffix("."+desc.GetPackage(), ".")
+	if err := g.messages(scope, desc.GetMessageType()); err != nil {
+		return "", fmt.Errorf("%s: %v", desc.GetName(), err)
+	}
+	g.enums(scope, desc.GetEnumType())
+	if g.body.Len() == 0 {
+		return "", nil
+	}
+
+	w := bytes.NewBuffer(nil)
+	fmt.Fprintf(w, "// Code generated by protoc-gen-ts-json-types. DO NOT EDIT.\n// source: %s\n", desc.GetName())
+	if len(g.imports) > 0 {
+		var modules []string
+		for m := range g.imports {
+			modules = append(modules, m)
+		}
+		sort.Strings(modules)
+		w.WriteString("\n")
+		for _, m := range modules {
+			fmt.Fprintf(w, "import type * as %s from %s;\n", g.imports[m], strconv.Quote(m))
+		}
+	}
+	g.body.WriteTo(w)
+	return w.String(), nil
+}
+
+type tsGen struct {
+	desc *descriptor.FileDescriptorProto
+	idx  *typeIndex
+	// docs holds the comments of the declarations.
+	docs *protodoc.Index
+	opts *marshalOptions
+	// imports maps the modules of the other files referred to to their
+	// aliases.
+	imports map[string]string
+	body    *bytes.Buffer
+}
+
+// messages writes the interfaces of msgs, and the types of the messages
+// and enums nested in them. scope is the full proto name of their parent.
+func (g *tsGen) messages(scope string, msgs []*descriptor.DescriptorProto) error {
+	for _, msg := range msgs {
+		if msg.GetOptions().GetMapEntry() {
+			continue
+		}
+		typeName := scope + "." + msg.GetName()
+		g.doc("", g.docs.Comment(typeName), msg.GetOptions().GetDeprecated())
+		fmt.Fprintf(g.body, "export interface %s {\n", g.localName(typeName))
+		for _, field := range msg.GetField() {
+			t, err := g.fieldType(field)
+			if err != nil {
+				return fmt.Errorf("%s.%s: %v", strings.TrimPrefix(typeName, "."), field.GetName(), err)
+			}
+			comment := g.docs.Comment(typeName + "." + field.GetName())
+			optional := "?"
+			switch {
+			case field.OneofIndex != nil:
+				// jsonpb only writes the set member of a oneof.
+				if !field.GetProto3Optional() {
+					oneof := msg.GetOneofDecl()[field.GetOneofIndex()].GetName()
+					comment = strings.TrimRight(comment, "\n") + fmt.Sprintf("\n\nMember of the oneof %s, of which at most one field is set.", oneof)
+				}
+			case g.opts.EmitDefaults:
+				optional = ""
+				if (field.GetType() == descriptor.FieldDescriptorProto_TYPE_MESSAGE ||
+					field.GetType() == descriptor.FieldDescriptorProto_TYPE_GROUP) &&
+					field.GetLabel() != descriptor.FieldDescriptorProto_LABEL_REPEATED {
+					t += " | null"
+				}
+			}
+			g.doc("  ", comment, field.GetOptions().GetDeprecated())
+			fmt.Fprintf(g.body, "  %s%s: %s;\n", tsKey(g.fieldName(field)), optional, t)
+		}
+		g.body.WriteString("}\n")
+		if err := g.messages(typeName, msg.GetNestedType()); err != nil {
+			return err
+		}
+		g.enums(typeName, msg.GetEnumType())
+	}
+	return nil
+}
+
+// enums writes the types of enums, the unions of the names of their
+// values, as messages does.
+func (g *tsGen) enums(scope string, enums []*descriptor.EnumDescriptorProto) {
+	for _, enum := range enums {
+		typeName := scope + "." + enum.GetName()
+		g.doc("", g.docs.Comment(typeName), enum.GetOptions().GetDeprecated())
+		var names []string
+		for _, v := range enum.GetValue() {
+			names = append(names, strconv.Quote(v.GetName()))
+		}
+		if len(names) == 0 {
+			names = []string{"never"}
+		}
+		fmt.Fprintf(g.body, "export type %s =\n  | %s;\n", g.localName(typeName), strings.Join(names, "\n  | "))
+	}
+}
+
+// doc writes the JSDoc of a declaration indented by indent, after a blank
+// line for top-level declarations.
+func (g *tsGen) doc(indent, comment string, deprecated bool) {
+	if indent == "" {
+		g.body.WriteString("\n")
+	}
+	lines := strings.Split(comment, "\n")
+	for len(lines) > 0 && lines[0] == "" {
+		lines = lines[1:]
+	}
+	if deprecated {
+		if len(lines) > 0 {
+			lines = append(lines, "")
+		}
+		lines = append(lines, "@deprecated")
+	}
+	if len(lines) == 0 {
+		return
+	}
+	fmt.Fprintf(g.body, "%s/**\n", indent)
+	for _, l := range lines {
+		l = strings.Replace(l, "*/", "*\\/", -1)
+		if l == "" {
+			fmt.Fprintf(g.body, "%s *\n", indent)
+			continue
+		}
+		fmt.Fprintf(g.body, "%s * %s\n", indent, l)
+	}
+	fmt.Fprintf(g.body, "%s */\n", indent)
+}
+
+// fieldName returns the key jsonpb writes field under.
+func (g *tsGen) fieldName(field *descriptor.FieldDescriptorProto) string {
+	if g.opts.OrigName {
+		return field.GetName()
+	}
+	return jsonName(field)
+}
+
+// fieldType returns the TypeScript type of the JSON value of field.
+func (g *tsGen) fieldType(field *descriptor.FieldDescriptorProto) (string, error) {
+	if g.idx.isMap(field) {
+		entry := g.idx.messages[field.GetTypeName()]
+		v, err := g.valueType(entry.GetField()[1])
+		if err != nil {
+			return "", err
+		}
+		// jsonpb writes the keys of all types as strings.
+		return fmt.Sprintf("{ [key: string]: %s }", v), nil
+	}
+	t, err := g.valueType(field)
+	if err != nil {
+		return "", err
+	}
+	if field.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REPEATED {
+		if strings.ContainsAny(t, " |") {
+			t = "(" + t + ")"
+		}
+		t += "[]"
+	}
+	return t, nil
+}
+
+// wellKnownTypes are the types of the JSON of the well-known types, which
+// jsonpb encodes specially.
+var wellKnownTypes = map[string]string{
+	".google.protobuf.Timestamp":   "string",
+	".google.protobuf.Duration":    "string",
+	".google.protobuf.FieldMask":   "string",
+	".google.protobuf.Empty":       "{}",
+	".google.protobuf.Struct":      "{ [key: string]: unknown }",
+	".google.protobuf.Value":       "unknown",
+	".google.protobuf.ListValue":   "unknown[]",
+	".google.protobuf.Any":         `{ "@type": string; [key: string]: unknown }`,
+	".google.protobuf.DoubleValue": "number | \"NaN\" | \"Infinity\" | \"-Infinity\"",
+	".google.protobuf.FloatValue":  "number | \"NaN\" | \"Infinity\" | \"-Infinity\"",
+	".google.protobuf.Int64Value":  "string",
+	".google.protobuf.UInt64Value": "string",
+	".google.protobuf.Int32Value":  "number",
+	".google.protobuf.UInt32Value": "number",
+	".google.protobuf.BoolValue":   "boolean",
+	".google.protobuf.StringValue": "string",
+	".google.protobuf.BytesValue":  "string",
+}
+
+// valueType returns the TypeScript type of the JSON of a value of field.
+func (g *tsGen) valueType(field *descriptor.FieldDescriptorProto) (string, error) {
+	switch field.GetType() {
+	case descriptor.FieldDescriptorProto_TYPE_DOUBLE,
+		descriptor.FieldDescriptorProto_TYPE_FLOAT:
+		// jsonpb writes the non-finite values as strings.
+		return "number | \"NaN\" | \"Infinity\" | \"-Infinity\"", nil
+	case descriptor.FieldDescriptorProto_TYPE_INT32,
+		descriptor.FieldDescriptorProto_TYPE_SINT32,
+		descriptor.FieldDescriptorProto_TYPE_SFIXED32,
+		descriptor.FieldDescriptorProto_TYPE_UINT32,
+		descriptor.FieldDescriptorProto_TYPE_FIXED32:
+		return "number", nil
+	case descriptor.FieldDescriptorProto_TYPE_INT64,
+		descriptor.FieldDescriptorProto_TYPE_SINT64,
+		descriptor.FieldDescriptorProto_TYPE_SFIXED64,
+		descriptor.FieldDescriptorProto_TYPE_UINT64,
+		descriptor.FieldDescriptorProto_TYPE_FIXED64:
+		// jsonpb writes 64-bit integers as strings, which JavaScript
+		// numbers cannot hold exactly.
+		return "string", nil
+	case descriptor.FieldDescriptorProto_TYPE_BOOL:
+		return "boolean", nil
+	case descriptor.FieldDescriptorProto_TYPE_STRING:
+		return "string", nil
+	case descriptor.FieldDescriptorProto_TYPE_BYTES:
+		// In base64.
+		return "string", nil
+	case descriptor.FieldDescriptorProto_TYPE_ENUM,
+		descriptor.FieldDescriptorProto_TYPE_MESSAGE,
+		descriptor.FieldDescriptorProto_TYPE_GROUP:
+		// jsonpb writes groups as the messages they are.
+		if t, ok := wellKnownTypes[field.GetTypeName()]; ok {
+			return t, nil
+		}
+		return g.typeRef(field.GetTypeName())
+	default:
+		return "", fmt.Errorf("unsupported field type %v", field.GetType())
+	}
+}
+
+// typeRef returns the reference to the type of the message or enum
+// typeName, importing the module of the file declaring it if needed.
+func (g *tsGen) typeRef(typeName string) (string, error) {
+	f := g.idx.files[typeName]
+	if f == nil {
+		return "", fmt.Errorf("unknown type %s", typeName)
+	}
+	local := localTypeName(f, typeName)
+	if f == g.desc {
+		return local, nil
+	}
+	rel := relativeModule(g.desc.GetName(), f.GetName())
+	alias, ok := g.imports[rel]
+	if !ok {
+		alias = strings.Map(func(r rune) rune {
+			if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+				return r
+			}
+			return '_'
+		}, tsModule(f.GetName()))
+		g.imports[rel] = alias
+	}
+	return alias + "." + local, nil
+}
+
+func (g *tsGen) localName(typeName string) string {
+	return localTypeName(g.desc, typeName)
+}
+
+// localTypeName returns the name of the type of typeName, declared in f:
+// its name relative to the package, with "_" separating nested names as in
+// the Go types.
+func localTypeName(f *descriptor.FileDescriptorProto, typeName string) string {
+	name := strings.TrimPrefix(typeName, ".")
+	if f.GetPackage() != "" {
+		name = strings.TrimPrefix(name, f.GetPackage()+".")
+	}
+	return strings.Replace(name, ".", "_", -1)
+}
+
+// tsModule returns the module path of the types of the proto file name.
+func tsModule(name string) string {
+	return strings.TrimSuffix(name, path.Ext(name)) + ".jsontypes"
+}
+
+// relativeModule returns the module of the types of the proto file to, as
+// imported by those of from.
+func relativeModule(from, to string) string {
+	fromDir := strings.Split(path.Dir(from), "/")
+	toParts := strings.Split(tsModule(to), "/")
+	if fromDir[0] == "." {
+		fromDir = nil
+	}
+	i := 0
+	for i < len(fromDir) && i < len(toParts)-1 && fromDir[i] == toParts[i] {
+		i++
+	}
+	rel := strings.Repeat("../", len(fromDir)-i) + strings.Join(toParts[i:], "/")
+	if !strings.HasPrefix(rel, "../") {
+		rel = "./" + rel
+	}
+	return rel
+}
+
+// tsKey returns name as the key of an interface member, quoted unless it
+// is an identifier.
+func tsKey(name string) string {
+	for i, r := range name {
+		if !(r == '_' || r == '$' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || i > 0 && r >= '0' && r <= '9') {
+			return strconv.Quote(name)
+		}
+	}
+	return name
+}
+
+// jsonName returns the JSON name of field, which protoc sets, or else the
+// lower camel case of its name.
+func jsonName(field *descriptor.FieldDescriptorProto) string {
+	if field.GetJsonName() != "" {
+		return field.GetJsonName()
+	}
+	name := camelCase(field.GetName())
+	return strings.ToLower(name[:1]) + name[1:]
+}
+
+// typeIndex maps fully-qualified proto type names (".pkg.Msg") to their
+// descriptors and declaring files for every file in the request.
+type typeIndex struct {
+	messages map[string]*descriptor.DescriptorProto
+	enums    map[string]*descriptor.EnumDescriptorProto
+	files    map[string]*descriptor.FileDescriptorProto
+}
+
+func newTypeIndex(files []*descriptor.FileDescriptorProto) *typeIndex {
+	idx := &typeIndex{
+		messages: make(map[string]*descriptor.DescriptorProto),
+		enums:    make(map[string]*descriptor.EnumDescriptorProto),
+		files:    make(map[string]*descriptor.FileDescriptorProto),
+	}
+	for _, f := range files {
+		prefix := ""
+		if f.GetPackage() != "" {
+			prefix = "." + f.GetPackage()
+		}
+		idx.addMessages(f, prefix, f.GetMessageType())
+		idx.addEnums(f, prefix, f.GetEnumType())
+	}
+	return idx
+}
+
+func (idx *typeIndex) addMessages(f *descriptor.FileDescriptorProto, prefix string, msgs []*descriptor.DescriptorProto) {
+	for _, m := range msgs {
+		name := prefix + "." + m.GetName()
+		idx.messages[name] = m
+		idx.files[name] = f
+		idx.addMessages(f, name, m.GetNestedType())
+		idx.addEnums(f, name, m.GetEnumType())
+	}
+}
+
+func (idx *typeIndex) addEnums(f *descriptor.FileDescriptorProto, prefix string, enums []*descriptor.EnumDescriptorProto) {
+	for _, e := range enums {
+		name := prefix + "." + e.GetName()
+		idx.enums[name] = e
+		idx.files[name] = f
+	}
+}
+
+// isMap reports whether field is a map field.
+func (idx *typeIndex) isMap(field *descriptor.FieldDescriptorProto) bool {
+	if field.GetType() != descriptor.FieldDescriptorProto_TYPE_MESSAGE {
+		return false
+	}
+	return idx.messages[field.GetTypeName()].GetOptions().GetMapEntry()
+}
+
+// parseParams splits the comma separated key=value plugin parameter.
+func parseParams(param string) map[string]string {
+	params := make(map[string]string)
+	for _, p := range strings.Split(param, ",") {
+		if p == "" {
+			continue
+		}
+		if i := strings.IndexByte(p, '='); i >= 0 {
+			params[p[:i]] = p[i+1:]
+		} else {
+			params[p] = ""
+		}
+	}
+	return params
+}
+
+// camelCase returns the Go field name protoc-gen-go derives from s.
+func camelCase(s string) string {
+	if s == "" {
+		return ""
+	}
+	t := make([]byte, 0, 32)
+	i := 0
+	if s[0] == '_' {
+		// Need a capital letter; drop the '_'.
+		t = append(t, 'X')
+		i++
+	}
+	for ; i < len(s); i++ {
+		c := s[i]
+		if c == '_' && i+1 < len(s) && isASCIILower(s[i+1]) {
+			continue
+		}
+		if isASCIIDigit(c) {
+			t = append(t, c)
+			continue
+		}
+		if isASCIILower(c) {
+			c ^= ' '
+		}
+		t = append(t, c)
+		for i+1 < len(s) && isASCIILower(s[i+1]) {
+			i++
+			t = append(t, s[i])
+		}
+	}
+	return string(t)
+}
+
+func isASCIILower(c byte) bool {
+	return 'a' <= c && c <= 'z'
+}
+
+func isASCIIDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+func emitFiles(out []*plugin.CodeGeneratorResponse_File) {
+	emitResp(&plugin.CodeGeneratorResponse{File: out})
+}
+
+func emitError(err error) {
+	emitResp(&plugin.CodeGeneratorResponse{Error: proto.String(err.Error())})
+}
+
+func emitResp(resp *plugin.CodeGeneratorResponse) {
+	buf, err := proto.Marshal(resp)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if _, err := os.Stdout.Write(buf); err != nil {
+		log.Fatal(err)
+	}
+}
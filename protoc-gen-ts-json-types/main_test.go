@@ -0,0 +1,76 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/f4tq/protoc-go-plugins/internal/plugintest"
+)
+
+const groupProto = `
+syntax = "proto2";
+
+package doc.v1;
+
+option go_package = "example.com/doc/v1;docv1";
+
+message Doc {
+    optional group Meta = 1 {
+        optional string author = 2;
+    }
+    repeated group Part = 3 {
+        optional bytes data = 4;
+    }
+}
+`
+
+// TestGroups checks group fields have the types of their messages.
+func TestGroups(t *testing.T) {
+	tests := []struct {
+		param string
+		want  []string
+	}{
+		{"", []string{"export interface Doc_Meta {", "meta?: Doc_Meta;", "part?: Doc_Part[];"}},
+		{"emit_defaults", []string{"meta: Doc_Meta | null;", "part: Doc_Part[];"}},
+	}
+	for _, test := range tests {
+		t.Run(test.param, func(t *testing.T) {
+			req := plugintest.Request(t, test.param, map[string]string{"doc/v1/doc.proto": groupProto})
+			got := plugintest.Generate(t, req, generate)["doc/v1/doc.jsontypes.d.ts"]
+			for _, want := range test.want {
+				if !strings.Contains(got, want) {
+					t.Errorf("types do not contain %q:\n%s", want, got)
+				}
+			}
+		})
+	}
+}
+
+const commentedProto = `
+syntax = "proto3";
+
+package doc.v1;
+
+option go_package = "example.com/doc/v1;docv1";
+
+// Doc is a document.
+message Doc {
+    // title names the document.
+    string title = 1; // Shown in lists.
+}
+`
+
+// TestComments checks the types carry the leading and trailing comments of
+// the declarations.
+func TestComments(t *testing.T) {
+	req := plugintest.Request(t, "", map[string]string{"doc/v1/doc.proto": commentedProto})
+	got := plugintest.Generate(t, req, generate)["doc/v1/doc.jsontypes.d.ts"]
+	for _, want := range []string{
+		" * Doc is a document.\n */\nexport interface Doc {",
+		"   * title names the document.\n   *\n   * Shown in lists.\n   */\n  title?: string;",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("the types does not contain %q:\n%s", want, got)
+		}
+	}
+}
@@ -0,0 +1,193 @@
+// Package protodoc carries the comments of proto declarations over to the
+// Go declarations the plugins in this repo generate for them, so that the
+// documentation of a schema shows in godoc and in IDE tooltips.
+package protodoc
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+// Index holds the comments and deprecation of the declarations of a set of
+// files by full name, e.g. "example.v1.User", "example.v1.User.email",
+// "example.v1.User.contact" for a oneof, "example.v1.Status.STATUS_ACTIVE"
+// for an enum value or "example.v1.Users.GetUser" for a method. Names may
+// also be given with the leading dot of descriptor type names.
+//
+// A nil *Index has no comments.
+type Index struct {
+	docs map[string]*doc
+}
+
+type doc struct {
+	// comment is the leading comment of the declaration, followed by its
+	// trailing one, without the comment markers.
+	comment    string
+	deprecated bool
+}
+
+// New returns the Index of the declarations of files. Comments come from
+// the source info protoc includes for the files to generate.
+func New(files []*descriptor.FileDescriptorProto) *Index {
+	idx := &Index{docs: make(map[string]*doc)}
+	for _, f := range files {
+		comments := make(map[string]string)
+		for _, loc := range f.GetSourceCodeInfo().GetLocation() {
+			var parts []string
+			for _, c := range []string{loc.GetLeadingComments(), loc.GetTrailingComments()} {
+				if c = clean(c); c != "" {
+					parts = append(parts, c)
+				}
+			}
+			if len(parts) > 0 {
+				comments[fmt.Sprint(loc.GetPath())] = strings.Join(parts, "\n\n")
+			}
+		}
+		b := &builder{idx: idx, comments: comments}
+		b.messages(f.GetPackage(), []int32{4}, f.GetMessageType())
+		b.enums(f.GetPackage(), []int32{5}, f.GetEnumType())
+		for i, svc := range f.GetService() {
+			path := []int32{6, int32(i)}
+			name := qualify(f.GetPackage(), svc.GetName())
+			b.add(name, path, svc.GetOptions().GetDeprecated())
+			for j, m := range svc.GetMethod() {
+				b.add(name+"."+m.GetName(), appendPath(path, 2, int32(j)), m.GetOptions().GetDeprecated())
+			}
+		}
+	}
+	return idx
+}
+
+type builder struct {
+	idx *Index
+	// comments maps the source paths of the declarations of a file to
+	// their comments.
+	comments map[string]string
+}
+
+func (b *builder) messages(scope string, path []int32, msgs []*descriptor.DescriptorProto) {
+	for i, msg := range msgs {
+		msgPath := appendPath(path, int32(i))
+		name := qualify(scope, msg.GetName())
+		b.add(name, msgPath, msg.GetOptions().GetDeprecated())
+		for j, field := range msg.GetField() {
+			b.add(name+"."+field.GetName(), appendPath(msgPath, 2, int32(j)), field.GetOptions().GetDeprecated())
+		}
+		for j, oneof := range msg.GetOneofDecl() {
+			b.add(name+"."+oneof.GetName(), appendPath(msgPath, 8, int32(j)), false)
+		}
+		b.messages(name, appendPath(msgPath, 3), msg.GetNestedType())
+		b.enums(name, appendPath(msgPath, 4), msg.GetEnumType())
+	}
+}
+
+func (b *builder) enums(scope string, path []int32, enums []*descriptor.EnumDescriptorProto) {
+	for i, enum := range enums {
+		enumPath := appendPath(path, int32(i))
+		name := qualify(scope, enum.GetName())
+		b.add(name, enumPath, enum.GetOptions().GetDeprecated())
+		for j, v := range enum.GetValue() {
+			b.add(name+"."+v.GetName(), appendPath(enumPath, 2, int32(j)), v.GetOptions().GetDeprecated())
+		}
+	}
+}
+
+func (b *builder) add(name string, path []int32, deprecated bool) {
+	c := b.comments[fmt.Sprint(path)]
+	if c == "" && !deprecated {
+		return
+	}
+	b.idx.docs[name] = &doc{comment: c, deprecated: deprecated}
+}
+
+func (idx *Index) lookup(name string) *doc {
+	if idx == nil {
+		return nil
+	}
+	return idx.docs[strings.TrimPrefix(name, ".")]
+}
+
+// Comment returns the comment of the declaration name, without the comment
+// markers, or "".
+func (idx *Index) Comment(name string) string {
+	if d := idx.lookup(name); d != nil {
+		return d.comment
+	}
+	return ""
+}
+
+// Deprecated reports whether the declaration name has the deprecated
+// option.
+func (idx *Index) Deprecated(name string) bool {
+	d := idx.lookup(name)
+	return d != nil && d.deprecated
+}
+
+// Godoc returns the comment of the declaration name as further paragraphs
+// of the doc comment of a Go declaration generated for it: each line is
+// preceded by "\n", so that templates can write it right after the first
+// paragraph the plugin writes, which it leaves unterminated. A Deprecated
+// paragraph is added for declarations with the deprecated option whose
+// comment has none. Godoc returns "" for the declarations without comment
+// that are not deprecated.
+func (idx *Index) Godoc(name string) string {
+	d := idx.lookup(name)
+	if d == nil {
+		return ""
+	}
+	var lines []string
+	if d.comment != "" {
+		lines = append(lines, "")
+		for _, l := range strings.Split(d.comment, "\n") {
+			// Deprecated notes only count as such in paragraphs of their own.
+			if strings.HasPrefix(l, "Deprecated: ") && lines[len(lines)-1] != "" {
+				lines = append(lines, "")
+			}
+			lines = append(lines, l)
+		}
+	}
+	if d.deprecated && !hasDeprecated(lines) {
+		lines = append(lines, "", "Deprecated: Do not use.")
+	}
+	var b strings.Builder
+	for _, l := range lines {
+		b.WriteString("\n//")
+		if l != "" {
+			b.WriteString(" " + l)
+		}
+	}
+	return b.String()
+}
+
+func hasDeprecated(lines []string) bool {
+	for i, l := range lines {
+		if strings.HasPrefix(l, "Deprecated: ") && (i == 0 || lines[i-1] == "") {
+			return true
+		}
+	}
+	return false
+}
+
+// clean strips the leading space protoc leaves on the lines of the comment
+// c, and its surrounding blank lines.
+func clean(c string) string {
+	lines := strings.Split(strings.TrimRight(c, "\n"), "\n")
+	for i, l := range lines {
+		lines[i] = strings.TrimPrefix(strings.TrimRight(l, " \t"), " ")
+	}
+	return strings.Trim(strings.Join(lines, "\n"), "\n")
+}
+
+// qualify returns the full proto name of name, declared in scope.
+func qualify(scope, name string) string {
+	if scope == "" {
+		return name
+	}
+	return scope + "." + name
+}
+
+func appendPath(path []int32, elems ...int32) []int32 {
+	return append(append([]int32(nil), path...), elems...)
+}
@@ -0,0 +1,117 @@
+package protodoc
+
+import (
+	"testing"
+
+	"github.com/f4tq/protoc-go-plugins/internal/plugintest"
+)
+
+const userProto = `
+syntax = "proto3";
+
+package example.v1;
+
+option go_package = "example.com/example/v1;examplev1";
+
+// User is a user.
+//
+// Deprecated: Use Account.
+message User {
+    option deprecated = true;
+
+    // email is where to write.
+    string email = 1; // Checked on sign-up.
+    string name = 2 [deprecated = true];
+
+    // contact is how to reach the user.
+    oneof contact {
+        string phone = 3;
+    }
+}
+
+// Status is the status of an account.
+enum Status {
+    STATUS_UNSPECIFIED = 0;
+    // The account can sign in.
+    STATUS_ACTIVE = 1;
+}
+
+service Users {
+    // GetUser returns a user.
+    rpc GetUser(User) returns (User);
+}
+`
+
+func index(t *testing.T) *Index {
+	t.Helper()
+	req := plugintest.Request(t, "", map[string]string{"example/v1/user.proto": userProto})
+	return New(req.GetProtoFile())
+}
+
+func TestComment(t *testing.T) {
+	idx := index(t)
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"example.v1.User", "User is a user.\n\nDeprecated: Use Account."},
+		{".example.v1.User", "User is a user.\n\nDeprecated: Use Account."},
+		{"example.v1.User.email", "email is where to write.\n\nChecked on sign-up."},
+		{"example.v1.User.name", ""},
+		{"example.v1.User.contact", "contact is how to reach the user."},
+		{"example.v1.Status.STATUS_ACTIVE", "The account can sign in."},
+		{"example.v1.Status.STATUS_UNSPECIFIED", ""},
+		{"example.v1.Users.GetUser", "GetUser returns a user."},
+		{"example.v1.Missing", ""},
+	}
+	for _, test := range tests {
+		if got := idx.Comment(test.name); got != test.want {
+			t.Errorf("Comment(%q) = %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestDeprecated(t *testing.T) {
+	idx := index(t)
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{"example.v1.User", true},
+		{"example.v1.User.name", true},
+		{".example.v1.User.name", true},
+		{"example.v1.User.email", false},
+		{"example.v1.Status", false},
+	}
+	for _, test := range tests {
+		if got := idx.Deprecated(test.name); got != test.want {
+			t.Errorf("Deprecated(%q) = %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestGodoc(t *testing.T) {
+	idx := index(t)
+	tests := []struct {
+		name string
+		want string
+	}{
+		// The Deprecated paragraph of the comment is not repeated.
+		{"example.v1.User", "\n//\n// User is a user.\n//\n// Deprecated: Use Account."},
+		{"example.v1.User.email", "\n//\n// email is where to write.\n//\n// Checked on sign-up."},
+		{"example.v1.User.name", "\n//\n// Deprecated: Do not use."},
+		{"example.v1.Status.STATUS_UNSPECIFIED", ""},
+	}
+	for _, test := range tests {
+		if got := idx.Godoc(test.name); got != test.want {
+			t.Errorf("Godoc(%q) = %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestNilIndex(t *testing.T) {
+	var idx *Index
+	if idx.Comment("example.v1.User") != "" || idx.Deprecated("example.v1.User") || idx.Godoc("example.v1.User") != "" {
+		t.Error("a nil Index has docs")
+	}
+}
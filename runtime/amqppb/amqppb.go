@@ -0,0 +1,108 @@
+// Package amqppb is the runtime support for code generated by
+// protoc-gen-go-amqp. It publishes protobuf messages to AMQP exchanges,
+// declares the exchanges and queues they are routed through, and consumes
+// them with explicit acknowledgements.
+package amqppb
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+	"github.com/streadway/amqp"
+)
+
+// Codecs encoding message bodies, named by the content type of each
+// message.
+const (
+	ContentTypeProto = "application/x-protobuf"
+	ContentTypeJSON  = "application/json"
+)
+
+// Marshal encodes msg as contentType; an empty contentType selects
+// ContentTypeProto.
+func Marshal(contentType string, msg proto.Message) ([]byte, error) {
+	switch contentType {
+	case "", ContentTypeProto:
+		return proto.Marshal(msg)
+	case ContentTypeJSON:
+		var buf bytes.Buffer
+		if err := new(jsonpb.Marshaler).Marshal(&buf, msg); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+	return nil, fmt.Errorf("amqppb: unsupported content type %q", contentType)
+}
+
+// Unmarshal decodes data of contentType into msg; an empty contentType
+// selects ContentTypeProto.
+func Unmarshal(contentType string, data []byte, msg proto.Message) error {
+	switch contentType {
+	case "", ContentTypeProto:
+		return proto.Unmarshal(data, msg)
+	case ContentTypeJSON:
+		u := jsonpb.Unmarshaler{AllowUnknownFields: true}
+		return u.Unmarshal(bytes.NewReader(data), msg)
+	}
+	return fmt.Errorf("amqppb: unsupported content type %q", contentType)
+}
+
+// Publisher publishes messages on an AMQP channel.
+type Publisher struct {
+	Channel *amqp.Channel
+	// ContentType encodes the message bodies; ContentTypeProto if empty.
+	ContentType string
+	// Transient publishes messages that do not survive a broker restart.
+	Transient bool
+}
+
+// NewPublisher returns a Publisher sending persistent, protobuf-encoded
+// messages on ch.
+func NewPublisher(ch *amqp.Channel) *Publisher {
+	return &Publisher{Channel: ch, ContentType: ContentTypeProto}
+}
+
+// Publish encodes msg and publishes it to exchange with routing key key.
+// The message type is set to the full name of msg.
+func (p *Publisher) Publish(exchange, key string, msg proto.Message) error {
+	contentType := p.ContentType
+	if contentType == "" {
+		contentType = ContentTypeProto
+	}
+	body, err := Marshal(contentType, msg)
+	if err != nil {
+		return err
+	}
+	mode := amqp.Persistent
+	if p.Transient {
+		mode = amqp.Transient
+	}
+	return p.Channel.Publish(exchange, key, false, false, amqp.Publishing{
+		ContentType:  contentType,
+		DeliveryMode: mode,
+		Timestamp:    time.Now(),
+		Type:         proto.MessageName(msg),
+		Body:         body,
+	})
+}
+
+// Declare declares a durable exchange of kind and a durable queue, and
+// binds the queue to the exchange with key. An empty exchange names the
+// default exchange, which routes by queue name and is not declared.
+func Declare(ch *amqp.Channel, exchange, kind, queue, key string) error {
+	if exchange != "" {
+		if err := ch.ExchangeDeclare(exchange, kind, true, false, false, false, nil); err != nil {
+			return err
+		}
+	}
+	if _, err := ch.QueueDeclare(queue, true, false, false, false, nil); err != nil {
+		return err
+	}
+	if exchange == "" {
+		return nil
+	}
+	return ch.QueueBind(queue, key, exchange, false, nil)
+}
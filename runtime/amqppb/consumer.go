@@ -0,0 +1,91 @@
+package amqppb
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/streadway/amqp"
+)
+
+// ErrorHandler is called with deliveries that cannot be decoded, which are
+// then rejected without requeueing. It logs them by default.
+var ErrorHandler = func(d *amqp.Delivery, err error) {
+	log.Printf("amqppb: %s/%s: %v", d.Exchange, d.RoutingKey, err)
+}
+
+// HandlerFunc handles a decoded message. Delivery(ctx) returns the
+// delivery it was decoded from.
+type HandlerFunc func(ctx context.Context, msg proto.Message) error
+
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e *permanentError) Unwrap() error {
+	return e.err
+}
+
+// Permanent marks err as not worth retrying: the delivery is rejected
+// without requeueing, so that it is dead-lettered if the queue has a dead
+// letter exchange.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err}
+}
+
+type deliveryKey struct{}
+
+// Delivery returns the delivery being handled, or nil if ctx was not
+// passed to a handler.
+func Delivery(ctx context.Context) *amqp.Delivery {
+	d, _ := ctx.Value(deliveryKey{}).(*amqp.Delivery)
+	return d
+}
+
+// Consume consumes queue on ch as consumer, a tag unique on ch, until ctx
+// is done. Each delivery is decoded into a message returned by newMsg and
+// passed to handle; it is acknowledged once handle succeeds and requeued
+// if it fails, unless the error is Permanent.
+func Consume(ctx context.Context, ch *amqp.Channel, queue, consumer string, newMsg func() proto.Message, handle HandlerFunc) error {
+	deliveries, err := ch.Consume(queue, consumer, false, false, false, false, nil)
+	if err != nil {
+		return err
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return ch.Cancel(consumer, false)
+		case d, ok := <-deliveries:
+			if !ok {
+				return amqp.ErrClosed
+			}
+			if err := deliver(ctx, &d, newMsg, handle); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// deliver handles d and settles it, returning the error of the
+// settlement.
+func deliver(ctx context.Context, d *amqp.Delivery, newMsg func() proto.Message, handle HandlerFunc) error {
+	msg := newMsg()
+	if err := Unmarshal(d.ContentType, d.Body, msg); err != nil {
+		ErrorHandler(d, err)
+		return d.Reject(false)
+	}
+	err := handle(context.WithValue(ctx, deliveryKey{}, d), msg)
+	if err == nil {
+		return d.Ack(false)
+	}
+	var perm *permanentError
+	return d.Nack(false, !errors.As(err, &perm))
+}
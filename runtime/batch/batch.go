@@ -0,0 +1,146 @@
+// Package batch is the runtime support for code generated by
+// protoc-gen-go-batcher. A Batcher coalesces concurrent calls into batches
+// made by one function and hands each caller its own result.
+package batch
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// DefaultMaxSize is the batch size used when MaxSize is not positive.
+	DefaultMaxSize = 100
+	// DefaultMaxDelay is the wait used when MaxDelay is not positive.
+	DefaultMaxDelay = 10 * time.Millisecond
+)
+
+// DoFunc makes one batch call for reqs. It returns the responses in the
+// order of reqs and, optionally, the error of each request. A non-nil err
+// fails every request.
+type DoFunc[Req, Resp any] func(ctx context.Context, reqs []Req) (resps []Resp, errs []error, err error)
+
+// Batcher collects the requests of Call until MaxSize of them are pending
+// or MaxDelay has passed since the first, then passes them to Do. The
+// exported fields must not be changed after the first call.
+type Batcher[Req, Resp any] struct {
+	// MaxSize is the largest batch; DefaultMaxSize if not positive.
+	MaxSize int
+	// MaxDelay is how long a request waits for others; DefaultMaxDelay if
+	// not positive.
+	MaxDelay time.Duration
+	// Do makes the batch calls.
+	Do DoFunc[Req, Resp]
+
+	mu      sync.Mutex
+	pending []*call[Req, Resp]
+	timer   *time.Timer
+}
+
+type call[Req, Resp any] struct {
+	ctx  context.Context
+	req  Req
+	done chan result[Resp]
+}
+
+type result[Resp any] struct {
+	resp Resp
+	err  error
+}
+
+// Call adds req to the next batch and waits for its response. It returns
+// early with the error of ctx if ctx is done first; the batch is still
+// made. The batch runs with the values of the context of its first call
+// and the earliest deadline of its calls.
+func (b *Batcher[Req, Resp]) Call(ctx context.Context, req Req) (Resp, error) {
+	c := &call[Req, Resp]{ctx: ctx, req: req, done: make(chan result[Resp], 1)}
+	b.add(c)
+	select {
+	case r := <-c.done:
+		return r.resp, r.err
+	case <-ctx.Done():
+		var zero Resp
+		return zero, status.FromContextError(ctx.Err()).Err()
+	}
+}
+
+func (b *Batcher[Req, Resp]) add(c *call[Req, Resp]) {
+	maxSize := b.MaxSize
+	if maxSize <= 0 {
+		maxSize = DefaultMaxSize
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.pending = append(b.pending, c)
+	switch {
+	case len(b.pending) >= maxSize:
+		b.flush()
+	case len(b.pending) == 1:
+		delay := b.MaxDelay
+		if delay <= 0 {
+			delay = DefaultMaxDelay
+		}
+		var t *time.Timer
+		t = time.AfterFunc(delay, func() {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+			// The batch may have been flushed when it filled up.
+			if b.timer == t {
+				b.flush()
+			}
+		})
+		b.timer = t
+	}
+}
+
+// flush starts the batch of the pending calls. b.mu must be held.
+func (b *Batcher[Req, Resp]) flush() {
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	if len(b.pending) == 0 {
+		return
+	}
+	calls := b.pending
+	b.pending = nil
+	go b.run(calls)
+}
+
+func (b *Batcher[Req, Resp]) run(calls []*call[Req, Resp]) {
+	ctx := context.WithoutCancel(calls[0].ctx)
+	var deadline time.Time
+	reqs := make([]Req, len(calls))
+	for i, c := range calls {
+		reqs[i] = c.req
+		if d, ok := c.ctx.Deadline(); ok && (deadline.IsZero() || d.Before(deadline)) {
+			deadline = d
+		}
+	}
+	if !deadline.IsZero() {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, deadline)
+		defer cancel()
+	}
+
+	resps, errs, err := b.Do(ctx, reqs)
+	if err == nil && len(resps) != len(reqs) {
+		err = status.Errorf(codes.Internal, "batch: got %d responses for %d requests", len(resps), len(reqs))
+	}
+	for i, c := range calls {
+		var r result[Resp]
+		switch {
+		case err != nil:
+			r.err = err
+		case i < len(errs) && errs[i] != nil:
+			r.err = errs[i]
+		default:
+			r.resp = resps[i]
+		}
+		c.done <- r
+	}
+}
@@ -0,0 +1,171 @@
+// Package breaker is the runtime support for code generated by
+// protoc-gen-go-circuitbreaker. It implements a consecutive-failure circuit
+// breaker for gRPC calls.
+package breaker
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Defaults applied to the zero fields of Settings.
+const (
+	DefaultFailureThreshold = 5
+	DefaultOpenTimeout      = 30 * time.Second
+)
+
+// ErrOpen is returned, without calling through, while a breaker is open.
+var ErrOpen = status.Error(codes.Unavailable, "breaker: circuit open")
+
+// State is the state of a Breaker.
+type State int
+
+const (
+	// Closed lets every call through.
+	Closed State = iota
+	// Open rejects every call with ErrOpen.
+	Open
+	// HalfOpen lets a single probe call through to decide whether to
+	// close again.
+	HalfOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case Closed:
+		return "closed"
+	case Open:
+		return "open"
+	case HalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+// Settings configures a Breaker.
+type Settings struct {
+	// FailureThreshold is the number of consecutive failures that opens
+	// the breaker.
+	FailureThreshold int
+	// OpenTimeout is how long the breaker stays open before a probe.
+	OpenTimeout time.Duration
+}
+
+// Breaker is a circuit breaker. It counts consecutive calls failing with
+// a code that signals an unhealthy backend (Unavailable, DeadlineExceeded,
+// Internal, Unknown); other errors count as successes.
+type Breaker struct {
+	settings Settings
+
+	mu       sync.Mutex
+	state    State
+	failures int
+	openedAt time.Time
+	probing  bool
+	// generation counts the changes of state. Calls record their outcome
+	// only if the state has not changed since they started.
+	generation uint64
+}
+
+// New returns a closed Breaker.
+func New(settings Settings) *Breaker {
+	if settings.FailureThreshold <= 0 {
+		settings.FailureThreshold = DefaultFailureThreshold
+	}
+	if settings.OpenTimeout <= 0 {
+		settings.OpenTimeout = DefaultOpenTimeout
+	}
+	return &Breaker{settings: settings}
+}
+
+// State returns the current state of b.
+func (b *Breaker) State() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.advance()
+	return b.state
+}
+
+// Do calls call unless b is open, and records its outcome. A call that
+// panics counts as a failure.
+func (b *Breaker) Do(ctx context.Context, call func(context.Context) error) (err error) {
+	generation, err := b.allow()
+	if err != nil {
+		return err
+	}
+	failed := true
+	defer func() {
+		b.record(generation, failed)
+	}()
+	err = call(ctx)
+	failed = isFailure(err)
+	return err
+}
+
+// setState moves b to state, starting a new generation. b.mu must be held.
+func (b *Breaker) setState(state State) {
+	b.state = state
+	b.probing = false
+	b.generation++
+}
+
+// advance moves an open breaker to half-open once its timeout elapsed.
+// b.mu must be held.
+func (b *Breaker) advance() {
+	if b.state == Open && time.Since(b.openedAt) >= b.settings.OpenTimeout {
+		b.setState(HalfOpen)
+	}
+}
+
+// allow returns the generation a call starts in, or ErrOpen if b rejects
+// it.
+func (b *Breaker) allow() (uint64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.advance()
+	switch b.state {
+	case Open:
+		return 0, ErrOpen
+	case HalfOpen:
+		if b.probing {
+			return 0, ErrOpen
+		}
+		b.probing = true
+	}
+	return b.generation, nil
+}
+
+// record records the outcome of a call started in generation.
+func (b *Breaker) record(generation uint64, failed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if generation != b.generation {
+		// A call that started before the state changed, such as one
+		// overlapping the probe of a half-open breaker.
+		return
+	}
+	if !failed {
+		if b.state != Closed {
+			b.setState(Closed)
+		}
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if b.state == HalfOpen || b.failures >= b.settings.FailureThreshold {
+		b.setState(Open)
+		b.openedAt = time.Now()
+	}
+}
+
+func isFailure(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded, codes.Internal, codes.Unknown:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,151 @@
+package breaker
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const openTimeout = 50 * time.Millisecond
+
+var errUnavailable = status.Error(codes.Unavailable, "unavailable")
+
+func succeed(context.Context) error { return nil }
+
+func fail(context.Context) error { return errUnavailable }
+
+// open fails a call through b, whose threshold is 1, and waits for it to
+// be half-open.
+func open(t *testing.T, b *Breaker) {
+	t.Helper()
+	b.Do(context.Background(), fail)
+	if got := b.State(); got != Open {
+		t.Fatalf("State() after a failure = %v, want open", got)
+	}
+	time.Sleep(openTimeout)
+}
+
+// started starts a call through b in a goroutine and returns once b let
+// it through. The function it returns makes the call return err and waits
+// for its outcome to be recorded.
+func started(b *Breaker, err error) func() {
+	entered, release, done := make(chan struct{}), make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(done)
+		b.Do(context.Background(), func(context.Context) error {
+			close(entered)
+			<-release
+			return err
+		})
+	}()
+	<-entered
+	return func() {
+		close(release)
+		<-done
+	}
+}
+
+func TestDo(t *testing.T) {
+	tests := []struct {
+		name string
+		// run calls through a closed breaker of threshold 1.
+		run  func(t *testing.T, b *Breaker)
+		want State
+	}{
+		{
+			name: "failure opens",
+			run: func(t *testing.T, b *Breaker) {
+				b.Do(context.Background(), fail)
+			},
+			want: Open,
+		},
+		{
+			name: "error of the caller",
+			run: func(t *testing.T, b *Breaker) {
+				b.Do(context.Background(), func(context.Context) error {
+					return status.Error(codes.InvalidArgument, "invalid")
+				})
+			},
+			want: Closed,
+		},
+		{
+			name: "successful probe closes",
+			run: func(t *testing.T, b *Breaker) {
+				open(t, b)
+				b.Do(context.Background(), succeed)
+			},
+			want: Closed,
+		},
+		{
+			name: "failed probe opens",
+			run: func(t *testing.T, b *Breaker) {
+				open(t, b)
+				b.Do(context.Background(), fail)
+			},
+			want: Open,
+		},
+		{
+			name: "panicking probe opens",
+			run: func(t *testing.T, b *Breaker) {
+				open(t, b)
+				func() {
+					defer func() {
+						if recover() == nil {
+							t.Error("Do recovered the panic of the probe")
+						}
+					}()
+					b.Do(context.Background(), func(context.Context) error { panic("probe") })
+				}()
+			},
+			want: Open,
+		},
+		{
+			name: "call during a probe is rejected",
+			run: func(t *testing.T, b *Breaker) {
+				open(t, b)
+				finish := started(b, nil)
+				if err := b.Do(context.Background(), succeed); err != ErrOpen {
+					t.Errorf("Do during a probe = %v, want ErrOpen", err)
+				}
+				finish()
+			},
+			want: Closed,
+		},
+		{
+			name: "stale success during a probe",
+			run: func(t *testing.T, b *Breaker) {
+				finish := started(b, nil)
+				open(t, b)
+				probe := started(b, errUnavailable)
+				finish()
+				if got := b.State(); got != HalfOpen {
+					t.Errorf("State() after the stale success = %v, want half-open", got)
+				}
+				probe()
+			},
+			want: Open,
+		},
+		{
+			name: "stale failure after closing",
+			run: func(t *testing.T, b *Breaker) {
+				finish := started(b, errUnavailable)
+				open(t, b)
+				b.Do(context.Background(), succeed)
+				finish()
+			},
+			want: Closed,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			b := New(Settings{FailureThreshold: 1, OpenTimeout: openTimeout})
+			test.run(t, b)
+			if got := b.State(); got != test.want {
+				t.Errorf("State() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
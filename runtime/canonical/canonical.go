@@ -0,0 +1,383 @@
+// Package canonical is the runtime support for code generated by
+// protoc-gen-go-canonical. An Encoder writes messages in the protobuf wire
+// format under fixed rules, so that equal messages encode to the same bytes
+// whatever produced them:
+//
+//   - fields are written in field number order, defaults omitted as usual;
+//   - repeated numeric fields are packed;
+//   - map entries are sorted by key, and write their key and value even if
+//     they are defaults;
+//   - NaNs are written as the quiet NaN 0x7ff8000000000001 (0x7fc00001 for
+//     floats);
+//   - unknown fields are kept as received and merged in field number
+//     order, those of the same number in the order received.
+//
+// These rules are part of the signatures and content addresses computed
+// from the encoding: they will not change.
+package canonical
+
+import (
+	"encoding/binary"
+	"math"
+	"sort"
+
+	"github.com/golang/protobuf/proto"
+)
+
+// Wire types.
+const (
+	wireVarint     = 0
+	wireFixed64    = 1
+	wireBytes      = 2
+	wireStartGroup = 3
+	wireEndGroup   = 4
+	wireFixed32    = 5
+)
+
+// Encoder accumulates the canonical encoding of a message. The zero value
+// is ready to use.
+type Encoder struct {
+	b []byte
+	// unknown holds the unknown fields not written yet, in field number
+	// order.
+	unknown []rawField
+	// omit is the number of the field left out, or 0.
+	omit int32
+}
+
+// rawField is an unknown field: its number and its tag and value as
+// received.
+type rawField struct {
+	num int32
+	b   []byte
+}
+
+// Result returns the encoding written so far.
+func (e *Encoder) Result() []byte {
+	return e.b
+}
+
+// Unknown records the unknown fields raw of the message being written, to
+// be merged with the known ones. Fields that do not parse are kept as a
+// whole after all others.
+func (e *Encoder) Unknown(raw []byte) {
+	for len(raw) > 0 {
+		n := fieldLen(raw)
+		if n <= 0 {
+			e.unknown = append(e.unknown, rawField{num: math.MaxInt32, b: raw})
+			break
+		}
+		tag, _ := binary.Uvarint(raw)
+		e.unknown = append(e.unknown, rawField{num: int32(tag >> 3), b: raw[:n]})
+		raw = raw[n:]
+	}
+	sort.SliceStable(e.unknown, func(i, j int) bool { return e.unknown[i].num < e.unknown[j].num })
+}
+
+// Omit leaves the field numbered num out of the message written next, as
+// signatures do with the field that holds them. Its messages are written
+// in full.
+func (e *Encoder) Omit(num int32) {
+	e.omit = num
+}
+
+// End writes the unknown fields left after the last known one.
+func (e *Encoder) End() {
+	e.flush(math.MaxInt32 + 1)
+}
+
+// flush writes the unknown fields numbered below num.
+func (e *Encoder) flush(num int64) {
+	for len(e.unknown) > 0 && int64(e.unknown[0].num) < num {
+		if e.unknown[0].num != e.omit {
+			e.b = append(e.b, e.unknown[0].b...)
+		}
+		e.unknown = e.unknown[1:]
+	}
+}
+
+// tag writes the tag of the field num, and reports whether to write its
+// value: false if the field is omitted.
+func (e *Encoder) tag(num int32, wire int) bool {
+	if num == e.omit {
+		return false
+	}
+	e.flush(int64(num))
+	e.b = binary.AppendUvarint(e.b, uint64(num)<<3|uint64(wire))
+	return true
+}
+
+// Int writes an int32, int64 or enum field.
+func (e *Encoder) Int(num int32, v int64) {
+	if !e.tag(num, wireVarint) {
+		return
+	}
+	e.b = binary.AppendUvarint(e.b, uint64(v))
+}
+
+// Uint writes a uint32 or uint64 field.
+func (e *Encoder) Uint(num int32, v uint64) {
+	if !e.tag(num, wireVarint) {
+		return
+	}
+	e.b = binary.AppendUvarint(e.b, v)
+}
+
+// Sint writes a sint32 or sint64 field.
+func (e *Encoder) Sint(num int32, v int64) {
+	if !e.tag(num, wireVarint) {
+		return
+	}
+	e.b = binary.AppendUvarint(e.b, zigzag(v))
+}
+
+// Bool writes a bool field.
+func (e *Encoder) Bool(num int32, v bool) {
+	if !e.tag(num, wireVarint) {
+		return
+	}
+	e.b = append(e.b, boolByte(v))
+}
+
+// Fixed32 writes a fixed32 or sfixed32 field.
+func (e *Encoder) Fixed32(num int32, v uint32) {
+	if !e.tag(num, wireFixed32) {
+		return
+	}
+	e.b = binary.LittleEndian.AppendUint32(e.b, v)
+}
+
+// Fixed64 writes a fixed64 or sfixed64 field.
+func (e *Encoder) Fixed64(num int32, v uint64) {
+	if !e.tag(num, wireFixed64) {
+		return
+	}
+	e.b = binary.LittleEndian.AppendUint64(e.b, v)
+}
+
+// Float writes a float field.
+func (e *Encoder) Float(num int32, v float32) {
+	e.Fixed32(num, float32Bits(v))
+}
+
+// Double writes a double field.
+func (e *Encoder) Double(num int32, v float64) {
+	e.Fixed64(num, float64Bits(v))
+}
+
+// String writes a string field.
+func (e *Encoder) String(num int32, s string) {
+	if !e.tag(num, wireBytes) {
+		return
+	}
+	e.b = binary.AppendUvarint(e.b, uint64(len(s)))
+	e.b = append(e.b, s...)
+}
+
+// Bytes writes a bytes field.
+func (e *Encoder) Bytes(num int32, b []byte) {
+	if !e.tag(num, wireBytes) {
+		return
+	}
+	e.b = binary.AppendUvarint(e.b, uint64(len(b)))
+	e.b = append(e.b, b...)
+}
+
+// Message writes a message field, its fields written by f.
+func (e *Encoder) Message(num int32, f func(*Encoder)) {
+	if num == e.omit {
+		return
+	}
+	var sub Encoder
+	f(&sub)
+	e.Bytes(num, sub.b)
+}
+
+// Group writes a group field, its fields written by f.
+func (e *Encoder) Group(num int32, f func(*Encoder)) {
+	if !e.tag(num, wireStartGroup) {
+		return
+	}
+	var sub Encoder
+	f(&sub)
+	e.b = append(e.b, sub.b...)
+	e.b = binary.AppendUvarint(e.b, uint64(num)<<3|wireEndGroup)
+}
+
+// SecondsNanos writes a google.protobuf.Timestamp or Duration field of
+// the given seconds and nanos.
+func (e *Encoder) SecondsNanos(num int32, seconds int64, nanos int32) {
+	e.Message(num, func(e *Encoder) {
+		if seconds != 0 {
+			e.Int(1, seconds)
+		}
+		if nanos != 0 {
+			e.Int(2, int64(nanos))
+		}
+	})
+}
+
+// Proto writes a message field holding m, a message without a generated
+// EncodeCanonical, in the deterministic encoding of package proto. That
+// encoding sorts maps but is not promised to be stable across releases of
+// the package; prefer messages generated by protoc-gen-go-canonical.
+func (e *Encoder) Proto(num int32, m proto.Message) {
+	var buf proto.Buffer
+	buf.SetDeterministic(true)
+	// Encoding errors are left to the code that sends m.
+	buf.Marshal(m)
+	e.Bytes(num, buf.Bytes())
+}
+
+// packed writes the packed field num of n values, each appended by add.
+func packed(e *Encoder, num int32, n int, add func([]byte, int) []byte) {
+	var body []byte
+	for i := 0; i < n; i++ {
+		body = add(body, i)
+	}
+	e.Bytes(num, body)
+}
+
+// PackedInt writes a repeated int32, int64 or enum field.
+func PackedInt[T ~int32 | ~int64](e *Encoder, num int32, s []T) {
+	packed(e, num, len(s), func(b []byte, i int) []byte { return binary.AppendUvarint(b, uint64(s[i])) })
+}
+
+// PackedUint writes a repeated uint32 or uint64 field.
+func PackedUint[T ~uint32 | ~uint64](e *Encoder, num int32, s []T) {
+	packed(e, num, len(s), func(b []byte, i int) []byte { return binary.AppendUvarint(b, uint64(s[i])) })
+}
+
+// PackedSint writes a repeated sint32 or sint64 field.
+func PackedSint[T ~int32 | ~int64](e *Encoder, num int32, s []T) {
+	packed(e, num, len(s), func(b []byte, i int) []byte { return binary.AppendUvarint(b, zigzag(int64(s[i]))) })
+}
+
+// PackedBool writes a repeated bool field.
+func PackedBool(e *Encoder, num int32, s []bool) {
+	packed(e, num, len(s), func(b []byte, i int) []byte { return append(b, boolByte(s[i])) })
+}
+
+// PackedFixed32 writes a repeated fixed32 or sfixed32 field.
+func PackedFixed32[T ~uint32 | ~int32](e *Encoder, num int32, s []T) {
+	packed(e, num, len(s), func(b []byte, i int) []byte { return binary.LittleEndian.AppendUint32(b, uint32(s[i])) })
+}
+
+// PackedFixed64 writes a repeated fixed64 or sfixed64 field.
+func PackedFixed64[T ~uint64 | ~int64](e *Encoder, num int32, s []T) {
+	packed(e, num, len(s), func(b []byte, i int) []byte { return binary.LittleEndian.AppendUint64(b, uint64(s[i])) })
+}
+
+// PackedFloat writes a repeated float field.
+func PackedFloat(e *Encoder, num int32, s []float32) {
+	packed(e, num, len(s), func(b []byte, i int) []byte { return binary.LittleEndian.AppendUint32(b, float32Bits(s[i])) })
+}
+
+// PackedDouble writes a repeated double field.
+func PackedDouble(e *Encoder, num int32, s []float64) {
+	packed(e, num, len(s), func(b []byte, i int) []byte { return binary.LittleEndian.AppendUint64(b, float64Bits(s[i])) })
+}
+
+// Map writes the map field num of m in key order, each entry written by f.
+func Map[K comparable, V any](e *Encoder, num int32, m map[K]V, f func(e *Encoder, k K, v V)) {
+	keys := make([]K, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return less(keys[i], keys[j]) })
+	for _, k := range keys {
+		e.Message(num, func(e *Encoder) { f(e, k, m[k]) })
+	}
+}
+
+// less orders the keys of a map, false before true for bools.
+func less(a, b interface{}) bool {
+	switch a := a.(type) {
+	case string:
+		return a < b.(string)
+	case int32:
+		return a < b.(int32)
+	case int64:
+		return a < b.(int64)
+	case uint32:
+		return a < b.(uint32)
+	case uint64:
+		return a < b.(uint64)
+	case bool:
+		return !a && b.(bool)
+	}
+	panic("canonical: unexpected map key type")
+}
+
+func zigzag(v int64) uint64 {
+	return uint64(v<<1) ^ uint64(v>>63)
+}
+
+func boolByte(v bool) byte {
+	if v {
+		return 1
+	}
+	return 0
+}
+
+func float32Bits(v float32) uint32 {
+	if v != v {
+		return 0x7fc00001
+	}
+	return math.Float32bits(v)
+}
+
+func float64Bits(v float64) uint64 {
+	if v != v {
+		return 0x7ff8000000000001
+	}
+	return math.Float64bits(v)
+}
+
+// fieldLen returns the length of the field, tag and value, at the start of
+// b, or 0 if it does not parse.
+func fieldLen(b []byte) int {
+	tag, n := binary.Uvarint(b)
+	if n <= 0 || tag>>3 == 0 {
+		return 0
+	}
+	switch tag & 7 {
+	case wireVarint:
+		_, m := binary.Uvarint(b[n:])
+		if m <= 0 {
+			return 0
+		}
+		return n + m
+	case wireFixed64:
+		if len(b) < n+8 {
+			return 0
+		}
+		return n + 8
+	case wireFixed32:
+		if len(b) < n+4 {
+			return 0
+		}
+		return n + 4
+	case wireBytes:
+		l, m := binary.Uvarint(b[n:])
+		if m <= 0 || uint64(len(b)-n-m) < l {
+			return 0
+		}
+		return n + m + int(l)
+	case wireStartGroup:
+		// The group ends at the end group tag of its number.
+		for i := n; i < len(b); {
+			t, _ := binary.Uvarint(b[i:])
+			if t == tag>>3<<3|wireEndGroup {
+				_, m := binary.Uvarint(b[i:])
+				return i + m
+			}
+			l := fieldLen(b[i:])
+			if l <= 0 {
+				return 0
+			}
+			i += l
+		}
+	}
+	return 0
+}
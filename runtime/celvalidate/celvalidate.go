@@ -0,0 +1,43 @@
+// Package celvalidate is the runtime support for code generated by
+// protoc-gen-go-protovalidate. It wraps protovalidate so that the
+// generated code, not its callers, deals with compiling the buf.validate
+// CEL constraints.
+package celvalidate
+
+import (
+	"context"
+
+	"github.com/bufbuild/protovalidate-go"
+	"github.com/golang/protobuf/proto"
+	protoV2 "google.golang.org/protobuf/proto"
+)
+
+// Validator checks the messages of one generated file.
+type Validator struct {
+	v   *protovalidate.Validator
+	err error
+}
+
+// New returns a Validator with the constraints of msgs compiled. An error
+// compiling them is returned by every Validate call rather than panicking
+// at init.
+func New(msgs ...proto.Message) *Validator {
+	ms := make([]protoV2.Message, len(msgs))
+	for i, m := range msgs {
+		ms[i] = proto.MessageV2(m)
+	}
+	v, err := protovalidate.New(protovalidate.WithMessages(ms...))
+	return &Validator{v: v, err: err}
+}
+
+// Validate returns the error of ctx if it is done, else the violations of
+// the constraints of m as a *protovalidate.ValidationError, or nil.
+func (v *Validator) Validate(ctx context.Context, m proto.Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if v.err != nil {
+		return v.err
+	}
+	return v.v.Validate(proto.MessageV2(m))
+}
@@ -0,0 +1,57 @@
+// Package clientcache is the runtime support for code generated by
+// protoc-gen-go-clientcache. Responses are cached in a pluggable Cache
+// under a key derived from the canonical JSON encoding of the request, so
+// equal requests share an entry across processes and releases.
+package clientcache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+
+	"github.com/f4tq/protoc-go-plugins/runtime/jcs"
+)
+
+// Cache stores encoded responses. Keys have the form method + "/" + hash,
+// where method is a full method name such as "/pkg.Service/Method".
+type Cache interface {
+	// Get returns the value of key, and false if there is none.
+	Get(ctx context.Context, key string) (value []byte, ok bool, err error)
+	// Set stores value under key for ttl.
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	// Invalidate drops every entry of method.
+	Invalidate(ctx context.Context, method string) error
+}
+
+// Key returns the cache key of req for method.
+func Key(method string, req proto.Message) (string, error) {
+	b, err := jcs.Marshal(req)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return method + "/" + base64.RawURLEncoding.EncodeToString(sum[:]), nil
+}
+
+// Lookup decodes the entry of key into resp and reports whether there was
+// one. Cache and decoding errors count as misses.
+func Lookup(ctx context.Context, c Cache, key string, resp proto.Message) bool {
+	b, ok, err := c.Get(ctx, key)
+	if err != nil || !ok {
+		return false
+	}
+	return proto.Unmarshal(b, resp) == nil
+}
+
+// Store caches resp under key for ttl. Errors are ignored: a response that
+// could not be cached is fetched again next time.
+func Store(ctx context.Context, c Cache, key string, resp proto.Message, ttl time.Duration) {
+	b, err := proto.Marshal(resp)
+	if err != nil {
+		return
+	}
+	c.Set(ctx, key, b, ttl)
+}
@@ -0,0 +1,62 @@
+package clientcache
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemoryCache is an in-process Cache. Expired entries are dropped when
+// they are next read.
+type MemoryCache struct {
+	mu      sync.Mutex
+	entries map[string]entry
+	now     func() time.Time
+}
+
+type entry struct {
+	value   []byte
+	expires time.Time
+}
+
+// NewMemoryCache returns an empty MemoryCache.
+func NewMemoryCache() *MemoryCache {
+	return &MemoryCache{entries: make(map[string]entry), now: time.Now}
+}
+
+// Get implements Cache.
+func (c *MemoryCache) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok {
+		return nil, false, nil
+	}
+	if !c.now().Before(e.expires) {
+		delete(c.entries, key)
+		return nil, false, nil
+	}
+	return e.value, true, nil
+}
+
+// Set implements Cache.
+func (c *MemoryCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[key] = entry{value: value, expires: c.now().Add(ttl)}
+	return nil
+}
+
+// Invalidate implements Cache.
+func (c *MemoryCache) Invalidate(ctx context.Context, method string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	prefix := method + "/"
+	for key := range c.entries {
+		if strings.HasPrefix(key, prefix) {
+			delete(c.entries, key)
+		}
+	}
+	return nil
+}
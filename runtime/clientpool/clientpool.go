@@ -0,0 +1,138 @@
+// Package clientpool is the runtime support for code generated by
+// protoc-gen-go-clientfactory. A Pool spreads calls over a fixed number of
+// lazily dialed gRPC connections and applies per-method default call
+// options.
+package clientpool
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+)
+
+// ErrClosed is returned by calls made through a closed Pool.
+var ErrClosed = errors.New("clientpool: pool is closed")
+
+// Pool is a grpc.ClientConnInterface backed by up to Size connections to
+// Target. Connections are dialed on first use; one that has been shut down
+// is dialed again, and one in TRANSIENT_FAILURE skips its reconnect
+// backoff. The exported fields must not be changed after the first call.
+type Pool struct {
+	// Target is the address passed to grpc.Dial.
+	Target string
+	// Size is the number of connections; 1 if not positive.
+	Size int
+	// DialOptions are passed to grpc.Dial.
+	DialOptions []grpc.DialOption
+	// CallOptions holds default call options keyed by full method name,
+	// e.g. "/pkg.Service/Method". They are applied before the options of
+	// each call, which take precedence.
+	CallOptions map[string][]grpc.CallOption
+
+	mu     sync.Mutex
+	conns  []*grpc.ClientConn
+	next   int
+	closed bool
+}
+
+// New returns a Pool of one connection to target. Nothing is dialed until
+// the first call.
+func New(target string, opts ...grpc.DialOption) *Pool {
+	return &Pool{Target: target, Size: 1, DialOptions: opts}
+}
+
+// Conn returns the next connection in round-robin order, dialing it if
+// needed.
+func (p *Pool) Conn() (*grpc.ClientConn, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return nil, ErrClosed
+	}
+	if p.conns == nil {
+		n := p.Size
+		if n <= 0 {
+			n = 1
+		}
+		p.conns = make([]*grpc.ClientConn, n)
+	}
+	i := p.next
+	p.next = (p.next + 1) % len(p.conns)
+
+	cc := p.conns[i]
+	if cc != nil {
+		switch cc.GetState() {
+		case connectivity.Shutdown:
+			cc = nil
+		case connectivity.TransientFailure:
+			cc.ResetConnectBackoff()
+		}
+	}
+	if cc == nil {
+		var err error
+		if cc, err = grpc.Dial(p.Target, p.DialOptions...); err != nil {
+			return nil, err
+		}
+		p.conns[i] = cc
+	}
+	return cc, nil
+}
+
+// SetCallOptions sets the default call options of method.
+func (p *Pool) SetCallOptions(method string, opts ...grpc.CallOption) {
+	if p.CallOptions == nil {
+		p.CallOptions = make(map[string][]grpc.CallOption)
+	}
+	p.CallOptions[method] = opts
+}
+
+func (p *Pool) callOptions(method string, opts []grpc.CallOption) []grpc.CallOption {
+	defaults := p.CallOptions[method]
+	if len(defaults) == 0 {
+		return opts
+	}
+	return append(append([]grpc.CallOption(nil), defaults...), opts...)
+}
+
+// Invoke implements grpc.ClientConnInterface.
+func (p *Pool) Invoke(ctx context.Context, method string, args, reply interface{}, opts ...grpc.CallOption) error {
+	cc, err := p.Conn()
+	if err != nil {
+		return err
+	}
+	return cc.Invoke(ctx, method, args, reply, p.callOptions(method, opts)...)
+}
+
+// NewStream implements grpc.ClientConnInterface.
+func (p *Pool) NewStream(ctx context.Context, desc *grpc.StreamDesc, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	cc, err := p.Conn()
+	if err != nil {
+		return nil, err
+	}
+	return cc.NewStream(ctx, desc, method, p.callOptions(method, opts)...)
+}
+
+// Close closes the dialed connections. Calls made after Close fail with
+// ErrClosed.
+func (p *Pool) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return nil
+	}
+	p.closed = true
+	var first error
+	for _, cc := range p.conns {
+		if cc == nil {
+			continue
+		}
+		if err := cc.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	p.conns = nil
+	return first
+}
@@ -0,0 +1,39 @@
+// Package collection is the runtime support for code generated by
+// protoc-gen-go-collection.
+package collection
+
+import "fmt"
+
+// DuplicateKeyError reports two elements of a repeated field sharing a key.
+type DuplicateKeyError struct {
+	// Key is the name of the key field, e.g. "city".
+	Key   string
+	Value interface{}
+	// First and Second are the indexes of the elements.
+	First, Second int
+}
+
+func (e *DuplicateKeyError) Error() string {
+	return fmt.Sprintf("duplicate %s %v at indexes %d and %d", e.Key, e.Value, e.First, e.Second)
+}
+
+// Index returns the elements of s by their key, the name of which is
+// name. If several elements share a key, the first one is kept and a
+// *DuplicateKeyError reports the first duplicate.
+func Index[K comparable, V any](s []V, key func(V) K, name string) (map[K]V, error) {
+	idx := make(map[K]V, len(s))
+	at := make(map[K]int, len(s))
+	var err error
+	for i, v := range s {
+		k := key(v)
+		if j, ok := at[k]; ok {
+			if err == nil {
+				err = &DuplicateKeyError{Key: name, Value: k, First: j, Second: i}
+			}
+			continue
+		}
+		idx[k] = v
+		at[k] = i
+	}
+	return idx, err
+}
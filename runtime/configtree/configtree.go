@@ -0,0 +1,89 @@
+// Package configtree is the runtime support for code generated by
+// protoc-gen-go-config: it decodes the configuration trees of viper and
+// koanf into configuration messages through their JSON form, reporting the
+// keys that name no field.
+package configtree
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+)
+
+// Schema holds the keys of the fields of a message, by proto and JSON
+// name, and the Schema of their messages. It is nil for the fields whose
+// keys are left to jsonpb: scalars, maps, well-known types and messages of
+// other packages.
+type Schema map[string]Schema
+
+// UnknownKeysError reports the keys of a configuration tree that name no
+// field, dotted from its root.
+type UnknownKeysError struct {
+	Keys []string
+}
+
+func (e *UnknownKeysError) Error() string {
+	return "configtree: unknown keys " + strings.Join(e.Keys, ", ")
+}
+
+// Decode sets m from settings, the tree returned by viper's AllSettings or
+// koanf's Raw, after checking its keys against schema. It then applies the
+// defaults of m and validates it, if m has the ApplyDefaults method of
+// protoc-gen-go-defaults or the Validate method of protoc-gen-go-validate.
+//
+// Viper lower-cases keys, which therefore only match lower-case proto
+// names.
+func Decode(settings map[string]interface{}, schema Schema, m proto.Message) error {
+	var unknown []string
+	check(&unknown, "", settings, schema)
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return &UnknownKeysError{Keys: unknown}
+	}
+	b, err := json.Marshal(settings)
+	if err != nil {
+		return fmt.Errorf("configtree: %v", err)
+	}
+	if err := jsonpb.Unmarshal(bytes.NewReader(b), m); err != nil {
+		return fmt.Errorf("configtree: %v", err)
+	}
+	if d, ok := m.(interface{ ApplyDefaults() }); ok {
+		d.ApplyDefaults()
+	}
+	if v, ok := m.(interface{ Validate() error }); ok {
+		if err := v.Validate(); err != nil {
+			return fmt.Errorf("configtree: %w", err)
+		}
+	}
+	return nil
+}
+
+// check appends to unknown the keys of tree, a message value at path,
+// missing from schema, and checks the message values of those it has.
+func check(unknown *[]string, path string, tree map[string]interface{}, schema Schema) {
+	for key, v := range tree {
+		sub, ok := schema[key]
+		if !ok {
+			*unknown = append(*unknown, path+key)
+			continue
+		}
+		if sub == nil {
+			continue
+		}
+		switch v := v.(type) {
+		case map[string]interface{}:
+			check(unknown, path+key+".", v, sub)
+		case []interface{}:
+			for i, e := range v {
+				if e, ok := e.(map[string]interface{}); ok {
+					check(unknown, fmt.Sprintf("%s%s.%d.", path, key, i), e, sub)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package connectrpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Client calls Connect procedures on a server.
+type Client struct {
+	// BaseURL is the URL the procedure paths are appended to, e.g.
+	// "https://api.example.com".
+	BaseURL string
+	// HTTPClient sends the requests; http.DefaultClient if nil.
+	HTTPClient *http.Client
+	// Codec is CodecProto (the default) or CodecJSON.
+	Codec string
+}
+
+// NewClient returns a Client for the server at baseURL using the proto
+// codec.
+func NewClient(baseURL string) *Client {
+	return &Client{BaseURL: strings.TrimSuffix(baseURL, "/"), Codec: CodecProto}
+}
+
+func (c *Client) codec() string {
+	if c.Codec == CodecJSON {
+		return CodecJSON
+	}
+	return CodecProto
+}
+
+func (c *Client) do(ctx context.Context, path string, streaming bool, body []byte) (*http.Response, error) {
+	r, err := http.NewRequest(http.MethodPost, c.BaseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "connectrpc: %v", err)
+	}
+	r = r.WithContext(ctx)
+	r.Header.Set("Content-Type", contentType(c.codec(), streaming))
+	r.Header.Set("Connect-Protocol-Version", "1")
+	if deadline, ok := ctx.Deadline(); ok {
+		ms := time.Until(deadline).Milliseconds()
+		if ms <= 0 {
+			return nil, status.Error(codes.DeadlineExceeded, context.DeadlineExceeded.Error())
+		}
+		r.Header.Set("Connect-Timeout-Ms", strconv.FormatInt(ms, 10))
+	}
+	hc := c.HTTPClient
+	if hc == nil {
+		hc = http.DefaultClient
+	}
+	resp, err := hc.Do(r)
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, status.FromContextError(ctxErr).Err()
+		}
+		return nil, status.Errorf(codes.Unavailable, "connectrpc: %v", err)
+	}
+	return resp, nil
+}
+
+// CallUnary calls the unary procedure at path with req and decodes the
+// result into resp. Errors are gRPC status errors.
+func (c *Client) CallUnary(ctx context.Context, path string, req, resp proto.Message) error {
+	body, err := marshal(c.codec(), req)
+	if err != nil {
+		return status.Errorf(codes.Internal, "encoding request: %v", err)
+	}
+	r, err := c.do(ctx, path, false, body)
+	if err != nil {
+		return err
+	}
+	defer r.Body.Close()
+	data, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return status.Errorf(codes.Unavailable, "reading response: %v", err)
+	}
+	if r.StatusCode != http.StatusOK {
+		var we wireError
+		if json.Unmarshal(data, &we) != nil || we.Code == "" {
+			return status.Errorf(codeFromHTTPStatus(r.StatusCode), "connectrpc: HTTP status %s", r.Status)
+		}
+		return we.err()
+	}
+	if err := unmarshal(c.codec(), data, resp); err != nil {
+		return status.Errorf(codes.Internal, "decoding response: %v", err)
+	}
+	return nil
+}
+
+// CallServerStream calls the server-streaming procedure at path with req.
+func (c *Client) CallServerStream(ctx context.Context, path string, req proto.Message) (*ClientStream, error) {
+	data, err := marshal(c.codec(), req)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "encoding request: %v", err)
+	}
+	var body bytes.Buffer
+	writeEnvelope(&body, 0, data)
+	r, err := c.do(ctx, path, true, body.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	if r.StatusCode != http.StatusOK {
+		r.Body.Close()
+		return nil, status.Errorf(codeFromHTTPStatus(r.StatusCode), "connectrpc: HTTP status %s", r.Status)
+	}
+	return &ClientStream{body: r.Body, codec: c.codec()}, nil
+}
+
+// ClientStream receives the responses of a server-streaming call.
+type ClientStream struct {
+	body  io.ReadCloser
+	codec string
+	err   error
+}
+
+// Receive decodes the next response into msg. It returns io.EOF once the
+// stream ended successfully, or the status error it ended with.
+func (s *ClientStream) Receive(msg proto.Message) error {
+	if s.err != nil {
+		return s.err
+	}
+	flags, data, err := readEnvelope(s.body)
+	switch {
+	case err == io.EOF:
+		s.err = status.Error(codes.Internal, "connectrpc: stream ended without end-of-stream message")
+	case err != nil:
+		s.err = status.Errorf(codes.Unavailable, "connectrpc: %v", err)
+	case flags&flagEndStream != 0:
+		var end struct {
+			Error *wireError `json:"error"`
+		}
+		if jerr := json.Unmarshal(data, &end); jerr != nil {
+			s.err = status.Errorf(codes.Internal, "decoding end of stream: %v", jerr)
+		} else if end.Error != nil {
+			s.err = end.Error.err()
+		} else {
+			s.err = io.EOF
+		}
+	default:
+		if uerr := unmarshal(s.codec, data, msg); uerr != nil {
+			s.err = status.Errorf(codes.Internal, "decoding response: %v", uerr)
+			break
+		}
+		return nil
+	}
+	s.body.Close()
+	return s.err
+}
+
+// Close abandons the stream.
+func (s *ClientStream) Close() error {
+	return s.body.Close()
+}
@@ -0,0 +1,116 @@
+// Package connectrpc is the runtime support for code generated by
+// protoc-gen-go-connect. It implements the unary and server-streaming
+// parts of the Connect protocol over net/http, with the proto and JSON
+// codecs, and can share its endpoints with a gRPC server.
+package connectrpc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+)
+
+// Codec names, as used in Content-Type headers and GET requests.
+const (
+	CodecProto = "proto"
+	CodecJSON  = "json"
+)
+
+// Envelope flags of streaming messages.
+const (
+	flagCompressed = 0x01
+	flagEndStream  = 0x02
+)
+
+var (
+	jsonMarshaler   = &jsonpb.Marshaler{}
+	jsonUnmarshaler = &jsonpb.Unmarshaler{AllowUnknownFields: true}
+)
+
+func marshal(codec string, msg proto.Message) ([]byte, error) {
+	if codec == CodecJSON {
+		var buf bytes.Buffer
+		if err := jsonMarshaler.Marshal(&buf, msg); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+	return proto.Marshal(msg)
+}
+
+func unmarshal(codec string, data []byte, msg proto.Message) error {
+	if codec == CodecJSON {
+		if len(bytes.TrimSpace(data)) == 0 {
+			return nil
+		}
+		return jsonUnmarshaler.Unmarshal(bytes.NewReader(data), msg)
+	}
+	return proto.Unmarshal(data, msg)
+}
+
+// codecFromContentType returns the codec of a unary ("application/json")
+// or streaming ("application/connect+json") content type, and whether it
+// is supported.
+func codecFromContentType(contentType string, streaming bool) (string, bool) {
+	if i := strings.IndexByte(contentType, ';'); i >= 0 {
+		contentType = contentType[:i]
+	}
+	contentType = strings.TrimSpace(strings.ToLower(contentType))
+	prefix := "application/"
+	if streaming {
+		prefix = "application/connect+"
+	}
+	if !strings.HasPrefix(contentType, prefix) {
+		return "", false
+	}
+	switch codec := strings.TrimPrefix(contentType, prefix); codec {
+	case CodecProto, CodecJSON:
+		return codec, true
+	}
+	return "", false
+}
+
+func contentType(codec string, streaming bool) string {
+	if streaming {
+		return "application/connect+" + codec
+	}
+	return "application/" + codec
+}
+
+// writeEnvelope writes data framed as a streaming message.
+func writeEnvelope(w io.Writer, flags byte, data []byte) error {
+	var prefix [5]byte
+	prefix[0] = flags
+	binary.BigEndian.PutUint32(prefix[1:], uint32(len(data)))
+	if _, err := w.Write(prefix[:]); err != nil {
+		return err
+	}
+	_, err := w.Write(data)
+	return err
+}
+
+// readEnvelope reads one streaming message. It returns io.EOF if r is
+// exhausted before the message starts.
+func readEnvelope(r io.Reader) (byte, []byte, error) {
+	var prefix [5]byte
+	if _, err := io.ReadFull(r, prefix[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return 0, nil, errors.New("connectrpc: truncated envelope")
+		}
+		return 0, nil, err
+	}
+	if prefix[0]&flagCompressed != 0 {
+		return 0, nil, errors.New("connectrpc: compressed messages are not supported")
+	}
+	data := make([]byte, binary.BigEndian.Uint32(prefix[1:]))
+	if _, err := io.ReadFull(r, data); err != nil {
+		return 0, nil, fmt.Errorf("connectrpc: truncated envelope: %v", err)
+	}
+	return prefix[0], data, nil
+}
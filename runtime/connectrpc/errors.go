@@ -0,0 +1,132 @@
+package connectrpc
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/golang/protobuf/ptypes/any"
+	spb "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// codeNames are the Connect names of the gRPC status codes.
+var codeNames = map[codes.Code]string{
+	codes.Canceled:           "canceled",
+	codes.Unknown:            "unknown",
+	codes.InvalidArgument:    "invalid_argument",
+	codes.DeadlineExceeded:   "deadline_exceeded",
+	codes.NotFound:           "not_found",
+	codes.AlreadyExists:      "already_exists",
+	codes.PermissionDenied:   "permission_denied",
+	codes.ResourceExhausted:  "resource_exhausted",
+	codes.FailedPrecondition: "failed_precondition",
+	codes.Aborted:            "aborted",
+	codes.OutOfRange:         "out_of_range",
+	codes.Unimplemented:      "unimplemented",
+	codes.Internal:           "internal",
+	codes.Unavailable:        "unavailable",
+	codes.DataLoss:           "data_loss",
+	codes.Unauthenticated:    "unauthenticated",
+}
+
+// httpStatuses are the HTTP statuses of unary errors by code.
+var httpStatuses = map[codes.Code]int{
+	codes.Canceled:           499,
+	codes.Unknown:            http.StatusInternalServerError,
+	codes.InvalidArgument:    http.StatusBadRequest,
+	codes.DeadlineExceeded:   http.StatusGatewayTimeout,
+	codes.NotFound:           http.StatusNotFound,
+	codes.AlreadyExists:      http.StatusConflict,
+	codes.PermissionDenied:   http.StatusForbidden,
+	codes.ResourceExhausted:  http.StatusTooManyRequests,
+	codes.FailedPrecondition: http.StatusBadRequest,
+	codes.Aborted:            http.StatusConflict,
+	codes.OutOfRange:         http.StatusBadRequest,
+	codes.Unimplemented:      http.StatusNotImplemented,
+	codes.Internal:           http.StatusInternalServerError,
+	codes.Unavailable:        http.StatusServiceUnavailable,
+	codes.DataLoss:           http.StatusInternalServerError,
+	codes.Unauthenticated:    http.StatusUnauthorized,
+}
+
+// wireError is the JSON form of an error.
+type wireError struct {
+	Code    string       `json:"code"`
+	Message string       `json:"message,omitempty"`
+	Details []wireDetail `json:"details,omitempty"`
+}
+
+type wireDetail struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+const typeURLPrefix = "type.googleapis.com/"
+
+func toWireError(err error) *wireError {
+	st := status.Convert(err)
+	we := &wireError{Code: codeNames[st.Code()], Message: st.Message()}
+	if we.Code == "" {
+		we.Code = codeNames[codes.Unknown]
+	}
+	for _, d := range st.Proto().GetDetails() {
+		we.Details = append(we.Details, wireDetail{
+			Type:  strings.TrimPrefix(d.GetTypeUrl(), typeURLPrefix),
+			Value: base64.RawStdEncoding.EncodeToString(d.GetValue()),
+		})
+	}
+	return we
+}
+
+func (we *wireError) err() error {
+	code := codes.Unknown
+	for c, name := range codeNames {
+		if name == we.Code {
+			code = c
+			break
+		}
+	}
+	s := &spb.Status{Code: int32(code), Message: we.Message}
+	for _, d := range we.Details {
+		value, err := base64.RawStdEncoding.DecodeString(strings.TrimRight(d.Value, "="))
+		if err != nil {
+			continue
+		}
+		s.Details = append(s.Details, &any.Any{TypeUrl: typeURLPrefix + d.Type, Value: value})
+	}
+	return status.ErrorProto(s)
+}
+
+// writeUnaryError writes err as the response to a unary call.
+func writeUnaryError(w http.ResponseWriter, err error) {
+	we := toWireError(err)
+	code := status.Code(err)
+	httpStatus, ok := httpStatuses[code]
+	if !ok {
+		httpStatus = http.StatusInternalServerError
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(httpStatus)
+	json.NewEncoder(w).Encode(we)
+}
+
+// codeFromHTTPStatus infers the code of an error response without a
+// Connect error body, e.g. from a proxy.
+func codeFromHTTPStatus(httpStatus int) codes.Code {
+	switch httpStatus {
+	case http.StatusBadRequest:
+		return codes.Internal
+	case http.StatusUnauthorized:
+		return codes.Unauthenticated
+	case http.StatusForbidden:
+		return codes.PermissionDenied
+	case http.StatusNotFound:
+		return codes.Unimplemented
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return codes.Unavailable
+	}
+	return codes.Unknown
+}
@@ -0,0 +1,310 @@
+package connectrpc
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// UnaryFunc calls a unary method with a decoded request.
+type UnaryFunc func(ctx context.Context, req proto.Message) (proto.Message, error)
+
+// ServerStreamFunc calls a server-streaming method with a decoded request,
+// sending responses on stream.
+type ServerStreamFunc func(req proto.Message, stream *ServerStream) error
+
+type procedure struct {
+	newRequest func() proto.Message
+	unary      UnaryFunc
+	stream     ServerStreamFunc
+	get        bool
+}
+
+// Handler serves Connect procedures at their gRPC paths
+// ("/pkg.Service/Method").
+type Handler struct {
+	procedures map[string]*procedure
+}
+
+// NewHandler returns a Handler with no procedures.
+func NewHandler() *Handler {
+	return &Handler{procedures: make(map[string]*procedure)}
+}
+
+// HandleUnary registers a unary procedure. If get is set, the procedure
+// has no side effects and also accepts GET requests.
+func (h *Handler) HandleUnary(path string, newRequest func() proto.Message, call UnaryFunc, get bool) {
+	h.procedures[path] = &procedure{newRequest: newRequest, unary: call, get: get}
+}
+
+// HandleServerStream registers a server-streaming procedure.
+func (h *Handler) HandleServerStream(path string, newRequest func() proto.Message, call ServerStreamFunc) {
+	h.procedures[path] = &procedure{newRequest: newRequest, stream: call}
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p, ok := h.procedures[r.URL.Path]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	ctx, cancel, err := requestContext(r)
+	if err != nil {
+		writeUnaryError(w, err)
+		return
+	}
+	defer cancel()
+	if p.stream != nil {
+		h.serveStream(ctx, w, r, p)
+		return
+	}
+	h.serveUnary(ctx, w, r, p)
+}
+
+// requestContext derives the context of a call from its timeout and
+// headers.
+func requestContext(r *http.Request) (context.Context, context.CancelFunc, error) {
+	ctx := r.Context()
+	md := metadata.MD{}
+	for k, vs := range r.Header {
+		k = strings.ToLower(k)
+		if strings.HasPrefix(k, "connect-") || k == "content-type" || k == "content-length" {
+			continue
+		}
+		md[k] = vs
+	}
+	ctx = metadata.NewIncomingContext(ctx, md)
+	timeout := r.Header.Get("Connect-Timeout-Ms")
+	if timeout == "" {
+		ctx, cancel := context.WithCancel(ctx)
+		return ctx, cancel, nil
+	}
+	ms, err := strconv.ParseInt(timeout, 10, 64)
+	if err != nil || ms < 0 {
+		return nil, nil, status.Errorf(codes.InvalidArgument, "invalid Connect-Timeout-Ms %q", timeout)
+	}
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(ms)*time.Millisecond)
+	return ctx, cancel, nil
+}
+
+func (h *Handler) serveUnary(ctx context.Context, w http.ResponseWriter, r *http.Request, p *procedure) {
+	var codec string
+	var body []byte
+	switch {
+	case r.Method == http.MethodPost:
+		var ok bool
+		codec, ok = codecFromContentType(r.Header.Get("Content-Type"), false)
+		if !ok {
+			w.WriteHeader(http.StatusUnsupportedMediaType)
+			return
+		}
+		var err error
+		if body, err = ioutil.ReadAll(r.Body); err != nil {
+			writeUnaryError(w, status.Errorf(codes.InvalidArgument, "reading request: %v", err))
+			return
+		}
+	case r.Method == http.MethodGet && p.get:
+		var err error
+		if codec, body, err = getRequest(r); err != nil {
+			writeUnaryError(w, err)
+			return
+		}
+	default:
+		w.Header().Set("Allow", http.MethodPost)
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	req := p.newRequest()
+	if err := unmarshal(codec, body, req); err != nil {
+		writeUnaryError(w, status.Errorf(codes.InvalidArgument, "decoding request: %v", err))
+		return
+	}
+	resp, err := p.unary(ctx, req)
+	if err != nil {
+		writeUnaryError(w, err)
+		return
+	}
+	data, err := marshal(codec, resp)
+	if err != nil {
+		writeUnaryError(w, status.Errorf(codes.Internal, "encoding response: %v", err))
+		return
+	}
+	w.Header().Set("Content-Type", contentType(codec, false))
+	w.Write(data)
+}
+
+// getRequest decodes the message of a GET request from its query.
+func getRequest(r *http.Request) (string, []byte, error) {
+	q := r.URL.Query()
+	codec := q.Get("encoding")
+	if codec != CodecProto && codec != CodecJSON {
+		return "", nil, status.Errorf(codes.InvalidArgument, "unsupported encoding %q", codec)
+	}
+	if c := q.Get("compression"); c != "" && c != "identity" {
+		return "", nil, status.Errorf(codes.Unimplemented, "unsupported compression %q", c)
+	}
+	msg := q.Get("message")
+	if q.Get("base64") != "1" {
+		return codec, []byte(msg), nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(msg, "="))
+	if err != nil {
+		return "", nil, status.Errorf(codes.InvalidArgument, "decoding message: %v", err)
+	}
+	return codec, data, nil
+}
+
+func (h *Handler) serveStream(ctx context.Context, w http.ResponseWriter, r *http.Request, p *procedure) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	codec, ok := codecFromContentType(r.Header.Get("Content-Type"), true)
+	if !ok {
+		w.WriteHeader(http.StatusUnsupportedMediaType)
+		return
+	}
+	stream := &ServerStream{ctx: ctx, w: w, codec: codec}
+	req := p.newRequest()
+	flags, data, err := readEnvelope(r.Body)
+	switch {
+	case err != nil:
+		err = status.Errorf(codes.InvalidArgument, "reading request: %v", err)
+	case flags&flagEndStream != 0:
+		err = status.Error(codes.InvalidArgument, "missing request message")
+	default:
+		if uerr := unmarshal(codec, data, req); uerr != nil {
+			err = status.Errorf(codes.InvalidArgument, "decoding request: %v", uerr)
+		}
+	}
+	if err == nil {
+		err = p.stream(req, stream)
+	}
+	stream.end(err)
+}
+
+// ServerStream is the response side of a server-streaming call. It
+// implements grpc.ServerStream so that gRPC service implementations can
+// serve Connect calls unchanged.
+type ServerStream struct {
+	ctx     context.Context
+	w       http.ResponseWriter
+	codec   string
+	header  metadata.MD
+	trailer metadata.MD
+	started bool
+}
+
+// Context returns the context of the call.
+func (s *ServerStream) Context() context.Context {
+	return s.ctx
+}
+
+// SetHeader merges md into the response headers, which are sent with the
+// first message.
+func (s *ServerStream) SetHeader(md metadata.MD) error {
+	if s.started {
+		return errors.New("connectrpc: headers already sent")
+	}
+	s.header = metadata.Join(s.header, md)
+	return nil
+}
+
+// SendHeader sends the response headers merged with md.
+func (s *ServerStream) SendHeader(md metadata.MD) error {
+	if err := s.SetHeader(md); err != nil {
+		return err
+	}
+	s.start()
+	return nil
+}
+
+// SetTrailer merges md into the trailers sent at the end of the stream.
+func (s *ServerStream) SetTrailer(md metadata.MD) {
+	s.trailer = metadata.Join(s.trailer, md)
+}
+
+func (s *ServerStream) start() {
+	if s.started {
+		return
+	}
+	s.started = true
+	for k, vs := range s.header {
+		for _, v := range vs {
+			s.w.Header().Add(k, v)
+		}
+	}
+	s.w.Header().Set("Content-Type", contentType(s.codec, true))
+	s.w.WriteHeader(http.StatusOK)
+}
+
+// SendMsg sends m, which must be a proto.Message.
+func (s *ServerStream) SendMsg(m interface{}) error {
+	msg, ok := m.(proto.Message)
+	if !ok {
+		return status.Errorf(codes.Internal, "connectrpc: %T is not a proto.Message", m)
+	}
+	data, err := marshal(s.codec, msg)
+	if err != nil {
+		return status.Errorf(codes.Internal, "encoding response: %v", err)
+	}
+	s.start()
+	if err := writeEnvelope(s.w, 0, data); err != nil {
+		return err
+	}
+	if f, ok := s.w.(http.Flusher); ok {
+		f.Flush()
+	}
+	return nil
+}
+
+// RecvMsg fails: the single request of a server-streaming call is decoded
+// by the Handler.
+func (s *ServerStream) RecvMsg(m interface{}) error {
+	return status.Error(codes.Internal, "connectrpc: RecvMsg on a server stream")
+}
+
+// end finishes the stream with err, or successfully if err is nil.
+func (s *ServerStream) end(err error) {
+	s.start()
+	var end struct {
+		Error    *wireError          `json:"error,omitempty"`
+		Metadata map[string][]string `json:"metadata,omitempty"`
+	}
+	if err != nil {
+		end.Error = toWireError(err)
+	}
+	if len(s.trailer) > 0 {
+		end.Metadata = s.trailer
+	}
+	data, _ := json.Marshal(end)
+	writeEnvelope(s.w, flagEndStream, data)
+}
+
+// WithGRPC returns a handler that serves gRPC requests, recognized by
+// their application/grpc content type, with grpcServer (typically a
+// *grpc.Server) and everything else with h. Because Connect procedures
+// live at the gRPC paths, clients of either protocol reach the same
+// endpoints. gRPC needs HTTP/2, e.g. through golang.org/x/net/http2/h2c.
+func WithGRPC(h, grpcServer http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ProtoMajor == 2 && strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc") {
+			grpcServer.ServeHTTP(w, r)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
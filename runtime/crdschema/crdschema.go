@@ -0,0 +1,19 @@
+// Package crdschema is the runtime support for code generated by
+// protoc-gen-go-crdschema: the pointers to constants that the optional
+// bounds of apiextensionsv1.JSONSchemaProps take.
+package crdschema
+
+// Int64 returns a pointer to v.
+func Int64(v int64) *int64 {
+	return &v
+}
+
+// Float64 returns a pointer to v.
+func Float64(v float64) *float64 {
+	return &v
+}
+
+// Bool returns a pointer to v.
+func Bool(v bool) *bool {
+	return &v
+}
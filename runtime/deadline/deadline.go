@@ -0,0 +1,36 @@
+// Package deadline is the runtime support for code generated by
+// protoc-gen-go-deadlines. It gives calls a default deadline when their
+// context has none, and counts how often that happens.
+package deadline
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Calls counts the calls going through WithDefault by full method name and
+// by "deadline": "default" when the default was applied, "caller" when the
+// context already had a deadline.
+var Calls = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "grpc_client_deadline_calls_total",
+	Help: "Number of gRPC client calls by whether the default deadline was applied.",
+}, []string{"method", "deadline"})
+
+// Register registers Calls with registerer.
+func Register(registerer prometheus.Registerer) error {
+	return registerer.Register(Calls)
+}
+
+// WithDefault returns ctx with a deadline of timeout from now if ctx has no
+// deadline, and ctx unchanged otherwise. The returned cancel must be
+// called once the call completes.
+func WithDefault(ctx context.Context, method string, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if _, ok := ctx.Deadline(); ok {
+		Calls.WithLabelValues(method, "caller").Inc()
+		return ctx, func() {}
+	}
+	Calls.WithLabelValues(method, "default").Inc()
+	return context.WithTimeout(ctx, timeout)
+}
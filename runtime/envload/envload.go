@@ -0,0 +1,149 @@
+// Package envload is the runtime support for code generated by
+// protoc-gen-go-env: the Loader reading environment variables into the
+// fields of configuration messages, and the ParseError it reports for
+// values that do not parse.
+package envload
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ParseError is the error for an environment variable whose value does not
+// parse as its field.
+type ParseError struct {
+	// Var is the name of the variable.
+	Var string
+	// Value is its value.
+	Value string
+	// Err is the error of the parse function.
+	Err error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("envload: %s=%q: %v", e.Var, e.Value, e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// Loader reads the variables of an environment, collecting the errors of
+// those that do not parse.
+type Loader struct {
+	env  map[string]string
+	errs []error
+}
+
+// New returns the Loader of environ, a list of "key=value" strings as
+// returned by os.Environ.
+func New(environ []string) *Loader {
+	l := &Loader{env: make(map[string]string, len(environ))}
+	for _, kv := range environ {
+		if i := strings.IndexByte(kv, '='); i > 0 {
+			l.env[kv[:i]] = kv[i+1:]
+		}
+	}
+	return l
+}
+
+// Any reports whether a variable starting with prefix is set.
+func (l *Loader) Any(prefix string) bool {
+	for name := range l.env {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Err returns the *ParseError of each variable that did not parse, joined,
+// or nil.
+func (l *Loader) Err() error {
+	return errors.Join(l.errs...)
+}
+
+// lookup returns the value of the variable name and reports whether it is
+// set.
+func (l *Loader) lookup(name string) (string, bool) {
+	v, ok := l.env[name]
+	return v, ok
+}
+
+func (l *Loader) fail(name, value string, err error) {
+	l.errs = append(l.errs, &ParseError{Var: name, Value: value, Err: err})
+}
+
+// Value sets *p to the variable name parsed by parse, one of the functions
+// of package scalarparse, if it is set.
+func Value[T any](l *Loader, name string, p *T, parse func(string) (T, error)) {
+	s, ok := l.lookup(name)
+	if !ok {
+		return
+	}
+	x, err := parse(s)
+	if err != nil {
+		l.fail(name, s, err)
+		return
+	}
+	*p = x
+}
+
+// Optional is Value for a field with presence: *p stays nil unless the
+// variable is set.
+func Optional[T any](l *Loader, name string, p **T, parse func(string) (T, error)) {
+	s, ok := l.lookup(name)
+	if !ok {
+		return
+	}
+	x, err := parse(s)
+	if err != nil {
+		l.fail(name, s, err)
+		return
+	}
+	*p = &x
+}
+
+// List sets *p to the comma separated elements of the variable name, each
+// parsed by parse, if it is set. Spaces around the elements are ignored,
+// and an empty variable sets an empty list.
+func List[T any](l *Loader, name string, p *[]T, parse func(string) (T, error)) {
+	s, ok := l.lookup(name)
+	if !ok {
+		return
+	}
+	list := []T{}
+	if strings.TrimSpace(s) != "" {
+		for _, e := range strings.Split(s, ",") {
+			x, err := parse(strings.TrimSpace(e))
+			if err != nil {
+				l.fail(name, s, err)
+				return
+			}
+			list = append(list, x)
+		}
+	}
+	*p = list
+}
+
+// Map sets *p to the comma separated key=value pairs of the variable name,
+// if it is set, as List does.
+func Map(l *Loader, name string, p *map[string]string) {
+	s, ok := l.lookup(name)
+	if !ok {
+		return
+	}
+	m := make(map[string]string)
+	if strings.TrimSpace(s) != "" {
+		for _, e := range strings.Split(s, ",") {
+			i := strings.IndexByte(e, '=')
+			if i < 0 {
+				l.fail(name, s, fmt.Errorf("%q is not key=value", strings.TrimSpace(e)))
+				return
+			}
+			m[strings.TrimSpace(e[:i])] = strings.TrimSpace(e[i+1:])
+		}
+	}
+	*p = m
+}
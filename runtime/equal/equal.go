@@ -0,0 +1,23 @@
+// Package equal is the runtime support for code generated by
+// protoc-gen-go-equal.
+package equal
+
+import "github.com/golang/protobuf/proto"
+
+// Float64 reports whether a and b are equal, treating NaNs as equal to
+// each other.
+func Float64(a, b float64) bool {
+	return a == b || a != a && b != b
+}
+
+// Float32 reports whether a and b are equal, treating NaNs as equal to
+// each other.
+func Float32(a, b float32) bool {
+	return a == b || a != a && b != b
+}
+
+// Proto reports whether a and b are equal according to proto.Equal, for
+// messages without a generated Equal method.
+func Proto[T proto.Message](a, b T) bool {
+	return proto.Equal(a, b)
+}
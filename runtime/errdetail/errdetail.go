@@ -0,0 +1,93 @@
+// Package errdetail is the runtime support for code generated by
+// protoc-gen-go-errordetails. It finds the google.rpc.Status of errors
+// through wrapping and turns received status errors into the typed errors
+// registered for their details, so that errors.As works on both sides of a
+// call.
+package errdetail
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/golang/protobuf/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// WrapFunc returns the typed error for a status carrying detail.
+type WrapFunc func(st *status.Status, detail proto.Message) error
+
+var (
+	mu    sync.RWMutex
+	wraps = make(map[string]WrapFunc)
+)
+
+// Register registers wrap for the details of the message type named name,
+// e.g. "example.v1.NotFound". Generated code calls it from init, which may
+// run before that of the file registering the message type, so the name is
+// given rather than taken from a message.
+func Register(name string, wrap WrapFunc) {
+	mu.Lock()
+	defer mu.Unlock()
+	wraps[name] = wrap
+}
+
+// Status returns the status of the first error in the chain of err with a
+// GRPCStatus method.
+func Status(err error) (*status.Status, bool) {
+	var s interface{ GRPCStatus() *status.Status }
+	if err == nil || !errors.As(err, &s) {
+		return nil, false
+	}
+	return s.GRPCStatus(), true
+}
+
+// Details returns the decoded details of the status of err. Details whose
+// type is not linked into the binary are left out.
+func Details(err error) []proto.Message {
+	st, ok := Status(err)
+	if !ok {
+		return nil
+	}
+	var details []proto.Message
+	for _, d := range st.Details() {
+		if m, ok := d.(proto.Message); ok {
+			details = append(details, m)
+		}
+	}
+	return details
+}
+
+// Typed returns the typed error registered for the first detail of the
+// status of err, or err if there is none. The typed error keeps the status,
+// so status.FromError and status.Code still work on it.
+func Typed(err error) error {
+	st, ok := Status(err)
+	if !ok {
+		return err
+	}
+	mu.RLock()
+	defer mu.RUnlock()
+	for _, d := range st.Details() {
+		m, ok := d.(proto.Message)
+		if !ok {
+			continue
+		}
+		if wrap, ok := wraps[proto.MessageName(m)]; ok {
+			return wrap(st, m)
+		}
+	}
+	return err
+}
+
+// UnaryClientInterceptor returns an interceptor passing the errors of calls
+// through Typed.
+func UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if err := invoker(ctx, method, req, reply, cc, opts...); err != nil {
+			return Typed(err)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,118 @@
+// Package event is the runtime support for code generated by
+// protoc-gen-go-event. It packs messages in the Envelope declared in
+// event.proto, the standard form of the events our services publish.
+package event
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
+	anypb "github.com/golang/protobuf/ptypes/any"
+	"github.com/golang/protobuf/ptypes/timestamp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// Envelope is the (f4tq.plugins.event.Envelope) message.
+type Envelope struct {
+	TypeUrl              string               `protobuf:"bytes,1,opt,name=type_url,json=typeUrl,proto3" json:"type_url,omitempty"`
+	SchemaVersion        string               `protobuf:"bytes,2,opt,name=schema_version,json=schemaVersion,proto3" json:"schema_version,omitempty"`
+	OccurredAt           *timestamp.Timestamp `protobuf:"bytes,3,opt,name=occurred_at,json=occurredAt,proto3" json:"occurred_at,omitempty"`
+	TraceContext         map[string]string    `protobuf:"bytes,4,rep,name=trace_context,json=traceContext,proto3" json:"trace_context,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	Payload              *anypb.Any           `protobuf:"bytes,5,opt,name=payload,proto3" json:"payload,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}             `json:"-"`
+	XXX_unrecognized     []byte               `json:"-"`
+	XXX_sizecache        int32                `json:"-"`
+}
+
+func (m *Envelope) Reset()         { *m = Envelope{} }
+func (m *Envelope) String() string { return proto.CompactTextString(m) }
+func (*Envelope) ProtoMessage()    {}
+
+func (m *Envelope) GetTypeUrl() string {
+	if m != nil {
+		return m.TypeUrl
+	}
+	return ""
+}
+
+func (m *Envelope) GetSchemaVersion() string {
+	if m != nil {
+		return m.SchemaVersion
+	}
+	return ""
+}
+
+func (m *Envelope) GetOccurredAt() *timestamp.Timestamp {
+	if m != nil {
+		return m.OccurredAt
+	}
+	return nil
+}
+
+func (m *Envelope) GetTraceContext() map[string]string {
+	if m != nil {
+		return m.TraceContext
+	}
+	return nil
+}
+
+func (m *Envelope) GetPayload() *anypb.Any {
+	if m != nil {
+		return m.Payload
+	}
+	return nil
+}
+
+func init() {
+	proto.RegisterType((*Envelope)(nil), "f4tq.plugins.event.Envelope")
+	proto.RegisterMapType((map[string]string)(nil), "f4tq.plugins.event.Envelope.TraceContextEntry")
+}
+
+// ErrNoPayload is returned by Unwrap for an envelope without a payload.
+var ErrNoPayload = errors.New("event: envelope has no payload")
+
+// Wrap packs m in an envelope stamped with schemaVersion, occurredAt, or
+// the current time if it is zero, and the trace context that the global
+// OpenTelemetry propagator finds in ctx.
+func Wrap(ctx context.Context, m proto.Message, schemaVersion string, occurredAt time.Time) (*Envelope, error) {
+	payload, err := ptypes.MarshalAny(m)
+	if err != nil {
+		return nil, err
+	}
+	if occurredAt.IsZero() {
+		occurredAt = time.Now()
+	}
+	ts, err := ptypes.TimestampProto(occurredAt)
+	if err != nil {
+		return nil, err
+	}
+	carrier := propagation.MapCarrier{}
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+	e := &Envelope{
+		TypeUrl:       payload.GetTypeUrl(),
+		SchemaVersion: schemaVersion,
+		OccurredAt:    ts,
+		Payload:       payload,
+	}
+	if len(carrier) > 0 {
+		e.TraceContext = carrier
+	}
+	return e, nil
+}
+
+// Unwrap unpacks the payload of e into m, which must be of the packed
+// type, and returns ctx with the trace context of e, so that the handling
+// of the event joins the producer's trace.
+func Unwrap(ctx context.Context, e *Envelope, m proto.Message) (context.Context, error) {
+	if e.GetPayload() == nil {
+		return ctx, ErrNoPayload
+	}
+	if err := ptypes.UnmarshalAny(e.GetPayload(), m); err != nil {
+		return ctx, err
+	}
+	return otel.GetTextMapPropagator().Extract(ctx, propagation.MapCarrier(e.GetTraceContext())), nil
+}
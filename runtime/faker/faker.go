@@ -0,0 +1,264 @@
+// Package faker is the runtime support for the fixture constructors
+// generated by protoc-gen-go-faker. A Faker draws realistic values, such as
+// names, email addresses and timestamps, from a seeded source, so that the
+// same seed always builds the same fixture.
+package faker
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/golang/protobuf/ptypes/duration"
+	"github.com/golang/protobuf/ptypes/timestamp"
+)
+
+// MaxDepth is the depth the NewFake functions nest optional message fields
+// down to. Required message fields, and the min_items first elements of
+// repeated ones, are set at any depth.
+var MaxDepth = 3
+
+// MaxItems is the greatest number of elements beyond their min_items the
+// NewFake functions put in repeated and map fields.
+var MaxItems = 3
+
+// Epoch and Span bound the timestamps of the fixtures: they fall within
+// Span of Epoch, before it for the fields marked "past" and after it for
+// those marked "future". Epoch is fixed, rather than the current time, so
+// that fixtures do not change from a run to the next.
+var (
+	Epoch = time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	Span  = 365 * 24 * time.Hour
+)
+
+var (
+	firstNames = []string{"Ada", "Alan", "Amara", "Barbara", "Chen", "Diego", "Edsger", "Fatima", "Grace", "Hiro", "Ingrid", "Jamal", "Katherine", "Leslie", "Margaret", "Nia", "Olga", "Priya", "Radia", "Sofia", "Tim", "Yusuf"}
+	lastNames  = []string{"Almeida", "Backus", "Dijkstra", "Hamilton", "Hopper", "Johnson", "Kay", "Knuth", "Lamport", "Liskov", "Lovelace", "Mensah", "Nakamura", "Okafor", "Perlman", "Ritchie", "Sato", "Thompson", "Turing", "Wirth"}
+	words      = []string{"alpha", "amber", "anchor", "basket", "breeze", "canyon", "cedar", "comet", "delta", "ember", "falcon", "garden", "harbor", "island", "jasper", "lantern", "maple", "meadow", "nectar", "orbit", "pepper", "quartz", "river", "saffron", "summit", "timber", "velvet", "willow"}
+	companies  = []string{"Acme", "Globex", "Initech", "Umbrella", "Hooli", "Stark", "Wayne", "Tyrell", "Cyberdyne", "Soylent"}
+	suffixes   = []string{"Inc.", "LLC", "Ltd.", "GmbH", "Group", "Labs"}
+	cities     = []string{"Amsterdam", "Austin", "Berlin", "Bogotá", "Cape Town", "Lagos", "Lisbon", "Montréal", "Mumbai", "Osaka", "Seoul", "Sydney", "Toronto", "Zürich"}
+	countries  = []string{"AU", "BR", "CA", "CH", "CO", "DE", "IN", "JP", "KR", "NG", "NL", "PT", "US", "ZA"}
+	// domains are reserved for documentation by RFC 2606, so that fixtures
+	// never address real mailboxes or hosts.
+	domains = []string{"example.com", "example.net", "example.org"}
+)
+
+// Faker draws the values of fixtures.
+type Faker struct {
+	r *rand.Rand
+}
+
+// New returns a Faker drawing from seed.
+func New(seed int64) *Faker {
+	return &Faker{r: rand.New(rand.NewSource(seed))}
+}
+
+func (f *Faker) pick(list []string) string {
+	return list[f.r.Intn(len(list))]
+}
+
+// Intn returns a number in [0, n).
+func (f *Faker) Intn(n int) int {
+	return f.r.Intn(n)
+}
+
+// Bool returns true or false.
+func (f *Faker) Bool() bool {
+	return f.r.Intn(2) == 1
+}
+
+// Int returns a number in [lo, hi].
+func (f *Faker) Int(lo, hi int64) int64 {
+	if hi <= lo {
+		return lo
+	}
+	span := uint64(hi - lo)
+	if span == 1<<64-1 {
+		return int64(f.r.Uint64())
+	}
+	return lo + int64(f.r.Uint64()%(span+1))
+}
+
+// Uint returns a number in [lo, hi].
+func (f *Faker) Uint(lo, hi uint64) uint64 {
+	if hi <= lo {
+		return lo
+	}
+	if hi-lo == 1<<64-1 {
+		return f.r.Uint64()
+	}
+	return lo + f.r.Uint64()%(hi-lo+1)
+}
+
+// Float returns a number in [lo, hi], rounded to cents when that keeps it
+// in the range.
+func (f *Faker) Float(lo, hi float64) float64 {
+	v := lo + f.r.Float64()*(hi-lo)
+	if c := float64(int64(v*100)) / 100; c >= lo && c <= hi {
+		return c
+	}
+	return v
+}
+
+// Pick returns one of values, which are the numbers of enum values.
+func (f *Faker) Pick(values ...int32) int32 {
+	return values[f.r.Intn(len(values))]
+}
+
+// Items returns the number of elements of a repeated or map field of min
+// to max elements, or of at least min if max is -1: at least one where
+// allowed, and at most MaxItems beyond min.
+func (f *Faker) Items(min, max int) int {
+	if max < 0 || max > min+MaxItems {
+		max = min + MaxItems
+	}
+	lo := min
+	if lo == 0 && max > 0 {
+		lo = 1
+	}
+	return lo + f.r.Intn(max-lo+1)
+}
+
+// NestedItems returns the number of elements of a repeated or map field of
+// messages at depth: as Items returns, or min once the optional message
+// fields are no longer set.
+func (f *Faker) NestedItems(depth, min, max int) int {
+	if depth <= 0 {
+		return min
+	}
+	return f.Items(min, max)
+}
+
+// Bytes returns min to max random bytes, or min to min+16 if max is -1.
+func (f *Faker) Bytes(min, max int) []byte {
+	if max < 0 || max > min+16 {
+		max = min + 16
+	}
+	b := make([]byte, min+f.r.Intn(max-min+1))
+	f.r.Read(b)
+	return b
+}
+
+// Text returns words making up min to max characters, or at least min if
+// max is -1.
+func (f *Faker) Text(min, max int) string {
+	n := 1 + f.r.Intn(4)
+	ws := make([]string, n)
+	for i := range ws {
+		ws[i] = f.pick(words)
+	}
+	return Clip(strings.Join(ws, " "), min, max)
+}
+
+// Word returns a word.
+func (f *Faker) Word() string {
+	return f.pick(words)
+}
+
+// Sentence returns a sentence of a few words.
+func (f *Faker) Sentence() string {
+	s := f.Text(0, -1)
+	return strings.ToUpper(s[:1]) + s[1:] + "."
+}
+
+// FirstName returns a given name.
+func (f *Faker) FirstName() string {
+	return f.pick(firstNames)
+}
+
+// LastName returns a family name.
+func (f *Faker) LastName() string {
+	return f.pick(lastNames)
+}
+
+// Name returns the full name of a person.
+func (f *Faker) Name() string {
+	return f.FirstName() + " " + f.LastName()
+}
+
+// Username returns a login name.
+func (f *Faker) Username() string {
+	return fmt.Sprintf("%s%d", strings.ToLower(f.FirstName()), f.r.Intn(100))
+}
+
+// Email returns an email address at a documentation domain.
+func (f *Faker) Email() string {
+	return fmt.Sprintf("%s.%s@%s", strings.ToLower(f.FirstName()), strings.ToLower(f.LastName()), f.pick(domains))
+}
+
+// Phone returns a phone number in the fictional 555 range.
+func (f *Faker) Phone() string {
+	return fmt.Sprintf("+1 %03d-555-%04d", 200+f.r.Intn(800), 100+f.r.Intn(100))
+}
+
+// URL returns the https URL of a page at a documentation domain.
+func (f *Faker) URL() string {
+	return fmt.Sprintf("https://www.%s/%s/%s", f.pick(domains), f.pick(words), f.pick(words))
+}
+
+// UUID returns a random, version 4, UUID.
+func (f *Faker) UUID() string {
+	var b [16]byte
+	f.r.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// IPv4 returns an address of the documentation range 192.0.2.0/24.
+func (f *Faker) IPv4() string {
+	return fmt.Sprintf("192.0.2.%d", 1+f.r.Intn(254))
+}
+
+// Company returns the name of a company.
+func (f *Faker) Company() string {
+	return f.pick(companies) + " " + f.pick(suffixes)
+}
+
+// City returns the name of a city.
+func (f *Faker) City() string {
+	return f.pick(cities)
+}
+
+// CountryCode returns an ISO 3166-1 alpha-2 country code.
+func (f *Faker) CountryCode() string {
+	return f.pick(countries)
+}
+
+// Time returns a timestamp within Span of Epoch, to the second: before
+// Epoch if when is "past", after it if "future".
+func (f *Faker) Time(when string) *timestamp.Timestamp {
+	lo, hi := -int64(Span/time.Second), int64(Span/time.Second)
+	switch when {
+	case "past":
+		hi = -1
+	case "future":
+		lo = 1
+	}
+	return &timestamp.Timestamp{Seconds: Epoch.Unix() + f.Int(lo, hi)}
+}
+
+// Duration returns a duration from a second to a day, to the second.
+func (f *Faker) Duration() *duration.Duration {
+	return &duration.Duration{Seconds: f.Int(1, 24*60*60)}
+}
+
+// Clip returns s padded with x's to min characters, and cut to max, unless
+// max is -1.
+func Clip(s string, min, max int) string {
+	if n := utf8.RuneCountInString(s); n < min {
+		s += strings.Repeat("x", min-n)
+	}
+	if max >= 0 && utf8.RuneCountInString(s) > max {
+		s = string([]rune(s)[:max])
+	}
+	return s
+}
+
+// Ptr returns a pointer to v, for the optional fields of the fixtures.
+func Ptr[T any](v T) *T {
+	return &v
+}
@@ -0,0 +1,190 @@
+// Package fieldcrypt is the runtime support for code generated by
+// protoc-gen-go-encrypt. It envelope-encrypts field values: each call to
+// EncryptFields draws a data key, wrapped by a KMS, and seals the values
+// with AES-256-GCM under it, authenticating the full name of their field.
+//
+// A sealed value is a version byte, the length of the wrapped key as a
+// varint, the wrapped key, a 12-byte nonce and the ciphertext. String
+// fields hold it in standard base64.
+package fieldcrypt
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// version is the first byte of sealed values.
+const version = 1
+
+// KMS wraps and unwraps data keys with a master key it keeps.
+type KMS interface {
+	// WrapKey returns the data key dek encrypted under the master key.
+	WrapKey(ctx context.Context, dek []byte) ([]byte, error)
+	// UnwrapKey returns the data key that WrapKey encrypted as wrapped.
+	UnwrapKey(ctx context.Context, wrapped []byte) ([]byte, error)
+}
+
+// Crypter transforms the values of the encrypted fields. field is the full
+// name of their field, e.g. "pkg.User.ssn". Empty values are kept as they
+// are. After an error, values are kept as they are and Err reports it.
+type Crypter interface {
+	String(v, field string) string
+	Bytes(v []byte, field string) []byte
+	Err() error
+}
+
+// ErrMalformed is reported for values that are not sealed values.
+var ErrMalformed = errors.New("fieldcrypt: malformed sealed value")
+
+// Sealer encrypts values under one data key, drawn at the first value.
+type Sealer struct {
+	ctx     context.Context
+	kms     KMS
+	aead    cipher.AEAD
+	wrapped []byte
+	err     error
+}
+
+// NewSealer returns a Sealer wrapping its data key with kms.
+func NewSealer(ctx context.Context, kms KMS) *Sealer {
+	return &Sealer{ctx: ctx, kms: kms}
+}
+
+// Err returns the first error met.
+func (s *Sealer) Err() error {
+	return s.err
+}
+
+// Bytes returns v sealed.
+func (s *Sealer) Bytes(v []byte, field string) []byte {
+	if len(v) == 0 || s.err != nil {
+		return v
+	}
+	if s.aead == nil {
+		dek := make([]byte, 32)
+		if _, err := rand.Read(dek); err != nil {
+			s.err = err
+			return v
+		}
+		wrapped, err := s.kms.WrapKey(s.ctx, dek)
+		if err != nil {
+			s.err = err
+			return v
+		}
+		if s.aead, s.err = newAEAD(dek); s.err != nil {
+			return v
+		}
+		s.wrapped = wrapped
+	}
+	nonce := make([]byte, s.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		s.err = err
+		return v
+	}
+	out := []byte{version}
+	out = binary.AppendUvarint(out, uint64(len(s.wrapped)))
+	out = append(out, s.wrapped...)
+	out = append(out, nonce...)
+	return s.aead.Seal(out, nonce, v, []byte(field))
+}
+
+// String returns v sealed, in base64.
+func (s *Sealer) String(v, field string) string {
+	if v == "" || s.err != nil {
+		return v
+	}
+	b := s.Bytes([]byte(v), field)
+	if s.err != nil {
+		return v
+	}
+	return base64.StdEncoding.EncodeToString(b)
+}
+
+// Opener decrypts sealed values, unwrapping each data key once.
+type Opener struct {
+	ctx  context.Context
+	kms  KMS
+	keys map[string]cipher.AEAD
+	err  error
+}
+
+// NewOpener returns an Opener unwrapping data keys with kms.
+func NewOpener(ctx context.Context, kms KMS) *Opener {
+	return &Opener{ctx: ctx, kms: kms, keys: make(map[string]cipher.AEAD)}
+}
+
+// Err returns the first error met.
+func (o *Opener) Err() error {
+	return o.err
+}
+
+// Bytes returns the value sealed in v.
+func (o *Opener) Bytes(v []byte, field string) []byte {
+	if len(v) == 0 || o.err != nil {
+		return v
+	}
+	out, err := o.open(v, field)
+	if err != nil {
+		o.err = fmt.Errorf("%s: %w", field, err)
+		return v
+	}
+	return out
+}
+
+// String returns the value sealed in the base64 v.
+func (o *Opener) String(v, field string) string {
+	if v == "" || o.err != nil {
+		return v
+	}
+	b, err := base64.StdEncoding.DecodeString(v)
+	if err != nil {
+		o.err = fmt.Errorf("%s: %w", field, ErrMalformed)
+		return v
+	}
+	out := o.Bytes(b, field)
+	if o.err != nil {
+		return v
+	}
+	return string(out)
+}
+
+func (o *Opener) open(v []byte, field string) ([]byte, error) {
+	if v[0] != version {
+		return nil, ErrMalformed
+	}
+	n, m := binary.Uvarint(v[1:])
+	if m <= 0 || uint64(len(v)-1-m) < n {
+		return nil, ErrMalformed
+	}
+	wrapped := v[1+m : 1+m+int(n)]
+	rest := v[1+m+int(n):]
+	aead, ok := o.keys[string(wrapped)]
+	if !ok {
+		dek, err := o.kms.UnwrapKey(o.ctx, wrapped)
+		if err != nil {
+			return nil, err
+		}
+		if aead, err = newAEAD(dek); err != nil {
+			return nil, err
+		}
+		o.keys[string(wrapped)] = aead
+	}
+	if len(rest) < aead.NonceSize() {
+		return nil, ErrMalformed
+	}
+	return aead.Open(nil, rest[:aead.NonceSize()], rest[aead.NonceSize():], []byte(field))
+}
+
+func newAEAD(dek []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(dek)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,75 @@
+// Package fieldmask is the runtime support for code generated by
+// protoc-gen-go-mask and protoc-gen-go-maskpath.
+package fieldmask
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Tree holds field mask paths by their first name. A name mapped to nil
+// selects the whole field; otherwise the subtree selects fields of the
+// message in it.
+type Tree map[string]Tree
+
+// Parse returns the tree of paths. A path selecting a field takes
+// precedence over paths descending into it, so "a" and "a.b" parse as "a".
+func Parse(paths []string) Tree {
+	t := make(Tree)
+	for _, p := range paths {
+		node := t
+		names := strings.Split(p, ".")
+		for i, name := range names {
+			sub, ok := node[name]
+			if ok && sub == nil {
+				// The field is already selected as a whole.
+				break
+			}
+			if i == len(names)-1 {
+				node[name] = nil
+				break
+			}
+			if sub == nil {
+				sub = make(Tree)
+				node[name] = sub
+			}
+			node = sub
+		}
+	}
+	return t
+}
+
+// PathError reports a path naming no field of a message.
+type PathError struct {
+	// Message is the full name of the message, e.g. "pkg.User".
+	Message string
+	Path    string
+}
+
+func (e *PathError) Error() string {
+	return fmt.Sprintf("invalid field mask path %q for %s", e.Path, e.Message)
+}
+
+// Elem reports whether path, the rest of a path after a repeated field, is
+// "*", optionally followed by a path that valid accepts. valid checks the
+// paths of the element message, and is nil for scalar elements. Elements
+// cannot be selected by index.
+func Elem(path string, valid func(string) bool) bool {
+	head, rest, more := strings.Cut(path, ".")
+	if head != "*" {
+		return false
+	}
+	return !more || (valid != nil && valid(rest))
+}
+
+// Key reports whether path, the rest of a path after a map field, is a key
+// or "*", optionally followed by a path that valid accepts. valid checks
+// the paths of the value message, and is nil for scalar values. Keys are
+// not checked against the key type.
+func Key(path string, valid func(string) bool) bool {
+	head, rest, more := strings.Cut(path, ".")
+	if head == "" {
+		return false
+	}
+	return !more || (valid != nil && valid(rest))
+}
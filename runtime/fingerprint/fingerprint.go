@@ -0,0 +1,123 @@
+// Package fingerprint is the runtime support for code generated by
+// protoc-gen-go-hash. A Hasher feeds field numbers and values to SHA-256 in
+// a fixed encoding, so fingerprints are stable across processes and
+// releases as long as the schema is.
+package fingerprint
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"hash"
+	"math"
+	"sort"
+
+	"github.com/golang/protobuf/proto"
+
+	"github.com/f4tq/protoc-go-plugins/runtime/jcs"
+)
+
+// Hasher accumulates the fields of a message.
+type Hasher struct {
+	h   hash.Hash
+	buf [binary.MaxVarintLen64]byte
+}
+
+// New returns an empty Hasher.
+func New() *Hasher {
+	return &Hasher{h: sha256.New()}
+}
+
+// Field starts the field numbered num.
+func (h *Hasher) Field(num int32) {
+	h.Uvarint(uint64(num))
+}
+
+// End ends a message. Field numbers start at 1, so the 0 it writes cannot
+// be mistaken for a field.
+func (h *Hasher) End() {
+	h.Uvarint(0)
+}
+
+// Len writes the number of items of a repeated field.
+func (h *Hasher) Len(n int) {
+	h.Uvarint(uint64(n))
+}
+
+// Uvarint writes v as a varint.
+func (h *Hasher) Uvarint(v uint64) {
+	n := binary.PutUvarint(h.buf[:], v)
+	h.h.Write(h.buf[:n])
+}
+
+// Uint64 writes v.
+func (h *Hasher) Uint64(v uint64) {
+	binary.BigEndian.PutUint64(h.buf[:8], v)
+	h.h.Write(h.buf[:8])
+}
+
+// Int64 writes v.
+func (h *Hasher) Int64(v int64) {
+	h.Uint64(uint64(v))
+}
+
+// Float64 writes v. -0 hashes like 0 and all NaNs hash alike.
+func (h *Hasher) Float64(v float64) {
+	switch {
+	case v == 0:
+		v = 0
+	case v != v:
+		v = math.NaN()
+	}
+	h.Uint64(math.Float64bits(v))
+}
+
+// Bool writes v.
+func (h *Hasher) Bool(v bool) {
+	if v {
+		h.Uvarint(1)
+	} else {
+		h.Uvarint(0)
+	}
+}
+
+// String writes s prefixed with its length.
+func (h *Hasher) String(s string) {
+	h.Uvarint(uint64(len(s)))
+	h.h.Write([]byte(s))
+}
+
+// Bytes writes b prefixed with its length.
+func (h *Hasher) Bytes(b []byte) {
+	h.Uvarint(uint64(len(b)))
+	h.h.Write(b)
+}
+
+// Proto writes m, a message without generated hash methods, by its
+// canonical JSON encoding. Messages jsonpb cannot encode hash as empty.
+func (h *Hasher) Proto(m proto.Message) {
+	b, _ := jcs.Marshal(m)
+	h.Bytes(b)
+}
+
+// Unordered writes the digests of the entries of a map in sorted order, so
+// that the result does not depend on iteration order.
+func (h *Hasher) Unordered(digests [][]byte) {
+	sort.Slice(digests, func(i, j int) bool {
+		return bytes.Compare(digests[i], digests[j]) < 0
+	})
+	h.Len(len(digests))
+	for _, d := range digests {
+		h.h.Write(d)
+	}
+}
+
+// Sum returns the SHA-256 digest of what was written.
+func (h *Hasher) Sum() []byte {
+	return h.h.Sum(nil)
+}
+
+// Sum64 returns the first 8 bytes of Sum as a big-endian integer.
+func (h *Hasher) Sum64() uint64 {
+	return binary.BigEndian.Uint64(h.Sum())
+}
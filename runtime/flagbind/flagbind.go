@@ -0,0 +1,111 @@
+// Package flagbind is the runtime support for code generated by
+// protoc-gen-go-flags: the pflag.Value implementations binding the fields
+// that have no pflag counterpart, enums, fields with presence and
+// google.protobuf.Duration.
+package flagbind
+
+import (
+	"fmt"
+
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/duration"
+	"github.com/spf13/pflag"
+
+	"github.com/f4tq/protoc-go-plugins/runtime/scalarparse"
+)
+
+// Value returns the flag value setting *p to its argument parsed by parse,
+// one of the functions of package scalarparse. typ names the type of the
+// argument in help messages.
+func Value[T any](p *T, typ string, parse func(string) (T, error)) pflag.Value {
+	return &value[T]{p: p, typ: typ, parse: parse}
+}
+
+type value[T any] struct {
+	p     *T
+	typ   string
+	parse func(string) (T, error)
+}
+
+func (v *value[T]) Set(s string) error {
+	x, err := v.parse(s)
+	if err != nil {
+		return err
+	}
+	*v.p = x
+	return nil
+}
+
+func (v *value[T]) String() string {
+	return fmt.Sprint(*v.p)
+}
+
+func (v *value[T]) Type() string {
+	return v.typ
+}
+
+// Optional is Value for a field with presence: *p stays nil until the
+// flag is set.
+func Optional[T any](p **T, typ string, parse func(string) (T, error)) pflag.Value {
+	return &optional[T]{p: p, typ: typ, parse: parse}
+}
+
+type optional[T any] struct {
+	p     **T
+	typ   string
+	parse func(string) (T, error)
+}
+
+func (v *optional[T]) Set(s string) error {
+	x, err := v.parse(s)
+	if err != nil {
+		return err
+	}
+	*v.p = &x
+	return nil
+}
+
+func (v *optional[T]) String() string {
+	if *v.p == nil {
+		return ""
+	}
+	return fmt.Sprint(**v.p)
+}
+
+func (v *optional[T]) Type() string {
+	return v.typ
+}
+
+// Duration returns the flag value setting *p, a google.protobuf.Duration
+// field, to its argument, a Go duration such as "1m30s".
+func Duration(p **duration.Duration) pflag.Value {
+	return durationValue{p}
+}
+
+type durationValue struct {
+	p **duration.Duration
+}
+
+func (v durationValue) Set(s string) error {
+	d, err := scalarparse.Duration(s)
+	if err != nil {
+		return err
+	}
+	*v.p = d
+	return nil
+}
+
+func (v durationValue) String() string {
+	if *v.p == nil {
+		return ""
+	}
+	d, err := ptypes.Duration(*v.p)
+	if err != nil {
+		return (*v.p).String()
+	}
+	return d.String()
+}
+
+func (v durationValue) Type() string {
+	return "duration"
+}
@@ -0,0 +1,90 @@
+// Package fuzzpb is the runtime support for the fuzz targets generated by
+// protoc-gen-go-fuzz. JSONRoundTrip checks the invariants of the JSON
+// encoding of a message on the inputs of the fuzzer, and AddFiles seeds
+// its corpus with the example payloads of protoc-gen-go-examples.
+package fuzzpb
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+)
+
+// JSONRoundTrip checks that, if data is the JSON of a message of the type
+// newMsg returns, the message encodes to JSON that decodes again, to a
+// message with the same JSON and binary encodings. Inputs that do not
+// decode are skipped, as they are the decoder's to reject.
+func JSONRoundTrip(t testing.TB, data []byte, newMsg func() proto.Message) {
+	t.Helper()
+	m := newMsg()
+	if err := jsonpb.Unmarshal(bytes.NewReader(data), m); err != nil {
+		t.Skip()
+	}
+	var marshaler jsonpb.Marshaler
+	first, err := marshaler.MarshalToString(m)
+	if err != nil {
+		t.Fatalf("marshal %s: %v", data, err)
+	}
+	again := newMsg()
+	if err := jsonpb.UnmarshalString(first, again); err != nil {
+		t.Fatalf("unmarshal %s, marshaled from %s: %v", first, data, err)
+	}
+	second, err := marshaler.MarshalToString(again)
+	if err != nil {
+		t.Fatalf("marshal %s: %v", first, err)
+	}
+	if first != second {
+		t.Fatalf("JSON of %s changed on a round trip:\n%s\n%s", data, first, second)
+	}
+
+	wire, err := marshal(m)
+	if err != nil {
+		t.Fatalf("binary marshal %s: %v", data, err)
+	}
+	wireAgain, err := marshal(again)
+	if err != nil {
+		t.Fatalf("binary marshal %s: %v", first, err)
+	}
+	if !bytes.Equal(wire, wireAgain) {
+		t.Fatalf("binary encoding of %s changed on a JSON round trip:\n%x\n%x", data, wire, wireAgain)
+	}
+	decoded := newMsg()
+	if err := proto.Unmarshal(wire, decoded); err != nil {
+		t.Fatalf("binary unmarshal %x, marshaled from %s: %v", wire, data, err)
+	}
+	if third, err := marshaler.MarshalToString(decoded); err != nil || third != first {
+		t.Fatalf("JSON of %s changed on a binary round trip:\n%s\n%s (%v)", data, first, third, err)
+	}
+}
+
+// marshal returns the deterministic binary encoding of m.
+func marshal(m proto.Message) ([]byte, error) {
+	var buf proto.Buffer
+	buf.SetDeterministic(true)
+	if err := buf.Marshal(m); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// AddFiles adds the contents of the files of paths, relative to the
+// directory of the package under test, to the seed corpus of f. Missing
+// files are left out, so that targets can name the examples of plugins
+// that may not run.
+func AddFiles(f *testing.F, paths ...string) {
+	f.Helper()
+	for _, p := range paths {
+		b, err := ioutil.ReadFile(p)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(b)
+	}
+}
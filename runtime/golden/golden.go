@@ -0,0 +1,195 @@
+// Package golden is the runtime support for the golden-file helpers
+// generated by protoc-gen-go-golden. Golden files hold the canonical JSON
+// of a message (RFC 8785, indented), and are compared in that form, so that
+// neither the order of their members nor their white space makes a
+// difference.
+//
+// Test binaries get a -golden.update flag, which rewrites the golden files
+// with the messages under test instead of comparing them. An -update flag
+// the test binary declares itself does the same.
+package golden
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+
+	"github.com/f4tq/protoc-go-plugins/runtime/jcs"
+)
+
+// update is set by -golden.update. The flag is not named -update, which
+// test binaries often declare: the flags of this package are declared
+// first, and a second -update would panic.
+var update bool
+
+func init() {
+	// Binaries other than tests importing a generated package keep their
+	// flags to themselves.
+	if testing.Testing() {
+		flag.BoolVar(&update, "golden.update", false, "rewrite the golden files with the messages under test")
+	}
+}
+
+// Update reports whether the test runs with -golden.update, or with an
+// -update flag of its own.
+func Update() bool {
+	if update {
+		return true
+	}
+	if f := flag.Lookup("update"); f != nil {
+		if g, ok := f.Value.(flag.Getter); ok {
+			b, _ := g.Get().(bool)
+			return b
+		}
+	}
+	return false
+}
+
+// Assert checks that the canonical JSON of m is that of the golden file at
+// path, relative to the directory of the package under test. With
+// -golden.update, it writes the JSON of m to the file instead, creating its
+// directory.
+func Assert(t testing.TB, m proto.Message, path string) {
+	t.Helper()
+	got, err := Marshal(m)
+	if err != nil {
+		t.Fatalf("golden %s: %v", path, err)
+	}
+	if Update() {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("golden %s: %v", path, err)
+		}
+		if err := ioutil.WriteFile(path, got, 0644); err != nil {
+			t.Fatalf("golden %s: %v", path, err)
+		}
+		return
+	}
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		t.Fatalf("golden %s does not exist: run the test with -golden.update to create it", path)
+	}
+	if err != nil {
+		t.Fatalf("golden %s: %v", path, err)
+	}
+	want, err := canonical(b)
+	if err != nil {
+		t.Fatalf("golden %s: %v", path, err)
+	}
+	if !bytes.Equal(want, got) {
+		t.Errorf("message differs from golden %s (-want +got), run the test with -golden.update to accept it:\n%s", path, Diff(want, got))
+	}
+}
+
+// Marshal returns the content of the golden file of m: its canonical JSON,
+// indented by two spaces, and a final newline.
+func Marshal(m proto.Message) ([]byte, error) {
+	b, err := jcs.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	return indent(b)
+}
+
+// canonical returns the JSON document b as Marshal formats it.
+func canonical(b []byte) ([]byte, error) {
+	c, err := jcs.Canonicalize(b)
+	if err != nil {
+		return nil, err
+	}
+	return indent(c)
+}
+
+func indent(b []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, b, "", "  "); err != nil {
+		return nil, err
+	}
+	buf.WriteByte('\n')
+	return buf.Bytes(), nil
+}
+
+// context is the number of unchanged lines Diff shows around changes.
+const context = 3
+
+// Diff returns the lines of want and got, prefixed with "-" if only want
+// has them, "+" if only got does, and " " if both do. Unchanged lines
+// further than a few from a change are elided.
+func Diff(want, got []byte) string {
+	a := strings.SplitAfter(string(want), "\n")
+	b := strings.SplitAfter(string(got), "\n")
+	// lcs[i][j] is the length of the longest common subsequence of a[i:]
+	// and b[j:].
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			switch {
+			case a[i] == b[j]:
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			case lcs[i+1][j] >= lcs[i][j+1]:
+				lcs[i][j] = lcs[i+1][j]
+			default:
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+	type line struct {
+		op   byte
+		text string
+	}
+	var lines []line
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			lines = append(lines, line{' ', a[i]})
+			i, j = i+1, j+1
+		case j == len(b) || i < len(a) && lcs[i+1][j] >= lcs[i][j+1]:
+			lines = append(lines, line{'-', a[i]})
+			i++
+		default:
+			lines = append(lines, line{'+', b[j]})
+			j++
+		}
+	}
+	// near[k] reports whether lines[k] is within context of a change.
+	near := make([]bool, len(lines))
+	for k, l := range lines {
+		if l.op == ' ' {
+			continue
+		}
+		for n := k - context; n <= k+context; n++ {
+			if n >= 0 && n < len(lines) {
+				near[n] = true
+			}
+		}
+	}
+	var buf strings.Builder
+	elided := false
+	for k, l := range lines {
+		if l.text == "" {
+			continue
+		}
+		if !near[k] {
+			if !elided {
+				buf.WriteString("...\n")
+				elided = true
+			}
+			continue
+		}
+		elided = false
+		fmt.Fprintf(&buf, "%c %s", l.op, strings.TrimSuffix(l.text, "\n"))
+		buf.WriteByte('\n')
+	}
+	return buf.String()
+}
@@ -0,0 +1,46 @@
+package golden
+
+import (
+	"flag"
+	"path/filepath"
+	"testing"
+
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// The test binary declares an -update flag of its own, as tests of golden
+// files often do: the package must not declare it again.
+var updateFlag = flag.Bool("update", false, "rewrite the golden files")
+
+func TestUpdate(t *testing.T) {
+	tests := []struct {
+		name string
+		flag string
+		want bool
+	}{
+		{"no flag", "", false},
+		{"golden.update", "golden.update", true},
+		{"update of the test", "update", true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if test.flag != "" {
+				if err := flag.Set(test.flag, "true"); err != nil {
+					t.Fatal(err)
+				}
+				defer flag.Set(test.flag, "false")
+			}
+			if got := Update(); got != test.want {
+				t.Errorf("Update() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestAssertUpdates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "testdata", "name.json")
+	*updateFlag = true
+	Assert(t, wrapperspb.String("docs/readme"), path)
+	*updateFlag = false
+	Assert(t, wrapperspb.String("docs/readme"), path)
+}
@@ -0,0 +1,198 @@
+// Package gqlpb is the runtime support for code generated by
+// protoc-gen-go-graphql. It implements the gqlgen custom scalars that
+// protobuf types without a GraphQL equivalent are mapped to. 64-bit
+// integers are encoded as strings, as in the protobuf JSON mapping.
+package gqlpb
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/duration"
+	"github.com/golang/protobuf/ptypes/timestamp"
+	"google.golang.org/genproto/protobuf/field_mask"
+)
+
+func writeString(s string) graphql.Marshaler {
+	return graphql.WriterFunc(func(w io.Writer) {
+		io.WriteString(w, strconv.Quote(s))
+	})
+}
+
+func writeRaw(s string) graphql.Marshaler {
+	return graphql.WriterFunc(func(w io.Writer) {
+		io.WriteString(w, s)
+	})
+}
+
+// number returns v, a string or JSON number, as a decimal string.
+func number(v interface{}) (string, error) {
+	switch v := v.(type) {
+	case string:
+		return v, nil
+	case json.Number:
+		return v.String(), nil
+	case int:
+		return strconv.Itoa(v), nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	}
+	return "", fmt.Errorf("%T is not a number", v)
+}
+
+// MarshalInt64 encodes an int64 as a string.
+func MarshalInt64(v int64) graphql.Marshaler {
+	return writeString(strconv.FormatInt(v, 10))
+}
+
+// UnmarshalInt64 decodes an int64 from a string or number.
+func UnmarshalInt64(v interface{}) (int64, error) {
+	s, err := number(v)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(s, 10, 64)
+}
+
+// MarshalUint64 encodes a uint64 as a string.
+func MarshalUint64(v uint64) graphql.Marshaler {
+	return writeString(strconv.FormatUint(v, 10))
+}
+
+// UnmarshalUint64 decodes a uint64 from a string or number.
+func UnmarshalUint64(v interface{}) (uint64, error) {
+	s, err := number(v)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(s, 10, 64)
+}
+
+// MarshalUint32 encodes a uint32, which may exceed GraphQL's Int, as a
+// number.
+func MarshalUint32(v uint32) graphql.Marshaler {
+	return writeRaw(strconv.FormatUint(uint64(v), 10))
+}
+
+// UnmarshalUint32 decodes a uint32 from a number or string.
+func UnmarshalUint32(v interface{}) (uint32, error) {
+	s, err := number(v)
+	if err != nil {
+		return 0, err
+	}
+	u, err := strconv.ParseUint(s, 10, 32)
+	return uint32(u), err
+}
+
+// MarshalFloat32 encodes a float32 as a number.
+func MarshalFloat32(v float32) graphql.Marshaler {
+	f := float64(v)
+	if math.IsInf(f, 0) || math.IsNaN(f) {
+		return graphql.Null
+	}
+	return writeRaw(strconv.FormatFloat(f, 'g', -1, 32))
+}
+
+// UnmarshalFloat32 decodes a float32 from a number or string.
+func UnmarshalFloat32(v interface{}) (float32, error) {
+	s, err := number(v)
+	if err != nil {
+		return 0, err
+	}
+	f, err := strconv.ParseFloat(s, 32)
+	return float32(f), err
+}
+
+// MarshalBytes encodes bytes as a base64 string.
+func MarshalBytes(v []byte) graphql.Marshaler {
+	return writeString(base64.StdEncoding.EncodeToString(v))
+}
+
+// UnmarshalBytes decodes bytes from a standard or URL-safe base64 string.
+func UnmarshalBytes(v interface{}) ([]byte, error) {
+	s, ok := v.(string)
+	if !ok {
+		return nil, fmt.Errorf("%T is not a string", v)
+	}
+	s = strings.TrimRight(s, "=")
+	if strings.ContainsAny(s, "-_") {
+		return base64.RawURLEncoding.DecodeString(s)
+	}
+	return base64.RawStdEncoding.DecodeString(s)
+}
+
+// MarshalTimestamp encodes a Timestamp as an RFC 3339 string.
+func MarshalTimestamp(v *timestamp.Timestamp) graphql.Marshaler {
+	t, err := ptypes.Timestamp(v)
+	if err != nil {
+		return graphql.Null
+	}
+	return writeString(t.UTC().Format(time.RFC3339Nano))
+}
+
+// UnmarshalTimestamp decodes a Timestamp from an RFC 3339 string.
+func UnmarshalTimestamp(v interface{}) (*timestamp.Timestamp, error) {
+	s, ok := v.(string)
+	if !ok {
+		return nil, fmt.Errorf("%T is not a string", v)
+	}
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return nil, err
+	}
+	return ptypes.TimestampProto(t)
+}
+
+// MarshalDuration encodes a Duration as seconds with an "s" suffix, e.g.
+// "1.5s".
+func MarshalDuration(v *duration.Duration) graphql.Marshaler {
+	d, err := ptypes.Duration(v)
+	if err != nil {
+		return graphql.Null
+	}
+	return writeString(strconv.FormatFloat(d.Seconds(), 'f', -1, 64) + "s")
+}
+
+// UnmarshalDuration decodes a Duration from a Go duration string such as
+// "1.5s" or "1m30s".
+func UnmarshalDuration(v interface{}) (*duration.Duration, error) {
+	s, ok := v.(string)
+	if !ok {
+		return nil, fmt.Errorf("%T is not a string", v)
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return nil, err
+	}
+	return ptypes.DurationProto(d), nil
+}
+
+// MarshalFieldMask encodes a FieldMask as its comma separated paths.
+func MarshalFieldMask(v *field_mask.FieldMask) graphql.Marshaler {
+	return writeString(strings.Join(v.GetPaths(), ","))
+}
+
+// UnmarshalFieldMask decodes a FieldMask from comma separated paths.
+func UnmarshalFieldMask(v interface{}) (*field_mask.FieldMask, error) {
+	s, ok := v.(string)
+	if !ok {
+		return nil, fmt.Errorf("%T is not a string", v)
+	}
+	m := new(field_mask.FieldMask)
+	for _, p := range strings.Split(s, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			m.Paths = append(m.Paths, p)
+		}
+	}
+	return m, nil
+}
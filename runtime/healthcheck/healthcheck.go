@@ -0,0 +1,246 @@
+// Package healthcheck is the runtime support for code generated by
+// protoc-gen-go-health. A Health runs the dependency checks of each
+// service and reports the results both through the standard gRPC health
+// service and as an HTTP /healthz handler.
+package healthcheck
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+const (
+	// DefaultInterval is the time between rounds of checks in Run.
+	DefaultInterval = 10 * time.Second
+	// DefaultTimeout bounds one round of checks.
+	DefaultTimeout = 5 * time.Second
+)
+
+// Checker checks a dependency. A nil error means the dependency is ready.
+type Checker interface {
+	Check(ctx context.Context) error
+}
+
+// CheckFunc is a Checker calling the function.
+type CheckFunc func(ctx context.Context) error
+
+// Check calls f.
+func (f CheckFunc) Check(ctx context.Context) error {
+	return f(ctx)
+}
+
+// Health tracks the serving status of services. A service is SERVING while
+// all of its checks pass; the server as a whole, the "" service, is SERVING
+// while every service is.
+type Health struct {
+	// Interval is the time between rounds of checks in Run;
+	// DefaultInterval if zero.
+	Interval time.Duration
+	// Timeout bounds one round of checks; DefaultTimeout if zero.
+	Timeout time.Duration
+
+	server *health.Server
+
+	mu       sync.RWMutex
+	services map[string]map[string]Checker
+	failures map[string]map[string]string
+	shutdown bool
+}
+
+// New returns a Health with no services. The server is reported SERVING
+// until services are added.
+func New() *Health {
+	return &Health{
+		server:   health.NewServer(),
+		services: make(map[string]map[string]Checker),
+		failures: make(map[string]map[string]string),
+	}
+}
+
+// AddService adds the service name with its checks keyed by dependency
+// name; nil checks are ignored. The service is NOT_SERVING until its checks
+// first pass, see CheckNow and Run.
+func (h *Health) AddService(name string, checks map[string]Checker) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	deps := make(map[string]Checker)
+	for dep, c := range checks {
+		if c != nil {
+			deps[dep] = c
+		}
+	}
+	h.services[name] = deps
+	h.failures[name] = map[string]string{"": "not checked yet"}
+	h.publish()
+}
+
+// Register registers the gRPC health service on s.
+func (h *Health) Register(s *grpc.Server) {
+	healthpb.RegisterHealthServer(s, h.server)
+}
+
+// CheckNow runs the checks of every service concurrently and updates their
+// status.
+func (h *Health) CheckNow(ctx context.Context) {
+	timeout := h.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	h.mu.RLock()
+	type result struct {
+		service, dep string
+		err          error
+	}
+	var wg sync.WaitGroup
+	results := make(chan result)
+	failures := make(map[string]map[string]string, len(h.services))
+	for name, deps := range h.services {
+		failures[name] = make(map[string]string)
+		for dep, c := range deps {
+			wg.Add(1)
+			go func(name, dep string, c Checker) {
+				defer wg.Done()
+				results <- result{name, dep, c.Check(ctx)}
+			}(name, dep, c)
+		}
+	}
+	h.mu.RUnlock()
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+	for r := range results {
+		if r.err != nil {
+			failures[r.service][r.dep] = r.err.Error()
+		}
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for name, f := range failures {
+		if _, ok := h.services[name]; ok {
+			h.failures[name] = f
+		}
+	}
+	h.publish()
+}
+
+// Run calls CheckNow every Interval until ctx is done, then calls
+// Shutdown.
+func (h *Health) Run(ctx context.Context) {
+	interval := h.Interval
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		h.CheckNow(ctx)
+		select {
+		case <-ctx.Done():
+			h.Shutdown()
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// Shutdown reports every service NOT_SERVING regardless of its checks, so
+// that load balancers drain the server before it stops.
+func (h *Health) Shutdown() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.shutdown = true
+	h.publish()
+}
+
+// Resume undoes Shutdown.
+func (h *Health) Resume() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.shutdown = false
+	h.publish()
+}
+
+// Status reports whether service is serving, with the failed checks keyed
+// by dependency name. ok is false if service was not added. The status of
+// "", the whole server, lists failures as "service/dependency".
+func (h *Health) Status(service string) (serving bool, failures map[string]string, ok bool) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	if service == "" {
+		failures = make(map[string]string)
+		for name, f := range h.failures {
+			for dep, msg := range f {
+				key := name
+				if dep != "" {
+					key += "/" + dep
+				}
+				failures[key] = msg
+			}
+		}
+		return !h.shutdown && len(failures) == 0, failures, true
+	}
+	f, ok := h.failures[service]
+	if !ok {
+		return false, nil, false
+	}
+	return !h.shutdown && len(f) == 0, f, true
+}
+
+// publish pushes the current status to the gRPC health server. h.mu must
+// be held.
+func (h *Health) publish() {
+	all := !h.shutdown
+	names := make([]string, 0, len(h.failures))
+	for name := range h.failures {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		serving := !h.shutdown && len(h.failures[name]) == 0
+		all = all && serving
+		h.server.SetServingStatus(name, servingStatus(serving))
+	}
+	h.server.SetServingStatus("", servingStatus(all))
+}
+
+func servingStatus(serving bool) healthpb.HealthCheckResponse_ServingStatus {
+	if serving {
+		return healthpb.HealthCheckResponse_SERVING
+	}
+	return healthpb.HealthCheckResponse_NOT_SERVING
+}
+
+// ServeHTTP reports the status of the service named by the "service" query
+// parameter, or of the whole server, as JSON. It responds 200 when serving,
+// 503 when not and 404 for an unknown service.
+func (h *Health) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	serving, failures, ok := h.Status(r.URL.Query().Get("service"))
+	code := http.StatusOK
+	body := struct {
+		Status   string            `json:"status"`
+		Failures map[string]string `json:"failures,omitempty"`
+	}{Status: "SERVING", Failures: failures}
+	switch {
+	case !ok:
+		code, body.Status = http.StatusNotFound, "SERVICE_UNKNOWN"
+	case !serving:
+		code, body.Status = http.StatusServiceUnavailable, "NOT_SERVING"
+	}
+	b, _ := json.Marshal(body)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	w.Write(b)
+}
@@ -0,0 +1,107 @@
+// Package httpbind is the runtime support for code generated by
+// protoc-gen-go-httpbind: it matches requests against the google.api.http
+// rules of their methods and collects the errors binding their path
+// variables, query parameters, headers and body to request messages.
+package httpbind
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+
+	"github.com/f4tq/protoc-go-plugins/runtime/httpgw"
+)
+
+// FieldError is the error binding one field of a request message.
+type FieldError struct {
+	// Field is the proto path of the field, or "" for the whole body.
+	Field string
+	// Source is where the value comes from: "path", "query", "header" or
+	// "body".
+	Source string
+	Err    error
+}
+
+func (e *FieldError) Error() string {
+	if e.Field == "" {
+		return fmt.Sprintf("%s: %v", e.Source, e.Err)
+	}
+	return fmt.Sprintf("%s %s: %v", e.Source, e.Field, e.Err)
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// Error lists the fields of a request that do not bind. It reports HTTP
+// status 400 to httpgw.WriteError.
+type Error struct {
+	Errors []*FieldError
+}
+
+func (e *Error) Error() string {
+	msgs := make([]string, len(e.Errors))
+	for i, fe := range e.Errors {
+		msgs[i] = fe.Error()
+	}
+	return "httpbind: " + strings.Join(msgs, "; ")
+}
+
+// HTTPStatus returns 400.
+func (e *Error) HTTPStatus() int {
+	return http.StatusBadRequest
+}
+
+// Binder collects the errors binding a request.
+type Binder struct {
+	errs []*FieldError
+}
+
+// Fail records the error binding field from source.
+func (b *Binder) Fail(field, source string, err error) {
+	b.errs = append(b.errs, &FieldError{Field: field, Source: source, Err: err})
+}
+
+// Err returns an *Error listing the errors recorded, or nil.
+func (b *Binder) Err() error {
+	if len(b.errs) == 0 {
+		return nil
+	}
+	return &Error{Errors: b.errs}
+}
+
+// Match matches r against the method and path template p, returning the
+// values of the path variables.
+func Match(r *http.Request, method string, p *httpgw.Pattern) (map[string]string, bool) {
+	if r.Method != method {
+		return nil, false
+	}
+	return p.Match(r.URL.EscapedPath())
+}
+
+// NoMatch returns the error for a request matching no rule: an
+// *httpgw.Error with status 404.
+func NoMatch(r *http.Request) error {
+	return &httpgw.Error{Status: http.StatusNotFound, Message: fmt.Sprintf("httpbind: no binding for %s %s", r.Method, r.URL.Path)}
+}
+
+// DecodeBody unmarshals the JSON body of r into m. An empty or nil body
+// leaves m unchanged.
+func DecodeBody(r *http.Request, m proto.Message) error {
+	if r.Body == nil {
+		return nil
+	}
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return err
+	}
+	if len(bytes.TrimSpace(body)) == 0 {
+		return nil
+	}
+	return jsonpb.Unmarshal(bytes.NewReader(body), m)
+}
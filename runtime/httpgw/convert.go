@@ -0,0 +1,89 @@
+package httpgw
+
+import (
+	"encoding/base64"
+	"strconv"
+)
+
+// The conversions below parse path and query parameters, returning a 400
+// Error on malformed input.
+
+func Bool(s string) (bool, error) {
+	v, err := strconv.ParseBool(s)
+	if err != nil {
+		return false, BadRequest("invalid bool %q", s)
+	}
+	return v, nil
+}
+
+func Int32(s string) (int32, error) {
+	v, err := strconv.ParseInt(s, 10, 32)
+	if err != nil {
+		return 0, BadRequest("invalid int32 %q", s)
+	}
+	return int32(v), nil
+}
+
+func Int64(s string) (int64, error) {
+	v, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, BadRequest("invalid int64 %q", s)
+	}
+	return v, nil
+}
+
+func Uint32(s string) (uint32, error) {
+	v, err := strconv.ParseUint(s, 10, 32)
+	if err != nil {
+		return 0, BadRequest("invalid uint32 %q", s)
+	}
+	return uint32(v), nil
+}
+
+func Uint64(s string) (uint64, error) {
+	v, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return 0, BadRequest("invalid uint64 %q", s)
+	}
+	return v, nil
+}
+
+func Float32(s string) (float32, error) {
+	v, err := strconv.ParseFloat(s, 32)
+	if err != nil {
+		return 0, BadRequest("invalid float %q", s)
+	}
+	return float32(v), nil
+}
+
+func Float64(s string) (float64, error) {
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, BadRequest("invalid double %q", s)
+	}
+	return v, nil
+}
+
+// Bytes decodes standard or URL-safe base64.
+func Bytes(s string) ([]byte, error) {
+	if v, err := base64.StdEncoding.DecodeString(s); err == nil {
+		return v, nil
+	}
+	v, err := base64.URLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, BadRequest("invalid base64 %q", s)
+	}
+	return v, nil
+}
+
+// Enum parses an enum value given by name or number.
+func Enum(s string, values map[string]int32) (int32, error) {
+	if v, ok := values[s]; ok {
+		return v, nil
+	}
+	v, err := strconv.ParseInt(s, 10, 32)
+	if err != nil {
+		return 0, BadRequest("invalid enum value %q", s)
+	}
+	return int32(v), nil
+}
@@ -0,0 +1,180 @@
+// Package httpgw is the runtime support for code generated by
+// protoc-gen-go-httpgateway, and for the path binders of
+// protoc-gen-go-resourcename. It routes requests by google.api.http path
+// templates and encodes messages with the jsonpb marshalers, without
+// depending on gRPC.
+package httpgw
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+)
+
+// HandlerFunc handles a request matched by a path template. params holds
+// the values of the template's variables keyed by field path.
+type HandlerFunc func(w http.ResponseWriter, r *http.Request, params map[string]string)
+
+type route struct {
+	method  string
+	pattern *Pattern
+	h       HandlerFunc
+}
+
+// ServeMux dispatches requests to the first registered route whose method
+// and path template match.
+type ServeMux struct {
+	routes []route
+
+	// Marshaler encodes response messages.
+	Marshaler *jsonpb.Marshaler
+	// Unmarshaler decodes request bodies.
+	Unmarshaler *jsonpb.Unmarshaler
+	// ErrorHandler writes errors returned by bindings and services;
+	// WriteError if nil.
+	ErrorHandler func(w http.ResponseWriter, r *http.Request, err error)
+}
+
+// NewServeMux returns a ServeMux using the default jsonpb marshalers.
+func NewServeMux() *ServeMux {
+	return &ServeMux{
+		Marshaler:   new(jsonpb.Marshaler),
+		Unmarshaler: new(jsonpb.Unmarshaler),
+	}
+}
+
+// Handle registers h for method and path template. It panics if template is
+// invalid, as the templates come from generated code.
+func (m *ServeMux) Handle(method, template string, h HandlerFunc) {
+	m.routes = append(m.routes, route{method: method, pattern: MustParsePattern(template), h: h})
+}
+
+func (m *ServeMux) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := r.URL.EscapedPath()
+	allowed := false
+	for _, rt := range m.routes {
+		params, ok := rt.pattern.Match(path)
+		if !ok {
+			continue
+		}
+		if rt.method != r.Method {
+			allowed = true
+			continue
+		}
+		rt.h(w, r, params)
+		return
+	}
+	if allowed {
+		m.Error(w, r, &Error{Status: http.StatusMethodNotAllowed, Message: http.StatusText(http.StatusMethodNotAllowed)})
+		return
+	}
+	m.Error(w, r, &Error{Status: http.StatusNotFound, Message: http.StatusText(http.StatusNotFound)})
+}
+
+// DecodeBody unmarshals the JSON request body into msg.
+func (m *ServeMux) DecodeBody(r *http.Request, msg proto.Message) error {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return BadRequest("reading body: %v", err)
+	}
+	if len(bytes.TrimSpace(body)) == 0 {
+		return nil
+	}
+	u := m.Unmarshaler
+	if u == nil {
+		u = new(jsonpb.Unmarshaler)
+	}
+	if err := u.Unmarshal(bytes.NewReader(body), msg); err != nil {
+		return BadRequest("decoding body: %v", err)
+	}
+	return nil
+}
+
+// Respond writes msg as the JSON response.
+func (m *ServeMux) Respond(w http.ResponseWriter, r *http.Request, msg proto.Message) {
+	mr := m.Marshaler
+	if mr == nil {
+		mr = new(jsonpb.Marshaler)
+	}
+	var buf bytes.Buffer
+	if err := mr.Marshal(&buf, msg); err != nil {
+		m.Error(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(buf.Bytes())
+}
+
+// Error writes err using the ErrorHandler.
+func (m *ServeMux) Error(w http.ResponseWriter, r *http.Request, err error) {
+	if m.ErrorHandler != nil {
+		m.ErrorHandler(w, r, err)
+		return
+	}
+	WriteError(w, r, err)
+}
+
+// Error is an error with an HTTP status.
+type Error struct {
+	Status  int
+	Message string
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// HTTPStatus returns the HTTP status of e.
+func (e *Error) HTTPStatus() int {
+	return e.Status
+}
+
+// BadRequest returns an Error with status 400.
+func BadRequest(format string, args ...interface{}) error {
+	return &Error{Status: http.StatusBadRequest, Message: fmt.Sprintf(format, args...)}
+}
+
+// WriteError writes err as a JSON object with "code" and "message". The
+// status is taken from an HTTPStatus() int method of err, and is 500 for
+// other errors.
+func WriteError(w http.ResponseWriter, r *http.Request, err error) {
+	status := http.StatusInternalServerError
+	if s, ok := err.(interface{ HTTPStatus() int }); ok {
+		status = s.HTTPStatus()
+	}
+	body, _ := json.Marshal(struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	}{status, err.Error()})
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(body)
+}
+
+// RespondList writes msgs as a JSON array, for response_body bindings
+// naming a repeated field.
+func (m *ServeMux) RespondList(w http.ResponseWriter, r *http.Request, msgs []proto.Message) {
+	mr := m.Marshaler
+	if mr == nil {
+		mr = new(jsonpb.Marshaler)
+	}
+	var buf bytes.Buffer
+	buf.WriteByte('[')
+	for i, msg := range msgs {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		if err := mr.Marshal(&buf, msg); err != nil {
+			m.Error(w, r, err)
+			return
+		}
+	}
+	buf.WriteByte(']')
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(buf.Bytes())
+}
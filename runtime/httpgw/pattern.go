@@ -0,0 +1,212 @@
+package httpgw
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+type tokenKind int
+
+const (
+	literal  tokenKind = iota
+	wildcard           // "*", exactly one segment
+	deep               // "**", zero or more segments
+)
+
+type token struct {
+	kind  tokenKind
+	value string
+	// v is the index of the variable capturing the segment, or -1.
+	v int
+}
+
+// Pattern is a compiled google.api.http path template such as
+// "/v1/{name=shelves/*/books/*}:publish".
+type Pattern struct {
+	template string
+	tokens   []token
+	verb     string
+	vars     []string
+}
+
+// ParsePattern compiles a path template.
+func ParsePattern(template string) (*Pattern, error) {
+	if !strings.HasPrefix(template, "/") {
+		return nil, fmt.Errorf("httpgw: template %q must start with /", template)
+	}
+	p := &Pattern{template: template}
+	s := template[1:]
+	if i := strings.LastIndexByte(s, ':'); i >= 0 && i > strings.LastIndexByte(s, '}') && i > strings.LastIndexByte(s, '/') {
+		p.verb = s[i+1:]
+		s = s[:i]
+	}
+	for len(s) > 0 {
+		if s[0] == '{' {
+			end := strings.IndexByte(s, '}')
+			if end < 0 {
+				return nil, fmt.Errorf("httpgw: unterminated variable in %q", template)
+			}
+			name, sub := s[1:end], "*"
+			if i := strings.IndexByte(name, '='); i >= 0 {
+				name, sub = name[:i], name[i+1:]
+			}
+			if name == "" {
+				return nil, fmt.Errorf("httpgw: empty variable name in %q", template)
+			}
+			v := len(p.vars)
+			p.vars = append(p.vars, name)
+			for _, seg := range strings.Split(sub, "/") {
+				t, err := parseSegment(template, seg)
+				if err != nil {
+					return nil, err
+				}
+				t.v = v
+				p.tokens = append(p.tokens, t)
+			}
+			s = s[end+1:]
+		} else {
+			seg := s
+			if i := strings.IndexByte(s, '/'); i >= 0 {
+				seg = s[:i]
+			}
+			t, err := parseSegment(template, seg)
+			if err != nil {
+				return nil, err
+			}
+			t.v = -1
+			p.tokens = append(p.tokens, t)
+			s = s[len(seg):]
+		}
+		if len(s) > 0 {
+			if s[0] != '/' || len(s) == 1 {
+				return nil, fmt.Errorf("httpgw: malformed template %q", template)
+			}
+			s = s[1:]
+		}
+	}
+	return p, nil
+}
+
+// MustParsePattern is ParsePattern for the templates of generated code. It
+// panics if template is invalid.
+func MustParsePattern(template string) *Pattern {
+	p, err := ParsePattern(template)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+func parseSegment(template, seg string) (token, error) {
+	switch {
+	case seg == "*":
+		return token{kind: wildcard}, nil
+	case seg == "**":
+		return token{kind: deep}, nil
+	case seg == "" || strings.ContainsAny(seg, "{}="):
+		return token{}, fmt.Errorf("httpgw: invalid segment %q in %q", seg, template)
+	}
+	return token{kind: literal, value: seg}, nil
+}
+
+// String returns the template p was compiled from.
+func (p *Pattern) String() string {
+	return p.template
+}
+
+// Vars returns the field paths of the variables in p, in order.
+func (p *Pattern) Vars() []string {
+	return p.vars
+}
+
+// Verb returns the custom verb of p, or "".
+func (p *Pattern) Verb() string {
+	return p.verb
+}
+
+// Segment is one path segment of a Pattern.
+type Segment struct {
+	// Literal is the text of a literal segment, or "" for "*" and "**".
+	Literal string
+	// Deep is true for "**", which matches zero or more segments.
+	Deep bool
+	// Var is the index in Vars of the variable capturing the segment, or
+	// -1.
+	Var int
+}
+
+// Segments returns the path segments of p, in order.
+func (p *Pattern) Segments() []Segment {
+	segs := make([]Segment, len(p.tokens))
+	for i, t := range p.tokens {
+		segs[i] = Segment{Literal: t.value, Deep: t.kind == deep, Var: t.v}
+	}
+	return segs
+}
+
+// Match matches the escaped URL path against p and returns the unescaped
+// values of its variables keyed by field path.
+func (p *Pattern) Match(path string) (map[string]string, bool) {
+	if !strings.HasPrefix(path, "/") {
+		return nil, false
+	}
+	path = path[1:]
+	if p.verb != "" {
+		if !strings.HasSuffix(path, ":"+p.verb) {
+			return nil, false
+		}
+		path = strings.TrimSuffix(path, ":"+p.verb)
+	}
+	var segs []string
+	if path != "" {
+		segs = strings.Split(path, "/")
+	}
+	captured := make([][]string, len(p.vars))
+	if !matchTokens(p.tokens, segs, captured) {
+		return nil, false
+	}
+	params := make(map[string]string, len(p.vars))
+	for i, name := range p.vars {
+		values := make([]string, len(captured[i]))
+		for j, seg := range captured[i] {
+			v, err := url.PathUnescape(seg)
+			if err != nil {
+				return nil, false
+			}
+			values[j] = v
+		}
+		params[name] = strings.Join(values, "/")
+	}
+	return params, true
+}
+
+// matchTokens matches segs against tokens, recording the segments captured
+// by variables only along the successful match.
+func matchTokens(tokens []token, segs []string, captured [][]string) bool {
+	if len(tokens) == 0 {
+		return len(segs) == 0
+	}
+	t := tokens[0]
+	if t.kind == deep {
+		for n := len(segs); n >= 0; n-- {
+			if matchTokens(tokens[1:], segs[n:], captured) {
+				if t.v >= 0 {
+					captured[t.v] = append(append([]string(nil), segs[:n]...), captured[t.v]...)
+				}
+				return true
+			}
+		}
+		return false
+	}
+	if len(segs) == 0 || (t.kind == literal && segs[0] != t.value) {
+		return false
+	}
+	if !matchTokens(tokens[1:], segs[1:], captured) {
+		return false
+	}
+	if t.v >= 0 {
+		captured[t.v] = append([]string{segs[0]}, captured[t.v]...)
+	}
+	return true
+}
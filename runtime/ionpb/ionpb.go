@@ -0,0 +1,57 @@
+// Package ionpb is the runtime support for code generated by
+// protoc-gen-go-ion.
+package ionpb
+
+import (
+	"bytes"
+
+	"github.com/amzn/ion-go/ion"
+)
+
+// Marshaler is implemented by messages with generated Ion encoders.
+type Marshaler interface {
+	MarshalIon(w ion.Writer) error
+}
+
+// Unmarshaler is implemented by messages with generated Ion decoders.
+type Unmarshaler interface {
+	UnmarshalIon(r ion.Reader) error
+}
+
+// Marshal encodes m in the Ion binary format.
+func Marshal(m Marshaler) ([]byte, error) {
+	var buf bytes.Buffer
+	w := ion.NewBinaryWriter(&buf)
+	if err := m.MarshalIon(w); err != nil {
+		return nil, err
+	}
+	if err := w.Finish(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// MarshalText encodes m in the Ion text format.
+func MarshalText(m Marshaler) ([]byte, error) {
+	var buf bytes.Buffer
+	w := ion.NewTextWriter(&buf)
+	if err := m.MarshalIon(w); err != nil {
+		return nil, err
+	}
+	if err := w.Finish(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Unmarshal decodes the first value of the Ion text or binary data b into m.
+func Unmarshal(b []byte, m Unmarshaler) error {
+	r := ion.NewReaderBytes(b)
+	if !r.Next() {
+		if err := r.Err(); err != nil {
+			return err
+		}
+		return nil
+	}
+	return m.UnmarshalIon(r)
+}
@@ -0,0 +1,177 @@
+package ionpb
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/amzn/ion-go/ion"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/duration"
+	"github.com/golang/protobuf/ptypes/timestamp"
+)
+
+// ReadStruct steps into the struct r is positioned on and calls field for
+// each non-null field. A null struct is skipped.
+func ReadStruct(r ion.Reader, field func(name string) error) error {
+	if r.IsNull() {
+		return nil
+	}
+	if r.Type() != ion.StructType {
+		return fmt.Errorf("ionpb: expected struct, got %v", r.Type())
+	}
+	if err := r.StepIn(); err != nil {
+		return err
+	}
+	for r.Next() {
+		if r.IsNull() {
+			continue
+		}
+		tok, err := r.FieldName()
+		if err != nil {
+			return err
+		}
+		if tok == nil || tok.Text == nil {
+			continue
+		}
+		if err := field(*tok.Text); err != nil {
+			return fmt.Errorf("%s: %v", *tok.Text, err)
+		}
+	}
+	if err := r.Err(); err != nil {
+		return err
+	}
+	return r.StepOut()
+}
+
+// ReadList steps into the list r is positioned on and calls elem for each
+// element. A null list is skipped.
+func ReadList(r ion.Reader, elem func() error) error {
+	if r.IsNull() {
+		return nil
+	}
+	if r.Type() != ion.ListType && r.Type() != ion.SexpType {
+		return fmt.Errorf("ionpb: expected list, got %v", r.Type())
+	}
+	if err := r.StepIn(); err != nil {
+		return err
+	}
+	for r.Next() {
+		if err := elem(); err != nil {
+			return err
+		}
+	}
+	if err := r.Err(); err != nil {
+		return err
+	}
+	return r.StepOut()
+}
+
+// String reads a string or symbol value.
+func String(r ion.Reader) (string, error) {
+	if r.Type() == ion.SymbolType {
+		tok, err := r.SymbolValue()
+		if err != nil || tok == nil || tok.Text == nil {
+			return "", err
+		}
+		return *tok.Text, nil
+	}
+	v, err := r.StringValue()
+	if err != nil || v == nil {
+		return "", err
+	}
+	return *v, nil
+}
+
+func Bool(r ion.Reader) (bool, error) {
+	v, err := r.BoolValue()
+	if err != nil || v == nil {
+		return false, err
+	}
+	return *v, nil
+}
+
+func Int64(r ion.Reader) (int64, error) {
+	v, err := r.Int64Value()
+	if err != nil || v == nil {
+		return 0, err
+	}
+	return *v, nil
+}
+
+func Uint64(r ion.Reader) (uint64, error) {
+	v, err := r.BigIntValue()
+	if err != nil || v == nil {
+		return 0, err
+	}
+	if v.Sign() < 0 || !v.IsUint64() {
+		return 0, fmt.Errorf("ionpb: %v overflows uint64", v)
+	}
+	return v.Uint64(), nil
+}
+
+func Float64(r ion.Reader) (float64, error) {
+	v, err := r.FloatValue()
+	if err != nil || v == nil {
+		return 0, err
+	}
+	return *v, nil
+}
+
+func Bytes(r ion.Reader) ([]byte, error) {
+	return r.ByteValue()
+}
+
+// Decimal reads an Ion decimal as its text representation.
+func Decimal(r ion.Reader) (string, error) {
+	v, err := r.DecimalValue()
+	if err != nil || v == nil {
+		return "", err
+	}
+	return v.String(), nil
+}
+
+// Enum reads an enum value written as a symbol, string or number.
+func Enum(r ion.Reader, values map[string]int32) (int32, error) {
+	if r.Type() == ion.IntType {
+		v, err := Int64(r)
+		return int32(v), err
+	}
+	name, err := String(r)
+	if err != nil {
+		return 0, err
+	}
+	v, ok := values[name]
+	if !ok {
+		return 0, fmt.Errorf("ionpb: unknown enum value %q", name)
+	}
+	return v, nil
+}
+
+// Timestamp reads an Ion timestamp.
+func Timestamp(r ion.Reader) (*timestamp.Timestamp, error) {
+	v, err := r.TimestampValue()
+	if err != nil || v == nil {
+		return nil, err
+	}
+	return ptypes.TimestampProto(v.GetDateTime())
+}
+
+// Duration reads a decimal number of seconds.
+func Duration(r ion.Reader) (*duration.Duration, error) {
+	v, err := r.DecimalValue()
+	if err != nil || v == nil {
+		return nil, err
+	}
+	coef, exp := v.CoEx()
+	nanos := new(big.Int).Set(coef)
+	if e := int64(exp) + 9; e >= 0 {
+		nanos.Mul(nanos, new(big.Int).Exp(big.NewInt(10), big.NewInt(e), nil))
+	} else {
+		nanos.Quo(nanos, new(big.Int).Exp(big.NewInt(10), big.NewInt(-e), nil))
+	}
+	sec, rem := new(big.Int).QuoRem(nanos, big.NewInt(1e9), new(big.Int))
+	if !sec.IsInt64() {
+		return nil, fmt.Errorf("ionpb: duration %v out of range", v)
+	}
+	return &duration.Duration{Seconds: sec.Int64(), Nanos: int32(rem.Int64())}, nil
+}
@@ -0,0 +1,115 @@
+package ionpb
+
+import (
+	"fmt"
+
+	"github.com/amzn/ion-go/ion"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/duration"
+	"github.com/golang/protobuf/ptypes/timestamp"
+)
+
+// Writer wraps an ion.Writer and latches the first error, so generated
+// encoders can write a whole message before checking Err.
+type Writer struct {
+	w   ion.Writer
+	err error
+}
+
+// NewWriter returns a Writer writing to w.
+func NewWriter(w ion.Writer) *Writer {
+	return &Writer{w: w}
+}
+
+// Err returns the first error encountered.
+func (w *Writer) Err() error {
+	return w.err
+}
+
+func (w *Writer) do(f func() error) {
+	if w.err == nil {
+		w.err = f()
+	}
+}
+
+// Field writes the name of the next struct field.
+func (w *Writer) Field(name string) {
+	w.do(func() error { return w.w.FieldName(ion.NewSymbolTokenFromString(name)) })
+}
+
+func (w *Writer) BeginStruct() { w.do(w.w.BeginStruct) }
+func (w *Writer) EndStruct()   { w.do(w.w.EndStruct) }
+func (w *Writer) BeginList()   { w.do(w.w.BeginList) }
+func (w *Writer) EndList()     { w.do(w.w.EndList) }
+func (w *Writer) Null()        { w.do(w.w.WriteNull) }
+
+func (w *Writer) String(v string) {
+	w.do(func() error { return w.w.WriteString(v) })
+}
+
+func (w *Writer) Bool(v bool) {
+	w.do(func() error { return w.w.WriteBool(v) })
+}
+
+func (w *Writer) Int(v int64) {
+	w.do(func() error { return w.w.WriteInt(v) })
+}
+
+func (w *Writer) Uint(v uint64) {
+	w.do(func() error { return w.w.WriteUint(v) })
+}
+
+func (w *Writer) Float(v float64) {
+	w.do(func() error { return w.w.WriteFloat(v) })
+}
+
+func (w *Writer) Blob(v []byte) {
+	w.do(func() error { return w.w.WriteBlob(v) })
+}
+
+// Symbol writes v as an Ion symbol; enums are written by value name.
+func (w *Writer) Symbol(v string) {
+	w.do(func() error { return w.w.WriteSymbolFromString(v) })
+}
+
+// Decimal writes the decimal number in v as an Ion decimal, or null if v is
+// empty.
+func (w *Writer) Decimal(v string) {
+	if v == "" {
+		w.Null()
+		return
+	}
+	w.do(func() error {
+		d, err := ion.ParseDecimal(v)
+		if err != nil {
+			return fmt.Errorf("ionpb: invalid decimal %q: %v", v, err)
+		}
+		return w.w.WriteDecimal(d)
+	})
+}
+
+// Timestamp writes ts as an Ion timestamp with nanosecond precision.
+func (w *Writer) Timestamp(ts *timestamp.Timestamp) {
+	w.do(func() error {
+		t, err := ptypes.Timestamp(ts)
+		if err != nil {
+			return err
+		}
+		return w.w.WriteTimestamp(ion.NewTimestamp(t, ion.TimestampPrecisionNanosecond, ion.TimezoneUTC))
+	})
+}
+
+// Duration writes d as an Ion decimal number of seconds.
+func (w *Writer) Duration(d *duration.Duration) {
+	sign := ""
+	sec, nanos := d.GetSeconds(), d.GetNanos()
+	if sec < 0 || nanos < 0 {
+		sign, sec, nanos = "-", -sec, -nanos
+	}
+	w.Decimal(fmt.Sprintf("%s%d.%09d", sign, sec, nanos))
+}
+
+// Message writes the nested message m.
+func (w *Writer) Message(m Marshaler) {
+	w.do(func() error { return m.MarshalIon(w.w) })
+}
@@ -0,0 +1,170 @@
+// Package jcs implements the JSON Canonicalization Scheme (RFC 8785) and is
+// the runtime support for code generated by protoc-gen-go-canonicaljson.
+package jcs
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"unicode/utf16"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+)
+
+// Marshal returns the canonical JSON encoding of m under the proto3 JSON
+// mapping: field names in lowerCamelCase, default values omitted, 64-bit
+// integers as strings and enums by name.
+func Marshal(m proto.Message) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := new(jsonpb.Marshaler).Marshal(&buf, m); err != nil {
+		return nil, err
+	}
+	return Canonicalize(buf.Bytes())
+}
+
+// Canonicalize re-encodes the JSON document b in canonical form: no
+// insignificant whitespace, object members sorted by the UTF-16 code units
+// of their names, ECMAScript number formatting and minimal string escaping.
+func Canonicalize(b []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	if dec.More() {
+		return nil, errors.New("jcs: trailing data after JSON value")
+	}
+	var buf bytes.Buffer
+	if err := encode(&buf, v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func encode(w *bytes.Buffer, v interface{}) error {
+	switch v := v.(type) {
+	case nil:
+		w.WriteString("null")
+	case bool:
+		w.WriteString(strconv.FormatBool(v))
+	case json.Number:
+		f, err := strconv.ParseFloat(string(v), 64)
+		if err != nil {
+			return fmt.Errorf("jcs: invalid number %s: %v", v, err)
+		}
+		s, err := formatNumber(f)
+		if err != nil {
+			return err
+		}
+		w.WriteString(s)
+	case string:
+		writeString(w, v)
+	case []interface{}:
+		w.WriteByte('[')
+		for i, e := range v {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			if err := encode(w, e); err != nil {
+				return err
+			}
+		}
+		w.WriteByte(']')
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Slice(keys, func(i, j int) bool { return lessUTF16(keys[i], keys[j]) })
+		w.WriteByte('{')
+		for i, k := range keys {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			writeString(w, k)
+			w.WriteByte(':')
+			if err := encode(w, v[k]); err != nil {
+				return err
+			}
+		}
+		w.WriteByte('}')
+	default:
+		return fmt.Errorf("jcs: unexpected value %T", v)
+	}
+	return nil
+}
+
+// formatNumber formats f as ECMAScript's Number.prototype.toString does.
+func formatNumber(f float64) (string, error) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return "", fmt.Errorf("jcs: %v cannot be represented", f)
+	}
+	if f == 0 {
+		// Also covers negative zero.
+		return "0", nil
+	}
+	format := byte('f')
+	if abs := math.Abs(f); abs < 1e-6 || abs >= 1e21 {
+		format = 'e'
+	}
+	s := strconv.FormatFloat(f, format, -1, 64)
+	if format == 'e' {
+		// Go pads the exponent to two digits, ECMAScript does not.
+		if n := len(s); n >= 4 && s[n-4] == 'e' && s[n-2] == '0' {
+			s = s[:n-2] + s[n-1:]
+		}
+	}
+	return s, nil
+}
+
+// writeString writes s as a JSON string, escaping only what RFC 8785
+// requires.
+func writeString(w *bytes.Buffer, s string) {
+	const hex = "0123456789abcdef"
+	w.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			w.WriteString(`\"`)
+		case '\\':
+			w.WriteString(`\\`)
+		case '\b':
+			w.WriteString(`\b`)
+		case '\f':
+			w.WriteString(`\f`)
+		case '\n':
+			w.WriteString(`\n`)
+		case '\r':
+			w.WriteString(`\r`)
+		case '\t':
+			w.WriteString(`\t`)
+		default:
+			if r < 0x20 {
+				w.WriteString(`\u00`)
+				w.WriteByte(hex[r>>4])
+				w.WriteByte(hex[r&0xf])
+			} else {
+				w.WriteRune(r)
+			}
+		}
+	}
+	w.WriteByte('"')
+}
+
+// lessUTF16 orders a and b by their UTF-16 code units.
+func lessUTF16(a, b string) bool {
+	ua := utf16.Encode([]rune(a))
+	ub := utf16.Encode([]rune(b))
+	for i := 0; i < len(ua) && i < len(ub); i++ {
+		if ua[i] != ub[i] {
+			return ua[i] < ub[i]
+		}
+	}
+	return len(ua) < len(ub)
+}
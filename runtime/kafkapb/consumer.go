@@ -0,0 +1,58 @@
+package kafkapb
+
+import (
+	"context"
+
+	"github.com/Shopify/sarama"
+	"github.com/golang/protobuf/proto"
+)
+
+// HandlerFunc handles a decoded message. Record(ctx) returns the record it
+// was decoded from.
+type HandlerFunc func(ctx context.Context, msg proto.Message) error
+
+// ConsumerGroupHandler is a sarama.ConsumerGroupHandler decoding each
+// record and passing it to Handle. A record is marked consumed once Handle
+// succeeds. An error from Handle ends the session without marking the
+// record, so that it is consumed again after the group rebalances; records
+// that cannot be decoded are passed to ErrorHandler and marked.
+type ConsumerGroupHandler struct {
+	// Codec decodes records without a content type header; CodecProto if
+	// empty.
+	Codec string
+	// NewMessage returns an empty message to decode a record into.
+	NewMessage func() proto.Message
+	Handle     HandlerFunc
+}
+
+// Setup implements sarama.ConsumerGroupHandler.
+func (h *ConsumerGroupHandler) Setup(sarama.ConsumerGroupSession) error {
+	return nil
+}
+
+// Cleanup implements sarama.ConsumerGroupHandler.
+func (h *ConsumerGroupHandler) Cleanup(sarama.ConsumerGroupSession) error {
+	return nil
+}
+
+// ConsumeClaim implements sarama.ConsumerGroupHandler.
+func (h *ConsumerGroupHandler) ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	for record := range claim.Messages() {
+		codec := recordCodec(record)
+		if codec == "" {
+			codec = h.Codec
+		}
+		msg := h.NewMessage()
+		if err := Unmarshal(codec, record.Value, msg); err != nil {
+			ErrorHandler(record, err)
+			session.MarkMessage(record, "")
+			continue
+		}
+		ctx := context.WithValue(session.Context(), recordKey{}, record)
+		if err := h.Handle(ctx, msg); err != nil {
+			return err
+		}
+		session.MarkMessage(record, "")
+	}
+	return nil
+}
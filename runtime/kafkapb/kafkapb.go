@@ -0,0 +1,128 @@
+// Package kafkapb is the runtime support for code generated by
+// protoc-gen-go-kafka. It produces protobuf messages as Kafka records with
+// sarama, encoded as binary protobuf or JSON, and decodes them again in
+// consumer group handlers.
+package kafkapb
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/Shopify/sarama"
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+)
+
+// Codecs encoding record values.
+const (
+	CodecProto = "proto"
+	CodecJSON  = "json"
+)
+
+// ContentTypeHeader is the record header naming the content type of the
+// value, so consumers can decode records from producers using either
+// codec.
+const ContentTypeHeader = "content-type"
+
+var contentTypes = map[string]string{
+	CodecProto: "application/x-protobuf",
+	CodecJSON:  "application/json",
+}
+
+// ErrorHandler is called with records a consumer group handler cannot
+// decode, which are then skipped. It logs them by default.
+var ErrorHandler = func(record *sarama.ConsumerMessage, err error) {
+	log.Printf("kafkapb: %s/%d@%d: %v", record.Topic, record.Partition, record.Offset, err)
+}
+
+// Marshal encodes msg with codec; an empty codec selects CodecProto.
+func Marshal(codec string, msg proto.Message) ([]byte, error) {
+	switch codec {
+	case "", CodecProto:
+		return proto.Marshal(msg)
+	case CodecJSON:
+		var buf bytes.Buffer
+		if err := new(jsonpb.Marshaler).Marshal(&buf, msg); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+	return nil, fmt.Errorf("kafkapb: unknown codec %q", codec)
+}
+
+// Unmarshal decodes data encoded with codec into msg; an empty codec
+// selects CodecProto.
+func Unmarshal(codec string, data []byte, msg proto.Message) error {
+	switch codec {
+	case "", CodecProto:
+		return proto.Unmarshal(data, msg)
+	case CodecJSON:
+		u := jsonpb.Unmarshaler{AllowUnknownFields: true}
+		return u.Unmarshal(bytes.NewReader(data), msg)
+	}
+	return fmt.Errorf("kafkapb: unknown codec %q", codec)
+}
+
+// Producer sends messages as records through a sarama.SyncProducer.
+type Producer struct {
+	Producer sarama.SyncProducer
+	// Codec encodes the record values; CodecProto if empty.
+	Codec string
+}
+
+// NewProducer returns a Producer sending records through p with the proto
+// codec.
+func NewProducer(p sarama.SyncProducer) *Producer {
+	return &Producer{Producer: p, Codec: CodecProto}
+}
+
+// Send encodes msg and sends it to topic, keyed by key if non-nil.
+func (p *Producer) Send(topic string, key []byte, msg proto.Message) error {
+	codec := p.Codec
+	if codec == "" {
+		codec = CodecProto
+	}
+	value, err := Marshal(codec, msg)
+	if err != nil {
+		return err
+	}
+	record := &sarama.ProducerMessage{
+		Topic: topic,
+		Value: sarama.ByteEncoder(value),
+		Headers: []sarama.RecordHeader{
+			{Key: []byte(ContentTypeHeader), Value: []byte(contentTypes[codec])},
+		},
+	}
+	if key != nil {
+		record.Key = sarama.ByteEncoder(key)
+	}
+	_, _, err = p.Producer.SendMessage(record)
+	return err
+}
+
+type recordKey struct{}
+
+// Record returns the record being handled by a consumer group handler, or
+// nil if ctx was not passed to a handler.
+func Record(ctx context.Context) *sarama.ConsumerMessage {
+	r, _ := ctx.Value(recordKey{}).(*sarama.ConsumerMessage)
+	return r
+}
+
+// recordCodec returns the codec named by the content type header of
+// record, or "" if it has none.
+func recordCodec(record *sarama.ConsumerMessage) string {
+	for _, h := range record.Headers {
+		if h == nil || string(h.Key) != ContentTypeHeader {
+			continue
+		}
+		for codec, ct := range contentTypes {
+			if string(h.Value) == ct {
+				return codec
+			}
+		}
+	}
+	return ""
+}
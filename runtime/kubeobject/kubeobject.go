@@ -0,0 +1,29 @@
+// Package kubeobject is the runtime support for code generated by
+// protoc-gen-go-k8s.
+package kubeobject
+
+import (
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// Kind is the schema.ObjectKind of a custom resource message. The message
+// has no TypeMeta to record the group, version and kind it was read with,
+// so Kind always reports those of its type and ignores
+// SetGroupVersionKind.
+type Kind struct {
+	gvk schema.GroupVersionKind
+}
+
+// NewKind returns the Kind reporting gvk.
+func NewKind(gvk schema.GroupVersionKind) *Kind {
+	return &Kind{gvk: gvk}
+}
+
+// SetGroupVersionKind does nothing: the kind of a message is that of its
+// type.
+func (k *Kind) SetGroupVersionKind(schema.GroupVersionKind) {}
+
+// GroupVersionKind returns the group, version and kind of the message.
+func (k *Kind) GroupVersionKind() schema.GroupVersionKind {
+	return k.gvk
+}
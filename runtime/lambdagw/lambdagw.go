@@ -0,0 +1,167 @@
+// Package lambdagw is the runtime support for code generated by
+// protoc-gen-go-lambda. It serves API Gateway HTTP API events with an
+// http.Handler, such as the one generated by protoc-gen-go-httpgateway, so
+// that services can run on AWS Lambda without a gateway in front of them.
+//
+// The event types follow payload format 2.0 and need no AWS library; the
+// handler can be passed to lambda.Start of github.com/aws/aws-lambda-go.
+package lambdagw
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// HandlerFunc handles one API Gateway event.
+type HandlerFunc func(ctx context.Context, req *Request) (*Response, error)
+
+type requestContextKey struct{}
+
+// RequestFromContext returns the event being served, e.g. to read the
+// request context of API Gateway from a service method.
+func RequestFromContext(ctx context.Context) (*Request, bool) {
+	req, ok := ctx.Value(requestContextKey{}).(*Request)
+	return req, ok
+}
+
+// Handler returns a HandlerFunc serving events with h. Errors are only
+// returned for events that cannot be turned into an http.Request; those of
+// h are part of its response.
+func Handler(h http.Handler) HandlerFunc {
+	return func(ctx context.Context, req *Request) (*Response, error) {
+		r, err := httpRequest(context.WithValue(ctx, requestContextKey{}, req), req)
+		if err != nil {
+			return nil, err
+		}
+		w := &responseWriter{header: make(http.Header)}
+		h.ServeHTTP(w, r)
+		return w.response(), nil
+	}
+}
+
+func httpRequest(ctx context.Context, req *Request) (*http.Request, error) {
+	body := []byte(req.Body)
+	if req.IsBase64Encoded {
+		var err error
+		if body, err = base64.StdEncoding.DecodeString(req.Body); err != nil {
+			return nil, err
+		}
+	}
+	url := req.RawPath
+	if req.RawQueryString != "" {
+		url += "?" + req.RawQueryString
+	}
+	r, err := http.NewRequestWithContext(ctx, req.RequestContext.HTTP.Method, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range req.Headers {
+		// API Gateway joins repeated headers with commas, which is
+		// equivalent for list-valued headers.
+		r.Header.Set(k, v)
+	}
+	if len(req.Cookies) > 0 {
+		r.Header.Set("Cookie", strings.Join(req.Cookies, "; "))
+	}
+	r.Host = req.RequestContext.DomainName
+	r.RemoteAddr = req.RequestContext.HTTP.SourceIP
+	r.RequestURI = url
+	r.ContentLength = int64(len(body))
+	return r, nil
+}
+
+// responseWriter records a response in memory.
+type responseWriter struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (w *responseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *responseWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	return w.body.Write(b)
+}
+
+func (w *responseWriter) response() *Response {
+	resp := &Response{StatusCode: w.status, Headers: make(map[string]string)}
+	if resp.StatusCode == 0 {
+		resp.StatusCode = http.StatusOK
+	}
+	for k, v := range w.header {
+		if k == "Set-Cookie" {
+			resp.Cookies = v
+			continue
+		}
+		resp.Headers[k] = strings.Join(v, ",")
+	}
+	if b := w.body.Bytes(); utf8.Valid(b) {
+		resp.Body = string(b)
+	} else {
+		resp.Body = base64.StdEncoding.EncodeToString(b)
+		resp.IsBase64Encoded = true
+	}
+	return resp
+}
+
+// httpStatuses are the HTTP statuses of gRPC status codes.
+var httpStatuses = map[codes.Code]int{
+	codes.OK:                 http.StatusOK,
+	codes.Canceled:           499,
+	codes.Unknown:            http.StatusInternalServerError,
+	codes.InvalidArgument:    http.StatusBadRequest,
+	codes.DeadlineExceeded:   http.StatusGatewayTimeout,
+	codes.NotFound:           http.StatusNotFound,
+	codes.AlreadyExists:      http.StatusConflict,
+	codes.PermissionDenied:   http.StatusForbidden,
+	codes.ResourceExhausted:  http.StatusTooManyRequests,
+	codes.FailedPrecondition: http.StatusBadRequest,
+	codes.Aborted:            http.StatusConflict,
+	codes.OutOfRange:         http.StatusBadRequest,
+	codes.Unimplemented:      http.StatusNotImplemented,
+	codes.Internal:           http.StatusInternalServerError,
+	codes.Unavailable:        http.StatusServiceUnavailable,
+	codes.DataLoss:           http.StatusInternalServerError,
+	codes.Unauthenticated:    http.StatusUnauthorized,
+}
+
+// WriteError writes err as a JSON object with "code" and "message", like
+// httpgw.WriteError, but also maps gRPC status errors to their HTTP
+// status. The status is taken from an HTTPStatus() int method of err, then
+// from its gRPC code, and is 500 for other errors.
+func WriteError(w http.ResponseWriter, r *http.Request, err error) {
+	code := http.StatusInternalServerError
+	msg := err.Error()
+	if s, ok := err.(interface{ HTTPStatus() int }); ok {
+		code = s.HTTPStatus()
+	} else if st, ok := status.FromError(err); ok {
+		if c, ok := httpStatuses[st.Code()]; ok {
+			code = c
+		}
+		msg = st.Message()
+	}
+	body, _ := json.Marshal(struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	}{code, msg})
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	w.Write(body)
+}
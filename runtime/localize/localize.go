@@ -0,0 +1,185 @@
+// Package localize is the runtime support for code generated by
+// protoc-gen-go-i18n. The generated Localize methods set the user-facing
+// text fields of messages to their translations by a Localizer, which
+// adapts go-i18n or golang.org/x/text/message; Status and the interceptors
+// localize the error details returned to clients.
+package localize
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+	"golang.org/x/text/message"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// Message is a user-facing text to translate, with the values of its
+// placeholders.
+type Message struct {
+	// ID is the full proto name of the field holding the text, under which
+	// the catalogs of protoc-gen-go-i18n hold it.
+	ID string
+	// Template is the source text as a text/template over Data, the way
+	// go-i18n takes it, and Format the same text as a fmt format over Args,
+	// the way golang.org/x/text/message takes it.
+	Template, Format string
+	// Names are the names of the placeholders of the text, and Args their
+	// values.
+	Names []string
+	Args  []interface{}
+}
+
+// Data returns the values of the placeholders of m by name.
+func (m *Message) Data() map[string]interface{} {
+	data := make(map[string]interface{}, len(m.Names))
+	for i, name := range m.Names {
+		data[name] = m.Args[i]
+	}
+	return data
+}
+
+// A Localizer translates texts into the language of a request.
+type Localizer interface {
+	Localize(m *Message) (string, error)
+}
+
+// LocalizerFunc adapts a function to a Localizer.
+type LocalizerFunc func(m *Message) (string, error)
+
+// Localize returns f(m).
+func (f LocalizerFunc) Localize(m *Message) (string, error) {
+	return f(m)
+}
+
+// Source is the Localizer leaving texts in their source language.
+var Source Localizer = LocalizerFunc(func(m *Message) (string, error) {
+	return fmt.Sprintf(m.Format, m.Args...), nil
+})
+
+// GoI18n returns the Localizer translating texts with l. Texts the bundle
+// of l has no translation of are left in their source language.
+func GoI18n(l *i18n.Localizer) Localizer {
+	return LocalizerFunc(func(m *Message) (string, error) {
+		s, err := l.Localize(&i18n.LocalizeConfig{
+			DefaultMessage: &i18n.Message{ID: m.ID, Other: m.Template},
+			TemplateData:   m.Data(),
+		})
+		var notFound *i18n.MessageNotFoundErr
+		if errors.As(err, &notFound) && s != "" {
+			return s, nil
+		}
+		return s, err
+	})
+}
+
+// Printer returns the Localizer translating texts with p, whose catalog
+// holds them under their IDs. Texts missing from it are left in their
+// source language.
+func Printer(p *message.Printer) Localizer {
+	return LocalizerFunc(func(m *Message) (string, error) {
+		return p.Sprintf(message.Key(m.ID, m.Format), m.Args...), nil
+	})
+}
+
+// All returns the translations of texts by loc, in order.
+func All(loc Localizer, texts ...*Message) ([]string, error) {
+	out := make([]string, len(texts))
+	for i, m := range texts {
+		s, err := loc.Localize(m)
+		if err != nil {
+			return nil, fmt.Errorf("localize %s: %v", m.ID, err)
+		}
+		out[i] = s
+	}
+	return out, nil
+}
+
+// Localizable is implemented by the messages with user-facing text fields.
+type Localizable interface {
+	proto.Message
+	Localize(loc Localizer) error
+}
+
+// Status returns st with its Localizable details localized by loc. Unless
+// st carries one already, a google.rpc.LocalizedMessage detail of locale
+// is added with the first text localized, so that clients unaware of the
+// details can show it. st is returned as is if a detail fails to localize.
+func Status(st *status.Status, locale string, loc Localizer) *status.Status {
+	var first string
+	record := LocalizerFunc(func(m *Message) (string, error) {
+		s, err := loc.Localize(m)
+		if err == nil && first == "" {
+			first = s
+		}
+		return s, err
+	})
+	var details []proto.Message
+	localized := false
+	for _, d := range st.Details() {
+		m, ok := d.(proto.Message)
+		if !ok {
+			return st
+		}
+		switch m := m.(type) {
+		case *errdetails.LocalizedMessage:
+			localized = true
+		case Localizable:
+			c := proto.Clone(m).(Localizable)
+			if err := c.Localize(record); err != nil {
+				return st
+			}
+			details = append(details, c)
+			continue
+		}
+		details = append(details, m)
+	}
+	if first != "" && !localized {
+		details = append(details, &errdetails.LocalizedMessage{Locale: locale, Message: first})
+	}
+	out, err := status.New(st.Code(), st.Message()).WithDetails(details...)
+	if err != nil {
+		return st
+	}
+	return out
+}
+
+// UnaryServerInterceptor returns an interceptor localizing the status of
+// the errors of calls with the locale and Localizer localizer returns for
+// their context, e.g. after the accept-language metadata of the call.
+func UnaryServerInterceptor(localizer func(ctx context.Context) (string, Localizer)) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		resp, err := handler(ctx, req)
+		if err != nil {
+			return nil, localizeError(ctx, err, localizer)
+		}
+		return resp, nil
+	}
+}
+
+// StreamServerInterceptor is the streaming counterpart of
+// UnaryServerInterceptor.
+func StreamServerInterceptor(localizer func(ctx context.Context) (string, Localizer)) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := handler(srv, ss); err != nil {
+			return localizeError(ss.Context(), err, localizer)
+		}
+		return nil
+	}
+}
+
+func localizeError(ctx context.Context, err error, localizer func(ctx context.Context) (string, Localizer)) error {
+	st, ok := status.FromError(err)
+	if !ok || len(st.Details()) == 0 {
+		return err
+	}
+	locale, loc := localizer(ctx)
+	if loc == nil {
+		return err
+	}
+	return Status(st, locale, loc).Err()
+}
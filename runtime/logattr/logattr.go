@@ -0,0 +1,87 @@
+// Package logattr is the runtime support for code generated by
+// protoc-gen-go-slog and protoc-gen-go-zap, and is shared by
+// protoc-gen-go-summary. It bounds the size of the values messages log, so
+// that a huge message cannot flood the logs.
+package logattr
+
+import (
+	"fmt"
+	"log/slog"
+	"sort"
+	"strconv"
+	"unicode/utf8"
+)
+
+var (
+	// MaxString is the number of bytes of a string logged in full; longer
+	// strings are cut.
+	MaxString = 256
+	// MaxItems is the number of items of a repeated or map field logged;
+	// the rest are counted.
+	MaxItems = 16
+)
+
+// Truncate returns s cut to MaxString bytes, on a rune boundary, with the
+// number of bytes left out appended.
+func Truncate(s string) string {
+	if len(s) <= MaxString {
+		return s
+	}
+	n := MaxString
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n] + "…(" + strconv.Itoa(len(s)-n) + " more bytes)"
+}
+
+// String returns the value of the string s, see Truncate.
+func String(s string) slog.Value {
+	return slog.StringValue(Truncate(s))
+}
+
+// ByteCount returns a summary of b: its length.
+func ByteCount(b []byte) string {
+	return strconv.Itoa(len(b)) + " bytes"
+}
+
+// Bytes returns the value of b, see ByteCount.
+func Bytes(b []byte) slog.Value {
+	return slog.StringValue(ByteCount(b))
+}
+
+// List returns the value of the repeated field s, each item turned into a
+// value by f. Items past MaxItems are counted in a "more" attribute.
+func List[T any](s []T, f func(T) slog.Value) slog.Value {
+	n := min(len(s), MaxItems)
+	attrs := make([]slog.Attr, 0, n+1)
+	for i, v := range s[:n] {
+		attrs = append(attrs, slog.Attr{Key: strconv.Itoa(i), Value: f(v)})
+	}
+	if len(s) > n {
+		attrs = append(attrs, slog.Int("more", len(s)-n))
+	}
+	return slog.GroupValue(attrs...)
+}
+
+// Map returns the value of the map field m, each value turned into a value
+// by f, in key order. Entries past MaxItems are counted in a "more"
+// attribute.
+func Map[K comparable, V any](m map[K]V, f func(V) slog.Value) slog.Value {
+	keys := make([]string, 0, len(m))
+	values := make(map[string]V, len(m))
+	for k, v := range m {
+		key := fmt.Sprint(k)
+		keys = append(keys, key)
+		values[key] = v
+	}
+	sort.Strings(keys)
+	n := min(len(keys), MaxItems)
+	attrs := make([]slog.Attr, 0, n+1)
+	for _, k := range keys[:n] {
+		attrs = append(attrs, slog.Attr{Key: k, Value: f(values[k])})
+	}
+	if len(keys) > n {
+		attrs = append(attrs, slog.Int("more", len(keys)-n))
+	}
+	return slog.GroupValue(attrs...)
+}
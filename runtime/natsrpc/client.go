@@ -0,0 +1,77 @@
+package natsrpc
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/nats-io/nats.go"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// DefaultTimeout bounds calls whose context has no deadline.
+const DefaultTimeout = 5 * time.Second
+
+// Client sends requests over a NATS connection.
+type Client struct {
+	Conn *nats.Conn
+	// Timeout bounds calls whose context has no deadline; DefaultTimeout
+	// if zero.
+	Timeout time.Duration
+}
+
+// NewClient returns a Client sending requests over nc.
+func NewClient(nc *nats.Conn) *Client {
+	return &Client{Conn: nc}
+}
+
+// Call sends req on subject and decodes the reply into resp. The outgoing
+// metadata of ctx is sent as headers. Errors are gRPC status errors.
+func (c *Client) Call(ctx context.Context, subject string, req, resp proto.Message) error {
+	if _, ok := ctx.Deadline(); !ok {
+		timeout := c.Timeout
+		if timeout <= 0 {
+			timeout = DefaultTimeout
+		}
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	deadline, _ := ctx.Deadline()
+	ms := time.Until(deadline).Milliseconds()
+	if ms <= 0 {
+		return status.Error(codes.DeadlineExceeded, context.DeadlineExceeded.Error())
+	}
+
+	msg := nats.NewMsg(subject)
+	if md, ok := metadata.FromOutgoingContext(ctx); ok {
+		setMetadata(msg, md)
+	}
+	msg.Header.Set(TimeoutHeader, strconv.FormatInt(ms, 10))
+	data, err := proto.Marshal(req)
+	if err != nil {
+		return status.Errorf(codes.Internal, "encoding request: %v", err)
+	}
+	msg.Data = data
+
+	reply, err := c.Conn.RequestMsgWithContext(ctx, msg)
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return status.FromContextError(ctxErr).Err()
+		}
+		if err == nats.ErrNoResponders {
+			return status.Errorf(codes.Unavailable, "natsrpc: no responders on %s", subject)
+		}
+		return status.Errorf(codes.Unavailable, "natsrpc: %v", err)
+	}
+	if err := replyError(reply); err != nil {
+		return err
+	}
+	if err := proto.Unmarshal(reply.Data, resp); err != nil {
+		return status.Errorf(codes.Internal, "decoding reply: %v", err)
+	}
+	return nil
+}
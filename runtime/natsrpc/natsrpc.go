@@ -0,0 +1,116 @@
+// Package natsrpc is the runtime support for code generated by
+// protoc-gen-go-nats. It carries protobuf-encoded requests and replies over
+// NATS request/reply, reporting errors as gRPC status codes in reply
+// headers, and publishes and subscribes to protobuf-encoded messages.
+package natsrpc
+
+import (
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/nats-io/nats.go"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Headers used by the request/reply protocol. Other headers carry gRPC
+// metadata.
+const (
+	// StatusHeader holds the numeric gRPC code of a failed call.
+	StatusHeader = "Rpc-Status"
+	// MessageHeader holds the status message of a failed call.
+	MessageHeader = "Rpc-Message"
+	// TimeoutHeader holds the milliseconds left before the caller's
+	// deadline.
+	TimeoutHeader = "Rpc-Timeout-Ms"
+)
+
+// ErrorHandler is called with messages a subscription cannot decode. It
+// logs them by default.
+var ErrorHandler = func(subject string, err error) {
+	log.Printf("natsrpc: %s: %v", subject, err)
+}
+
+// Publish marshals msg and publishes it on subject.
+func Publish(nc *nats.Conn, subject string, msg proto.Message) error {
+	data, err := proto.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	return nc.Publish(subject, data)
+}
+
+// Subscribe calls fn with each message published on subject, decoded into
+// a message returned by newMsg. Subscribers sharing a non-empty queue
+// group split the messages between them.
+func Subscribe(nc *nats.Conn, subject, queue string, newMsg func() proto.Message, fn func(proto.Message)) (*nats.Subscription, error) {
+	cb := func(m *nats.Msg) {
+		msg := newMsg()
+		if err := proto.Unmarshal(m.Data, msg); err != nil {
+			ErrorHandler(m.Subject, err)
+			return
+		}
+		fn(msg)
+	}
+	if queue != "" {
+		return nc.QueueSubscribe(subject, queue, cb)
+	}
+	return nc.Subscribe(subject, cb)
+}
+
+// reservedHeader reports whether key is a header of the protocol rather
+// than metadata.
+func reservedHeader(key string) bool {
+	return strings.HasPrefix(strings.ToLower(key), "rpc-")
+}
+
+// setMetadata copies md into the headers of m.
+func setMetadata(m *nats.Msg, md metadata.MD) {
+	for k, vs := range md {
+		if reservedHeader(k) {
+			continue
+		}
+		for _, v := range vs {
+			m.Header.Add(k, v)
+		}
+	}
+}
+
+// headerMetadata returns the headers of m that carry metadata.
+func headerMetadata(m *nats.Msg) metadata.MD {
+	md := metadata.MD{}
+	for k, vs := range m.Header {
+		if reservedHeader(k) {
+			continue
+		}
+		md.Append(strings.ToLower(k), vs...)
+	}
+	return md
+}
+
+// setStatus records err in the headers of the reply m.
+func setStatus(m *nats.Msg, err error) {
+	st := status.Convert(err)
+	m.Header.Set(StatusHeader, strconv.Itoa(int(st.Code())))
+	m.Header.Set(MessageHeader, st.Message())
+}
+
+// replyError returns the error recorded in the headers of the reply m, or
+// nil if the call succeeded.
+func replyError(m *nats.Msg) error {
+	v := m.Header.Get(StatusHeader)
+	if v == "" {
+		return nil
+	}
+	code, err := strconv.Atoi(v)
+	if err != nil {
+		return status.Errorf(codes.Internal, "natsrpc: invalid %s header %q", StatusHeader, v)
+	}
+	if codes.Code(code) == codes.OK {
+		return nil
+	}
+	return status.Error(codes.Code(code), m.Header.Get(MessageHeader))
+}
@@ -0,0 +1,100 @@
+package natsrpc
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/nats-io/nats.go"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// UnaryFunc calls a unary method with a decoded request.
+type UnaryFunc func(ctx context.Context, req proto.Message) (proto.Message, error)
+
+// Method binds a unary method to the subject it is served on.
+type Method struct {
+	Subject string
+	// NewRequest returns an empty request message.
+	NewRequest func() proto.Message
+	Call       UnaryFunc
+}
+
+// Serve subscribes to the subjects of methods and answers their requests
+// until ctx is done, then drains the subscriptions and waits for the calls
+// in flight. Servers sharing a non-empty queue group split the requests
+// between them.
+func Serve(ctx context.Context, nc *nats.Conn, queue string, methods []Method) error {
+	var wg sync.WaitGroup
+	var subs []*nats.Subscription
+	defer func() {
+		for _, sub := range subs {
+			sub.Drain()
+		}
+		wg.Wait()
+	}()
+	for _, m := range methods {
+		m := m
+		cb := func(msg *nats.Msg) {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				handle(ctx, msg, m)
+			}()
+		}
+		var sub *nats.Subscription
+		var err error
+		if queue != "" {
+			sub, err = nc.QueueSubscribe(m.Subject, queue, cb)
+		} else {
+			sub, err = nc.Subscribe(m.Subject, cb)
+		}
+		if err != nil {
+			return err
+		}
+		subs = append(subs, sub)
+	}
+	<-ctx.Done()
+	return nil
+}
+
+// handle calls m with the request msg and replies with its result.
+func handle(ctx context.Context, msg *nats.Msg, m Method) {
+	if v := msg.Header.Get(TimeoutHeader); v != "" {
+		if ms, err := strconv.ParseInt(v, 10, 64); err == nil {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, time.Duration(ms)*time.Millisecond)
+			defer cancel()
+		}
+	}
+	ctx = metadata.NewIncomingContext(ctx, headerMetadata(msg))
+
+	resp, err := call(ctx, msg, m)
+	if msg.Reply == "" {
+		// Published rather than requested; nobody awaits a reply.
+		return
+	}
+	reply := nats.NewMsg(msg.Reply)
+	if err == nil {
+		reply.Data, err = proto.Marshal(resp)
+	}
+	if err != nil {
+		reply.Data = nil
+		setStatus(reply, err)
+	}
+	if err := msg.RespondMsg(reply); err != nil {
+		ErrorHandler(msg.Subject, err)
+	}
+}
+
+func call(ctx context.Context, msg *nats.Msg, m Method) (proto.Message, error) {
+	req := m.NewRequest()
+	if err := proto.Unmarshal(msg.Data, req); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "decoding request: %v", err)
+	}
+	return m.Call(ctx, req)
+}
@@ -0,0 +1,34 @@
+//go:build go1.23
+
+package pagination
+
+import "iter"
+
+// FetchFunc fetches the page at token and returns its items and the token
+// of the next page, "" after the last one.
+type FetchFunc[T any] func(token string) (items []T, next string, err error)
+
+// All returns an iterator over the items of every page, starting at token.
+// A fetch error is yielded once, with the zero item, before the iterator
+// ends. Pages are fetched as the iteration reaches them.
+func All[T any](token string, fetch FetchFunc[T]) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		for {
+			items, next, err := fetch(token)
+			if err != nil {
+				var zero T
+				yield(zero, err)
+				return
+			}
+			for _, item := range items {
+				if !yield(item, nil) {
+					return
+				}
+			}
+			if next == "" {
+				return
+			}
+			token = next
+		}
+	}
+}
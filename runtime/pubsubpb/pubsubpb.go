@@ -0,0 +1,128 @@
+// Package pubsubpb is the runtime support for code generated by
+// protoc-gen-go-gcppubsub. It publishes protobuf messages to Google Cloud
+// Pub/Sub topics and decodes them in subscription handlers.
+package pubsubpb
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"sync"
+
+	"cloud.google.com/go/pubsub"
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+)
+
+// ContentTypeAttribute is the message attribute naming the encoding of the
+// data. Messages are published as binary protobuf; messages whose
+// attribute is "application/json" are decoded as JSON.
+const ContentTypeAttribute = "content-type"
+
+const (
+	contentTypeProto = "application/x-protobuf"
+	contentTypeJSON  = "application/json"
+)
+
+// ErrorHandler is called with messages a subscription handler cannot
+// decode, which are then nacked; configure a dead letter topic on the
+// subscription to set them aside. It logs them by default.
+var ErrorHandler = func(m *pubsub.Message, err error) {
+	log.Printf("pubsubpb: message %s: %v", m.ID, err)
+}
+
+var (
+	mu     sync.Mutex
+	topics = make(map[*pubsub.Client]map[string]*pubsub.Topic)
+)
+
+// Topic returns the topic id of client, with message ordering enabled.
+// Topics are created once per client and reused, so that their publish
+// batches are shared; Stop flushes and releases them.
+func Topic(client *pubsub.Client, id string) *pubsub.Topic {
+	mu.Lock()
+	defer mu.Unlock()
+	byID := topics[client]
+	if byID == nil {
+		byID = make(map[string]*pubsub.Topic)
+		topics[client] = byID
+	}
+	t := byID[id]
+	if t == nil {
+		t = client.Topic(id)
+		t.EnableMessageOrdering = true
+		byID[id] = t
+	}
+	return t
+}
+
+// Stop publishes the pending messages of the topics returned by Topic for
+// client and releases them. Call it before closing client.
+func Stop(client *pubsub.Client) {
+	mu.Lock()
+	byID := topics[client]
+	delete(topics, client)
+	mu.Unlock()
+	for _, t := range byID {
+		t.Stop()
+	}
+}
+
+// Publish publishes msg to t with orderingKey and attrs, and waits for the
+// server-assigned message ID. A failed publish with an ordering key pauses
+// publishing for that key until ResumePublish is called on t.
+func Publish(ctx context.Context, t *pubsub.Topic, msg proto.Message, orderingKey string, attrs map[string]string) (string, error) {
+	data, err := proto.Marshal(msg)
+	if err != nil {
+		return "", err
+	}
+	if attrs == nil {
+		attrs = make(map[string]string, 1)
+	}
+	attrs[ContentTypeAttribute] = contentTypeProto
+	return t.Publish(ctx, &pubsub.Message{
+		Data:        data,
+		Attributes:  attrs,
+		OrderingKey: orderingKey,
+	}).Get(ctx)
+}
+
+// HandlerFunc handles a decoded message. Message(ctx) returns the Pub/Sub
+// message it was decoded from.
+type HandlerFunc func(ctx context.Context, msg proto.Message) error
+
+type messageKey struct{}
+
+// Message returns the Pub/Sub message being handled, or nil if ctx was not
+// passed to a handler.
+func Message(ctx context.Context) *pubsub.Message {
+	m, _ := ctx.Value(messageKey{}).(*pubsub.Message)
+	return m
+}
+
+// Receive passes the messages of sub to handle until ctx is done, decoding
+// each into a message returned by newMsg. Messages are acked once handle
+// succeeds and nacked, to be redelivered, if it fails.
+func Receive(ctx context.Context, sub *pubsub.Subscription, newMsg func() proto.Message, handle HandlerFunc) error {
+	return sub.Receive(ctx, func(ctx context.Context, m *pubsub.Message) {
+		msg := newMsg()
+		if err := unmarshal(m, msg); err != nil {
+			ErrorHandler(m, err)
+			m.Nack()
+			return
+		}
+		if err := handle(context.WithValue(ctx, messageKey{}, m), msg); err != nil {
+			m.Nack()
+			return
+		}
+		m.Ack()
+	})
+}
+
+func unmarshal(m *pubsub.Message, msg proto.Message) error {
+	if m.Attributes[ContentTypeAttribute] == contentTypeJSON {
+		u := jsonpb.Unmarshaler{AllowUnknownFields: true}
+		return u.Unmarshal(bytes.NewReader(m.Data), msg)
+	}
+	return proto.Unmarshal(m.Data, msg)
+}
@@ -0,0 +1,37 @@
+// Package rapidpb is the runtime support for the property-based test
+// generators of protoc-gen-go-rapid, which draw random messages with
+// pgregory.net/rapid.
+package rapidpb
+
+import "unicode/utf8"
+
+// MaxDepth is the depth the generators returned by the Gen functions nest
+// optional message fields down to. Required message fields, and the
+// min_items first elements of repeated ones, are drawn at any depth.
+var MaxDepth = 3
+
+// MaxItems returns the greatest number of elements to draw for a repeated
+// or map field of messages, at depth: max, or min once the optional
+// message fields are no longer drawn.
+func MaxItems(depth, min, max int) int {
+	if depth <= 0 {
+		return min
+	}
+	return max
+}
+
+// RuneCount returns a filter of the strings of min to max characters; max
+// is -1 for no upper bound.
+func RuneCount(min, max int) func(string) bool {
+	return func(s string) bool {
+		n := utf8.RuneCountInString(s)
+		return n >= min && (max < 0 || n <= max)
+	}
+}
+
+// NonZero reports whether v is not the zero value of its type, to filter
+// the values of required fields.
+func NonZero[T comparable](v T) bool {
+	var zero T
+	return v != zero
+}
@@ -0,0 +1,60 @@
+// Package ratelimit is the runtime support for code generated by
+// protoc-gen-go-ratelimit. It admits calls through a pluggable Limiter and
+// rejects the excess with RESOURCE_EXHAUSTED.
+package ratelimit
+
+import (
+	"context"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Limit is a token bucket: Burst tokens refilled at Rate per second.
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// A Limiter decides whether the call identified by key may proceed under
+// limit. When it may not, it reports how long to wait before retrying.
+type Limiter interface {
+	Allow(ctx context.Context, key string, limit Limit) (ok bool, retryAfter time.Duration, err error)
+}
+
+type keyContextKey struct{}
+
+// WithKey returns a copy of ctx whose calls are limited separately under
+// key, e.g. a caller or tenant ID set by an authentication interceptor.
+// Without a key all callers of a method share its limit.
+func WithKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, keyContextKey{}, key)
+}
+
+// Check asks limiter to admit a call to method under limit. It returns a
+// RESOURCE_EXHAUSTED status carrying a RetryInfo detail when the call is
+// rejected. Errors from the limiter itself fail open.
+func Check(ctx context.Context, limiter Limiter, method string, limit Limit) error {
+	key := method
+	if k, ok := ctx.Value(keyContextKey{}).(string); ok && k != "" {
+		key += ":" + k
+	}
+	ok, retryAfter, err := limiter.Allow(ctx, key, limit)
+	if err != nil || ok {
+		return nil
+	}
+	return Exhausted(retryAfter)
+}
+
+// Exhausted returns a RESOURCE_EXHAUSTED error telling the caller to retry
+// after retryAfter.
+func Exhausted(retryAfter time.Duration) error {
+	st := status.New(codes.ResourceExhausted, "ratelimit: rate limit exceeded")
+	if d, err := st.WithDetails(&errdetails.RetryInfo{RetryDelay: ptypes.DurationProto(retryAfter)}); err == nil {
+		st = d
+	}
+	return st.Err()
+}
@@ -0,0 +1,77 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+)
+
+// EvalFunc runs a Lua script on Redis and returns its result. It adapts
+// any Redis client; with go-redis:
+//
+//	eval := func(ctx context.Context, script string, keys []string, args ...interface{}) (interface{}, error) {
+//	    return rdb.Eval(ctx, script, keys, args...).Result()
+//	}
+type EvalFunc func(ctx context.Context, script string, keys []string, args ...interface{}) (interface{}, error)
+
+// tokenBucketScript refills and takes from the bucket stored in the hash
+// KEYS[1]. ARGV holds the rate per second, the burst and the current time
+// in milliseconds. It returns {allowed, retry_after_ms}.
+const tokenBucketScript = `
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local state = redis.call("HMGET", KEYS[1], "tokens", "ts")
+local tokens = tonumber(state[1])
+local ts = tonumber(state[2])
+if tokens == nil then
+    tokens = burst
+    ts = now
+end
+tokens = math.min(burst, tokens + math.max(0, now - ts) / 1000 * rate)
+local allowed = 0
+local wait = 0
+if tokens >= 1 then
+    tokens = tokens - 1
+    allowed = 1
+else
+    wait = math.ceil((1 - tokens) / rate * 1000)
+end
+redis.call("HSET", KEYS[1], "tokens", tostring(tokens), "ts", tostring(now))
+redis.call("PEXPIRE", KEYS[1], math.ceil(burst / rate * 1000) + 1000)
+return {allowed, wait}
+`
+
+// RedisLimiter is a token bucket Limiter whose buckets live in Redis, so
+// limits hold across every replica sharing the Redis instance.
+type RedisLimiter struct {
+	eval   EvalFunc
+	prefix string
+}
+
+// NewRedisLimiter returns a RedisLimiter that stores buckets under keys
+// starting with prefix.
+func NewRedisLimiter(eval EvalFunc, prefix string) *RedisLimiter {
+	return &RedisLimiter{eval: eval, prefix: prefix}
+}
+
+// Allow implements Limiter.
+func (l *RedisLimiter) Allow(ctx context.Context, key string, limit Limit) (bool, time.Duration, error) {
+	burst := limit.Burst
+	if burst <= 0 {
+		burst = int(math.Ceil(limit.Rate))
+	}
+	now := time.Now().UnixNano() / int64(time.Millisecond)
+	res, err := l.eval(ctx, tokenBucketScript, []string{l.prefix + key}, limit.Rate, burst, now)
+	if err != nil {
+		return false, 0, err
+	}
+	vals, ok := res.([]interface{})
+	if !ok || len(vals) != 2 {
+		return false, 0, fmt.Errorf("ratelimit: unexpected script result %v", res)
+	}
+	allowed, _ := vals[0].(int64)
+	wait, _ := vals[1].(int64)
+	return allowed == 1, time.Duration(wait) * time.Millisecond, nil
+}
@@ -0,0 +1,22 @@
+// Package redact is the runtime support for code generated by
+// protoc-gen-go-redact.
+package redact
+
+// Mask replaces the sensitive strings that are not empty.
+var Mask = "[REDACTED]"
+
+// String returns Mask, or "" if s is empty so that unset fields stay
+// unset.
+func String(s string) string {
+	if s == "" {
+		return ""
+	}
+	return Mask
+}
+
+// Bytes zeroes b in place.
+func Bytes(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
@@ -0,0 +1,95 @@
+// Package retry is the runtime support for code generated by
+// protoc-gen-go-retry. It retries calls with exponential backoff and jitter.
+package retry
+
+import (
+	"context"
+	"math/rand"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Defaults applied to the zero fields of a Policy.
+const (
+	DefaultMaxAttempts       = 3
+	DefaultInitialBackoff    = 100 * time.Millisecond
+	DefaultMaxBackoff        = 5 * time.Second
+	DefaultBackoffMultiplier = 2
+)
+
+// Policy describes how a call is retried. Zero fields take the package
+// defaults; an empty Codes retries codes.Unavailable only.
+type Policy struct {
+	MaxAttempts       int
+	Codes             []codes.Code
+	InitialBackoff    time.Duration
+	MaxBackoff        time.Duration
+	BackoffMultiplier float64
+}
+
+// Retryable reports whether err carries one of the policy's retryable
+// codes.
+func (p *Policy) Retryable(err error) bool {
+	if err == nil {
+		return false
+	}
+	code := status.Code(err)
+	if len(p.Codes) == 0 {
+		return code == codes.Unavailable
+	}
+	for _, c := range p.Codes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+// Backoff returns the delay before retry number attempt (starting at 1):
+// a uniformly random duration up to the exponential backoff ("full
+// jitter"), capped at MaxBackoff.
+func (p *Policy) Backoff(attempt int) time.Duration {
+	initial, max, mult := p.InitialBackoff, p.MaxBackoff, p.BackoffMultiplier
+	if initial <= 0 {
+		initial = DefaultInitialBackoff
+	}
+	if max <= 0 {
+		max = DefaultMaxBackoff
+	}
+	if mult < 1 {
+		mult = DefaultBackoffMultiplier
+	}
+	d := float64(initial)
+	for i := 1; i < attempt && d < float64(max); i++ {
+		d *= mult
+	}
+	if d > float64(max) {
+		d = float64(max)
+	}
+	return time.Duration(rand.Int63n(int64(d) + 1))
+}
+
+// Do calls call until it succeeds, fails with a non-retryable error, the
+// policy's attempts are used up or ctx is done. It returns the last error.
+func (p *Policy) Do(ctx context.Context, call func(context.Context) error) error {
+	attempts := p.MaxAttempts
+	if attempts <= 0 {
+		attempts = DefaultMaxAttempts
+	}
+	var err error
+	for attempt := 1; ; attempt++ {
+		err = call(ctx)
+		if attempt >= attempts || !p.Retryable(err) {
+			return err
+		}
+		t := time.NewTimer(p.Backoff(attempt))
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return err
+		case <-t.C:
+		}
+	}
+}
@@ -0,0 +1,91 @@
+// Package roundtrip is the runtime support for the test suites generated
+// by protoc-gen-go-roundtrip. JSON and Binary check that a message
+// survives a round trip through its encodings unchanged, and the values
+// below fill the corner-case fixtures of the suites.
+package roundtrip
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+)
+
+// Unicode is the text of the string and bytes fields of the unicode
+// fixtures: accented, combining, non-Latin and astral-plane characters,
+// and characters JSON escapes.
+const Unicode = "Grüße, ñandú e\u0301 — 世界 Привет مرحبا 🚀👩‍💻 \"\\\t\u2028<&>"
+
+// Long is the text of the string and bytes fields of the max values
+// fixtures.
+var Long = strings.Repeat("0123456789abcdef", 1<<8)
+
+// Depth is the depth the deep nesting fixtures nest messages to.
+var Depth = 64
+
+// Ptr returns a pointer to v, for the optional fields of the fixtures.
+func Ptr[T any](v T) *T {
+	return &v
+}
+
+// JSON checks that m encodes to JSON that decodes, to a message of the type
+// newMsg returns, equal to m and with the same JSON encoding.
+func JSON(t testing.TB, m proto.Message, newMsg func() proto.Message) {
+	t.Helper()
+	var marshaler jsonpb.Marshaler
+	first, err := marshaler.MarshalToString(m)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	decoded := newMsg()
+	if err := jsonpb.UnmarshalString(first, decoded); err != nil {
+		t.Fatalf("unmarshal %s: %v", first, err)
+	}
+	if !proto.Equal(m, decoded) {
+		t.Fatalf("message changed on a JSON round trip through %s:\n%v\n%v", first, m, decoded)
+	}
+	second, err := marshaler.MarshalToString(decoded)
+	if err != nil {
+		t.Fatalf("marshal %s: %v", first, err)
+	}
+	if first != second {
+		t.Fatalf("JSON changed on a round trip:\n%s\n%s", first, second)
+	}
+}
+
+// Binary checks that m encodes to bytes that decode, to a message of the
+// type newMsg returns, equal to m and with the same deterministic binary
+// encoding.
+func Binary(t testing.TB, m proto.Message, newMsg func() proto.Message) {
+	t.Helper()
+	first, err := marshal(m)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	decoded := newMsg()
+	if err := proto.Unmarshal(first, decoded); err != nil {
+		t.Fatalf("unmarshal %x: %v", first, err)
+	}
+	if !proto.Equal(m, decoded) {
+		t.Fatalf("message changed on a binary round trip:\n%v\n%v", m, decoded)
+	}
+	second, err := marshal(decoded)
+	if err != nil {
+		t.Fatalf("marshal %v: %v", decoded, err)
+	}
+	if !bytes.Equal(first, second) {
+		t.Fatalf("binary encoding changed on a round trip:\n%x\n%x", first, second)
+	}
+}
+
+// marshal returns the deterministic binary encoding of m.
+func marshal(m proto.Message) ([]byte, error) {
+	var buf proto.Buffer
+	buf.SetDeterministic(true)
+	if err := buf.Marshal(m); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
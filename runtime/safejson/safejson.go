@@ -0,0 +1,218 @@
+// Package safejson is the runtime support for code generated by
+// protoc-gen-go-jsonsafe. It writes messages in the proto3 JSON mapping
+// with their sensitive fields masked, without copying or modifying them.
+package safejson
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+
+	"github.com/f4tq/protoc-go-plugins/runtime/redact"
+)
+
+// Encoder accumulates the JSON encoding of a message. The zero value is
+// ready to use.
+type Encoder struct {
+	b bytes.Buffer
+	// sep records that a comma goes before the next member or item.
+	sep bool
+	// err is the first error met by Proto.
+	err error
+}
+
+// Result returns the JSON written so far, or the first error met.
+func (e *Encoder) Result() ([]byte, error) {
+	if e.err != nil {
+		return nil, e.err
+	}
+	return e.b.Bytes(), nil
+}
+
+// Begin starts an object.
+func (e *Encoder) Begin() {
+	e.b.WriteByte('{')
+	e.sep = false
+}
+
+// End ends the object started by Begin.
+func (e *Encoder) End() {
+	e.b.WriteByte('}')
+	e.sep = true
+}
+
+// Null writes a nil message.
+func (e *Encoder) Null() {
+	e.b.WriteString("null")
+	e.sep = true
+}
+
+// Field starts the member name; its value is written next.
+func (e *Encoder) Field(name string) {
+	if e.sep {
+		e.b.WriteByte(',')
+	}
+	writeString(&e.b, name)
+	e.b.WriteByte(':')
+	e.sep = false
+}
+
+// String writes s.
+func (e *Encoder) String(s string) {
+	writeString(&e.b, s)
+	e.sep = true
+}
+
+// Base64 writes b in standard base64, as the JSON mapping encodes bytes.
+func (e *Encoder) Base64(b []byte) {
+	e.String(base64.StdEncoding.EncodeToString(b))
+}
+
+// Bool writes v.
+func (e *Encoder) Bool(v bool) {
+	e.b.WriteString(strconv.FormatBool(v))
+	e.sep = true
+}
+
+// Int writes a 32-bit integer.
+func (e *Encoder) Int(v int64) {
+	e.b.WriteString(strconv.FormatInt(v, 10))
+	e.sep = true
+}
+
+// Uint writes a 32-bit unsigned integer.
+func (e *Encoder) Uint(v uint64) {
+	e.b.WriteString(strconv.FormatUint(v, 10))
+	e.sep = true
+}
+
+// Int64 writes a 64-bit integer as a string, as the JSON mapping does.
+func (e *Encoder) Int64(v int64) {
+	e.String(strconv.FormatInt(v, 10))
+}
+
+// Uint64 writes a 64-bit unsigned integer as a string.
+func (e *Encoder) Uint64(v uint64) {
+	e.String(strconv.FormatUint(v, 10))
+}
+
+// Float writes a float of the given bit size. NaN and the infinities are
+// written as the strings the JSON mapping uses for them.
+func (e *Encoder) Float(v float64, bits int) {
+	switch {
+	case math.IsNaN(v):
+		e.String("NaN")
+	case math.IsInf(v, 1):
+		e.String("Infinity")
+	case math.IsInf(v, -1):
+		e.String("-Infinity")
+	default:
+		e.b.WriteString(strconv.FormatFloat(v, 'g', -1, bits))
+		e.sep = true
+	}
+}
+
+// Redacted writes redact.Mask in place of a sensitive value.
+func (e *Encoder) Redacted() {
+	e.String(redact.Mask)
+}
+
+// Proto writes m, a message without a generated MarshalJSONSafe, with
+// jsonpb. Its fields are not masked.
+func (e *Encoder) Proto(m proto.Message) {
+	s, err := new(jsonpb.Marshaler).MarshalToString(m)
+	if err != nil {
+		if e.err == nil {
+			e.err = err
+		}
+		s = "null"
+	}
+	e.b.WriteString(s)
+	e.sep = true
+}
+
+// List writes the repeated field s as an array, each item written by f.
+func List[T any](e *Encoder, s []T, f func(*Encoder, T)) {
+	e.b.WriteByte('[')
+	e.sep = false
+	for _, v := range s {
+		if e.sep {
+			e.b.WriteByte(',')
+		}
+		f(e, v)
+	}
+	e.b.WriteByte(']')
+	e.sep = true
+}
+
+// Map writes the map field m as an object, each value written by f, in key
+// order as jsonpb does.
+func Map[K comparable, V any](e *Encoder, m map[K]V, f func(*Encoder, V)) {
+	keys := make([]K, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return less(keys[i], keys[j]) })
+	e.Begin()
+	for _, k := range keys {
+		e.Field(fmt.Sprint(k))
+		f(e, m[k])
+	}
+	e.End()
+}
+
+// less orders map keys, numbers by value and the rest by their text.
+func less(a, b interface{}) bool {
+	switch a := a.(type) {
+	case int32:
+		return a < b.(int32)
+	case int64:
+		return a < b.(int64)
+	case uint32:
+		return a < b.(uint32)
+	case uint64:
+		return a < b.(uint64)
+	case bool:
+		return !a && b.(bool)
+	}
+	return fmt.Sprint(a) < fmt.Sprint(b)
+}
+
+// writeString writes s as a JSON string. Unlike encoding/json it leaves
+// <, > and & alone, as jsonpb does.
+func writeString(w *bytes.Buffer, s string) {
+	const hex = "0123456789abcdef"
+	w.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			w.WriteString(`\"`)
+		case '\\':
+			w.WriteString(`\\`)
+		case '\n':
+			w.WriteString(`\n`)
+		case '\r':
+			w.WriteString(`\r`)
+		case '\t':
+			w.WriteString(`\t`)
+		case '\u2028', '\u2029':
+			w.WriteString(`\u202`)
+			w.WriteByte(hex[r&0xf])
+		default:
+			if r < 0x20 {
+				w.WriteString(`\u00`)
+				w.WriteByte(hex[r>>4])
+				w.WriteByte(hex[r&0xf])
+			} else {
+				w.WriteRune(r)
+			}
+		}
+	}
+	w.WriteByte('"')
+}
@@ -0,0 +1,92 @@
+// Package scalarparse parses the text forms of proto scalars, enums and
+// durations, as written in the command-line flags bound by
+// protoc-gen-go-flags and the environment variables read by
+// protoc-gen-go-env.
+package scalarparse
+
+import (
+	"encoding/base64"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/duration"
+)
+
+// String returns s.
+func String(s string) (string, error) {
+	return s, nil
+}
+
+// Bool parses a bool as strconv.ParseBool does.
+func Bool(s string) (bool, error) {
+	return strconv.ParseBool(s)
+}
+
+// Int32 parses a decimal, or 0x-prefixed hexadecimal, int32.
+func Int32(s string) (int32, error) {
+	n, err := strconv.ParseInt(s, 0, 32)
+	return int32(n), err
+}
+
+// Int64 parses an int64 as Int32 does.
+func Int64(s string) (int64, error) {
+	return strconv.ParseInt(s, 0, 64)
+}
+
+// Uint32 parses a uint32 as Int32 does.
+func Uint32(s string) (uint32, error) {
+	n, err := strconv.ParseUint(s, 0, 32)
+	return uint32(n), err
+}
+
+// Uint64 parses a uint64 as Int32 does.
+func Uint64(s string) (uint64, error) {
+	return strconv.ParseUint(s, 0, 64)
+}
+
+// Float32 parses a float as strconv.ParseFloat does.
+func Float32(s string) (float32, error) {
+	f, err := strconv.ParseFloat(s, 32)
+	return float32(f), err
+}
+
+// Float64 parses a double as strconv.ParseFloat does.
+func Float64(s string) (float64, error) {
+	return strconv.ParseFloat(s, 64)
+}
+
+// Bytes decodes bytes written in standard base64.
+func Bytes(s string) ([]byte, error) {
+	return base64.StdEncoding.DecodeString(s)
+}
+
+// Duration parses a google.protobuf.Duration written as a Go duration,
+// such as "1m30s".
+func Duration(s string) (*duration.Duration, error) {
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return nil, err
+	}
+	return ptypes.DurationProto(d), nil
+}
+
+// Enum returns the function parsing the value names of an enum, which
+// values maps to their numbers as the <Enum>_value map of protoc-gen-go.
+func Enum[T ~int32](values map[string]int32) func(string) (T, error) {
+	return func(s string) (T, error) {
+		n, ok := values[s]
+		if !ok {
+			names := make([]string, 0, len(values))
+			for name := range values {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			return 0, fmt.Errorf("unknown value %q, want one of %s", s, strings.Join(names, ", "))
+		}
+		return T(n), nil
+	}
+}
@@ -0,0 +1,164 @@
+// Package schemaregistry is the runtime support for code generated by
+// protoc-gen-go-schemaregistry. It talks to a Confluent Schema Registry
+// over its REST API and implements the Confluent protobuf wire format.
+package schemaregistry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+const contentType = "application/vnd.schemaregistry.v1+json"
+
+// Schema is a protobuf schema as registered with the registry.
+type Schema struct {
+	// Schema is the .proto source of the schema.
+	Schema string
+	// References lists the imports of the schema that are registered
+	// under their own subjects.
+	References []Reference
+}
+
+// Reference names a registered schema imported by another schema.
+type Reference struct {
+	// Name is the import path used in the importing schema.
+	Name string `json:"name"`
+	// Subject the imported schema is registered under.
+	Subject string `json:"subject"`
+	// Version of the subject; zero resolves the latest version at
+	// registration time.
+	Version int `json:"version"`
+	// Schema, if set, is registered under Subject before the reference
+	// is resolved.
+	Schema *Schema `json:"-"`
+}
+
+// Client is a minimal Confluent Schema Registry client. Registered schema
+// IDs are cached per subject.
+type Client struct {
+	// URL of the registry, e.g. "http://localhost:8081".
+	URL string
+	// Username and Password enable HTTP basic authentication when set.
+	Username string
+	Password string
+	// HTTPClient is used for requests; http.DefaultClient if nil.
+	HTTPClient *http.Client
+
+	mu  sync.Mutex
+	ids map[string]int
+}
+
+// NewClient returns a Client for the registry at url.
+func NewClient(url string) *Client {
+	return &Client{URL: strings.TrimSuffix(url, "/")}
+}
+
+// Register registers schema under subject, returning its schema ID. The
+// registry returns the existing ID if the schema is already registered.
+func (c *Client) Register(ctx context.Context, subject string, schema Schema) (int, error) {
+	c.mu.Lock()
+	id, ok := c.ids[subject]
+	c.mu.Unlock()
+	if ok {
+		return id, nil
+	}
+
+	refs := make([]Reference, len(schema.References))
+	for i, ref := range schema.References {
+		if ref.Schema != nil {
+			if _, err := c.Register(ctx, ref.Subject, *ref.Schema); err != nil {
+				return 0, err
+			}
+		}
+		if ref.Version == 0 {
+			v, err := c.LatestVersion(ctx, ref.Subject)
+			if err != nil {
+				return 0, err
+			}
+			ref.Version = v
+		}
+		refs[i] = ref
+	}
+	body := struct {
+		Schema     string      `json:"schema"`
+		SchemaType string      `json:"schemaType"`
+		References []Reference `json:"references,omitempty"`
+	}{schema.Schema, "PROTOBUF", refs}
+	var resp struct {
+		ID int `json:"id"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/subjects/"+url.PathEscape(subject)+"/versions", body, &resp); err != nil {
+		return 0, err
+	}
+
+	c.mu.Lock()
+	if c.ids == nil {
+		c.ids = make(map[string]int)
+	}
+	c.ids[subject] = resp.ID
+	c.mu.Unlock()
+	return resp.ID, nil
+}
+
+// LatestVersion returns the latest registered version of subject.
+func (c *Client) LatestVersion(ctx context.Context, subject string) (int, error) {
+	var resp struct {
+		Version int `json:"version"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/subjects/"+url.PathEscape(subject)+"/versions/latest", nil, &resp); err != nil {
+		return 0, err
+	}
+	return resp.Version, nil
+}
+
+func (c *Client) do(ctx context.Context, method, path string, in, out interface{}) error {
+	var body bytes.Buffer
+	if in != nil {
+		if err := json.NewEncoder(&body).Encode(in); err != nil {
+			return err
+		}
+	}
+	req, err := http.NewRequest(method, c.URL+path, &body)
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Accept", contentType)
+	if in != nil {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if c.Username != "" {
+		req.SetBasicAuth(c.Username, c.Password)
+	}
+	hc := c.HTTPClient
+	if hc == nil {
+		hc = http.DefaultClient
+	}
+	resp, err := hc.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode/100 != 2 {
+		var e struct {
+			Code    int    `json:"error_code"`
+			Message string `json:"message"`
+		}
+		if json.Unmarshal(data, &e) == nil && e.Message != "" {
+			return fmt.Errorf("schemaregistry: %s %s: %s (%d)", method, path, e.Message, e.Code)
+		}
+		return fmt.Errorf("schemaregistry: %s %s: %s", method, path, resp.Status)
+	}
+	return json.Unmarshal(data, out)
+}
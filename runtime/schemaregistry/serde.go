@@ -0,0 +1,133 @@
+package schemaregistry
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/golang/protobuf/proto"
+)
+
+// magicByte prefixes every payload in the Confluent wire format.
+const magicByte = 0
+
+// ErrInvalidPayload is returned when a payload is not in the Confluent
+// wire format.
+var ErrInvalidPayload = errors.New("schemaregistry: invalid payload")
+
+// Subject name strategies, selected per message with the
+// (f4tq.plugins.subject_name_strategy) option.
+const (
+	TopicNameStrategy       = "topic"
+	RecordNameStrategy      = "record"
+	TopicRecordNameStrategy = "topic_record"
+)
+
+// SubjectName returns the subject a record named recordName is registered
+// under when written to topic using strategy. An empty strategy selects
+// TopicNameStrategy.
+func SubjectName(strategy, topic, recordName string, isKey bool) (string, error) {
+	suffix := "-value"
+	if isKey {
+		suffix = "-key"
+	}
+	switch strategy {
+	case "", TopicNameStrategy:
+		if topic == "" {
+			return "", errors.New("schemaregistry: topic name strategy requires a topic")
+		}
+		return topic + suffix, nil
+	case RecordNameStrategy:
+		return recordName, nil
+	case TopicRecordNameStrategy:
+		if topic == "" {
+			return "", errors.New("schemaregistry: topic record name strategy requires a topic")
+		}
+		return topic + "-" + recordName, nil
+	}
+	return "", fmt.Errorf("schemaregistry: unknown subject name strategy %q", strategy)
+}
+
+// Encode frames payload with the schema ID and the message indexes locating
+// the message type within its schema.
+func Encode(schemaID int, indexes []int, payload []byte) []byte {
+	buf := make([]byte, 5, 5+binary.MaxVarintLen64*(len(indexes)+1)+len(payload))
+	buf[0] = magicByte
+	binary.BigEndian.PutUint32(buf[1:5], uint32(schemaID))
+	var tmp [binary.MaxVarintLen64]byte
+	if len(indexes) == 1 && indexes[0] == 0 {
+		// The common case of the first message type is a single zero.
+		buf = append(buf, 0)
+	} else {
+		buf = append(buf, tmp[:binary.PutVarint(tmp[:], int64(len(indexes)))]...)
+		for _, i := range indexes {
+			buf = append(buf, tmp[:binary.PutVarint(tmp[:], int64(i))]...)
+		}
+	}
+	return append(buf, payload...)
+}
+
+// Decode splits a framed payload into its schema ID, message indexes and
+// protobuf payload.
+func Decode(b []byte) (schemaID int, indexes []int, payload []byte, err error) {
+	if len(b) < 6 || b[0] != magicByte {
+		return 0, nil, nil, ErrInvalidPayload
+	}
+	schemaID = int(binary.BigEndian.Uint32(b[1:5]))
+	b = b[5:]
+	n, l := binary.Varint(b)
+	if l <= 0 || n < 0 {
+		return 0, nil, nil, ErrInvalidPayload
+	}
+	b = b[l:]
+	if n == 0 {
+		return schemaID, []int{0}, b, nil
+	}
+	indexes = make([]int, n)
+	for i := range indexes {
+		v, l := binary.Varint(b)
+		if l <= 0 {
+			return 0, nil, nil, ErrInvalidPayload
+		}
+		indexes[i] = int(v)
+		b = b[l:]
+	}
+	return schemaID, indexes, b, nil
+}
+
+// Serializer encodes messages of one type for one subject, registering
+// their schema on first use.
+type Serializer struct {
+	Client  *Client
+	Subject string
+	Schema  Schema
+	// Indexes locate the message type within Schema.
+	Indexes []int
+}
+
+// Serialize marshals msg and frames it with its schema ID.
+func (s *Serializer) Serialize(ctx context.Context, msg proto.Message) ([]byte, error) {
+	id, err := s.Client.Register(ctx, s.Subject, s.Schema)
+	if err != nil {
+		return nil, err
+	}
+	payload, err := proto.Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+	return Encode(id, s.Indexes, payload), nil
+}
+
+// Deserialize unmarshals a framed payload into msg and returns the schema
+// ID it was written with.
+func Deserialize(b []byte, msg proto.Message) (int, error) {
+	id, _, payload, err := Decode(b)
+	if err != nil {
+		return 0, err
+	}
+	if err := proto.Unmarshal(payload, msg); err != nil {
+		return 0, err
+	}
+	return id, nil
+}
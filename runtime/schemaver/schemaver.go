@@ -0,0 +1,100 @@
+// Package schemaver is the runtime support for code generated by
+// protoc-gen-go-schemaversion. It parses the semantic versions set by the
+// (f4tq.plugins.schema_version) option and decides whether two peers can
+// exchange messages.
+package schemaver
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Version is a semantic version, "MAJOR.MINOR.PATCH" with an optional
+// "-PRERELEASE". Build metadata is dropped by Parse.
+type Version struct {
+	Major, Minor, Patch int
+	Pre                 string
+}
+
+// Parse parses s, with or without a leading "v".
+func Parse(s string) (Version, error) {
+	var v Version
+	rest := strings.TrimPrefix(s, "v")
+	if i := strings.IndexByte(rest, '+'); i >= 0 {
+		rest = rest[:i]
+	}
+	if i := strings.IndexByte(rest, '-'); i >= 0 {
+		rest, v.Pre = rest[:i], rest[i+1:]
+		if v.Pre == "" {
+			return Version{}, fmt.Errorf("schemaver: invalid version %q: empty pre-release", s)
+		}
+	}
+	parts := strings.Split(rest, ".")
+	if len(parts) != 3 {
+		return Version{}, fmt.Errorf("schemaver: invalid version %q: want MAJOR.MINOR.PATCH", s)
+	}
+	for i, dst := range []*int{&v.Major, &v.Minor, &v.Patch} {
+		n, err := strconv.Atoi(parts[i])
+		if err != nil || n < 0 || (len(parts[i]) > 1 && parts[i][0] == '0') {
+			return Version{}, fmt.Errorf("schemaver: invalid version %q: bad number %q", s, parts[i])
+		}
+		*dst = n
+	}
+	return v, nil
+}
+
+// String returns v in the form Parse accepts, without the "v".
+func (v Version) String() string {
+	s := fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+	if v.Pre != "" {
+		s += "-" + v.Pre
+	}
+	return s
+}
+
+// IncompatibleError is returned by Check for versions that cannot
+// exchange messages.
+type IncompatibleError struct {
+	Local, Remote Version
+	// Reason is the rule the versions break.
+	Reason string
+}
+
+func (e *IncompatibleError) Error() string {
+	return fmt.Sprintf("schemaver: remote schema %s is incompatible with local %s: %s", e.Remote, e.Local, e.Reason)
+}
+
+// Check returns nil if a peer at schema version remote can exchange
+// messages with us at local. Our rules, stricter than semver's for
+// pre-releases:
+//
+//   - the majors must match, a major bump being a breaking change;
+//   - below 1.0.0 the minors must match too, as any minor may break;
+//   - a pre-release only matches the very same version;
+//   - minors and patches may differ otherwise, the changes they bring
+//     being additive and unknown fields preserved.
+//
+// It returns a *IncompatibleError, or the error of Parse.
+func Check(local, remote string) error {
+	l, err := Parse(local)
+	if err != nil {
+		return err
+	}
+	r, err := Parse(remote)
+	if err != nil {
+		return err
+	}
+	var reason string
+	switch {
+	case l.Major != r.Major:
+		reason = "major versions differ"
+	case l.Major == 0 && l.Minor != r.Minor:
+		reason = "minor versions differ before 1.0.0"
+	case (l.Pre != "" || r.Pre != "") && l != r:
+		reason = "pre-releases only match themselves"
+	default:
+		return nil
+	}
+	return &IncompatibleError{Local: l, Remote: r, Reason: reason}
+}
@@ -0,0 +1,243 @@
+// Package servergroup is the runtime support for code generated by
+// protoc-gen-go-servergroup. A Group serves several gRPC services, and
+// optionally their HTTP handlers, from one binary: it chains shared
+// interceptors, runs startup and shutdown hooks in order and lets each
+// service be turned off with a flag.
+package servergroup
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+// DefaultShutdownTimeout bounds the graceful shutdown of the servers.
+const DefaultShutdownTimeout = 30 * time.Second
+
+// Starter is implemented by service implementations that must be started
+// before the servers accept calls.
+type Starter interface {
+	Start(ctx context.Context) error
+}
+
+// Stopper is implemented by service implementations that must be stopped
+// after the servers have shut down.
+type Stopper interface {
+	Stop(ctx context.Context) error
+}
+
+// Hook is a named startup and shutdown step. Either function may be nil.
+type Hook struct {
+	Name  string
+	Start func(ctx context.Context) error
+	Stop  func(ctx context.Context) error
+}
+
+// Service is a gRPC service in a Group.
+type Service struct {
+	// Name is the full service name, e.g. "pkg.Service".
+	Name string
+	// Flag names the service in the -enable-<Flag> flag.
+	Flag string
+	// Enabled reports whether the service is served; true when added.
+	Enabled bool
+	// Impl is the implementation. If it is a Starter or a Stopper, it is
+	// started or stopped along with the hooks.
+	Impl interface{}
+	// RegisterGRPC registers Impl on s.
+	RegisterGRPC func(s *grpc.Server)
+	// RegisterHTTP, if set, registers HTTP handlers for Impl on mux.
+	RegisterHTTP func(mux *http.ServeMux)
+}
+
+// Group is a set of services and hooks served together. Services and hooks
+// are started in the order they were added and stopped in reverse order.
+type Group struct {
+	// GRPCAddr is the address of the gRPC server.
+	GRPCAddr string
+	// HTTPAddr, if set, is the address of the HTTP server serving the
+	// handlers of the enabled services.
+	HTTPAddr string
+	// ServerOptions are passed to grpc.NewServer.
+	ServerOptions []grpc.ServerOption
+	// UnaryInterceptors and StreamInterceptors are chained in order for
+	// every service.
+	UnaryInterceptors  []grpc.UnaryServerInterceptor
+	StreamInterceptors []grpc.StreamServerInterceptor
+	// ShutdownTimeout bounds the graceful shutdown of the servers;
+	// DefaultShutdownTimeout if zero. Calls still open are then canceled.
+	ShutdownTimeout time.Duration
+
+	steps []step
+}
+
+// step is a Service or a Hook, kept in the order they were added.
+type step struct {
+	service *Service
+	hook    *Hook
+}
+
+// Add adds s to g, enabled, and returns it.
+func (g *Group) Add(s *Service) *Service {
+	s.Enabled = true
+	g.steps = append(g.steps, step{service: s})
+	return s
+}
+
+// AddHook adds h to g.
+func (g *Group) AddHook(h Hook) {
+	g.steps = append(g.steps, step{hook: &h})
+}
+
+// Services returns the services of g in the order they were added.
+func (g *Group) Services() []*Service {
+	var services []*Service
+	for _, st := range g.steps {
+		if st.service != nil {
+			services = append(services, st.service)
+		}
+	}
+	return services
+}
+
+// RegisterFlags defines an -enable-<Flag> flag on fs for each service
+// added so far.
+func (g *Group) RegisterFlags(fs *flag.FlagSet) {
+	for _, s := range g.Services() {
+		fs.BoolVar(&s.Enabled, "enable-"+s.Flag, s.Enabled, "serve "+s.Name)
+	}
+}
+
+// hooks returns the hooks of the enabled services and of g, in order.
+func (g *Group) hooks() []Hook {
+	var hooks []Hook
+	for _, st := range g.steps {
+		if st.hook != nil {
+			hooks = append(hooks, *st.hook)
+			continue
+		}
+		s := st.service
+		if !s.Enabled {
+			continue
+		}
+		h := Hook{Name: s.Name}
+		if starter, ok := s.Impl.(Starter); ok {
+			h.Start = starter.Start
+		}
+		if stopper, ok := s.Impl.(Stopper); ok {
+			h.Stop = stopper.Stop
+		}
+		hooks = append(hooks, h)
+	}
+	return hooks
+}
+
+// Run starts the hooks, serves the enabled services until ctx is done or
+// a server fails, then shuts the servers down and stops the hooks. If a
+// hook fails to start, the hooks started before it are stopped and its
+// error is returned.
+func (g *Group) Run(ctx context.Context) error {
+	var services []*Service
+	for _, s := range g.Services() {
+		if s.Enabled {
+			services = append(services, s)
+		}
+	}
+	if len(services) == 0 {
+		return errors.New("servergroup: no service enabled")
+	}
+
+	opts := append([]grpc.ServerOption(nil), g.ServerOptions...)
+	if len(g.UnaryInterceptors) > 0 {
+		opts = append(opts, grpc.ChainUnaryInterceptor(g.UnaryInterceptors...))
+	}
+	if len(g.StreamInterceptors) > 0 {
+		opts = append(opts, grpc.ChainStreamInterceptor(g.StreamInterceptors...))
+	}
+	gs := grpc.NewServer(opts...)
+	mux := http.NewServeMux()
+	for _, s := range services {
+		s.RegisterGRPC(gs)
+		if s.RegisterHTTP != nil {
+			s.RegisterHTTP(mux)
+		}
+	}
+
+	hooks := g.hooks()
+	for i, h := range hooks {
+		if h.Start == nil {
+			continue
+		}
+		if err := h.Start(ctx); err != nil {
+			stopHooks(context.WithoutCancel(ctx), hooks[:i])
+			return fmt.Errorf("servergroup: starting %s: %w", h.Name, err)
+		}
+	}
+
+	errc := make(chan error, 2)
+	lis, err := net.Listen("tcp", g.GRPCAddr)
+	if err != nil {
+		stopHooks(context.WithoutCancel(ctx), hooks)
+		return err
+	}
+	go func() { errc <- gs.Serve(lis) }()
+	var hs *http.Server
+	if g.HTTPAddr != "" {
+		hs = &http.Server{Addr: g.HTTPAddr, Handler: mux}
+		go func() {
+			if err := hs.ListenAndServe(); err != http.ErrServerClosed {
+				errc <- err
+			}
+		}()
+	}
+
+	select {
+	case <-ctx.Done():
+	case err = <-errc:
+	}
+
+	timeout := g.ShutdownTimeout
+	if timeout <= 0 {
+		timeout = DefaultShutdownTimeout
+	}
+	sctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), timeout)
+	defer cancel()
+	if hs != nil {
+		hs.Shutdown(sctx)
+	}
+	stopped := make(chan struct{})
+	go func() {
+		gs.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-sctx.Done():
+		gs.Stop()
+	}
+	if serr := stopHooks(sctx, hooks); err == nil {
+		err = serr
+	}
+	return err
+}
+
+// stopHooks stops hooks in reverse order and returns the first error.
+func stopHooks(ctx context.Context, hooks []Hook) error {
+	var first error
+	for i := len(hooks) - 1; i >= 0; i-- {
+		h := hooks[i]
+		if h.Stop == nil {
+			continue
+		}
+		if err := h.Stop(ctx); err != nil && first == nil {
+			first = fmt.Errorf("servergroup: stopping %s: %w", h.Name, err)
+		}
+	}
+	return first
+}
@@ -0,0 +1,68 @@
+// Package signing is the runtime support for code generated by
+// protoc-gen-go-sign. Messages are signed over their canonical encoding,
+// see package canonical, without their signature field.
+package signing
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
+)
+
+// ErrBadSignature is returned by Verify for a missing or wrong signature.
+var ErrBadSignature = errors.New("signing: bad signature")
+
+// Signer signs messages.
+type Signer interface {
+	Sign(msg []byte) ([]byte, error)
+}
+
+// Verifier checks the signatures of messages.
+type Verifier interface {
+	// Verify returns ErrBadSignature if sig is not a signature of msg.
+	Verify(msg, sig []byte) error
+}
+
+// HMACKey is a secret that both signs and verifies, with HMAC-SHA256.
+type HMACKey []byte
+
+// Sign returns the HMAC-SHA256 of msg under k.
+func (k HMACKey) Sign(msg []byte) ([]byte, error) {
+	mac := hmac.New(sha256.New, k)
+	mac.Write(msg)
+	return mac.Sum(nil), nil
+}
+
+// Verify checks in constant time that sig is the HMAC-SHA256 of msg under
+// k.
+func (k HMACKey) Verify(msg, sig []byte) error {
+	want, _ := k.Sign(msg)
+	if !hmac.Equal(sig, want) {
+		return ErrBadSignature
+	}
+	return nil
+}
+
+// Ed25519PrivateKey signs with Ed25519.
+type Ed25519PrivateKey ed25519.PrivateKey
+
+// Sign returns the Ed25519 signature of msg by k.
+func (k Ed25519PrivateKey) Sign(msg []byte) ([]byte, error) {
+	if len(k) != ed25519.PrivateKeySize {
+		return nil, errors.New("signing: bad Ed25519 private key length")
+	}
+	return ed25519.Sign(ed25519.PrivateKey(k), msg), nil
+}
+
+// Ed25519PublicKey verifies Ed25519 signatures.
+type Ed25519PublicKey ed25519.PublicKey
+
+// Verify checks that sig is the Ed25519 signature of msg by the private
+// key of k.
+func (k Ed25519PublicKey) Verify(msg, sig []byte) error {
+	if len(k) != ed25519.PublicKeySize || !ed25519.Verify(ed25519.PublicKey(k), msg, sig) {
+		return ErrBadSignature
+	}
+	return nil
+}
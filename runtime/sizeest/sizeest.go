@@ -0,0 +1,85 @@
+// Package sizeest is the runtime support for code generated by
+// protoc-gen-go-size.
+package sizeest
+
+import (
+	"math"
+	"math/bits"
+	"strconv"
+
+	"github.com/golang/protobuf/proto"
+)
+
+// Size is the estimated encoded size of a message.
+type Size struct {
+	// Wire is the size of the binary encoding.
+	Wire int
+	// JSON is the approximate size of the JSON encoding; string escapes
+	// and the messages without a generated EstimateSize are guessed.
+	JSON int
+}
+
+// Varint returns the size of v encoded as a varint.
+func Varint(v uint64) int {
+	return 1 + (bits.Len64(v|1)-1)/7
+}
+
+// Zigzag returns the size of v encoded as a zigzag varint, as sint32 and
+// sint64 fields are.
+func Zigzag(v int64) int {
+	return Varint(uint64(v<<1) ^ uint64(v>>63))
+}
+
+// Int returns the length of v in decimal.
+func Int(v int64) int {
+	if v < 0 {
+		return 1 + Uint(uint64(-v))
+	}
+	return Uint(uint64(v))
+}
+
+// Uint returns the length of v in decimal.
+func Uint(v uint64) int {
+	n := 1
+	for v >= 10 {
+		v /= 10
+		n++
+	}
+	return n
+}
+
+// Float returns the length of v in JSON: the shortest decimal that reads
+// back as v, or the quoted name of a NaN or an infinity.
+func Float(v float64) int {
+	switch {
+	case math.IsNaN(v):
+		return len(`"NaN"`)
+	case math.IsInf(v, 1):
+		return len(`"Infinity"`)
+	case math.IsInf(v, -1):
+		return len(`"-Infinity"`)
+	}
+	var buf [32]byte
+	return len(strconv.AppendFloat(buf[:0], v, 'g', -1, 64))
+}
+
+// Bool returns the length of v in JSON.
+func Bool(v bool) int {
+	if v {
+		return len("true")
+	}
+	return len("false")
+}
+
+// Base64 returns the length of n bytes in quoted standard base64, as JSON
+// encodes bytes fields.
+func Base64(n int) int {
+	return 2 + (n+2)/3*4
+}
+
+// Proto returns the size of m, a message without a generated
+// EstimateSize. Its JSON size is guessed as twice its wire size.
+func Proto(m proto.Message) Size {
+	n := proto.Size(m)
+	return Size{Wire: n, JSON: 2 + 2*n}
+}
@@ -0,0 +1,141 @@
+package sqspb
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/aws/aws-sdk-go/service/sqs/sqsiface"
+	"github.com/golang/protobuf/proto"
+)
+
+// HandlerFunc handles a decoded message. Message(ctx) returns the SQS
+// message it was decoded from.
+type HandlerFunc func(ctx context.Context, msg proto.Message) error
+
+type retryError struct {
+	err   error
+	after time.Duration
+}
+
+func (e *retryError) Error() string {
+	return e.err.Error()
+}
+
+func (e *retryError) Unwrap() error {
+	return e.err
+}
+
+// RetryAfter wraps err so that the failed message becomes visible again
+// after d rather than after the visibility timeout.
+func RetryAfter(err error, d time.Duration) error {
+	if err == nil {
+		return nil
+	}
+	return &retryError{err, d}
+}
+
+type messageKey struct{}
+
+// Message returns the SQS message being handled, or nil if ctx was not
+// passed to a handler.
+func Message(ctx context.Context) *sqs.Message {
+	m, _ := ctx.Value(messageKey{}).(*sqs.Message)
+	return m
+}
+
+// Poller long-polls an SQS queue. Messages are deleted once handled
+// successfully. Messages that fail, or cannot be decoded, are left on the
+// queue to be received again, and moved to the dead letter queue by the
+// queue's redrive policy once they reach its maxReceiveCount.
+type Poller struct {
+	Client   sqsiface.SQSAPI
+	QueueURL string
+	// MaxMessages is the number of messages received, and handled
+	// concurrently, at once; MaxBatchSize if zero.
+	MaxMessages int
+	// WaitTime is how long a receive waits for messages; 20s if zero.
+	WaitTime time.Duration
+	// VisibilityTimeout hides received messages from other pollers; the
+	// queue's default if zero.
+	VisibilityTimeout time.Duration
+}
+
+// NewPoller returns a Poller for the queue at queueURL.
+func NewPoller(client sqsiface.SQSAPI, queueURL string) *Poller {
+	return &Poller{Client: client, QueueURL: queueURL}
+}
+
+// Poll passes the messages of the queue to handle until ctx is done,
+// decoding each into a message returned by newMsg.
+func (p *Poller) Poll(ctx context.Context, newMsg func() proto.Message, handle HandlerFunc) error {
+	max := int64(p.MaxMessages)
+	if max <= 0 || max > MaxBatchSize {
+		max = MaxBatchSize
+	}
+	wait := p.WaitTime
+	if wait <= 0 {
+		wait = 20 * time.Second
+	}
+	in := &sqs.ReceiveMessageInput{
+		QueueUrl:              aws.String(p.QueueURL),
+		AttributeNames:        []*string{aws.String("ApproximateReceiveCount")},
+		MessageAttributeNames: []*string{aws.String("All")},
+		MaxNumberOfMessages:   aws.Int64(max),
+		WaitTimeSeconds:       aws.Int64(int64(wait / time.Second)),
+	}
+	if p.VisibilityTimeout > 0 {
+		in.VisibilityTimeout = aws.Int64(int64(p.VisibilityTimeout / time.Second))
+	}
+	for {
+		out, err := p.Client.ReceiveMessageWithContext(ctx, in)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		var wg sync.WaitGroup
+		for _, m := range out.Messages {
+			wg.Add(1)
+			go func(m *sqs.Message) {
+				defer wg.Done()
+				p.handle(ctx, m, newMsg, handle)
+			}(m)
+		}
+		wg.Wait()
+	}
+}
+
+// handle handles m and deletes it if it succeeded.
+func (p *Poller) handle(ctx context.Context, m *sqs.Message, newMsg func() proto.Message, handle HandlerFunc) {
+	body, contentType := unwrap(m)
+	msg := newMsg()
+	if err := decode(contentType, body, msg); err != nil {
+		ErrorHandler(m, err)
+		return
+	}
+	err := handle(context.WithValue(ctx, messageKey{}, m), msg)
+	var retry *retryError
+	switch {
+	case err == nil:
+		_, err = p.Client.DeleteMessageWithContext(ctx, &sqs.DeleteMessageInput{
+			QueueUrl:      aws.String(p.QueueURL),
+			ReceiptHandle: m.ReceiptHandle,
+		})
+	case errors.As(err, &retry):
+		_, err = p.Client.ChangeMessageVisibilityWithContext(ctx, &sqs.ChangeMessageVisibilityInput{
+			QueueUrl:          aws.String(p.QueueURL),
+			ReceiptHandle:     m.ReceiptHandle,
+			VisibilityTimeout: aws.Int64(int64(retry.after / time.Second)),
+		})
+	default:
+		return
+	}
+	if err != nil && ctx.Err() == nil {
+		ErrorHandler(m, err)
+	}
+}
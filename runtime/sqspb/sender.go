@@ -0,0 +1,181 @@
+package sqspb
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sns"
+	"github.com/aws/aws-sdk-go/service/sns/snsiface"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/aws/aws-sdk-go/service/sqs/sqsiface"
+	"github.com/golang/protobuf/proto"
+)
+
+// MaxBatchSize is the number of messages SQS accepts in one batch.
+const MaxBatchSize = 10
+
+// Sender sends messages to an SQS queue.
+type Sender struct {
+	Client   sqsiface.SQSAPI
+	QueueURL string
+	// ContentType encodes the bodies; ContentTypeProto if empty.
+	ContentType string
+}
+
+// NewSender returns a Sender sending protobuf-encoded messages to the
+// queue at queueURL.
+func NewSender(client sqsiface.SQSAPI, queueURL string) *Sender {
+	return &Sender{Client: client, QueueURL: queueURL}
+}
+
+// QueueURL returns the URL of the queue named name.
+func QueueURL(ctx context.Context, client sqsiface.SQSAPI, name string) (string, error) {
+	out, err := client.GetQueueUrlWithContext(ctx, &sqs.GetQueueUrlInput{QueueName: aws.String(name)})
+	if err != nil {
+		return "", err
+	}
+	return aws.StringValue(out.QueueUrl), nil
+}
+
+// fifo reports whether the queue or topic named by url or ARN is a FIFO
+// queue or topic, which requires a message group ID.
+func fifo(name string) bool {
+	return strings.HasSuffix(name, ".fifo")
+}
+
+// Send sends msg with attrs and returns its message ID. groupID is the
+// message group of FIFO queues and ignored by standard queues.
+func (s *Sender) Send(ctx context.Context, msg proto.Message, groupID string, attrs []Attribute) (string, error) {
+	body, contentType, err := encode(s.ContentType, msg)
+	if err != nil {
+		return "", err
+	}
+	in := &sqs.SendMessageInput{
+		QueueUrl:          aws.String(s.QueueURL),
+		MessageBody:       aws.String(body),
+		MessageAttributes: sqsAttributes(contentType, attrs),
+	}
+	if fifo(s.QueueURL) {
+		in.MessageGroupId = aws.String(groupID)
+	}
+	out, err := s.Client.SendMessageWithContext(ctx, in)
+	if err != nil {
+		return "", err
+	}
+	return aws.StringValue(out.MessageId), nil
+}
+
+// Entry is a message sent by SendBatch.
+type Entry struct {
+	Message    proto.Message
+	GroupID    string
+	Attributes []Attribute
+}
+
+// BatchFailure describes an entry SQS did not accept.
+type BatchFailure struct {
+	// Index is the position of the entry in the slice passed to
+	// SendBatch.
+	Index   int
+	Code    string
+	Message string
+	// SenderFault reports whether the entry itself was at fault, so that
+	// resending it will fail again.
+	SenderFault bool
+}
+
+// BatchError is returned by SendBatch when some entries were not accepted.
+type BatchError struct {
+	Failures []BatchFailure
+}
+
+func (e *BatchError) Error() string {
+	f := e.Failures[0]
+	return fmt.Sprintf("sqspb: %d messages not sent; entry %d: %s: %s", len(e.Failures), f.Index, f.Code, f.Message)
+}
+
+// SendBatch sends entries in batches of up to MaxBatchSize. Entries SQS
+// rejects are reported by a *BatchError; other errors abort the remaining
+// batches.
+func (s *Sender) SendBatch(ctx context.Context, entries []Entry) error {
+	var failures []BatchFailure
+	for start := 0; start < len(entries); start += MaxBatchSize {
+		end := start + MaxBatchSize
+		if end > len(entries) {
+			end = len(entries)
+		}
+		in := &sqs.SendMessageBatchInput{QueueUrl: aws.String(s.QueueURL)}
+		for i, e := range entries[start:end] {
+			body, contentType, err := encode(s.ContentType, e.Message)
+			if err != nil {
+				return fmt.Errorf("sqspb: entry %d: %v", start+i, err)
+			}
+			be := &sqs.SendMessageBatchRequestEntry{
+				Id:                aws.String(strconv.Itoa(start + i)),
+				MessageBody:       aws.String(body),
+				MessageAttributes: sqsAttributes(contentType, e.Attributes),
+			}
+			if fifo(s.QueueURL) {
+				be.MessageGroupId = aws.String(e.GroupID)
+			}
+			in.Entries = append(in.Entries, be)
+		}
+		out, err := s.Client.SendMessageBatchWithContext(ctx, in)
+		if err != nil {
+			return err
+		}
+		for _, f := range out.Failed {
+			idx, _ := strconv.Atoi(aws.StringValue(f.Id))
+			failures = append(failures, BatchFailure{
+				Index:       idx,
+				Code:        aws.StringValue(f.Code),
+				Message:     aws.StringValue(f.Message),
+				SenderFault: aws.BoolValue(f.SenderFault),
+			})
+		}
+	}
+	if len(failures) > 0 {
+		return &BatchError{Failures: failures}
+	}
+	return nil
+}
+
+// Publisher publishes messages to an SNS topic, typically fanned out to
+// SQS queues.
+type Publisher struct {
+	Client   snsiface.SNSAPI
+	TopicARN string
+	// ContentType encodes the messages; ContentTypeProto if empty.
+	ContentType string
+}
+
+// NewPublisher returns a Publisher publishing protobuf-encoded messages
+// to the topic topicARN.
+func NewPublisher(client snsiface.SNSAPI, topicARN string) *Publisher {
+	return &Publisher{Client: client, TopicARN: topicARN}
+}
+
+// Publish publishes msg with attrs and returns its message ID. groupID is
+// the message group of FIFO topics and ignored by standard topics.
+func (p *Publisher) Publish(ctx context.Context, msg proto.Message, groupID string, attrs []Attribute) (string, error) {
+	body, contentType, err := encode(p.ContentType, msg)
+	if err != nil {
+		return "", err
+	}
+	in := &sns.PublishInput{
+		TopicArn:          aws.String(p.TopicARN),
+		Message:           aws.String(body),
+		MessageAttributes: snsAttributes(contentType, attrs),
+	}
+	if fifo(p.TopicARN) {
+		in.MessageGroupId = aws.String(groupID)
+	}
+	out, err := p.Client.PublishWithContext(ctx, in)
+	if err != nil {
+		return "", err
+	}
+	return aws.StringValue(out.MessageId), nil
+}
@@ -0,0 +1,125 @@
+// Package sqspb is the runtime support for code generated by
+// protoc-gen-go-sqs. It sends protobuf messages to Amazon SQS queues,
+// publishes them to SNS topics and polls queues for them, leaving failed
+// messages on the queue so that its redrive policy moves them to a dead
+// letter queue.
+package sqspb
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sns"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+)
+
+// ContentTypeAttribute is the message attribute naming the encoding of the
+// body.
+const ContentTypeAttribute = "content-type"
+
+// Body encodings. SQS bodies must be text, so protobuf bodies are base64
+// encoded.
+const (
+	ContentTypeProto = "application/x-protobuf"
+	ContentTypeJSON  = "application/json"
+)
+
+// ErrorHandler is called with messages that cannot be decoded, which are
+// left on the queue for its redrive policy. It logs them by default.
+var ErrorHandler = func(m *sqs.Message, err error) {
+	log.Printf("sqspb: message %s: %v", aws.StringValue(m.MessageId), err)
+}
+
+// Attribute is a message attribute taken from a field.
+type Attribute struct {
+	Name string
+	// Type is the attribute data type, "String" or "Number".
+	Type  string
+	Value string
+}
+
+// encode returns the body of msg encoded as contentType; an empty
+// contentType selects ContentTypeProto.
+func encode(contentType string, msg proto.Message) (string, string, error) {
+	switch contentType {
+	case "", ContentTypeProto:
+		b, err := proto.Marshal(msg)
+		if err != nil {
+			return "", "", err
+		}
+		return base64.StdEncoding.EncodeToString(b), ContentTypeProto, nil
+	case ContentTypeJSON:
+		s, err := new(jsonpb.Marshaler).MarshalToString(msg)
+		if err != nil {
+			return "", "", err
+		}
+		return s, ContentTypeJSON, nil
+	}
+	return "", "", fmt.Errorf("sqspb: unsupported content type %q", contentType)
+}
+
+// decode decodes body of contentType into msg.
+func decode(contentType, body string, msg proto.Message) error {
+	switch contentType {
+	case "", ContentTypeProto:
+		b, err := base64.StdEncoding.DecodeString(body)
+		if err != nil {
+			return err
+		}
+		return proto.Unmarshal(b, msg)
+	case ContentTypeJSON:
+		u := jsonpb.Unmarshaler{AllowUnknownFields: true}
+		return u.Unmarshal(bytes.NewReader([]byte(body)), msg)
+	}
+	return fmt.Errorf("sqspb: unsupported content type %q", contentType)
+}
+
+func sqsAttributes(contentType string, attrs []Attribute) map[string]*sqs.MessageAttributeValue {
+	m := make(map[string]*sqs.MessageAttributeValue, len(attrs)+1)
+	m[ContentTypeAttribute] = &sqs.MessageAttributeValue{DataType: aws.String("String"), StringValue: aws.String(contentType)}
+	for _, a := range attrs {
+		m[a.Name] = &sqs.MessageAttributeValue{DataType: aws.String(a.Type), StringValue: aws.String(a.Value)}
+	}
+	return m
+}
+
+func snsAttributes(contentType string, attrs []Attribute) map[string]*sns.MessageAttributeValue {
+	m := make(map[string]*sns.MessageAttributeValue, len(attrs)+1)
+	m[ContentTypeAttribute] = &sns.MessageAttributeValue{DataType: aws.String("String"), StringValue: aws.String(contentType)}
+	for _, a := range attrs {
+		m[a.Name] = &sns.MessageAttributeValue{DataType: aws.String(a.Type), StringValue: aws.String(a.Value)}
+	}
+	return m
+}
+
+// snsNotification is the envelope SNS wraps messages in when delivering
+// to a queue without raw message delivery.
+type snsNotification struct {
+	Type              string
+	TopicArn          string
+	Message           string
+	MessageAttributes map[string]struct {
+		Type  string
+		Value string
+	}
+}
+
+// unwrap returns the body and content type of m, unwrapping the envelope
+// of a message delivered by SNS.
+func unwrap(m *sqs.Message) (body, contentType string) {
+	body = aws.StringValue(m.Body)
+	if a := m.MessageAttributes[ContentTypeAttribute]; a != nil {
+		return body, aws.StringValue(a.StringValue)
+	}
+	var n snsNotification
+	if json.Unmarshal([]byte(body), &n) != nil || n.Type != "Notification" || n.TopicArn == "" {
+		return body, ""
+	}
+	return n.Message, n.MessageAttributes[ContentTypeAttribute].Value
+}
@@ -0,0 +1,70 @@
+// Package streamiter is the runtime support for code generated by
+// protoc-gen-go-streamiter. It adapts the Recv and Send methods of gRPC
+// streams to channels and, from Go 1.23, to iterators.
+//
+// Messages are only received when the consumer is ready for them, so a
+// slow consumer holds back the sender through gRPC flow control instead of
+// buffering without bound.
+package streamiter
+
+import (
+	"context"
+	"io"
+	"sync"
+)
+
+// Chan calls recv on a new goroutine and delivers the messages on the
+// returned channel, which holds up to buf of them. The channel is closed
+// when recv fails or ctx is done. wait blocks until then and returns the
+// error that ended the stream, or nil for io.EOF.
+//
+// The goroutine blocks while the channel is full, so a consumer that stops
+// reading early must cancel ctx.
+func Chan[T any](ctx context.Context, recv func() (T, error), buf int) (ch <-chan T, wait func() error) {
+	out := make(chan T, buf)
+	done := make(chan struct{})
+	var err error
+	go func() {
+		defer close(done)
+		defer close(out)
+		for {
+			m, rerr := recv()
+			if rerr != nil {
+				if rerr != io.EOF {
+					err = rerr
+				}
+				return
+			}
+			select {
+			case out <- m:
+			case <-ctx.Done():
+				err = ctx.Err()
+				return
+			}
+		}
+	}()
+	var once sync.Once
+	return out, func() error {
+		once.Do(func() { <-done })
+		return err
+	}
+}
+
+// SendAll calls send with every message received from ch until ch is
+// closed. It returns the first error of send, or the error of ctx if it is
+// done first.
+func SendAll[T any](ctx context.Context, send func(T) error, ch <-chan T) error {
+	for {
+		select {
+		case m, ok := <-ch:
+			if !ok {
+				return nil
+			}
+			if err := send(m); err != nil {
+				return err
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,45 @@
+//go:build go1.23
+
+package streamiter
+
+import (
+	"context"
+	"io"
+	"iter"
+)
+
+// Iter returns an iterator over the messages returned by recv. It ends
+// after io.EOF. Any other error is yielded once, with the zero message,
+// before the iterator ends.
+func Iter[T any](recv func() (T, error)) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		for {
+			m, err := recv()
+			if err == io.EOF {
+				return
+			}
+			if err != nil {
+				var zero T
+				yield(zero, err)
+				return
+			}
+			if !yield(m, nil) {
+				return
+			}
+		}
+	}
+}
+
+// SendSeq calls send with every message of seq. It returns the first error
+// of send, or the error of ctx once it is done.
+func SendSeq[T any](ctx context.Context, send func(T) error, seq iter.Seq[T]) error {
+	for m := range seq {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := send(m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,146 @@
+// Package summary is the runtime support for code generated by
+// protoc-gen-go-summary. It prints messages compactly, with long strings,
+// bytes, repeated and map fields cut as package logattr does for logs.
+package summary
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes/duration"
+	"github.com/golang/protobuf/ptypes/timestamp"
+
+	"github.com/f4tq/protoc-go-plugins/runtime/logattr"
+	"github.com/f4tq/protoc-go-plugins/runtime/redact"
+)
+
+// Printer accumulates the summary of a message. The zero value is ready to
+// use.
+type Printer struct {
+	b strings.Builder
+	// sep is written before the next field or item.
+	sep string
+}
+
+// String returns the summary printed so far.
+func (p *Printer) String() string {
+	return p.b.String()
+}
+
+// Begin starts the message whose Go type name is name.
+func (p *Printer) Begin(name string) {
+	p.b.WriteString(name)
+	p.b.WriteByte('{')
+	p.sep = ""
+}
+
+// End ends the message started by Begin.
+func (p *Printer) End() {
+	p.b.WriteByte('}')
+	p.sep = ", "
+}
+
+// Nil prints a nil message.
+func (p *Printer) Nil() {
+	p.b.WriteString("nil")
+	p.sep = ", "
+}
+
+// Field starts the field name; its value is printed next.
+func (p *Printer) Field(name string) {
+	p.b.WriteString(p.sep)
+	p.b.WriteString(name)
+	p.b.WriteString(": ")
+	p.sep = ""
+}
+
+// Quote prints s quoted, see logattr.Truncate.
+func (p *Printer) Quote(s string) {
+	p.b.WriteString(strconv.Quote(logattr.Truncate(s)))
+	p.sep = ", "
+}
+
+// Bytes prints a summary of b: its length.
+func (p *Printer) Bytes(b []byte) {
+	p.b.WriteString(logattr.ByteCount(b))
+	p.sep = ", "
+}
+
+// Redacted prints redact.Mask in place of a sensitive value.
+func (p *Printer) Redacted() {
+	p.b.WriteString(redact.Mask)
+	p.sep = ", "
+}
+
+// Value prints v in its default format.
+func (p *Printer) Value(v interface{}) {
+	fmt.Fprint(&p.b, v)
+	p.sep = ", "
+}
+
+// Time prints ts as an RFC 3339 time.
+func (p *Printer) Time(ts *timestamp.Timestamp) {
+	p.b.WriteString(ts.AsTime().Format(time.RFC3339Nano))
+	p.sep = ", "
+}
+
+// Duration prints d as a Go duration, e.g. "1m30s".
+func (p *Printer) Duration(d *duration.Duration) {
+	p.b.WriteString(d.AsDuration().String())
+	p.sep = ", "
+}
+
+// Proto prints m, a message without a generated summary, in the compact
+// text format, see logattr.Truncate.
+func (p *Printer) Proto(m proto.Message) {
+	p.b.WriteString(logattr.Truncate(proto.CompactTextString(m)))
+	p.sep = ", "
+}
+
+// List prints the repeated field s, each item printed by f. Items past
+// logattr.MaxItems are counted.
+func List[T any](p *Printer, s []T, f func(*Printer, T)) {
+	n := min(len(s), logattr.MaxItems)
+	p.b.WriteByte('[')
+	p.sep = ""
+	for _, v := range s[:n] {
+		p.b.WriteString(p.sep)
+		f(p, v)
+	}
+	if len(s) > n {
+		p.b.WriteString(p.sep)
+		p.b.WriteString("…(" + strconv.Itoa(len(s)-n) + " more)")
+	}
+	p.b.WriteByte(']')
+	p.sep = ", "
+}
+
+// Map prints the map field m, each value printed by f, in key order.
+// Entries past logattr.MaxItems are counted.
+func Map[K comparable, V any](p *Printer, m map[K]V, f func(*Printer, V)) {
+	keys := make([]string, 0, len(m))
+	values := make(map[string]V, len(m))
+	for k, v := range m {
+		key := fmt.Sprint(k)
+		keys = append(keys, key)
+		values[key] = v
+	}
+	sort.Strings(keys)
+	n := min(len(keys), logattr.MaxItems)
+	p.b.WriteByte('{')
+	p.sep = ""
+	for _, k := range keys[:n] {
+		p.Field(k)
+		f(p, values[k])
+	}
+	if len(keys) > n {
+		p.b.WriteString(p.sep)
+		p.b.WriteString("…(" + strconv.Itoa(len(keys)-n) + " more)")
+	}
+	p.b.WriteByte('}')
+	p.sep = ", "
+}
@@ -0,0 +1,82 @@
+// Package swaggerui is the runtime support for code generated by
+// protoc-gen-go-swaggerui. Handler serves Swagger UI for an OpenAPI
+// document embedded in the binary; the UI itself is loaded by the browser
+// from AssetsURL, so that the binaries do not carry it.
+package swaggerui
+
+import (
+	"html/template"
+	"net/http"
+	"strings"
+)
+
+// DefaultPrefix is the path the generated Register functions serve the UI
+// under.
+const DefaultPrefix = "/docs"
+
+// AssetsURL is the base URL of the swagger-ui-dist files the page loads.
+// Set it to a copy served alongside the API for hosts without access to
+// the CDN.
+var AssetsURL = "https://unpkg.com/swagger-ui-dist@5"
+
+var indexTmpl = template.Must(template.New("index").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+<link rel="stylesheet" href="{{.Assets}}/swagger-ui.css">
+</head>
+<body>
+<div id="swagger-ui"></div>
+<script src="{{.Assets}}/swagger-ui-bundle.js"></script>
+<script>
+window.ui = SwaggerUIBundle({url: {{.Spec}}, dom_id: "#swagger-ui"});
+</script>
+</body>
+</html>
+`))
+
+// Handler returns a handler serving Swagger UI for the OpenAPI document
+// spec at prefix + "/", and spec itself at prefix + "/openapi.yaml". A
+// request for prefix is redirected to prefix + "/". The handler expects
+// the full request path, so register it for both prefix and prefix + "/"
+// on an http.ServeMux, as Register does.
+func Handler(prefix, title, spec string) http.Handler {
+	prefix = strings.TrimSuffix(prefix, "/")
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		switch r.URL.Path {
+		case prefix:
+			http.Redirect(w, r, prefix+"/", http.StatusMovedPermanently)
+		case prefix + "/", prefix + "/index.html":
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			indexTmpl.Execute(w, map[string]string{
+				"Title":  title,
+				"Assets": strings.TrimSuffix(AssetsURL, "/"),
+				"Spec":   prefix + "/openapi.yaml",
+			})
+		case prefix + "/openapi.yaml":
+			w.Header().Set("Content-Type", "application/yaml")
+			w.Write([]byte(spec))
+		default:
+			http.NotFound(w, r)
+		}
+	})
+}
+
+// Register serves the handler of prefix, title and spec on mux, under
+// DefaultPrefix if prefix is "".
+func Register(mux *http.ServeMux, prefix, title, spec string) {
+	if prefix == "" {
+		prefix = DefaultPrefix
+	}
+	prefix = strings.TrimSuffix(prefix, "/")
+	h := Handler(prefix, title, spec)
+	mux.Handle(prefix, h)
+	mux.Handle(prefix+"/", h)
+}
@@ -0,0 +1,138 @@
+// Package tfconv is the runtime support for code generated by
+// protoc-gen-go-terraform: it converts the values of message fields to and
+// from the values of the terraform-plugin-framework. The optional
+// attributes of fields with their zero values are null, as they are when
+// left out of the configuration.
+package tfconv
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/duration"
+	"github.com/golang/protobuf/ptypes/timestamp"
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+// Known reports whether v is neither null nor unknown.
+func Known(v attr.Value) bool {
+	return !v.IsNull() && !v.IsUnknown()
+}
+
+// String returns v, or null if omitZero and v is empty.
+func String(v string, omitZero bool) types.String {
+	if omitZero && v == "" {
+		return types.StringNull()
+	}
+	return types.StringValue(v)
+}
+
+// Bool returns v, or null if omitZero and v is false.
+func Bool(v bool, omitZero bool) types.Bool {
+	if omitZero && !v {
+		return types.BoolNull()
+	}
+	return types.BoolValue(v)
+}
+
+// Int64 returns v, or null if omitZero and v is 0.
+func Int64(v int64, omitZero bool) types.Int64 {
+	if omitZero && v == 0 {
+		return types.Int64Null()
+	}
+	return types.Int64Value(v)
+}
+
+// Float64 returns v, or null if omitZero and v is 0.
+func Float64(v float64, omitZero bool) types.Float64 {
+	if omitZero && v == 0 {
+		return types.Float64Null()
+	}
+	return types.Float64Value(v)
+}
+
+// EnumName returns the name of the enum value v, looked up in names, the
+// <Enum>_name map of its Go type, or null if omitZero and v is 0. Values
+// without names are written as numbers.
+func EnumName(v int32, names map[int32]string, omitZero bool) types.String {
+	if omitZero && v == 0 {
+		return types.StringNull()
+	}
+	if name, ok := names[v]; ok {
+		return types.StringValue(name)
+	}
+	return types.StringValue(strconv.Itoa(int(v)))
+}
+
+// EnumValue returns the enum value named v, looked up in values, the
+// <Enum>_value map of its Go type.
+func EnumValue(v types.String, values map[string]int32) (int32, error) {
+	if n, ok := values[v.ValueString()]; ok {
+		return n, nil
+	}
+	n, err := strconv.ParseInt(v.ValueString(), 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("unknown enum value %q", v.ValueString())
+	}
+	return int32(n), nil
+}
+
+// Bytes returns v in base64, or null if omitZero and v is empty.
+func Bytes(v []byte, omitZero bool) types.String {
+	if omitZero && len(v) == 0 {
+		return types.StringNull()
+	}
+	return types.StringValue(base64.StdEncoding.EncodeToString(v))
+}
+
+// ParseBytes decodes the base64 of v.
+func ParseBytes(v types.String) ([]byte, error) {
+	return base64.StdEncoding.DecodeString(v.ValueString())
+}
+
+// Timestamp returns v in RFC 3339, or null if v is nil or invalid.
+func Timestamp(v *timestamp.Timestamp) types.String {
+	if v == nil {
+		return types.StringNull()
+	}
+	t, err := ptypes.Timestamp(v)
+	if err != nil {
+		return types.StringNull()
+	}
+	return types.StringValue(t.UTC().Format(time.RFC3339Nano))
+}
+
+// ParseTimestamp decodes the RFC 3339 time of v.
+func ParseTimestamp(v types.String) (*timestamp.Timestamp, error) {
+	t, err := time.Parse(time.RFC3339Nano, v.ValueString())
+	if err != nil {
+		return nil, err
+	}
+	return ptypes.TimestampProto(t)
+}
+
+// Duration returns v as a Go duration, e.g. "1m30s", or null if v is nil
+// or invalid.
+func Duration(v *duration.Duration) types.String {
+	if v == nil {
+		return types.StringNull()
+	}
+	d, err := ptypes.Duration(v)
+	if err != nil {
+		return types.StringNull()
+	}
+	return types.StringValue(d.String())
+}
+
+// ParseDuration decodes the Go duration of v.
+func ParseDuration(v types.String) (*duration.Duration, error) {
+	d, err := time.ParseDuration(v.ValueString())
+	if err != nil {
+		return nil, err
+	}
+	return ptypes.DurationProto(d), nil
+}
@@ -0,0 +1,89 @@
+package validate
+
+import (
+	"fmt"
+	"strings"
+)
+
+// FieldError is a violation of the rules of one field, returned by code
+// generated by protoc-gen-go-validate.
+type FieldError struct {
+	field  string
+	reason string
+}
+
+// Violation returns the FieldError of field, a dotted proto field path.
+func Violation(field, reason string) *FieldError {
+	return &FieldError{field: field, reason: reason}
+}
+
+// Field returns the path of the invalid field, e.g. "address.city".
+func (e *FieldError) Field() string {
+	return e.field
+}
+
+// Reason describes the violated rule.
+func (e *FieldError) Reason() string {
+	return e.reason
+}
+
+func (e *FieldError) Error() string {
+	return e.field + ": " + e.reason
+}
+
+// MultiError holds every violation found by a generated ValidateAll.
+type MultiError []error
+
+func (m MultiError) Error() string {
+	msgs := make([]string, len(m))
+	for i, err := range m {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// AllErrors returns the violations.
+func (m MultiError) AllErrors() []error {
+	return m
+}
+
+// Index returns the path of the element of field at key, e.g. "tags[2]".
+func Index(field string, key interface{}) string {
+	return fmt.Sprintf("%s[%v]", field, key)
+}
+
+// allValidator is implemented by messages with a generated ValidateAll.
+type allValidator interface {
+	ValidateAll() error
+}
+
+// Message validates m, the value of the message field field, if it has
+// generated validation methods, and returns its violations with their
+// paths prefixed by field. All violations are returned if all is set and
+// m has ValidateAll, else the first.
+func Message(field string, m interface{}, all bool) []error {
+	var err error
+	if v, ok := m.(allValidator); ok && all {
+		err = v.ValidateAll()
+	} else if v, ok := m.(Validator); ok {
+		err = v.Validate()
+	}
+	if err == nil {
+		return nil
+	}
+	return prefix(field, err)
+}
+
+func prefix(field string, err error) []error {
+	switch e := err.(type) {
+	case MultiError:
+		var errs []error
+		for _, err := range e {
+			errs = append(errs, prefix(field, err)...)
+		}
+		return errs
+	case *FieldError:
+		return []error{Violation(field+"."+e.field, e.reason)}
+	}
+	return []error{fmt.Errorf("%s: %w", field, err)}
+}
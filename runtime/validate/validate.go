@@ -0,0 +1,147 @@
+// Package validate is the runtime support for code generated by
+// protoc-gen-go-validate and protoc-gen-go-validate-interceptor. It holds
+// the violations reported by generated Validate methods, validates
+// incoming requests that have one and reports violations as
+// INVALID_ARGUMENT.
+package validate
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Validator is implemented by messages with a generated Validate method.
+type Validator interface {
+	Validate() error
+}
+
+// Error is returned for a request that fails validation. It converts to an
+// INVALID_ARGUMENT status with a BadRequest detail listing the field
+// violations, and reports HTTP status 400 to runtime/httpgw.
+type Error struct {
+	Err        error
+	Violations []*errdetails.BadRequest_FieldViolation
+}
+
+func (e *Error) Error() string {
+	return "invalid request: " + e.Err.Error()
+}
+
+// Unwrap returns the error reported by Validate.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// GRPCStatus returns the INVALID_ARGUMENT status of e.
+func (e *Error) GRPCStatus() *status.Status {
+	st := status.New(codes.InvalidArgument, e.Error())
+	if len(e.Violations) == 0 {
+		return st
+	}
+	if d, err := st.WithDetails(&errdetails.BadRequest{FieldViolations: e.Violations}); err == nil {
+		return d
+	}
+	return st
+}
+
+// HTTPStatus returns http.StatusBadRequest.
+func (e *Error) HTTPStatus() int {
+	return http.StatusBadRequest
+}
+
+// Request validates req if it implements Validator, and returns an *Error
+// if it is invalid.
+func Request(req interface{}) error {
+	v, ok := req.(Validator)
+	if !ok {
+		return nil
+	}
+	err := v.Validate()
+	if err == nil {
+		return nil
+	}
+	return &Error{Err: err, Violations: violations(err)}
+}
+
+// fieldError is implemented by the per-field errors of
+// protoc-gen-validate and protoc-gen-go-validate.
+type fieldError interface {
+	Field() string
+	Reason() string
+}
+
+// multiError is implemented by errors aggregating several violations.
+type multiError interface {
+	AllErrors() []error
+}
+
+func violations(err error) []*errdetails.BadRequest_FieldViolation {
+	if m, ok := err.(multiError); ok {
+		var vs []*errdetails.BadRequest_FieldViolation
+		for _, e := range m.AllErrors() {
+			vs = append(vs, violations(e)...)
+		}
+		return vs
+	}
+	if f, ok := err.(fieldError); ok {
+		return []*errdetails.BadRequest_FieldViolation{{Field: f.Field(), Description: f.Reason()}}
+	}
+	return nil
+}
+
+// covers reports whether fullMethod ("/pkg.Service/Method") belongs to one
+// of services, or whether services is empty.
+func covers(services []string, fullMethod string) bool {
+	if len(services) == 0 {
+		return true
+	}
+	for _, s := range services {
+		if strings.HasPrefix(fullMethod, "/"+s+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// UnaryServerInterceptor validates the requests of unary calls to
+// services, given by their full proto names, or of every service if none
+// are given.
+func UnaryServerInterceptor(services ...string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if covers(services, info.FullMethod) {
+			if err := Request(req); err != nil {
+				return nil, err
+			}
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor validates every message received on streams of
+// services, given by their full proto names, or of every service if none
+// are given.
+func StreamServerInterceptor(services ...string) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if !covers(services, info.FullMethod) {
+			return handler(srv, ss)
+		}
+		return handler(srv, &validatingStream{ss})
+	}
+}
+
+type validatingStream struct {
+	grpc.ServerStream
+}
+
+func (s *validatingStream) RecvMsg(m interface{}) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+	return Request(m)
+}
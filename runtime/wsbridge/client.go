@@ -0,0 +1,90 @@
+package wsbridge
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Dialer opens bridged streams.
+type Dialer struct {
+	// BaseURL is the ws:// or wss:// URL the method paths are appended
+	// to.
+	BaseURL string
+	// Subprotocol is SubprotocolJSON (the default) or SubprotocolProto.
+	Subprotocol string
+	// Header is sent with every upgrade request.
+	Header http.Header
+	// Dialer opens the connections; websocket.DefaultDialer if nil.
+	Dialer *websocket.Dialer
+}
+
+// NewDialer returns a Dialer for the server at baseURL using JSON.
+func NewDialer(baseURL string) *Dialer {
+	return &Dialer{BaseURL: strings.TrimSuffix(baseURL, "/"), Subprotocol: SubprotocolJSON}
+}
+
+// Dial opens a stream to the method at path.
+func (d *Dialer) Dial(ctx context.Context, path string) (*ClientStream, error) {
+	subprotocol := d.Subprotocol
+	if subprotocol != SubprotocolProto {
+		subprotocol = SubprotocolJSON
+	}
+	dialer := websocket.DefaultDialer
+	if d.Dialer != nil {
+		dialer = d.Dialer
+	}
+	wd := *dialer
+	wd.Subprotocols = []string{subprotocol}
+	conn, _, err := wd.DialContext(ctx, d.BaseURL+path, d.Header)
+	if err != nil {
+		return nil, status.Errorf(codes.Unavailable, "wsbridge: %v", err)
+	}
+	return &ClientStream{conn: conn, codec: newCodec(subprotocol)}, nil
+}
+
+// ClientStream is the client side of a bridged stream.
+type ClientStream struct {
+	conn  *websocket.Conn
+	codec codec
+}
+
+// SendMsg sends m, which must be a proto.Message.
+func (s *ClientStream) SendMsg(m interface{}) error {
+	return s.codec.write(s.conn, m)
+}
+
+// CloseSend tells the server no more messages will be sent.
+func (s *ClientStream) CloseSend() error {
+	return s.conn.WriteMessage(s.codec.closeSendType(), nil)
+}
+
+// RecvMsg receives the next message into m, which must be a
+// proto.Message. It returns io.EOF once the server ended the stream
+// successfully, or the status error it ended with.
+func (s *ClientStream) RecvMsg(m interface{}) error {
+	err := s.codec.read(s.conn, m)
+	switch err.(type) {
+	case nil:
+		return nil
+	case interface{ GRPCStatus() *status.Status }:
+		// A message that could not be decoded.
+		return err
+	}
+	if err == io.EOF {
+		return err
+	}
+	return closeError(err)
+}
+
+// Close closes the connection.
+func (s *ClientStream) Close() error {
+	s.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(closeTimeout))
+	return s.conn.Close()
+}
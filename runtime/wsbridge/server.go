@@ -0,0 +1,95 @@
+package wsbridge
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"google.golang.org/grpc/metadata"
+)
+
+// DefaultUpgrader upgrades the requests served by Handle. Its CheckOrigin
+// is nil, so cross-origin requests are rejected; replace it to allow them.
+var DefaultUpgrader = &websocket.Upgrader{
+	Subprotocols: []string{SubprotocolJSON, SubprotocolProto},
+}
+
+// closeTimeout bounds the time spent writing the final close frame.
+const closeTimeout = 5 * time.Second
+
+// Handle returns a handler that upgrades requests to WebSocket and serves
+// each connection with fn, closing it with the error fn returns.
+func Handle(fn func(stream *ServerStream) error) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := DefaultUpgrader.Upgrade(w, r, nil)
+		if err != nil {
+			// Upgrade has already replied with an HTTP error.
+			return
+		}
+		defer conn.Close()
+		ctx, cancel := context.WithCancel(r.Context())
+		defer cancel()
+		md := metadata.MD{}
+		for _, k := range []string{"Authorization", "User-Agent"} {
+			if v := r.Header.Get(k); v != "" {
+				md.Set(k, v)
+			}
+		}
+		stream := &ServerStream{
+			ctx:   metadata.NewIncomingContext(ctx, md),
+			conn:  conn,
+			codec: newCodec(conn.Subprotocol()),
+		}
+		err = fn(stream)
+		conn.WriteControl(websocket.CloseMessage, closeMessage(err), time.Now().Add(closeTimeout))
+	})
+}
+
+// ServerStream is the server side of a bridged stream. It implements
+// grpc.ServerStream so that gRPC service implementations can serve
+// WebSocket clients unchanged.
+type ServerStream struct {
+	ctx   context.Context
+	conn  *websocket.Conn
+	codec codec
+}
+
+// Context returns the context of the stream. Incoming metadata holds the
+// Authorization and User-Agent headers of the upgrade request.
+func (s *ServerStream) Context() context.Context {
+	return s.ctx
+}
+
+// SetHeader is a no-op: a WebSocket has no response headers after the
+// upgrade.
+func (s *ServerStream) SetHeader(metadata.MD) error {
+	return nil
+}
+
+// SendHeader is a no-op; see SetHeader.
+func (s *ServerStream) SendHeader(metadata.MD) error {
+	return nil
+}
+
+// SetTrailer is a no-op: trailers cannot be carried by a close frame.
+func (s *ServerStream) SetTrailer(metadata.MD) {}
+
+// SendMsg sends m, which must be a proto.Message.
+func (s *ServerStream) SendMsg(m interface{}) error {
+	return s.codec.write(s.conn, m)
+}
+
+// RecvMsg receives the next message into m, which must be a
+// proto.Message. It returns io.EOF once the client half-closed or closed
+// the connection.
+func (s *ServerStream) RecvMsg(m interface{}) error {
+	err := s.codec.read(s.conn, m)
+	var ce *websocket.CloseError
+	if errors.As(err, &ce) {
+		return io.EOF
+	}
+	return err
+}
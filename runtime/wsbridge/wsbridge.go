@@ -0,0 +1,152 @@
+// Package wsbridge is the runtime support for code generated by
+// protoc-gen-go-websocket. It carries the messages of streaming RPCs over a
+// WebSocket connection so that browsers can consume them.
+//
+// Each message travels in its own WebSocket message: a text message
+// holding JSON when the "json" subprotocol is negotiated (the default), or
+// a binary message holding the proto encoding with the "proto"
+// subprotocol. A zero-length message of the other type half-closes the
+// sender's side. The server ends the stream with a close frame whose code
+// is 1000 on success, or 4000 plus the gRPC status code on failure, with
+// the status message as the reason.
+package wsbridge
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+	"github.com/gorilla/websocket"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Subprotocols selecting the message encoding.
+const (
+	SubprotocolJSON  = "json"
+	SubprotocolProto = "proto"
+)
+
+// closeCodeBase is added to gRPC status codes to form close codes in the
+// range reserved for applications.
+const closeCodeBase = 4000
+
+// maxCloseReason is the longest reason a close frame can carry.
+const maxCloseReason = 123
+
+var (
+	jsonMarshaler   = &jsonpb.Marshaler{}
+	jsonUnmarshaler = &jsonpb.Unmarshaler{AllowUnknownFields: true}
+)
+
+// codec encodes messages for one negotiated subprotocol.
+type codec struct {
+	proto bool
+}
+
+func newCodec(subprotocol string) codec {
+	return codec{proto: subprotocol == SubprotocolProto}
+}
+
+// messageType returns the WebSocket message type carrying data.
+func (c codec) messageType() int {
+	if c.proto {
+		return websocket.BinaryMessage
+	}
+	return websocket.TextMessage
+}
+
+// closeSendType returns the WebSocket message type signaling a half-close.
+func (c codec) closeSendType() int {
+	if c.proto {
+		return websocket.TextMessage
+	}
+	return websocket.BinaryMessage
+}
+
+func (c codec) marshal(m interface{}) ([]byte, error) {
+	msg, ok := m.(proto.Message)
+	if !ok {
+		return nil, status.Errorf(codes.Internal, "wsbridge: %T is not a proto.Message", m)
+	}
+	if c.proto {
+		return proto.Marshal(msg)
+	}
+	var buf bytes.Buffer
+	if err := jsonMarshaler.Marshal(&buf, msg); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (c codec) unmarshal(data []byte, m interface{}) error {
+	msg, ok := m.(proto.Message)
+	if !ok {
+		return status.Errorf(codes.Internal, "wsbridge: %T is not a proto.Message", m)
+	}
+	if c.proto {
+		return proto.Unmarshal(data, msg)
+	}
+	return jsonUnmarshaler.Unmarshal(bytes.NewReader(data), msg)
+}
+
+// read reads the next message into m. It returns io.EOF when the peer
+// half-closed.
+func (c codec) read(conn *websocket.Conn, m interface{}) error {
+	for {
+		typ, data, err := conn.ReadMessage()
+		if err != nil {
+			return err
+		}
+		switch typ {
+		case c.messageType():
+			if err := c.unmarshal(data, m); err != nil {
+				return status.Errorf(codes.InvalidArgument, "wsbridge: decoding message: %v", err)
+			}
+			return nil
+		case c.closeSendType():
+			if len(data) == 0 {
+				return io.EOF
+			}
+			return status.Error(codes.InvalidArgument, "wsbridge: unexpected message type")
+		}
+	}
+}
+
+func (c codec) write(conn *websocket.Conn, m interface{}) error {
+	data, err := c.marshal(m)
+	if err != nil {
+		return err
+	}
+	return conn.WriteMessage(c.messageType(), data)
+}
+
+// closeMessage returns the close frame ending a stream with err.
+func closeMessage(err error) []byte {
+	if err == nil {
+		return websocket.FormatCloseMessage(websocket.CloseNormalClosure, "")
+	}
+	st := status.Convert(err)
+	reason := st.Message()
+	if len(reason) > maxCloseReason {
+		reason = reason[:maxCloseReason]
+	}
+	return websocket.FormatCloseMessage(closeCodeBase+int(st.Code()), reason)
+}
+
+// closeError converts the close frame received by a client into the
+// stream's final error: io.EOF on success.
+func closeError(err error) error {
+	ce, ok := err.(*websocket.CloseError)
+	if !ok {
+		return status.Errorf(codes.Unavailable, "wsbridge: %v", err)
+	}
+	switch {
+	case ce.Code == websocket.CloseNormalClosure:
+		return io.EOF
+	case ce.Code >= closeCodeBase && ce.Code <= closeCodeBase+int(codes.Unauthenticated):
+		return status.Error(codes.Code(ce.Code-closeCodeBase), ce.Text)
+	}
+	return status.Errorf(codes.Unavailable, "wsbridge: connection closed: %d %s", ce.Code, ce.Text)
+}
@@ -0,0 +1,57 @@
+// Package zaplog is the runtime support for code generated by
+// protoc-gen-go-zap. It bounds repeated and map fields the way package
+// logattr does for slog.
+package zaplog
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+
+	"go.uber.org/zap/zapcore"
+
+	"github.com/f4tq/protoc-go-plugins/runtime/logattr"
+)
+
+// Array returns the marshaler of the repeated field s, each item appended
+// by add. Items past logattr.MaxItems are counted in a final string item.
+func Array[T any](s []T, add func(zapcore.ArrayEncoder, T) error) zapcore.ArrayMarshaler {
+	return zapcore.ArrayMarshalerFunc(func(enc zapcore.ArrayEncoder) error {
+		n := min(len(s), logattr.MaxItems)
+		for _, v := range s[:n] {
+			if err := add(enc, v); err != nil {
+				return err
+			}
+		}
+		if len(s) > n {
+			enc.AppendString("…(" + strconv.Itoa(len(s)-n) + " more items)")
+		}
+		return nil
+	})
+}
+
+// Map returns the marshaler of the map field m, each entry added by add
+// under its formatted key, in key order. Entries past logattr.MaxItems are
+// counted in a "more" field.
+func Map[K comparable, V any](m map[K]V, add func(zapcore.ObjectEncoder, string, V) error) zapcore.ObjectMarshaler {
+	return zapcore.ObjectMarshalerFunc(func(enc zapcore.ObjectEncoder) error {
+		keys := make([]string, 0, len(m))
+		values := make(map[string]V, len(m))
+		for k, v := range m {
+			key := fmt.Sprint(k)
+			keys = append(keys, key)
+			values[key] = v
+		}
+		sort.Strings(keys)
+		n := min(len(keys), logattr.MaxItems)
+		for _, k := range keys[:n] {
+			if err := add(enc, k, values[k]); err != nil {
+				return err
+			}
+		}
+		if len(keys) > n {
+			enc.AddInt("more", len(keys)-n)
+		}
+		return nil
+	})
+}